@@ -98,7 +98,8 @@ func (s *SocketStatsV3) QueueFreezes() uint {
 	return uint(s.tp_freeze_q_cnt)
 }
 
-// TPacket implements packet receiving for Linux AF_PACKET versions 1, 2, and 3.
+// TPacket implements packet receiving and sending for Linux AF_PACKET versions
+// 1, 2, and 3.
 type TPacket struct {
 	// stats is simple statistics on TPacket's run. This MUST be the first entry to ensure alignment for sync.atomic
 	stats Stats
@@ -108,6 +109,8 @@ type TPacket struct {
 	ring []byte
 	// rawring is the unsafe pointer that we use to poll for packets
 	rawring unsafe.Pointer
+	// uring is used to wait for packets instead of poll if OptIOURing is set.
+	uring *ioURing
 	// opts contains read-only options for the TPacket object.
 	opts options
 	mu   sync.Mutex // guards below
@@ -126,6 +129,12 @@ type TPacket struct {
 	// so we leave it in the TPacket object and return a pointer to it.
 	v3 v3wrapper
 
+	txMu sync.Mutex // guards below
+	// txRing is the part of ring holding the TX ring, if OptTXNumBlocks is set.
+	txRing []byte
+	// txOffset is the index of the next frame of the TX ring to fill.
+	txOffset int
+
 	statsMu sync.Mutex // guards stats below
 	// socketStats contains stats from the socket
 	socketStats SocketStats
@@ -176,33 +185,53 @@ func (h *TPacket) setRequestedTPacketVersion() error {
 	return nil
 }
 
-// setUpRing sets up the shared-memory ring buffer between the user process and the kernel.
-func (h *TPacket) setUpRing() (err error) {
-	totalSize := int(h.opts.framesPerBlock * h.opts.numBlocks * h.opts.frameSize)
+// setRing asks the kernel to set up the RX or TX ring, given by opt, with
+// numBlocks blocks.
+func (h *TPacket) setRing(opt int, name string, numBlocks int) error {
 	switch h.tpVersion {
 	case TPacketVersion1, TPacketVersion2:
 		var tp C.struct_tpacket_req
 		tp.tp_block_size = C.uint(h.opts.blockSize)
-		tp.tp_block_nr = C.uint(h.opts.numBlocks)
+		tp.tp_block_nr = C.uint(numBlocks)
 		tp.tp_frame_size = C.uint(h.opts.frameSize)
-		tp.tp_frame_nr = C.uint(h.opts.framesPerBlock * h.opts.numBlocks)
-		if err := setsockopt(h.fd, unix.SOL_PACKET, unix.PACKET_RX_RING, unsafe.Pointer(&tp), unsafe.Sizeof(tp)); err != nil {
-			return fmt.Errorf("setsockopt packet_rx_ring: %v", err)
+		tp.tp_frame_nr = C.uint(h.opts.framesPerBlock * numBlocks)
+		if err := setsockopt(h.fd, unix.SOL_PACKET, opt, unsafe.Pointer(&tp), unsafe.Sizeof(tp)); err != nil {
+			return fmt.Errorf("setsockopt %s: %v", name, err)
 		}
 	case TPacketVersion3:
 		var tp C.struct_tpacket_req3
 		tp.tp_block_size = C.uint(h.opts.blockSize)
-		tp.tp_block_nr = C.uint(h.opts.numBlocks)
+		tp.tp_block_nr = C.uint(numBlocks)
 		tp.tp_frame_size = C.uint(h.opts.frameSize)
-		tp.tp_frame_nr = C.uint(h.opts.framesPerBlock * h.opts.numBlocks)
-		tp.tp_retire_blk_tov = C.uint(h.opts.blockTimeout / time.Millisecond)
-		if err := setsockopt(h.fd, unix.SOL_PACKET, unix.PACKET_RX_RING, unsafe.Pointer(&tp), unsafe.Sizeof(tp)); err != nil {
-			return fmt.Errorf("setsockopt packet_rx_ring v3: %v", err)
+		tp.tp_frame_nr = C.uint(h.opts.framesPerBlock * numBlocks)
+		// The kernel rejects a block timeout for the TX ring, whose
+		// frames it doesn't gather in blocks.
+		if opt == unix.PACKET_RX_RING {
+			tp.tp_retire_blk_tov = C.uint(h.opts.blockTimeout / time.Millisecond)
+		}
+		if err := setsockopt(h.fd, unix.SOL_PACKET, opt, unsafe.Pointer(&tp), unsafe.Sizeof(tp)); err != nil {
+			return fmt.Errorf("setsockopt %s v3: %v", name, err)
 		}
 	default:
 		return errors.New("invalid tpVersion")
 	}
-	h.ring, err = unix.Mmap(h.fd, 0, totalSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	return nil
+}
+
+// setUpRing sets up the shared-memory ring buffers between the user process
+// and the kernel. The TX ring, if any, is mapped right after the RX ring.
+func (h *TPacket) setUpRing() (err error) {
+	rxSize := int(h.opts.framesPerBlock * h.opts.numBlocks * h.opts.frameSize)
+	txSize := int(h.opts.framesPerBlock * h.opts.txNumBlocks * h.opts.frameSize)
+	if err = h.setRing(unix.PACKET_RX_RING, "packet_rx_ring", h.opts.numBlocks); err != nil {
+		return err
+	}
+	if h.opts.txNumBlocks > 0 {
+		if err = h.setRing(unix.PACKET_TX_RING, "packet_tx_ring", h.opts.txNumBlocks); err != nil {
+			return err
+		}
+	}
+	h.ring, err = unix.Mmap(h.fd, 0, rxSize+txSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
 	if err != nil {
 		return err
 	}
@@ -210,6 +239,9 @@ func (h *TPacket) setUpRing() (err error) {
 		return errors.New("no ring")
 	}
 	h.rawring = unsafe.Pointer(&h.ring[0])
+	if txSize > 0 {
+		h.txRing = h.ring[rxSize:]
+	}
 	return nil
 }
 
@@ -222,6 +254,11 @@ func (h *TPacket) Close() {
 		unix.Munmap(h.ring)
 	}
 	h.ring = nil
+	h.txRing = nil
+	if h.uring != nil {
+		h.uring.close()
+		h.uring = nil
+	}
 	unix.Close(h.fd)
 	h.fd = -1
 	runtime.SetFinalizer(h, nil)
@@ -251,6 +288,12 @@ func NewTPacket(opts ...interface{}) (h *TPacket, err error) {
 	if err = h.setUpRing(); err != nil {
 		goto errlbl
 	}
+	if h.opts.ioURing {
+		if h.uring, err = newIOURing(h.fd); err != nil {
+			err = fmt.Errorf("io_uring: %v", err)
+			goto errlbl
+		}
+	}
 	// Clear stat counter from socket
 	if err = h.InitSocketStats(); err != nil {
 		goto errlbl
@@ -457,6 +500,12 @@ func (h *TPacket) getTPacketHeader() header {
 func (h *TPacket) pollForFirstPacket(hdr header) error {
 	tm := int(h.opts.pollTimeout / time.Millisecond)
 	for hdr.getStatus()&unix.TP_STATUS_USER == 0 {
+		if h.uring != nil {
+			if err := h.uring.wait(h.opts.pollTimeout, &h.stats.Polls); err != nil {
+				return err
+			}
+			continue
+		}
 		pollset := [1]unix.PollFd{
 			{
 				Fd:     int32(h.fd),
@@ -516,8 +565,76 @@ func (h *TPacket) SetFanout(t FanoutType, id uint16) error {
 	return setsockopt(h.fd, unix.SOL_PACKET, unix.PACKET_FANOUT, unsafe.Pointer(&arg), unsafe.Sizeof(arg))
 }
 
-// WritePacketData transmits a raw packet.
+// WritePacketData transmits a raw packet. With a TX ring, set up by passing
+// OptTXNumBlocks to NewTPacket, the packet is copied into its next frame and
+// sent from there, and WritePacketData returns once the kernel sent it.
 func (h *TPacket) WritePacketData(pkt []byte) error {
-	_, err := unix.Write(h.fd, pkt)
+	if h.txRing == nil {
+		_, err := unix.Write(h.fd, pkt)
+		return err
+	}
+	h.txMu.Lock()
+	defer h.txMu.Unlock()
+	offset := txDataOffset(h.tpVersion)
+	if len(pkt) > h.opts.frameSize-offset {
+		return fmt.Errorf("packet length %d exceeds the %d bytes of TX frames", len(pkt), h.opts.frameSize-offset)
+	}
+	frame := h.txRing[h.txOffset*h.opts.frameSize : (h.txOffset+1)*h.opts.frameSize]
+	hdr := txHeaderAt(h.tpVersion, unsafe.Pointer(&frame[0]))
+	if err := h.pollForTXFrame(hdr); err != nil {
+		return err
+	}
+	copy(frame[offset:], pkt)
+	hdr.setLength(len(pkt))
+	hdr.setStatus(unix.TP_STATUS_SEND_REQUEST)
+	h.txOffset = (h.txOffset + 1) % (h.opts.framesPerBlock * h.opts.txNumBlocks)
+	// A blocking send of nothing sends the frames handed to the kernel. A
+	// write of nothing wouldn't reach the socket.
+	var err error
+	if _, _, errno := unix.Syscall6(unix.SYS_SENDTO, uintptr(h.fd), 0, 0, 0, 0, 0); errno != 0 {
+		err = errno
+	}
+	if hdr.getStatus()&unix.TP_STATUS_WRONG_FORMAT != 0 {
+		// The kernel leaves frames it can't send in the ring, and stops
+		// sending at them.
+		hdr.setStatus(unix.TP_STATUS_AVAILABLE)
+		if err == nil {
+			err = errors.New("packet rejected by the kernel")
+		}
+	}
 	return err
 }
+
+// pollForTXFrame waits until the kernel is done with the TX frame of hdr.
+func (h *TPacket) pollForTXFrame(hdr txHeader) error {
+	tm := int(h.opts.pollTimeout / time.Millisecond)
+	for {
+		status := hdr.getStatus()
+		if status == unix.TP_STATUS_AVAILABLE {
+			return nil
+		}
+		if status&unix.TP_STATUS_WRONG_FORMAT != 0 {
+			hdr.setStatus(unix.TP_STATUS_AVAILABLE)
+			return nil
+		}
+		pollset := [1]unix.PollFd{
+			{
+				Fd:     int32(h.fd),
+				Events: unix.POLLOUT,
+			},
+		}
+		n, err := unix.Poll(pollset[:], tm)
+		if n == 0 {
+			return ErrTimeout
+		}
+		if pollset[0].Revents&unix.POLLERR > 0 {
+			return ErrPoll
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -9,8 +9,10 @@
 package afpacket
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseOptions(t *testing.T) {
@@ -26,6 +28,7 @@ func TestParseOptions(t *testing.T) {
 		{opts: []interface{}{OptFrameSize(333)}, err: true},
 		{opts: []interface{}{OptTPacketVersion(-3)}, err: true},
 		{opts: []interface{}{OptTPacketVersion(5)}, err: true},
+		{opts: []interface{}{OptTXNumBlocks(-1)}, err: true},
 		{opts: []interface{}{OptFrameSize(1 << 10)}, want: wanted1},
 	} {
 		got, err := parseOptions(test.opts...)
@@ -38,3 +41,47 @@ func TestParseOptions(t *testing.T) {
 		}
 	}
 }
+
+func TestWritePacketDataTXRing(t *testing.T) {
+	frame := []byte{
+		0, 0, 0, 0, 0, 0, // destination
+		0, 0, 0, 0, 0, 0, // source
+		0x88, 0xb5, // local experimental EtherType
+		'g', 'o', 'p', 'a', 'c', 'k', 'e', 't',
+	}
+	for _, version := range []OptTPacketVersion{TPacketVersion1, TPacketVersion2, TPacketVersion3} {
+		opts := []interface{}{OptInterface("lo"), OptTPacketVersion(version), OptFrameSize(2048),
+			OptBlockSize(pageSize), OptNumBlocks(16), OptPollTimeout(time.Second)}
+		rx, err := NewTPacket(opts...)
+		if err != nil {
+			t.Skipf("can't capture on lo: %v", err)
+		}
+		defer rx.Close()
+		tx, err := NewTPacket(append(opts, OptTXNumBlocks(1))...)
+		if err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+		defer tx.Close()
+		if err := tx.WritePacketData(make([]byte, 2048)); err == nil {
+			t.Errorf("%v: packet longer than TX frames written", version)
+		}
+		// More packets than TX frames, to reuse them.
+		n := 2*pageSize/2048 + 1
+		for i := 0; i < n; i++ {
+			frame[len(frame)-1] = byte(i)
+			if err := tx.WritePacketData(frame); err != nil {
+				t.Fatalf("%v: write %d: %v", version, i, err)
+			}
+		}
+		for i := 0; i < n; {
+			data, _, err := rx.ReadPacketData()
+			if err != nil {
+				t.Fatalf("%v: read %d: %v", version, i, err)
+			}
+			frame[len(frame)-1] = byte(i)
+			if bytes.Equal(data, frame) {
+				i++
+			}
+		}
+	}
+}
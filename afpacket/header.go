@@ -10,6 +10,7 @@ package afpacket
 
 import (
 	"reflect"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -134,6 +135,65 @@ func (h *v2header) next() bool {
 	return false
 }
 
+// txHeader is the header of a frame of the TX ring, which the user process
+// fills and hands to the kernel by setting its status to
+// TP_STATUS_SEND_REQUEST. The kernel sets it back to TP_STATUS_AVAILABLE once
+// the packet is sent.
+type txHeader interface {
+	getStatus() int
+	setStatus(status int)
+	setLength(length int)
+}
+
+type v3header C.struct_tpacket3_hdr
+
+// txHeaderAt returns the header of the TX frame at p.
+func txHeaderAt(version OptTPacketVersion, p unsafe.Pointer) txHeader {
+	switch version {
+	case TPacketVersion1:
+		return (*v1header)(p)
+	case TPacketVersion2:
+		return (*v2header)(p)
+	}
+	return (*v3header)(p)
+}
+
+// txDataOffset returns the offset of the packet data in TX frames, where the
+// kernel expects it.
+func txDataOffset(version OptTPacketVersion) int {
+	switch version {
+	case TPacketVersion1:
+		return tpAlign(int(C.sizeof_struct_tpacket_hdr))
+	case TPacketVersion2:
+		return tpAlign(int(C.sizeof_struct_tpacket2_hdr))
+	}
+	return tpAlign(int(C.sizeof_struct_tpacket3_hdr))
+}
+
+// The status of TX frames is set atomically, so the kernel sees the frame
+// filled when it is handed over. tp_status of tpacket_hdr is an unsigned long.
+func (h *v1header) setStatus(status int) {
+	atomic.StoreUintptr((*uintptr)(unsafe.Pointer(&h.tp_status)), uintptr(status))
+}
+func (h *v1header) setLength(length int) {
+	h.tp_len = C.uint(length)
+}
+func (h *v2header) setStatus(status int) {
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(&h.tp_status)), uint32(status))
+}
+func (h *v2header) setLength(length int) {
+	h.tp_len = C.__u32(length)
+}
+func (h *v3header) getStatus() int {
+	return int(h.tp_status)
+}
+func (h *v3header) setStatus(status int) {
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(&h.tp_status)), uint32(status))
+}
+func (h *v3header) setLength(length int) {
+	h.tp_len = C.__u32(length)
+}
+
 type v3wrapper struct {
 	block    *C.struct_tpacket_block_desc
 	blockhdr *C.struct_tpacket_hdr_v1
@@ -0,0 +1,222 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+//go:build linux
+// +build linux
+
+package afpacket
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// io_uring constants, from linux/io_uring.h.
+const (
+	ioringOpPollAdd      = 6
+	ioringEnterGetEvents = 1 << 0
+	ioringEnterExtArg    = 1 << 3
+	ioringFeatExtArg     = 1 << 8
+	ioringPollAddMulti   = 1 << 0
+	ioringCQEFMore       = 1 << 1
+	ioringOffSQRing      = 0
+	ioringOffCQRing      = 0x8000000
+	ioringOffSQEs        = 0x10000000
+	ioringSQESize        = 64
+	ioringCQESize        = 16
+	// ioringEntries is the size of the submission queue. Only one poll
+	// is ever submitted at a time.
+	ioringEntries = 4
+)
+
+type ioSQRingOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	resv2                                                           uint64
+}
+
+type ioCQRingOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	resv2                                                           uint64
+}
+
+// ioURingParams is struct io_uring_params.
+type ioURingParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  ioSQRingOffsets
+	cqOff                                                                  ioCQRingOffsets
+}
+
+// ioURingGeteventsArg is struct io_uring_getevents_arg.
+type ioURingGeteventsArg struct {
+	sigmask   uint64
+	sigmaskSz uint32
+	pad       uint32
+	ts        uint64
+}
+
+// ioURing waits for a file descriptor to become readable with an io_uring
+// poll. The poll is multishot if the kernel supports it, so it stays armed
+// across wakeups, and those which happen while packets are being read are
+// found in the completion queue without a syscall.
+type ioURing struct {
+	fd     int
+	pollFd int
+	sqRing []byte
+	cqRing []byte
+	sqes   []byte
+	params ioURingParams
+	// toSubmit is the number of queued submissions the kernel didn't
+	// consume yet.
+	toSubmit uint32
+	// armed is set while a poll is submitted and has not completed for
+	// good.
+	armed bool
+	// multishot is cleared if the kernel rejects multishot polls, which
+	// are then re-armed after each wakeup.
+	multishot bool
+	// arg and ts are passed to io_uring_enter by address, so they are kept
+	// here rather than on the stack, which may move.
+	arg ioURingGeteventsArg
+	ts  unix.Timespec
+}
+
+// newIOURing sets up an io_uring to poll fd.
+func newIOURing(fd int) (r *ioURing, err error) {
+	r = &ioURing{fd: -1, pollFd: fd, multishot: true}
+	ringFd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, ioringEntries, uintptr(unsafe.Pointer(&r.params)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	r.fd = int(ringFd)
+	defer func() {
+		if err != nil {
+			r.close()
+		}
+	}()
+	if r.params.features&ioringFeatExtArg == 0 {
+		return nil, errors.New("io_uring does not support waiting with a timeout, which needs Linux 5.11")
+	}
+	p := &r.params
+	if r.sqRing, err = unix.Mmap(r.fd, ioringOffSQRing, int(p.sqOff.array+p.sqEntries*4), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return nil, err
+	}
+	if r.cqRing, err = unix.Mmap(r.fd, ioringOffCQRing, int(p.cqOff.cqes+p.cqEntries*ioringCQESize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return nil, err
+	}
+	if r.sqes, err = unix.Mmap(r.fd, ioringOffSQEs, int(p.sqEntries*ioringSQESize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// close unmaps the rings and closes the io_uring.
+func (r *ioURing) close() {
+	for _, b := range [][]byte{r.sqRing, r.cqRing, r.sqes} {
+		if b != nil {
+			unix.Munmap(b)
+		}
+	}
+	r.sqRing, r.cqRing, r.sqes = nil, nil, nil
+	if r.fd != -1 {
+		unix.Close(r.fd)
+		r.fd = -1
+	}
+}
+
+func ringUint32(ring []byte, off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&ring[off]))
+}
+
+// queuePoll queues a poll for the file descriptor to become readable.
+func (r *ioURing) queuePoll() {
+	tail := atomic.LoadUint32(ringUint32(r.sqRing, r.params.sqOff.tail))
+	index := tail & *ringUint32(r.sqRing, r.params.sqOff.ringMask)
+	sqe := r.sqes[index*ioringSQESize : (index+1)*ioringSQESize]
+	for i := range sqe {
+		sqe[i] = 0
+	}
+	sqe[0] = ioringOpPollAdd
+	*(*int32)(unsafe.Pointer(&sqe[4])) = int32(r.pollFd)
+	if r.multishot {
+		*(*uint32)(unsafe.Pointer(&sqe[24])) = ioringPollAddMulti
+	}
+	// poll_events, whose 16 bits are read by all kernels
+	*(*uint16)(unsafe.Pointer(&sqe[28])) = unix.POLLIN
+	*ringUint32(r.sqRing, r.params.sqOff.array+index*4) = index
+	atomic.StoreUint32(ringUint32(r.sqRing, r.params.sqOff.tail), tail+1)
+	r.toSubmit++
+	r.armed = true
+}
+
+// reap consumes the completions of the poll, returning whether the file
+// descriptor became readable.
+func (r *ioURing) reap() (ready bool, err error) {
+	headp := ringUint32(r.cqRing, r.params.cqOff.head)
+	head := atomic.LoadUint32(headp)
+	tail := atomic.LoadUint32(ringUint32(r.cqRing, r.params.cqOff.tail))
+	mask := *ringUint32(r.cqRing, r.params.cqOff.ringMask)
+	for ; head != tail; head++ {
+		cqe := r.cqRing[r.params.cqOff.cqes+(head&mask)*ioringCQESize:]
+		res := *(*int32)(unsafe.Pointer(&cqe[8]))
+		flags := *(*uint32)(unsafe.Pointer(&cqe[12]))
+		if flags&ioringCQEFMore == 0 {
+			r.armed = false
+		}
+		switch {
+		case res == -int32(unix.EINVAL) && r.multishot:
+			r.multishot = false
+		case res == -int32(unix.ECANCELED):
+			// multishot polls are cancelled if the completion queue overflows
+		case res < 0:
+			err = syscall.Errno(-res)
+		case res&unix.POLLERR != 0:
+			err = ErrPoll
+		default:
+			ready = true
+		}
+	}
+	atomic.StoreUint32(headp, head)
+	return
+}
+
+// wait waits until the file descriptor may be readable, or the timeout
+// expires if it isn't negative. Each blocking syscall is counted in polls.
+func (r *ioURing) wait(timeout time.Duration, polls *int64) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if ready, err := r.reap(); err != nil || ready {
+			return err
+		}
+		r.arg.ts = 0
+		if timeout >= 0 {
+			left := time.Until(deadline)
+			if left < 0 {
+				return ErrTimeout
+			}
+			r.ts = unix.NsecToTimespec(int64(left))
+			r.arg.ts = uint64(uintptr(unsafe.Pointer(&r.ts)))
+		}
+		if !r.armed {
+			r.queuePoll()
+		}
+		n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(r.toSubmit), 1,
+			ioringEnterGetEvents|ioringEnterExtArg, uintptr(unsafe.Pointer(&r.arg)), unsafe.Sizeof(r.arg))
+		atomic.AddInt64(polls, 1)
+		switch errno {
+		case 0:
+			r.toSubmit -= uint32(n)
+		case unix.EINTR, unix.ETIME:
+		default:
+			return errno
+		}
+	}
+}
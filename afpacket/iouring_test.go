@@ -0,0 +1,60 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux
+
+package afpacket
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestIOURingWait(t *testing.T) {
+	var p [2]int
+	if err := unix.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+	r, err := newIOURing(p[0])
+	if err != nil {
+		t.Skipf("io_uring unavailable: %v", err)
+	}
+	defer r.close()
+	var polls int64
+	if err := r.wait(10*time.Millisecond, &polls); err != ErrTimeout {
+		t.Fatalf("wait on empty pipe: got %v, want ErrTimeout", err)
+	}
+	if polls == 0 {
+		t.Error("polls not counted")
+	}
+	if _, err := unix.Write(p[1], []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.wait(-1, &polls); err != nil {
+		t.Fatalf("wait on readable pipe: %v", err)
+	}
+	if _, err := unix.Read(p[0], make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.wait(10*time.Millisecond, &polls); err != ErrTimeout {
+		t.Fatalf("wait on drained pipe: got %v, want ErrTimeout", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := unix.Write(p[1], []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.wait(time.Second, &polls); err != nil {
+			t.Fatalf("wait %d: %v", i, err)
+		}
+		if _, err := unix.Read(p[0], make([]byte, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -106,6 +106,21 @@ type OptPollTimeout time.Duration
 // be provided if available.
 type OptAddVLANHeader bool
 
+// OptIOURing makes TPacket wait for packets with an io_uring poll instead of
+// poll(). The poll stays armed across wakeups, so wakeups which happen while
+// packets are read are noticed without a syscall, and waiting with
+// OptPollTimeout takes a single syscall. It needs Linux 5.11, and NewTPacket
+// fails on older kernels.
+type OptIOURing bool
+
+// OptTXNumBlocks is the tp_block_nr of a TX ring, whose blocks and frames
+// have the sizes of those of the RX ring. WritePacketData copies packets into
+// its frames, which must fit them with their header, and asks the kernel to
+// send them from there. With 0, the default, there is no TX ring and
+// WritePacketData uses write(). TPacket version 3 needs Linux 4.11 for it.
+// It can be passed into NewTPacket.
+type OptTXNumBlocks int
+
 // Default constants used by options.
 const (
 	DefaultFrameSize    = 4096                   // Default value for OptFrameSize.
@@ -120,7 +135,9 @@ type options struct {
 	framesPerBlock int
 	blockSize      int
 	numBlocks      int
+	txNumBlocks    int
 	addVLANHeader  bool
+	ioURing        bool
 	blockTimeout   time.Duration
 	pollTimeout    time.Duration
 	version        OptTPacketVersion
@@ -148,6 +165,8 @@ func parseOptions(opts ...interface{}) (ret options, err error) {
 			ret.blockSize = int(v)
 		case OptNumBlocks:
 			ret.numBlocks = int(v)
+		case OptTXNumBlocks:
+			ret.txNumBlocks = int(v)
 		case OptBlockTimeout:
 			ret.blockTimeout = time.Duration(v)
 		case OptPollTimeout:
@@ -160,6 +179,8 @@ func parseOptions(opts ...interface{}) (ret options, err error) {
 			ret.socktype = v
 		case OptAddVLANHeader:
 			ret.addVLANHeader = bool(v)
+		case OptIOURing:
+			ret.ioURing = bool(v)
 		default:
 			err = errors.New("unknown type in options")
 			return
@@ -179,6 +200,8 @@ func (o options) check() error {
 		return fmt.Errorf("block size %d must be divisible by frame size %d", o.blockSize, o.frameSize)
 	case o.numBlocks < 1:
 		return fmt.Errorf("num blocks %d must be >= 1", o.numBlocks)
+	case o.txNumBlocks < 0:
+		return fmt.Errorf("tx num blocks %d must be >= 0", o.txNumBlocks)
 	case o.blockTimeout < time.Millisecond:
 		return fmt.Errorf("block timeout %v must be > %v", o.blockTimeout, time.Millisecond)
 	case o.version < tpacketVersionMin || o.version > tpacketVersionMax:
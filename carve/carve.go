@@ -0,0 +1,176 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package carve reconstructs files transferred over the network from
+// captured packets, for protocols which move whole files in the clear:
+// TFTP, commonly used for firmware images and device configurations, and
+// NFSv3 READ and WRITE calls.
+//
+// Carvers are fed packets in capture order and hand out File values as
+// files are completed or flushed:
+//
+//  tftp := &carve.TFTPCarver{OnFile: save}
+//  for packet := range source.Packets() {
+//  	tftp.Packet(packet)
+//  }
+//  tftp.Flush(time.Time{})
+//
+// Carvers are not safe for concurrent use.
+package carve
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// DefaultMaxFileSize is the size limit of carved files used when a carver's
+// MaxFileSize is zero.
+const DefaultMaxFileSize = 256 << 20
+
+// File is a file reconstructed from captured traffic.
+type File struct {
+	// Protocol is "tftp" or "nfs".
+	Protocol string
+	// Name is the file name, if known. For NFS it is the path built from
+	// the LOOKUP calls seen, which may only be the last components.
+	Name string
+	// Handle is the NFS file handle.
+	Handle []byte
+	// Network is the network flow from client to server, if known.
+	Network gopacket.Flow
+	// Data holds the file contents. Bytes that were not captured are zero;
+	// see Missing.
+	Data []byte
+	// Size is the size of the file, or -1 if the end of the file was not
+	// seen.
+	Size int64
+	// Truncated is set if data beyond the size limit was dropped.
+	Truncated bool
+	// Err is set if the transfer failed, such as the TFTP error message.
+	Err string
+	// First and Last are the timestamps of the first and last packets
+	// carrying data of this file.
+	First, Last time.Time
+
+	// have is the sorted list of captured byte ranges.
+	have []byteRange
+}
+
+type byteRange struct {
+	start, end int64
+}
+
+func newFile(protocol string) *File {
+	return &File{Protocol: protocol, Size: -1}
+}
+
+// Complete reports whether the size of the file is known and all of its
+// bytes were captured.
+func (f *File) Complete() bool {
+	switch {
+	case f.Size < 0:
+		return false
+	case f.Size == 0:
+		return true
+	}
+	return len(f.have) == 1 && f.have[0].start == 0 && f.have[0].end == f.Size
+}
+
+// Missing returns the byte ranges of the file that were not captured, as
+// pairs of start and end offsets. If the size of the file is unknown,
+// missing data after the last captured byte is not reported.
+func (f *File) Missing() [][2]int64 {
+	var out [][2]int64
+	pos := int64(0)
+	for _, r := range f.have {
+		if r.start > pos {
+			out = append(out, [2]int64{pos, r.start})
+		}
+		pos = r.end
+	}
+	if f.Size > pos {
+		out = append(out, [2]int64{pos, f.Size})
+	}
+	return out
+}
+
+// write copies data into the file at offset off, up to maxSize bytes.
+func (f *File) write(off int64, data []byte, ts time.Time, maxSize int64) {
+	if f.First.IsZero() || ts.Before(f.First) {
+		f.First = ts
+	}
+	if ts.After(f.Last) {
+		f.Last = ts
+	}
+	if off < 0 || off >= maxSize {
+		if len(data) > 0 {
+			f.Truncated = true
+		}
+		return
+	}
+	if int64(len(data)) > maxSize-off {
+		data = data[:maxSize-off]
+		f.Truncated = true
+	}
+	if len(data) == 0 {
+		return
+	}
+	end := off + int64(len(data))
+	if end > int64(len(f.Data)) {
+		if end > int64(cap(f.Data)) {
+			grown := make([]byte, end, end+end/2)
+			copy(grown, f.Data)
+			f.Data = grown
+		} else {
+			f.Data = f.Data[:end]
+		}
+	}
+	copy(f.Data[off:], data)
+	f.addRange(off, end)
+}
+
+// addRange records [start, end) as captured, merging adjacent ranges.
+func (f *File) addRange(start, end int64) {
+	i := sort.Search(len(f.have), func(i int) bool { return f.have[i].end >= start })
+	j := i
+	for j < len(f.have) && f.have[j].start <= end {
+		if f.have[j].start < start {
+			start = f.have[j].start
+		}
+		if f.have[j].end > end {
+			end = f.have[j].end
+		}
+		j++
+	}
+	merged := append([]byteRange{{start, end}}, f.have[j:]...)
+	f.have = append(f.have[:i], merged...)
+}
+
+// setSize records the size of the file, dropping data captured beyond it.
+func (f *File) setSize(size int64) {
+	f.Size = size
+	if int64(len(f.Data)) > size {
+		f.Data = f.Data[:size]
+	}
+	for i := range f.have {
+		if f.have[i].start >= size {
+			f.have = f.have[:i]
+			break
+		}
+		if f.have[i].end > size {
+			f.have[i].end = size
+		}
+	}
+}
+
+func maxFileSize(n int64) int64 {
+	if n <= 0 {
+		return DefaultMaxFileSize
+	}
+	return n
+}
@@ -0,0 +1,389 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package carve
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+)
+
+// NFSPort is the port NFS servers listen on.
+const NFSPort = 2049
+
+// ONC RPC (RFC 5531) and NFSv3 (RFC 1813) constants.
+const (
+	rpcCall  = 0
+	rpcReply = 1
+
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	nfsProcLookup = 3
+	nfsProcRead   = 6
+	nfsProcWrite  = 7
+
+	// nfsFattr3Size is the encoded size of the fattr3 structure.
+	nfsFattr3Size = 84
+)
+
+var errXDRShort = errors.New("XDR data too short")
+
+// xdrReader decodes XDR (RFC 4506) data.
+type xdrReader struct {
+	data []byte
+	err  error
+}
+
+func (r *xdrReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = errXDRShort
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *xdrReader) uint64() uint64 {
+	return uint64(r.uint32())<<32 | uint64(r.uint32())
+}
+
+// skip skips n bytes, rounded up to a multiple of four.
+func (r *xdrReader) skip(n int) {
+	if r.err != nil {
+		return
+	}
+	n = (n + 3) &^ 3
+	if n < 0 || len(r.data) < n {
+		r.err = errXDRShort
+		return
+	}
+	r.data = r.data[n:]
+}
+
+// opaque returns variable length opaque data of at most max bytes. The
+// result points into the decoded data.
+func (r *xdrReader) opaque(max int) []byte {
+	n := int(r.uint32())
+	if r.err != nil {
+		return nil
+	}
+	if n > max {
+		r.err = fmt.Errorf("XDR opaque length %d exceeds %d", n, max)
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = errXDRShort
+		return nil
+	}
+	v := r.data[:n]
+	r.skip(n)
+	return v
+}
+
+// postOpAttr decodes an NFSv3 post_op_attr and returns the file size, or -1
+// if no attributes follow.
+func (r *xdrReader) postOpAttr() int64 {
+	if r.uint32() == 0 {
+		return -1
+	}
+	if r.err != nil || len(r.data) < nfsFattr3Size {
+		r.err = errXDRShort
+		return -1
+	}
+	size := int64(binary.BigEndian.Uint64(r.data[20:28]))
+	r.data = r.data[nfsFattr3Size:]
+	return size
+}
+
+// nfsCall is an outstanding NFS call waiting for its reply.
+type nfsCall struct {
+	proc   uint32
+	handle []byte
+	name   string
+	offset uint64
+}
+
+// NFSCarver reconstructs files from NFSv3 READ and WRITE calls. Files are
+// identified by their file handle and named after the LOOKUP calls seen.
+//
+// Feed it RPC messages with Message, UDP packets with Packet, or use it as
+// a tcpassembly.StreamFactory for NFS over TCP:
+//
+//	nfs := &carve.NFSCarver{OnFile: save}
+//	assembler := tcpassembly.NewAssembler(tcpassembly.NewStreamPool(nfs))
+type NFSCarver struct {
+	// OnFile is called with each file when all of it has been read, and
+	// by Flush.
+	OnFile func(*File)
+	// MaxFileSize limits the size of carved files. Zero selects
+	// DefaultMaxFileSize.
+	MaxFileSize int64
+
+	// calls maps RPC transaction IDs to outstanding calls.
+	calls map[uint32]*nfsCall
+	// files maps file handles to files being carved.
+	files map[string]*File
+	// names maps file handles to the names found by LOOKUP.
+	names map[string]string
+}
+
+func (c *NFSCarver) init() {
+	if c.calls == nil {
+		c.calls = make(map[uint32]*nfsCall)
+		c.files = make(map[string]*File)
+		c.names = make(map[string]string)
+	}
+}
+
+// Packet processes a captured packet. UDP packets to or from NFSPort are
+// decoded as RPC messages; other packets are ignored.
+func (c *NFSCarver) Packet(packet gopacket.Packet) {
+	udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok || (udp.SrcPort != NFSPort && udp.DstPort != NFSPort) {
+		return
+	}
+	c.Message(udp.Payload, packet.Metadata().Timestamp)
+}
+
+// Message processes a single ONC RPC message, without the record marking
+// used over TCP. Messages other than NFSv3 LOOKUP, READ and WRITE calls and
+// their replies are ignored.
+func (c *NFSCarver) Message(msg []byte, ts time.Time) error {
+	c.init()
+	r := &xdrReader{data: msg}
+	xid := r.uint32()
+	switch r.uint32() {
+	case rpcCall:
+		return c.call(xid, r, ts)
+	case rpcReply:
+		return c.reply(xid, r, ts)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return errors.New("invalid RPC message type")
+}
+
+func (c *NFSCarver) call(xid uint32, r *xdrReader, ts time.Time) error {
+	rpcvers, prog, vers, proc := r.uint32(), r.uint32(), r.uint32(), r.uint32()
+	if r.err != nil {
+		return r.err
+	}
+	if rpcvers != 2 {
+		return fmt.Errorf("unsupported RPC version %d", rpcvers)
+	}
+	if prog != nfsProgram || vers != nfsVersion {
+		return nil
+	}
+	// Credentials and verifier.
+	for i := 0; i < 2; i++ {
+		r.uint32()
+		r.opaque(400)
+	}
+	call := &nfsCall{proc: proc}
+	switch proc {
+	case nfsProcLookup:
+		call.handle = r.opaque(64)
+		call.name = string(r.opaque(255))
+	case nfsProcRead:
+		call.handle = r.opaque(64)
+		call.offset = r.uint64()
+	case nfsProcWrite:
+		call.handle = r.opaque(64)
+		call.offset = r.uint64()
+		r.uint32() // count
+		r.uint32() // stable
+		data := r.opaque(len(r.data))
+		if r.err != nil {
+			return r.err
+		}
+		c.file(call.handle).write(int64(call.offset), data, ts, maxFileSize(c.MaxFileSize))
+	default:
+		return nil
+	}
+	if r.err != nil {
+		return r.err
+	}
+	call.handle = append([]byte(nil), call.handle...)
+	c.calls[xid] = call
+	return nil
+}
+
+func (c *NFSCarver) reply(xid uint32, r *xdrReader, ts time.Time) error {
+	call := c.calls[xid]
+	if call == nil {
+		return nil
+	}
+	delete(c.calls, xid)
+	if r.uint32() != 0 { // reply_stat: MSG_DENIED
+		return r.err
+	}
+	// Verifier.
+	r.uint32()
+	r.opaque(400)
+	if r.uint32() != 0 { // accept_stat: not SUCCESS
+		return r.err
+	}
+	if r.uint32() != 0 { // nfsstat3: not NFS3_OK
+		return r.err
+	}
+	switch call.proc {
+	case nfsProcLookup:
+		handle := r.opaque(64)
+		if r.err != nil {
+			return r.err
+		}
+		name := call.name
+		if dir, ok := c.names[string(call.handle)]; ok {
+			name = dir + "/" + name
+		}
+		c.names[string(handle)] = name
+		if f := c.files[string(handle)]; f != nil {
+			f.Name = name
+		}
+	case nfsProcRead:
+		size := r.postOpAttr()
+		r.uint32() // count
+		eof := r.uint32() != 0
+		data := r.opaque(len(r.data))
+		if r.err != nil {
+			return r.err
+		}
+		f := c.file(call.handle)
+		f.write(int64(call.offset), data, ts, maxFileSize(c.MaxFileSize))
+		if eof {
+			size = int64(call.offset) + int64(len(data))
+		}
+		if size >= 0 && f.Size < 0 {
+			f.setSize(size)
+		}
+		if f.Complete() {
+			c.finish(call.handle)
+		}
+	}
+	return r.err
+}
+
+// file returns the file being carved for handle, creating it if needed.
+func (c *NFSCarver) file(handle []byte) *File {
+	f := c.files[string(handle)]
+	if f == nil {
+		f = newFile("nfs")
+		f.Handle = append([]byte(nil), handle...)
+		f.Name = c.names[string(handle)]
+		c.files[string(handle)] = f
+	}
+	return f
+}
+
+func (c *NFSCarver) finish(handle []byte) {
+	f := c.files[string(handle)]
+	delete(c.files, string(handle))
+	if f != nil && c.OnFile != nil {
+		c.OnFile(f)
+	}
+}
+
+// Flush hands out the files not written to since before, or all files if
+// before is the zero time, whether complete or not. It also forgets calls
+// whose reply was not seen.
+func (c *NFSCarver) Flush(before time.Time) {
+	for h, f := range c.files {
+		if before.IsZero() || f.Last.Before(before) {
+			c.finish([]byte(h))
+		}
+	}
+	if before.IsZero() {
+		c.calls = make(map[uint32]*nfsCall)
+	}
+}
+
+// New implements tcpassembly.StreamFactory, returning a stream which
+// splits NFS over TCP into RPC messages. Connections not using NFSPort are
+// ignored.
+func (c *NFSCarver) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	src, dst := tcpFlow.Endpoints()
+	port := layers.NewTCPPortEndpoint(NFSPort)
+	return &nfsStream{carver: c, ignore: src != port && dst != port}
+}
+
+// nfsStream decodes the record marking (RFC 5531 section 11) of one
+// direction of an NFS connection.
+type nfsStream struct {
+	carver *NFSCarver
+	ignore bool
+	// buf holds the data not yet decoded and record the fragments of the
+	// current record.
+	buf, record []byte
+	// lost is set when data was skipped and the next record boundary is
+	// unknown.
+	lost bool
+}
+
+// maxRecord limits the size of RPC records, fragments included; larger ones
+// are taken as a sign of having lost the record boundaries.
+const maxRecord = 4 << 20
+
+// Reassembled implements tcpassembly.Stream.
+func (s *nfsStream) Reassembled(rs []tcpassembly.Reassembly) {
+	if s.ignore {
+		return
+	}
+	for _, r := range rs {
+		if r.Skip != 0 {
+			// Hope the next segment starts a record; messages which fail
+			// to decode are dropped until one does.
+			s.buf, s.record = s.buf[:0], s.record[:0]
+			s.lost = true
+		}
+		s.buf = append(s.buf, r.Bytes...)
+		s.decode(r.Seen)
+	}
+}
+
+func (s *nfsStream) decode(ts time.Time) {
+	for len(s.buf) >= 4 {
+		mark := binary.BigEndian.Uint32(s.buf)
+		n := int(mark & 0x7fffffff)
+		if len(s.record)+n > maxRecord {
+			s.buf, s.record = s.buf[:0], s.record[:0]
+			s.lost = true
+			return
+		}
+		if len(s.buf) < 4+n {
+			return
+		}
+		s.record = append(s.record, s.buf[4:4+n]...)
+		s.buf = s.buf[:copy(s.buf, s.buf[4+n:])]
+		if mark&0x80000000 == 0 {
+			continue
+		}
+		err := s.carver.Message(s.record, ts)
+		s.record = s.record[:0]
+		if err == nil {
+			s.lost = false
+		} else if s.lost {
+			s.buf = s.buf[:0]
+			return
+		}
+	}
+}
+
+// ReassemblyComplete implements tcpassembly.Stream.
+func (s *nfsStream) ReassemblyComplete() {
+	s.buf, s.record = nil, nil
+}
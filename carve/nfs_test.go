@@ -0,0 +1,181 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package carve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+)
+
+type xdrWriter []byte
+
+func (w *xdrWriter) uint32(v uint32) *xdrWriter {
+	*w = append(*w, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	return w
+}
+
+func (w *xdrWriter) uint64(v uint64) *xdrWriter {
+	return w.uint32(uint32(v >> 32)).uint32(uint32(v))
+}
+
+func (w *xdrWriter) opaque(b []byte) *xdrWriter {
+	w.uint32(uint32(len(b)))
+	*w = append(*w, b...)
+	for len(*w)%4 != 0 {
+		*w = append(*w, 0)
+	}
+	return w
+}
+
+func nfsCallHeader(xid, proc uint32) *xdrWriter {
+	w := &xdrWriter{}
+	w.uint32(xid).uint32(rpcCall).uint32(2).uint32(nfsProgram).uint32(nfsVersion).uint32(proc)
+	return w.uint32(0).opaque(nil).uint32(0).opaque(nil) // AUTH_NONE
+}
+
+func nfsReplyHeader(xid uint32) *xdrWriter {
+	w := &xdrWriter{}
+	w.uint32(xid).uint32(rpcReply).uint32(0).uint32(0).opaque(nil).uint32(0)
+	return w.uint32(0) // NFS3_OK
+}
+
+// attrs returns a post_op_attr carrying the given file size.
+func attrs(size uint64) []byte {
+	w := &xdrWriter{}
+	w.uint32(1)
+	fattr := make([]byte, nfsFattr3Size)
+	binary.BigEndian.PutUint64(fattr[20:], size)
+	return append(*w, fattr...)
+}
+
+func nfsLookup(xid uint32, dir []byte, name string, handle []byte) [][]byte {
+	call := nfsCallHeader(xid, nfsProcLookup).opaque(dir).opaque([]byte(name))
+	reply := nfsReplyHeader(xid).opaque(handle).uint32(0).uint32(0)
+	return [][]byte{*call, *reply}
+}
+
+func nfsRead(xid uint32, handle []byte, offset uint64, data []byte, eof bool, withAttrs bool, size uint64) [][]byte {
+	call := nfsCallHeader(xid, nfsProcRead).opaque(handle).uint64(offset).uint32(uint32(len(data)))
+	reply := nfsReplyHeader(xid)
+	if withAttrs {
+		*reply = append(*reply, attrs(size)...)
+	} else {
+		reply.uint32(0)
+	}
+	e := uint32(0)
+	if eof {
+		e = 1
+	}
+	reply.uint32(uint32(len(data))).uint32(e).opaque(data)
+	return [][]byte{*call, *reply}
+}
+
+func TestNFSCarver(t *testing.T) {
+	root, dir, file := []byte("root-fh"), []byte("dir-fh"), []byte("file-fh")
+	want := bytes.Repeat([]byte("0123456789"), 100)
+
+	var files []*File
+	c := &NFSCarver{OnFile: func(f *File) { files = append(files, f) }}
+	var msgs [][]byte
+	msgs = append(msgs, nfsLookup(1, root, "etc", dir)...)
+	msgs = append(msgs, nfsLookup(2, dir, "shadow", file)...)
+	msgs = append(msgs, nfsRead(3, file, 600, want[600:], false, true, 1000)...)
+	msgs = append(msgs, nfsRead(4, file, 0, want[:600], false, false, 0)...)
+	for i, m := range msgs {
+		if err := c.Message(m, testTime); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	f := files[0]
+	if f.Name != "etc/shadow" || !bytes.Equal(f.Handle, file) || !bytes.Equal(f.Data, want) {
+		t.Errorf("got file %q handle %q with %d bytes", f.Name, f.Handle, len(f.Data))
+	}
+}
+
+func TestNFSCarverWrite(t *testing.T) {
+	var files []*File
+	c := &NFSCarver{OnFile: func(f *File) { files = append(files, f) }}
+	call := nfsCallHeader(9, nfsProcWrite).opaque([]byte("fh")).uint64(4).uint32(5).uint32(2).opaque([]byte("hello"))
+	if err := c.Message(*call, testTime); err != nil {
+		t.Fatal(err)
+	}
+	c.Flush(testTime)
+	if len(files) != 0 {
+		t.Fatal("flushed a file written at the flush time")
+	}
+	c.Flush(testTime.Add(1))
+	if len(files) != 1 || !bytes.Equal(files[0].Data, []byte("\x00\x00\x00\x00hello")) || files[0].Size != -1 {
+		t.Fatalf("got files %+v", files)
+	}
+	if got := files[0].Missing(); len(got) != 1 || got[0] != [2]int64{0, 4} {
+		t.Errorf("got missing ranges %v", got)
+	}
+}
+
+func TestNFSStream(t *testing.T) {
+	want := []byte("some file contents")
+	var files []*File
+	c := &NFSCarver{OnFile: func(f *File) { files = append(files, f) }}
+	msgs := nfsRead(1, []byte("fh"), 0, want, true, false, 0)
+
+	// Each message is sent as two record fragments.
+	var stream []byte
+	for _, m := range msgs {
+		half := len(m) / 2 &^ 3
+		stream = append(stream, byte(half>>24), byte(half>>16), byte(half>>8), byte(half))
+		stream = append(stream, m[:half]...)
+		rest := len(m) - half
+		stream = append(stream, 0x80|byte(rest>>24), byte(rest>>16), byte(rest>>8), byte(rest))
+		stream = append(stream, m[half:]...)
+	}
+	ips := gopacket.NewFlow(layers.EndpointIPv4, testServer, testClient)
+	ports, _ := gopacket.FlowFromEndpoints(layers.NewTCPPortEndpoint(NFSPort), layers.NewTCPPortEndpoint(900))
+	s := c.New(ips, ports)
+	// Lost data is skipped until a record decodes again.
+	s.Reassembled([]tcpassembly.Reassembly{{Bytes: []byte{0, 0, 0, 4, 1, 2}, Skip: -1}})
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		r := tcpassembly.Reassembly{Bytes: stream[i:end], Seen: testTime}
+		if i == 0 {
+			r.Skip = 10
+		}
+		s.Reassembled([]tcpassembly.Reassembly{r})
+	}
+	s.ReassemblyComplete()
+	if len(files) != 1 || !bytes.Equal(files[0].Data, want) || !files[0].Complete() {
+		t.Fatalf("got files %+v", files)
+	}
+}
+
+func TestNFSStreamRecordLimit(t *testing.T) {
+	c := &NFSCarver{OnFile: func(f *File) {}}
+	ips := gopacket.NewFlow(layers.EndpointIPv4, testServer, testClient)
+	ports, _ := gopacket.FlowFromEndpoints(layers.NewTCPPortEndpoint(NFSPort), layers.NewTCPPortEndpoint(900))
+	s := c.New(ips, ports).(*nfsStream)
+	// Non-final fragments which never complete a record.
+	fragment := append([]byte{0, 0, 0x10, 0}, make([]byte, 0x1000)...)
+	for i := 0; i < 2*maxRecord/len(fragment); i++ {
+		s.Reassembled([]tcpassembly.Reassembly{{Bytes: fragment, Seen: testTime}})
+		if len(s.record) > maxRecord {
+			t.Fatalf("record grew to %d bytes", len(s.record))
+		}
+	}
+	if !s.lost {
+		t.Error("oversized record not abandoned")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package carve
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/seqnum"
+)
+
+// tftpKey identifies a transfer. The server answers a request from a new
+// port, so only the client side and the server address are known when the
+// request is seen.
+type tftpKey struct {
+	client, server gopacket.Endpoint
+	clientPort     layers.UDPPort
+}
+
+type tftpTransfer struct {
+	key       tftpKey
+	file      *File
+	blockSize int64
+	// rollover is the block number following 65535; 0 unless the rollover
+	// option asked for 1.
+	rollover uint16
+	blocks   seqnum.Unwrapper16
+	last     time.Time
+}
+
+// TFTPCarver reconstructs files transferred with TFTP read and write
+// requests. Transfers are found through their request to port 69, so
+// transfers whose request was not captured are not carved.
+type TFTPCarver struct {
+	// OnFile is called with each file when its transfer completes, fails
+	// or is flushed.
+	OnFile func(*File)
+	// MaxFileSize limits the size of carved files. Zero selects
+	// DefaultMaxFileSize.
+	MaxFileSize int64
+
+	transfers map[tftpKey]*tftpTransfer
+	tftp      layers.TFTP
+}
+
+// Packet processes a captured packet. Packets which are not part of a TFTP
+// transfer are ignored.
+func (c *TFTPCarver) Packet(packet gopacket.Packet) {
+	udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok || packet.NetworkLayer() == nil {
+		return
+	}
+	src, dst := packet.NetworkLayer().NetworkFlow().Endpoints()
+	c.UDP(src, dst, udp, packet.Metadata().Timestamp)
+}
+
+// UDP processes a UDP datagram sent from src to dst, which are IP
+// endpoints.
+func (c *TFTPCarver) UDP(src, dst gopacket.Endpoint, udp *layers.UDP, ts time.Time) {
+	if c.transfers == nil {
+		c.transfers = make(map[tftpKey]*tftpTransfer)
+	}
+	t := &c.tftp
+	if t.DecodeFromBytes(udp.Payload, gopacket.NilDecodeFeedback) != nil {
+		return
+	}
+	if udp.DstPort == 69 {
+		if t.Opcode == layers.TFTPOpcodeRRQ || t.Opcode == layers.TFTPOpcodeWRQ {
+			c.request(tftpKey{src, dst, udp.SrcPort}, src, dst, ts)
+		}
+		return
+	}
+	tr := c.transfers[tftpKey{src, dst, udp.SrcPort}]
+	if tr == nil {
+		tr = c.transfers[tftpKey{dst, src, udp.DstPort}]
+	}
+	if tr == nil {
+		return
+	}
+	tr.last = ts
+	switch t.Opcode {
+	case layers.TFTPOpcodeOACK:
+		tr.blockSize = int64(t.BlockSize())
+	case layers.TFTPOpcodeERROR:
+		tr.file.Err = t.ErrorMessage
+		c.finish(tr)
+	case layers.TFTPOpcodeDATA:
+		block := tr.blocks.Unwrap(t.Block) - 1
+		if tr.rollover == 1 {
+			block -= block / 65536
+		}
+		off := block * tr.blockSize
+		tr.file.write(off, t.Payload(), ts, maxFileSize(c.MaxFileSize))
+		if int64(len(t.Payload())) < tr.blockSize {
+			tr.file.setSize(off + int64(len(t.Payload())))
+		}
+		if tr.file.Complete() {
+			c.finish(tr)
+		}
+	}
+}
+
+func (c *TFTPCarver) request(k tftpKey, client, server gopacket.Endpoint, ts time.Time) {
+	t := &c.tftp
+	if old := c.transfers[k]; old != nil {
+		// A retransmitted request before any answer is the same transfer.
+		if old.file.Name == t.Filename && len(old.file.have) == 0 {
+			return
+		}
+		c.finish(old)
+	}
+	f := newFile("tftp")
+	f.Name = t.Filename
+	f.Network = gopacket.NewFlow(client.EndpointType(), client.Raw(), server.Raw())
+	tr := &tftpTransfer{key: k, file: f, blockSize: layers.TFTPDefaultBlockSize, last: ts}
+	if v, ok := t.Option("rollover"); ok && v == "1" {
+		tr.rollover = 1
+	}
+	c.transfers[k] = tr
+}
+
+func (c *TFTPCarver) finish(tr *tftpTransfer) {
+	delete(c.transfers, tr.key)
+	if c.OnFile != nil {
+		c.OnFile(tr.file)
+	}
+}
+
+// Flush hands out the files of transfers not seen since before, or of all
+// transfers if before is the zero time, whether complete or not.
+func (c *TFTPCarver) Flush(before time.Time) {
+	for k, tr := range c.transfers {
+		if before.IsZero() || tr.last.Before(before) {
+			delete(c.transfers, k)
+			if c.OnFile != nil {
+				c.OnFile(tr.file)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package carve
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	testClient = net.IP{10, 0, 0, 1}
+	testServer = net.IP{10, 0, 0, 2}
+	testTime   = time.Unix(1600000000, 0)
+)
+
+func udpPacket(t *testing.T, src, dst net.IP, sport, dport layers.UDPPort, payload ...gopacket.SerializableLayer) gopacket.Packet {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+	udp := &layers.UDP{SrcPort: sport, DstPort: dport}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, append([]gopacket.SerializableLayer{ip, udp}, payload...)...); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	p.Metadata().Timestamp = testTime
+	return p
+}
+
+func tftpData(t *testing.T, block uint16, data []byte) gopacket.Packet {
+	return udpPacket(t, testServer, testClient, 40000, 3000,
+		&layers.TFTP{Opcode: layers.TFTPOpcodeDATA, Block: block}, gopacket.Payload(data))
+}
+
+func TestTFTPCarver(t *testing.T) {
+	want := make([]byte, 1000)
+	for i := range want {
+		want[i] = byte(i)
+	}
+	var files []*File
+	c := &TFTPCarver{OnFile: func(f *File) { files = append(files, f) }}
+	rrq := &layers.TFTP{Opcode: layers.TFTPOpcodeRRQ, Filename: "boot.img", Mode: "octet",
+		Options: []layers.TFTPOption{{Name: "blksize", Value: "400"}}}
+	c.Packet(udpPacket(t, testClient, testServer, 3000, 69, rrq))
+	// The blksize option only applies once acknowledged.
+	c.Packet(udpPacket(t, testServer, testClient, 40000, 3000,
+		&layers.TFTP{Opcode: layers.TFTPOpcodeOACK, Options: rrq.Options}))
+	c.Packet(tftpData(t, 1, want[:400]))
+	c.Packet(tftpData(t, 3, want[800:]))
+	if len(files) != 0 {
+		t.Fatal("file handed out before the transfer was complete")
+	}
+	c.Packet(tftpData(t, 2, want[400:800]))
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	f := files[0]
+	if f.Name != "boot.img" || f.Size != 1000 || !f.Complete() || !bytes.Equal(f.Data, want) {
+		t.Errorf("got file %q of size %d, complete %v", f.Name, f.Size, f.Complete())
+	}
+	if f.Network.String() != "10.0.0.1->10.0.0.2" {
+		t.Errorf("got network flow %v", f.Network)
+	}
+}
+
+func TestTFTPCarverIncomplete(t *testing.T) {
+	var files []*File
+	c := &TFTPCarver{OnFile: func(f *File) { files = append(files, f) }}
+	c.Packet(udpPacket(t, testClient, testServer, 3000, 69,
+		&layers.TFTP{Opcode: layers.TFTPOpcodeRRQ, Filename: "a", Mode: "octet"}))
+	c.Packet(tftpData(t, 1, make([]byte, 512)))
+	c.Packet(tftpData(t, 3, make([]byte, 10)))
+	c.Flush(time.Time{})
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	f := files[0]
+	if f.Complete() || f.Size != 1034 {
+		t.Errorf("got size %d, complete %v", f.Size, f.Complete())
+	}
+	if got := f.Missing(); len(got) != 1 || got[0] != [2]int64{512, 1024} {
+		t.Errorf("got missing ranges %v", got)
+	}
+
+	files = nil
+	c.Packet(udpPacket(t, testClient, testServer, 3001, 69,
+		&layers.TFTP{Opcode: layers.TFTPOpcodeWRQ, Filename: "b", Mode: "octet"}))
+	c.Packet(udpPacket(t, testServer, testClient, 40001, 3001,
+		&layers.TFTP{Opcode: layers.TFTPOpcodeERROR, ErrorCode: 2, ErrorMessage: "access violation"}))
+	if len(files) != 1 || files[0].Err != "access violation" {
+		t.Errorf("got files %+v", files)
+	}
+}
+
+func TestFileRanges(t *testing.T) {
+	f := newFile("test")
+	f.write(10, []byte("abc"), testTime, 100)
+	f.write(20, []byte("def"), testTime, 100)
+	f.write(13, []byte("1234567"), testTime, 100)
+	if len(f.have) != 1 || f.have[0] != (byteRange{10, 23}) {
+		t.Errorf("got ranges %v", f.have)
+	}
+	f.write(95, []byte("0123456789"), testTime, 100)
+	if !f.Truncated || len(f.Data) != 100 {
+		t.Errorf("got %d bytes, truncated %v", len(f.Data), f.Truncated)
+	}
+	f.setSize(50)
+	if got := f.Missing(); len(got) != 2 || got[0] != [2]int64{0, 10} || got[1] != [2]int64{23, 50} {
+		t.Errorf("got missing ranges %v", got)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package gopacket
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ChecksumStatus is the outcome of verifying the checksum of a layer.
+type ChecksumStatus uint8
+
+const (
+	// ChecksumCorrect is the status of checksums matching the contents of
+	// their layer.
+	ChecksumCorrect ChecksumStatus = iota
+	// ChecksumIncorrect is the status of checksums which do not match.
+	ChecksumIncorrect
+	// ChecksumNotComputed is the status of checksums left unset by their
+	// sender, as UDP over IPv4 allows with a zero checksum, and, with
+	// ChecksumOptions.Offloaded, of incorrect checksums.
+	ChecksumNotComputed
+	// ChecksumUnverifiable is the status of checksums which could not be
+	// verified, such as those of truncated packets, or of transport layers
+	// without a network layer for their pseudo-header.
+	ChecksumUnverifiable
+)
+
+func (s ChecksumStatus) String() string {
+	switch s {
+	case ChecksumCorrect:
+		return "Correct"
+	case ChecksumIncorrect:
+		return "Incorrect"
+	case ChecksumNotComputed:
+		return "NotComputed"
+	case ChecksumUnverifiable:
+		return "Unverifiable"
+	}
+	return fmt.Sprintf("ChecksumStatus(%d)", uint8(s))
+}
+
+// ChecksumResult is the result of verifying the checksum of a layer.
+type ChecksumResult struct {
+	LayerType LayerType
+	Status    ChecksumStatus
+	// Checksum is the checksum carried by the layer, and Computed the
+	// checksum of its contents, if it could be computed.
+	Checksum, Computed uint32
+	// Err tells why a checksum is ChecksumUnverifiable.
+	Err error
+}
+
+// ChecksumVerifier is implemented by layers carrying a checksum.
+type ChecksumVerifier interface {
+	Layer
+	// VerifyChecksum verifies the checksum of the decoded layer. Layers
+	// whose checksum covers a pseudo-header, like TCP, need their network
+	// layer, which Packet.VerifyChecksums sets with
+	// SetNetworkLayerForChecksum.
+	VerifyChecksum() ChecksumResult
+}
+
+// ChecksumOptions tells Packet.VerifyChecksums how to report checksums.
+type ChecksumOptions struct {
+	// Offloaded is set for packets which may have been captured before
+	// the network interface computed their checksums, as packets sent by
+	// the capturing host with checksum offloading are. Their incorrect
+	// checksums are reported as ChecksumNotComputed.
+	Offloaded bool
+}
+
+var errChecksumTruncated = errors.New("packet truncated")
+
+// verifyChecksums implements Packet.VerifyChecksums. Transport layers are
+// verified with the pseudo-header of the network layer preceding them.
+func verifyChecksums(p Packet, opts ChecksumOptions) []ChecksumResult {
+	var results []ChecksumResult
+	var network NetworkLayer
+	for _, l := range p.Layers() {
+		if v, ok := l.(ChecksumVerifier); ok {
+			if s, ok := l.(interface {
+				SetNetworkLayerForChecksum(NetworkLayer) error
+			}); ok && network != nil {
+				// Layers which cannot use this network layer report
+				// their checksum unverifiable.
+				s.SetNetworkLayerForChecksum(network)
+			}
+			r := v.VerifyChecksum()
+			if r.Status == ChecksumIncorrect {
+				switch {
+				case p.Metadata().Truncated:
+					r.Status, r.Err = ChecksumUnverifiable, errChecksumTruncated
+				case opts.Offloaded:
+					r.Status = ChecksumNotComputed
+				}
+			}
+			results = append(results, r)
+		}
+		if n, ok := l.(NetworkLayer); ok {
+			network = n
+		}
+	}
+	return results
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package gopacket
+
+import (
+	"fmt"
+)
+
+// DecodeAs decodes the data of p starting at offset with dec, much like
+// Wireshark's "Decode As", and returns the result as a new packet. It is
+// meant for exploring traffic which was classified wrongly, such as RTP on
+// a port registered for something else:
+//
+//  udp := p.TransportLayer().(*layers.UDP)
+//  offset := len(p.Data()) - len(udp.Payload)
+//  rtp, err := gopacket.DecodeAs(p, offset, layers.LayerTypeRTP)
+//
+// Any LayerType may be passed as dec. The new packet starts with the
+// layers of p which end at or before offset; bytes between the last of
+// them and offset are added as a Payload layer. Decoding from offset then
+// proceeds as usual, and errors are reported by the new packet's
+// ErrorLayer. The new packet shares its data and leading layers with p and
+// is always decoded eagerly.
+//
+// DecodeAs returns an error if offset is not within the data of p.
+func DecodeAs(p Packet, offset int, dec Decoder) (Packet, error) {
+	data := p.Data()
+	if offset < 0 || offset > len(data) {
+		return nil, fmt.Errorf("decode as offset %d outside packet of %d bytes", offset, len(data))
+	}
+	if dec == nil {
+		return nil, errNilDecoder
+	}
+	np := &eagerPacket{packet: packet{data: data, metadata: *p.Metadata()}}
+	if o, ok := p.(interface{ DecodeOptions() *DecodeOptions }); ok {
+		np.decodeOptions = *o.DecodeOptions()
+	}
+	np.decodeOptions.Lazy = false
+	np.decodeOptions.NoCopy = true
+	np.layers = np.initialLayers[:0]
+
+	end := 0
+	for _, l := range p.Layers() {
+		if _, ok := l.(ErrorLayer); ok {
+			break
+		}
+		start, ok := subsliceOffset(data, l.LayerContents())
+		if !ok || start < end || start+len(l.LayerContents()) > offset {
+			break
+		}
+		np.AddLayer(l)
+		switch l := l.(type) {
+		case LinkLayer:
+			np.SetLinkLayer(l)
+		case NetworkLayer:
+			np.SetNetworkLayer(l)
+		case TransportLayer:
+			np.SetTransportLayer(l)
+		case ApplicationLayer:
+			np.SetApplicationLayer(l)
+		}
+		end = start + len(l.LayerContents())
+	}
+	if end < offset {
+		gap := Payload(data[end:offset])
+		np.AddLayer(&gap)
+	}
+	np.decodeAt(offset, dec)
+	return np, nil
+}
+
+func (p *eagerPacket) decodeAt(offset int, dec Decoder) {
+	defer p.recoverDecodeError()
+	if offset == len(p.data) {
+		return
+	}
+	if err := dec.Decode(p.data[offset:], p); err != nil {
+		p.addFinalDecodeError(err, nil)
+	}
+}
+
+// subsliceOffset returns the offset of sub within data, if sub is a part
+// of it.
+func subsliceOffset(data, sub []byte) (int, bool) {
+	off := cap(data) - cap(sub)
+	if off < 0 || off+len(sub) > len(data) {
+		return 0, false
+	}
+	if len(sub) > 0 && &data[off] != &sub[0] {
+		return 0, false
+	}
+	return off, true
+}
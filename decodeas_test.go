@@ -0,0 +1,95 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package gopacket
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testHeader is a layer with a 4 byte header followed by a payload.
+type testHeader struct {
+	contents, payload []byte
+}
+
+var layerTypeTestHeader = RegisterLayerType(-1332, LayerTypeMetadata{Name: "TestHeader", Decoder: DecodeFunc(decodeTestHeader)})
+
+func (t *testHeader) LayerType() LayerType  { return layerTypeTestHeader }
+func (t *testHeader) LayerContents() []byte { return t.contents }
+func (t *testHeader) LayerPayload() []byte  { return t.payload }
+func (t *testHeader) LinkFlow() Flow        { return InvalidFlow }
+func (t *testHeader) String() string        { return "TestHeader" }
+
+func decodeTestHeader(data []byte, p PacketBuilder) error {
+	if len(data) < 4 {
+		return errors.New("test header too short")
+	}
+	h := &testHeader{contents: data[:4], payload: data[4:]}
+	p.AddLayer(h)
+	p.SetLinkLayer(h)
+	return p.NextDecoder(DecodePayload)
+}
+
+func layerTypes(p Packet) []LayerType {
+	var out []LayerType
+	for _, l := range p.Layers() {
+		out = append(out, l.LayerType())
+	}
+	return out
+}
+
+func TestDecodeAs(t *testing.T) {
+	data := []byte("hdr1abcdhdr2efgh")
+	p := NewPacket(data, layerTypeTestHeader, Default)
+
+	for _, test := range []struct {
+		offset int
+		want   []LayerType
+		// contents of the last layer
+		last string
+	}{
+		{4, []LayerType{layerTypeTestHeader, layerTypeTestHeader, LayerTypePayload}, "hdr2efgh"},
+		{8, []LayerType{layerTypeTestHeader, LayerTypePayload, layerTypeTestHeader, LayerTypePayload}, "efgh"},
+		{0, []LayerType{layerTypeTestHeader, LayerTypePayload}, "abcdhdr2efgh"},
+		{14, []LayerType{layerTypeTestHeader, LayerTypePayload, LayerTypeDecodeFailure}, "abcdhdr2ef"},
+		{16, []LayerType{layerTypeTestHeader, LayerTypePayload}, "abcdhdr2efgh"},
+	} {
+		got, err := DecodeAs(p, test.offset, layerTypeTestHeader)
+		if err != nil {
+			t.Fatalf("offset %d: %v", test.offset, err)
+		}
+		if types := layerTypes(got); !reflect.DeepEqual(types, test.want) {
+			t.Errorf("offset %d: got layers %v, want %v", test.offset, types, test.want)
+			continue
+		}
+		layers := got.Layers()
+		last := layers[len(layers)-1]
+		if _, ok := last.(ErrorLayer); ok {
+			last = layers[len(layers)-2]
+		}
+		if string(last.LayerContents()) != test.last {
+			t.Errorf("offset %d: last layer holds %q, want %q", test.offset, last.LayerContents(), test.last)
+		}
+		if (got.ErrorLayer() != nil) != (test.want[len(test.want)-1] == LayerTypeDecodeFailure) {
+			t.Errorf("offset %d: got error layer %v", test.offset, got.ErrorLayer())
+		}
+		if test.offset >= 4 && got.LinkLayer() != p.LinkLayer() {
+			t.Errorf("offset %d: link layer not kept", test.offset)
+		}
+	}
+	// The original packet is unchanged.
+	if types := layerTypes(p); !reflect.DeepEqual(types, []LayerType{layerTypeTestHeader, LayerTypePayload}) {
+		t.Errorf("original packet now has layers %v", types)
+	}
+
+	for _, offset := range []int{-1, 17} {
+		if _, err := DecodeAs(p, offset, layerTypeTestHeader); err == nil {
+			t.Errorf("offset %d: no error", offset)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package decodecache avoids decoding the same packet bytes over and over,
+// as in broadcast and multicast storms or with retransmitted frames.
+//
+// A Cache remembers the most recently decoded packets by the hash of their
+// data. Packets whose data is identical to a remembered one share its
+// decoded layers, and only get their own metadata:
+//
+//	cache := decodecache.New(1024, layers.LinkTypeEthernet)
+//	for {
+//		data, ci, err := source.ReadPacketData()
+//		if err != nil {
+//			break
+//		}
+//		packet := cache.Decode(data, ci)
+//		...
+//	}
+//
+// The layers of packets returned by a Cache may be shared with other
+// packets, and must not be modified. Caching trades memory for CPU time: it
+// pays off when many packets are repeated, and costs a hash and a copy per
+// packet otherwise.
+package decodecache
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/google/gopacket"
+)
+
+// Stats holds the counters of a Cache.
+type Stats struct {
+	// Hits is the number of packets whose decoding was shared with an
+	// earlier packet, Misses the number of packets which were decoded.
+	Hits, Misses uint64
+	// Entries is the number of packets currently remembered.
+	Entries int
+}
+
+type entry struct {
+	hash   uint64
+	packet gopacket.Packet
+}
+
+// Cache decodes packets, sharing the decoding of packets with identical
+// data. A Cache is safe for concurrent use.
+type Cache struct {
+	decoder gopacket.Decoder
+	size    int
+
+	mu           sync.Mutex
+	entries      map[uint64][]*list.Element
+	lru          *list.List
+	hits, misses uint64
+}
+
+// New returns a Cache remembering up to size decoded packets, which decodes
+// packets starting with decoder.
+func New(size int, decoder gopacket.Decoder) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		decoder: decoder,
+		size:    size,
+		entries: make(map[uint64][]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Decode returns the decoded packet of data with the capture info ci. The
+// data is copied, so it may be reused by the caller.
+func (c *Cache) Decode(data []byte, ci gopacket.CaptureInfo) gopacket.Packet {
+	h := fnv.New64a()
+	h.Write(data)
+	hash := h.Sum64()
+
+	c.mu.Lock()
+	for _, e := range c.entries[hash] {
+		shared := e.Value.(*entry).packet
+		if bytes.Equal(shared.Data(), data) {
+			c.lru.MoveToFront(e)
+			c.hits++
+			c.mu.Unlock()
+			return newPacket(shared, ci)
+		}
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Decode outside of the lock. Concurrent misses on the same data
+	// both decode it and both add it, which is harmless.
+	shared := gopacket.NewPacket(data, c.decoder, gopacket.Default)
+
+	c.mu.Lock()
+	c.entries[hash] = append(c.entries[hash], c.lru.PushFront(&entry{hash: hash, packet: shared}))
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	c.mu.Unlock()
+	return newPacket(shared, ci)
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	hash := e.Value.(*entry).hash
+	es := c.entries[hash]
+	for i, x := range es {
+		if x == e {
+			es = append(es[:i], es[i+1:]...)
+			break
+		}
+	}
+	if len(es) == 0 {
+		delete(c.entries, hash)
+	} else {
+		c.entries[hash] = es
+	}
+}
+
+// Stats returns the counters of c.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Hits: c.hits, Misses: c.misses, Entries: c.lru.Len()}
+}
+
+// Reset forgets all remembered packets and resets the counters.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	c.entries = make(map[uint64][]*list.Element)
+	c.lru.Init()
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+}
+
+// packet is a shared decoded packet with its own metadata.
+type packet struct {
+	gopacket.Packet
+	md gopacket.PacketMetadata
+}
+
+func newPacket(shared gopacket.Packet, ci gopacket.CaptureInfo) *packet {
+	p := &packet{Packet: shared}
+	p.md.CaptureInfo = ci
+	p.md.Truncated = shared.Metadata().Truncated || ci.CaptureLength < ci.Length
+	p.md.Tunnel = shared.Metadata().Tunnel
+	p.md.Tags = shared.Metadata().Tags
+	return p
+}
+
+// Metadata returns the metadata of this packet, which is not shared.
+func (p *packet) Metadata() *gopacket.PacketMetadata {
+	return &p.md
+}
+
+// String returns the string of the shared packet, with the first line
+// describing this packet's metadata.
+func (p *packet) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "PACKET: %d bytes", len(p.Data()))
+	if p.md.Truncated {
+		b.WriteString(", truncated")
+	}
+	if p.md.Length > 0 {
+		fmt.Fprintf(&b, ", wire length %d cap length %d", p.md.Length, p.md.CaptureLength)
+	}
+	if !p.md.Timestamp.IsZero() {
+		fmt.Fprintf(&b, " @ %v", p.md.Timestamp)
+	}
+	s := p.Packet.String()
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		b.WriteString(s[i:])
+	}
+	return b.String()
+}
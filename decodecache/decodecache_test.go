@@ -0,0 +1,122 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package decodecache
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func udpPacket(t testing.TB, port uint16) []byte {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 255}}
+	udp := &layers.UDP{SrcPort: 1000, DstPort: layers.UDPPort(port)}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, eth, ip, udp, gopacket.Payload("storm")); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCache(t *testing.T) {
+	c := New(2, layers.LinkTypeEthernet)
+	a, b, d := udpPacket(t, 1), udpPacket(t, 2), udpPacket(t, 3)
+	t0 := time.Unix(1000, 0)
+	ci := func(i int, data []byte) gopacket.CaptureInfo {
+		return gopacket.CaptureInfo{Timestamp: t0.Add(time.Duration(i) * time.Second), CaptureLength: len(data), Length: len(data)}
+	}
+
+	p1 := c.Decode(a, ci(1, a))
+	p2 := c.Decode(a, ci(2, a))
+	if p1.Layer(layers.LayerTypeUDP) != p2.Layer(layers.LayerTypeUDP) {
+		t.Error("identical packets do not share their layers")
+	}
+	if !p1.Metadata().Timestamp.Equal(t0.Add(time.Second)) || !p2.Metadata().Timestamp.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("timestamps %v, %v", p1.Metadata().Timestamp, p2.Metadata().Timestamp)
+	}
+	if s := p2.String(); !strings.Contains(s, "@ "+p2.Metadata().Timestamp.String()) || !strings.Contains(s, "UDP") {
+		t.Errorf("String() = %q", s)
+	}
+
+	// The cache copies data, so the caller may reuse its buffer.
+	buf := append([]byte(nil), b...)
+	c.Decode(buf, ci(3, buf))
+	copy(buf, d)
+	p4 := c.Decode(buf, ci(4, buf))
+	if got := p4.Layer(layers.LayerTypeUDP).(*layers.UDP).DstPort; got != 3 {
+		t.Errorf("reused buffer decoded with port %d, want 3", got)
+	}
+	if want := (Stats{Hits: 1, Misses: 3, Entries: 2}); c.Stats() != want {
+		t.Errorf("stats %+v, want %+v", c.Stats(), want)
+	}
+
+	// a was evicted as the least recently used packet.
+	p5 := c.Decode(a, ci(5, a))
+	if p5.Layer(layers.LayerTypeUDP) == p1.Layer(layers.LayerTypeUDP) {
+		t.Error("evicted packet still shared")
+	}
+
+	// Truncation comes from the capture info of each packet.
+	short := ci(6, a)
+	short.Length += 10
+	if !c.Decode(a, short).Metadata().Truncated || c.Decode(a, ci(7, a)).Metadata().Truncated {
+		t.Error("truncation not taken from capture info")
+	}
+
+	c.Reset()
+	if want := (Stats{}); c.Stats() != want {
+		t.Errorf("stats after Reset %+v", c.Stats())
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := New(8, layers.LinkTypeEthernet)
+	var packets [][]byte
+	for i := 0; i < 16; i++ {
+		packets = append(packets, udpPacket(t, uint16(i)))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				port := i % len(packets)
+				p := c.Decode(packets[port], gopacket.CaptureInfo{})
+				if got := p.Layer(layers.LayerTypeUDP).(*layers.UDP).DstPort; int(got) != port {
+					t.Errorf("decoded port %d, want %d", got, port)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if s := c.Stats(); s.Hits+s.Misses != 4000 || s.Entries > 8 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func BenchmarkDecodeHit(b *testing.B) {
+	c := New(16, layers.LinkTypeEthernet)
+	data := udpPacket(b, 1)
+	for i := 0; i < b.N; i++ {
+		c.Decode(data, gopacket.CaptureInfo{})
+	}
+}
+
+func BenchmarkDecodeNoCache(b *testing.B) {
+	data := udpPacket(b, 1)
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package decodeprof measures the heap allocations made by each layer
+// decoder for a given traffic mix, to find the decoders most worth
+// optimizing.
+//
+// A Profiler is fed already decoded packets. For a sample of them it decodes
+// every layer again on its own, reading the runtime's allocation counters
+// around the call, and accumulates the results per layer type:
+//
+//  prof := decodeprof.NewProfiler()
+//  for packet := range source.Packets() {
+//  	prof.Add(packet)
+//  }
+//  prof.WriteReport(os.Stdout)
+//
+// Each layer is decoded in isolation: allocations made by the decoders it
+// hands off to are attributed to those layers instead. Reading the
+// allocation counters stops the world, so profiling is much slower than
+// normal decoding and should only be used for diagnostics. Allocations made
+// concurrently by other goroutines are counted too; profile in an otherwise
+// quiet process for accurate numbers.
+package decodeprof
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/google/gopacket"
+)
+
+// Stats holds the allocations attributed to the decoder of one layer type.
+type Stats struct {
+	LayerType gopacket.LayerType
+	// Decodes is the number of profiled decodes.
+	Decodes uint64
+	// Allocs and Bytes are the heap allocations made by those decodes.
+	Allocs uint64
+	Bytes  uint64
+}
+
+// AllocsPerDecode returns the average number of allocations per decode.
+func (s Stats) AllocsPerDecode() float64 {
+	if s.Decodes == 0 {
+		return 0
+	}
+	return float64(s.Allocs) / float64(s.Decodes)
+}
+
+// BytesPerDecode returns the average number of bytes allocated per decode.
+func (s Stats) BytesPerDecode() float64 {
+	if s.Decodes == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.Decodes)
+}
+
+// Profiler accumulates per-layer allocation statistics. It is not safe for
+// concurrent use.
+type Profiler struct {
+	// SampleEvery profiles only every SampleEvery'th packet passed to Add.
+	// Values below 1 profile every packet.
+	SampleEvery int
+	// Repeat is the number of times each layer is decoded per sample. The
+	// allocation counters are read once around all repetitions, which
+	// amortizes their cost. Values below 1 decode each layer once.
+	Repeat int
+	// Options are the decode options passed to decoders.
+	Options gopacket.DecodeOptions
+
+	seen  int
+	stats map[gopacket.LayerType]*Stats
+	b     builder
+}
+
+// NewProfiler returns a Profiler that profiles every packet, decoding each
+// layer 16 times per sample.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		SampleEvery: 1,
+		Repeat:      16,
+		stats:       make(map[gopacket.LayerType]*Stats),
+	}
+}
+
+// Add profiles the layers of packet, if it is part of the sample.
+func (p *Profiler) Add(packet gopacket.Packet) {
+	p.seen++
+	if p.SampleEvery > 1 && p.seen%p.SampleEvery != 1 {
+		return
+	}
+	if p.stats == nil {
+		p.stats = make(map[gopacket.LayerType]*Stats)
+	}
+	data := packet.Data()
+	for _, l := range packet.Layers() {
+		if l.LayerType() == gopacket.LayerTypeDecodeFailure {
+			break
+		}
+		p.profile(l.LayerType(), data)
+		data = l.LayerPayload()
+	}
+}
+
+// profile decodes data as typ and records the allocations made.
+func (p *Profiler) profile(typ gopacket.LayerType, data []byte) {
+	repeat := p.Repeat
+	if repeat < 1 {
+		repeat = 1
+	}
+	p.b.opts = &p.Options
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < repeat; i++ {
+		typ.Decode(data, &p.b)
+	}
+	runtime.ReadMemStats(&after)
+
+	s, ok := p.stats[typ]
+	if !ok {
+		s = &Stats{LayerType: typ}
+		p.stats[typ] = s
+	}
+	s.Decodes += uint64(repeat)
+	s.Allocs += after.Mallocs - before.Mallocs
+	s.Bytes += after.TotalAlloc - before.TotalAlloc
+}
+
+// Stats returns the statistics gathered so far, most allocated bytes first.
+func (p *Profiler) Stats() []Stats {
+	out := make([]Stats, 0, len(p.stats))
+	for _, s := range p.stats {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return out[i].LayerType < out[j].LayerType
+	})
+	return out
+}
+
+// WriteReport writes a table of the gathered statistics to w.
+func (p *Profiler) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "layer\tdecodes\tallocs/op\tbytes/op\ttotal bytes\t")
+	for _, s := range p.Stats() {
+		fmt.Fprintf(tw, "%v\t%d\t%.1f\t%.1f\t%d\t\n", s.LayerType, s.Decodes, s.AllocsPerDecode(), s.BytesPerDecode(), s.Bytes)
+	}
+	return tw.Flush()
+}
+
+// builder is a PacketBuilder which drops everything it is given, so that
+// only the allocations of a single decoder are measured.
+type builder struct {
+	opts *gopacket.DecodeOptions
+}
+
+func (b *builder) SetTruncated()                                 {}
+func (b *builder) AddLayer(l gopacket.Layer)                     {}
+func (b *builder) SetLinkLayer(gopacket.LinkLayer)               {}
+func (b *builder) SetNetworkLayer(gopacket.NetworkLayer)         {}
+func (b *builder) SetTransportLayer(gopacket.TransportLayer)     {}
+func (b *builder) SetApplicationLayer(gopacket.ApplicationLayer) {}
+func (b *builder) SetErrorLayer(gopacket.ErrorLayer)             {}
+func (b *builder) NextDecoder(next gopacket.Decoder) error       { return nil }
+func (b *builder) DumpPacketData()                               {}
+func (b *builder) DecodeOptions() *gopacket.DecodeOptions        { return b.opts }
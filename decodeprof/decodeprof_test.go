@@ -0,0 +1,64 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package decodeprof
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func testPacket(t *testing.T) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: []byte{10, 0, 0, 1}, DstIP: []byte{10, 0, 0, 2}}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	udp.SetNetworkLayerForChecksum(ip)
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		&layers.Ethernet{SrcMAC: make([]byte, 6), DstMAC: make([]byte, 6), EthernetType: layers.EthernetTypeIPv4},
+		ip, udp, gopacket.Payload("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LinkTypeEthernet, gopacket.Default)
+}
+
+func TestProfiler(t *testing.T) {
+	prof := NewProfiler()
+	prof.SampleEvery = 2
+	p := testPacket(t)
+	for i := 0; i < 3; i++ {
+		prof.Add(p)
+	}
+
+	stats := prof.Stats()
+	if len(stats) != 4 {
+		t.Fatalf("got stats for %d layers, want 4: %+v", len(stats), stats)
+	}
+	for _, s := range stats {
+		// Two of the three packets are sampled.
+		if s.Decodes != 2*16 {
+			t.Errorf("%v: got %d decodes", s.LayerType, s.Decodes)
+		}
+		// Every decoder allocates at least its layer.
+		if s.AllocsPerDecode() < 1 || s.Bytes == 0 {
+			t.Errorf("%v: got %.1f allocs, %d bytes per decode", s.LayerType, s.AllocsPerDecode(), s.Bytes)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := prof.WriteReport(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Ethernet", "IPv4", "UDP", "Payload"} {
+		if !strings.Contains(out.String(), name) {
+			t.Errorf("report is missing %s:\n%s", name, out.String())
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package dot11decrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// pbkdf2SHA1 derives a key from a password, as RFC 2898 describes, the
+// way WPA derives PMKs from passphrases.
+func pbkdf2SHA1(password, salt []byte, iterations, length int) []byte {
+	h := hmac.New(sha1.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < length; block++ {
+		h.Reset()
+		h.Write(salt)
+		binary.Write(h, binary.BigEndian, block)
+		u := h.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(u)
+			u = h.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:length]
+}
+
+// prf is the HMAC-SHA1 based pseudo-random function of 802.11i, returning
+// length bytes.
+func prf(key []byte, label string, data []byte, length int) []byte {
+	h := hmac.New(sha1.New, key)
+	var out []byte
+	for i := byte(0); len(out) < length; i++ {
+		h.Reset()
+		h.Write([]byte(label))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{i})
+		out = h.Sum(out)
+	}
+	return out[:length]
+}
+
+const (
+	ccmpHeaderLength = 8
+	ccmpMICLength    = 8
+)
+
+// ccmpAAD returns the additional authenticated data and the nonce CCMP
+// computes from the header of a frame and its packet number.
+func ccmpAAD(header []byte, pn [6]byte) (aad, nonce []byte) {
+	qos := header[0]&0x8c == 0x88
+	addr4 := header[1]&0x03 == 0x03
+
+	aad = make([]byte, 0, 30)
+	// The subtype bits of data frames, and the retry, power management and
+	// more data flags are masked, the protected flag set. So is the order
+	// flag of QoS data frames, which tells an HT control field.
+	fc1 := header[1]&^0x38 | 0x40
+	if qos {
+		fc1 &^= 0x80
+	}
+	aad = append(aad, header[0]&0x8f, fc1)
+	aad = append(aad, header[4:22]...)
+	// Only the fragment number of the sequence control is kept.
+	aad = append(aad, header[22]&0x0f, 0)
+	offset := 24
+	if addr4 {
+		aad = append(aad, header[24:30]...)
+		offset += 6
+	}
+	var priority byte
+	if qos {
+		priority = header[offset] & 0x0f
+		aad = append(aad, priority, 0)
+	}
+
+	nonce = make([]byte, 0, 13)
+	nonce = append(nonce, priority)
+	nonce = append(nonce, header[10:16]...)
+	nonce = append(nonce, pn[:]...)
+	return aad, nonce
+}
+
+// ccmpDecrypt decrypts the body of a protected frame, starting with its
+// CCMP header and ending with its MIC, with the temporal key tk.
+func ccmpDecrypt(tk, header, body []byte) ([]byte, error) {
+	block, err := aes.NewCipher(tk)
+	if err != nil {
+		return nil, err
+	}
+	pn := [6]byte{body[7], body[6], body[5], body[4], body[1], body[0]}
+	aad, nonce := ccmpAAD(header, pn)
+	ciphertext := body[ccmpHeaderLength : len(body)-ccmpMICLength]
+	mic := body[len(body)-ccmpMICLength:]
+
+	plaintext := make([]byte, len(ciphertext))
+	s0 := ccmCTR(block, nonce, plaintext, ciphertext)
+	tag := ccmMAC(block, nonce, aad, plaintext)
+	for i := range tag {
+		tag[i] ^= s0[i]
+	}
+	if subtle.ConstantTimeCompare(tag, mic) != 1 {
+		return nil, errors.New("CCMP MIC mismatch")
+	}
+	return plaintext, nil
+}
+
+// ccmCTR encrypts or decrypts src into dst in the counter mode of CCM with
+// 2 byte lengths, returning the first key stream block, which encrypts the
+// MIC.
+func ccmCTR(block cipher.Block, nonce, dst, src []byte) []byte {
+	var ctr [aes.BlockSize]byte
+	ctr[0] = 0x01
+	copy(ctr[1:], nonce)
+	s0 := make([]byte, aes.BlockSize)
+	block.Encrypt(s0, ctr[:])
+	ctr[15] = 1
+	cipher.NewCTR(block, ctr[:]).XORKeyStream(dst, src)
+	return s0
+}
+
+// ccmMAC returns the 8 byte CBC-MAC of CCM with 2 byte lengths.
+func ccmMAC(block cipher.Block, nonce, aad, plaintext []byte) []byte {
+	var x, b [aes.BlockSize]byte
+	b[0] = 0x40 | (ccmpMICLength-2)/2<<3 | 0x01
+	copy(b[1:], nonce)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(plaintext)))
+	block.Encrypt(x[:], b[:])
+
+	mac := func(data []byte) {
+		for len(data) > 0 {
+			n := copy(b[:], data)
+			for i := n; i < len(b); i++ {
+				b[i] = 0
+			}
+			for i := range x {
+				x[i] ^= b[i]
+			}
+			block.Encrypt(x[:], x[:])
+			data = data[n:]
+		}
+	}
+	a := make([]byte, 2, 2+len(aad))
+	binary.BigEndian.PutUint16(a, uint16(len(aad)))
+	mac(append(a, aad...))
+	mac(plaintext)
+	return append([]byte(nil), x[:ccmpMICLength]...)
+}
@@ -0,0 +1,339 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package dot11decrypt decrypts the data frames of WPA2 personal networks,
+// protected with CCMP, from the passphrases of the networks and the 4-way
+// handshakes of their stations.
+//
+// A Decrypter must see the handshake of a station, from message 1 to
+// message 3, before it can decrypt the frames the station and its access
+// point exchange: the pairwise keys are derived from the PMK and the
+// nonces of the handshake, and the PMK is told by the MIC of message 2.
+// The group key of broadcast and multicast frames is taken from message 3,
+// and from the group key handshakes which follow, which it decrypts too.
+//
+//	d := dot11decrypt.NewDecrypter()
+//	if err := d.AddPassphrase("lab", "correct horse battery staple"); err != nil {
+//		log.Fatal(err)
+//	}
+//	for packet := range source.Packets() {
+//		decrypted, err := d.Decrypt(packet)
+//		if err != nil {
+//			log.Print(err)
+//		} else if decrypted != nil {
+//			packet = decrypted
+//		}
+//		...
+//	}
+//
+// TKIP, the 802.11r and 802.11w key hierarchies, SAE and enterprise
+// networks are not supported. Replayed frames are not detected.
+package dot11decrypt
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ErrNoKey is returned for protected frames of stations whose handshake
+// was not seen, or did not match any passphrase.
+var ErrNoKey = errors.New("no key to decrypt the frame")
+
+// DefaultMaxSessions is the number of links a Decrypter returned by
+// NewDecrypter tracks the handshakes of.
+const DefaultMaxSessions = 4096
+
+// Decrypter tracks the handshakes of stations and decrypts their frames. It
+// is not safe for concurrent use.
+type Decrypter struct {
+	// DecodeOptions are the options decrypted frames are decoded with.
+	DecodeOptions gopacket.DecodeOptions
+	// MaxSessions is the number of links whose handshakes and keys are
+	// kept. Once it is reached, the least recently used link is forgotten,
+	// and its frames cannot be decrypted until its next handshake. Zero
+	// means no limit.
+	MaxSessions int
+
+	pmks [][]byte
+	// sessions holds the sessions of links, in lru, most recently used
+	// first.
+	sessions map[link]*list.Element
+	lru      *list.List
+	// groups holds the group keys of access points, by key ID.
+	groups map[[6]byte]*[4][]byte
+}
+
+// link is an access point and one of its stations.
+type link struct {
+	bssid, sta [6]byte
+}
+
+// session is the state of the handshakes of a link.
+type session struct {
+	link           link
+	anonce, snonce []byte
+	// kck, kek and tk are the parts of the PTK, once derived.
+	kck, kek, tk []byte
+}
+
+// NewDecrypter returns a Decrypter without any passphrase.
+func NewDecrypter() *Decrypter {
+	return &Decrypter{
+		MaxSessions: DefaultMaxSessions,
+		sessions:    make(map[link]*list.Element),
+		lru:         list.New(),
+		groups:      make(map[[6]byte]*[4][]byte),
+	}
+}
+
+// session returns the session of l, creating it if create is set, and
+// marks it as the most recently used.
+func (d *Decrypter) session(l link, create bool) *session {
+	if e := d.sessions[l]; e != nil {
+		d.lru.MoveToFront(e)
+		return e.Value.(*session)
+	}
+	if !create {
+		return nil
+	}
+	s := &session{link: l}
+	d.sessions[l] = d.lru.PushFront(s)
+	for d.MaxSessions > 0 && d.lru.Len() > d.MaxSessions {
+		e := d.lru.Back()
+		d.lru.Remove(e)
+		delete(d.sessions, e.Value.(*session).link)
+	}
+	return s
+}
+
+// AddPassphrase adds the passphrase of the network named ssid, from 8 to
+// 63 ASCII characters.
+func (d *Decrypter) AddPassphrase(ssid, passphrase string) error {
+	if len(passphrase) < 8 || len(passphrase) > 63 {
+		return fmt.Errorf("passphrase length %d not between 8 and 63", len(passphrase))
+	}
+	if len(ssid) > 32 {
+		return fmt.Errorf("SSID length %d too long", len(ssid))
+	}
+	d.pmks = append(d.pmks, pbkdf2SHA1([]byte(passphrase), []byte(ssid), 4096, 32))
+	return nil
+}
+
+// AddPMK adds the 32 byte pairwise master key of a network, as derived from
+// its SSID and passphrase or 64 hexadecimal digit PSK.
+func (d *Decrypter) AddPMK(pmk []byte) error {
+	if len(pmk) != 32 {
+		return fmt.Errorf("PMK length %d invalid", len(pmk))
+	}
+	d.pmks = append(d.pmks, append([]byte(nil), pmk...))
+	return nil
+}
+
+// Decrypt returns p decrypted, decoded from its 802.11 layer, if it is a
+// data frame protected with CCMP. The frame is returned with its protected
+// flag cleared, the CCMP header and MIC removed, and a new frame check
+// sequence.
+//
+// Decrypt tracks the handshakes p carries, in the clear or protected,
+// returning an error if their MIC does not match any passphrase. It
+// returns nil for all other frames.
+func (d *Decrypter) Decrypt(p gopacket.Packet) (gopacket.Packet, error) {
+	dot11, ok := p.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	if !ok || dot11.Type.MainType() != layers.Dot11TypeData {
+		return nil, nil
+	}
+	if !dot11.Flags.WEP() {
+		return nil, d.handshake(p, dot11)
+	}
+	frame, err := d.decrypt(dot11)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := gopacket.NewPacket(frame, layers.LayerTypeDot11, d.DecodeOptions)
+	if m := p.Metadata(); m != nil {
+		ci := m.CaptureInfo
+		ci.CaptureLength, ci.Length = len(frame), len(frame)
+		decrypted.Metadata().CaptureInfo = ci
+	}
+	return decrypted, d.handshake(decrypted, decrypted.Layer(layers.LayerTypeDot11).(*layers.Dot11))
+}
+
+// linkOf returns the link of a frame an access point sent or received.
+func linkOf(dot11 *layers.Dot11) (link, bool) {
+	var l link
+	switch {
+	case dot11.Flags.FromDS() && !dot11.Flags.ToDS():
+		copy(l.bssid[:], dot11.Address2)
+		copy(l.sta[:], dot11.Address1)
+	case dot11.Flags.ToDS() && !dot11.Flags.FromDS():
+		copy(l.bssid[:], dot11.Address1)
+		copy(l.sta[:], dot11.Address2)
+	default:
+		return l, false
+	}
+	return l, true
+}
+
+// handshake tracks the EAPOL-Key frame p may carry.
+func (d *Decrypter) handshake(p gopacket.Packet, dot11 *layers.Dot11) error {
+	key, ok := p.Layer(layers.LayerTypeEAPOLKey).(*layers.EAPOLKey)
+	if !ok {
+		return nil
+	}
+	l, ok := linkOf(dot11)
+	if !ok || key.KeyDescriptorVersion != layers.EAPOLKeyDescriptorVersionAESHMACSHA1 {
+		return nil
+	}
+	eapol := p.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL)
+	frame := append(append([]byte(nil), eapol.Contents...), eapol.Payload...)
+	if n := 4 + int(eapol.Length); n < len(frame) {
+		frame = frame[:n]
+	}
+
+	s := d.session(l, true)
+	n := key.HandshakeMessage()
+	if key.KeyType == layers.EAPOLKeyTypeGroupSMK {
+		if n != 1 || s.kck == nil {
+			return nil
+		}
+		if !validMIC(s.kck, frame) {
+			return fmt.Errorf("group key handshake of %v and %v does not match its PTK", net.HardwareAddr(l.bssid[:]), net.HardwareAddr(l.sta[:]))
+		}
+		return d.groupKey(l, s, key)
+	}
+	switch n {
+	case 1:
+		s.anonce = append([]byte(nil), key.Nonce...)
+		return nil
+	case 2:
+		s.snonce = append([]byte(nil), key.Nonce...)
+	case 3:
+		s.anonce = append([]byte(nil), key.Nonce...)
+	default:
+		return nil
+	}
+	if s.anonce == nil || s.snonce == nil {
+		return nil
+	}
+	if err := d.derive(l, s, frame); err != nil {
+		return err
+	}
+	if n == 3 {
+		return d.groupKey(l, s, key)
+	}
+	return nil
+}
+
+// derive derives the PTK of a link from the PMK the MIC of frame, message 2
+// or 3 of the 4-way handshake, tells.
+func (d *Decrypter) derive(l link, s *session, frame []byte) error {
+	aa, spa := l.bssid[:], l.sta[:]
+	if bytes.Compare(aa, spa) > 0 {
+		aa, spa = spa, aa
+	}
+	anonce, snonce := s.anonce, s.snonce
+	if bytes.Compare(anonce, snonce) > 0 {
+		anonce, snonce = snonce, anonce
+	}
+	data := make([]byte, 0, 76)
+	data = append(append(append(append(data, aa...), spa...), anonce...), snonce...)
+	for _, pmk := range d.pmks {
+		ptk := prf(pmk, "Pairwise key expansion", data, 48)
+		if validMIC(ptk[:16], frame) {
+			s.kck, s.kek, s.tk = ptk[:16], ptk[16:32], ptk[32:]
+			return nil
+		}
+	}
+	return fmt.Errorf("4-way handshake of %v and %v does not match any passphrase", net.HardwareAddr(l.bssid[:]), net.HardwareAddr(l.sta[:]))
+}
+
+// groupKey stores the GTK the encrypted key data of key carries.
+func (d *Decrypter) groupKey(l link, s *session, key *layers.EAPOLKey) error {
+	if !key.HasEncryptedKeyData {
+		return nil
+	}
+	data, err := key.DecryptKeyData(s.kek)
+	if err != nil {
+		return err
+	}
+	kd, err := layers.DecodeEAPOLKeyData(data)
+	if err != nil {
+		return err
+	}
+	gtk, err := kd.GTK()
+	if err != nil {
+		return err
+	}
+	keys := d.groups[l.bssid]
+	if keys == nil {
+		keys = new([4][]byte)
+		d.groups[l.bssid] = keys
+	}
+	keys[gtk.KeyID] = append([]byte(nil), gtk.Key...)
+	return nil
+}
+
+// eapolKeyMICOffset is the offset of the MIC in EAPOL-Key frames, from
+// their EAPOL header.
+const eapolKeyMICOffset = 81
+
+// validMIC tells whether the MIC of the EAPOL-Key frame is the HMAC-SHA1
+// of the frame, with its MIC zeroed, under kck.
+func validMIC(kck, frame []byte) bool {
+	if len(frame) < eapolKeyMICOffset+16 {
+		return false
+	}
+	mic := append([]byte(nil), frame[eapolKeyMICOffset:eapolKeyMICOffset+16]...)
+	zeroed := append([]byte(nil), frame...)
+	copy(zeroed[eapolKeyMICOffset:], make([]byte, 16))
+	h := hmac.New(sha1.New, kck)
+	h.Write(zeroed)
+	return hmac.Equal(h.Sum(nil)[:16], mic)
+}
+
+// decrypt returns the decrypted frame of a protected data frame.
+func (d *Decrypter) decrypt(dot11 *layers.Dot11) ([]byte, error) {
+	header, body := dot11.Contents, dot11.Payload
+	if len(body) < ccmpHeaderLength+ccmpMICLength {
+		return nil, fmt.Errorf("protected frame length %d too short", len(body))
+	}
+	if body[3]&0x20 == 0 {
+		return nil, errors.New("protected frame is not a CCMP frame")
+	}
+	l, ok := linkOf(dot11)
+	if !ok {
+		return nil, errors.New("cannot decrypt frames of the wireless distribution system or of ad hoc networks")
+	}
+	var tk []byte
+	if dot11.Address1[0]&1 != 0 {
+		if keys := d.groups[l.bssid]; keys != nil {
+			tk = keys[body[3]>>6]
+		}
+	} else if s := d.session(l, false); s != nil {
+		tk = s.tk
+	}
+	if tk == nil {
+		return nil, ErrNoKey
+	}
+	plaintext, err := ccmpDecrypt(tk, header, body)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 0, len(header)+len(plaintext)+4)
+	frame = append(append(frame, header...), plaintext...)
+	frame[1] &^= uint8(layers.Dot11FlagsWEP)
+	fcs := crc32.ChecksumIEEE(frame)
+	return append(frame, byte(fcs), byte(fcs>>8), byte(fcs>>16), byte(fcs>>24)), nil
+}
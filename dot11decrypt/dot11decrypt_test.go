@@ -0,0 +1,272 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package dot11decrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestPBKDF2(t *testing.T) {
+	// IEEE 802.11i, annex H.4.
+	got := pbkdf2SHA1([]byte("password"), []byte("IEEE"), 4096, 32)
+	if want := unhex(t, "f42c6fc52df0ebef9ebb4b90b38a5f902e83fe1b135a70e23aed762e9710a12e"); !bytes.Equal(got, want) {
+		t.Errorf("PMK %x, want %x", got, want)
+	}
+}
+
+func TestCCMP(t *testing.T) {
+	// IEEE 802.11, annex M.6.4.
+	tk := unhex(t, "c97c1f67ce371185514a8a19f2bdd52f")
+	header := unhex(t, "0848c32c0fd2e128a57c5030f1844408abaea5b8fcba8033")
+	body := unhex(t, "0ce70020769703b5"+"f3d0a2fe9a3dbf2342a643e43246e80c3c04d019"+"7845ce0b16f97623")
+	got, err := ccmpDecrypt(tk, header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex(t, "f8ba1a55d02f85ae967bb62fb6cda8eb7e78a050"); !bytes.Equal(got, want) {
+		t.Errorf("decrypted %x, want %x", got, want)
+	}
+	body[len(body)-1] ^= 1
+	if _, err := ccmpDecrypt(tk, header, body); err == nil {
+		t.Error("decrypted a frame with a corrupted MIC")
+	}
+}
+
+var (
+	testAP  = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	testSTA = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+)
+
+func serialize(t *testing.T, ls ...gopacket.SerializableLayer) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+func dot11Header(fromAP bool, typ layers.Dot11Type) *layers.Dot11 {
+	d := &layers.Dot11{Type: typ, SequenceNumber: 42, AppendFCS: true}
+	if fromAP {
+		d.Flags = layers.Dot11FlagsFromDS
+		d.Address1, d.Address2 = testSTA, testAP
+	} else {
+		d.Flags = layers.Dot11FlagsToDS
+		d.Address1, d.Address2 = testAP, testSTA
+	}
+	d.Address3 = testAP
+	if typ.QOS() {
+		d.QOS = &layers.Dot11QOS{TID: 5}
+	}
+	return d
+}
+
+// handshakeFrame returns a data frame carrying the EAPOL-Key frame key, with
+// its MIC computed with kck if it is set.
+func handshakeFrame(t *testing.T, fromAP bool, key *layers.EAPOLKey, kck []byte) []byte {
+	key.KeyDescriptorType = layers.EAPOLKeyDescriptorTypeDot11
+	key.KeyDescriptorVersion = layers.EAPOLKeyDescriptorVersionAESHMACSHA1
+	key.KeyDataLength = uint16(len(key.EncryptedKeyData))
+	key.MIC = nil
+	eapol := &layers.EAPOL{Version: 2, Type: layers.EAPOLTypeKey, Length: uint16(95 + len(key.EncryptedKeyData))}
+	if kck != nil {
+		h := hmac.New(sha1.New, kck)
+		h.Write(serialize(t, eapol, key))
+		key.MIC = h.Sum(nil)[:16]
+	}
+	return serialize(t, dot11Header(fromAP, layers.Dot11TypeData), &layers.Dot11Data{},
+		&layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+		&layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeEAPOL},
+		eapol, key)
+}
+
+// aesKeyWrap wraps data with kek, as RFC 3394 describes.
+func aesKeyWrap(kek, data []byte) []byte {
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		panic(err)
+	}
+	n := len(data) / 8
+	a := uint64(0xa6a6a6a6a6a6a6a6)
+	r := append([]byte(nil), data...)
+	var b [16]byte
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			binary.BigEndian.PutUint64(b[:8], a)
+			copy(b[8:], r[(i-1)*8:i*8])
+			c.Encrypt(b[:], b[:])
+			a = binary.BigEndian.Uint64(b[:8]) ^ uint64(n*j+i)
+			copy(r[(i-1)*8:], b[8:])
+		}
+	}
+	out := make([]byte, 8, 8+len(r))
+	binary.BigEndian.PutUint64(out, a)
+	return append(out, r...)
+}
+
+// protectedFrame returns the frame of header and plaintext, protected with
+// CCMP under tk.
+func protectedFrame(t *testing.T, d *layers.Dot11, tk []byte, keyID byte, pn uint64, plaintext []byte) []byte {
+	d.Flags |= layers.Dot11FlagsWEP
+	frame := serialize(t, d)
+	header := frame[:len(frame)-4]
+
+	var pnb [6]byte
+	for i := range pnb {
+		pnb[i] = byte(pn >> (40 - 8*uint(i)))
+	}
+	body := []byte{pnb[5], pnb[4], 0, 0x20 | keyID<<6, pnb[3], pnb[2], pnb[1], pnb[0]}
+	block, _ := aes.NewCipher(tk)
+	aad, nonce := ccmpAAD(header, pnb)
+	tag := ccmMAC(block, nonce, aad, plaintext)
+	ciphertext := make([]byte, len(plaintext))
+	s0 := ccmCTR(block, nonce, ciphertext, plaintext)
+	for i := range tag {
+		tag[i] ^= s0[i]
+	}
+	body = append(append(body, ciphertext...), tag...)
+	return serialize(t, d, gopacket.Payload(body))
+}
+
+func TestDecrypter(t *testing.T) {
+	const ssid, passphrase = "lab", "correct horse battery staple"
+	pmk := pbkdf2SHA1([]byte(passphrase), []byte(ssid), 4096, 32)
+	anonce := bytes.Repeat([]byte{0xa1}, 32)
+	snonce := bytes.Repeat([]byte{0x5b}, 32)
+	ptk := prf(pmk, "Pairwise key expansion", append(append(append(append([]byte(nil), testAP...), testSTA...), snonce...), anonce...), 48)
+	kck, kek, tk := ptk[:16], ptk[16:32], ptk[32:]
+	gtk := bytes.Repeat([]byte{0x67}, 16)
+	keyData := append(append([]byte{0xdd, 0x16, 0x00, 0x0f, 0xac, 0x01, 0x02, 0x00}, gtk...), 0xdd, 0x00, 0, 0, 0, 0, 0, 0)
+
+	handshake := [][]byte{
+		handshakeFrame(t, true, &layers.EAPOLKey{KeyType: layers.EAPOLKeyTypePairwise, KeyACK: true, KeyLength: 16, ReplayCounter: 1, Nonce: anonce}, nil),
+		handshakeFrame(t, false, &layers.EAPOLKey{KeyType: layers.EAPOLKeyTypePairwise, KeyMIC: true, ReplayCounter: 1, Nonce: snonce}, kck),
+		handshakeFrame(t, true, &layers.EAPOLKey{KeyType: layers.EAPOLKeyTypePairwise, KeyACK: true, KeyMIC: true, Install: true, Secure: true,
+			HasEncryptedKeyData: true, KeyLength: 16, ReplayCounter: 2, Nonce: anonce, EncryptedKeyData: aesKeyWrap(kek, keyData)}, kck),
+		handshakeFrame(t, false, &layers.EAPOLKey{KeyType: layers.EAPOLKeyTypePairwise, KeyMIC: true, Secure: true, ReplayCounter: 2, Nonce: make([]byte, 32)}, kck),
+	}
+
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &layers.UDP{SrcPort: 5000, DstPort: 5001}
+	udp.SetNetworkLayerForChecksum(ip)
+	plaintext := serialize(t, &layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+		&layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeIPv4},
+		ip, udp, gopacket.Payload("hello"))
+	unicast := protectedFrame(t, dot11Header(true, layers.Dot11TypeDataQOSData), tk, 0, 1, plaintext)
+	broadcast := dot11Header(true, layers.Dot11TypeData)
+	broadcast.Address1 = layers.EthernetBroadcast
+	group := protectedFrame(t, broadcast, gtk, 2, 7, plaintext)
+
+	decode := func(frame []byte) gopacket.Packet {
+		p := gopacket.NewPacket(frame, layers.LayerTypeDot11, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+		}
+		return p
+	}
+
+	d := NewDecrypter()
+	if err := d.AddPassphrase("other", "not the passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddPassphrase(ssid, passphrase); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Decrypt(decode(unicast)); err != ErrNoKey {
+		t.Errorf("decrypted a frame before the handshake: %v", err)
+	}
+	for i, frame := range handshake {
+		if p, err := d.Decrypt(decode(frame)); p != nil || err != nil {
+			t.Fatalf("message %d: %v, %v", i+1, p, err)
+		}
+	}
+	for _, frame := range [][]byte{unicast, group} {
+		p, err := d.Decrypt(decode(frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ErrorLayer() != nil {
+			t.Fatal("Failed to decode decrypted packet:", p.ErrorLayer().Error())
+		}
+		if dot11 := p.Layer(layers.LayerTypeDot11).(*layers.Dot11); dot11.Flags.WEP() || !dot11.ChecksumValid() {
+			t.Errorf("decrypted frame flags %v, checksum valid %t", dot11.Flags, dot11.ChecksumValid())
+		}
+		udp, ok := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if !ok || udp.DstPort != 5001 || string(udp.Payload) != "hello" {
+			t.Errorf("decrypted %v", p)
+		}
+	}
+
+	corrupted := append([]byte(nil), unicast...)
+	corrupted[len(corrupted)-6] ^= 1
+	if _, err := d.Decrypt(decode(corrupted)); err == nil {
+		t.Error("decrypted a corrupted frame")
+	}
+
+	d = NewDecrypter()
+	if err := d.AddPassphrase(ssid, "not the passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	d.Decrypt(decode(handshake[0]))
+	if _, err := d.Decrypt(decode(handshake[1])); err == nil {
+		t.Error("handshake matched the wrong passphrase")
+	}
+	if _, err := d.Decrypt(decode(unicast)); err != ErrNoKey {
+		t.Errorf("decrypted with the wrong passphrase: %v", err)
+	}
+}
+
+func TestDecrypterMaxSessions(t *testing.T) {
+	d := NewDecrypter()
+	d.MaxSessions = 2
+	links := []link{{sta: [6]byte{1}}, {sta: [6]byte{2}}, {sta: [6]byte{3}}}
+	first := d.session(links[0], true)
+	d.session(links[1], true)
+	if d.session(links[0], false) != first {
+		t.Fatal("lost a session below the limit")
+	}
+	d.session(links[2], true)
+	if len(d.sessions) != 2 || d.lru.Len() != 2 {
+		t.Fatalf("%d sessions, %d in the LRU list, want 2", len(d.sessions), d.lru.Len())
+	}
+	if d.session(links[1], false) != nil {
+		t.Error("kept the least recently used session")
+	}
+	if d.session(links[0], false) != first || d.session(links[2], false) == nil {
+		t.Error("evicted a recently used session")
+	}
+}
+
+func TestAddPassphrase(t *testing.T) {
+	d := NewDecrypter()
+	for _, passphrase := range []string{"short", string(bytes.Repeat([]byte{'a'}, 64))} {
+		if err := d.AddPassphrase("lab", passphrase); err == nil {
+			t.Errorf("added passphrase %q", passphrase)
+		}
+	}
+	if err := d.AddPMK(make([]byte, 16)); err == nil {
+		t.Error("added a 16 byte PMK")
+	}
+}
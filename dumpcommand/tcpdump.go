@@ -7,11 +7,23 @@
 // Package dumpcommand implements a run function for pfdump and pcapdump
 // with many similar flags/features to tcpdump.  This code is split out seperate
 // from data sources (pcap/pfring) so it can be used by both.
+//
+// Run configures itself from command line flags. Programs embedding the
+// dump loop call Dump with Options instead, and get its Stats back:
+//
+//  stats, err := dumpcommand.Dump(handle, dumpcommand.Options{
+//  	Decoder: layers.LayerTypeEthernet,
+//  	Print:   true,
+//  	Out:     w,
+//  }, done)
 package dumpcommand
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"time"
@@ -32,34 +44,122 @@ var (
 	defrag      = flag.Bool("defrag", false, "If true, do IPv4 defrag")
 )
 
-func Run(src gopacket.PacketDataSource) {
+// Options configures Dump. The zero value decodes packets and counts them
+// without printing anything.
+type Options struct {
+	// Decoder decodes the packets, layers.LayerTypeEthernet if nil.
+	Decoder gopacket.Decoder
+	// Print prints each packet, and DumpPackets a hex dump of each
+	// packet and its layers.
+	Print, DumpPackets bool
+	// PrintErrors prints a dump of the packets which could not be decoded.
+	PrintErrors bool
+	// Lazy decodes packets lazily. Packets which are not printed are not
+	// counted by layer type or as errors then.
+	Lazy bool
+	// Defrag reassembles fragmented IPv4 packets, and drops packets
+	// without an IPv4 layer.
+	Defrag bool
+	// MaxCount, if positive, stops Dump after that many packets.
+	MaxCount int
+	// StatsEvery, if positive, prints statistics every StatsEvery packets
+	// and at the end.
+	StatsEvery int
+	// Out receives the packets and Log the statistics. They default to
+	// os.Stdout and os.Stderr.
+	Out, Log io.Writer
+}
+
+// Stats holds what Dump saw.
+type Stats struct {
+	Packets    int
+	Bytes      int64
+	Errors     int
+	Truncated  int
+	LayerTypes map[gopacket.LayerType]int
+}
+
+func (s *Stats) print(w io.Writer, start time.Time) {
+	fmt.Fprintf(w, "Processed %v packets (%v bytes) in %v, %v errors and %v truncated packets\n", s.Packets, s.Bytes, time.Since(start), s.Errors, s.Truncated)
+	if len(s.LayerTypes) > 0 {
+		fmt.Fprintf(w, "Layer types seen: %+v\n", s.LayerTypes)
+	}
+}
+
+// FlagOptions returns the Options set by the command line flags.
+func FlagOptions() (Options, error) {
 	if !flag.Parsed() {
-		log.Fatalln("Run called without flags.Parse() being called")
+		return Options{}, errors.New("FlagOptions called without flags.Parse() being called")
+	}
+	dec, ok := gopacket.DecodersByLayerName[*decoder]
+	if !ok {
+		return Options{}, fmt.Errorf("No decoder named %s", *decoder)
+	}
+	return Options{
+		Decoder:     dec,
+		Print:       *print,
+		DumpPackets: *dump,
+		PrintErrors: *printErrors,
+		Lazy:        *lazy,
+		Defrag:      *defrag,
+		MaxCount:    *maxcount,
+		StatsEvery:  *statsevery,
+	}, nil
+}
+
+// Run dumps the packets of src as configured by the command line flags,
+// exiting on errors.
+func Run(src gopacket.PacketDataSource) {
+	opts, err := FlagOptions()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if _, err := Dump(src, opts, nil); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Dump reads and decodes the packets of src until it is exhausted,
+// opts.MaxCount packets have been read or done is closed. It returns an
+// error if IPv4 reassembly fails.
+func Dump(src gopacket.PacketDataSource, opts Options, done <-chan struct{}) (Stats, error) {
+	if opts.Decoder == nil {
+		opts.Decoder = layers.LayerTypeEthernet
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	if opts.Log == nil {
+		opts.Log = os.Stderr
 	}
-	var dec gopacket.Decoder
-	var ok bool
-	if dec, ok = gopacket.DecodersByLayerName[*decoder]; !ok {
-		log.Fatalln("No decoder named", *decoder)
+	if opts.StatsEvery <= 0 {
+		opts.Log = ioutil.Discard
 	}
-	source := gopacket.NewPacketSource(src, dec)
-	source.Lazy = *lazy
+	source := gopacket.NewPacketSource(src, opts.Decoder)
+	source.Lazy = opts.Lazy
 	source.NoCopy = true
 	source.DecodeStreamsAsDatagrams = true
-	fmt.Fprintln(os.Stderr, "Starting to read packets")
-	count := 0
-	bytes := int64(0)
+	fmt.Fprintln(opts.Log, "Starting to read packets")
+	stats := Stats{LayerTypes: map[gopacket.LayerType]int{}}
 	start := time.Now()
-	errors := 0
-	truncated := 0
-	layertypes := map[gopacket.LayerType]int{}
 	defragger := ip4defrag.NewIPv4Defragmenter()
+	packets := source.Packets()
 
-	for packet := range source.Packets() {
-		count++
-		bytes += int64(len(packet.Data()))
+	for {
+		var packet gopacket.Packet
+		select {
+		case packet = <-packets:
+		case <-done:
+		}
+		if packet == nil {
+			stats.print(opts.Log, start)
+			return stats, nil
+		}
+		stats.Packets++
+		stats.Bytes += int64(len(packet.Data()))
 
 		// defrag the IPv4 packet if required
-		if *defrag {
+		if opts.Defrag {
 			ip4Layer := packet.Layer(layers.LayerTypeIPv4)
 			if ip4Layer == nil {
 				continue
@@ -69,12 +169,12 @@ func Run(src gopacket.PacketDataSource) {
 
 			newip4, err := defragger.DefragIPv4(ip4)
 			if err != nil {
-				log.Fatalln("Error while de-fragmenting", err)
+				return stats, fmt.Errorf("error while de-fragmenting: %v", err)
 			} else if newip4 == nil {
 				continue // packet fragment, we don't have whole packet yet.
 			}
 			if newip4.Length != l {
-				fmt.Printf("Decoding re-assembled packet: %s\n", newip4.NextLayerType())
+				fmt.Fprintf(opts.Out, "Decoding re-assembled packet: %s\n", newip4.NextLayerType())
 				pb, ok := packet.(gopacket.PacketBuilder)
 				if !ok {
 					panic("Not a PacketBuilder")
@@ -84,36 +184,34 @@ func Run(src gopacket.PacketDataSource) {
 			}
 		}
 
-		if *dump {
-			fmt.Println(packet.Dump())
-		} else if *print {
-			fmt.Println(packet)
+		if opts.DumpPackets {
+			fmt.Fprintln(opts.Out, packet.Dump())
+		} else if opts.Print {
+			fmt.Fprintln(opts.Out, packet)
 		}
-		if !*lazy || *print || *dump { // if we've already decoded all layers...
+		if !opts.Lazy || opts.Print || opts.DumpPackets { // if we've already decoded all layers...
 			for _, layer := range packet.Layers() {
-				layertypes[layer.LayerType()]++
+				stats.LayerTypes[layer.LayerType()]++
 			}
 			if packet.Metadata().Truncated {
-				truncated++
+				stats.Truncated++
 			}
 			if errLayer := packet.ErrorLayer(); errLayer != nil {
-				errors++
-				if *printErrors {
-					fmt.Println("Error:", errLayer.Error())
-					fmt.Println("--- Packet ---")
-					fmt.Println(packet.Dump())
+				stats.Errors++
+				if opts.PrintErrors {
+					fmt.Fprintln(opts.Out, "Error:", errLayer.Error())
+					fmt.Fprintln(opts.Out, "--- Packet ---")
+					fmt.Fprintln(opts.Out, packet.Dump())
 				}
 			}
 		}
-		done := *maxcount > 0 && count >= *maxcount
-		if count%*statsevery == 0 || done {
-			fmt.Fprintf(os.Stderr, "Processed %v packets (%v bytes) in %v, %v errors and %v truncated packets\n", count, bytes, time.Since(start), errors, truncated)
-			if len(layertypes) > 0 {
-				fmt.Fprintf(os.Stderr, "Layer types seen: %+v\n", layertypes)
-			}
+		last := opts.MaxCount > 0 && stats.Packets >= opts.MaxCount
+		if last {
+			stats.print(opts.Log, start)
+			return stats, nil
 		}
-		if done {
-			break
+		if opts.StatsEvery > 0 && stats.Packets%opts.StatsEvery == 0 {
+			stats.print(opts.Log, start)
 		}
 	}
 }
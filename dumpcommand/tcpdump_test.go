@@ -0,0 +1,76 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package dumpcommand
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// packets is a PacketDataSource returning its packets in order.
+type packets [][]byte
+
+func (p *packets) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(*p) == 0 {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	data := (*p)[0]
+	*p = (*p)[1:]
+	return data, gopacket.CaptureInfo{CaptureLength: len(data), Length: len(data)}, nil
+}
+
+func udpPacket(t *testing.T) []byte {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &layers.UDP{SrcPort: 1000, DstPort: 2000}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, gopacket.Payload{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDump(t *testing.T) {
+	src := packets{udpPacket(t), udpPacket(t), {0x45, 0}, udpPacket(t)}
+	var out, log bytes.Buffer
+	stats, err := Dump(&src, Options{
+		Decoder:    layers.LayerTypeIPv4,
+		Print:      true,
+		MaxCount:   3,
+		StatsEvery: 2,
+		Out:        &out,
+		Log:        &log,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Packets != 3 || stats.Errors != 1 || stats.LayerTypes[layers.LayerTypeUDP] != 2 {
+		t.Errorf("got stats %+v", stats)
+	}
+	if n := strings.Count(out.String(), "PACKET:"); n != 3 {
+		t.Errorf("printed %d packets, want 3", n)
+	}
+	if n := strings.Count(log.String(), "Processed"); n != 2 {
+		t.Errorf("printed statistics %d times, want 2", n)
+	}
+}
+
+func TestDumpDone(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	var src packets
+	if stats, err := Dump(&src, Options{}, done); err != nil || stats.Packets != 0 {
+		t.Errorf("got %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package editor rewrites header fields of decoded packets in their data,
+// without serializing them again.
+//
+// An Editor locates the layers of a packet in its data, and overwrites the
+// fields of their headers, updating the checksums covering them
+// incrementally:
+//
+//	e, err := editor.New(packet)
+//	if err != nil {
+//		return err
+//	}
+//	if err := e.SetSrcIP(publicIP); err != nil {
+//		return err
+//	}
+//	if err := e.SetSrcPort(port); err != nil {
+//		return err
+//	}
+//	handle.WritePacketData(e.Data())
+//
+// The decoded layers of the packet are not updated, the data has to be
+// decoded again to see the changes. Packets decoded with NoCopy share their
+// data with the packet source, which an Editor modifies.
+package editor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TCPFlag is a flag of the TCP header.
+type TCPFlag uint8
+
+// TCP flags, as found in the 14th byte of the header.
+const (
+	TCPFlagFIN TCPFlag = 1 << iota
+	TCPFlagSYN
+	TCPFlagRST
+	TCPFlagPSH
+	TCPFlagACK
+	TCPFlagURG
+	TCPFlagECE
+	TCPFlagCWR
+)
+
+// located is a layer located in the data of a packet.
+type located struct {
+	typ    gopacket.LayerType
+	offset int
+}
+
+// Editor edits the headers of a packet in its data.
+type Editor struct {
+	data []byte
+	// link, network and transport are the first layers of their kind,
+	// and transportNetwork the network layer carrying transport.
+	link, network, transport, transportNetwork located
+}
+
+// offset returns the offset of b in data, or -1 if it does not share its
+// memory.
+func offset(data, b []byte) int {
+	if len(b) == 0 || cap(b) > cap(data) {
+		return -1
+	}
+	o := cap(data) - cap(b)
+	if o+len(b) > len(data) || &data[o] != &b[0] {
+		return -1
+	}
+	return o
+}
+
+// New returns an Editor of the data of packet.
+func New(packet gopacket.Packet) (*Editor, error) {
+	e := &Editor{data: packet.Data()}
+	none := located{offset: -1}
+	e.link, e.network, e.transport, e.transportNetwork = none, none, none, none
+	last := none
+	for _, l := range packet.Layers() {
+		o := offset(e.data, l.LayerContents())
+		if o < 0 {
+			continue
+		}
+		here := located{l.LayerType(), o}
+		switch l.(type) {
+		case gopacket.LinkLayer:
+			if e.link.offset < 0 {
+				e.link = here
+			}
+		case gopacket.NetworkLayer:
+			if e.network.offset < 0 {
+				e.network = here
+			}
+			last = here
+		case gopacket.TransportLayer:
+			if e.transport.offset < 0 {
+				e.transport, e.transportNetwork = here, last
+			}
+		}
+	}
+	if e.link.offset < 0 && e.network.offset < 0 {
+		return nil, fmt.Errorf("no layer of the packet found in its data")
+	}
+	return e, nil
+}
+
+// Data returns the data of the packet, with the changes made.
+func (e *Editor) Data() []byte {
+	return e.data
+}
+
+func (e *Editor) ethernet() (int, error) {
+	if e.link.typ != layers.LayerTypeEthernet {
+		return 0, fmt.Errorf("no Ethernet layer")
+	}
+	return e.link.offset, nil
+}
+
+func (e *Editor) ip() (int, error) {
+	if e.network.typ != layers.LayerTypeIPv4 && e.network.typ != layers.LayerTypeIPv6 {
+		return 0, fmt.Errorf("no IP layer")
+	}
+	return e.network.offset, nil
+}
+
+// transportIP returns the offset of the IP layer carrying the transport
+// layer, which must be TCP or UDP.
+func (e *Editor) transportIP() (int, error) {
+	if e.transport.typ != layers.LayerTypeTCP && e.transport.typ != layers.LayerTypeUDP {
+		return 0, fmt.Errorf("no TCP or UDP layer")
+	}
+	if e.transportNetwork.typ != layers.LayerTypeIPv4 && e.transportNetwork.typ != layers.LayerTypeIPv6 {
+		return 0, fmt.Errorf("%v layer is not carried by IP", e.transport.typ)
+	}
+	return e.transportNetwork.offset, nil
+}
+
+func (e *Editor) setMAC(mac net.HardwareAddr, field int) error {
+	o, err := e.ethernet()
+	if err != nil {
+		return err
+	}
+	if len(mac) != 6 {
+		return fmt.Errorf("invalid Ethernet address %v", mac)
+	}
+	copy(e.data[o+field:], mac)
+	return nil
+}
+
+// SetSrcMAC sets the source address of the Ethernet layer.
+func (e *Editor) SetSrcMAC(mac net.HardwareAddr) error {
+	return e.setMAC(mac, 6)
+}
+
+// SetDstMAC sets the destination address of the Ethernet layer.
+func (e *Editor) SetDstMAC(mac net.HardwareAddr) error {
+	return e.setMAC(mac, 0)
+}
+
+// SetSrcIP sets the source address of the first IPv4 or IPv6 layer to ip,
+// of the same version, updating the IPv4 header checksum and the checksum
+// of the TCP, UDP or ICMPv6 layer it carries.
+func (e *Editor) SetSrcIP(ip net.IP) error {
+	o, err := e.ip()
+	if err != nil {
+		return err
+	}
+	return layers.RewriteIPSrc(e.data[o:], ip)
+}
+
+// SetDstIP sets the destination address of the first IPv4 or IPv6 layer,
+// as SetSrcIP does.
+func (e *Editor) SetDstIP(ip net.IP) error {
+	o, err := e.ip()
+	if err != nil {
+		return err
+	}
+	return layers.RewriteIPDst(e.data[o:], ip)
+}
+
+// DecrementTTL decrements the TTL or hop limit of the first IPv4 or IPv6
+// layer, failing if it is zero.
+func (e *Editor) DecrementTTL() error {
+	o, err := e.ip()
+	if err != nil {
+		return err
+	}
+	return layers.DecrementTTL(e.data[o:])
+}
+
+// SetSrcPort sets the source port of the first TCP or UDP layer, updating
+// its checksum.
+func (e *Editor) SetSrcPort(port uint16) error {
+	o, err := e.transportIP()
+	if err != nil {
+		return err
+	}
+	return layers.RewriteSrcPort(e.data[o:], port)
+}
+
+// SetDstPort sets the destination port of the first TCP or UDP layer,
+// updating its checksum.
+func (e *Editor) SetDstPort(port uint16) error {
+	o, err := e.transportIP()
+	if err != nil {
+		return err
+	}
+	return layers.RewriteDstPort(e.data[o:], port)
+}
+
+// SetTCPFlags sets the flags set, and clears the flags clear, of the first
+// TCP layer, updating its checksum.
+func (e *Editor) SetTCPFlags(set, clear TCPFlag) error {
+	if e.transport.typ != layers.LayerTypeTCP {
+		return fmt.Errorf("no TCP layer")
+	}
+	o := e.transport.offset
+	if len(e.data) < o+18 {
+		return fmt.Errorf("TCP header truncated")
+	}
+	old := binary.BigEndian.Uint16(e.data[o+12:])
+	e.data[o+13] = e.data[o+13]&^uint8(clear) | uint8(set)
+	csum := binary.BigEndian.Uint16(e.data[o+16:])
+	binary.BigEndian.PutUint16(e.data[o+16:], layers.UpdateChecksum(csum, old, binary.BigEndian.Uint16(e.data[o+12:])))
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package editor
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func serialize(t *testing.T, ls ...gopacket.SerializableLayer) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func checkChecksums(t *testing.T, p gopacket.Packet) {
+	for _, r := range p.VerifyChecksums(gopacket.ChecksumOptions{}) {
+		if r.Status != gopacket.ChecksumCorrect {
+			t.Errorf("%v checksum %v: %+v", r.LayerType, r.Status, r)
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{192, 0, 2, 1}}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 80, SYN: true, Seq: 1, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+	p := serialize(t, eth, ip, tcp, gopacket.Payload("hello"))
+
+	e, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		e.SetSrcMAC(net.HardwareAddr{2, 0, 0, 0, 0, 3}),
+		e.SetDstMAC(net.HardwareAddr{2, 0, 0, 0, 0, 4}),
+		e.SetSrcIP(net.IP{203, 0, 113, 1}),
+		e.SetDstIP(net.IP{192, 0, 2, 2}),
+		e.DecrementTTL(),
+		e.SetSrcPort(50000),
+		e.SetDstPort(8080),
+		e.SetTCPFlags(TCPFlagACK|TCPFlagECE, TCPFlagSYN),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := gopacket.NewPacket(e.Data(), layers.LayerTypeEthernet, gopacket.Default)
+	checkChecksums(t, q)
+	gotEth := q.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if gotEth.SrcMAC.String() != "02:00:00:00:00:03" || gotEth.DstMAC.String() != "02:00:00:00:00:04" {
+		t.Errorf("Ethernet edited as %+v", gotEth)
+	}
+	gotIP := q.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !gotIP.SrcIP.Equal(net.IP{203, 0, 113, 1}) || !gotIP.DstIP.Equal(net.IP{192, 0, 2, 2}) || gotIP.TTL != 63 {
+		t.Errorf("IPv4 edited as %+v", gotIP)
+	}
+	gotTCP := q.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if gotTCP.SrcPort != 50000 || gotTCP.DstPort != 8080 || gotTCP.SYN || !gotTCP.ACK || !gotTCP.ECE {
+		t.Errorf("TCP edited as %+v", gotTCP)
+	}
+	if string(q.ApplicationLayer().Payload()) != "hello" {
+		t.Errorf("payload %q", q.ApplicationLayer().Payload())
+	}
+}
+
+func TestEditorErrors(t *testing.T) {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv6}
+	ip := &layers.IPv6{Version: 6, HopLimit: 1, NextHeader: layers.IPProtocolUDP, SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")}
+	udp := &layers.UDP{SrcPort: 1000, DstPort: 53}
+	udp.SetNetworkLayerForChecksum(ip)
+	p := serialize(t, eth, ip, udp, gopacket.Payload("query"))
+	e, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetTCPFlags(TCPFlagRST, 0); err == nil {
+		t.Error("set the TCP flags of a UDP packet")
+	}
+	if err := e.SetSrcIP(net.IP{192, 0, 2, 1}); err == nil {
+		t.Error("set an IPv4 address in an IPv6 header")
+	}
+	if err := e.SetSrcMAC(net.HardwareAddr{1, 2, 3}); err == nil {
+		t.Error("set a short MAC address")
+	}
+	if err := e.SetDstPort(5353); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DecrementTTL(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DecrementTTL(); err == nil {
+		t.Error("decremented a zero hop limit")
+	}
+	q := gopacket.NewPacket(e.Data(), layers.LayerTypeEthernet, gopacket.Default)
+	checkChecksums(t, q)
+	if got := q.Layer(layers.LayerTypeUDP).(*layers.UDP); got.DstPort != 5353 {
+		t.Errorf("UDP edited as %+v", got)
+	}
+}
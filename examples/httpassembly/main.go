@@ -5,24 +5,21 @@
 // tree.
 
 // This binary provides sample code for using the gopacket TCP assembler and TCP
-// stream reader.  It reads packets off the wire and reconstructs HTTP requests
-// it sees, logging them.
+// stream reader, through package httpassembly.  It reads packets off the wire
+// and reconstructs HTTP requests it sees, logging them.
 package main
 
 import (
-	"bufio"
 	"flag"
-	"io"
 	"log"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/examples/util"
-	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/httpassembly"
 	"github.com/google/gopacket/pcap"
-	"github.com/google/gopacket/tcpassembly"
-	"github.com/google/gopacket/tcpassembly/tcpreader"
 )
 
 var iface = flag.String("i", "eth0", "Interface to get packets from")
@@ -31,46 +28,6 @@ var snaplen = flag.Int("s", 1600, "SnapLen for pcap packet capture")
 var filter = flag.String("f", "tcp and dst port 80", "BPF filter for pcap")
 var logAllPackets = flag.Bool("v", false, "Logs every packet in great detail")
 
-// Build a simple HTTP request parser using tcpassembly.StreamFactory and tcpassembly.Stream interfaces
-
-// httpStreamFactory implements tcpassembly.StreamFactory
-type httpStreamFactory struct{}
-
-// httpStream will handle the actual decoding of http requests.
-type httpStream struct {
-	net, transport gopacket.Flow
-	r              tcpreader.ReaderStream
-}
-
-func (h *httpStreamFactory) New(net, transport gopacket.Flow) tcpassembly.Stream {
-	hstream := &httpStream{
-		net:       net,
-		transport: transport,
-		r:         tcpreader.NewReaderStream(),
-	}
-	go hstream.run() // Important... we must guarantee that data from the reader stream is read.
-
-	// ReaderStream implements tcpassembly.Stream, so we can return a pointer to it.
-	return &hstream.r
-}
-
-func (h *httpStream) run() {
-	buf := bufio.NewReader(&h.r)
-	for {
-		req, err := http.ReadRequest(buf)
-		if err == io.EOF {
-			// We must read until we see an EOF... very important!
-			return
-		} else if err != nil {
-			log.Println("Error reading stream", h.net, h.transport, ":", err)
-		} else {
-			bodyBytes := tcpreader.DiscardBytesToEOF(req.Body)
-			req.Body.Close()
-			log.Println("Received request from stream", h.net, h.transport, ":", req, "with", bodyBytes, "bytes in request body")
-		}
-	}
-}
-
 func main() {
 	defer util.Run()()
 	var handle *pcap.Handle
@@ -92,36 +49,26 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Set up assembly
-	streamFactory := &httpStreamFactory{}
-	streamPool := tcpassembly.NewStreamPool(streamFactory)
-	assembler := tcpassembly.NewAssembler(streamPool)
-
-	log.Println("reading in packets")
-	// Read in packets, pass to assembler.
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-	packets := packetSource.Packets()
-	ticker := time.Tick(time.Minute)
-	for {
-		select {
-		case packet := <-packets:
-			// A nil packet indicates the end of a pcap file.
-			if packet == nil {
-				return
-			}
-			if *logAllPackets {
-				log.Println(packet)
-			}
-			if packet.NetworkLayer() == nil || packet.TransportLayer() == nil || packet.TransportLayer().LayerType() != layers.LayerTypeTCP {
-				log.Println("Unusable packet")
-				continue
-			}
-			tcp := packet.TransportLayer().(*layers.TCP)
-			assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcp, packet.Metadata().Timestamp)
-
-		case <-ticker:
-			// Every minute, flush connections that haven't seen activity in the past 2 minutes.
-			assembler.FlushOlderThan(time.Now().Add(time.Minute * -2))
-		}
+	// Stop on interrupt, after flushing the streams.
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		close(done)
+	}()
+
+	sniffer := &httpassembly.Sniffer{
+		OnRequest: func(net, transport gopacket.Flow, req *http.Request) {
+			log.Println("Received request from stream", net, transport, ":", req)
+		},
+		OnError: func(net, transport gopacket.Flow, err error) {
+			log.Println("Error reading stream", net, transport, ":", err)
+		},
 	}
+	if *logAllPackets {
+		sniffer.OnPacket = func(packet gopacket.Packet) { log.Println(packet) }
+	}
+	log.Println("reading in packets")
+	sniffer.Run(gopacket.NewPacketSource(handle, handle.LinkType()), done)
 }
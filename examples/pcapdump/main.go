@@ -11,13 +11,15 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/google/gopacket/dumpcommand"
-	"github.com/google/gopacket/examples/util"
-	"github.com/google/gopacket/pcap"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
+
+	"github.com/google/gopacket/dumpcommand"
+	"github.com/google/gopacket/examples/util"
+	"github.com/google/gopacket/pcap"
 )
 
 var iface = flag.String("i", "eth0", "Interface to read packets from")
@@ -69,5 +71,22 @@ func main() {
 			log.Fatal("BPF filter error:", err)
 		}
 	}
-	dumpcommand.Run(handle)
+	opts, err := dumpcommand.FlagOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stop on interrupt, printing the final statistics.
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		close(done)
+	}()
+
+	_, err = dumpcommand.Dump(handle, opts, done)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,327 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package fieldnames maps the fields of gopacket layers to the field names
+// of Wireshark display filters, such as DNS.QDCount to dns.count.queries,
+// so tools moving from tshark can keep their field names in exported
+// records and filter expressions.
+//
+// A field is named by its layer type and a path of Go field or method
+// names within the layer, separated by dots: "QDCount" for DNS.QDCount,
+// "Questions.Name" for the names of all questions, or "TypeCode.Type" for
+// the result of ICMPv4.TypeCode.Type(). Only fields with the same meaning
+// and unit in both are mapped; Wireshark's relative TCP sequence numbers
+// or the header length of IPv4 in bytes, for example, have no
+// counterpart.
+//
+//	name, _ := fieldnames.Wireshark(layers.LayerTypeDNS, "QDCount") // "dns.count.queries"
+//	for _, v := range fieldnames.Values(packet) {
+//		fmt.Println(v.Name, v.Value)
+//	}
+package fieldnames
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Field is a mapped layer field.
+type Field struct {
+	LayerType gopacket.LayerType
+	// Path is the path of Go field or method names within the layer.
+	Path string
+	// Name is the Wireshark display filter field name.
+	Name string
+}
+
+// GoName returns the Go name of the field, such as "DNS.QDCount".
+func (f Field) GoName() string {
+	return f.LayerType.String() + "." + f.Path
+}
+
+type layerField struct {
+	t    gopacket.LayerType
+	path string
+}
+
+var (
+	byName  = map[string]Field{}
+	byField = map[layerField]Field{}
+	byLayer = map[gopacket.LayerType][]Field{}
+)
+
+// Register adds a mapping between the field at path within layers of type
+// t and the Wireshark field name, replacing earlier mappings of either. It
+// is not safe to call concurrently with the other functions of this
+// package, so mappings should be registered during init.
+func Register(t gopacket.LayerType, path, name string) {
+	if old, ok := byName[name]; ok {
+		unregister(old)
+	}
+	if old, ok := byField[layerField{t, path}]; ok {
+		unregister(old)
+	}
+	f := Field{LayerType: t, Path: path, Name: name}
+	byName[name] = f
+	byField[layerField{t, path}] = f
+	byLayer[t] = append(byLayer[t], f)
+}
+
+func unregister(f Field) {
+	delete(byName, f.Name)
+	delete(byField, layerField{f.LayerType, f.Path})
+	fields := byLayer[f.LayerType]
+	for i, g := range fields {
+		if g == f {
+			byLayer[f.LayerType] = append(fields[:i:i], fields[i+1:]...)
+			break
+		}
+	}
+}
+
+// Wireshark returns the Wireshark field name of the field at path within
+// layers of type t.
+func Wireshark(t gopacket.LayerType, path string) (string, bool) {
+	f, ok := byField[layerField{t, path}]
+	return f.Name, ok
+}
+
+// Lookup returns the field with the given Wireshark name.
+func Lookup(name string) (Field, bool) {
+	f, ok := byName[name]
+	return f, ok
+}
+
+// Fields returns the mapped fields of layers of type t, sorted by
+// Wireshark name.
+func Fields(t gopacket.LayerType) []Field {
+	fields := append([]Field(nil), byLayer[t]...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+// Value is the value of a field in a packet.
+type Value struct {
+	// Name is the Wireshark field name.
+	Name string
+	// Value is the Go value, such as a uint16, a bool or a net.IP. Names
+	// of DNS questions and records are []byte.
+	Value interface{}
+}
+
+// Values returns the values of the mapped fields of all layers of packet,
+// in layer order and sorted by name within each layer. Fields within
+// slices, such as the names of DNS questions, have a value per element.
+func Values(packet gopacket.Packet) []Value {
+	var values []Value
+	for _, l := range packet.Layers() {
+		values = append(values, LayerValues(l)...)
+	}
+	return values
+}
+
+// LayerValues returns the values of the mapped fields of l, sorted by name.
+func LayerValues(l gopacket.Layer) []Value {
+	var values []Value
+	for _, f := range Fields(l.LayerType()) {
+		for _, x := range f.Values(l) {
+			values = append(values, Value{Name: f.Name, Value: x})
+		}
+	}
+	return values
+}
+
+// Values returns the values of the field within l, which has none unless
+// it is of the field's layer type.
+func (f Field) Values(l gopacket.Layer) []interface{} {
+	if l.LayerType() != f.LayerType {
+		return nil
+	}
+	var values []interface{}
+	walk(reflect.ValueOf(l), strings.Split(f.Path, "."), func(x reflect.Value) {
+		values = append(values, x.Interface())
+	})
+	return values
+}
+
+// Protocol returns the layer type whose fields are named after the
+// Wireshark protocol name, the part of their names before the first dot,
+// such as LayerTypeIPv4 for "ip".
+func Protocol(name string) (gopacket.LayerType, bool) {
+	for _, f := range byName {
+		if i := strings.IndexByte(f.Name, '.'); i > 0 && f.Name[:i] == name {
+			return f.LayerType, true
+		}
+	}
+	return 0, false
+}
+
+var byteType = reflect.TypeOf(byte(0))
+
+// walk calls fn with each value at path within v, descending into
+// pointers and into slices other than byte slices.
+func walk(v reflect.Value, path []string, fn func(reflect.Value)) {
+	if !v.IsValid() {
+		return
+	}
+	if len(path) == 0 {
+		if v.CanInterface() {
+			fn(v)
+		}
+		return
+	}
+	if m := method(v, path[0]); m.IsValid() {
+		walk(m.Call(nil)[0], path[1:], fn)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), path, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem() == byteType {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), path, fn)
+		}
+	case reflect.Struct:
+		walk(v.FieldByName(path[0]), path[1:], fn)
+	}
+}
+
+// method returns the method of v called name if it takes no arguments and
+// returns a single value.
+func method(v reflect.Value, name string) reflect.Value {
+	m := v.MethodByName(name)
+	if !m.IsValid() && v.CanAddr() {
+		m = v.Addr().MethodByName(name)
+	}
+	if m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+		return m
+	}
+	return reflect.Value{}
+}
+
+func init() {
+	for t, fields := range map[gopacket.LayerType][][2]string{
+		layers.LayerTypeEthernet: {
+			{"SrcMAC", "eth.src"},
+			{"DstMAC", "eth.dst"},
+			{"EthernetType", "eth.type"},
+		},
+		layers.LayerTypeDot1Q: {
+			{"Priority", "vlan.priority"},
+			{"DropEligible", "vlan.dei"},
+			{"VLANIdentifier", "vlan.id"},
+			{"Type", "vlan.etype"},
+		},
+		layers.LayerTypeARP: {
+			{"Operation", "arp.opcode"},
+			{"SourceHwAddress", "arp.src.hw_mac"},
+			{"SourceProtAddress", "arp.src.proto_ipv4"},
+			{"DstHwAddress", "arp.dst.hw_mac"},
+			{"DstProtAddress", "arp.dst.proto_ipv4"},
+		},
+		layers.LayerTypeMPLS: {
+			{"Label", "mpls.label"},
+			{"TrafficClass", "mpls.exp"},
+			{"StackBottom", "mpls.bottom"},
+			{"TTL", "mpls.ttl"},
+		},
+		layers.LayerTypeIPv4: {
+			{"Version", "ip.version"},
+			{"TOS", "ip.dsfield"},
+			{"Length", "ip.len"},
+			{"Id", "ip.id"},
+			{"Flags", "ip.flags"},
+			{"TTL", "ip.ttl"},
+			{"Protocol", "ip.proto"},
+			{"Checksum", "ip.checksum"},
+			{"SrcIP", "ip.src"},
+			{"DstIP", "ip.dst"},
+		},
+		layers.LayerTypeIPv6: {
+			{"Version", "ipv6.version"},
+			{"TrafficClass", "ipv6.tclass"},
+			{"FlowLabel", "ipv6.flow"},
+			{"Length", "ipv6.plen"},
+			{"NextHeader", "ipv6.nxt"},
+			{"HopLimit", "ipv6.hlim"},
+			{"SrcIP", "ipv6.src"},
+			{"DstIP", "ipv6.dst"},
+		},
+		layers.LayerTypeICMPv4: {
+			{"TypeCode.Type", "icmp.type"},
+			{"TypeCode.Code", "icmp.code"},
+			{"Checksum", "icmp.checksum"},
+			{"Id", "icmp.ident"},
+			{"Seq", "icmp.seq"},
+		},
+		layers.LayerTypeICMPv6: {
+			{"TypeCode.Type", "icmpv6.type"},
+			{"TypeCode.Code", "icmpv6.code"},
+			{"Checksum", "icmpv6.checksum"},
+		},
+		layers.LayerTypeTCP: {
+			{"SrcPort", "tcp.srcport"},
+			{"DstPort", "tcp.dstport"},
+			{"Seq", "tcp.seq_raw"},
+			{"Ack", "tcp.ack_raw"},
+			{"FIN", "tcp.flags.fin"},
+			{"SYN", "tcp.flags.syn"},
+			{"RST", "tcp.flags.reset"},
+			{"PSH", "tcp.flags.push"},
+			{"ACK", "tcp.flags.ack"},
+			{"URG", "tcp.flags.urg"},
+			{"ECE", "tcp.flags.ece"},
+			{"CWR", "tcp.flags.cwr"},
+			{"NS", "tcp.flags.ns"},
+			{"Window", "tcp.window_size_value"},
+			{"Checksum", "tcp.checksum"},
+			{"Urgent", "tcp.urgent_pointer"},
+		},
+		layers.LayerTypeUDP: {
+			{"SrcPort", "udp.srcport"},
+			{"DstPort", "udp.dstport"},
+			{"Length", "udp.length"},
+			{"Checksum", "udp.checksum"},
+		},
+		layers.LayerTypeVXLAN: {
+			{"VNI", "vxlan.vni"},
+		},
+		layers.LayerTypeDNS: {
+			{"ID", "dns.id"},
+			{"QR", "dns.flags.response"},
+			{"OpCode", "dns.flags.opcode"},
+			{"AA", "dns.flags.authoritative"},
+			{"TC", "dns.flags.truncated"},
+			{"RD", "dns.flags.recdesired"},
+			{"RA", "dns.flags.recavail"},
+			{"ResponseCode", "dns.flags.rcode"},
+			{"QDCount", "dns.count.queries"},
+			{"ANCount", "dns.count.answers"},
+			{"NSCount", "dns.count.auth_rr"},
+			{"ARCount", "dns.count.add_rr"},
+			{"Questions.Name", "dns.qry.name"},
+			{"Questions.Type", "dns.qry.type"},
+			{"Questions.Class", "dns.qry.class"},
+			{"Answers.Name", "dns.resp.name"},
+			{"Answers.Type", "dns.resp.type"},
+			{"Answers.TTL", "dns.resp.ttl"},
+			{"Answers.CNAME", "dns.cname"},
+		},
+	} {
+		for _, f := range fields {
+			Register(t, f[0], f[1])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package fieldnames
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// resolve reports whether path names a field or method within values of
+// type t.
+func resolve(t reflect.Type, path []string) bool {
+	if len(path) == 0 {
+		return true
+	}
+	if m, ok := t.MethodByName(path[0]); ok && m.Type.NumIn() == 1 && m.Type.NumOut() == 1 {
+		return resolve(m.Type.Out(0), path[1:])
+	}
+	if m, ok := reflect.PtrTo(t).MethodByName(path[0]); ok && m.Type.NumIn() == 1 && m.Type.NumOut() == 1 {
+		return resolve(m.Type.Out(0), path[1:])
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return resolve(t.Elem(), path)
+	case reflect.Struct:
+		f, ok := t.FieldByName(path[0])
+		return ok && f.PkgPath == "" && resolve(f.Type, path[1:])
+	}
+	return false
+}
+
+func TestBuiltinPaths(t *testing.T) {
+	for _, l := range []gopacket.Layer{
+		&layers.Ethernet{}, &layers.Dot1Q{}, &layers.ARP{}, &layers.MPLS{},
+		&layers.IPv4{}, &layers.IPv6{}, &layers.ICMPv4{}, &layers.ICMPv6{},
+		&layers.TCP{}, &layers.UDP{}, &layers.VXLAN{}, &layers.DNS{},
+	} {
+		fields := Fields(l.LayerType())
+		if len(fields) == 0 {
+			t.Errorf("no fields for %v", l.LayerType())
+		}
+		for _, f := range fields {
+			if !resolve(reflect.TypeOf(l), strings.Split(f.Path, ".")) {
+				t.Errorf("%s (%s) does not resolve", f.GoName(), f.Name)
+			}
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if name, ok := Wireshark(layers.LayerTypeDNS, "QDCount"); !ok || name != "dns.count.queries" {
+		t.Errorf("got %q, %v", name, ok)
+	}
+	f, ok := Lookup("dns.count.queries")
+	if !ok || f.GoName() != "DNS.QDCount" {
+		t.Errorf("got %+v, %v", f, ok)
+	}
+	if _, ok := Lookup("dns.nonexistent"); ok {
+		t.Error("found unmapped name")
+	}
+
+	Register(layers.LayerTypeUDP, "Length", "udp.len")
+	defer Register(layers.LayerTypeUDP, "Length", "udp.length")
+	if _, ok := Lookup("udp.length"); ok {
+		t.Error("replaced name still mapped")
+	}
+	if name, _ := Wireshark(layers.LayerTypeUDP, "Length"); name != "udp.len" {
+		t.Errorf("got %q after replacing", name)
+	}
+	if lt, ok := Protocol("ip"); !ok || lt != layers.LayerTypeIPv4 {
+		t.Errorf("got protocol %v, %v for ip", lt, ok)
+	}
+	if _, ok := Protocol("dns.count"); ok {
+		t.Error("found protocol for a field name prefix")
+	}
+}
+
+func TestValues(t *testing.T) {
+	dns := &layers.DNS{
+		ID: 0x1234, QR: true, RD: true, RA: true,
+		Questions: []layers.DNSQuestion{{Name: []byte("www.example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte("www.example.com"), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, TTL: 60, CNAME: []byte("example.com")},
+			{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 30, IP: net.IP{192, 0, 2, 1}},
+		},
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{192, 0, 2, 53}, DstIP: net.IP{192, 0, 2, 2}}
+	udp := &layers.UDP{SrcPort: 53, DstPort: 33333}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, udp, dns); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+
+	got := map[string][]string{}
+	for _, v := range Values(p) {
+		got[v.Name] = append(got[v.Name], fmtValue(v.Value))
+	}
+	for name, want := range map[string][]string{
+		"ip.src":             {"192.0.2.53"},
+		"ip.ttl":             {"64"},
+		"udp.srcport":        {"53(domain)"},
+		"dns.id":             {"4660"},
+		"dns.flags.response": {"true"},
+		"dns.count.answers":  {"2"},
+		"dns.qry.name":       {"www.example.com"},
+		"dns.resp.ttl":       {"60", "30"},
+		"dns.cname":          {"example.com", ""},
+	} {
+		if !reflect.DeepEqual(got[name], want) {
+			t.Errorf("%s: got %q, want %q", name, got[name], want)
+		}
+	}
+}
+
+func fmtValue(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,319 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package flowfilter pushes the selection of interesting flows down into
+// the kernel, by turning a set of tracked flows into a BPF filter installed
+// on a live capture handle.
+//
+// A typical use is selective full-packet capture: an analyzer inspects a
+// cheap subset of the traffic (matched by the base expression, for example
+// TCP SYNs or DNS) and flags flows worth capturing completely. Flagged
+// flows are added to a Tracker and a Pushdown periodically recompiles the
+// handle's filter, so that only their packets reach user space:
+//
+//  tracker := flowfilter.NewTracker()
+//  tracker.TTL = time.Minute
+//  push := &flowfilter.Pushdown{
+//  	Handle:  handle, // a *pcap.Handle or *pfring.Ring
+//  	Tracker: tracker,
+//  	Base:    "tcp[tcpflags] & tcp-syn != 0",
+//  }
+//  go push.Run(time.Second, done)
+//  for packet := range source.Packets() {
+//  	if interesting(packet) {
+//  		net, transport := packet.NetworkLayer(), packet.TransportLayer()
+//  		tracker.Add(net.NetworkFlow(), transport.TransportFlow())
+//  	}
+//  }
+//
+// The generated expressions match untagged traffic only; use Base or wrap
+// the handle's SetBPFFilter to add "vlan" qualifiers where needed.
+package flowfilter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// MatchNothing is the expression installed when there is neither a base
+// expression nor a tracked flow.
+const MatchNothing = "len = 0"
+
+// Key identifies a tracked flow. Transport may be the zero Flow to track all
+// traffic between the two network endpoints.
+type Key struct {
+	Network, Transport gopacket.Flow
+}
+
+// NewKey returns the key for the given flows. Both directions of a
+// connection map to the same key.
+func NewKey(network, transport gopacket.Flow) Key {
+	nsrc, ndst := network.Endpoints()
+	tsrc, tdst := transport.Endpoints()
+	if ndst.LessThan(nsrc) || (nsrc == ndst && tdst.LessThan(tsrc)) {
+		return Key{network.Reverse(), transport.Reverse()}
+	}
+	return Key{network, transport}
+}
+
+// Expression returns the BPF expression matching both directions of k.
+func (k Key) Expression() (string, error) {
+	fwd, err := k.direction(k.Network, k.Transport)
+	if err != nil {
+		return "", err
+	}
+	rev, err := k.direction(k.Network.Reverse(), k.Transport.Reverse())
+	if err != nil {
+		return "", err
+	}
+	if fwd == rev {
+		return "(" + fwd + ")", nil
+	}
+	return "(" + fwd + ") or (" + rev + ")", nil
+}
+
+func (k Key) direction(network, transport gopacket.Flow) (string, error) {
+	src, dst := network.Endpoints()
+	s, err := endpointExpression("src", src)
+	if err != nil {
+		return "", err
+	}
+	d, err := endpointExpression("dst", dst)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{s, d}
+	if transport != (gopacket.Flow{}) {
+		src, dst = transport.Endpoints()
+		if s, err = endpointExpression("src", src); err != nil {
+			return "", err
+		}
+		if d, err = endpointExpression("dst", dst); err != nil {
+			return "", err
+		}
+		parts = append(parts, s, d)
+	}
+	return strings.Join(parts, " and "), nil
+}
+
+// endpointExpression returns the BPF primitive matching e in direction dir,
+// which is "src" or "dst".
+func endpointExpression(dir string, e gopacket.Endpoint) (string, error) {
+	raw := e.Raw()
+	switch e.EndpointType() {
+	case layers.EndpointTCPPort, layers.EndpointUDPPort, layers.EndpointSCTPPort:
+		if len(raw) != 2 {
+			return "", fmt.Errorf("flowfilter: invalid %v endpoint %v", e.EndpointType(), e)
+		}
+	}
+	switch e.EndpointType() {
+	case layers.EndpointIPv4, layers.EndpointIPv6:
+		return dir + " host " + net.IP(raw).String(), nil
+	case layers.EndpointMAC:
+		return "ether " + dir + " " + net.HardwareAddr(raw).String(), nil
+	case layers.EndpointTCPPort:
+		return fmt.Sprintf("tcp %s port %d", dir, binary.BigEndian.Uint16(raw)), nil
+	case layers.EndpointUDPPort:
+		return fmt.Sprintf("udp %s port %d", dir, binary.BigEndian.Uint16(raw)), nil
+	case layers.EndpointSCTPPort:
+		return fmt.Sprintf("sctp %s port %d", dir, binary.BigEndian.Uint16(raw)), nil
+	}
+	return "", fmt.Errorf("flowfilter: cannot filter on %v endpoints", e.EndpointType())
+}
+
+// Tracker is the set of flows to capture. It is safe for concurrent use.
+type Tracker struct {
+	// TTL is how long a flow stays tracked after it was last added. Zero
+	// keeps flows until they are removed.
+	TTL time.Duration
+	// MaxFlows bounds the number of flows put into the expression, since
+	// filter programs are limited in size (4096 instructions on Linux).
+	// Once exceeded, the most recently added flows are kept. Zero means
+	// no limit.
+	MaxFlows int
+
+	mu    sync.Mutex
+	flows map[Key]time.Time
+}
+
+// NewTracker returns a Tracker keeping at most 128 flows.
+func NewTracker() *Tracker {
+	return &Tracker{MaxFlows: 128, flows: make(map[Key]time.Time)}
+}
+
+// Add starts tracking the flow, or refreshes it if it is already tracked.
+// It fails for flows whose endpoints cannot be expressed in a filter.
+func (t *Tracker) Add(network, transport gopacket.Flow) error {
+	k := NewKey(network, transport)
+	if _, err := k.Expression(); err != nil {
+		return err
+	}
+	t.addAt(k, time.Now())
+	return nil
+}
+
+func (t *Tracker) addAt(k Key, now time.Time) {
+	t.mu.Lock()
+	if t.flows == nil {
+		t.flows = make(map[Key]time.Time)
+	}
+	t.flows[k] = now
+	t.mu.Unlock()
+}
+
+// Remove stops tracking the flow.
+func (t *Tracker) Remove(network, transport gopacket.Flow) {
+	t.mu.Lock()
+	delete(t.flows, NewKey(network, transport))
+	t.mu.Unlock()
+}
+
+// Expire removes the flows last added more than TTL before now and returns
+// how many were removed.
+func (t *Tracker) Expire(now time.Time) int {
+	if t.TTL <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for k, added := range t.flows {
+		if now.Sub(added) > t.TTL {
+			delete(t.flows, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of tracked flows.
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.flows)
+}
+
+// Flows returns the flows to put into the filter: all tracked flows, or the
+// MaxFlows most recently added ones.
+func (t *Tracker) Flows() []Key {
+	t.mu.Lock()
+	type entry struct {
+		k     Key
+		added time.Time
+	}
+	entries := make([]entry, 0, len(t.flows))
+	for k, added := range t.flows {
+		entries = append(entries, entry{k, added})
+	}
+	t.mu.Unlock()
+
+	if t.MaxFlows > 0 && len(entries) > t.MaxFlows {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].added.After(entries[j].added) })
+		entries = entries[:t.MaxFlows]
+	}
+	keys := make([]Key, len(entries))
+	for i, e := range entries {
+		keys[i] = e.k
+	}
+	return keys
+}
+
+// Expression returns the filter expression matching base or any of the
+// flows. The flows are sorted, so the same set always gives the same
+// expression.
+func Expression(base string, flows []Key) (string, error) {
+	var parts []string
+	if base != "" {
+		parts = append(parts, "("+base+")")
+	}
+	exprs := make([]string, 0, len(flows))
+	for _, k := range flows {
+		e, err := k.Expression()
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, e)
+	}
+	sort.Strings(exprs)
+	parts = append(parts, exprs...)
+	if len(parts) == 0 {
+		return MatchNothing, nil
+	}
+	return strings.Join(parts, " or "), nil
+}
+
+// FilterSetter is implemented by capture handles accepting a BPF filter
+// expression, such as *pcap.Handle and *pfring.Ring.
+type FilterSetter interface {
+	SetBPFFilter(expr string) error
+}
+
+// Pushdown keeps the filter of a capture handle in sync with a Tracker.
+type Pushdown struct {
+	Handle  FilterSetter
+	Tracker *Tracker
+	// Base is an expression matching traffic to capture in addition to the
+	// tracked flows. It may be empty.
+	Base string
+
+	mu        sync.Mutex
+	installed string
+}
+
+// Refresh expires old flows and installs the resulting filter, unless it is
+// the one already installed. It reports whether a new filter was installed.
+func (p *Pushdown) Refresh() (bool, error) {
+	p.Tracker.Expire(time.Now())
+	expr, err := Expression(p.Base, p.Tracker.Flows())
+	if err != nil {
+		return false, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if expr == p.installed {
+		return false, nil
+	}
+	if err := p.Handle.SetBPFFilter(expr); err != nil {
+		return false, err
+	}
+	p.installed = expr
+	return true, nil
+}
+
+// Installed returns the expression last installed by Refresh.
+func (p *Pushdown) Installed() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.installed
+}
+
+// Run calls Refresh every interval until done is closed or Refresh fails.
+// It refreshes once immediately.
+func (p *Pushdown) Run(interval time.Duration, done <-chan struct{}) error {
+	if _, err := p.Refresh(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+			if _, err := p.Refresh(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowfilter
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func testFlows(src, dst string, sport, dport uint16) (gopacket.Flow, gopacket.Flow) {
+	ip := &layers.IPv4{SrcIP: net.ParseIP(src).To4(), DstIP: net.ParseIP(dst).To4()}
+	s, d := layers.NewTCPPortEndpoint(layers.TCPPort(sport)), layers.NewTCPPortEndpoint(layers.TCPPort(dport))
+	return ip.NetworkFlow(), gopacket.NewFlow(layers.EndpointTCPPort, s.Raw(), d.Raw())
+}
+
+func TestKeyExpression(t *testing.T) {
+	n, tr := testFlows("10.0.0.2", "10.0.0.1", 80, 40000)
+	k := NewKey(n, tr)
+	if k != NewKey(n.Reverse(), tr.Reverse()) {
+		t.Error("directions of a flow map to different keys")
+	}
+	got, err := k.Expression()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "(src host 10.0.0.1 and dst host 10.0.0.2 and tcp src port 40000 and tcp dst port 80) or " +
+		"(src host 10.0.0.2 and dst host 10.0.0.1 and tcp src port 80 and tcp dst port 40000)"
+	if got != want {
+		t.Errorf("got expression\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = NewKey(n, gopacket.Flow{}).Expression()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(src host 10.0.0.1 and dst host 10.0.0.2) or (src host 10.0.0.2 and dst host 10.0.0.1)"; got != want {
+		t.Errorf("got host pair expression %q", got)
+	}
+
+	ppp := gopacket.NewFlow(layers.EndpointPPP, nil, nil)
+	if err := NewTracker().Add(ppp, gopacket.Flow{}); err == nil {
+		t.Error("added a PPP flow")
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	tr.TTL = time.Minute
+	tr.MaxFlows = 2
+	now := time.Now()
+	for i, port := range []uint16{1, 2, 3} {
+		n, tp := testFlows("10.0.0.1", "10.0.0.2", port, 80)
+		tr.addAt(NewKey(n, tp), now.Add(time.Duration(i)*time.Minute))
+	}
+	flows := tr.Flows()
+	if len(flows) != 2 {
+		t.Fatalf("got %d flows, want 2", len(flows))
+	}
+	for _, k := range flows {
+		if _, p := k.Transport.Endpoints(); p == layers.NewTCPPortEndpoint(1) {
+			t.Error("oldest flow was kept")
+		}
+	}
+	if n := tr.Expire(now.Add(150 * time.Second)); n != 2 || tr.Len() != 1 {
+		t.Errorf("expired %d flows, %d left", n, tr.Len())
+	}
+}
+
+type fakeHandle struct {
+	exprs []string
+	err   error
+}
+
+func (h *fakeHandle) SetBPFFilter(expr string) error {
+	if h.err != nil {
+		return h.err
+	}
+	h.exprs = append(h.exprs, expr)
+	return nil
+}
+
+func TestPushdown(t *testing.T) {
+	h := &fakeHandle{}
+	p := &Pushdown{Handle: h, Tracker: NewTracker()}
+	if ok, err := p.Refresh(); !ok || err != nil || p.Installed() != MatchNothing {
+		t.Fatalf("got %v, %v, installed %q", ok, err, p.Installed())
+	}
+
+	p.Base = "udp port 53"
+	n, tr := testFlows("10.0.0.1", "10.0.0.2", 1234, 80)
+	if err := p.Tracker.Add(n, tr); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := p.Refresh(); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if ok, _ := p.Refresh(); ok {
+		t.Error("unchanged filter was installed again")
+	}
+	want := "(udp port 53) or (src host 10.0.0.1 and dst host 10.0.0.2 and tcp src port 1234 and tcp dst port 80) or " +
+		"(src host 10.0.0.2 and dst host 10.0.0.1 and tcp src port 80 and tcp dst port 1234)"
+	if len(h.exprs) != 2 || h.exprs[1] != want {
+		t.Errorf("got filters %q", h.exprs)
+	}
+
+	h.err = errors.New("bad filter")
+	p.Tracker.Remove(n, tr)
+	done := make(chan struct{})
+	if err := p.Run(time.Millisecond, done); err != h.err {
+		t.Errorf("got error %v from Run", err)
+	}
+	if p.Installed() != h.exprs[1] {
+		t.Errorf("failed filter recorded as installed")
+	}
+}
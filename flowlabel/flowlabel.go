@@ -0,0 +1,187 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package flowlabel detects IPv6 flow labels being changed on the path,
+// which breaks load balancing over equal cost paths (RFC 6438) and is a
+// common cause of reordering and of ECMP troubleshooting sessions.
+//
+// RFC 6437 asks sources to keep the flow label of a flow constant and
+// forbids forwarding nodes to change it. A Monitor reports two kinds of
+// violations:
+//
+//  - ChangedInFlow: the label of a flow changed at one vantage point. This
+//    is either a middlebox rewriting labels inconsistently or the source
+//    choosing a new label; Linux for example does so after retransmission
+//    timeouts to move a connection to another path.
+//  - DiffersBetweenVantages: the same flow carries different labels at two
+//    vantage points, for example captures taken before and after a
+//    firewall. Something between them rewrites labels.
+//
+// Feed it packets from one or more capture points:
+//
+//  m := flowlabel.NewMonitor()
+//  m.OnChange = func(c flowlabel.Change) { log.Print(c) }
+//  for packet := range inside.Packets() {
+//  	m.Observe("inside", packet)
+//  }
+package flowlabel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ChangeKind tells how a flow label changed.
+type ChangeKind uint8
+
+const (
+	// ChangedInFlow means the label changed between packets of a flow seen
+	// at the same vantage point.
+	ChangedInFlow ChangeKind = iota
+	// DiffersBetweenVantages means the flow was seen with different labels
+	// at two vantage points.
+	DiffersBetweenVantages
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangedInFlow:
+		return "ChangedInFlow"
+	case DiffersBetweenVantages:
+		return "DiffersBetweenVantages"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", uint8(k))
+}
+
+// Change describes a flow label change.
+type Change struct {
+	Kind ChangeKind
+	// Network and Transport identify the flow, in the direction of the
+	// packet.
+	Network, Transport gopacket.Flow
+	// Old is the label previously seen at OldVantage, New the label just
+	// seen at Vantage. For ChangedInFlow both vantages are the same.
+	Old, New            uint32
+	OldVantage, Vantage string
+	Timestamp           time.Time
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%v %v %v: flow label %#05x (%s) -> %#05x (%s)", c.Timestamp.Format(time.RFC3339Nano),
+		c.Kind, c.Network, c.Old, c.OldVantage, c.New, c.Vantage)
+}
+
+type flowKey struct {
+	network, transport gopacket.Flow
+}
+
+type flowState struct {
+	// labels holds the last label seen at each vantage point.
+	labels   map[string]uint32
+	lastSeen time.Time
+}
+
+// Monitor tracks the flow labels of IPv6 flows. Each direction of a
+// connection is tracked separately. A Monitor is not safe for concurrent
+// use.
+type Monitor struct {
+	// OnChange, if not nil, is called for each change detected.
+	OnChange func(Change)
+	// IgnoreZero ignores packets with a zero flow label, as sent by
+	// sources not labeling their flows at all, which would otherwise show
+	// up as changes when a middlebox sets labels.
+	IgnoreZero bool
+
+	flows   map[flowKey]*flowState
+	changes map[ChangeKind]int
+}
+
+// NewMonitor returns an empty Monitor.
+func NewMonitor() *Monitor {
+	return &Monitor{flows: make(map[flowKey]*flowState), changes: make(map[ChangeKind]int)}
+}
+
+// Observe records the flow label of packet as seen at vantage. Packets
+// without an IPv6 layer are ignored; for tunneled traffic the outermost
+// IPv6 layer is used.
+func (m *Monitor) Observe(vantage string, packet gopacket.Packet) {
+	ip6, ok := packet.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	if !ok {
+		return
+	}
+	var transport gopacket.Flow
+	if t := packet.TransportLayer(); t != nil {
+		transport = t.TransportFlow()
+	}
+	m.ObserveFlow(vantage, ip6.NetworkFlow(), transport, ip6.FlowLabel, packet.Metadata().Timestamp)
+}
+
+// ObserveFlow records label as seen for the given flow at vantage.
+func (m *Monitor) ObserveFlow(vantage string, network, transport gopacket.Flow, label uint32, ts time.Time) {
+	if label == 0 && m.IgnoreZero {
+		return
+	}
+	if m.flows == nil {
+		m.flows = make(map[flowKey]*flowState)
+		m.changes = make(map[ChangeKind]int)
+	}
+	k := flowKey{network, transport}
+	st := m.flows[k]
+	if st == nil {
+		st = &flowState{labels: map[string]uint32{vantage: label}, lastSeen: ts}
+		m.flows[k] = st
+		return
+	}
+	if ts.After(st.lastSeen) {
+		st.lastSeen = ts
+	}
+	if old, ok := st.labels[vantage]; ok && old != label {
+		m.report(Change{Kind: ChangedInFlow, Network: network, Transport: transport,
+			Old: old, New: label, OldVantage: vantage, Vantage: vantage, Timestamp: ts})
+	} else if !ok {
+		for v, old := range st.labels {
+			if old != label {
+				m.report(Change{Kind: DiffersBetweenVantages, Network: network, Transport: transport,
+					Old: old, New: label, OldVantage: v, Vantage: vantage, Timestamp: ts})
+				break
+			}
+		}
+	}
+	st.labels[vantage] = label
+}
+
+func (m *Monitor) report(c Change) {
+	m.changes[c.Kind]++
+	if m.OnChange != nil {
+		m.OnChange(c)
+	}
+}
+
+// Changes returns the number of changes of the given kind detected so far.
+func (m *Monitor) Changes(kind ChangeKind) int {
+	return m.changes[kind]
+}
+
+// Flows returns the number of flows tracked.
+func (m *Monitor) Flows() int {
+	return len(m.flows)
+}
+
+// Expire forgets the flows not seen since before and returns how many
+// there were.
+func (m *Monitor) Expire(before time.Time) int {
+	n := 0
+	for k, st := range m.flows {
+		if st.lastSeen.Before(before) {
+			delete(m.flows, k)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowlabel
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func testPacket(t *testing.T, label uint32, ts time.Time) gopacket.Packet {
+	ip := &layers.IPv6{
+		Version:    6,
+		FlowLabel:  label,
+		NextHeader: layers.IPProtocolUDP,
+		HopLimit:   64,
+		SrcIP:      net.ParseIP("2001:db8::1"),
+		DstIP:      net.ParseIP("2001:db8::2"),
+	}
+	udp := &layers.UDP{SrcPort: 1000, DstPort: 53}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, gopacket.Payload("x")); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv6, gopacket.Default)
+	p.Metadata().Timestamp = ts
+	return p
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor()
+	var changes []Change
+	m.OnChange = func(c Change) { changes = append(changes, c) }
+	now := time.Unix(1000, 0)
+
+	m.Observe("inside", testPacket(t, 0x12345, now))
+	m.Observe("inside", testPacket(t, 0x12345, now.Add(time.Second)))
+	m.Observe("outside", testPacket(t, 0xabcde, now.Add(2*time.Second)))
+	m.Observe("inside", testPacket(t, 0x54321, now.Add(3*time.Second)))
+
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes: %v", len(changes), changes)
+	}
+	if c := changes[0]; c.Kind != DiffersBetweenVantages || c.Old != 0x12345 || c.New != 0xabcde || c.OldVantage != "inside" || c.Vantage != "outside" {
+		t.Errorf("got change %+v", c)
+	}
+	if c := changes[1]; c.Kind != ChangedInFlow || c.Old != 0x12345 || c.New != 0x54321 || c.Vantage != "inside" {
+		t.Errorf("got change %+v", c)
+	}
+	if m.Changes(ChangedInFlow) != 1 || m.Changes(DiffersBetweenVantages) != 1 {
+		t.Errorf("got counts %d, %d", m.Changes(ChangedInFlow), m.Changes(DiffersBetweenVantages))
+	}
+	if n := m.Expire(now.Add(time.Minute)); n != 1 || m.Flows() != 0 {
+		t.Errorf("expired %d flows, %d left", n, m.Flows())
+	}
+}
+
+func TestMonitorIgnoreZero(t *testing.T) {
+	m := &Monitor{IgnoreZero: true}
+	now := time.Unix(1000, 0)
+	m.Observe("a", testPacket(t, 0, now))
+	m.Observe("a", testPacket(t, 7, now))
+	m.Observe("a", testPacket(t, 0, now))
+	if m.Changes(ChangedInFlow) != 0 || m.Flows() != 1 {
+		t.Errorf("got %d changes, %d flows", m.Changes(ChangedInFlow), m.Flows())
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package flowtable provides a concurrent table of per-flow state, for
+// probes tracking millions of flows from many decoding goroutines.
+//
+// The table is split into shards selected by Flow.FastHash, so writers of
+// different flows rarely contend. Each shard publishes an immutable map of
+// its established entries, which readers load atomically and search without
+// taking any lock. New entries first go into a small per-shard map guarded
+// by a mutex and are merged into a new published map once enough of them
+// have accumulated, which keeps the cost of copying amortized.
+//
+//  table := flowtable.New(0)
+//  for packet := range source.Packets() {
+//  	key, ok := flowtable.PacketKey(packet, false)
+//  	if !ok {
+//  		continue
+//  	}
+//  	e, _ := table.GetOrCreate(key, now, func() interface{} { return &myStats{} })
+//  	e.Touch(now)
+//  	e.Value.(*myStats).update(packet) // must be safe for concurrent use
+//  }
+//
+// and, periodically:
+//
+//  table.Expire(time.Now().Add(-timeout), exportRecord)
+package flowtable
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Key identifies a flow by its network and transport flows. Transport may
+// be the zero Flow for traffic without a transport layer.
+type Key struct {
+	Network, Transport gopacket.Flow
+	// FlowLabel is the IPv6 flow label, if it is part of the key. See
+	// NewKeyWithFlowLabel.
+	FlowLabel uint32
+}
+
+// NewKey returns a key which is the same for both directions of a
+// connection.
+func NewKey(network, transport gopacket.Flow) Key {
+	nsrc, ndst := network.Endpoints()
+	tsrc, tdst := transport.Endpoints()
+	if ndst.LessThan(nsrc) || (nsrc == ndst && tdst.LessThan(tsrc)) {
+		return Key{Network: network.Reverse(), Transport: transport.Reverse()}
+	}
+	return Key{Network: network, Transport: transport}
+}
+
+// NewKeyWithFlowLabel returns a key which also includes the IPv6 flow
+// label, as used by routers balancing traffic over equal cost paths (RFC
+// 6438). Flows differing only in their label get different entries, so
+// each direction of a connection is usually its own entry, since hosts
+// pick flow labels independently.
+func NewKeyWithFlowLabel(network, transport gopacket.Flow, flowLabel uint32) Key {
+	k := NewKey(network, transport)
+	k.FlowLabel = flowLabel & 0xfffff
+	return k
+}
+
+// PacketKey returns the key of packet, or false if it has no network layer.
+// If withFlowLabel is set, the flow label of IPv6 packets is part of the
+// key.
+func PacketKey(packet gopacket.Packet, withFlowLabel bool) (Key, bool) {
+	net := packet.NetworkLayer()
+	if net == nil {
+		return Key{}, false
+	}
+	var transport gopacket.Flow
+	if t := packet.TransportLayer(); t != nil {
+		transport = t.TransportFlow()
+	}
+	if ip6, ok := net.(*layers.IPv6); ok && withFlowLabel {
+		return NewKeyWithFlowLabel(net.NetworkFlow(), transport, ip6.FlowLabel), true
+	}
+	return NewKey(net.NetworkFlow(), transport), true
+}
+
+// FastHash returns a hash of k, which like Flow.FastHash is the same for
+// both directions of a connection.
+func (k Key) FastHash() uint64 {
+	h := k.Network.FastHash()
+	h = h*31 + k.Transport.FastHash()
+	h ^= uint64(k.FlowLabel) * 0x9e3779b97f4a7c15
+	return h ^ h>>29
+}
+
+// Entry is the state kept for one flow. Entries are shared between all
+// goroutines using the table: Value must be safe for concurrent use if the
+// flow is handled by more than one of them.
+type Entry struct {
+	lastSeen int64 // UnixNano, accessed atomically
+	removed  int32 // accessed atomically
+
+	Key   Key
+	Value interface{}
+}
+
+// LastSeen returns the latest time passed to Touch or GetOrCreate.
+func (e *Entry) LastSeen() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&e.lastSeen))
+}
+
+// Touch records that the flow was seen at t. Earlier times are ignored.
+func (e *Entry) Touch(t time.Time) {
+	n := t.UnixNano()
+	for {
+		old := atomic.LoadInt64(&e.lastSeen)
+		if n <= old || atomic.CompareAndSwapInt64(&e.lastSeen, old, n) {
+			return
+		}
+	}
+}
+
+// Removed reports whether the entry was deleted or expired from its table.
+func (e *Entry) Removed() bool {
+	return atomic.LoadInt32(&e.removed) != 0
+}
+
+// minMerge is the number of new entries a shard accumulates before
+// publishing a new map, regardless of its size.
+const minMerge = 64
+
+type shard struct {
+	// read holds a map[Key]*Entry which is never modified once stored.
+	read atomic.Value
+
+	mu sync.Mutex
+	// readLen is the size of the map in read and removed the number of
+	// its entries deleted since.
+	readLen, removed int
+	// dirty holds the entries added since read was published.
+	dirty map[Key]*Entry
+}
+
+func (s *shard) load() map[Key]*Entry {
+	m, _ := s.read.Load().(map[Key]*Entry)
+	return m
+}
+
+// publish merges dirty into a new read map, dropping removed entries and
+// those for which drop returns true. It must be called with mu held.
+func (s *shard) publish(drop func(*Entry) bool) {
+	old := s.load()
+	m := make(map[Key]*Entry, s.readLen-s.removed+len(s.dirty))
+	for k, e := range old {
+		if !e.Removed() && (drop == nil || !drop(e)) {
+			m[k] = e
+		}
+	}
+	for k, e := range s.dirty {
+		if drop == nil || !drop(e) {
+			m[k] = e
+		}
+	}
+	s.read.Store(m)
+	s.readLen = len(m)
+	s.removed = 0
+	s.dirty = make(map[Key]*Entry)
+}
+
+// Table maps flow keys to entries. It is safe for concurrent use.
+type Table struct {
+	// size is accessed atomically. It MUST be the first field, to be 64-bit
+	// aligned on 32-bit platforms.
+	size   int64
+	shards []*shard
+	mask   uint64
+}
+
+// New returns a table with the given number of shards, rounded up to a
+// power of two. Zero selects 16 shards per CPU.
+func New(shards int) *Table {
+	if shards <= 0 {
+		shards = 16 * runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	t := &Table{shards: make([]*shard, n), mask: uint64(n - 1)}
+	for i := range t.shards {
+		s := &shard{dirty: make(map[Key]*Entry)}
+		s.read.Store(map[Key]*Entry{})
+		t.shards[i] = s
+	}
+	return t
+}
+
+func (t *Table) shard(k Key) *shard {
+	return t.shards[k.FastHash()&t.mask]
+}
+
+// Get returns the entry for k, or nil. Looking up an established entry does
+// not take a lock; entries created since the shard last published its map
+// are looked up under the shard's lock.
+func (t *Table) Get(k Key) *Entry {
+	s := t.shard(k)
+	if e := s.load()[k]; e != nil && !e.Removed() {
+		return e
+	}
+	s.mu.Lock()
+	e := s.dirty[k]
+	s.mu.Unlock()
+	return e
+}
+
+// GetOrCreate returns the entry for k, creating it with the value returned
+// by create if there is none. It reports whether the entry was created.
+// New entries are last seen at now. create is called with the shard's lock
+// held and must not use the table.
+func (t *Table) GetOrCreate(k Key, now time.Time, create func() interface{}) (e *Entry, created bool) {
+	s := t.shard(k)
+	if e := s.load()[k]; e != nil && !e.Removed() {
+		return e, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The map may have been published while we were waiting.
+	if e := s.load()[k]; e != nil && !e.Removed() {
+		return e, false
+	}
+	if e := s.dirty[k]; e != nil {
+		return e, false
+	}
+	e = &Entry{lastSeen: now.UnixNano(), Key: k}
+	if create != nil {
+		e.Value = create()
+	}
+	s.dirty[k] = e
+	atomic.AddInt64(&t.size, 1)
+	if len(s.dirty) >= minMerge+s.readLen/8 {
+		s.publish(nil)
+	}
+	return e, true
+}
+
+// Delete removes the entry for k and returns it, or nil if there was none.
+func (t *Table) Delete(k Key) *Entry {
+	s := t.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.dirty[k]; e != nil {
+		delete(s.dirty, k)
+		atomic.StoreInt32(&e.removed, 1)
+		atomic.AddInt64(&t.size, -1)
+		return e
+	}
+	e := s.load()[k]
+	if e == nil || e.Removed() {
+		return nil
+	}
+	atomic.StoreInt32(&e.removed, 1)
+	atomic.AddInt64(&t.size, -1)
+	s.removed++
+	if s.removed > minMerge+s.readLen/4 {
+		s.publish(nil)
+	}
+	return e
+}
+
+// Expire removes all entries last seen before the given time, calling fn
+// (if not nil) for each of them, and returns how many were removed. fn is
+// called with a shard's lock held and must not use the table.
+func (t *Table) Expire(before time.Time, fn func(*Entry)) int {
+	limit := before.UnixNano()
+	n := 0
+	for _, s := range t.shards {
+		s.mu.Lock()
+		s.publish(func(e *Entry) bool {
+			if atomic.LoadInt64(&e.lastSeen) >= limit {
+				return false
+			}
+			atomic.StoreInt32(&e.removed, 1)
+			n++
+			if fn != nil {
+				fn(e)
+			}
+			return true
+		})
+		s.mu.Unlock()
+	}
+	atomic.AddInt64(&t.size, -int64(n))
+	return n
+}
+
+// Len returns the number of entries in the table.
+func (t *Table) Len() int {
+	return int(atomic.LoadInt64(&t.size))
+}
+
+// Range calls fn for each entry until it returns false. Entries added or
+// removed concurrently may or may not be visited.
+func (t *Table) Range(fn func(*Entry) bool) {
+	var dirty []*Entry
+	for _, s := range t.shards {
+		s.mu.Lock()
+		dirty = dirty[:0]
+		for _, e := range s.dirty {
+			dirty = append(dirty, e)
+		}
+		s.mu.Unlock()
+		for _, e := range s.load() {
+			if !e.Removed() && !fn(e) {
+				return
+			}
+		}
+		for _, e := range dirty {
+			if !e.Removed() && !fn(e) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowtable
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func testKey(i int) Key {
+	var src, dst [4]byte
+	binary.BigEndian.PutUint32(src[:], uint32(i))
+	binary.BigEndian.PutUint32(dst[:], 0x0a000001)
+	var sport, dport [2]byte
+	binary.BigEndian.PutUint16(sport[:], uint16(i))
+	binary.BigEndian.PutUint16(dport[:], 80)
+	return NewKey(gopacket.NewFlow(layers.EndpointIPv4, src[:], dst[:]),
+		gopacket.NewFlow(layers.EndpointTCPPort, sport[:], dport[:]))
+}
+
+func TestNewKeyBidirectional(t *testing.T) {
+	k := testKey(7)
+	r := NewKey(k.Network.Reverse(), k.Transport.Reverse())
+	if k != r || k.FastHash() != r.FastHash() {
+		t.Errorf("got different keys %v and %v", k, r)
+	}
+}
+
+func TestTable(t *testing.T) {
+	table := New(4)
+	now := time.Unix(1000, 0)
+	const n = 1000
+	for i := 0; i < n; i++ {
+		v := i
+		e, created := table.GetOrCreate(testKey(i), now, func() interface{} { return v })
+		if !created || e.Value != v {
+			t.Fatalf("key %d: got %v, created %v", i, e.Value, created)
+		}
+	}
+	if table.Len() != n {
+		t.Fatalf("got %d entries, want %d", table.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		e := table.Get(testKey(i))
+		if e == nil || e.Value != i {
+			t.Fatalf("key %d: got entry %+v", i, e)
+		}
+		if _, created := table.GetOrCreate(testKey(i), now, nil); created {
+			t.Fatalf("key %d: created twice", i)
+		}
+	}
+
+	// Deleted entries can be created again.
+	e := table.Delete(testKey(3))
+	if e == nil || !e.Removed() || table.Get(testKey(3)) != nil || table.Delete(testKey(3)) != nil {
+		t.Error("delete failed")
+	}
+	if _, created := table.GetOrCreate(testKey(3), now, nil); !created {
+		t.Error("deleted entry was not created again")
+	}
+
+	// Touch half the entries and expire the others.
+	later := now.Add(time.Minute)
+	for i := 0; i < n; i += 2 {
+		table.Get(testKey(i)).Touch(later)
+	}
+	table.Get(testKey(0)).Touch(now)
+	if got := table.Get(testKey(0)).LastSeen(); !got.Equal(later) {
+		t.Errorf("Touch went back in time to %v", got)
+	}
+	var expired []*Entry
+	if got := table.Expire(later, func(e *Entry) { expired = append(expired, e) }); got != n/2 || len(expired) != n/2 {
+		t.Errorf("expired %d entries, %d reported", got, len(expired))
+	}
+	count := 0
+	table.Range(func(e *Entry) bool {
+		count++
+		return true
+	})
+	if count != n/2 || table.Len() != n/2 {
+		t.Errorf("got %d entries, Len %d, want %d", count, table.Len(), n/2)
+	}
+	if table.Get(testKey(1)) != nil {
+		t.Error("expired entry still present")
+	}
+}
+
+func TestTableConcurrent(t *testing.T) {
+	table := New(0)
+	now := time.Now()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				e, _ := table.GetOrCreate(testKey(i), now, nil)
+				e.Touch(now)
+				if i%10 == 0 {
+					table.Delete(testKey(i / 2))
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			table.Expire(now.Add(-time.Hour), nil)
+		}
+	}()
+	wg.Wait()
+	count := 0
+	table.Range(func(*Entry) bool {
+		count++
+		return true
+	})
+	if count != table.Len() {
+		t.Errorf("Range found %d entries, Len is %d", count, table.Len())
+	}
+}
+
+func TestKeyWithFlowLabel(t *testing.T) {
+	k := testKey(1)
+	a := NewKeyWithFlowLabel(k.Network, k.Transport, 0x12345)
+	b := NewKeyWithFlowLabel(k.Network.Reverse(), k.Transport.Reverse(), 0x12345)
+	c := NewKeyWithFlowLabel(k.Network, k.Transport, 0x54321)
+	if a != b || a == c || a == k {
+		t.Errorf("got keys %v, %v, %v", a, b, c)
+	}
+	if a.FastHash() == c.FastHash() {
+		t.Error("flow label does not change the hash")
+	}
+}
+
+// benchmarkFlows is the number of flows the benchmarks keep in the table.
+const benchmarkFlows = 1 << 20
+
+var benchmarkTable struct {
+	once  sync.Once
+	table *Table
+	keys  []Key
+}
+
+func filledTable() (*Table, []Key) {
+	benchmarkTable.once.Do(func() {
+		t := New(0)
+		keys := make([]Key, benchmarkFlows)
+		now := time.Now()
+		for i := range keys {
+			keys[i] = testKey(i)
+			t.GetOrCreate(keys[i], now, nil)
+		}
+		benchmarkTable.table, benchmarkTable.keys = t, keys
+	})
+	return benchmarkTable.table, benchmarkTable.keys
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	table, keys := filledTable()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			table.Get(keys[i&(benchmarkFlows-1)])
+			i += 7919
+		}
+	})
+}
+
+func BenchmarkGetOrCreateParallel(b *testing.B) {
+	table := New(0)
+	now := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			e, _ := table.GetOrCreate(testKey(i&(benchmarkFlows-1)), now, nil)
+			e.Touch(now)
+			i += 7919
+		}
+	})
+}
+
+// BenchmarkMutexMap is the baseline: a single map behind a mutex.
+func BenchmarkMutexMap(b *testing.B) {
+	_, keys := filledTable()
+	var mu sync.RWMutex
+	m := make(map[Key]*Entry, len(keys))
+	for _, k := range keys {
+		m[k] = &Entry{Key: k}
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			mu.RLock()
+			_ = m[keys[i&(benchmarkFlows-1)]]
+			mu.RUnlock()
+			i += 7919
+		}
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowtable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// Codec converts entry values to and from bytes for Snapshot and Restore.
+type Codec struct {
+	Marshal   func(value interface{}) ([]byte, error)
+	Unmarshal func(data []byte) (interface{}, error)
+}
+
+// snapshotVersion is written at the start of each snapshot.
+const snapshotVersion = 1
+
+// Snapshot writes the key, last seen time and value of every entry to w,
+// using codec to marshal the values. Entries added or removed concurrently
+// may or may not be included. It can be registered with a
+// snapshot.Coordinator through snapshot.Func.
+func (t *Table) Snapshot(w io.Writer, codec Codec) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteByte(snapshotVersion)
+	var err error
+	t.Range(func(e *Entry) bool {
+		var value []byte
+		if value, err = codec.Marshal(e.Value); err != nil {
+			err = fmt.Errorf("flowtable: marshaling value of %v: %v", e.Key, err)
+			return false
+		}
+		bw.WriteByte(1)
+		writeFlow(bw, e.Key.Network)
+		writeFlow(bw, e.Key.Transport)
+		writeUvarint(bw, uint64(e.Key.FlowLabel))
+		writeUvarint(bw, uint64(e.LastSeen().UnixNano()))
+		writeUvarint(bw, uint64(len(value)))
+		bw.Write(value)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteByte(0)
+	return bw.Flush()
+}
+
+func writeUvarint(w *bufio.Writer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeFlow(w *bufio.Writer, f gopacket.Flow) {
+	src, dst := f.Endpoints()
+	writeUvarint(w, uint64(f.EndpointType()))
+	writeUvarint(w, uint64(len(src.Raw())))
+	w.Write(src.Raw())
+	writeUvarint(w, uint64(len(dst.Raw())))
+	w.Write(dst.Raw())
+}
+
+// Restore adds the entries written by Snapshot to the table, using codec to
+// unmarshal their values. Existing entries with the same key get the
+// restored value and last seen time.
+func (t *Table) Restore(r io.Reader, codec Codec) error {
+	br := bufio.NewReader(r)
+	version, err := br.ReadByte()
+	if err != nil {
+		return fmt.Errorf("flowtable: reading snapshot: %v", err)
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("flowtable: unsupported snapshot version %d", version)
+	}
+	for {
+		more, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("flowtable: reading snapshot: %v", err)
+		}
+		if more == 0 {
+			return nil
+		}
+		var k Key
+		if k.Network, err = readFlow(br); err != nil {
+			return err
+		}
+		if k.Transport, err = readFlow(br); err != nil {
+			return err
+		}
+		label, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fmt.Errorf("flowtable: reading snapshot: %v", err)
+		}
+		k.FlowLabel = uint32(label)
+		seen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fmt.Errorf("flowtable: reading snapshot: %v", err)
+		}
+		data, err := readRaw(br)
+		if err != nil {
+			return err
+		}
+		value, err := codec.Unmarshal(data)
+		if err != nil {
+			return fmt.Errorf("flowtable: unmarshaling value of %v: %v", k, err)
+		}
+		lastSeen := time.Unix(0, int64(seen))
+		e, created := t.GetOrCreate(k, lastSeen, func() interface{} { return value })
+		if !created {
+			e.Value = value
+			e.Touch(lastSeen)
+		}
+	}
+}
+
+// maxRaw bounds the length of a value or endpoint read by Restore.
+const maxRaw = 1 << 24
+
+func readRaw(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("flowtable: reading snapshot: %v", err)
+	}
+	if n > maxRaw {
+		return nil, errors.New("flowtable: snapshot corrupt")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("flowtable: reading snapshot: %v", err)
+	}
+	return b, nil
+}
+
+func readFlow(r *bufio.Reader) (gopacket.Flow, error) {
+	typ, err := binary.ReadUvarint(r)
+	if err != nil {
+		return gopacket.Flow{}, fmt.Errorf("flowtable: reading snapshot: %v", err)
+	}
+	src, err := readRaw(r)
+	if err != nil {
+		return gopacket.Flow{}, err
+	}
+	dst, err := readRaw(r)
+	if err != nil {
+		return gopacket.Flow{}, err
+	}
+	if len(src) > gopacket.MaxEndpointSize || len(dst) > gopacket.MaxEndpointSize {
+		return gopacket.Flow{}, errors.New("flowtable: snapshot corrupt")
+	}
+	if len(src) == 0 && len(dst) == 0 && typ == 0 {
+		return gopacket.Flow{}, nil
+	}
+	return gopacket.NewFlow(gopacket.EndpointType(typ), src, dst), nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowtable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+var uint64Codec = Codec{
+	Marshal: func(v interface{}) ([]byte, error) {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v.(uint64))
+		return b, nil
+	},
+	Unmarshal: func(b []byte) (interface{}, error) {
+		if len(b) != 8 {
+			return nil, errors.New("bad value")
+		}
+		return binary.BigEndian.Uint64(b), nil
+	},
+}
+
+func TestSnapshot(t *testing.T) {
+	now := time.Unix(1600000000, 123)
+	table := New(4)
+	for i := 0; i < 100; i++ {
+		table.GetOrCreate(testKey(i), now.Add(time.Duration(i)), func() interface{} { return uint64(i) })
+	}
+	// A key without a transport flow.
+	k := testKey(200)
+	k.Transport = Key{}.Transport
+	k.FlowLabel = 0xabcde
+	table.GetOrCreate(k, now, func() interface{} { return uint64(200) })
+
+	var buf bytes.Buffer
+	if err := table.Snapshot(&buf, uint64Codec); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(0)
+	if err := restored.Restore(bytes.NewReader(buf.Bytes()), uint64Codec); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Len() != 101 {
+		t.Fatalf("restored %d entries, want 101", restored.Len())
+	}
+	for i := 0; i < 100; i++ {
+		e := restored.Get(testKey(i))
+		if e == nil || e.Value.(uint64) != uint64(i) || !e.LastSeen().Equal(now.Add(time.Duration(i))) {
+			t.Fatalf("entry %d restored as %+v", i, e)
+		}
+	}
+	if e := restored.Get(k); e == nil || e.Value.(uint64) != 200 {
+		t.Errorf("entry without transport restored as %+v", e)
+	}
+
+	if err := restored.Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), uint64Codec); err == nil {
+		t.Error("restored truncated snapshot")
+	}
+}
@@ -3,6 +3,8 @@ module github.com/google/gopacket
 go 1.12
 
 require (
+	github.com/klauspost/compress v1.11.13
+	github.com/pierrec/lz4 v2.6.1+incompatible
 	github.com/vishvananda/netlink v1.1.0
 	github.com/vishvananda/netns v0.0.0-20210104183010-2eb08e3e575f
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
@@ -0,0 +1,141 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package httpassembly reassembles TCP streams and reads the HTTP requests
+// they carry. It is the library behind the httpassembly example, for
+// programs which embed it:
+//
+//	s := &httpassembly.Sniffer{
+//		OnRequest: func(net, transport gopacket.Flow, req *http.Request) {
+//			log.Println(net, transport, req.Method, req.URL)
+//		},
+//	}
+//	s.Run(gopacket.NewPacketSource(handle, handle.LinkType()), done)
+//
+// Run returns once the source is exhausted or done is closed, after all
+// streams have been flushed and their requests handled.
+package httpassembly
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+	"github.com/google/gopacket/tcpassembly/tcpreader"
+)
+
+// Default values of the Sniffer fields.
+const (
+	DefaultFlushInterval = time.Minute
+	DefaultIdleTimeout   = 2 * time.Minute
+)
+
+// Sniffer reads HTTP requests from the TCP streams of packets.
+type Sniffer struct {
+	// OnRequest is called with each request read from a stream. It is
+	// called from a goroutine per stream, so concurrently for different
+	// streams. The rest of the request body is discarded once it returns.
+	OnRequest func(net, transport gopacket.Flow, req *http.Request)
+	// OnError, if not nil, is called when a stream does not hold a valid
+	// request. The rest of the stream is discarded.
+	OnError func(net, transport gopacket.Flow, err error)
+	// OnPacket, if not nil, is called with every packet of the source
+	// before it is reassembled.
+	OnPacket func(gopacket.Packet)
+	// FlushInterval is how often streams are checked for inactivity, and
+	// IdleTimeout how long, in packet time, a stream with missing data may
+	// wait for it before the gap is skipped. They default to
+	// DefaultFlushInterval and DefaultIdleTimeout.
+	FlushInterval time.Duration
+	IdleTimeout   time.Duration
+
+	wg sync.WaitGroup
+}
+
+// factory starts a goroutine reading the requests of each new stream.
+type factory struct {
+	s *Sniffer
+}
+
+func (f factory) New(net, transport gopacket.Flow) tcpassembly.Stream {
+	r := tcpreader.NewReaderStream()
+	f.s.wg.Add(1)
+	go f.s.read(net, transport, &r)
+	return &r
+}
+
+// read handles the requests of a stream until its end, which it must read
+// up to for the assembler not to block.
+func (s *Sniffer) read(net, transport gopacket.Flow, r *tcpreader.ReaderStream) {
+	defer s.wg.Done()
+	buf := bufio.NewReader(r)
+	for {
+		req, err := http.ReadRequest(buf)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			if s.OnError != nil {
+				s.OnError(net, transport, err)
+			}
+			tcpreader.DiscardBytesToEOF(buf)
+			return
+		}
+		if s.OnRequest != nil {
+			s.OnRequest(net, transport, req)
+		}
+		tcpreader.DiscardBytesToEOF(req.Body)
+		req.Body.Close()
+	}
+}
+
+// Run reassembles the TCP packets of source until it is exhausted or done
+// is closed. It then flushes all streams, and waits for their requests to
+// be handled before returning. A Sniffer must not be run more than once at
+// a time.
+func (s *Sniffer) Run(source *gopacket.PacketSource, done <-chan struct{}) {
+	interval, timeout := s.FlushInterval, s.IdleTimeout
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	assembler := tcpassembly.NewAssembler(tcpassembly.NewStreamPool(factory{s}))
+	defer func() {
+		assembler.FlushAll()
+		s.wg.Wait()
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	packets := source.Packets()
+	var last time.Time
+	for {
+		select {
+		case packet, ok := <-packets:
+			if !ok {
+				return
+			}
+			if s.OnPacket != nil {
+				s.OnPacket(packet)
+			}
+			tcp, _ := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+			if tcp == nil || packet.NetworkLayer() == nil {
+				continue
+			}
+			last = packet.Metadata().Timestamp
+			assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcp, last)
+		case <-ticker.C:
+			assembler.FlushOlderThan(last.Add(-timeout))
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package httpassembly
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// packets is a PacketDataSource returning its packets in order.
+type packets [][]byte
+
+func (p *packets) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(*p) == 0 {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	data := (*p)[0]
+	*p = (*p)[1:]
+	return data, gopacket.CaptureInfo{Timestamp: time.Unix(1, 0), CaptureLength: len(data), Length: len(data)}, nil
+}
+
+func tcpPacket(t *testing.T, srcPort layers.TCPPort, seq uint32, syn, fin bool, payload string) []byte {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	tcp := &layers.TCP{SrcPort: srcPort, DstPort: 80, Seq: seq, SYN: syn, FIN: fin, ACK: !syn, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffer(t *testing.T) {
+	get := "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	post := "POST /b HTTP/1.1\r\nHost: example.com\r\nContent-Length: 4\r\n\r\nbody"
+	src := packets{
+		tcpPacket(t, 1000, 100, true, false, ""),
+		tcpPacket(t, 1000, 101, false, false, get),
+		tcpPacket(t, 1000, 101+uint32(len(get)), false, false, post),
+		tcpPacket(t, 1000, 101+uint32(len(get)+len(post)), false, true, ""),
+		tcpPacket(t, 2000, 500, true, false, ""),
+		tcpPacket(t, 2000, 501, false, false, "not HTTP\r\n\r\n"),
+	}
+
+	var mu sync.Mutex
+	var requests []string
+	var errors, packets int
+	s := &Sniffer{
+		OnRequest: func(net, transport gopacket.Flow, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, req.Method+" "+req.URL.String())
+		},
+		OnError: func(net, transport gopacket.Flow, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errors++
+		},
+		OnPacket: func(gopacket.Packet) { packets++ },
+	}
+	s.Run(gopacket.NewPacketSource(&src, layers.LayerTypeIPv4), nil)
+
+	// Run waits for the streams, so the results are complete.
+	sort.Strings(requests)
+	if len(requests) != 2 || requests[0] != "GET /a" || requests[1] != "POST /b" {
+		t.Errorf("got requests %q", requests)
+	}
+	if errors != 1 {
+		t.Errorf("got %d errors, want 1", errors)
+	}
+	if packets != 6 {
+		t.Errorf("got %d packets, want 6", packets)
+	}
+}
+
+func TestSnifferDone(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	s := &Sniffer{}
+	s.Run(gopacket.NewPacketSource(&packets{}, layers.LayerTypeIPv4), done)
+}
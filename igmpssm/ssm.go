@@ -0,0 +1,322 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package igmpssm tracks the IGMPv3 membership state announced by each host
+// on a link and checks it against the source-specific multicast rules of
+// RFC 4604 and RFC 4607.
+//
+// A typical use is validating set-top box behaviour from a capture: feed
+// every decoded packet to an Analyzer and collect the returned violations.
+//
+//  a := igmpssm.NewAnalyzer()
+//  for p := range source.Packets() {
+//      for _, v := range a.ProcessPacket(p) {
+//          fmt.Println(v)
+//      }
+//  }
+package igmpssm
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// DefaultSSMRange is the IPv4 source-specific multicast range reserved by
+// RFC 4607, 232.0.0.0/8.
+var DefaultSSMRange = &net.IPNet{IP: net.IPv4(232, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}
+
+// FilterMode is the filter mode of a host's membership in a group.
+type FilterMode uint8
+
+const (
+	// Include means the host wants traffic only from the listed sources.
+	Include FilterMode = iota
+	// Exclude means the host wants traffic from all but the listed sources.
+	Exclude
+)
+
+func (m FilterMode) String() string {
+	switch m {
+	case Include:
+		return "INCLUDE"
+	case Exclude:
+		return "EXCLUDE"
+	default:
+		return fmt.Sprintf("FilterMode(%d)", m)
+	}
+}
+
+// GroupState is the membership state of one host for one multicast group, as
+// reconstructed from the host's reports (RFC 3376 section 5.1).
+type GroupState struct {
+	Group      net.IP
+	FilterMode FilterMode
+	// Sources is kept sorted in ascending byte order.
+	Sources []net.IP
+	// Known is false until a current-state or filter-mode-change record has
+	// been seen. Until then the filter mode is only inferred from source
+	// list changes, and transition checks are skipped.
+	Known    bool
+	LastSeen time.Time
+}
+
+// Member reports whether the host wants any traffic for the group.
+func (g *GroupState) Member() bool {
+	return g.FilterMode == Exclude || len(g.Sources) > 0
+}
+
+// Violation describes a single report that breaks IGMPv3 or SSM rules.
+type Violation struct {
+	Host   net.IP
+	Group  net.IP
+	Record layers.IGMPv3GroupRecordType
+	Reason string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v: group %v: %v: %s", v.Host, v.Group, v.Record, v.Reason)
+}
+
+type stateKey struct {
+	host, group [4]byte
+}
+
+// Analyzer reconstructs per-host, per-group IGMPv3 membership state and
+// reports violations of the SSM model. It is not safe for concurrent use.
+type Analyzer struct {
+	// SSMRange is the group range in which only source-specific INCLUDE mode
+	// joins are legal. It defaults to DefaultSSMRange.
+	SSMRange *net.IPNet
+	states   map[stateKey]*GroupState
+}
+
+// NewAnalyzer returns an Analyzer using DefaultSSMRange.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		SSMRange: DefaultSSMRange,
+		states:   make(map[stateKey]*GroupState),
+	}
+}
+
+func key4(host, group net.IP) (k stateKey) {
+	copy(k.host[:], host.To4())
+	copy(k.group[:], group.To4())
+	return
+}
+
+// State returns a copy of the membership state of host for group.
+func (a *Analyzer) State(host, group net.IP) (GroupState, bool) {
+	st, ok := a.states[key4(host, group)]
+	if !ok {
+		return GroupState{}, false
+	}
+	cp := *st
+	cp.Sources = append([]net.IP(nil), st.Sources...)
+	return cp, true
+}
+
+// Groups returns the state of every group host currently is a member of,
+// ordered by group address.
+func (a *Analyzer) Groups(host net.IP) []GroupState {
+	var out []GroupState
+	for k, st := range a.states {
+		if !bytes.Equal(k.host[:], host.To4()) || !st.Member() {
+			continue
+		}
+		cp := *st
+		cp.Sources = append([]net.IP(nil), st.Sources...)
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i].Group, out[j].Group) < 0 })
+	return out
+}
+
+// ProcessPacket feeds the IGMP layer of p, if any, to the analyzer using the
+// IPv4 source address as the reporting host. Packets without IGMP are
+// ignored.
+func (a *Analyzer) ProcessPacket(p gopacket.Packet) []Violation {
+	ip, ok := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		return nil
+	}
+	var ts time.Time
+	if md := p.Metadata(); md != nil {
+		ts = md.Timestamp
+	}
+	switch igmp := p.Layer(layers.LayerTypeIGMP).(type) {
+	case *layers.IGMP:
+		return a.ProcessReport(ip.SrcIP, igmp, ts)
+	case *layers.IGMPv1or2:
+		return a.ProcessV1or2(ip.SrcIP, igmp, ts)
+	}
+	return nil
+}
+
+// ProcessV1or2 checks an IGMPv1 or IGMPv2 message sent by host. Such messages
+// cannot carry source lists, so any join or leave of an SSM group is
+// reported.
+func (a *Analyzer) ProcessV1or2(host net.IP, igmp *layers.IGMPv1or2, ts time.Time) []Violation {
+	switch igmp.Type {
+	case layers.IGMPMembershipReportV1, layers.IGMPMembershipReportV2, layers.IGMPLeaveGroup:
+	default:
+		return nil
+	}
+	if !a.isSSM(igmp.GroupAddress) {
+		return nil
+	}
+	return []Violation{{
+		Host:   host,
+		Group:  igmp.GroupAddress,
+		Reason: fmt.Sprintf("IGMPv%d %v for an SSM group", igmp.Version, igmp.Type),
+	}}
+}
+
+// ProcessReport applies every group record of an IGMPv3 Membership Report
+// sent by host and returns the violations found. Queries are ignored.
+func (a *Analyzer) ProcessReport(host net.IP, igmp *layers.IGMP, ts time.Time) []Violation {
+	if igmp.Type != layers.IGMPMembershipReportV3 {
+		return nil
+	}
+	var out []Violation
+	for i := range igmp.GroupRecords {
+		out = append(out, a.processRecord(host, &igmp.GroupRecords[i], ts)...)
+	}
+	return out
+}
+
+func (a *Analyzer) isSSM(group net.IP) bool {
+	return a.SSMRange != nil && a.SSMRange.Contains(group)
+}
+
+func (a *Analyzer) processRecord(host net.IP, gr *layers.IGMPv3GroupRecord, ts time.Time) []Violation {
+	var out []Violation
+	violate := func(format string, args ...interface{}) {
+		out = append(out, Violation{
+			Host:   host,
+			Group:  gr.MulticastAddress,
+			Record: gr.Type,
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+	if err := gr.Validate(); err != nil {
+		violate("%v", err)
+		return out
+	}
+
+	k := key4(host, gr.MulticastAddress)
+	st, ok := a.states[k]
+	if !ok {
+		st = &GroupState{Group: gr.MulticastAddress.To4()}
+		a.states[k] = st
+	}
+	st.LastSeen = ts
+	srcs := sortedSources(gr.SourceAddresses)
+
+	switch gr.Type {
+	case layers.IGMPIsIn, layers.IGMPIsEx:
+		mode := Include
+		if gr.Type == layers.IGMPIsEx {
+			mode = Exclude
+		}
+		if st.Known && st.FilterMode != mode {
+			violate("current state reports %v but host was last seen in %v mode", mode, st.FilterMode)
+		}
+		st.FilterMode, st.Sources, st.Known = mode, srcs, true
+	case layers.IGMPToIn, layers.IGMPToEx:
+		mode := Include
+		if gr.Type == layers.IGMPToEx {
+			mode = Exclude
+		}
+		if st.Known && st.FilterMode == mode {
+			violate("filter mode change to %v while already in %v mode", mode, mode)
+		}
+		st.FilterMode, st.Sources, st.Known = mode, srcs, true
+	case layers.IGMPAllow:
+		if len(srcs) == 0 {
+			violate("ALLOW_NEW_SOURCES record without sources")
+		}
+		if st.FilterMode == Include {
+			st.Sources = union(st.Sources, srcs)
+		} else {
+			st.Sources = difference(st.Sources, srcs)
+		}
+	case layers.IGMPBlock:
+		if len(srcs) == 0 {
+			violate("BLOCK_OLD_SOURCES record without sources")
+		}
+		if st.FilterMode == Include {
+			if st.Known {
+				if missing := difference(srcs, st.Sources); len(missing) > 0 {
+					violate("blocks sources %v that were never allowed", missing)
+				}
+			}
+			st.Sources = difference(st.Sources, srcs)
+		} else {
+			st.Sources = union(st.Sources, srcs)
+		}
+	}
+
+	if a.isSSM(gr.MulticastAddress) && (gr.Type == layers.IGMPIsEx || gr.Type == layers.IGMPToEx) {
+		// RFC 4604 section 2.2.1: EXCLUDE mode is not applicable to SSM
+		// addresses and such records must be ignored by routers.
+		violate("EXCLUDE mode membership for an SSM group")
+	}
+
+	return out
+}
+
+func sortedSources(in []net.IP) []net.IP {
+	out := make([]net.IP, 0, len(in))
+	for _, ip := range in {
+		out = append(out, ip.To4())
+	}
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i], out[j]) < 0 })
+	return out
+}
+
+// union returns the sorted union of two sorted source lists.
+func union(a, b []net.IP) []net.IP {
+	out := make([]net.IP, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch c := bytes.Compare(a[i], b[j]); {
+		case c < 0:
+			out = append(out, a[i])
+			i++
+		case c > 0:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// difference returns the sorted list of sources in a but not in b.
+func difference(a, b []net.IP) []net.IP {
+	out := make([]net.IP, 0, len(a))
+	j := 0
+	for _, ip := range a {
+		for j < len(b) && bytes.Compare(b[j], ip) < 0 {
+			j++
+		}
+		if j < len(b) && bytes.Equal(b[j], ip) {
+			continue
+		}
+		out = append(out, ip)
+	}
+	return out
+}
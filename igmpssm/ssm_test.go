@@ -0,0 +1,124 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package igmpssm
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	stb    = net.IPv4(192, 168, 1, 20)
+	group  = net.IPv4(232, 1, 1, 1)
+	asm    = net.IPv4(239, 1, 1, 1)
+	srcA   = net.IPv4(10, 0, 0, 1)
+	srcB   = net.IPv4(10, 0, 0, 2)
+	tStart = time.Unix(1600000000, 0)
+)
+
+func report(records ...layers.IGMPv3GroupRecord) *layers.IGMP {
+	return &layers.IGMP{
+		Type:                 layers.IGMPMembershipReportV3,
+		NumberOfGroupRecords: uint16(len(records)),
+		GroupRecords:         records,
+	}
+}
+
+func rec(typ layers.IGMPv3GroupRecordType, g net.IP, srcs ...net.IP) layers.IGMPv3GroupRecord {
+	return layers.NewIGMPv3GroupRecord(typ, g, srcs)
+}
+
+func TestSSMJoinLeave(t *testing.T) {
+	a := NewAnalyzer()
+	steps := []struct {
+		report  *layers.IGMP
+		sources []net.IP
+	}{
+		{report(rec(layers.IGMPAllow, group, srcA)), []net.IP{srcA}},
+		{report(rec(layers.IGMPIsIn, group, srcA, srcB)), []net.IP{srcA, srcB}},
+		{report(rec(layers.IGMPBlock, group, srcA)), []net.IP{srcB}},
+		{report(rec(layers.IGMPBlock, group, srcB)), nil},
+	}
+	for i, s := range steps {
+		if v := a.ProcessReport(stb, s.report, tStart); len(v) != 0 {
+			t.Fatalf("step %d: unexpected violations %v", i, v)
+		}
+		st, ok := a.State(stb, group)
+		if !ok {
+			t.Fatalf("step %d: no state", i)
+		}
+		if st.FilterMode != Include || len(st.Sources) != len(s.sources) {
+			t.Fatalf("step %d: got %v %v, want INCLUDE %v", i, st.FilterMode, st.Sources, s.sources)
+		}
+		for j := range s.sources {
+			if !st.Sources[j].Equal(s.sources[j]) {
+				t.Fatalf("step %d: got sources %v, want %v", i, st.Sources, s.sources)
+			}
+		}
+	}
+	if g := a.Groups(stb); len(g) != 0 {
+		t.Errorf("host should have left every group, still in %v", g)
+	}
+}
+
+func TestSSMViolations(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		reports []*layers.IGMP
+		want    string
+	}{
+		{"exclude join", []*layers.IGMP{report(rec(layers.IGMPToEx, group))}, "EXCLUDE mode"},
+		{"redundant TO_IN", []*layers.IGMP{
+			report(rec(layers.IGMPIsIn, group, srcA)),
+			report(rec(layers.IGMPToIn, group, srcB)),
+		}, "already in INCLUDE"},
+		{"block unknown source", []*layers.IGMP{
+			report(rec(layers.IGMPIsIn, group, srcA)),
+			report(rec(layers.IGMPBlock, group, srcB)),
+		}, "never allowed"},
+		{"bad source", []*layers.IGMP{report(rec(layers.IGMPAllow, group, net.IPv4(224, 0, 0, 1)))}, "invalid source"},
+		{"bad group", []*layers.IGMP{report(rec(layers.IGMPAllow, srcA, srcB))}, "not an IPv4 multicast"},
+	} {
+		a := NewAnalyzer()
+		var got []Violation
+		for _, r := range test.reports {
+			got = append(got, a.ProcessReport(stb, r, tStart)...)
+		}
+		if len(got) != 1 || !strings.Contains(got[0].Reason, test.want) {
+			t.Errorf("%s: got violations %v, want one containing %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestASMExcludeAllowed(t *testing.T) {
+	a := NewAnalyzer()
+	if v := a.ProcessReport(stb, report(rec(layers.IGMPToEx, asm)), tStart); len(v) != 0 {
+		t.Errorf("unexpected violations for ASM join: %v", v)
+	}
+	if v := a.ProcessReport(stb, report(rec(layers.IGMPToIn, asm)), tStart); len(v) != 0 {
+		t.Errorf("unexpected violations for ASM leave: %v", v)
+	}
+}
+
+func TestProcessPacketV2Join(t *testing.T) {
+	buf := gopacket.NewSerializeBuffer()
+	ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 1, Protocol: layers.IPProtocolIGMP, SrcIP: stb, DstIP: group}
+	payload := gopacket.Payload{0x16, 0x00, 0x00, 0x00, 232, 1, 1, 1}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip, payload); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	a := NewAnalyzer()
+	if v := a.ProcessPacket(p); len(v) != 1 {
+		t.Errorf("got violations %v, want one for the IGMPv2 report", v)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package ipfrag serializes IPv4 and IPv6 packets into fragments fitting
+// an MTU, the inverse of ip4defrag.
+//
+// A Fragmenter serializes a stack of layers starting with an IP layer, and
+// splits the packet if it is larger than the MTU:
+//
+//	f := ipfrag.NewFragmenter(1280)
+//	fragments, err := f.Fragment(opts, ip, udp, payload)
+//	if err != nil {
+//		return err
+//	}
+//	for _, data := range fragments {
+//		handle.WritePacketData(data)
+//	}
+//
+// Transport checksums are computed, with ComputeChecksums, over the whole
+// packet before it is split. Fragments always get their lengths fixed, and
+// IPv4 fragments their header checksum computed with ComputeChecksums.
+package ipfrag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Fragmenter splits packets into fragments.
+type Fragmenter struct {
+	// MTU is the size of the largest fragment, IP header included.
+	MTU int
+	// id is the identification of the next IPv6 packet fragmented.
+	id uint32
+}
+
+// NewFragmenter returns a Fragmenter of packets larger than mtu, whose
+// IPv6 fragment identifications start at a random number.
+func NewFragmenter(mtu int) *Fragmenter {
+	return &Fragmenter{MTU: mtu, id: rand.Uint32()}
+}
+
+// Fragment serializes ls, whose first layer is an *layers.IPv4 or
+// *layers.IPv6, and returns the packet, or its fragments if it is larger
+// than the MTU.
+//
+// IPv4 fragments carry the Id of the IPv4 layer, and only the options to
+// be copied into all fragments after the first. Packets with the Don't
+// Fragment flag are not fragmented.
+//
+// IPv6 packets are fragmented after the headers which are processed by
+// the routers on their path: the hop-by-hop options, routing header and
+// destination options preceding a routing header. A Fragment header is
+// added there, with the next identification of f, unless ls has one,
+// whose identification is kept.
+func (f *Fragmenter) Fragment(opts gopacket.SerializeOptions, ls ...gopacket.SerializableLayer) ([][]byte, error) {
+	if len(ls) == 0 {
+		return nil, errors.New("no layers to fragment")
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	switch ls[0].(type) {
+	case *layers.IPv4:
+		return f.fragment4(data, opts)
+	case *layers.IPv6:
+		return f.fragment6(data)
+	}
+	return nil, fmt.Errorf("cannot fragment %v packets", ls[0].LayerType())
+}
+
+// chunks returns the sizes of the fragments of a payload of n bytes, when
+// the headers leave first bytes in the first fragment and rest in the
+// others.
+func chunks(n, first, rest int) ([]int, error) {
+	first, rest = first&^7, rest&^7
+	if first <= 0 || rest <= 0 {
+		return nil, errors.New("MTU too small to fragment")
+	}
+	if n <= first {
+		return []int{n}, nil
+	}
+	sizes := []int{first}
+	for n -= first; n > rest; n -= rest {
+		sizes = append(sizes, rest)
+	}
+	return append(sizes, n), nil
+}
+
+func (f *Fragmenter) fragment4(data []byte, opts gopacket.SerializeOptions) ([][]byte, error) {
+	if len(data) <= f.MTU {
+		return [][]byte{data}, nil
+	}
+	var ip layers.IPv4
+	if err := ip.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		return nil, err
+	}
+	if ip.Flags&layers.IPv4DontFragment != 0 {
+		return nil, fmt.Errorf("packet of %d bytes larger than the MTU, with Don't Fragment set", len(data))
+	}
+	// Fragments after the first only carry the options whose copied flag
+	// is set.
+	later := ip
+	later.Options = nil
+	for _, o := range ip.Options {
+		if o.OptionType&0x80 != 0 {
+			later.Options = append(later.Options, o)
+		}
+	}
+	sizes, err := chunks(len(ip.Payload), f.MTU-len(ip.Contents), f.MTU-20-optionsLength(later.Options))
+	if err != nil {
+		return nil, err
+	}
+
+	opts.FixLengths = true
+	var fragments [][]byte
+	offset := 0
+	for i, size := range sizes {
+		frag := later
+		if i == 0 {
+			frag = ip
+		}
+		frag.FragOffset = ip.FragOffset + uint16(offset/8)
+		if i < len(sizes)-1 {
+			frag.Flags |= layers.IPv4MoreFragments
+		}
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, opts, &frag, gopacket.Payload(ip.Payload[offset:offset+size])); err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, buf.Bytes())
+		offset += size
+	}
+	return fragments, nil
+}
+
+// optionsLength returns the length of options, padding included.
+func optionsLength(options []layers.IPv4Option) int {
+	n := 0
+	for _, o := range options {
+		if o.OptionType <= 1 {
+			n++
+		} else {
+			n += len(o.OptionData) + 2
+		}
+	}
+	return (n + 3) &^ 3
+}
+
+func (f *Fragmenter) fragment6(data []byte) ([][]byte, error) {
+	if len(data) < 40 {
+		return nil, errors.New("IPv6 header too short")
+	}
+	if len(data) <= f.MTU {
+		return [][]byte{data}, nil
+	}
+	// Find the end of the headers processed on the path, split, and the
+	// offset of the next header field preceding it, or the existing
+	// Fragment header.
+	split, nextField, frag := 40, 6, -1
+	next := layers.IPProtocol(data[6])
+walk:
+	for end := 40; frag < 0; {
+		switch next {
+		case layers.IPProtocolIPv6HopByHop, layers.IPProtocolIPv6Routing, layers.IPProtocolIPv6Destination, layers.IPProtocolIPv6Fragment:
+		default:
+			break walk
+		}
+		if end+8 > len(data) {
+			return nil, errors.New("IPv6 extension header truncated")
+		}
+		length := (int(data[end+1]) + 1) * 8
+		switch next {
+		case layers.IPProtocolIPv6Fragment:
+			frag = end
+		case layers.IPProtocolIPv6Destination:
+			if layers.IPProtocol(data[end]) != layers.IPProtocolIPv6Routing {
+				break walk
+			}
+			fallthrough
+		default:
+			split, nextField = end+length, end
+		}
+		next = layers.IPProtocol(data[end])
+		end += length
+		if end > len(data) {
+			return nil, errors.New("IPv6 extension header truncated")
+		}
+	}
+	var header [8]byte
+	var payload []byte
+	base := 0
+	if frag >= 0 {
+		// Fragments of a fragment keep its identification and offset.
+		copy(header[:], data[frag:])
+		base = int(binary.BigEndian.Uint16(header[2:]) &^ 7)
+		split, payload = frag, data[frag+8:]
+	} else {
+		header[0] = data[nextField]
+		binary.BigEndian.PutUint32(header[4:], f.id)
+		f.id++
+		payload = data[split:]
+	}
+	room := f.MTU - split - 8
+	sizes, err := chunks(len(payload), room, room)
+	if err != nil {
+		return nil, err
+	}
+
+	var fragments [][]byte
+	offset := 0
+	for i, size := range sizes {
+		b := make([]byte, split+8+size)
+		copy(b, data[:split])
+		if frag < 0 {
+			b[nextField] = byte(layers.IPProtocolIPv6Fragment)
+		}
+		binary.BigEndian.PutUint16(b[4:], uint16(len(b)-40))
+		copy(b[split:], header[:])
+		flags := binary.BigEndian.Uint16(header[2:]) & 1
+		if i < len(sizes)-1 {
+			flags = 1
+		}
+		binary.BigEndian.PutUint16(b[split+2:], uint16(base+offset)|flags)
+		copy(b[split+8:], payload[offset:offset+size])
+		fragments = append(fragments, b)
+		offset += size
+	}
+	return fragments, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package ipfrag
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var opts = gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+
+func serialize(t *testing.T, ls ...gopacket.SerializableLayer) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func udp4(payload int, options ...layers.IPv4Option) (*layers.IPv4, *layers.UDP, gopacket.Payload) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Id: 0x1234, Protocol: layers.IPProtocolUDP,
+		SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}, Options: options}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 9999}
+	udp.SetNetworkLayerForChecksum(ip)
+	return ip, udp, gopacket.Payload(bytes.Repeat([]byte("0123456789"), payload/10))
+}
+
+func TestFragmentIPv4(t *testing.T) {
+	// A copied option (Security) and one which is not (Record Route).
+	ip, udp, payload := udp4(3000,
+		layers.IPv4Option{OptionType: 0x82, OptionData: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		layers.IPv4Option{OptionType: 0x07, OptionData: []byte{4, 0, 0, 0, 0, 0, 0, 0, 0}})
+	want := serialize(t, ip, udp, payload)
+
+	f := NewFragmenter(1000)
+	fragments, err := f.Fragment(opts, ip, udp, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) != 4 {
+		t.Fatalf("got %d fragments, want 4", len(fragments))
+	}
+	var reassembled []byte
+	for i, data := range fragments {
+		if len(data) > f.MTU {
+			t.Errorf("fragment %d of %d bytes", i, len(data))
+		}
+		p := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+		frag := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+		for _, r := range p.VerifyChecksums(gopacket.ChecksumOptions{}) {
+			if r.LayerType == layers.LayerTypeIPv4 && r.Status != gopacket.ChecksumCorrect {
+				t.Errorf("fragment %d: %+v", i, r)
+			}
+		}
+		if frag.Id != ip.Id || int(frag.FragOffset)*8 != len(reassembled) || (frag.Flags&layers.IPv4MoreFragments != 0) != (i < len(fragments)-1) {
+			t.Errorf("fragment %d: id %#x, offset %d, flags %v", i, frag.Id, frag.FragOffset, frag.Flags)
+		}
+		// The options are followed by an end of options list.
+		if options := len(frag.Options); i == 0 && options != 3 || i > 0 && (options != 2 || frag.Options[0].OptionType != 0x82) {
+			t.Errorf("fragment %d: options %v", i, frag.Options)
+		}
+		reassembled = append(reassembled, frag.Payload...)
+	}
+	if !bytes.Equal(reassembled, want[44:]) {
+		t.Error("reassembled payload differs")
+	}
+
+	// Fragments are fragmented again.
+	again, err := (&Fragmenter{MTU: 500}).Fragment(opts, gopacket.Payload(fragments[1]))
+	if err == nil {
+		t.Errorf("fragmented a Payload into %d", len(again))
+	}
+	p := gopacket.NewPacket(fragments[1], layers.LayerTypeIPv4, gopacket.Default)
+	frag := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if again, err = (&Fragmenter{MTU: 500}).Fragment(opts, frag, gopacket.Payload(frag.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	first := gopacket.NewPacket(again[0], layers.LayerTypeIPv4, gopacket.Default).Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	last := gopacket.NewPacket(again[len(again)-1], layers.LayerTypeIPv4, gopacket.Default).Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if first.FragOffset != frag.FragOffset || first.Flags&layers.IPv4MoreFragments == 0 || last.Flags&layers.IPv4MoreFragments == 0 {
+		t.Errorf("fragment at %d split into %d at %d, last %v", frag.FragOffset, len(again), first.FragOffset, last.Flags)
+	}
+}
+
+func TestFragmentIPv4Errors(t *testing.T) {
+	ip, udp, payload := udp4(100)
+	if fragments, err := NewFragmenter(1500).Fragment(opts, ip, udp, payload); err != nil || len(fragments) != 1 {
+		t.Errorf("small packet fragmented into %d (%v)", len(fragments), err)
+	}
+	if _, err := NewFragmenter(27).Fragment(opts, ip, udp, payload); err == nil {
+		t.Error("fragmented with an MTU too small")
+	}
+	ip.Flags = layers.IPv4DontFragment
+	if _, err := NewFragmenter(100).Fragment(opts, ip, udp, payload); err == nil {
+		t.Error("fragmented with Don't Fragment set")
+	}
+}
+
+func TestFragmentIPv6(t *testing.T) {
+	ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolIPv6HopByHop,
+		SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")}
+	hbh := &layers.IPv6HopByHop{}
+	hbh.NextHeader = layers.IPProtocolUDP
+	hbh.Options = []*layers.IPv6HopByHopOption{{OptionType: 1, OptionData: []byte{0, 0, 0, 0}}}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 9999}
+	udp.SetNetworkLayerForChecksum(ip)
+	payload := gopacket.Payload(bytes.Repeat([]byte("0123456789"), 300))
+	want := serialize(t, ip, hbh, udp, payload)
+
+	f := NewFragmenter(1280)
+	fragments, err := f.Fragment(opts, ip, hbh, udp, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) != 3 {
+		t.Fatalf("got %d fragments, want 3", len(fragments))
+	}
+	var reassembled []byte
+	for i, data := range fragments {
+		if len(data) > f.MTU {
+			t.Errorf("fragment %d of %d bytes", i, len(data))
+		}
+		p := gopacket.NewPacket(data, layers.LayerTypeIPv6, gopacket.Default)
+		if p.Layer(layers.LayerTypeIPv6HopByHop) == nil {
+			t.Fatalf("fragment %d: no hop-by-hop options: %v", i, p)
+		}
+		frag, ok := p.Layer(layers.LayerTypeIPv6Fragment).(*layers.IPv6Fragment)
+		if !ok {
+			t.Fatalf("fragment %d: no Fragment header: %v", i, p)
+		}
+		if frag.NextHeader != layers.IPProtocolUDP || frag.Identification != fragmentID(fragments[0]) ||
+			int(frag.FragmentOffset)*8 != len(reassembled) || frag.MoreFragments != (i < len(fragments)-1) {
+			t.Errorf("fragment %d: %+v", i, frag)
+		}
+		reassembled = append(reassembled, frag.Payload...)
+	}
+	if !bytes.Equal(reassembled, want[48:]) {
+		t.Error("reassembled payload differs")
+	}
+
+	// The next packet gets the next identification.
+	next, err := f.Fragment(opts, ip, hbh, udp, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := fragmentID(next[0]); id != fragmentID(fragments[0])+1 {
+		t.Errorf("got identification %#x after %#x", id, fragmentID(fragments[0]))
+	}
+
+	if _, err := NewFragmenter(56).Fragment(opts, ip, hbh, udp, payload); err == nil {
+		t.Error("fragmented with an MTU too small")
+	}
+}
+
+// fragmentID returns the identification of an IPv6 fragment with
+// hop-by-hop options of 8 bytes.
+func fragmentID(data []byte) uint32 {
+	return uint32(data[52])<<24 | uint32(data[53])<<16 | uint32(data[54])<<8 | uint32(data[55])
+}
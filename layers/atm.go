@@ -0,0 +1,264 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// SunATMTrafficType is the type of traffic carried by an ATM virtual
+// circuit, as recorded in the SunATM pseudo-header.
+type SunATMTrafficType uint8
+
+// SunATM traffic types.
+const (
+	SunATMTrafficTypeRaw  SunATMTrafficType = 0
+	SunATMTrafficTypeLANE SunATMTrafficType = 1
+	SunATMTrafficTypeLLC  SunATMTrafficType = 2
+)
+
+func (t SunATMTrafficType) String() string {
+	switch t {
+	case SunATMTrafficTypeRaw:
+		return "Raw"
+	case SunATMTrafficTypeLANE:
+		return "LANE"
+	case SunATMTrafficTypeLLC:
+		return "LLC"
+	}
+	return "Unknown"
+}
+
+// SunATM is the 4 byte pseudo-header of pcap's LINKTYPE_SUNATM, which is
+// followed by a reassembled AAL5 frame.
+type SunATM struct {
+	BaseLayer
+	// Outbound is set if the frame was sent by the capturing host.
+	Outbound    bool
+	TrafficType SunATMTrafficType
+	VPI         uint8
+	VCI         uint16
+}
+
+// LayerType returns LayerTypeSunATM.
+func (s *SunATM) LayerType() gopacket.LayerType { return LayerTypeSunATM }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *SunATM) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("SunATM header too short")
+	}
+	s.Outbound = data[0]&0x80 != 0
+	s.TrafficType = SunATMTrafficType(data[0] & 0x0f)
+	s.VPI = data[1]
+	s.VCI = binary.BigEndian.Uint16(data[2:4])
+	s.BaseLayer = BaseLayer{Contents: data[:4], Payload: data[4:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (s *SunATM) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(s.TrafficType & 0x0f)
+	if s.Outbound {
+		bytes[0] |= 0x80
+	}
+	bytes[1] = s.VPI
+	binary.BigEndian.PutUint16(bytes[2:], s.VCI)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *SunATM) CanDecode() gopacket.LayerClass {
+	return LayerTypeSunATM
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+// LAN emulation frames are not decoded.
+func (s *SunATM) NextLayerType() gopacket.LayerType {
+	if s.TrafficType == SunATMTrafficTypeLANE {
+		return gopacket.LayerTypePayload
+	}
+	return LayerTypeRFC2684
+}
+
+func decodeSunATM(data []byte, p gopacket.PacketBuilder) error {
+	s := &SunATM{}
+	return decodingLayerDecoder(s, data, p)
+}
+
+// RFC2684Encapsulation is the multiplexing method of protocols over an ATM
+// virtual circuit.
+type RFC2684Encapsulation uint8
+
+// RFC 2684 encapsulations. LLC encapsulation identifies the protocol with an
+// LLC header, followed by an NLPID or a SNAP header. VC multiplexing carries
+// a single protocol per virtual circuit, which is not identified.
+const (
+	RFC2684EncapsulationLLC RFC2684Encapsulation = iota
+	RFC2684EncapsulationVCMux
+)
+
+func (e RFC2684Encapsulation) String() string {
+	switch e {
+	case RFC2684EncapsulationLLC:
+		return "LLC"
+	case RFC2684EncapsulationVCMux:
+		return "VC-Mux"
+	}
+	return "Unknown"
+}
+
+// RFC2684 is the header of a bridged or routed protocol carried in an ATM
+// AAL5 frame (RFC 2684, formerly RFC 1483), as found in pcap's
+// LINKTYPE_ATM_RFC1483 and after SunATM headers.
+//
+// The encapsulation is detected from the frame: LLC headers are recognized,
+// other frames are assumed to be VC multiplexed, routed if they start like
+// an IP packet and bridged Ethernet if they start with the two byte pad.
+type RFC2684 struct {
+	BaseLayer
+	Encapsulation RFC2684Encapsulation
+	// Bridged is set if the frame carries a bridged Ethernet frame or
+	// another bridged protocol.
+	Bridged bool
+	// NLPID identifies the protocol of LLC encapsulated frames. If it is
+	// NLPIDSNAP, OUI and PID do, PID being an EtherType for OUI 0.
+	NLPID NLPID
+	OUI   IEEEOUI
+	PID   uint16
+}
+
+// LayerType returns LayerTypeRFC2684.
+func (r *RFC2684) LayerType() gopacket.LayerType { return LayerTypeRFC2684 }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RFC2684) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("RFC 2684 frame too short")
+	}
+	r.NLPID, r.OUI, r.PID = 0, 0, 0
+	n := 0
+	switch {
+	case data[0] == 0xaa && data[1] == 0xaa:
+		if len(data) < 8 {
+			df.SetTruncated()
+			return errors.New("RFC 2684 LLC/SNAP header too short")
+		}
+		r.Encapsulation = RFC2684EncapsulationLLC
+		r.NLPID = NLPIDSNAP
+		r.OUI = IEEEOUI(uint32(data[3])<<16 | uint32(binary.BigEndian.Uint16(data[4:])))
+		r.PID = binary.BigEndian.Uint16(data[6:8])
+		r.Bridged = r.OUI == IEEEOUI8021
+		n = 8
+		// Bridged Ethernet frames are padded to align their payload.
+		if r.Bridged && (r.PID == BridgedPIDEthernet || r.PID == BridgedPIDEthernetFCS) {
+			n += 2
+		}
+	case data[0] == 0xfe && data[1] == 0xfe:
+		if len(data) < 4 {
+			df.SetTruncated()
+			return errors.New("RFC 2684 LLC/NLPID header too short")
+		}
+		r.Encapsulation = RFC2684EncapsulationLLC
+		r.Bridged = false
+		r.NLPID = NLPID(data[3])
+		n = 4
+	case data[0] == 0 && data[1] == 0:
+		r.Encapsulation = RFC2684EncapsulationVCMux
+		r.Bridged = true
+		n = 2
+	case data[0]>>4 == 4 || data[0]>>4 == 6:
+		r.Encapsulation = RFC2684EncapsulationVCMux
+		r.Bridged = false
+	default:
+		return fmt.Errorf("unknown RFC 2684 encapsulation of frame starting with %#x", data[:2])
+	}
+	if len(data) < n {
+		df.SetTruncated()
+		return errors.New("RFC 2684 header too short")
+	}
+	r.BaseLayer = BaseLayer{Contents: data[:n], Payload: data[n:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (r *RFC2684) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	switch r.Encapsulation {
+	case RFC2684EncapsulationVCMux:
+		if r.Bridged {
+			bytes, err := b.PrependBytes(2)
+			if err != nil {
+				return err
+			}
+			bytes[0], bytes[1] = 0, 0
+		}
+		return nil
+	case RFC2684EncapsulationLLC:
+	default:
+		return fmt.Errorf("invalid RFC 2684 encapsulation %d", r.Encapsulation)
+	}
+	if r.NLPID != NLPIDSNAP {
+		bytes, err := b.PrependBytes(4)
+		if err != nil {
+			return err
+		}
+		copy(bytes, []byte{0xfe, 0xfe, 0x03, uint8(r.NLPID)})
+		return nil
+	}
+	n := 8
+	if r.OUI == IEEEOUI8021 && (r.PID == BridgedPIDEthernet || r.PID == BridgedPIDEthernetFCS) {
+		n += 2
+	}
+	bytes, err := b.PrependBytes(n)
+	if err != nil {
+		return err
+	}
+	copy(bytes, []byte{0xaa, 0xaa, 0x03, uint8(r.OUI >> 16), uint8(r.OUI >> 8), uint8(r.OUI)})
+	binary.BigEndian.PutUint16(bytes[6:], r.PID)
+	copy(bytes[8:], lotsOfZeros[:n-8])
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RFC2684) CanDecode() gopacket.LayerClass {
+	return LayerTypeRFC2684
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (r *RFC2684) NextLayerType() gopacket.LayerType {
+	switch {
+	case r.Encapsulation == RFC2684EncapsulationVCMux && r.Bridged:
+		return LayerTypeEthernet
+	case r.Encapsulation == RFC2684EncapsulationVCMux:
+		if len(r.Payload) > 0 && r.Payload[0]>>4 == 6 {
+			return LayerTypeIPv6
+		}
+		return LayerTypeIPv4
+	case r.NLPID == NLPIDSNAP:
+		return snapLayerType(r.OUI, r.PID)
+	}
+	return r.NLPID.LayerType()
+}
+
+func decodeRFC2684(data []byte, p gopacket.PacketBuilder) error {
+	r := &RFC2684{}
+	return decodingLayerDecoder(r, data, p)
+}
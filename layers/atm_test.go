@@ -0,0 +1,106 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestRFC2684(t *testing.T) {
+	eth := &Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 2}, EthernetType: EthernetTypeIPv4}
+	routed := []gopacket.LayerType{LayerTypeRFC2684, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}
+	bridged := []gopacket.LayerType{LayerTypeRFC2684, LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}
+	for _, test := range []struct {
+		name   string
+		r      *RFC2684
+		ls     []gopacket.SerializableLayer
+		header []byte
+		layers []gopacket.LayerType
+	}{
+		{
+			name:   "LLC routed",
+			r:      &RFC2684{Encapsulation: RFC2684EncapsulationLLC, NLPID: NLPIDSNAP, PID: uint16(EthernetTypeIPv4)},
+			header: []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x08, 0x00},
+			layers: routed,
+		},
+		{
+			name:   "LLC NLPID routed",
+			r:      &RFC2684{Encapsulation: RFC2684EncapsulationLLC, NLPID: NLPIDIPv4},
+			header: []byte{0xfe, 0xfe, 0x03, 0xcc},
+			layers: routed,
+		},
+		{
+			name:   "LLC bridged",
+			r:      &RFC2684{Encapsulation: RFC2684EncapsulationLLC, Bridged: true, NLPID: NLPIDSNAP, OUI: IEEEOUI8021, PID: BridgedPIDEthernet},
+			ls:     []gopacket.SerializableLayer{eth},
+			header: []byte{0xaa, 0xaa, 0x03, 0x00, 0x80, 0xc2, 0x00, 0x07, 0x00, 0x00},
+			layers: bridged,
+		},
+		{
+			name:   "VC-Mux routed",
+			r:      &RFC2684{Encapsulation: RFC2684EncapsulationVCMux},
+			header: []byte{0x45},
+			layers: routed,
+		},
+		{
+			name:   "VC-Mux bridged",
+			r:      &RFC2684{Encapsulation: RFC2684EncapsulationVCMux, Bridged: true},
+			ls:     []gopacket.SerializableLayer{eth},
+			header: []byte{0x00, 0x00, 0x02, 0x00},
+			layers: bridged,
+		},
+	} {
+		data := serializeOverLink(t, append([]gopacket.SerializableLayer{test.r}, test.ls...)...)
+		if !bytes.Equal(data[:len(test.header)], test.header) {
+			t.Errorf("%s: header %x, want %x", test.name, data[:len(test.header)], test.header)
+			continue
+		}
+		p := gopacket.NewPacket(data, LinkTypeATM_RFC1483, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, test.layers, t)
+		got := p.Layer(LayerTypeRFC2684).(*RFC2684)
+		want := *test.r
+		want.BaseLayer = got.BaseLayer
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, want)
+		}
+	}
+
+	if p := gopacket.NewPacket([]byte{0x12, 0x34, 0x56}, LinkTypeATM_RFC1483, gopacket.Default); p.ErrorLayer() == nil {
+		t.Error("no error decoding unknown encapsulation")
+	}
+}
+
+func TestSunATM(t *testing.T) {
+	data := serializeOverLink(t,
+		&SunATM{Outbound: true, TrafficType: SunATMTrafficTypeLLC, VPI: 1, VCI: 0x123},
+		&RFC2684{Encapsulation: RFC2684EncapsulationLLC, NLPID: NLPIDSNAP, PID: uint16(EthernetTypeIPv4)})
+	if !bytes.Equal(data[:4], []byte{0x82, 0x01, 0x01, 0x23}) {
+		t.Fatalf("header %x", data[:4])
+	}
+	p := gopacket.NewPacket(data, LinkTypeSunATM, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSunATM, LayerTypeRFC2684, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	s := p.Layer(LayerTypeSunATM).(*SunATM)
+	if !s.Outbound || s.TrafficType != SunATMTrafficTypeLLC || s.VPI != 1 || s.VCI != 0x123 {
+		t.Errorf("got %+v", s)
+	}
+
+	data[0] = uint8(SunATMTrafficTypeLANE)
+	p = gopacket.NewPacket(data, LinkTypeSunATM, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeSunATM, gopacket.LayerTypePayload}, t)
+}
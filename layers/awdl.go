@@ -0,0 +1,446 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// awdlHeader is the start of the vendor specific action frames of AWDL: the
+// vendor specific category, the OUI of Apple and the AWDL type.
+var awdlHeader = []byte{127, 0x00, 0x17, 0xf2, 0x08}
+
+// awdlHeaderLength is the length of the fixed fields of AWDL action frames,
+// before their TLVs.
+const awdlHeaderLength = 16
+
+// isAWDL tells whether the body of an action frame is an AWDL action frame.
+func isAWDL(data []byte) bool {
+	return len(data) >= len(awdlHeader) && string(data[:len(awdlHeader)]) == string(awdlHeader)
+}
+
+// AWDLSubtype is the subtype of an AWDL action frame.
+type AWDLSubtype uint8
+
+// AWDL action frame subtypes.
+const (
+	AWDLSubtypePSF AWDLSubtype = 0
+	AWDLSubtypeMIF AWDLSubtype = 3
+)
+
+func (s AWDLSubtype) String() string {
+	switch s {
+	case AWDLSubtypePSF:
+		return "Periodic Synchronization Frame"
+	case AWDLSubtypeMIF:
+		return "Master Indication Frame"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// AWDLTLVType is the type of a TLV of an AWDL action frame.
+type AWDLTLVType uint8
+
+// AWDL TLV types.
+const (
+	AWDLTLVTypeSSTHRequest          AWDLTLVType = 0
+	AWDLTLVTypeServiceRequest       AWDLTLVType = 1
+	AWDLTLVTypeServiceResponse      AWDLTLVType = 2
+	AWDLTLVTypeSyncParameters       AWDLTLVType = 4
+	AWDLTLVTypeElectionParameters   AWDLTLVType = 5
+	AWDLTLVTypeServiceParameters    AWDLTLVType = 6
+	AWDLTLVTypeHTCapabilities       AWDLTLVType = 7
+	AWDLTLVTypeEnhancedDataRate     AWDLTLVType = 8
+	AWDLTLVTypeInfra                AWDLTLVType = 9
+	AWDLTLVTypeInvite               AWDLTLVType = 10
+	AWDLTLVTypeDebugString          AWDLTLVType = 11
+	AWDLTLVTypeDataPathState        AWDLTLVType = 12
+	AWDLTLVTypeEncapsulatedIP       AWDLTLVType = 13
+	AWDLTLVTypeDataPathDebug        AWDLTLVType = 14
+	AWDLTLVTypeDataPathDebugAF      AWDLTLVType = 15
+	AWDLTLVTypeArpa                 AWDLTLVType = 16
+	AWDLTLVTypeVHTCapabilities      AWDLTLVType = 17
+	AWDLTLVTypeChannelSequence      AWDLTLVType = 18
+	AWDLTLVTypeSyncTree             AWDLTLVType = 19
+	AWDLTLVTypeVersion              AWDLTLVType = 20
+	AWDLTLVTypeBloomFilter          AWDLTLVType = 21
+	AWDLTLVTypeNANSync              AWDLTLVType = 22
+	AWDLTLVTypeElectionParametersV2 AWDLTLVType = 24
+)
+
+var awdlTLVTypeNames = map[AWDLTLVType]string{
+	AWDLTLVTypeSSTHRequest:          "SSTH Request",
+	AWDLTLVTypeServiceRequest:       "Service Request",
+	AWDLTLVTypeServiceResponse:      "Service Response",
+	AWDLTLVTypeSyncParameters:       "Synchronization Parameters",
+	AWDLTLVTypeElectionParameters:   "Election Parameters",
+	AWDLTLVTypeServiceParameters:    "Service Parameters",
+	AWDLTLVTypeHTCapabilities:       "HT Capabilities",
+	AWDLTLVTypeEnhancedDataRate:     "Enhanced Data Rate Operation",
+	AWDLTLVTypeInfra:                "Infra",
+	AWDLTLVTypeInvite:               "Invite",
+	AWDLTLVTypeDebugString:          "Debug String",
+	AWDLTLVTypeDataPathState:        "Data Path State",
+	AWDLTLVTypeEncapsulatedIP:       "Encapsulated IP",
+	AWDLTLVTypeDataPathDebug:        "Data Path Debug Packet Live",
+	AWDLTLVTypeDataPathDebugAF:      "Data Path Debug AF Live",
+	AWDLTLVTypeArpa:                 "Arpa",
+	AWDLTLVTypeVHTCapabilities:      "VHT Capabilities",
+	AWDLTLVTypeChannelSequence:      "Channel Sequence",
+	AWDLTLVTypeSyncTree:             "Synchronization Tree",
+	AWDLTLVTypeVersion:              "Version",
+	AWDLTLVTypeBloomFilter:          "Bloom Filter",
+	AWDLTLVTypeNANSync:              "NAN Sync",
+	AWDLTLVTypeElectionParametersV2: "Election Parameters v2",
+}
+
+func (t AWDLTLVType) String() string {
+	if name, ok := awdlTLVTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// AWDLTLV is a TLV of an AWDL action frame.
+type AWDLTLV struct {
+	Type  AWDLTLVType
+	Value []byte
+}
+
+// AWDL is an action frame of the Apple Wireless Direct Link, the peer to
+// peer protocol of AirDrop and AirPlay, which follows the Dot11MgmtAction
+// layer of vendor specific action frames of Apple.
+//
+// The TLVs of the frame are kept undecoded; the methods of AWDL decode
+// those which describe the synchronization, the election, the services and
+// the data path of the sender.
+type AWDL struct {
+	BaseLayer
+	// Version is the major version in its high nibble, and the minor
+	// version in its low one.
+	Version      uint8
+	Subtype      AWDLSubtype
+	PHYTxTime    uint32
+	TargetTxTime uint32
+	TLVs         []AWDLTLV
+}
+
+// LayerType returns LayerTypeAWDL.
+func (a *AWDL) LayerType() gopacket.LayerType { return LayerTypeAWDL }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *AWDL) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < awdlHeaderLength {
+		df.SetTruncated()
+		return fmt.Errorf("AWDL length %d too short, %d required", len(data), awdlHeaderLength)
+	}
+	if !isAWDL(data) {
+		return errors.New("AWDL frame is not a vendor specific action frame of Apple")
+	}
+	a.Version = data[5]
+	a.Subtype = AWDLSubtype(data[6])
+	a.PHYTxTime = binary.LittleEndian.Uint32(data[8:])
+	a.TargetTxTime = binary.LittleEndian.Uint32(data[12:])
+	a.TLVs = a.TLVs[:0]
+	for tlvs := data[awdlHeaderLength:]; len(tlvs) > 0; {
+		if len(tlvs) < 3 {
+			df.SetTruncated()
+			return errors.New("AWDL TLV header too short")
+		}
+		length := int(binary.LittleEndian.Uint16(tlvs[1:]))
+		if len(tlvs) < 3+length {
+			df.SetTruncated()
+			return fmt.Errorf("AWDL TLV %v length %d exceeds %d remaining bytes", AWDLTLVType(tlvs[0]), length, len(tlvs)-3)
+		}
+		a.TLVs = append(a.TLVs, AWDLTLV{Type: AWDLTLVType(tlvs[0]), Value: tlvs[3 : 3+length]})
+		tlvs = tlvs[3+length:]
+	}
+	a.BaseLayer = BaseLayer{Contents: data, Payload: nil}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *AWDL) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := awdlHeaderLength
+	for _, tlv := range a.TLVs {
+		if len(tlv.Value) > 0xffff {
+			return fmt.Errorf("AWDL TLV %v length %d too long", tlv.Type, len(tlv.Value))
+		}
+		length += 3 + len(tlv.Value)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	copy(bytes, awdlHeader)
+	bytes[5] = a.Version
+	bytes[6] = uint8(a.Subtype)
+	bytes[7] = 0
+	binary.LittleEndian.PutUint32(bytes[8:], a.PHYTxTime)
+	binary.LittleEndian.PutUint32(bytes[12:], a.TargetTxTime)
+	offset := awdlHeaderLength
+	for _, tlv := range a.TLVs {
+		bytes[offset] = uint8(tlv.Type)
+		binary.LittleEndian.PutUint16(bytes[offset+1:], uint16(len(tlv.Value)))
+		offset += 3 + copy(bytes[offset+3:], tlv.Value)
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *AWDL) CanDecode() gopacket.LayerClass {
+	return LayerTypeAWDL
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (a *AWDL) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+func decodeAWDL(data []byte, p gopacket.PacketBuilder) error {
+	a := &AWDL{}
+	return decodingLayerDecoder(a, data, p)
+}
+
+// TLV returns the value of the first TLV of type t, or nil if the frame has
+// none.
+func (a *AWDL) TLV(t AWDLTLVType) []byte {
+	for _, tlv := range a.TLVs {
+		if tlv.Type == t {
+			return tlv.Value
+		}
+	}
+	return nil
+}
+
+// AWDLChannel is a channel of the channel sequence of an AWDL node. Flags
+// are only set in the legacy encoding, and OperatingClass in the operating
+// class encoding.
+type AWDLChannel struct {
+	Number         uint8
+	Flags          uint8
+	OperatingClass uint8
+}
+
+// AWDLSyncParameters are the synchronization parameters of an AWDL node:
+// the timing of its availability windows and its channel sequence.
+type AWDLSyncParameters struct {
+	NextAWChannel         uint8
+	TxCounter             uint16
+	MasterChannel         uint8
+	GuardTime             uint8
+	AWPeriod              uint16
+	ActionFramePeriod     uint16
+	Flags                 uint16
+	AWExtensionLength     uint16
+	AWCommonLength        uint16
+	RemainingAWLength     uint16
+	MinExtensionCount     uint8
+	MaxMulticastExtension uint8
+	MaxUnicastExtension   uint8
+	MaxAFExtension        uint8
+	MasterAddress         net.HardwareAddr
+	PresenceMode          uint8
+	AWSequenceNumber      uint16
+	APBeaconAlignment     uint16
+	// ChannelEncoding is 0 for channel numbers, 1 for the legacy encoding
+	// with flags and 3 for channel numbers with operating classes.
+	ChannelEncoding uint8
+	DuplicateCount  uint8
+	StepCount       uint8
+	FillChannel     uint16
+	Channels        []AWDLChannel
+}
+
+// SyncParameters decodes the synchronization parameters TLV.
+func (a *AWDL) SyncParameters() (*AWDLSyncParameters, error) {
+	data := a.TLV(AWDLTLVTypeSyncParameters)
+	if data == nil {
+		return nil, errors.New("AWDL frame has no synchronization parameters")
+	}
+	if len(data) < 39 {
+		return nil, fmt.Errorf("AWDL synchronization parameters length %d too short", len(data))
+	}
+	s := &AWDLSyncParameters{
+		NextAWChannel:         data[0],
+		TxCounter:             binary.LittleEndian.Uint16(data[1:]),
+		MasterChannel:         data[3],
+		GuardTime:             data[4],
+		AWPeriod:              binary.LittleEndian.Uint16(data[5:]),
+		ActionFramePeriod:     binary.LittleEndian.Uint16(data[7:]),
+		Flags:                 binary.LittleEndian.Uint16(data[9:]),
+		AWExtensionLength:     binary.LittleEndian.Uint16(data[11:]),
+		AWCommonLength:        binary.LittleEndian.Uint16(data[13:]),
+		RemainingAWLength:     binary.LittleEndian.Uint16(data[15:]),
+		MinExtensionCount:     data[17],
+		MaxMulticastExtension: data[18],
+		MaxUnicastExtension:   data[19],
+		MaxAFExtension:        data[20],
+		MasterAddress:         net.HardwareAddr(data[21:27]),
+		PresenceMode:          data[27],
+		AWSequenceNumber:      binary.LittleEndian.Uint16(data[29:]),
+		APBeaconAlignment:     binary.LittleEndian.Uint16(data[31:]),
+		ChannelEncoding:       data[34],
+		DuplicateCount:        data[35],
+		StepCount:             data[36],
+		FillChannel:           binary.LittleEndian.Uint16(data[37:]),
+	}
+	// The count is that of the channels less one.
+	count := int(data[33]) + 1
+	width := 2
+	if s.ChannelEncoding == 0 {
+		width = 1
+	}
+	channels := data[39:]
+	if len(channels) < count*width {
+		return nil, fmt.Errorf("AWDL channel sequence of %d channels too short", count)
+	}
+	for i := 0; i < count; i++ {
+		c := channels[i*width : (i+1)*width]
+		switch s.ChannelEncoding {
+		case 0:
+			s.Channels = append(s.Channels, AWDLChannel{Number: c[0]})
+		case 1:
+			s.Channels = append(s.Channels, AWDLChannel{Flags: c[0], Number: c[1]})
+		default:
+			s.Channels = append(s.Channels, AWDLChannel{Number: c[0], OperatingClass: c[1]})
+		}
+	}
+	return s, nil
+}
+
+// AWDLElectionParameters are the election parameters of an AWDL node, which
+// elect the master nodes the others synchronize to.
+type AWDLElectionParameters struct {
+	Flags            uint8
+	ID               uint16
+	DistanceToMaster uint8
+	MasterAddress    net.HardwareAddr
+	MasterMetric     uint32
+	SelfMetric       uint32
+}
+
+// ElectionParameters decodes the election parameters TLV.
+func (a *AWDL) ElectionParameters() (*AWDLElectionParameters, error) {
+	data := a.TLV(AWDLTLVTypeElectionParameters)
+	if data == nil {
+		return nil, errors.New("AWDL frame has no election parameters")
+	}
+	if len(data) < 19 {
+		return nil, fmt.Errorf("AWDL election parameters length %d too short", len(data))
+	}
+	return &AWDLElectionParameters{
+		Flags:            data[0],
+		ID:               binary.LittleEndian.Uint16(data[1:]),
+		DistanceToMaster: data[3],
+		MasterAddress:    net.HardwareAddr(data[5:11]),
+		MasterMetric:     binary.LittleEndian.Uint32(data[11:]),
+		SelfMetric:       binary.LittleEndian.Uint32(data[15:]),
+	}, nil
+}
+
+// AWDLServiceParameters are the service parameters of an AWDL node.
+type AWDLServiceParameters struct {
+	// SUI is the service update indicator, which changes with the services
+	// the node advertises.
+	SUI uint16
+	// Values holds the undecoded bitmask and values of the services.
+	Values []byte
+}
+
+// ServiceParameters decodes the service parameters TLV.
+func (a *AWDL) ServiceParameters() (*AWDLServiceParameters, error) {
+	data := a.TLV(AWDLTLVTypeServiceParameters)
+	if data == nil {
+		return nil, errors.New("AWDL frame has no service parameters")
+	}
+	if len(data) < 5 {
+		return nil, fmt.Errorf("AWDL service parameters length %d too short", len(data))
+	}
+	return &AWDLServiceParameters{
+		SUI:    binary.LittleEndian.Uint16(data[3:]),
+		Values: data[5:],
+	}, nil
+}
+
+// AWDL data path state flags, which tell the fields of the data path state.
+const (
+	AWDLDataPathFlagInfraInfo      = 0x0001
+	AWDLDataPathFlagInfraAddress   = 0x0002
+	AWDLDataPathFlagAWDLAddress    = 0x0004
+	AWDLDataPathFlagRSDB           = 0x0008
+	AWDLDataPathFlagUMI            = 0x0010
+	AWDLDataPathFlagDualBand       = 0x0020
+	AWDLDataPathFlagAirPlay        = 0x0040
+	AWDLDataPathFlagCountryCode    = 0x0100
+	AWDLDataPathFlagChannelMap     = 0x0200
+	AWDLDataPathFlagAirPlaySink    = 0x0400
+	AWDLDataPathFlagExtensionFlags = 0x8000
+)
+
+// AWDLDataPathState is the data path state of an AWDL node, which tells
+// its addresses and its infrastructure network. Only the fields Flags tells
+// are set.
+type AWDLDataPathState struct {
+	Flags uint16
+	// CountryCode is the two letter code of the country of the node.
+	CountryCode      string
+	SocialChannelMap uint16
+	InfraBSSID       net.HardwareAddr
+	InfraChannel     uint16
+	InfraAddress     net.HardwareAddr
+	AWDLAddress      net.HardwareAddr
+	UMI              uint16
+	// Extensions holds the undecoded fields following UMI.
+	Extensions []byte
+}
+
+// DataPathState decodes the data path state TLV.
+func (a *AWDL) DataPathState() (*AWDLDataPathState, error) {
+	data := a.TLV(AWDLTLVTypeDataPathState)
+	if data == nil {
+		return nil, errors.New("AWDL frame has no data path state")
+	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("AWDL data path state length %d too short", len(data))
+	}
+	d := &AWDLDataPathState{Flags: binary.LittleEndian.Uint16(data)}
+	data = data[2:]
+	for _, f := range []struct {
+		flag   uint16
+		length int
+		decode func([]byte)
+	}{
+		{AWDLDataPathFlagCountryCode, 3, func(b []byte) { d.CountryCode = string(b[:2]) }},
+		{AWDLDataPathFlagChannelMap, 2, func(b []byte) { d.SocialChannelMap = binary.LittleEndian.Uint16(b) }},
+		{AWDLDataPathFlagInfraInfo, 8, func(b []byte) {
+			d.InfraBSSID = net.HardwareAddr(b[:6])
+			d.InfraChannel = binary.LittleEndian.Uint16(b[6:])
+		}},
+		{AWDLDataPathFlagInfraAddress, 6, func(b []byte) { d.InfraAddress = net.HardwareAddr(b) }},
+		{AWDLDataPathFlagAWDLAddress, 6, func(b []byte) { d.AWDLAddress = net.HardwareAddr(b) }},
+		{AWDLDataPathFlagUMI, 2, func(b []byte) { d.UMI = binary.LittleEndian.Uint16(b) }},
+	} {
+		if d.Flags&f.flag == 0 {
+			continue
+		}
+		if len(data) < f.length {
+			return nil, fmt.Errorf("AWDL data path state flags %#04x exceed its length", d.Flags)
+		}
+		f.decode(data[:f.length])
+		data = data[f.length:]
+	}
+	d.Extensions = data
+	return d, nil
+}
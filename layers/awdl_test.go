@@ -0,0 +1,138 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+var testAWDLSyncParameters = []byte{
+	0x95,       // next AW channel
+	0x10, 0x00, // tx counter
+	0x95,       // master channel
+	0x00,       // guard time
+	0x10, 0x00, // AW period
+	0x6e, 0x00, // action frame period
+	0x00, 0x18, // flags
+	0x10, 0x00, // AW extension length
+	0x10, 0x00, // AW common length
+	0x00, 0x00, // remaining AW length
+	0x03, 0x03, 0x03, 0x03, // extension counts
+	0x02, 0x11, 0x22, 0x33, 0x44, 0x55, // master address
+	0x04, 0x00, // presence mode, reserved
+	0x2a, 0x00, // AW sequence number
+	0x00, 0x00, // AP beacon alignment
+	0x01, 0x03, 0xff, 0x03, 0xff, 0xff, // channel sequence header
+	0x06, 0x51, 0x95, 0x80, // channels
+}
+
+var testAWDLElectionParameters = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x00,
+	0x02, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x3c, 0x02, 0x00, 0x00, 0x3c, 0x02, 0x00, 0x00,
+	0x00, 0x00,
+}
+
+var testAWDLDataPathState = []byte{
+	0x05, 0x03, // flags
+	'U', 'S', 0x00,
+	0x34, 0x12,
+	0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x24, 0x00, // infra BSSID and channel
+	0x02, 0x66, 0x77, 0x88, 0x99, 0xaa, // AWDL address
+	0xde, 0xad,
+}
+
+func TestAWDL(t *testing.T) {
+	awdl := &AWDL{
+		Version:      0x10,
+		Subtype:      AWDLSubtypePSF,
+		PHYTxTime:    1000,
+		TargetTxTime: 990,
+		TLVs: []AWDLTLV{
+			{AWDLTLVTypeSyncParameters, testAWDLSyncParameters},
+			{AWDLTLVTypeElectionParameters, testAWDLElectionParameters},
+			{AWDLTLVTypeServiceParameters, []byte{0x00, 0x00, 0x00, 0x03, 0x00, 0x00}},
+			{AWDLTLVTypeDataPathState, testAWDLDataPathState},
+		},
+	}
+	src := net.HardwareAddr{0x02, 0x66, 0x77, 0x88, 0x99, 0xaa}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true},
+		&Dot11{Type: Dot11TypeMgmtAction, Address1: EthernetBroadcast, Address2: src, Address3: net.HardwareAddr{0x00, 0x25, 0x00, 0xff, 0x94, 0x73},
+			AppendFCS: true},
+		&Dot11MgmtAction{}, awdl); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeDot11, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeDot11, LayerTypeDot11MgmtAction, LayerTypeAWDL}, t)
+	got := p.Layer(LayerTypeAWDL).(*AWDL)
+	if action := p.Layer(LayerTypeDot11MgmtAction).(*Dot11MgmtAction); !bytes.Equal(action.Contents, got.Contents) {
+		t.Errorf("action contents %x, want %x", action.Contents, got.Contents)
+	}
+	if got.Version != 0x10 || got.Subtype != AWDLSubtypePSF || got.PHYTxTime != 1000 || got.TargetTxTime != 990 || len(got.TLVs) != 4 {
+		t.Errorf("got %+v", got)
+	}
+
+	s, err := got.SyncParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NextAWChannel != 149 || s.TxCounter != 16 || s.AWPeriod != 16 || s.ActionFramePeriod != 110 || s.PresenceMode != 4 ||
+		s.MasterAddress.String() != "02:11:22:33:44:55" || s.AWSequenceNumber != 42 || s.ChannelEncoding != 3 {
+		t.Errorf("synchronization parameters %+v", s)
+	}
+	if want := []AWDLChannel{{Number: 6, OperatingClass: 0x51}, {Number: 149, OperatingClass: 0x80}}; len(s.Channels) != 2 || s.Channels[0] != want[0] || s.Channels[1] != want[1] {
+		t.Errorf("channels %+v, want %+v", s.Channels, want)
+	}
+
+	e, err := got.ElectionParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.DistanceToMaster != 1 || e.MasterAddress.String() != "02:11:22:33:44:55" || e.MasterMetric != 572 || e.SelfMetric != 572 {
+		t.Errorf("election parameters %+v", e)
+	}
+
+	sp, err := got.ServiceParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.SUI != 3 || len(sp.Values) != 1 {
+		t.Errorf("service parameters %+v", sp)
+	}
+
+	d, err := got.DataPathState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.CountryCode != "US" || d.SocialChannelMap != 0x1234 || d.InfraBSSID.String() != "0a:0b:0c:0d:0e:0f" || d.InfraChannel != 36 ||
+		d.InfraAddress != nil || !bytes.Equal(d.AWDLAddress, src) || !bytes.Equal(d.Extensions, []byte{0xde, 0xad}) {
+		t.Errorf("data path state %+v", d)
+	}
+
+	if got.TLV(AWDLTLVTypeArpa) != nil {
+		t.Error("got an Arpa TLV")
+	}
+	got.TLVs[3].Value = testAWDLDataPathState[:10]
+	if _, err := got.DataPathState(); err == nil {
+		t.Error("no error decoding truncated data path state")
+	}
+
+	data := buf.Bytes()
+	p = gopacket.NewPacket(data[:len(data)-8], LayerTypeDot11, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("no error decoding truncated TLV")
+	}
+}
@@ -0,0 +1,844 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// BluetoothH4Type is the packet indicator of the HCI UART transport (H4),
+// telling the kind of HCI packet which follows.
+type BluetoothH4Type uint8
+
+// Bluetooth HCI packet indicators.
+const (
+	BluetoothH4TypeCommand BluetoothH4Type = 0x01
+	BluetoothH4TypeACL     BluetoothH4Type = 0x02
+	BluetoothH4TypeSCO     BluetoothH4Type = 0x03
+	BluetoothH4TypeEvent   BluetoothH4Type = 0x04
+	BluetoothH4TypeISO     BluetoothH4Type = 0x05
+)
+
+func (t BluetoothH4Type) String() string {
+	switch t {
+	case BluetoothH4TypeCommand:
+		return "Command"
+	case BluetoothH4TypeACL:
+		return "ACL"
+	case BluetoothH4TypeSCO:
+		return "SCO"
+	case BluetoothH4TypeEvent:
+		return "Event"
+	case BluetoothH4TypeISO:
+		return "ISO"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// BluetoothH4 is the packet indicator preceding the HCI packets of pcap's
+// LINKTYPE_BLUETOOTH_HCI_H4.
+type BluetoothH4 struct {
+	BaseLayer
+	Type BluetoothH4Type
+}
+
+// LayerType returns LayerTypeBluetoothH4.
+func (h *BluetoothH4) LayerType() gopacket.LayerType { return LayerTypeBluetoothH4 }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (h *BluetoothH4) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("BluetoothH4 packet empty")
+	}
+	h.Type = BluetoothH4Type(data[0])
+	h.BaseLayer = BaseLayer{Contents: data[:1], Payload: data[1:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (h *BluetoothH4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(1)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(h.Type)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (h *BluetoothH4) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothH4
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (h *BluetoothH4) NextLayerType() gopacket.LayerType {
+	switch h.Type {
+	case BluetoothH4TypeCommand:
+		return LayerTypeBluetoothHCICommand
+	case BluetoothH4TypeACL:
+		return LayerTypeBluetoothHCIACL
+	case BluetoothH4TypeEvent:
+		return LayerTypeBluetoothHCIEvent
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeBluetoothH4(data []byte, p gopacket.PacketBuilder) error {
+	h := &BluetoothH4{}
+	return decodingLayerDecoder(h, data, p)
+}
+
+// BluetoothPHDR is the direction pseudo-header of pcap's
+// LINKTYPE_BLUETOOTH_HCI_H4_WITH_PHDR, which precedes the packet indicator.
+type BluetoothPHDR struct {
+	BaseLayer
+	// Received is set for packets from the controller to the host, and
+	// unset for packets the host sent.
+	Received bool
+}
+
+// LayerType returns LayerTypeBluetoothPHDR.
+func (h *BluetoothPHDR) LayerType() gopacket.LayerType { return LayerTypeBluetoothPHDR }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (h *BluetoothPHDR) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("BluetoothPHDR header too short")
+	}
+	h.Received = binary.BigEndian.Uint32(data) != 0
+	h.BaseLayer = BaseLayer{Contents: data[:4], Payload: data[4:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (h *BluetoothPHDR) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	var direction uint32
+	if h.Received {
+		direction = 1
+	}
+	binary.BigEndian.PutUint32(bytes, direction)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (h *BluetoothPHDR) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothPHDR
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (h *BluetoothPHDR) NextLayerType() gopacket.LayerType {
+	return LayerTypeBluetoothH4
+}
+
+func decodeBluetoothPHDR(data []byte, p gopacket.PacketBuilder) error {
+	h := &BluetoothPHDR{}
+	return decodingLayerDecoder(h, data, p)
+}
+
+// BluetoothHCIOpcode is the opcode of an HCI command: its 6 bit opcode
+// group field (OGF) followed by its 10 bit opcode command field (OCF).
+type BluetoothHCIOpcode uint16
+
+// Some Bluetooth HCI command opcodes.
+const (
+	BluetoothHCIOpcodeInquiry                    BluetoothHCIOpcode = 0x0401
+	BluetoothHCIOpcodeCreateConnection           BluetoothHCIOpcode = 0x0405
+	BluetoothHCIOpcodeDisconnect                 BluetoothHCIOpcode = 0x0406
+	BluetoothHCIOpcodeReset                      BluetoothHCIOpcode = 0x0c03
+	BluetoothHCIOpcodeWriteLocalName             BluetoothHCIOpcode = 0x0c13
+	BluetoothHCIOpcodeReadBDAddr                 BluetoothHCIOpcode = 0x1009
+	BluetoothHCIOpcodeLESetAdvertisingParams     BluetoothHCIOpcode = 0x2006
+	BluetoothHCIOpcodeLESetAdvertisingData       BluetoothHCIOpcode = 0x2008
+	BluetoothHCIOpcodeLESetAdvertiseEnable       BluetoothHCIOpcode = 0x200a
+	BluetoothHCIOpcodeLESetScanParameters        BluetoothHCIOpcode = 0x200b
+	BluetoothHCIOpcodeLESetScanEnable            BluetoothHCIOpcode = 0x200c
+	BluetoothHCIOpcodeLECreateConnection         BluetoothHCIOpcode = 0x200d
+	BluetoothHCIOpcodeLEStartEncryption          BluetoothHCIOpcode = 0x2019
+	BluetoothHCIOpcodeLESetExtendedScanEnable    BluetoothHCIOpcode = 0x2042
+	BluetoothHCIOpcodeLEExtendedCreateConnection BluetoothHCIOpcode = 0x2043
+)
+
+// OGF returns the opcode group field: 1 for link control, 3 for controller
+// and baseband, 4 for informational parameters and 8 for LE commands.
+func (o BluetoothHCIOpcode) OGF() uint8 { return uint8(o >> 10) }
+
+// OCF returns the opcode command field.
+func (o BluetoothHCIOpcode) OCF() uint16 { return uint16(o & 0x3ff) }
+
+func (o BluetoothHCIOpcode) String() string {
+	switch o {
+	case BluetoothHCIOpcodeInquiry:
+		return "Inquiry"
+	case BluetoothHCIOpcodeCreateConnection:
+		return "Create Connection"
+	case BluetoothHCIOpcodeDisconnect:
+		return "Disconnect"
+	case BluetoothHCIOpcodeReset:
+		return "Reset"
+	case BluetoothHCIOpcodeWriteLocalName:
+		return "Write Local Name"
+	case BluetoothHCIOpcodeReadBDAddr:
+		return "Read BD_ADDR"
+	case BluetoothHCIOpcodeLESetAdvertisingParams:
+		return "LE Set Advertising Parameters"
+	case BluetoothHCIOpcodeLESetAdvertisingData:
+		return "LE Set Advertising Data"
+	case BluetoothHCIOpcodeLESetAdvertiseEnable:
+		return "LE Set Advertise Enable"
+	case BluetoothHCIOpcodeLESetScanParameters:
+		return "LE Set Scan Parameters"
+	case BluetoothHCIOpcodeLESetScanEnable:
+		return "LE Set Scan Enable"
+	case BluetoothHCIOpcodeLECreateConnection:
+		return "LE Create Connection"
+	case BluetoothHCIOpcodeLEStartEncryption:
+		return "LE Start Encryption"
+	case BluetoothHCIOpcodeLESetExtendedScanEnable:
+		return "LE Set Extended Scan Enable"
+	case BluetoothHCIOpcodeLEExtendedCreateConnection:
+		return "LE Extended Create Connection"
+	}
+	return fmt.Sprintf("OGF 0x%02x OCF 0x%03x", o.OGF(), o.OCF())
+}
+
+// BluetoothHCICommand is an HCI command packet, sent by the host to the
+// controller.
+type BluetoothHCICommand struct {
+	BaseLayer
+	Opcode          BluetoothHCIOpcode
+	ParameterLength uint8
+	Parameters      []byte
+}
+
+// LayerType returns LayerTypeBluetoothHCICommand.
+func (c *BluetoothHCICommand) LayerType() gopacket.LayerType {
+	return LayerTypeBluetoothHCICommand
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *BluetoothHCICommand) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 3 {
+		df.SetTruncated()
+		return errors.New("BluetoothHCICommand header too short")
+	}
+	c.Opcode = BluetoothHCIOpcode(binary.LittleEndian.Uint16(data))
+	c.ParameterLength = data[2]
+	end := 3 + int(c.ParameterLength)
+	if len(data) < end {
+		df.SetTruncated()
+		return fmt.Errorf("BluetoothHCICommand parameters length %d exceeds %d remaining bytes", c.ParameterLength, len(data)-3)
+	}
+	c.Parameters = data[3:end]
+	c.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *BluetoothHCICommand) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(c.Parameters) > 0xff {
+		return fmt.Errorf("BluetoothHCICommand parameters length %d too long", len(c.Parameters))
+	}
+	bytes, err := b.PrependBytes(3 + len(c.Parameters))
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		c.ParameterLength = uint8(len(c.Parameters))
+	}
+	binary.LittleEndian.PutUint16(bytes, uint16(c.Opcode))
+	bytes[2] = c.ParameterLength
+	copy(bytes[3:], c.Parameters)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *BluetoothHCICommand) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothHCICommand
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *BluetoothHCICommand) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+func decodeBluetoothHCICommand(data []byte, p gopacket.PacketBuilder) error {
+	c := &BluetoothHCICommand{}
+	return decodingLayerDecoder(c, data, p)
+}
+
+// BluetoothHCIEventCode is the code of an HCI event.
+type BluetoothHCIEventCode uint8
+
+// Some Bluetooth HCI event codes.
+const (
+	BluetoothHCIEventCodeInquiryComplete              BluetoothHCIEventCode = 0x01
+	BluetoothHCIEventCodeConnectionComplete           BluetoothHCIEventCode = 0x03
+	BluetoothHCIEventCodeConnectionRequest            BluetoothHCIEventCode = 0x04
+	BluetoothHCIEventCodeDisconnectionComplete        BluetoothHCIEventCode = 0x05
+	BluetoothHCIEventCodeEncryptionChange             BluetoothHCIEventCode = 0x08
+	BluetoothHCIEventCodeCommandComplete              BluetoothHCIEventCode = 0x0e
+	BluetoothHCIEventCodeCommandStatus                BluetoothHCIEventCode = 0x0f
+	BluetoothHCIEventCodeHardwareError                BluetoothHCIEventCode = 0x10
+	BluetoothHCIEventCodeNumberOfCompletedPackets     BluetoothHCIEventCode = 0x13
+	BluetoothHCIEventCodeEncryptionKeyRefreshComplete BluetoothHCIEventCode = 0x30
+	BluetoothHCIEventCodeLEMeta                       BluetoothHCIEventCode = 0x3e
+	BluetoothHCIEventCodeVendor                       BluetoothHCIEventCode = 0xff
+)
+
+func (e BluetoothHCIEventCode) String() string {
+	switch e {
+	case BluetoothHCIEventCodeInquiryComplete:
+		return "Inquiry Complete"
+	case BluetoothHCIEventCodeConnectionComplete:
+		return "Connection Complete"
+	case BluetoothHCIEventCodeConnectionRequest:
+		return "Connection Request"
+	case BluetoothHCIEventCodeDisconnectionComplete:
+		return "Disconnection Complete"
+	case BluetoothHCIEventCodeEncryptionChange:
+		return "Encryption Change"
+	case BluetoothHCIEventCodeCommandComplete:
+		return "Command Complete"
+	case BluetoothHCIEventCodeCommandStatus:
+		return "Command Status"
+	case BluetoothHCIEventCodeHardwareError:
+		return "Hardware Error"
+	case BluetoothHCIEventCodeNumberOfCompletedPackets:
+		return "Number of Completed Packets"
+	case BluetoothHCIEventCodeEncryptionKeyRefreshComplete:
+		return "Encryption Key Refresh Complete"
+	case BluetoothHCIEventCodeLEMeta:
+		return "LE Meta"
+	case BluetoothHCIEventCodeVendor:
+		return "Vendor"
+	}
+	return fmt.Sprintf("Unknown(0x%02x)", uint8(e))
+}
+
+// BluetoothHCIEvent is an HCI event packet, sent by the controller to the
+// host.
+type BluetoothHCIEvent struct {
+	BaseLayer
+	EventCode       BluetoothHCIEventCode
+	ParameterLength uint8
+	Parameters      []byte
+}
+
+// LayerType returns LayerTypeBluetoothHCIEvent.
+func (e *BluetoothHCIEvent) LayerType() gopacket.LayerType { return LayerTypeBluetoothHCIEvent }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (e *BluetoothHCIEvent) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("BluetoothHCIEvent header too short")
+	}
+	e.EventCode = BluetoothHCIEventCode(data[0])
+	e.ParameterLength = data[1]
+	end := 2 + int(e.ParameterLength)
+	if len(data) < end {
+		df.SetTruncated()
+		return fmt.Errorf("BluetoothHCIEvent parameters length %d exceeds %d remaining bytes", e.ParameterLength, len(data)-2)
+	}
+	e.Parameters = data[2:end]
+	e.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (e *BluetoothHCIEvent) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(e.Parameters) > 0xff {
+		return fmt.Errorf("BluetoothHCIEvent parameters length %d too long", len(e.Parameters))
+	}
+	bytes, err := b.PrependBytes(2 + len(e.Parameters))
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		e.ParameterLength = uint8(len(e.Parameters))
+	}
+	bytes[0] = uint8(e.EventCode)
+	bytes[1] = e.ParameterLength
+	copy(bytes[2:], e.Parameters)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (e *BluetoothHCIEvent) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothHCIEvent
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (e *BluetoothHCIEvent) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// CommandOpcode returns the opcode of the command a Command Complete or
+// Command Status event answers.
+func (e *BluetoothHCIEvent) CommandOpcode() (BluetoothHCIOpcode, bool) {
+	switch {
+	case e.EventCode == BluetoothHCIEventCodeCommandComplete && len(e.Parameters) >= 3:
+		return BluetoothHCIOpcode(binary.LittleEndian.Uint16(e.Parameters[1:])), true
+	case e.EventCode == BluetoothHCIEventCodeCommandStatus && len(e.Parameters) >= 4:
+		return BluetoothHCIOpcode(binary.LittleEndian.Uint16(e.Parameters[2:])), true
+	}
+	return 0, false
+}
+
+// Status returns the status of the events which start with one, such as
+// Command Status or Disconnection Complete, or, for Command Complete, the
+// first return parameter, which is the status of most commands. Zero means
+// success.
+func (e *BluetoothHCIEvent) Status() (uint8, bool) {
+	switch e.EventCode {
+	case BluetoothHCIEventCodeCommandComplete:
+		if len(e.Parameters) >= 4 {
+			return e.Parameters[3], true
+		}
+	case BluetoothHCIEventCodeInquiryComplete, BluetoothHCIEventCodeConnectionComplete,
+		BluetoothHCIEventCodeDisconnectionComplete, BluetoothHCIEventCodeEncryptionChange,
+		BluetoothHCIEventCodeCommandStatus, BluetoothHCIEventCodeEncryptionKeyRefreshComplete:
+		if len(e.Parameters) >= 1 {
+			return e.Parameters[0], true
+		}
+	}
+	return 0, false
+}
+
+// LESubeventCode returns the subevent code of an LE Meta event, such as 2
+// for LE Advertising Report.
+func (e *BluetoothHCIEvent) LESubeventCode() (uint8, bool) {
+	if e.EventCode == BluetoothHCIEventCodeLEMeta && len(e.Parameters) >= 1 {
+		return e.Parameters[0], true
+	}
+	return 0, false
+}
+
+func decodeBluetoothHCIEvent(data []byte, p gopacket.PacketBuilder) error {
+	e := &BluetoothHCIEvent{}
+	return decodingLayerDecoder(e, data, p)
+}
+
+// BluetoothACLPacketBoundary tells whether an ACL packet starts or continues
+// a higher layer message.
+type BluetoothACLPacketBoundary uint8
+
+// Bluetooth ACL packet boundary flags.
+const (
+	BluetoothACLPacketBoundaryFirstNonFlushable BluetoothACLPacketBoundary = 0
+	BluetoothACLPacketBoundaryContinuing        BluetoothACLPacketBoundary = 1
+	BluetoothACLPacketBoundaryFirstFlushable    BluetoothACLPacketBoundary = 2
+	BluetoothACLPacketBoundaryComplete          BluetoothACLPacketBoundary = 3
+)
+
+// BluetoothHCIACL is an HCI ACL data packet, carrying L2CAP frames, in
+// fragments if they are larger than the buffers of the controller.
+type BluetoothHCIACL struct {
+	BaseLayer
+	// Handle is the 12 bit connection handle.
+	Handle         uint16
+	PacketBoundary BluetoothACLPacketBoundary
+	Broadcast      uint8
+	DataLength     uint16
+}
+
+// LayerType returns LayerTypeBluetoothHCIACL.
+func (a *BluetoothHCIACL) LayerType() gopacket.LayerType { return LayerTypeBluetoothHCIACL }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *BluetoothHCIACL) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("BluetoothHCIACL header too short")
+	}
+	h := binary.LittleEndian.Uint16(data)
+	a.Handle = h & 0x0fff
+	a.PacketBoundary = BluetoothACLPacketBoundary(h >> 12 & 0x3)
+	a.Broadcast = uint8(h >> 14)
+	a.DataLength = binary.LittleEndian.Uint16(data[2:])
+	end := 4 + int(a.DataLength)
+	if len(data) < end {
+		df.SetTruncated()
+		return fmt.Errorf("BluetoothHCIACL data length %d exceeds %d remaining bytes", a.DataLength, len(data)-4)
+	}
+	a.BaseLayer = BaseLayer{Contents: data[:4], Payload: data[4:end]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *BluetoothHCIACL) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if a.Handle > 0x0fff {
+		return fmt.Errorf("BluetoothHCIACL handle %#x too large", a.Handle)
+	}
+	length := len(b.Bytes())
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		if length > 0xffff {
+			return fmt.Errorf("BluetoothHCIACL data length %d too long", length)
+		}
+		a.DataLength = uint16(length)
+	}
+	binary.LittleEndian.PutUint16(bytes, a.Handle|uint16(a.PacketBoundary&0x3)<<12|uint16(a.Broadcast&0x3)<<14)
+	binary.LittleEndian.PutUint16(bytes[2:], a.DataLength)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *BluetoothHCIACL) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothHCIACL
+}
+
+// NextLayerType returns LayerTypeBluetoothL2CAP for packets starting an
+// L2CAP frame, and LayerTypeFragment for those continuing one.
+func (a *BluetoothHCIACL) NextLayerType() gopacket.LayerType {
+	switch a.PacketBoundary {
+	case BluetoothACLPacketBoundaryFirstNonFlushable, BluetoothACLPacketBoundaryFirstFlushable:
+		return LayerTypeBluetoothL2CAP
+	case BluetoothACLPacketBoundaryContinuing:
+		return gopacket.LayerTypeFragment
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeBluetoothHCIACL(data []byte, p gopacket.PacketBuilder) error {
+	a := &BluetoothHCIACL{}
+	return decodingLayerDecoder(a, data, p)
+}
+
+// BluetoothL2CAPChannel is the channel identifier (CID) of an L2CAP frame.
+// The identifiers below 0x0040 are fixed channels; the others are allocated
+// dynamically.
+type BluetoothL2CAPChannel uint16
+
+// Bluetooth L2CAP fixed channels.
+const (
+	BluetoothL2CAPChannelSignaling      BluetoothL2CAPChannel = 0x0001
+	BluetoothL2CAPChannelConnectionless BluetoothL2CAPChannel = 0x0002
+	BluetoothL2CAPChannelATT            BluetoothL2CAPChannel = 0x0004
+	BluetoothL2CAPChannelLESignaling    BluetoothL2CAPChannel = 0x0005
+	BluetoothL2CAPChannelSMP            BluetoothL2CAPChannel = 0x0006
+	BluetoothL2CAPChannelBREDRSMP       BluetoothL2CAPChannel = 0x0007
+)
+
+func (c BluetoothL2CAPChannel) String() string {
+	switch c {
+	case BluetoothL2CAPChannelSignaling:
+		return "Signaling"
+	case BluetoothL2CAPChannelConnectionless:
+		return "Connectionless"
+	case BluetoothL2CAPChannelATT:
+		return "ATT"
+	case BluetoothL2CAPChannelLESignaling:
+		return "LE Signaling"
+	case BluetoothL2CAPChannelSMP:
+		return "SMP"
+	case BluetoothL2CAPChannelBREDRSMP:
+		return "BR/EDR SMP"
+	}
+	return fmt.Sprintf("0x%04x", uint16(c))
+}
+
+// BluetoothL2CAP is the basic header of an L2CAP frame. The frame may span
+// several ACL packets, in which case the payload only holds its first
+// fragment.
+type BluetoothL2CAP struct {
+	BaseLayer
+	Length    uint16
+	ChannelID BluetoothL2CAPChannel
+}
+
+// LayerType returns LayerTypeBluetoothL2CAP.
+func (l *BluetoothL2CAP) LayerType() gopacket.LayerType { return LayerTypeBluetoothL2CAP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (l *BluetoothL2CAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("BluetoothL2CAP header too short")
+	}
+	l.Length = binary.LittleEndian.Uint16(data)
+	l.ChannelID = BluetoothL2CAPChannel(binary.LittleEndian.Uint16(data[2:]))
+	payload := data[4:]
+	if len(payload) > int(l.Length) {
+		payload = payload[:l.Length]
+	}
+	l.BaseLayer = BaseLayer{Contents: data[:4], Payload: payload}
+	return nil
+}
+
+// Fragmented tells whether the payload holds only the first fragment of the
+// frame, the rest of which follows in other ACL packets.
+func (l *BluetoothL2CAP) Fragmented() bool {
+	return len(l.Payload) < int(l.Length)
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (l *BluetoothL2CAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := len(b.Bytes())
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		if length > 0xffff {
+			return fmt.Errorf("BluetoothL2CAP length %d too long", length)
+		}
+		l.Length = uint16(length)
+	}
+	binary.LittleEndian.PutUint16(bytes, l.Length)
+	binary.LittleEndian.PutUint16(bytes[2:], uint16(l.ChannelID))
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (l *BluetoothL2CAP) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothL2CAP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (l *BluetoothL2CAP) NextLayerType() gopacket.LayerType {
+	if l.Fragmented() {
+		return gopacket.LayerTypeFragment
+	}
+	if l.ChannelID == BluetoothL2CAPChannelATT {
+		return LayerTypeBluetoothATT
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeBluetoothL2CAP(data []byte, p gopacket.PacketBuilder) error {
+	l := &BluetoothL2CAP{}
+	return decodingLayerDecoder(l, data, p)
+}
+
+// BluetoothATTOpcode is the opcode of an attribute protocol PDU: its method
+// in the low 6 bits, a command flag and an authentication signature flag.
+type BluetoothATTOpcode uint8
+
+// Bluetooth ATT opcodes.
+const (
+	BluetoothATTOpcodeErrorRsp                BluetoothATTOpcode = 0x01
+	BluetoothATTOpcodeExchangeMTUReq          BluetoothATTOpcode = 0x02
+	BluetoothATTOpcodeExchangeMTURsp          BluetoothATTOpcode = 0x03
+	BluetoothATTOpcodeFindInformationReq      BluetoothATTOpcode = 0x04
+	BluetoothATTOpcodeFindInformationRsp      BluetoothATTOpcode = 0x05
+	BluetoothATTOpcodeFindByTypeValueReq      BluetoothATTOpcode = 0x06
+	BluetoothATTOpcodeFindByTypeValueRsp      BluetoothATTOpcode = 0x07
+	BluetoothATTOpcodeReadByTypeReq           BluetoothATTOpcode = 0x08
+	BluetoothATTOpcodeReadByTypeRsp           BluetoothATTOpcode = 0x09
+	BluetoothATTOpcodeReadReq                 BluetoothATTOpcode = 0x0a
+	BluetoothATTOpcodeReadRsp                 BluetoothATTOpcode = 0x0b
+	BluetoothATTOpcodeReadBlobReq             BluetoothATTOpcode = 0x0c
+	BluetoothATTOpcodeReadBlobRsp             BluetoothATTOpcode = 0x0d
+	BluetoothATTOpcodeReadMultipleReq         BluetoothATTOpcode = 0x0e
+	BluetoothATTOpcodeReadMultipleRsp         BluetoothATTOpcode = 0x0f
+	BluetoothATTOpcodeReadByGroupTypeReq      BluetoothATTOpcode = 0x10
+	BluetoothATTOpcodeReadByGroupTypeRsp      BluetoothATTOpcode = 0x11
+	BluetoothATTOpcodeWriteReq                BluetoothATTOpcode = 0x12
+	BluetoothATTOpcodeWriteRsp                BluetoothATTOpcode = 0x13
+	BluetoothATTOpcodePrepareWriteReq         BluetoothATTOpcode = 0x16
+	BluetoothATTOpcodePrepareWriteRsp         BluetoothATTOpcode = 0x17
+	BluetoothATTOpcodeExecuteWriteReq         BluetoothATTOpcode = 0x18
+	BluetoothATTOpcodeExecuteWriteRsp         BluetoothATTOpcode = 0x19
+	BluetoothATTOpcodeHandleValueNtf          BluetoothATTOpcode = 0x1b
+	BluetoothATTOpcodeHandleValueInd          BluetoothATTOpcode = 0x1d
+	BluetoothATTOpcodeHandleValueCfm          BluetoothATTOpcode = 0x1e
+	BluetoothATTOpcodeReadMultipleVariableReq BluetoothATTOpcode = 0x20
+	BluetoothATTOpcodeReadMultipleVariableRsp BluetoothATTOpcode = 0x21
+	BluetoothATTOpcodeMultipleHandleValueNtf  BluetoothATTOpcode = 0x23
+	BluetoothATTOpcodeWriteCmd                BluetoothATTOpcode = 0x52
+	BluetoothATTOpcodeSignedWriteCmd          BluetoothATTOpcode = 0xd2
+)
+
+var bluetoothATTOpcodeNames = map[BluetoothATTOpcode]string{
+	BluetoothATTOpcodeErrorRsp:                "Error Response",
+	BluetoothATTOpcodeExchangeMTUReq:          "Exchange MTU Request",
+	BluetoothATTOpcodeExchangeMTURsp:          "Exchange MTU Response",
+	BluetoothATTOpcodeFindInformationReq:      "Find Information Request",
+	BluetoothATTOpcodeFindInformationRsp:      "Find Information Response",
+	BluetoothATTOpcodeFindByTypeValueReq:      "Find By Type Value Request",
+	BluetoothATTOpcodeFindByTypeValueRsp:      "Find By Type Value Response",
+	BluetoothATTOpcodeReadByTypeReq:           "Read By Type Request",
+	BluetoothATTOpcodeReadByTypeRsp:           "Read By Type Response",
+	BluetoothATTOpcodeReadReq:                 "Read Request",
+	BluetoothATTOpcodeReadRsp:                 "Read Response",
+	BluetoothATTOpcodeReadBlobReq:             "Read Blob Request",
+	BluetoothATTOpcodeReadBlobRsp:             "Read Blob Response",
+	BluetoothATTOpcodeReadMultipleReq:         "Read Multiple Request",
+	BluetoothATTOpcodeReadMultipleRsp:         "Read Multiple Response",
+	BluetoothATTOpcodeReadByGroupTypeReq:      "Read By Group Type Request",
+	BluetoothATTOpcodeReadByGroupTypeRsp:      "Read By Group Type Response",
+	BluetoothATTOpcodeWriteReq:                "Write Request",
+	BluetoothATTOpcodeWriteRsp:                "Write Response",
+	BluetoothATTOpcodePrepareWriteReq:         "Prepare Write Request",
+	BluetoothATTOpcodePrepareWriteRsp:         "Prepare Write Response",
+	BluetoothATTOpcodeExecuteWriteReq:         "Execute Write Request",
+	BluetoothATTOpcodeExecuteWriteRsp:         "Execute Write Response",
+	BluetoothATTOpcodeHandleValueNtf:          "Handle Value Notification",
+	BluetoothATTOpcodeHandleValueInd:          "Handle Value Indication",
+	BluetoothATTOpcodeHandleValueCfm:          "Handle Value Confirmation",
+	BluetoothATTOpcodeReadMultipleVariableReq: "Read Multiple Variable Request",
+	BluetoothATTOpcodeReadMultipleVariableRsp: "Read Multiple Variable Response",
+	BluetoothATTOpcodeMultipleHandleValueNtf:  "Multiple Handle Value Notification",
+	BluetoothATTOpcodeWriteCmd:                "Write Command",
+	BluetoothATTOpcodeSignedWriteCmd:          "Signed Write Command",
+}
+
+func (o BluetoothATTOpcode) String() string {
+	if name, ok := bluetoothATTOpcodeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(0x%02x)", uint8(o))
+}
+
+// Method returns the method of the opcode, without its flags.
+func (o BluetoothATTOpcode) Method() uint8 { return uint8(o & 0x3f) }
+
+// Command tells whether the PDU is a command, which has no response.
+func (o BluetoothATTOpcode) Command() bool { return o&0x40 != 0 }
+
+// Signed tells whether the PDU ends with a 12 byte authentication
+// signature.
+func (o BluetoothATTOpcode) Signed() bool { return o&0x80 != 0 }
+
+// hasHandle tells whether the parameters of PDUs with the opcode start with
+// an attribute handle.
+func (o BluetoothATTOpcode) hasHandle() bool {
+	switch o {
+	case BluetoothATTOpcodeReadReq, BluetoothATTOpcodeReadBlobReq, BluetoothATTOpcodeWriteReq,
+		BluetoothATTOpcodePrepareWriteReq, BluetoothATTOpcodePrepareWriteRsp,
+		BluetoothATTOpcodeHandleValueNtf, BluetoothATTOpcodeHandleValueInd,
+		BluetoothATTOpcodeWriteCmd, BluetoothATTOpcodeSignedWriteCmd:
+		return true
+	}
+	return false
+}
+
+// BluetoothATT is an attribute protocol PDU, the transport of GATT.
+type BluetoothATT struct {
+	BaseLayer
+	Opcode BluetoothATTOpcode
+	// Parameters holds the parameters following the opcode.
+	Parameters []byte
+	// Handle is the attribute handle of the PDUs which carry one, as
+	// reads, writes, notifications and indications, or the handle in
+	// error of error responses.
+	Handle uint16
+	// Value is the attribute value of writes, notifications, indications
+	// and read responses, without the authentication signature of signed
+	// writes.
+	Value []byte
+	// RequestOpcode and ErrorCode are set for error responses.
+	RequestOpcode BluetoothATTOpcode
+	ErrorCode     uint8
+}
+
+// LayerType returns LayerTypeBluetoothATT.
+func (a *BluetoothATT) LayerType() gopacket.LayerType { return LayerTypeBluetoothATT }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *BluetoothATT) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("BluetoothATT PDU empty")
+	}
+	a.Opcode = BluetoothATTOpcode(data[0])
+	a.Parameters = data[1:]
+	a.Handle, a.Value, a.RequestOpcode, a.ErrorCode = 0, nil, 0, 0
+	switch {
+	case a.Opcode == BluetoothATTOpcodeErrorRsp:
+		if len(a.Parameters) < 4 {
+			df.SetTruncated()
+			return errors.New("BluetoothATT error response too short")
+		}
+		a.RequestOpcode = BluetoothATTOpcode(a.Parameters[0])
+		a.Handle = binary.LittleEndian.Uint16(a.Parameters[1:])
+		a.ErrorCode = a.Parameters[3]
+	case a.Opcode.hasHandle():
+		if len(a.Parameters) < 2 {
+			df.SetTruncated()
+			return fmt.Errorf("BluetoothATT %v too short", a.Opcode)
+		}
+		a.Handle = binary.LittleEndian.Uint16(a.Parameters)
+		value := a.Parameters[2:]
+		switch a.Opcode {
+		case BluetoothATTOpcodeReadReq, BluetoothATTOpcodeReadBlobReq:
+			value = nil
+		case BluetoothATTOpcodePrepareWriteReq, BluetoothATTOpcodePrepareWriteRsp:
+			// The value offset precedes the value.
+			if len(value) < 2 {
+				df.SetTruncated()
+				return fmt.Errorf("BluetoothATT %v too short", a.Opcode)
+			}
+			value = value[2:]
+		case BluetoothATTOpcodeSignedWriteCmd:
+			if len(value) < 12 {
+				df.SetTruncated()
+				return fmt.Errorf("BluetoothATT %v too short", a.Opcode)
+			}
+			value = value[:len(value)-12]
+		}
+		a.Value = value
+	case a.Opcode == BluetoothATTOpcodeReadRsp || a.Opcode == BluetoothATTOpcodeReadBlobRsp:
+		a.Value = a.Parameters
+	}
+	a.BaseLayer = BaseLayer{Contents: data, Payload: nil}
+	return nil
+}
+
+// SerializeTo writes the opcode and Parameters, implementing
+// gopacket.SerializableLayer. Handle, Value and the fields of error
+// responses are not encoded.
+func (a *BluetoothATT) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(1 + len(a.Parameters))
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(a.Opcode)
+	copy(bytes[1:], a.Parameters)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *BluetoothATT) CanDecode() gopacket.LayerClass {
+	return LayerTypeBluetoothATT
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (a *BluetoothATT) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+func decodeBluetoothATT(data []byte, p gopacket.PacketBuilder) error {
+	a := &BluetoothATT{}
+	return decodingLayerDecoder(a, data, p)
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A Reset command, with the direction pseudo-header.
+var testPacketBluetoothReset = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x01, 0x03, 0x0c, 0x00,
+}
+
+// The Command Complete event answering it.
+var testPacketBluetoothCommandComplete = []byte{
+	0x04, 0x0e, 0x04, 0x01, 0x03, 0x0c, 0x00,
+}
+
+// An ATT Handle Value Notification of handle 0x002a, on connection 0x040.
+var testPacketBluetoothATTNotification = []byte{
+	0x02, 0x40, 0x20, 0x09, 0x00,
+	0x05, 0x00, 0x04, 0x00,
+	0x1b, 0x2a, 0x00, 0x64, 0xff,
+}
+
+func TestBluetoothHCICommand(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothReset, LinkTypeBluetoothHCIH4PHDR, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothPHDR, LayerTypeBluetoothH4, LayerTypeBluetoothHCICommand}, t)
+	if p.Layer(LayerTypeBluetoothPHDR).(*BluetoothPHDR).Received {
+		t.Error("command received")
+	}
+	c := p.Layer(LayerTypeBluetoothHCICommand).(*BluetoothHCICommand)
+	if c.Opcode != BluetoothHCIOpcodeReset || c.Opcode.OGF() != 3 || c.Opcode.OCF() != 3 || len(c.Parameters) != 0 {
+		t.Errorf("got %+v", c)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&BluetoothPHDR{}, &BluetoothH4{Type: BluetoothH4TypeCommand}, &BluetoothHCICommand{Opcode: BluetoothHCIOpcodeReset}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketBluetoothReset) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testPacketBluetoothReset)
+	}
+}
+
+func TestBluetoothHCIEvent(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothCommandComplete, LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothH4, LayerTypeBluetoothHCIEvent}, t)
+	e := p.Layer(LayerTypeBluetoothHCIEvent).(*BluetoothHCIEvent)
+	if e.EventCode != BluetoothHCIEventCodeCommandComplete || e.ParameterLength != 4 {
+		t.Errorf("got %+v", e)
+	}
+	if opcode, ok := e.CommandOpcode(); !ok || opcode != BluetoothHCIOpcodeReset {
+		t.Errorf("command opcode %v, %t", opcode, ok)
+	}
+	if status, ok := e.Status(); !ok || status != 0 {
+		t.Errorf("status %d, %t", status, ok)
+	}
+	if _, ok := e.LESubeventCode(); ok {
+		t.Error("LE subevent code of a Command Complete event")
+	}
+
+	p = gopacket.NewPacket(testPacketBluetoothCommandComplete[:6], LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated parameters")
+	}
+}
+
+func TestBluetoothATT(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothATTNotification, LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothH4, LayerTypeBluetoothHCIACL, LayerTypeBluetoothL2CAP, LayerTypeBluetoothATT}, t)
+	acl := p.Layer(LayerTypeBluetoothHCIACL).(*BluetoothHCIACL)
+	if acl.Handle != 0x040 || acl.PacketBoundary != BluetoothACLPacketBoundaryFirstFlushable || acl.DataLength != 9 {
+		t.Errorf("got %+v", acl)
+	}
+	l2cap := p.Layer(LayerTypeBluetoothL2CAP).(*BluetoothL2CAP)
+	if l2cap.ChannelID != BluetoothL2CAPChannelATT || l2cap.Length != 5 || l2cap.Fragmented() {
+		t.Errorf("got %+v", l2cap)
+	}
+	att := p.Layer(LayerTypeBluetoothATT).(*BluetoothATT)
+	if att.Opcode != BluetoothATTOpcodeHandleValueNtf || att.Handle != 0x2a || !bytes.Equal(att.Value, []byte{0x64, 0xff}) {
+		t.Errorf("got %+v", att)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&BluetoothH4{Type: BluetoothH4TypeACL},
+		&BluetoothHCIACL{Handle: 0x040, PacketBoundary: BluetoothACLPacketBoundaryFirstFlushable},
+		&BluetoothL2CAP{ChannelID: BluetoothL2CAPChannelATT},
+		&BluetoothATT{Opcode: BluetoothATTOpcodeHandleValueNtf, Parameters: []byte{0x2a, 0x00, 0x64, 0xff}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketBluetoothATTNotification) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testPacketBluetoothATTNotification)
+	}
+
+	for _, test := range []struct {
+		pdu   []byte
+		check func(*BluetoothATT) bool
+	}{
+		{[]byte{0x01, 0x0a, 0x03, 0x00, 0x0a}, func(a *BluetoothATT) bool {
+			return a.RequestOpcode == BluetoothATTOpcodeReadReq && a.Handle == 3 && a.ErrorCode == 0x0a
+		}},
+		{[]byte{0x0a, 0x03, 0x00}, func(a *BluetoothATT) bool {
+			return a.Handle == 3 && a.Value == nil
+		}},
+		{[]byte{0x0b, 'h', 'i'}, func(a *BluetoothATT) bool {
+			return string(a.Value) == "hi"
+		}},
+		{[]byte{0x16, 0x03, 0x00, 0x10, 0x00, 'h', 'i'}, func(a *BluetoothATT) bool {
+			return a.Handle == 3 && string(a.Value) == "hi"
+		}},
+		{append([]byte{0xd2, 0x03, 0x00, 'h', 'i'}, lotsOfZeros[:12]...), func(a *BluetoothATT) bool {
+			return a.Opcode.Command() && a.Opcode.Signed() && a.Opcode.Method() == 0x12 && string(a.Value) == "hi"
+		}},
+	} {
+		att := &BluetoothATT{}
+		if err := att.DecodeFromBytes(test.pdu, gopacket.NilDecodeFeedback); err != nil {
+			t.Errorf("%x: %v", test.pdu, err)
+		} else if !test.check(att) {
+			t.Errorf("%x: got %+v", test.pdu, att)
+		}
+	}
+	att = &BluetoothATT{}
+	if err := att.DecodeFromBytes([]byte{0x1b, 0x2a}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated notification")
+	}
+}
+
+func TestBluetoothL2CAPFragment(t *testing.T) {
+	// The first fragment of a frame of 8 bytes, and its continuation.
+	first := []byte{0x02, 0x40, 0x20, 0x08, 0x00, 0x08, 0x00, 0x04, 0x00, 0x1b, 0x2a, 0x00, 0x01}
+	p := gopacket.NewPacket(first, LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothH4, LayerTypeBluetoothHCIACL, LayerTypeBluetoothL2CAP, gopacket.LayerTypeFragment}, t)
+	if l2cap := p.Layer(LayerTypeBluetoothL2CAP).(*BluetoothL2CAP); !l2cap.Fragmented() {
+		t.Errorf("got %+v", l2cap)
+	}
+
+	next := []byte{0x02, 0x40, 0x10, 0x04, 0x00, 0x02, 0x03, 0x04, 0x05}
+	p = gopacket.NewPacket(next, LinkTypeBluetoothHCIH4, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothH4, LayerTypeBluetoothHCIACL, gopacket.LayerTypeFragment}, t)
+
+	p = gopacket.NewPacket(next[:8], LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated ACL packet")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// CAN ID flags of SocketCAN frames.
+const (
+	canEFFFlag = 0x80000000
+	canRTRFlag = 0x40000000
+	canERRFlag = 0x20000000
+)
+
+// CAN FD flags of SocketCAN frames.
+const (
+	canFDBRS = 0x01
+	canFDESI = 0x02
+	canFDFDF = 0x04
+)
+
+// canFDMTU is the length of the SocketCAN frames of CAN FD, which hold up
+// to 64 bytes of data.
+const canFDMTU = 72
+
+// CAN is a classical CAN or CAN FD frame as SocketCAN captures it, the
+// frames of pcap's LINKTYPE_CAN_SOCKETCAN. Its payload is the data of the
+// frame, or the error information of error frames.
+type CAN struct {
+	BaseLayer
+	// ID is the 11 bit identifier of standard frames, or the 29 bit
+	// identifier of frames whose Extended flag is set.
+	ID       uint32
+	Extended bool
+	// RTR is set for remote transmission requests, which have no data;
+	// their Length is that of the data they request.
+	RTR bool
+	// Error is set for the error frames SocketCAN reports, whose ID tells
+	// the class of the error.
+	Error bool
+	// Length is the length of the data.
+	Length uint8
+	// FD is set for CAN FD frames, which may carry up to 64 bytes of data,
+	// sent at a second bit rate if BRS is set. ESI tells the error state
+	// of the sender.
+	FD  bool
+	BRS bool
+	ESI bool
+	// Len8DLC is the data length code, from 9 to 15, of classical frames
+	// of 8 bytes which were sent with one larger than 8.
+	Len8DLC uint8
+}
+
+// LayerType returns LayerTypeCAN.
+func (c *CAN) LayerType() gopacket.LayerType { return LayerTypeCAN }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CAN) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return fmt.Errorf("CAN length %d too short, %d required", len(data), 8)
+	}
+	id := binary.BigEndian.Uint32(data)
+	c.Extended = id&canEFFFlag != 0
+	c.RTR = id&canRTRFlag != 0
+	c.Error = id&canERRFlag != 0
+	if c.Extended {
+		c.ID = id & 0x1fffffff
+	} else {
+		c.ID = id & 0x7ff
+	}
+	c.Length = data[4]
+	flags := data[5]
+	// Older kernels do not set the FDF flag of CAN FD frames, which only
+	// their length tells.
+	c.FD = flags&canFDFDF != 0 || len(data) == canFDMTU
+	c.BRS = c.FD && flags&canFDBRS != 0
+	c.ESI = c.FD && flags&canFDESI != 0
+	c.Len8DLC = 0
+	if !c.FD {
+		c.Len8DLC = data[7]
+	}
+	max := 8
+	if c.FD {
+		max = 64
+	}
+	if int(c.Length) > max {
+		return fmt.Errorf("CAN data length %d too long, at most %d allowed", c.Length, max)
+	}
+	if c.RTR {
+		c.BaseLayer = BaseLayer{Contents: data[:8]}
+		return nil
+	}
+	end := 8 + int(c.Length)
+	if len(data) < end {
+		df.SetTruncated()
+		return fmt.Errorf("CAN data length %d exceeds %d remaining bytes", c.Length, len(data)-8)
+	}
+	c.BaseLayer = BaseLayer{Contents: data[:8], Payload: data[8:end]}
+	return nil
+}
+
+// canFDLengths are the data lengths of the CAN FD data length codes larger
+// than 8.
+var canFDLengths = [...]uint8{12, 16, 20, 24, 32, 48, 64}
+
+// DLC returns the data length code of the frame, as sent on the bus.
+func (c *CAN) DLC() uint8 {
+	if c.Length <= 8 {
+		if c.Length == 8 && c.Len8DLC > 8 {
+			return c.Len8DLC
+		}
+		return c.Length
+	}
+	for i, length := range canFDLengths {
+		if c.Length <= length {
+			return 9 + uint8(i)
+		}
+	}
+	return 15
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *CAN) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if c.Extended && c.ID > 0x1fffffff || !c.Extended && c.ID > 0x7ff {
+		return fmt.Errorf("CAN ID %#x too large", c.ID)
+	}
+	length := len(b.Bytes())
+	max := 8
+	if c.FD {
+		max = 64
+	}
+	if length > max {
+		return fmt.Errorf("CAN data length %d too long, at most %d allowed", length, max)
+	}
+	bytes, err := b.PrependBytes(8)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths && !c.RTR {
+		c.Length = uint8(length)
+	}
+	id := c.ID
+	if c.Extended {
+		id |= canEFFFlag
+	}
+	if c.RTR {
+		id |= canRTRFlag
+	}
+	if c.Error {
+		id |= canERRFlag
+	}
+	binary.BigEndian.PutUint32(bytes, id)
+	bytes[4] = c.Length
+	var flags uint8
+	if c.FD {
+		flags = canFDFDF
+		if c.BRS {
+			flags |= canFDBRS
+		}
+		if c.ESI {
+			flags |= canFDESI
+		}
+	}
+	bytes[5] = flags
+	bytes[6] = 0
+	bytes[7] = 0
+	if !c.FD {
+		bytes[7] = c.Len8DLC
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CAN) CanDecode() gopacket.LayerClass {
+	return LayerTypeCAN
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *CAN) NextLayerType() gopacket.LayerType {
+	if len(c.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeCAN(data []byte, p gopacket.PacketBuilder) error {
+	c := &CAN{}
+	return decodingLayerDecoder(c, data, p)
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A classical frame of ID 0x123 with 4 bytes of data, as candump captures
+// it, padded to 16 bytes.
+var testPacketCAN = []byte{
+	0x00, 0x00, 0x01, 0x23, 0x04, 0x00, 0x00, 0x00,
+	0xde, 0xad, 0xbe, 0xef, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestCAN(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCAN, LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCAN, gopacket.LayerTypePayload}, t)
+	c := p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x123 || c.Extended || c.RTR || c.Error || c.FD || c.Length != 4 || c.DLC() != 4 ||
+		!bytes.Equal(c.Payload, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("got %+v", c)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &CAN{ID: 0x123}, gopacket.Payload(c.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketCAN[:12]) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testPacketCAN[:12])
+	}
+
+	// An extended remote transmission request of 8 bytes.
+	rtr := []byte{0xc1, 0x23, 0x45, 0x67, 0x08, 0x00, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	p = gopacket.NewPacket(rtr, LinkTypeCANSocketCAN, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeCAN}, t)
+	c = p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x01234567 || !c.Extended || !c.RTR || c.Length != 8 || len(c.Payload) != 0 {
+		t.Errorf("got %+v", c)
+	}
+
+	p = gopacket.NewPacket(testPacketCAN[:10], LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated data")
+	}
+	if err := (&CAN{ID: 0x800}).SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("serialized a standard frame with an extended ID")
+	}
+}
+
+func TestCANFD(t *testing.T) {
+	data := make([]byte, 20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&CAN{ID: 0x1abcdef, Extended: true, FD: true, BRS: true}, gopacket.Payload(data)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x81, 0xab, 0xcd, 0xef, 20, canFDFDF | canFDBRS, 0, 0}; !bytes.Equal(buf.Bytes()[:8], want) {
+		t.Errorf("header %x, want %x", buf.Bytes()[:8], want)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	c := p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x1abcdef || !c.FD || !c.BRS || c.ESI || c.Length != 20 || c.DLC() != 11 || !bytes.Equal(c.Payload, data) {
+		t.Errorf("got %+v", c)
+	}
+
+	// A frame of a kernel which does not set the FDF flag, told by its
+	// length.
+	frame := make([]byte, canFDMTU)
+	copy(frame, []byte{0x00, 0x00, 0x07, 0xff, 12, canFDESI})
+	p = gopacket.NewPacket(frame, LinkTypeCANSocketCAN, gopacket.Default)
+	c = p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x7ff || !c.FD || !c.ESI || c.Length != 12 || c.DLC() != 9 || len(c.Payload) != 12 {
+		t.Errorf("got %+v", c)
+	}
+
+	if err := gopacket.SerializeLayers(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{FixLengths: true},
+		&CAN{ID: 1}, gopacket.Payload(data)); err == nil {
+		t.Error("serialized a classical frame of 20 bytes")
+	}
+}
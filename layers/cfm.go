@@ -0,0 +1,533 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// CFMOpCode is the OpCode of an IEEE 802.1ag Connectivity Fault Management
+// or ITU-T Y.1731 Ethernet OAM PDU.
+type CFMOpCode uint8
+
+// CFM OpCodes from IEEE 802.1Q table 21-4 and ITU-T Y.1731 table 9-1.
+const (
+	CFMOpCodeCCM  CFMOpCode = 1
+	CFMOpCodeLBR  CFMOpCode = 2
+	CFMOpCodeLBM  CFMOpCode = 3
+	CFMOpCodeLTR  CFMOpCode = 4
+	CFMOpCodeLTM  CFMOpCode = 5
+	CFMOpCodeAIS  CFMOpCode = 33
+	CFMOpCodeLCK  CFMOpCode = 35
+	CFMOpCodeTST  CFMOpCode = 37
+	CFMOpCodeAPS  CFMOpCode = 39
+	CFMOpCodeRAPS CFMOpCode = 40
+	CFMOpCodeMCC  CFMOpCode = 41
+	CFMOpCodeLMR  CFMOpCode = 42
+	CFMOpCodeLMM  CFMOpCode = 43
+	CFMOpCode1DM  CFMOpCode = 45
+	CFMOpCodeDMR  CFMOpCode = 46
+	CFMOpCodeDMM  CFMOpCode = 47
+	CFMOpCodeEXR  CFMOpCode = 48
+	CFMOpCodeEXM  CFMOpCode = 49
+	CFMOpCodeVSR  CFMOpCode = 50
+	CFMOpCodeVSM  CFMOpCode = 51
+	CFMOpCodeCSF  CFMOpCode = 52
+	CFMOpCode1SL  CFMOpCode = 53
+	CFMOpCodeSLR  CFMOpCode = 54
+	CFMOpCodeSLM  CFMOpCode = 55
+)
+
+func (o CFMOpCode) String() string {
+	switch o {
+	case CFMOpCodeCCM:
+		return "CCM"
+	case CFMOpCodeLBR:
+		return "LBR"
+	case CFMOpCodeLBM:
+		return "LBM"
+	case CFMOpCodeLTR:
+		return "LTR"
+	case CFMOpCodeLTM:
+		return "LTM"
+	case CFMOpCodeAIS:
+		return "AIS"
+	case CFMOpCodeLCK:
+		return "LCK"
+	case CFMOpCodeTST:
+		return "TST"
+	case CFMOpCodeAPS:
+		return "APS"
+	case CFMOpCodeRAPS:
+		return "R-APS"
+	case CFMOpCodeMCC:
+		return "MCC"
+	case CFMOpCodeLMR:
+		return "LMR"
+	case CFMOpCodeLMM:
+		return "LMM"
+	case CFMOpCode1DM:
+		return "1DM"
+	case CFMOpCodeDMR:
+		return "DMR"
+	case CFMOpCodeDMM:
+		return "DMM"
+	case CFMOpCodeEXR:
+		return "EXR"
+	case CFMOpCodeEXM:
+		return "EXM"
+	case CFMOpCodeVSR:
+		return "VSR"
+	case CFMOpCodeVSM:
+		return "VSM"
+	case CFMOpCodeCSF:
+		return "CSF"
+	case CFMOpCode1SL:
+		return "1SL"
+	case CFMOpCodeSLR:
+		return "SLR"
+	case CFMOpCodeSLM:
+		return "SLM"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(o))
+}
+
+// cfmFirstTLVOffset returns the size of the OpCode specific fields of the
+// OpCodes this layer decodes.
+func cfmFirstTLVOffset(o CFMOpCode) (int, bool) {
+	switch o {
+	case CFMOpCodeCCM:
+		return 70, true
+	case CFMOpCodeLBM, CFMOpCodeLBR:
+		return 4, true
+	case CFMOpCodeLTM:
+		return 17, true
+	case CFMOpCodeLTR:
+		return 6, true
+	case CFMOpCodeLMM, CFMOpCodeLMR:
+		return 12, true
+	case CFMOpCode1DM:
+		return 16, true
+	case CFMOpCodeDMM, CFMOpCodeDMR:
+		return 32, true
+	case CFMOpCodeAIS, CFMOpCodeLCK:
+		return 0, true
+	}
+	return 0, false
+}
+
+// CFMTLVType is the type of a TLV following the OpCode specific fields of a
+// CFM PDU.
+type CFMTLVType uint8
+
+// CFM TLV types from IEEE 802.1Q table 21-6 and ITU-T Y.1731.
+const (
+	CFMTLVTypeEnd                  CFMTLVType = 0
+	CFMTLVTypeSenderID             CFMTLVType = 1
+	CFMTLVTypePortStatus           CFMTLVType = 2
+	CFMTLVTypeData                 CFMTLVType = 3
+	CFMTLVTypeInterfaceStatus      CFMTLVType = 4
+	CFMTLVTypeReplyIngress         CFMTLVType = 5
+	CFMTLVTypeReplyEgress          CFMTLVType = 6
+	CFMTLVTypeLTMEgressIdentifier  CFMTLVType = 7
+	CFMTLVTypeLTREgressIdentifier  CFMTLVType = 8
+	CFMTLVTypeOrganizationSpecific CFMTLVType = 31
+	CFMTLVTypeTest                 CFMTLVType = 32
+)
+
+func (t CFMTLVType) String() string {
+	switch t {
+	case CFMTLVTypeEnd:
+		return "End"
+	case CFMTLVTypeSenderID:
+		return "SenderID"
+	case CFMTLVTypePortStatus:
+		return "PortStatus"
+	case CFMTLVTypeData:
+		return "Data"
+	case CFMTLVTypeInterfaceStatus:
+		return "InterfaceStatus"
+	case CFMTLVTypeReplyIngress:
+		return "ReplyIngress"
+	case CFMTLVTypeReplyEgress:
+		return "ReplyEgress"
+	case CFMTLVTypeLTMEgressIdentifier:
+		return "LTMEgressIdentifier"
+	case CFMTLVTypeLTREgressIdentifier:
+		return "LTREgressIdentifier"
+	case CFMTLVTypeOrganizationSpecific:
+		return "OrganizationSpecific"
+	case CFMTLVTypeTest:
+		return "Test"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// CFMTLV is a TLV of a CFM PDU. The End TLV is not included.
+type CFMTLV struct {
+	Type  CFMTLVType
+	Value []byte
+}
+
+// CFMRelayAction is the relay action of a Linktrace Reply.
+type CFMRelayAction uint8
+
+const (
+	CFMRelayActionHit  CFMRelayAction = 1
+	CFMRelayActionFDB  CFMRelayAction = 2
+	CFMRelayActionMPDB CFMRelayAction = 3
+)
+
+func (a CFMRelayAction) String() string {
+	switch a {
+	case CFMRelayActionHit:
+		return "RlyHit"
+	case CFMRelayActionFDB:
+		return "RlyFDB"
+	case CFMRelayActionMPDB:
+		return "RlyMPDB"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(a))
+}
+
+// CFMMAID is the Maintenance Association Identifier of a CCM, called MEG ID
+// by Y.1731. MDNameFormat 1 means there is no MD name; Y.1731 ICC-based MEG
+// IDs use it with MANameFormat 32.
+type CFMMAID struct {
+	MDNameFormat uint8
+	MDName       []byte
+	MANameFormat uint8
+	MAName       []byte
+}
+
+func (m CFMMAID) String() string {
+	if m.MDNameFormat == 1 {
+		return string(m.MAName)
+	}
+	return string(m.MDName) + "/" + string(m.MAName)
+}
+
+const cfmMAIDLength = 48
+
+func (m *CFMMAID) decode(data []byte) error {
+	*m = CFMMAID{MDNameFormat: data[0]}
+	i := 1
+	if m.MDNameFormat != 1 {
+		n := int(data[1])
+		if 2+n > cfmMAIDLength-2 {
+			return fmt.Errorf("CFM MD name length %d too long", n)
+		}
+		m.MDName = data[2 : 2+n]
+		i = 2 + n
+	}
+	m.MANameFormat = data[i]
+	n := int(data[i+1])
+	if i+2+n > cfmMAIDLength {
+		return fmt.Errorf("CFM short MA name length %d too long", n)
+	}
+	m.MAName = data[i+2 : i+2+n]
+	return nil
+}
+
+func (m *CFMMAID) encode(data []byte) error {
+	n := 2 + len(m.MAName)
+	if m.MDNameFormat != 1 {
+		n += 2 + len(m.MDName)
+	} else {
+		n++
+	}
+	if n > cfmMAIDLength || len(m.MDName) > 255 || len(m.MAName) > 255 {
+		return fmt.Errorf("CFM MAID of %d bytes too long", n)
+	}
+	copy(data, lotsOfZeros[:cfmMAIDLength])
+	data[0] = m.MDNameFormat
+	i := 1
+	if m.MDNameFormat != 1 {
+		data[1] = byte(len(m.MDName))
+		i = 2 + copy(data[2:], m.MDName)
+	}
+	data[i] = m.MANameFormat
+	data[i+1] = byte(len(m.MAName))
+	copy(data[i+2:], m.MAName)
+	return nil
+}
+
+// CFMTimestamp is a Y.1731 time stamp in the format of the IEEE 1588
+// Precision Time Protocol.
+type CFMTimestamp struct {
+	Seconds, Nanoseconds uint32
+}
+
+// Duration returns the time stamp as the time since its epoch.
+func (t CFMTimestamp) Duration() time.Duration {
+	return time.Duration(t.Seconds)*time.Second + time.Duration(t.Nanoseconds)
+}
+
+func (t *CFMTimestamp) decode(data []byte) {
+	t.Seconds = binary.BigEndian.Uint32(data)
+	t.Nanoseconds = binary.BigEndian.Uint32(data[4:])
+}
+
+func (t CFMTimestamp) encode(data []byte) {
+	binary.BigEndian.PutUint32(data, t.Seconds)
+	binary.BigEndian.PutUint32(data[4:], t.Nanoseconds)
+}
+
+// CFM is an IEEE 802.1ag Connectivity Fault Management PDU, including the
+// ITU-T Y.1731 performance monitoring extensions used by carrier Ethernet.
+// Which of the OpCode specific fields are set depends on OpCode; the
+// fields of OpCodes not listed below are not decoded.
+type CFM struct {
+	BaseLayer
+	MDLevel uint8
+	Version uint8
+	OpCode  CFMOpCode
+	// Flags holds the flags of the PDU. For CCMs they contain the RDI bit
+	// and the transmission interval; see RDI and CCMInterval.
+	Flags          uint8
+	FirstTLVOffset uint8
+
+	// SequenceNumber and MEPID are set for CCMs, MAID too.
+	SequenceNumber uint32
+	MEPID          uint16
+	MAID           CFMMAID
+	// TxFCf, RxFCb and TxFCb are the frame counters of CCMs. LMMs and LMRs
+	// carry TxFCf, RxFCf and TxFCb.
+	TxFCf, RxFCf, TxFCb, RxFCb uint32
+
+	// TransactionID is set for LBMs, LBRs, LTMs and LTRs.
+	TransactionID uint32
+	// TTL is set for LTMs and LTRs, where it holds the reply TTL.
+	TTL uint8
+	// OriginalMAC and TargetMAC are set for LTMs.
+	OriginalMAC, TargetMAC net.HardwareAddr
+	// RelayAction is set for LTRs.
+	RelayAction CFMRelayAction
+
+	// TxTimestampf is set for DMMs, DMRs and 1DMs. DMRs carry all four
+	// time stamps, 1DMs TxTimestampf and RxTimestampf.
+	TxTimestampf, RxTimestampf, TxTimestampb, RxTimestampb CFMTimestamp
+
+	TLVs []CFMTLV
+}
+
+// LayerType returns LayerTypeCFM.
+func (c *CFM) LayerType() gopacket.LayerType { return LayerTypeCFM }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CFM) CanDecode() gopacket.LayerClass { return LayerTypeCFM }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *CFM) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// RDI returns the Remote Defect Indication flag of a CCM.
+func (c *CFM) RDI() bool {
+	return c.Flags&0x80 != 0
+}
+
+var cfmCCMIntervals = [...]time.Duration{
+	0,
+	3333 * time.Microsecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+}
+
+// CCMInterval returns the transmission interval of a CCM, or 0 if it is
+// invalid.
+func (c *CFM) CCMInterval() time.Duration {
+	return cfmCCMIntervals[c.Flags&0x07]
+}
+
+// FrameDelay returns the two-way frame delay measured by a DMR, excluding
+// the processing time of the responder if it filled in RxTimestampf and
+// TxTimestampb.
+func (c *CFM) FrameDelay() time.Duration {
+	d := c.RxTimestampb.Duration() - c.TxTimestampf.Duration()
+	if c.TxTimestampb != (CFMTimestamp{}) {
+		d -= c.TxTimestampb.Duration() - c.RxTimestampf.Duration()
+	}
+	return d
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CFM) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("CFM PDU too short")
+	}
+	*c = CFM{
+		MDLevel:        data[0] >> 5,
+		Version:        data[0] & 0x1f,
+		OpCode:         CFMOpCode(data[1]),
+		Flags:          data[2],
+		FirstTLVOffset: data[3],
+	}
+	start := 4 + int(c.FirstTLVOffset)
+	if len(data) < start {
+		df.SetTruncated()
+		return fmt.Errorf("CFM first TLV offset %d exceeds PDU", c.FirstTLVOffset)
+	}
+	if want, ok := cfmFirstTLVOffset(c.OpCode); ok && int(c.FirstTLVOffset) < want {
+		return fmt.Errorf("CFM %v first TLV offset %d too small, want %d", c.OpCode, c.FirstTLVOffset, want)
+	}
+	d := data[4:]
+	switch c.OpCode {
+	case CFMOpCodeCCM:
+		c.SequenceNumber = binary.BigEndian.Uint32(d)
+		c.MEPID = binary.BigEndian.Uint16(d[4:]) & 0x1fff
+		if err := c.MAID.decode(d[6 : 6+cfmMAIDLength]); err != nil {
+			return err
+		}
+		c.TxFCf = binary.BigEndian.Uint32(d[54:])
+		c.RxFCb = binary.BigEndian.Uint32(d[58:])
+		c.TxFCb = binary.BigEndian.Uint32(d[62:])
+	case CFMOpCodeLBM, CFMOpCodeLBR:
+		c.TransactionID = binary.BigEndian.Uint32(d)
+	case CFMOpCodeLTM:
+		c.TransactionID = binary.BigEndian.Uint32(d)
+		c.TTL = d[4]
+		c.OriginalMAC = net.HardwareAddr(d[5:11])
+		c.TargetMAC = net.HardwareAddr(d[11:17])
+	case CFMOpCodeLTR:
+		c.TransactionID = binary.BigEndian.Uint32(d)
+		c.TTL = d[4]
+		c.RelayAction = CFMRelayAction(d[5])
+	case CFMOpCodeLMM, CFMOpCodeLMR:
+		c.TxFCf = binary.BigEndian.Uint32(d)
+		c.RxFCf = binary.BigEndian.Uint32(d[4:])
+		c.TxFCb = binary.BigEndian.Uint32(d[8:])
+	case CFMOpCode1DM:
+		c.TxTimestampf.decode(d)
+		c.RxTimestampf.decode(d[8:])
+	case CFMOpCodeDMM, CFMOpCodeDMR:
+		c.TxTimestampf.decode(d)
+		c.RxTimestampf.decode(d[8:])
+		c.TxTimestampb.decode(d[16:])
+		c.RxTimestampb.decode(d[24:])
+	}
+
+	i := start
+	for {
+		if i >= len(data) {
+			// Some implementations leave out the End TLV.
+			break
+		}
+		t := CFMTLVType(data[i])
+		if t == CFMTLVTypeEnd {
+			i++
+			break
+		}
+		if i+3 > len(data) {
+			df.SetTruncated()
+			return errors.New("CFM TLV header truncated")
+		}
+		n := int(binary.BigEndian.Uint16(data[i+1:]))
+		if i+3+n > len(data) {
+			df.SetTruncated()
+			return fmt.Errorf("CFM %v TLV length %d exceeds PDU", t, n)
+		}
+		c.TLVs = append(c.TLVs, CFMTLV{Type: t, Value: data[i+3 : i+3+n]})
+		i += 3 + n
+	}
+	c.BaseLayer = BaseLayer{Contents: data[:i]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// FirstTLVOffset is set from OpCode. Only the OpCodes whose fields are
+// decoded can be serialized.
+func (c *CFM) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	size, ok := cfmFirstTLVOffset(c.OpCode)
+	if !ok {
+		return fmt.Errorf("cannot serialize CFM %v", c.OpCode)
+	}
+	if c.MDLevel > 7 || c.Version > 0x1f {
+		return fmt.Errorf("invalid CFM MD level %d or version %d", c.MDLevel, c.Version)
+	}
+	c.FirstTLVOffset = uint8(size)
+	n := 4 + size + 1
+	for _, tlv := range c.TLVs {
+		if len(tlv.Value) > 0xffff {
+			return fmt.Errorf("CFM %v TLV of %d bytes too long", tlv.Type, len(tlv.Value))
+		}
+		n += 3 + len(tlv.Value)
+	}
+	bytes, err := b.PrependBytes(n)
+	if err != nil {
+		return err
+	}
+	copy(bytes[4:4+size], lotsOfZeros[:size])
+	bytes[0] = c.MDLevel<<5 | c.Version
+	bytes[1] = byte(c.OpCode)
+	bytes[2] = c.Flags
+	bytes[3] = byte(size)
+	d := bytes[4:]
+	switch c.OpCode {
+	case CFMOpCodeCCM:
+		if c.MEPID > 0x1fff {
+			return fmt.Errorf("invalid CFM MEP ID %d", c.MEPID)
+		}
+		binary.BigEndian.PutUint32(d, c.SequenceNumber)
+		binary.BigEndian.PutUint16(d[4:], c.MEPID)
+		if err := c.MAID.encode(d[6 : 6+cfmMAIDLength]); err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(d[54:], c.TxFCf)
+		binary.BigEndian.PutUint32(d[58:], c.RxFCb)
+		binary.BigEndian.PutUint32(d[62:], c.TxFCb)
+	case CFMOpCodeLBM, CFMOpCodeLBR:
+		binary.BigEndian.PutUint32(d, c.TransactionID)
+	case CFMOpCodeLTM:
+		if len(c.OriginalMAC) != 6 || len(c.TargetMAC) != 6 {
+			return errors.New("CFM LTM MAC addresses must be 6 bytes")
+		}
+		binary.BigEndian.PutUint32(d, c.TransactionID)
+		d[4] = c.TTL
+		copy(d[5:], c.OriginalMAC)
+		copy(d[11:], c.TargetMAC)
+	case CFMOpCodeLTR:
+		binary.BigEndian.PutUint32(d, c.TransactionID)
+		d[4] = c.TTL
+		d[5] = byte(c.RelayAction)
+	case CFMOpCodeLMM, CFMOpCodeLMR:
+		binary.BigEndian.PutUint32(d, c.TxFCf)
+		binary.BigEndian.PutUint32(d[4:], c.RxFCf)
+		binary.BigEndian.PutUint32(d[8:], c.TxFCb)
+	case CFMOpCode1DM:
+		c.TxTimestampf.encode(d)
+		c.RxTimestampf.encode(d[8:])
+	case CFMOpCodeDMM, CFMOpCodeDMR:
+		c.TxTimestampf.encode(d)
+		c.RxTimestampf.encode(d[8:])
+		c.TxTimestampb.encode(d[16:])
+		c.RxTimestampb.encode(d[24:])
+	}
+	i := 4 + size
+	for _, tlv := range c.TLVs {
+		bytes[i] = byte(tlv.Type)
+		binary.BigEndian.PutUint16(bytes[i+1:], uint16(len(tlv.Value)))
+		i += 3 + copy(bytes[i+3:], tlv.Value)
+	}
+	bytes[i] = byte(CFMTLVTypeEnd)
+	return nil
+}
+
+func decodeCFM(data []byte, p gopacket.PacketBuilder) error {
+	c := &CFM{}
+	return decodingLayerDecoder(c, data, p)
+}
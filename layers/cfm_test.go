@@ -0,0 +1,121 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketCFMCCM is a CCM at MD level 5 from MEP 1 with a 1s interval and
+// the MAID "md1/ma1", followed by a Port Status TLV.
+var testPacketCFMCCM = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x35, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x89, 0x02,
+	0xa0, 0x01, 0x04, 0x46, // level 5, version 0, CCM, interval 1s, offset 70
+	0x00, 0x00, 0x00, 0x2a, // sequence number
+	0x00, 0x01, // MEP ID
+	0x04, 0x03, 'm', 'd', '1', 0x02, 0x03, 'm', 'a', '1',
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // Y.1731 counters
+	0x02, 0x00, 0x01, 0x02, // Port Status TLV: up
+	0x00, // End TLV
+}
+
+func TestCFMCCM(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCFMCCM, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeCFM}, t)
+	c := p.Layer(LayerTypeCFM).(*CFM)
+	want := &CFM{
+		BaseLayer:      BaseLayer{Contents: testPacketCFMCCM[14:]},
+		MDLevel:        5,
+		OpCode:         CFMOpCodeCCM,
+		Flags:          0x04,
+		FirstTLVOffset: 70,
+		SequenceNumber: 42,
+		MEPID:          1,
+		MAID:           CFMMAID{MDNameFormat: 4, MDName: []byte("md1"), MANameFormat: 2, MAName: []byte("ma1")},
+		TLVs:           []CFMTLV{{Type: CFMTLVTypePortStatus, Value: []byte{2}}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got\n%#v\nwant\n%#v", c, want)
+	}
+	if c.RDI() || c.CCMInterval() != time.Second || c.MAID.String() != "md1/ma1" {
+		t.Errorf("got RDI %v, interval %v, MAID %v", c.RDI(), c.CCMInterval(), c.MAID)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, p.Layer(LayerTypeEthernet).(*Ethernet), c); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketCFMCCM) {
+		t.Errorf("serialized\n%x\nwant\n%x", buf.Bytes(), testPacketCFMCCM)
+	}
+}
+
+func TestCFMRoundTrip(t *testing.T) {
+	for _, c := range []*CFM{
+		{MDLevel: 3, OpCode: CFMOpCodeLBM, TransactionID: 7, TLVs: []CFMTLV{{Type: CFMTLVTypeData, Value: []byte("ping")}}},
+		{MDLevel: 3, OpCode: CFMOpCodeLTM, Flags: 0x80, TransactionID: 8, TTL: 64,
+			OriginalMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, TargetMAC: net.HardwareAddr{6, 7, 8, 9, 10, 11}},
+		{MDLevel: 3, OpCode: CFMOpCodeLTR, Flags: 0x60, TransactionID: 8, TTL: 63, RelayAction: CFMRelayActionHit},
+		{MDLevel: 6, OpCode: CFMOpCodeLMR, TxFCf: 100, RxFCf: 99, TxFCb: 98},
+		{MDLevel: 6, OpCode: CFMOpCodeCCM, Flags: 0x81, MEPID: 0x1fff,
+			MAID: CFMMAID{MDNameFormat: 1, MANameFormat: 32, MAName: []byte("ABCDEFGHIJKLM")}},
+		{MDLevel: 7, OpCode: CFMOpCodeAIS, Flags: 0x04},
+	} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := c.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+			t.Fatalf("%v: %v", c.OpCode, err)
+		}
+		var got CFM
+		if err := got.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+			t.Fatalf("%v: %v", c.OpCode, err)
+		}
+		got.BaseLayer = BaseLayer{}
+		if !reflect.DeepEqual(&got, c) {
+			t.Errorf("%v: got\n%#v\nwant\n%#v", c.OpCode, &got, c)
+		}
+	}
+	if err := (&CFM{OpCode: CFMOpCodeTST}).SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("serialized TST without error")
+	}
+}
+
+func TestCFMDelay(t *testing.T) {
+	c := &CFM{
+		OpCode:       CFMOpCodeDMR,
+		TxTimestampf: CFMTimestamp{Seconds: 10, Nanoseconds: 0},
+		RxTimestampf: CFMTimestamp{Seconds: 20, Nanoseconds: 1000},
+		TxTimestampb: CFMTimestamp{Seconds: 20, Nanoseconds: 5000},
+		RxTimestampb: CFMTimestamp{Seconds: 10, Nanoseconds: 300000},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := c.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var got CFM
+	if err := got.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if d := got.FrameDelay(); d != 296*time.Microsecond {
+		t.Errorf("got frame delay %v, want 296µs", d)
+	}
+
+	// DMR truncated before its time stamps.
+	if err := got.DecodeFromBytes(buf.Bytes()[:20], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded truncated DMR")
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// Cisco HDLC addresses.
+const (
+	CiscoHDLCAddressUnicast   uint8 = 0x0f
+	CiscoHDLCAddressBroadcast uint8 = 0x8f
+)
+
+// CiscoHDLCProtocolSLARP is the protocol of SLARP packets in Cisco HDLC
+// headers, where it does not stand for RARP as it does in Ethernet frames.
+const CiscoHDLCProtocolSLARP EthernetType = 0x8035
+
+// CiscoHDLC is the Cisco HDLC header used on serial links (pcap's
+// LINKTYPE_C_HDLC), which is followed by a packet of an EtherType, or by
+// a SLARP packet.
+type CiscoHDLC struct {
+	BaseLayer
+	// Address is CiscoHDLCAddressUnicast or CiscoHDLCAddressBroadcast.
+	Address  uint8
+	Control  uint8
+	Protocol EthernetType
+}
+
+// LayerType returns LayerTypeCiscoHDLC.
+func (c *CiscoHDLC) LayerType() gopacket.LayerType { return LayerTypeCiscoHDLC }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CiscoHDLC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("Cisco HDLC header too short")
+	}
+	c.Address = data[0]
+	c.Control = data[1]
+	c.Protocol = EthernetType(binary.BigEndian.Uint16(data[2:4]))
+	c.BaseLayer = BaseLayer{Contents: data[:4], Payload: data[4:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *CiscoHDLC) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	bytes[0] = c.Address
+	bytes[1] = c.Control
+	binary.BigEndian.PutUint16(bytes[2:], uint16(c.Protocol))
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CiscoHDLC) CanDecode() gopacket.LayerClass {
+	return LayerTypeCiscoHDLC
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *CiscoHDLC) NextLayerType() gopacket.LayerType {
+	if c.Protocol == CiscoHDLCProtocolSLARP {
+		return LayerTypeSLARP
+	}
+	return ethernetTypePayloadLayerType(c.Protocol, c.Payload)
+}
+
+// ethernetTypePayloadLayerType returns the layer type of the given payload
+// of EtherType t, falling back to gopacket.LayerTypePayload for the
+// EtherTypes gopacket does not decode.
+func ethernetTypePayloadLayerType(t EthernetType, payload []byte) gopacket.LayerType {
+	if len(payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	if lt := t.LayerType(); lt != gopacket.LayerTypeZero {
+		return lt
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeCiscoHDLC(data []byte, p gopacket.PacketBuilder) error {
+	c := &CiscoHDLC{}
+	return decodingLayerDecoder(c, data, p)
+}
+
+// decodePPPHDLC decodes pcap's LINKTYPE_PPP_HDLC, which holds either PPP in
+// HDLC-like framing (RFC 1662) or Cisco PPP with HDLC framing (RFC 1547),
+// told apart by their address byte.
+func decodePPPHDLC(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) > 0 && (data[0] == CiscoHDLCAddressUnicast || data[0] == CiscoHDLCAddressBroadcast) {
+		return decodeCiscoHDLC(data, p)
+	}
+	return decodePPP(data, p)
+}
+
+// SLARPCode is the code of a SLARP packet.
+type SLARPCode uint32
+
+const (
+	SLARPCodeRequest   SLARPCode = 0
+	SLARPCodeReply     SLARPCode = 1
+	SLARPCodeKeepalive SLARPCode = 2
+)
+
+func (c SLARPCode) String() string {
+	switch c {
+	case SLARPCodeRequest:
+		return "Request"
+	case SLARPCodeReply:
+		return "Reply"
+	case SLARPCodeKeepalive:
+		return "Keepalive"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint32(c))
+	}
+}
+
+// SLARP is a packet of Cisco's Serial Line ARP, which routers exchange over
+// Cisco HDLC links to learn the address of their peer and, in keepalives,
+// to tell the link is up.
+type SLARP struct {
+	BaseLayer
+	Code SLARPCode
+	// Address and Mask are those of requests and replies.
+	Address net.IP
+	Mask    net.IP
+	// MySequence and YourSequence are the sequence numbers of keepalives,
+	// that of the sender and the last one it received.
+	MySequence   uint32
+	YourSequence uint32
+	// Reliability is usually 0xffff, and Time the uptime of the sender in
+	// milliseconds.
+	Reliability uint16
+	Time        uint32
+}
+
+// LayerType returns LayerTypeSLARP.
+func (s *SLARP) LayerType() gopacket.LayerType { return LayerTypeSLARP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *SLARP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 18 {
+		df.SetTruncated()
+		return fmt.Errorf("SLARP length %d too short, 18 required", len(data))
+	}
+	s.Code = SLARPCode(binary.BigEndian.Uint32(data[0:4]))
+	s.Address, s.Mask, s.MySequence, s.YourSequence = nil, nil, 0, 0
+	if s.Code == SLARPCodeKeepalive {
+		s.MySequence = binary.BigEndian.Uint32(data[4:8])
+		s.YourSequence = binary.BigEndian.Uint32(data[8:12])
+	} else {
+		s.Address = net.IP(data[4:8])
+		s.Mask = net.IP(data[8:12])
+	}
+	s.Reliability = binary.BigEndian.Uint16(data[12:14])
+	s.Time = binary.BigEndian.Uint32(data[14:18])
+	s.BaseLayer = BaseLayer{Contents: data[:18], Payload: data[18:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (s *SLARP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	par1, par2 := s.MySequence, s.YourSequence
+	if s.Code != SLARPCodeKeepalive {
+		addr, mask := s.Address.To4(), s.Mask.To4()
+		if addr == nil || mask == nil {
+			return fmt.Errorf("invalid SLARP address %v or mask %v", s.Address, s.Mask)
+		}
+		par1, par2 = binary.BigEndian.Uint32(addr), binary.BigEndian.Uint32(mask)
+	}
+	bytes, err := b.PrependBytes(18)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(bytes[0:4], uint32(s.Code))
+	binary.BigEndian.PutUint32(bytes[4:8], par1)
+	binary.BigEndian.PutUint32(bytes[8:12], par2)
+	binary.BigEndian.PutUint16(bytes[12:14], s.Reliability)
+	binary.BigEndian.PutUint32(bytes[14:18], s.Time)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *SLARP) CanDecode() gopacket.LayerClass {
+	return LayerTypeSLARP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *SLARP) NextLayerType() gopacket.LayerType {
+	if len(s.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeSLARP(data []byte, p gopacket.PacketBuilder) error {
+	s := &SLARP{}
+	return decodingLayerDecoder(s, data, p)
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func serializeOverLink(t *testing.T, ls ...gopacket.SerializableLayer) []byte {
+	ip := &IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 1000, DstPort: 2000}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	ls = append(ls, ip, udp, gopacket.Payload("hello"))
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCiscoHDLC(t *testing.T) {
+	data := serializeOverLink(t, &CiscoHDLC{Address: CiscoHDLCAddressUnicast, Protocol: EthernetTypeIPv4})
+	if !bytes.Equal(data[:4], []byte{0x0f, 0x00, 0x08, 0x00}) {
+		t.Fatalf("header %x", data[:4])
+	}
+	for _, lt := range []LinkType{LinkTypeC_HDLC, LinkTypePPP_HDLC} {
+		p := gopacket.NewPacket(data, lt, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatalf("%v: %v", lt, p.ErrorLayer().Error())
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeCiscoHDLC, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+		c := p.Layer(LayerTypeCiscoHDLC).(*CiscoHDLC)
+		if c.Address != CiscoHDLCAddressUnicast || c.Control != 0 || c.Protocol != EthernetTypeIPv4 {
+			t.Errorf("%v: got %+v", lt, c)
+		}
+	}
+
+	p := gopacket.NewPacket(data[:3], LinkTypeC_HDLC, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated header")
+	}
+}
+
+func TestPPPHDLC(t *testing.T) {
+	data := serializeOverLink(t, &PPP{PPPType: PPPTypeIPv4, HasPPTPHeader: true})
+	if !bytes.Equal(data[:4], []byte{0xff, 0x03, 0x00, 0x21}) {
+		t.Fatalf("header %x", data[:4])
+	}
+	p := gopacket.NewPacket(data, LinkTypePPP_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	// Address and control field compression.
+	p = gopacket.NewPacket(data[2:], LinkTypePPP_HDLC, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	for _, short := range [][]byte{{}, {0xff, 0x03}, {0xff, 0x03, 0x00}} {
+		p := gopacket.NewPacket(short, LinkTypePPP_HDLC, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("no error decoding %x", short)
+		}
+	}
+}
+
+func TestSLARP(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		data  []byte
+		slarp SLARP
+	}{
+		{
+			name: "keepalive",
+			data: []byte{
+				0x8f, 0x00, 0x80, 0x35,
+				0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x00, 0x00, 0x00, 0x29,
+				0xff, 0xff, 0x00, 0x01, 0xe2, 0x40,
+			},
+			slarp: SLARP{Code: SLARPCodeKeepalive, MySequence: 42, YourSequence: 41, Reliability: 0xffff, Time: 123456},
+		},
+		{
+			name: "reply",
+			data: []byte{
+				0x8f, 0x00, 0x80, 0x35,
+				0x00, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xfc,
+				0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+			},
+			slarp: SLARP{Code: SLARPCodeReply, Address: net.IP{10, 0, 0, 1}, Mask: net.IP{255, 255, 255, 252}, Reliability: 0xffff},
+		},
+	} {
+		p := gopacket.NewPacket(test.data, LinkTypeC_HDLC, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeCiscoHDLC, LayerTypeSLARP}, t)
+		got := p.Layer(LayerTypeSLARP).(*SLARP)
+		if got.Code != test.slarp.Code || !got.Address.Equal(test.slarp.Address) || !got.Mask.Equal(test.slarp.Mask) ||
+			got.MySequence != test.slarp.MySequence || got.YourSequence != test.slarp.YourSequence ||
+			got.Reliability != test.slarp.Reliability || got.Time != test.slarp.Time {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.slarp)
+		}
+
+		buf := gopacket.NewSerializeBuffer()
+		hdlc := &CiscoHDLC{Address: CiscoHDLCAddressBroadcast, Protocol: CiscoHDLCProtocolSLARP}
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, hdlc, &test.slarp); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(buf.Bytes(), test.data) {
+			t.Errorf("%s: serialized %x, want %x", test.name, buf.Bytes(), test.data)
+		}
+
+		p = gopacket.NewPacket(test.data[:len(test.data)-1], LinkTypeC_HDLC, gopacket.Default)
+		if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+			t.Errorf("%s: no error decoding truncated packet", test.name)
+		}
+	}
+
+	// Protocols gopacket does not decode are left as payload.
+	p := gopacket.NewPacket([]byte{0x0f, 0x00, 0x88, 0xb5, 0x01, 0x02}, LinkTypeC_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCiscoHDLC, gopacket.LayerTypePayload}, t)
+}
@@ -0,0 +1,759 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// DCCPType is the type of a DCCP packet.
+type DCCPType uint8
+
+// DCCP packet types (RFC 4340).
+const (
+	DCCPTypeRequest  DCCPType = 0
+	DCCPTypeResponse DCCPType = 1
+	DCCPTypeData     DCCPType = 2
+	DCCPTypeAck      DCCPType = 3
+	DCCPTypeDataAck  DCCPType = 4
+	DCCPTypeCloseReq DCCPType = 5
+	DCCPTypeClose    DCCPType = 6
+	DCCPTypeReset    DCCPType = 7
+	DCCPTypeSync     DCCPType = 8
+	DCCPTypeSyncAck  DCCPType = 9
+)
+
+func (t DCCPType) String() string {
+	switch t {
+	case DCCPTypeRequest:
+		return "Request"
+	case DCCPTypeResponse:
+		return "Response"
+	case DCCPTypeData:
+		return "Data"
+	case DCCPTypeAck:
+		return "Ack"
+	case DCCPTypeDataAck:
+		return "DataAck"
+	case DCCPTypeCloseReq:
+		return "CloseReq"
+	case DCCPTypeClose:
+		return "Close"
+	case DCCPTypeReset:
+		return "Reset"
+	case DCCPTypeSync:
+		return "Sync"
+	case DCCPTypeSyncAck:
+		return "SyncAck"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// DCCPResetCode tells why a DCCP connection was reset.
+type DCCPResetCode uint8
+
+// DCCP reset codes (RFC 4340).
+const (
+	DCCPResetUnspecified       DCCPResetCode = 0
+	DCCPResetClosed            DCCPResetCode = 1
+	DCCPResetAborted           DCCPResetCode = 2
+	DCCPResetNoConnection      DCCPResetCode = 3
+	DCCPResetPacketError       DCCPResetCode = 4
+	DCCPResetOptionError       DCCPResetCode = 5
+	DCCPResetMandatoryError    DCCPResetCode = 6
+	DCCPResetConnectionRefused DCCPResetCode = 7
+	DCCPResetBadServiceCode    DCCPResetCode = 8
+	DCCPResetTooBusy           DCCPResetCode = 9
+	DCCPResetBadInitCookie     DCCPResetCode = 10
+	DCCPResetAggressionPenalty DCCPResetCode = 11
+)
+
+func (c DCCPResetCode) String() string {
+	switch c {
+	case DCCPResetUnspecified:
+		return "Unspecified"
+	case DCCPResetClosed:
+		return "Closed"
+	case DCCPResetAborted:
+		return "Aborted"
+	case DCCPResetNoConnection:
+		return "NoConnection"
+	case DCCPResetPacketError:
+		return "PacketError"
+	case DCCPResetOptionError:
+		return "OptionError"
+	case DCCPResetMandatoryError:
+		return "MandatoryError"
+	case DCCPResetConnectionRefused:
+		return "ConnectionRefused"
+	case DCCPResetBadServiceCode:
+		return "BadServiceCode"
+	case DCCPResetTooBusy:
+		return "TooBusy"
+	case DCCPResetBadInitCookie:
+		return "BadInitCookie"
+	case DCCPResetAggressionPenalty:
+		return "AggressionPenalty"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// DCCPOptionType is the type of a DCCP option. Types below 32 are single
+// bytes, without length or data.
+type DCCPOptionType uint8
+
+// DCCP option types (RFC 4340).
+const (
+	DCCPOptionPadding       DCCPOptionType = 0
+	DCCPOptionMandatory     DCCPOptionType = 1
+	DCCPOptionSlowReceiver  DCCPOptionType = 2
+	DCCPOptionChangeL       DCCPOptionType = 32
+	DCCPOptionConfirmL      DCCPOptionType = 33
+	DCCPOptionChangeR       DCCPOptionType = 34
+	DCCPOptionConfirmR      DCCPOptionType = 35
+	DCCPOptionInitCookie    DCCPOptionType = 36
+	DCCPOptionNDPCount      DCCPOptionType = 37
+	DCCPOptionAckVector0    DCCPOptionType = 38
+	DCCPOptionAckVector1    DCCPOptionType = 39
+	DCCPOptionDataDropped   DCCPOptionType = 40
+	DCCPOptionTimestamp     DCCPOptionType = 41
+	DCCPOptionTimestampEcho DCCPOptionType = 42
+	DCCPOptionElapsedTime   DCCPOptionType = 43
+	DCCPOptionDataChecksum  DCCPOptionType = 44
+)
+
+func (t DCCPOptionType) String() string {
+	switch t {
+	case DCCPOptionPadding:
+		return "Padding"
+	case DCCPOptionMandatory:
+		return "Mandatory"
+	case DCCPOptionSlowReceiver:
+		return "SlowReceiver"
+	case DCCPOptionChangeL:
+		return "ChangeL"
+	case DCCPOptionConfirmL:
+		return "ConfirmL"
+	case DCCPOptionChangeR:
+		return "ChangeR"
+	case DCCPOptionConfirmR:
+		return "ConfirmR"
+	case DCCPOptionInitCookie:
+		return "InitCookie"
+	case DCCPOptionNDPCount:
+		return "NDPCount"
+	case DCCPOptionAckVector0:
+		return "AckVector0"
+	case DCCPOptionAckVector1:
+		return "AckVector1"
+	case DCCPOptionDataDropped:
+		return "DataDropped"
+	case DCCPOptionTimestamp:
+		return "Timestamp"
+	case DCCPOptionTimestampEcho:
+		return "TimestampEcho"
+	case DCCPOptionElapsedTime:
+		return "ElapsedTime"
+	case DCCPOptionDataChecksum:
+		return "DataChecksum"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// DCCPOption is an option of a DCCP header.
+type DCCPOption struct {
+	OptionType   DCCPOptionType
+	OptionLength uint8
+	OptionData   []byte
+}
+
+func (o DCCPOption) String() string {
+	hd := hex.EncodeToString(o.OptionData)
+	if len(hd) > 0 {
+		hd = " 0x" + hd
+	}
+	return fmt.Sprintf("DCCPOption(%s:%s)", o.OptionType, hd)
+}
+
+func (o DCCPOption) length() int {
+	if o.OptionType < 32 {
+		return 1
+	}
+	return 2 + len(o.OptionData)
+}
+
+// DCCP is the layer for DCCP headers (RFC 4340).
+type DCCP struct {
+	BaseLayer
+	SrcPort, DstPort DCCPPort
+	// DataOffset is the length of the header, options included, in 32-bit
+	// words.
+	DataOffset uint8
+	CCVal      uint8
+	// CsCov is the checksum coverage: the checksum covers the header and
+	// the first (CsCov-1)*4 bytes of application data, or all of it if
+	// CsCov is zero.
+	CsCov    uint8
+	Checksum uint16
+	Type     DCCPType
+	// ExtendedSeq is set if SequenceNumber and AckNumber are 48 bits long,
+	// rather than 24 bits.
+	ExtendedSeq    bool
+	SequenceNumber uint64
+	// AckNumber is only present in packets other than requests and data,
+	// see HasAck.
+	AckNumber uint64
+	// ServiceCode is only present in requests and responses.
+	ServiceCode uint32
+	// ResetCode and ResetData are only present in resets.
+	ResetCode    DCCPResetCode
+	ResetData    [3]byte
+	Options      []DCCPOption
+	Padding      []byte
+	sPort, dPort []byte
+	tcpipchecksum
+}
+
+// LayerType returns gopacket.LayerTypeDCCP
+func (d *DCCP) LayerType() gopacket.LayerType { return LayerTypeDCCP }
+
+// HasAck returns whether packets of the type of d have an acknowledgement
+// number.
+func (d *DCCP) HasAck() bool {
+	return d.Type != DCCPTypeRequest && d.Type != DCCPTypeData
+}
+
+// headerLength returns the length of the header without options.
+func (d *DCCP) headerLength() int {
+	length := 12
+	if d.ExtendedSeq {
+		length = 16
+	}
+	if d.HasAck() {
+		if d.ExtendedSeq {
+			length += 8
+		} else {
+			length += 4
+		}
+	}
+	switch d.Type {
+	case DCCPTypeRequest, DCCPTypeResponse, DCCPTypeReset:
+		length += 4
+	}
+	return length
+}
+
+func decodeDCCP(data []byte, p gopacket.PacketBuilder) error {
+	dccp := &DCCP{}
+	err := dccp.DecodeFromBytes(data, p)
+	p.AddLayer(dccp)
+	p.SetTransportLayer(dccp)
+	if err != nil {
+		return err
+	}
+	return p.NextDecoder(gopacket.LayerTypePayload)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (d *DCCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return fmt.Errorf("Invalid DCCP header. Length %d less than 12", len(data))
+	}
+	d.SrcPort = DCCPPort(binary.BigEndian.Uint16(data[0:2]))
+	d.sPort = data[0:2]
+	d.DstPort = DCCPPort(binary.BigEndian.Uint16(data[2:4]))
+	d.dPort = data[2:4]
+	d.DataOffset = data[4]
+	d.CCVal = data[5] >> 4
+	d.CsCov = data[5] & 0xf
+	d.Checksum = binary.BigEndian.Uint16(data[6:8])
+	d.Type = DCCPType(data[8] >> 1 & 0xf)
+	d.ExtendedSeq = data[8]&0x1 != 0
+
+	offset := int(d.DataOffset) * 4
+	headerLength := d.headerLength()
+	if offset < headerLength {
+		return fmt.Errorf("Invalid DCCP data offset %d < %d", offset, headerLength)
+	}
+	if offset > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("DCCP data offset %d greater than packet length %d", offset, len(data))
+	}
+
+	start := 12
+	if d.ExtendedSeq {
+		d.SequenceNumber = uint64(binary.BigEndian.Uint16(data[10:12]))<<32 | uint64(binary.BigEndian.Uint32(data[12:16]))
+		start = 16
+	} else {
+		d.SequenceNumber = uint64(binary.BigEndian.Uint32(data[8:12]) & 0xffffff)
+	}
+	d.AckNumber = 0
+	if d.HasAck() {
+		if d.ExtendedSeq {
+			d.AckNumber = uint64(binary.BigEndian.Uint16(data[start+2:start+4]))<<32 | uint64(binary.BigEndian.Uint32(data[start+4:start+8]))
+			start += 8
+		} else {
+			d.AckNumber = uint64(binary.BigEndian.Uint32(data[start:start+4]) & 0xffffff)
+			start += 4
+		}
+	}
+	d.ServiceCode, d.ResetCode, d.ResetData = 0, 0, [3]byte{}
+	switch d.Type {
+	case DCCPTypeRequest, DCCPTypeResponse:
+		d.ServiceCode = binary.BigEndian.Uint32(data[start : start+4])
+		start += 4
+	case DCCPTypeReset:
+		d.ResetCode = DCCPResetCode(data[start])
+		copy(d.ResetData[:], data[start+1:start+4])
+		start += 4
+	}
+
+	d.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:]}
+	d.Options = d.Options[:0]
+	d.Padding = nil
+	for opts := data[start:offset]; len(opts) > 0; {
+		o := DCCPOption{OptionType: DCCPOptionType(opts[0]), OptionLength: 1}
+		if o.OptionType == DCCPOptionPadding && allZero(opts) {
+			d.Padding = opts
+			break
+		}
+		if o.OptionType >= 32 {
+			if len(opts) < 2 {
+				return errors.New("DCCP option too short")
+			}
+			o.OptionLength = opts[1]
+			if o.OptionLength < 2 || int(o.OptionLength) > len(opts) {
+				return fmt.Errorf("Invalid DCCP option length %d", o.OptionLength)
+			}
+			o.OptionData = opts[2:o.OptionLength]
+		}
+		d.Options = append(d.Options, o)
+		opts = opts[o.OptionLength:]
+	}
+	return nil
+}
+
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// DataOffset and Padding are computed from the options if opts.FixLengths is
+// set or DataOffset is zero, which is never valid. The checksum only covers
+// the data CsCov asks for.
+func (d *DCCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	headerLength := d.headerLength()
+	optionLength := 0
+	for _, o := range d.Options {
+		optionLength += o.length()
+	}
+	if opts.FixLengths || d.DataOffset == 0 {
+		d.Padding = nil
+		if rem := optionLength % 4; rem != 0 {
+			d.Padding = lotsOfZeros[:4-rem]
+		}
+		length := headerLength + optionLength + len(d.Padding)
+		if length > 255*4 {
+			return fmt.Errorf("DCCP header too long: %d bytes", length)
+		}
+		d.DataOffset = uint8(length / 4)
+	}
+	if d.CsCov > 15 {
+		return fmt.Errorf("invalid DCCP checksum coverage %d", d.CsCov)
+	}
+	payloadLength := len(b.Bytes())
+	bytes, err := b.PrependBytes(headerLength + optionLength + len(d.Padding))
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], uint16(d.SrcPort))
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(d.DstPort))
+	bytes[4] = d.DataOffset
+	bytes[5] = d.CCVal<<4 | d.CsCov
+	bytes[8] = uint8(d.Type&0xf) << 1
+	start := 12
+	if d.ExtendedSeq {
+		bytes[8] |= 0x1
+		bytes[9] = 0
+		binary.BigEndian.PutUint16(bytes[10:12], uint16(d.SequenceNumber>>32))
+		binary.BigEndian.PutUint32(bytes[12:16], uint32(d.SequenceNumber))
+		start = 16
+	} else {
+		bytes[9] = uint8(d.SequenceNumber >> 16)
+		binary.BigEndian.PutUint16(bytes[10:12], uint16(d.SequenceNumber))
+	}
+	if d.HasAck() {
+		if d.ExtendedSeq {
+			binary.BigEndian.PutUint16(bytes[start:start+2], 0)
+			binary.BigEndian.PutUint16(bytes[start+2:start+4], uint16(d.AckNumber>>32))
+			binary.BigEndian.PutUint32(bytes[start+4:start+8], uint32(d.AckNumber))
+			start += 8
+		} else {
+			binary.BigEndian.PutUint32(bytes[start:start+4], uint32(d.AckNumber)&0xffffff)
+			start += 4
+		}
+	}
+	switch d.Type {
+	case DCCPTypeRequest, DCCPTypeResponse:
+		binary.BigEndian.PutUint32(bytes[start:start+4], d.ServiceCode)
+		start += 4
+	case DCCPTypeReset:
+		bytes[start] = uint8(d.ResetCode)
+		copy(bytes[start+1:start+4], d.ResetData[:])
+		start += 4
+	}
+	for _, o := range d.Options {
+		bytes[start] = uint8(o.OptionType)
+		if o.OptionType < 32 {
+			start++
+			continue
+		}
+		if opts.FixLengths {
+			o.OptionLength = uint8(len(o.OptionData) + 2)
+		}
+		bytes[start+1] = o.OptionLength
+		copy(bytes[start+2:], o.OptionData)
+		start += 2 + len(o.OptionData)
+	}
+	copy(bytes[start:], d.Padding)
+
+	if opts.ComputeChecksums {
+		// zero out checksum bytes in current serialization.
+		bytes[6] = 0
+		bytes[7] = 0
+		csum, err := d.computePartialChecksum(b.Bytes(), d.coverage(len(bytes), payloadLength), IPProtocolDCCP)
+		if err != nil {
+			return err
+		}
+		d.Checksum = csum
+	}
+	binary.BigEndian.PutUint16(bytes[6:], d.Checksum)
+	return nil
+}
+
+// coverage returns the number of bytes of the packet covered by its
+// checksum.
+func (d *DCCP) coverage(headerLength, payloadLength int) int {
+	if d.CsCov == 0 || int(d.CsCov-1)*4 > payloadLength {
+		return headerLength + payloadLength
+	}
+	return headerLength + int(d.CsCov-1)*4
+}
+
+// ComputeChecksum computes the checksum of the decoded packet.
+func (d *DCCP) ComputeChecksum() (uint16, error) {
+	data := append(append([]byte(nil), d.Contents...), d.Payload...)
+	if len(data) >= 8 {
+		data[6], data[7] = 0, 0
+	}
+	return d.computePartialChecksum(data, d.coverage(len(d.Contents), len(d.Payload)), IPProtocolDCCP)
+}
+
+// VerifyChecksum verifies the checksum of the decoded packet.
+func (d *DCCP) VerifyChecksum() gopacket.ChecksumResult {
+	csum, err := d.ComputeChecksum()
+	if err != nil {
+		return gopacket.ChecksumResult{LayerType: LayerTypeDCCP, Status: gopacket.ChecksumUnverifiable, Checksum: uint32(d.Checksum), Err: err}
+	}
+	return checksumResult(LayerTypeDCCP, uint32(d.Checksum), uint32(csum))
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (d *DCCP) CanDecode() gopacket.LayerClass {
+	return LayerTypeDCCP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (d *DCCP) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+// TransportFlow returns a flow based on the source and destination DCCP
+// ports.
+func (d *DCCP) TransportFlow() gopacket.Flow {
+	return gopacket.NewFlow(EndpointDCCPPort, d.sPort, d.dPort)
+}
+
+// DCCPOptionValue is a decoded DCCP option: a *DCCPFeatureOption,
+// *DCCPAckVectorOption, *DCCPTimestampOption, *DCCPTimestampEchoOption or
+// *DCCPElapsedTimeOption.
+type DCCPOptionValue interface {
+	// Option encodes the option.
+	Option() DCCPOption
+}
+
+func newDCCPOption(typ DCCPOptionType, data []byte) DCCPOption {
+	return DCCPOption{OptionType: typ, OptionLength: uint8(2 + len(data)), OptionData: data}
+}
+
+// DecodeDCCPOption decodes a DCCP option. It returns an error if o is not
+// of a supported type, or is malformed.
+func DecodeDCCPOption(o DCCPOption) (DCCPOptionValue, error) {
+	var v interface {
+		DCCPOptionValue
+		decode(typ DCCPOptionType, data []byte) error
+	}
+	switch o.OptionType {
+	case DCCPOptionChangeL, DCCPOptionConfirmL, DCCPOptionChangeR, DCCPOptionConfirmR:
+		v = &DCCPFeatureOption{}
+	case DCCPOptionAckVector0, DCCPOptionAckVector1:
+		v = &DCCPAckVectorOption{}
+	case DCCPOptionTimestamp:
+		v = &DCCPTimestampOption{}
+	case DCCPOptionTimestampEcho:
+		v = &DCCPTimestampEchoOption{}
+	case DCCPOptionElapsedTime:
+		v = &DCCPElapsedTimeOption{}
+	default:
+		return nil, fmt.Errorf("unsupported DCCP option %v", o.OptionType)
+	}
+	if err := v.decode(o.OptionType, o.OptionData); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OptionValues returns the valid options of the header, of the types
+// supported by DecodeDCCPOption.
+func (d *DCCP) OptionValues() []DCCPOptionValue {
+	var values []DCCPOptionValue
+	for _, o := range d.Options {
+		if v, err := DecodeDCCPOption(o); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// DCCPFeature is a feature negotiated by DCCP endpoints.
+type DCCPFeature uint8
+
+// DCCP features (RFC 4340).
+const (
+	DCCPFeatureCCID                DCCPFeature = 1
+	DCCPFeatureAllowShortSeqnos    DCCPFeature = 2
+	DCCPFeatureSequenceWindow      DCCPFeature = 3
+	DCCPFeatureECNIncapable        DCCPFeature = 4
+	DCCPFeatureAckRatio            DCCPFeature = 5
+	DCCPFeatureSendAckVector       DCCPFeature = 6
+	DCCPFeatureSendNDPCount        DCCPFeature = 7
+	DCCPFeatureMinChecksumCoverage DCCPFeature = 8
+	DCCPFeatureCheckDataChecksum   DCCPFeature = 9
+)
+
+func (f DCCPFeature) String() string {
+	switch f {
+	case DCCPFeatureCCID:
+		return "CCID"
+	case DCCPFeatureAllowShortSeqnos:
+		return "AllowShortSeqnos"
+	case DCCPFeatureSequenceWindow:
+		return "SequenceWindow"
+	case DCCPFeatureECNIncapable:
+		return "ECNIncapable"
+	case DCCPFeatureAckRatio:
+		return "AckRatio"
+	case DCCPFeatureSendAckVector:
+		return "SendAckVector"
+	case DCCPFeatureSendNDPCount:
+		return "SendNDPCount"
+	case DCCPFeatureMinChecksumCoverage:
+		return "MinChecksumCoverage"
+	case DCCPFeatureCheckDataChecksum:
+		return "CheckDataChecksum"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(f))
+}
+
+// DCCPFeatureOption is a Change L, Confirm L, Change R or Confirm R
+// option, negotiating the value of a feature. Values holds the preferred
+// values in order for server-priority features, such as CCID, and the
+// big-endian value for non-negotiable features, such as SequenceWindow.
+type DCCPFeatureOption struct {
+	Type    DCCPOptionType
+	Feature DCCPFeature
+	Values  []byte
+}
+
+func (o *DCCPFeatureOption) decode(typ DCCPOptionType, data []byte) error {
+	if len(data) < 1 {
+		return errors.New("DCCP feature option too short")
+	}
+	*o = DCCPFeatureOption{Type: typ, Feature: DCCPFeature(data[0]), Values: data[1:]}
+	return nil
+}
+
+// Value returns Values as a big-endian integer, the value of
+// non-negotiable features.
+func (o *DCCPFeatureOption) Value() uint64 {
+	var v uint64
+	for _, b := range o.Values {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// Option encodes the option.
+func (o *DCCPFeatureOption) Option() DCCPOption {
+	return newDCCPOption(o.Type, append([]byte{uint8(o.Feature)}, o.Values...))
+}
+
+// DCCPAckVectorState is the state of the packets of a run of an ack
+// vector.
+type DCCPAckVectorState uint8
+
+// DCCP ack vector states.
+const (
+	DCCPAckVectorReceived          DCCPAckVectorState = 0
+	DCCPAckVectorReceivedECNMarked DCCPAckVectorState = 1
+	DCCPAckVectorNotYetReceived    DCCPAckVectorState = 3
+)
+
+func (s DCCPAckVectorState) String() string {
+	switch s {
+	case DCCPAckVectorReceived:
+		return "Received"
+	case DCCPAckVectorReceivedECNMarked:
+		return "ReceivedECNMarked"
+	case DCCPAckVectorNotYetReceived:
+		return "NotYetReceived"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// DCCPAckVectorRun is a run of an ack vector: Length+1 consecutive
+// packets in the same state, going back from the acknowledgement number.
+type DCCPAckVectorRun struct {
+	State  DCCPAckVectorState
+	Length uint8
+}
+
+// DCCPAckVectorOption is an ack vector option, reporting the state of the
+// packets up to the acknowledgement number. Nonce is the sum of the ECN
+// nonces of the packets received, which selects the option type.
+type DCCPAckVectorOption struct {
+	Nonce uint8
+	Runs  []DCCPAckVectorRun
+}
+
+func (o *DCCPAckVectorOption) decode(typ DCCPOptionType, data []byte) error {
+	o.Nonce = uint8(typ - DCCPOptionAckVector0)
+	o.Runs = make([]DCCPAckVectorRun, len(data))
+	for i, b := range data {
+		o.Runs[i] = DCCPAckVectorRun{State: DCCPAckVectorState(b >> 6), Length: b & 0x3f}
+	}
+	return nil
+}
+
+// Option encodes the option.
+func (o *DCCPAckVectorOption) Option() DCCPOption {
+	data := make([]byte, len(o.Runs))
+	for i, r := range o.Runs {
+		data[i] = uint8(r.State)<<6 | r.Length&0x3f
+	}
+	return newDCCPOption(DCCPOptionAckVector0+DCCPOptionType(o.Nonce&0x1), data)
+}
+
+// DCCPTimestampOption is a timestamp option, in units of 10 microseconds.
+type DCCPTimestampOption struct {
+	Timestamp uint32
+}
+
+func (o *DCCPTimestampOption) decode(typ DCCPOptionType, data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("invalid DCCP timestamp option length %d", len(data)+2)
+	}
+	o.Timestamp = binary.BigEndian.Uint32(data)
+	return nil
+}
+
+// Option encodes the option.
+func (o *DCCPTimestampOption) Option() DCCPOption {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, o.Timestamp)
+	return newDCCPOption(DCCPOptionTimestamp, data)
+}
+
+// putDCCPElapsedTime appends an elapsed time, in as few bytes as it fits
+// in, to data.
+func putDCCPElapsedTime(data []byte, elapsed uint32) []byte {
+	if elapsed <= 0xffff {
+		return append(data, byte(elapsed>>8), byte(elapsed))
+	}
+	return append(data, byte(elapsed>>24), byte(elapsed>>16), byte(elapsed>>8), byte(elapsed))
+}
+
+// DCCPTimestampEchoOption is a timestamp echo option, echoing a timestamp
+// received Elapsed units of 10 microseconds ago. A zero Elapsed time is
+// left out of the option.
+type DCCPTimestampEchoOption struct {
+	Timestamp uint32
+	Elapsed   uint32
+}
+
+func (o *DCCPTimestampEchoOption) decode(typ DCCPOptionType, data []byte) error {
+	switch len(data) {
+	case 4:
+		o.Elapsed = 0
+	case 6:
+		o.Elapsed = uint32(binary.BigEndian.Uint16(data[4:]))
+	case 8:
+		o.Elapsed = binary.BigEndian.Uint32(data[4:])
+	default:
+		return fmt.Errorf("invalid DCCP timestamp echo option length %d", len(data)+2)
+	}
+	o.Timestamp = binary.BigEndian.Uint32(data)
+	return nil
+}
+
+// Option encodes the option.
+func (o *DCCPTimestampEchoOption) Option() DCCPOption {
+	data := make([]byte, 4, 8)
+	binary.BigEndian.PutUint32(data, o.Timestamp)
+	if o.Elapsed != 0 {
+		data = putDCCPElapsedTime(data, o.Elapsed)
+	}
+	return newDCCPOption(DCCPOptionTimestampEcho, data)
+}
+
+// DCCPElapsedTimeOption is an elapsed time option, the time in units of 10
+// microseconds between the receipt of the acknowledged packet and the
+// sending of the acknowledgement.
+type DCCPElapsedTimeOption struct {
+	Elapsed uint32
+}
+
+func (o *DCCPElapsedTimeOption) decode(typ DCCPOptionType, data []byte) error {
+	switch len(data) {
+	case 2:
+		o.Elapsed = uint32(binary.BigEndian.Uint16(data))
+	case 4:
+		o.Elapsed = binary.BigEndian.Uint32(data)
+	default:
+		return fmt.Errorf("invalid DCCP elapsed time option length %d", len(data)+2)
+	}
+	return nil
+}
+
+// Option encodes the option.
+func (o *DCCPElapsedTimeOption) Option() DCCPOption {
+	return newDCCPOption(DCCPOptionElapsedTime, putDCCPElapsedTime(nil, o.Elapsed))
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A DCCP Request from port 40000 to port 5001 with extended sequence number
+// 0x0102030405, service code 42 and a Change L option asking for CCID 2.
+var testDCCPRequest = []byte{
+	0x9c, 0x40, 0x13, 0x89, // ports
+	0x06, 0x00, 0x00, 0x00, // data offset, CCVal/CsCov, checksum
+	0x01, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, // type, X, sequence number
+	0x00, 0x00, 0x00, 0x2a, // service code
+	0x20, 0x04, 0x01, 0x02, // Change L(CCID, 2)
+}
+
+func TestDCCPRequest(t *testing.T) {
+	p := gopacket.NewPacket(testDCCPRequest, LayerTypeDCCP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	dccp := p.Layer(LayerTypeDCCP).(*DCCP)
+	if dccp.SrcPort != 40000 || dccp.DstPort != 5001 || dccp.Type != DCCPTypeRequest || !dccp.ExtendedSeq ||
+		dccp.SequenceNumber != 0x0102030405 || dccp.ServiceCode != 42 || dccp.HasAck() {
+		t.Errorf("Request decoded as %+v", dccp)
+	}
+	want := []DCCPOptionValue{&DCCPFeatureOption{Type: DCCPOptionChangeL, Feature: DCCPFeatureCCID, Values: []byte{2}}}
+	if got := dccp.OptionValues(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got options %+v, want %+v", got, want)
+	}
+	if got, want := dccp.TransportFlow(), gopacket.NewFlow(EndpointDCCPPort, []byte{0x9c, 0x40}, []byte{0x13, 0x89}); got != want {
+		t.Errorf("got flow %v, want %v", got, want)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, dccp); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testDCCPRequest) {
+		t.Errorf("serialized as %x, want %x", buf.Bytes(), testDCCPRequest)
+	}
+}
+
+func TestDCCPSerialize(t *testing.T) {
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolDCCP, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}}
+	opts := []DCCPOptionValue{
+		&DCCPAckVectorOption{Nonce: 1, Runs: []DCCPAckVectorRun{{DCCPAckVectorReceived, 5}, {DCCPAckVectorNotYetReceived, 1}, {DCCPAckVectorReceivedECNMarked, 0}}},
+		&DCCPTimestampOption{Timestamp: 123456},
+		&DCCPTimestampEchoOption{Timestamp: 654321, Elapsed: 70000},
+		&DCCPElapsedTimeOption{Elapsed: 12},
+		&DCCPFeatureOption{Type: DCCPOptionConfirmR, Feature: DCCPFeatureSequenceWindow, Values: []byte{0, 0, 0, 0, 0, 100}},
+	}
+	for _, test := range []struct {
+		name  string
+		dccp  *DCCP
+		cscov uint8
+	}{
+		{"short", &DCCP{Type: DCCPTypeDataAck, SequenceNumber: 0xabcdef, AckNumber: 0x123456}, 0},
+		{"extended", &DCCP{Type: DCCPTypeDataAck, ExtendedSeq: true, SequenceNumber: 1 << 40, AckNumber: 1<<40 - 1}, 0},
+		{"partial", &DCCP{Type: DCCPTypeData, ExtendedSeq: true, SequenceNumber: 7}, 2},
+		{"reset", &DCCP{Type: DCCPTypeReset, ExtendedSeq: true, SequenceNumber: 8, AckNumber: 9, ResetCode: DCCPResetBadServiceCode, ResetData: [3]byte{1, 2, 3}}, 0},
+	} {
+		d := test.dccp
+		d.SrcPort, d.DstPort, d.CsCov, d.CCVal = 40000, 5001, test.cscov, 3
+		for _, o := range opts {
+			d.Options = append(d.Options, o.Option())
+		}
+		d.Options = append(d.Options, DCCPOption{OptionType: DCCPOptionSlowReceiver})
+		if err := d.SetNetworkLayerForChecksum(ip); err != nil {
+			t.Fatal(err)
+		}
+		buf := gopacket.NewSerializeBuffer()
+		payload := gopacket.Payload("0123456789")
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, d, payload); err != nil {
+			t.Fatal(test.name, err)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal(test.name, "failed to decode packet:", p.ErrorLayer().Error())
+		}
+		got, ok := p.Layer(LayerTypeDCCP).(*DCCP)
+		if !ok {
+			t.Fatal(test.name, "no DCCP layer")
+		}
+		if got.Type != d.Type || got.ExtendedSeq != d.ExtendedSeq || got.SequenceNumber != d.SequenceNumber || got.AckNumber != d.AckNumber ||
+			got.ResetCode != d.ResetCode || got.ResetData != d.ResetData || got.CsCov != d.CsCov || got.CCVal != 3 || got.DataOffset != d.DataOffset {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, d)
+		}
+		if !reflect.DeepEqual(got.OptionValues(), opts) {
+			t.Errorf("%s: got options %+v, want %+v", test.name, got.OptionValues(), opts)
+		}
+		if len(got.Options) != len(opts)+1 || got.Options[len(opts)].OptionType != DCCPOptionSlowReceiver {
+			t.Errorf("%s: got options %v", test.name, got.Options)
+		}
+		if string(got.Payload) != string(payload) {
+			t.Errorf("%s: got payload %q", test.name, got.Payload)
+		}
+		got.SetNetworkLayerForChecksum(p.NetworkLayer())
+		if csum, err := got.ComputeChecksum(); err != nil || csum != d.Checksum || got.Checksum != d.Checksum {
+			t.Errorf("%s: checksum %#x, computed %#x (%v), want %#x", test.name, got.Checksum, csum, err, d.Checksum)
+		}
+
+		// Only the first (CsCov-1)*4 bytes of the payload are covered by the
+		// checksum.
+		got.Payload[len(got.Payload)-1] ^= 0xff
+		csum, _ := got.ComputeChecksum()
+		if covered := test.cscov != 0; (csum == d.Checksum) != covered {
+			t.Errorf("%s: checksum changed %t with CsCov %d", test.name, csum != d.Checksum, test.cscov)
+		}
+	}
+}
+
+func TestDCCPOptionErrors(t *testing.T) {
+	for _, o := range []DCCPOption{
+		{OptionType: DCCPOptionChangeL, OptionLength: 2},
+		{OptionType: DCCPOptionTimestamp, OptionLength: 5, OptionData: []byte{1, 2, 3}},
+		{OptionType: DCCPOptionTimestampEcho, OptionLength: 7, OptionData: []byte{1, 2, 3, 4, 5}},
+		{OptionType: DCCPOptionElapsedTime, OptionLength: 5, OptionData: []byte{1, 2, 3}},
+		{OptionType: DCCPOptionInitCookie, OptionLength: 3, OptionData: []byte{1}},
+	} {
+		if v, err := DecodeDCCPOption(o); err == nil {
+			t.Errorf("%v decoded as %+v", o, v)
+		}
+	}
+
+	// The option length runs past the header.
+	data := append([]byte(nil), testDCCPRequest...)
+	data[21] = 5
+	p := gopacket.NewPacket(data, LayerTypeDCCP, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("decoded an option running past the header")
+	}
+}
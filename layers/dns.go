@@ -633,6 +633,11 @@ type DNSQuestion struct {
 	Class DNSClass
 }
 
+// NameString returns Name as a string without copying it. See
+// gopacket.UnsafeString for how long the result stays valid; for DNS this
+// is until the DNS layer decodes another packet.
+func (q *DNSQuestion) NameString() string { return gopacket.UnsafeString(q.Name) }
+
 func (q *DNSQuestion) decode(data []byte, offset int, df gopacket.DecodeFeedback, buffer *[]byte) (int, error) {
 	name, endq, err := decodeName(data, offset, buffer, 1)
 	if err != nil {
@@ -706,6 +711,11 @@ type DNSResourceRecord struct {
 	TXT []byte
 }
 
+// NameString returns Name as a string without copying it. See
+// gopacket.UnsafeString for how long the result stays valid; for DNS this
+// is until the DNS layer decodes another packet.
+func (rr *DNSResourceRecord) NameString() string { return gopacket.UnsafeString(rr.Name) }
+
 // decode decodes the resource record, returning the total length of the record.
 func (rr *DNSResourceRecord) decode(data []byte, offset int, df gopacket.DecodeFeedback, buffer *[]byte) (int, error) {
 	name, endq, err := decodeName(data, offset, buffer, 1)
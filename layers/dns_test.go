@@ -72,6 +72,18 @@ func TestPacketDNSRegression(t *testing.T) {
 	}
 	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeDNS}, t)
 }
+func TestDNSNameString(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDNSRegression, LinkTypeEthernet, testDecodeOptions)
+	dns := p.Layer(LayerTypeDNS).(*DNS)
+	q := &dns.Questions[0]
+	if got := q.NameString(); got != "picslife.ru" {
+		t.Errorf("got name %q", got)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { q.NameString() }); allocs != 0 {
+		t.Errorf("NameString allocated %v times", allocs)
+	}
+}
+
 func BenchmarkDecodePacketDNSRegression(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		gopacket.NewPacket(testPacketDNSRegression, LinkTypeEthernet, gopacket.NoCopy)
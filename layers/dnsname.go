@@ -0,0 +1,150 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/idna"
+)
+
+// DNSTrailingDot selects how CanonicalDNSName treats the trailing dot of a
+// fully qualified name.
+type DNSTrailingDot uint8
+
+const (
+	// DNSTrailingDotKeep leaves the trailing dot as it is.
+	DNSTrailingDotKeep DNSTrailingDot = iota
+	// DNSTrailingDotStrip removes a trailing dot. This matches how the DNS
+	// layer decodes names.
+	DNSTrailingDotStrip
+	// DNSTrailingDotAdd makes sure the name ends in a dot.
+	DNSTrailingDotAdd
+)
+
+// Limits from RFC 1035 section 2.3.4.
+const (
+	dnsMaxLabelLength = 63
+	dnsMaxNameLength  = 255
+)
+
+// CanonicalDNSName returns a copy of name in canonical form: ASCII letters
+// are folded to lower case as required by RFC 4343, all other bytes are left
+// alone, and the trailing dot is handled according to dot. The root name
+// is returned as "." unless dot is DNSTrailingDotStrip.
+func CanonicalDNSName(name []byte, dot DNSTrailingDot) []byte {
+	out := make([]byte, len(name), len(name)+1)
+	for i, c := range name {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out[i] = c
+	}
+	switch dot {
+	case DNSTrailingDotStrip:
+		out = bytes.TrimSuffix(out, []byte{'.'})
+	case DNSTrailingDotAdd:
+		if len(out) == 0 || out[len(out)-1] != '.' {
+			out = append(out, '.')
+		}
+	}
+	return out
+}
+
+// DNSNameEqual reports whether a and b are the same DNS name: equal up to
+// ASCII case (RFC 4343) and a trailing dot.
+func DNSNameEqual(a, b []byte) bool {
+	a = bytes.TrimSuffix(a, []byte{'.'})
+	b = bytes.TrimSuffix(b, []byte{'.'})
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitDNSName splits a name as decoded by the DNS layer into its labels.
+// A trailing dot is ignored and the root name yields no labels. Empty or
+// over-long labels and over-long names are rejected.
+func SplitDNSName(name []byte) ([][]byte, error) {
+	name = bytes.TrimSuffix(name, []byte{'.'})
+	if len(name) == 0 {
+		return nil, nil
+	}
+	// Wire length is one length byte per label plus the root label.
+	if len(name)+2 > dnsMaxNameLength {
+		return nil, fmt.Errorf("DNS name too long: %d bytes", len(name))
+	}
+	labels := bytes.Split(name, []byte{'.'})
+	for _, l := range labels {
+		if len(l) == 0 {
+			return nil, fmt.Errorf("DNS name %q has an empty label", name)
+		}
+		if len(l) > dnsMaxLabelLength {
+			return nil, fmt.Errorf("DNS label too long: %d bytes", len(l))
+		}
+	}
+	return labels, nil
+}
+
+// JoinDNSLabels joins labels into a name in the form used by the DNS layer,
+// without a trailing dot. Labels must not be empty, contain dots, or exceed
+// the label and name length limits of RFC 1035.
+func JoinDNSLabels(labels [][]byte) ([]byte, error) {
+	var out []byte
+	wire := 1
+	for i, l := range labels {
+		if len(l) == 0 {
+			return nil, errors.New("empty DNS label")
+		}
+		if len(l) > dnsMaxLabelLength {
+			return nil, fmt.Errorf("DNS label too long: %d bytes", len(l))
+		}
+		if bytes.IndexByte(l, '.') >= 0 {
+			return nil, fmt.Errorf("DNS label %q contains a dot", l)
+		}
+		wire += 1 + len(l)
+		if wire > dnsMaxNameLength {
+			return nil, errors.New("DNS name too long")
+		}
+		if i > 0 {
+			out = append(out, '.')
+		}
+		out = append(out, l...)
+	}
+	return out, nil
+}
+
+// dnsIDNA converts internationalized domain names as idna.Lookup does, but
+// allows underscores, which DNS names like those of SRV records hold.
+var dnsIDNA = idna.New(idna.MapForLookup(), idna.Transitional(true), idna.BidiRule(), idna.StrictDomainName(false))
+
+// DNSNameToUnicode converts the punycode ("xn--") labels of name to Unicode
+// for display, applying the IDNA2008 mapping and validation used for
+// lookups (RFC 5891, UTS #46), so other labels are lower cased.
+func DNSNameToUnicode(name string) (string, error) {
+	return dnsIDNA.ToUnicode(name)
+}
+
+// DNSNameToASCII converts the non-ASCII labels of name to punycode, applying
+// the IDNA2008 mapping and validation used for lookups (RFC 5891, UTS #46).
+func DNSNameToASCII(name string) (string, error) {
+	return dnsIDNA.ToASCII(name)
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalDNSName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		dot  DNSTrailingDot
+		want string
+	}{
+		{"WWW.Example.COM", DNSTrailingDotKeep, "www.example.com"},
+		{"WWW.Example.COM.", DNSTrailingDotStrip, "www.example.com"},
+		{"www.example.com", DNSTrailingDotAdd, "www.example.com."},
+		{"www.example.com.", DNSTrailingDotAdd, "www.example.com."},
+		{"", DNSTrailingDotAdd, "."},
+		// Only ASCII letters are folded.
+		{"\xc3\x9c.Example", DNSTrailingDotKeep, "\xc3\x9c.example"},
+	} {
+		if got := string(CanonicalDNSName([]byte(test.in), test.dot)); got != test.want {
+			t.Errorf("CanonicalDNSName(%q, %d) = %q, want %q", test.in, test.dot, got, test.want)
+		}
+	}
+	if !DNSNameEqual([]byte("Example.COM."), []byte("example.com")) {
+		t.Error("names differing in case and trailing dot are not equal")
+	}
+	if DNSNameEqual([]byte("example.com"), []byte("example.org")) {
+		t.Error("different names are equal")
+	}
+}
+
+func TestSplitJoinDNSName(t *testing.T) {
+	labels, err := SplitDNSName([]byte("www.example.com."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || string(labels[1]) != "example" {
+		t.Errorf("got labels %q", labels)
+	}
+	name, err := JoinDNSLabels(labels)
+	if err != nil || string(name) != "www.example.com" {
+		t.Errorf("got name %q, %v", name, err)
+	}
+	if labels, err := SplitDNSName([]byte(".")); err != nil || len(labels) != 0 {
+		t.Errorf("root name gave %q, %v", labels, err)
+	}
+	for _, bad := range []string{"a..b", strings.Repeat("a", 64) + ".com", strings.Repeat("abcdefg.", 32) + "com"} {
+		if _, err := SplitDNSName([]byte(bad)); err == nil {
+			t.Errorf("SplitDNSName(%q) succeeded", bad)
+		}
+	}
+	if _, err := JoinDNSLabels([][]byte{[]byte("a.b"), []byte("c")}); err == nil {
+		t.Error("JoinDNSLabels accepted a label containing a dot")
+	}
+	if _, err := JoinDNSLabels([][]byte{[]byte("a"), nil}); err == nil {
+		t.Error("JoinDNSLabels accepted an empty label")
+	}
+}
+
+func TestDNSNameIDNA(t *testing.T) {
+	for _, test := range []struct {
+		unicode, ascii string
+	}{
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		{"www.example.com", "www.example.com"},
+		{"_sip._tcp.example.com", "_sip._tcp.example.com"},
+	} {
+		ascii, err := DNSNameToASCII(test.unicode)
+		if err != nil || ascii != test.ascii {
+			t.Errorf("DNSNameToASCII(%q) = %q, %v, want %q", test.unicode, ascii, err, test.ascii)
+		}
+		unicode, err := DNSNameToUnicode(test.ascii)
+		if err != nil || unicode != test.unicode {
+			t.Errorf("DNSNameToUnicode(%q) = %q, %v, want %q", test.ascii, unicode, err, test.unicode)
+		}
+	}
+	// Names are mapped before conversion.
+	if a, err := DNSNameToASCII("BÜCHER.example"); err != nil || a != "xn--bcher-kva.example" {
+		t.Errorf("got %q, %v", a, err)
+	}
+	if u, err := DNSNameToUnicode("XN--BCHER-KVA.example"); err != nil || u != "bücher.example" {
+		t.Errorf("got %q, %v", u, err)
+	}
+	if _, err := DNSNameToUnicode("xn--a!b.com"); err == nil {
+		t.Error("invalid punycode decoded")
+	}
+	// A zero width joiner is only allowed after a virama (RFC 5892).
+	if _, err := DNSNameToUnicode("xn--1ug.example"); err == nil {
+		t.Error("invalid label decoded")
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// DoIPPayloadType is the type of a DoIP message.
+type DoIPPayloadType uint16
+
+const (
+	DoIPPayloadTypeGenericNACK                  DoIPPayloadType = 0x0000
+	DoIPPayloadTypeVehicleIdentificationRequest DoIPPayloadType = 0x0001
+	DoIPPayloadTypeVehicleIdentificationEID     DoIPPayloadType = 0x0002
+	DoIPPayloadTypeVehicleIdentificationVIN     DoIPPayloadType = 0x0003
+	DoIPPayloadTypeVehicleAnnouncement          DoIPPayloadType = 0x0004
+	DoIPPayloadTypeRoutingActivationRequest     DoIPPayloadType = 0x0005
+	DoIPPayloadTypeRoutingActivationResponse    DoIPPayloadType = 0x0006
+	DoIPPayloadTypeAliveCheckRequest            DoIPPayloadType = 0x0007
+	DoIPPayloadTypeAliveCheckResponse           DoIPPayloadType = 0x0008
+	DoIPPayloadTypeEntityStatusRequest          DoIPPayloadType = 0x4001
+	DoIPPayloadTypeEntityStatusResponse         DoIPPayloadType = 0x4002
+	DoIPPayloadTypeDiagnosticPowerModeRequest   DoIPPayloadType = 0x4003
+	DoIPPayloadTypeDiagnosticPowerModeResponse  DoIPPayloadType = 0x4004
+	DoIPPayloadTypeDiagnosticMessage            DoIPPayloadType = 0x8001
+	DoIPPayloadTypeDiagnosticMessageAck         DoIPPayloadType = 0x8002
+	DoIPPayloadTypeDiagnosticMessageNACK        DoIPPayloadType = 0x8003
+)
+
+func (t DoIPPayloadType) String() string {
+	switch t {
+	case DoIPPayloadTypeGenericNACK:
+		return "Generic NACK"
+	case DoIPPayloadTypeVehicleIdentificationRequest:
+		return "Vehicle Identification Request"
+	case DoIPPayloadTypeVehicleIdentificationEID:
+		return "Vehicle Identification Request with EID"
+	case DoIPPayloadTypeVehicleIdentificationVIN:
+		return "Vehicle Identification Request with VIN"
+	case DoIPPayloadTypeVehicleAnnouncement:
+		return "Vehicle Announcement"
+	case DoIPPayloadTypeRoutingActivationRequest:
+		return "Routing Activation Request"
+	case DoIPPayloadTypeRoutingActivationResponse:
+		return "Routing Activation Response"
+	case DoIPPayloadTypeAliveCheckRequest:
+		return "Alive Check Request"
+	case DoIPPayloadTypeAliveCheckResponse:
+		return "Alive Check Response"
+	case DoIPPayloadTypeEntityStatusRequest:
+		return "Entity Status Request"
+	case DoIPPayloadTypeEntityStatusResponse:
+		return "Entity Status Response"
+	case DoIPPayloadTypeDiagnosticPowerModeRequest:
+		return "Diagnostic Power Mode Request"
+	case DoIPPayloadTypeDiagnosticPowerModeResponse:
+		return "Diagnostic Power Mode Response"
+	case DoIPPayloadTypeDiagnosticMessage:
+		return "Diagnostic Message"
+	case DoIPPayloadTypeDiagnosticMessageAck:
+		return "Diagnostic Message Ack"
+	case DoIPPayloadTypeDiagnosticMessageNACK:
+		return "Diagnostic Message NACK"
+	default:
+		return fmt.Sprintf("Unknown(%#04x)", uint16(t))
+	}
+}
+
+// doIPLength returns the length of the fields DoIP decodes of messages of
+// the type, and whether the type may have more.
+func (t DoIPPayloadType) doIPLength() (int, bool) {
+	switch t {
+	case DoIPPayloadTypeGenericNACK:
+		return 1, false
+	case DoIPPayloadTypeVehicleIdentificationEID:
+		return 6, false
+	case DoIPPayloadTypeVehicleIdentificationVIN:
+		return 17, false
+	case DoIPPayloadTypeVehicleAnnouncement:
+		return 32, false
+	case DoIPPayloadTypeRoutingActivationRequest:
+		return 7, false
+	case DoIPPayloadTypeRoutingActivationResponse:
+		return 9, false
+	case DoIPPayloadTypeAliveCheckResponse:
+		return 2, false
+	case DoIPPayloadTypeDiagnosticMessage:
+		return 4, true
+	case DoIPPayloadTypeDiagnosticMessageAck, DoIPPayloadTypeDiagnosticMessageNACK:
+		return 5, true
+	}
+	return 0, true
+}
+
+const doIPHeaderLength = 8
+
+// DoIP is a Diagnostics over IP (ISO 13400-2) message, sent over TCP and UDP
+// port 13400. The payload of diagnostic messages is the UDS request or
+// response they carry, that of their acknowledgements the diagnostic message
+// they acknowledge, if any, and that of the messages whose fields DoIP does
+// not decode their whole data.
+//
+// Over TCP, a message may continue in later segments, and a segment may hold
+// several messages. A message continuing past the end of the data is decoded
+// as far as the data goes, without being flagged as truncated: its fields
+// are decoded if all of them are there, and the rest of its data is the
+// payload. Each message of a segment is added to the packet as a DoIP
+// layer, and only the payload of the last one is decoded further. Segments
+// that do not start with a DoIP header are left as payload.
+type DoIP struct {
+	BaseLayer
+	// Version is the version of the protocol, 2 for ISO 13400-2:2012 and 3
+	// for ISO 13400-2:2019, or 0xff in vehicle identification requests.
+	// InverseVersion is its complement.
+	Version        uint8
+	InverseVersion uint8
+	PayloadType    DoIPPayloadType
+	Length         uint32
+
+	// SourceAddress and TargetAddress are the logical addresses of the
+	// sender and the receiver of diagnostic messages and their
+	// acknowledgements. Vehicle announcements, routing activation requests
+	// and alive check responses have the SourceAddress of their sender
+	// only, and routing activation responses have the address of the
+	// tester as TargetAddress and that of the DoIP entity as SourceAddress.
+	SourceAddress uint16
+	TargetAddress uint16
+	// Code is the code of generic NACKs, routing activation responses and
+	// the acknowledgements of diagnostic messages, and the activation type
+	// of routing activation requests.
+	Code uint8
+	// Reserved and the optional OEMData are those of routing activation
+	// requests and responses.
+	Reserved uint32
+	OEMData  []byte
+
+	// VIN and EID are those of vehicle announcements and vehicle
+	// identification requests.
+	VIN string
+	EID net.HardwareAddr
+	// GID, FurtherAction and SyncStatus are those of vehicle
+	// announcements, which may lack the SyncStatus.
+	GID           net.HardwareAddr
+	FurtherAction uint8
+	SyncStatus    uint8
+	HasSyncStatus bool
+}
+
+// LayerType returns LayerTypeDoIP.
+func (d *DoIP) LayerType() gopacket.LayerType { return LayerTypeDoIP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (d *DoIP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < doIPHeaderLength {
+		df.SetTruncated()
+		return fmt.Errorf("DoIP length %d too short, %d required", len(data), doIPHeaderLength)
+	}
+	d.Version = data[0]
+	d.InverseVersion = data[1]
+	if d.InverseVersion != ^d.Version {
+		return fmt.Errorf("DoIP inverse version %#02x does not match version %#02x", d.InverseVersion, d.Version)
+	}
+	d.PayloadType = DoIPPayloadType(binary.BigEndian.Uint16(data[2:4]))
+	d.Length = binary.BigEndian.Uint32(data[4:8])
+	body := data[doIPHeaderLength:]
+	partial := uint64(d.Length) > uint64(len(body))
+	if !partial {
+		body = body[:d.Length]
+	}
+	length, more := d.PayloadType.doIPLength()
+
+	d.SourceAddress, d.TargetAddress, d.Code, d.Reserved, d.OEMData = 0, 0, 0, 0, nil
+	d.VIN, d.EID, d.GID, d.FurtherAction, d.SyncStatus, d.HasSyncStatus = "", nil, nil, 0, 0, false
+	if len(body) < length {
+		if partial {
+			// The fields continue in the next segment.
+			d.BaseLayer = BaseLayer{Contents: data[:doIPHeaderLength], Payload: body}
+			return nil
+		}
+		return fmt.Errorf("DoIP %v length %d too short, %d required", d.PayloadType, len(body), length)
+	}
+	switch d.PayloadType {
+	case DoIPPayloadTypeGenericNACK:
+		d.Code = body[0]
+	case DoIPPayloadTypeVehicleIdentificationEID:
+		d.EID = net.HardwareAddr(body[:6])
+	case DoIPPayloadTypeVehicleIdentificationVIN:
+		d.VIN = string(body[:17])
+	case DoIPPayloadTypeVehicleAnnouncement:
+		d.VIN = string(body[:17])
+		d.SourceAddress = binary.BigEndian.Uint16(body[17:19])
+		d.EID = net.HardwareAddr(body[19:25])
+		d.GID = net.HardwareAddr(body[25:31])
+		d.FurtherAction = body[31]
+		if len(body) > length && !partial {
+			d.SyncStatus = body[length]
+			d.HasSyncStatus = true
+			length++
+		}
+	case DoIPPayloadTypeRoutingActivationRequest:
+		d.SourceAddress = binary.BigEndian.Uint16(body[0:2])
+		d.Code = body[2]
+		d.Reserved = binary.BigEndian.Uint32(body[3:7])
+		if len(body) > length {
+			d.OEMData = body[length:]
+			length = len(body)
+		}
+	case DoIPPayloadTypeRoutingActivationResponse:
+		d.TargetAddress = binary.BigEndian.Uint16(body[0:2])
+		d.SourceAddress = binary.BigEndian.Uint16(body[2:4])
+		d.Code = body[4]
+		d.Reserved = binary.BigEndian.Uint32(body[5:9])
+		if len(body) > length {
+			d.OEMData = body[length:]
+			length = len(body)
+		}
+	case DoIPPayloadTypeAliveCheckResponse:
+		d.SourceAddress = binary.BigEndian.Uint16(body[0:2])
+	case DoIPPayloadTypeDiagnosticMessage, DoIPPayloadTypeDiagnosticMessageAck, DoIPPayloadTypeDiagnosticMessageNACK:
+		d.SourceAddress = binary.BigEndian.Uint16(body[0:2])
+		d.TargetAddress = binary.BigEndian.Uint16(body[2:4])
+		if d.PayloadType != DoIPPayloadTypeDiagnosticMessage {
+			d.Code = body[4]
+		}
+	}
+	if !more && len(body) > length && !partial {
+		return fmt.Errorf("DoIP %v length %d too long, %d allowed", d.PayloadType, len(body), length)
+	}
+	d.BaseLayer = BaseLayer{Contents: data[:doIPHeaderLength+length], Payload: body[length:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (d *DoIP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	payload := len(b.Bytes())
+	length, more := d.PayloadType.doIPLength()
+	switch d.PayloadType {
+	case DoIPPayloadTypeVehicleIdentificationEID:
+		if len(d.EID) != 6 {
+			return fmt.Errorf("DoIP EID length %d, not 6", len(d.EID))
+		}
+	case DoIPPayloadTypeVehicleIdentificationVIN:
+		if len(d.VIN) != 17 {
+			return fmt.Errorf("DoIP VIN length %d, not 17", len(d.VIN))
+		}
+	case DoIPPayloadTypeVehicleAnnouncement:
+		if len(d.VIN) != 17 || len(d.EID) != 6 || len(d.GID) != 6 {
+			return errors.New("DoIP VIN, EID or GID of wrong length")
+		}
+		if d.HasSyncStatus {
+			length++
+		}
+	case DoIPPayloadTypeRoutingActivationRequest, DoIPPayloadTypeRoutingActivationResponse:
+		length += len(d.OEMData)
+	}
+	if !more && payload > 0 {
+		return fmt.Errorf("DoIP %v has no payload", d.PayloadType)
+	}
+	bytes, err := b.PrependBytes(doIPHeaderLength + length)
+	if err != nil {
+		return err
+	}
+	body := bytes[doIPHeaderLength:]
+	switch d.PayloadType {
+	case DoIPPayloadTypeGenericNACK:
+		body[0] = d.Code
+	case DoIPPayloadTypeVehicleIdentificationEID:
+		copy(body, d.EID)
+	case DoIPPayloadTypeVehicleIdentificationVIN:
+		copy(body, d.VIN)
+	case DoIPPayloadTypeVehicleAnnouncement:
+		copy(body, d.VIN)
+		binary.BigEndian.PutUint16(body[17:19], d.SourceAddress)
+		copy(body[19:25], d.EID)
+		copy(body[25:31], d.GID)
+		body[31] = d.FurtherAction
+		if d.HasSyncStatus {
+			body[32] = d.SyncStatus
+		}
+	case DoIPPayloadTypeRoutingActivationRequest:
+		binary.BigEndian.PutUint16(body[0:2], d.SourceAddress)
+		body[2] = d.Code
+		binary.BigEndian.PutUint32(body[3:7], d.Reserved)
+		copy(body[7:], d.OEMData)
+	case DoIPPayloadTypeRoutingActivationResponse:
+		binary.BigEndian.PutUint16(body[0:2], d.TargetAddress)
+		binary.BigEndian.PutUint16(body[2:4], d.SourceAddress)
+		body[4] = d.Code
+		binary.BigEndian.PutUint32(body[5:9], d.Reserved)
+		copy(body[9:], d.OEMData)
+	case DoIPPayloadTypeAliveCheckResponse:
+		binary.BigEndian.PutUint16(body[0:2], d.SourceAddress)
+	case DoIPPayloadTypeDiagnosticMessage, DoIPPayloadTypeDiagnosticMessageAck, DoIPPayloadTypeDiagnosticMessageNACK:
+		binary.BigEndian.PutUint16(body[0:2], d.SourceAddress)
+		binary.BigEndian.PutUint16(body[2:4], d.TargetAddress)
+		if d.PayloadType != DoIPPayloadTypeDiagnosticMessage {
+			body[4] = d.Code
+		}
+	}
+	if opts.FixLengths {
+		d.InverseVersion = ^d.Version
+		d.Length = uint32(length + payload)
+	}
+	bytes[0] = d.Version
+	bytes[1] = d.InverseVersion
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(d.PayloadType))
+	binary.BigEndian.PutUint32(bytes[4:8], d.Length)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (d *DoIP) CanDecode() gopacket.LayerClass {
+	return LayerTypeDoIP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (d *DoIP) NextLayerType() gopacket.LayerType {
+	if len(d.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	if d.PayloadType == DoIPPayloadTypeDiagnosticMessage && !d.partial() {
+		return LayerTypeUDS
+	}
+	return gopacket.LayerTypePayload
+}
+
+// partial reports whether the message continues past the data decoded.
+func (d *DoIP) partial() bool {
+	return uint64(d.Length) > uint64(len(d.Contents)-doIPHeaderLength+len(d.Payload))
+}
+
+// doIPStartsMessage reports whether data starts with a DoIP header. Other
+// TCP segments of a DoIP connection, like those continuing a long diagnostic
+// message, are left as payload.
+func doIPStartsMessage(data []byte) bool {
+	if len(data) < doIPHeaderLength || data[1] != ^data[0] {
+		return false
+	}
+	return data[0] >= 1 && data[0] <= 4 || data[0] == 0xff
+}
+
+func decodeDoIP(data []byte, p gopacket.PacketBuilder) error {
+	if !doIPStartsMessage(data) {
+		return p.NextDecoder(gopacket.LayerTypePayload)
+	}
+	for {
+		d := &DoIP{}
+		if err := d.DecodeFromBytes(data, p); err != nil {
+			return err
+		}
+		p.AddLayer(d)
+		data = data[len(d.Contents)+len(d.Payload):]
+		if doIPStartsMessage(data) {
+			continue
+		}
+		if next := d.NextLayerType(); next != gopacket.LayerTypeZero {
+			return p.NextDecoder(next)
+		}
+		return nil
+	}
+}
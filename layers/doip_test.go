@@ -0,0 +1,211 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A ReadDataByIdentifier request for the VIN from tester 0x0e80 to ECU
+// 0x1001.
+var testDoIPDiagnosticMessage = []byte{
+	0x02, 0xfd, 0x80, 0x01, 0x00, 0x00, 0x00, 0x07,
+	0x0e, 0x80, 0x10, 0x01,
+	0x22, 0xf1, 0x90,
+}
+
+func TestDoIPDiagnosticMessage(t *testing.T) {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolTCP, SrcIP: net.IP{192, 168, 0, 2}, DstIP: net.IP{192, 168, 0, 10}}
+	tcp := &TCP{SrcPort: 50000, DstPort: 13400, PSH: true, ACK: true, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp, gopacket.Payload(testDoIPDiagnosticMessage)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.DecodeOptions{DecodeStreamsAsDatagrams: true})
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeTCP, LayerTypeDoIP, LayerTypeUDS, gopacket.LayerTypePayload}, t)
+	d := p.Layer(LayerTypeDoIP).(*DoIP)
+	if d.Version != 2 || d.PayloadType != DoIPPayloadTypeDiagnosticMessage || d.Length != 7 ||
+		d.SourceAddress != 0x0e80 || d.TargetAddress != 0x1001 {
+		t.Errorf("got %+v", d)
+	}
+	u := p.Layer(LayerTypeUDS).(*UDS)
+	if u.ServiceID != UDSServiceReadDataByIdentifier || u.Response || u.NegativeResponse || !bytes.Equal(u.Payload, []byte{0xf1, 0x90}) {
+		t.Errorf("got %+v", u)
+	}
+
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&DoIP{Version: 2, PayloadType: DoIPPayloadTypeDiagnosticMessage, SourceAddress: 0x0e80, TargetAddress: 0x1001},
+		&UDS{ServiceID: UDSServiceReadDataByIdentifier}, gopacket.Payload(u.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testDoIPDiagnosticMessage) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testDoIPDiagnosticMessage)
+	}
+
+	d = &DoIP{}
+	if err := d.DecodeFromBytes(testDoIPDiagnosticMessage[:8], gopacket.NilDecodeFeedback); err != nil || d.NextLayerType() != gopacket.LayerTypeZero {
+		t.Errorf("decoding header alone: %v, next layer %v", err, d.NextLayerType())
+	}
+	if err := d.DecodeFromBytes(testDoIPDiagnosticMessage[:4], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated header")
+	}
+}
+
+// doIPSegment returns an IPv4 TCP segment to port 13400 carrying data.
+func doIPSegment(t *testing.T, data []byte) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolTCP, SrcIP: net.IP{192, 168, 0, 2}, DstIP: net.IP{192, 168, 0, 10}}
+	tcp := &TCP{SrcPort: 50000, DstPort: 13400, PSH: true, ACK: true, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, tcp, gopacket.Payload(data)); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.DecodeOptions{DecodeStreamsAsDatagrams: true})
+}
+
+func TestDoIPStream(t *testing.T) {
+	// A message split across segments is not truncated, and the segment
+	// continuing it is left as payload.
+	for _, n := range []int{10, 13} {
+		p := doIPSegment(t, testDoIPDiagnosticMessage[:n])
+		if p.ErrorLayer() != nil || p.Metadata().Truncated {
+			t.Fatalf("split at %d: error %v, truncated %v", n, p.ErrorLayer(), p.Metadata().Truncated)
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeTCP, LayerTypeDoIP, gopacket.LayerTypePayload}, t)
+		if d := p.Layer(LayerTypeDoIP).(*DoIP); len(d.Contents)+len(d.Payload) != n {
+			t.Errorf("split at %d: got %d bytes of contents and %d of payload", n, len(d.Contents), len(d.Payload))
+		}
+		p = doIPSegment(t, testDoIPDiagnosticMessage[n:])
+		checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeTCP, gopacket.LayerTypePayload}, t)
+	}
+
+	// Every message of a segment is decoded.
+	alive := []byte{0x02, 0xfd, 0x00, 0x08, 0x00, 0x00, 0x00, 0x02, 0x0e, 0x80}
+	p := doIPSegment(t, append(append([]byte(nil), alive...), testDoIPDiagnosticMessage...))
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeTCP, LayerTypeDoIP, LayerTypeDoIP, LayerTypeUDS, gopacket.LayerTypePayload}, t)
+	var types []DoIPPayloadType
+	for _, l := range p.Layers() {
+		if d, ok := l.(*DoIP); ok {
+			types = append(types, d.PayloadType)
+		}
+	}
+	if len(types) != 2 || types[0] != DoIPPayloadTypeAliveCheckResponse || types[1] != DoIPPayloadTypeDiagnosticMessage {
+		t.Errorf("got messages %v", types)
+	}
+}
+
+func TestDoIPUDSResponses(t *testing.T) {
+	u := &UDS{}
+	if err := u.DecodeFromBytes([]byte{0x62, 0xf1, 0x90, 'W'}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if u.ServiceID != UDSServiceReadDataByIdentifier || !u.Response || u.NegativeResponse || len(u.Payload) != 3 {
+		t.Errorf("got %+v", u)
+	}
+	data := []byte{0x7f, 0x27, 0x35}
+	if err := u.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if u.ServiceID != UDSServiceSecurityAccess || !u.Response || !u.NegativeResponse || u.ResponseCode != UDSResponseInvalidKey {
+		t.Errorf("got %+v", u)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := u.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data)
+	}
+	if err := u.DecodeFromBytes(data[:2], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated negative response")
+	}
+}
+
+func TestDoIPVehicleAnnouncement(t *testing.T) {
+	data := []byte{
+		0x02, 0xfd, 0x00, 0x04, 0x00, 0x00, 0x00, 0x21,
+		'W', 'V', 'W', 'Z', 'Z', 'Z', '1', 'K', 'Z', 'A', 'B', '1', '2', '3', '4', '5', '6', // VIN
+		0x10, 0x01, // logical address
+		0x00, 0x1a, 0x37, 0x01, 0x02, 0x03, // EID
+		0x00, 0x1a, 0x37, 0x01, 0x02, 0x00, // GID
+		0x00, 0x10, // further action, sync status
+	}
+	p := gopacket.NewPacket(data, LayerTypeDoIP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	d := p.Layer(LayerTypeDoIP).(*DoIP)
+	if d.PayloadType != DoIPPayloadTypeVehicleAnnouncement || d.VIN != "WVWZZZ1KZAB123456" || d.SourceAddress != 0x1001 ||
+		d.EID.String() != "00:1a:37:01:02:03" || d.GID.String() != "00:1a:37:01:02:00" || !d.HasSyncStatus || d.SyncStatus != 0x10 ||
+		len(d.Payload) != 0 {
+		t.Errorf("got %+v", d)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := d.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data)
+	}
+
+	data[7] = 0x1f // too short for an announcement
+	if err := d.DecodeFromBytes(data[:8+0x1f], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding short announcement")
+	}
+}
+
+func TestDoIPRoutingActivation(t *testing.T) {
+	req := []byte{
+		0x02, 0xfd, 0x00, 0x05, 0x00, 0x00, 0x00, 0x0b,
+		0x0e, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0xde, 0xad, 0xbe, 0xef,
+	}
+	d := &DoIP{}
+	if err := d.DecodeFromBytes(req, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if d.PayloadType != DoIPPayloadTypeRoutingActivationRequest || d.SourceAddress != 0x0e80 || d.Code != 0 ||
+		!bytes.Equal(d.OEMData, []byte{0xde, 0xad, 0xbe, 0xef}) || d.NextLayerType() != gopacket.LayerTypeZero {
+		t.Errorf("got %+v", d)
+	}
+
+	resp := []byte{
+		0x02, 0xfd, 0x00, 0x06, 0x00, 0x00, 0x00, 0x09,
+		0x0e, 0x80, 0x10, 0x01, 0x10, 0x00, 0x00, 0x00, 0x00,
+	}
+	if err := d.DecodeFromBytes(resp, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if d.PayloadType != DoIPPayloadTypeRoutingActivationResponse || d.TargetAddress != 0x0e80 || d.SourceAddress != 0x1001 ||
+		d.Code != 0x10 || d.OEMData != nil {
+		t.Errorf("got %+v", d)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := d.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), resp) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), resp)
+	}
+
+	resp[1] = 0xfe
+	if err := d.DecodeFromBytes(resp, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding mismatched inverse version")
+	}
+}
@@ -412,6 +412,7 @@ const (
 	Dot11InformationElementIDWhiteSpaceMap             Dot11InformationElementID = 205
 	Dot11InformationElementIDFineTuningMeasureParams   Dot11InformationElementID = 206
 	Dot11InformationElementIDVendor                    Dot11InformationElementID = 221
+	Dot11InformationElementIDExtension                 Dot11InformationElementID = 255
 )
 
 // String provides a human readable string for Dot11InformationElementID.
@@ -762,6 +763,8 @@ func (a Dot11InformationElementID) String() string {
 		return "Fine Tuning Measure Parameters"
 	case Dot11InformationElementIDVendor:
 		return "Vendor"
+	case Dot11InformationElementIDExtension:
+		return "Element ID Extension"
 	default:
 		return "Unknown information element id"
 	}
@@ -786,6 +789,9 @@ type Dot11 struct {
 	QOS            *Dot11QOS
 	HTControl      *Dot11HTControl
 	DataLayer      gopacket.Layer
+	// AppendFCS makes SerializeTo append the frame check sequence, as
+	// frames following a radiotap header with RadioTapFlagsFCS have it.
+	AppendFCS bool
 }
 
 type Dot11QOS struct {
@@ -1089,9 +1095,45 @@ func (m *Dot11) ChecksumValid() bool {
 	return m.Checksum == h.Sum32()
 }
 
-func (m Dot11) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	buf, err := b.PrependBytes(24)
+// headerLength returns the length of the header of frames of the type and
+// flags of m, as DecodeFromBytes decodes it.
+func (m *Dot11) headerLength() int {
+	n := 10
+	mainType := m.Type.MainType()
+	switch mainType {
+	case Dot11TypeCtrl:
+		switch m.Type {
+		case Dot11TypeCtrlRTS, Dot11TypeCtrlPowersavePoll, Dot11TypeCtrlCFEnd, Dot11TypeCtrlCFEndAck:
+			n += 6
+		}
+	case Dot11TypeMgmt, Dot11TypeData:
+		n += 14
+	}
+	if mainType == Dot11TypeData && m.Flags.FromDS() && m.Flags.ToDS() {
+		n += 6
+	}
+	if m.Type.QOS() {
+		n += 2
+	}
+	if m.Flags.Order() && (m.Type.QOS() || mainType == Dot11TypeMgmt) {
+		n += 4
+	}
+	return n
+}
 
+// SerializeTo writes the header of the frame. If AppendFCS is set, it also
+// appends the frame check sequence, computed if opts.ComputeChecksums is set,
+// or else taken from Checksum.
+//
+// DurationID is written as is: the duration of the frame exchange in
+// microseconds, or, for PS-Poll frames, the association ID with its two
+// high bits set. SequenceNumber is taken modulo 4096, as the counter
+// of the sequence control field wraps.
+func (m Dot11) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if m.FragmentNumber > 0xf {
+		return fmt.Errorf("Dot11 fragment number %d too large", m.FragmentNumber)
+	}
+	buf, err := b.PrependBytes(m.headerLength())
 	if err != nil {
 		return err
 	}
@@ -1105,7 +1147,8 @@ func (m Dot11) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOp
 
 	offset := 10
 
-	switch m.Type.MainType() {
+	mainType := m.Type.MainType()
+	switch mainType {
 	case Dot11TypeCtrl:
 		switch m.Type {
 		case Dot11TypeCtrlRTS, Dot11TypeCtrlPowersavePoll, Dot11TypeCtrlCFEnd, Dot11TypeCtrlCFEndAck:
@@ -1122,14 +1165,124 @@ func (m Dot11) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOp
 		offset += 2
 	}
 
-	if m.Type.MainType() == Dot11TypeData && m.Flags.FromDS() && m.Flags.ToDS() {
+	if mainType == Dot11TypeData && m.Flags.FromDS() && m.Flags.ToDS() {
 		copy(buf[offset:offset+6], m.Address4)
 		offset += 6
 	}
 
+	if m.Type.QOS() {
+		buf[offset], buf[offset+1] = 0, 0
+		if q := m.QOS; q != nil {
+			buf[offset] = q.TID&0x0F | uint8(q.AckPolicy&0x3)<<5
+			if q.EOSP {
+				buf[offset] |= 0x10
+			}
+			buf[offset+1] = q.TXOP
+		}
+		offset += 2
+	}
+	if m.Flags.Order() && (m.Type.QOS() || mainType == Dot11TypeMgmt) {
+		var htc [4]byte
+		if m.HTControl != nil {
+			htc = m.HTControl.encode()
+		}
+		copy(buf[offset:offset+4], htc[:])
+	}
+
+	if !m.AppendFCS {
+		return nil
+	}
+	fcs, err := b.AppendBytes(4)
+	if err != nil {
+		return err
+	}
+	checksum := m.Checksum
+	if opts.ComputeChecksums {
+		bytes := b.Bytes()
+		checksum = crc32.ChecksumIEEE(bytes[:len(bytes)-4])
+	}
+	binary.LittleEndian.PutUint32(fcs, checksum)
 	return nil
 }
 
+// encode encodes the HT Control field, as Dot11.DecodeFromBytes decodes
+// it.
+func (h *Dot11HTControl) encode() (b [4]byte) {
+	if h.ACConstraint {
+		b[3] |= 0x40
+	}
+	if h.RDGMorePPDU {
+		b[3] |= 0x80
+	}
+	if vht := h.VHT; vht != nil {
+		b[0] |= 0x1
+		if vht.MRQ {
+			b[0] |= 0x4
+		}
+		b[1] |= vht.MFB.NumSTS&0x7<<1 | vht.MFB.VHTMCS<<4
+		b[2] |= vht.MFB.BW&0x3 | uint8(vht.MFB.SNR-22)&0x3F<<2
+		if vht.UnsolicitedMFB {
+			b[3] |= 0x20
+			if !vht.MFB.NoFeedBackPresent() {
+				if vht.CompressedMSI != nil {
+					b[0] |= *vht.CompressedMSI & 0x3 << 3
+				}
+				if vht.STBCIndication {
+					b[0] |= 0x20
+				}
+				if vht.CodingType != nil {
+					b[3] |= uint8(*vht.CodingType) & 0x1 << 3
+				}
+				if vht.FbTXBeamformed {
+					b[3] |= 0x10
+				}
+				if vht.GID != nil {
+					b[0] |= *vht.GID << 6
+					b[1] |= *vht.GID >> 2 & 0x1
+					b[3] |= *vht.GID >> 3 & 0x7
+				}
+			}
+		} else {
+			if vht.MRQ && vht.MSI != nil {
+				b[0] |= *vht.MSI & 0x7 << 3
+			}
+			if vht.MFSI != nil {
+				b[0] |= *vht.MFSI << 6
+				b[1] |= *vht.MFSI >> 2 & 0x1
+			}
+		}
+		return b
+	}
+	if ht := h.HT; ht != nil {
+		if lac := ht.LinkAdapationControl; lac != nil {
+			if lac.TRQ {
+				b[0] |= 0x2
+			}
+			b[0] |= lac.MFSI << 6
+			b[1] |= lac.MFSI >> 3 & 0x1
+			if lac.ASEL != nil {
+				b[0] |= 0x38
+				b[1] |= lac.ASEL.Command&0x7<<1 | lac.ASEL.Data<<4
+			} else {
+				if lac.MRQ {
+					b[0] |= 0x4 | lac.MSI&0x7<<3
+				}
+				if lac.MFB != nil {
+					b[1] |= *lac.MFB << 1
+				}
+			}
+		}
+		b[2] |= ht.CalibrationPosition&0x3 | ht.CalibrationSequence&0x3<<2 | ht.CSISteering&0x3<<6
+		if ht.NDPAnnouncement {
+			b[3] |= 0x1
+		}
+		if ht.DEI {
+			b[3] |= 0x20
+		}
+	}
+	return b
+}
+
 // Dot11Mgmt is a base for all IEEE 802.11 management layers.
 type Dot11Mgmt struct {
 	BaseLayer
@@ -1141,6 +1294,21 @@ func (m *Dot11Mgmt) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	return nil
 }
 
+// SerializeTo writes the undecoded body of management frames whose fields
+// are not decoded, such as action frames.
+func (m Dot11Mgmt) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return serializeDot11Body(b, m.Contents)
+}
+
+func serializeDot11Body(b gopacket.SerializeBuffer, body []byte) error {
+	buf, err := b.PrependBytes(len(body))
+	if err != nil {
+		return err
+	}
+	copy(buf, body)
+	return nil
+}
+
 // Dot11Ctrl is a base for all IEEE 802.11 control layers.
 type Dot11Ctrl struct {
 	BaseLayer
@@ -1155,6 +1323,12 @@ func (m *Dot11Ctrl) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	return nil
 }
 
+// SerializeTo writes the undecoded body of control frames, such as block
+// acknowledgements.
+func (m Dot11Ctrl) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return serializeDot11Body(b, m.Contents)
+}
+
 func decodeDot11Ctrl(data []byte, p gopacket.PacketBuilder) error {
 	d := &Dot11Ctrl{}
 	return decodingLayerDecoder(d, data, p)
@@ -1174,6 +1348,10 @@ func (m *Dot11WEP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 	return nil
 }
 
+func (m Dot11WEP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return serializeDot11Body(b, m.Contents)
+}
+
 func decodeDot11WEP(data []byte, p gopacket.PacketBuilder) error {
 	d := &Dot11WEP{}
 	return decodingLayerDecoder(d, data, p)
@@ -1195,6 +1373,12 @@ func (m *Dot11Data) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	return nil
 }
 
+// SerializeTo writes nothing: the data of the frame is carried by the
+// layers following it.
+func (m Dot11Data) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return nil
+}
+
 func decodeDot11Data(data []byte, p gopacket.PacketBuilder) error {
 	d := &Dot11Data{}
 	return decodingLayerDecoder(d, data, p)
@@ -1470,12 +1654,11 @@ func (m *Dot11InformationElement) DecodeFromBytes(data []byte, df gopacket.Decod
 		df.SetTruncated()
 		return fmt.Errorf("Dot11InformationElement length %v too short, %v required", len(data), offset+int(m.Length))
 	}
-	if len(data) < offset+4 {
-		df.SetTruncated()
-		return fmt.Errorf("vendor extension size < %d", offset+int(m.Length))
-	}
 	if m.ID == 221 {
 		// Vendor extension
+		if m.Length < 4 {
+			return fmt.Errorf("vendor extension size %d < 4", m.Length)
+		}
 		m.OUI = data[offset : offset+4]
 		m.Info = data[offset+4 : offset+int(m.Length)]
 	} else {
@@ -1811,6 +1994,9 @@ func (m Dot11MgmtReassociationReq) SerializeTo(b gopacket.SerializeBuffer, opts
 
 type Dot11MgmtReassociationResp struct {
 	Dot11Mgmt
+	CapabilityInfo uint16
+	Status         Dot11Status
+	AID            uint16
 }
 
 func decodeDot11MgmtReassociationResp(data []byte, p gopacket.PacketBuilder) error {
@@ -1827,6 +2013,31 @@ func (m *Dot11MgmtReassociationResp) CanDecode() gopacket.LayerClass {
 func (m *Dot11MgmtReassociationResp) NextLayerType() gopacket.LayerType {
 	return LayerTypeDot11InformationElement
 }
+func (m *Dot11MgmtReassociationResp) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 6 {
+		df.SetTruncated()
+		return fmt.Errorf("Dot11MgmtReassociationResp length %v too short, %v required", len(data), 6)
+	}
+	m.CapabilityInfo = binary.LittleEndian.Uint16(data[0:2])
+	m.Status = Dot11Status(binary.LittleEndian.Uint16(data[2:4]))
+	m.AID = binary.LittleEndian.Uint16(data[4:6])
+	m.Payload = data[6:]
+	return m.Dot11Mgmt.DecodeFromBytes(data, df)
+}
+
+func (m Dot11MgmtReassociationResp) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	buf, err := b.PrependBytes(6)
+
+	if err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint16(buf[0:2], m.CapabilityInfo)
+	binary.LittleEndian.PutUint16(buf[2:4], uint16(m.Status))
+	binary.LittleEndian.PutUint16(buf[4:6], m.AID)
+
+	return nil
+}
 
 type Dot11MgmtProbeReq struct {
 	Dot11Mgmt
@@ -2093,6 +2304,25 @@ func decodeDot11MgmtAction(data []byte, p gopacket.PacketBuilder) error {
 func (m *Dot11MgmtAction) LayerType() gopacket.LayerType  { return LayerTypeDot11MgmtAction }
 func (m *Dot11MgmtAction) CanDecode() gopacket.LayerClass { return LayerTypeDot11MgmtAction }
 
+// DecodeFromBytes decodes the body of the action frame. The body of AWDL
+// action frames is also kept as the payload, which the AWDL layer decodes.
+func (m *Dot11MgmtAction) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if isAWDL(data) {
+		m.BaseLayer = BaseLayer{Contents: data, Payload: data}
+		return nil
+	}
+	m.Payload = nil
+	return m.Dot11Mgmt.DecodeFromBytes(data, df)
+}
+
+// NextLayerType returns LayerTypeAWDL for AWDL action frames.
+func (m *Dot11MgmtAction) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) > 0 {
+		return LayerTypeAWDL
+	}
+	return m.Dot11Mgmt.NextLayerType()
+}
+
 type Dot11MgmtActionNoAck struct {
 	Dot11Mgmt
 }
@@ -556,3 +556,121 @@ func TestInformationElement(t *testing.T) {
 		t.Error("build failed")
 	}
 }
+
+func TestDot11SerializeDecoded(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"CTS", testPacketDot11CtrlCTS},
+		{"Ack", testPacketDot11CtrlAck},
+		{"Beacon", testPacketDot11MgmtBeacon},
+		{"Action", testPacketDot11MgmtAction},
+		{"QOSData", testPacketDot11DataQOSData},
+		{"ARP", testPacketDot11DataARP},
+	} {
+		p := gopacket.NewPacket(test.data, LinkTypeIEEE80211Radio, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal(test.name, "failed to decode packet:", p.ErrorLayer().Error())
+		}
+		// The radiotap header is not serialized back as it was captured.
+		want := p.Layer(LayerTypeRadioTap).LayerPayload()
+		p.Layer(LayerTypeDot11).(*Dot11).AppendFCS = true
+		var ls []gopacket.SerializableLayer
+		for _, l := range p.Layers()[1:] {
+			s, ok := l.(gopacket.SerializableLayer)
+			if !ok {
+				t.Fatalf("%s: %v is not serializable", test.name, l.LayerType())
+			}
+			ls = append(ls, s)
+		}
+		for _, opts := range []gopacket.SerializeOptions{{}, {ComputeChecksums: true}} {
+			buf := gopacket.NewSerializeBuffer()
+			if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+				t.Fatal(test.name, err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s: serialized with %+v as\n%x, want\n%x", test.name, opts, buf.Bytes(), want)
+			}
+		}
+	}
+
+	// The HT Control field is encoded as it is decoded.
+	p := gopacket.NewPacket(testPacketDot11HTControl, LinkTypeIEEE80211Radio, gopacket.Default)
+	d := p.Layer(LayerTypeDot11).(*Dot11)
+	d.AppendFCS = true
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, d, gopacket.Payload(d.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	p = gopacket.NewPacket(buf.Bytes(), LayerTypeDot11, gopacket.Default)
+	if got, ok := p.Layer(LayerTypeDot11).(*Dot11); !ok || got.HTControl == nil || !reflect.DeepEqual(*got.HTControl, wantHTControl) {
+		t.Errorf("HT Control serialized as %x", buf.Bytes())
+	}
+}
+
+func TestDot11SerializeFrames(t *testing.T) {
+	ap := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	sta := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+	bcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	opts := gopacket.SerializeOptions{ComputeChecksums: true}
+	for _, test := range []struct {
+		name   string
+		layers []gopacket.SerializableLayer
+		want   []gopacket.LayerType
+	}{
+		{"deauthentication", []gopacket.SerializableLayer{
+			&Dot11{Type: Dot11TypeMgmtDeauthentication, DurationID: 314, Address1: bcast, Address2: ap, Address3: ap, SequenceNumber: 4095, FragmentNumber: 1},
+			&Dot11MgmtDeauthentication{Reason: Dot11ReasonClass2FromNonAuth},
+		}, []gopacket.LayerType{LayerTypeDot11, LayerTypeDot11MgmtDeauthentication}},
+		{"beacon", []gopacket.SerializableLayer{
+			&Dot11{Type: Dot11TypeMgmtBeacon, Address1: bcast, Address2: ap, Address3: ap, SequenceNumber: 12},
+			&Dot11MgmtBeacon{Timestamp: 123456789, Interval: 100, Flags: 0x0411},
+			&Dot11InformationElement{ID: Dot11InformationElementIDSSID, Info: []byte("test")},
+			&Dot11InformationElement{ID: Dot11InformationElementIDRates, Info: []byte{0x82, 0x84, 0x8b, 0x96}},
+		}, []gopacket.LayerType{LayerTypeDot11, LayerTypeDot11MgmtBeacon, LayerTypeDot11InformationElement, LayerTypeDot11InformationElement}},
+		{"RTS", []gopacket.SerializableLayer{
+			&Dot11{Type: Dot11TypeCtrlRTS, DurationID: 200, Address1: ap, Address2: sta},
+		}, []gopacket.LayerType{LayerTypeDot11}},
+		{"QoS data", []gopacket.SerializableLayer{
+			&Dot11{Type: Dot11TypeDataQOSData, Flags: Dot11FlagsToDS | Dot11FlagsFromDS | Dot11FlagsWEP, Address1: ap, Address2: sta, Address3: bcast, Address4: sta,
+				SequenceNumber: 7, QOS: &Dot11QOS{TID: 5, EOSP: true, AckPolicy: Dot11AckPolicyNone, TXOP: 3}},
+			&Dot11DataQOSData{},
+			&Dot11WEP{BaseLayer: BaseLayer{Contents: []byte{1, 2, 3, 4, 5, 6, 7, 8}}},
+		}, []gopacket.LayerType{LayerTypeDot11, LayerTypeDot11DataQOSData, LayerTypeDot11WEP}},
+	} {
+		want := test.layers[0].(*Dot11)
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, opts, test.layers...); err != nil {
+			t.Fatal(test.name, err)
+		}
+		// The frame check sequence is only appended on request.
+		n := len(buf.Bytes())
+		want.AppendFCS = true
+		if err := gopacket.SerializeLayers(buf, opts, test.layers...); err != nil {
+			t.Fatal(test.name, err)
+		}
+		if len(buf.Bytes()) != n+4 {
+			t.Errorf("%s: serialized %d bytes with FCS, %d without", test.name, len(buf.Bytes()), n)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LayerTypeDot11, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal(test.name, "failed to decode packet:", p.ErrorLayer().Error())
+		}
+		checkLayers(p, test.want, t)
+		got := p.Layer(LayerTypeDot11).(*Dot11)
+		if !got.ChecksumValid() {
+			t.Errorf("%s: invalid checksum %#x", test.name, got.Checksum)
+		}
+		if got.Type != want.Type || got.Flags != want.Flags || got.DurationID != want.DurationID || got.SequenceNumber != want.SequenceNumber ||
+			got.FragmentNumber != want.FragmentNumber || !bytes.Equal(got.Address1, want.Address1) || !bytes.Equal(got.Address2, want.Address2) ||
+			!bytes.Equal(got.Address3, want.Address3) || !bytes.Equal(got.Address4, want.Address4) || !reflect.DeepEqual(got.QOS, want.QOS) {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, want)
+		}
+	}
+
+	d := &Dot11{Type: Dot11TypeMgmtDeauthentication, FragmentNumber: 16}
+	if err := gopacket.SerializeLayers(gopacket.NewSerializeBuffer(), opts, d); err == nil {
+		t.Error("serialized a fragment number of 16")
+	}
+}
@@ -0,0 +1,721 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// Dot11InformationElementValue is a decoded information element: a
+// *Dot11SSIDElement, *Dot11RatesElement, *Dot11RSNElement,
+// *Dot11CountryElement, *Dot11HTCapabilitiesElement,
+// *Dot11HTOperationElement, *Dot11VHTCapabilitiesElement,
+// *Dot11VHTOperationElement, *Dot11HECapabilitiesElement,
+// *Dot11HEOperationElement, *Dot11WPSElement, *Dot11WMMElement or
+// *Dot11VendorElement.
+type Dot11InformationElementValue interface {
+	// InformationElementID returns the ID of the element.
+	InformationElementID() Dot11InformationElementID
+}
+
+// Dot11ExtensionElementID is the ID of an element carried by an
+// information element of ID Dot11InformationElementIDExtension.
+type Dot11ExtensionElementID uint8
+
+// Dot11 extension element IDs.
+const (
+	Dot11ExtensionElementIDHECapabilities Dot11ExtensionElementID = 35
+	Dot11ExtensionElementIDHEOperation    Dot11ExtensionElementID = 36
+)
+
+// DecodeDot11InformationElement decodes an information element. Vendor
+// specific elements other than WPS and WMM are decoded as
+// *Dot11VendorElement. It returns an error if e is not of a supported ID,
+// or is malformed.
+func DecodeDot11InformationElement(e *Dot11InformationElement) (Dot11InformationElementValue, error) {
+	var v interface {
+		Dot11InformationElementValue
+		decode(data []byte) error
+	}
+	data := e.Info
+	switch e.ID {
+	case Dot11InformationElementIDSSID:
+		v = &Dot11SSIDElement{}
+	case Dot11InformationElementIDRates, Dot11InformationElementIDESRates:
+		v = &Dot11RatesElement{Extended: e.ID == Dot11InformationElementIDESRates}
+	case Dot11InformationElementIDRSNInfo:
+		v = &Dot11RSNElement{}
+	case Dot11InformationElementIDCountryInfo:
+		v = &Dot11CountryElement{}
+	case Dot11InformationElementIDHTCapabilities:
+		v = &Dot11HTCapabilitiesElement{}
+	case Dot11InformationElementIDHTInfo:
+		v = &Dot11HTOperationElement{}
+	case Dot11InformationElementIDVHTCapabilities:
+		v = &Dot11VHTCapabilitiesElement{}
+	case Dot11InformationElementIDVHTOperation:
+		v = &Dot11VHTOperationElement{}
+	case Dot11InformationElementIDExtension:
+		if len(data) == 0 {
+			return nil, fmt.Errorf("extension element without an ID")
+		}
+		switch Dot11ExtensionElementID(data[0]) {
+		case Dot11ExtensionElementIDHECapabilities:
+			v = &Dot11HECapabilitiesElement{}
+		case Dot11ExtensionElementIDHEOperation:
+			v = &Dot11HEOperationElement{}
+		default:
+			return nil, fmt.Errorf("unsupported 802.11 extension element %d", data[0])
+		}
+		data = data[1:]
+	case Dot11InformationElementIDVendor:
+		if len(e.OUI) != 4 {
+			return nil, fmt.Errorf("vendor element OUI of %d bytes", len(e.OUI))
+		}
+		vendor := &Dot11VendorElement{Type: e.OUI[3], Data: e.Info}
+		copy(vendor.OUI[:], e.OUI)
+		switch {
+		case vendor.OUI == dot11MicrosoftOUI && vendor.Type == 4:
+			v = &Dot11WPSElement{}
+		case vendor.OUI == dot11MicrosoftOUI && vendor.Type == 2:
+			v = &Dot11WMMElement{}
+		default:
+			return vendor, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported 802.11 information element %v", e.ID)
+	}
+	if err := v.decode(data); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Dot11InformationElementValues returns the valid information elements of
+// p, of the IDs supported by DecodeDot11InformationElement.
+func Dot11InformationElementValues(p gopacket.Packet) []Dot11InformationElementValue {
+	var values []Dot11InformationElementValue
+	for _, l := range p.Layers() {
+		if e, ok := l.(*Dot11InformationElement); ok {
+			if v, err := DecodeDot11InformationElement(e); err == nil {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func dot11ElementLength(name string, data []byte, length int) error {
+	if len(data) < length {
+		return fmt.Errorf("%s element of %d bytes, %d required", name, len(data), length)
+	}
+	return nil
+}
+
+// Dot11SSIDElement is an SSID element. Hidden networks may advertise an
+// empty SSID, or one of zeros.
+type Dot11SSIDElement struct {
+	SSID string
+}
+
+func (e *Dot11SSIDElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDSSID
+}
+
+func (e *Dot11SSIDElement) decode(data []byte) error {
+	if len(data) > 32 {
+		return fmt.Errorf("SSID of %d bytes", len(data))
+	}
+	e.SSID = string(data)
+	return nil
+}
+
+// Dot11Rate is a data rate of a Supported Rates element, in units of
+// 500 kbit/s, whose high bit is set for the rates of the basic rate set.
+type Dot11Rate uint8
+
+// Basic returns whether r is in the basic rate set, which all stations of
+// the BSS must support.
+func (r Dot11Rate) Basic() bool {
+	return r&0x80 != 0
+}
+
+// Mbps returns the rate in Mbit/s.
+func (r Dot11Rate) Mbps() float64 {
+	return float64(r&0x7f) / 2
+}
+
+// Dot11RatesElement is a Supported Rates element, or, if Extended is set, an
+// Extended Supported Rates element.
+type Dot11RatesElement struct {
+	Extended bool
+	Rates    []Dot11Rate
+}
+
+func (e *Dot11RatesElement) InformationElementID() Dot11InformationElementID {
+	if e.Extended {
+		return Dot11InformationElementIDESRates
+	}
+	return Dot11InformationElementIDRates
+}
+
+func (e *Dot11RatesElement) decode(data []byte) error {
+	e.Rates = make([]Dot11Rate, len(data))
+	for i, r := range data {
+		e.Rates[i] = Dot11Rate(r)
+	}
+	return nil
+}
+
+var dot11MicrosoftOUI = [3]byte{0x00, 0x50, 0xf2}
+
+// Dot11Suite is a cipher or AKM suite selector: an OUI followed by a suite
+// type.
+type Dot11Suite uint32
+
+// Cipher and AKM suites of the 802.11 OUI.
+const (
+	Dot11CipherSuiteUseGroup Dot11Suite = 0x000fac00
+	Dot11CipherSuiteWEP40    Dot11Suite = 0x000fac01
+	Dot11CipherSuiteTKIP     Dot11Suite = 0x000fac02
+	Dot11CipherSuiteCCMP     Dot11Suite = 0x000fac04
+	Dot11CipherSuiteWEP104   Dot11Suite = 0x000fac05
+	Dot11CipherSuiteBIPCMAC  Dot11Suite = 0x000fac06
+	Dot11CipherSuiteGCMP     Dot11Suite = 0x000fac08
+	Dot11CipherSuiteGCMP256  Dot11Suite = 0x000fac09
+	Dot11CipherSuiteCCMP256  Dot11Suite = 0x000fac0a
+	Dot11CipherSuiteBIPGMAC  Dot11Suite = 0x000fac0b
+
+	Dot11AKMSuite8021X       Dot11Suite = 0x000fac01
+	Dot11AKMSuitePSK         Dot11Suite = 0x000fac02
+	Dot11AKMSuiteFT8021X     Dot11Suite = 0x000fac03
+	Dot11AKMSuiteFTPSK       Dot11Suite = 0x000fac04
+	Dot11AKMSuite8021XSHA256 Dot11Suite = 0x000fac05
+	Dot11AKMSuitePSKSHA256   Dot11Suite = 0x000fac06
+	Dot11AKMSuiteSAE         Dot11Suite = 0x000fac08
+	Dot11AKMSuiteFTSAE       Dot11Suite = 0x000fac09
+	Dot11AKMSuiteSuiteB      Dot11Suite = 0x000fac0b
+	Dot11AKMSuiteSuiteB192   Dot11Suite = 0x000fac0c
+	Dot11AKMSuiteOWE         Dot11Suite = 0x000fac12
+)
+
+// OUI returns the organization defining the suite.
+func (s Dot11Suite) OUI() [3]byte {
+	return [3]byte{byte(s >> 24), byte(s >> 16), byte(s >> 8)}
+}
+
+// Type returns the type of the suite, defined by its OUI.
+func (s Dot11Suite) Type() uint8 {
+	return uint8(s)
+}
+
+func (s Dot11Suite) String() string {
+	o := s.OUI()
+	return fmt.Sprintf("%02x-%02x-%02x:%d", o[0], o[1], o[2], s.Type())
+}
+
+func dot11Suites(data []byte) ([]Dot11Suite, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, fmt.Errorf("suite count truncated")
+	}
+	n := int(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < 4*n {
+		return nil, nil, fmt.Errorf("%d suites in %d bytes", n, len(data))
+	}
+	suites := make([]Dot11Suite, n)
+	for i := range suites {
+		suites[i] = Dot11Suite(binary.BigEndian.Uint32(data[4*i:]))
+	}
+	return suites, data[4*n:], nil
+}
+
+// Dot11RSNElement is a Robust Security Network element. The fields after
+// Version are optional, and are only set if the element carries them.
+type Dot11RSNElement struct {
+	Version         uint16
+	GroupCipher     Dot11Suite
+	PairwiseCiphers []Dot11Suite
+	AKMSuites       []Dot11Suite
+	Capabilities    uint16
+	PMKIDs          [][16]byte
+	// GroupManagementCipher is the cipher protecting group management
+	// frames, with management frame protection.
+	GroupManagementCipher Dot11Suite
+}
+
+// Dot11 RSN capabilities.
+const (
+	Dot11RSNCapabilityPreauth uint16 = 0x0001
+	Dot11RSNCapabilityMFPR    uint16 = 0x0040
+	Dot11RSNCapabilityMFPC    uint16 = 0x0080
+)
+
+func (e *Dot11RSNElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDRSNInfo
+}
+
+func (e *Dot11RSNElement) decode(data []byte) (err error) {
+	if err := dot11ElementLength("RSN", data, 2); err != nil {
+		return err
+	}
+	e.Version = binary.LittleEndian.Uint16(data)
+	if data = data[2:]; len(data) < 4 {
+		return nil
+	}
+	e.GroupCipher = Dot11Suite(binary.BigEndian.Uint32(data))
+	if data = data[4:]; len(data) == 0 {
+		return nil
+	}
+	if e.PairwiseCiphers, data, err = dot11Suites(data); err != nil {
+		return fmt.Errorf("RSN pairwise ciphers: %v", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if e.AKMSuites, data, err = dot11Suites(data); err != nil {
+		return fmt.Errorf("RSN AKM suites: %v", err)
+	}
+	if len(data) < 2 {
+		return nil
+	}
+	e.Capabilities = binary.LittleEndian.Uint16(data)
+	if data = data[2:]; len(data) < 2 {
+		return nil
+	}
+	n := int(binary.LittleEndian.Uint16(data))
+	if data = data[2:]; len(data) < 16*n {
+		return fmt.Errorf("%d RSN PMKIDs in %d bytes", n, len(data))
+	}
+	e.PMKIDs = make([][16]byte, n)
+	for i := range e.PMKIDs {
+		copy(e.PMKIDs[i][:], data[16*i:])
+	}
+	if data = data[16*n:]; len(data) >= 4 {
+		e.GroupManagementCipher = Dot11Suite(binary.BigEndian.Uint32(data))
+	}
+	return nil
+}
+
+// Dot11CountryTriplet is a subband of a Country element: NumChannels
+// channels from FirstChannel, allowed MaxTxPower dBm. Triplets whose
+// FirstChannel is 201 or more are operating extension triplets, of an
+// operating extension identifier, operating class and coverage class.
+type Dot11CountryTriplet struct {
+	FirstChannel, NumChannels, MaxTxPower uint8
+}
+
+// Dot11CountryElement is a Country element.
+type Dot11CountryElement struct {
+	// Code is the ISO 3166 code of the country, and Environment 'I'
+	// for indoors, 'O' for outdoors, or ' ' for both.
+	Code        string
+	Environment byte
+	Triplets    []Dot11CountryTriplet
+}
+
+func (e *Dot11CountryElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDCountryInfo
+}
+
+func (e *Dot11CountryElement) decode(data []byte) error {
+	if err := dot11ElementLength("Country", data, 3); err != nil {
+		return err
+	}
+	e.Code, e.Environment = string(data[:2]), data[2]
+	// The element is padded to an even length.
+	for data = data[3:]; len(data) >= 3; data = data[3:] {
+		e.Triplets = append(e.Triplets, Dot11CountryTriplet{data[0], data[1], data[2]})
+	}
+	return nil
+}
+
+// Dot11HTCapabilitiesElement is an HT (802.11n) Capabilities element.
+type Dot11HTCapabilitiesElement struct {
+	Info            uint16
+	AMPDUParameters uint8
+	// MCSSet is the bitmap of the supported MCS, followed by the highest
+	// supported data rate and transmit parameters.
+	MCSSet                 [16]byte
+	ExtendedCapabilities   uint16
+	TxBeamforming          uint32
+	AntennaSelectionCapabs uint8
+}
+
+func (e *Dot11HTCapabilitiesElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDHTCapabilities
+}
+
+func (e *Dot11HTCapabilitiesElement) decode(data []byte) error {
+	if err := dot11ElementLength("HT Capabilities", data, 26); err != nil {
+		return err
+	}
+	e.Info = binary.LittleEndian.Uint16(data)
+	e.AMPDUParameters = data[2]
+	copy(e.MCSSet[:], data[3:19])
+	e.ExtendedCapabilities = binary.LittleEndian.Uint16(data[19:])
+	e.TxBeamforming = binary.LittleEndian.Uint32(data[21:])
+	e.AntennaSelectionCapabs = data[25]
+	return nil
+}
+
+// ChannelWidth40 returns whether 40 MHz channels are supported.
+func (e *Dot11HTCapabilitiesElement) ChannelWidth40() bool {
+	return e.Info&0x0002 != 0
+}
+
+// ShortGI20 and ShortGI40 return whether the short guard interval is
+// supported on 20 and 40 MHz channels.
+func (e *Dot11HTCapabilitiesElement) ShortGI20() bool {
+	return e.Info&0x0020 != 0
+}
+
+func (e *Dot11HTCapabilitiesElement) ShortGI40() bool {
+	return e.Info&0x0040 != 0
+}
+
+// SpatialStreams returns the number of spatial streams for which an MCS
+// is supported on reception.
+func (e *Dot11HTCapabilitiesElement) SpatialStreams() int {
+	n := 0
+	for i := 0; i < 4; i++ {
+		if e.MCSSet[i] != 0 {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// Dot11HTOperationElement is an HT Operation element.
+type Dot11HTOperationElement struct {
+	PrimaryChannel uint8
+	Info           [5]byte
+	BasicMCSSet    [16]byte
+}
+
+func (e *Dot11HTOperationElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDHTInfo
+}
+
+func (e *Dot11HTOperationElement) decode(data []byte) error {
+	if err := dot11ElementLength("HT Operation", data, 22); err != nil {
+		return err
+	}
+	e.PrimaryChannel = data[0]
+	copy(e.Info[:], data[1:6])
+	copy(e.BasicMCSSet[:], data[6:22])
+	return nil
+}
+
+// SecondaryChannelOffset returns 1 if the secondary channel is above the
+// primary channel, 3 if it is below, and 0 if there is none.
+func (e *Dot11HTOperationElement) SecondaryChannelOffset() uint8 {
+	return e.Info[0] & 0x03
+}
+
+// AnyChannelWidth returns whether stations may use channels wider than
+// 20 MHz.
+func (e *Dot11HTOperationElement) AnyChannelWidth() bool {
+	return e.Info[0]&0x04 != 0
+}
+
+// Dot11VHTCapabilitiesElement is a VHT (802.11ac) Capabilities element.
+type Dot11VHTCapabilitiesElement struct {
+	Info uint32
+	// The MCS maps hold 2 bits per spatial stream, 3 for streams which
+	// are not supported.
+	RxMCSMap, RxHighestRate uint16
+	TxMCSMap, TxHighestRate uint16
+}
+
+func (e *Dot11VHTCapabilitiesElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDVHTCapabilities
+}
+
+func (e *Dot11VHTCapabilitiesElement) decode(data []byte) error {
+	if err := dot11ElementLength("VHT Capabilities", data, 12); err != nil {
+		return err
+	}
+	e.Info = binary.LittleEndian.Uint32(data)
+	e.RxMCSMap = binary.LittleEndian.Uint16(data[4:])
+	e.RxHighestRate = binary.LittleEndian.Uint16(data[6:]) & 0x1fff
+	e.TxMCSMap = binary.LittleEndian.Uint16(data[8:])
+	e.TxHighestRate = binary.LittleEndian.Uint16(data[10:]) & 0x1fff
+	return nil
+}
+
+// SupportedChannelWidthSet returns 0 if neither 160 nor 80+80 MHz channels
+// are supported, 1 if 160 MHz channels are, and 2 if both are.
+func (e *Dot11VHTCapabilitiesElement) SupportedChannelWidthSet() uint8 {
+	return uint8(e.Info>>2) & 0x03
+}
+
+// SpatialStreams returns the number of spatial streams supported on
+// reception.
+func (e *Dot11VHTCapabilitiesElement) SpatialStreams() int {
+	return dot11MCSMapStreams(e.RxMCSMap)
+}
+
+func dot11MCSMapStreams(m uint16) int {
+	n := 0
+	for i := 0; i < 8; i++ {
+		if (m>>(2*uint(i)))&0x03 != 0x03 {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// Dot11VHTOperationElement is a VHT Operation element.
+type Dot11VHTOperationElement struct {
+	// ChannelWidth is 0 for 20 or 40 MHz channels, as the HT Operation
+	// element tells, and 1 for 80, 160 or 80+80 MHz ones, with the channel
+	// center frequency segments CenterFrequency0 and CenterFrequency1.
+	ChannelWidth                       uint8
+	CenterFrequency0, CenterFrequency1 uint8
+	BasicMCSMap                        uint16
+}
+
+func (e *Dot11VHTOperationElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDVHTOperation
+}
+
+func (e *Dot11VHTOperationElement) decode(data []byte) error {
+	if err := dot11ElementLength("VHT Operation", data, 5); err != nil {
+		return err
+	}
+	e.ChannelWidth, e.CenterFrequency0, e.CenterFrequency1 = data[0], data[1], data[2]
+	e.BasicMCSMap = binary.LittleEndian.Uint16(data[3:])
+	return nil
+}
+
+// Dot11HECapabilitiesElement is an HE (802.11ax) Capabilities element.
+type Dot11HECapabilitiesElement struct {
+	MACCapabilities [6]byte
+	PHYCapabilities [11]byte
+	// MCSNSS holds the supported HE-MCS and NSS sets, of 4 bytes for
+	// channels up to 80 MHz, followed by the sets for 160 and 80+80 MHz
+	// channels if they are supported.
+	MCSNSS []byte
+	// PPEThresholds are the optional PPE thresholds.
+	PPEThresholds []byte
+}
+
+func (e *Dot11HECapabilitiesElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDExtension
+}
+
+func (e *Dot11HECapabilitiesElement) decode(data []byte) error {
+	if err := dot11ElementLength("HE Capabilities", data, 21); err != nil {
+		return err
+	}
+	copy(e.MACCapabilities[:], data[:6])
+	copy(e.PHYCapabilities[:], data[6:17])
+	// The channel width set of the PHY capabilities tells which MCS and
+	// NSS sets follow.
+	n := 4
+	if width := e.PHYCapabilities[0] >> 1; width&0x04 != 0 {
+		n += 4
+		if width&0x08 != 0 {
+			n += 4
+		}
+	}
+	if err := dot11ElementLength("HE Capabilities", data, 17+n); err != nil {
+		return err
+	}
+	e.MCSNSS = data[17 : 17+n]
+	if e.PHYCapabilities[6]&0x80 != 0 {
+		e.PPEThresholds = data[17+n:]
+	}
+	return nil
+}
+
+// SpatialStreams returns the number of spatial streams supported on
+// reception on channels up to 80 MHz.
+func (e *Dot11HECapabilitiesElement) SpatialStreams() int {
+	return dot11MCSMapStreams(binary.LittleEndian.Uint16(e.MCSNSS))
+}
+
+// Dot11HEOperationElement is an HE Operation element.
+type Dot11HEOperationElement struct {
+	// Parameters are the 24 bits of the HE Operation Parameters field.
+	Parameters   uint32
+	BSSColorInfo uint8
+	BasicMCSNSS  uint16
+	// The optional fields are set as Parameters tells.
+	VHTOperation              *Dot11VHTOperationElement
+	MaxCoHostedBSSIDIndicator uint8
+	SixGHzOperation           []byte
+}
+
+func (e *Dot11HEOperationElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDExtension
+}
+
+func (e *Dot11HEOperationElement) decode(data []byte) error {
+	if err := dot11ElementLength("HE Operation", data, 6); err != nil {
+		return err
+	}
+	e.Parameters = uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+	e.BSSColorInfo = data[3]
+	e.BasicMCSNSS = binary.LittleEndian.Uint16(data[4:])
+	data = data[6:]
+	if e.Parameters&(1<<14) != 0 {
+		if err := dot11ElementLength("HE Operation", data, 3); err != nil {
+			return err
+		}
+		e.VHTOperation = &Dot11VHTOperationElement{ChannelWidth: data[0], CenterFrequency0: data[1], CenterFrequency1: data[2]}
+		data = data[3:]
+	}
+	if e.Parameters&(1<<15) != 0 {
+		if err := dot11ElementLength("HE Operation", data, 1); err != nil {
+			return err
+		}
+		e.MaxCoHostedBSSIDIndicator = data[0]
+		data = data[1:]
+	}
+	if e.Parameters&(1<<17) != 0 {
+		if err := dot11ElementLength("HE Operation", data, 5); err != nil {
+			return err
+		}
+		e.SixGHzOperation = data[:5]
+	}
+	return nil
+}
+
+// BSSColor returns the BSS color, telling apart overlapping BSSs.
+func (e *Dot11HEOperationElement) BSSColor() uint8 {
+	return e.BSSColorInfo & 0x3f
+}
+
+// Dot11VendorElement is a vendor specific element, of a vendor OUI and
+// type, other than those decoded as Dot11WPSElement or Dot11WMMElement.
+type Dot11VendorElement struct {
+	OUI  [3]byte
+	Type uint8
+	Data []byte
+}
+
+func (e *Dot11VendorElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDVendor
+}
+
+// Dot11WPSAttributeType is the type of a Wi-Fi Protected Setup attribute.
+type Dot11WPSAttributeType uint16
+
+// Dot11 WPS attribute types.
+const (
+	Dot11WPSAttributeConfigMethods   Dot11WPSAttributeType = 0x1008
+	Dot11WPSAttributeDeviceName      Dot11WPSAttributeType = 0x1011
+	Dot11WPSAttributeManufacturer    Dot11WPSAttributeType = 0x1021
+	Dot11WPSAttributeModelName       Dot11WPSAttributeType = 0x1023
+	Dot11WPSAttributeModelNumber     Dot11WPSAttributeType = 0x1024
+	Dot11WPSAttributeSerialNumber    Dot11WPSAttributeType = 0x1042
+	Dot11WPSAttributeWPSState        Dot11WPSAttributeType = 0x1044
+	Dot11WPSAttributeUUIDE           Dot11WPSAttributeType = 0x1047
+	Dot11WPSAttributeVersion         Dot11WPSAttributeType = 0x104a
+	Dot11WPSAttributeAPSetupLocked   Dot11WPSAttributeType = 0x1057
+	Dot11WPSAttributeVendorExtension Dot11WPSAttributeType = 0x1049
+)
+
+// Dot11WPSAttribute is an attribute of a WPS element.
+type Dot11WPSAttribute struct {
+	Type  Dot11WPSAttributeType
+	Value []byte
+}
+
+// Dot11WPSElement is a Wi-Fi Protected Setup vendor specific element.
+type Dot11WPSElement struct {
+	Attributes []Dot11WPSAttribute
+}
+
+func (e *Dot11WPSElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDVendor
+}
+
+func (e *Dot11WPSElement) decode(data []byte) error {
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return fmt.Errorf("WPS attribute header truncated")
+		}
+		a := Dot11WPSAttribute{Type: Dot11WPSAttributeType(binary.BigEndian.Uint16(data))}
+		n := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < 4+n {
+			return fmt.Errorf("WPS attribute %#x of %d bytes truncated", a.Type, n)
+		}
+		a.Value = data[4 : 4+n]
+		e.Attributes = append(e.Attributes, a)
+		data = data[4+n:]
+	}
+	return nil
+}
+
+// Attribute returns the value of the first attribute of type t, or nil.
+func (e *Dot11WPSElement) Attribute(t Dot11WPSAttributeType) []byte {
+	for _, a := range e.Attributes {
+		if a.Type == t {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// Dot11WMMAccessCategory holds the parameters of a WMM access category.
+type Dot11WMMAccessCategory struct {
+	// ACI is the access category: 0 for best effort, 1 for background,
+	// 2 for video and 3 for voice.
+	ACI uint8
+	// ACM is set if admission control is mandatory.
+	ACM   bool
+	AIFSN uint8
+	// ECWMin and ECWMax are the exponents of the contention window
+	// bounds.
+	ECWMin, ECWMax uint8
+	// TXOPLimit is the transmit opportunity limit, in units of 32 µs.
+	TXOPLimit uint16
+}
+
+// Dot11WMMElement is a Wi-Fi Multimedia vendor specific element: an
+// information element, of subtype 0, or a parameter element, of subtype 1,
+// which also carries the parameters of the access categories.
+type Dot11WMMElement struct {
+	Subtype, Version uint8
+	QoSInfo          uint8
+	AccessCategories []Dot11WMMAccessCategory
+}
+
+func (e *Dot11WMMElement) InformationElementID() Dot11InformationElementID {
+	return Dot11InformationElementIDVendor
+}
+
+func (e *Dot11WMMElement) decode(data []byte) error {
+	if err := dot11ElementLength("WMM", data, 3); err != nil {
+		return err
+	}
+	e.Subtype, e.Version, e.QoSInfo = data[0], data[1], data[2]
+	if e.Subtype != 1 {
+		return nil
+	}
+	if err := dot11ElementLength("WMM parameter", data, 20); err != nil {
+		return err
+	}
+	// A reserved byte follows the QoS info.
+	for data = data[4:]; len(data) >= 4; data = data[4:] {
+		e.AccessCategories = append(e.AccessCategories, Dot11WMMAccessCategory{
+			ACI:       data[0] >> 5 & 0x03,
+			ACM:       data[0]&0x10 != 0,
+			AIFSN:     data[0] & 0x0f,
+			ECWMin:    data[1] & 0x0f,
+			ECWMax:    data[1] >> 4,
+			TXOPLimit: binary.LittleEndian.Uint16(data[2:]),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// Information elements of a beacon of a WPA2/WPA3 transition network.
+var testDot11InformationElements = []byte{
+	0x00, 0x04, 't', 'e', 's', 't', // SSID
+	0x01, 0x04, 0x82, 0x84, 0x0b, 0x16, // Supported Rates
+	0x32, 0x02, 0x30, 0x48, // Extended Supported Rates
+	0x07, 0x06, 'U', 'S', ' ', 0x01, 0x0b, 0x1e, // Country
+	0x30, 0x18, 0x01, 0x00, // RSN version
+	0x00, 0x0f, 0xac, 0x04, // group cipher
+	0x01, 0x00, 0x00, 0x0f, 0xac, 0x04, // pairwise ciphers
+	0x02, 0x00, 0x00, 0x0f, 0xac, 0x02, 0x00, 0x0f, 0xac, 0x08, // AKM suites
+	0x80, 0x00, // capabilities
+	0x2d, 0x1a, 0xef, 0x01, 0x1b, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // HT Capabilities
+	0x3d, 0x16, 0x24, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // HT Operation
+	0xbf, 0x0c, 0x32, 0x00, 0x80, 0x03, 0xfa, 0xff, 0x00, 0x00, 0xfa, 0xff, 0x00, 0x00, // VHT Capabilities
+	0xc0, 0x05, 0x01, 0x2a, 0x00, 0xfc, 0xff, // VHT Operation
+	0xff, 0x1a, 0x23, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, // HE Capabilities, MAC capabilities
+	0x0c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // PHY capabilities
+	0xfa, 0xff, 0xfa, 0xff, 0x00, 0x00, 0x00, 0x00, // MCS and NSS sets for 80 and 160 MHz
+	0xff, 0x0a, 0x24, 0x00, 0x40, 0x00, 0x05, 0xfc, 0xff, 0x01, 0x2a, 0x00, // HE Operation
+	0xdd, 0x18, 0x00, 0x50, 0xf2, 0x02, 0x01, 0x01, 0x80, 0x00, // WMM parameters
+	0x03, 0xa4, 0x00, 0x00, 0x27, 0xa4, 0x00, 0x00, 0x42, 0x43, 0x5e, 0x00, 0x62, 0x32, 0x2f, 0x00,
+	0xdd, 0x0e, 0x00, 0x50, 0xf2, 0x04, // WPS
+	0x10, 0x4a, 0x00, 0x01, 0x10,
+	0x10, 0x44, 0x00, 0x01, 0x02,
+	0xdd, 0x05, 0x00, 0x10, 0x18, 0x02, 0x01, // vendor
+	0x00, 0x00, // hidden SSID
+}
+
+func TestDot11InformationElementValues(t *testing.T) {
+	p := gopacket.NewPacket(testDot11InformationElements, LayerTypeDot11InformationElement, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	want := []Dot11InformationElementValue{
+		&Dot11SSIDElement{SSID: "test"},
+		&Dot11RatesElement{Rates: []Dot11Rate{0x82, 0x84, 0x0b, 0x16}},
+		&Dot11RatesElement{Extended: true, Rates: []Dot11Rate{0x30, 0x48}},
+		&Dot11CountryElement{Code: "US", Environment: ' ', Triplets: []Dot11CountryTriplet{{1, 11, 30}}},
+		&Dot11RSNElement{
+			Version:         1,
+			GroupCipher:     Dot11CipherSuiteCCMP,
+			PairwiseCiphers: []Dot11Suite{Dot11CipherSuiteCCMP},
+			AKMSuites:       []Dot11Suite{Dot11AKMSuitePSK, Dot11AKMSuiteSAE},
+			Capabilities:    Dot11RSNCapabilityMFPC,
+		},
+		&Dot11HTCapabilitiesElement{Info: 0x01ef, AMPDUParameters: 0x1b, MCSSet: [16]byte{0xff, 0xff}},
+		&Dot11HTOperationElement{PrimaryChannel: 36, Info: [5]byte{0x05}},
+		&Dot11VHTCapabilitiesElement{Info: 0x03800032, RxMCSMap: 0xfffa, TxMCSMap: 0xfffa},
+		&Dot11VHTOperationElement{ChannelWidth: 1, CenterFrequency0: 42, BasicMCSMap: 0xfffc},
+		&Dot11HECapabilitiesElement{
+			MACCapabilities: [6]byte{0x01},
+			PHYCapabilities: [11]byte{0x0c},
+			MCSNSS:          []byte{0xfa, 0xff, 0xfa, 0xff, 0x00, 0x00, 0x00, 0x00},
+		},
+		&Dot11HEOperationElement{
+			Parameters:   0x004000,
+			BSSColorInfo: 0x05,
+			BasicMCSNSS:  0xfffc,
+			VHTOperation: &Dot11VHTOperationElement{ChannelWidth: 1, CenterFrequency0: 42},
+		},
+		&Dot11WMMElement{Subtype: 1, Version: 1, QoSInfo: 0x80, AccessCategories: []Dot11WMMAccessCategory{
+			{ACI: 0, AIFSN: 3, ECWMin: 4, ECWMax: 10},
+			{ACI: 1, AIFSN: 7, ECWMin: 4, ECWMax: 10},
+			{ACI: 2, AIFSN: 2, ECWMin: 3, ECWMax: 4, TXOPLimit: 94},
+			{ACI: 3, AIFSN: 2, ECWMin: 2, ECWMax: 3, TXOPLimit: 47},
+		}},
+		&Dot11WPSElement{Attributes: []Dot11WPSAttribute{
+			{Dot11WPSAttributeVersion, []byte{0x10}},
+			{Dot11WPSAttributeWPSState, []byte{0x02}},
+		}},
+		&Dot11VendorElement{OUI: [3]byte{0x00, 0x10, 0x18}, Type: 2, Data: []byte{0x01}},
+		&Dot11SSIDElement{},
+	}
+	got := Dot11InformationElementValues(p)
+	if len(got) != len(want) {
+		t.Fatalf("got %d elements, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("element %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	ht := got[5].(*Dot11HTCapabilitiesElement)
+	if !ht.ChannelWidth40() || !ht.ShortGI20() || !ht.ShortGI40() || ht.SpatialStreams() != 2 {
+		t.Errorf("HT capabilities %+v", ht)
+	}
+	if vht := got[7].(*Dot11VHTCapabilitiesElement); vht.SpatialStreams() != 2 || vht.SupportedChannelWidthSet() != 0 {
+		t.Errorf("VHT capabilities %+v", vht)
+	}
+	if he := got[9].(*Dot11HECapabilitiesElement); he.SpatialStreams() != 2 {
+		t.Errorf("HE capabilities %+v", he)
+	}
+	if he := got[10].(*Dot11HEOperationElement); he.BSSColor() != 5 {
+		t.Errorf("HE operation %+v", he)
+	}
+	if r := got[1].(*Dot11RatesElement).Rates[0]; !r.Basic() || r.Mbps() != 1 {
+		t.Errorf("rate %#x: basic %t, %v Mbit/s", r, r.Basic(), r.Mbps())
+	}
+	if v := got[12].(*Dot11WPSElement).Attribute(Dot11WPSAttributeWPSState); len(v) != 1 || v[0] != 2 {
+		t.Errorf("WPS state %x", v)
+	}
+}
+
+func TestDot11InformationElementErrors(t *testing.T) {
+	for _, e := range []*Dot11InformationElement{
+		{ID: Dot11InformationElementIDSSID, Info: make([]byte, 33)},
+		{ID: Dot11InformationElementIDRSNInfo, Info: []byte{1}},
+		{ID: Dot11InformationElementIDRSNInfo, Info: []byte{1, 0, 0x00, 0x0f, 0xac, 0x04, 2, 0, 0x00, 0x0f, 0xac, 0x04}},
+		{ID: Dot11InformationElementIDHTCapabilities, Info: make([]byte, 25)},
+		{ID: Dot11InformationElementIDExtension},
+		{ID: Dot11InformationElementIDExtension, Info: []byte{byte(Dot11ExtensionElementIDHEOperation), 0, 0x40, 0, 0, 0, 0}},
+		{ID: Dot11InformationElementIDVendor, OUI: []byte{0x00, 0x50, 0xf2, 0x04}, Info: []byte{0x10, 0x4a, 0x00, 0x02, 0x10}},
+		{ID: Dot11InformationElementIDTIM},
+	} {
+		if v, err := DecodeDot11InformationElement(e); err == nil {
+			t.Errorf("%v decoded as %+v", e, v)
+		}
+	}
+}
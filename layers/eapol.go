@@ -7,8 +7,12 @@
 package layers
 
 import (
+	"crypto/aes"
+	"crypto/rc4"
 	"encoding/binary"
+	"errors"
 	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -229,6 +233,7 @@ func (ek *EAPOLKey) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 			Payload:  data[totalLength:],
 		}
 	} else {
+		ek.EncryptedKeyData = nil
 		ek.BaseLayer = BaseLayer{
 			Contents: data[:eapolKeyFrameLen],
 			Payload:  data[eapolKeyFrameLen:],
@@ -300,3 +305,219 @@ func decodeEAPOLKey(data []byte, p gopacket.PacketBuilder) error {
 	ek := &EAPOLKey{}
 	return decodingLayerDecoder(ek, data, p)
 }
+
+// HandshakeMessage returns the number, from 1 to 4, of the message of the
+// 4-way handshake a pairwise EAPOL-Key frame is, or, for a group key frame,
+// 1 or 2 for the message of the group key handshake. It returns 0 for
+// frames it cannot tell, such as requests.
+func (ek *EAPOLKey) HandshakeMessage() int {
+	if ek.Request || ek.MICError {
+		return 0
+	}
+	if ek.KeyType == EAPOLKeyTypeGroupSMK {
+		switch {
+		case ek.KeyACK && ek.KeyMIC:
+			return 1
+		case !ek.KeyACK && ek.KeyMIC:
+			return 2
+		}
+		return 0
+	}
+	switch {
+	case ek.KeyACK && !ek.KeyMIC:
+		return 1
+	case ek.KeyACK && ek.KeyMIC:
+		return 3
+	case !ek.KeyACK && ek.KeyMIC:
+		// Message 4 has no nonce, and, but for WPA, the secure bit.
+		if ek.Secure || isZero(ek.Nonce) {
+			return 4
+		}
+		return 2
+	}
+	return 0
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DecryptKeyData decrypts EncryptedKeyData with the key encryption key
+// (KEK) derived from the PTK, returning the key data, which
+// DecodeEAPOLKeyData decodes. Key data is encrypted with RC4 for
+// EAPOLKeyDescriptorVersionRC4HMACMD5 and wrapped with AES, as RFC 3394
+// describes, for the other versions; the padding of the wrapped key data is
+// returned along with it.
+func (ek *EAPOLKey) DecryptKeyData(kek []byte) ([]byte, error) {
+	if !ek.HasEncryptedKeyData {
+		return nil, errors.New("EAPOLKey has no encrypted key data")
+	}
+	if ek.KeyDescriptorVersion != EAPOLKeyDescriptorVersionRC4HMACMD5 {
+		return aesKeyUnwrap(kek, ek.EncryptedKeyData)
+	}
+	// The RC4 key is the IV followed by the KEK, and the first 256 bytes of
+	// the key stream are discarded.
+	c, err := rc4.NewCipher(append(append([]byte(nil), ek.IV...), kek...))
+	if err != nil {
+		return nil, err
+	}
+	var discard [256]byte
+	c.XORKeyStream(discard[:], discard[:])
+	data := make([]byte, len(ek.EncryptedKeyData))
+	c.XORKeyStream(data, ek.EncryptedKeyData)
+	return data, nil
+}
+
+// aesKeyUnwrapIV is the initial value of RFC 3394.
+const aesKeyUnwrapIV = 0xa6a6a6a6a6a6a6a6
+
+func aesKeyUnwrap(kek, data []byte) ([]byte, error) {
+	if len(data) < 24 || len(data)%8 != 0 {
+		return nil, fmt.Errorf("wrapped key data length %d invalid", len(data))
+	}
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(data)/8 - 1
+	a := binary.BigEndian.Uint64(data)
+	r := append([]byte(nil), data[8:]...)
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			binary.BigEndian.PutUint64(b[:8], a^uint64(n*j+i))
+			copy(b[8:], r[(i-1)*8:i*8])
+			c.Decrypt(b[:], b[:])
+			a = binary.BigEndian.Uint64(b[:8])
+			copy(r[(i-1)*8:], b[8:])
+		}
+	}
+	if a != aesKeyUnwrapIV {
+		return nil, errors.New("key data integrity check failed, the KEK may be wrong")
+	}
+	return r, nil
+}
+
+// EAPOLKeyKDEType is the data type of a key data encapsulation (KDE) in
+// the key data of an EAPOL-Key frame.
+type EAPOLKeyKDEType uint8
+
+// Enumeration of EAPOLKeyKDEType
+const (
+	EAPOLKeyKDETypeGTK        EAPOLKeyKDEType = 1
+	EAPOLKeyKDETypeMACAddress EAPOLKeyKDEType = 3
+	EAPOLKeyKDETypePMKID      EAPOLKeyKDEType = 4
+	EAPOLKeyKDETypeNonce      EAPOLKeyKDEType = 6
+	EAPOLKeyKDETypeLifetime   EAPOLKeyKDEType = 7
+	EAPOLKeyKDETypeError      EAPOLKeyKDEType = 8
+	EAPOLKeyKDETypeIGTK       EAPOLKeyKDEType = 9
+	EAPOLKeyKDETypeKeyID      EAPOLKeyKDEType = 10
+	EAPOLKeyKDETypeOCI        EAPOLKeyKDEType = 13
+	EAPOLKeyKDETypeBIGTK      EAPOLKeyKDEType = 14
+)
+
+func (t EAPOLKeyKDEType) String() string {
+	switch t {
+	case EAPOLKeyKDETypeGTK:
+		return "GTK"
+	case EAPOLKeyKDETypeMACAddress:
+		return "MAC address"
+	case EAPOLKeyKDETypePMKID:
+		return "PMKID"
+	case EAPOLKeyKDETypeNonce:
+		return "Nonce"
+	case EAPOLKeyKDETypeLifetime:
+		return "Lifetime"
+	case EAPOLKeyKDETypeError:
+		return "Error"
+	case EAPOLKeyKDETypeIGTK:
+		return "IGTK"
+	case EAPOLKeyKDETypeKeyID:
+		return "Key ID"
+	case EAPOLKeyKDETypeOCI:
+		return "OCI"
+	case EAPOLKeyKDETypeBIGTK:
+		return "BIGTK"
+	default:
+		return fmt.Sprintf("unknown KDE type %d", t)
+	}
+}
+
+// EAPOLKeyKDE is a key data encapsulation, a vendor information element of
+// the IEEE 802.11 OUI 00-0F-AC in the key data of an EAPOL-Key frame.
+type EAPOLKeyKDE struct {
+	Type EAPOLKeyKDEType
+	Data []byte
+}
+
+// EAPOLKeyData is the decoded key data of an EAPOL-Key frame.
+type EAPOLKeyData struct {
+	// Elements are the information elements other than KDEs, such as
+	// the RSN element of message 3 of the 4-way handshake.
+	Elements []*Dot11InformationElement
+	KDEs     []EAPOLKeyKDE
+}
+
+// EAPOLKeyGTK is the group temporal key a GTK KDE carries.
+type EAPOLKeyGTK struct {
+	KeyID uint8
+	Tx    bool
+	Key   []byte
+}
+
+var eapolKeyKDEOUI = [3]byte{0x00, 0x0f, 0xac}
+
+// DecodeEAPOLKeyData decodes the information elements and KDEs of key
+// data, up to its padding: a vendor element ID followed by zeros.
+func DecodeEAPOLKeyData(data []byte) (*EAPOLKeyData, error) {
+	d := &EAPOLKeyData{}
+	for len(data) > 0 {
+		if data[0] == byte(Dot11InformationElementIDVendor) && (len(data) == 1 || isZero(data[1:])) {
+			break
+		}
+		e := &Dot11InformationElement{}
+		if err := e.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+			return nil, err
+		}
+		data = e.Payload
+		if e.ID == Dot11InformationElementIDVendor && [3]byte{e.OUI[0], e.OUI[1], e.OUI[2]} == eapolKeyKDEOUI {
+			d.KDEs = append(d.KDEs, EAPOLKeyKDE{Type: EAPOLKeyKDEType(e.OUI[3]), Data: e.Info})
+			continue
+		}
+		e.Payload = nil
+		d.Elements = append(d.Elements, e)
+	}
+	return d, nil
+}
+
+// KDE returns the data of the first KDE of type t, or nil.
+func (d *EAPOLKeyData) KDE(t EAPOLKeyKDEType) []byte {
+	for _, kde := range d.KDEs {
+		if kde.Type == t {
+			return kde.Data
+		}
+	}
+	return nil
+}
+
+// GTK returns the group temporal key of the GTK KDE, as message 3 of the
+// 4-way handshake and message 1 of the group key handshake carry it.
+func (d *EAPOLKeyData) GTK() (*EAPOLKeyGTK, error) {
+	data := d.KDE(EAPOLKeyKDETypeGTK)
+	if data == nil {
+		return nil, errors.New("key data has no GTK")
+	}
+	if len(data) < 3 {
+		return nil, fmt.Errorf("GTK KDE length %d too short", len(data))
+	}
+	return &EAPOLKeyGTK{
+		KeyID: data[0] & 0x03,
+		Tx:    data[0]&0x04 != 0,
+		Key:   data[2:],
+	}, nil
+}
@@ -6,6 +6,10 @@
 package layers
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rc4"
+	"encoding/binary"
 	"reflect"
 	"testing"
 
@@ -94,6 +98,16 @@ func TestPacketEAPOLKey(t *testing.T) {
 		if !reflect.DeepEqual(got, want) {
 			t.Errorf(eapolErrFmt, "EAPOLKey", got, want)
 		}
+		if n := got.HandshakeMessage(); n != 1 {
+			t.Errorf("handshake message %d, want 1", n)
+		}
+		d, err := DecodeEAPOLKeyData(got.Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pmkid := d.KDE(EAPOLKeyKDETypePMKID); !bytes.Equal(pmkid, testPacketEAPOLKey[4+eapolKeyFrameLen+6:]) {
+			t.Errorf("PMKID %x", pmkid)
+		}
 	}
 	{
 		got := p.Layer(LayerTypeDot11InformationElement).(*Dot11InformationElement)
@@ -116,6 +130,114 @@ func TestPacketEAPOLKey(t *testing.T) {
 	}
 }
 
+func TestEAPOLKeyHandshakeMessage(t *testing.T) {
+	nonce := make([]byte, 32)
+	nonce[0] = 1
+	for _, test := range []struct {
+		key  EAPOLKey
+		want int
+	}{
+		{EAPOLKey{KeyType: EAPOLKeyTypePairwise, KeyACK: true, Nonce: nonce}, 1},
+		{EAPOLKey{KeyType: EAPOLKeyTypePairwise, KeyMIC: true, Nonce: nonce}, 2},
+		{EAPOLKey{KeyType: EAPOLKeyTypePairwise, KeyACK: true, KeyMIC: true, Install: true, Secure: true, Nonce: nonce}, 3},
+		{EAPOLKey{KeyType: EAPOLKeyTypePairwise, KeyMIC: true, Secure: true, Nonce: make([]byte, 32)}, 4},
+		{EAPOLKey{KeyType: EAPOLKeyTypePairwise, KeyMIC: true, Nonce: make([]byte, 32)}, 4},
+		{EAPOLKey{KeyType: EAPOLKeyTypeGroupSMK, KeyACK: true, KeyMIC: true, Secure: true}, 1},
+		{EAPOLKey{KeyType: EAPOLKeyTypeGroupSMK, KeyMIC: true, Secure: true}, 2},
+		{EAPOLKey{KeyType: EAPOLKeyTypePairwise, KeyMIC: true, Request: true}, 0},
+	} {
+		if got := test.key.HandshakeMessage(); got != test.want {
+			t.Errorf("%+v: message %d, want %d", test.key, got, test.want)
+		}
+	}
+}
+
+// aesKeyWrap wraps data with kek, as RFC 3394 describes.
+func aesKeyWrap(kek, data []byte) []byte {
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		panic(err)
+	}
+	n := len(data) / 8
+	a := uint64(aesKeyUnwrapIV)
+	r := append([]byte(nil), data...)
+	var b [16]byte
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			binary.BigEndian.PutUint64(b[:8], a)
+			copy(b[8:], r[(i-1)*8:i*8])
+			c.Encrypt(b[:], b[:])
+			a = binary.BigEndian.Uint64(b[:8]) ^ uint64(n*j+i)
+			copy(r[(i-1)*8:], b[8:])
+		}
+	}
+	out := make([]byte, 8, 8+len(r))
+	binary.BigEndian.PutUint64(out, a)
+	return append(out, r...)
+}
+
+func TestEAPOLKeyDecryptKeyData(t *testing.T) {
+	// RFC 3394, section 4.1.
+	kek := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	ek := &EAPOLKey{
+		KeyDescriptorVersion: EAPOLKeyDescriptorVersionAESHMACSHA1,
+		HasEncryptedKeyData:  true,
+		EncryptedKeyData: []byte{
+			0x1f, 0xa6, 0x8b, 0x0a, 0x81, 0x12, 0xb4, 0x47, 0xae, 0xf3, 0x4b, 0xd8,
+			0xfb, 0x5a, 0x7b, 0x82, 0x9d, 0x3e, 0x86, 0x23, 0x71, 0xd2, 0xcf, 0xe5,
+		},
+	}
+	got, err := ek.DecryptKeyData(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}; !bytes.Equal(got, want) {
+		t.Errorf("unwrapped %x, want %x", got, want)
+	}
+	ek.EncryptedKeyData[0] ^= 1
+	if _, err := ek.DecryptKeyData(kek); err == nil {
+		t.Error("unwrapped corrupted key data")
+	}
+
+	// The key data of message 3 of the 4-way handshake.
+	keyData := []byte{
+		0x30, 0x14, 0x01, 0x00, 0x00, 0x0f, 0xac, 0x04, 0x01, 0x00, 0x00, 0x0f, 0xac, 0x04, 0x01, 0x00, 0x00, 0x0f, 0xac, 0x02, 0x00, 0x00, // RSN
+		0xdd, 0x16, 0x00, 0x0f, 0xac, 0x01, 0x05, 0x00, // GTK, key ID 1, Tx
+		0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf,
+		0xdd, 0x00, // padding
+	}
+	for _, version := range []EAPOLKeyDescriptorVersion{EAPOLKeyDescriptorVersionRC4HMACMD5, EAPOLKeyDescriptorVersionAESHMACSHA1} {
+		ek := &EAPOLKey{KeyDescriptorVersion: version, HasEncryptedKeyData: true, IV: make([]byte, 16)}
+		ek.IV[15] = 1
+		if version == EAPOLKeyDescriptorVersionRC4HMACMD5 {
+			c, _ := rc4.NewCipher(append(append([]byte(nil), ek.IV...), kek...))
+			ek.EncryptedKeyData = make([]byte, 256+len(keyData))
+			c.XORKeyStream(ek.EncryptedKeyData, append(make([]byte, 256), keyData...))
+			ek.EncryptedKeyData = ek.EncryptedKeyData[256:]
+		} else {
+			ek.EncryptedKeyData = aesKeyWrap(kek, keyData)
+		}
+		data, err := ek.DecryptKeyData(kek)
+		if err != nil {
+			t.Fatal(version, err)
+		}
+		d, err := DecodeEAPOLKeyData(data)
+		if err != nil {
+			t.Fatal(version, err)
+		}
+		if len(d.Elements) != 1 || d.Elements[0].ID != Dot11InformationElementIDRSNInfo || len(d.KDEs) != 1 {
+			t.Errorf("%v: decoded %+v", version, d)
+		}
+		gtk, err := d.GTK()
+		if err != nil {
+			t.Fatal(version, err)
+		}
+		if want := (&EAPOLKeyGTK{KeyID: 1, Tx: true, Key: keyData[30:46]}); !reflect.DeepEqual(gtk, want) {
+			t.Errorf("%v: GTK %+v, want %+v", version, gtk, want)
+		}
+	}
+}
+
 func BenchmarkDecodePacketEAPOLKey(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		gopacket.NewPacket(testPacketEAPOLKey, nil, gopacket.NoCopy)
@@ -45,6 +45,9 @@ var (
 	EndpointPPP = gopacket.RegisterEndpointType(9, gopacket.EndpointTypeMetadata{Name: "PPP", Formatter: func([]byte) string {
 		return "point"
 	}})
+	EndpointDCCPPort = gopacket.RegisterEndpointType(10, gopacket.EndpointTypeMetadata{Name: "DCCP", Formatter: func(b []byte) string {
+		return strconv.Itoa(int(binary.BigEndian.Uint16(b)))
+	}})
 )
 
 // NewIPEndpoint creates a new IP (v4 or v6) endpoint from a net.IP address.
@@ -95,3 +98,8 @@ func NewRUDPPortEndpoint(p RUDPPort) gopacket.Endpoint {
 func NewUDPLitePortEndpoint(p UDPLitePort) gopacket.Endpoint {
 	return newPortEndpoint(EndpointUDPLitePort, uint16(p))
 }
+
+// NewDCCPPortEndpoint returns an endpoint based on a DCCP port.
+func NewDCCPPortEndpoint(p DCCPPort) gopacket.Endpoint {
+	return newPortEndpoint(EndpointDCCPPort, uint16(p))
+}
@@ -10,6 +10,8 @@ package layers
 import (
 	"fmt"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/google/gopacket"
 )
@@ -25,6 +27,103 @@ type EnumMetadata struct {
 	LayerType gopacket.LayerType
 }
 
+// enumAliases holds names accepted by the Parse functions in addition to
+// the metadata names, keyed by enum type and upper case alias.
+var enumAliases = map[string]map[string]int{
+	"LinkType":   linkTypeAliases(),
+	"IPProtocol": {"ICMP": 1, "IPV6-ICMP": 58, "IPV6-FRAG": 44, "IPV6-ROUTE": 43, "IPV6-OPTS": 60, "IPV6-NONXT": 59},
+}
+
+// libpcapLinkTypes maps the LINKTYPE_ names used by libpcap (see
+// http://www.tcpdump.org/linktypes.html), without their prefix, to values.
+var libpcapLinkTypes = map[string]LinkType{
+	"NULL":                       LinkTypeNull,
+	"ETHERNET":                   LinkTypeEthernet,
+	"AX25":                       LinkTypeAX25,
+	"IEEE802_5":                  LinkTypeTokenRing,
+	"ARCNET_BSD":                 LinkTypeArcNet,
+	"SLIP":                       LinkTypeSLIP,
+	"PPP":                        LinkTypePPP,
+	"FDDI":                       LinkTypeFDDI,
+	"PPP_HDLC":                   LinkTypePPP_HDLC,
+	"PPP_ETHER":                  LinkTypePPPEthernet,
+	"ATM_RFC1483":                LinkTypeATM_RFC1483,
+	"RAW":                        LinkTypeRaw,
+	"C_HDLC":                     LinkTypeC_HDLC,
+	"IEEE802_11":                 LinkTypeIEEE802_11,
+	"FRELAY":                     LinkTypeFRelay,
+	"LOOP":                       LinkTypeLoop,
+	"LINUX_SLL":                  LinkTypeLinuxSLL,
+	"LTALK":                      LinkTypeLTalk,
+	"PFLOG":                      LinkTypePFLog,
+	"IEEE802_11_PRISM":           LinkTypePrismHeader,
+	"IP_OVER_FC":                 LinkTypeIPOverFC,
+	"SUNATM":                     LinkTypeSunATM,
+	"IEEE802_11_RADIOTAP":        LinkTypeIEEE80211Radio,
+	"ARCNET_LINUX":               LinkTypeARCNetLinux,
+	"APPLE_IP_OVER_IEEE1394":     LinkTypeIPOver1394,
+	"MTP2_WITH_PHDR":             LinkTypeMTP2Phdr,
+	"MTP2":                       LinkTypeMTP2,
+	"MTP3":                       LinkTypeMTP3,
+	"SCCP":                       LinkTypeSCCP,
+	"DOCSIS":                     LinkTypeDOCSIS,
+	"LINUX_IRDA":                 LinkTypeLinuxIRDA,
+	"LINUX_LAPD":                 LinkTypeLinuxLAPD,
+	"BLUETOOTH_HCI_H4":           LinkTypeBluetoothHCIH4,
+	"USB_LINUX":                  LinkTypeLinuxUSB48,
+	"IEEE802_15_4_WITHFCS":       LinkTypeIEEE802154,
+	"BLUETOOTH_HCI_H4_WITH_PHDR": LinkTypeBluetoothHCIH4PHDR,
+	"USB_LINUX_MMAPPED":          LinkTypeLinuxUSB,
+	"FC_2":                       LinkTypeFC2,
+	"FC_2_WITH_FRAME_DELIMS":     LinkTypeFC2Framed,
+	"CAN_SOCKETCAN":              LinkTypeCANSocketCAN,
+	"IPV4":                       LinkTypeIPv4,
+	"IPV6":                       LinkTypeIPv6,
+	"IEEE802_15_4_NOFCS":         LinkTypeIEEE802154NoFCS,
+	"USBPCAP":                    LinkTypeUSBPcap,
+	"LINUX_SLL2":                 LinkTypeLinuxSLL2,
+}
+
+func linkTypeAliases() map[string]int {
+	aliases := make(map[string]int)
+	for name, lt := range libpcapLinkTypes {
+		aliases["LINKTYPE_"+name] = int(lt)
+	}
+	return aliases
+}
+
+// parseEnum returns the value of the enum type typ named s. It accepts, in
+// this order:
+//
+//   - a name from md, matched exactly and then ignoring case; if several
+//     values share a name, the smallest one is returned
+//   - an alias, ignoring case, such as the libpcap LINKTYPE_ names of link
+//     types or the IANA keywords of IP protocols
+//   - a decimal, hex (0x) or octal (0) number
+func parseEnum(s, typ string, md []EnumMetadata) (int, error) {
+	unknown := "Unknown" + typ
+	if s == "" || s == unknown {
+		return 0, fmt.Errorf("invalid %s %q", typ, s)
+	}
+	for i := range md {
+		if md[i].Name == s {
+			return i, nil
+		}
+	}
+	for i := range md {
+		if md[i].Name != unknown && strings.EqualFold(md[i].Name, s) {
+			return i, nil
+		}
+	}
+	if v, ok := enumAliases[typ][strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	if n, err := strconv.ParseUint(s, 0, 32); err == nil && n < uint64(len(md)) {
+		return int(n), nil
+	}
+	return 0, fmt.Errorf("unknown %s %q", typ, s)
+}
+
 // EthernetType is an enumeration of ethernet type values, and acts as a decoder
 // for any type it supports.
 type EthernetType uint16
@@ -48,8 +147,11 @@ const (
 	EthernetTypeMPLSMulticast               EthernetType = 0x8848
 	EthernetTypeEAPOL                       EthernetType = 0x888e
 	EthernetTypeERSPAN                      EthernetType = 0x88be
+	EthernetTypeERSPANIII                   EthernetType = 0x22eb
 	EthernetTypeQinQ                        EthernetType = 0x88a8
+	EthernetTypeProfinet                    EthernetType = 0x8892
 	EthernetTypeLinkLayerDiscovery          EthernetType = 0x88cc
+	EthernetTypeCFM                         EthernetType = 0x8902
 	EthernetTypeEthernetCTP                 EthernetType = 0x9000
 )
 
@@ -65,6 +167,7 @@ const (
 	IPProtocolTCP             IPProtocol = 6
 	IPProtocolUDP             IPProtocol = 17
 	IPProtocolRUDP            IPProtocol = 27
+	IPProtocolDCCP            IPProtocol = 33
 	IPProtocolIPv6            IPProtocol = 41
 	IPProtocolIPv6Routing     IPProtocol = 43
 	IPProtocolIPv6Fragment    IPProtocol = 44
@@ -78,6 +181,7 @@ const (
 	IPProtocolIPIP            IPProtocol = 94
 	IPProtocolEtherIP         IPProtocol = 97
 	IPProtocolVRRP            IPProtocol = 112
+	IPProtocolL2TP            IPProtocol = 115
 	IPProtocolSCTP            IPProtocol = 132
 	IPProtocolUDPLite         IPProtocol = 136
 	IPProtocolMPLSInIP        IPProtocol = 137
@@ -85,7 +189,7 @@ const (
 
 // LinkType is an enumeration of link types, and acts as a decoder for any
 // link type it supports.
-type LinkType uint8
+type LinkType uint16
 
 const (
 	// According to pcap-linktype(7) and http://www.tcpdump.org/linktypes.html
@@ -121,11 +225,26 @@ const (
 	LinkTypeDOCSIS         LinkType = 143
 	LinkTypeLinuxIRDA      LinkType = 144
 	LinkTypeLinuxLAPD      LinkType = 177
-	LinkTypeLinuxUSB       LinkType = 220
-	LinkTypeFC2            LinkType = 224
-	LinkTypeFC2Framed      LinkType = 225
-	LinkTypeIPv4           LinkType = 228
-	LinkTypeIPv6           LinkType = 229
+	LinkTypeBluetoothHCIH4 LinkType = 187
+	// LinkTypeLinuxUSB48 is LinkTypeLinuxUSB with the 48 byte header of
+	// usbmon's older binary interface.
+	LinkTypeLinuxUSB48 LinkType = 189
+	LinkTypeIEEE802154 LinkType = 195
+	// LinkTypeBluetoothHCIH4PHDR is LinkTypeBluetoothHCIH4 with a direction
+	// pseudo-header, as captured by libpcap on Linux.
+	LinkTypeBluetoothHCIH4PHDR LinkType = 201
+	LinkTypeLinuxUSB           LinkType = 220
+	LinkTypeFC2                LinkType = 224
+	LinkTypeFC2Framed          LinkType = 225
+	LinkTypeCANSocketCAN       LinkType = 227
+	LinkTypeIPv4               LinkType = 228
+	LinkTypeIPv6               LinkType = 229
+	LinkTypeIEEE802154NoFCS    LinkType = 230
+	// LinkTypeUSBPcap is USB as captured by USBPcap on Windows.
+	LinkTypeUSBPcap LinkType = 249
+	// LinkTypeLinuxSLL2 is the version 2 of LinkTypeLinuxSLL, which libpcap
+	// uses for captures on Linux's "any" device.
+	LinkTypeLinuxSLL2 LinkType = 276
 )
 
 // PPPoECode is the PPPoE code enum, taken from http://tools.ietf.org/html/rfc2516
@@ -149,6 +268,11 @@ const (
 	PPPTypeIPv6          PPPType = 0x0057
 	PPPTypeMPLSUnicast   PPPType = 0x0281
 	PPPTypeMPLSMulticast PPPType = 0x0283
+	PPPTypeIPCP          PPPType = 0x8021
+	PPPTypeIPv6CP        PPPType = 0x8057
+	PPPTypeLCP           PPPType = 0xc021
+	PPPTypePAP           PPPType = 0xc023
+	PPPTypeCHAP          PPPType = 0xc223
 )
 
 // SCTPChunkType is an enumeration of chunk types inside SCTP packets.
@@ -168,6 +292,13 @@ const (
 	SCTPChunkTypeCookieEcho       SCTPChunkType = 10
 	SCTPChunkTypeCookieAck        SCTPChunkType = 11
 	SCTPChunkTypeShutdownComplete SCTPChunkType = 14
+	SCTPChunkTypeAuth             SCTPChunkType = 15
+	SCTPChunkTypeIData            SCTPChunkType = 64
+	SCTPChunkTypeASCONFAck        SCTPChunkType = 128
+	SCTPChunkTypeReconfig         SCTPChunkType = 130
+	SCTPChunkTypePad              SCTPChunkType = 132
+	SCTPChunkTypeForwardTSN       SCTPChunkType = 192
+	SCTPChunkTypeASCONF           SCTPChunkType = 193
 )
 
 // FDDIFrameControl is an enumeration of FDDI frame control bytes.
@@ -319,6 +450,9 @@ func initActualTypeData() {
 	EthernetTypeMetadata[EthernetTypeQinQ] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot1Q), Name: "Dot1Q", LayerType: LayerTypeDot1Q}
 	EthernetTypeMetadata[EthernetTypeTransparentEthernetBridging] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEthernet), Name: "TransparentEthernetBridging", LayerType: LayerTypeEthernet}
 	EthernetTypeMetadata[EthernetTypeERSPAN] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeERSPANII), Name: "ERSPAN Type II", LayerType: LayerTypeERSPANII}
+	EthernetTypeMetadata[EthernetTypeERSPANIII] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeERSPANIII), Name: "ERSPAN Type III", LayerType: LayerTypeERSPANIII}
+	EthernetTypeMetadata[EthernetTypeProfinet] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeProfinet), Name: "Profinet", LayerType: LayerTypeProfinet}
+	EthernetTypeMetadata[EthernetTypeCFM] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCFM), Name: "CFM", LayerType: LayerTypeCFM}
 
 	IPProtocolMetadata[IPProtocolIPv4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4", LayerType: LayerTypeIPv4}
 	IPProtocolMetadata[IPProtocolTCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeTCP), Name: "TCP", LayerType: LayerTypeTCP}
@@ -330,6 +464,7 @@ func initActualTypeData() {
 	IPProtocolMetadata[IPProtocolIPIP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4", LayerType: LayerTypeIPv4}
 	IPProtocolMetadata[IPProtocolEtherIP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEtherIP), Name: "EtherIP", LayerType: LayerTypeEtherIP}
 	IPProtocolMetadata[IPProtocolRUDP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeRUDP), Name: "RUDP", LayerType: LayerTypeRUDP}
+	IPProtocolMetadata[IPProtocolDCCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDCCP), Name: "DCCP", LayerType: LayerTypeDCCP}
 	IPProtocolMetadata[IPProtocolGRE] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeGRE), Name: "GRE", LayerType: LayerTypeGRE}
 	IPProtocolMetadata[IPProtocolIPv6HopByHop] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6HopByHop), Name: "IPv6HopByHop", LayerType: LayerTypeIPv6HopByHop}
 	IPProtocolMetadata[IPProtocolIPv6Routing] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6Routing), Name: "IPv6Routing", LayerType: LayerTypeIPv6Routing}
@@ -343,6 +478,7 @@ func initActualTypeData() {
 	IPProtocolMetadata[IPProtocolNoNextHeader] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "NoNextHeader", LayerType: gopacket.LayerTypePayload}
 	IPProtocolMetadata[IPProtocolIGMP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIGMP), Name: "IGMP", LayerType: LayerTypeIGMP}
 	IPProtocolMetadata[IPProtocolVRRP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeVRRP), Name: "VRRP", LayerType: LayerTypeVRRP}
+	IPProtocolMetadata[IPProtocolL2TP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeL2TPv3IP), Name: "L2TP", LayerType: LayerTypeL2TPv3IP}
 
 	SCTPChunkTypeMetadata[SCTPChunkTypeData] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPData), Name: "Data"}
 	SCTPChunkTypeMetadata[SCTPChunkTypeInit] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPInit), Name: "Init"}
@@ -357,16 +493,39 @@ func initActualTypeData() {
 	SCTPChunkTypeMetadata[SCTPChunkTypeCookieEcho] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPCookieEcho), Name: "CookieEcho"}
 	SCTPChunkTypeMetadata[SCTPChunkTypeCookieAck] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPEmptyLayer), Name: "CookieAck"}
 	SCTPChunkTypeMetadata[SCTPChunkTypeShutdownComplete] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPEmptyLayer), Name: "ShutdownComplete"}
+	SCTPChunkTypeMetadata[SCTPChunkTypeAuth] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPAuth), Name: "Auth"}
+	SCTPChunkTypeMetadata[SCTPChunkTypeIData] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPIData), Name: "IData"}
+	SCTPChunkTypeMetadata[SCTPChunkTypeASCONFAck] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPASCONF), Name: "ASCONFAck"}
+	SCTPChunkTypeMetadata[SCTPChunkTypeReconfig] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPReconfig), Name: "Reconfig"}
+	SCTPChunkTypeMetadata[SCTPChunkTypePad] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPPad), Name: "Pad"}
+	SCTPChunkTypeMetadata[SCTPChunkTypeForwardTSN] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPForwardTSN), Name: "ForwardTSN"}
+	SCTPChunkTypeMetadata[SCTPChunkTypeASCONF] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPASCONF), Name: "ASCONF"}
 
 	PPPTypeMetadata[PPPTypeIPv4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4"}
 	PPPTypeMetadata[PPPTypeIPv6] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6"}
 	PPPTypeMetadata[PPPTypeMPLSUnicast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSUnicast"}
 	PPPTypeMetadata[PPPTypeMPLSMulticast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSMulticast"}
+	PPPTypeMetadata[PPPTypeIPCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPCP), Name: "IPCP", LayerType: LayerTypeIPCP}
+	PPPTypeMetadata[PPPTypeIPv6CP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6CP), Name: "IPv6CP", LayerType: LayerTypeIPv6CP}
+	PPPTypeMetadata[PPPTypeLCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLCP), Name: "LCP", LayerType: LayerTypeLCP}
+	PPPTypeMetadata[PPPTypePAP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePAP), Name: "PAP", LayerType: LayerTypePAP}
+	PPPTypeMetadata[PPPTypeCHAP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCHAP), Name: "CHAP", LayerType: LayerTypeCHAP}
 
 	PPPoECodeMetadata[PPPoECodeSession] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPP), Name: "PPP"}
+	PPPoECodeMetadata[PPPoECodePADI] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "PADI"}
+	PPPoECodeMetadata[PPPoECodePADO] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "PADO"}
+	PPPoECodeMetadata[PPPoECodePADR] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "PADR"}
+	PPPoECodeMetadata[PPPoECodePADS] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "PADS"}
+	PPPoECodeMetadata[PPPoECodePADT] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "PADT"}
 
 	LinkTypeMetadata[LinkTypeEthernet] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEthernet), Name: "Ethernet"}
 	LinkTypeMetadata[LinkTypePPP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPP), Name: "PPP"}
+	LinkTypeMetadata[LinkTypePPP_HDLC] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPPHDLC), Name: "PPP_HDLC"}
+	LinkTypeMetadata[LinkTypeC_HDLC] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCiscoHDLC), Name: "C_HDLC"}
+	LinkTypeMetadata[LinkTypeSLIP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSLIP), Name: "SLIP"}
+	LinkTypeMetadata[LinkTypeFRelay] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeFrameRelay), Name: "FRelay"}
+	LinkTypeMetadata[LinkTypeATM_RFC1483] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeRFC2684), Name: "ATM_RFC1483"}
+	LinkTypeMetadata[LinkTypeSunATM] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSunATM), Name: "SunATM"}
 	LinkTypeMetadata[LinkTypeFDDI] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeFDDI), Name: "FDDI"}
 	LinkTypeMetadata[LinkTypeNull] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLoopback), Name: "Null"}
 	LinkTypeMetadata[LinkTypeIEEE802_11] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot11), Name: "Dot11"}
@@ -383,8 +542,16 @@ func initActualTypeData() {
 	LinkTypeMetadata[LinkTypePFLog] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePFLog), Name: "PFLog"}
 	LinkTypeMetadata[LinkTypeIEEE80211Radio] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeRadioTap), Name: "RadioTap"}
 	LinkTypeMetadata[LinkTypeLinuxUSB] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeUSB), Name: "USB"}
+	LinkTypeMetadata[LinkTypeLinuxUSB48] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeUSB48), Name: "USB"}
 	LinkTypeMetadata[LinkTypeLinuxSLL] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLinuxSLL), Name: "Linux SLL"}
+	LinkTypeMetadata[LinkTypeLinuxSLL2] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLinuxSLL2), Name: "Linux SLL2"}
 	LinkTypeMetadata[LinkTypePrismHeader] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePrismHeader), Name: "Prism"}
+	LinkTypeMetadata[LinkTypeBluetoothHCIH4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeBluetoothH4), Name: "Bluetooth HCI H4"}
+	LinkTypeMetadata[LinkTypeBluetoothHCIH4PHDR] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeBluetoothPHDR), Name: "Bluetooth HCI H4 PHDR"}
+	LinkTypeMetadata[LinkTypeIEEE802154] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIEEE802154), Name: "IEEE 802.15.4"}
+	LinkTypeMetadata[LinkTypeCANSocketCAN] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCAN), Name: "CAN"}
+	LinkTypeMetadata[LinkTypeIEEE802154NoFCS] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIEEE802154NoFCS), Name: "IEEE 802.15.4 without FCS"}
+	LinkTypeMetadata[LinkTypeUSBPcap] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeUSBPcap), Name: "USBPcap"}
 
 	FDDIFrameControlMetadata[FDDIFrameControlLLC] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLLC), Name: "LLC"}
 
@@ -3,7 +3,7 @@
 package layers
 
 // Created by gen2.go, don't edit manually
-// Generated at 2017-10-23 10:20:24.458771856 -0600 MDT m=+0.001159033
+// Generated at 2026-10-15 03:48:38.061620575 +0000 UTC m=+0.000136886
 
 import (
 	"fmt"
@@ -50,11 +50,30 @@ func (a *errorDecoderForLinkType) Error() string {
 	return fmt.Sprintf("Unable to decode LinkType %d", int(*a))
 }
 
-var errorDecodersForLinkType [256]errorDecoderForLinkType
-var LinkTypeMetadata [256]EnumMetadata
+var errorDecodersForLinkType [65536]errorDecoderForLinkType
+var LinkTypeMetadata [65536]EnumMetadata
+
+// LinkTypeNames returns the names of all LinkType values with an entry in
+// LinkTypeMetadata.
+func LinkTypeNames() map[LinkType]string {
+	names := make(map[LinkType]string)
+	for i := range LinkTypeMetadata {
+		if name := LinkTypeMetadata[i].Name; name != "" && name != "UnknownLinkType" {
+			names[LinkType(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseLinkType returns the LinkType with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseLinkType(s string) (LinkType, error) {
+	v, err := parseEnum(s, "LinkType", LinkTypeMetadata[:])
+	return LinkType(v), err
+}
 
 func initUnknownTypesForLinkType() {
-	for i := 0; i < 256; i++ {
+	for i := 0; i < 65536; i++ {
 		errorDecodersForLinkType[i] = errorDecoderForLinkType(i)
 		LinkTypeMetadata[i] = EnumMetadata{
 			DecodeWith: &errorDecodersForLinkType[i],
@@ -90,6 +109,25 @@ func (a *errorDecoderForEthernetType) Error() string {
 var errorDecodersForEthernetType [65536]errorDecoderForEthernetType
 var EthernetTypeMetadata [65536]EnumMetadata
 
+// EthernetTypeNames returns the names of all EthernetType values with an entry in
+// EthernetTypeMetadata.
+func EthernetTypeNames() map[EthernetType]string {
+	names := make(map[EthernetType]string)
+	for i := range EthernetTypeMetadata {
+		if name := EthernetTypeMetadata[i].Name; name != "" && name != "UnknownEthernetType" {
+			names[EthernetType(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseEthernetType returns the EthernetType with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseEthernetType(s string) (EthernetType, error) {
+	v, err := parseEnum(s, "EthernetType", EthernetTypeMetadata[:])
+	return EthernetType(v), err
+}
+
 func initUnknownTypesForEthernetType() {
 	for i := 0; i < 65536; i++ {
 		errorDecodersForEthernetType[i] = errorDecoderForEthernetType(i)
@@ -127,6 +165,25 @@ func (a *errorDecoderForPPPType) Error() string {
 var errorDecodersForPPPType [65536]errorDecoderForPPPType
 var PPPTypeMetadata [65536]EnumMetadata
 
+// PPPTypeNames returns the names of all PPPType values with an entry in
+// PPPTypeMetadata.
+func PPPTypeNames() map[PPPType]string {
+	names := make(map[PPPType]string)
+	for i := range PPPTypeMetadata {
+		if name := PPPTypeMetadata[i].Name; name != "" && name != "UnknownPPPType" {
+			names[PPPType(i)] = name
+		}
+	}
+	return names
+}
+
+// ParsePPPType returns the PPPType with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParsePPPType(s string) (PPPType, error) {
+	v, err := parseEnum(s, "PPPType", PPPTypeMetadata[:])
+	return PPPType(v), err
+}
+
 func initUnknownTypesForPPPType() {
 	for i := 0; i < 65536; i++ {
 		errorDecodersForPPPType[i] = errorDecoderForPPPType(i)
@@ -164,6 +221,25 @@ func (a *errorDecoderForIPProtocol) Error() string {
 var errorDecodersForIPProtocol [256]errorDecoderForIPProtocol
 var IPProtocolMetadata [256]EnumMetadata
 
+// IPProtocolNames returns the names of all IPProtocol values with an entry in
+// IPProtocolMetadata.
+func IPProtocolNames() map[IPProtocol]string {
+	names := make(map[IPProtocol]string)
+	for i := range IPProtocolMetadata {
+		if name := IPProtocolMetadata[i].Name; name != "" && name != "UnknownIPProtocol" {
+			names[IPProtocol(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseIPProtocol returns the IPProtocol with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseIPProtocol(s string) (IPProtocol, error) {
+	v, err := parseEnum(s, "IPProtocol", IPProtocolMetadata[:])
+	return IPProtocol(v), err
+}
+
 func initUnknownTypesForIPProtocol() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForIPProtocol[i] = errorDecoderForIPProtocol(i)
@@ -201,6 +277,25 @@ func (a *errorDecoderForSCTPChunkType) Error() string {
 var errorDecodersForSCTPChunkType [256]errorDecoderForSCTPChunkType
 var SCTPChunkTypeMetadata [256]EnumMetadata
 
+// SCTPChunkTypeNames returns the names of all SCTPChunkType values with an entry in
+// SCTPChunkTypeMetadata.
+func SCTPChunkTypeNames() map[SCTPChunkType]string {
+	names := make(map[SCTPChunkType]string)
+	for i := range SCTPChunkTypeMetadata {
+		if name := SCTPChunkTypeMetadata[i].Name; name != "" && name != "UnknownSCTPChunkType" {
+			names[SCTPChunkType(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseSCTPChunkType returns the SCTPChunkType with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseSCTPChunkType(s string) (SCTPChunkType, error) {
+	v, err := parseEnum(s, "SCTPChunkType", SCTPChunkTypeMetadata[:])
+	return SCTPChunkType(v), err
+}
+
 func initUnknownTypesForSCTPChunkType() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForSCTPChunkType[i] = errorDecoderForSCTPChunkType(i)
@@ -238,6 +333,25 @@ func (a *errorDecoderForPPPoECode) Error() string {
 var errorDecodersForPPPoECode [256]errorDecoderForPPPoECode
 var PPPoECodeMetadata [256]EnumMetadata
 
+// PPPoECodeNames returns the names of all PPPoECode values with an entry in
+// PPPoECodeMetadata.
+func PPPoECodeNames() map[PPPoECode]string {
+	names := make(map[PPPoECode]string)
+	for i := range PPPoECodeMetadata {
+		if name := PPPoECodeMetadata[i].Name; name != "" && name != "UnknownPPPoECode" {
+			names[PPPoECode(i)] = name
+		}
+	}
+	return names
+}
+
+// ParsePPPoECode returns the PPPoECode with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParsePPPoECode(s string) (PPPoECode, error) {
+	v, err := parseEnum(s, "PPPoECode", PPPoECodeMetadata[:])
+	return PPPoECode(v), err
+}
+
 func initUnknownTypesForPPPoECode() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForPPPoECode[i] = errorDecoderForPPPoECode(i)
@@ -275,6 +389,25 @@ func (a *errorDecoderForFDDIFrameControl) Error() string {
 var errorDecodersForFDDIFrameControl [256]errorDecoderForFDDIFrameControl
 var FDDIFrameControlMetadata [256]EnumMetadata
 
+// FDDIFrameControlNames returns the names of all FDDIFrameControl values with an entry in
+// FDDIFrameControlMetadata.
+func FDDIFrameControlNames() map[FDDIFrameControl]string {
+	names := make(map[FDDIFrameControl]string)
+	for i := range FDDIFrameControlMetadata {
+		if name := FDDIFrameControlMetadata[i].Name; name != "" && name != "UnknownFDDIFrameControl" {
+			names[FDDIFrameControl(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseFDDIFrameControl returns the FDDIFrameControl with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseFDDIFrameControl(s string) (FDDIFrameControl, error) {
+	v, err := parseEnum(s, "FDDIFrameControl", FDDIFrameControlMetadata[:])
+	return FDDIFrameControl(v), err
+}
+
 func initUnknownTypesForFDDIFrameControl() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForFDDIFrameControl[i] = errorDecoderForFDDIFrameControl(i)
@@ -312,6 +445,25 @@ func (a *errorDecoderForEAPOLType) Error() string {
 var errorDecodersForEAPOLType [256]errorDecoderForEAPOLType
 var EAPOLTypeMetadata [256]EnumMetadata
 
+// EAPOLTypeNames returns the names of all EAPOLType values with an entry in
+// EAPOLTypeMetadata.
+func EAPOLTypeNames() map[EAPOLType]string {
+	names := make(map[EAPOLType]string)
+	for i := range EAPOLTypeMetadata {
+		if name := EAPOLTypeMetadata[i].Name; name != "" && name != "UnknownEAPOLType" {
+			names[EAPOLType(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseEAPOLType returns the EAPOLType with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseEAPOLType(s string) (EAPOLType, error) {
+	v, err := parseEnum(s, "EAPOLType", EAPOLTypeMetadata[:])
+	return EAPOLType(v), err
+}
+
 func initUnknownTypesForEAPOLType() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForEAPOLType[i] = errorDecoderForEAPOLType(i)
@@ -349,6 +501,25 @@ func (a *errorDecoderForProtocolFamily) Error() string {
 var errorDecodersForProtocolFamily [256]errorDecoderForProtocolFamily
 var ProtocolFamilyMetadata [256]EnumMetadata
 
+// ProtocolFamilyNames returns the names of all ProtocolFamily values with an entry in
+// ProtocolFamilyMetadata.
+func ProtocolFamilyNames() map[ProtocolFamily]string {
+	names := make(map[ProtocolFamily]string)
+	for i := range ProtocolFamilyMetadata {
+		if name := ProtocolFamilyMetadata[i].Name; name != "" && name != "UnknownProtocolFamily" {
+			names[ProtocolFamily(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseProtocolFamily returns the ProtocolFamily with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseProtocolFamily(s string) (ProtocolFamily, error) {
+	v, err := parseEnum(s, "ProtocolFamily", ProtocolFamilyMetadata[:])
+	return ProtocolFamily(v), err
+}
+
 func initUnknownTypesForProtocolFamily() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForProtocolFamily[i] = errorDecoderForProtocolFamily(i)
@@ -386,6 +557,25 @@ func (a *errorDecoderForDot11Type) Error() string {
 var errorDecodersForDot11Type [256]errorDecoderForDot11Type
 var Dot11TypeMetadata [256]EnumMetadata
 
+// Dot11TypeNames returns the names of all Dot11Type values with an entry in
+// Dot11TypeMetadata.
+func Dot11TypeNames() map[Dot11Type]string {
+	names := make(map[Dot11Type]string)
+	for i := range Dot11TypeMetadata {
+		if name := Dot11TypeMetadata[i].Name; name != "" && name != "UnknownDot11Type" {
+			names[Dot11Type(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseDot11Type returns the Dot11Type with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseDot11Type(s string) (Dot11Type, error) {
+	v, err := parseEnum(s, "Dot11Type", Dot11TypeMetadata[:])
+	return Dot11Type(v), err
+}
+
 func initUnknownTypesForDot11Type() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForDot11Type[i] = errorDecoderForDot11Type(i)
@@ -423,6 +613,25 @@ func (a *errorDecoderForUSBTransportType) Error() string {
 var errorDecodersForUSBTransportType [256]errorDecoderForUSBTransportType
 var USBTransportTypeMetadata [256]EnumMetadata
 
+// USBTransportTypeNames returns the names of all USBTransportType values with an entry in
+// USBTransportTypeMetadata.
+func USBTransportTypeNames() map[USBTransportType]string {
+	names := make(map[USBTransportType]string)
+	for i := range USBTransportTypeMetadata {
+		if name := USBTransportTypeMetadata[i].Name; name != "" && name != "UnknownUSBTransportType" {
+			names[USBTransportType(i)] = name
+		}
+	}
+	return names
+}
+
+// ParseUSBTransportType returns the USBTransportType with the given name, as returned by
+// String, or number. See parseEnum for details.
+func ParseUSBTransportType(s string) (USBTransportType, error) {
+	v, err := parseEnum(s, "USBTransportType", USBTransportTypeMetadata[:])
+	return USBTransportType(v), err
+}
+
 func initUnknownTypesForUSBTransportType() {
 	for i := 0; i < 256; i++ {
 		errorDecodersForUSBTransportType[i] = errorDecoderForUSBTransportType(i)
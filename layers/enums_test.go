@@ -0,0 +1,66 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import "testing"
+
+func TestParseEnums(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want LinkType
+	}{
+		{"Ethernet", LinkTypeEthernet},
+		{"ethernet", LinkTypeEthernet},
+		{"LINKTYPE_ETHERNET", LinkTypeEthernet},
+		{"linktype_ieee802_11_radiotap", LinkTypeIEEE80211Radio},
+		{"Linux SLL", LinkTypeLinuxSLL},
+		{"113", LinkTypeLinuxSLL},
+		{"0x65", LinkTypeRaw},
+	} {
+		got, err := ParseLinkType(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("ParseLinkType(%q) = %v, %v, want %v", test.s, got, err, test.want)
+		}
+	}
+	for _, s := range []string{"", "UnknownLinkType", "unknownlinktype", "Bogus", "DLT_EN10MB", "65536"} {
+		if got, err := ParseLinkType(s); err == nil {
+			t.Errorf("ParseLinkType(%q) = %v, want an error", s, got)
+		}
+	}
+
+	if got, err := ParseEthernetType("IPv6"); err != nil || got != EthernetTypeIPv6 {
+		t.Errorf("ParseEthernetType(IPv6) = %v, %v", got, err)
+	}
+	// Dot1Q is also the name of EthernetTypeQinQ.
+	if got, err := ParseEthernetType("Dot1Q"); err != nil || got != EthernetTypeDot1Q {
+		t.Errorf("ParseEthernetType(Dot1Q) = %#x, %v", uint16(got), err)
+	}
+	if got, err := ParseEthernetType("0x88cc"); err != nil || got != EthernetTypeLinkLayerDiscovery {
+		t.Errorf("ParseEthernetType(0x88cc) = %v, %v", got, err)
+	}
+	for s, want := range map[string]IPProtocol{"TCP": IPProtocolTCP, "IPv4": IPProtocolIPv4, "icmp": IPProtocolICMPv4, "ipv6-icmp": IPProtocolICMPv6} {
+		if got, err := ParseIPProtocol(s); err != nil || got != want {
+			t.Errorf("ParseIPProtocol(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+}
+
+func TestEnumNamesRoundTrip(t *testing.T) {
+	names := IPProtocolNames()
+	if names[IPProtocolUDP] != "UDP" {
+		t.Errorf("got name %q for UDP", names[IPProtocolUDP])
+	}
+	if _, ok := names[IPProtocol(255)]; ok {
+		t.Error("unknown protocol listed")
+	}
+	for v, name := range EthernetTypeNames() {
+		got, err := ParseEthernetType(name)
+		if err != nil || EthernetTypeMetadata[got].Name != name {
+			t.Errorf("%s (%#x) parsed as %#x, %v", name, uint16(v), uint16(got), err)
+		}
+	}
+}
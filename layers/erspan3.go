@@ -0,0 +1,198 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// ERSPANIIIVersion is the value of the version field of ERSPAN Type III.
+const ERSPANIIIVersion = 0x2
+
+// ERSPANIIIFrameType is the type of the frame mirrored by ERSPAN Type III.
+type ERSPANIIIFrameType uint8
+
+const (
+	ERSPANIIIFrameTypeEthernet ERSPANIIIFrameType = 0
+	ERSPANIIIFrameTypeIP       ERSPANIIIFrameType = 2
+)
+
+// ERSPANIIIGranularity is the unit of the ERSPAN Type III timestamp.
+type ERSPANIIIGranularity uint8
+
+const (
+	ERSPANIIIGranularity100Microseconds ERSPANIIIGranularity = 0
+	ERSPANIIIGranularity100Nanoseconds  ERSPANIIIGranularity = 1
+	ERSPANIIIGranularityIEEE1588        ERSPANIIIGranularity = 2
+	ERSPANIIIGranularityUserDefined     ERSPANIIIGranularity = 3
+)
+
+func (g ERSPANIIIGranularity) String() string {
+	switch g {
+	case ERSPANIIIGranularity100Microseconds:
+		return "100us"
+	case ERSPANIIIGranularity100Nanoseconds:
+		return "100ns"
+	case ERSPANIIIGranularityIEEE1588:
+		return "IEEE1588"
+	case ERSPANIIIGranularityUserDefined:
+		return "UserDefined"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(g))
+}
+
+// ERSPANIII contains the fields of an ERSPAN Type III header, including
+// the optional platform specific subheader.
+// https://tools.ietf.org/html/draft-foschiano-erspan-03
+type ERSPANIII struct {
+	BaseLayer
+	Version        uint8
+	VLANIdentifier uint16
+	CoS            uint8
+	// BSO holds the bad, short or oversized frame bits.
+	BSO         uint8
+	IsTruncated bool
+	SessionID   uint16
+	// Timestamp is in the unit given by Granularity.
+	Timestamp uint32
+	// SGT is the Security Group Tag of the mirrored frame.
+	SGT uint16
+	// P is set if the mirrored frame is an Ethernet frame including its
+	// FCS.
+	P           bool
+	FrameType   ERSPANIIIFrameType
+	HardwareID  uint8
+	Egress      bool
+	Granularity ERSPANIIIGranularity
+	// HasPlatformSubheader is set if the platform specific subheader
+	// follows, made of PlatformID and PlatformInfo.
+	HasPlatformSubheader bool
+	PlatformID           uint8
+	// PlatformInfo holds the 58 bits following PlatformID, whose meaning
+	// depends on it.
+	PlatformInfo uint64
+}
+
+// LayerType returns LayerTypeERSPANIII.
+func (erspan3 *ERSPANIII) LayerType() gopacket.LayerType { return LayerTypeERSPANIII }
+
+// TimestampDuration returns the timestamp as a duration, for the
+// granularities of fixed length.
+func (erspan3 *ERSPANIII) TimestampDuration() (time.Duration, bool) {
+	switch erspan3.Granularity {
+	case ERSPANIIIGranularity100Microseconds:
+		return time.Duration(erspan3.Timestamp) * 100 * time.Microsecond, true
+	case ERSPANIIIGranularity100Nanoseconds:
+		return time.Duration(erspan3.Timestamp) * 100 * time.Nanosecond, true
+	}
+	return 0, false
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (erspan3 *ERSPANIII) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return errors.New("ERSPAN Type III header too short")
+	}
+	erspan3.Version = data[0] >> 4
+	erspan3.VLANIdentifier = binary.BigEndian.Uint16(data[:2]) & 0x0FFF
+	erspan3.CoS = data[2] >> 5
+	erspan3.BSO = data[2] & 0x18 >> 3
+	erspan3.IsTruncated = data[2]&0x4 != 0
+	erspan3.SessionID = binary.BigEndian.Uint16(data[2:4]) & 0x03FF
+	erspan3.Timestamp = binary.BigEndian.Uint32(data[4:8])
+	erspan3.SGT = binary.BigEndian.Uint16(data[8:10])
+	flags := binary.BigEndian.Uint16(data[10:12])
+	erspan3.P = flags&0x8000 != 0
+	erspan3.FrameType = ERSPANIIIFrameType(flags >> 10 & 0x1F)
+	erspan3.HardwareID = uint8(flags >> 4 & 0x3F)
+	erspan3.Egress = flags&0x8 != 0
+	erspan3.Granularity = ERSPANIIIGranularity(flags >> 1 & 0x3)
+	erspan3.HasPlatformSubheader = flags&0x1 != 0
+	erspan3.PlatformID, erspan3.PlatformInfo = 0, 0
+	length := 12
+	if erspan3.HasPlatformSubheader {
+		length += 8
+		if len(data) < length {
+			df.SetTruncated()
+			return errors.New("ERSPAN Type III platform subheader too short")
+		}
+		sub := binary.BigEndian.Uint64(data[12:20])
+		erspan3.PlatformID = uint8(sub >> 58)
+		erspan3.PlatformInfo = sub & (1<<58 - 1)
+	}
+	erspan3.BaseLayer = BaseLayer{Contents: data[:length], Payload: data[length:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (erspan3 *ERSPANIII) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 12
+	if erspan3.HasPlatformSubheader {
+		length += 8
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes, uint16(erspan3.Version&0xF)<<12|erspan3.VLANIdentifier&0x0FFF)
+	twoByteInt := uint16(erspan3.CoS&0x7)<<13 | uint16(erspan3.BSO&0x3)<<11 | erspan3.SessionID&0x03FF
+	if erspan3.IsTruncated {
+		twoByteInt |= 0x400
+	}
+	binary.BigEndian.PutUint16(bytes[2:], twoByteInt)
+	binary.BigEndian.PutUint32(bytes[4:], erspan3.Timestamp)
+	binary.BigEndian.PutUint16(bytes[8:], erspan3.SGT)
+	flags := uint16(erspan3.FrameType&0x1F)<<10 | uint16(erspan3.HardwareID&0x3F)<<4 | uint16(erspan3.Granularity&0x3)<<1
+	if erspan3.P {
+		flags |= 0x8000
+	}
+	if erspan3.Egress {
+		flags |= 0x8
+	}
+	if erspan3.HasPlatformSubheader {
+		flags |= 0x1
+		binary.BigEndian.PutUint64(bytes[12:], uint64(erspan3.PlatformID&0x3F)<<58|erspan3.PlatformInfo&(1<<58-1))
+	}
+	binary.BigEndian.PutUint16(bytes[10:], flags)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (erspan3 *ERSPANIII) CanDecode() gopacket.LayerClass {
+	return LayerTypeERSPANIII
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (erspan3 *ERSPANIII) NextLayerType() gopacket.LayerType {
+	switch erspan3.FrameType {
+	case ERSPANIIIFrameTypeEthernet:
+		return LayerTypeEthernet
+	case ERSPANIIIFrameTypeIP:
+		if len(erspan3.Payload) > 0 {
+			switch erspan3.Payload[0] >> 4 {
+			case 4:
+				return LayerTypeIPv4
+			case 6:
+				return LayerTypeIPv6
+			}
+		}
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeERSPANIII(data []byte, p gopacket.PacketBuilder) error {
+	erspan3 := &ERSPANIII{}
+	return decodingLayerDecoder(erspan3, data, p)
+}
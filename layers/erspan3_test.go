@@ -0,0 +1,88 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+func TestERSPANIII(t *testing.T) {
+	erspan := &ERSPANIII{
+		Version:              ERSPANIIIVersion,
+		VLANIdentifier:       0x123,
+		CoS:                  5,
+		BSO:                  1,
+		IsTruncated:          true,
+		SessionID:            0x2aa,
+		Timestamp:            0x01020304,
+		SGT:                  0xbeef,
+		FrameType:            ERSPANIIIFrameTypeEthernet,
+		HardwareID:           0x2a,
+		Egress:               true,
+		Granularity:          ERSPANIIIGranularity100Nanoseconds,
+		HasPlatformSubheader: true,
+		PlatformID:           3,
+		PlatformInfo:         0x0123456789abcde,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := erspan.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x21, 0x23, 0xae, 0xaa, // version, VLAN, CoS, BSO, T, session
+		0x01, 0x02, 0x03, 0x04, // timestamp
+		0xbe, 0xef, 0x02, 0xab, // SGT, P, FT, HW ID, D, Gra, O
+		0x0c, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, // platform subheader
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("serialized\n%x\nwant\n%x", buf.Bytes(), want)
+	}
+
+	// Mirror a frame over GRE and decode it again.
+	inner := &Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 2}, EthernetType: EthernetTypeIPv4}
+	innerIP := &IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{192, 168, 0, 1}, DstIP: net.IP{192, 168, 0, 2}}
+	outer := &Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: EthernetTypeIPv4}
+	outerIP := &IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: IPProtocolGRE, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	gre := &GRE{SeqPresent: true, Seq: 7, Protocol: EthernetTypeERSPANIII}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, outer, outerIP, gre, erspan, inner, innerIP, &UDP{SrcPort: 1, DstPort: 2}); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeGRE, LayerTypeERSPANIII, LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP}, t)
+	got := p.Layer(LayerTypeERSPANIII).(*ERSPANIII)
+	got.BaseLayer = BaseLayer{}
+	if !reflect.DeepEqual(got, erspan) {
+		t.Errorf("decoded\n%#v\nwant\n%#v", got, erspan)
+	}
+	if d, ok := got.TimestampDuration(); !ok || d != 0x01020304*100*time.Nanosecond {
+		t.Errorf("got timestamp %v, %v", d, ok)
+	}
+
+	// IP frames without platform subheader.
+	erspan = &ERSPANIII{Version: ERSPANIIIVersion, FrameType: ERSPANIIIFrameTypeIP}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, erspan, innerIP, &UDP{SrcPort: 1, DstPort: 2}); err != nil {
+		t.Fatal(err)
+	}
+	p = gopacket.NewPacket(buf.Bytes(), LayerTypeERSPANIII, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeERSPANIII, LayerTypeIPv4, LayerTypeUDP}, t)
+
+	var e ERSPANIII
+	if err := e.DecodeFromBytes(want[:16], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded truncated platform subheader")
+	}
+}
@@ -78,13 +78,18 @@ func (eth *Ethernet) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Seria
 	}
 	copy(bytes, eth.DstMAC)
 	copy(bytes[6:], eth.SrcMAC)
-	if eth.Length != 0 || eth.EthernetType == EthernetTypeLLC {
-		if opts.FixLengths {
+	// 802.3 frames carrying LLC use the type field for the payload length.
+	// Use that form whenever the layer serialized inside this one is LLC,
+	// since an LLC header behind an actual EtherType is not a valid frame.
+	inner := b.Layers()
+	llc := len(inner) > 0 && inner[len(inner)-1] == LayerTypeLLC
+	if eth.Length != 0 || eth.EthernetType == EthernetTypeLLC || llc {
+		if opts.FixLengths || eth.Length == 0 {
 			eth.Length = uint16(len(payload))
 		}
-		if eth.EthernetType != EthernetTypeLLC {
+		if eth.EthernetType != EthernetTypeLLC && !llc {
 			return fmt.Errorf("ethernet type %v not compatible with length value %v", eth.EthernetType, eth.Length)
-		} else if eth.Length > 0x0600 {
+		} else if eth.Length >= 0x0600 {
 			return fmt.Errorf("invalid ethernet length %v", eth.Length)
 		}
 		binary.BigEndian.PutUint16(bytes[12:], eth.Length)
@@ -0,0 +1,175 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestFrameRelay(t *testing.T) {
+	eth := &Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 2}, EthernetType: EthernetTypeIPv4}
+	for _, test := range []struct {
+		name   string
+		fr     *FrameRelay
+		ls     []gopacket.SerializableLayer
+		header []byte
+		layers []gopacket.LayerType
+	}{
+		{
+			name:   "routed",
+			fr:     &FrameRelay{DLCI: 100, FECN: true, DE: true, Control: 0x03, NLPID: NLPIDIPv4},
+			header: []byte{0x18, 0x4b, 0x03, 0xcc},
+			layers: []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload},
+		},
+		{
+			name:   "cisco",
+			fr:     &FrameRelay{DLCI: 16, CR: true, BECN: true, Cisco: true, EthernetType: EthernetTypeIPv4},
+			header: []byte{0x06, 0x05, 0x08, 0x00},
+			layers: []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload},
+		},
+		{
+			name:   "bridged",
+			fr:     &FrameRelay{DLCI: 1007, Control: 0x03, NLPID: NLPIDSNAP, OUI: IEEEOUI8021, PID: BridgedPIDEthernet},
+			ls:     []gopacket.SerializableLayer{eth},
+			header: []byte{0xf8, 0xf1, 0x03, 0x00, 0x80, 0x00, 0x80, 0xc2, 0x00, 0x07},
+			layers: []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload},
+		},
+		{
+			name:   "ppp",
+			fr:     &FrameRelay{DLCI: 100, Control: 0x03, NLPID: NLPIDPPP},
+			ls:     []gopacket.SerializableLayer{&PPP{PPPType: PPPTypeIPv4}},
+			header: []byte{0x18, 0x41, 0x03, 0xcf, 0x00, 0x21},
+			layers: []gopacket.LayerType{LayerTypeFrameRelay, LayerTypePPP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload},
+		},
+		{
+			name:   "3 byte address",
+			fr:     &FrameRelay{DLCI: 0xabcd, AddressLength: 3, Control: 0x03, NLPID: NLPIDIPv4},
+			header: []byte{0xa8, 0xf0, 0x35, 0x03, 0xcc},
+			layers: []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload},
+		},
+		{
+			name:   "4 byte address",
+			fr:     &FrameRelay{DLCI: 0x5abcde, AddressLength: 4, Control: 0x03, NLPID: NLPIDIPv4},
+			header: []byte{0xb4, 0x50, 0xe6, 0x79, 0x03, 0xcc},
+			layers: []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload},
+		},
+	} {
+		data := serializeOverLink(t, append([]gopacket.SerializableLayer{test.fr}, test.ls...)...)
+		if !bytes.Equal(data[:len(test.header)], test.header) {
+			t.Errorf("%s: header %x, want %x", test.name, data[:len(test.header)], test.header)
+			continue
+		}
+		p := gopacket.NewPacket(data, LinkTypeFRelay, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, test.layers, t)
+		got := p.Layer(LayerTypeFrameRelay).(*FrameRelay)
+		want := *test.fr
+		if want.AddressLength == 0 {
+			want.AddressLength = 2
+		}
+		want.BaseLayer = got.BaseLayer
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, want)
+		}
+	}
+
+	if err := (&FrameRelay{DLCI: 1024}).SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("no error serializing DLCI 1024 in a 2 byte address")
+	}
+	for _, data := range [][]byte{{0x18}, {0x19, 0x41, 0x03, 0xcc}, {0x18, 0x40, 0x01}, {0x18, 0x41, 0x03}} {
+		if p := gopacket.NewPacket(data, LinkTypeFRelay, gopacket.Default); p.ErrorLayer() == nil {
+			t.Errorf("no error decoding %x", data)
+		}
+	}
+}
+
+func TestFrameRelayLMI(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+		fr   FrameRelay
+		lmi  FrameRelayLMI
+	}{
+		{
+			name: "ansi",
+			data: []byte{0x00, 0x01, 0x03, 0x08, 0x00, 0x75, 0x95, 0x01, 0x01, 0x01, 0x03, 0x02, 0x05, 0x04},
+			fr:   FrameRelay{Control: 0x03, NLPID: NLPIDQ933},
+			lmi: FrameRelayLMI{
+				Variant:         FrameRelayLMIVariantANSI,
+				MessageType:     FrameRelayLMIMessageTypeStatusEnquiry,
+				ReportType:      FrameRelayLMIReportTypeLinkIntegrity,
+				SendSequence:    5,
+				ReceiveSequence: 4,
+			},
+		},
+		{
+			name: "q933",
+			data: []byte{
+				0x00, 0x01, 0x03, 0x08, 0x00, 0x7d, 0x51, 0x01, 0x00, 0x53, 0x02, 0x06, 0x05,
+				0x57, 0x03, 0x06, 0xa0, 0x82,
+			},
+			fr: FrameRelay{Control: 0x03, NLPID: NLPIDQ933},
+			lmi: FrameRelayLMI{
+				Variant:         FrameRelayLMIVariantQ933,
+				MessageType:     FrameRelayLMIMessageTypeStatus,
+				ReportType:      FrameRelayLMIReportTypeFullStatus,
+				SendSequence:    6,
+				ReceiveSequence: 5,
+				PVCs:            []FrameRelayLMIPVC{{DLCI: 100, Active: true}},
+			},
+		},
+		{
+			name: "cisco",
+			data: []byte{
+				0xfc, 0xf1, 0x03, 0x09, 0x00, 0x7d, 0x01, 0x01, 0x00, 0x03, 0x02, 0x07, 0x06,
+				0x07, 0x06, 0x01, 0x80, 0x8a, 0x00, 0xfa, 0x00,
+			},
+			fr: FrameRelay{DLCI: 1023, Control: 0x03, NLPID: NLPIDCiscoLMI},
+			lmi: FrameRelayLMI{
+				Variant:         FrameRelayLMIVariantCisco,
+				MessageType:     FrameRelayLMIMessageTypeStatus,
+				ReportType:      FrameRelayLMIReportTypeFullStatus,
+				SendSequence:    7,
+				ReceiveSequence: 6,
+				PVCs:            []FrameRelayLMIPVC{{DLCI: 16, New: true, Active: true, Bandwidth: 64000}},
+			},
+		},
+	} {
+		p := gopacket.NewPacket(test.data, LinkTypeFRelay, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeFrameRelayLMI}, t)
+		got := p.Layer(LayerTypeFrameRelayLMI).(*FrameRelayLMI)
+		want := test.lmi
+		want.BaseLayer = got.BaseLayer
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, want)
+		}
+
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, &test.fr, &test.lmi); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(buf.Bytes(), test.data) {
+			t.Errorf("%s: serialized %x, want %x", test.name, buf.Bytes(), test.data)
+		}
+
+		p = gopacket.NewPacket(test.data[:len(test.data)-1], LinkTypeFRelay, gopacket.Default)
+		if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+			t.Errorf("%s: no error decoding truncated packet", test.name)
+		}
+	}
+}
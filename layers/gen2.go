@@ -59,6 +59,25 @@ func (a *errorDecoderFor{{.Name}}) Error() string {
 var errorDecodersFor{{.Name}} [{{.Num}}]errorDecoderFor{{.Name}}
 var {{.Name}}Metadata [{{.Num}}]EnumMetadata
 
+// {{.Name}}Names returns the names of all {{.Name}} values with an entry in
+// {{.Name}}Metadata.
+func {{.Name}}Names() map[{{.Name}}]string {
+	names := make(map[{{.Name}}]string)
+	for i := range {{.Name}}Metadata {
+		if name := {{.Name}}Metadata[i].Name; name != "" && name != "Unknown{{.Name}}" {
+			names[{{.Name}}(i)] = name
+		}
+	}
+	return names
+}
+
+// Parse{{.Name}} returns the {{.Name}} with the given name, as returned by
+// String, or number. See parseEnum for details.
+func Parse{{.Name}}(s string) ({{.Name}}, error) {
+	v, err := parseEnum(s, "{{.Name}}", {{.Name}}Metadata[:])
+	return {{.Name}}(v), err
+}
+
 func initUnknownTypesFor{{.Name}}() {
   for i := 0; i < {{.Num}}; i++ {
     errorDecodersFor{{.Name}}[i] = errorDecoderFor{{.Name}}(i)
@@ -77,7 +96,7 @@ func main() {
 		Name string
 		Num  int
 	}{
-		{"LinkType", 256},
+		{"LinkType", 65536},
 		{"EthernetType", 65536},
 		{"PPPType", 65536},
 		{"IPProtocol", 256},
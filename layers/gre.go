@@ -8,11 +8,15 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 
 	"github.com/google/gopacket"
 )
 
-// GRE is a Generic Routing Encapsulation header.
+// GRE is a Generic Routing Encapsulation header (RFC 1701, RFC 2784 and RFC
+// 2890), or the enhanced GRE header of PPTP (RFC 2637) if Version is 1. The
+// optional fields are present if their flags are set.
 type GRE struct {
 	BaseLayer
 	ChecksumPresent, RoutingPresent, KeyPresent, SeqPresent, StrictSourceRoute, AckPresent bool
@@ -37,6 +41,10 @@ func (g *GRE) LayerType() gopacket.LayerType { return LayerTypeGRE }
 
 // DecodeFromBytes decodes the given bytes into this layer.
 func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("GRE header too short")
+	}
 	g.ChecksumPresent = data[0]&0x80 != 0
 	g.RoutingPresent = data[0]&0x40 != 0
 	g.KeyPresent = data[0]&0x20 != 0
@@ -47,6 +55,12 @@ func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	g.Flags = data[1] >> 3
 	g.Version = data[1] & 0x7
 	g.Protocol = EthernetType(binary.BigEndian.Uint16(data[2:4]))
+	g.Checksum, g.Offset, g.Key, g.Seq, g.Ack = 0, 0, 0, 0, 0
+	g.GRERouting = nil
+	if len(data) < g.fixedSize() {
+		df.SetTruncated()
+		return errors.New("GRE header too short")
+	}
 	offset := 4
 	if g.ChecksumPresent || g.RoutingPresent {
 		g.Checksum = binary.BigEndian.Uint16(data[offset : offset+2])
@@ -64,11 +78,19 @@ func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	if g.RoutingPresent {
 		tail := &g.GRERouting
 		for {
+			if len(data) < offset+4 {
+				df.SetTruncated()
+				return errors.New("GRE routing field too short")
+			}
 			sre := &GRERouting{
 				AddressFamily: binary.BigEndian.Uint16(data[offset : offset+2]),
 				SREOffset:     data[offset+2],
 				SRELength:     data[offset+3],
 			}
+			if len(data) < offset+4+int(sre.SRELength) {
+				df.SetTruncated()
+				return errors.New("GRE routing field too short")
+			}
 			sre.RoutingInformation = data[offset+4 : offset+4+int(sre.SRELength)]
 			offset += 4 + int(sre.SRELength)
 			if sre.AddressFamily == 0 && sre.SRELength == 0 {
@@ -79,6 +101,10 @@ func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 		}
 	}
 	if g.AckPresent {
+		if len(data) < offset+4 {
+			df.SetTruncated()
+			return errors.New("GRE header too short")
+		}
 		g.Ack = binary.BigEndian.Uint32(data[offset : offset+4])
 		offset += 4
 	}
@@ -86,9 +112,8 @@ func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	return nil
 }
 
-// SerializeTo writes the serialized form of this layer into the SerializationBuffer,
-// implementing gopacket.SerializableLayer. See the docs for gopacket.SerializableLayer for more info.
-func (g *GRE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+// fixedSize returns the size of the header without its routing field.
+func (g *GRE) fixedSize() int {
 	size := 4
 	if g.ChecksumPresent || g.RoutingPresent {
 		size += 4
@@ -99,16 +124,42 @@ func (g *GRE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	if g.SeqPresent {
 		size += 4
 	}
+	if g.AckPresent {
+		size += 4
+	}
+	return size
+}
+
+// SerializeTo writes the serialized form of this layer into the SerializationBuffer,
+// implementing gopacket.SerializableLayer. See the docs for gopacket.SerializableLayer for more info.
+//
+// The checksum is computed over the header and payload with
+// ComputeChecksums. With FixLengths, the SRELength of each routing entry is
+// set to the length of its RoutingInformation, and, for the enhanced GRE
+// of PPTP, the payload length held in the high 16 bits of Key is set.
+func (g *GRE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	size := g.fixedSize()
 	if g.RoutingPresent {
-		r := g.GRERouting
-		for r != nil {
+		for r := g.GRERouting; r != nil; r = r.Next {
+			if opts.FixLengths {
+				if len(r.RoutingInformation) > 0xff {
+					return fmt.Errorf("GRE routing information of %d bytes too long", len(r.RoutingInformation))
+				}
+				r.SRELength = uint8(len(r.RoutingInformation))
+			}
+			if r.AddressFamily == 0 && r.SRELength == 0 {
+				return errors.New("GRE routing entry would terminate the routing field")
+			}
 			size += 4 + int(r.SRELength)
-			r = r.Next
 		}
 		size += 4
 	}
-	if g.AckPresent {
-		size += 4
+	if opts.FixLengths && g.Version == 1 && g.KeyPresent {
+		payloadLength := len(b.Bytes())
+		if payloadLength > 0xffff {
+			return fmt.Errorf("GRE payload of %d bytes too long", payloadLength)
+		}
+		g.Key = uint32(payloadLength)<<16 | g.Key&0xffff
 	}
 	buf, err := b.PrependBytes(size)
 	if err != nil {
@@ -135,9 +186,9 @@ func (g *GRE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	if g.AckPresent {
 		buf[1] |= 0x80
 	}
-	buf[0] |= g.RecursionControl
-	buf[1] |= g.Flags << 3
-	buf[1] |= g.Version
+	buf[0] |= g.RecursionControl & 0x7
+	buf[1] |= (g.Flags & 0xf) << 3
+	buf[1] |= g.Version & 0x7
 	binary.BigEndian.PutUint16(buf[2:4], uint16(g.Protocol))
 	offset := 4
 	if g.ChecksumPresent || g.RoutingPresent {
@@ -163,12 +214,14 @@ func (g *GRE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 			binary.BigEndian.PutUint16(buf[offset:offset+2], sre.AddressFamily)
 			buf[offset+2] = sre.SREOffset
 			buf[offset+3] = sre.SRELength
-			copy(buf[offset+4:offset+4+int(sre.SRELength)], sre.RoutingInformation)
+			n := copy(buf[offset+4:offset+4+int(sre.SRELength)], sre.RoutingInformation)
+			copy(buf[offset+4+n:offset+4+int(sre.SRELength)], lotsOfZeros[:])
 			offset += 4 + int(sre.SRELength)
 			sre = sre.Next
 		}
 		// Terminate routing field with a "NULL" SRE.
 		binary.BigEndian.PutUint32(buf[offset:offset+4], 0)
+		offset += 4
 	}
 	if g.AckPresent {
 		binary.BigEndian.PutUint32(buf[offset:offset+4], g.Ack)
@@ -387,3 +387,95 @@ func setNetworkLayer(layers []gopacket.SerializableLayer) error {
 	}
 	return nil
 }
+
+func TestGREOptionalFieldsEncode(t *testing.T) {
+	gre := &GRE{
+		ChecksumPresent: true,
+		RoutingPresent:  true,
+		KeyPresent:      true,
+		SeqPresent:      true,
+		Protocol:        EthernetTypeIPv4,
+		Offset:          4,
+		Key:             0x01020304,
+		Seq:             42,
+		GRERouting: &GRERouting{
+			AddressFamily:      0x0800,
+			RoutingInformation: []byte{10, 0, 0, 1, 10, 0, 0, 2},
+			Next: &GRERouting{
+				AddressFamily:      0x0800,
+				SREOffset:          4,
+				RoutingInformation: []byte{10, 0, 0, 3},
+			},
+		},
+	}
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolICMPv4, SrcIP: net.IP{172, 16, 1, 1}, DstIP: net.IP{172, 16, 2, 1}}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, gre, ip, &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeEchoRequest, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeGRE, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeGRE, LayerTypeIPv4, LayerTypeICMPv4}, t)
+	got := p.Layer(LayerTypeGRE).(*GRE)
+	if len(got.Contents) != 4+4+4+4+(4+8)+(4+4)+4 {
+		t.Errorf("GRE header of %d bytes", len(got.Contents))
+	}
+	if got.GRERouting == nil || got.GRERouting.SRELength != 8 || got.GRERouting.Next == nil || got.GRERouting.Next.SRELength != 4 || got.GRERouting.Next.Next != nil {
+		t.Errorf("unexpected routing %+v", got.GRERouting)
+	}
+	if tcpipChecksum(buf.Bytes(), 0) != 0 {
+		t.Error("GRE checksum does not verify")
+	}
+	gre.BaseLayer = got.BaseLayer
+	for r, want := got.GRERouting, gre.GRERouting; r != nil; r, want = r.Next, want.Next {
+		want.RoutingInformation = r.RoutingInformation
+	}
+	if !reflect.DeepEqual(got, gre) {
+		t.Errorf("got %+v, want %+v", got, gre)
+	}
+}
+
+func TestGREEnhancedEncode(t *testing.T) {
+	// The enhanced GRE header of PPTP carries the payload length in its
+	// key, and may acknowledge sequence numbers.
+	gre := &GRE{
+		KeyPresent: true,
+		SeqPresent: true,
+		AckPresent: true,
+		Version:    1,
+		Protocol:   EthernetTypePPP,
+		Key:        0xffff0007,
+		Seq:        3,
+		Ack:        2,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, gre, gopacket.Payload{0xff, 0x03, 0x00, 0x21, 0x45}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x30, 0x81, 0x88, 0x0b,
+		0x00, 0x05, 0x00, 0x07,
+		0x00, 0x00, 0x00, 0x03,
+		0x00, 0x00, 0x00, 0x02,
+		0xff, 0x03, 0x00, 0x21, 0x45,
+	}
+	if !reflect.DeepEqual(buf.Bytes(), want) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func TestGRETruncated(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x00, 0x00, 0x08},
+		{0x80, 0x00, 0x08, 0x00, 0x00},
+		{0x40, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x04, 0x0a},
+	} {
+		p := gopacket.NewPacket(data, LayerTypeGRE, gopacket.Default)
+		if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+			t.Errorf("%x: expected truncated packet", data)
+		}
+	}
+}
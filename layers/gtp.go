@@ -16,12 +16,95 @@ import (
 
 const gtpMinimumSizeInBytes int = 8
 
+// GTPv1U message types carrying user data.
+const (
+	GTPv1UMessageTypeEndMarker uint8 = 254
+	GTPv1UMessageTypeGPDU      uint8 = 255
+)
+
+// GTP extension header types, from 3GPP TS 29.281.
+const (
+	GTPExtensionHeaderTypeNoMore              uint8 = 0x00
+	GTPExtensionHeaderTypeUDPPort             uint8 = 0x40
+	GTPExtensionHeaderTypeRANContainer        uint8 = 0x81
+	GTPExtensionHeaderTypeLongPDCPPDUNumber   uint8 = 0x82
+	GTPExtensionHeaderTypeNRRANContainer      uint8 = 0x84
+	GTPExtensionHeaderTypePDUSessionContainer uint8 = 0x85
+	GTPExtensionHeaderTypePDCPPDUNumber       uint8 = 0xc0
+)
+
 // GTPExtensionHeader is used to carry extra data and enable future extensions of the GTP  without the need to use another version number.
 type GTPExtensionHeader struct {
-	Type    uint8
+	Type uint8
+	// Content is the extension header without its length and next type
+	// fields, so its length is a multiple of 4 bytes minus 2.
 	Content []byte
 }
 
+// GTP PDU Session Container PDU types.
+const (
+	GTPPDUTypeDownlink uint8 = 0
+	GTPPDUTypeUplink   uint8 = 1
+)
+
+// GTPPDUSessionContainer is the PDU Session Container extension header of
+// 5G user plane packets, defined in 3GPP TS 38.415. Only its leading
+// fields are decoded.
+type GTPPDUSessionContainer struct {
+	// PDUType is GTPPDUTypeDownlink or GTPPDUTypeUplink.
+	PDUType uint8
+	// QFI is the QoS Flow Identifier.
+	QFI uint8
+	// RQI, the Reflective QoS Indicator, PPP, the Paging Policy Presence
+	// flag, and PPI, the Paging Policy Indicator present if PPP is set,
+	// are only used in the downlink.
+	RQI bool
+	PPP bool
+	PPI uint8
+}
+
+// PDUSessionContainer decodes the content of a PDU Session Container
+// extension header.
+func (eh GTPExtensionHeader) PDUSessionContainer() (GTPPDUSessionContainer, error) {
+	var c GTPPDUSessionContainer
+	if eh.Type != GTPExtensionHeaderTypePDUSessionContainer {
+		return c, fmt.Errorf("GTP extension header type %#x is not a PDU Session Container", eh.Type)
+	}
+	if len(eh.Content) < 2 {
+		return c, fmt.Errorf("GTP PDU Session Container too small: %d bytes", len(eh.Content))
+	}
+	c.PDUType = eh.Content[0] >> 4
+	c.QFI = eh.Content[1] & 0x3f
+	if c.PDUType == GTPPDUTypeDownlink {
+		c.PPP = eh.Content[1]&0x80 != 0
+		c.RQI = eh.Content[1]&0x40 != 0
+		if c.PPP {
+			if len(eh.Content) < 3 {
+				return c, fmt.Errorf("GTP PDU Session Container too small: %d bytes", len(eh.Content))
+			}
+			c.PPI = eh.Content[2] >> 5
+		}
+	}
+	return c, nil
+}
+
+// ExtensionHeader returns the extension header holding c.
+func (c GTPPDUSessionContainer) ExtensionHeader() GTPExtensionHeader {
+	content := make([]byte, 2, 6)
+	content[0] = c.PDUType << 4
+	content[1] = c.QFI & 0x3f
+	if c.PDUType == GTPPDUTypeDownlink {
+		if c.RQI {
+			content[1] |= 0x40
+		}
+		if c.PPP {
+			content[1] |= 0x80
+			content = append(content, c.PPI<<5, 0, 0, 0)
+		}
+	}
+	return GTPExtensionHeader{Type: GTPExtensionHeaderTypePDUSessionContainer, Content: content}
+}
+
 // GTPv1U protocol is used to exchange user data over GTP tunnels across the Sx interfaces.
 // Defined in https://portal.3gpp.org/desktopmodules/Specifications/SpecificationDetails.aspx?specificationId=1595
 type GTPv1U struct {
@@ -43,11 +126,24 @@ type GTPv1U struct {
 // LayerType returns LayerTypeGTPV1U
 func (g *GTPv1U) LayerType() gopacket.LayerType { return LayerTypeGTPv1U }
 
+// PDUSessionContainer returns the first PDU Session Container extension
+// header of g, which holds the QFI of 5G user plane packets.
+func (g *GTPv1U) PDUSessionContainer() (GTPPDUSessionContainer, bool) {
+	for _, eh := range g.GTPExtensionHeaders {
+		if eh.Type == GTPExtensionHeaderTypePDUSessionContainer {
+			c, err := eh.PDUSessionContainer()
+			return c, err == nil
+		}
+	}
+	return GTPPDUSessionContainer{}, false
+}
+
 // DecodeFromBytes analyses a byte slice and attempts to decode it as a GTPv1U packet
 func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	hLen := gtpMinimumSizeInBytes
 	dLen := len(data)
 	if dLen < hLen {
+		df.SetTruncated()
 		return fmt.Errorf("GTP packet too small: %d bytes", dLen)
 	}
 	g.Version = (data[0] >> 5) & 0x07
@@ -58,17 +154,21 @@ func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 	g.ExtensionHeaderFlag = ((data[0] >> 2) & 0x01) == 1
 	g.MessageType = data[1]
 	g.MessageLength = binary.BigEndian.Uint16(data[2:4])
-	pLen := 8 + g.MessageLength
-	if uint16(dLen) < pLen {
+	pLen := 8 + int(g.MessageLength)
+	if dLen < pLen {
+		df.SetTruncated()
 		return fmt.Errorf("GTP packet too small: %d bytes", dLen)
 	}
 	//  Field used to multiplex different connections in the same GTP tunnel.
 	g.TEID = binary.BigEndian.Uint32(data[4:8])
-	cIndex := uint16(hLen)
+	g.SequenceNumber, g.NPDU = 0, 0
+	g.GTPExtensionHeaders = g.GTPExtensionHeaders[:0]
+	cIndex := hLen
 	if g.SequenceNumberFlag || g.NPDUFlag || g.ExtensionHeaderFlag {
 		hLen += 4
 		cIndex += 4
 		if dLen < hLen {
+			df.SetTruncated()
 			return fmt.Errorf("GTP packet too small: %d bytes", dLen)
 		}
 		if g.SequenceNumberFlag {
@@ -80,14 +180,21 @@ func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 		if g.ExtensionHeaderFlag {
 			extensionFlag := true
 			for extensionFlag {
-				extensionType := uint8(data[cIndex-1])
-				extensionLength := uint(data[cIndex])
+				// The type of each extension header is the last byte
+				// of what precedes it.
+				extensionType := data[cIndex-1]
+				if cIndex+1 > dLen {
+					df.SetTruncated()
+					return fmt.Errorf("GTP packet with truncated extension header: %d bytes", dLen)
+				}
+				extensionLength := int(data[cIndex])
 				if extensionLength == 0 {
 					return fmt.Errorf("GTP packet with invalid extension header")
 				}
 				// extensionLength is in 4-octet units
-				lIndex := cIndex + (uint16(extensionLength) * 4)
-				if uint16(dLen) < lIndex {
+				lIndex := cIndex + extensionLength*4
+				if lIndex > dLen {
+					df.SetTruncated()
 					return fmt.Errorf("GTP packet with small extension header: %d bytes", dLen)
 				}
 				content := data[cIndex+1 : lIndex-1]
@@ -109,15 +216,29 @@ func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (g *GTPv1U) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	data, err := b.PrependBytes(gtpMinimumSizeInBytes)
+	if len(g.GTPExtensionHeaders) > 0 {
+		g.ExtensionHeaderFlag = true
+	}
+	hLen := gtpMinimumSizeInBytes
+	if g.ExtensionHeaderFlag || g.SequenceNumberFlag || g.NPDUFlag {
+		hLen += 4
+	}
+	for _, eh := range g.GTPExtensionHeaders {
+		// Two extra bytes for the length and the next extension header
+		// type, the length being in 4-octet units.
+		if (len(eh.Content)+2)%4 != 0 || len(eh.Content)+2 > 255*4 {
+			return fmt.Errorf("invalid GTP extension header content length %d", len(eh.Content))
+		}
+		hLen += len(eh.Content) + 2
+	}
+	payloadLen := len(b.Bytes())
+	data, err := b.PrependBytes(hLen)
 	if err != nil {
 		return err
 	}
-	data[0] |= (g.Version << 5)
-	data[0] |= (1 << 4)
-	if len(g.GTPExtensionHeaders) > 0 {
+	data[0] = g.Version<<5 | 1<<4
+	if g.ExtensionHeaderFlag {
 		data[0] |= 0x04
-		g.ExtensionHeaderFlag = true
 	}
 	if g.SequenceNumberFlag {
 		data[0] |= 0x02
@@ -126,31 +247,26 @@ func (g *GTPv1U) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serialize
 		data[0] |= 0x01
 	}
 	data[1] = g.MessageType
+	if opts.FixLengths {
+		g.MessageLength = uint16(hLen - gtpMinimumSizeInBytes + payloadLen)
+	}
 	binary.BigEndian.PutUint16(data[2:4], g.MessageLength)
 	binary.BigEndian.PutUint32(data[4:8], g.TEID)
-	if g.ExtensionHeaderFlag || g.SequenceNumberFlag || g.NPDUFlag {
-		data, err := b.AppendBytes(4)
-		if err != nil {
-			return err
-		}
-		binary.BigEndian.PutUint16(data[:2], g.SequenceNumber)
-		data[2] = g.NPDU
-		for _, eh := range g.GTPExtensionHeaders {
-			data[len(data)-1] = eh.Type
-			lContent := len(eh.Content)
-			// extensionLength is in 4-octet units
-			extensionLength := (lContent + 2) / 4
-			// Get two extra byte for the next extension header type and length
-			data, err = b.AppendBytes(lContent + 2)
-			if err != nil {
-				return err
-			}
-			data[0] = byte(extensionLength)
-			copy(data[1:lContent+1], eh.Content)
-		}
+	if hLen == gtpMinimumSizeInBytes {
+		return nil
+	}
+	binary.BigEndian.PutUint16(data[8:10], g.SequenceNumber)
+	data[10] = g.NPDU
+	data[11] = GTPExtensionHeaderTypeNoMore
+	i := 12
+	for _, eh := range g.GTPExtensionHeaders {
+		data[i-1] = eh.Type
+		data[i] = byte((len(eh.Content) + 2) / 4)
+		copy(data[i+1:], eh.Content)
+		i += len(eh.Content) + 2
+		data[i-1] = GTPExtensionHeaderTypeNoMore
 	}
 	return nil
-
 }
 
 // CanDecode returns a set of layers that GTP objects can decode.
@@ -159,10 +275,14 @@ func (g *GTPv1U) CanDecode() gopacket.LayerClass {
 }
 
 // NextLayerType specifies the next layer that GoPacket should attempt to
+// decode: the user data of G-PDUs, and the payload of other messages.
 func (g *GTPv1U) NextLayerType() gopacket.LayerType {
 	if len(g.LayerPayload()) == 0 {
 		return gopacket.LayerTypeZero
 	}
+	if g.MessageType != GTPv1UMessageTypeGPDU {
+		return gopacket.LayerTypePayload
+	}
 	version := uint8(g.LayerPayload()[0]) >> 4
 	if version == 4 {
 		return LayerTypeIPv4
@@ -156,3 +156,103 @@ func TestGTPPacketWithEH(t *testing.T) {
 	}
 
 }
+
+func TestGTPPDUSessionContainer(t *testing.T) {
+	eth := &Ethernet{
+		SrcMAC:       []byte{0, 1, 2, 3, 4, 5},
+		DstMAC:       []byte{6, 7, 8, 9, 10, 11},
+		EthernetType: EthernetTypeIPv4,
+	}
+	outer := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: []byte{10, 0, 0, 1}, DstIP: []byte{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 2152, DstPort: 2152}
+	udp.SetNetworkLayerForChecksum(outer)
+	gtp := &GTPv1U{
+		Version:     1,
+		MessageType: GTPv1UMessageTypeGPDU,
+		TEID:        0x1234,
+		GTPExtensionHeaders: []GTPExtensionHeader{
+			GTPPDUSessionContainer{PDUType: GTPPDUTypeDownlink, QFI: 9, RQI: true, PPP: true, PPI: 5}.ExtensionHeader(),
+		},
+	}
+	inner := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: []byte{192, 168, 0, 1}, DstIP: []byte{192, 168, 0, 2}}
+	innerUDP := &UDP{SrcPort: 1000, DstPort: 2000}
+	innerUDP.SetNetworkLayerForChecksum(inner)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, outer, udp, gtp, inner, innerUDP, gopacket.Payload("hello")); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeGTPv1U, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	got := p.Layer(LayerTypeGTPv1U).(*GTPv1U)
+	if !got.ExtensionHeaderFlag || got.MessageLength != 4+8+20+8+5 {
+		t.Errorf("unexpected GTP header %+v", got)
+	}
+	c, ok := got.PDUSessionContainer()
+	if !ok {
+		t.Fatal("no PDU Session Container")
+	}
+	want := GTPPDUSessionContainer{PDUType: GTPPDUTypeDownlink, QFI: 9, RQI: true, PPP: true, PPI: 5}
+	if c != want {
+		t.Errorf("PDU Session Container: got %+v, want %+v", c, want)
+	}
+	if got := p.Layer(gopacket.LayerTypePayload).LayerContents(); string(got) != "hello" {
+		t.Errorf("payload: got %q", got)
+	}
+}
+
+func TestGTPPDUSessionContainerUplink(t *testing.T) {
+	eh := GTPPDUSessionContainer{PDUType: GTPPDUTypeUplink, QFI: 1}.ExtensionHeader()
+	if len(eh.Content) != 2 || eh.Content[0] != 0x10 || eh.Content[1] != 0x01 {
+		t.Errorf("unexpected content %x", eh.Content)
+	}
+	c, err := eh.PDUSessionContainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PDUType != GTPPDUTypeUplink || c.QFI != 1 {
+		t.Errorf("unexpected container %+v", c)
+	}
+	if _, err := (GTPExtensionHeader{Type: GTPExtensionHeaderTypeUDPPort, Content: []byte{0, 1}}).PDUSessionContainer(); err == nil {
+		t.Error("expected an error decoding a UDP port extension header")
+	}
+}
+
+func TestGTPNonGPDU(t *testing.T) {
+	// An echo request is not followed by user data.
+	data := []byte{0x32, 0x01, 0x00, 0x04, 0, 0, 0, 0, 0x00, 0x01, 0x00, 0x00}
+	p := gopacket.NewPacket(data, LayerTypeGTPv1U, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeGTPv1U}, t)
+
+	p = gopacket.NewPacket(data[:10], LayerTypeGTPv1U, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("expected truncated packet")
+	}
+}
+
+func TestGTPTruncatedExtensionHeader(t *testing.T) {
+	for _, data := range [][]byte{
+		// The header announces an extension header but ends before it.
+		{0x34, 0xff, 0x00, 0x04, 0, 0, 0, 1, 0, 0, 0, 0x85},
+		// The extension header is longer than the packet.
+		{0x34, 0xff, 0x00, 0x08, 0, 0, 0, 1, 0, 0, 0, 0x85, 0x02, 0, 0, 0},
+	} {
+		p := gopacket.NewPacket(data, LayerTypeGTPv1U, gopacket.Default)
+		if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+			t.Errorf("%x: expected truncated packet", data)
+		}
+		// DecodingLayerParser doesn't recover from panics.
+		var g GTPv1U
+		if err := g.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%x: decoded truncated extension header", data)
+		}
+	}
+}
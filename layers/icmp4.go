@@ -11,6 +11,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
 
 	"github.com/google/gopacket"
@@ -251,6 +252,13 @@ func (i *ICMPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serialize
 	return nil
 }
 
+// VerifyChecksum verifies the checksum of the decoded message.
+func (i *ICMPv4) VerifyChecksum() gopacket.ChecksumResult {
+	data := append(append([]byte(nil), i.Contents...), i.Payload...)
+	data[2], data[3] = 0, 0
+	return checksumResult(LayerTypeICMPv4, uint32(i.Checksum), uint32(tcpipChecksum(data, 0)))
+}
+
 // CanDecode returns the set of layer types that this DecodingLayer can decode.
 func (i *ICMPv4) CanDecode() gopacket.LayerClass {
 	return LayerTypeICMPv4
@@ -258,9 +266,77 @@ func (i *ICMPv4) CanDecode() gopacket.LayerClass {
 
 // NextLayerType returns the layer type contained by this DecodingLayer.
 func (i *ICMPv4) NextLayerType() gopacket.LayerType {
+	switch i.TypeCode.Type() {
+	case ICMPv4TypeTimestampRequest, ICMPv4TypeTimestampReply:
+		return LayerTypeICMPv4Timestamp
+	case ICMPv4TypeAddressMaskRequest, ICMPv4TypeAddressMaskReply:
+		return LayerTypeICMPv4AddressMask
+	}
 	return gopacket.LayerTypePayload
 }
 
+// Gateway returns the gateway internet address of a Redirect message. For
+// Redirect messages the second word of the ICMPv4 header holds this address
+// instead of an identifier and sequence number, so Gateway returns nil for any
+// other message type.
+func (i *ICMPv4) Gateway() net.IP {
+	if i.TypeCode.Type() != ICMPv4TypeRedirect {
+		return nil
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint16(ip[0:], i.Id)
+	binary.BigEndian.PutUint16(ip[2:], i.Seq)
+	return ip
+}
+
+// SetGateway stores the given IPv4 address in the Id and Seq fields so that it
+// is serialized as the gateway internet address of a Redirect message.
+func (i *ICMPv4) SetGateway(ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return fmt.Errorf("invalid ICMPv4 redirect gateway %v", ip)
+	}
+	i.Id = binary.BigEndian.Uint16(ip4[0:2])
+	i.Seq = binary.BigEndian.Uint16(ip4[2:4])
+	return nil
+}
+
+// isError returns whether the message is an error message which may carry
+// extensions after the datagram which caused it (RFC 4884).
+func (i *ICMPv4) isError() bool {
+	switch i.TypeCode.Type() {
+	case ICMPv4TypeDestinationUnreachable, ICMPv4TypeTimeExceeded, ICMPv4TypeParameterProblem:
+		return true
+	}
+	return false
+}
+
+// OriginalDatagram returns the part of the payload of an error message
+// holding the datagram which caused it, without the extensions which may
+// follow. It returns the whole payload for other messages.
+func (i *ICMPv4) OriginalDatagram() []byte {
+	if !i.isError() {
+		return i.Payload
+	}
+	datagram, _, _ := icmpExtensions(i.Payload, 4*int(i.Id&0xff))
+	return datagram
+}
+
+// Extensions decodes the extensions following the original datagram of a
+// Destination Unreachable, Time Exceeded or Parameter Problem message
+// (RFC 4884), such as the MPLS label stacks and interface information
+// reported to traceroute. It returns nil if the message has none. The
+// length of the original datagram is in the low byte of Id, in 32-bit
+// words; if it is zero, extensions are looked for after the first 128
+// bytes, as sent by implementations which predate RFC 4884.
+func (i *ICMPv4) Extensions() (*ICMPExtensions, error) {
+	if !i.isError() {
+		return nil, nil
+	}
+	_, e, err := icmpExtensions(i.Payload, 4*int(i.Id&0xff))
+	return e, err
+}
+
 func decodeICMPv4(data []byte, p gopacket.PacketBuilder) error {
 	i := &ICMPv4{}
 	return decodingLayerDecoder(i, data, p)
@@ -0,0 +1,154 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketICMPv4TimestampReply is the packet:
+// IP 192.168.1.1 > 192.168.1.2: ICMP time stamp reply id 16962 seq 1: org 13:27:48.800, recv 13:27:48.805, xmit 13:27:48.805, length 20
+//         0x0000:  001b 213c 9df8 000c 290e 4c67 0800 4500  ..!<....).Lg..E.
+//         0x0010:  0028 1234 0000 4001 e54d c0a8 0101 c0a8  .(.4..@..M......
+//         0x0020:  0102 0e00 8ecd 4242 0001 02e1 b2c0 02e1  ......BB........
+//         0x0030:  b2c5 02e1 b2c5 0000 0000 0000            ............
+var testPacketICMPv4TimestampReply = []byte{
+	0x00, 0x1b, 0x21, 0x3c, 0x9d, 0xf8, 0x00, 0x0c, 0x29, 0x0e, 0x4c, 0x67, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x28, 0x12, 0x34, 0x00, 0x00, 0x40, 0x01, 0xe5, 0x4d, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0x02, 0x0e, 0x00, 0x8e, 0xcd, 0x42, 0x42, 0x00, 0x01, 0x02, 0xe1, 0xb2, 0xc0, 0x02, 0xe1,
+	0xb2, 0xc5, 0x02, 0xe1, 0xb2, 0xc5, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketICMPv4TimestampReply(t *testing.T) {
+	p := gopacket.NewPacket(testPacketICMPv4TimestampReply, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, LayerTypeICMPv4Timestamp}, t)
+
+	icmp := p.Layer(LayerTypeICMPv4).(*ICMPv4)
+	if icmp.Id != 0x4242 || icmp.Seq != 1 {
+		t.Errorf("bad id/seq: got %d/%d", icmp.Id, icmp.Seq)
+	}
+	ts := p.Layer(LayerTypeICMPv4Timestamp).(*ICMPv4Timestamp)
+	if ts.Originate != 0x02e1b2c0 || ts.Receive != 0x02e1b2c5 || ts.Transmit != 0x02e1b2c5 {
+		t.Errorf("bad timestamps: %+v", ts)
+	}
+	checkSerialization(p, t)
+}
+
+func TestICMPv4AddressMaskSerialize(t *testing.T) {
+	icmp := &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeAddressMaskReply, 0), Id: 1, Seq: 2}
+	mask := &ICMPv4AddressMask{AddressMask: net.CIDRMask(22, 32)}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, icmp, mask); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeICMPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got, ok := p.Layer(LayerTypeICMPv4AddressMask).(*ICMPv4AddressMask)
+	if !ok {
+		t.Fatal("No address mask layer decoded")
+	}
+	if ones, bits := got.AddressMask.Size(); ones != 22 || bits != 32 {
+		t.Errorf("bad address mask: got %v", got.AddressMask)
+	}
+	if c := tcpipChecksum(buf.Bytes(), 0); c != 0 {
+		t.Errorf("bad checksum: residual %#04x", c)
+	}
+}
+
+func TestICMPv4RedirectGateway(t *testing.T) {
+	icmp := &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeRedirect, ICMPv4CodeHost)}
+	gw := net.IPv4(10, 0, 0, 254)
+	if err := icmp.SetGateway(gw); err != nil {
+		t.Fatal(err)
+	}
+	if got := icmp.Gateway(); !got.Equal(gw) {
+		t.Errorf("got gateway %v, want %v", got, gw)
+	}
+	if err := icmp.SetGateway(net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("expected error setting an IPv6 gateway")
+	}
+	icmp.TypeCode = CreateICMPv4TypeCode(ICMPv4TypeEchoRequest, 0)
+	if icmp.Gateway() != nil {
+		t.Error("expected no gateway for an echo request")
+	}
+}
+
+func TestICMPv4Extensions(t *testing.T) {
+	datagram := make([]byte, 128)
+	datagram[0] = 0x45
+	ext := []byte{
+		0x20, 0x00, 0x00, 0x00, // version 2, checksum filled in below
+		0x00, 0x0c, 0x01, 0x01, // MPLS label stack, 2 entries
+		0x00, 0x01, 0x01, 0x01, // label 16, bottom of stack, TTL 1
+		0x00, 0x01, 0x13, 0xff, // label 17, TC 1, bottom of stack, TTL 255
+		0x00, 0x18, 0x02, 0x8f, // interface information: outgoing, all fields
+		0x00, 0x00, 0x00, 0x07, // ifIndex
+		0x00, 0x01, 0x00, 0x00, 192, 0, 2, 1, // IPv4 address
+		0x04, 'e', 't', 0x00, // name
+		0x00, 0x00, 0x05, 0xdc, // MTU
+	}
+	binary.BigEndian.PutUint16(ext[2:], tcpipChecksum(ext, 0))
+	wantLabels := []MPLS{{Label: 16, StackBottom: true, TTL: 1}, {Label: 17, TrafficClass: 1, StackBottom: true, TTL: 255}}
+	wantInfo := &ICMPInterfaceInfo{
+		Role:       ICMPInterfaceRoleOutgoing,
+		HasIfIndex: true, IfIndex: 7,
+		IP:     net.IP{192, 0, 2, 1},
+		Name:   "et",
+		HasMTU: true, MTU: 1500,
+	}
+
+	// With the original datagram length set, and as sent before RFC 4884.
+	for _, id := range []uint16{128 / 4, 0} {
+		icmp := &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeTimeExceeded, ICMPv4CodeTTLExceeded), Id: id}
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, icmp,
+			gopacket.Payload(append(append([]byte{}, datagram...), ext...))); err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LayerTypeICMPv4, gopacket.Default)
+		got := p.Layer(LayerTypeICMPv4).(*ICMPv4)
+		if d := got.OriginalDatagram(); !bytes.Equal(d, datagram) {
+			t.Errorf("id %d: got original datagram of %d bytes", id, len(d))
+		}
+		e, err := got.Extensions()
+		if err != nil {
+			t.Fatalf("id %d: %v", id, err)
+		} else if e == nil || len(e.Objects) != 2 {
+			t.Fatalf("id %d: got extensions %+v", id, e)
+		}
+		if labels, err := e.Objects[0].MPLSLabelStack(); err != nil || !reflect.DeepEqual(labels, wantLabels) {
+			t.Errorf("id %d: got labels %+v, %v", id, labels, err)
+		}
+		if info, err := e.Objects[1].InterfaceInfo(); err != nil || !reflect.DeepEqual(info, wantInfo) {
+			t.Errorf("id %d: got interface information %+v, %v", id, info, err)
+		}
+	}
+
+	// Without a valid checksum, data past 128 bytes is not taken for
+	// extensions unless the length says so.
+	ext[2] ^= 0xff
+	icmp := &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeDestinationUnreachable, ICMPv4CodePort)}
+	icmp.Payload = append(append([]byte{}, datagram...), ext...)
+	if e, err := icmp.Extensions(); e != nil || err != nil {
+		t.Errorf("got extensions %+v, %v", e, err)
+	}
+	if d := icmp.OriginalDatagram(); len(d) != len(icmp.Payload) {
+		t.Errorf("got original datagram of %d bytes", len(d))
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// Based on RFC 792 and RFC 950
+
+// ICMPv4Timestamp holds the body of an ICMPv4 Timestamp Request or Timestamp
+// Reply message. The identifier and sequence number are carried in the
+// preceding ICMPv4 layer. All timestamps are in milliseconds since midnight
+// UT.
+type ICMPv4Timestamp struct {
+	BaseLayer
+	Originate uint32
+	Receive   uint32
+	Transmit  uint32
+}
+
+// ICMPv4AddressMask holds the body of an ICMPv4 Address Mask Request or
+// Address Mask Reply message. The identifier and sequence number are carried
+// in the preceding ICMPv4 layer.
+type ICMPv4AddressMask struct {
+	BaseLayer
+	AddressMask net.IPMask
+}
+
+// LayerType returns LayerTypeICMPv4Timestamp.
+func (i *ICMPv4Timestamp) LayerType() gopacket.LayerType {
+	return LayerTypeICMPv4Timestamp
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *ICMPv4Timestamp) CanDecode() gopacket.LayerClass {
+	return LayerTypeICMPv4Timestamp
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (i *ICMPv4Timestamp) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *ICMPv4Timestamp) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return errors.New("ICMP layer less then 12 bytes for ICMPv4 timestamp")
+	}
+	i.Originate = binary.BigEndian.Uint32(data[0:4])
+	i.Receive = binary.BigEndian.Uint32(data[4:8])
+	i.Transmit = binary.BigEndian.Uint32(data[8:12])
+	i.BaseLayer = BaseLayer{data[:12], data[12:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (i *ICMPv4Timestamp) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	buf, err := b.PrependBytes(12)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(buf[0:], i.Originate)
+	binary.BigEndian.PutUint32(buf[4:], i.Receive)
+	binary.BigEndian.PutUint32(buf[8:], i.Transmit)
+	return nil
+}
+
+func decodeICMPv4Timestamp(data []byte, p gopacket.PacketBuilder) error {
+	i := &ICMPv4Timestamp{}
+	return decodingLayerDecoder(i, data, p)
+}
+
+// LayerType returns LayerTypeICMPv4AddressMask.
+func (i *ICMPv4AddressMask) LayerType() gopacket.LayerType {
+	return LayerTypeICMPv4AddressMask
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *ICMPv4AddressMask) CanDecode() gopacket.LayerClass {
+	return LayerTypeICMPv4AddressMask
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (i *ICMPv4AddressMask) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *ICMPv4AddressMask) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("ICMP layer less then 4 bytes for ICMPv4 address mask")
+	}
+	i.AddressMask = net.IPMask(data[0:4])
+	i.BaseLayer = BaseLayer{data[:4], data[4:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (i *ICMPv4AddressMask) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	buf, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	switch len(i.AddressMask) {
+	case 0:
+		copy(buf, lotsOfZeros[:4])
+	case 4:
+		copy(buf, i.AddressMask)
+	case 16:
+		// A 16 byte mask carries the IPv4 part in its last four bytes.
+		copy(buf, i.AddressMask[12:])
+	default:
+		return errors.New("invalid ICMPv4 address mask length")
+	}
+	return nil
+}
+
+func decodeICMPv4AddressMask(data []byte, p gopacket.PacketBuilder) error {
+	i := &ICMPv4AddressMask{}
+	return decodingLayerDecoder(i, data, p)
+}
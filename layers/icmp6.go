@@ -199,8 +199,18 @@ func (i *ICMPv6) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
-// See the docs for gopacket.SerializableLayer for more info.
+// See the docs for gopacket.SerializableLayer for more info. With
+// FixLengths, a TypeCode of zero, which is a reserved type, is set from the
+// neighbor discovery or multicast listener discovery message serialized
+// inside this layer.
 func (i *ICMPv6) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if opts.FixLengths && i.TypeCode == 0 {
+		if inner := b.Layers(); len(inner) > 0 {
+			if typ, ok := icmpv6MessageTypes[inner[len(inner)-1]]; ok {
+				i.TypeCode = CreateICMPv6TypeCode(typ, 0)
+			}
+		}
+	}
 	bytes, err := b.PrependBytes(4)
 	if err != nil {
 		return err
@@ -221,6 +231,26 @@ func (i *ICMPv6) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serialize
 	return nil
 }
 
+// VerifyChecksum verifies the checksum of the decoded message.
+func (i *ICMPv6) VerifyChecksum() gopacket.ChecksumResult {
+	return i.verifyChecksum(LayerTypeICMPv6, i.Contents, i.Payload, 2, IPProtocolICMPv6, i.Checksum)
+}
+
+// icmpv6MessageTypes maps the layers of neighbor discovery and multicast
+// listener discovery messages to their ICMPv6 types.
+var icmpv6MessageTypes = map[gopacket.LayerType]uint8{
+	LayerTypeICMPv6RouterSolicitation:     ICMPv6TypeRouterSolicitation,
+	LayerTypeICMPv6RouterAdvertisement:    ICMPv6TypeRouterAdvertisement,
+	LayerTypeICMPv6NeighborSolicitation:   ICMPv6TypeNeighborSolicitation,
+	LayerTypeICMPv6NeighborAdvertisement:  ICMPv6TypeNeighborAdvertisement,
+	LayerTypeICMPv6Redirect:               ICMPv6TypeRedirect,
+	LayerTypeMLDv1MulticastListenerQuery:  ICMPv6TypeMLDv1MulticastListenerQueryMessage,
+	LayerTypeMLDv1MulticastListenerReport: ICMPv6TypeMLDv1MulticastListenerReportMessage,
+	LayerTypeMLDv1MulticastListenerDone:   ICMPv6TypeMLDv1MulticastListenerDoneMessage,
+	LayerTypeMLDv2MulticastListenerQuery:  ICMPv6TypeMLDv1MulticastListenerQueryMessage,
+	LayerTypeMLDv2MulticastListenerReport: ICMPv6TypeMLDv2MulticastListenerReportMessageV2,
+}
+
 // CanDecode returns the set of layer types that this DecodingLayer can decode.
 func (i *ICMPv6) CanDecode() gopacket.LayerClass {
 	return LayerTypeICMPv6
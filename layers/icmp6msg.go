@@ -325,7 +325,7 @@ func (i *ICMPv6NeighborSolicitation) SerializeTo(b gopacket.SerializeBuffer, opt
 	}
 
 	copy(buf, lotsOfZeros[:4])
-	copy(buf[4:], i.TargetAddress)
+	copy(buf[4:], i.TargetAddress.To16())
 	return nil
 }
 
@@ -376,7 +376,7 @@ func (i *ICMPv6NeighborAdvertisement) SerializeTo(b gopacket.SerializeBuffer, op
 
 	buf[0] = byte(i.Flags)
 	copy(buf[1:], lotsOfZeros[:3])
-	copy(buf[4:], i.TargetAddress)
+	copy(buf[4:], i.TargetAddress.To16())
 	return nil
 }
 
@@ -442,8 +442,8 @@ func (i *ICMPv6Redirect) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.S
 	}
 
 	copy(buf, lotsOfZeros[:4])
-	copy(buf[4:], i.TargetAddress)
-	copy(buf[20:], i.DestinationAddress)
+	copy(buf[4:], i.TargetAddress.To16())
+	copy(buf[20:], i.DestinationAddress.To16())
 	return nil
 }
 
@@ -530,10 +530,24 @@ func (i *ICMPv6Options) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback)
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
-// See the docs for gopacket.SerializableLayer for more info.
+// See the docs for gopacket.SerializableLayer for more info. Options are
+// sized in units of 8 bytes: with FixLengths their data is padded with
+// zeros up to the next unit, and without it, options of other sizes are
+// an error.
 func (i *ICMPv6Options) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	for _, opt := range []ICMPv6Option(*i) {
+	// Options are prepended, so the last one goes first.
+	for j := len(*i) - 1; j >= 0; j-- {
+		opt := (*i)[j]
 		length := len(opt.Data) + 2
+		if rem := length % 8; rem != 0 {
+			if !opts.FixLengths {
+				return fmt.Errorf("ICMPv6 option %v length %d is not a multiple of 8", opt.Type, length)
+			}
+			length += 8 - rem
+		}
+		if length > 255*8 {
+			return fmt.Errorf("ICMPv6 option %v too long: %d bytes", opt.Type, length)
+		}
 		buf, err := b.PrependBytes(length)
 		if err != nil {
 			return err
@@ -542,6 +556,7 @@ func (i *ICMPv6Options) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Se
 		buf[0] = byte(opt.Type)
 		buf[1] = byte(length / 8)
 		copy(buf[2:], opt.Data)
+		copy(buf[2+len(opt.Data):], lotsOfZeros[:length-2-len(opt.Data)])
 	}
 
 	return nil
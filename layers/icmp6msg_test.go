@@ -8,8 +8,12 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"net"
+	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 // testPacketICMPv6RouterAdvertisement is the packet:
@@ -71,3 +75,62 @@ func TestPacketICMPv6NeighborSolicitation(t *testing.T) {
 	}
 	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv6, LayerTypeICMPv6, LayerTypeICMPv6NeighborSolicitation}, t)
 }
+
+func TestPacketICMPv6RouterAdvertisementSerialize(t *testing.T) {
+	p := gopacket.NewPacket(testPacketICMPv6RouterAdvertisement, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	p.Layer(LayerTypeICMPv6).(*ICMPv6).SetNetworkLayerForChecksum(p.NetworkLayer())
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializePacket(buf, opts, p); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.Bytes(); !bytes.Equal(got, testPacketICMPv6RouterAdvertisement) {
+		t.Errorf("got\n%x\nwant\n%x", got, testPacketICMPv6RouterAdvertisement)
+	}
+}
+
+func TestICMPv6NeighborSolicitationSerialize(t *testing.T) {
+	ip6 := &IPv6{Version: 6, HopLimit: 255, NextHeader: IPProtocolICMPv6,
+		SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP("ff02::1:ff00:2")}
+	// The ICMPv6 type is left for FixLengths to set.
+	icmp := &ICMPv6{}
+	icmp.SetNetworkLayerForChecksum(ip6)
+	options := ICMPv6Options{
+		{Type: ICMPv6OptSourceAddress, Data: []byte{0, 1, 2, 3, 4, 5}},
+		{Type: ICMPv6Opt(14), Data: []byte{1, 2, 3, 4}}, // padded to 8 bytes
+	}
+	ns := &ICMPv6NeighborSolicitation{TargetAddress: net.ParseIP("fe80::2"), Options: options}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, icmp, ns); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeICMPv6, LayerTypeICMPv6NeighborSolicitation}, t)
+	gotICMP := p.Layer(LayerTypeICMPv6).(*ICMPv6)
+	if gotICMP.TypeCode.Type() != ICMPv6TypeNeighborSolicitation {
+		t.Errorf("got type %v", gotICMP.TypeCode)
+	}
+	gotICMP.SetNetworkLayerForChecksum(p.NetworkLayer())
+	data := append(append([]byte{}, gotICMP.Contents...), gotICMP.Payload...)
+	data[2], data[3] = 0, 0
+	if csum, err := gotICMP.computeChecksum(data, IPProtocolICMPv6); err != nil || csum != gotICMP.Checksum {
+		t.Errorf("got checksum %#x, want %#x (%v)", gotICMP.Checksum, csum, err)
+	}
+	got := p.Layer(LayerTypeICMPv6NeighborSolicitation).(*ICMPv6NeighborSolicitation)
+	options[1].Data = []byte{1, 2, 3, 4, 0, 0}
+	if !got.TargetAddress.Equal(ns.TargetAddress) || !reflect.DeepEqual(got.Options, options) {
+		t.Errorf("got %v %v, want %v %v", got.TargetAddress, got.Options, ns.TargetAddress, options)
+	}
+
+	ns.Options = ICMPv6Options{{Type: ICMPv6Opt(14), Data: []byte{1, 2, 3, 4}}}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, ns); err == nil {
+		t.Error("serialized an option of 6 bytes without FixLengths")
+	}
+}
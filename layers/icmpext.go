@@ -0,0 +1,237 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// icmpExtensionCompatOffset is where extensions start in ICMP messages
+// which predate RFC 4884 and leave the original datagram length unset.
+const icmpExtensionCompatOffset = 128
+
+// ICMPExtensionClass is the class of an ICMP extension object.
+type ICMPExtensionClass uint8
+
+// ICMP extension object classes.
+const (
+	ICMPExtensionClassMPLSLabelStack ICMPExtensionClass = 1 // RFC 4950
+	ICMPExtensionClassInterfaceInfo  ICMPExtensionClass = 2 // RFC 5837
+	ICMPExtensionClassInterfaceID    ICMPExtensionClass = 3 // RFC 8335
+)
+
+func (c ICMPExtensionClass) String() string {
+	switch c {
+	case ICMPExtensionClassMPLSLabelStack:
+		return "MPLSLabelStack"
+	case ICMPExtensionClassInterfaceInfo:
+		return "InterfaceInfo"
+	case ICMPExtensionClassInterfaceID:
+		return "InterfaceID"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// ICMPExtensions is the extension structure appended to the original
+// datagram of ICMP error messages (RFC 4884).
+type ICMPExtensions struct {
+	Version  uint8
+	Checksum uint16
+	Objects  []ICMPExtensionObject
+}
+
+// ICMPExtensionObject is an object of an ICMP extension structure.
+type ICMPExtensionObject struct {
+	Class ICMPExtensionClass
+	CType uint8
+	Data  []byte
+}
+
+// DecodeICMPExtensions decodes an ICMP extension structure. It does not
+// verify its checksum.
+func DecodeICMPExtensions(data []byte) (*ICMPExtensions, error) {
+	if len(data) < 4 {
+		return nil, errors.New("ICMP extension structure too short")
+	}
+	e := &ICMPExtensions{
+		Version:  data[0] >> 4,
+		Checksum: binary.BigEndian.Uint16(data[2:]),
+	}
+	if e.Version != 2 {
+		return nil, fmt.Errorf("unsupported ICMP extension version %d", e.Version)
+	}
+	for data = data[4:]; len(data) > 0; {
+		if len(data) < 4 {
+			return nil, errors.New("ICMP extension object too short")
+		}
+		length := int(binary.BigEndian.Uint16(data))
+		if length < 4 || length > len(data) {
+			return nil, fmt.Errorf("invalid ICMP extension object length %d", length)
+		}
+		e.Objects = append(e.Objects, ICMPExtensionObject{
+			Class: ICMPExtensionClass(data[2]),
+			CType: data[3],
+			Data:  data[4:length],
+		})
+		data = data[length:]
+	}
+	return e, nil
+}
+
+// icmpExtensions returns the original datagram and the extension structure
+// of the payload of an ICMP error message whose original datagram length
+// field is length bytes. Messages which leave the length unset may still
+// have extensions after the first 128 bytes, which are only accepted if
+// their checksum is valid.
+func icmpExtensions(payload []byte, length int) ([]byte, *ICMPExtensions, error) {
+	if length == 0 {
+		if len(payload) <= icmpExtensionCompatOffset {
+			return payload, nil, nil
+		}
+		data := payload[icmpExtensionCompatOffset:]
+		e, err := DecodeICMPExtensions(data)
+		if err != nil || tcpipChecksum(data, 0) != 0 {
+			return payload, nil, nil
+		}
+		return payload[:icmpExtensionCompatOffset], e, nil
+	}
+	if length >= len(payload) {
+		return payload, nil, nil
+	}
+	e, err := DecodeICMPExtensions(payload[length:])
+	return payload[:length], e, err
+}
+
+// MPLSLabelStack returns the label stack entries of an MPLS label stack
+// object (RFC 4950), outermost first, as MPLS layers without contents.
+func (o ICMPExtensionObject) MPLSLabelStack() ([]MPLS, error) {
+	if o.Class != ICMPExtensionClassMPLSLabelStack || o.CType != 1 {
+		return nil, fmt.Errorf("not an MPLS label stack object: %v/%d", o.Class, o.CType)
+	}
+	if len(o.Data)%4 != 0 {
+		return nil, fmt.Errorf("invalid MPLS label stack object length %d", len(o.Data))
+	}
+	stack := make([]MPLS, len(o.Data)/4)
+	for i := range stack {
+		entry := binary.BigEndian.Uint32(o.Data[4*i:])
+		stack[i] = MPLS{
+			Label:        entry >> 12,
+			TrafficClass: uint8(entry>>9) & 0x7,
+			StackBottom:  entry&0x100 != 0,
+			TTL:          uint8(entry),
+		}
+	}
+	return stack, nil
+}
+
+// ICMPInterfaceRole is the role of the interface described by an
+// interface information object.
+type ICMPInterfaceRole uint8
+
+// ICMP interface information object roles.
+const (
+	ICMPInterfaceRoleIncoming  ICMPInterfaceRole = 0
+	ICMPInterfaceRoleSubIP     ICMPInterfaceRole = 1
+	ICMPInterfaceRoleOutgoing  ICMPInterfaceRole = 2
+	ICMPInterfaceRoleIPNextHop ICMPInterfaceRole = 3
+)
+
+func (r ICMPInterfaceRole) String() string {
+	switch r {
+	case ICMPInterfaceRoleIncoming:
+		return "Incoming"
+	case ICMPInterfaceRoleSubIP:
+		return "SubIP"
+	case ICMPInterfaceRoleOutgoing:
+		return "Outgoing"
+	case ICMPInterfaceRoleIPNextHop:
+		return "IPNextHop"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(r))
+}
+
+// ICMPInterfaceInfo is the content of an interface information object
+// (RFC 5837), describing an interface of the router which sent the
+// message. Its C-Type tells which of the fields are present.
+type ICMPInterfaceInfo struct {
+	Role ICMPInterfaceRole
+	// HasIfIndex and HasMTU tell whether IfIndex and MTU are present. IP
+	// and Name are nil and empty when absent.
+	HasIfIndex, HasMTU bool
+	IfIndex, MTU       uint32
+	IP                 net.IP
+	Name               string
+}
+
+// C-Type bits of interface information objects.
+const (
+	icmpInterfaceInfoIfIndex = 0x08
+	icmpInterfaceInfoIP      = 0x04
+	icmpInterfaceInfoName    = 0x02
+	icmpInterfaceInfoMTU     = 0x01
+)
+
+// InterfaceInfo decodes an interface information object.
+func (o ICMPExtensionObject) InterfaceInfo() (*ICMPInterfaceInfo, error) {
+	if o.Class != ICMPExtensionClassInterfaceInfo {
+		return nil, fmt.Errorf("not an interface information object: %v", o.Class)
+	}
+	info := &ICMPInterfaceInfo{Role: ICMPInterfaceRole(o.CType >> 6)}
+	data := o.Data
+	tooShort := errors.New("interface information object too short")
+	if o.CType&icmpInterfaceInfoIfIndex != 0 {
+		if len(data) < 4 {
+			return nil, tooShort
+		}
+		info.HasIfIndex = true
+		info.IfIndex = binary.BigEndian.Uint32(data)
+		data = data[4:]
+	}
+	if o.CType&icmpInterfaceInfoIP != 0 {
+		if len(data) < 4 {
+			return nil, tooShort
+		}
+		var n int
+		switch afi := binary.BigEndian.Uint16(data); afi {
+		case 1:
+			n = net.IPv4len
+		case 2:
+			n = net.IPv6len
+		default:
+			return nil, fmt.Errorf("unknown interface address family %d", afi)
+		}
+		if len(data) < 4+n {
+			return nil, tooShort
+		}
+		info.IP = net.IP(data[4 : 4+n])
+		data = data[4+n:]
+	}
+	if o.CType&icmpInterfaceInfoName != 0 {
+		// The length of the name sub-object covers its length byte and
+		// the padding of the name to a multiple of 4 bytes.
+		if len(data) < 1 || data[0] == 0 || int(data[0]) > len(data) {
+			return nil, tooShort
+		}
+		name := data[1:data[0]]
+		for len(name) > 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+		info.Name = string(name)
+		data = data[data[0]:]
+	}
+	if o.CType&icmpInterfaceInfoMTU != 0 {
+		if len(data) < 4 {
+			return nil, tooShort
+		}
+		info.HasMTU = true
+		info.MTU = binary.BigEndian.Uint32(data)
+	}
+	return info, nil
+}
@@ -0,0 +1,493 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// IEEE802154FrameType is the type of an IEEE 802.15.4 MAC frame.
+type IEEE802154FrameType uint8
+
+// IEEE 802.15.4 frame types.
+const (
+	IEEE802154FrameTypeBeacon       IEEE802154FrameType = 0
+	IEEE802154FrameTypeData         IEEE802154FrameType = 1
+	IEEE802154FrameTypeAck          IEEE802154FrameType = 2
+	IEEE802154FrameTypeCommand      IEEE802154FrameType = 3
+	IEEE802154FrameTypeMultipurpose IEEE802154FrameType = 5
+	IEEE802154FrameTypeFragment     IEEE802154FrameType = 6
+	IEEE802154FrameTypeExtended     IEEE802154FrameType = 7
+)
+
+func (t IEEE802154FrameType) String() string {
+	switch t {
+	case IEEE802154FrameTypeBeacon:
+		return "Beacon"
+	case IEEE802154FrameTypeData:
+		return "Data"
+	case IEEE802154FrameTypeAck:
+		return "Ack"
+	case IEEE802154FrameTypeCommand:
+		return "Command"
+	case IEEE802154FrameTypeMultipurpose:
+		return "Multipurpose"
+	case IEEE802154FrameTypeFragment:
+		return "Fragment"
+	case IEEE802154FrameTypeExtended:
+		return "Extended"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// IEEE802154AddressMode tells the length of an address of an IEEE 802.15.4
+// frame.
+type IEEE802154AddressMode uint8
+
+// IEEE 802.15.4 address modes.
+const (
+	IEEE802154AddressModeNone     IEEE802154AddressMode = 0
+	IEEE802154AddressModeShort    IEEE802154AddressMode = 2
+	IEEE802154AddressModeExtended IEEE802154AddressMode = 3
+)
+
+func (a IEEE802154AddressMode) String() string {
+	switch a {
+	case IEEE802154AddressModeNone:
+		return "None"
+	case IEEE802154AddressModeShort:
+		return "Short"
+	case IEEE802154AddressModeExtended:
+		return "Extended"
+	}
+	return "Reserved"
+}
+
+// length returns the length of addresses of the mode.
+func (a IEEE802154AddressMode) length() (int, error) {
+	switch a {
+	case IEEE802154AddressModeNone:
+		return 0, nil
+	case IEEE802154AddressModeShort:
+		return 2, nil
+	case IEEE802154AddressModeExtended:
+		return 8, nil
+	}
+	return 0, fmt.Errorf("IEEE802154 address mode %d reserved", uint8(a))
+}
+
+// IEEE802154Broadcast is the short broadcast address and PAN identifier.
+const IEEE802154Broadcast = 0xffff
+
+// IEEE802154Security is the auxiliary security header of the IEEE 802.15.4
+// frames which have their security enabled.
+type IEEE802154Security struct {
+	// Level tells whether the payload is encrypted, if its bit 2 is set,
+	// and the length of its MIC.
+	Level                   uint8
+	KeyIDMode               uint8
+	FrameCounterSuppression bool
+	ASNInNonce              bool
+	FrameCounter            uint32
+	// KeySource is the 4 or 8 byte key source of key identifier modes 2
+	// and 3.
+	KeySource []byte
+	KeyIndex  uint8
+	// MIC is the message integrity code following the payload.
+	MIC []byte
+}
+
+// Encrypted tells whether the payload is encrypted.
+func (s *IEEE802154Security) Encrypted() bool { return s.Level&4 != 0 }
+
+// MICLength returns the length of the MIC of the security level.
+func (s *IEEE802154Security) MICLength() int {
+	if s.Level&3 == 0 {
+		return 0
+	}
+	return 2 << (s.Level & 3)
+}
+
+// keyIdentifierLength returns the length of the key identifier of the key
+// identifier mode.
+func (s *IEEE802154Security) keyIdentifierLength() int {
+	return [4]int{0, 1, 5, 9}[s.KeyIDMode&3]
+}
+
+func (s *IEEE802154Security) length() int {
+	n := 1 + s.keyIdentifierLength()
+	if !s.FrameCounterSuppression {
+		n += 4
+	}
+	return n
+}
+
+// IEEE802154 is an IEEE 802.15.4 MAC frame, the link layer of Zigbee,
+// Thread and 6LoWPAN. Its payload is the frame body, without the MIC of
+// secured frames and without the frame check sequence.
+//
+// The header information elements of frames which have them are left at
+// the start of the payload.
+type IEEE802154 struct {
+	BaseLayer
+	FrameType                 IEEE802154FrameType
+	SecurityEnabled           bool
+	FramePending              bool
+	AckRequest                bool
+	PANIDCompression          bool
+	SequenceNumberSuppression bool
+	IEPresent                 bool
+	DestinationAddressMode    IEEE802154AddressMode
+	FrameVersion              uint8
+	SourceAddressMode         IEEE802154AddressMode
+	SequenceNumber            uint8
+	DestinationPAN            uint16
+	// DestinationAddress and SourceAddress hold the short or extended
+	// addresses their address modes tell.
+	DestinationAddress uint64
+	// SourcePAN is DestinationPAN if the PAN ID compression elides it.
+	SourcePAN     uint16
+	SourceAddress uint64
+	// Security is set if SecurityEnabled is.
+	Security *IEEE802154Security
+	Checksum uint16
+	// NoFCS is set for frames without a frame check sequence, such as
+	// those of LinkTypeIEEE802154NoFCS.
+	NoFCS bool
+}
+
+// LayerType returns LayerTypeIEEE802154.
+func (m *IEEE802154) LayerType() gopacket.LayerType { return LayerTypeIEEE802154 }
+
+// panIDsPresent tells whether the destination and source PAN identifiers
+// are present, as the address modes and the PAN ID compression tell.
+func (m *IEEE802154) panIDsPresent() (dst, src bool) {
+	d := m.DestinationAddressMode != IEEE802154AddressModeNone
+	s := m.SourceAddressMode != IEEE802154AddressModeNone
+	if m.FrameVersion < 2 {
+		return d, s && !m.PANIDCompression
+	}
+	switch {
+	case !d && !s:
+		return m.PANIDCompression, false
+	case !s:
+		return !m.PANIDCompression, false
+	case !d:
+		return false, !m.PANIDCompression
+	case m.DestinationAddressMode == IEEE802154AddressModeExtended && m.SourceAddressMode == IEEE802154AddressModeExtended:
+		return !m.PANIDCompression, false
+	}
+	return true, !m.PANIDCompression
+}
+
+// addressingLength returns the length of the header up to the auxiliary
+// security header.
+func (m *IEEE802154) addressingLength() (int, error) {
+	dstLength, err := m.DestinationAddressMode.length()
+	if err != nil {
+		return 0, err
+	}
+	srcLength, err := m.SourceAddressMode.length()
+	if err != nil {
+		return 0, err
+	}
+	n := 2 + dstLength + srcLength
+	if !m.SequenceNumberSuppression {
+		n++
+	}
+	dstPAN, srcPAN := m.panIDsPresent()
+	if dstPAN {
+		n += 2
+	}
+	if srcPAN {
+		n += 2
+	}
+	return n, nil
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *IEEE802154) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	body := data
+	m.Checksum = 0
+	if !m.NoFCS {
+		if len(data) < 2 {
+			df.SetTruncated()
+			return errors.New("IEEE802154 frame too short")
+		}
+		body = data[:len(data)-2]
+		m.Checksum = binary.LittleEndian.Uint16(data[len(data)-2:])
+	}
+	if len(body) < 2 {
+		df.SetTruncated()
+		return errors.New("IEEE802154 frame too short")
+	}
+	fc := binary.LittleEndian.Uint16(body)
+	m.FrameType = IEEE802154FrameType(fc & 0x7)
+	m.SecurityEnabled = fc&0x0008 != 0
+	m.FramePending = fc&0x0010 != 0
+	m.AckRequest = fc&0x0020 != 0
+	m.PANIDCompression = fc&0x0040 != 0
+	m.SequenceNumberSuppression = fc&0x0100 != 0
+	m.IEPresent = fc&0x0200 != 0
+	m.DestinationAddressMode = IEEE802154AddressMode(fc >> 10 & 0x3)
+	m.FrameVersion = uint8(fc >> 12 & 0x3)
+	m.SourceAddressMode = IEEE802154AddressMode(fc >> 14)
+
+	length, err := m.addressingLength()
+	if err != nil {
+		return err
+	}
+	if len(body) < length {
+		df.SetTruncated()
+		return fmt.Errorf("IEEE802154 header length %d too short", len(body))
+	}
+	offset := 2
+	m.SequenceNumber = 0
+	if !m.SequenceNumberSuppression {
+		m.SequenceNumber = body[offset]
+		offset++
+	}
+	dstPAN, srcPAN := m.panIDsPresent()
+	m.DestinationPAN = 0
+	if dstPAN {
+		m.DestinationPAN = binary.LittleEndian.Uint16(body[offset:])
+		offset += 2
+	}
+	m.DestinationAddress, offset = decodeIEEE802154Address(body, offset, m.DestinationAddressMode)
+	m.SourcePAN = m.DestinationPAN
+	if srcPAN {
+		m.SourcePAN = binary.LittleEndian.Uint16(body[offset:])
+		offset += 2
+	}
+	m.SourceAddress, offset = decodeIEEE802154Address(body, offset, m.SourceAddressMode)
+
+	m.Security = nil
+	if m.SecurityEnabled {
+		if len(body) < offset+1 {
+			df.SetTruncated()
+			return errors.New("IEEE802154 auxiliary security header missing")
+		}
+		sc := body[offset]
+		s := &IEEE802154Security{
+			Level:                   sc & 0x7,
+			KeyIDMode:               sc >> 3 & 0x3,
+			FrameCounterSuppression: sc&0x20 != 0,
+			ASNInNonce:              sc&0x40 != 0,
+		}
+		if len(body) < offset+s.length()+s.MICLength() {
+			df.SetTruncated()
+			return errors.New("IEEE802154 auxiliary security header too short")
+		}
+		offset++
+		if !s.FrameCounterSuppression {
+			s.FrameCounter = binary.LittleEndian.Uint32(body[offset:])
+			offset += 4
+		}
+		if n := s.keyIdentifierLength(); n > 1 {
+			s.KeySource = body[offset : offset+n-1]
+			offset += n - 1
+		}
+		if s.KeyIDMode != 0 {
+			s.KeyIndex = body[offset]
+			offset++
+		}
+		s.MIC = body[len(body)-s.MICLength():]
+		body = body[:len(body)-s.MICLength()]
+		m.Security = s
+	}
+	m.BaseLayer = BaseLayer{Contents: body[:offset], Payload: body[offset:]}
+	return nil
+}
+
+// decodeIEEE802154Address decodes the address of the mode at data[offset:],
+// returning the offset following it.
+func decodeIEEE802154Address(data []byte, offset int, mode IEEE802154AddressMode) (uint64, int) {
+	switch mode {
+	case IEEE802154AddressModeShort:
+		return uint64(binary.LittleEndian.Uint16(data[offset:])), offset + 2
+	case IEEE802154AddressModeExtended:
+		return binary.LittleEndian.Uint64(data[offset:]), offset + 8
+	}
+	return 0, offset
+}
+
+// ChecksumValid tells whether the frame check sequence of the frame
+// matches its contents.
+func (m *IEEE802154) ChecksumValid() bool {
+	crc := ieee802154CRC(0, m.Contents)
+	crc = ieee802154CRC(crc, m.Payload)
+	if m.Security != nil {
+		crc = ieee802154CRC(crc, m.Security.MIC)
+	}
+	return m.Checksum == crc
+}
+
+// ieee802154CRC updates crc, the CRC-16 of the ITU-T in its bit reversed
+// form, with data.
+func ieee802154CRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer. The MIC of
+// secured frames is taken from Security, and the frame check sequence is
+// computed if opts.ComputeChecksums is set, or else taken from Checksum.
+func (m *IEEE802154) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if m.SecurityEnabled && m.Security == nil {
+		return errors.New("IEEE802154 security enabled without a security header")
+	}
+	length, err := m.addressingLength()
+	if err != nil {
+		return err
+	}
+	if m.SecurityEnabled {
+		if m.Security.KeyIDMode > 1 && len(m.Security.KeySource) != m.Security.keyIdentifierLength()-1 {
+			return fmt.Errorf("IEEE802154 key source length %d invalid for key identifier mode %d", len(m.Security.KeySource), m.Security.KeyIDMode)
+		}
+		length += m.Security.length()
+		if len(m.Security.MIC) > 0 {
+			mic, err := b.AppendBytes(len(m.Security.MIC))
+			if err != nil {
+				return err
+			}
+			copy(mic, m.Security.MIC)
+		}
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	fc := uint16(m.FrameType&0x7) | uint16(m.DestinationAddressMode&0x3)<<10 | uint16(m.FrameVersion&0x3)<<12 | uint16(m.SourceAddressMode&0x3)<<14
+	for _, f := range []struct {
+		set  bool
+		mask uint16
+	}{
+		{m.SecurityEnabled, 0x0008},
+		{m.FramePending, 0x0010},
+		{m.AckRequest, 0x0020},
+		{m.PANIDCompression, 0x0040},
+		{m.SequenceNumberSuppression, 0x0100},
+		{m.IEPresent, 0x0200},
+	} {
+		if f.set {
+			fc |= f.mask
+		}
+	}
+	binary.LittleEndian.PutUint16(bytes, fc)
+	offset := 2
+	if !m.SequenceNumberSuppression {
+		bytes[offset] = m.SequenceNumber
+		offset++
+	}
+	dstPAN, srcPAN := m.panIDsPresent()
+	if dstPAN {
+		binary.LittleEndian.PutUint16(bytes[offset:], m.DestinationPAN)
+		offset += 2
+	}
+	offset = encodeIEEE802154Address(bytes, offset, m.DestinationAddressMode, m.DestinationAddress)
+	if srcPAN {
+		binary.LittleEndian.PutUint16(bytes[offset:], m.SourcePAN)
+		offset += 2
+	}
+	offset = encodeIEEE802154Address(bytes, offset, m.SourceAddressMode, m.SourceAddress)
+	if m.SecurityEnabled {
+		s := m.Security
+		sc := s.Level&0x7 | (s.KeyIDMode&0x3)<<3
+		if s.FrameCounterSuppression {
+			sc |= 0x20
+		}
+		if s.ASNInNonce {
+			sc |= 0x40
+		}
+		bytes[offset] = sc
+		offset++
+		if !s.FrameCounterSuppression {
+			binary.LittleEndian.PutUint32(bytes[offset:], s.FrameCounter)
+			offset += 4
+		}
+		if s.KeyIDMode > 1 {
+			offset += copy(bytes[offset:], s.KeySource)
+		}
+		if s.KeyIDMode != 0 {
+			bytes[offset] = s.KeyIndex
+		}
+	}
+
+	if m.NoFCS {
+		return nil
+	}
+	fcs, err := b.AppendBytes(2)
+	if err != nil {
+		return err
+	}
+	checksum := m.Checksum
+	if opts.ComputeChecksums {
+		frame := b.Bytes()
+		checksum = ieee802154CRC(0, frame[:len(frame)-2])
+	}
+	binary.LittleEndian.PutUint16(fcs, checksum)
+	return nil
+}
+
+// encodeIEEE802154Address encodes the address of the mode at
+// data[offset:], returning the offset following it.
+func encodeIEEE802154Address(data []byte, offset int, mode IEEE802154AddressMode, address uint64) int {
+	switch mode {
+	case IEEE802154AddressModeShort:
+		binary.LittleEndian.PutUint16(data[offset:], uint16(address))
+		return offset + 2
+	case IEEE802154AddressModeExtended:
+		binary.LittleEndian.PutUint64(data[offset:], address)
+		return offset + 8
+	}
+	return offset
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *IEEE802154) CanDecode() gopacket.LayerClass {
+	return LayerTypeIEEE802154
+}
+
+// NextLayerType returns LayerTypeZigbeeNWK for data frames which carry a
+// Zigbee network frame in the clear, and gopacket.LayerTypePayload for the
+// others which have a payload.
+func (m *IEEE802154) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	if m.FrameType != IEEE802154FrameTypeData || m.IEPresent || (m.Security != nil && m.Security.Encrypted()) {
+		return gopacket.LayerTypePayload
+	}
+	if isZigbeeNWK(m.Payload) {
+		return LayerTypeZigbeeNWK
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeIEEE802154(data []byte, p gopacket.PacketBuilder) error {
+	m := &IEEE802154{}
+	return decodingLayerDecoder(m, data, p)
+}
+
+func decodeIEEE802154NoFCS(data []byte, p gopacket.PacketBuilder) error {
+	m := &IEEE802154{NoFCS: true}
+	return decodingLayerDecoder(m, data, p)
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A Zigbee ZCL Read Attributes command of the On/Off cluster, in the clear,
+// without its frame check sequence.
+var testPacketZigbeeData = []byte{
+	0x61, 0x88, 0x42, 0x62, 0x1a, 0x00, 0x00, 0x34, 0x12, // 802.15.4
+	0x48, 0x00, 0x00, 0x00, 0x34, 0x12, 0x1e, 0x07, // NWK
+	0x40, 0x01, 0x06, 0x00, 0x04, 0x01, 0x01, 0x10, // APS
+	0x00, 0x05, 0x00, 0x00, 0x00, // ZCL
+}
+
+// A Zigbee frame secured with the network key, without its frame check
+// sequence.
+var testPacketZigbeeSecured = []byte{
+	0x61, 0x88, 0x43, 0x62, 0x1a, 0x00, 0x00, 0x34, 0x12, // 802.15.4
+	0x48, 0x02, 0x00, 0x00, 0x34, 0x12, 0x1e, 0x08, // NWK
+	0x28, 0x01, 0x00, 0x00, 0x00, // security control, frame counter
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // source
+	0x00,                   // key sequence number
+	0xde, 0xad, 0xbe, 0xef, // encrypted payload
+	0x11, 0x22, 0x33, 0x44, // MIC
+}
+
+func withFCS(frame []byte) []byte {
+	fcs := ieee802154CRC(0, frame)
+	return append(append([]byte(nil), frame...), byte(fcs), byte(fcs>>8))
+}
+
+func TestIEEE802154CRC(t *testing.T) {
+	if got := ieee802154CRC(0, []byte("123456789")); got != 0x2189 {
+		t.Errorf("CRC %#04x, want 0x2189", got)
+	}
+}
+
+func TestZigbeeData(t *testing.T) {
+	data := withFCS(testPacketZigbeeData)
+	p := gopacket.NewPacket(data, LinkTypeIEEE802154, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIEEE802154, LayerTypeZigbeeNWK, LayerTypeZigbeeAPS, gopacket.LayerTypePayload}, t)
+
+	mac := p.Layer(LayerTypeIEEE802154).(*IEEE802154)
+	if mac.FrameType != IEEE802154FrameTypeData || !mac.AckRequest || !mac.PANIDCompression || mac.SequenceNumber != 0x42 ||
+		mac.DestinationPAN != 0x1a62 || mac.SourcePAN != 0x1a62 || mac.DestinationAddress != 0 || mac.SourceAddress != 0x1234 ||
+		mac.SourceAddressMode != IEEE802154AddressModeShort || !mac.ChecksumValid() {
+		t.Errorf("got %+v", mac)
+	}
+	nwk := p.Layer(LayerTypeZigbeeNWK).(*ZigbeeNWK)
+	if nwk.FrameType != ZigbeeNWKFrameTypeData || nwk.ProtocolVersion != 2 || nwk.DiscoverRoute != 1 ||
+		nwk.Source != 0x1234 || nwk.Radius != 30 || nwk.SequenceNumber != 7 {
+		t.Errorf("got %+v", nwk)
+	}
+	aps := p.Layer(LayerTypeZigbeeAPS).(*ZigbeeAPS)
+	if aps.FrameType != ZigbeeAPSFrameTypeData || !aps.AckRequest || aps.DestinationEndpoint != 1 || aps.ClusterID != 0x0006 ||
+		aps.ProfileID != 0x0104 || aps.SourceEndpoint != 1 || aps.Counter != 0x10 || len(aps.Payload) != 5 {
+		t.Errorf("got %+v", aps)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, mac, nwk, aps, gopacket.Payload(aps.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data)
+	}
+
+	p = gopacket.NewPacket(testPacketZigbeeData, LinkTypeIEEE802154NoFCS, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeIEEE802154, LayerTypeZigbeeNWK, LayerTypeZigbeeAPS, gopacket.LayerTypePayload}, t)
+
+	data[len(data)-1] ^= 1
+	p = gopacket.NewPacket(data, LinkTypeIEEE802154, gopacket.Default)
+	if p.Layer(LayerTypeIEEE802154).(*IEEE802154).ChecksumValid() {
+		t.Error("corrupted checksum valid")
+	}
+	p = gopacket.NewPacket(data[:15], LinkTypeIEEE802154, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated NWK header")
+	}
+}
+
+func TestZigbeeSecured(t *testing.T) {
+	data := withFCS(testPacketZigbeeSecured)
+	p := gopacket.NewPacket(data, LinkTypeIEEE802154, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIEEE802154, LayerTypeZigbeeNWK, gopacket.LayerTypePayload}, t)
+	nwk := p.Layer(LayerTypeZigbeeNWK).(*ZigbeeNWK)
+	s := nwk.SecurityHeader
+	if !nwk.Security || s == nil || s.KeyID != ZigbeeKeyIDNetwork || !s.ExtendedNonce || s.FrameCounter != 1 ||
+		s.Source != 0x0807060504030201 || s.KeySequenceNumber != 0 {
+		t.Fatalf("got %+v, security header %+v", nwk, s)
+	}
+	if !bytes.Equal(nwk.Payload, []byte{0xde, 0xad, 0xbe, 0xef}) || !bytes.Equal(nwk.MIC, []byte{0x11, 0x22, 0x33, 0x44}) {
+		t.Errorf("payload %x, MIC %x", nwk.Payload, nwk.MIC)
+	}
+
+	mac := p.Layer(LayerTypeIEEE802154).(*IEEE802154)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, mac, nwk, gopacket.Payload(nwk.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data)
+	}
+}
+
+func TestIEEE802154Security(t *testing.T) {
+	// A data frame of the 2015 revision, between extended addresses with
+	// the PAN ID compressed, encrypted with a 4 byte MIC and a key index.
+	frame := []byte{
+		0x69, 0xec, 0x01, // frame control, sequence number
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // destination
+		0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, // source
+		0x0d, 0x05, 0x00, 0x00, 0x00, 0x02, // security control, frame counter, key index
+		0xaa, 0xbb, // encrypted payload
+		0xc1, 0xc2, 0xc3, 0xc4, // MIC
+	}
+	m := &IEEE802154{NoFCS: true}
+	if err := m.DecodeFromBytes(frame, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if m.FrameVersion != 2 || m.DestinationAddressMode != IEEE802154AddressModeExtended || m.DestinationPAN != 0 ||
+		m.DestinationAddress != 0x0807060504030201 || m.SourceAddress != 0x1817161514131211 {
+		t.Errorf("got %+v", m)
+	}
+	s := m.Security
+	if s == nil || s.Level != 5 || !s.Encrypted() || s.MICLength() != 4 || s.KeyIDMode != 1 || s.KeyIndex != 2 || s.FrameCounter != 5 ||
+		!bytes.Equal(s.MIC, []byte{0xc1, 0xc2, 0xc3, 0xc4}) || !bytes.Equal(m.Payload, []byte{0xaa, 0xbb}) {
+		t.Errorf("got %+v, payload %x", s, m.Payload)
+	}
+	if m.NextLayerType() != gopacket.LayerTypePayload {
+		t.Errorf("next layer type %v", m.NextLayerType())
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, m, gopacket.Payload(m.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), frame) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), frame)
+	}
+
+	if err := m.DecodeFromBytes(frame[:22], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated security header")
+	}
+	frame[1] = 0x44 // the reserved destination address mode
+	if err := m.DecodeFromBytes(frame, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding reserved address mode")
+	}
+}
+
+func TestZigbeeAPSAck(t *testing.T) {
+	// An ack of the third block of a fragmented frame.
+	frame := []byte{0x82, 0x01, 0x06, 0x00, 0x04, 0x01, 0x01, 0x10, 0x01, 0x02, 0xff}
+	aps := &ZigbeeAPS{}
+	if err := aps.DecodeFromBytes(frame, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if aps.FrameType != ZigbeeAPSFrameTypeAck || !aps.ExtendedHeader || aps.ClusterID != 0x0006 || aps.Counter != 0x10 ||
+		aps.Fragmentation != 1 || aps.BlockNumber != 2 || aps.AckBitfield != 0xff || len(aps.Payload) != 0 {
+		t.Errorf("got %+v", aps)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := aps.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), frame) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), frame)
+	}
+	if err := aps.DecodeFromBytes(frame[:10], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated extended header")
+	}
+
+	// A group data frame.
+	frame = []byte{0x0c, 0x01, 0x00, 0x06, 0x00, 0x04, 0x01, 0x01, 0x11, 0x01}
+	if err := aps.DecodeFromBytes(frame, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if aps.DeliveryMode != ZigbeeAPSDeliveryModeGroup || aps.GroupAddress != 1 || aps.ClusterID != 0x0006 ||
+		aps.SourceEndpoint != 1 || aps.Counter != 0x11 || !bytes.Equal(aps.Payload, []byte{0x01}) {
+		t.Errorf("got %+v", aps)
+	}
+}
@@ -10,6 +10,7 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
 	"time"
 
@@ -168,6 +169,65 @@ type IGMPv3GroupRecord struct {
 	AuxData          uint32 // NOT USED
 }
 
+// NewIGMPv3GroupRecord returns a group record of the given type for group.
+// The source list is copied with duplicate addresses removed, preserving the
+// order of first appearance, and NumberOfSources is set to match it.
+func NewIGMPv3GroupRecord(typ IGMPv3GroupRecordType, group net.IP, sources []net.IP) IGMPv3GroupRecord {
+	gr := IGMPv3GroupRecord{
+		Type:             typ,
+		MulticastAddress: group.To4(),
+	}
+	seen := make(map[[4]byte]bool, len(sources))
+	for _, src := range sources {
+		src4 := src.To4()
+		if src4 == nil {
+			// Keep the bogus address around so Validate can complain.
+			gr.SourceAddresses = append(gr.SourceAddresses, src)
+			continue
+		}
+		var k [4]byte
+		copy(k[:], src4)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		gr.SourceAddresses = append(gr.SourceAddresses, src4)
+	}
+	gr.NumberOfSources = uint16(len(gr.SourceAddresses))
+	return gr
+}
+
+// Validate checks a group record against the rules of RFC 3376 section 4.2:
+// the record type must be known, the multicast address must be an IPv4
+// multicast group, every source must be a distinct IPv4 unicast address and
+// NumberOfSources must match the source list.
+func (gr *IGMPv3GroupRecord) Validate() error {
+	if gr.Type < IGMPIsIn || gr.Type > IGMPBlock {
+		return fmt.Errorf("unknown IGMPv3 group record type %d", gr.Type)
+	}
+	group := gr.MulticastAddress.To4()
+	if group == nil || !group.IsMulticast() {
+		return fmt.Errorf("IGMPv3 group record address %v is not an IPv4 multicast group", gr.MulticastAddress)
+	}
+	if int(gr.NumberOfSources) != len(gr.SourceAddresses) {
+		return fmt.Errorf("IGMPv3 group record for %v claims %d sources but lists %d", group, gr.NumberOfSources, len(gr.SourceAddresses))
+	}
+	seen := make(map[[4]byte]bool, len(gr.SourceAddresses))
+	for _, src := range gr.SourceAddresses {
+		src4 := src.To4()
+		if src4 == nil || src4.IsMulticast() || src4.IsUnspecified() || src4.Equal(net.IPv4bcast) {
+			return fmt.Errorf("IGMPv3 group record for %v has invalid source %v", group, src)
+		}
+		var k [4]byte
+		copy(k[:], src4)
+		if seen[k] {
+			return fmt.Errorf("IGMPv3 group record for %v lists source %v twice", group, src4)
+		}
+		seen[k] = true
+	}
+	return nil
+}
+
 func (i *IGMP) decodeIGMPv3MembershipReport(data []byte) error {
 	if len(data) < 8 {
 		return errors.New("IGMPv3 Membership Report too small #1")
@@ -251,9 +311,24 @@ func igmpTimeDecode(t uint8) time.Duration {
 	if t&0x80 == 0 {
 		return time.Millisecond * 100 * time.Duration(t)
 	}
-	mant := (t & 0x70) >> 4
-	exp := t & 0x0F
-	return time.Millisecond * 100 * time.Duration((mant|0x10)<<(exp+3))
+	exp := (t & 0x70) >> 4
+	mant := t & 0x0F
+	return time.Millisecond * 100 * time.Duration(mant|0x10) << (exp + 3)
+}
+
+// igmpTimeEncode is the inverse of igmpTimeDecode. Durations too long to be
+// represented are clamped to the largest encodable value.
+func igmpTimeEncode(d time.Duration) uint8 {
+	v := d / (100 * time.Millisecond)
+	if v < 128 {
+		return uint8(v)
+	}
+	for exp := uint8(0); exp < 8; exp++ {
+		if mant := v >> (exp + 3); mant < 0x20 {
+			return 0x80 | exp<<4 | uint8(mant&0x0F)
+		}
+	}
+	return 0xff
 }
 
 // LayerType returns LayerTypeIGMP for the V1,2,3 message protocol formats.
@@ -289,6 +364,8 @@ func (i *IGMP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 
 	// common IGMP header values between versions 1..3 of IGMP specification..
 	i.Type = IGMPType(data[0])
+	i.SourceAddresses = i.SourceAddresses[:0]
+	i.GroupRecords = i.GroupRecords[:0]
 
 	switch i.Type {
 	case IGMPMembershipQuery:
@@ -302,6 +379,78 @@ func (i *IGMP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	return nil
 }
 
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Only IGMPv3 Membership Queries and Membership Reports can be serialized.
+func (i *IGMP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var bytes []byte
+	var err error
+	switch i.Type {
+	case IGMPMembershipQuery:
+		if opts.FixLengths {
+			i.NumberOfSources = uint16(len(i.SourceAddresses))
+		}
+		bytes, err = b.PrependBytes(12 + 4*len(i.SourceAddresses))
+		if err != nil {
+			return err
+		}
+		bytes[1] = igmpTimeEncode(i.MaxResponseTime)
+		copy(bytes[4:8], i.GroupAddress.To4())
+		bytes[8] = i.RobustnessValue & 0x7
+		if i.SupressRouterProcessing {
+			bytes[8] |= 0x8
+		}
+		bytes[9] = igmpTimeEncode(i.IntervalTime)
+		binary.BigEndian.PutUint16(bytes[10:], i.NumberOfSources)
+		for j, src := range i.SourceAddresses {
+			copy(bytes[12+j*4:16+j*4], src.To4())
+		}
+	case IGMPMembershipReportV3:
+		length := 8
+		for _, gr := range i.GroupRecords {
+			length += 8 + 4*len(gr.SourceAddresses)
+		}
+		if opts.FixLengths {
+			i.NumberOfGroupRecords = uint16(len(i.GroupRecords))
+		}
+		bytes, err = b.PrependBytes(length)
+		if err != nil {
+			return err
+		}
+		bytes[1] = 0
+		binary.BigEndian.PutUint16(bytes[4:], 0)
+		binary.BigEndian.PutUint16(bytes[6:], i.NumberOfGroupRecords)
+		off := 8
+		for j := range i.GroupRecords {
+			gr := &i.GroupRecords[j]
+			if opts.FixLengths {
+				gr.NumberOfSources = uint16(len(gr.SourceAddresses))
+				gr.AuxDataLen = 0
+			}
+			bytes[off] = byte(gr.Type)
+			bytes[off+1] = gr.AuxDataLen
+			binary.BigEndian.PutUint16(bytes[off+2:], gr.NumberOfSources)
+			copy(bytes[off+4:off+8], gr.MulticastAddress.To4())
+			off += 8
+			for _, src := range gr.SourceAddresses {
+				copy(bytes[off:off+4], src.To4())
+				off += 4
+			}
+		}
+	default:
+		return fmt.Errorf("serialization of IGMP type %v not supported", i.Type)
+	}
+	bytes[0] = byte(i.Type)
+	if opts.ComputeChecksums {
+		bytes[2], bytes[3] = 0, 0
+		i.Checksum = tcpipChecksum(bytes, 0)
+	}
+	binary.BigEndian.PutUint16(bytes[2:], i.Checksum)
+	return nil
+}
+
 // CanDecode returns the set of layer types that this DecodingLayer can decode.
 func (i *IGMP) CanDecode() gopacket.LayerClass {
 	return LayerTypeIGMP
@@ -7,6 +7,8 @@
 package layers
 
 import (
+	"bytes"
+	"net"
 	"testing"
 
 	"github.com/google/gopacket"
@@ -169,3 +171,47 @@ func BenchmarkDecodeigmpv3MembershipReport2Records(b *testing.B) {
 		gopacket.NewPacket(igmpv3MembershipReport2Records, LinkTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestIGMPv3SerializeRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{igmp3v3MembershipQueryPacket, igmpv3MembershipReport2Records} {
+		p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+		igmp := p.Layer(LayerTypeIGMP).(*IGMP)
+		buf := gopacket.NewSerializeBuffer()
+		if err := igmp.SerializeTo(buf, gopacket.SerializeOptions{ComputeChecksums: true}); err != nil {
+			t.Fatal(err)
+		}
+		want := p.Layer(LayerTypeIPv4).LayerPayload()[:len(buf.Bytes())]
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("serialized IGMP mismatch:\ngot  %x\nwant %x", buf.Bytes(), want)
+		}
+	}
+}
+
+func TestIGMPv3GroupRecordValidate(t *testing.T) {
+	src := net.IPv4(10, 1, 1, 1)
+	gr := NewIGMPv3GroupRecord(IGMPIsIn, net.IPv4(232, 1, 1, 1), []net.IP{src, src, net.IPv4(10, 1, 1, 2)})
+	if gr.NumberOfSources != 2 {
+		t.Errorf("duplicate source not removed: %v", gr.SourceAddresses)
+	}
+	if err := gr.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	gr.SourceAddresses = append(gr.SourceAddresses, src)
+	gr.NumberOfSources++
+	if err := gr.Validate(); err == nil {
+		t.Error("expected duplicate source error")
+	}
+	gr = NewIGMPv3GroupRecord(IGMPAllow, net.IPv4(10, 1, 1, 1), nil)
+	if err := gr.Validate(); err == nil {
+		t.Error("expected error for unicast group")
+	}
+}
+
+func TestIGMPTimeEncode(t *testing.T) {
+	for v := 0; v < 256; v++ {
+		d := igmpTimeDecode(uint8(v))
+		if got := igmpTimeEncode(d); got != uint8(v) {
+			t.Errorf("encode(%v) = %#x, want %#x", d, got, v)
+		}
+	}
+}
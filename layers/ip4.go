@@ -76,8 +76,8 @@ func (i IPv4Option) String() string {
 
 // for the current ipv4 options, return the number of bytes (including
 // padding that the options used)
-func (ip *IPv4) getIPv4OptionSize() uint8 {
-	optionSize := uint8(0)
+func (ip *IPv4) getIPv4OptionSize() int {
+	optionSize := 0
 	for _, opt := range ip.Options {
 		switch opt.OptionType {
 		case 0:
@@ -87,7 +87,7 @@ func (ip *IPv4) getIPv4OptionSize() uint8 {
 			// this is the padding
 			optionSize++
 		default:
-			optionSize += opt.OptionLength
+			optionSize += int(opt.OptionLength)
 
 		}
 	}
@@ -100,14 +100,31 @@ func (ip *IPv4) getIPv4OptionSize() uint8 {
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
+//
+// The options are padded with zeros to a multiple of 4 bytes. If
+// opts.FixLengths is set, the OptionLength of each option is set from its
+// data, and IHL and Length are computed; IHL is also computed if it is
+// zero, which is never valid.
 func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if opts.FixLengths {
+		for i, opt := range ip.Options {
+			if opt.OptionType > 1 {
+				ip.Options[i].OptionLength = uint8(len(opt.OptionData) + 2)
+			}
+		}
+	}
 	optionLength := ip.getIPv4OptionSize()
-	bytes, err := b.PrependBytes(20 + int(optionLength))
+	if optionLength > MaxIPv4OptionLength {
+		return fmt.Errorf("IPv4 options too long: %d bytes", optionLength)
+	}
+	bytes, err := b.PrependBytes(20 + optionLength)
 	if err != nil {
 		return err
 	}
+	if opts.FixLengths || ip.IHL == 0 {
+		ip.IHL = 5 + uint8(optionLength/4)
+	}
 	if opts.FixLengths {
-		ip.IHL = 5 + (optionLength / 4)
 		ip.Length = uint16(len(b.Bytes()))
 	}
 	bytes[0] = (ip.Version << 4) | ip.IHL
@@ -123,6 +140,9 @@ func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeO
 	copy(bytes[12:16], ip.SrcIP)
 	copy(bytes[16:20], ip.DstIP)
 
+	// Zero the options, so that the bytes of options longer than their
+	// data and the padding are zeros.
+	copy(bytes[20:], lotsOfZeros[:optionLength])
 	curLocation := 20
 	// Now, we will encode the options
 	for _, opt := range ip.Options {
@@ -140,7 +160,7 @@ func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeO
 			bytes[curLocation+1] = opt.OptionLength
 
 			// sanity checking to protect us from buffer overrun
-			if len(opt.OptionData) > int(opt.OptionLength-2) {
+			if opt.OptionLength < 2 || len(opt.OptionData) > int(opt.OptionLength-2) {
 				return errors.New("option length is smaller than length of option data")
 			}
 			copy(bytes[curLocation+2:curLocation+int(opt.OptionLength)], opt.OptionData)
@@ -155,6 +175,15 @@ func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeO
 	return nil
 }
 
+// VerifyChecksum verifies the header checksum of the decoded packet.
+func (ip *IPv4) VerifyChecksum() gopacket.ChecksumResult {
+	header := append([]byte(nil), ip.Contents...)
+	if len(header) < 20 {
+		return gopacket.ChecksumResult{LayerType: LayerTypeIPv4, Status: gopacket.ChecksumUnverifiable, Checksum: uint32(ip.Checksum), Err: errors.New("IPv4 header too short")}
+	}
+	return checksumResult(LayerTypeIPv4, uint32(ip.Checksum), uint32(checksum(header)))
+}
+
 func checksum(bytes []byte) uint16 {
 	// Clear checksum bytes
 	bytes[10] = 0
@@ -293,7 +322,11 @@ func decodeIPv4(data []byte, p gopacket.PacketBuilder) error {
 	if err != nil {
 		return err
 	}
-	return p.NextDecoder(ip.NextLayerType())
+	next := ip.NextLayerType()
+	if next == LayerTypeIPv6 {
+		setIPv6Tunnel(ip, p)
+	}
+	return p.NextDecoder(next)
 }
 
 func checkIPv4Address(addr net.IP) (net.IP, error) {
@@ -222,3 +222,50 @@ func TestIPv4Options(t *testing.T) {
 		}
 	}
 }
+
+func TestIPv4SerializeTruncated(t *testing.T) {
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 1000, DstPort: 2000}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, gopacket.Payload(bytes.Repeat([]byte{0xff}, 100))); err != nil {
+		t.Fatal(err)
+	}
+	full := buf.Bytes()
+	p := gopacket.NewPacket(full[:50], LayerTypeIPv4, gopacket.Default)
+	p.Metadata().CaptureInfo = gopacket.CaptureInfo{CaptureLength: 50, Length: len(full)}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	for _, test := range []struct {
+		truncation       gopacket.Truncation
+		ipLen, udpLen    uint16
+		length, zeroFrom int
+	}{
+		{gopacket.TruncationIgnore, 128, 108, 50, 50},
+		{gopacket.TruncationPad, 128, 108, 128, 50},
+		{gopacket.TruncationFixLengths, 50, 30, 50, 50},
+	} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializePacket(buf, gopacket.SerializeOptions{Truncation: test.truncation}, p); err != nil {
+			t.Errorf("truncation %d: %v", test.truncation, err)
+			continue
+		}
+		got := buf.Bytes()
+		if len(got) != test.length {
+			t.Errorf("truncation %d: got %d bytes, want %d", test.truncation, len(got), test.length)
+			continue
+		}
+		if l := binary.BigEndian.Uint16(got[2:]); l != test.ipLen {
+			t.Errorf("truncation %d: got IPv4 length %d, want %d", test.truncation, l, test.ipLen)
+		}
+		if l := binary.BigEndian.Uint16(got[24:]); l != test.udpLen {
+			t.Errorf("truncation %d: got UDP length %d, want %d", test.truncation, l, test.udpLen)
+		}
+		if !bytes.Equal(got[28:50], full[28:50]) || !bytes.Equal(got[test.zeroFrom:], make([]byte, test.length-test.zeroFrom)) {
+			t.Errorf("truncation %d: got payload %x", test.truncation, got[28:])
+		}
+	}
+	if err := gopacket.SerializePacket(buf, gopacket.SerializeOptions{Truncation: gopacket.TruncationError}, p); err == nil {
+		t.Error("serialized a truncated packet")
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// IPv4 option types, including the copied flag and class.
+const (
+	IPv4OptionEndList           = 0
+	IPv4OptionNOP               = 1
+	IPv4OptionRecordRoute       = 7
+	IPv4OptionTimestamp         = 68
+	IPv4OptionLooseSourceRoute  = 131
+	IPv4OptionStrictSourceRoute = 137
+	IPv4OptionRouterAlert       = 148
+)
+
+// MaxIPv4OptionLength is the number of bytes of options which fit in an
+// IPv4 header.
+const MaxIPv4OptionLength = 40
+
+// IPv4OptionValue is a decoded IPv4 option: an *IPv4RouteOption,
+// *IPv4TimestampOption or *IPv4RouterAlertOption.
+type IPv4OptionValue interface {
+	// Option encodes the option.
+	Option() IPv4Option
+}
+
+// DecodeIPv4Option decodes an IPv4 option. It returns an error if o is not
+// of a supported type, or is malformed.
+func DecodeIPv4Option(o IPv4Option) (IPv4OptionValue, error) {
+	var v interface {
+		IPv4OptionValue
+		decode(typ uint8, data []byte) error
+	}
+	switch o.OptionType {
+	case IPv4OptionRecordRoute, IPv4OptionLooseSourceRoute, IPv4OptionStrictSourceRoute:
+		v = &IPv4RouteOption{}
+	case IPv4OptionTimestamp:
+		v = &IPv4TimestampOption{}
+	case IPv4OptionRouterAlert:
+		v = &IPv4RouterAlertOption{}
+	default:
+		return nil, fmt.Errorf("unsupported IPv4 option %d", o.OptionType)
+	}
+	if err := v.decode(o.OptionType, o.OptionData); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OptionValues returns the valid options of the header, of the types
+// supported by DecodeIPv4Option.
+func (ip *IPv4) OptionValues() []IPv4OptionValue {
+	var values []IPv4OptionValue
+	for _, o := range ip.Options {
+		if v, err := DecodeIPv4Option(o); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func newIPv4Option(typ uint8, data []byte) IPv4Option {
+	return IPv4Option{OptionType: typ, OptionLength: uint8(2 + len(data)), OptionData: data}
+}
+
+// IPv4RouteOption is a record route, loose source route or strict source
+// route option (RFC 791). Pointer is the offset in the option, from 1, of
+// the next address to record or route through, 4 being the first one and
+// anything past the last address meaning that the route is complete.
+type IPv4RouteOption struct {
+	// Type is IPv4OptionRecordRoute, IPv4OptionLooseSourceRoute or
+	// IPv4OptionStrictSourceRoute.
+	Type    uint8
+	Pointer uint8
+	// Addresses holds the route. When recording a route, the sender
+	// reserves room for the addresses with zero addresses.
+	Addresses []net.IP
+}
+
+func (o *IPv4RouteOption) decode(typ uint8, data []byte) error {
+	if len(data) < 1 || (len(data)-1)%4 != 0 {
+		return fmt.Errorf("invalid IPv4 route option length %d", len(data)+2)
+	}
+	if data[0] < 4 {
+		return fmt.Errorf("invalid IPv4 route option pointer %d", data[0])
+	}
+	*o = IPv4RouteOption{Type: typ, Pointer: data[0], Addresses: make([]net.IP, (len(data)-1)/4)}
+	for i := range o.Addresses {
+		o.Addresses[i] = net.IP(data[1+4*i : 5+4*i])
+	}
+	return nil
+}
+
+// Done returns the addresses already recorded or routed through.
+func (o *IPv4RouteOption) Done() []net.IP {
+	n := (int(o.Pointer) - 4) / 4
+	if n < 0 {
+		return nil
+	} else if n > len(o.Addresses) {
+		n = len(o.Addresses)
+	}
+	return o.Addresses[:n]
+}
+
+// Option encodes the option, with a Pointer of 4 if it is zero.
+func (o *IPv4RouteOption) Option() IPv4Option {
+	data := make([]byte, 1+4*len(o.Addresses))
+	data[0] = o.Pointer
+	if data[0] == 0 {
+		data[0] = 4
+	}
+	for i, a := range o.Addresses {
+		copy(data[1+4*i:], a.To4())
+	}
+	return newIPv4Option(o.Type, data)
+}
+
+// IPv4TimestampFlag tells what an IPv4 timestamp option records.
+type IPv4TimestampFlag uint8
+
+// IPv4 timestamp option flags.
+const (
+	// IPv4TimestampOnly records timestamps only.
+	IPv4TimestampOnly IPv4TimestampFlag = 0
+	// IPv4TimestampAndAddress records the address of each router with its
+	// timestamp.
+	IPv4TimestampAndAddress IPv4TimestampFlag = 1
+	// IPv4TimestampPrespecified records the timestamps of the routers
+	// whose addresses the sender filled in.
+	IPv4TimestampPrespecified IPv4TimestampFlag = 3
+)
+
+// IPv4TimestampEntry is an entry of an IPv4 timestamp option. Address is
+// nil if the option records timestamps only.
+type IPv4TimestampEntry struct {
+	Address   net.IP
+	Timestamp uint32
+}
+
+// IPv4TimestampOption is an internet timestamp option (RFC 791). Pointer
+// is the offset in the option, from 1, of the next entry to fill in, 5
+// being the first one, and Overflow counts the routers which could not
+// record a timestamp for lack of room.
+type IPv4TimestampOption struct {
+	Pointer  uint8
+	Overflow uint8
+	Flag     IPv4TimestampFlag
+	Entries  []IPv4TimestampEntry
+}
+
+func (f IPv4TimestampFlag) entrySize() int {
+	if f == IPv4TimestampOnly {
+		return 4
+	}
+	return 8
+}
+
+func (o *IPv4TimestampOption) decode(typ uint8, data []byte) error {
+	if len(data) < 2 {
+		return errors.New("IPv4 timestamp option too short")
+	}
+	*o = IPv4TimestampOption{Pointer: data[0], Overflow: data[1] >> 4, Flag: IPv4TimestampFlag(data[1] & 0xf)}
+	switch o.Flag {
+	case IPv4TimestampOnly, IPv4TimestampAndAddress, IPv4TimestampPrespecified:
+	default:
+		return fmt.Errorf("invalid IPv4 timestamp option flag %d", o.Flag)
+	}
+	size := o.Flag.entrySize()
+	if (len(data)-2)%size != 0 {
+		return fmt.Errorf("invalid IPv4 timestamp option length %d", len(data)+2)
+	}
+	o.Entries = make([]IPv4TimestampEntry, (len(data)-2)/size)
+	for i := range o.Entries {
+		entry := data[2+size*i:]
+		if size == 8 {
+			o.Entries[i].Address = net.IP(entry[:4])
+			entry = entry[4:]
+		}
+		o.Entries[i].Timestamp = binary.BigEndian.Uint32(entry)
+	}
+	return nil
+}
+
+// Option encodes the option, with a Pointer of 5 if it is zero.
+func (o *IPv4TimestampOption) Option() IPv4Option {
+	size := o.Flag.entrySize()
+	data := make([]byte, 2+size*len(o.Entries))
+	data[0] = o.Pointer
+	if data[0] == 0 {
+		data[0] = 5
+	}
+	data[1] = o.Overflow<<4 | uint8(o.Flag)&0xf
+	for i, e := range o.Entries {
+		entry := data[2+size*i:]
+		if size == 8 {
+			copy(entry, e.Address.To4())
+			entry = entry[4:]
+		}
+		binary.BigEndian.PutUint32(entry, e.Timestamp)
+	}
+	return newIPv4Option(IPv4OptionTimestamp, data)
+}
+
+// IPv4RouterAlertOption is a router alert option (RFC 2113), asking
+// routers to examine the packet more closely. A Value of 0 asks them to
+// examine it in any case.
+type IPv4RouterAlertOption struct {
+	Value uint16
+}
+
+func (o *IPv4RouterAlertOption) decode(typ uint8, data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("invalid IPv4 router alert option length %d", len(data)+2)
+	}
+	o.Value = binary.BigEndian.Uint16(data)
+	return nil
+}
+
+// Option encodes the option.
+func (o *IPv4RouterAlertOption) Option() IPv4Option {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, o.Value)
+	return newIPv4Option(IPv4OptionRouterAlert, data)
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestIPv4OptionValues(t *testing.T) {
+	values := []IPv4OptionValue{
+		&IPv4RouteOption{Type: IPv4OptionRecordRoute, Pointer: 8, Addresses: []net.IP{{10, 0, 0, 1}, {0, 0, 0, 0}}},
+		&IPv4RouteOption{Type: IPv4OptionLooseSourceRoute, Pointer: 4, Addresses: []net.IP{{10, 0, 0, 2}}},
+		&IPv4TimestampOption{Pointer: 13, Flag: IPv4TimestampAndAddress, Entries: []IPv4TimestampEntry{
+			{Address: net.IP{10, 0, 0, 1}, Timestamp: 1000},
+		}},
+		&IPv4RouterAlertOption{},
+	}
+	ip := &IPv4{Version: 4, TTL: 1, Protocol: IPProtocolIGMP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{224, 0, 0, 22}}
+	for _, v := range values {
+		ip.Options = append(ip.Options, v.Option())
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip); err != nil {
+		t.Fatal(err)
+	}
+	// 11 + 7 + 12 + 4 bytes of options, padded to 36.
+	if ihl := buf.Bytes()[0] & 0xf; ihl != 14 {
+		t.Errorf("got IHL %d, want 14", ihl)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeIPv4).(*IPv4).OptionValues()
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("got %+v, want %+v", got, values)
+	}
+	if done := got[0].(*IPv4RouteOption).Done(); !reflect.DeepEqual(done, []net.IP{{10, 0, 0, 1}}) {
+		t.Errorf("got recorded route %v", done)
+	}
+}
+
+func TestIPv4OptionSerialization(t *testing.T) {
+	// A reused buffer holding garbage must not leak into the options.
+	buf := gopacket.NewSerializeBuffer()
+	junk, _ := buf.PrependBytes(64)
+	copy(junk, bytes.Repeat([]byte{0xff}, 64))
+	buf.Clear()
+	ip := &IPv4{Version: 4, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2},
+		Options: []IPv4Option{(&IPv4RouterAlertOption{}).Option(), {OptionType: IPv4OptionNOP}}}
+	if err := ip.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{IPv4OptionRouterAlert, 4, 0, 0, IPv4OptionNOP, 0, 0, 0}
+	if got := buf.Bytes(); got[0]&0xf != 7 || !bytes.Equal(got[20:], want) {
+		t.Errorf("got header %x, want IHL 7 and options %x", got, want)
+	}
+
+	ip.Options = []IPv4Option{(&IPv4RouteOption{Type: IPv4OptionRecordRoute, Addresses: make([]net.IP, 10)}).Option()}
+	if err := ip.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err == nil {
+		t.Error("serialized options longer than the header")
+	}
+}
+
+func TestDecodeIPv4OptionErrors(t *testing.T) {
+	for _, o := range []IPv4Option{
+		{OptionType: IPv4OptionRecordRoute, OptionData: []byte{4, 10, 0, 0}},
+		{OptionType: IPv4OptionStrictSourceRoute, OptionData: []byte{3, 10, 0, 0, 1}},
+		{OptionType: IPv4OptionTimestamp, OptionData: []byte{5, 2, 0, 0, 0, 0}},
+		{OptionType: IPv4OptionTimestamp, OptionData: []byte{5, 1, 0, 0, 0, 0}},
+		{OptionType: IPv4OptionRouterAlert, OptionData: []byte{0}},
+		{OptionType: 130, OptionData: make([]byte, 9)},
+	} {
+		if _, err := DecodeIPv4Option(o); err == nil {
+			t.Errorf("decoded invalid option %+v", o)
+		}
+	}
+}
@@ -135,7 +135,9 @@ func setIPv6PayloadJumboLength(hbh []byte) error {
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
-// See the docs for gopacket.SerializableLayer for more info.
+// See the docs for gopacket.SerializableLayer for more info. With
+// FixLengths, NextHeader is set from the layer serialized inside this one,
+// or to IPProtocolIPv6HopByHop if HopByHop is set.
 func (ipv6 *IPv6) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	var jumbo bool
 	var err error
@@ -170,6 +172,11 @@ func (ipv6 *IPv6) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serializ
 			}
 		}
 	}
+	if opts.FixLengths {
+		if p, ok := ipv6InnerProtocol(b); ok {
+			ipv6.NextHeader = p
+		}
+	}
 	if ipv6.HopByHop != nil && !hbhAlreadySerialized {
 		if ipv6.NextHeader != IPProtocolIPv6HopByHop {
 			// Just fix it instead of throwing an error
@@ -246,24 +253,34 @@ func (ipv6 *IPv6) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 		if err != nil {
 			return err
 		}
-		if jumbo && ipv6.Length == 0 {
-			pEnd := int(pEnd)
-			if pEnd > len(ipv6.Payload) {
-				df.SetTruncated()
-				pEnd = len(ipv6.Payload)
-			}
-			ipv6.Payload = ipv6.Payload[:pEnd]
-			return nil
-		} else if jumbo && ipv6.Length != 0 {
+		if jumbo && ipv6.Length != 0 {
 			return errors.New("IPv6 has jumbo length and IPv6 length is not 0")
 		} else if !jumbo && ipv6.Length == 0 {
 			return errors.New("IPv6 length 0, but HopByHop header does not have jumbogram option")
+		} else if !jumbo {
+			pEnd = uint32(ipv6.Length)
+		}
+		// The payload length, or the jumbo payload length, covers the
+		// hop-by-hop header, whose payload is what follows up to the end of
+		// the packet.
+		end := int(pEnd)
+		if end > len(ipv6.Payload) {
+			df.SetTruncated()
+			end = len(ipv6.Payload)
+		}
+		if end < ipv6.hbh.ActualLength {
+			return fmt.Errorf("IPv6 payload length %d shorter than its hop-by-hop header", pEnd)
+		}
+		ipv6.hbh.Payload = ipv6.Payload[ipv6.hbh.ActualLength:end]
+		if jumbo {
+			ipv6.Payload = ipv6.Payload[:end]
 		} else {
-			ipv6.Payload = ipv6.Payload[ipv6.hbh.ActualLength:]
+			ipv6.Payload = ipv6.hbh.Payload
 		}
+		return nil
 	}
 
-	if ipv6.Length == 0 {
+	if ipv6.Length == 0 && ipv6.NextHeader != IPProtocolNoNextHeader {
 		return fmt.Errorf("IPv6 length 0, but next header is %v, not HopByHop", ipv6.NextHeader)
 	}
 
@@ -312,6 +329,12 @@ type ipv6HeaderTLVOption struct {
 }
 
 func (h *ipv6HeaderTLVOption) serializeTo(data []byte, fixLengths bool, dryrun bool) int {
+	if h.OptionType == IPv6OptionPad1 {
+		if !dryrun {
+			data[0] = IPv6OptionPad1
+		}
+		return 1
+	}
 	if fixLengths {
 		h.OptionLength = uint8(len(h.OptionData))
 	}
@@ -325,15 +348,19 @@ func (h *ipv6HeaderTLVOption) serializeTo(data []byte, fixLengths bool, dryrun b
 }
 
 func decodeIPv6HeaderTLVOption(data []byte, df gopacket.DecodeFeedback) (h *ipv6HeaderTLVOption, _ error) {
-	if len(data) < 2 {
+	if len(data) < 1 {
 		df.SetTruncated()
 		return nil, errors.New("IPv6 header option too small")
 	}
 	h = &ipv6HeaderTLVOption{}
-	if data[0] == 0 {
+	if data[0] == IPv6OptionPad1 {
 		h.ActualLength = 1
 		return
 	}
+	if len(data) < 2 {
+		df.SetTruncated()
+		return nil, errors.New("IPv6 header option too small")
+	}
 	h.OptionType = data[0]
 	h.OptionLength = data[1]
 	h.ActualLength = int(h.OptionLength) + 2
@@ -342,6 +369,7 @@ func decodeIPv6HeaderTLVOption(data []byte, df gopacket.DecodeFeedback) (h *ipv6
 		return nil, errors.New("IPv6 header TLV option too small")
 	}
 	h.OptionData = data[2:h.ActualLength]
+	h.OptionAlignment = ipv6OptionAlignment(h.OptionType)
 	return
 }
 
@@ -397,7 +425,7 @@ func serializeIPv6HeaderTLVOptions(buf []byte, options []*ipv6HeaderTLVOption, f
 		length += l
 	}
 	if fixLengths {
-		pad := length % 8
+		pad := (8 - length%8) % 8
 		if pad != 0 {
 			if !dryrun {
 				serializeTLVOptionPadding(buf[length-2:], pad)
@@ -408,6 +436,27 @@ func serializeIPv6HeaderTLVOptions(buf []byte, options []*ipv6HeaderTLVOption, f
 	return length - 2
 }
 
+// ipv6InnerProtocol returns the IP protocol of the layer serialized inside
+// the one being serialized to b, so that IPv6 headers and extensions can
+// chain their next header fields. It returns false if there is no such
+// layer, or it is a payload, or of no known IP protocol.
+func ipv6InnerProtocol(b gopacket.SerializeBuffer) (IPProtocol, bool) {
+	inner := b.Layers()
+	if len(inner) == 0 {
+		return 0, false
+	}
+	t := inner[len(inner)-1]
+	if t == gopacket.LayerTypePayload {
+		return 0, false
+	}
+	for p := range IPProtocolMetadata {
+		if IPProtocolMetadata[p].LayerType == t {
+			return IPProtocol(p), true
+		}
+	}
+	return 0, false
+}
+
 type ipv6ExtensionBase struct {
 	BaseLayer
 	NextHeader   IPProtocol
@@ -472,10 +521,16 @@ type IPv6HopByHop struct {
 // LayerType returns LayerTypeIPv6HopByHop.
 func (i *IPv6HopByHop) LayerType() gopacket.LayerType { return LayerTypeIPv6HopByHop }
 
-// SerializeTo implementation according to gopacket.SerializableLayer
+// SerializeTo implementation according to gopacket.SerializableLayer. With
+// FixLengths, NextHeader is set from the layer serialized inside this one.
 func (i *IPv6HopByHop) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	var bytes []byte
 	var err error
+	if opts.FixLengths {
+		if p, ok := ipv6InnerProtocol(b); ok {
+			i.NextHeader = p
+		}
+	}
 
 	o := make([]*ipv6HeaderTLVOption, 0, len(i.Options))
 	for _, v := range i.Options {
@@ -547,6 +602,27 @@ func (o *IPv6HopByHopOption) SetJumboLength(len uint32) {
 	o.OptionAlignment = [2]uint8{4, 2}
 }
 
+// IPv6 routing header types.
+const (
+	IPv6RoutingTypeSource  uint8 = 0
+	IPv6RoutingTypeSegment uint8 = 4
+)
+
+// IPv6SRHTLV is a TLV of an IPv6 segment routing header. Type
+// IPv6SRHTLVTypePad1 is a single byte, without length or value.
+type IPv6SRHTLV struct {
+	Type   uint8
+	Length uint8
+	Value  []byte
+}
+
+// IPv6 segment routing header TLV types (RFC 8754).
+const (
+	IPv6SRHTLVTypePad1 uint8 = 0
+	IPv6SRHTLVTypePadN uint8 = 4
+	IPv6SRHTLVTypeHMAC uint8 = 5
+)
+
 // IPv6Routing is the IPv6 routing extension.
 type IPv6Routing struct {
 	ipv6ExtensionBase
@@ -558,33 +634,192 @@ type IPv6Routing struct {
 	// SourceRoutingIPs is the set of IPv6 addresses requested for source routing,
 	// set only if RoutingType == 0.
 	SourceRoutingIPs []net.IP
+
+	// The following are set only if RoutingType == 4, for segment routing
+	// headers (RFC 8754).
+	LastEntry uint8
+	Flags     uint8
+	Tag       uint16
+	// Segments is the segment list, in which the last segment of the path
+	// comes first.
+	Segments []net.IP
+	TLVs     []IPv6SRHTLV
 }
 
 // LayerType returns LayerTypeIPv6Routing.
 func (i *IPv6Routing) LayerType() gopacket.LayerType { return LayerTypeIPv6Routing }
 
-func decodeIPv6Routing(data []byte, p gopacket.PacketBuilder) error {
-	base, err := decodeIPv6ExtensionBase(data, p)
+// ActiveSegment returns the segment currently being routed to by a segment
+// routing header, which should be the destination of the IPv6 header, or nil.
+func (i *IPv6Routing) ActiveSegment() net.IP {
+	if i.RoutingType != IPv6RoutingTypeSegment || int(i.SegmentsLeft) >= len(i.Segments) {
+		return nil
+	}
+	return i.Segments[i.SegmentsLeft]
+}
+
+// DecodeFromBytes implementation according to gopacket.DecodingLayer
+func (i *IPv6Routing) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	base, err := decodeIPv6ExtensionBase(data, df)
 	if err != nil {
 		return err
 	}
-	i := &IPv6Routing{
-		ipv6ExtensionBase: base,
-		RoutingType:       data[2],
-		SegmentsLeft:      data[3],
-		Reserved:          data[4:8],
-	}
+	i.ipv6ExtensionBase = base
+	i.RoutingType = data[2]
+	i.SegmentsLeft = data[3]
+	i.Reserved = nil
+	i.SourceRoutingIPs = i.SourceRoutingIPs[:0]
+	i.LastEntry, i.Flags, i.Tag = 0, 0, 0
+	i.Segments = i.Segments[:0]
+	i.TLVs = i.TLVs[:0]
 	switch i.RoutingType {
-	case 0: // Source routing
+	case IPv6RoutingTypeSource:
 		if (i.ActualLength-8)%16 != 0 {
 			return fmt.Errorf("Invalid IPv6 source routing, length of type 0 packet %d", i.ActualLength)
 		}
+		i.Reserved = data[4:8]
 		for d := i.Contents[8:]; len(d) >= 16; d = d[16:] {
 			i.SourceRoutingIPs = append(i.SourceRoutingIPs, net.IP(d[:16]))
 		}
+	case IPv6RoutingTypeSegment:
+		i.LastEntry = data[4]
+		i.Flags = data[5]
+		i.Tag = binary.BigEndian.Uint16(data[6:8])
+		end := 8 + (int(i.LastEntry)+1)*16
+		if end > i.ActualLength {
+			return fmt.Errorf("Invalid IPv6 segment routing header, last entry %d too large for length %d", i.LastEntry, i.ActualLength)
+		}
+		for d := i.Contents[8:end]; len(d) > 0; d = d[16:] {
+			i.Segments = append(i.Segments, net.IP(d[:16]))
+		}
+		for d := i.Contents[end:]; len(d) > 0; {
+			if d[0] == IPv6SRHTLVTypePad1 {
+				i.TLVs = append(i.TLVs, IPv6SRHTLV{Type: IPv6SRHTLVTypePad1})
+				d = d[1:]
+				continue
+			}
+			if len(d) < 2 || len(d) < 2+int(d[1]) {
+				return errors.New("Invalid IPv6 segment routing header TLV")
+			}
+			i.TLVs = append(i.TLVs, IPv6SRHTLV{Type: d[0], Length: d[1], Value: d[2 : 2+d[1]]})
+			d = d[2+d[1]:]
+		}
+	default:
+		return fmt.Errorf("Unknown IPv6 routing header type %d", i.RoutingType)
+	}
+	return nil
+}
+
+// SerializeTo implementation according to gopacket.SerializableLayer. With
+// FixLengths, NextHeader is set from the layer serialized inside this one,
+// and the last entry of segment routing headers is set and their TLVs are
+// padded to a multiple of 8 bytes.
+func (i *IPv6Routing) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if opts.FixLengths {
+		if p, ok := ipv6InnerProtocol(b); ok {
+			i.NextHeader = p
+		}
+	}
+	var length int
+	switch i.RoutingType {
+	case IPv6RoutingTypeSource:
+		length = 8 + 16*len(i.SourceRoutingIPs)
+	case IPv6RoutingTypeSegment:
+		if len(i.Segments) == 0 || len(i.Segments) > 256 {
+			return fmt.Errorf("invalid number of IPv6 segments %d", len(i.Segments))
+		}
+		length = 8 + 16*len(i.Segments)
+		for _, tlv := range i.TLVs {
+			if tlv.Type == IPv6SRHTLVTypePad1 {
+				length++
+			} else {
+				length += 2 + len(tlv.Value)
+			}
+		}
 	default:
 		return fmt.Errorf("Unknown IPv6 routing header type %d", i.RoutingType)
 	}
+	pad := 0
+	if length%8 != 0 {
+		if !opts.FixLengths {
+			return errors.New("IPv6Routing actual length must be multiple of 8")
+		}
+		pad = 8 - length%8
+	}
+	length += pad
+	if length > 255*8+8 {
+		return errors.New("IPv6Routing too long")
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		i.HeaderLength = uint8(length/8 - 1)
+	}
+	bytes[0] = uint8(i.NextHeader)
+	bytes[1] = i.HeaderLength
+	bytes[2] = i.RoutingType
+	bytes[3] = i.SegmentsLeft
+	if i.RoutingType == IPv6RoutingTypeSource {
+		copy(bytes[4:8], lotsOfZeros[:4])
+		copy(bytes[4:8], i.Reserved)
+		for n, ip := range i.SourceRoutingIPs {
+			copy(bytes[8+16*n:], ip.To16())
+		}
+		return nil
+	}
+
+	if opts.FixLengths {
+		i.LastEntry = uint8(len(i.Segments) - 1)
+	}
+	bytes[4] = i.LastEntry
+	bytes[5] = i.Flags
+	binary.BigEndian.PutUint16(bytes[6:], i.Tag)
+	for n, ip := range i.Segments {
+		copy(bytes[8+16*n:], ip.To16())
+	}
+	d := bytes[8+16*len(i.Segments):]
+	for _, tlv := range i.TLVs {
+		d[0] = tlv.Type
+		if tlv.Type == IPv6SRHTLVTypePad1 {
+			d = d[1:]
+			continue
+		}
+		if opts.FixLengths {
+			tlv.Length = uint8(len(tlv.Value))
+		}
+		d[1] = tlv.Length
+		copy(d[2:], tlv.Value)
+		d = d[2+len(tlv.Value):]
+	}
+	switch pad {
+	case 0:
+	case 1:
+		d[0] = IPv6SRHTLVTypePad1
+	default:
+		d[0] = IPv6SRHTLVTypePadN
+		d[1] = uint8(pad - 2)
+		copy(d[2:], lotsOfZeros[:pad-2])
+	}
+	return nil
+}
+
+// CanDecode implementation according to gopacket.DecodingLayer
+func (i *IPv6Routing) CanDecode() gopacket.LayerClass {
+	return LayerTypeIPv6Routing
+}
+
+// NextLayerType implementation according to gopacket.DecodingLayer
+func (i *IPv6Routing) NextLayerType() gopacket.LayerType {
+	return i.NextHeader.LayerType()
+}
+
+func decodeIPv6Routing(data []byte, p gopacket.PacketBuilder) error {
+	i := &IPv6Routing{}
+	if err := i.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
 	p.AddLayer(i)
 	return p.NextDecoder(i.NextHeader)
 }
@@ -606,6 +841,32 @@ type IPv6Fragment struct {
 // LayerType returns LayerTypeIPv6Fragment.
 func (i *IPv6Fragment) LayerType() gopacket.LayerType { return LayerTypeIPv6Fragment }
 
+// SerializeTo implementation according to gopacket.SerializableLayer. With
+// FixLengths, NextHeader is set from the layer serialized inside this one.
+func (i *IPv6Fragment) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if i.FragmentOffset > 0x1fff {
+		return fmt.Errorf("invalid IPv6 fragment offset %d", i.FragmentOffset)
+	}
+	if opts.FixLengths {
+		if p, ok := ipv6InnerProtocol(b); ok {
+			i.NextHeader = p
+		}
+	}
+	bytes, err := b.PrependBytes(8)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(i.NextHeader)
+	bytes[1] = i.Reserved1
+	flags := i.FragmentOffset<<3 | uint16(i.Reserved2&0x3)<<1
+	if i.MoreFragments {
+		flags |= 1
+	}
+	binary.BigEndian.PutUint16(bytes[2:], flags)
+	binary.BigEndian.PutUint32(bytes[4:], i.Identification)
+	return nil
+}
+
 func decodeIPv6Fragment(data []byte, p gopacket.PacketBuilder) error {
 	if len(data) < 8 {
 		p.SetTruncated()
@@ -667,10 +928,16 @@ func decodeIPv6Destination(data []byte, p gopacket.PacketBuilder) error {
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
-// See the docs for gopacket.SerializableLayer for more info.
+// See the docs for gopacket.SerializableLayer for more info. With
+// FixLengths, NextHeader is set from the layer serialized inside this one.
 func (i *IPv6Destination) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	var bytes []byte
 	var err error
+	if opts.FixLengths {
+		if p, ok := ipv6InnerProtocol(b); ok {
+			i.NextHeader = p
+		}
+	}
 
 	o := make([]*ipv6HeaderTLVOption, 0, len(i.Options))
 	for _, v := range i.Options {
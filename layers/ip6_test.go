@@ -384,6 +384,7 @@ func TestIPv6JumbogramDecode(t *testing.T) {
 	opt.OptionLength = uint8(0x04)
 	opt.ActualLength = 6
 	opt.OptionData = []byte{0x00, 0x01, 0x00, 0x08}
+	opt.OptionAlignment = [2]uint8{4, 2}
 	hop.Options = append(hop.Options, opt)
 	ip6.HopByHop = hop
 
@@ -428,3 +429,115 @@ func TestIPv6JumbogramDecode(t *testing.T) {
 		t.Error("No Payload layer type found in packet")
 	}
 }
+
+var testPacketIPv6SegmentRouting = []byte{
+	0x60, 0x00, 0x00, 0x00, 0x00, 0x40, 0x2b, 0x40, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x3b, 0x07, 0x04, 0x01, 0x02, 0x00, 0x12, 0x34,
+	0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+	0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+	0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	0x07, 0x03, 0xaa, 0xbb, 0xcc, 0x04, 0x01, 0x00,
+}
+
+func TestPacketIPv6SegmentRoutingSerialize(t *testing.T) {
+	ip6 := &IPv6{
+		Version:    6,
+		NextHeader: IPProtocolIPv6Routing,
+		HopLimit:   64,
+		SrcIP:      net.ParseIP("2001:db8::a"),
+		DstIP:      net.ParseIP("2001:db8::2"),
+	}
+	srh := &IPv6Routing{
+		RoutingType:  IPv6RoutingTypeSegment,
+		SegmentsLeft: 1,
+		Tag:          0x1234,
+		Segments: []net.IP{
+			net.ParseIP("2001:db8::3"),
+			net.ParseIP("2001:db8::2"),
+			net.ParseIP("2001:db8::1"),
+		},
+		TLVs: []IPv6SRHTLV{{Type: 7, Value: []byte{0xaa, 0xbb, 0xcc}}},
+	}
+	srh.NextHeader = IPProtocolNoNextHeader
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, srh); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+	want := testPacketIPv6SegmentRouting
+	if !bytes.Equal(got, want) {
+		t.Errorf("IPv6Routing serialize failed:\ngot:\n%#v\n\nwant:\n%#v\n\n", got, want)
+	}
+	if srh.LastEntry != 2 || srh.HeaderLength != 7 {
+		t.Errorf("got last entry %d header length %d, want 2 and 7", srh.LastEntry, srh.HeaderLength)
+	}
+}
+
+func TestPacketIPv6SegmentRoutingDecode(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIPv6SegmentRouting, LayerTypeIPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeIPv6Routing}, t)
+
+	srh := p.Layer(LayerTypeIPv6Routing).(*IPv6Routing)
+	if srh.RoutingType != IPv6RoutingTypeSegment || srh.SegmentsLeft != 1 || srh.LastEntry != 2 || srh.Flags != 0 || srh.Tag != 0x1234 {
+		t.Errorf("got segment routing header %+v", srh)
+	}
+	if len(srh.Segments) != 3 || !srh.Segments[2].Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("got segments %v", srh.Segments)
+	}
+	if active := srh.ActiveSegment(); !active.Equal(p.NetworkLayer().(*IPv6).DstIP) {
+		t.Errorf("got active segment %v, want the destination", active)
+	}
+	want := []IPv6SRHTLV{
+		{Type: 7, Length: 3, Value: []byte{0xaa, 0xbb, 0xcc}},
+		{Type: IPv6SRHTLVTypePadN, Length: 1, Value: []byte{0}},
+	}
+	if !reflect.DeepEqual(srh.TLVs, want) {
+		t.Errorf("got TLVs %+v, want %+v", srh.TLVs, want)
+	}
+}
+
+func TestIPv6ExtensionChainSerialize(t *testing.T) {
+	ip6 := &IPv6{Version: 6, HopLimit: 64, SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")}
+	hop := &IPv6HopByHop{}
+	// A router alert, which needs padding up to 8 bytes.
+	hop.Options = []*IPv6HopByHopOption{{OptionType: 0x05, OptionData: []byte{0, 0}}}
+	dst := &IPv6Destination{}
+	dst.Options = []*IPv6DestinationOption{{OptionType: 0x1e, OptionData: []byte{1, 2}}}
+	routing := &IPv6Routing{RoutingType: IPv6RoutingTypeSegment, Segments: []net.IP{net.ParseIP("2001:db8::2")}}
+	frag := &IPv6Fragment{FragmentOffset: 0x1234, MoreFragments: true, Identification: 0xdeadbeef}
+	udp := &UDP{SrcPort: 1000, DstPort: 2000}
+	udp.SetNetworkLayerForChecksum(ip6)
+
+	// All next header fields are left zero, to be chained by FixLengths.
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, hop, dst, routing, frag, udp); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv6, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeIPv6HopByHop, LayerTypeIPv6Destination,
+		LayerTypeIPv6Routing, LayerTypeIPv6Fragment, gopacket.LayerTypeFragment}, t)
+	for _, test := range []struct {
+		got, want IPProtocol
+	}{
+		{p.Layer(LayerTypeIPv6).(*IPv6).NextHeader, IPProtocolIPv6HopByHop},
+		{p.Layer(LayerTypeIPv6HopByHop).(*IPv6HopByHop).NextHeader, IPProtocolIPv6Destination},
+		{p.Layer(LayerTypeIPv6Destination).(*IPv6Destination).NextHeader, IPProtocolIPv6Routing},
+		{p.Layer(LayerTypeIPv6Routing).(*IPv6Routing).NextHeader, IPProtocolIPv6Fragment},
+		{p.Layer(LayerTypeIPv6Fragment).(*IPv6Fragment).NextHeader, IPProtocolUDP},
+	} {
+		if test.got != test.want {
+			t.Errorf("got next header %v, want %v", test.got, test.want)
+		}
+	}
+	got := p.Layer(LayerTypeIPv6Fragment).(*IPv6Fragment)
+	if got.FragmentOffset != frag.FragmentOffset || !got.MoreFragments || got.Identification != frag.Identification {
+		t.Errorf("got fragment header %+v, want %+v", got, frag)
+	}
+}
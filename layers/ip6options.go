@@ -0,0 +1,178 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IPv6 hop-by-hop and destination option types.
+const (
+	IPv6OptionPad1        = 0
+	IPv6OptionPadN        = 1
+	IPv6OptionRouterAlert = 5
+	IPv6OptionJumbo       = IPv6HopByHopOptionJumbogram
+)
+
+// ipv6OptionAlignment returns the alignment requirement of options of type
+// typ, as xn+y = [2]uint8{x, y}.
+func ipv6OptionAlignment(typ uint8) [2]uint8 {
+	switch typ {
+	case IPv6OptionRouterAlert:
+		return [2]uint8{2, 0}
+	case IPv6OptionJumbo:
+		return [2]uint8{4, 2}
+	}
+	return [2]uint8{}
+}
+
+// IPv6OptionValue is a decoded hop-by-hop or destination option: an
+// *IPv6PadOption, *IPv6RouterAlertOption or *IPv6JumboOption. Use
+// NewIPv6HopByHopOption and NewIPv6DestinationOption to encode it.
+type IPv6OptionValue interface {
+	tlv() *ipv6HeaderTLVOption
+}
+
+func decodeIPv6OptionValue(o *ipv6HeaderTLVOption) (IPv6OptionValue, error) {
+	switch o.OptionType {
+	case IPv6OptionPad1:
+		return &IPv6PadOption{Length: 1}, nil
+	case IPv6OptionPadN:
+		return &IPv6PadOption{Length: 2 + len(o.OptionData)}, nil
+	case IPv6OptionRouterAlert:
+		if len(o.OptionData) != 2 {
+			return nil, fmt.Errorf("invalid IPv6 router alert option length %d", len(o.OptionData))
+		}
+		return &IPv6RouterAlertOption{Value: IPv6RouterAlertValue(binary.BigEndian.Uint16(o.OptionData))}, nil
+	case IPv6OptionJumbo:
+		if len(o.OptionData) != 4 {
+			return nil, fmt.Errorf("invalid IPv6 jumbo payload option length %d", len(o.OptionData))
+		}
+		return &IPv6JumboOption{Length: binary.BigEndian.Uint32(o.OptionData)}, nil
+	}
+	return nil, fmt.Errorf("unsupported IPv6 option %d", o.OptionType)
+}
+
+// Value decodes the option. It returns an error if the option is not of a
+// supported type, or is malformed.
+func (o *IPv6HopByHopOption) Value() (IPv6OptionValue, error) {
+	return decodeIPv6OptionValue((*ipv6HeaderTLVOption)(o))
+}
+
+// Value decodes the option. It returns an error if the option is not of a
+// supported type, or is malformed.
+func (o *IPv6DestinationOption) Value() (IPv6OptionValue, error) {
+	return decodeIPv6OptionValue((*ipv6HeaderTLVOption)(o))
+}
+
+// NewIPv6HopByHopOption encodes v as a hop-by-hop option, with the
+// alignment it requires when serialized with FixLengths.
+func NewIPv6HopByHopOption(v IPv6OptionValue) *IPv6HopByHopOption {
+	return (*IPv6HopByHopOption)(v.tlv())
+}
+
+// NewIPv6DestinationOption encodes v as a destination option, with the
+// alignment it requires when serialized with FixLengths.
+func NewIPv6DestinationOption(v IPv6OptionValue) *IPv6DestinationOption {
+	return (*IPv6DestinationOption)(v.tlv())
+}
+
+func newIPv6HeaderTLVOption(typ uint8, data []byte) *ipv6HeaderTLVOption {
+	return &ipv6HeaderTLVOption{
+		OptionType:      typ,
+		OptionLength:    uint8(len(data)),
+		ActualLength:    2 + len(data),
+		OptionData:      data,
+		OptionAlignment: ipv6OptionAlignment(typ),
+	}
+}
+
+// OptionValues returns the valid options of the header, of the types
+// supported by IPv6HopByHopOption.Value.
+func (i *IPv6HopByHop) OptionValues() []IPv6OptionValue {
+	var values []IPv6OptionValue
+	for _, o := range i.Options {
+		if v, err := o.Value(); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// OptionValues returns the valid options of the header, of the types
+// supported by IPv6DestinationOption.Value.
+func (i *IPv6Destination) OptionValues() []IPv6OptionValue {
+	var values []IPv6OptionValue
+	for _, o := range i.Options {
+		if v, err := o.Value(); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// IPv6PadOption is a Pad1 option if Length is 1, and a PadN option of
+// Length bytes otherwise.
+type IPv6PadOption struct {
+	Length int
+}
+
+func (o *IPv6PadOption) tlv() *ipv6HeaderTLVOption {
+	if o.Length <= 1 {
+		return &ipv6HeaderTLVOption{OptionType: IPv6OptionPad1, ActualLength: 1}
+	}
+	return newIPv6HeaderTLVOption(IPv6OptionPadN, make([]byte, o.Length-2))
+}
+
+// IPv6RouterAlertValue tells what a router alert option alerts routers
+// to.
+type IPv6RouterAlertValue uint16
+
+// IPv6 router alert values (RFC 2711).
+const (
+	IPv6RouterAlertMLD            IPv6RouterAlertValue = 0
+	IPv6RouterAlertRSVP           IPv6RouterAlertValue = 1
+	IPv6RouterAlertActiveNetworks IPv6RouterAlertValue = 2
+)
+
+func (v IPv6RouterAlertValue) String() string {
+	switch v {
+	case IPv6RouterAlertMLD:
+		return "MLD"
+	case IPv6RouterAlertRSVP:
+		return "RSVP"
+	case IPv6RouterAlertActiveNetworks:
+		return "ActiveNetworks"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(v))
+}
+
+// IPv6RouterAlertOption is a router alert hop-by-hop option (RFC 2711),
+// asking routers to examine the packet more closely, as MLD messages do.
+type IPv6RouterAlertOption struct {
+	Value IPv6RouterAlertValue
+}
+
+func (o *IPv6RouterAlertOption) tlv() *ipv6HeaderTLVOption {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, uint16(o.Value))
+	return newIPv6HeaderTLVOption(IPv6OptionRouterAlert, data)
+}
+
+// IPv6JumboOption is a jumbo payload hop-by-hop option (RFC 2675), giving
+// the length of packets whose payload length field is zero, from the
+// hop-by-hop header to the end of the packet.
+type IPv6JumboOption struct {
+	Length uint32
+}
+
+func (o *IPv6JumboOption) tlv() *ipv6HeaderTLVOption {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, o.Length)
+	return newIPv6HeaderTLVOption(IPv6OptionJumbo, data)
+}
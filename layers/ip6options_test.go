@@ -0,0 +1,66 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestIPv6OptionValues(t *testing.T) {
+	ip6 := &IPv6{Version: 6, HopLimit: 1, SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP("ff02::16")}
+	hop := &IPv6HopByHop{}
+	hop.Options = []*IPv6HopByHopOption{
+		NewIPv6HopByHopOption(&IPv6PadOption{Length: 1}),
+		NewIPv6HopByHopOption(&IPv6RouterAlertOption{Value: IPv6RouterAlertMLD}),
+	}
+	dst := &IPv6Destination{}
+	dst.NextHeader = IPProtocolNoNextHeader
+	dst.Options = []*IPv6DestinationOption{NewIPv6DestinationOption(&IPv6PadOption{Length: 6})}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip6, hop, dst,
+		gopacket.Payload{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	// The router alert is aligned to 2n with another Pad1.
+	wantHop := []byte{byte(IPProtocolIPv6Destination), 0, IPv6OptionPad1, IPv6OptionPad1, IPv6OptionRouterAlert, 2, 0, 0}
+	if got := buf.Bytes()[40:48]; !bytes.Equal(got, wantHop) {
+		t.Errorf("got hop-by-hop header %x, want %x", got, wantHop)
+	}
+
+	// Trailing bytes past the payload length are not decoded.
+	data := append(buf.Bytes(), 0xff, 0xff)
+	p := gopacket.NewPacket(data, LayerTypeIPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeIPv6HopByHop, LayerTypeIPv6Destination,
+		gopacket.LayerTypePayload}, t)
+	if got := p.ApplicationLayer().Payload(); !bytes.Equal(got, []byte{1, 2, 3, 4}) {
+		t.Errorf("got payload %x", got)
+	}
+	gotHop := p.Layer(LayerTypeIPv6HopByHop).(*IPv6HopByHop)
+	want := []IPv6OptionValue{&IPv6PadOption{Length: 1}, &IPv6PadOption{Length: 1}, &IPv6RouterAlertOption{Value: IPv6RouterAlertMLD}}
+	if got := gotHop.OptionValues(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got hop-by-hop options %+v, want %+v", got, want)
+	}
+	if a := gotHop.Options[2].OptionAlignment; a != [2]uint8{2, 0} {
+		t.Errorf("got router alert alignment %v", a)
+	}
+	want = []IPv6OptionValue{&IPv6PadOption{Length: 6}}
+	if got := p.Layer(LayerTypeIPv6Destination).(*IPv6Destination).OptionValues(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got destination options %+v, want %+v", got, want)
+	}
+
+	if _, err := (&IPv6HopByHopOption{OptionType: IPv6OptionJumbo, OptionData: []byte{1}}).Value(); err == nil {
+		t.Error("decoded a short jumbo payload option")
+	}
+}
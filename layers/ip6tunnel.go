@@ -0,0 +1,145 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// IPv6TunnelType is the kind of tunnel carrying IPv6 packets in IPv4
+// packets of protocol 41, or in UDP for Teredo.
+type IPv6TunnelType uint8
+
+// IPv6 in IPv4 tunnel types. IPv6Tunnel6in4 is a configured tunnel (RFC
+// 4213), the others are automatic tunnels whose IPv6 addresses embed the
+// IPv4 addresses of their endpoints: 6to4 (RFC 3056), 6rd (RFC 5969) and
+// ISATAP (RFC 5214). IPv6TunnelTeredo is Teredo (RFC 4380).
+const (
+	IPv6TunnelNone IPv6TunnelType = iota
+	IPv6Tunnel6in4
+	IPv6Tunnel6to4
+	IPv6Tunnel6rd
+	IPv6TunnelISATAP
+	IPv6TunnelTeredo
+)
+
+func (t IPv6TunnelType) String() string {
+	switch t {
+	case IPv6TunnelNone:
+		return ""
+	case IPv6Tunnel6in4:
+		return "6in4"
+	case IPv6Tunnel6to4:
+		return "6to4"
+	case IPv6Tunnel6rd:
+		return "6rd"
+	case IPv6TunnelISATAP:
+		return "ISATAP"
+	case IPv6TunnelTeredo:
+		return "Teredo"
+	}
+	return "Unknown"
+}
+
+// IPv6RDDomain is the configuration of a 6rd domain: the IPv6 prefix of its
+// delegated prefixes, and the number of high order bits of its border relay
+// and customer edge IPv4 addresses which are common, and left out of the
+// delegated prefixes.
+type IPv6RDDomain struct {
+	Prefix      net.IPNet
+	IPv4MaskLen int
+}
+
+// IPv6RDDomains lists the 6rd domains used to recognize 6rd tunnels, which
+// cannot be told apart from configured tunnels otherwise. It is initially
+// empty.
+var IPv6RDDomains []IPv6RDDomain
+
+// match returns whether addr is a delegated address of d for the IPv4
+// address ip4.
+func (d *IPv6RDDomain) match(addr, ip4 []byte) bool {
+	ones, bits := d.Prefix.Mask.Size()
+	if bits != 8*net.IPv6len || !d.Prefix.Contains(addr) || d.IPv4MaskLen < 0 || d.IPv4MaskLen > 32 {
+		return false
+	}
+	n := 32 - d.IPv4MaskLen
+	if ones+n > 64 {
+		return false
+	}
+	v4 := uint64(ip4[0])<<24 | uint64(ip4[1])<<16 | uint64(ip4[2])<<8 | uint64(ip4[3])
+	hi := uint64(addr[0])<<56 | uint64(addr[1])<<48 | uint64(addr[2])<<40 | uint64(addr[3])<<32 |
+		uint64(addr[4])<<24 | uint64(addr[5])<<16 | uint64(addr[6])<<8 | uint64(addr[7])
+	mask := uint64(1)<<uint(n) - 1
+	return hi>>uint(64-ones-n)&mask == v4&mask
+}
+
+var (
+	isatapIdentifier       = []byte{0x00, 0x00, 0x5e, 0xfe}
+	isatapGlobalIdentifier = []byte{0x02, 0x00, 0x5e, 0xfe}
+)
+
+// classifyIPv6Tunnel returns the type of tunnel between the IPv4 addresses
+// src4 and dst4 carrying an IPv6 packet from src6 to dst6. The tunnel is
+// automatic if the IPv6 address of either end embeds its IPv4 address.
+func classifyIPv6Tunnel(src4, dst4, src6, dst6 []byte) IPv6TunnelType {
+	ends := [2][2][]byte{{src4, src6}, {dst4, dst6}}
+	for i := range IPv6RDDomains {
+		for _, e := range ends {
+			if IPv6RDDomains[i].match(e[1], e[0]) {
+				return IPv6Tunnel6rd
+			}
+		}
+	}
+	for _, e := range ends {
+		iid := e[1][8:12]
+		if (bytes.Equal(iid, isatapIdentifier) || bytes.Equal(iid, isatapGlobalIdentifier)) && bytes.Equal(e[1][12:], e[0]) {
+			return IPv6TunnelISATAP
+		}
+	}
+	for _, e := range ends {
+		if e[1][0] == 0x20 && e[1][1] == 0x02 && bytes.Equal(e[1][2:6], e[0]) {
+			return IPv6Tunnel6to4
+		}
+	}
+	return IPv6Tunnel6in4
+}
+
+// ClassifyIPv6Tunnel returns the type of tunnel of an IPv6 packet carried
+// in an IPv4 packet, or IPv6TunnelNone if inner is not carried by outer. It
+// is meant for users of DecodingLayerParser: packets decoded by
+// gopacket.NewPacket record it in their metadata's Tunnel.
+func ClassifyIPv6Tunnel(outer *IPv4, inner *IPv6) IPv6TunnelType {
+	src4, dst4 := outer.SrcIP.To4(), outer.DstIP.To4()
+	src6, dst6 := inner.SrcIP.To16(), inner.DstIP.To16()
+	if outer.Protocol != IPProtocolIPv6 || src4 == nil || dst4 == nil || src6 == nil || dst6 == nil {
+		return IPv6TunnelNone
+	}
+	return classifyIPv6Tunnel(src4, dst4, src6, dst6)
+}
+
+// setIPv6Tunnel records in the metadata of the packet being built the type
+// of tunnel of the IPv6 packet carried by ip.
+func setIPv6Tunnel(ip *IPv4, p gopacket.PacketBuilder) {
+	if len(ip.Payload) < 40 || ip.Payload[0]>>4 != 6 {
+		return
+	}
+	src4, dst4 := ip.SrcIP.To4(), ip.DstIP.To4()
+	if src4 == nil || dst4 == nil {
+		return
+	}
+	setTunnel(p, classifyIPv6Tunnel(src4, dst4, ip.Payload[8:24], ip.Payload[24:40]))
+}
+
+// setTunnel records t in the metadata of the packet being built.
+func setTunnel(p gopacket.PacketBuilder, t IPv6TunnelType) {
+	if m, ok := p.(interface{ Metadata() *gopacket.PacketMetadata }); ok {
+		m.Metadata().Tunnel = t.String()
+	}
+}
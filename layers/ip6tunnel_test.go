@@ -0,0 +1,78 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func ipv6InIPv4Packet(t *testing.T, src4, dst4, src6, dst6 string) []byte {
+	ip4 := &IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: IPProtocolIPv6,
+		SrcIP:    net.ParseIP(src4),
+		DstIP:    net.ParseIP(dst4),
+	}
+	ip6 := &IPv6{
+		Version:    6,
+		NextHeader: IPProtocolNoNextHeader,
+		HopLimit:   64,
+		SrcIP:      net.ParseIP(src6),
+		DstIP:      net.ParseIP(dst6),
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip4, ip6, gopacket.Payload{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIPv6Tunnel(t *testing.T) {
+	defer func(d []IPv6RDDomain) { IPv6RDDomains = d }(IPv6RDDomains)
+	_, prefix, _ := net.ParseCIDR("2001:db8::/32")
+	IPv6RDDomains = []IPv6RDDomain{{Prefix: *prefix, IPv4MaskLen: 8}}
+
+	for _, test := range []struct {
+		src4, dst4, src6, dst6 string
+		want                   IPv6TunnelType
+	}{
+		{"192.0.2.1", "198.51.100.1", "2001:db9::1", "2001:db9::2", IPv6Tunnel6in4},
+		{"192.0.2.1", "198.51.100.1", "2002:c000:201::1", "2001:db9::2", IPv6Tunnel6to4},
+		{"192.0.2.1", "198.51.100.1", "2001:db9::2", "2002:c633:6401::1", IPv6Tunnel6to4},
+		{"192.0.2.1", "198.51.100.1", "fe80::5efe:c000:201", "fe80::5efe:c633:6401", IPv6TunnelISATAP},
+		{"192.0.2.1", "198.51.100.1", "2001:db9::200:5efe:c000:201", "2001:db9::2", IPv6TunnelISATAP},
+		{"10.1.2.3", "10.0.0.1", "2001:db8:102:300::1", "2001:db9::2", IPv6Tunnel6rd},
+		// The embedded IPv4 address must match the outer one.
+		{"192.0.2.2", "198.51.100.1", "2002:c000:201::1", "2001:db9::2", IPv6Tunnel6in4},
+		{"10.1.2.4", "10.0.0.1", "2001:db8:102:300::1", "2001:db9::2", IPv6Tunnel6in4},
+	} {
+		p := gopacket.NewPacket(ipv6InIPv4Packet(t, test.src4, test.dst4, test.src6, test.dst6), LayerTypeIPv4, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+		}
+		if got := p.Metadata().Tunnel; got != test.want.String() {
+			t.Errorf("%s %s -> %s %s: got tunnel %q, want %q", test.src4, test.src6, test.dst4, test.dst6, got, test.want)
+		}
+		outer := p.Layers()[0].(*IPv4)
+		inner := p.Layer(LayerTypeIPv6).(*IPv6)
+		if got := ClassifyIPv6Tunnel(outer, inner); got != test.want {
+			t.Errorf("%s %s -> %s %s: classified as %v, want %v", test.src4, test.src6, test.dst4, test.dst6, got, test.want)
+		}
+	}
+}
+
+func TestIPv6TunnelNative(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIPv6SegmentRouting, LayerTypeIPv6, gopacket.Default)
+	if got := p.Metadata().Tunnel; got != "" {
+		t.Errorf("got tunnel %q for a native IPv6 packet", got)
+	}
+}
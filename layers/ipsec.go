@@ -9,6 +9,8 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -22,11 +24,185 @@ type IPSecAH struct {
 	Reserved           uint16
 	SPI, Seq           uint32
 	AuthenticationData []byte
+
+	// icv and network are set by SetICVFunc.
+	icv     AHICVFunc
+	network gopacket.NetworkLayer
 }
 
 // LayerType returns LayerTypeIPSecAH.
 func (i *IPSecAH) LayerType() gopacket.LayerType { return LayerTypeIPSecAH }
 
+// AHICVFunc computes the Integrity Check Value of an AH protected packet,
+// such as an HMAC-SHA1-96 keyed with the security association's key. data
+// is the IP packet from the start of the IP header to the end of the
+// payload, with the mutable fields of the IP header and the ICV field of
+// the AH header zeroed as described in RFC 4302 section 3.3.3.1.
+type AHICVFunc func(data []byte) ([]byte, error)
+
+// SetICVFunc tells this layer how to compute its ICV when serialized with
+// ComputeChecksums set. network is the *IPv4 or *IPv6 layer wrapping this
+// one; its header and, for IPv6, its HopByHop options are covered by the
+// ICV. IPv6 extension headers serialized as separate layers between the
+// IPv6 and AH layers are not.
+//
+// The ICV field is as long as AuthenticationData, so set that to the ICV
+// length of the algorithm in use, for example 12 bytes for
+// HMAC-SHA1-96. Serializing pads it as required for the IP version.
+func (i *IPSecAH) SetICVFunc(network gopacket.NetworkLayer, fn AHICVFunc) error {
+	switch network.(type) {
+	case *IPv4, *IPv6:
+	default:
+		return fmt.Errorf("cannot use layer type %v for AH ICV network layer", network.LayerType())
+	}
+	i.network, i.icv = network, fn
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// If ComputeChecksums is set and SetICVFunc was called, the ICV is
+// computed and stored in AuthenticationData.
+func (i *IPSecAH) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	// The AH header must be a multiple of 32 bits long for IPv4 and of 64
+	// bits for IPv6.
+	align := 4
+	if _, ok := i.network.(*IPv6); ok {
+		align = 8
+	}
+	length := 12 + len(i.AuthenticationData)
+	length += (align - length%align) % align
+	if opts.FixLengths {
+		i.HeaderLength = uint8(length/4 - 2)
+		i.ActualLength = length
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(i.NextHeader)
+	bytes[1] = i.HeaderLength
+	binary.BigEndian.PutUint16(bytes[2:], i.Reserved)
+	binary.BigEndian.PutUint32(bytes[4:], i.SPI)
+	binary.BigEndian.PutUint32(bytes[8:], i.Seq)
+	icv := bytes[12:]
+	n := copy(icv, i.AuthenticationData)
+	copy(icv[n:], lotsOfZeros[:])
+	if !opts.ComputeChecksums || i.icv == nil {
+		return nil
+	}
+
+	copy(icv, lotsOfZeros[:])
+	data, err := i.icvInput(b.Bytes(), opts)
+	if err != nil {
+		return err
+	}
+	sum, err := i.icv(data)
+	if err != nil {
+		return err
+	}
+	if len(sum) > len(icv) {
+		return fmt.Errorf("AH ICV of %d bytes does not fit in %d bytes of authentication data", len(sum), len(icv))
+	}
+	copy(icv, sum)
+	i.AuthenticationData = icv[:len(i.AuthenticationData)]
+	return nil
+}
+
+// icvInput returns the IP packet the ICV is computed over, given the
+// serialized AH header and payload.
+func (i *IPSecAH) icvInput(ah []byte, opts gopacket.SerializeOptions) ([]byte, error) {
+	// Serialize a copy of the network layer, so its fields are left for
+	// its own SerializeTo to set.
+	buf := gopacket.NewSerializeBuffer()
+	tail, err := buf.AppendBytes(len(ah))
+	if err != nil {
+		return nil, err
+	}
+	copy(tail, ah)
+	switch ip := i.network.(type) {
+	case *IPv4:
+		c := *ip
+		if err := c.SerializeTo(buf, opts); err != nil {
+			return nil, err
+		}
+		zeroIPv4MutableFields(buf.Bytes())
+	case *IPv6:
+		c := *ip
+		if err := c.SerializeTo(buf, opts); err != nil {
+			return nil, err
+		}
+		zeroIPv6MutableFields(buf.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+// zeroIPv4MutableFields zeroes the TOS, flags, fragment offset, TTL and
+// checksum of an IPv4 header, and the options other than those RFC 4302
+// lists as immutable.
+func zeroIPv4MutableFields(data []byte) {
+	data[1] = 0
+	data[6], data[7], data[8] = 0, 0, 0
+	data[10], data[11] = 0, 0
+	options := data[20 : int(data[0]&0x0f)*4]
+	for len(options) > 0 {
+		switch options[0] {
+		case 0: // end of option list
+			return
+		case 1: // no operation
+			options = options[1:]
+			continue
+		}
+		n := len(options)
+		if len(options) >= 2 && int(options[1]) >= 2 && int(options[1]) <= len(options) {
+			n = int(options[1])
+		}
+		switch options[0] {
+		case 130, 133, 134, 149:
+			// Security, extended security, commercial security and sender
+			// directed multi-destination delivery.
+		default:
+			copy(options[:n], lotsOfZeros[:])
+		}
+		options = options[n:]
+	}
+}
+
+// zeroIPv6MutableFields zeroes the traffic class, flow label and hop limit
+// of an IPv6 header, and the data of the hop-by-hop options which may
+// change en route.
+func zeroIPv6MutableFields(data []byte) {
+	data[0] &= 0xf0
+	data[1], data[2], data[3] = 0, 0, 0
+	data[7] = 0
+	if IPProtocol(data[6]) != IPProtocolIPv6HopByHop || len(data) < 42 {
+		return
+	}
+	end := 40 + (int(data[41])+1)*8
+	if end > len(data) {
+		return
+	}
+	options := data[42:end]
+	for len(options) > 0 {
+		if options[0] == 0 { // Pad1
+			options = options[1:]
+			continue
+		}
+		if len(options) < 2 || int(options[1])+2 > len(options) {
+			return
+		}
+		n := int(options[1]) + 2
+		// The third highest bit of the option type is set for options
+		// whose data may change en route.
+		if options[0]&0x20 != 0 {
+			copy(options[2:n], lotsOfZeros[:])
+		}
+		options = options[n:]
+	}
+}
+
 func decodeIPSecAH(data []byte, p gopacket.PacketBuilder) error {
 	if len(data) < 12 {
 		p.SetTruncated()
@@ -7,9 +7,14 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"net"
 	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 // testPacketIPSecAHTransport is the packet:
@@ -56,6 +61,99 @@ func TestPacketIPSecAHTransport(t *testing.T) {
 	}
 }
 
+func TestIPSecAHSerialize(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIPSecAHTransport, LinkTypeEthernet, gopacket.Default)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializePacket(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketIPSecAHTransport) {
+		t.Errorf("serialized\n%x\nwant\n%x", buf.Bytes(), testPacketIPSecAHTransport)
+	}
+}
+
+func hmacSHA196(key []byte, seen *[]byte) AHICVFunc {
+	return func(data []byte) ([]byte, error) {
+		*seen = append([]byte(nil), data...)
+		mac := hmac.New(sha1.New, key)
+		mac.Write(data)
+		return mac.Sum(nil)[:12], nil
+	}
+}
+
+func TestIPSecAHICV(t *testing.T) {
+	key := []byte("0123456789abcdefghij")
+	payload := gopacket.Payload("conformance test payload")
+	for _, network := range []gopacket.NetworkLayer{
+		&IPv4{Version: 4, TOS: 0x10, TTL: 64, Flags: IPv4DontFragment, Protocol: IPProtocolAH,
+			SrcIP: net.IP{192, 168, 1, 1}, DstIP: net.IP{192, 168, 1, 2}},
+		&IPv6{Version: 6, TrafficClass: 0x20, FlowLabel: 0x12345, HopLimit: 64, NextHeader: IPProtocolAH,
+			SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")},
+	} {
+		var seen []byte
+		ah := &IPSecAH{SPI: 0x101, Seq: 1, AuthenticationData: make([]byte, 12)}
+		ah.NextHeader = IPProtocolNoNextHeader
+		if err := ah.SetICVFunc(network, hmacSHA196(key, &seen)); err != nil {
+			t.Fatal(err)
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, network.(gopacket.SerializableLayer), ah, payload); err != nil {
+			t.Fatalf("%v: %v", network.LayerType(), err)
+		}
+		data := buf.Bytes()
+		if len(seen) != len(data) {
+			t.Fatalf("%v: ICV computed over %d bytes, packet is %d", network.LayerType(), len(seen), len(data))
+		}
+
+		// Zero the mutable fields and the ICV by hand and check the ICV.
+		want := append([]byte(nil), data...)
+		hdr := 20
+		if _, ok := network.(*IPv4); ok {
+			want[1], want[6], want[7], want[8], want[10], want[11] = 0, 0, 0, 0, 0, 0
+		} else {
+			hdr = 40
+			want[0], want[1], want[2], want[3], want[7] = 0x60, 0, 0, 0, 0
+		}
+		// 12 bytes of header and 12 bytes of ICV need no padding for either
+		// IP version.
+		const wantLen = 24
+		if int(data[hdr+1]) != wantLen/4-2 {
+			t.Errorf("%v: got AH length field %d", network.LayerType(), data[hdr+1])
+		}
+		icv := append([]byte(nil), want[hdr+12:hdr+24]...)
+		copy(want[hdr+12:hdr+24], make([]byte, 12))
+		if !bytes.Equal(seen, want) {
+			t.Errorf("%v: ICV input\n%x\nwant\n%x", network.LayerType(), seen, want)
+		}
+		mac := hmac.New(sha1.New, key)
+		mac.Write(want)
+		if !bytes.Equal(icv, mac.Sum(nil)[:12]) || !bytes.Equal(ah.AuthenticationData, icv) {
+			t.Errorf("%v: got ICV %x, AuthenticationData %x", network.LayerType(), icv, ah.AuthenticationData)
+		}
+	}
+}
+
+func TestIPSecAHPadding(t *testing.T) {
+	// A 16 byte ICV is padded to 64 bits for IPv6, but not for IPv4.
+	for _, test := range []struct {
+		network gopacket.NetworkLayer
+		want    int
+	}{{&IPv4{}, 28}, {&IPv6{}, 32}} {
+		ah := &IPSecAH{AuthenticationData: make([]byte, 16)}
+		if err := ah.SetICVFunc(test.network, nil); err != nil {
+			t.Fatal(err)
+		}
+		buf := gopacket.NewSerializeBuffer()
+		if err := ah.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Bytes()) != test.want || ah.ActualLength != test.want || int(ah.HeaderLength) != test.want/4-2 {
+			t.Errorf("%v: got %d bytes, header length %d", test.network.LayerType(), len(buf.Bytes()), ah.HeaderLength)
+		}
+	}
+}
+
 func BenchmarkDecodePacketIPSecAHTransport(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		gopacket.NewPacket(testPacketIPSecAHTransport, LinkTypeEthernet, gopacket.NoCopy)
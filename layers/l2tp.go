@@ -0,0 +1,503 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// L2TPMessageType is the type of an L2TP control message.
+type L2TPMessageType uint16
+
+// L2TP control message types, from RFC 2661 and RFC 3931. Zero length body
+// acknowledgements have no message type AVP, and are given type
+// L2TPMessageTypeZLB.
+const (
+	L2TPMessageTypeZLB     L2TPMessageType = 0
+	L2TPMessageTypeSCCRQ   L2TPMessageType = 1
+	L2TPMessageTypeSCCRP   L2TPMessageType = 2
+	L2TPMessageTypeSCCCN   L2TPMessageType = 3
+	L2TPMessageTypeStopCCN L2TPMessageType = 4
+	L2TPMessageTypeHello   L2TPMessageType = 6
+	L2TPMessageTypeOCRQ    L2TPMessageType = 7
+	L2TPMessageTypeOCRP    L2TPMessageType = 8
+	L2TPMessageTypeOCCN    L2TPMessageType = 9
+	L2TPMessageTypeICRQ    L2TPMessageType = 10
+	L2TPMessageTypeICRP    L2TPMessageType = 11
+	L2TPMessageTypeICCN    L2TPMessageType = 12
+	L2TPMessageTypeCDN     L2TPMessageType = 14
+	L2TPMessageTypeWEN     L2TPMessageType = 15
+	L2TPMessageTypeSLI     L2TPMessageType = 16
+	L2TPMessageTypeACK     L2TPMessageType = 20
+)
+
+func (t L2TPMessageType) String() string {
+	switch t {
+	case L2TPMessageTypeZLB:
+		return "ZLB"
+	case L2TPMessageTypeSCCRQ:
+		return "SCCRQ"
+	case L2TPMessageTypeSCCRP:
+		return "SCCRP"
+	case L2TPMessageTypeSCCCN:
+		return "SCCCN"
+	case L2TPMessageTypeStopCCN:
+		return "StopCCN"
+	case L2TPMessageTypeHello:
+		return "Hello"
+	case L2TPMessageTypeOCRQ:
+		return "OCRQ"
+	case L2TPMessageTypeOCRP:
+		return "OCRP"
+	case L2TPMessageTypeOCCN:
+		return "OCCN"
+	case L2TPMessageTypeICRQ:
+		return "ICRQ"
+	case L2TPMessageTypeICRP:
+		return "ICRP"
+	case L2TPMessageTypeICCN:
+		return "ICCN"
+	case L2TPMessageTypeCDN:
+		return "CDN"
+	case L2TPMessageTypeWEN:
+		return "WEN"
+	case L2TPMessageTypeSLI:
+		return "SLI"
+	case L2TPMessageTypeACK:
+		return "ACK"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
+// L2TPAVPType is the attribute type of an L2TP AVP of the IETF vendor ID 0.
+type L2TPAVPType uint16
+
+// L2TP AVP types, from RFC 2661 and RFC 3931.
+const (
+	L2TPAVPTypeMessageType                  L2TPAVPType = 0
+	L2TPAVPTypeResultCode                   L2TPAVPType = 1
+	L2TPAVPTypeProtocolVersion              L2TPAVPType = 2
+	L2TPAVPTypeFirmwareRevision             L2TPAVPType = 6
+	L2TPAVPTypeHostName                     L2TPAVPType = 7
+	L2TPAVPTypeVendorName                   L2TPAVPType = 8
+	L2TPAVPTypeAssignedTunnelID             L2TPAVPType = 9
+	L2TPAVPTypeAssignedSessionID            L2TPAVPType = 14
+	L2TPAVPTypeRouterID                     L2TPAVPType = 60
+	L2TPAVPTypeAssignedControlConnectionID  L2TPAVPType = 61
+	L2TPAVPTypePseudowireCapabilitiesList   L2TPAVPType = 62
+	L2TPAVPTypeLocalSessionID               L2TPAVPType = 63
+	L2TPAVPTypeRemoteSessionID              L2TPAVPType = 64
+	L2TPAVPTypeAssignedCookie               L2TPAVPType = 65
+	L2TPAVPTypeRemoteEndID                  L2TPAVPType = 66
+	L2TPAVPTypePseudowireType               L2TPAVPType = 68
+	L2TPAVPTypeL2SpecificSublayer           L2TPAVPType = 69
+	L2TPAVPTypeDataSequencing               L2TPAVPType = 70
+	L2TPAVPTypeCircuitStatus                L2TPAVPType = 71
+	L2TPAVPTypePreferredLanguage            L2TPAVPType = 72
+	L2TPAVPTypeControlMessageAuthentication L2TPAVPType = 73
+)
+
+// L2TPAVP is an attribute value pair of an L2TP control message.
+type L2TPAVP struct {
+	// Mandatory is set if the receiver must understand the AVP. Hidden is
+	// set if Value is encrypted.
+	Mandatory, Hidden bool
+	VendorID          uint16
+	Type              L2TPAVPType
+	Value             []byte
+}
+
+// L2TPv3PseudowireType is the type of the layer 2 frames carried by an
+// L2TPv3 session.
+type L2TPv3PseudowireType uint16
+
+// L2TPv3 pseudowire types, from the IANA registry.
+const (
+	L2TPv3PseudowireTypeFrameRelay   L2TPv3PseudowireType = 0x0001
+	L2TPv3PseudowireTypeEthernetVLAN L2TPv3PseudowireType = 0x0004
+	L2TPv3PseudowireTypeEthernet     L2TPv3PseudowireType = 0x0005
+	L2TPv3PseudowireTypeHDLC         L2TPv3PseudowireType = 0x0006
+	L2TPv3PseudowireTypePPP          L2TPv3PseudowireType = 0x0007
+	L2TPv3PseudowireTypeIP           L2TPv3PseudowireType = 0x000b
+)
+
+func (t L2TPv3PseudowireType) String() string {
+	switch t {
+	case L2TPv3PseudowireTypeFrameRelay:
+		return "Frame Relay"
+	case L2TPv3PseudowireTypeEthernetVLAN:
+		return "Ethernet VLAN"
+	case L2TPv3PseudowireTypeEthernet:
+		return "Ethernet"
+	case L2TPv3PseudowireTypeHDLC:
+		return "HDLC"
+	case L2TPv3PseudowireTypePPP:
+		return "PPP"
+	case L2TPv3PseudowireTypeIP:
+		return "IP"
+	}
+	return fmt.Sprintf("Unknown(%#04x)", uint16(t))
+}
+
+// L2TPv3SessionConfig describes the data messages of L2TPv3 sessions, which
+// is negotiated by control messages or configured statically, and cannot be
+// told from the data messages themselves.
+type L2TPv3SessionConfig struct {
+	// CookieLength is the length of the cookie following the session ID,
+	// 0, 4 or 8 bytes.
+	CookieLength int
+	// DefaultL2SpecificSublayer is set if the cookie is followed by the 4
+	// byte default L2-Specific Sublayer of RFC 3931, holding a sequence
+	// number.
+	DefaultL2SpecificSublayer bool
+	// PseudowireType is the type of the frames carried.
+	PseudowireType L2TPv3PseudowireType
+}
+
+// L2TPv3DataConfig is used to decode the data messages of all L2TPv3
+// sessions. It is initially set to Ethernet pseudowires without cookies
+// or L2-Specific Sublayer, a common static configuration. If your sessions
+// are configured differently, you may reset this.
+var L2TPv3DataConfig = L2TPv3SessionConfig{PseudowireType: L2TPv3PseudowireTypeEthernet}
+
+const (
+	l2tpFlagType     = 0x8000
+	l2tpFlagLength   = 0x4000
+	l2tpFlagSequence = 0x0800
+)
+
+// L2TPv3 is a Layer Two Tunneling Protocol version 3 message (RFC 3931),
+// carried directly over IP (protocol 115) or over UDP (port 1701).
+//
+// Control messages hold AVPs, some of which are decoded into the fields
+// below. Data messages carry the frames of a pseudowire session, decoded
+// according to L2TPv3DataConfig.
+type L2TPv3 struct {
+	BaseLayer
+	// OverIP is set for messages carried directly over IP, which are
+	// decoded as LayerTypeL2TPv3IP. When used as a DecodingLayer, it must
+	// be set before decoding to decode messages over IP.
+	OverIP bool
+	// Control is set for control messages, otherwise the message is a
+	// data message.
+	Control bool
+	// Version is the protocol version of messages over UDP.
+	Version uint8
+
+	// Length, ControlConnectionID, Ns, Nr and AVPs are the header and the
+	// AVPs of control messages.
+	Length              uint16
+	ControlConnectionID uint32
+	Ns, Nr              uint16
+	AVPs                []L2TPAVP
+
+	// MessageType, HostName, AssignedControlConnectionID, LocalSessionID,
+	// RemoteSessionID and PseudowireType are decoded from the AVPs of
+	// control messages which are not hidden. They are ignored by
+	// SerializeTo.
+	MessageType                 L2TPMessageType
+	HostName                    string
+	AssignedControlConnectionID uint32
+	LocalSessionID              uint32
+	RemoteSessionID             uint32
+	PseudowireType              L2TPv3PseudowireType
+
+	// SessionID and Cookie are the header of data messages. The default
+	// L2-Specific Sublayer follows if L2SpecificSublayer is set, holding
+	// SequenceNumber if Sequenced is set.
+	SessionID          uint32
+	Cookie             []byte
+	L2SpecificSublayer bool
+	Sequenced          bool
+	SequenceNumber     uint32
+	// DataPseudowireType is the type of the frames carried by data
+	// messages.
+	DataPseudowireType L2TPv3PseudowireType
+}
+
+// LayerType returns LayerTypeL2TPv3IP for messages over IP, LayerTypeL2TPv3
+// otherwise.
+func (l *L2TPv3) LayerType() gopacket.LayerType {
+	if l.OverIP {
+		return LayerTypeL2TPv3IP
+	}
+	return LayerTypeL2TPv3
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can
+// decode, which depends on OverIP.
+func (l *L2TPv3) CanDecode() gopacket.LayerClass {
+	return l.LayerType()
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (l *L2TPv3) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	l.Length, l.ControlConnectionID, l.Ns, l.Nr = 0, 0, 0, 0
+	l.AVPs = l.AVPs[:0]
+	l.MessageType, l.HostName = 0, ""
+	l.AssignedControlConnectionID, l.LocalSessionID, l.RemoteSessionID, l.PseudowireType = 0, 0, 0, 0
+	l.SessionID, l.Cookie, l.L2SpecificSublayer, l.Sequenced, l.SequenceNumber = 0, nil, false, false, 0
+	l.DataPseudowireType = 0
+
+	offset := 0
+	if l.OverIP {
+		if len(data) < 4 {
+			df.SetTruncated()
+			return errors.New("L2TPv3 message too short")
+		}
+		l.SessionID = binary.BigEndian.Uint32(data)
+		// Control messages are told by a zero session ID.
+		l.Control = l.SessionID == 0
+		l.Version = 3
+		offset = 4
+		if !l.Control {
+			return l.decodeData(data, offset, df)
+		}
+	}
+	if len(data) < offset+4 {
+		df.SetTruncated()
+		return errors.New("L2TPv3 message too short")
+	}
+	flags := binary.BigEndian.Uint16(data[offset:])
+	l.Version = uint8(flags & 0xf)
+	if l.Version != 3 {
+		return fmt.Errorf("unsupported L2TP version %d", l.Version)
+	}
+	if !l.OverIP {
+		l.Control = flags&l2tpFlagType != 0
+		if !l.Control {
+			// Skip the flags and the reserved field.
+			if len(data) < offset+8 {
+				df.SetTruncated()
+				return errors.New("L2TPv3 data message too short")
+			}
+			l.SessionID = binary.BigEndian.Uint32(data[offset+4:])
+			return l.decodeData(data, offset+8, df)
+		}
+	}
+	if flags&(l2tpFlagType|l2tpFlagLength|l2tpFlagSequence) != l2tpFlagType|l2tpFlagLength|l2tpFlagSequence {
+		return fmt.Errorf("invalid L2TPv3 control message flags %#04x", flags)
+	}
+	if len(data) < offset+12 {
+		df.SetTruncated()
+		return errors.New("L2TPv3 control message too short")
+	}
+	l.Length = binary.BigEndian.Uint16(data[offset+2:])
+	l.ControlConnectionID = binary.BigEndian.Uint32(data[offset+4:])
+	l.Ns = binary.BigEndian.Uint16(data[offset+8:])
+	l.Nr = binary.BigEndian.Uint16(data[offset+10:])
+	end := offset + int(l.Length)
+	if int(l.Length) < 12 {
+		return fmt.Errorf("invalid L2TPv3 control message length %d", l.Length)
+	}
+	if len(data) < end {
+		df.SetTruncated()
+		return errors.New("L2TPv3 control message too short")
+	}
+	if err := l.decodeAVPs(data[offset+12 : end]); err != nil {
+		return err
+	}
+	l.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+func (l *L2TPv3) decodeAVPs(data []byte) error {
+	for len(data) > 0 {
+		if len(data) < 6 {
+			return errors.New("L2TP AVP too short")
+		}
+		flags := binary.BigEndian.Uint16(data)
+		length := int(flags & 0x3ff)
+		if length < 6 || length > len(data) {
+			return fmt.Errorf("invalid L2TP AVP length %d", length)
+		}
+		avp := L2TPAVP{
+			Mandatory: flags&0x8000 != 0,
+			Hidden:    flags&0x4000 != 0,
+			VendorID:  binary.BigEndian.Uint16(data[2:]),
+			Type:      L2TPAVPType(binary.BigEndian.Uint16(data[4:])),
+			Value:     data[6:length],
+		}
+		l.AVPs = append(l.AVPs, avp)
+		data = data[length:]
+		if avp.VendorID != 0 || avp.Hidden {
+			continue
+		}
+		v := avp.Value
+		switch {
+		case avp.Type == L2TPAVPTypeMessageType && len(v) == 2:
+			l.MessageType = L2TPMessageType(binary.BigEndian.Uint16(v))
+		case avp.Type == L2TPAVPTypeHostName:
+			l.HostName = string(v)
+		case avp.Type == L2TPAVPTypeAssignedControlConnectionID && len(v) == 4:
+			l.AssignedControlConnectionID = binary.BigEndian.Uint32(v)
+		case avp.Type == L2TPAVPTypeLocalSessionID && len(v) == 4:
+			l.LocalSessionID = binary.BigEndian.Uint32(v)
+		case avp.Type == L2TPAVPTypeRemoteSessionID && len(v) == 4:
+			l.RemoteSessionID = binary.BigEndian.Uint32(v)
+		case avp.Type == L2TPAVPTypePseudowireType && len(v) == 2:
+			l.PseudowireType = L2TPv3PseudowireType(binary.BigEndian.Uint16(v))
+		}
+	}
+	return nil
+}
+
+func (l *L2TPv3) decodeData(data []byte, offset int, df gopacket.DecodeFeedback) error {
+	config := L2TPv3DataConfig
+	end := offset + config.CookieLength
+	if config.DefaultL2SpecificSublayer {
+		end += 4
+	}
+	if len(data) < end {
+		df.SetTruncated()
+		return errors.New("L2TPv3 data message too short")
+	}
+	l.Cookie = data[offset : offset+config.CookieLength]
+	if config.DefaultL2SpecificSublayer {
+		sublayer := binary.BigEndian.Uint32(data[end-4:])
+		l.L2SpecificSublayer = true
+		l.Sequenced = sublayer&0x40000000 != 0
+		l.SequenceNumber = sublayer & 0xffffff
+	}
+	l.DataPseudowireType = config.PseudowireType
+	l.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info. Control
+// messages are written with their AVPs, whose lengths are computed.
+func (l *L2TPv3) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if !l.Control {
+		return l.serializeData(b)
+	}
+	length := 12
+	for _, avp := range l.AVPs {
+		if len(avp.Value) > 0x3ff-6 {
+			return fmt.Errorf("L2TP AVP value of %d bytes too long", len(avp.Value))
+		}
+		length += 6 + len(avp.Value)
+	}
+	if length > 0xffff {
+		return fmt.Errorf("L2TPv3 control message of %d bytes too long", length)
+	}
+	if opts.FixLengths {
+		l.Length = uint16(length)
+	}
+	offset := 0
+	if l.OverIP {
+		offset = 4
+	}
+	bytes, err := b.PrependBytes(offset + length)
+	if err != nil {
+		return err
+	}
+	copy(bytes, lotsOfZeros[:offset])
+	binary.BigEndian.PutUint16(bytes[offset:], l2tpFlagType|l2tpFlagLength|l2tpFlagSequence|3)
+	binary.BigEndian.PutUint16(bytes[offset+2:], l.Length)
+	binary.BigEndian.PutUint32(bytes[offset+4:], l.ControlConnectionID)
+	binary.BigEndian.PutUint16(bytes[offset+8:], l.Ns)
+	binary.BigEndian.PutUint16(bytes[offset+10:], l.Nr)
+	i := offset + 12
+	for _, avp := range l.AVPs {
+		flags := uint16(6 + len(avp.Value))
+		if avp.Mandatory {
+			flags |= 0x8000
+		}
+		if avp.Hidden {
+			flags |= 0x4000
+		}
+		binary.BigEndian.PutUint16(bytes[i:], flags)
+		binary.BigEndian.PutUint16(bytes[i+2:], avp.VendorID)
+		binary.BigEndian.PutUint16(bytes[i+4:], uint16(avp.Type))
+		copy(bytes[i+6:], avp.Value)
+		i += 6 + len(avp.Value)
+	}
+	return nil
+}
+
+func (l *L2TPv3) serializeData(b gopacket.SerializeBuffer) error {
+	if l.SessionID == 0 {
+		return errors.New("L2TPv3 data messages must have a non-zero session ID")
+	}
+	switch len(l.Cookie) {
+	case 0, 4, 8:
+	default:
+		return fmt.Errorf("invalid L2TPv3 cookie length %d", len(l.Cookie))
+	}
+	offset := 4
+	if !l.OverIP {
+		offset = 8
+	}
+	length := offset + len(l.Cookie)
+	if l.L2SpecificSublayer {
+		length += 4
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if !l.OverIP {
+		binary.BigEndian.PutUint16(bytes, 3)
+		bytes[2], bytes[3] = 0, 0
+	}
+	binary.BigEndian.PutUint32(bytes[offset-4:], l.SessionID)
+	copy(bytes[offset:], l.Cookie)
+	if l.L2SpecificSublayer {
+		sublayer := l.SequenceNumber & 0xffffff
+		if l.Sequenced {
+			sublayer |= 0x40000000
+		}
+		binary.BigEndian.PutUint32(bytes[length-4:], sublayer)
+	}
+	return nil
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer:
+// the frames carried by data messages.
+func (l *L2TPv3) NextLayerType() gopacket.LayerType {
+	if l.Control || len(l.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	switch l.DataPseudowireType {
+	case L2TPv3PseudowireTypeEthernet, L2TPv3PseudowireTypeEthernetVLAN:
+		return LayerTypeEthernet
+	case L2TPv3PseudowireTypePPP:
+		return LayerTypePPP
+	case L2TPv3PseudowireTypeHDLC:
+		return LayerTypeCiscoHDLC
+	case L2TPv3PseudowireTypeFrameRelay:
+		return LayerTypeFrameRelay
+	case L2TPv3PseudowireTypeIP:
+		switch l.Payload[0] >> 4 {
+		case 4:
+			return LayerTypeIPv4
+		case 6:
+			return LayerTypeIPv6
+		}
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeL2TPv3(data []byte, p gopacket.PacketBuilder) error {
+	// L2TP version 2 shares the UDP port of version 3.
+	if len(data) >= 2 && data[1]&0xf != 3 {
+		return p.NextDecoder(gopacket.LayerTypePayload)
+	}
+	l := &L2TPv3{}
+	return decodingLayerDecoder(l, data, p)
+}
+
+func decodeL2TPv3IP(data []byte, p gopacket.PacketBuilder) error {
+	l := &L2TPv3{OverIP: true}
+	return decodingLayerDecoder(l, data, p)
+}
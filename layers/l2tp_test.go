@@ -0,0 +1,181 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testL2TPv3SCCRQ is an L2TPv3 SCCRQ over IP, with message type, host name
+// and assigned control connection ID AVPs.
+var testL2TPv3SCCRQ = []byte{
+	0x00, 0x00, 0x00, 0x00, // session ID 0: control message
+	0xc8, 0x03, 0x00, 0x28, // flags, version 3, length 40
+	0x00, 0x00, 0x00, 0x00, // control connection ID
+	0x00, 0x00, 0x00, 0x00, // Ns, Nr
+	0x80, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // message type SCCRQ
+	0x80, 0x0a, 0x00, 0x00, 0x00, 0x07, 'l', 'a', 'c', '1', // host name
+	0x80, 0x0a, 0x00, 0x00, 0x00, 0x3d, 0x12, 0x34, 0x56, 0x78, // assigned control connection ID
+}
+
+func TestL2TPv3ControlOverIP(t *testing.T) {
+	p := gopacket.NewPacket(testL2TPv3SCCRQ, LayerTypeL2TPv3IP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeL2TPv3IP}, t)
+	l := p.Layer(LayerTypeL2TPv3IP).(*L2TPv3)
+	if !l.OverIP || !l.Control || l.Version != 3 || l.Length != 40 {
+		t.Errorf("unexpected header %+v", l)
+	}
+	if l.MessageType != L2TPMessageTypeSCCRQ || l.HostName != "lac1" || l.AssignedControlConnectionID != 0x12345678 {
+		t.Errorf("unexpected AVPs decoded: %v %q %#x", l.MessageType, l.HostName, l.AssignedControlConnectionID)
+	}
+	if len(l.AVPs) != 3 || !l.AVPs[1].Mandatory || l.AVPs[1].Type != L2TPAVPTypeHostName {
+		t.Errorf("unexpected AVPs %+v", l.AVPs)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := l.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testL2TPv3SCCRQ) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testL2TPv3SCCRQ)
+	}
+}
+
+func uint32AVP(typ L2TPAVPType, v uint32) L2TPAVP {
+	return L2TPAVP{Mandatory: true, Type: typ, Value: []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}}
+}
+
+func serializeL2TPv3(t *testing.T, overIP bool, ls ...gopacket.SerializableLayer) []byte {
+	ip := &IPv4{Version: 4, TTL: 64, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	outer := []gopacket.SerializableLayer{
+		&Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{6, 7, 8, 9, 10, 11}, EthernetType: EthernetTypeIPv4},
+		ip,
+	}
+	if overIP {
+		ip.Protocol = IPProtocolL2TP
+	} else {
+		ip.Protocol = IPProtocolUDP
+		udp := &UDP{SrcPort: 1701, DstPort: 1701}
+		udp.SetNetworkLayerForChecksum(ip)
+		outer = append(outer, udp)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, append(outer, ls...)...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestL2TPv3ControlOverUDP(t *testing.T) {
+	data := serializeL2TPv3(t, false, &L2TPv3{
+		Control:             true,
+		ControlConnectionID: 0x12345678,
+		Ns:                  2,
+		Nr:                  3,
+		AVPs: []L2TPAVP{
+			{Mandatory: true, Type: L2TPAVPTypeMessageType, Value: []byte{0, byte(L2TPMessageTypeICRQ)}},
+			uint32AVP(L2TPAVPTypeLocalSessionID, 0xabcd),
+			uint32AVP(L2TPAVPTypeRemoteSessionID, 0),
+			{Mandatory: true, Type: L2TPAVPTypePseudowireType, Value: []byte{0, byte(L2TPv3PseudowireTypeEthernet)}},
+			{VendorID: 9, Type: L2TPAVPTypeHostName, Value: []byte("vendor")},
+		},
+	})
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeL2TPv3}, t)
+	l := p.Layer(LayerTypeL2TPv3).(*L2TPv3)
+	if l.OverIP || !l.Control || l.ControlConnectionID != 0x12345678 || l.Ns != 2 || l.Nr != 3 || l.Length != 12+8+10+10+8+12 {
+		t.Errorf("unexpected header %+v", l)
+	}
+	if l.MessageType != L2TPMessageTypeICRQ || l.LocalSessionID != 0xabcd || l.RemoteSessionID != 0 || l.PseudowireType != L2TPv3PseudowireTypeEthernet {
+		t.Errorf("unexpected AVPs decoded %+v", l)
+	}
+	if l.HostName != "" {
+		t.Errorf("decoded host name %q of another vendor", l.HostName)
+	}
+}
+
+func TestL2TPv3DataOverIP(t *testing.T) {
+	inner := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{192, 168, 0, 1}, DstIP: net.IP{192, 168, 0, 2}}
+	udp := &UDP{SrcPort: 1000, DstPort: 2000}
+	udp.SetNetworkLayerForChecksum(inner)
+	data := serializeL2TPv3(t, true,
+		&L2TPv3{OverIP: true, SessionID: 0x1234},
+		&Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{6, 7, 8, 9, 10, 11}, EthernetType: EthernetTypeIPv4},
+		inner, udp, gopacket.Payload("hello"))
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeL2TPv3IP, LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	l := p.Layer(LayerTypeL2TPv3IP).(*L2TPv3)
+	if l.Control || l.SessionID != 0x1234 || l.DataPseudowireType != L2TPv3PseudowireTypeEthernet || len(l.Contents) != 4 {
+		t.Errorf("unexpected header %+v", l)
+	}
+}
+
+func TestL2TPv3DataOverUDPConfig(t *testing.T) {
+	defer func(c L2TPv3SessionConfig) { L2TPv3DataConfig = c }(L2TPv3DataConfig)
+	L2TPv3DataConfig = L2TPv3SessionConfig{CookieLength: 4, DefaultL2SpecificSublayer: true, PseudowireType: L2TPv3PseudowireTypePPP}
+
+	inner := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{192, 168, 0, 1}, DstIP: net.IP{192, 168, 0, 2}}
+	udp := &UDP{SrcPort: 1000, DstPort: 2000}
+	udp.SetNetworkLayerForChecksum(inner)
+	want := &L2TPv3{SessionID: 7, Cookie: []byte{1, 2, 3, 4}, L2SpecificSublayer: true, Sequenced: true, SequenceNumber: 0x123456}
+	data := serializeL2TPv3(t, false, want, &PPP{PPPType: PPPTypeIPv4}, inner, udp, gopacket.Payload("hello"))
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeL2TPv3, LayerTypePPP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	got := p.Layer(LayerTypeL2TPv3).(*L2TPv3)
+	want.Version = 3
+	want.DataPseudowireType = L2TPv3PseudowireTypePPP
+	want.BaseLayer = got.BaseLayer
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestL2TPv2OverUDP(t *testing.T) {
+	// A version 2 data message is not decoded.
+	data := serializeL2TPv3(t, false, gopacket.Payload{0x00, 0x02, 0x00, 0x01, 0x00, 0x02, 0xff, 0x03})
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+}
+
+func TestL2TPv3Errors(t *testing.T) {
+	for _, data := range [][]byte{
+		testL2TPv3SCCRQ[:3],
+		testL2TPv3SCCRQ[:14],
+		testL2TPv3SCCRQ[:len(testL2TPv3SCCRQ)-1],
+	} {
+		p := gopacket.NewPacket(data, LayerTypeL2TPv3IP, gopacket.Default)
+		if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+			t.Errorf("%x: expected truncated packet", data)
+		}
+	}
+	bad := append([]byte(nil), testL2TPv3SCCRQ...)
+	bad[17] = 0x05 // AVP length 5
+	if p := gopacket.NewPacket(bad, LayerTypeL2TPv3IP, gopacket.Default); p.ErrorLayer() == nil {
+		t.Error("expected an error decoding an invalid AVP length")
+	}
+}
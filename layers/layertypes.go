@@ -148,6 +148,63 @@ var (
 	LayerTypeASFPresencePong              = gopacket.RegisterLayerType(144, gopacket.LayerTypeMetadata{Name: "ASFPresencePong", Decoder: gopacket.DecodeFunc(decodeASFPresencePong)})
 	LayerTypeERSPANII                     = gopacket.RegisterLayerType(145, gopacket.LayerTypeMetadata{Name: "ERSPAN Type II", Decoder: gopacket.DecodeFunc(decodeERSPANII)})
 	LayerTypeRADIUS                       = gopacket.RegisterLayerType(146, gopacket.LayerTypeMetadata{Name: "RADIUS", Decoder: gopacket.DecodeFunc(decodeRADIUS)})
+	LayerTypeICMPv4Timestamp              = gopacket.RegisterLayerType(147, gopacket.LayerTypeMetadata{Name: "ICMPv4Timestamp", Decoder: gopacket.DecodeFunc(decodeICMPv4Timestamp)})
+	LayerTypeICMPv4AddressMask            = gopacket.RegisterLayerType(148, gopacket.LayerTypeMetadata{Name: "ICMPv4AddressMask", Decoder: gopacket.DecodeFunc(decodeICMPv4AddressMask)})
+	LayerTypeProfinet                     = gopacket.RegisterLayerType(149, gopacket.LayerTypeMetadata{Name: "Profinet", Decoder: gopacket.DecodeFunc(decodeProfinet)})
+	LayerTypeProfinetDCP                  = gopacket.RegisterLayerType(150, gopacket.LayerTypeMetadata{Name: "ProfinetDCP", Decoder: gopacket.DecodeFunc(decodeProfinetDCP)})
+	LayerTypeProfinetAlarm                = gopacket.RegisterLayerType(151, gopacket.LayerTypeMetadata{Name: "ProfinetAlarm", Decoder: gopacket.DecodeFunc(decodeProfinetAlarm)})
+	LayerTypeRTSP                         = gopacket.RegisterLayerType(152, gopacket.LayerTypeMetadata{Name: "RTSP", Decoder: gopacket.DecodeFunc(decodeRTSP)})
+	LayerTypeRTP                          = gopacket.RegisterLayerType(153, gopacket.LayerTypeMetadata{Name: "RTP", Decoder: gopacket.DecodeFunc(decodeRTP)})
+	LayerTypeMPEGTS                       = gopacket.RegisterLayerType(154, gopacket.LayerTypeMetadata{Name: "MPEGTS", Decoder: gopacket.DecodeFunc(decodeMPEGTS)})
+	LayerTypeSRT                          = gopacket.RegisterLayerType(155, gopacket.LayerTypeMetadata{Name: "SRT", Decoder: gopacket.DecodeFunc(decodeSRT)})
+	LayerTypeTFTP                         = gopacket.RegisterLayerType(156, gopacket.LayerTypeMetadata{Name: "TFTP", Decoder: gopacket.DecodeFunc(decodeTFTP)})
+	LayerTypeCFM                          = gopacket.RegisterLayerType(157, gopacket.LayerTypeMetadata{Name: "CFM", Decoder: gopacket.DecodeFunc(decodeCFM)})
+	LayerTypeERSPANIII                    = gopacket.RegisterLayerType(158, gopacket.LayerTypeMetadata{Name: "ERSPAN Type III", Decoder: gopacket.DecodeFunc(decodeERSPANIII)})
+	LayerTypeCiscoHDLC                    = gopacket.RegisterLayerType(159, gopacket.LayerTypeMetadata{Name: "CiscoHDLC", Decoder: gopacket.DecodeFunc(decodeCiscoHDLC)})
+	LayerTypeSLIP                         = gopacket.RegisterLayerType(160, gopacket.LayerTypeMetadata{Name: "SLIP", Decoder: gopacket.DecodeFunc(decodeSLIP)})
+	LayerTypeFrameRelay                   = gopacket.RegisterLayerType(161, gopacket.LayerTypeMetadata{Name: "FrameRelay", Decoder: gopacket.DecodeFunc(decodeFrameRelay)})
+	LayerTypeSunATM                       = gopacket.RegisterLayerType(162, gopacket.LayerTypeMetadata{Name: "SunATM", Decoder: gopacket.DecodeFunc(decodeSunATM)})
+	LayerTypeRFC2684                      = gopacket.RegisterLayerType(163, gopacket.LayerTypeMetadata{Name: "RFC2684", Decoder: gopacket.DecodeFunc(decodeRFC2684)})
+	LayerTypePWControlWord                = gopacket.RegisterLayerType(164, gopacket.LayerTypeMetadata{Name: "PWControlWord", Decoder: gopacket.DecodeFunc(decodePWControlWord)})
+	LayerTypeL2TPv3                       = gopacket.RegisterLayerType(165, gopacket.LayerTypeMetadata{Name: "L2TPv3", Decoder: gopacket.DecodeFunc(decodeL2TPv3)})
+	LayerTypeL2TPv3IP                     = gopacket.RegisterLayerType(166, gopacket.LayerTypeMetadata{Name: "L2TPv3IP", Decoder: gopacket.DecodeFunc(decodeL2TPv3IP)})
+	LayerTypeS1AP                         = gopacket.RegisterLayerType(167, gopacket.LayerTypeMetadata{Name: "S1AP", Decoder: gopacket.DecodeFunc(decodeS1AP)})
+	LayerTypeNGAP                         = gopacket.RegisterLayerType(168, gopacket.LayerTypeMetadata{Name: "NGAP", Decoder: gopacket.DecodeFunc(decodeNGAP)})
+	LayerTypeNASEPS                       = gopacket.RegisterLayerType(169, gopacket.LayerTypeMetadata{Name: "NASEPS", Decoder: gopacket.DecodeFunc(decodeNASEPS)})
+	LayerTypeNAS5GS                       = gopacket.RegisterLayerType(170, gopacket.LayerTypeMetadata{Name: "NAS5GS", Decoder: gopacket.DecodeFunc(decodeNAS5GS)})
+	LayerTypePFCP                         = gopacket.RegisterLayerType(171, gopacket.LayerTypeMetadata{Name: "PFCP", Decoder: gopacket.DecodeFunc(decodePFCP)})
+	LayerTypeTeredo                       = gopacket.RegisterLayerType(172, gopacket.LayerTypeMetadata{Name: "Teredo", Decoder: gopacket.DecodeFunc(decodeTeredo)})
+	LayerTypeSCTPForwardTSN               = gopacket.RegisterLayerType(173, gopacket.LayerTypeMetadata{Name: "SCTPForwardTSN", Decoder: nil})
+	LayerTypeSCTPAuth                     = gopacket.RegisterLayerType(174, gopacket.LayerTypeMetadata{Name: "SCTPAuth", Decoder: nil})
+	LayerTypeSCTPASCONF                   = gopacket.RegisterLayerType(175, gopacket.LayerTypeMetadata{Name: "SCTPASCONF", Decoder: nil})
+	LayerTypeSCTPASCONFAck                = gopacket.RegisterLayerType(176, gopacket.LayerTypeMetadata{Name: "SCTPASCONFAck", Decoder: nil})
+	LayerTypeSCTPPad                      = gopacket.RegisterLayerType(177, gopacket.LayerTypeMetadata{Name: "SCTPPad", Decoder: nil})
+	LayerTypeSCTPIData                    = gopacket.RegisterLayerType(178, gopacket.LayerTypeMetadata{Name: "SCTPIData", Decoder: nil})
+	LayerTypeSCTPReconfig                 = gopacket.RegisterLayerType(179, gopacket.LayerTypeMetadata{Name: "SCTPReconfig", Decoder: nil})
+	LayerTypeDCCP                         = gopacket.RegisterLayerType(180, gopacket.LayerTypeMetadata{Name: "DCCP", Decoder: gopacket.DecodeFunc(decodeDCCP)})
+	LayerTypeBluetoothH4                  = gopacket.RegisterLayerType(181, gopacket.LayerTypeMetadata{Name: "BluetoothH4", Decoder: gopacket.DecodeFunc(decodeBluetoothH4)})
+	LayerTypeBluetoothPHDR                = gopacket.RegisterLayerType(182, gopacket.LayerTypeMetadata{Name: "BluetoothPHDR", Decoder: gopacket.DecodeFunc(decodeBluetoothPHDR)})
+	LayerTypeBluetoothHCICommand          = gopacket.RegisterLayerType(183, gopacket.LayerTypeMetadata{Name: "BluetoothHCICommand", Decoder: gopacket.DecodeFunc(decodeBluetoothHCICommand)})
+	LayerTypeBluetoothHCIEvent            = gopacket.RegisterLayerType(184, gopacket.LayerTypeMetadata{Name: "BluetoothHCIEvent", Decoder: gopacket.DecodeFunc(decodeBluetoothHCIEvent)})
+	LayerTypeBluetoothHCIACL              = gopacket.RegisterLayerType(185, gopacket.LayerTypeMetadata{Name: "BluetoothHCIACL", Decoder: gopacket.DecodeFunc(decodeBluetoothHCIACL)})
+	LayerTypeBluetoothL2CAP               = gopacket.RegisterLayerType(186, gopacket.LayerTypeMetadata{Name: "BluetoothL2CAP", Decoder: gopacket.DecodeFunc(decodeBluetoothL2CAP)})
+	LayerTypeBluetoothATT                 = gopacket.RegisterLayerType(187, gopacket.LayerTypeMetadata{Name: "BluetoothATT", Decoder: gopacket.DecodeFunc(decodeBluetoothATT)})
+	LayerTypeIEEE802154                   = gopacket.RegisterLayerType(188, gopacket.LayerTypeMetadata{Name: "IEEE802154", Decoder: gopacket.DecodeFunc(decodeIEEE802154)})
+	LayerTypeZigbeeNWK                    = gopacket.RegisterLayerType(189, gopacket.LayerTypeMetadata{Name: "ZigbeeNWK", Decoder: gopacket.DecodeFunc(decodeZigbeeNWK)})
+	LayerTypeZigbeeAPS                    = gopacket.RegisterLayerType(190, gopacket.LayerTypeMetadata{Name: "ZigbeeAPS", Decoder: gopacket.DecodeFunc(decodeZigbeeAPS)})
+	LayerTypeAWDL                         = gopacket.RegisterLayerType(191, gopacket.LayerTypeMetadata{Name: "AWDL", Decoder: gopacket.DecodeFunc(decodeAWDL)})
+	LayerTypeCAN                          = gopacket.RegisterLayerType(192, gopacket.LayerTypeMetadata{Name: "CAN", Decoder: gopacket.DecodeFunc(decodeCAN)})
+	LayerTypeUSBPcap                      = gopacket.RegisterLayerType(193, gopacket.LayerTypeMetadata{Name: "USBPcap", Decoder: gopacket.DecodeFunc(decodeUSBPcap)})
+	LayerTypeDoIP                         = gopacket.RegisterLayerType(194, gopacket.LayerTypeMetadata{Name: "DoIP", Decoder: gopacket.DecodeFunc(decodeDoIP)})
+	LayerTypeUDS                          = gopacket.RegisterLayerType(195, gopacket.LayerTypeMetadata{Name: "UDS", Decoder: gopacket.DecodeFunc(decodeUDS)})
+	LayerTypeLCP                          = gopacket.RegisterLayerType(196, gopacket.LayerTypeMetadata{Name: "LCP", Decoder: gopacket.DecodeFunc(decodeLCP)})
+	LayerTypeIPCP                         = gopacket.RegisterLayerType(197, gopacket.LayerTypeMetadata{Name: "IPCP", Decoder: gopacket.DecodeFunc(decodeIPCP)})
+	LayerTypeIPv6CP                       = gopacket.RegisterLayerType(198, gopacket.LayerTypeMetadata{Name: "IPv6CP", Decoder: gopacket.DecodeFunc(decodeIPv6CP)})
+	LayerTypeCHAP                         = gopacket.RegisterLayerType(199, gopacket.LayerTypeMetadata{Name: "CHAP", Decoder: gopacket.DecodeFunc(decodeCHAP)})
+	LayerTypePAP                          = gopacket.RegisterLayerType(200, gopacket.LayerTypeMetadata{Name: "PAP", Decoder: gopacket.DecodeFunc(decodePAP)})
+	LayerTypeSLARP                        = gopacket.RegisterLayerType(201, gopacket.LayerTypeMetadata{Name: "SLARP", Decoder: gopacket.DecodeFunc(decodeSLARP)})
+	LayerTypeFrameRelayLMI                = gopacket.RegisterLayerType(202, gopacket.LayerTypeMetadata{Name: "FrameRelayLMI", Decoder: gopacket.DecodeFunc(decodeFrameRelayLMI)})
+	LayerTypeLinuxSLL2                    = gopacket.RegisterLayerType(203, gopacket.LayerTypeMetadata{Name: "Linux SLL2", Decoder: gopacket.DecodeFunc(decodeLinuxSLL2)})
 )
 
 var (
@@ -161,6 +218,7 @@ var (
 		LayerTypeTCP,
 		LayerTypeUDP,
 		LayerTypeSCTP,
+		LayerTypeDCCP,
 	})
 	// LayerClassIPControl contains TCP/IP control protocols.
 	LayerClassIPControl = gopacket.NewLayerClass([]gopacket.LayerType{
@@ -185,6 +243,13 @@ var (
 		LayerTypeSCTPAbort,
 		LayerTypeSCTPShutdownComplete,
 		LayerTypeSCTPCookieAck,
+		LayerTypeSCTPForwardTSN,
+		LayerTypeSCTPAuth,
+		LayerTypeSCTPASCONF,
+		LayerTypeSCTPASCONFAck,
+		LayerTypeSCTPPad,
+		LayerTypeSCTPIData,
+		LayerTypeSCTPReconfig,
 	})
 	// LayerClassIPv6Extension contains IPv6 extension headers.
 	LayerClassIPv6Extension = gopacket.NewLayerClass([]gopacket.LayerType{
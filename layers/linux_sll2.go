@@ -0,0 +1,116 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// linuxSLLProtocolLLC is the protocol of the Linux cooked capture headers of
+// 802.2 LLC frames, Linux's ETH_P_802_2.
+const linuxSLLProtocolLLC EthernetType = 0x0004
+
+// LinuxSLL2 is the version 2 of the Linux cooked capture header, which
+// libpcap writes for captures on the "any" device. Over LinuxSLL, it adds
+// the index of the interface packets were captured on.
+type LinuxSLL2 struct {
+	BaseLayer
+	EthernetType   EthernetType
+	InterfaceIndex uint32
+	// AddrType is the ARPHRD_ type of the interface, 1 for Ethernet.
+	AddrType   uint16
+	PacketType LinuxSLLPacketType
+	// AddrLen is the length of the link layer address of the sender, of
+	// which Addr holds up to 8 bytes.
+	AddrLen uint8
+	Addr    net.HardwareAddr
+}
+
+// LayerType returns LayerTypeLinuxSLL2.
+func (sll *LinuxSLL2) LayerType() gopacket.LayerType { return LayerTypeLinuxSLL2 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (sll *LinuxSLL2) CanDecode() gopacket.LayerClass {
+	return LayerTypeLinuxSLL2
+}
+
+// LinkFlow returns a flow from the address of the sender.
+func (sll *LinuxSLL2) LinkFlow() gopacket.Flow {
+	return gopacket.NewFlow(EndpointMAC, sll.Addr, nil)
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (sll *LinuxSLL2) NextLayerType() gopacket.LayerType {
+	if sll.EthernetType == linuxSLLProtocolLLC && len(sll.Payload) > 0 {
+		return LayerTypeLLC
+	}
+	return ethernetTypePayloadLayerType(sll.EthernetType, sll.Payload)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (sll *LinuxSLL2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 20 {
+		df.SetTruncated()
+		return fmt.Errorf("Linux SLL2 length %d too short, 20 required", len(data))
+	}
+	sll.EthernetType = EthernetType(binary.BigEndian.Uint16(data[0:2]))
+	sll.InterfaceIndex = binary.BigEndian.Uint32(data[4:8])
+	sll.AddrType = binary.BigEndian.Uint16(data[8:10])
+	sll.PacketType = LinuxSLLPacketType(data[10])
+	sll.AddrLen = data[11]
+	addrLen := sll.AddrLen
+	if addrLen > 8 {
+		addrLen = 8
+	}
+	sll.Addr = net.HardwareAddr(data[12 : 12+addrLen])
+	sll.BaseLayer = BaseLayer{Contents: data[:20], Payload: data[20:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (sll *LinuxSLL2) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(sll.Addr) > 8 {
+		return fmt.Errorf("Linux SLL2 address of %d bytes too long", len(sll.Addr))
+	}
+	bytes, err := b.PrependBytes(20)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		sll.AddrLen = uint8(len(sll.Addr))
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], uint16(sll.EthernetType))
+	bytes[2], bytes[3] = 0, 0
+	binary.BigEndian.PutUint32(bytes[4:8], sll.InterfaceIndex)
+	binary.BigEndian.PutUint16(bytes[8:10], sll.AddrType)
+	bytes[10] = uint8(sll.PacketType)
+	bytes[11] = sll.AddrLen
+	n := copy(bytes[12:20], sll.Addr)
+	for i := 12 + n; i < 20; i++ {
+		bytes[i] = 0
+	}
+	return nil
+}
+
+func decodeLinuxSLL2(data []byte, p gopacket.PacketBuilder) error {
+	sll := &LinuxSLL2{}
+	if err := sll.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(sll)
+	p.SetLinkLayer(sll)
+	if next := sll.NextLayerType(); next != gopacket.LayerTypeZero {
+		return p.NextDecoder(next)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestLinuxSLL2(t *testing.T) {
+	sll := &LinuxSLL2{
+		EthernetType:   EthernetTypeIPv4,
+		InterfaceIndex: 3,
+		AddrType:       1,
+		PacketType:     LinuxSLLPacketTypeOutgoing,
+		Addr:           net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+	}
+	data := serializeOverLink(t, sll)
+	header := []byte{
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 0x04, 0x06,
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+	}
+	if !bytes.Equal(data[:20], header) {
+		t.Fatalf("header %x, want %x", data[:20], header)
+	}
+	p := gopacket.NewPacket(data, LinkTypeLinuxSLL2, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeLinuxSLL2, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	got := p.Layer(LayerTypeLinuxSLL2).(*LinuxSLL2)
+	want := *sll
+	want.BaseLayer = got.BaseLayer
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+	if p.LinkLayer() != got {
+		t.Error("Linux SLL2 is not the link layer")
+	}
+	if lt, err := ParseLinkType("LINKTYPE_LINUX_SLL2"); err != nil || lt != LinkTypeLinuxSLL2 {
+		t.Errorf("ParseLinkType(LINKTYPE_LINUX_SLL2) = %v, %v", lt, err)
+	}
+
+	// Protocols gopacket does not decode are left as payload.
+	unknown := append([]byte{0x88, 0xb5}, data[2:]...)
+	p = gopacket.NewPacket(unknown, LinkTypeLinuxSLL2, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeLinuxSLL2, gopacket.LayerTypePayload}, t)
+
+	p = gopacket.NewPacket(data[:19], LinkTypeLinuxSLL2, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated header")
+	}
+}
@@ -9,6 +9,7 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/google/gopacket"
 )
@@ -135,10 +136,16 @@ func (l *LLC) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	var igFlag, crFlag byte
 	var length int
 
-	if l.Control&0xFF00 != 0 {
-		length = 4
-	} else {
+	// Unnumbered frames have a one byte control field with both low bits
+	// set; information and supervisory frames have a two byte one, whose
+	// first byte tells them apart as in DecodeFromBytes.
+	switch {
+	case l.Control&0xFF00 == 0 && l.Control&0x3 == 0x3:
 		length = 3
+	case l.Control>>8&0x3 == 0x3:
+		return fmt.Errorf("LLC control field %#04x invalid, unnumbered frames have one byte control fields", l.Control)
+	default:
+		length = 4
 	}
 
 	if l.DSAP&0x1 != 0 {
@@ -180,6 +187,9 @@ func (l *LLC) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (s *SNAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(s.OrganizationalCode) != 3 {
+		return fmt.Errorf("SNAP organizational code %x invalid, must be 3 bytes", s.OrganizationalCode)
+	}
 	if buf, err := b.PrependBytes(5); err != nil {
 		return err
 	} else {
@@ -0,0 +1,96 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestLLCSNAPSerialize(t *testing.T) {
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	eth := &Ethernet{
+		SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC: net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc},
+	}
+	llc := &LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 0x03}
+	snapLayer := &SNAP{OrganizationalCode: []byte{0x00, 0x00, 0x0c}, Type: 0x88b5}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, eth, llc, snapLayer, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != 60 {
+		t.Errorf("frame not padded, got %d bytes", len(data))
+	}
+	// The length excludes the padding.
+	if got := binary.BigEndian.Uint16(data[12:]); got != 3+5+8 {
+		t.Errorf("got length field %d, want %d", got, 3+5+8)
+	}
+	if want := []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x0c, 0x88, 0xb5}; !bytes.Equal(data[14:22], want) {
+		t.Errorf("got LLC/SNAP header %x, want %x", data[14:22], want)
+	}
+
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	snap, ok := p.Layer(LayerTypeSNAP).(*SNAP)
+	if !ok {
+		t.Fatal("no SNAP layer")
+	}
+	if got := snap.LayerPayload(); !bytes.Equal(got, payload) {
+		t.Errorf("got payload %x, want %x", got, payload)
+	}
+}
+
+func TestSTPSerialize(t *testing.T) {
+	testSTPpacket := []byte{
+		0x01, 0x80, 0xC2, 0x00, 0x00, 0x00, 0x00, 0x1C, 0x0E, 0x87, 0x85, 0x04, 0x00, 0x26, 0x42, 0x42,
+		0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0x64, 0x00, 0x1C, 0x0E, 0x87, 0x78, 0x00, 0x00, 0x00,
+		0x00, 0x04, 0x80, 0x64, 0x00, 0x1C, 0x0E, 0x87, 0x85, 0x00, 0x80, 0x04, 0x01, 0x00, 0x14, 0x00,
+		0x02, 0x00, 0x0F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	p := gopacket.NewPacket(testSTPpacket, LinkTypeEthernet, gopacket.Default)
+	p.Layer(LayerTypeEthernet).(*Ethernet).Length = 0
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializePacket(buf, gopacket.SerializeOptions{FixLengths: true}, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testSTPpacket) {
+		t.Errorf("serialized\n%x\nwant\n%x", buf.Bytes(), testSTPpacket)
+	}
+}
+
+func TestLLCControlLength(t *testing.T) {
+	for _, test := range []struct {
+		control uint16
+		want    []byte
+	}{
+		{0x03, []byte{0x42, 0x42, 0x03}},      // UI
+		{0x0000, []byte{0x42, 0x42, 0, 0}},    // I frame, N(S) = N(R) = 0
+		{0x0102, []byte{0x42, 0x42, 0x01, 2}}, // RR, N(R) = 1
+		{0x0e04, []byte{0x42, 0x42, 0x0e, 4}}, // I frame
+	} {
+		buf := gopacket.NewSerializeBuffer()
+		llc := &LLC{DSAP: 0x42, SSAP: 0x42, Control: test.control}
+		if err := llc.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("control %#04x: got %x, want %x", test.control, buf.Bytes(), test.want)
+		}
+		var decoded LLC
+		if err := decoded.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil || decoded.Control != test.control {
+			t.Errorf("control %#04x: decoded %#04x, %v", test.control, decoded.Control, err)
+		}
+	}
+	if err := (&LLC{Control: 0x0303}).SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("serialized two byte unnumbered control field")
+	}
+}
@@ -61,6 +61,10 @@ type LLDPChassisID struct {
 	ID      []byte
 }
 
+// IDString returns ID as a string without copying it. It is only valid as
+// long as the packet data is; see gopacket.UnsafeString.
+func (c *LLDPChassisID) IDString() string { return gopacket.UnsafeString(c.ID) }
+
 func (c *LLDPChassisID) serialize() []byte {
 
 	var buf = make([]byte, c.serializedLen())
@@ -95,6 +99,10 @@ type LLDPPortID struct {
 	ID      []byte
 }
 
+// IDString returns ID as a string without copying it. It is only valid as
+// long as the packet data is; see gopacket.UnsafeString.
+func (c *LLDPPortID) IDString() string { return gopacket.UnsafeString(c.ID) }
+
 func (c *LLDPPortID) serialize() []byte {
 
 	var buf = make([]byte, c.serializedLen())
@@ -168,7 +168,7 @@ func (m *MLDv2MulticastListenerQueryMessage) String() string {
 	return fmt.Sprintf(
 		"Maximum Response Code: %#x (%dms), Multicast Address: %s, Suppress Routerside Processing: %t, QRV: %#x, QQIC: %#x (%ds), Number of Source Address: %d (actual: %d), Source Addresses: %s",
 		m.MaximumResponseCode,
-		m.MaximumResponseDelay(),
+		m.MaximumResponseDelay()/time.Millisecond,
 		m.MulticastAddress,
 		m.SuppressRoutersideProcessing,
 		m.QueriersRobustnessVariable,
@@ -197,47 +197,38 @@ func (m *MLDv2MulticastListenerQueryMessage) QQI() time.Duration {
 		return time.Second * time.Duration(data)
 	}
 
-	exp := uint16(data) & 0x70 >> 4
-	mant := uint16(data) & 0x0F
-	return time.Second * time.Duration(mant|0x1000<<(exp+3))
+	exp := data & 0x70 >> 4
+	mant := data & 0x0F
+	return time.Second * time.Duration((uint32(mant)|0x10)<<(exp+3))
 }
 
 // SetQQI calculates and updates the Querier's Query Interval Code (QQIC)
 // according to https://tools.ietf.org/html/rfc3810#section-5.1.9
+// Intervals which cannot be represented exactly are rounded down.
 func (m *MLDv2MulticastListenerQueryMessage) SetQQI(d time.Duration) error {
 	if d < 0 {
 		m.QueriersQueryIntervalCode = 0
 		return errors.New("QQI duration is negative")
 	}
 
-	if d == 0 {
-		m.QueriersQueryIntervalCode = 0
+	ds := d / time.Second
+	if ds < 128 {
+		m.QueriersQueryIntervalCode = uint8(ds)
 		return nil
 	}
 
-	dms := d / time.Second
-	if dms < 128 {
-		m.QueriersQueryIntervalCode = uint8(dms)
-	}
-
-	if dms > 31744 { // mant=0xF, exp=0x7
+	if ds > 31744 { // mant=0xF, exp=0x7
 		m.QueriersQueryIntervalCode = 0xFF
-		return fmt.Errorf("QQI duration %ds is, maximum allowed is 31744s", dms)
+		return fmt.Errorf("QQI duration %ds is more than the allowed 31744s", ds)
 	}
 
-	value := uint16(dms) // ok, because 31744 < math.MaxUint16
-	exp := uint8(7)
-	for mask := uint16(0x4000); exp > 0; exp-- {
-		if mask&value != 0 {
-			break
-		}
-
-		mask >>= 1
+	exp := uint8(0)
+	for ds>>(exp+3) > 0x1F {
+		exp++
 	}
 
-	mant := uint8(0x000F & (value >> (exp + 3)))
-	sig := uint8(0x10)
-	m.QueriersQueryIntervalCode = sig | exp<<4 | mant
+	mant := uint8(ds>>(exp+3)) & 0x0F
+	m.QueriersQueryIntervalCode = 0x80 | exp<<4 | mant
 
 	return nil
 }
@@ -247,50 +238,40 @@ func (m *MLDv2MulticastListenerQueryMessage) SetQQI(d time.Duration) error {
 // https://tools.ietf.org/html/rfc3810#section-5.1.3
 func (m *MLDv2MulticastListenerQueryMessage) MaximumResponseDelay() time.Duration {
 	if m.MaximumResponseCode < 0x8000 {
-		return time.Duration(m.MaximumResponseCode)
+		return time.Millisecond * time.Duration(m.MaximumResponseCode)
 	}
 
 	exp := m.MaximumResponseCode & 0x7000 >> 12
 	mant := m.MaximumResponseCode & 0x0FFF
 
-	return time.Millisecond * time.Duration(mant|0x1000<<(exp+3))
+	return time.Millisecond * time.Duration((uint32(mant)|0x1000)<<(exp+3))
 }
 
 // SetMLDv2MaximumResponseDelay updates the Maximum Response Code according to
 // https://tools.ietf.org/html/rfc3810#section-5.1.3
+// Delays which cannot be represented exactly are rounded down.
 func (m *MLDv2MulticastListenerQueryMessage) SetMLDv2MaximumResponseDelay(d time.Duration) error {
-	if d == 0 {
-		m.MaximumResponseCode = 0
-		return nil
-	}
-
 	if d < 0 {
 		return errors.New("maximum response delay must not be negative")
 	}
 
 	dms := d / time.Millisecond
-
 	if dms < 32768 {
 		m.MaximumResponseCode = uint16(dms)
+		return nil
 	}
 
-	if dms > 4193280 { // mant=0xFFF, exp=0x7
-		return fmt.Errorf("maximum response delay %dms is bigger the than maximum of 4193280ms", dms)
+	if dms > 8387584 { // mant=0xFFF, exp=0x7
+		return fmt.Errorf("maximum response delay %dms is more than the allowed 8387584ms", dms)
 	}
 
-	value := uint32(dms) // ok, because 4193280 < math.MaxUint32
-	exp := uint8(7)
-	for mask := uint32(0x40000000); exp > 0; exp-- {
-		if mask&value != 0 {
-			break
-		}
-
-		mask >>= 1
+	exp := uint16(0)
+	for dms>>(exp+3) > 0x1FFF {
+		exp++
 	}
 
-	mant := uint16(0x00000FFF & (value >> (exp + 3)))
-	sig := uint16(0x1000)
-	m.MaximumResponseCode = sig | uint16(exp)<<12 | mant
+	mant := uint16(dms>>(exp+3)) & 0x0FFF
+	m.MaximumResponseCode = 0x8000 | exp<<12 | mant
 	return nil
 }
 
@@ -551,13 +532,11 @@ func (m *MLDv2MulticastAddressRecord) serializeTo(b gopacket.SerializeBuffer, op
 
 // serializes the auxiliary data of a multicast address record
 func (m *MLDv2MulticastAddressRecord) serializeAuxiliaryDataTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	if remainder := len(m.AuxiliaryData) % 4; remainder != 0 {
-		zeroWord := []byte{0x0, 0x0, 0x0, 0x0}
-		m.AuxiliaryData = append(m.AuxiliaryData, zeroWord[:remainder]...)
-	}
+	// the auxiliary data is zero padded to a multiple of 32-bit words
+	length := (len(m.AuxiliaryData) + 3) &^ 3
 
 	if opts.FixLengths {
-		auxDataLen := len(m.AuxiliaryData) / 4
+		auxDataLen := length / 4
 
 		if auxDataLen > math.MaxUint8 {
 			return fmt.Errorf("auxilary data is %d 32-bit words, but the maximum is 255 32-bit words", auxDataLen)
@@ -566,12 +545,13 @@ func (m *MLDv2MulticastAddressRecord) serializeAuxiliaryDataTo(b gopacket.Serial
 		m.AuxDataLen = uint8(auxDataLen)
 	}
 
-	buf, err := b.PrependBytes(len(m.AuxiliaryData))
+	buf, err := b.PrependBytes(length)
 	if err != nil {
 		return err
 	}
 
 	copy(buf, m.AuxiliaryData)
+	copy(buf[len(m.AuxiliaryData):], lotsOfZeros[:])
 	return nil
 }
 
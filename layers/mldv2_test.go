@@ -7,7 +7,10 @@
 package layers
 
 import (
+	"net"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/gopacket"
 )
@@ -135,3 +138,95 @@ func TestPacketMulticastListenerReportMessageV2(t *testing.T) {
 	// See https://github.com/google/gopacket/issues/517
 	// checkSerialization(p, t)
 }
+
+func TestMLDv2QueryCodes(t *testing.T) {
+	m := &MLDv2MulticastListenerQueryMessage{}
+	for _, test := range []struct {
+		qqi  time.Duration
+		qqic uint8
+	}{
+		{125 * time.Second, 125},
+		{128 * time.Second, 0x80},
+		{200 * time.Second, 0x89},
+		{31744 * time.Second, 0xff},
+	} {
+		if err := m.SetQQI(test.qqi); err != nil {
+			t.Fatal(err)
+		}
+		if m.QueriersQueryIntervalCode != test.qqic {
+			t.Errorf("SetQQI(%v) set QQIC %#x, want %#x", test.qqi, m.QueriersQueryIntervalCode, test.qqic)
+		}
+		if got := m.QQI(); got != test.qqi {
+			t.Errorf("QQI() of QQIC %#x is %v, want %v", test.qqic, got, test.qqi)
+		}
+	}
+	if err := m.SetQQI(31745 * time.Second); err == nil {
+		t.Error("SetQQI accepted a QQI of 31745s")
+	}
+
+	for _, test := range []struct {
+		delay time.Duration
+		code  uint16
+	}{
+		{10 * time.Second, 10000},
+		{32768 * time.Millisecond, 0x8000},
+		{40000 * time.Millisecond, 0x8388},
+		{8387584 * time.Millisecond, 0xffff},
+	} {
+		if err := m.SetMLDv2MaximumResponseDelay(test.delay); err != nil {
+			t.Fatal(err)
+		}
+		if m.MaximumResponseCode != test.code {
+			t.Errorf("SetMLDv2MaximumResponseDelay(%v) set code %#x, want %#x", test.delay, m.MaximumResponseCode, test.code)
+		}
+		if got := m.MaximumResponseDelay(); got != test.delay {
+			t.Errorf("MaximumResponseDelay() of code %#x is %v, want %v", test.code, got, test.delay)
+		}
+	}
+}
+
+func TestMLDv2MulticastListenerReportSerialize(t *testing.T) {
+	report := &MLDv2MulticastListenerReportMessage{
+		MulticastAddressRecords: []MLDv2MulticastAddressRecord{
+			{
+				RecordType:       MLDv2MulticastAddressRecordTypeChangeToIncludeMode,
+				MulticastAddress: net.ParseIP("ff02::1:3"),
+				SourceAddresses:  []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+				AuxiliaryData:    []byte{1, 2, 3, 4, 5},
+			},
+			{
+				RecordType:       MLDv2MulticastAddressRecordTypeModeIsExcluded,
+				MulticastAddress: net.ParseIP("ff05::fb"),
+			},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &ICMPv6{}, report); err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Bytes()) != 4+4+20+2*16+8+20 {
+		t.Fatalf("serialized %d bytes", len(buf.Bytes()))
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeICMPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	icmp := p.Layer(LayerTypeICMPv6).(*ICMPv6)
+	if icmp.TypeCode.Type() != ICMPv6TypeMLDv2MulticastListenerReportMessageV2 {
+		t.Errorf("ICMPv6 type %d, want %d", icmp.TypeCode.Type(), ICMPv6TypeMLDv2MulticastListenerReportMessageV2)
+	}
+	got, ok := p.Layer(LayerTypeMLDv2MulticastListenerReport).(*MLDv2MulticastListenerReportMessage)
+	if !ok {
+		t.Fatal("No MLDv2 report layer")
+	}
+	if got.NumberOfMulticastAddressRecords != 2 {
+		t.Errorf("%d records, want 2", got.NumberOfMulticastAddressRecords)
+	}
+	want := report.MulticastAddressRecords
+	want[0].AuxiliaryData = []byte{1, 2, 3, 4, 5, 0, 0, 0}
+	want[1].AuxiliaryData = []byte{}
+	if !reflect.DeepEqual(got.MulticastAddressRecords, want) {
+		t.Errorf("got records %+v, want %+v", got.MulticastAddressRecords, want)
+	}
+}
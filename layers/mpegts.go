@@ -0,0 +1,264 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+const (
+	// MPEGTSPacketSize is the size of a single transport stream packet.
+	MPEGTSPacketSize = 188
+	// MPEGTSSyncByte starts every transport stream packet.
+	MPEGTSSyncByte = 0x47
+	// MPEGTSPIDNull is the PID of null (stuffing) packets.
+	MPEGTSPIDNull uint16 = 0x1fff
+	// MPEGTSPCRClock is the frequency of the program clock reference, in Hz.
+	MPEGTSPCRClock = 27000000
+)
+
+// MPEGTSAdaptationField is the optional adaptation field of a transport
+// stream packet (ISO/IEC 13818-1 section 2.4.3.4). Only the fields
+// diagnostic tools commonly need are decoded.
+type MPEGTSAdaptationField struct {
+	Length                   uint8
+	Discontinuity            bool
+	RandomAccess             bool
+	ElementaryStreamPriority bool
+	HasPCR                   bool
+	HasOPCR                  bool
+	SplicingPoint            bool
+	// PCR and OPCR are in units of the 27 MHz system clock, i.e.
+	// base*300 + extension.
+	PCR             uint64
+	OPCR            uint64
+	SpliceCountdown int8
+}
+
+// MPEGTSPacket is a single 188 byte transport stream packet.
+type MPEGTSPacket struct {
+	TransportError    bool
+	PayloadUnitStart  bool
+	TransportPriority bool
+	PID               uint16
+	Scrambling        uint8
+	// HasAdaptation and HasPayload are the two bits of the
+	// adaptation_field_control field.
+	HasAdaptation     bool
+	HasPayload        bool
+	ContinuityCounter uint8
+	Adaptation        MPEGTSAdaptationField
+	Payload           []byte
+}
+
+// MPEGTS is a sequence of MPEG-2 transport stream packets, as carried in UDP
+// multicast or RTP (RFC 2250) for video distribution.
+//
+// Decoding searches for the first offset at which the sync byte repeats
+// every 188 bytes; any bytes skipped to get there are reported in
+// SyncOffset. Trailing bytes that do not form a whole packet are left as
+// payload.
+type MPEGTS struct {
+	BaseLayer
+	SyncOffset int
+	Packets    []MPEGTSPacket
+}
+
+// LayerType returns LayerTypeMPEGTS.
+func (m *MPEGTS) LayerType() gopacket.LayerType { return LayerTypeMPEGTS }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *MPEGTS) CanDecode() gopacket.LayerClass { return LayerTypeMPEGTS }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (m *MPEGTS) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+// mpegtsSyncOffset returns the first offset in data at which every following
+// whole packet starts with a sync byte, or -1.
+func mpegtsSyncOffset(data []byte) int {
+	for off := 0; off < MPEGTSPacketSize && off+MPEGTSPacketSize <= len(data); off++ {
+		ok := true
+		for i := off; i+MPEGTSPacketSize <= len(data); i += MPEGTSPacketSize {
+			if data[i] != MPEGTSSyncByte {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return off
+		}
+	}
+	return -1
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *MPEGTS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < MPEGTSPacketSize {
+		df.SetTruncated()
+		return errors.New("MPEG-TS data shorter than one packet")
+	}
+	off := mpegtsSyncOffset(data)
+	if off < 0 {
+		return errors.New("MPEG-TS sync byte not found")
+	}
+	m.SyncOffset = off
+	m.Packets = m.Packets[:0]
+	end := off
+	for ; end+MPEGTSPacketSize <= len(data); end += MPEGTSPacketSize {
+		var pkt MPEGTSPacket
+		if err := pkt.decode(data[end : end+MPEGTSPacketSize]); err != nil {
+			return err
+		}
+		m.Packets = append(m.Packets, pkt)
+	}
+	m.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+func (p *MPEGTSPacket) decode(data []byte) error {
+	p.TransportError = data[1]&0x80 != 0
+	p.PayloadUnitStart = data[1]&0x40 != 0
+	p.TransportPriority = data[1]&0x20 != 0
+	p.PID = uint16(data[1]&0x1f)<<8 | uint16(data[2])
+	p.Scrambling = data[3] >> 6
+	p.HasAdaptation = data[3]&0x20 != 0
+	p.HasPayload = data[3]&0x10 != 0
+	p.ContinuityCounter = data[3] & 0x0f
+
+	offset := 4
+	if p.HasAdaptation {
+		a := &p.Adaptation
+		a.Length = data[4]
+		offset = 5 + int(a.Length)
+		if offset > len(data) {
+			return fmt.Errorf("MPEG-TS adaptation field length %d too large", a.Length)
+		}
+		if a.Length > 0 {
+			if err := a.decode(data[5:offset]); err != nil {
+				return err
+			}
+		}
+	}
+	if p.HasPayload {
+		p.Payload = data[offset:]
+	}
+	return nil
+}
+
+func mpegtsClockReference(b []byte) uint64 {
+	base := uint64(b[0])<<25 | uint64(b[1])<<17 | uint64(b[2])<<9 | uint64(b[3])<<1 | uint64(b[4])>>7
+	ext := uint64(b[4]&0x01)<<8 | uint64(b[5])
+	return base*300 + ext
+}
+
+func (a *MPEGTSAdaptationField) decode(data []byte) error {
+	flags := data[0]
+	a.Discontinuity = flags&0x80 != 0
+	a.RandomAccess = flags&0x40 != 0
+	a.ElementaryStreamPriority = flags&0x20 != 0
+	a.HasPCR = flags&0x10 != 0
+	a.HasOPCR = flags&0x08 != 0
+	a.SplicingPoint = flags&0x04 != 0
+
+	offset := 1
+	if a.HasPCR {
+		if len(data) < offset+6 {
+			return errors.New("MPEG-TS PCR truncated")
+		}
+		a.PCR = mpegtsClockReference(data[offset:])
+		offset += 6
+	}
+	if a.HasOPCR {
+		if len(data) < offset+6 {
+			return errors.New("MPEG-TS OPCR truncated")
+		}
+		a.OPCR = mpegtsClockReference(data[offset:])
+		offset += 6
+	}
+	if a.SplicingPoint {
+		if len(data) < offset+1 {
+			return errors.New("MPEG-TS splice countdown truncated")
+		}
+		a.SpliceCountdown = int8(data[offset])
+	}
+	return nil
+}
+
+// MPEGTSContinuityError describes a gap in the continuity counter of a PID.
+type MPEGTSContinuityError struct {
+	PID           uint16
+	Expected, Got uint8
+}
+
+func (e *MPEGTSContinuityError) Error() string {
+	return fmt.Sprintf("MPEG-TS PID %#x: continuity counter %d, expected %d", e.PID, e.Got, e.Expected)
+}
+
+// MPEGTSContinuityChecker follows the continuity counter of every PID across
+// transport stream packets and reports lost or reordered packets.
+//
+// Following ISO/IEC 13818-1 section 2.4.3.3 the counter only advances on
+// packets carrying payload, a single duplicate packet is allowed, and a set
+// discontinuity indicator restarts tracking. Null packets are ignored.
+// The zero value is ready to use.
+type MPEGTSContinuityChecker struct {
+	last map[uint16]uint8
+	dup  map[uint16]bool
+}
+
+// Check processes pkt and returns an error if its continuity counter does
+// not follow the previous packet of the same PID.
+func (c *MPEGTSContinuityChecker) Check(pkt *MPEGTSPacket) error {
+	if pkt.PID == MPEGTSPIDNull {
+		return nil
+	}
+	if c.last == nil {
+		c.last = make(map[uint16]uint8)
+		c.dup = make(map[uint16]bool)
+	}
+	cc := pkt.ContinuityCounter
+	last, seen := c.last[pkt.PID]
+	c.last[pkt.PID] = cc
+	if !seen || pkt.Adaptation.Discontinuity {
+		c.dup[pkt.PID] = false
+		return nil
+	}
+	if !pkt.HasPayload {
+		if cc != last {
+			return &MPEGTSContinuityError{PID: pkt.PID, Expected: last, Got: cc}
+		}
+		return nil
+	}
+	if cc == last && !c.dup[pkt.PID] {
+		c.dup[pkt.PID] = true
+		return nil
+	}
+	c.dup[pkt.PID] = false
+	if want := (last + 1) & 0x0f; cc != want {
+		return &MPEGTSContinuityError{PID: pkt.PID, Expected: want, Got: cc}
+	}
+	return nil
+}
+
+// CheckAll runs Check over every packet of m and returns all errors found.
+func (c *MPEGTSContinuityChecker) CheckAll(m *MPEGTS) []error {
+	var errs []error
+	for i := range m.Packets {
+		if err := c.Check(&m.Packets[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func decodeMPEGTS(data []byte, p gopacket.PacketBuilder) error {
+	m := &MPEGTS{}
+	return decodingLayerDecoder(m, data, p)
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testMPEGTSPacket builds a transport stream packet for pid with the given
+// continuity counter, optionally carrying a PCR in its adaptation field.
+func testMPEGTSPacket(pid uint16, cc uint8, pcr []byte) []byte {
+	b := make([]byte, MPEGTSPacketSize)
+	b[0] = MPEGTSSyncByte
+	b[1] = byte(pid >> 8)
+	b[2] = byte(pid)
+	b[3] = 0x10 | cc
+	if pcr != nil {
+		b[3] |= 0x20
+		b[4] = 7
+		b[5] = 0x50 // random access, PCR
+		copy(b[6:], pcr)
+	}
+	return b
+}
+
+func TestMPEGTSOverRTP(t *testing.T) {
+	// RTP header, payload type 33, followed by two junk bytes and three TS
+	// packets: PID 0x100 with a PCR, PID 0x100 and a null packet.
+	data := []byte{0x80, 0x21, 0x12, 0x34, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02}
+	// PCR base 0x12345678, extension 0x123.
+	pcr := []byte{0x09, 0x1a, 0x2b, 0x3c, 0x7f, 0x23}
+	data = append(data, 0xff, 0xee)
+	data = append(data, testMPEGTSPacket(0x100, 5, pcr)...)
+	data = append(data, testMPEGTSPacket(0x100, 6, nil)...)
+	data = append(data, testMPEGTSPacket(MPEGTSPIDNull, 0, nil)...)
+
+	p := gopacket.NewPacket(data, LayerTypeRTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeRTP, LayerTypeMPEGTS}, t)
+	ts := p.Layer(LayerTypeMPEGTS).(*MPEGTS)
+	if ts.SyncOffset != 2 || len(ts.Packets) != 3 {
+		t.Fatalf("got sync offset %d, %d packets", ts.SyncOffset, len(ts.Packets))
+	}
+	first := ts.Packets[0]
+	if first.PID != 0x100 || first.ContinuityCounter != 5 || !first.HasAdaptation || !first.Adaptation.RandomAccess {
+		t.Errorf("bad first packet %+v", first)
+	}
+	if want := uint64(0x12345678*300 + 0x123); !first.Adaptation.HasPCR || first.Adaptation.PCR != want {
+		t.Errorf("got PCR %d, want %d", first.Adaptation.PCR, want)
+	}
+	if len(first.Payload) != MPEGTSPacketSize-12 || len(ts.Packets[1].Payload) != MPEGTSPacketSize-4 {
+		t.Errorf("bad payload lengths %d, %d", len(first.Payload), len(ts.Packets[1].Payload))
+	}
+
+	var c MPEGTSContinuityChecker
+	if errs := c.CheckAll(ts); len(errs) != 0 {
+		t.Errorf("unexpected continuity errors %v", errs)
+	}
+}
+
+func TestMPEGTSContinuityChecker(t *testing.T) {
+	var c MPEGTSContinuityChecker
+	for i, test := range []struct {
+		cc      uint8
+		payload bool
+		disc    bool
+		ok      bool
+	}{
+		{14, true, false, true},
+		{15, true, false, true},
+		{15, true, false, true},  // one duplicate is allowed
+		{15, true, false, false}, // a second one is not
+		{0, true, false, true},   // wraps around
+		{0, false, false, true},  // no payload, counter unchanged
+		{3, true, false, false},  // lost packets
+		{9, true, true, true},    // discontinuity indicator
+		{10, true, false, true},
+	} {
+		pkt := MPEGTSPacket{PID: 0x44, ContinuityCounter: test.cc, HasPayload: test.payload}
+		pkt.Adaptation.Discontinuity = test.disc
+		if err := c.Check(&pkt); (err == nil) != test.ok {
+			t.Errorf("%d: cc %d: got error %v", i, test.cc, err)
+		}
+	}
+}
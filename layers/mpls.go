@@ -9,6 +9,8 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -24,15 +26,37 @@ type MPLS struct {
 // LayerType returns gopacket.LayerTypeMPLS.
 func (m *MPLS) LayerType() gopacket.LayerType { return LayerTypeMPLS }
 
+// MPLSLabelStack returns MPLS layers for labels, outermost first, all with
+// the given TTL and the bottom of stack bit set on the last one, ready to
+// be serialized in sequence:
+//
+//	ls := append([]gopacket.SerializableLayer{eth}, layers.MPLSLabelStack(64, 16, 17)...)
+//	err := gopacket.SerializeLayers(buf, opts, append(ls, ip, udp, payload)...)
+func MPLSLabelStack(ttl uint8, labels ...uint32) []gopacket.SerializableLayer {
+	ls := make([]gopacket.SerializableLayer, len(labels))
+	for i, label := range labels {
+		ls[i] = &MPLS{Label: label, TTL: ttl, StackBottom: i == len(labels)-1}
+	}
+	return ls
+}
+
 // ProtocolGuessingDecoder attempts to guess the protocol of the bytes it's
 // given, then decode the packet accordingly.  Its algorithm for guessing is:
 //  If the packet starts with byte 0x45-0x4F: IPv4
 //  If the packet starts with byte 0x60-0x6F: IPv6
+//  If the packet starts with byte 0x00-0x0F: PW control word (RFC 4385),
+//  followed by an Ethernet frame
 //  Otherwise:  Error
 // See draft-hsmit-isis-aal5mux-00.txt for more detail on this approach.
 type ProtocolGuessingDecoder struct{}
 
 func (ProtocolGuessingDecoder) Decode(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) == 0 {
+		return errors.New("Unable to guess protocol of empty packet data")
+	}
+	if data[0]>>4 == 0 {
+		return decodePWControlWord(data, p)
+	}
 	switch data[0] {
 	// 0x40 | header_len, where header_len is at least 5.
 	case 0x45, 0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d, 0x4e, 0x4f:
@@ -53,6 +77,10 @@ func (ProtocolGuessingDecoder) Decode(data []byte, p gopacket.PacketBuilder) err
 var MPLSPayloadDecoder gopacket.Decoder = ProtocolGuessingDecoder{}
 
 func decodeMPLS(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) < 4 {
+		p.SetTruncated()
+		return errors.New("MPLS header too short")
+	}
 	decoded := binary.BigEndian.Uint32(data[:4])
 	mpls := &MPLS{
 		Label:        decoded >> 12,
@@ -70,14 +98,24 @@ func decodeMPLS(data []byte, p gopacket.PacketBuilder) error {
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
-// See the docs for gopacket.SerializableLayer for more info.
+// See the docs for gopacket.SerializableLayer for more info. With
+// FixLengths, StackBottom is set unless the layer serialized inside this
+// one is another MPLS layer, so a label stack serialized in sequence has
+// the bottom of stack bit on its last label only.
 func (m *MPLS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if m.Label > 0xfffff {
+		return fmt.Errorf("invalid MPLS label %d", m.Label)
+	}
+	if opts.FixLengths {
+		inner := b.Layers()
+		m.StackBottom = len(inner) == 0 || inner[len(inner)-1] != LayerTypeMPLS
+	}
 	bytes, err := b.PrependBytes(4)
 	if err != nil {
 		return err
 	}
 	encoded := m.Label << 12
-	encoded |= uint32(m.TrafficClass) << 9
+	encoded |= uint32(m.TrafficClass&0x7) << 9
 	encoded |= uint32(m.TTL)
 	if m.StackBottom {
 		encoded |= 0x100
@@ -85,3 +123,80 @@ func (m *MPLS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOp
 	binary.BigEndian.PutUint32(bytes, encoded)
 	return nil
 }
+
+// PWControlWord is the control word preceding the payload of an MPLS
+// pseudowire (RFC 4385), such as an Ethernet frame (RFC 4448).
+type PWControlWord struct {
+	BaseLayer
+	Flags uint8
+	// Fragmentation holds the FRG bits of pseudowires fragmenting their
+	// payload (RFC 4623).
+	Fragmentation uint8
+	// Length is the length of the control word and the payload if they are
+	// shorter than 64 bytes, so padding added by Ethernet links can be
+	// removed, and zero otherwise.
+	Length         uint8
+	SequenceNumber uint16
+}
+
+// LayerType returns LayerTypePWControlWord.
+func (c *PWControlWord) LayerType() gopacket.LayerType { return LayerTypePWControlWord }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *PWControlWord) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("PW control word too short")
+	}
+	if data[0]>>4 != 0 {
+		return fmt.Errorf("invalid PW control word nibble %d", data[0]>>4)
+	}
+	c.Flags = data[0] & 0xf
+	c.Fragmentation = data[1] >> 6
+	c.Length = data[1] & 0x3f
+	c.SequenceNumber = binary.BigEndian.Uint16(data[2:4])
+	payload := data[4:]
+	if c.Length >= 4 && int(c.Length) < len(data) {
+		payload = data[4:c.Length]
+	}
+	c.BaseLayer = BaseLayer{Contents: data[:4], Payload: payload}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info. With
+// FixLengths, Length is set as RFC 4385 requires.
+func (c *PWControlWord) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if opts.FixLengths {
+		c.Length = 0
+		if n := len(b.Bytes()) + 4; n < 64 {
+			c.Length = uint8(n)
+		}
+	}
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	bytes[0] = c.Flags & 0xf
+	bytes[1] = c.Fragmentation<<6 | c.Length&0x3f
+	binary.BigEndian.PutUint16(bytes[2:], c.SequenceNumber)
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *PWControlWord) CanDecode() gopacket.LayerClass {
+	return LayerTypePWControlWord
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer. The
+// control word does not identify the payload, which is assumed to be an
+// Ethernet frame, the payload of the most common pseudowires.
+func (c *PWControlWord) NextLayerType() gopacket.LayerType {
+	return LayerTypeEthernet
+}
+
+func decodePWControlWord(data []byte, p gopacket.PacketBuilder) error {
+	c := &PWControlWord{}
+	return decodingLayerDecoder(c, data, p)
+}
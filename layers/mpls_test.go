@@ -94,3 +94,69 @@ func BenchmarkDecodePacketMPLS(b *testing.B) {
 		gopacket.NewPacket(testPacketMPLS, LinkTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestMPLSLabelStackSerialize(t *testing.T) {
+	ip := &IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: IPProtocolUDP, SrcIP: []byte{10, 0, 0, 1}, DstIP: []byte{10, 0, 0, 2}}
+	eth := &Ethernet{SrcMAC: []byte{2, 0, 0, 0, 0, 1}, DstMAC: []byte{2, 0, 0, 0, 0, 2}, EthernetType: EthernetTypeMPLSUnicast}
+	stack := MPLSLabelStack(64, 16, 17, 18)
+	// Mark the wrong label as the bottom of the stack, for FixLengths to
+	// fix.
+	stack[0].(*MPLS).StackBottom = true
+	stack[2].(*MPLS).StackBottom = false
+	ls := append([]gopacket.SerializableLayer{eth}, stack...)
+	ls = append(ls, ip, &UDP{SrcPort: 1, DstPort: 2}, gopacket.Payload("hello"))
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0x01, 0x00, 0x40, 0x00, 0x01, 0x10, 0x40, 0x00, 0x01, 0x21, 0x40}
+	if got := buf.Bytes()[14:26]; !reflect.DeepEqual(got, want) {
+		t.Errorf("label stack %x, want %x", got, want)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMPLS, LayerTypeMPLS, LayerTypeMPLS, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	if err := (&MPLS{Label: 1 << 20}).SerializeTo(buf, gopacket.SerializeOptions{}); err == nil {
+		t.Error("no error serializing label 1<<20")
+	}
+}
+
+func TestMPLSPWControlWord(t *testing.T) {
+	inner := &Ethernet{SrcMAC: []byte{2, 0, 0, 0, 0, 3}, DstMAC: []byte{2, 0, 0, 0, 0, 4}, EthernetType: EthernetTypeIPv4}
+	ip := &IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: IPProtocolUDP, SrcIP: []byte{10, 0, 0, 1}, DstIP: []byte{10, 0, 0, 2}}
+	eth := &Ethernet{SrcMAC: []byte{2, 0, 0, 0, 0, 1}, DstMAC: []byte{2, 0, 0, 0, 0, 2}, EthernetType: EthernetTypeMPLSUnicast}
+	cw := &PWControlWord{SequenceNumber: 7}
+	ls := append([]gopacket.SerializableLayer{eth}, MPLSLabelStack(64, 100, 200)...)
+	ls = append(ls, cw, inner, ip, &UDP{SrcPort: 1, DstPort: 2}, gopacket.Payload("hello"))
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	// The inner frame is padded to 60 bytes, so with the control word the
+	// MPLS payload is 64 bytes long and Length is zero.
+	if cw.Length != 0 {
+		t.Errorf("control word length %d, want 0", cw.Length)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMPLS, LayerTypeMPLS, LayerTypePWControlWord, LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	if got := p.Layer(LayerTypePWControlWord).(*PWControlWord); got.SequenceNumber != 7 {
+		t.Errorf("sequence number %d, want 7", got.SequenceNumber)
+	}
+
+	// A short payload padded by the outer Ethernet layer.
+	ls = append([]gopacket.SerializableLayer{eth}, MPLSLabelStack(64, 100)...)
+	ls = append(ls, cw, gopacket.Payload("short"))
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	if cw.Length != 9 {
+		t.Errorf("control word length %d, want 9", cw.Length)
+	}
+	p = gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if got := p.Layer(LayerTypePWControlWord).(*PWControlWord); string(got.Payload) != "short" {
+		t.Errorf("control word payload %q, want %q", got.Payload, "short")
+	}
+}
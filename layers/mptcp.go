@@ -0,0 +1,429 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// MPTCPSubtype is the subtype of a Multipath TCP option (RFC 8684), in
+// the high nibble of its first data byte.
+type MPTCPSubtype uint8
+
+// MPTCP option subtypes.
+const (
+	MPTCPSubtypeCapable    MPTCPSubtype = 0
+	MPTCPSubtypeJoin       MPTCPSubtype = 1
+	MPTCPSubtypeDSS        MPTCPSubtype = 2
+	MPTCPSubtypeAddAddr    MPTCPSubtype = 3
+	MPTCPSubtypeRemoveAddr MPTCPSubtype = 4
+	MPTCPSubtypePrio       MPTCPSubtype = 5
+	MPTCPSubtypeFail       MPTCPSubtype = 6
+	MPTCPSubtypeFastClose  MPTCPSubtype = 7
+	MPTCPSubtypeTCPRST     MPTCPSubtype = 8
+)
+
+func (s MPTCPSubtype) String() string {
+	switch s {
+	case MPTCPSubtypeCapable:
+		return "MP_CAPABLE"
+	case MPTCPSubtypeJoin:
+		return "MP_JOIN"
+	case MPTCPSubtypeDSS:
+		return "DSS"
+	case MPTCPSubtypeAddAddr:
+		return "ADD_ADDR"
+	case MPTCPSubtypeRemoveAddr:
+		return "REMOVE_ADDR"
+	case MPTCPSubtypePrio:
+		return "MP_PRIO"
+	case MPTCPSubtypeFail:
+		return "MP_FAIL"
+	case MPTCPSubtypeFastClose:
+		return "MP_FASTCLOSE"
+	case MPTCPSubtypeTCPRST:
+		return "MP_TCPRST"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// MPTCPOption is a decoded MPTCP option: an *MPTCPCapable, *MPTCPJoin,
+// *MPTCPDSS or *MPTCPAddAddr.
+type MPTCPOption interface {
+	Subtype() MPTCPSubtype
+	// Option encodes the option.
+	Option() TCPOption
+}
+
+func newMPTCPOption(data []byte) TCPOption {
+	return TCPOption{OptionType: TCPOptionKindMPTCP, OptionLength: uint8(2 + len(data)), OptionData: data}
+}
+
+// DecodeMPTCPOption decodes an MPTCP option. It returns an error if o is
+// not an MPTCP option of a supported subtype, or is malformed.
+func DecodeMPTCPOption(o TCPOption) (MPTCPOption, error) {
+	if o.OptionType != TCPOptionKindMPTCP {
+		return nil, fmt.Errorf("not an MPTCP option: %v", o.OptionType)
+	}
+	if len(o.OptionData) < 1 {
+		return nil, errors.New("MPTCP option too short")
+	}
+	var opt interface {
+		MPTCPOption
+		decode([]byte) error
+	}
+	switch st := MPTCPSubtype(o.OptionData[0] >> 4); st {
+	case MPTCPSubtypeCapable:
+		opt = &MPTCPCapable{}
+	case MPTCPSubtypeJoin:
+		opt = &MPTCPJoin{}
+	case MPTCPSubtypeDSS:
+		opt = &MPTCPDSS{}
+	case MPTCPSubtypeAddAddr:
+		opt = &MPTCPAddAddr{}
+	default:
+		return nil, fmt.Errorf("unsupported MPTCP option %v", st)
+	}
+	if err := opt.decode(o.OptionData); err != nil {
+		return nil, err
+	}
+	return opt, nil
+}
+
+// MPTCPOptions returns the valid MPTCP options of the segment, of the
+// subtypes supported by DecodeMPTCPOption.
+func (t *TCP) MPTCPOptions() []MPTCPOption {
+	var opts []MPTCPOption
+	for _, o := range t.Options {
+		if o.OptionType != TCPOptionKindMPTCP {
+			continue
+		}
+		if opt, err := DecodeMPTCPOption(o); err == nil {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// MPTCPCapable is an MP_CAPABLE option, which negotiates MPTCP on the
+// handshake of the first subflow of a connection. Which keys are present
+// depends on the segment: none on the SYN of version 1, the sender's on
+// the SYN of version 0 and on the SYN/ACK, both on the third ACK. Version
+// 1 may also carry the data level length and checksum of the first data
+// on the third ACK.
+type MPTCPCapable struct {
+	Version uint8
+	// Flags holds the A to H bits, A being the checksum requirement and H
+	// the use of HMAC-SHA256.
+	Flags                        uint8
+	HasSenderKey, HasReceiverKey bool
+	SenderKey, ReceiverKey       uint64
+	HasDataLength, HasChecksum   bool
+	DataLength, Checksum         uint16
+}
+
+// Subtype returns MPTCPSubtypeCapable.
+func (o *MPTCPCapable) Subtype() MPTCPSubtype { return MPTCPSubtypeCapable }
+
+func (o *MPTCPCapable) decode(data []byte) error {
+	switch len(data) {
+	case 2, 10, 18, 20, 22:
+	default:
+		return fmt.Errorf("invalid MP_CAPABLE length %d", len(data)+2)
+	}
+	*o = MPTCPCapable{Version: data[0] & 0xf, Flags: data[1]}
+	if len(data) >= 10 {
+		o.HasSenderKey = true
+		o.SenderKey = binary.BigEndian.Uint64(data[2:])
+	}
+	if len(data) >= 18 {
+		o.HasReceiverKey = true
+		o.ReceiverKey = binary.BigEndian.Uint64(data[10:])
+	}
+	if len(data) >= 20 {
+		o.HasDataLength = true
+		o.DataLength = binary.BigEndian.Uint16(data[18:])
+	}
+	if len(data) == 22 {
+		o.HasChecksum = true
+		o.Checksum = binary.BigEndian.Uint16(data[20:])
+	}
+	return nil
+}
+
+// Option encodes the option. Only the fields present on the segment types
+// described above are encoded: a receiver key requires a sender key, and
+// a data length both keys.
+func (o *MPTCPCapable) Option() TCPOption {
+	data := []byte{byte(MPTCPSubtypeCapable)<<4 | o.Version&0xf, o.Flags}
+	if o.HasSenderKey {
+		data = append(data, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(data[2:], o.SenderKey)
+		if o.HasReceiverKey {
+			data = append(data, make([]byte, 8)...)
+			binary.BigEndian.PutUint64(data[10:], o.ReceiverKey)
+			if o.HasDataLength {
+				data = append(data, byte(o.DataLength>>8), byte(o.DataLength))
+				if o.HasChecksum {
+					data = append(data, byte(o.Checksum>>8), byte(o.Checksum))
+				}
+			}
+		}
+	}
+	return newMPTCPOption(data)
+}
+
+// MPTCPJoin is an MP_JOIN option, which adds a subflow to a connection.
+// The SYN carries the receiver's token and the sender's random number,
+// the SYN/ACK a truncated 8 byte HMAC and the sender's random number, and
+// the third ACK the full 20 byte HMAC.
+type MPTCPJoin struct {
+	// Backup and AddressID are set on SYNs and SYN/ACKs.
+	Backup        bool
+	AddressID     uint8
+	ReceiverToken uint32
+	SenderRandom  uint32
+	HMAC          []byte
+}
+
+// Subtype returns MPTCPSubtypeJoin.
+func (o *MPTCPJoin) Subtype() MPTCPSubtype { return MPTCPSubtypeJoin }
+
+func (o *MPTCPJoin) decode(data []byte) error {
+	*o = MPTCPJoin{}
+	switch len(data) {
+	case 10:
+		o.ReceiverToken = binary.BigEndian.Uint32(data[2:])
+		o.SenderRandom = binary.BigEndian.Uint32(data[6:])
+	case 14:
+		o.HMAC = data[2:10]
+		o.SenderRandom = binary.BigEndian.Uint32(data[10:])
+	case 22:
+		o.HMAC = data[2:22]
+		return nil
+	default:
+		return fmt.Errorf("invalid MP_JOIN length %d", len(data)+2)
+	}
+	o.Backup = data[0]&1 != 0
+	o.AddressID = data[1]
+	return nil
+}
+
+// Option encodes the option, for a SYN if HMAC is empty, a SYN/ACK if it
+// is 8 bytes long and a third ACK otherwise.
+func (o *MPTCPJoin) Option() TCPOption {
+	data := []byte{byte(MPTCPSubtypeJoin) << 4, o.AddressID}
+	if o.Backup {
+		data[0] |= 1
+	}
+	switch len(o.HMAC) {
+	case 0:
+		data = append(data, make([]byte, 8)...)
+		binary.BigEndian.PutUint32(data[2:], o.ReceiverToken)
+		binary.BigEndian.PutUint32(data[6:], o.SenderRandom)
+	case 8:
+		data = append(data, o.HMAC...)
+		data = append(data, make([]byte, 4)...)
+		binary.BigEndian.PutUint32(data[10:], o.SenderRandom)
+	default:
+		data = append([]byte{byte(MPTCPSubtypeJoin) << 4, 0}, o.HMAC...)
+	}
+	return newMPTCPOption(data)
+}
+
+// DSS flags.
+const (
+	mptcpDSSDataACK   = 0x01
+	mptcpDSSDataACK8  = 0x02
+	mptcpDSSMapping   = 0x04
+	mptcpDSSSequence8 = 0x08
+	mptcpDSSDataFIN   = 0x10
+)
+
+// MPTCPDSS is a Data Sequence Signal option, which acknowledges data at
+// the connection level and maps subflow sequence numbers to connection
+// level data sequence numbers.
+type MPTCPDSS struct {
+	DataFIN bool
+	// HasDataACK is true if the option acknowledges data up to DataACK,
+	// encoded in 8 bytes if DataACK8 is set and in 4 otherwise.
+	HasDataACK bool
+	DataACK8   bool
+	DataACK    uint64
+	// HasMapping is true if the option maps the DataLength bytes starting
+	// at SubflowSequence, relative to the initial subflow sequence
+	// number, to the data sequence number DSN, encoded in 8 bytes if DSN8
+	// is set and in 4 otherwise. HasChecksum is true if the mapping has a
+	// checksum.
+	HasMapping      bool
+	DSN8            bool
+	DSN             uint64
+	SubflowSequence uint32
+	DataLength      uint16
+	HasChecksum     bool
+	Checksum        uint16
+}
+
+// Subtype returns MPTCPSubtypeDSS.
+func (o *MPTCPDSS) Subtype() MPTCPSubtype { return MPTCPSubtypeDSS }
+
+func (o *MPTCPDSS) decode(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("DSS option too short")
+	}
+	flags := data[1]
+	*o = MPTCPDSS{
+		DataFIN:    flags&mptcpDSSDataFIN != 0,
+		HasDataACK: flags&mptcpDSSDataACK != 0,
+		DataACK8:   flags&mptcpDSSDataACK8 != 0,
+		HasMapping: flags&mptcpDSSMapping != 0,
+		DSN8:       flags&mptcpDSSSequence8 != 0,
+	}
+	data = data[2:]
+	var ok bool
+	if o.HasDataACK {
+		if o.DataACK, data, ok = mptcpUint(data, o.DataACK8); !ok {
+			return errors.New("DSS option too short for its data ACK")
+		}
+	}
+	if o.HasMapping {
+		if o.DSN, data, ok = mptcpUint(data, o.DSN8); !ok || len(data) < 6 {
+			return errors.New("DSS option too short for its mapping")
+		}
+		o.SubflowSequence = binary.BigEndian.Uint32(data)
+		o.DataLength = binary.BigEndian.Uint16(data[4:])
+		data = data[6:]
+		if len(data) >= 2 {
+			o.HasChecksum = true
+			o.Checksum = binary.BigEndian.Uint16(data)
+			data = data[2:]
+		}
+	}
+	if len(data) != 0 {
+		return errors.New("DSS option too long")
+	}
+	return nil
+}
+
+// mptcpUint decodes a 4 or 8 byte number at the start of data, returning
+// the rest of data.
+func mptcpUint(data []byte, wide bool) (uint64, []byte, bool) {
+	if wide {
+		if len(data) < 8 {
+			return 0, nil, false
+		}
+		return binary.BigEndian.Uint64(data), data[8:], true
+	}
+	if len(data) < 4 {
+		return 0, nil, false
+	}
+	return uint64(binary.BigEndian.Uint32(data)), data[4:], true
+}
+
+func appendMPTCPUint(data []byte, v uint64, wide bool) []byte {
+	if wide {
+		return append(data, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	return append(data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// Option encodes the option.
+func (o *MPTCPDSS) Option() TCPOption {
+	var flags byte
+	if o.DataFIN {
+		flags |= mptcpDSSDataFIN
+	}
+	data := []byte{byte(MPTCPSubtypeDSS) << 4, 0}
+	if o.HasDataACK {
+		flags |= mptcpDSSDataACK
+		if o.DataACK8 {
+			flags |= mptcpDSSDataACK8
+		}
+		data = appendMPTCPUint(data, o.DataACK, o.DataACK8)
+	}
+	if o.HasMapping {
+		flags |= mptcpDSSMapping
+		if o.DSN8 {
+			flags |= mptcpDSSSequence8
+		}
+		data = appendMPTCPUint(data, o.DSN, o.DSN8)
+		data = appendMPTCPUint(data, uint64(o.SubflowSequence), false)
+		data = append(data, byte(o.DataLength>>8), byte(o.DataLength))
+		if o.HasChecksum {
+			data = append(data, byte(o.Checksum>>8), byte(o.Checksum))
+		}
+	}
+	data[1] = flags
+	return newMPTCPOption(data)
+}
+
+// MPTCPAddAddr is an ADD_ADDR option, which advertises an additional
+// address of the sender. Echo is set when echoing an advertisement back to
+// acknowledge it; advertisements which are not echoes carry a truncated
+// HMAC of 8 bytes.
+type MPTCPAddAddr struct {
+	Echo      bool
+	AddressID uint8
+	Address   net.IP
+	HasPort   bool
+	Port      uint16
+	HMAC      []byte
+}
+
+// Subtype returns MPTCPSubtypeAddAddr.
+func (o *MPTCPAddAddr) Subtype() MPTCPSubtype { return MPTCPSubtypeAddAddr }
+
+func (o *MPTCPAddAddr) decode(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("ADD_ADDR option too short")
+	}
+	*o = MPTCPAddAddr{Echo: data[0]&1 != 0, AddressID: data[1]}
+	rest := len(data) - 2
+	addrLen := net.IPv4len
+	if rest >= 16 {
+		addrLen = net.IPv6len
+	}
+	switch rest - addrLen {
+	case 0, 2, 8, 10:
+	default:
+		return fmt.Errorf("invalid ADD_ADDR length %d", len(data)+2)
+	}
+	o.Address = net.IP(data[2 : 2+addrLen])
+	data = data[2+addrLen:]
+	if len(data) == 2 || len(data) == 10 {
+		o.HasPort = true
+		o.Port = binary.BigEndian.Uint16(data)
+		data = data[2:]
+	}
+	if len(data) == 8 {
+		o.HMAC = data
+	}
+	return nil
+}
+
+// Option encodes the option. Address must be an IPv4 or IPv6 address, and
+// HMAC empty or 8 bytes long.
+func (o *MPTCPAddAddr) Option() TCPOption {
+	data := []byte{byte(MPTCPSubtypeAddAddr) << 4, o.AddressID}
+	if o.Echo {
+		data[0] |= 1
+	}
+	if ip4 := o.Address.To4(); ip4 != nil {
+		data = append(data, ip4...)
+	} else {
+		data = append(data, o.Address.To16()...)
+	}
+	if o.HasPort {
+		data = append(data, byte(o.Port>>8), byte(o.Port))
+	}
+	data = append(data, o.HMAC...)
+	return newMPTCPOption(data)
+}
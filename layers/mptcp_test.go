@@ -0,0 +1,87 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestMPTCPOptions(t *testing.T) {
+	opts := []MPTCPOption{
+		&MPTCPCapable{Version: 1, Flags: 0x81},
+		&MPTCPCapable{Version: 1, Flags: 0x81, HasSenderKey: true, SenderKey: 0x0102030405060708},
+		&MPTCPCapable{Version: 1, Flags: 0x81, HasSenderKey: true, SenderKey: 1, HasReceiverKey: true, ReceiverKey: 2,
+			HasDataLength: true, DataLength: 100, HasChecksum: true, Checksum: 0xbeef},
+		&MPTCPJoin{Backup: true, AddressID: 3, ReceiverToken: 0xdeadbeef, SenderRandom: 42},
+		&MPTCPJoin{AddressID: 3, HMAC: []byte{1, 2, 3, 4, 5, 6, 7, 8}, SenderRandom: 43},
+		&MPTCPJoin{HMAC: bytes.Repeat([]byte{9}, 20)},
+		&MPTCPDSS{HasDataACK: true, DataACK: 1000},
+		&MPTCPDSS{DataFIN: true, HasDataACK: true, DataACK8: true, DataACK: 1 << 40,
+			HasMapping: true, DSN8: true, DSN: 1<<40 + 5, SubflowSequence: 1, DataLength: 1400, HasChecksum: true, Checksum: 7},
+		&MPTCPDSS{HasMapping: true, DSN: 5, SubflowSequence: 1, DataLength: 1400},
+		&MPTCPAddAddr{AddressID: 2, Address: net.IP{192, 0, 2, 1}, HMAC: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		&MPTCPAddAddr{Echo: true, AddressID: 2, Address: net.IP{192, 0, 2, 1}, HasPort: true, Port: 8080},
+		&MPTCPAddAddr{AddressID: 4, Address: net.ParseIP("2001:db8::1"), HasPort: true, Port: 443, HMAC: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+	}
+	for _, want := range opts {
+		tcp := &TCP{SrcPort: 1, DstPort: 2, Options: []TCPOption{want.Option()}}
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, tcp); err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LayerTypeTCP, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+		}
+		got := p.Layer(LayerTypeTCP).(*TCP).MPTCPOptions()
+		if len(got) != 1 {
+			t.Errorf("%v: got %d MPTCP options", want.Subtype(), len(got))
+			continue
+		}
+		if a, ok := want.(*MPTCPAddAddr); ok {
+			if a.Address.To4() != nil {
+				a.Address = a.Address.To4()
+			}
+		}
+		if !reflect.DeepEqual(got[0], want) {
+			t.Errorf("got %+v, want %+v", got[0], want)
+		}
+	}
+}
+
+func TestMPTCPOptionBytes(t *testing.T) {
+	// MP_CAPABLE on the SYN of version 1, requiring checksums and HMAC-SHA256.
+	o, err := DecodeMPTCPOption(TCPOption{OptionType: TCPOptionKindMPTCP, OptionLength: 4, OptionData: []byte{0x01, 0x81}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := o.(*MPTCPCapable); !ok || c.Version != 1 || c.Flags != 0x81 || c.HasSenderKey {
+		t.Errorf("got %+v", o)
+	}
+	if got := o.Option(); !bytes.Equal(got.OptionData, []byte{0x01, 0x81}) || got.OptionLength != 4 {
+		t.Errorf("encoded as %+v", got)
+	}
+
+	for _, data := range [][]byte{
+		{},
+		{0x00, 0x81, 0x01},          // MP_CAPABLE with a truncated key
+		{0x10, 0x00, 1, 2, 3, 4},    // MP_JOIN of no valid length
+		{0x20, 0x05, 0, 0, 0},       // DSS with a truncated data ACK
+		{0x20, 0x01, 0, 0, 0, 1, 0}, // DSS with trailing data
+		{0x30, 0x01, 192, 0, 2},     // ADD_ADDR with a truncated address
+		{0x40, 0x01},                // REMOVE_ADDR is not supported
+	} {
+		if _, err := DecodeMPTCPOption(TCPOption{OptionType: TCPOptionKindMPTCP, OptionData: data}); err == nil {
+			t.Errorf("decoded invalid option %x", data)
+		}
+	}
+}
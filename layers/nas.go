@@ -0,0 +1,483 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// NASProtocolDiscriminator identifies the protocol of an EPS NAS message.
+type NASProtocolDiscriminator uint8
+
+// EPS NAS protocol discriminators, from 3GPP TS 24.007.
+const (
+	NASProtocolDiscriminatorESM NASProtocolDiscriminator = 0x2
+	NASProtocolDiscriminatorEMM NASProtocolDiscriminator = 0x7
+)
+
+func (d NASProtocolDiscriminator) String() string {
+	switch d {
+	case NASProtocolDiscriminatorESM:
+		return "ESM"
+	case NASProtocolDiscriminatorEMM:
+		return "EMM"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(d))
+}
+
+// NASSecurityHeaderType tells whether a NAS message is integrity protected
+// and ciphered. The values are shared by EPS and 5GS NAS.
+type NASSecurityHeaderType uint8
+
+// NAS security header types, from 3GPP TS 24.301 and TS 24.501.
+const (
+	NASSecurityHeaderTypePlain                                NASSecurityHeaderType = 0
+	NASSecurityHeaderTypeIntegrityProtected                   NASSecurityHeaderType = 1
+	NASSecurityHeaderTypeIntegrityProtectedCiphered           NASSecurityHeaderType = 2
+	NASSecurityHeaderTypeIntegrityProtectedNewContext         NASSecurityHeaderType = 3
+	NASSecurityHeaderTypeIntegrityProtectedCipheredNewContext NASSecurityHeaderType = 4
+	// NASSecurityHeaderTypeServiceRequest is only used by EPS, for the
+	// short service request message.
+	NASSecurityHeaderTypeServiceRequest NASSecurityHeaderType = 12
+)
+
+func (t NASSecurityHeaderType) String() string {
+	switch t {
+	case NASSecurityHeaderTypePlain:
+		return "Plain"
+	case NASSecurityHeaderTypeIntegrityProtected:
+		return "IntegrityProtected"
+	case NASSecurityHeaderTypeIntegrityProtectedCiphered:
+		return "IntegrityProtectedCiphered"
+	case NASSecurityHeaderTypeIntegrityProtectedNewContext:
+		return "IntegrityProtectedNewContext"
+	case NASSecurityHeaderTypeIntegrityProtectedCipheredNewContext:
+		return "IntegrityProtectedCipheredNewContext"
+	case NASSecurityHeaderTypeServiceRequest:
+		return "ServiceRequest"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// protected returns whether a message with this security header is wrapped
+// in a security protected NAS message.
+func (t NASSecurityHeaderType) protected() bool {
+	return t >= NASSecurityHeaderTypeIntegrityProtected && t <= NASSecurityHeaderTypeIntegrityProtectedCipheredNewContext
+}
+
+// ciphered returns whether the message wrapped with this security header is
+// ciphered.
+func (t NASSecurityHeaderType) ciphered() bool {
+	return t == NASSecurityHeaderTypeIntegrityProtectedCiphered || t == NASSecurityHeaderTypeIntegrityProtectedCipheredNewContext
+}
+
+// NASEPSMessageType is the type of an EPS mobility management (EMM) or
+// session management (ESM) message, whose values do not overlap.
+type NASEPSMessageType uint8
+
+// EMM and ESM message types, from 3GPP TS 24.301.
+const (
+	NASEPSMessageTypeAttachRequest                  NASEPSMessageType = 0x41
+	NASEPSMessageTypeAttachAccept                   NASEPSMessageType = 0x42
+	NASEPSMessageTypeAttachComplete                 NASEPSMessageType = 0x43
+	NASEPSMessageTypeAttachReject                   NASEPSMessageType = 0x44
+	NASEPSMessageTypeDetachRequest                  NASEPSMessageType = 0x45
+	NASEPSMessageTypeDetachAccept                   NASEPSMessageType = 0x46
+	NASEPSMessageTypeTrackingAreaUpdateRequest      NASEPSMessageType = 0x48
+	NASEPSMessageTypeTrackingAreaUpdateAccept       NASEPSMessageType = 0x49
+	NASEPSMessageTypeTrackingAreaUpdateComplete     NASEPSMessageType = 0x4a
+	NASEPSMessageTypeTrackingAreaUpdateReject       NASEPSMessageType = 0x4b
+	NASEPSMessageTypeExtendedServiceRequest         NASEPSMessageType = 0x4c
+	NASEPSMessageTypeServiceReject                  NASEPSMessageType = 0x4e
+	NASEPSMessageTypeGUTIReallocationCommand        NASEPSMessageType = 0x50
+	NASEPSMessageTypeGUTIReallocationComplete       NASEPSMessageType = 0x51
+	NASEPSMessageTypeAuthenticationRequest          NASEPSMessageType = 0x52
+	NASEPSMessageTypeAuthenticationResponse         NASEPSMessageType = 0x53
+	NASEPSMessageTypeAuthenticationReject           NASEPSMessageType = 0x54
+	NASEPSMessageTypeIdentityRequest                NASEPSMessageType = 0x55
+	NASEPSMessageTypeIdentityResponse               NASEPSMessageType = 0x56
+	NASEPSMessageTypeAuthenticationFailure          NASEPSMessageType = 0x5c
+	NASEPSMessageTypeSecurityModeCommand            NASEPSMessageType = 0x5d
+	NASEPSMessageTypeSecurityModeComplete           NASEPSMessageType = 0x5e
+	NASEPSMessageTypeSecurityModeReject             NASEPSMessageType = 0x5f
+	NASEPSMessageTypeEMMStatus                      NASEPSMessageType = 0x60
+	NASEPSMessageTypeEMMInformation                 NASEPSMessageType = 0x61
+	NASEPSMessageTypeDownlinkNASTransport           NASEPSMessageType = 0x62
+	NASEPSMessageTypeUplinkNASTransport             NASEPSMessageType = 0x63
+	NASEPSMessageTypeActivateDefaultBearerRequest   NASEPSMessageType = 0xc1
+	NASEPSMessageTypeActivateDefaultBearerAccept    NASEPSMessageType = 0xc2
+	NASEPSMessageTypeActivateDefaultBearerReject    NASEPSMessageType = 0xc3
+	NASEPSMessageTypeActivateDedicatedBearerRequest NASEPSMessageType = 0xc5
+	NASEPSMessageTypeActivateDedicatedBearerAccept  NASEPSMessageType = 0xc6
+	NASEPSMessageTypeDeactivateBearerRequest        NASEPSMessageType = 0xcd
+	NASEPSMessageTypeDeactivateBearerAccept         NASEPSMessageType = 0xce
+	NASEPSMessageTypePDNConnectivityRequest         NASEPSMessageType = 0xd0
+	NASEPSMessageTypePDNConnectivityReject          NASEPSMessageType = 0xd1
+	NASEPSMessageTypePDNDisconnectRequest           NASEPSMessageType = 0xd2
+	NASEPSMessageTypePDNDisconnectReject            NASEPSMessageType = 0xd3
+	NASEPSMessageTypeESMInformationRequest          NASEPSMessageType = 0xd9
+	NASEPSMessageTypeESMInformationResponse         NASEPSMessageType = 0xda
+	NASEPSMessageTypeESMStatus                      NASEPSMessageType = 0xe8
+)
+
+var nasEPSMessageTypeNames = map[NASEPSMessageType]string{
+	NASEPSMessageTypeAttachRequest:                  "AttachRequest",
+	NASEPSMessageTypeAttachAccept:                   "AttachAccept",
+	NASEPSMessageTypeAttachComplete:                 "AttachComplete",
+	NASEPSMessageTypeAttachReject:                   "AttachReject",
+	NASEPSMessageTypeDetachRequest:                  "DetachRequest",
+	NASEPSMessageTypeDetachAccept:                   "DetachAccept",
+	NASEPSMessageTypeTrackingAreaUpdateRequest:      "TrackingAreaUpdateRequest",
+	NASEPSMessageTypeTrackingAreaUpdateAccept:       "TrackingAreaUpdateAccept",
+	NASEPSMessageTypeTrackingAreaUpdateComplete:     "TrackingAreaUpdateComplete",
+	NASEPSMessageTypeTrackingAreaUpdateReject:       "TrackingAreaUpdateReject",
+	NASEPSMessageTypeExtendedServiceRequest:         "ExtendedServiceRequest",
+	NASEPSMessageTypeServiceReject:                  "ServiceReject",
+	NASEPSMessageTypeGUTIReallocationCommand:        "GUTIReallocationCommand",
+	NASEPSMessageTypeGUTIReallocationComplete:       "GUTIReallocationComplete",
+	NASEPSMessageTypeAuthenticationRequest:          "AuthenticationRequest",
+	NASEPSMessageTypeAuthenticationResponse:         "AuthenticationResponse",
+	NASEPSMessageTypeAuthenticationReject:           "AuthenticationReject",
+	NASEPSMessageTypeIdentityRequest:                "IdentityRequest",
+	NASEPSMessageTypeIdentityResponse:               "IdentityResponse",
+	NASEPSMessageTypeAuthenticationFailure:          "AuthenticationFailure",
+	NASEPSMessageTypeSecurityModeCommand:            "SecurityModeCommand",
+	NASEPSMessageTypeSecurityModeComplete:           "SecurityModeComplete",
+	NASEPSMessageTypeSecurityModeReject:             "SecurityModeReject",
+	NASEPSMessageTypeEMMStatus:                      "EMMStatus",
+	NASEPSMessageTypeEMMInformation:                 "EMMInformation",
+	NASEPSMessageTypeDownlinkNASTransport:           "DownlinkNASTransport",
+	NASEPSMessageTypeUplinkNASTransport:             "UplinkNASTransport",
+	NASEPSMessageTypeActivateDefaultBearerRequest:   "ActivateDefaultEPSBearerContextRequest",
+	NASEPSMessageTypeActivateDefaultBearerAccept:    "ActivateDefaultEPSBearerContextAccept",
+	NASEPSMessageTypeActivateDefaultBearerReject:    "ActivateDefaultEPSBearerContextReject",
+	NASEPSMessageTypeActivateDedicatedBearerRequest: "ActivateDedicatedEPSBearerContextRequest",
+	NASEPSMessageTypeActivateDedicatedBearerAccept:  "ActivateDedicatedEPSBearerContextAccept",
+	NASEPSMessageTypeDeactivateBearerRequest:        "DeactivateEPSBearerContextRequest",
+	NASEPSMessageTypeDeactivateBearerAccept:         "DeactivateEPSBearerContextAccept",
+	NASEPSMessageTypePDNConnectivityRequest:         "PDNConnectivityRequest",
+	NASEPSMessageTypePDNConnectivityReject:          "PDNConnectivityReject",
+	NASEPSMessageTypePDNDisconnectRequest:           "PDNDisconnectRequest",
+	NASEPSMessageTypePDNDisconnectReject:            "PDNDisconnectReject",
+	NASEPSMessageTypeESMInformationRequest:          "ESMInformationRequest",
+	NASEPSMessageTypeESMInformationResponse:         "ESMInformationResponse",
+	NASEPSMessageTypeESMStatus:                      "ESMStatus",
+}
+
+func (t NASEPSMessageType) String() string {
+	if s, ok := nasEPSMessageTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown(%#02x)", uint8(t))
+}
+
+// NASEPS is the header of an EPS NAS message (3GPP TS 24.301), exchanged
+// between UEs and MMEs, usually carried by S1AP.
+//
+// A security protected message has a header made of MAC and SequenceNumber,
+// and wraps another NAS message: plain messages which are only integrity
+// protected are decoded as a further NASEPS layer, ciphered ones are left
+// as payload. The short service request message has neither a message type
+// nor a payload: its KSI and sequence number and short MAC are in
+// SequenceNumber and MAC.
+type NASEPS struct {
+	BaseLayer
+	SecurityHeaderType    NASSecurityHeaderType
+	ProtocolDiscriminator NASProtocolDiscriminator
+	MAC                   uint32
+	SequenceNumber        uint8
+	// EPSBearerIdentity and ProcedureTransactionIdentity are only set for
+	// ESM messages.
+	EPSBearerIdentity            uint8
+	ProcedureTransactionIdentity uint8
+	MessageType                  NASEPSMessageType
+}
+
+// LayerType returns LayerTypeNASEPS.
+func (n *NASEPS) LayerType() gopacket.LayerType { return LayerTypeNASEPS }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NASEPS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("NAS message too short")
+	}
+	n.ProtocolDiscriminator = NASProtocolDiscriminator(data[0] & 0xf)
+	n.SecurityHeaderType, n.EPSBearerIdentity, n.ProcedureTransactionIdentity = 0, 0, 0
+	n.MAC, n.SequenceNumber, n.MessageType = 0, 0, 0
+	var length int
+	switch n.ProtocolDiscriminator {
+	case NASProtocolDiscriminatorEMM:
+		n.SecurityHeaderType = NASSecurityHeaderType(data[0] >> 4)
+		switch {
+		case n.SecurityHeaderType == NASSecurityHeaderTypePlain:
+			n.MessageType = NASEPSMessageType(data[1])
+			length = 2
+		case n.SecurityHeaderType.protected():
+			if len(data) < 6 {
+				df.SetTruncated()
+				return errors.New("NAS security header too short")
+			}
+			n.MAC = binary.BigEndian.Uint32(data[1:5])
+			n.SequenceNumber = data[5]
+			length = 6
+		case n.SecurityHeaderType == NASSecurityHeaderTypeServiceRequest:
+			if len(data) < 4 {
+				df.SetTruncated()
+				return errors.New("NAS service request too short")
+			}
+			n.SequenceNumber = data[1]
+			n.MAC = uint32(binary.BigEndian.Uint16(data[2:4]))
+			length = 4
+		default:
+			return fmt.Errorf("invalid NAS security header type %d", n.SecurityHeaderType)
+		}
+	case NASProtocolDiscriminatorESM:
+		if len(data) < 3 {
+			df.SetTruncated()
+			return errors.New("NAS ESM message too short")
+		}
+		n.EPSBearerIdentity = data[0] >> 4
+		n.ProcedureTransactionIdentity = data[1]
+		n.MessageType = NASEPSMessageType(data[2])
+		length = 3
+	default:
+		return fmt.Errorf("unsupported NAS protocol discriminator %d", n.ProtocolDiscriminator)
+	}
+	n.BaseLayer = BaseLayer{Contents: data[:length], Payload: data[length:]}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *NASEPS) CanDecode() gopacket.LayerClass {
+	return LayerTypeNASEPS
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (n *NASEPS) NextLayerType() gopacket.LayerType {
+	switch {
+	case len(n.Payload) == 0:
+		return gopacket.LayerTypeZero
+	case n.SecurityHeaderType.protected() && !n.SecurityHeaderType.ciphered():
+		return LayerTypeNASEPS
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeNASEPS(data []byte, p gopacket.PacketBuilder) error {
+	n := &NASEPS{}
+	return decodingLayerDecoder(n, data, p)
+}
+
+// NAS5GSProtocolDiscriminator is the extended protocol discriminator of a
+// 5GS NAS message.
+type NAS5GSProtocolDiscriminator uint8
+
+// 5GS NAS extended protocol discriminators, from 3GPP TS 24.007.
+const (
+	NAS5GSProtocolDiscriminator5GSM NAS5GSProtocolDiscriminator = 0x2e
+	NAS5GSProtocolDiscriminator5GMM NAS5GSProtocolDiscriminator = 0x7e
+)
+
+func (d NAS5GSProtocolDiscriminator) String() string {
+	switch d {
+	case NAS5GSProtocolDiscriminator5GSM:
+		return "5GSM"
+	case NAS5GSProtocolDiscriminator5GMM:
+		return "5GMM"
+	}
+	return fmt.Sprintf("Unknown(%#02x)", uint8(d))
+}
+
+// NAS5GSMessageType is the type of a 5GS mobility management (5GMM) or
+// session management (5GSM) message, whose values do not overlap.
+type NAS5GSMessageType uint8
+
+// 5GMM and 5GSM message types, from 3GPP TS 24.501.
+const (
+	NAS5GSMessageTypeRegistrationRequest                NAS5GSMessageType = 0x41
+	NAS5GSMessageTypeRegistrationAccept                 NAS5GSMessageType = 0x42
+	NAS5GSMessageTypeRegistrationComplete               NAS5GSMessageType = 0x43
+	NAS5GSMessageTypeRegistrationReject                 NAS5GSMessageType = 0x44
+	NAS5GSMessageTypeDeregistrationRequestUEOriginating NAS5GSMessageType = 0x45
+	NAS5GSMessageTypeDeregistrationAcceptUEOriginating  NAS5GSMessageType = 0x46
+	NAS5GSMessageTypeDeregistrationRequestUETerminated  NAS5GSMessageType = 0x47
+	NAS5GSMessageTypeDeregistrationAcceptUETerminated   NAS5GSMessageType = 0x48
+	NAS5GSMessageTypeServiceRequest                     NAS5GSMessageType = 0x4c
+	NAS5GSMessageTypeServiceReject                      NAS5GSMessageType = 0x4d
+	NAS5GSMessageTypeServiceAccept                      NAS5GSMessageType = 0x4e
+	NAS5GSMessageTypeConfigurationUpdateCommand         NAS5GSMessageType = 0x54
+	NAS5GSMessageTypeConfigurationUpdateComplete        NAS5GSMessageType = 0x55
+	NAS5GSMessageTypeAuthenticationRequest              NAS5GSMessageType = 0x56
+	NAS5GSMessageTypeAuthenticationResponse             NAS5GSMessageType = 0x57
+	NAS5GSMessageTypeAuthenticationReject               NAS5GSMessageType = 0x58
+	NAS5GSMessageTypeAuthenticationFailure              NAS5GSMessageType = 0x59
+	NAS5GSMessageTypeAuthenticationResult               NAS5GSMessageType = 0x5a
+	NAS5GSMessageTypeIdentityRequest                    NAS5GSMessageType = 0x5b
+	NAS5GSMessageTypeIdentityResponse                   NAS5GSMessageType = 0x5c
+	NAS5GSMessageTypeSecurityModeCommand                NAS5GSMessageType = 0x5d
+	NAS5GSMessageTypeSecurityModeComplete               NAS5GSMessageType = 0x5e
+	NAS5GSMessageTypeSecurityModeReject                 NAS5GSMessageType = 0x5f
+	NAS5GSMessageType5GMMStatus                         NAS5GSMessageType = 0x64
+	NAS5GSMessageTypeNotification                       NAS5GSMessageType = 0x65
+	NAS5GSMessageTypeNotificationResponse               NAS5GSMessageType = 0x66
+	NAS5GSMessageTypeULNASTransport                     NAS5GSMessageType = 0x67
+	NAS5GSMessageTypeDLNASTransport                     NAS5GSMessageType = 0x68
+	NAS5GSMessageTypePDUSessionEstablishmentRequest     NAS5GSMessageType = 0xc1
+	NAS5GSMessageTypePDUSessionEstablishmentAccept      NAS5GSMessageType = 0xc2
+	NAS5GSMessageTypePDUSessionEstablishmentReject      NAS5GSMessageType = 0xc3
+	NAS5GSMessageTypePDUSessionModificationRequest      NAS5GSMessageType = 0xc9
+	NAS5GSMessageTypePDUSessionModificationReject       NAS5GSMessageType = 0xca
+	NAS5GSMessageTypePDUSessionModificationCommand      NAS5GSMessageType = 0xcb
+	NAS5GSMessageTypePDUSessionModificationComplete     NAS5GSMessageType = 0xcc
+	NAS5GSMessageTypePDUSessionReleaseRequest           NAS5GSMessageType = 0xd1
+	NAS5GSMessageTypePDUSessionReleaseReject            NAS5GSMessageType = 0xd2
+	NAS5GSMessageTypePDUSessionReleaseCommand           NAS5GSMessageType = 0xd3
+	NAS5GSMessageTypePDUSessionReleaseComplete          NAS5GSMessageType = 0xd4
+	NAS5GSMessageType5GSMStatus                         NAS5GSMessageType = 0xd6
+)
+
+var nas5GSMessageTypeNames = map[NAS5GSMessageType]string{
+	NAS5GSMessageTypeRegistrationRequest:                "RegistrationRequest",
+	NAS5GSMessageTypeRegistrationAccept:                 "RegistrationAccept",
+	NAS5GSMessageTypeRegistrationComplete:               "RegistrationComplete",
+	NAS5GSMessageTypeRegistrationReject:                 "RegistrationReject",
+	NAS5GSMessageTypeDeregistrationRequestUEOriginating: "DeregistrationRequestUEOriginating",
+	NAS5GSMessageTypeDeregistrationAcceptUEOriginating:  "DeregistrationAcceptUEOriginating",
+	NAS5GSMessageTypeDeregistrationRequestUETerminated:  "DeregistrationRequestUETerminated",
+	NAS5GSMessageTypeDeregistrationAcceptUETerminated:   "DeregistrationAcceptUETerminated",
+	NAS5GSMessageTypeServiceRequest:                     "ServiceRequest",
+	NAS5GSMessageTypeServiceReject:                      "ServiceReject",
+	NAS5GSMessageTypeServiceAccept:                      "ServiceAccept",
+	NAS5GSMessageTypeConfigurationUpdateCommand:         "ConfigurationUpdateCommand",
+	NAS5GSMessageTypeConfigurationUpdateComplete:        "ConfigurationUpdateComplete",
+	NAS5GSMessageTypeAuthenticationRequest:              "AuthenticationRequest",
+	NAS5GSMessageTypeAuthenticationResponse:             "AuthenticationResponse",
+	NAS5GSMessageTypeAuthenticationReject:               "AuthenticationReject",
+	NAS5GSMessageTypeAuthenticationFailure:              "AuthenticationFailure",
+	NAS5GSMessageTypeAuthenticationResult:               "AuthenticationResult",
+	NAS5GSMessageTypeIdentityRequest:                    "IdentityRequest",
+	NAS5GSMessageTypeIdentityResponse:                   "IdentityResponse",
+	NAS5GSMessageTypeSecurityModeCommand:                "SecurityModeCommand",
+	NAS5GSMessageTypeSecurityModeComplete:               "SecurityModeComplete",
+	NAS5GSMessageTypeSecurityModeReject:                 "SecurityModeReject",
+	NAS5GSMessageType5GMMStatus:                         "5GMMStatus",
+	NAS5GSMessageTypeNotification:                       "Notification",
+	NAS5GSMessageTypeNotificationResponse:               "NotificationResponse",
+	NAS5GSMessageTypeULNASTransport:                     "ULNASTransport",
+	NAS5GSMessageTypeDLNASTransport:                     "DLNASTransport",
+	NAS5GSMessageTypePDUSessionEstablishmentRequest:     "PDUSessionEstablishmentRequest",
+	NAS5GSMessageTypePDUSessionEstablishmentAccept:      "PDUSessionEstablishmentAccept",
+	NAS5GSMessageTypePDUSessionEstablishmentReject:      "PDUSessionEstablishmentReject",
+	NAS5GSMessageTypePDUSessionModificationRequest:      "PDUSessionModificationRequest",
+	NAS5GSMessageTypePDUSessionModificationReject:       "PDUSessionModificationReject",
+	NAS5GSMessageTypePDUSessionModificationCommand:      "PDUSessionModificationCommand",
+	NAS5GSMessageTypePDUSessionModificationComplete:     "PDUSessionModificationComplete",
+	NAS5GSMessageTypePDUSessionReleaseRequest:           "PDUSessionReleaseRequest",
+	NAS5GSMessageTypePDUSessionReleaseReject:            "PDUSessionReleaseReject",
+	NAS5GSMessageTypePDUSessionReleaseCommand:           "PDUSessionReleaseCommand",
+	NAS5GSMessageTypePDUSessionReleaseComplete:          "PDUSessionReleaseComplete",
+	NAS5GSMessageType5GSMStatus:                         "5GSMStatus",
+}
+
+func (t NAS5GSMessageType) String() string {
+	if s, ok := nas5GSMessageTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown(%#02x)", uint8(t))
+}
+
+// NAS5GS is the header of a 5GS NAS message (3GPP TS 24.501), exchanged
+// between UEs and AMFs, usually carried by NGAP.
+//
+// As with NASEPS, a security protected 5GMM message has a header made of
+// MAC and SequenceNumber, and wraps another NAS message which is decoded as
+// a further NAS5GS layer if it is not ciphered.
+type NAS5GS struct {
+	BaseLayer
+	ProtocolDiscriminator NAS5GSProtocolDiscriminator
+	SecurityHeaderType    NASSecurityHeaderType
+	MAC                   uint32
+	SequenceNumber        uint8
+	// PDUSessionID and ProcedureTransactionIdentity are only set for 5GSM
+	// messages.
+	PDUSessionID                 uint8
+	ProcedureTransactionIdentity uint8
+	MessageType                  NAS5GSMessageType
+}
+
+// LayerType returns LayerTypeNAS5GS.
+func (n *NAS5GS) LayerType() gopacket.LayerType { return LayerTypeNAS5GS }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NAS5GS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 3 {
+		df.SetTruncated()
+		return errors.New("NAS message too short")
+	}
+	n.ProtocolDiscriminator = NAS5GSProtocolDiscriminator(data[0])
+	n.SecurityHeaderType, n.PDUSessionID, n.ProcedureTransactionIdentity = 0, 0, 0
+	n.MAC, n.SequenceNumber, n.MessageType = 0, 0, 0
+	var length int
+	switch n.ProtocolDiscriminator {
+	case NAS5GSProtocolDiscriminator5GMM:
+		n.SecurityHeaderType = NASSecurityHeaderType(data[1] & 0xf)
+		switch {
+		case n.SecurityHeaderType == NASSecurityHeaderTypePlain:
+			n.MessageType = NAS5GSMessageType(data[2])
+			length = 3
+		case n.SecurityHeaderType.protected():
+			if len(data) < 7 {
+				df.SetTruncated()
+				return errors.New("NAS security header too short")
+			}
+			n.MAC = binary.BigEndian.Uint32(data[2:6])
+			n.SequenceNumber = data[6]
+			length = 7
+		default:
+			return fmt.Errorf("invalid NAS security header type %d", n.SecurityHeaderType)
+		}
+	case NAS5GSProtocolDiscriminator5GSM:
+		if len(data) < 4 {
+			df.SetTruncated()
+			return errors.New("NAS 5GSM message too short")
+		}
+		n.PDUSessionID = data[1]
+		n.ProcedureTransactionIdentity = data[2]
+		n.MessageType = NAS5GSMessageType(data[3])
+		length = 4
+	default:
+		return fmt.Errorf("unsupported NAS extended protocol discriminator %#02x", uint8(n.ProtocolDiscriminator))
+	}
+	n.BaseLayer = BaseLayer{Contents: data[:length], Payload: data[length:]}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *NAS5GS) CanDecode() gopacket.LayerClass {
+	return LayerTypeNAS5GS
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (n *NAS5GS) NextLayerType() gopacket.LayerType {
+	switch {
+	case len(n.Payload) == 0:
+		return gopacket.LayerTypeZero
+	case n.SecurityHeaderType.protected() && !n.SecurityHeaderType.ciphered():
+		return LayerTypeNAS5GS
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeNAS5GS(data []byte, p gopacket.PacketBuilder) error {
+	n := &NAS5GS{}
+	return decodingLayerDecoder(n, data, p)
+}
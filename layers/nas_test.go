@@ -0,0 +1,95 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestNASEPS(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		data   []byte
+		layers []gopacket.LayerType
+		want   NASEPS
+	}{
+		{
+			name:   "ciphered",
+			data:   []byte{0x27, 0x12, 0x34, 0x56, 0x78, 0x02, 0xaa, 0xbb},
+			layers: []gopacket.LayerType{LayerTypeNASEPS, gopacket.LayerTypePayload},
+			want: NASEPS{
+				SecurityHeaderType:    NASSecurityHeaderTypeIntegrityProtectedCiphered,
+				ProtocolDiscriminator: NASProtocolDiscriminatorEMM,
+				MAC:                   0x12345678,
+				SequenceNumber:        2,
+			},
+		},
+		{
+			name:   "esm",
+			data:   []byte{0x52, 0x01, 0xc1, 0x01},
+			layers: []gopacket.LayerType{LayerTypeNASEPS, gopacket.LayerTypePayload},
+			want: NASEPS{
+				ProtocolDiscriminator:        NASProtocolDiscriminatorESM,
+				EPSBearerIdentity:            5,
+				ProcedureTransactionIdentity: 1,
+				MessageType:                  NASEPSMessageTypeActivateDefaultBearerRequest,
+			},
+		},
+		{
+			name:   "service request",
+			data:   []byte{0xc7, 0x21, 0xab, 0xcd},
+			layers: []gopacket.LayerType{LayerTypeNASEPS},
+			want: NASEPS{
+				SecurityHeaderType:    NASSecurityHeaderTypeServiceRequest,
+				ProtocolDiscriminator: NASProtocolDiscriminatorEMM,
+				MAC:                   0xabcd,
+				SequenceNumber:        0x21,
+			},
+		},
+	} {
+		p := gopacket.NewPacket(test.data, LayerTypeNASEPS, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, test.layers, t)
+		got := *p.Layer(LayerTypeNASEPS).(*NASEPS)
+		got.BaseLayer = BaseLayer{}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNAS5GSSessionManagement(t *testing.T) {
+	p := gopacket.NewPacket([]byte{0x2e, 0x01, 0x02, 0xc1, 0xff, 0xff}, LayerTypeNAS5GS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeNAS5GS, gopacket.LayerTypePayload}, t)
+	n := p.Layer(LayerTypeNAS5GS).(*NAS5GS)
+	if n.PDUSessionID != 1 || n.ProcedureTransactionIdentity != 2 || n.MessageType != NAS5GSMessageTypePDUSessionEstablishmentRequest {
+		t.Errorf("got %+v", n)
+	}
+}
+
+func TestNASInvalid(t *testing.T) {
+	var e NASEPS
+	if err := e.DecodeFromBytes([]byte{0x07}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded a truncated EPS NAS message")
+	}
+	if err := e.DecodeFromBytes([]byte{0x57, 0x41}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded an invalid security header type")
+	}
+	var f NAS5GS
+	if err := f.DecodeFromBytes([]byte{0x7e, 0x02, 0x00, 0x00}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded a truncated 5GS security header")
+	}
+}
@@ -42,6 +42,37 @@ const (
 	NSSALSAtypeV2           = 0x7
 	LinkLSAtype             = 0x0008
 	IntraAreaPrefixLSAtype  = 0x2009
+	OpaqueLinkLSAtypeV2     = 0x9
+	OpaqueAreaLSAtypeV2     = 0xa
+	OpaqueASLSAtypeV2       = 0xb
+)
+
+// OSPFv3 options (RFC 5340 A.2), as found in the Options fields of Hello,
+// Database Description, Router, Network, Inter-Area-Router and Link LSAs.
+const (
+	OSPFv3OptionV6 = 0x0001
+	OSPFv3OptionE  = 0x0002
+	OSPFv3OptionN  = 0x0008
+	OSPFv3OptionR  = 0x0010
+	OSPFv3OptionDC = 0x0020
+	OSPFv3OptionAF = 0x0100 // RFC 5838
+	OSPFv3OptionL  = 0x0200 // RFC 5613
+	OSPFv3OptionAT = 0x0400 // RFC 7166
+)
+
+// OSPFv3 prefix options (RFC 5340 A.4.1.1), as found in Prefix.PrefixOptions.
+const (
+	OSPFv3PrefixOptionNU = 0x01
+	OSPFv3PrefixOptionLA = 0x02
+	OSPFv3PrefixOptionP  = 0x08
+	OSPFv3PrefixOptionDN = 0x10
+)
+
+// OSPFv3 AS-external and NSSA LSA flags, as found in ASExternalLSA.Flags.
+const (
+	OSPFv3ExternalFlagT = 0x01
+	OSPFv3ExternalFlagF = 0x02
+	OSPFv3ExternalFlagE = 0x04
 )
 
 // String conversions for OSPFType
@@ -125,6 +156,22 @@ type InterAreaPrefixLSA struct {
 	AddressPrefix []byte
 }
 
+// SummaryLSAV2 is the struct from RFC 2328  A.4.4, used for both network
+// (type 3) and ASBR (type 4) summary LSAs.
+type SummaryLSAV2 struct {
+	NetworkMask uint32
+	Metric      uint32
+}
+
+// OpaqueLSAV2 is the struct from RFC 5250  A.2. OpaqueType and OpaqueID are
+// the two parts of the Link State ID; when serializing, the LSA header's
+// LinkStateID is used as is.
+type OpaqueLSAV2 struct {
+	OpaqueType uint8
+	OpaqueID   uint32
+	Data       []byte
+}
+
 // NetworkLSA is the struct from RFC 5340  A.4.4.
 type NetworkLSA struct {
 	Options        uint32
@@ -137,12 +184,19 @@ type NetworkLSAV2 struct {
 	AttachedRouter []uint32
 }
 
+// RouterTOSV2 is a TOS-specific metric of a RouterV2 link.
+type RouterTOSV2 struct {
+	TOS    uint8
+	Metric uint16
+}
+
 // RouterV2 extends RouterLSAV2
 type RouterV2 struct {
 	Type     uint8
 	LinkID   uint32
 	LinkData uint32
 	Metric   uint16
+	TOS      []RouterTOSV2
 }
 
 // RouterLSAV2 is the struct from RFC 2328  A.4.2.
@@ -253,12 +307,53 @@ type OSPFv3 struct {
 	Reserved uint8
 }
 
+// OSPFv3AddressFamily is the address family an OSPFv3 instance carries
+// routes for, as encoded in its Instance ID by RFC 5838.
+type OSPFv3AddressFamily uint8
+
+// Address families of RFC 5838 section 2.1.
+const (
+	OSPFv3AddressFamilyIPv6Unicast OSPFv3AddressFamily = iota
+	OSPFv3AddressFamilyIPv6Multicast
+	OSPFv3AddressFamilyIPv4Unicast
+	OSPFv3AddressFamilyIPv4Multicast
+	OSPFv3AddressFamilyUnknown
+)
+
+func (a OSPFv3AddressFamily) String() string {
+	switch a {
+	case OSPFv3AddressFamilyIPv6Unicast:
+		return "IPv6 unicast"
+	case OSPFv3AddressFamilyIPv6Multicast:
+		return "IPv6 multicast"
+	case OSPFv3AddressFamilyIPv4Unicast:
+		return "IPv4 unicast"
+	case OSPFv3AddressFamilyIPv4Multicast:
+		return "IPv4 multicast"
+	}
+	return "Unknown"
+}
+
+// AddressFamily returns the address family implied by the Instance ID.
+// Instances 128 to 255 are unassigned and return
+// OSPFv3AddressFamilyUnknown. Routers not implementing RFC 5838 may use any
+// Instance ID for IPv6 unicast.
+func (ospf *OSPFv3) AddressFamily() OSPFv3AddressFamily {
+	if ospf.Instance >= 128 {
+		return OSPFv3AddressFamilyUnknown
+	}
+	return OSPFv3AddressFamily(ospf.Instance / 32)
+}
+
 // getLSAsv2 parses the LSA information from the packet for OSPFv2
 func getLSAsv2(num uint32, data []byte) ([]LSA, error) {
 	var lsas []LSA
 	var i uint32 = 0
 	var offset uint32 = 0
 	for ; i < num; i++ {
+		if len(data) < int(offset+20) {
+			return nil, errors.New("LSA header truncated")
+		}
 		lstype := uint16(data[offset+3])
 		lsalength := binary.BigEndian.Uint16(data[offset+18 : offset+20])
 		content, err := extractLSAInformation(lstype, lsalength, data[offset:])
@@ -297,7 +392,7 @@ func extractLSAInformation(lstype, lsalength uint16, data []byte) (interface{},
 	case RouterLSAtypeV2:
 		var routers []RouterV2
 		var j uint32
-		for j = 24; j < uint32(lsalength); j += 12 {
+		for j = 24; j < uint32(lsalength); {
 			if len(data) < int(j+12) {
 				return nil, errors.New("LSAtypeV2 too small")
 			}
@@ -307,6 +402,18 @@ func extractLSAInformation(lstype, lsalength uint16, data []byte) (interface{},
 				Type:     uint8(data[j+8]),
 				Metric:   binary.BigEndian.Uint16(data[j+10 : j+12]),
 			}
+			numTOS := int(data[j+9])
+			j += 12
+			for k := 0; k < numTOS; k++ {
+				if len(data) < int(j+4) {
+					return nil, errors.New("LSAtypeV2 TOS metrics truncated")
+				}
+				router.TOS = append(router.TOS, RouterTOSV2{
+					TOS:    data[j],
+					Metric: binary.BigEndian.Uint16(data[j+2 : j+4]),
+				})
+				j += 4
+			}
 			routers = append(routers, router)
 		}
 		if len(data) < 24 {
@@ -321,6 +428,9 @@ func extractLSAInformation(lstype, lsalength uint16, data []byte) (interface{},
 	case NSSALSAtypeV2:
 		fallthrough
 	case ASExternalLSAtypeV2:
+		if lsalength < 36 {
+			return nil, errors.New("AS-external LSA too small")
+		}
 		content = ASExternalLSAV2{
 			NetworkMask:       binary.BigEndian.Uint32(data[20:24]),
 			ExternalBit:       data[24] & 0x80,
@@ -328,7 +438,24 @@ func extractLSAInformation(lstype, lsalength uint16, data []byte) (interface{},
 			ForwardingAddress: binary.BigEndian.Uint32(data[28:32]),
 			ExternalRouteTag:  binary.BigEndian.Uint32(data[32:36]),
 		}
+	case SummaryLSANetworktypeV2, SummaryLSAASBRtypeV2:
+		if lsalength < 28 {
+			return nil, errors.New("Summary LSA too small")
+		}
+		content = SummaryLSAV2{
+			NetworkMask: binary.BigEndian.Uint32(data[20:24]),
+			Metric:      binary.BigEndian.Uint32(data[24:28]) & 0x00FFFFFF,
+		}
+	case OpaqueLinkLSAtypeV2, OpaqueAreaLSAtypeV2, OpaqueASLSAtypeV2:
+		content = OpaqueLSAV2{
+			OpaqueType: data[4],
+			OpaqueID:   binary.BigEndian.Uint32(data[4:8]) & 0x00FFFFFF,
+			Data:       data[20:lsalength],
+		}
 	case NetworkLSAtypeV2:
+		if lsalength < 24 {
+			return nil, errors.New("Network LSA too small")
+		}
 		var routers []uint32
 		var j uint32
 		for j = 24; j < uint32(lsalength); j += 4 {
@@ -382,64 +509,91 @@ func extractLSAInformation(lstype, lsalength uint16, data []byte) (interface{},
 	case ASExternalLSAtype:
 		fallthrough
 	case NSSALSAtype:
-		flags := uint8(data[20])
-		prefixLen := uint8(data[24]) / 8
-		var forwardingAddress []byte
-		if (flags & 0x02) == 0x02 {
-			forwardingAddress = data[28+uint32(prefixLen) : 28+uint32(prefixLen)+16]
-		}
-		content = ASExternalLSA{
-			Flags:             flags,
-			Metric:            binary.BigEndian.Uint32(data[20:24]) & 0x00FFFFFF,
-			PrefixLength:      prefixLen,
-			PrefixOptions:     uint8(data[25]),
-			RefLSType:         binary.BigEndian.Uint16(data[26:28]),
-			AddressPrefix:     data[28 : 28+uint32(prefixLen)],
-			ForwardingAddress: forwardingAddress,
+		lsa := data[:lsalength]
+		if len(lsa) < 28 {
+			return nil, errors.New("AS-external LSA too small")
+		}
+		flags := uint8(lsa[20])
+		prefixLen := lsa[24]
+		end := 28 + ospfv3PrefixBytes(prefixLen)
+		if len(lsa) < end {
+			return nil, errors.New("AS-external LSA prefix truncated")
+		}
+		ext := ASExternalLSA{
+			Flags:         flags,
+			Metric:        binary.BigEndian.Uint32(lsa[20:24]) & 0x00FFFFFF,
+			PrefixLength:  prefixLen,
+			PrefixOptions: uint8(lsa[25]),
+			RefLSType:     binary.BigEndian.Uint16(lsa[26:28]),
+			AddressPrefix: lsa[28:end],
+		}
+		if flags&OSPFv3ExternalFlagF != 0 {
+			if len(lsa) < end+16 {
+				return nil, errors.New("AS-external LSA forwarding address truncated")
+			}
+			ext.ForwardingAddress = lsa[end : end+16]
+			end += 16
 		}
+		if flags&OSPFv3ExternalFlagT != 0 {
+			if len(lsa) < end+4 {
+				return nil, errors.New("AS-external LSA route tag truncated")
+			}
+			ext.ExternalRouteTag = binary.BigEndian.Uint32(lsa[end : end+4])
+			end += 4
+		}
+		if ext.RefLSType != 0 {
+			if len(lsa) < end+4 {
+				return nil, errors.New("AS-external LSA referenced Link State ID truncated")
+			}
+			ext.RefLinkStateID = binary.BigEndian.Uint32(lsa[end : end+4])
+		}
+		content = ext
 	case LinkLSAtype:
+		lsa := data[:lsalength]
+		if len(lsa) < 44 {
+			return nil, errors.New("Link LSA too small")
+		}
 		var prefixes []Prefix
-		var prefixOffset uint32 = 44
-		var j uint32
-		numOfPrefixes := binary.BigEndian.Uint32(data[40:44])
-		for j = 0; j < numOfPrefixes; j++ {
-			prefixLen := uint8(data[prefixOffset])
-			prefix := Prefix{
-				PrefixLength:  prefixLen,
-				PrefixOptions: uint8(data[prefixOffset+1]),
-				AddressPrefix: data[prefixOffset+4 : prefixOffset+4+uint32(prefixLen)/8],
+		offset := 44
+		numOfPrefixes := binary.BigEndian.Uint32(lsa[40:44])
+		for j := uint32(0); j < numOfPrefixes; j++ {
+			prefix, next, err := decodeOSPFv3Prefix(lsa, offset)
+			if err != nil {
+				return nil, err
 			}
+			// The third byte of a Link LSA prefix is reserved.
+			prefix.Metric = 0
 			prefixes = append(prefixes, prefix)
-			prefixOffset = prefixOffset + 4 + uint32(prefixLen)/8
+			offset = next
 		}
 		content = LinkLSA{
-			RtrPriority:      uint8(data[20]),
-			Options:          binary.BigEndian.Uint32(data[20:24]) & 0x00FFFFFF,
-			LinkLocalAddress: data[24:40],
+			RtrPriority:      uint8(lsa[20]),
+			Options:          binary.BigEndian.Uint32(lsa[20:24]) & 0x00FFFFFF,
+			LinkLocalAddress: lsa[24:40],
 			NumOfPrefixes:    numOfPrefixes,
 			Prefixes:         prefixes,
 		}
 	case IntraAreaPrefixLSAtype:
+		lsa := data[:lsalength]
+		if len(lsa) < 32 {
+			return nil, errors.New("Intra-Area-Prefix LSA too small")
+		}
 		var prefixes []Prefix
-		var prefixOffset uint32 = 32
-		var j uint16
-		numOfPrefixes := binary.BigEndian.Uint16(data[20:22])
-		for j = 0; j < numOfPrefixes; j++ {
-			prefixLen := uint8(data[prefixOffset])
-			prefix := Prefix{
-				PrefixLength:  prefixLen,
-				PrefixOptions: uint8(data[prefixOffset+1]),
-				Metric:        binary.BigEndian.Uint16(data[prefixOffset+2 : prefixOffset+4]),
-				AddressPrefix: data[prefixOffset+4 : prefixOffset+4+uint32(prefixLen)/8],
+		offset := 32
+		numOfPrefixes := binary.BigEndian.Uint16(lsa[20:22])
+		for j := uint16(0); j < numOfPrefixes; j++ {
+			prefix, next, err := decodeOSPFv3Prefix(lsa, offset)
+			if err != nil {
+				return nil, err
 			}
 			prefixes = append(prefixes, prefix)
-			prefixOffset = prefixOffset + 4 + uint32(prefixLen)
+			offset = next
 		}
 		content = IntraAreaPrefixLSA{
 			NumOfPrefixes:  numOfPrefixes,
-			RefLSType:      binary.BigEndian.Uint16(data[22:24]),
-			RefLinkStateID: binary.BigEndian.Uint32(data[24:28]),
-			RefAdvRouter:   binary.BigEndian.Uint32(data[28:32]),
+			RefLSType:      binary.BigEndian.Uint16(lsa[22:24]),
+			RefLinkStateID: binary.BigEndian.Uint32(lsa[24:28]),
+			RefAdvRouter:   binary.BigEndian.Uint32(lsa[28:32]),
 			Prefixes:       prefixes,
 		}
 	default:
@@ -448,6 +602,32 @@ func extractLSAInformation(lstype, lsalength uint16, data []byte) (interface{},
 	return content, nil
 }
 
+// ospfv3PrefixBytes returns the number of bytes used to encode an address
+// prefix of the given length, which is padded to whole 32 bit words (RFC
+// 5340 A.4.1).
+func ospfv3PrefixBytes(prefixLen uint8) int {
+	return (int(prefixLen) + 31) / 32 * 4
+}
+
+// decodeOSPFv3Prefix decodes the prefix starting at offset in lsa and
+// returns the offset following it.
+func decodeOSPFv3Prefix(lsa []byte, offset int) (Prefix, int, error) {
+	if len(lsa) < offset+4 {
+		return Prefix{}, 0, errors.New("OSPFv3 prefix truncated")
+	}
+	prefixLen := lsa[offset]
+	end := offset + 4 + ospfv3PrefixBytes(prefixLen)
+	if prefixLen > 128 || len(lsa) < end {
+		return Prefix{}, 0, fmt.Errorf("invalid OSPFv3 prefix length %d", prefixLen)
+	}
+	return Prefix{
+		PrefixLength:  prefixLen,
+		PrefixOptions: lsa[offset+1],
+		Metric:        binary.BigEndian.Uint16(lsa[offset+2 : offset+4]),
+		AddressPrefix: lsa[offset+4 : end],
+	}, end, nil
+}
+
 // getLSAs parses the LSA information from the packet for OSPFv3
 func getLSAs(num uint32, data []byte) ([]LSA, error) {
 	var lsas []LSA
@@ -455,6 +635,9 @@ func getLSAs(num uint32, data []byte) ([]LSA, error) {
 	var offset uint32 = 0
 	for ; i < num; i++ {
 		var content interface{}
+		if len(data) < int(offset+20) {
+			return nil, errors.New("LSA header truncated")
+		}
 		lstype := binary.BigEndian.Uint16(data[offset+2 : offset+4])
 		lsalength := binary.BigEndian.Uint16(data[offset+18 : offset+20])
 
@@ -494,6 +677,10 @@ func (ospf *OSPFv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	ospf.Checksum = binary.BigEndian.Uint16(data[12:14])
 	ospf.AuType = binary.BigEndian.Uint16(data[14:16])
 	ospf.Authentication = binary.BigEndian.Uint64(data[16:24])
+	if int(ospf.PacketLength) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("OSPF packet length %d exceeds %d bytes of data", ospf.PacketLength, len(data))
+	}
 
 	switch ospf.Type {
 	case OSPFHello:
@@ -518,7 +705,8 @@ func (ospf *OSPFv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 		for i := 32; uint16(i+20) <= ospf.PacketLength; i += 20 {
 			lsa := LSAheader{
 				LSAge:       binary.BigEndian.Uint16(data[i : i+2]),
-				LSType:      binary.BigEndian.Uint16(data[i+2 : i+4]),
+				LSOptions:   data[i+2],
+				LSType:      uint16(data[i+3]),
 				LinkStateID: binary.BigEndian.Uint32(data[i+4 : i+8]),
 				AdvRouter:   binary.BigEndian.Uint32(data[i+8 : i+12]),
 				LSSeqNumber: binary.BigEndian.Uint32(data[i+12 : i+16]),
@@ -591,6 +779,10 @@ func (ospf *OSPFv3) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	ospf.Checksum = binary.BigEndian.Uint16(data[12:14])
 	ospf.Instance = uint8(data[14])
 	ospf.Reserved = uint8(data[15])
+	if int(ospf.PacketLength) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("OSPF packet length %d exceeds %d bytes of data", ospf.PacketLength, len(data))
+	}
 
 	switch ospf.Type {
 	case OSPFHello:
@@ -672,6 +864,193 @@ func (ospf *OSPFv3) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	return nil
 }
 
+// ospfLSAChecksum computes the Fletcher checksum of an LSA (RFC 2328
+// section 12.1.7), which covers everything but the LS age field.
+func ospfLSAChecksum(lsa []byte) uint16 {
+	const offset = 14 // checksum position, not counting the LS age
+	data := lsa[2:]
+	var c0, c1 int
+	for i, b := range data {
+		if i == offset || i == offset+1 {
+			b = 0
+		}
+		c0 = (c0 + int(b)) % 255
+		c1 = (c1 + c0) % 255
+	}
+	x := ((len(data)-offset-1)*c0 - c1) % 255
+	if x <= 0 {
+		x += 255
+	}
+	y := 510 - c0 - x
+	if y > 255 {
+		y -= 255
+	}
+	return uint16(x)<<8 | uint16(y)
+}
+
+// appendLSAheaderV2 appends the OSPFv2 form of an LSA header.
+func appendLSAheaderV2(b []byte, h *LSAheader) []byte {
+	var hdr [20]byte
+	binary.BigEndian.PutUint16(hdr[0:2], h.LSAge)
+	hdr[2] = h.LSOptions
+	hdr[3] = uint8(h.LSType)
+	binary.BigEndian.PutUint32(hdr[4:8], h.LinkStateID)
+	binary.BigEndian.PutUint32(hdr[8:12], h.AdvRouter)
+	binary.BigEndian.PutUint32(hdr[12:16], h.LSSeqNumber)
+	binary.BigEndian.PutUint16(hdr[16:18], h.LSChecksum)
+	binary.BigEndian.PutUint16(hdr[18:20], h.Length)
+	return append(b, hdr[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendLSAV2 appends an OSPFv2 LSA, updating its Length and LSChecksum if
+// requested by opts.
+func appendLSAV2(b []byte, lsa *LSA, opts gopacket.SerializeOptions) ([]byte, error) {
+	start := len(b)
+	b = appendLSAheaderV2(b, &lsa.LSAheader)
+	switch c := lsa.Content.(type) {
+	case RouterLSAV2:
+		links := c.Links
+		if opts.FixLengths {
+			links = uint16(len(c.Routers))
+		}
+		b = append(b, c.Flags, 0)
+		b = appendUint16(b, links)
+		for _, r := range c.Routers {
+			if len(r.TOS) > 255 {
+				return nil, fmt.Errorf("too many TOS metrics: %d", len(r.TOS))
+			}
+			b = appendUint32(b, r.LinkID)
+			b = appendUint32(b, r.LinkData)
+			b = append(b, r.Type, uint8(len(r.TOS)))
+			b = appendUint16(b, r.Metric)
+			for _, tos := range r.TOS {
+				b = append(b, tos.TOS, 0)
+				b = appendUint16(b, tos.Metric)
+			}
+		}
+	case NetworkLSAV2:
+		b = appendUint32(b, c.NetworkMask)
+		for _, r := range c.AttachedRouter {
+			b = appendUint32(b, r)
+		}
+	case SummaryLSAV2:
+		b = appendUint32(b, c.NetworkMask)
+		b = appendUint32(b, c.Metric&0x00FFFFFF)
+	case ASExternalLSAV2:
+		b = appendUint32(b, c.NetworkMask)
+		b = appendUint32(b, uint32(c.ExternalBit&0x80)<<24|c.Metric&0x00FFFFFF)
+		b = appendUint32(b, c.ForwardingAddress)
+		b = appendUint32(b, c.ExternalRouteTag)
+	case OpaqueLSAV2:
+		b = append(b, c.Data...)
+	default:
+		return nil, fmt.Errorf("cannot serialize OSPFv2 LSA content %T", lsa.Content)
+	}
+
+	raw := b[start:]
+	if opts.FixLengths {
+		lsa.Length = uint16(len(raw))
+		binary.BigEndian.PutUint16(raw[18:20], lsa.Length)
+	}
+	if opts.ComputeChecksums {
+		lsa.LSChecksum = ospfLSAChecksum(raw)
+		binary.BigEndian.PutUint16(raw[16:18], lsa.LSChecksum)
+	}
+	return b, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// With FixLengths, PacketLength and the Length of every LSA (and the link
+// and LSA counts) are set from the content. With ComputeChecksums, the
+// packet checksum and LSA checksums are computed; the packet checksum is
+// left zero for cryptographic authentication (AuType 2), as required by
+// RFC 2328 appendix D.4.3.
+func (ospf *OSPFv2) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var body []byte
+	switch c := ospf.Content.(type) {
+	case HelloPkgV2:
+		body = appendUint32(body, c.NetworkMask)
+		body = appendUint16(body, c.HelloInterval)
+		body = append(body, uint8(c.Options), c.RtrPriority)
+		body = appendUint32(body, c.RouterDeadInterval)
+		body = appendUint32(body, c.DesignatedRouterID)
+		body = appendUint32(body, c.BackupDesignatedRouterID)
+		for _, n := range c.NeighborID {
+			body = appendUint32(body, n)
+		}
+	case DbDescPkg:
+		body = appendUint16(body, c.InterfaceMTU)
+		body = append(body, uint8(c.Options), uint8(c.Flags))
+		body = appendUint32(body, c.DDSeqNumber)
+		for i := range c.LSAinfo {
+			body = appendLSAheaderV2(body, &c.LSAinfo[i])
+		}
+	case []LSReq:
+		for _, r := range c {
+			body = appendUint32(body, uint32(r.LSType))
+			body = appendUint32(body, r.LSID)
+			body = appendUint32(body, r.AdvRouter)
+		}
+	case LSUpdate:
+		if opts.FixLengths {
+			c.NumOfLSAs = uint32(len(c.LSAs))
+			ospf.Content = c
+		}
+		body = appendUint32(body, c.NumOfLSAs)
+		for i := range c.LSAs {
+			var err error
+			if body, err = appendLSAV2(body, &c.LSAs[i], opts); err != nil {
+				return err
+			}
+		}
+	case []LSAheader:
+		for i := range c {
+			body = appendLSAheaderV2(body, &c[i])
+		}
+	default:
+		return fmt.Errorf("cannot serialize OSPFv2 content %T", ospf.Content)
+	}
+
+	bytes, err := b.PrependBytes(24 + len(body))
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		ospf.Version = 2
+		ospf.PacketLength = uint16(len(bytes))
+	}
+	bytes[0] = ospf.Version
+	bytes[1] = uint8(ospf.Type)
+	binary.BigEndian.PutUint16(bytes[2:4], ospf.PacketLength)
+	binary.BigEndian.PutUint32(bytes[4:8], ospf.RouterID)
+	binary.BigEndian.PutUint32(bytes[8:12], ospf.AreaID)
+	binary.BigEndian.PutUint16(bytes[12:14], 0)
+	binary.BigEndian.PutUint16(bytes[14:16], ospf.AuType)
+	// The checksum excludes the authentication field.
+	binary.BigEndian.PutUint64(bytes[16:24], 0)
+	copy(bytes[24:], body)
+	if opts.ComputeChecksums {
+		ospf.Checksum = 0
+		if ospf.AuType != 2 {
+			ospf.Checksum = tcpipChecksum(bytes, 0)
+		}
+	}
+	binary.BigEndian.PutUint16(bytes[12:14], ospf.Checksum)
+	binary.BigEndian.PutUint64(bytes[16:24], ospf.Authentication)
+	return nil
+}
+
 // LayerType returns LayerTypeOSPF
 func (ospf *OSPFv2) LayerType() gopacket.LayerType {
 	return LayerTypeOSPF
@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -1215,3 +1216,163 @@ func BenchmarkDecodePacketPacket4(b *testing.B) {
 		gopacket.NewPacket(testPacketOSPF3LSAck, LinkTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestOSPFv2SerializeRoundTrip(t *testing.T) {
+	// testPacketOSPF2LSUpdateLSA2 and testPacketOSPF2LSUpdateLSA7 are left
+	// out since their OSPF headers carry neither a valid length nor a
+	// checksum.
+	for _, data := range [][]byte{
+		testPacketOSPF2Hello,
+		testPacketOSPF2DBDesc,
+		testPacketOSPF2LSRequest,
+		testPacketOSPF2LSUpdate,
+		testPacketOSPF2LSAck,
+	} {
+		p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+		ospf, ok := p.Layer(LayerTypeOSPF).(*OSPFv2)
+		if !ok {
+			t.Fatal("No OSPF layer type found in packet")
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, ospf); err != nil {
+			t.Fatal(err)
+		}
+		want := p.Layer(LayerTypeIPv4).LayerPayload()[:ospf.PacketLength]
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("OSPF %v serialization mismatch:\ngot  %x\nwant %x", ospf.Type, buf.Bytes(), want)
+		}
+	}
+}
+
+func TestOSPFv2SummaryAndOpaqueLSA(t *testing.T) {
+	lsas := []LSA{
+		{
+			LSAheader: LSAheader{LSAge: 1, LSOptions: 0x22, LSType: SummaryLSANetworktypeV2, LinkStateID: 0x0a000000, AdvRouter: 0x01010101, LSSeqNumber: 0x80000001},
+			Content:   SummaryLSAV2{NetworkMask: 0xffffff00, Metric: 20},
+		},
+		{
+			LSAheader: LSAheader{LSAge: 1, LSOptions: 0x42, LSType: OpaqueAreaLSAtypeV2, LinkStateID: 0x01000007, AdvRouter: 0x01010101, LSSeqNumber: 0x80000001},
+			Content:   OpaqueLSAV2{Data: []byte{0x00, 0x01, 0x00, 0x04, 0x01, 0x01, 0x01, 0x01}},
+		},
+		{
+			LSAheader: LSAheader{LSAge: 1, LSOptions: 0x22, LSType: RouterLSAtypeV2, LinkStateID: 0x01010101, AdvRouter: 0x01010101, LSSeqNumber: 0x80000001},
+			Content: RouterLSAV2{Routers: []RouterV2{
+				{Type: 3, LinkID: 0x0a000000, LinkData: 0xffffff00, Metric: 10, TOS: []RouterTOSV2{{TOS: 8, Metric: 5}}},
+			}},
+		},
+	}
+	ospf := &OSPFv2{OSPF: OSPF{Type: OSPFLinkStateUpdate, RouterID: 0x01010101, Content: LSUpdate{LSAs: lsas}}}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ospf); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeOSPF, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeOSPF).(*OSPFv2)
+	if got.Checksum != ospf.Checksum || got.PacketLength != uint16(len(buf.Bytes())) {
+		t.Errorf("got checksum %#x length %d", got.Checksum, got.PacketLength)
+	}
+	upd := got.Content.(LSUpdate)
+	if upd.NumOfLSAs != 3 {
+		t.Fatalf("got %d LSAs", upd.NumOfLSAs)
+	}
+	for i, lsa := range upd.LSAs {
+		raw := buf.Bytes()[28:]
+		for _, prev := range upd.LSAs[:i] {
+			raw = raw[prev.Length:]
+		}
+		if ck := ospfLSAChecksum(raw[:lsa.Length]); ck != lsa.LSChecksum {
+			t.Errorf("LSA %d: checksum %#x does not verify (%#x)", i, lsa.LSChecksum, ck)
+		}
+	}
+	if s, ok := upd.LSAs[0].Content.(SummaryLSAV2); !ok || s.NetworkMask != 0xffffff00 || s.Metric != 20 {
+		t.Errorf("got summary LSA %#v", upd.LSAs[0].Content)
+	}
+	if o, ok := upd.LSAs[1].Content.(OpaqueLSAV2); !ok || o.OpaqueType != 1 || o.OpaqueID != 7 || len(o.Data) != 8 {
+		t.Errorf("got opaque LSA %#v", upd.LSAs[1].Content)
+	}
+	r, ok := upd.LSAs[2].Content.(RouterLSAV2)
+	if !ok || r.Links != 1 || len(r.Routers) != 1 || !reflect.DeepEqual(r.Routers[0].TOS, []RouterTOSV2{{TOS: 8, Metric: 5}}) {
+		t.Errorf("got router LSA %#v", upd.LSAs[2].Content)
+	}
+}
+
+func TestOSPFv3PrefixLSAs(t *testing.T) {
+	lsaHeader := make([]byte, 20)
+	intra := append(append([]byte(nil), lsaHeader...),
+		0x00, 0x02, 0x20, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0x01,
+		// 2001:db8:1::/48, padded to two words
+		0x30, 0x00, 0x00, 0x0a, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x00, 0x00,
+		// 2001:db8::1/128 with the LA bit
+		0x80, 0x02, 0x00, 0x00, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	)
+	content, err := extractLSAInformation(IntraAreaPrefixLSAtype, uint16(len(intra)), intra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IntraAreaPrefixLSA{
+		NumOfPrefixes: 2,
+		RefLSType:     0x2001,
+		RefAdvRouter:  0x01010101,
+		Prefixes: []Prefix{
+			{PrefixLength: 48, Metric: 10, AddressPrefix: []byte{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x00, 0x00}},
+			{PrefixLength: 128, PrefixOptions: OSPFv3PrefixOptionLA, AddressPrefix: intra[48:64]},
+		},
+	}
+	if !reflect.DeepEqual(content, want) {
+		t.Errorf("got intra-area-prefix LSA %#v, want %#v", content, want)
+	}
+	if _, err := extractLSAInformation(IntraAreaPrefixLSAtype, uint16(len(intra)-1), intra[:len(intra)-1]); err == nil {
+		t.Error("truncated intra-area-prefix LSA decoded successfully")
+	}
+
+	ext := append(append([]byte(nil), lsaHeader...),
+		0x03, 0x00, 0x00, 0x14, 0x40, 0x00, 0x00, 0x01,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x02, 0x00, 0x00,
+		// forwarding address fe80::1
+		0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x64, // route tag
+		0x00, 0x00, 0x00, 0x07, // referenced link state ID
+	)
+	content, err = extractLSAInformation(ASExternalLSAtype, uint16(len(ext)), ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExt := ASExternalLSA{
+		Flags:             OSPFv3ExternalFlagF | OSPFv3ExternalFlagT,
+		Metric:            20,
+		PrefixLength:      64,
+		RefLSType:         1,
+		AddressPrefix:     ext[28:36],
+		ForwardingAddress: ext[36:52],
+		ExternalRouteTag:  100,
+		RefLinkStateID:    7,
+	}
+	if !reflect.DeepEqual(content, wantExt) {
+		t.Errorf("got AS-external LSA %#v, want %#v", content, wantExt)
+	}
+}
+
+func TestOSPFv3AddressFamily(t *testing.T) {
+	for _, test := range []struct {
+		instance uint8
+		want     OSPFv3AddressFamily
+	}{
+		{0, OSPFv3AddressFamilyIPv6Unicast},
+		{32, OSPFv3AddressFamilyIPv6Multicast},
+		{64, OSPFv3AddressFamilyIPv4Unicast},
+		{127, OSPFv3AddressFamilyIPv4Multicast},
+		{128, OSPFv3AddressFamilyUnknown},
+	} {
+		ospf := &OSPFv3{Instance: test.instance}
+		if got := ospf.AddressFamily(); got != test.want {
+			t.Errorf("instance %d: got %v, want %v", test.instance, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,767 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// PFCPMessageType is the type of a PFCP message.
+type PFCPMessageType uint8
+
+// PFCP message types, from 3GPP TS 29.244.
+const (
+	PFCPMessageTypeHeartbeatRequest             PFCPMessageType = 1
+	PFCPMessageTypeHeartbeatResponse            PFCPMessageType = 2
+	PFCPMessageTypePFDManagementRequest         PFCPMessageType = 3
+	PFCPMessageTypePFDManagementResponse        PFCPMessageType = 4
+	PFCPMessageTypeAssociationSetupRequest      PFCPMessageType = 5
+	PFCPMessageTypeAssociationSetupResponse     PFCPMessageType = 6
+	PFCPMessageTypeAssociationUpdateRequest     PFCPMessageType = 7
+	PFCPMessageTypeAssociationUpdateResponse    PFCPMessageType = 8
+	PFCPMessageTypeAssociationReleaseRequest    PFCPMessageType = 9
+	PFCPMessageTypeAssociationReleaseResponse   PFCPMessageType = 10
+	PFCPMessageTypeVersionNotSupportedResponse  PFCPMessageType = 11
+	PFCPMessageTypeNodeReportRequest            PFCPMessageType = 12
+	PFCPMessageTypeNodeReportResponse           PFCPMessageType = 13
+	PFCPMessageTypeSessionSetDeletionRequest    PFCPMessageType = 14
+	PFCPMessageTypeSessionSetDeletionResponse   PFCPMessageType = 15
+	PFCPMessageTypeSessionEstablishmentRequest  PFCPMessageType = 50
+	PFCPMessageTypeSessionEstablishmentResponse PFCPMessageType = 51
+	PFCPMessageTypeSessionModificationRequest   PFCPMessageType = 52
+	PFCPMessageTypeSessionModificationResponse  PFCPMessageType = 53
+	PFCPMessageTypeSessionDeletionRequest       PFCPMessageType = 54
+	PFCPMessageTypeSessionDeletionResponse      PFCPMessageType = 55
+	PFCPMessageTypeSessionReportRequest         PFCPMessageType = 56
+	PFCPMessageTypeSessionReportResponse        PFCPMessageType = 57
+)
+
+func (t PFCPMessageType) String() string {
+	switch t {
+	case PFCPMessageTypeHeartbeatRequest:
+		return "HeartbeatRequest"
+	case PFCPMessageTypeHeartbeatResponse:
+		return "HeartbeatResponse"
+	case PFCPMessageTypePFDManagementRequest:
+		return "PFDManagementRequest"
+	case PFCPMessageTypePFDManagementResponse:
+		return "PFDManagementResponse"
+	case PFCPMessageTypeAssociationSetupRequest:
+		return "AssociationSetupRequest"
+	case PFCPMessageTypeAssociationSetupResponse:
+		return "AssociationSetupResponse"
+	case PFCPMessageTypeAssociationUpdateRequest:
+		return "AssociationUpdateRequest"
+	case PFCPMessageTypeAssociationUpdateResponse:
+		return "AssociationUpdateResponse"
+	case PFCPMessageTypeAssociationReleaseRequest:
+		return "AssociationReleaseRequest"
+	case PFCPMessageTypeAssociationReleaseResponse:
+		return "AssociationReleaseResponse"
+	case PFCPMessageTypeVersionNotSupportedResponse:
+		return "VersionNotSupportedResponse"
+	case PFCPMessageTypeNodeReportRequest:
+		return "NodeReportRequest"
+	case PFCPMessageTypeNodeReportResponse:
+		return "NodeReportResponse"
+	case PFCPMessageTypeSessionSetDeletionRequest:
+		return "SessionSetDeletionRequest"
+	case PFCPMessageTypeSessionSetDeletionResponse:
+		return "SessionSetDeletionResponse"
+	case PFCPMessageTypeSessionEstablishmentRequest:
+		return "SessionEstablishmentRequest"
+	case PFCPMessageTypeSessionEstablishmentResponse:
+		return "SessionEstablishmentResponse"
+	case PFCPMessageTypeSessionModificationRequest:
+		return "SessionModificationRequest"
+	case PFCPMessageTypeSessionModificationResponse:
+		return "SessionModificationResponse"
+	case PFCPMessageTypeSessionDeletionRequest:
+		return "SessionDeletionRequest"
+	case PFCPMessageTypeSessionDeletionResponse:
+		return "SessionDeletionResponse"
+	case PFCPMessageTypeSessionReportRequest:
+		return "SessionReportRequest"
+	case PFCPMessageTypeSessionReportResponse:
+		return "SessionReportResponse"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// PFCPIEType is the type of a PFCP information element. Types from 32768
+// are vendor specific.
+type PFCPIEType uint16
+
+// PFCP IE types, from 3GPP TS 29.244.
+const (
+	PFCPIETypeCreatePDR               PFCPIEType = 1
+	PFCPIETypePDI                     PFCPIEType = 2
+	PFCPIETypeCreateFAR               PFCPIEType = 3
+	PFCPIETypeForwardingParameters    PFCPIEType = 4
+	PFCPIETypeCreateURR               PFCPIEType = 6
+	PFCPIETypeCreateQER               PFCPIEType = 7
+	PFCPIETypeCreatedPDR              PFCPIEType = 8
+	PFCPIETypeUpdatePDR               PFCPIEType = 9
+	PFCPIETypeUpdateFAR               PFCPIEType = 10
+	PFCPIETypeUpdateForwardingParam   PFCPIEType = 11
+	PFCPIETypeUpdateURR               PFCPIEType = 13
+	PFCPIETypeUpdateQER               PFCPIEType = 14
+	PFCPIETypeRemovePDR               PFCPIEType = 15
+	PFCPIETypeRemoveFAR               PFCPIEType = 16
+	PFCPIETypeRemoveURR               PFCPIEType = 17
+	PFCPIETypeRemoveQER               PFCPIEType = 18
+	PFCPIETypeCause                   PFCPIEType = 19
+	PFCPIETypeSourceInterface         PFCPIEType = 20
+	PFCPIETypeFTEID                   PFCPIEType = 21
+	PFCPIETypeNetworkInstance         PFCPIEType = 22
+	PFCPIETypeSDFFilter               PFCPIEType = 23
+	PFCPIETypePrecedence              PFCPIEType = 29
+	PFCPIETypeReportType              PFCPIEType = 39
+	PFCPIETypeOffendingIE             PFCPIEType = 40
+	PFCPIETypeDestinationInterface    PFCPIEType = 42
+	PFCPIETypeUPFunctionFeatures      PFCPIEType = 43
+	PFCPIETypeApplyAction             PFCPIEType = 44
+	PFCPIETypeLoadControlInfo         PFCPIEType = 51
+	PFCPIETypeOverloadControlInfo     PFCPIEType = 54
+	PFCPIETypePDRID                   PFCPIEType = 56
+	PFCPIETypeFSEID                   PFCPIEType = 57
+	PFCPIETypeApplicationIDsPFDs      PFCPIEType = 58
+	PFCPIETypePFDContext              PFCPIEType = 59
+	PFCPIETypeNodeID                  PFCPIEType = 60
+	PFCPIETypeAppDetectionInfo        PFCPIEType = 68
+	PFCPIETypeQueryURR                PFCPIEType = 77
+	PFCPIETypeUsageReportSMR          PFCPIEType = 78
+	PFCPIETypeUsageReportSDR          PFCPIEType = 79
+	PFCPIETypeUsageReportSRR          PFCPIEType = 80
+	PFCPIETypeURRID                   PFCPIEType = 81
+	PFCPIETypeDownlinkDataReport      PFCPIEType = 83
+	PFCPIETypeOuterHeaderCreation     PFCPIEType = 84
+	PFCPIETypeCreateBAR               PFCPIEType = 85
+	PFCPIETypeUpdateBAR               PFCPIEType = 86
+	PFCPIETypeRemoveBAR               PFCPIEType = 87
+	PFCPIETypeCPFunctionFeatures      PFCPIEType = 89
+	PFCPIETypeUEIPAddress             PFCPIEType = 93
+	PFCPIETypeOuterHeaderRemoval      PFCPIEType = 95
+	PFCPIETypeRecoveryTimeStamp       PFCPIEType = 96
+	PFCPIETypeErrorIndicationReport   PFCPIEType = 99
+	PFCPIETypeNodeReportType          PFCPIEType = 101
+	PFCPIETypeUPPathFailureReport     PFCPIEType = 102
+	PFCPIETypeUpdateDuplicatingParams PFCPIEType = 105
+	PFCPIETypeFARID                   PFCPIEType = 108
+	PFCPIETypeQERID                   PFCPIEType = 109
+)
+
+var pfcpIETypeNames = map[PFCPIEType]string{
+	PFCPIETypeCreatePDR:               "CreatePDR",
+	PFCPIETypePDI:                     "PDI",
+	PFCPIETypeCreateFAR:               "CreateFAR",
+	PFCPIETypeForwardingParameters:    "ForwardingParameters",
+	PFCPIETypeCreateURR:               "CreateURR",
+	PFCPIETypeCreateQER:               "CreateQER",
+	PFCPIETypeCreatedPDR:              "CreatedPDR",
+	PFCPIETypeUpdatePDR:               "UpdatePDR",
+	PFCPIETypeUpdateFAR:               "UpdateFAR",
+	PFCPIETypeUpdateForwardingParam:   "UpdateForwardingParameters",
+	PFCPIETypeUpdateURR:               "UpdateURR",
+	PFCPIETypeUpdateQER:               "UpdateQER",
+	PFCPIETypeRemovePDR:               "RemovePDR",
+	PFCPIETypeRemoveFAR:               "RemoveFAR",
+	PFCPIETypeRemoveURR:               "RemoveURR",
+	PFCPIETypeRemoveQER:               "RemoveQER",
+	PFCPIETypeCause:                   "Cause",
+	PFCPIETypeSourceInterface:         "SourceInterface",
+	PFCPIETypeFTEID:                   "F-TEID",
+	PFCPIETypeNetworkInstance:         "NetworkInstance",
+	PFCPIETypeSDFFilter:               "SDFFilter",
+	PFCPIETypePrecedence:              "Precedence",
+	PFCPIETypeReportType:              "ReportType",
+	PFCPIETypeOffendingIE:             "OffendingIE",
+	PFCPIETypeDestinationInterface:    "DestinationInterface",
+	PFCPIETypeUPFunctionFeatures:      "UPFunctionFeatures",
+	PFCPIETypeApplyAction:             "ApplyAction",
+	PFCPIETypeLoadControlInfo:         "LoadControlInformation",
+	PFCPIETypeOverloadControlInfo:     "OverloadControlInformation",
+	PFCPIETypePDRID:                   "PDRID",
+	PFCPIETypeFSEID:                   "F-SEID",
+	PFCPIETypeApplicationIDsPFDs:      "ApplicationIDsPFDs",
+	PFCPIETypePFDContext:              "PFDContext",
+	PFCPIETypeNodeID:                  "NodeID",
+	PFCPIETypeAppDetectionInfo:        "ApplicationDetectionInformation",
+	PFCPIETypeQueryURR:                "QueryURR",
+	PFCPIETypeUsageReportSMR:          "UsageReport",
+	PFCPIETypeUsageReportSDR:          "UsageReport",
+	PFCPIETypeUsageReportSRR:          "UsageReport",
+	PFCPIETypeURRID:                   "URRID",
+	PFCPIETypeDownlinkDataReport:      "DownlinkDataReport",
+	PFCPIETypeOuterHeaderCreation:     "OuterHeaderCreation",
+	PFCPIETypeCreateBAR:               "CreateBAR",
+	PFCPIETypeUpdateBAR:               "UpdateBAR",
+	PFCPIETypeRemoveBAR:               "RemoveBAR",
+	PFCPIETypeCPFunctionFeatures:      "CPFunctionFeatures",
+	PFCPIETypeUEIPAddress:             "UEIPAddress",
+	PFCPIETypeOuterHeaderRemoval:      "OuterHeaderRemoval",
+	PFCPIETypeRecoveryTimeStamp:       "RecoveryTimeStamp",
+	PFCPIETypeErrorIndicationReport:   "ErrorIndicationReport",
+	PFCPIETypeNodeReportType:          "NodeReportType",
+	PFCPIETypeUPPathFailureReport:     "UserPlanePathFailureReport",
+	PFCPIETypeUpdateDuplicatingParams: "UpdateDuplicatingParameters",
+	PFCPIETypeFARID:                   "FARID",
+	PFCPIETypeQERID:                   "QERID",
+}
+
+func (t PFCPIEType) String() string {
+	if s, ok := pfcpIETypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
+// Grouped returns whether IEs of type t contain other IEs.
+func (t PFCPIEType) Grouped() bool {
+	if t >= PFCPIETypeCreatePDR && t <= PFCPIETypeRemoveQER {
+		return true
+	}
+	switch t {
+	case PFCPIETypeLoadControlInfo, PFCPIETypeOverloadControlInfo, PFCPIETypeApplicationIDsPFDs,
+		PFCPIETypePFDContext, PFCPIETypeAppDetectionInfo, PFCPIETypeQueryURR,
+		PFCPIETypeUsageReportSMR, PFCPIETypeUsageReportSDR, PFCPIETypeUsageReportSRR,
+		PFCPIETypeDownlinkDataReport, PFCPIETypeCreateBAR, PFCPIETypeUpdateBAR, PFCPIETypeRemoveBAR,
+		PFCPIETypeErrorIndicationReport, PFCPIETypeUPPathFailureReport, PFCPIETypeUpdateDuplicatingParams:
+		return true
+	}
+	return false
+}
+
+// VendorSpecific returns whether IEs of type t are vendor specific, and
+// carry an enterprise ID.
+func (t PFCPIEType) VendorSpecific() bool {
+	return t >= 32768
+}
+
+// PFCPCause is the value of a PFCP Cause IE.
+type PFCPCause uint8
+
+// PFCP causes, from 3GPP TS 29.244.
+const (
+	PFCPCauseRequestAccepted             PFCPCause = 1
+	PFCPCauseRequestRejected             PFCPCause = 64
+	PFCPCauseSessionContextNotFound      PFCPCause = 65
+	PFCPCauseMandatoryIEMissing          PFCPCause = 66
+	PFCPCauseConditionalIEMissing        PFCPCause = 67
+	PFCPCauseInvalidLength               PFCPCause = 68
+	PFCPCauseMandatoryIEIncorrect        PFCPCause = 69
+	PFCPCauseInvalidForwardingPolicy     PFCPCause = 70
+	PFCPCauseInvalidFTEIDAllocation      PFCPCause = 71
+	PFCPCauseNoEstablishedAssociation    PFCPCause = 72
+	PFCPCauseRuleCreationFailure         PFCPCause = 73
+	PFCPCausePFCPEntityInCongestion      PFCPCause = 74
+	PFCPCauseNoResourcesAvailable        PFCPCause = 75
+	PFCPCauseServiceNotSupported         PFCPCause = 76
+	PFCPCauseSystemFailure               PFCPCause = 77
+	PFCPCauseRedirectionRequested        PFCPCause = 78
+	PFCPCauseAllDynamicAddressesOccupied PFCPCause = 79
+)
+
+func (c PFCPCause) String() string {
+	switch c {
+	case PFCPCauseRequestAccepted:
+		return "RequestAccepted"
+	case PFCPCauseRequestRejected:
+		return "RequestRejected"
+	case PFCPCauseSessionContextNotFound:
+		return "SessionContextNotFound"
+	case PFCPCauseMandatoryIEMissing:
+		return "MandatoryIEMissing"
+	case PFCPCauseConditionalIEMissing:
+		return "ConditionalIEMissing"
+	case PFCPCauseInvalidLength:
+		return "InvalidLength"
+	case PFCPCauseMandatoryIEIncorrect:
+		return "MandatoryIEIncorrect"
+	case PFCPCauseInvalidForwardingPolicy:
+		return "InvalidForwardingPolicy"
+	case PFCPCauseInvalidFTEIDAllocation:
+		return "InvalidF-TEIDAllocation"
+	case PFCPCauseNoEstablishedAssociation:
+		return "NoEstablishedAssociation"
+	case PFCPCauseRuleCreationFailure:
+		return "RuleCreationFailure"
+	case PFCPCausePFCPEntityInCongestion:
+		return "PFCPEntityInCongestion"
+	case PFCPCauseNoResourcesAvailable:
+		return "NoResourcesAvailable"
+	case PFCPCauseServiceNotSupported:
+		return "ServiceNotSupported"
+	case PFCPCauseSystemFailure:
+		return "SystemFailure"
+	case PFCPCauseRedirectionRequested:
+		return "RedirectionRequested"
+	case PFCPCauseAllDynamicAddressesOccupied:
+		return "AllDynamicAddressesOccupied"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// PFCPIE is a PFCP information element. The IEs of grouped IEs are decoded
+// into IEs; when serializing, IEs are encoded if Value is nil.
+type PFCPIE struct {
+	Type PFCPIEType
+	// Length is the length of the IE after the type and length fields,
+	// including the enterprise ID.
+	Length uint16
+	// EnterpriseID is only present in vendor specific IEs.
+	EnterpriseID uint16
+	Value        []byte
+	IEs          []PFCPIE
+}
+
+// pfcpMaxDepth bounds the nesting of decoded grouped IEs.
+const pfcpMaxDepth = 8
+
+func decodePFCPIEs(data []byte, depth int, df gopacket.DecodeFeedback) ([]PFCPIE, error) {
+	var ies []PFCPIE
+	for len(data) > 0 {
+		if len(data) < 4 {
+			df.SetTruncated()
+			return nil, errors.New("PFCP IE too short")
+		}
+		ie := PFCPIE{
+			Type:   PFCPIEType(binary.BigEndian.Uint16(data)),
+			Length: binary.BigEndian.Uint16(data[2:]),
+		}
+		end := 4 + int(ie.Length)
+		if len(data) < end {
+			df.SetTruncated()
+			return nil, fmt.Errorf("PFCP IE %v length %d too long", ie.Type, ie.Length)
+		}
+		ie.Value = data[4:end]
+		if ie.Type.VendorSpecific() {
+			if len(ie.Value) < 2 {
+				return nil, fmt.Errorf("PFCP IE %v too short for an enterprise ID", ie.Type)
+			}
+			ie.EnterpriseID = binary.BigEndian.Uint16(ie.Value)
+			ie.Value = ie.Value[2:]
+		} else if ie.Type.Grouped() && depth < pfcpMaxDepth {
+			var err error
+			if ie.IEs, err = decodePFCPIEs(ie.Value, depth+1, df); err != nil {
+				return nil, err
+			}
+		}
+		ies = append(ies, ie)
+		data = data[end:]
+	}
+	return ies, nil
+}
+
+// pfcpIEsLength returns the encoded length of ies.
+func pfcpIEsLength(ies []PFCPIE) int {
+	n := 0
+	for i := range ies {
+		n += 4 + ies[i].valueLength()
+	}
+	return n
+}
+
+func (ie *PFCPIE) valueLength() int {
+	n := len(ie.Value)
+	if ie.Value == nil {
+		n = pfcpIEsLength(ie.IEs)
+	}
+	if ie.Type.VendorSpecific() {
+		n += 2
+	}
+	return n
+}
+
+// encodePFCPIEs writes ies to b, which must be large enough.
+func encodePFCPIEs(b []byte, ies []PFCPIE, fixLengths bool) error {
+	for i := range ies {
+		ie := &ies[i]
+		n := ie.valueLength()
+		if n > 0xffff {
+			return fmt.Errorf("PFCP IE %v too long", ie.Type)
+		}
+		if fixLengths {
+			ie.Length = uint16(n)
+		}
+		binary.BigEndian.PutUint16(b, uint16(ie.Type))
+		binary.BigEndian.PutUint16(b[2:], ie.Length)
+		v := b[4 : 4+n]
+		if ie.Type.VendorSpecific() {
+			binary.BigEndian.PutUint16(v, ie.EnterpriseID)
+			v = v[2:]
+		}
+		if ie.Value != nil {
+			copy(v, ie.Value)
+		} else if err := encodePFCPIEs(v, ie.IEs, fixLengths); err != nil {
+			return err
+		}
+		b = b[4+n:]
+	}
+	return nil
+}
+
+// findPFCPIE returns the first IE of type t in ies.
+func findPFCPIE(ies []PFCPIE, t PFCPIEType) *PFCPIE {
+	for i := range ies {
+		if ies[i].Type == t {
+			return &ies[i]
+		}
+	}
+	return nil
+}
+
+// IE returns the first IE of type t contained in ie, or nil.
+func (ie *PFCPIE) IE(t PFCPIEType) *PFCPIE {
+	return findPFCPIE(ie.IEs, t)
+}
+
+// Cause decodes the value of a Cause IE.
+func (ie *PFCPIE) Cause() (PFCPCause, error) {
+	if len(ie.Value) < 1 {
+		return 0, errors.New("PFCP Cause IE too short")
+	}
+	return PFCPCause(ie.Value[0]), nil
+}
+
+// NodeID decodes the value of a Node ID IE, which is either an IP address
+// or an FQDN.
+func (ie *PFCPIE) NodeID() (net.IP, string, error) {
+	if len(ie.Value) < 1 {
+		return nil, "", errors.New("PFCP Node ID IE too short")
+	}
+	v := ie.Value[1:]
+	switch ie.Value[0] & 0xf {
+	case 0:
+		if len(v) < 4 {
+			return nil, "", errors.New("PFCP Node ID IE too short")
+		}
+		return net.IP(v[:4]), "", nil
+	case 1:
+		if len(v) < 16 {
+			return nil, "", errors.New("PFCP Node ID IE too short")
+		}
+		return net.IP(v[:16]), "", nil
+	case 2:
+		name, err := decodeDNSLabels(v)
+		return nil, name, err
+	}
+	return nil, "", fmt.Errorf("invalid PFCP Node ID type %d", ie.Value[0]&0xf)
+}
+
+// decodeDNSLabels decodes a name encoded as length prefixed labels, without
+// compression, as in FQDN IEs.
+func decodeDNSLabels(data []byte) (string, error) {
+	var name []byte
+	for len(data) > 0 && data[0] != 0 {
+		n := int(data[0])
+		if len(data) < 1+n {
+			return "", errors.New("FQDN label too long")
+		}
+		if len(name) > 0 {
+			name = append(name, '.')
+		}
+		name = append(name, data[1:1+n]...)
+		data = data[1+n:]
+	}
+	return string(name), nil
+}
+
+// RecoveryTimeStamp decodes the value of a Recovery Time Stamp IE.
+func (ie *PFCPIE) RecoveryTimeStamp() (time.Time, error) {
+	if len(ie.Value) < 4 {
+		return time.Time{}, errors.New("PFCP Recovery Time Stamp IE too short")
+	}
+	// The time stamp is in NTP seconds, since 1900.
+	secs := int64(binary.BigEndian.Uint32(ie.Value)) - 2208988800
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// PFCPFSEID is the value of an F-SEID IE: a session endpoint identifier,
+// with the address of the node which allocated it.
+type PFCPFSEID struct {
+	SEID uint64
+	IPv4 net.IP
+	IPv6 net.IP
+}
+
+// FSEID decodes the value of an F-SEID IE.
+func (ie *PFCPIE) FSEID() (PFCPFSEID, error) {
+	var f PFCPFSEID
+	v := ie.Value
+	if len(v) < 9 {
+		return f, errors.New("PFCP F-SEID IE too short")
+	}
+	flags := v[0]
+	f.SEID = binary.BigEndian.Uint64(v[1:9])
+	v = v[9:]
+	if flags&0x2 != 0 {
+		if len(v) < 4 {
+			return f, errors.New("PFCP F-SEID IE too short")
+		}
+		f.IPv4, v = net.IP(v[:4]), v[4:]
+	}
+	if flags&0x1 != 0 {
+		if len(v) < 16 {
+			return f, errors.New("PFCP F-SEID IE too short")
+		}
+		f.IPv6 = net.IP(v[:16])
+	}
+	return f, nil
+}
+
+// IE returns an F-SEID IE with the value f.
+func (f PFCPFSEID) IE() PFCPIE {
+	v := make([]byte, 9, 29)
+	binary.BigEndian.PutUint64(v[1:], f.SEID)
+	if ip4 := f.IPv4.To4(); ip4 != nil {
+		v[0] |= 0x2
+		v = append(v, ip4...)
+	}
+	if f.IPv6 != nil {
+		v[0] |= 0x1
+		v = append(v, f.IPv6.To16()...)
+	}
+	return PFCPIE{Type: PFCPIETypeFSEID, Length: uint16(len(v)), Value: v}
+}
+
+// PFCPFTEID is the value of an F-TEID IE: a GTP-U tunnel endpoint, or a
+// request to the user plane function to choose one.
+type PFCPFTEID struct {
+	TEID uint32
+	IPv4 net.IP
+	IPv6 net.IP
+	// Choose asks the user plane function to allocate the TEID and
+	// addresses, of the families given by IPv4 and IPv6 being non-nil. The
+	// same ChooseID get the same allocation.
+	Choose   bool
+	ChooseID uint8
+	// HasChooseID tells whether ChooseID is present.
+	HasChooseID bool
+}
+
+// FTEID decodes the value of an F-TEID IE.
+func (ie *PFCPIE) FTEID() (PFCPFTEID, error) {
+	var f PFCPFTEID
+	v := ie.Value
+	if len(v) < 1 {
+		return f, errors.New("PFCP F-TEID IE too short")
+	}
+	flags := v[0]
+	v = v[1:]
+	f.Choose = flags&0x4 != 0
+	f.HasChooseID = flags&0x8 != 0
+	if f.Choose {
+		if flags&0x1 != 0 {
+			f.IPv4 = net.IPv4zero
+		}
+		if flags&0x2 != 0 {
+			f.IPv6 = net.IPv6zero
+		}
+		if f.HasChooseID {
+			if len(v) < 1 {
+				return f, errors.New("PFCP F-TEID IE too short")
+			}
+			f.ChooseID = v[0]
+		}
+		return f, nil
+	}
+	if len(v) < 4 {
+		return f, errors.New("PFCP F-TEID IE too short")
+	}
+	f.TEID, v = binary.BigEndian.Uint32(v), v[4:]
+	if flags&0x1 != 0 {
+		if len(v) < 4 {
+			return f, errors.New("PFCP F-TEID IE too short")
+		}
+		f.IPv4, v = net.IP(v[:4]), v[4:]
+	}
+	if flags&0x2 != 0 {
+		if len(v) < 16 {
+			return f, errors.New("PFCP F-TEID IE too short")
+		}
+		f.IPv6 = net.IP(v[:16])
+	}
+	return f, nil
+}
+
+// IE returns an F-TEID IE with the value f.
+func (f PFCPFTEID) IE() PFCPIE {
+	v := make([]byte, 1, 25)
+	if f.IPv4 != nil {
+		v[0] |= 0x1
+	}
+	if f.IPv6 != nil {
+		v[0] |= 0x2
+	}
+	if f.Choose {
+		v[0] |= 0x4
+		if f.HasChooseID {
+			v[0] |= 0x8
+			v = append(v, f.ChooseID)
+		}
+	} else {
+		v = append(v, uint8(f.TEID>>24), uint8(f.TEID>>16), uint8(f.TEID>>8), uint8(f.TEID))
+		if f.IPv4 != nil {
+			v = append(v, f.IPv4.To4()...)
+		}
+		if f.IPv6 != nil {
+			v = append(v, f.IPv6.To16()...)
+		}
+	}
+	return PFCPIE{Type: PFCPIETypeFTEID, Length: uint16(len(v)), Value: v}
+}
+
+// PFCP is a Packet Forwarding Control Protocol message (3GPP TS 29.244),
+// exchanged between control plane and user plane functions on the Sx and
+// N4 interfaces.
+//
+// A message with FollowOn set is followed by another message in the same
+// datagram, which is decoded as a further PFCP layer.
+type PFCP struct {
+	BaseLayer
+	Version  uint8
+	FollowOn bool
+	// MessagePriority is only present if MessagePriorityPresent.
+	MessagePriorityPresent bool
+	MessagePriority        uint8
+	// SEID is only present in session related messages, if SEIDPresent.
+	SEIDPresent bool
+	SEID        uint64
+	MessageType PFCPMessageType
+	// MessageLength is the length of the message after its first 4 bytes.
+	MessageLength  uint16
+	SequenceNumber uint32
+	IEs            []PFCPIE
+}
+
+// LayerType returns LayerTypePFCP.
+func (p *PFCP) LayerType() gopacket.LayerType { return LayerTypePFCP }
+
+// IE returns the first top-level IE of type t, or nil.
+func (p *PFCP) IE(t PFCPIEType) *PFCPIE {
+	return findPFCPIE(p.IEs, t)
+}
+
+func (p *PFCP) headerLength() int {
+	if p.SEIDPresent {
+		return 16
+	}
+	return 8
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (p *PFCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("PFCP header too short")
+	}
+	p.Version = data[0] >> 5
+	if p.Version != 1 {
+		return fmt.Errorf("unsupported PFCP version %d", p.Version)
+	}
+	p.FollowOn = data[0]&0x4 != 0
+	p.MessagePriorityPresent = data[0]&0x2 != 0
+	p.SEIDPresent = data[0]&0x1 != 0
+	p.MessageType = PFCPMessageType(data[1])
+	p.MessageLength = binary.BigEndian.Uint16(data[2:4])
+	hlen := p.headerLength()
+	end := 4 + int(p.MessageLength)
+	if end < hlen {
+		return fmt.Errorf("PFCP message length %d too short", p.MessageLength)
+	}
+	if len(data) < end {
+		df.SetTruncated()
+		return fmt.Errorf("PFCP message length %d too long", p.MessageLength)
+	}
+	n := 4
+	p.SEID = 0
+	if p.SEIDPresent {
+		p.SEID = binary.BigEndian.Uint64(data[4:12])
+		n = 12
+	}
+	p.SequenceNumber = uint32(data[n])<<16 | uint32(binary.BigEndian.Uint16(data[n+1:]))
+	p.MessagePriority = 0
+	if p.MessagePriorityPresent {
+		p.MessagePriority = data[n+3] >> 4
+	}
+	var err error
+	if p.IEs, err = decodePFCPIEs(data[hlen:end], 0, df); err != nil {
+		return err
+	}
+	p.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (p *PFCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	hlen := p.headerLength()
+	length := hlen + pfcpIEsLength(p.IEs)
+	if length-4 > 0xffff {
+		return errors.New("PFCP message too long")
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		p.MessageLength = uint16(length - 4)
+	}
+	bytes[0] = p.Version << 5
+	if p.FollowOn {
+		bytes[0] |= 0x4
+	}
+	if p.MessagePriorityPresent {
+		bytes[0] |= 0x2
+	}
+	if p.SEIDPresent {
+		bytes[0] |= 0x1
+	}
+	bytes[1] = uint8(p.MessageType)
+	binary.BigEndian.PutUint16(bytes[2:], p.MessageLength)
+	n := 4
+	if p.SEIDPresent {
+		binary.BigEndian.PutUint64(bytes[4:], p.SEID)
+		n = 12
+	}
+	bytes[n] = uint8(p.SequenceNumber >> 16)
+	binary.BigEndian.PutUint16(bytes[n+1:], uint16(p.SequenceNumber))
+	bytes[n+3] = 0
+	if p.MessagePriorityPresent {
+		bytes[n+3] = p.MessagePriority << 4
+	}
+	return encodePFCPIEs(bytes[hlen:], p.IEs, opts.FixLengths)
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (p *PFCP) CanDecode() gopacket.LayerClass {
+	return LayerTypePFCP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (p *PFCP) NextLayerType() gopacket.LayerType {
+	switch {
+	case len(p.Payload) == 0:
+		return gopacket.LayerTypeZero
+	case p.FollowOn:
+		return LayerTypePFCP
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodePFCP(data []byte, p gopacket.PacketBuilder) error {
+	pfcp := &PFCP{}
+	return decodingLayerDecoder(pfcp, data, p)
+}
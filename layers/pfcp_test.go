@@ -0,0 +1,154 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// A heartbeat request followed by a heartbeat response in the same
+// datagram.
+var testPFCPHeartbeats = []byte{
+	0x24, 0x01, 0x00, 0x0c, 0x00, 0x00, 0x01, 0x00, // request, follow on
+	0x00, 0x60, 0x00, 0x04, 0xe3, 0x98, 0xe4, 0x80, // recovery time stamp
+	0x20, 0x02, 0x00, 0x0c, 0x00, 0x00, 0x01, 0x00, // response
+	0x00, 0x60, 0x00, 0x04, 0xe3, 0x98, 0xe4, 0x80,
+}
+
+func TestPFCPHeartbeat(t *testing.T) {
+	p := gopacket.NewPacket(testPFCPHeartbeats, LayerTypePFCP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePFCP, LayerTypePFCP}, t)
+
+	req := p.Layers()[0].(*PFCP)
+	if req.MessageType != PFCPMessageTypeHeartbeatRequest || !req.FollowOn || req.SEIDPresent || req.SequenceNumber != 1 {
+		t.Errorf("got request %+v", req)
+	}
+	ts, err := req.IE(PFCPIETypeRecoveryTimeStamp).RecoveryTimeStamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC); !ts.Equal(want) {
+		t.Errorf("got recovery time stamp %v, want %v", ts, want)
+	}
+	if resp := p.Layers()[1].(*PFCP); resp.MessageType != PFCPMessageTypeHeartbeatResponse || resp.FollowOn {
+		t.Errorf("got response %+v", resp)
+	}
+}
+
+// A session establishment request with a node ID, an F-SEID and a PDR
+// asking the user plane function to choose an IPv4 F-TEID.
+var testPFCPSessionEstablishment = []byte{
+	0x21, 0x32, 0x00, 0x47, // flags, type, length
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // SEID
+	0x00, 0x00, 0x02, 0x00, // sequence number
+	0x00, 0x3c, 0x00, 0x05, 0x00, 0x0a, 0x00, 0x00, 0x01, // node ID
+	0x00, 0x39, 0x00, 0x0d, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x01, // F-SEID
+	0x00, 0x01, 0x00, 0x1d, // create PDR
+	0x00, 0x38, 0x00, 0x02, 0x00, 0x01, // PDR ID
+	0x00, 0x1d, 0x00, 0x04, 0x00, 0x00, 0x00, 0x64, // precedence
+	0x00, 0x02, 0x00, 0x0b, // PDI
+	0x00, 0x14, 0x00, 0x01, 0x00, // source interface
+	0x00, 0x15, 0x00, 0x02, 0x0d, 0x05, // F-TEID
+}
+
+func TestPFCPSessionEstablishmentDecode(t *testing.T) {
+	p := gopacket.NewPacket(testPFCPSessionEstablishment, LayerTypePFCP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePFCP}, t)
+	pfcp := p.Layer(LayerTypePFCP).(*PFCP)
+	if !pfcp.SEIDPresent || pfcp.SEID != 0 || pfcp.MessageType != PFCPMessageTypeSessionEstablishmentRequest || pfcp.SequenceNumber != 2 {
+		t.Errorf("got header %+v", pfcp)
+	}
+
+	ip, name, err := pfcp.IE(PFCPIETypeNodeID).NodeID()
+	if err != nil || !ip.Equal(net.IPv4(10, 0, 0, 1)) || name != "" {
+		t.Errorf("got node ID %v %q %v", ip, name, err)
+	}
+	fseid, err := pfcp.IE(PFCPIETypeFSEID).FSEID()
+	if err != nil || fseid.SEID != 1 || !fseid.IPv4.Equal(net.IPv4(10, 0, 0, 1)) || fseid.IPv6 != nil {
+		t.Errorf("got F-SEID %+v %v", fseid, err)
+	}
+	pdi := pfcp.IE(PFCPIETypeCreatePDR).IE(PFCPIETypePDI)
+	if pdi == nil || len(pdi.IEs) != 2 {
+		t.Fatalf("got PDI %+v", pdi)
+	}
+	fteid, err := pdi.IE(PFCPIETypeFTEID).FTEID()
+	if err != nil || !fteid.Choose || !fteid.HasChooseID || fteid.ChooseID != 5 || fteid.IPv4 == nil || fteid.IPv6 != nil {
+		t.Errorf("got F-TEID %+v %v", fteid, err)
+	}
+}
+
+func TestPFCPSessionEstablishmentEncode(t *testing.T) {
+	pfcp := &PFCP{
+		Version:        1,
+		SEIDPresent:    true,
+		MessageType:    PFCPMessageTypeSessionEstablishmentRequest,
+		SequenceNumber: 2,
+		IEs: []PFCPIE{
+			{Type: PFCPIETypeNodeID, Value: []byte{0, 10, 0, 0, 1}},
+			PFCPFSEID{SEID: 1, IPv4: net.IPv4(10, 0, 0, 1)}.IE(),
+			{Type: PFCPIETypeCreatePDR, IEs: []PFCPIE{
+				{Type: PFCPIETypePDRID, Value: []byte{0, 1}},
+				{Type: PFCPIETypePrecedence, Value: []byte{0, 0, 0, 100}},
+				{Type: PFCPIETypePDI, IEs: []PFCPIE{
+					{Type: PFCPIETypeSourceInterface, Value: []byte{0}},
+					PFCPFTEID{Choose: true, IPv4: net.IPv4zero, HasChooseID: true, ChooseID: 5}.IE(),
+				}},
+			}},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, pfcp); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.Bytes(); !bytes.Equal(got, testPFCPSessionEstablishment) {
+		t.Errorf("got\n%x\nwant\n%x", got, testPFCPSessionEstablishment)
+	}
+}
+
+func TestPFCPVendorSpecificIE(t *testing.T) {
+	pfcp := &PFCP{
+		Version:     1,
+		MessageType: PFCPMessageTypeAssociationSetupRequest,
+		IEs:         []PFCPIE{{Type: 32770, EnterpriseID: 10415, Value: []byte{1, 2, 3}}},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, pfcp); err != nil {
+		t.Fatal(err)
+	}
+	var got PFCP
+	if err := got.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	ie := got.IEs[0]
+	if ie.Type != 32770 || ie.Length != 5 || ie.EnterpriseID != 10415 || !bytes.Equal(ie.Value, []byte{1, 2, 3}) {
+		t.Errorf("got IE %+v", ie)
+	}
+}
+
+func TestPFCPTruncated(t *testing.T) {
+	var pfcp PFCP
+	data := testPFCPSessionEstablishment[:len(testPFCPSessionEstablishment)-1]
+	if err := pfcp.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded a truncated message")
+	}
+	data = append([]byte(nil), testPFCPSessionEstablishment...)
+	data[3]--
+	if err := pfcp.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded a message with a truncated IE")
+	}
+}
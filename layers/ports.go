@@ -28,12 +28,18 @@ type SCTPPort uint16
 // UDPLitePort is a port in a UDPLite layer.
 type UDPLitePort uint16
 
+// DCCPPort is a port in a DCCP layer.
+type DCCPPort uint16
+
 // RUDPPortNames contains the string names for all RUDP ports.
 var RUDPPortNames = map[RUDPPort]string{}
 
 // UDPLitePortNames contains the string names for all UDPLite ports.
 var UDPLitePortNames = map[UDPLitePort]string{}
 
+// DCCPPortNames contains the string names for all DCCP ports.
+var DCCPPortNames = map[DCCPPort]string{}
+
 // {TCP,UDP,SCTP}PortNames can be found in iana_ports.go
 
 // String returns the port as "number(name)" if there's a well-known port name,
@@ -62,6 +68,8 @@ func (a TCPPort) LayerType() gopacket.LayerType {
 		return LayerTypeTLS
 	case 502: // modbustcp
 		return LayerTypeModbusTCP
+	case 554: // rtsp
+		return LayerTypeRTSP
 	case 636: // ldaps
 		return LayerTypeTLS
 	case 989: // ftps-data
@@ -78,6 +86,8 @@ func (a TCPPort) LayerType() gopacket.LayerType {
 		return LayerTypeTLS
 	case 5061: // ips
 		return LayerTypeTLS
+	case 13400: // doip-data
+		return LayerTypeDoIP
 	}
 	return gopacket.LayerTypePayload
 }
@@ -119,6 +129,8 @@ func (a UDPPort) LayerType() gopacket.LayerType {
 		return LayerTypeDHCPv4
 	case 68:
 		return LayerTypeDHCPv4
+	case 69:
+		return LayerTypeTFTP
 	case 123:
 		return LayerTypeNTP
 	case 546:
@@ -127,10 +139,14 @@ func (a UDPPort) LayerType() gopacket.LayerType {
 		return LayerTypeDHCPv6
 	case 623:
 		return LayerTypeRMCP
+	case 1701:
+		return LayerTypeL2TPv3
 	case 1812:
 		return LayerTypeRADIUS
 	case 2152:
 		return LayerTypeGTPv1U
+	case 3544:
+		return LayerTypeTeredo
 	case 3784:
 		return LayerTypeBFD
 	case 4789:
@@ -141,6 +157,10 @@ func (a UDPPort) LayerType() gopacket.LayerType {
 		return LayerTypeGeneve
 	case 6343:
 		return LayerTypeSFlow
+	case 8805:
+		return LayerTypePFCP
+	case 13400:
+		return LayerTypeDoIP
 	}
 	return gopacket.LayerTypePayload
 }
@@ -185,3 +205,13 @@ func (a UDPLitePort) String() string {
 	}
 	return strconv.Itoa(int(a))
 }
+
+// String returns the port as "number(name)" if there's a well-known port name,
+// or just "number" if there isn't.  Well-known names are stored in
+// DCCPPortNames.
+func (a DCCPPort) String() string {
+	if name, ok := DCCPPortNames[a]; ok {
+		return fmt.Sprintf("%d(%s)", a, name)
+	}
+	return strconv.Itoa(int(a))
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// CHAPCode is the code of a CHAP packet.
+type CHAPCode uint8
+
+const (
+	CHAPCodeChallenge CHAPCode = 1
+	CHAPCodeResponse  CHAPCode = 2
+	CHAPCodeSuccess   CHAPCode = 3
+	CHAPCodeFailure   CHAPCode = 4
+)
+
+func (c CHAPCode) String() string {
+	switch c {
+	case CHAPCodeChallenge:
+		return "Challenge"
+	case CHAPCodeResponse:
+		return "Response"
+	case CHAPCodeSuccess:
+		return "Success"
+	case CHAPCodeFailure:
+		return "Failure"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// pppAuthHeader decodes the header CHAP and PAP packets share, returning
+// the data following it.
+func pppAuthHeader(proto string, data []byte, df gopacket.DecodeFeedback) (uint8, uint8, uint16, []byte, error) {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return 0, 0, 0, nil, fmt.Errorf("%s length %d too short, 4 required", proto, len(data))
+	}
+	length := binary.BigEndian.Uint16(data[2:4])
+	if length < 4 {
+		return 0, 0, 0, nil, fmt.Errorf("%s length field %d too short", proto, length)
+	}
+	if int(length) > len(data) {
+		df.SetTruncated()
+		return 0, 0, 0, nil, fmt.Errorf("%s length field %d exceeds %d bytes", proto, length, len(data))
+	}
+	return data[0], data[1], length, data[4:length], nil
+}
+
+// CHAP is a packet of the PPP Challenge Handshake Authentication Protocol
+// (RFC 1994).
+type CHAP struct {
+	BaseLayer
+	Code       CHAPCode
+	Identifier uint8
+	Length     uint16
+	// Value and Name are the challenge or the response of Challenge and
+	// Response packets and the name of their sender.
+	Value []byte
+	Name  string
+	// Message is that of Success and Failure packets.
+	Message string
+}
+
+// LayerType returns LayerTypeCHAP.
+func (c *CHAP) LayerType() gopacket.LayerType { return LayerTypeCHAP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CHAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	code, id, length, body, err := pppAuthHeader("CHAP", data, df)
+	if err != nil {
+		return err
+	}
+	c.Code, c.Identifier, c.Length = CHAPCode(code), id, length
+	c.Value, c.Name, c.Message = nil, "", ""
+	switch c.Code {
+	case CHAPCodeChallenge, CHAPCodeResponse:
+		if len(body) < 1 || int(body[0]) > len(body)-1 {
+			return fmt.Errorf("CHAP %v value exceeds %d bytes", c.Code, len(body))
+		}
+		c.Value = body[1 : 1+body[0]]
+		c.Name = string(body[1+body[0]:])
+	default:
+		c.Message = string(body)
+	}
+	c.BaseLayer = BaseLayer{Contents: data[:length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *CHAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 4 + len(c.Message)
+	challenge := c.Code == CHAPCodeChallenge || c.Code == CHAPCodeResponse
+	if challenge {
+		if len(c.Value) > 255 {
+			return fmt.Errorf("CHAP value of %d bytes too long", len(c.Value))
+		}
+		length = 5 + len(c.Value) + len(c.Name)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		c.Length = uint16(length)
+	}
+	bytes[0] = uint8(c.Code)
+	bytes[1] = c.Identifier
+	binary.BigEndian.PutUint16(bytes[2:4], c.Length)
+	if challenge {
+		bytes[4] = uint8(len(c.Value))
+		copy(bytes[5:], c.Value)
+		copy(bytes[5+len(c.Value):], c.Name)
+	} else {
+		copy(bytes[4:], c.Message)
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CHAP) CanDecode() gopacket.LayerClass {
+	return LayerTypeCHAP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *CHAP) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+func decodeCHAP(data []byte, p gopacket.PacketBuilder) error {
+	c := &CHAP{}
+	return decodingLayerDecoder(c, data, p)
+}
+
+// PAPCode is the code of a PAP packet.
+type PAPCode uint8
+
+const (
+	PAPCodeAuthenticateRequest PAPCode = 1
+	PAPCodeAuthenticateAck     PAPCode = 2
+	PAPCodeAuthenticateNak     PAPCode = 3
+)
+
+func (c PAPCode) String() string {
+	switch c {
+	case PAPCodeAuthenticateRequest:
+		return "Authenticate-Request"
+	case PAPCodeAuthenticateAck:
+		return "Authenticate-Ack"
+	case PAPCodeAuthenticateNak:
+		return "Authenticate-Nak"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// PAP is a packet of the PPP Password Authentication Protocol (RFC 1334),
+// whose Authenticate-Request packets carry the password in the clear.
+type PAP struct {
+	BaseLayer
+	Code       PAPCode
+	Identifier uint8
+	Length     uint16
+	// PeerID and Password are those of Authenticate-Request packets.
+	PeerID   string
+	Password string
+	// Message is that of Authenticate-Ack and Authenticate-Nak packets.
+	Message string
+}
+
+// LayerType returns LayerTypePAP.
+func (a *PAP) LayerType() gopacket.LayerType { return LayerTypePAP }
+
+// papString decodes a string prefixed by its length.
+func papString(data []byte) (string, []byte, error) {
+	if len(data) < 1 || int(data[0]) > len(data)-1 {
+		return "", nil, fmt.Errorf("PAP string exceeds %d bytes", len(data))
+	}
+	return string(data[1 : 1+data[0]]), data[1+data[0]:], nil
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *PAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	code, id, length, body, err := pppAuthHeader("PAP", data, df)
+	if err != nil {
+		return err
+	}
+	a.Code, a.Identifier, a.Length = PAPCode(code), id, length
+	a.PeerID, a.Password, a.Message = "", "", ""
+	if a.Code == PAPCodeAuthenticateRequest {
+		if a.PeerID, body, err = papString(body); err != nil {
+			return err
+		}
+		if a.Password, _, err = papString(body); err != nil {
+			return err
+		}
+	} else if len(body) > 0 {
+		if a.Message, _, err = papString(body); err != nil {
+			return err
+		}
+	}
+	a.BaseLayer = BaseLayer{Contents: data[:length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *PAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	strs := []string{a.Message}
+	if a.Code == PAPCodeAuthenticateRequest {
+		strs = []string{a.PeerID, a.Password}
+	}
+	length := 4
+	for _, s := range strs {
+		if len(s) > 255 {
+			return fmt.Errorf("PAP string of %d bytes too long", len(s))
+		}
+		length += 1 + len(s)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		a.Length = uint16(length)
+	}
+	bytes[0] = uint8(a.Code)
+	bytes[1] = a.Identifier
+	binary.BigEndian.PutUint16(bytes[2:4], a.Length)
+	o := bytes[4:]
+	for _, s := range strs {
+		o[0] = uint8(len(s))
+		copy(o[1:], s)
+		o = o[1+len(s):]
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *PAP) CanDecode() gopacket.LayerClass {
+	return LayerTypePAP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (a *PAP) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+func decodePAP(data []byte, p gopacket.PacketBuilder) error {
+	a := &PAP{}
+	return decodingLayerDecoder(a, data, p)
+}
@@ -0,0 +1,396 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// PPPControlCode is the code of a packet of the Link Control Protocol or
+// of a network control protocol such as IPCP, which use the codes of LCP up
+// to PPPControlCodeCodeReject.
+type PPPControlCode uint8
+
+const (
+	PPPControlCodeConfigureRequest PPPControlCode = 1
+	PPPControlCodeConfigureAck     PPPControlCode = 2
+	PPPControlCodeConfigureNak     PPPControlCode = 3
+	PPPControlCodeConfigureReject  PPPControlCode = 4
+	PPPControlCodeTerminateRequest PPPControlCode = 5
+	PPPControlCodeTerminateAck     PPPControlCode = 6
+	PPPControlCodeCodeReject       PPPControlCode = 7
+	PPPControlCodeProtocolReject   PPPControlCode = 8
+	PPPControlCodeEchoRequest      PPPControlCode = 9
+	PPPControlCodeEchoReply        PPPControlCode = 10
+	PPPControlCodeDiscardRequest   PPPControlCode = 11
+	PPPControlCodeIdentification   PPPControlCode = 12
+	PPPControlCodeTimeRemaining    PPPControlCode = 13
+)
+
+func (c PPPControlCode) String() string {
+	switch c {
+	case PPPControlCodeConfigureRequest:
+		return "Configure-Request"
+	case PPPControlCodeConfigureAck:
+		return "Configure-Ack"
+	case PPPControlCodeConfigureNak:
+		return "Configure-Nak"
+	case PPPControlCodeConfigureReject:
+		return "Configure-Reject"
+	case PPPControlCodeTerminateRequest:
+		return "Terminate-Request"
+	case PPPControlCodeTerminateAck:
+		return "Terminate-Ack"
+	case PPPControlCodeCodeReject:
+		return "Code-Reject"
+	case PPPControlCodeProtocolReject:
+		return "Protocol-Reject"
+	case PPPControlCodeEchoRequest:
+		return "Echo-Request"
+	case PPPControlCodeEchoReply:
+		return "Echo-Reply"
+	case PPPControlCodeDiscardRequest:
+		return "Discard-Request"
+	case PPPControlCodeIdentification:
+		return "Identification"
+	case PPPControlCodeTimeRemaining:
+		return "Time-Remaining"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// Options of LCP Configure packets.
+const (
+	LCPOptionMRU          uint8 = 1
+	LCPOptionACCM         uint8 = 2
+	LCPOptionAuthProtocol uint8 = 3
+	LCPOptionMagicNumber  uint8 = 5
+	LCPOptionPFC          uint8 = 7
+	LCPOptionACFC         uint8 = 8
+)
+
+// Options of IPCP Configure packets.
+const (
+	IPCPOptionCompression  uint8 = 2
+	IPCPOptionAddress      uint8 = 3
+	IPCPOptionPrimaryDNS   uint8 = 129
+	IPCPOptionSecondaryDNS uint8 = 131
+)
+
+// Options of IPv6CP Configure packets.
+const (
+	IPv6CPOptionInterfaceIdentifier uint8 = 1
+)
+
+// PPPControlOption is an option of a Configure packet, of which Data is
+// the value.
+type PPPControlOption struct {
+	Type uint8
+	Data []byte
+}
+
+// PPPControl holds the fields the packets of LCP and of the network control
+// protocols have in common. The Options of Configure packets are decoded,
+// and the data of the others is their payload, such as the packet a
+// Code-Reject rejects.
+type PPPControl struct {
+	BaseLayer
+	Code       PPPControlCode
+	Identifier uint8
+	Length     uint16
+	Options    []PPPControlOption
+}
+
+func (c *PPPControl) configure() bool {
+	return c.Code >= PPPControlCodeConfigureRequest && c.Code <= PPPControlCodeConfigureReject
+}
+
+// decodeFromBytes decodes the header of the packet, returning the data
+// following it.
+func (c *PPPControl) decodeFromBytes(data []byte, df gopacket.DecodeFeedback) ([]byte, error) {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return nil, fmt.Errorf("PPP control packet length %d too short, 4 required", len(data))
+	}
+	c.Code = PPPControlCode(data[0])
+	c.Identifier = data[1]
+	c.Length = binary.BigEndian.Uint16(data[2:4])
+	if c.Length < 4 {
+		return nil, fmt.Errorf("PPP control packet length field %d too short", c.Length)
+	}
+	if int(c.Length) > len(data) {
+		df.SetTruncated()
+		return nil, fmt.Errorf("PPP control packet length field %d exceeds %d bytes", c.Length, len(data))
+	}
+	body := data[4:c.Length]
+	c.Options = c.Options[:0]
+	if !c.configure() {
+		c.BaseLayer = BaseLayer{Contents: data[:4], Payload: body}
+		return body, nil
+	}
+	for o := body; len(o) > 0; {
+		if len(o) < 2 || o[1] < 2 || int(o[1]) > len(o) {
+			return nil, fmt.Errorf("PPP control option of %d bytes malformed", len(o))
+		}
+		c.Options = append(c.Options, PPPControlOption{Type: o[0], Data: o[2:o[1]]})
+		o = o[o[1]:]
+	}
+	c.BaseLayer = BaseLayer{Contents: data[:c.Length]}
+	return nil, nil
+}
+
+// serializeTo writes the header of the packet, and the options of Configure
+// packets.
+func (c *PPPControl) serializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 4
+	if c.configure() {
+		for _, o := range c.Options {
+			if len(o.Data) > 253 {
+				return fmt.Errorf("PPP control option of %d bytes too long", len(o.Data))
+			}
+			length += 2 + len(o.Data)
+		}
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		c.Length = uint16(len(b.Bytes()))
+	}
+	bytes[0] = uint8(c.Code)
+	bytes[1] = c.Identifier
+	binary.BigEndian.PutUint16(bytes[2:4], c.Length)
+	if c.configure() {
+		o := bytes[4:]
+		for _, opt := range c.Options {
+			o[0] = opt.Type
+			o[1] = uint8(2 + len(opt.Data))
+			copy(o[2:], opt.Data)
+			o = o[2+len(opt.Data):]
+		}
+	}
+	return nil
+}
+
+// Option returns the data of the first option of the type, or nil if
+// there is none.
+func (c *PPPControl) Option(t uint8) []byte {
+	for _, o := range c.Options {
+		if o.Type == t {
+			return o.Data
+		}
+	}
+	return nil
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *PPPControl) NextLayerType() gopacket.LayerType {
+	if len(c.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+// LCP is a packet of the PPP Link Control Protocol (RFC 1661).
+type LCP struct {
+	PPPControl
+	// MagicNumber is that of Echo-Request, Echo-Reply, Discard-Request,
+	// Identification and Time-Remaining packets, whose other data is the
+	// payload.
+	MagicNumber uint32
+	// RejectedProtocol is the protocol a Protocol-Reject rejects. The
+	// payload is the rejected information.
+	RejectedProtocol PPPType
+}
+
+// LayerType returns LayerTypeLCP.
+func (l *LCP) LayerType() gopacket.LayerType { return LayerTypeLCP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (l *LCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	body, err := l.decodeFromBytes(data, df)
+	if err != nil {
+		return err
+	}
+	l.MagicNumber, l.RejectedProtocol = 0, 0
+	if n := l.fieldsLength(); n > 0 {
+		if len(body) < n {
+			return fmt.Errorf("LCP %v length %d too short, %d required", l.Code, len(body)+4, n+4)
+		}
+		if l.Code == PPPControlCodeProtocolReject {
+			l.RejectedProtocol = PPPType(binary.BigEndian.Uint16(body))
+		} else {
+			l.MagicNumber = binary.BigEndian.Uint32(body)
+		}
+		l.BaseLayer = BaseLayer{Contents: data[:4+n], Payload: body[n:]}
+	}
+	return nil
+}
+
+// fieldsLength returns the length of the fields LCP decodes following the
+// header of packets other than Configure ones.
+func (l *LCP) fieldsLength() int {
+	switch l.Code {
+	case PPPControlCodeProtocolReject:
+		return 2
+	case PPPControlCodeEchoRequest, PPPControlCodeEchoReply, PPPControlCodeDiscardRequest,
+		PPPControlCodeIdentification, PPPControlCodeTimeRemaining:
+		return 4
+	}
+	return 0
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (l *LCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if n := l.fieldsLength(); n > 0 {
+		bytes, err := b.PrependBytes(n)
+		if err != nil {
+			return err
+		}
+		if l.Code == PPPControlCodeProtocolReject {
+			binary.BigEndian.PutUint16(bytes, uint16(l.RejectedProtocol))
+		} else {
+			binary.BigEndian.PutUint32(bytes, l.MagicNumber)
+		}
+	}
+	return l.serializeTo(b, opts)
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (l *LCP) CanDecode() gopacket.LayerClass {
+	return LayerTypeLCP
+}
+
+// MRU returns the Maximum-Receive-Unit option.
+func (l *LCP) MRU() (uint16, bool) {
+	if o := l.Option(LCPOptionMRU); len(o) >= 2 {
+		return binary.BigEndian.Uint16(o), true
+	}
+	return 0, false
+}
+
+// AuthProtocol returns the protocol of the Authentication-Protocol option,
+// such as PPPTypeCHAP, and the data following it, such as the algorithm of
+// CHAP.
+func (l *LCP) AuthProtocol() (PPPType, []byte, bool) {
+	if o := l.Option(LCPOptionAuthProtocol); len(o) >= 2 {
+		return PPPType(binary.BigEndian.Uint16(o)), o[2:], true
+	}
+	return 0, nil, false
+}
+
+// MagicNumberOption returns the Magic-Number option.
+func (l *LCP) MagicNumberOption() (uint32, bool) {
+	if o := l.Option(LCPOptionMagicNumber); len(o) >= 4 {
+		return binary.BigEndian.Uint32(o), true
+	}
+	return 0, false
+}
+
+func decodeLCP(data []byte, p gopacket.PacketBuilder) error {
+	l := &LCP{}
+	return decodingLayerDecoder(l, data, p)
+}
+
+// IPCP is a packet of the PPP Internet Protocol Control Protocol (RFC 1332),
+// negotiating the addresses of the link, and its DNS servers (RFC 1877).
+type IPCP struct {
+	PPPControl
+}
+
+// LayerType returns LayerTypeIPCP.
+func (c *IPCP) LayerType() gopacket.LayerType { return LayerTypeIPCP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *IPCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	_, err := c.decodeFromBytes(data, df)
+	return err
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *IPCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return c.serializeTo(b, opts)
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *IPCP) CanDecode() gopacket.LayerClass {
+	return LayerTypeIPCP
+}
+
+func (c *IPCP) address(t uint8) net.IP {
+	if o := c.Option(t); len(o) >= 4 {
+		return net.IP(o[:4])
+	}
+	return nil
+}
+
+// Address returns the IP-Address option, or nil if there is none.
+func (c *IPCP) Address() net.IP { return c.address(IPCPOptionAddress) }
+
+// PrimaryDNS returns the Primary-DNS-Server-Address option, or nil if there
+// is none.
+func (c *IPCP) PrimaryDNS() net.IP { return c.address(IPCPOptionPrimaryDNS) }
+
+// SecondaryDNS returns the Secondary-DNS-Server-Address option, or nil if
+// there is none.
+func (c *IPCP) SecondaryDNS() net.IP { return c.address(IPCPOptionSecondaryDNS) }
+
+func decodeIPCP(data []byte, p gopacket.PacketBuilder) error {
+	c := &IPCP{}
+	return decodingLayerDecoder(c, data, p)
+}
+
+// IPv6CP is a packet of the PPP IPv6 Control Protocol (RFC 5072),
+// negotiating the interface identifiers of the link.
+type IPv6CP struct {
+	PPPControl
+}
+
+// LayerType returns LayerTypeIPv6CP.
+func (c *IPv6CP) LayerType() gopacket.LayerType { return LayerTypeIPv6CP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *IPv6CP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	_, err := c.decodeFromBytes(data, df)
+	return err
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *IPv6CP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return c.serializeTo(b, opts)
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *IPv6CP) CanDecode() gopacket.LayerClass {
+	return LayerTypeIPv6CP
+}
+
+// InterfaceIdentifier returns the 8 bytes of the Interface-Identifier
+// option, or nil if there is none.
+func (c *IPv6CP) InterfaceIdentifier() []byte {
+	if o := c.Option(IPv6CPOptionInterfaceIdentifier); len(o) >= 8 {
+		return o[:8]
+	}
+	return nil
+}
+
+func decodeIPv6CP(data []byte, p gopacket.PacketBuilder) error {
+	c := &IPv6CP{}
+	return decodingLayerDecoder(c, data, p)
+}
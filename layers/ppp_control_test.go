@@ -0,0 +1,194 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// An LCP Configure-Request for an MRU of 1492, CHAP with MD5 and a magic
+// number.
+var testPacketLCPConfigureRequest = []byte{
+	0xff, 0x03, 0xc0, 0x21,
+	0x01, 0x01, 0x00, 0x13,
+	0x01, 0x04, 0x05, 0xd4,
+	0x03, 0x05, 0xc2, 0x23, 0x05,
+	0x05, 0x06, 0x12, 0x34, 0x56, 0x78,
+}
+
+func TestLCP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketLCPConfigureRequest, LayerTypePPP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeLCP}, t)
+	l := p.Layer(LayerTypeLCP).(*LCP)
+	if l.Code != PPPControlCodeConfigureRequest || l.Identifier != 1 || l.Length != 19 || len(l.Options) != 3 {
+		t.Errorf("got %+v", l)
+	}
+	if mru, ok := l.MRU(); !ok || mru != 1492 {
+		t.Errorf("MRU %d", mru)
+	}
+	if proto, data, ok := l.AuthProtocol(); !ok || proto != PPPTypeCHAP || !bytes.Equal(data, []byte{0x05}) {
+		t.Errorf("authentication protocol %v %x", proto, data)
+	}
+	if magic, ok := l.MagicNumberOption(); !ok || magic != 0x12345678 {
+		t.Errorf("magic number %#x", magic)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&PPP{PPPType: PPPTypeLCP, HasPPTPHeader: true}, l); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketLCPConfigureRequest) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testPacketLCPConfigureRequest)
+	}
+
+	p = gopacket.NewPacket(testPacketLCPConfigureRequest[:10], LayerTypePPP, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated packet")
+	}
+
+	// An Echo-Request with some data.
+	echo := []byte{0x09, 0x07, 0x00, 0x0c, 0x12, 0x34, 0x56, 0x78, 0xde, 0xad, 0xbe, 0xef}
+	if err := l.DecodeFromBytes(echo, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if l.Code != PPPControlCodeEchoRequest || l.MagicNumber != 0x12345678 || len(l.Options) != 0 ||
+		!bytes.Equal(l.Payload, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("got %+v", l)
+	}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, l, gopacket.Payload(l.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), echo) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), echo)
+	}
+
+	// A Protocol-Reject of CDP.
+	if err := l.DecodeFromBytes([]byte{0x08, 0x02, 0x00, 0x08, 0x82, 0x07, 0x01, 0x02}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if l.RejectedProtocol != 0x8207 || len(l.Payload) != 2 {
+		t.Errorf("got %+v", l)
+	}
+	if err := l.DecodeFromBytes([]byte{0x01, 0x01, 0x00, 0x07, 0x01, 0x04, 0x05}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding malformed option")
+	}
+}
+
+func TestIPCP(t *testing.T) {
+	data := []byte{
+		0x80, 0x21,
+		0x03, 0x02, 0x00, 0x16,
+		0x03, 0x06, 0x0a, 0x00, 0x00, 0x02,
+		0x81, 0x06, 0x08, 0x08, 0x08, 0x08,
+		0x83, 0x06, 0x08, 0x08, 0x04, 0x04,
+	}
+	p := gopacket.NewPacket(data, LayerTypePPP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeIPCP}, t)
+	c := p.Layer(LayerTypeIPCP).(*IPCP)
+	if c.Code != PPPControlCodeConfigureNak || c.Address().String() != "10.0.0.2" ||
+		c.PrimaryDNS().String() != "8.8.8.8" || c.SecondaryDNS().String() != "8.8.4.4" {
+		t.Errorf("got %+v", c)
+	}
+
+	data = []byte{
+		0x80, 0x57,
+		0x01, 0x01, 0x00, 0x0e,
+		0x01, 0x0a, 0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55,
+	}
+	p = gopacket.NewPacket(data, LayerTypePPP, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeIPv6CP}, t)
+	v := p.Layer(LayerTypeIPv6CP).(*IPv6CP)
+	if !bytes.Equal(v.InterfaceIdentifier(), data[8:]) {
+		t.Errorf("interface identifier %x", v.InterfaceIdentifier())
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &PPP{PPPType: PPPTypeIPv6CP}, v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data)
+	}
+}
+
+func TestCHAP(t *testing.T) {
+	data := []byte{
+		0xc2, 0x23,
+		0x01, 0x01, 0x00, 0x18, 0x10,
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		'l', 'n', 's', 0x00, // padding
+	}
+	p := gopacket.NewPacket(data, LayerTypePPP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeCHAP}, t)
+	c := p.Layer(LayerTypeCHAP).(*CHAP)
+	if c.Code != CHAPCodeChallenge || c.Length != 24 || !bytes.Equal(c.Value, data[7:23]) || c.Name != "lns" {
+		t.Errorf("got %+v", c)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &PPP{PPPType: PPPTypeCHAP}, c); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data[:len(data)-1]) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data[:len(data)-1])
+	}
+
+	if err := c.DecodeFromBytes([]byte{0x03, 0x01, 0x00, 0x06, 'O', 'K'}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if c.Code != CHAPCodeSuccess || c.Message != "OK" || c.Value != nil {
+		t.Errorf("got %+v", c)
+	}
+	if err := c.DecodeFromBytes([]byte{0x02, 0x01, 0x00, 0x06, 0x10, 0x00}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated value")
+	}
+}
+
+func TestPAP(t *testing.T) {
+	data := []byte{
+		0xc0, 0x23,
+		0x01, 0x02, 0x00, 0x0e, 0x04, 'u', 's', 'e', 'r', 0x04, 'p', 'a', 's', 's',
+	}
+	p := gopacket.NewPacket(data, LayerTypePPP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypePAP}, t)
+	a := p.Layer(LayerTypePAP).(*PAP)
+	if a.Code != PAPCodeAuthenticateRequest || a.Identifier != 2 || a.PeerID != "user" || a.Password != "pass" {
+		t.Errorf("got %+v", a)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &PPP{PPPType: PPPTypePAP}, a); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data)
+	}
+
+	if err := a.DecodeFromBytes([]byte{0x02, 0x02, 0x00, 0x08, 0x03, 'W', 'e', 'l'}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if a.Code != PAPCodeAuthenticateAck || a.Message != "Wel" || a.PeerID != "" {
+		t.Errorf("got %+v", a)
+	}
+	if err := a.DecodeFromBytes(data[2:12], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated request")
+	}
+}
@@ -8,10 +8,66 @@ package layers
 
 import (
 	"encoding/binary"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
-// PPPoE is the layer for PPPoE encapsulation headers.
+// PPPoETagType is the type of a tag of a PPPoE discovery packet.
+type PPPoETagType uint16
+
+const (
+	PPPoETagTypeEndOfList        PPPoETagType = 0x0000
+	PPPoETagTypeServiceName      PPPoETagType = 0x0101
+	PPPoETagTypeACName           PPPoETagType = 0x0102
+	PPPoETagTypeHostUniq         PPPoETagType = 0x0103
+	PPPoETagTypeACCookie         PPPoETagType = 0x0104
+	PPPoETagTypeVendorSpecific   PPPoETagType = 0x0105
+	PPPoETagTypeRelaySessionID   PPPoETagType = 0x0110
+	PPPoETagTypePPPMaxPayload    PPPoETagType = 0x0120
+	PPPoETagTypeServiceNameError PPPoETagType = 0x0201
+	PPPoETagTypeACSystemError    PPPoETagType = 0x0202
+	PPPoETagTypeGenericError     PPPoETagType = 0x0203
+)
+
+func (t PPPoETagType) String() string {
+	switch t {
+	case PPPoETagTypeEndOfList:
+		return "End-Of-List"
+	case PPPoETagTypeServiceName:
+		return "Service-Name"
+	case PPPoETagTypeACName:
+		return "AC-Name"
+	case PPPoETagTypeHostUniq:
+		return "Host-Uniq"
+	case PPPoETagTypeACCookie:
+		return "AC-Cookie"
+	case PPPoETagTypeVendorSpecific:
+		return "Vendor-Specific"
+	case PPPoETagTypeRelaySessionID:
+		return "Relay-Session-Id"
+	case PPPoETagTypePPPMaxPayload:
+		return "PPP-Max-Payload"
+	case PPPoETagTypeServiceNameError:
+		return "Service-Name-Error"
+	case PPPoETagTypeACSystemError:
+		return "AC-System-Error"
+	case PPPoETagTypeGenericError:
+		return "Generic-Error"
+	default:
+		return fmt.Sprintf("Unknown(%#04x)", uint16(t))
+	}
+}
+
+// PPPoETag is a tag of a PPPoE discovery packet.
+type PPPoETag struct {
+	Type  PPPoETagType
+	Value []byte
+}
+
+// PPPoE is the layer for PPPoE encapsulation headers. The PPP frames of
+// session packets are its payload, and the Tags of discovery packets, PADI,
+// PADO, PADR, PADS and PADT, are decoded.
 type PPPoE struct {
 	BaseLayer
 	Version   uint8
@@ -19,6 +75,7 @@ type PPPoE struct {
 	Code      PPPoECode
 	SessionId uint16
 	Length    uint16
+	Tags      []PPPoETag
 }
 
 // LayerType returns gopacket.LayerTypePPPoE.
@@ -28,22 +85,145 @@ func (p *PPPoE) LayerType() gopacket.LayerType {
 
 // decodePPPoE decodes the PPPoE header (see http://tools.ietf.org/html/rfc2516).
 func decodePPPoE(data []byte, p gopacket.PacketBuilder) error {
-	pppoe := &PPPoE{
-		Version:   data[0] >> 4,
-		Type:      data[0] & 0x0F,
-		Code:      PPPoECode(data[1]),
-		SessionId: binary.BigEndian.Uint16(data[2:4]),
-		Length:    binary.BigEndian.Uint16(data[4:6]),
+	pppoe := &PPPoE{}
+	return decodingLayerDecoder(pppoe, data, p)
+}
+
+func (p *PPPoE) discovery() bool {
+	switch p.Code {
+	case PPPoECodePADI, PPPoECodePADO, PPPoECodePADR, PPPoECodePADS, PPPoECodePADT:
+		return true
 	}
-	pppoe.BaseLayer = BaseLayer{data[:6], data[6 : 6+pppoe.Length]}
-	p.AddLayer(pppoe)
-	return p.NextDecoder(pppoe.Code)
+	return false
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (p *PPPoE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 6 {
+		df.SetTruncated()
+		return fmt.Errorf("PPPoE length %d too short, 6 required", len(data))
+	}
+	p.Version = data[0] >> 4
+	p.Type = data[0] & 0x0F
+	p.Code = PPPoECode(data[1])
+	p.SessionId = binary.BigEndian.Uint16(data[2:4])
+	p.Length = binary.BigEndian.Uint16(data[4:6])
+	if int(p.Length) > len(data)-6 {
+		df.SetTruncated()
+		return fmt.Errorf("PPPoE length field %d exceeds %d remaining bytes", p.Length, len(data)-6)
+	}
+	p.Tags = p.Tags[:0]
+	if !p.discovery() {
+		p.BaseLayer = BaseLayer{data[:6], data[6 : 6+p.Length]}
+		return nil
+	}
+	for tags := data[6 : 6+p.Length]; len(tags) > 0; {
+		if len(tags) < 4 {
+			return fmt.Errorf("PPPoE tag of %d bytes too short", len(tags))
+		}
+		length := int(binary.BigEndian.Uint16(tags[2:4]))
+		if length > len(tags)-4 {
+			return fmt.Errorf("PPPoE tag length %d exceeds %d remaining bytes", length, len(tags)-4)
+		}
+		tag := PPPoETag{Type: PPPoETagType(binary.BigEndian.Uint16(tags[:2])), Value: tags[4 : 4+length]}
+		p.Tags = append(p.Tags, tag)
+		tags = tags[4+length:]
+		if tag.Type == PPPoETagTypeEndOfList {
+			break
+		}
+	}
+	p.BaseLayer = BaseLayer{Contents: data[:6+p.Length]}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (p *PPPoE) CanDecode() gopacket.LayerClass {
+	return LayerTypePPPoE
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (p *PPPoE) NextLayerType() gopacket.LayerType {
+	if p.Code == PPPoECodeSession {
+		return LayerTypePPP
+	}
+	if len(p.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+// Tag returns the value of the first tag of the type, or nil if there is
+// none.
+func (p *PPPoE) Tag(t PPPoETagType) []byte {
+	for _, tag := range p.Tags {
+		if tag.Type == t {
+			return tag.Value
+		}
+	}
+	return nil
+}
+
+func (p *PPPoE) hasTag(t PPPoETagType) bool {
+	for _, tag := range p.Tags {
+		if tag.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceName returns the Service-Name tag, which is empty for any service,
+// and whether there is one.
+func (p *PPPoE) ServiceName() (string, bool) {
+	return string(p.Tag(PPPoETagTypeServiceName)), p.hasTag(PPPoETagTypeServiceName)
+}
+
+// ACName returns the AC-Name tag of the access concentrator, and whether
+// there is one.
+func (p *PPPoE) ACName() (string, bool) {
+	return string(p.Tag(PPPoETagTypeACName)), p.hasTag(PPPoETagTypeACName)
+}
+
+// HostUniq returns the Host-Uniq tag the host chose to match the replies
+// to its requests, or nil if there is none.
+func (p *PPPoE) HostUniq() []byte {
+	return p.Tag(PPPoETagTypeHostUniq)
+}
+
+// ACCookie returns the AC-Cookie tag, or nil if there is none.
+func (p *PPPoE) ACCookie() []byte {
+	return p.Tag(PPPoETagTypeACCookie)
+}
+
+// RelaySessionID returns the Relay-Session-Id tag a relay added, or nil if
+// there is none.
+func (p *PPPoE) RelaySessionID() []byte {
+	return p.Tag(PPPoETagTypeRelaySessionID)
 }
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (p *PPPoE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if p.discovery() {
+		length := 0
+		for _, tag := range p.Tags {
+			if len(tag.Value) > 0xffff {
+				return fmt.Errorf("PPPoE %v tag of %d bytes too long", tag.Type, len(tag.Value))
+			}
+			length += 4 + len(tag.Value)
+		}
+		bytes, err := b.PrependBytes(length)
+		if err != nil {
+			return err
+		}
+		for _, tag := range p.Tags {
+			binary.BigEndian.PutUint16(bytes[:2], uint16(tag.Type))
+			binary.BigEndian.PutUint16(bytes[2:4], uint16(len(tag.Value)))
+			copy(bytes[4:], tag.Value)
+			bytes = bytes[4+len(tag.Value):]
+		}
+	}
 	payload := b.Bytes()
 	bytes, err := b.PrependBytes(6)
 	if err != nil {
@@ -0,0 +1,109 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A PADO of access concentrator "ac1" offering any service, with the
+// Host-Uniq of the PADI and a cookie.
+var testPacketPPPoEPADO = []byte{
+	0x11, 0x07, 0x00, 0x00, 0x00, 0x1f,
+	0x01, 0x01, 0x00, 0x00,
+	0x01, 0x02, 0x00, 0x03, 'a', 'c', '1',
+	0x01, 0x03, 0x00, 0x04, 0xde, 0xad, 0xbe, 0xef,
+	0x01, 0x04, 0x00, 0x04, 0x01, 0x02, 0x03, 0x04,
+	0x01, 0x10, 0x00, 0x00,
+}
+
+func TestPPPoEDiscovery(t *testing.T) {
+	eth := &Ethernet{
+		SrcMAC:       net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		DstMAC:       net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		EthernetType: EthernetTypePPPoEDiscovery,
+	}
+	pado := &PPPoE{
+		Version: 1,
+		Type:    1,
+		Code:    PPPoECodePADO,
+		Tags: []PPPoETag{
+			{PPPoETagTypeServiceName, nil},
+			{PPPoETagTypeACName, []byte("ac1")},
+			{PPPoETagTypeHostUniq, []byte{0xde, 0xad, 0xbe, 0xef}},
+			{PPPoETagTypeACCookie, []byte{0x01, 0x02, 0x03, 0x04}},
+			{PPPoETagTypeRelaySessionID, nil},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, eth, pado); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.Bytes()[14 : 14+len(testPacketPPPoEPADO)]; !bytes.Equal(got, testPacketPPPoEPADO) {
+		t.Errorf("serialized %x, want %x", got, testPacketPPPoEPADO)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypePPPoE}, t)
+	got := p.Layer(LayerTypePPPoE).(*PPPoE)
+	if got.Code != PPPoECodePADO || got.Length != 31 || len(got.Tags) != 5 {
+		t.Errorf("got %+v", got)
+	}
+	if s, ok := got.ServiceName(); !ok || s != "" {
+		t.Errorf("service name %q, %v", s, ok)
+	}
+	if ac, ok := got.ACName(); !ok || ac != "ac1" {
+		t.Errorf("AC name %q, %v", ac, ok)
+	}
+	if !bytes.Equal(got.HostUniq(), []byte{0xde, 0xad, 0xbe, 0xef}) || !bytes.Equal(got.ACCookie(), []byte{0x01, 0x02, 0x03, 0x04}) ||
+		got.RelaySessionID() == nil || len(got.RelaySessionID()) != 0 {
+		t.Errorf("tags %+v", got.Tags)
+	}
+	if got.Tag(PPPoETagTypeGenericError) != nil {
+		t.Error("got a Generic-Error tag")
+	}
+
+	// The length of the last tag exceeds the packet.
+	data := append([]byte(nil), testPacketPPPoEPADO...)
+	data[len(data)-1] = 1
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding malformed tag")
+	}
+	p = gopacket.NewPacket(testPacketPPPoEPADO[:20], LayerTypePPPoE, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated packet")
+	}
+}
+
+func TestPPPoESession(t *testing.T) {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&PPPoE{Version: 1, Type: 1, Code: PPPoECodeSession, SessionId: 0x11},
+		&PPP{PPPType: PPPTypeLCP},
+		&LCP{PPPControl: PPPControl{Code: PPPControlCodeEchoRequest, Identifier: 1}, MagicNumber: 0x01020304}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x11, 0x00, 0x00, 0x11, 0x00, 0x0a, 0xc0, 0x21, 0x09, 0x01, 0x00, 0x08, 0x01, 0x02, 0x03, 0x04}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), want)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypePPPoE, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPPoE, LayerTypePPP, LayerTypeLCP}, t)
+	if s := p.Layer(LayerTypePPPoE).(*PPPoE); s.SessionId != 0x11 || s.Length != 10 || len(s.Tags) != 0 {
+		t.Errorf("got %+v", s)
+	}
+}
@@ -0,0 +1,524 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// ProfinetFrameID identifies the kind of a PROFINET real-time frame, and for
+// cyclic data the communication relation it belongs to.
+type ProfinetFrameID uint16
+
+// PROFINET FrameID values and range boundaries, from IEC 61158-6-10.
+const (
+	ProfinetFrameIDPTCPSyncFollowUpMin ProfinetFrameID = 0x0020
+	ProfinetFrameIDPTCPSyncFollowUpMax ProfinetFrameID = 0x0021
+	ProfinetFrameIDPTCPSyncMin         ProfinetFrameID = 0x0080
+	ProfinetFrameIDPTCPSyncMax         ProfinetFrameID = 0x0081
+	ProfinetFrameIDRTClass3Min         ProfinetFrameID = 0x0100
+	ProfinetFrameIDRTClass3Max         ProfinetFrameID = 0x0FFF
+	ProfinetFrameIDRTClass2Min         ProfinetFrameID = 0x8000
+	ProfinetFrameIDRTClass2Max         ProfinetFrameID = 0xBFFF
+	ProfinetFrameIDRTClass1Min         ProfinetFrameID = 0xC000
+	ProfinetFrameIDRTClass1Max         ProfinetFrameID = 0xFBFF
+	ProfinetFrameIDAlarmHigh           ProfinetFrameID = 0xFC01
+	ProfinetFrameIDAlarmLow            ProfinetFrameID = 0xFE01
+	ProfinetFrameIDDCPHello            ProfinetFrameID = 0xFEFC
+	ProfinetFrameIDDCPGetSet           ProfinetFrameID = 0xFEFD
+	ProfinetFrameIDDCPIdentifyRequest  ProfinetFrameID = 0xFEFE
+	ProfinetFrameIDDCPIdentifyResponse ProfinetFrameID = 0xFEFF
+	ProfinetFrameIDPTCPAnnounceMin     ProfinetFrameID = 0xFF00
+	ProfinetFrameIDPTCPAnnounceMax     ProfinetFrameID = 0xFF01
+	ProfinetFrameIDPTCPFollowUpMin     ProfinetFrameID = 0xFF20
+	ProfinetFrameIDPTCPFollowUpMax     ProfinetFrameID = 0xFF21
+	ProfinetFrameIDPTCPDelayMin        ProfinetFrameID = 0xFF40
+	ProfinetFrameIDPTCPDelayMax        ProfinetFrameID = 0xFF43
+	ProfinetFrameIDFragmentationMin    ProfinetFrameID = 0xFF80
+	ProfinetFrameIDFragmentationMax    ProfinetFrameID = 0xFF8F
+)
+
+// IsRTClass1 reports whether the frame carries RT_CLASS_1 cyclic data.
+func (f ProfinetFrameID) IsRTClass1() bool {
+	return f >= ProfinetFrameIDRTClass1Min && f <= ProfinetFrameIDRTClass1Max
+}
+
+// IsRTClass2 reports whether the frame carries RT_CLASS_2 cyclic data.
+func (f ProfinetFrameID) IsRTClass2() bool {
+	return f >= ProfinetFrameIDRTClass2Min && f <= ProfinetFrameIDRTClass2Max
+}
+
+// IsRTClass3 reports whether the frame carries RT_CLASS_3 (isochronous)
+// cyclic data.
+func (f ProfinetFrameID) IsRTClass3() bool {
+	return f >= ProfinetFrameIDRTClass3Min && f <= ProfinetFrameIDRTClass3Max
+}
+
+// IsCyclic reports whether the frame carries cyclic IO data, and thus a
+// cycle counter and status trailer.
+func (f ProfinetFrameID) IsCyclic() bool {
+	return f.IsRTClass1() || f.IsRTClass2() || f.IsRTClass3()
+}
+
+// IsAlarm reports whether the frame is an acyclic high or low priority alarm.
+func (f ProfinetFrameID) IsAlarm() bool {
+	return f == ProfinetFrameIDAlarmHigh || f == ProfinetFrameIDAlarmLow
+}
+
+// IsDCP reports whether the frame is a Discovery and Configuration Protocol
+// frame.
+func (f ProfinetFrameID) IsDCP() bool {
+	return f >= ProfinetFrameIDDCPHello && f <= ProfinetFrameIDDCPIdentifyResponse
+}
+
+// IsPTCP reports whether the frame belongs to the Precision Transparent Clock
+// Protocol.
+func (f ProfinetFrameID) IsPTCP() bool {
+	switch {
+	case f >= ProfinetFrameIDPTCPSyncFollowUpMin && f <= ProfinetFrameIDPTCPSyncFollowUpMax,
+		f >= ProfinetFrameIDPTCPSyncMin && f <= ProfinetFrameIDPTCPSyncMax,
+		f >= ProfinetFrameIDPTCPAnnounceMin && f <= ProfinetFrameIDPTCPAnnounceMax,
+		f >= ProfinetFrameIDPTCPFollowUpMin && f <= ProfinetFrameIDPTCPFollowUpMax,
+		f >= ProfinetFrameIDPTCPDelayMin && f <= ProfinetFrameIDPTCPDelayMax:
+		return true
+	}
+	return false
+}
+
+func (f ProfinetFrameID) String() string {
+	switch {
+	case f.IsRTClass1():
+		return fmt.Sprintf("RT_CLASS_1(%#04x)", uint16(f))
+	case f.IsRTClass2():
+		return fmt.Sprintf("RT_CLASS_2(%#04x)", uint16(f))
+	case f.IsRTClass3():
+		return fmt.Sprintf("RT_CLASS_3(%#04x)", uint16(f))
+	case f == ProfinetFrameIDAlarmHigh:
+		return "AlarmHigh"
+	case f == ProfinetFrameIDAlarmLow:
+		return "AlarmLow"
+	case f == ProfinetFrameIDDCPHello:
+		return "DCPHello"
+	case f == ProfinetFrameIDDCPGetSet:
+		return "DCPGetSet"
+	case f == ProfinetFrameIDDCPIdentifyRequest:
+		return "DCPIdentifyRequest"
+	case f == ProfinetFrameIDDCPIdentifyResponse:
+		return "DCPIdentifyResponse"
+	case f.IsPTCP():
+		return fmt.Sprintf("PTCP(%#04x)", uint16(f))
+	case f >= ProfinetFrameIDFragmentationMin && f <= ProfinetFrameIDFragmentationMax:
+		return fmt.Sprintf("Fragmentation(%#04x)", uint16(f))
+	}
+	return fmt.Sprintf("Reserved(%#04x)", uint16(f))
+}
+
+// ProfinetDataStatus is the DataStatus byte of a cyclic PROFINET frame.
+type ProfinetDataStatus uint8
+
+// Primary is true if the frame comes from the primary AR, false for backup.
+func (s ProfinetDataStatus) Primary() bool { return s&0x01 != 0 }
+
+// Redundancy reports the redundancy bit; its meaning depends on Primary.
+func (s ProfinetDataStatus) Redundancy() bool { return s&0x02 != 0 }
+
+// DataValid is true if the IO data in the frame is valid.
+func (s ProfinetDataStatus) DataValid() bool { return s&0x04 != 0 }
+
+// ProviderRun is true if the provider is in RUN state, false for STOP.
+func (s ProfinetDataStatus) ProviderRun() bool { return s&0x10 != 0 }
+
+// StationProblem is true if the provider signals a station problem. The
+// indicator bit is active low.
+func (s ProfinetDataStatus) StationProblem() bool { return s&0x20 == 0 }
+
+// Ignore is true if the frame should be ignored by the consumer.
+func (s ProfinetDataStatus) Ignore() bool { return s&0x80 != 0 }
+
+// Profinet is the PROFINET real-time (RT) layer carried directly in Ethernet
+// frames with EtherType 0x8892.
+//
+// For cyclic data frames the trailer is decoded into CycleCounter,
+// DataStatus and TransferStatus and the IO data is left as the payload. DCP
+// and alarm frames are handed to LayerTypeProfinetDCP and
+// LayerTypeProfinetAlarm respectively.
+//
+// The length of the IO data of cyclic frames is configured per frame ID
+// rather than carried in the frame, so the trailer is taken from the end of
+// the frame unless DataLength is set. That is right for frames padded to the
+// minimum 40 bytes of IO data, as IEC 61158 requires, but not for frames
+// followed by Ethernet padding or a frame check sequence.
+type Profinet struct {
+	BaseLayer
+	FrameID        ProfinetFrameID
+	CycleCounter   uint16
+	DataStatus     ProfinetDataStatus
+	TransferStatus uint8
+	// Trailer holds the cycle counter and status bytes of cyclic frames.
+	Trailer []byte
+	// Padding holds the bytes after the trailer when DataLength is set.
+	Padding []byte
+	// DataLength, if set before decoding, is the length of the IO data of
+	// cyclic frames, which is then followed by the trailer and Padding.
+	// It is kept by DecodeFromBytes, for use with DecodingLayerParser.
+	DataLength int
+}
+
+// LayerType returns LayerTypeProfinet.
+func (p *Profinet) LayerType() gopacket.LayerType { return LayerTypeProfinet }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (p *Profinet) CanDecode() gopacket.LayerClass { return LayerTypeProfinet }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (p *Profinet) NextLayerType() gopacket.LayerType {
+	switch {
+	case p.FrameID.IsDCP():
+		return LayerTypeProfinetDCP
+	case p.FrameID.IsAlarm():
+		return LayerTypeProfinetAlarm
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (p *Profinet) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("PROFINET frame too short")
+	}
+	p.FrameID = ProfinetFrameID(binary.BigEndian.Uint16(data[0:2]))
+	p.CycleCounter, p.DataStatus, p.TransferStatus = 0, 0, 0
+	p.Trailer, p.Padding = nil, nil
+	if !p.FrameID.IsCyclic() {
+		p.BaseLayer = BaseLayer{Contents: data[:2], Payload: data[2:]}
+		return nil
+	}
+	end := len(data) - 4
+	if p.DataLength > 0 {
+		end = 2 + p.DataLength
+	}
+	if end < 2 || end+4 > len(data) {
+		df.SetTruncated()
+		return errors.New("PROFINET cyclic frame too short for APDU status")
+	}
+	p.Trailer = data[end : end+4]
+	p.Padding = data[end+4:]
+	p.CycleCounter = binary.BigEndian.Uint16(p.Trailer[0:2])
+	p.DataStatus = ProfinetDataStatus(p.Trailer[2])
+	p.TransferStatus = p.Trailer[3]
+	p.BaseLayer = BaseLayer{Contents: data[:2], Payload: data[2:end]}
+	return nil
+}
+
+func decodeProfinet(data []byte, p gopacket.PacketBuilder) error {
+	pn := &Profinet{}
+	return decodingLayerDecoder(pn, data, p)
+}
+
+// ProfinetDCPServiceID is the service of a DCP frame.
+type ProfinetDCPServiceID uint8
+
+// ProfinetDCPServiceID known values.
+const (
+	ProfinetDCPServiceGet      ProfinetDCPServiceID = 3
+	ProfinetDCPServiceSet      ProfinetDCPServiceID = 4
+	ProfinetDCPServiceIdentify ProfinetDCPServiceID = 5
+	ProfinetDCPServiceHello    ProfinetDCPServiceID = 6
+)
+
+func (s ProfinetDCPServiceID) String() string {
+	switch s {
+	case ProfinetDCPServiceGet:
+		return "Get"
+	case ProfinetDCPServiceSet:
+		return "Set"
+	case ProfinetDCPServiceIdentify:
+		return "Identify"
+	case ProfinetDCPServiceHello:
+		return "Hello"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// ProfinetDCPServiceType tells requests and responses apart.
+type ProfinetDCPServiceType uint8
+
+// ProfinetDCPServiceType known values.
+const (
+	ProfinetDCPServiceTypeRequest              ProfinetDCPServiceType = 0
+	ProfinetDCPServiceTypeResponseSuccess      ProfinetDCPServiceType = 1
+	ProfinetDCPServiceTypeResponseNotSupported ProfinetDCPServiceType = 5
+)
+
+func (s ProfinetDCPServiceType) String() string {
+	switch s {
+	case ProfinetDCPServiceTypeRequest:
+		return "Request"
+	case ProfinetDCPServiceTypeResponseSuccess:
+		return "ResponseSuccess"
+	case ProfinetDCPServiceTypeResponseNotSupported:
+		return "ResponseNotSupported"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// ProfinetDCPOption is the option of a DCP block.
+type ProfinetDCPOption uint8
+
+// ProfinetDCPOption known values.
+const (
+	ProfinetDCPOptionIP               ProfinetDCPOption = 0x01
+	ProfinetDCPOptionDeviceProperties ProfinetDCPOption = 0x02
+	ProfinetDCPOptionDHCP             ProfinetDCPOption = 0x03
+	ProfinetDCPOptionControl          ProfinetDCPOption = 0x05
+	ProfinetDCPOptionDeviceInitiative ProfinetDCPOption = 0x06
+	ProfinetDCPOptionAll              ProfinetDCPOption = 0xFF
+)
+
+func (o ProfinetDCPOption) String() string {
+	switch o {
+	case ProfinetDCPOptionIP:
+		return "IP"
+	case ProfinetDCPOptionDeviceProperties:
+		return "DeviceProperties"
+	case ProfinetDCPOptionDHCP:
+		return "DHCP"
+	case ProfinetDCPOptionControl:
+		return "Control"
+	case ProfinetDCPOptionDeviceInitiative:
+		return "DeviceInitiative"
+	case ProfinetDCPOptionAll:
+		return "All"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(o))
+}
+
+// Suboptions of the IP, DeviceProperties and Control options.
+const (
+	ProfinetDCPSuboptionIPMAC                 uint8 = 0x01
+	ProfinetDCPSuboptionIPParameter           uint8 = 0x02
+	ProfinetDCPSuboptionIPFullSuite           uint8 = 0x03
+	ProfinetDCPSuboptionDeviceVendor          uint8 = 0x01
+	ProfinetDCPSuboptionDeviceNameOfStation   uint8 = 0x02
+	ProfinetDCPSuboptionDeviceID              uint8 = 0x03
+	ProfinetDCPSuboptionDeviceRole            uint8 = 0x04
+	ProfinetDCPSuboptionDeviceOptions         uint8 = 0x05
+	ProfinetDCPSuboptionDeviceAliasName       uint8 = 0x06
+	ProfinetDCPSuboptionDeviceInstance        uint8 = 0x07
+	ProfinetDCPSuboptionControlStart          uint8 = 0x01
+	ProfinetDCPSuboptionControlStop           uint8 = 0x02
+	ProfinetDCPSuboptionControlSignal         uint8 = 0x03
+	ProfinetDCPSuboptionControlResponse       uint8 = 0x04
+	ProfinetDCPSuboptionControlFactoryReset   uint8 = 0x05
+	ProfinetDCPSuboptionControlResetToFactory uint8 = 0x06
+)
+
+// ProfinetDCPBlock is a single option block of a DCP frame.
+//
+// Blocks in Set requests and in Get, Identify and Hello responses start with
+// a BlockQualifier or BlockInfo word. When present it is decoded into
+// Qualifier and HasQualifier is set; Data then holds the remaining bytes.
+// Blocks of a Get request only name an option and have no length or data.
+type ProfinetDCPBlock struct {
+	Option       ProfinetDCPOption
+	Suboption    uint8
+	Length       uint16
+	HasQualifier bool
+	Qualifier    uint16
+	Data         []byte
+}
+
+// ProfinetDCP is a PROFINET Discovery and Configuration Protocol frame.
+type ProfinetDCP struct {
+	BaseLayer
+	ServiceID   ProfinetDCPServiceID
+	ServiceType ProfinetDCPServiceType
+	Xid         uint32
+	// ResponseDelay is only meaningful in Identify requests; it is reserved
+	// and zero in all other frames.
+	ResponseDelay uint16
+	DataLength    uint16
+	Blocks        []ProfinetDCPBlock
+}
+
+// LayerType returns LayerTypeProfinetDCP.
+func (d *ProfinetDCP) LayerType() gopacket.LayerType { return LayerTypeProfinetDCP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (d *ProfinetDCP) CanDecode() gopacket.LayerClass { return LayerTypeProfinetDCP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (d *ProfinetDCP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+func (d *ProfinetDCP) blockHasQualifier(b *ProfinetDCPBlock) bool {
+	if d.ServiceType == ProfinetDCPServiceTypeRequest {
+		return d.ServiceID == ProfinetDCPServiceSet || d.ServiceID == ProfinetDCPServiceHello
+	}
+	// The control response block of a Set response carries the answered
+	// option instead of a BlockInfo.
+	return !(b.Option == ProfinetDCPOptionControl && b.Suboption == ProfinetDCPSuboptionControlResponse)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (d *ProfinetDCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 10 {
+		df.SetTruncated()
+		return errors.New("PROFINET DCP header too short")
+	}
+	d.ServiceID = ProfinetDCPServiceID(data[0])
+	d.ServiceType = ProfinetDCPServiceType(data[1])
+	d.Xid = binary.BigEndian.Uint32(data[2:6])
+	d.ResponseDelay = binary.BigEndian.Uint16(data[6:8])
+	d.DataLength = binary.BigEndian.Uint16(data[8:10])
+	end := 10 + int(d.DataLength)
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("PROFINET DCP data length %d exceeds frame", d.DataLength)
+	}
+	d.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	d.Blocks = d.Blocks[:0]
+
+	blocks := data[10:end]
+	if d.ServiceID == ProfinetDCPServiceGet && d.ServiceType == ProfinetDCPServiceTypeRequest {
+		for ; len(blocks) >= 2; blocks = blocks[2:] {
+			d.Blocks = append(d.Blocks, ProfinetDCPBlock{
+				Option:    ProfinetDCPOption(blocks[0]),
+				Suboption: blocks[1],
+			})
+		}
+		return nil
+	}
+	for len(blocks) >= 4 {
+		b := ProfinetDCPBlock{
+			Option:    ProfinetDCPOption(blocks[0]),
+			Suboption: blocks[1],
+			Length:    binary.BigEndian.Uint16(blocks[2:4]),
+		}
+		if 4+int(b.Length) > len(blocks) {
+			df.SetTruncated()
+			return fmt.Errorf("PROFINET DCP block length %d exceeds data", b.Length)
+		}
+		b.Data = blocks[4 : 4+b.Length]
+		if d.blockHasQualifier(&b) && len(b.Data) >= 2 {
+			b.HasQualifier = true
+			b.Qualifier = binary.BigEndian.Uint16(b.Data[0:2])
+			b.Data = b.Data[2:]
+		}
+		d.Blocks = append(d.Blocks, b)
+		// blocks are padded to an even length
+		next := 4 + int(b.Length) + int(b.Length&1)
+		if next > len(blocks) {
+			break
+		}
+		blocks = blocks[next:]
+	}
+	return nil
+}
+
+// Block returns the first block with the given option and suboption.
+func (d *ProfinetDCP) Block(option ProfinetDCPOption, suboption uint8) (*ProfinetDCPBlock, bool) {
+	for i := range d.Blocks {
+		if d.Blocks[i].Option == option && d.Blocks[i].Suboption == suboption {
+			return &d.Blocks[i], true
+		}
+	}
+	return nil, false
+}
+
+// NameOfStation returns the NameOfStation carried by the frame, if any.
+func (d *ProfinetDCP) NameOfStation() (string, bool) {
+	b, ok := d.Block(ProfinetDCPOptionDeviceProperties, ProfinetDCPSuboptionDeviceNameOfStation)
+	if !ok {
+		return "", false
+	}
+	return string(b.Data), true
+}
+
+// IPParameter returns the IP address, subnet mask and default gateway
+// carried by the frame's IP parameter or full IP suite block, if any.
+func (d *ProfinetDCP) IPParameter() (ip net.IP, mask net.IPMask, gateway net.IP, ok bool) {
+	b, ok := d.Block(ProfinetDCPOptionIP, ProfinetDCPSuboptionIPParameter)
+	if !ok {
+		b, ok = d.Block(ProfinetDCPOptionIP, ProfinetDCPSuboptionIPFullSuite)
+	}
+	if !ok || len(b.Data) < 12 {
+		return nil, nil, nil, false
+	}
+	return net.IP(b.Data[0:4]), net.IPMask(b.Data[4:8]), net.IP(b.Data[8:12]), true
+}
+
+// DeviceID returns the vendor and device IDs carried by the frame, if any.
+func (d *ProfinetDCP) DeviceID() (vendorID, deviceID uint16, ok bool) {
+	b, ok := d.Block(ProfinetDCPOptionDeviceProperties, ProfinetDCPSuboptionDeviceID)
+	if !ok || len(b.Data) < 4 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint16(b.Data[0:2]), binary.BigEndian.Uint16(b.Data[2:4]), true
+}
+
+func decodeProfinetDCP(data []byte, p gopacket.PacketBuilder) error {
+	d := &ProfinetDCP{}
+	return decodingLayerDecoder(d, data, p)
+}
+
+// ProfinetAlarm is the acyclic real-time (RTA) header of a PROFINET alarm
+// frame. The alarm notification or acknowledgement itself is left as the
+// payload.
+type ProfinetAlarm struct {
+	BaseLayer
+	AlarmDstEndpoint uint16
+	AlarmSrcEndpoint uint16
+	// PDUType holds the RTA PDU type in its low nibble (1 DATA, 2 NACK,
+	// 3 ACK, 4 ERR) and the RTA version in its high nibble.
+	PDUType    uint8
+	AddFlags   uint8
+	SendSeqNum uint16
+	AckSeqNum  uint16
+	VarPartLen uint16
+}
+
+// LayerType returns LayerTypeProfinetAlarm.
+func (a *ProfinetAlarm) LayerType() gopacket.LayerType { return LayerTypeProfinetAlarm }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *ProfinetAlarm) CanDecode() gopacket.LayerClass { return LayerTypeProfinetAlarm }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (a *ProfinetAlarm) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *ProfinetAlarm) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return errors.New("PROFINET alarm header too short")
+	}
+	a.AlarmDstEndpoint = binary.BigEndian.Uint16(data[0:2])
+	a.AlarmSrcEndpoint = binary.BigEndian.Uint16(data[2:4])
+	a.PDUType = data[4]
+	a.AddFlags = data[5]
+	a.SendSeqNum = binary.BigEndian.Uint16(data[6:8])
+	a.AckSeqNum = binary.BigEndian.Uint16(data[8:10])
+	a.VarPartLen = binary.BigEndian.Uint16(data[10:12])
+	end := 12 + int(a.VarPartLen)
+	if end > len(data) {
+		df.SetTruncated()
+		end = len(data)
+	}
+	a.BaseLayer = BaseLayer{Contents: data[:12], Payload: data[12:end]}
+	return nil
+}
+
+func decodeProfinetAlarm(data []byte, p gopacket.PacketBuilder) error {
+	a := &ProfinetAlarm{}
+	return decodingLayerDecoder(a, data, p)
+}
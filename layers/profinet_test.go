@@ -0,0 +1,123 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketProfinetDCPIdentifyResponse is a DCP Identify response carrying
+// NameOfStation "plc-1xyz", IP 192.168.0.1/24 via 192.168.0.254 and
+// vendor/device ID 0x002a/0x0101.
+var testPacketProfinetDCPIdentifyResponse = []byte{
+	0x00, 0x0e, 0x8c, 0xab, 0xcd, 0xef, 0x08, 0x00, 0x06, 0x12, 0x34, 0x56, 0x88, 0x92, 0xfe, 0xff,
+	0x05, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2a, 0x02, 0x02, 0x00, 0x0a, 0x00, 0x00,
+	0x70, 0x6c, 0x63, 0x2d, 0x31, 0x78, 0x79, 0x7a, 0x01, 0x02, 0x00, 0x0e, 0x00, 0x01, 0xc0, 0xa8,
+	0x00, 0x01, 0xff, 0xff, 0xff, 0x00, 0xc0, 0xa8, 0x00, 0xfe, 0x02, 0x03, 0x00, 0x06, 0x00, 0x00,
+	0x00, 0x2a, 0x01, 0x01,
+}
+
+func TestProfinetDCPIdentifyResponse(t *testing.T) {
+	p := gopacket.NewPacket(testPacketProfinetDCPIdentifyResponse, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeProfinet, LayerTypeProfinetDCP}, t)
+
+	pn := p.Layer(LayerTypeProfinet).(*Profinet)
+	if pn.FrameID != ProfinetFrameIDDCPIdentifyResponse {
+		t.Errorf("got FrameID %v", pn.FrameID)
+	}
+	dcp := p.Layer(LayerTypeProfinetDCP).(*ProfinetDCP)
+	if dcp.ServiceID != ProfinetDCPServiceIdentify || dcp.ServiceType != ProfinetDCPServiceTypeResponseSuccess || dcp.Xid != 1 {
+		t.Errorf("bad DCP header: %+v", dcp)
+	}
+	if len(dcp.Blocks) != 3 {
+		t.Fatalf("got %d blocks, want 3", len(dcp.Blocks))
+	}
+	if name, ok := dcp.NameOfStation(); !ok || name != "plc-1xyz" {
+		t.Errorf("got NameOfStation %q", name)
+	}
+	ip, mask, gw, ok := dcp.IPParameter()
+	if !ok || !ip.Equal(net.IPv4(192, 168, 0, 1)) || !gw.Equal(net.IPv4(192, 168, 0, 254)) {
+		t.Errorf("got IP parameter %v %v %v", ip, mask, gw)
+	}
+	if ones, _ := mask.Size(); ones != 24 {
+		t.Errorf("got mask %v", mask)
+	}
+	if b, _ := dcp.Block(ProfinetDCPOptionIP, ProfinetDCPSuboptionIPParameter); !b.HasQualifier || b.Qualifier != 1 {
+		t.Errorf("got block info %v/%d", b.HasQualifier, b.Qualifier)
+	}
+	if vendor, device, ok := dcp.DeviceID(); !ok || vendor != 0x2a || device != 0x0101 {
+		t.Errorf("got device ID %#x/%#x", vendor, device)
+	}
+}
+
+// testPacketProfinetRTClass1 is an RT_CLASS_1 frame with 40 bytes of IO
+// data, cycle counter 0x1a2b and data status 0x35.
+var testPacketProfinetRTClass1 = []byte{
+	0x00, 0x0e, 0x8c, 0xab, 0xcd, 0xef, 0x08, 0x00, 0x06, 0x12, 0x34, 0x56, 0x88, 0x92, 0xc0, 0x01,
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
+	0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20,
+	0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x1a, 0x2b, 0x35, 0x00,
+}
+
+func TestProfinetRTClass1(t *testing.T) {
+	p := gopacket.NewPacket(testPacketProfinetRTClass1, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeProfinet, gopacket.LayerTypePayload}, t)
+
+	pn := p.Layer(LayerTypeProfinet).(*Profinet)
+	if !pn.FrameID.IsRTClass1() {
+		t.Errorf("FrameID %v is not RT_CLASS_1", pn.FrameID)
+	}
+	if pn.CycleCounter != 0x1a2b {
+		t.Errorf("got cycle counter %#x", pn.CycleCounter)
+	}
+	if !pn.DataStatus.Primary() || !pn.DataStatus.DataValid() || !pn.DataStatus.ProviderRun() || pn.DataStatus.StationProblem() {
+		t.Errorf("bad data status %#x", uint8(pn.DataStatus))
+	}
+	if len(pn.Payload) != 40 || pn.Payload[0] != 1 || pn.Payload[39] != 0x28 {
+		t.Errorf("bad IO data %x", pn.Payload)
+	}
+	if len(pn.Contents) != 2 || len(pn.Trailer) != 4 || len(pn.Padding) != 0 {
+		t.Errorf("got contents %x, trailer %x, padding %x", pn.Contents, pn.Trailer, pn.Padding)
+	}
+}
+
+func TestProfinetRTClass1DataLength(t *testing.T) {
+	// 20 bytes of IO data followed by the trailer and Ethernet padding.
+	data := append([]byte{0xc0, 0x01}, make([]byte, 20)...)
+	data = append(data, 0x1a, 0x2b, 0x35, 0x00)
+	data = append(data, make([]byte, 20)...)
+	pn := &Profinet{DataLength: 20}
+	if err := pn.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if pn.CycleCounter != 0x1a2b || pn.DataStatus != 0x35 {
+		t.Errorf("got cycle counter %#x, data status %#x", pn.CycleCounter, uint8(pn.DataStatus))
+	}
+	if len(pn.Contents) != 2 || len(pn.Payload) != 20 || len(pn.Trailer) != 4 || len(pn.Padding) != 20 {
+		t.Errorf("got %d bytes of contents, %d of payload, %d of trailer, %d of padding",
+			len(pn.Contents), len(pn.Payload), len(pn.Trailer), len(pn.Padding))
+	}
+	pn.DataLength = 60
+	if err := pn.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded IO data longer than the frame")
+	}
+}
+
+func BenchmarkDecodeProfinetRTClass1(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(testPacketProfinetRTClass1, LinkTypeEthernet, gopacket.NoCopy)
+	}
+}
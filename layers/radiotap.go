@@ -45,13 +45,30 @@ const (
 	RadioTapPresentTxFlags
 	RadioTapPresentRtsRetries
 	RadioTapPresentDataRetries
-	_
+	RadioTapPresentXChannel
 	RadioTapPresentMCS
 	RadioTapPresentAMPDUStatus
 	RadioTapPresentVHT
-	RadioTapPresentEXT RadioTapPresent = 1 << 31
+	RadioTapPresentTimestamp
+	RadioTapPresentHE
+	RadioTapPresentHEMU
+	RadioTapPresentHEMUOtherUser
+	RadioTapPresentZeroLengthPSDU
+	RadioTapPresentLSIG
+	RadioTapPresentTLV
+	RadioTapPresentRadioTapNamespace
+	RadioTapPresentVendorNamespace
+	RadioTapPresentEXT
+
+	// radioTapPresentSerializable are the fields RadioTap.SerializeTo
+	// encodes.
+	radioTapPresentSerializable = RadioTapPresentTLV<<1 - 1
 )
 
+// radioTapMaxLength is the length of a header carrying all the fixed fields
+// of radioTapPresentSerializable, aligned. TLVs follow them.
+const radioTapMaxLength = 128
+
 func (r RadioTapPresent) TSFT() bool {
 	return r&RadioTapPresentTSFT != 0
 }
@@ -115,6 +132,36 @@ func (r RadioTapPresent) AMPDUStatus() bool {
 func (r RadioTapPresent) VHT() bool {
 	return r&RadioTapPresentVHT != 0
 }
+func (r RadioTapPresent) XChannel() bool {
+	return r&RadioTapPresentXChannel != 0
+}
+func (r RadioTapPresent) Timestamp() bool {
+	return r&RadioTapPresentTimestamp != 0
+}
+func (r RadioTapPresent) HE() bool {
+	return r&RadioTapPresentHE != 0
+}
+func (r RadioTapPresent) HEMU() bool {
+	return r&RadioTapPresentHEMU != 0
+}
+func (r RadioTapPresent) HEMUOtherUser() bool {
+	return r&RadioTapPresentHEMUOtherUser != 0
+}
+func (r RadioTapPresent) ZeroLengthPSDU() bool {
+	return r&RadioTapPresentZeroLengthPSDU != 0
+}
+func (r RadioTapPresent) LSIG() bool {
+	return r&RadioTapPresentLSIG != 0
+}
+func (r RadioTapPresent) TLV() bool {
+	return r&RadioTapPresentTLV != 0
+}
+func (r RadioTapPresent) RadioTapNamespace() bool {
+	return r&RadioTapPresentRadioTapNamespace != 0
+}
+func (r RadioTapPresent) VendorNamespace() bool {
+	return r&RadioTapPresentVendorNamespace != 0
+}
 func (r RadioTapPresent) EXT() bool {
 	return r&RadioTapPresentEXT != 0
 }
@@ -674,6 +721,197 @@ func (self RadioTapVHTMCSNSS) String() string {
 	return fmt.Sprintf("NSS#%dMCS#%d", uint32(self&0xf), uint32(self>>4))
 }
 
+// RadioTapXChannel is the extended channel field of Atheros and FreeBSD
+// drivers.
+type RadioTapXChannel struct {
+	Flags     uint32
+	Frequency RadioTapChannelFrequency
+	Channel   uint8
+	MaxPower  uint8
+}
+
+// RadioTapTimestamp is the timestamp field, measured at the sampling
+// position the UnitPosition field tells.
+type RadioTapTimestamp struct {
+	Timestamp uint64
+	Accuracy  uint16
+	// UnitPosition holds the time unit in its low 4 bits (0 for
+	// milliseconds, 1 for microseconds and 2 for nanoseconds), and the
+	// sampling position in its high 4 bits.
+	UnitPosition uint8
+	Flags        uint8
+}
+
+// RadioTapHEFormat is the HE PPDU format of a RadioTapHE field.
+type RadioTapHEFormat uint8
+
+const (
+	RadioTapHEFormatSU RadioTapHEFormat = iota
+	RadioTapHEFormatExtSU
+	RadioTapHEFormatMU
+	RadioTapHEFormatTrig
+)
+
+func (f RadioTapHEFormat) String() string {
+	switch f {
+	case RadioTapHEFormatSU:
+		return "SU"
+	case RadioTapHEFormatExtSU:
+		return "EXT_SU"
+	case RadioTapHEFormatMU:
+		return "MU"
+	case RadioTapHEFormatTrig:
+		return "TRIG"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(f))
+}
+
+// RadioTapHE is the HE field of 802.11ax frames. Its data words hold the
+// values of the HE-SIG-A fields along with bits telling which of them are
+// known; the methods below decode the commonly used ones.
+type RadioTapHE struct {
+	Data1, Data2, Data3, Data4, Data5, Data6 uint16
+}
+
+// Format returns the HE PPDU format.
+func (self RadioTapHE) Format() RadioTapHEFormat { return RadioTapHEFormat(self.Data1 & 0x3) }
+
+// BSSColor returns the BSS color, and whether it is known.
+func (self RadioTapHE) BSSColor() (uint8, bool) {
+	return uint8(self.Data3 & 0x3f), self.Data1&0x0004 != 0
+}
+
+// MCS returns the MCS index of the data, and whether it is known.
+func (self RadioTapHE) MCS() (uint8, bool) {
+	return uint8(self.Data3 >> 8 & 0xf), self.Data1&0x0020 != 0
+}
+
+// LDPC returns whether the data is LDPC rather than BCC coded, and whether
+// the coding is known.
+func (self RadioTapHE) LDPC() (bool, bool) {
+	return self.Data3&0x2000 != 0, self.Data1&0x0080 != 0
+}
+
+// STBC returns whether the data is space-time block coded, and whether it
+// is known.
+func (self RadioTapHE) STBC() (bool, bool) {
+	return self.Data3&0x8000 != 0, self.Data1&0x0200 != 0
+}
+
+// Bandwidth returns the data bandwidth or RU allocation: 0 to 3 for 20, 40,
+// 80 and 160 MHz, and 4 to 7 for 26, 52, 106 and 242 tone RUs, and whether
+// it is known.
+func (self RadioTapHE) Bandwidth() (uint8, bool) {
+	return uint8(self.Data5 & 0xf), self.Data1&0x4000 != 0
+}
+
+// GuardInterval returns the guard interval in nanoseconds, and whether it
+// is known.
+func (self RadioTapHE) GuardInterval() (uint16, bool) {
+	switch self.Data5 >> 4 & 0x3 {
+	case 0:
+		return 800, self.Data2&0x0002 != 0
+	case 1:
+		return 1600, self.Data2&0x0002 != 0
+	case 2:
+		return 3200, self.Data2&0x0002 != 0
+	}
+	return 0, false
+}
+
+// SpaceTimeStreams returns the number of space-time streams, or 0 if it is
+// unknown.
+func (self RadioTapHE) SpaceTimeStreams() uint8 { return uint8(self.Data6 & 0xf) }
+
+// SpatialStreams returns the number of spatial streams, or 0 if it is
+// unknown.
+func (self RadioTapHE) SpatialStreams() uint8 {
+	n := self.SpaceTimeStreams()
+	if stbc, known := self.STBC(); stbc && known {
+		n /= 2
+	}
+	return n
+}
+
+func (self RadioTapHE) String() string {
+	tokens := []string{self.Format().String()}
+	if mcs, ok := self.MCS(); ok {
+		tokens = append(tokens, fmt.Sprintf("MCS#%d", mcs))
+	}
+	if nss := self.SpatialStreams(); nss != 0 {
+		tokens = append(tokens, fmt.Sprintf("NSS#%d", nss))
+	}
+	if ldpc, ok := self.LDPC(); ok {
+		if ldpc {
+			tokens = append(tokens, "LDPC")
+		} else {
+			tokens = append(tokens, "BCC")
+		}
+	}
+	if gi, ok := self.GuardInterval(); ok {
+		tokens = append(tokens, fmt.Sprintf("GI=%dns", gi))
+	}
+	if color, ok := self.BSSColor(); ok {
+		tokens = append(tokens, fmt.Sprintf("BSS-color=%d", color))
+	}
+	return strings.Join(tokens, ",")
+}
+
+// RadioTapHEMU is the HE-MU field, holding the HE-SIG-B fields of
+// multi-user frames.
+type RadioTapHEMU struct {
+	Flags1, Flags2         uint16
+	RUChannel1, RUChannel2 [4]uint8
+}
+
+// SIGBMCS returns the MCS index of the HE-SIG-B field, and whether it is
+// known.
+func (self RadioTapHEMU) SIGBMCS() (uint8, bool) {
+	return uint8(self.Flags1 & 0xf), self.Flags1&0x0010 != 0
+}
+
+// RadioTapHEMUOtherUser is the HE-MU-other-user field, describing another
+// user of a multi-user frame.
+type RadioTapHEMUOtherUser struct {
+	PerUser1, PerUser2 uint16
+	PerUserPosition    uint8
+	PerUserKnown       uint8
+}
+
+// RadioTapLSIG is the L-SIG field of the legacy preamble of HT, VHT and HE
+// frames.
+type RadioTapLSIG struct {
+	Data1, Data2 uint16
+}
+
+// Rate returns the rate field, and whether it is known.
+func (self RadioTapLSIG) Rate() (uint8, bool) {
+	return uint8(self.Data2 & 0xf), self.Data1&0x0001 != 0
+}
+
+// Length returns the length field, and whether it is known.
+func (self RadioTapLSIG) Length() (uint16, bool) {
+	return self.Data2 >> 4, self.Data1&0x0002 != 0
+}
+
+// RadioTapTLVType is the type of a radiotap TLV. Types below 32 are those of
+// the fields of the presence bitmap.
+type RadioTapTLVType uint16
+
+const (
+	RadioTapTLVTypeVendorNamespace RadioTapTLVType = 30
+	RadioTapTLVTypeS1G             RadioTapTLVType = 32
+	RadioTapTLVTypeUSIG            RadioTapTLVType = 33
+	RadioTapTLVTypeEHT             RadioTapTLVType = 34
+)
+
+// RadioTapTLV is a field of the TLV list following the fields of the
+// presence bitmap when RadioTapPresentTLV is set.
+type RadioTapTLV struct {
+	Type RadioTapTLVType
+	Data []byte
+}
+
 func decodeRadioTap(data []byte, p gopacket.PacketBuilder) error {
 	d := &RadioTap{}
 	// TODO: Should we set LinkLayer here? And implement LinkFlow
@@ -725,6 +963,18 @@ type RadioTap struct {
 	MCS         RadioTapMCS
 	AMPDUStatus RadioTapAMPDUStatus
 	VHT         RadioTapVHT
+	XChannel    RadioTapXChannel
+	Timestamp   RadioTapTimestamp
+	HE          RadioTapHE
+	HEMU        RadioTapHEMU
+	// HEMUOtherUser describes another user of a multi-user frame.
+	HEMUOtherUser  RadioTapHEMUOtherUser
+	ZeroLengthPSDU uint8
+	LSIG           RadioTapLSIG
+	// TLVs holds the TLVs following the other fields when Present has
+	// RadioTapPresentTLV. They are only decoded from headers with a single
+	// presence bitmap.
+	TLVs []RadioTapTLV
 }
 
 func (m *RadioTap) LayerType() gopacket.LayerType { return LayerTypeRadioTap }
@@ -737,6 +987,10 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 	m.Version = uint8(data[0])
 	m.Length = binary.LittleEndian.Uint16(data[2:4])
 	m.Present = RadioTapPresent(binary.LittleEndian.Uint32(data[4:8]))
+	if m.Length < 8 || int(m.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("RadioTap length %d invalid for %d bytes", m.Length, len(data))
+	}
 
 	offset := uint16(4)
 
@@ -745,91 +999,164 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 		// and expects all fields are packed in the first it_present.
 		// Extended bitmap will be just ignored.
 		offset += 4
+		if offset+4 > m.Length {
+			return m.truncated("present bitmap", df)
+		}
 	}
 	offset += 4 // skip the bitmap
 
 	if m.Present.TSFT() {
 		offset += align(offset, 8)
+		if offset+8 > m.Length {
+			return m.truncated("TSFT", df)
+		}
 		m.TSFT = binary.LittleEndian.Uint64(data[offset : offset+8])
 		offset += 8
 	}
 	if m.Present.Flags() {
+		if offset+1 > m.Length {
+			return m.truncated("flags", df)
+		}
 		m.Flags = RadioTapFlags(data[offset])
 		offset++
 	}
 	if m.Present.Rate() {
+		if offset+1 > m.Length {
+			return m.truncated("rate", df)
+		}
 		m.Rate = RadioTapRate(data[offset])
 		offset++
 	}
 	if m.Present.Channel() {
 		offset += align(offset, 2)
+		if offset+4 > m.Length {
+			return m.truncated("channel", df)
+		}
 		m.ChannelFrequency = RadioTapChannelFrequency(binary.LittleEndian.Uint16(data[offset : offset+2]))
 		offset += 2
 		m.ChannelFlags = RadioTapChannelFlags(binary.LittleEndian.Uint16(data[offset : offset+2]))
 		offset += 2
 	}
 	if m.Present.FHSS() {
+		if offset+2 > m.Length {
+			return m.truncated("FHSS", df)
+		}
 		m.FHSS = binary.LittleEndian.Uint16(data[offset : offset+2])
 		offset += 2
 	}
 	if m.Present.DBMAntennaSignal() {
+		if offset+1 > m.Length {
+			return m.truncated("dBm antenna signal", df)
+		}
 		m.DBMAntennaSignal = int8(data[offset])
 		offset++
 	}
 	if m.Present.DBMAntennaNoise() {
+		if offset+1 > m.Length {
+			return m.truncated("dBm antenna noise", df)
+		}
 		m.DBMAntennaNoise = int8(data[offset])
 		offset++
 	}
 	if m.Present.LockQuality() {
 		offset += align(offset, 2)
+		if offset+2 > m.Length {
+			return m.truncated("lock quality", df)
+		}
 		m.LockQuality = binary.LittleEndian.Uint16(data[offset : offset+2])
 		offset += 2
 	}
 	if m.Present.TxAttenuation() {
 		offset += align(offset, 2)
+		if offset+2 > m.Length {
+			return m.truncated("TX attenuation", df)
+		}
 		m.TxAttenuation = binary.LittleEndian.Uint16(data[offset : offset+2])
 		offset += 2
 	}
 	if m.Present.DBTxAttenuation() {
 		offset += align(offset, 2)
+		if offset+2 > m.Length {
+			return m.truncated("dB TX attenuation", df)
+		}
 		m.DBTxAttenuation = binary.LittleEndian.Uint16(data[offset : offset+2])
 		offset += 2
 	}
 	if m.Present.DBMTxPower() {
+		if offset+1 > m.Length {
+			return m.truncated("dBm TX power", df)
+		}
 		m.DBMTxPower = int8(data[offset])
 		offset++
 	}
 	if m.Present.Antenna() {
+		if offset+1 > m.Length {
+			return m.truncated("antenna", df)
+		}
 		m.Antenna = uint8(data[offset])
 		offset++
 	}
 	if m.Present.DBAntennaSignal() {
+		if offset+1 > m.Length {
+			return m.truncated("dB antenna signal", df)
+		}
 		m.DBAntennaSignal = uint8(data[offset])
 		offset++
 	}
 	if m.Present.DBAntennaNoise() {
+		if offset+1 > m.Length {
+			return m.truncated("dB antenna noise", df)
+		}
 		m.DBAntennaNoise = uint8(data[offset])
 		offset++
 	}
 	if m.Present.RxFlags() {
 		offset += align(offset, 2)
+		if offset+2 > m.Length {
+			return m.truncated("RX flags", df)
+		}
 		m.RxFlags = RadioTapRxFlags(binary.LittleEndian.Uint16(data[offset:]))
 		offset += 2
 	}
 	if m.Present.TxFlags() {
 		offset += align(offset, 2)
+		if offset+2 > m.Length {
+			return m.truncated("TX flags", df)
+		}
 		m.TxFlags = RadioTapTxFlags(binary.LittleEndian.Uint16(data[offset:]))
 		offset += 2
 	}
 	if m.Present.RtsRetries() {
+		if offset+1 > m.Length {
+			return m.truncated("RTS retries", df)
+		}
 		m.RtsRetries = uint8(data[offset])
 		offset++
 	}
 	if m.Present.DataRetries() {
+		if offset+1 > m.Length {
+			return m.truncated("data retries", df)
+		}
 		m.DataRetries = uint8(data[offset])
 		offset++
 	}
+	if m.Present.XChannel() {
+		offset += align(offset, 4)
+		if offset+8 > m.Length {
+			return m.truncated("XChannel", df)
+		}
+		m.XChannel = RadioTapXChannel{
+			Flags:     binary.LittleEndian.Uint32(data[offset:]),
+			Frequency: RadioTapChannelFrequency(binary.LittleEndian.Uint16(data[offset+4:])),
+			Channel:   data[offset+6],
+			MaxPower:  data[offset+7],
+		}
+		offset += 8
+	}
 	if m.Present.MCS() {
+		if offset+3 > m.Length {
+			return m.truncated("MCS", df)
+		}
 		m.MCS = RadioTapMCS{
 			RadioTapMCSKnown(data[offset]),
 			RadioTapMCSFlags(data[offset+1]),
@@ -839,6 +1166,9 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 	}
 	if m.Present.AMPDUStatus() {
 		offset += align(offset, 4)
+		if offset+8 > m.Length {
+			return m.truncated("A-MPDU status", df)
+		}
 		m.AMPDUStatus = RadioTapAMPDUStatus{
 			Reference: binary.LittleEndian.Uint32(data[offset:]),
 			Flags:     RadioTapAMPDUStatusFlags(binary.LittleEndian.Uint16(data[offset+4:])),
@@ -848,6 +1178,9 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 	}
 	if m.Present.VHT() {
 		offset += align(offset, 2)
+		if offset+12 > m.Length {
+			return m.truncated("VHT", df)
+		}
 		m.VHT = RadioTapVHT{
 			Known:     RadioTapVHTKnown(binary.LittleEndian.Uint16(data[offset:])),
 			Flags:     RadioTapVHTFlags(data[offset+2]),
@@ -864,11 +1197,100 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 		}
 		offset += 12
 	}
+	if m.Present.Timestamp() {
+		offset += align(offset, 8)
+		if offset+12 > m.Length {
+			return m.truncated("timestamp", df)
+		}
+		m.Timestamp = RadioTapTimestamp{
+			Timestamp:    binary.LittleEndian.Uint64(data[offset:]),
+			Accuracy:     binary.LittleEndian.Uint16(data[offset+8:]),
+			UnitPosition: data[offset+10],
+			Flags:        data[offset+11],
+		}
+		offset += 12
+	}
+	if m.Present.HE() {
+		offset += align(offset, 2)
+		if offset+12 > m.Length {
+			return m.truncated("HE", df)
+		}
+		m.HE = RadioTapHE{
+			Data1: binary.LittleEndian.Uint16(data[offset:]),
+			Data2: binary.LittleEndian.Uint16(data[offset+2:]),
+			Data3: binary.LittleEndian.Uint16(data[offset+4:]),
+			Data4: binary.LittleEndian.Uint16(data[offset+6:]),
+			Data5: binary.LittleEndian.Uint16(data[offset+8:]),
+			Data6: binary.LittleEndian.Uint16(data[offset+10:]),
+		}
+		offset += 12
+	}
+	if m.Present.HEMU() {
+		offset += align(offset, 2)
+		if offset+12 > m.Length {
+			return m.truncated("HE-MU", df)
+		}
+		m.HEMU.Flags1 = binary.LittleEndian.Uint16(data[offset:])
+		m.HEMU.Flags2 = binary.LittleEndian.Uint16(data[offset+2:])
+		copy(m.HEMU.RUChannel1[:], data[offset+4:offset+8])
+		copy(m.HEMU.RUChannel2[:], data[offset+8:offset+12])
+		offset += 12
+	}
+	if m.Present.HEMUOtherUser() {
+		offset += align(offset, 2)
+		if offset+6 > m.Length {
+			return m.truncated("HE-MU-other-user", df)
+		}
+		m.HEMUOtherUser = RadioTapHEMUOtherUser{
+			PerUser1:        binary.LittleEndian.Uint16(data[offset:]),
+			PerUser2:        binary.LittleEndian.Uint16(data[offset+2:]),
+			PerUserPosition: data[offset+4],
+			PerUserKnown:    data[offset+5],
+		}
+		offset += 6
+	}
+	if m.Present.ZeroLengthPSDU() {
+		if offset+1 > m.Length {
+			return m.truncated("zero-length PSDU", df)
+		}
+		m.ZeroLengthPSDU = data[offset]
+		offset++
+	}
+	if m.Present.LSIG() {
+		offset += align(offset, 2)
+		if offset+4 > m.Length {
+			return m.truncated("L-SIG", df)
+		}
+		m.LSIG = RadioTapLSIG{
+			Data1: binary.LittleEndian.Uint16(data[offset:]),
+			Data2: binary.LittleEndian.Uint16(data[offset+2:]),
+		}
+		offset += 4
+	}
+	m.TLVs = m.TLVs[:0]
+	if m.Present.TLV() && !m.Present.EXT() {
+		// The TLVs start aligned and pad their values to 4 bytes.
+		offset += align(offset, 4)
+		for offset < m.Length {
+			if offset+4 > m.Length {
+				return m.truncated("TLV", df)
+			}
+			typ := RadioTapTLVType(binary.LittleEndian.Uint16(data[offset:]))
+			length := binary.LittleEndian.Uint16(data[offset+2:])
+			offset += 4
+			if int(offset)+int(length) > int(m.Length) {
+				return m.truncated("TLV", df)
+			}
+			m.TLVs = append(m.TLVs, RadioTapTLV{Type: typ, Data: data[offset : offset+length]})
+			offset += length
+			offset += align(offset, 4)
+		}
+	}
 
 	payload := data[m.Length:]
 
 	// Remove non standard padding used by some Wi-Fi drivers
-	if m.Flags.Datapad() &&
+	if m.Flags.Datapad() && len(payload) >= 2 &&
 		payload[0]&0xC == 0x8 { //&& // Data frame
 		headlen := 24
 		if payload[0]&0x8C == 0x88 { // QoS
@@ -877,7 +1299,7 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 		if payload[1]&0x3 == 0x3 { // 4 addresses
 			headlen += 2
 		}
-		if headlen%4 == 2 {
+		if headlen%4 == 2 && len(payload) >= headlen+2 {
 			payload = append(payload[:headlen], payload[headlen+2:len(payload)]...)
 		}
 	}
@@ -902,21 +1324,34 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 	return nil
 }
 
+func (m *RadioTap) truncated(field string, df gopacket.DecodeFeedback) error {
+	df.SetTruncated()
+	return fmt.Errorf("RadioTap %s field exceeds header length %d", field, m.Length)
+}
+
+// SerializeTo writes the fields Present tells, each aligned on its size as
+// the radiotap format requires, followed by TLVs when Present has
+// RadioTapPresentTLV. Only the fields of the standard namespace decoded by
+// DecodeFromBytes are supported, and the extended bitmaps the EXT bit
+// announces are dropped.
+//
+// Dot11 layers serialize their frame check sequence, which Flags should
+// tell with RadioTapFlagsFCS.
 func (m RadioTap) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	buf := make([]byte, 1024)
+	present := m.Present &^ RadioTapPresentEXT
+	if unsupported := present &^ radioTapPresentSerializable; unsupported != 0 {
+		return fmt.Errorf("cannot serialize RadioTap fields %#x", uint32(unsupported))
+	}
+	m.Present = present
+
+	var buf [radioTapMaxLength]byte
 
 	buf[0] = m.Version
 	buf[1] = 0
 
 	binary.LittleEndian.PutUint32(buf[4:8], uint32(m.Present))
 
-	offset := uint16(4)
-
-	for (binary.LittleEndian.Uint32(buf[offset:offset+4]) & 0x80000000) != 0 {
-		offset += 4
-	}
-
-	offset += 4
+	offset := uint16(8)
 
 	if m.Present.TSFT() {
 		offset += align(offset, 8)
@@ -1017,6 +1452,18 @@ func (m RadioTap) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serializ
 		offset++
 	}
 
+	if m.Present.XChannel() {
+		offset += align(offset, 4)
+
+		binary.LittleEndian.PutUint32(buf[offset:], m.XChannel.Flags)
+		binary.LittleEndian.PutUint16(buf[offset+4:], uint16(m.XChannel.Frequency))
+
+		buf[offset+6] = m.XChannel.Channel
+		buf[offset+7] = m.XChannel.MaxPower
+
+		offset += 8
+	}
+
 	if m.Present.MCS() {
 		buf[offset] = uint8(m.MCS.Known)
 		buf[offset+1] = uint8(m.MCS.Flags)
@@ -1055,19 +1502,101 @@ func (m RadioTap) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serializ
 		offset += 12
 	}
 
-	packetBuf, err := b.PrependBytes(int(offset))
+	if m.Present.Timestamp() {
+		offset += align(offset, 8)
+
+		binary.LittleEndian.PutUint64(buf[offset:], m.Timestamp.Timestamp)
+		binary.LittleEndian.PutUint16(buf[offset+8:], m.Timestamp.Accuracy)
+
+		buf[offset+10] = m.Timestamp.UnitPosition
+		buf[offset+11] = m.Timestamp.Flags
+
+		offset += 12
+	}
+
+	if m.Present.HE() {
+		offset += align(offset, 2)
+		for i, d := range [...]uint16{m.HE.Data1, m.HE.Data2, m.HE.Data3, m.HE.Data4, m.HE.Data5, m.HE.Data6} {
+			binary.LittleEndian.PutUint16(buf[offset+uint16(i)*2:], d)
+		}
+		offset += 12
+	}
+
+	if m.Present.HEMU() {
+		offset += align(offset, 2)
+
+		binary.LittleEndian.PutUint16(buf[offset:], m.HEMU.Flags1)
+		binary.LittleEndian.PutUint16(buf[offset+2:], m.HEMU.Flags2)
+
+		copy(buf[offset+4:], m.HEMU.RUChannel1[:])
+		copy(buf[offset+8:], m.HEMU.RUChannel2[:])
+
+		offset += 12
+	}
+
+	if m.Present.HEMUOtherUser() {
+		offset += align(offset, 2)
+
+		binary.LittleEndian.PutUint16(buf[offset:], m.HEMUOtherUser.PerUser1)
+		binary.LittleEndian.PutUint16(buf[offset+2:], m.HEMUOtherUser.PerUser2)
+
+		buf[offset+4] = m.HEMUOtherUser.PerUserPosition
+		buf[offset+5] = m.HEMUOtherUser.PerUserKnown
+
+		offset += 6
+	}
+
+	if m.Present.ZeroLengthPSDU() {
+		buf[offset] = m.ZeroLengthPSDU
+		offset++
+	}
+
+	if m.Present.LSIG() {
+		offset += align(offset, 2)
+
+		binary.LittleEndian.PutUint16(buf[offset:], m.LSIG.Data1)
+		binary.LittleEndian.PutUint16(buf[offset+2:], m.LSIG.Data2)
+
+		offset += 4
+	}
+
+	length := int(offset)
+	if m.Present.TLV() {
+		length += int(align(offset, 4))
+		for _, tlv := range m.TLVs {
+			length += 4 + len(tlv.Data) + int(align(uint16(len(tlv.Data)%4), 4))
+		}
+		if length > 0xffff {
+			return fmt.Errorf("RadioTap header length %d too long", length)
+		}
+	}
+
+	packetBuf, err := b.PrependBytes(length)
 
 	if err != nil {
 		return err
 	}
 
 	if opts.FixLengths {
-		m.Length = offset
+		m.Length = uint16(length)
 	}
 
 	binary.LittleEndian.PutUint16(buf[2:4], m.Length)
 
-	copy(packetBuf, buf)
+	copy(packetBuf, buf[:offset])
+
+	if m.Present.TLV() {
+		for i := int(offset); i < length; i++ {
+			packetBuf[i] = 0
+		}
+		n := int(offset + align(offset, 4))
+		for _, tlv := range m.TLVs {
+			binary.LittleEndian.PutUint16(packetBuf[n:], uint16(tlv.Type))
+			binary.LittleEndian.PutUint16(packetBuf[n+2:], uint16(len(tlv.Data)))
+			n += 4 + copy(packetBuf[n+4:], tlv.Data)
+			n += int(align(uint16(n%4), 4))
+		}
+	}
 
 	return nil
 }
@@ -6,8 +6,12 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"net"
+	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 // testPacketRadiotap0 is the packet:
@@ -77,3 +81,177 @@ func BenchmarkDecodePacketRadiotap1(b *testing.B) {
 		gopacket.NewPacket(testPacketRadiotap1, LayerTypeRadioTap, gopacket.NoCopy)
 	}
 }
+
+func TestRadiotapSerialize(t *testing.T) {
+	for _, data := range [][]byte{
+		testPacketRadiotap0,
+		testPacketRadiotap1,
+		testPacketDot11CtrlCTS,
+		testPacketDot11MgmtBeacon,
+		testPacketDot11HTControl,
+		testPacketDot11DataQOSData,
+	} {
+		p := gopacket.NewPacket(data, LayerTypeRadioTap, gopacket.Default)
+		rt, ok := p.Layer(LayerTypeRadioTap).(*RadioTap)
+		if !ok {
+			t.Fatal("no RadioTap layer in", p)
+		}
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, rt); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), rt.Contents) {
+			t.Errorf("serialized as %x, want %x", buf.Bytes(), rt.Contents)
+		}
+	}
+
+	// A frame to inject, with every field serialized.
+	rt := &RadioTap{
+		Present:          radioTapPresentSerializable &^ RadioTapPresentTLV,
+		TSFT:             0x0102030405060708,
+		Flags:            RadioTapFlagsFCS | RadioTapFlagsShortPreamble,
+		Rate:             12,
+		ChannelFrequency: 2437,
+		ChannelFlags:     RadioTapChannelFlags(0x00a0),
+		FHSS:             0x0102,
+		DBMAntennaSignal: -40,
+		DBMAntennaNoise:  -95,
+		LockQuality:      3,
+		TxAttenuation:    4,
+		DBTxAttenuation:  5,
+		DBMTxPower:       20,
+		Antenna:          1,
+		DBAntennaSignal:  60,
+		DBAntennaNoise:   10,
+		RxFlags:          RadioTapRxFlagsBadPlcp,
+		TxFlags:          RadioTapTxFlagsNoACK,
+		RtsRetries:       1,
+		DataRetries:      2,
+		MCS:              RadioTapMCS{Known: RadioTapMCSKnownMCSIndex | RadioTapMCSKnownBandwidth, Flags: RadioTapMCSFlagsShortGI, MCS: 7},
+		AMPDUStatus:      RadioTapAMPDUStatus{Reference: 42, Flags: RadioTapAMPDUIsLast, CRC: 9},
+		VHT: RadioTapVHT{Known: RadioTapVHTKnownBandwidth, Flags: RadioTapVHTFlagsSGI, Bandwidth: 4,
+			MCSNSS: [4]RadioTapVHTMCSNSS{0x92}, Coding: 1, GroupId: 3, PartialAID: 0x1234},
+		XChannel:       RadioTapXChannel{Flags: 0x00000140, Frequency: 5180, Channel: 36, MaxPower: 23},
+		Timestamp:      RadioTapTimestamp{Timestamp: 0x1122334455667788, Accuracy: 10, UnitPosition: 0x11, Flags: 2},
+		HE:             RadioTapHE{Data1: 0x0224, Data2: 0x0002, Data3: 0x0705, Data5: 0x0012, Data6: 0x0002},
+		HEMU:           RadioTapHEMU{Flags1: 0x0012, Flags2: 0x0100, RUChannel1: [4]uint8{0x60}},
+		HEMUOtherUser:  RadioTapHEMUOtherUser{PerUser1: 0x1234, PerUser2: 0x5678, PerUserPosition: 1, PerUserKnown: 0x3f},
+		ZeroLengthPSDU: 1,
+		LSIG:           RadioTapLSIG{Data1: 0x0003, Data2: 0x1a0b},
+	}
+	dot11 := &Dot11{Type: Dot11TypeMgmtDeauthentication, Address1: net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		Address2: net.HardwareAddr{2, 2, 3, 4, 5, 6}, Address3: net.HardwareAddr{2, 2, 3, 4, 5, 6}, AppendFCS: true}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, rt, dot11, &Dot11MgmtDeauthentication{Reason: Dot11ReasonDeauthStLeaving}); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeRadioTap, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeRadioTap).(*RadioTap)
+	rt.BaseLayer, rt.Length = got.BaseLayer, radioTapMaxLength
+	if !reflect.DeepEqual(got, rt) {
+		t.Errorf("decoded %+v, want %+v", got, rt)
+	}
+	if d, ok := p.Layer(LayerTypeDot11).(*Dot11); !ok || !d.ChecksumValid() {
+		t.Errorf("802.11 frame not decoded, or with an invalid checksum: %v", p)
+	}
+
+	rt.Present |= RadioTapPresentVendorNamespace
+	if err := gopacket.SerializeLayers(buf, opts, rt); err == nil {
+		t.Error("serialized an unsupported field")
+	}
+}
+
+// testPacketRadiotapHE is a radiotap header of an 802.11ax frame, with HE,
+// L-SIG and an U-SIG TLV.
+var testPacketRadiotapHE = []byte{
+	0x00, 0x00, 0x34, 0x00, // version, length
+	0x02, 0x00, 0x80, 0x18, // present: flags, HE, L-SIG, TLV
+	0x10, 0x00, // flags: FCS, padding
+	0x24, 0x02, 0x02, 0x00, 0x05, 0x87, 0x00, 0x00, 0x21, 0x00, 0x04, 0x00, // HE
+	0x03, 0x00, 0x0b, 0x1a, // L-SIG
+	0x00, 0x00, // padding
+	0x21, 0x00, 0x0c, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, // U-SIG
+	0x7f, 0x00, 0x03, 0x00, 0xaa, 0xbb, 0xcc, 0x00, // unknown, padded
+}
+
+func TestRadiotapHE(t *testing.T) {
+	rt := &RadioTap{}
+	if err := rt.DecodeFromBytes(testPacketRadiotapHE, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.Present.HE() || !rt.Present.LSIG() || !rt.Present.TLV() {
+		t.Errorf("present %#x", rt.Present)
+	}
+	he := rt.HE
+	if f := he.Format(); f != RadioTapHEFormatSU {
+		t.Errorf("format %v", f)
+	}
+	if color, ok := he.BSSColor(); color != 5 || !ok {
+		t.Errorf("BSS color %d, %t", color, ok)
+	}
+	if mcs, ok := he.MCS(); mcs != 7 || !ok {
+		t.Errorf("MCS %d, %t", mcs, ok)
+	}
+	if gi, ok := he.GuardInterval(); gi != 3200 || !ok {
+		t.Errorf("GI %d, %t", gi, ok)
+	}
+	if stbc, ok := he.STBC(); !stbc || !ok || he.SpaceTimeStreams() != 4 || he.SpatialStreams() != 2 {
+		t.Errorf("STBC %t, %t, %d space-time streams, %d spatial streams", stbc, ok, he.SpaceTimeStreams(), he.SpatialStreams())
+	}
+	if bw, ok := he.Bandwidth(); ok {
+		t.Errorf("bandwidth %d known", bw)
+	}
+	if s := he.String(); s != "SU,MCS#7,NSS#2,GI=3200ns,BSS-color=5" {
+		t.Errorf("HE %q", s)
+	}
+	if rate, ok := rt.LSIG.Rate(); rate != 0xb || !ok {
+		t.Errorf("L-SIG rate %d, %t", rate, ok)
+	}
+	if length, ok := rt.LSIG.Length(); length != 0x1a0 || !ok {
+		t.Errorf("L-SIG length %d, %t", length, ok)
+	}
+	want := []RadioTapTLV{
+		{RadioTapTLVTypeUSIG, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c}},
+		{0x7f, []byte{0xaa, 0xbb, 0xcc}},
+	}
+	if !reflect.DeepEqual(rt.TLVs, want) {
+		t.Errorf("TLVs %+v, want %+v", rt.TLVs, want)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, rt); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketRadiotapHE) {
+		t.Errorf("serialized as %x, want %x", buf.Bytes(), testPacketRadiotapHE)
+	}
+
+	for _, n := range []int{24, 30, 36, 46} {
+		data := append([]byte(nil), testPacketRadiotapHE[:n]...)
+		data[2] = byte(n)
+		if err := rt.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("decoded header truncated to %d bytes", n)
+		}
+	}
+}
+
+func TestRadiotapTruncatedFields(t *testing.T) {
+	for _, data := range [][]byte{
+		{0, 0, 8, 0, 0, 0, 0x20, 0},        // VHT
+		{0, 0, 8, 0, 0, 0, 0x08, 0},        // MCS
+		{0, 0, 8, 0, 0, 0, 0x10, 0},        // A-MPDU status
+		{0, 0, 8, 0, 1, 0, 0, 0},           // TSFT
+		{0, 0, 10, 0, 0x08, 0, 0, 0, 0, 0}, // channel
+		{0, 0, 8, 0, 0, 0, 0, 0x80},        // extended bitmap
+		{0, 0, 8, 0, 0, 0, 0x04, 0},        // data retries
+	} {
+		rt := &RadioTap{}
+		if err := rt.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("decoded truncated header %x", data)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// UpdateChecksum returns the internet checksum csum updated for a 16 bit
+// word of the data it covers changed from old to new, as RFC 1624
+// describes.
+func UpdateChecksum(csum, old, new uint16) uint16 {
+	sum := uint32(^csum) + uint32(^old) + uint32(new)
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// UpdateChecksumBytes returns the internet checksum csum updated for a
+// field of the data it covers changed from old to new. The field must
+// start at an even offset of the data, and old and new have the same
+// length.
+func UpdateChecksumBytes(csum uint16, old, new []byte) uint16 {
+	sum := uint32(^csum)
+	for i := 0; i < len(old); i += 2 {
+		var o, n uint16
+		if i+1 < len(old) {
+			o, n = binary.BigEndian.Uint16(old[i:]), binary.BigEndian.Uint16(new[i:])
+		} else {
+			o, n = uint16(old[i])<<8, uint16(new[i])<<8
+		}
+		sum += uint32(^o) + uint32(n)
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// The rewrite functions below patch serialized IPv4 or IPv6 packets in
+// place, updating the checksums covering the rewritten fields instead of
+// computing them again, as NATs and load balancers do. Packets must
+// start with their IP header, and are not otherwise validated.
+
+// ipPacket locates the transport header of a serialized IP packet.
+type ipPacket struct {
+	data    []byte
+	version uint8
+	proto   IPProtocol
+	// transport is the offset of the transport header, or -1 for
+	// fragments other than the first.
+	transport int
+}
+
+func parseIPPacket(data []byte) (ipPacket, error) {
+	p := ipPacket{data: data, transport: -1}
+	if len(data) == 0 {
+		return p, errors.New("empty IP packet")
+	}
+	p.version = data[0] >> 4
+	switch p.version {
+	case 4:
+		if len(data) < 20 {
+			return p, errors.New("IPv4 header too short")
+		}
+		ihl := int(data[0]&0xf) * 4
+		if ihl < 20 || ihl > len(data) {
+			return p, fmt.Errorf("invalid IPv4 header length %d", ihl)
+		}
+		p.proto = IPProtocol(data[9])
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff == 0 {
+			p.transport = ihl
+		}
+		return p, nil
+	case 6:
+		if len(data) < 40 {
+			return p, errors.New("IPv6 header too short")
+		}
+		p.proto = IPProtocol(data[6])
+		offset := 40
+		for {
+			switch p.proto {
+			case IPProtocolIPv6HopByHop, IPProtocolIPv6Routing, IPProtocolIPv6Destination, IPProtocolAH, IPProtocolIPv6Fragment:
+			default:
+				p.transport = offset
+				return p, nil
+			}
+			if offset+8 > len(data) {
+				return p, errors.New("IPv6 extension header truncated")
+			}
+			next := IPProtocol(data[offset])
+			switch p.proto {
+			case IPProtocolAH:
+				offset += (int(data[offset+1]) + 2) * 4
+			case IPProtocolIPv6Fragment:
+				if binary.BigEndian.Uint16(data[offset+2:])&0xfff8 != 0 {
+					p.proto = next
+					return p, nil
+				}
+				offset += 8
+			default:
+				offset += (int(data[offset+1]) + 1) * 8
+			}
+			p.proto = next
+		}
+	}
+	return p, fmt.Errorf("invalid IP version %d", p.version)
+}
+
+// transportChecksum returns the offset of the checksum of the transport
+// header covering its ports, or, if ports is not set, the addresses of the
+// pseudo-header. It returns -1 if there is no checksum to update.
+func (p ipPacket) transportChecksum(ports bool) (int, error) {
+	var offset int
+	switch {
+	case p.proto == IPProtocolTCP:
+		offset = 16
+	case p.proto == IPProtocolUDP || p.proto == IPProtocolUDPLite || p.proto == IPProtocolDCCP:
+		offset = 6
+	case p.proto == IPProtocolICMPv6 && !ports:
+		offset = 2
+	case ports:
+		return -1, fmt.Errorf("cannot rewrite the ports of %v packets", p.proto)
+	default:
+		return -1, nil
+	}
+	if p.transport < 0 {
+		if ports {
+			return -1, errors.New("packet is not the first fragment of its datagram")
+		}
+		return -1, nil
+	}
+	offset += p.transport
+	if offset+2 > len(p.data) {
+		return -1, fmt.Errorf("%v header truncated", p.proto)
+	}
+	if p.proto == IPProtocolUDP && binary.BigEndian.Uint16(p.data[offset:]) == 0 {
+		// The checksum was not computed, and must stay zero.
+		return -1, nil
+	}
+	return offset, nil
+}
+
+// rewrite replaces the field at offset with value, updating the checksum at
+// csum, if it is not negative.
+func (p ipPacket) rewrite(offset int, value []byte, csum int) {
+	if csum >= 0 {
+		c := UpdateChecksumBytes(binary.BigEndian.Uint16(p.data[csum:]), p.data[offset:offset+len(value)], value)
+		if c == 0 && p.proto == IPProtocolUDP {
+			c = 0xffff
+		}
+		binary.BigEndian.PutUint16(p.data[csum:], c)
+	}
+	copy(p.data[offset:], value)
+}
+
+func rewriteIP(data []byte, ip net.IP, offset4, offset6 int) error {
+	p, err := parseIPPacket(data)
+	if err != nil {
+		return err
+	}
+	offset := offset4
+	if p.version == 4 {
+		if ip = ip.To4(); ip == nil {
+			return errors.New("cannot rewrite an IPv4 address with an IPv6 one")
+		}
+	} else {
+		if ip.To4() != nil {
+			return errors.New("cannot rewrite an IPv6 address with an IPv4 one")
+		}
+		if ip = ip.To16(); ip == nil {
+			return errors.New("invalid IP address")
+		}
+		offset = offset6
+	}
+	csum, err := p.transportChecksum(false)
+	if err != nil {
+		return err
+	}
+	old := append([]byte(nil), data[offset:offset+len(ip)]...)
+	p.rewrite(offset, ip, csum)
+	if p.version == 4 {
+		binary.BigEndian.PutUint16(data[10:], UpdateChecksumBytes(binary.BigEndian.Uint16(data[10:]), old, ip))
+	}
+	return nil
+}
+
+// RewriteIPSrc sets the source address of a serialized IP packet to ip, of
+// the same version, and updates the IPv4 header checksum and the checksum
+// of the TCP, UDP, DCCP or ICMPv6 pseudo-header.
+func RewriteIPSrc(data []byte, ip net.IP) error {
+	return rewriteIP(data, ip, 12, 8)
+}
+
+// RewriteIPDst sets the destination address of a serialized IP packet to
+// ip, as RewriteIPSrc does.
+func RewriteIPDst(data []byte, ip net.IP) error {
+	return rewriteIP(data, ip, 16, 24)
+}
+
+func rewritePort(data []byte, port uint16, offset int) error {
+	p, err := parseIPPacket(data)
+	if err != nil {
+		return err
+	}
+	csum, err := p.transportChecksum(true)
+	if err != nil {
+		return err
+	}
+	if p.transport+4 > len(data) {
+		return fmt.Errorf("%v header truncated", p.proto)
+	}
+	var value [2]byte
+	binary.BigEndian.PutUint16(value[:], port)
+	p.rewrite(p.transport+offset, value[:], csum)
+	return nil
+}
+
+// RewriteSrcPort sets the source port of the TCP, UDP or DCCP header of
+// a serialized IP packet, and updates its checksum.
+func RewriteSrcPort(data []byte, port uint16) error {
+	return rewritePort(data, port, 0)
+}
+
+// RewriteDstPort sets the destination port of the TCP, UDP or DCCP header
+// of a serialized IP packet, and updates its checksum.
+func RewriteDstPort(data []byte, port uint16) error {
+	return rewritePort(data, port, 2)
+}
+
+// DecrementTTL decrements the TTL of a serialized IPv4 packet, updating its
+// header checksum, or the hop limit of an IPv6 packet. It returns an error
+// for packets whose TTL or hop limit is already zero.
+func DecrementTTL(data []byte) error {
+	p, err := parseIPPacket(data)
+	if err != nil {
+		return err
+	}
+	if p.version == 6 {
+		if data[7] == 0 {
+			return errors.New("hop limit exceeded")
+		}
+		data[7]--
+		return nil
+	}
+	if data[8] == 0 {
+		return errors.New("TTL exceeded")
+	}
+	// The TTL shares a 16 bit word with the protocol.
+	old := binary.BigEndian.Uint16(data[8:])
+	data[8]--
+	binary.BigEndian.PutUint16(data[10:], UpdateChecksum(binary.BigEndian.Uint16(data[10:]), old, binary.BigEndian.Uint16(data[8:])))
+	return nil
+}
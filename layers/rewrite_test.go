@@ -0,0 +1,143 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestUpdateChecksum(t *testing.T) {
+	data := []byte{0x45, 0x00, 0x00, 0x54, 0x12, 0x34, 0x40, 0x00, 0x40, 0x01}
+	csum := tcpipChecksum(data, 0)
+	data[8] = 0x3f
+	if got, want := UpdateChecksum(csum, 0x4001, 0x3f01), tcpipChecksum(data, 0); got != want {
+		t.Errorf("UpdateChecksum gave %#x, want %#x", got, want)
+	}
+	// A field of odd length.
+	csum = tcpipChecksum(data, 0)
+	old := append([]byte(nil), data[2:7]...)
+	copy(data[2:7], []byte{0xff, 0xff, 0xff, 0xff, 0xff})
+	if got, want := UpdateChecksumBytes(csum, old, data[2:7]), tcpipChecksum(data, 0); got != want {
+		t.Errorf("UpdateChecksumBytes gave %#x, want %#x", got, want)
+	}
+}
+
+// rewriteTestPacket serializes an IP packet carrying transport, with the
+// addresses and ports given.
+func rewriteTestPacket(t *testing.T, v6 bool, proto IPProtocol, src, dst net.IP, sport, dport uint16, ttl uint8) []byte {
+	var network gopacket.NetworkLayer
+	var ipLayers []gopacket.SerializableLayer
+	if v6 {
+		ip := &IPv6{Version: 6, NextHeader: IPProtocolIPv6Destination, HopLimit: ttl, SrcIP: src, DstIP: dst}
+		dst := createIPv6DestinationChecksumTestLayer()
+		dst.NextHeader = proto
+		network, ipLayers = ip, []gopacket.SerializableLayer{ip, dst}
+	} else {
+		ip := &IPv4{Version: 4, Protocol: proto, TTL: ttl, SrcIP: src, DstIP: dst}
+		network, ipLayers = ip, []gopacket.SerializableLayer{ip}
+	}
+	var transport gopacket.SerializableLayer
+	switch proto {
+	case IPProtocolTCP:
+		tcp := &TCP{SrcPort: TCPPort(sport), DstPort: TCPPort(dport), Seq: 42, ACK: true, Window: 512}
+		tcp.SetNetworkLayerForChecksum(network)
+		transport = tcp
+	case IPProtocolUDP:
+		udp := &UDP{SrcPort: UDPPort(sport), DstPort: UDPPort(dport)}
+		udp.SetNetworkLayerForChecksum(network)
+		transport = udp
+	case IPProtocolDCCP:
+		dccp := &DCCP{SrcPort: DCCPPort(sport), DstPort: DCCPPort(dport), Type: DCCPTypeDataAck, ExtendedSeq: true, SequenceNumber: 42, AckNumber: 7}
+		dccp.SetNetworkLayerForChecksum(network)
+		transport = dccp
+	case IPProtocolICMPv6:
+		icmp := &ICMPv6{TypeCode: CreateICMPv6TypeCode(ICMPv6TypeEchoRequest, 0)}
+		icmp.SetNetworkLayerForChecksum(network)
+		transport = icmp
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, append(ipLayers, transport, gopacket.Payload("some payload"))...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRewrite(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		v6             bool
+		proto          IPProtocol
+		src, dst, nsrc net.IP
+	}{
+		{"ipv4 tcp", false, IPProtocolTCP, net.IP{192, 0, 2, 1}, net.IP{198, 51, 100, 1}, net.IP{203, 0, 113, 200}},
+		{"ipv4 udp", false, IPProtocolUDP, net.IP{192, 0, 2, 1}, net.IP{198, 51, 100, 1}, net.IP{10, 255, 0, 7}},
+		{"ipv6 tcp", true, IPProtocolTCP, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8:ffff::9")},
+		{"ipv6 udp", true, IPProtocolUDP, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("fe80::1")},
+		{"ipv4 dccp", false, IPProtocolDCCP, net.IP{192, 0, 2, 1}, net.IP{198, 51, 100, 1}, net.IP{203, 0, 113, 9}},
+		{"ipv6 dccp", true, IPProtocolDCCP, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::5")},
+	} {
+		data := rewriteTestPacket(t, test.v6, test.proto, test.src, test.dst, 1234, 80, 64)
+		for _, err := range []error{
+			RewriteIPSrc(data, test.nsrc),
+			RewriteIPDst(data, test.src),
+			RewriteSrcPort(data, 40000),
+			RewriteDstPort(data, 8080),
+			DecrementTTL(data),
+		} {
+			if err != nil {
+				t.Fatal(test.name, err)
+			}
+		}
+		want := rewriteTestPacket(t, test.v6, test.proto, test.nsrc, test.src, 40000, 8080, 63)
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s: rewritten as\n%x, want\n%x", test.name, data, want)
+		}
+	}
+
+	// ICMPv6 checksums cover the addresses, and have no ports.
+	data := rewriteTestPacket(t, true, IPProtocolICMPv6, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 0, 0, 1)
+	if err := RewriteIPSrc(data, net.ParseIP("2001:db8::3")); err != nil {
+		t.Fatal(err)
+	}
+	if want := rewriteTestPacket(t, true, IPProtocolICMPv6, net.ParseIP("2001:db8::3"), net.ParseIP("2001:db8::2"), 0, 0, 1); !bytes.Equal(data, want) {
+		t.Errorf("ICMPv6 rewritten as\n%x, want\n%x", data, want)
+	}
+	if err := RewriteSrcPort(data, 1); err == nil {
+		t.Error("rewrote the port of an ICMPv6 packet")
+	}
+	if err := RewriteIPDst(data, net.IP{192, 0, 2, 1}); err == nil {
+		t.Error("rewrote an IPv6 address with an IPv4 one")
+	}
+	if err := DecrementTTL(data); err != nil || data[7] != 0 {
+		t.Errorf("hop limit %d, %v", data[7], err)
+	}
+	if err := DecrementTTL(data); err == nil {
+		t.Error("decremented a zero hop limit")
+	}
+
+	// UDP checksums which were not computed stay zero.
+	ip := &IPv4{Version: 4, Protocol: IPProtocolUDP, TTL: 64, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip, &UDP{SrcPort: 53, DstPort: 53}); err != nil {
+		t.Fatal(err)
+	}
+	data = buf.Bytes()
+	if err := RewriteIPSrc(data, net.IP{192, 0, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RewriteSrcPort(data, 5353); err != nil {
+		t.Fatal(err)
+	}
+	if data[26] != 0 || data[27] != 0 {
+		t.Errorf("UDP checksum %x, want zero", data[26:28])
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// RISTExtensionProfile is the RTP header extension profile ("RI") used by
+// the RIST Simple Profile (VSF TR-06-1) to carry the sequence number
+// extension.
+const RISTExtensionProfile uint16 = 0x5249
+
+// RISTSequenceNumber returns the 32 bit sequence number of a RIST packet,
+// built from the RTP sequence number and the RIST header extension. ok is
+// false if the packet has no RIST extension.
+func (r *RTP) RISTSequenceNumber() (seq uint32, ok bool) {
+	if !r.Extension || r.ExtensionProfile != RISTExtensionProfile || len(r.ExtensionData) < 4 {
+		return 0, false
+	}
+	ext := binary.BigEndian.Uint16(r.ExtensionData[2:4])
+	return uint32(ext)<<16 | uint32(r.SequenceNumber), true
+}
+
+// RISTNACKRange is a run of lost RTP packets, Start through Start+Extra,
+// requested for retransmission by a RIST receiver.
+type RISTNACKRange struct {
+	MediaSSRC uint32
+	Start     uint16
+	Extra     uint16
+}
+
+const (
+	rtcpTypeApp   = 204
+	rtcpTypeRTPFB = 205
+)
+
+// DecodeRISTNACKs extracts the retransmission requests of a compound RTCP
+// packet sent by a RIST receiver. Both bitmask NACKs (RFC 4585 Generic NACK)
+// and RIST range NACKs (APP packets named "RIST") are returned; other RTCP
+// packets are skipped.
+func DecodeRISTNACKs(data []byte) ([]RISTNACKRange, error) {
+	var out []RISTNACKRange
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return out, errors.New("RTCP header truncated")
+		}
+		if data[0]>>6 != 2 {
+			return out, fmt.Errorf("unsupported RTCP version %d", data[0]>>6)
+		}
+		fmtOrSubtype := data[0] & 0x1f
+		pt := data[1]
+		length := 4 * (int(binary.BigEndian.Uint16(data[2:4])) + 1)
+		if len(data) < length {
+			return out, errors.New("RTCP packet truncated")
+		}
+		pkt := data[:length]
+		data = data[length:]
+
+		switch {
+		case pt == rtcpTypeRTPFB && fmtOrSubtype == 1 && len(pkt) >= 12:
+			ssrc := binary.BigEndian.Uint32(pkt[8:12])
+			for fci := pkt[12:]; len(fci) >= 4; fci = fci[4:] {
+				pid := binary.BigEndian.Uint16(fci[0:2])
+				blp := binary.BigEndian.Uint16(fci[2:4])
+				out = appendRISTLost(out, ssrc, pid)
+				for i := uint16(0); i < 16; i++ {
+					if blp&(1<<i) != 0 {
+						out = appendRISTLost(out, ssrc, pid+i+1)
+					}
+				}
+			}
+		case pt == rtcpTypeApp && fmtOrSubtype == 0 && len(pkt) >= 12 && string(pkt[8:12]) == "RIST":
+			ssrc := binary.BigEndian.Uint32(pkt[4:8])
+			for fci := pkt[12:]; len(fci) >= 4; fci = fci[4:] {
+				out = append(out, RISTNACKRange{
+					MediaSSRC: ssrc,
+					Start:     binary.BigEndian.Uint16(fci[0:2]),
+					Extra:     binary.BigEndian.Uint16(fci[2:4]),
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
+// appendRISTLost adds seq to out, extending the last range if it directly
+// follows it.
+func appendRISTLost(out []RISTNACKRange, ssrc uint32, seq uint16) []RISTNACKRange {
+	if n := len(out); n > 0 {
+		last := &out[n-1]
+		if last.MediaSSRC == ssrc && last.Start+last.Extra+1 == seq {
+			last.Extra++
+			return out
+		}
+	}
+	return append(out, RISTNACKRange{MediaSSRC: ssrc, Start: seq})
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestRISTSequenceNumber(t *testing.T) {
+	data := []byte{
+		0x90, 0x21, 0xab, 0xcd, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+		0x52, 0x49, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03,
+	}
+	var r RTP
+	if err := r.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if seq, ok := r.RISTSequenceNumber(); !ok || seq != 0x3abcd {
+		t.Errorf("got sequence number %#x, %v", seq, ok)
+	}
+}
+
+func TestDecodeRISTNACKs(t *testing.T) {
+	data := []byte{
+		// Receiver report, no blocks.
+		0x80, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x09,
+		// Generic NACK: 100, 101, 102 and 116.
+		0x81, 0xcd, 0x00, 0x03, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x02,
+		0x00, 0x64, 0x80, 0x03,
+		// RIST range NACK: 200 plus 10 more.
+		0x80, 0xcc, 0x00, 0x03, 0x00, 0x00, 0x00, 0x02, 0x52, 0x49, 0x53, 0x54,
+		0x00, 0xc8, 0x00, 0x0a,
+	}
+	got, err := DecodeRISTNACKs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RISTNACKRange{
+		{MediaSSRC: 2, Start: 100, Extra: 2},
+		{MediaSSRC: 2, Start: 116},
+		{MediaSSRC: 2, Start: 200, Extra: 10},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
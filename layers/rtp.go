@@ -0,0 +1,124 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// RTPPayloadTypeMP2T is the static RTP payload type for MPEG-2 transport
+// streams (RFC 3551, RFC 2250).
+const RTPPayloadTypeMP2T uint8 = 33
+
+// RTP is the fixed header of a Real-time Transport Protocol (RFC 3550)
+// packet.
+//
+// RTP has no well-known port, so it is not decoded from UDP by default. Use
+// RegisterUDPPortLayerType to map the ports negotiated for a session to
+// LayerTypeRTP. Packets with payload type 33 are decoded further as MPEGTS;
+// other payload types are left as payload.
+type RTP struct {
+	BaseLayer
+	Version        uint8
+	Padding        bool
+	Extension      bool
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+	// ExtensionProfile and ExtensionData are only valid if Extension is set.
+	ExtensionProfile uint16
+	ExtensionData    []byte
+	// PaddingLength is the number of padding bytes removed from the end of
+	// the payload, including the count byte itself.
+	PaddingLength uint8
+}
+
+// LayerType returns LayerTypeRTP.
+func (r *RTP) LayerType() gopacket.LayerType { return LayerTypeRTP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RTP) CanDecode() gopacket.LayerClass { return LayerTypeRTP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (r *RTP) NextLayerType() gopacket.LayerType {
+	if r.PayloadType == RTPPayloadTypeMP2T {
+		return LayerTypeMPEGTS
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return errors.New("RTP header too short")
+	}
+	r.Version = data[0] >> 6
+	if r.Version != 2 {
+		return fmt.Errorf("unsupported RTP version %d", r.Version)
+	}
+	r.Padding = data[0]&0x20 != 0
+	r.Extension = data[0]&0x10 != 0
+	cc := int(data[0] & 0x0f)
+	r.Marker = data[1]&0x80 != 0
+	r.PayloadType = data[1] & 0x7f
+	r.SequenceNumber = binary.BigEndian.Uint16(data[2:4])
+	r.Timestamp = binary.BigEndian.Uint32(data[4:8])
+	r.SSRC = binary.BigEndian.Uint32(data[8:12])
+
+	offset := 12
+	if len(data) < offset+4*cc {
+		df.SetTruncated()
+		return errors.New("RTP CSRC list truncated")
+	}
+	r.CSRC = r.CSRC[:0]
+	for i := 0; i < cc; i++ {
+		r.CSRC = append(r.CSRC, binary.BigEndian.Uint32(data[offset:]))
+		offset += 4
+	}
+
+	r.ExtensionProfile, r.ExtensionData = 0, nil
+	if r.Extension {
+		if len(data) < offset+4 {
+			df.SetTruncated()
+			return errors.New("RTP header extension truncated")
+		}
+		r.ExtensionProfile = binary.BigEndian.Uint16(data[offset:])
+		extLen := 4 * int(binary.BigEndian.Uint16(data[offset+2:]))
+		offset += 4
+		if len(data) < offset+extLen {
+			df.SetTruncated()
+			return errors.New("RTP header extension truncated")
+		}
+		r.ExtensionData = data[offset : offset+extLen]
+		offset += extLen
+	}
+
+	end := len(data)
+	r.PaddingLength = 0
+	if r.Padding {
+		r.PaddingLength = data[end-1]
+		if r.PaddingLength == 0 || int(r.PaddingLength) > end-offset {
+			return fmt.Errorf("invalid RTP padding length %d", r.PaddingLength)
+		}
+		end -= int(r.PaddingLength)
+	}
+	r.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:end]}
+	return nil
+}
+
+func decodeRTP(data []byte, p gopacket.PacketBuilder) error {
+	r := &RTP{}
+	return decodingLayerDecoder(r, data, p)
+}
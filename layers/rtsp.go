@@ -0,0 +1,331 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+)
+
+// RTSP is a Real Time Streaming Protocol (RFC 2326, RFC 7826) message, or a
+// binary frame interleaved into the RTSP TCP connection.
+//
+// Requests have Method and RequestURI set, responses have IsResponse,
+// StatusCode and ReasonPhrase set. The message body, usually an SDP session
+// description, is the layer payload.
+//
+// Interleaved frames ('$', channel, 16 bit length, data; RFC 2326 section
+// 10.12) have Interleaved set and carry their data as payload. By the usual
+// convention even channels carry RTP and odd channels RTCP, so even channels
+// are decoded further as RTP.
+type RTSP struct {
+	BaseLayer
+
+	Interleaved bool
+	Channel     uint8
+	Length      uint16
+
+	Version string
+	Method  string
+	// RequestURI is the request target, e.g. rtsp://example.com/stream.
+	RequestURI   string
+	IsResponse   bool
+	StatusCode   int
+	ReasonPhrase string
+	// Headers maps lower-cased header names to their values.
+	Headers map[string][]string
+	CSeq    int
+
+	contentLength int
+}
+
+// LayerType returns LayerTypeRTSP.
+func (r *RTSP) LayerType() gopacket.LayerType { return LayerTypeRTSP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RTSP) CanDecode() gopacket.LayerClass { return LayerTypeRTSP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (r *RTSP) NextLayerType() gopacket.LayerType {
+	if r.Interleaved && r.Channel%2 == 0 {
+		return LayerTypeRTP
+	}
+	return gopacket.LayerTypePayload
+}
+
+// Payload returns the message body or interleaved frame data.
+func (r *RTSP) Payload() []byte { return r.BaseLayer.Payload }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RTSP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	*r = RTSP{Headers: r.Headers}
+	if r.Headers == nil {
+		r.Headers = make(map[string][]string)
+	} else {
+		for k := range r.Headers {
+			delete(r.Headers, k)
+		}
+	}
+	if len(data) > 0 && data[0] == '$' {
+		return r.decodeInterleaved(data, df)
+	}
+
+	offset := 0
+	firstLine := true
+	for {
+		idx := bytes.IndexByte(data[offset:], '\n')
+		if idx < 0 {
+			df.SetTruncated()
+			return errors.New("RTSP message headers truncated")
+		}
+		line := bytes.TrimRight(data[offset:offset+idx], "\r")
+		offset += idx + 1
+		if len(line) == 0 {
+			break
+		}
+		if firstLine {
+			if err := r.parseFirstLine(string(line)); err != nil {
+				return err
+			}
+			firstLine = false
+			continue
+		}
+		if err := r.parseHeader(string(line)); err != nil {
+			return err
+		}
+	}
+
+	end := offset + r.contentLength
+	if end > len(data) {
+		df.SetTruncated()
+		end = len(data)
+	}
+	r.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:end]}
+	return nil
+}
+
+func (r *RTSP) decodeInterleaved(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("RTSP interleaved frame header truncated")
+	}
+	r.Interleaved = true
+	r.Channel = data[1]
+	r.Length = binary.BigEndian.Uint16(data[2:4])
+	end := 4 + int(r.Length)
+	if end > len(data) {
+		df.SetTruncated()
+		end = len(data)
+	}
+	r.BaseLayer = BaseLayer{Contents: data[:4], Payload: data[4:end]}
+	return nil
+}
+
+func (r *RTSP) parseFirstLine(line string) error {
+	splits := strings.SplitN(line, " ", 3)
+	if len(splits) < 3 {
+		return fmt.Errorf("invalid first RTSP line: '%s'", line)
+	}
+	if strings.HasPrefix(splits[0], "RTSP/") {
+		r.IsResponse = true
+		r.Version = splits[0]
+		code, err := strconv.Atoi(splits[1])
+		if err != nil {
+			return fmt.Errorf("invalid RTSP status code: '%s'", splits[1])
+		}
+		r.StatusCode = code
+		r.ReasonPhrase = splits[2]
+		return nil
+	}
+	if !strings.HasPrefix(splits[2], "RTSP/") {
+		return fmt.Errorf("invalid RTSP version: '%s'", splits[2])
+	}
+	r.Method = splits[0]
+	r.RequestURI = splits[1]
+	r.Version = splits[2]
+	return nil
+}
+
+func (r *RTSP) parseHeader(line string) error {
+	idx := strings.IndexByte(line, ':')
+	if idx < 0 {
+		return fmt.Errorf("invalid RTSP header: '%s'", line)
+	}
+	name := strings.ToLower(strings.TrimSpace(line[:idx]))
+	value := strings.TrimSpace(line[idx+1:])
+	r.Headers[name] = append(r.Headers[name], value)
+
+	var err error
+	switch name {
+	case "cseq":
+		r.CSeq, err = strconv.Atoi(value)
+	case "content-length":
+		var cl int
+		cl, err = strconv.Atoi(value)
+		switch {
+		case err != nil:
+		case cl < 0:
+			err = errors.New("negative RTSP Content-Length")
+		case len(r.Headers[name]) > 1 && cl != r.contentLength:
+			// RFC 7230, section 3.3.2: repeated values must all agree.
+			err = errors.New("conflicting RTSP Content-Length headers")
+		default:
+			r.contentLength = cl
+		}
+	}
+	return err
+}
+
+// GetFirstHeader returns the first value of the named header, or "" if the
+// message does not carry it.
+func (r *RTSP) GetFirstHeader(name string) string {
+	if h := r.Headers[strings.ToLower(name)]; len(h) > 0 {
+		return h[0]
+	}
+	return ""
+}
+
+// Session returns the session identifier of the Session header, without
+// any timeout parameter.
+func (r *RTSP) Session() string {
+	s := r.GetFirstHeader("Session")
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// InterleavedChannels returns the channel pair negotiated through the
+// "interleaved=" parameter of the Transport header of a SETUP request or
+// response.
+func (r *RTSP) InterleavedChannels() (rtp, rtcp uint8, ok bool) {
+	for _, param := range strings.Split(r.GetFirstHeader("Transport"), ";") {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "interleaved=") {
+			continue
+		}
+		chans := strings.SplitN(param[len("interleaved="):], "-", 2)
+		a, err := strconv.ParseUint(chans[0], 10, 8)
+		if err != nil {
+			return 0, 0, false
+		}
+		b := a + 1
+		if len(chans) == 2 {
+			if b, err = strconv.ParseUint(chans[1], 10, 8); err != nil {
+				return 0, 0, false
+			}
+		}
+		return uint8(a), uint8(b), true
+	}
+	return 0, 0, false
+}
+
+// SplitRTSPStream splits a chunk of a reassembled RTSP TCP stream into
+// complete messages and interleaved frames. Each returned slice can be
+// decoded with RTSP.DecodeFromBytes. Any trailing incomplete unit is
+// returned as rest so it can be prepended to the next chunk. A message with
+// an invalid or conflicting Content-Length is returned without its body,
+// and fails to decode.
+func SplitRTSPStream(data []byte) (units [][]byte, rest []byte) {
+	for len(data) > 0 {
+		n := rtspUnitLength(data)
+		if n <= 0 {
+			break
+		}
+		units = append(units, data[:n])
+		data = data[n:]
+	}
+	return units, data
+}
+
+// rtspUnitLength returns the length of the message or interleaved frame at
+// the start of data, or 0 if it is incomplete. Messages with an invalid
+// Content-Length, or with conflicting ones, are taken to end with their
+// headers.
+func rtspUnitLength(data []byte) int {
+	if data[0] == '$' {
+		if len(data) < 4 {
+			return 0
+		}
+		n := 4 + int(binary.BigEndian.Uint16(data[2:4]))
+		if n > len(data) {
+			return 0
+		}
+		return n
+	}
+	hdrEnd := bytes.Index(data, []byte("\r\n\r\n"))
+	sepLen := 4
+	if lf := bytes.Index(data, []byte("\n\n")); lf >= 0 && (hdrEnd < 0 || lf < hdrEnd) {
+		hdrEnd, sepLen = lf, 2
+	}
+	if hdrEnd < 0 {
+		return 0
+	}
+	cl := -1
+	for _, line := range bytes.Split(data[:hdrEnd], []byte("\n")) {
+		idx := bytes.IndexByte(line, ':')
+		if idx < 0 || !strings.EqualFold(string(bytes.TrimSpace(line[:idx])), "content-length") {
+			continue
+		}
+		v, err := strconv.Atoi(string(bytes.TrimSpace(line[idx+1:])))
+		if err != nil || v < 0 || (cl >= 0 && v != cl) {
+			// The length of the body is unknown, so waiting for more
+			// data wouldn't help.
+			return hdrEnd + sepLen
+		}
+		cl = v
+	}
+	n := hdrEnd + sepLen
+	if cl > 0 {
+		n += cl
+	}
+	if n > len(data) {
+		return 0
+	}
+	return n
+}
+
+// rtspStartsUnit reports whether data starts with an interleaved frame, or
+// with the first line of a request or a response. Other TCP segments of an
+// RTSP connection, like those continuing a message, are left as payload.
+func rtspStartsUnit(data []byte) bool {
+	if len(data) > 0 && data[0] == '$' {
+		return true
+	}
+	line := data
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if bytes.HasPrefix(line, []byte("RTSP/")) {
+		return true
+	}
+	// Method SP Request-URI SP RTSP-Version
+	i := bytes.IndexByte(line, ' ')
+	return i > 0 && bytes.Contains(line[i+1:], []byte(" RTSP/"))
+}
+
+func decodeRTSP(data []byte, p gopacket.PacketBuilder) error {
+	if !rtspStartsUnit(data) {
+		return p.NextDecoder(gopacket.LayerTypePayload)
+	}
+	r := &RTSP{}
+	if err := r.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(r)
+	if r.Interleaved {
+		return p.NextDecoder(r.NextLayerType())
+	}
+	p.SetApplicationLayer(r)
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+var testRTSPSetupResponse = []byte("RTSP/1.0 200 OK\r\n" +
+	"CSeq: 3\r\n" +
+	"Session: 12345678;timeout=60\r\n" +
+	"Transport: RTP/AVP/TCP;unicast;interleaved=2-3\r\n" +
+	"Content-Length: 4\r\n" +
+	"\r\n" +
+	"v=0\n")
+
+func TestRTSPResponse(t *testing.T) {
+	p := gopacket.NewPacket(testRTSPSetupResponse, LayerTypeRTSP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeRTSP}, t)
+	r := p.ApplicationLayer().(*RTSP)
+	if !r.IsResponse || r.StatusCode != 200 || r.ReasonPhrase != "OK" || r.Version != "RTSP/1.0" {
+		t.Errorf("bad status line: %+v", r)
+	}
+	if r.CSeq != 3 {
+		t.Errorf("got CSeq %d", r.CSeq)
+	}
+	if s := r.Session(); s != "12345678" {
+		t.Errorf("got session %q", s)
+	}
+	if rtp, rtcp, ok := r.InterleavedChannels(); !ok || rtp != 2 || rtcp != 3 {
+		t.Errorf("got interleaved channels %d-%d %v", rtp, rtcp, ok)
+	}
+	if string(r.Payload()) != "v=0\n" {
+		t.Errorf("got body %q", r.Payload())
+	}
+}
+
+func TestRTSPRequest(t *testing.T) {
+	var r RTSP
+	data := []byte("PLAY rtsp://example.com/live RTSP/1.0\r\nCSeq: 4\r\nsession: abc\r\n\r\n")
+	if err := r.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsResponse || r.Method != "PLAY" || r.RequestURI != "rtsp://example.com/live" || r.CSeq != 4 {
+		t.Errorf("bad request: %+v", r)
+	}
+	if r.Session() != "abc" || len(r.Payload()) != 0 {
+		t.Errorf("got session %q, body %q", r.Session(), r.Payload())
+	}
+	if err := r.DecodeFromBytes([]byte("PLAY rtsp://x HTTP/1.1\r\n\r\n"), gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded request with non-RTSP version")
+	}
+}
+
+func TestRTSPInterleavedRTP(t *testing.T) {
+	rtp := []byte{0x80, 0xe0, 0x00, 0x07, 0x00, 0x00, 0x10, 0x00, 0xde, 0xad, 0xbe, 0xef, 0x01, 0x02, 0x03}
+	frame := append([]byte{'$', 0x02, 0x00, byte(len(rtp))}, rtp...)
+	p := gopacket.NewPacket(frame, LayerTypeRTSP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeRTSP, LayerTypeRTP, gopacket.LayerTypePayload}, t)
+	r := p.Layer(LayerTypeRTSP).(*RTSP)
+	if !r.Interleaved || r.Channel != 2 || r.Length != uint16(len(rtp)) {
+		t.Errorf("bad interleaved header: %+v", r)
+	}
+	h := p.Layer(LayerTypeRTP).(*RTP)
+	if !h.Marker || h.PayloadType != 96 || h.SequenceNumber != 7 || h.Timestamp != 0x1000 || h.SSRC != 0xdeadbeef {
+		t.Errorf("bad RTP header: %+v", h)
+	}
+	if !bytes.Equal(h.Payload, []byte{1, 2, 3}) {
+		t.Errorf("got RTP payload %x", h.Payload)
+	}
+}
+
+func TestSplitRTSPStream(t *testing.T) {
+	stream := append([]byte{}, testRTSPSetupResponse...)
+	stream = append(stream, '$', 0x01, 0x00, 0x02, 0xaa, 0xbb)
+	stream = append(stream, []byte("OPTIONS * RTSP/1.0\r\nCSeq: 5\r\n")...)
+	units, rest := SplitRTSPStream(stream)
+	if len(units) != 2 {
+		t.Fatalf("got %d units, want 2", len(units))
+	}
+	if !bytes.Equal(units[0], testRTSPSetupResponse) || !bytes.Equal(units[1], []byte{'$', 0x01, 0x00, 0x02, 0xaa, 0xbb}) {
+		t.Errorf("bad units %q", units)
+	}
+	if string(rest) != "OPTIONS * RTSP/1.0\r\nCSeq: 5\r\n" {
+		t.Errorf("got rest %q", rest)
+	}
+}
+
+func TestRTSPContinuationSegment(t *testing.T) {
+	tcp := &TCP{SrcPort: 554, DstPort: 40000, DataOffset: 5}
+	for _, data := range []string{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\n", "ion: 12345678\r\n\r\n"} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, tcp, gopacket.Payload(data)); err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LayerTypeTCP, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%q: %v", data, p.ErrorLayer().Error())
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeTCP, gopacket.LayerTypePayload}, t)
+	}
+}
+
+func TestSplitRTSPStreamInvalidContentLength(t *testing.T) {
+	bad := "ANNOUNCE rtsp://x RTSP/1.0\r\nContent-Length: -1\r\n\r\n"
+	units, rest := SplitRTSPStream([]byte(bad + "body"))
+	if len(units) != 1 || string(units[0]) != bad || string(rest) != "body" {
+		t.Fatalf("got units %q, rest %q", units, rest)
+	}
+	var r RTSP
+	if err := r.DecodeFromBytes(units[0], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded message with invalid Content-Length")
+	}
+}
+
+func TestRTSPRepeatedContentLength(t *testing.T) {
+	same := "ANNOUNCE rtsp://x RTSP/1.0\r\nContent-Length: 4\r\ncontent-length: 4\r\n\r\nbody"
+	units, rest := SplitRTSPStream([]byte(same + "OPTIONS"))
+	if len(units) != 1 || string(units[0]) != same || string(rest) != "OPTIONS" {
+		t.Fatalf("got units %q, rest %q", units, rest)
+	}
+	var r RTSP
+	if err := r.DecodeFromBytes(units[0], gopacket.NilDecodeFeedback); err != nil || string(r.Payload()) != "body" {
+		t.Errorf("payload %q, %v", r.Payload(), err)
+	}
+
+	conflicting := "ANNOUNCE rtsp://x RTSP/1.0\r\nContent-Length: 2\r\nContent-Length: 2\r\nContent-Length: 4\r\n\r\n"
+	units, rest = SplitRTSPStream([]byte(conflicting + "body"))
+	if len(units) != 1 || string(units[0]) != conflicting || string(rest) != "body" {
+		t.Fatalf("got units %q, rest %q", units, rest)
+	}
+	if err := r.DecodeFromBytes([]byte(conflicting+"body"), gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded message with conflicting Content-Length headers")
+	}
+}
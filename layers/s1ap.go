@@ -0,0 +1,499 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// S1AP (3GPP TS 36.413) and NGAP (3GPP TS 38.413) share the structure of
+// their PDUs, encoded with the ASN.1 aligned packed encoding rules: a choice
+// of an initiating message, a successful outcome or an unsuccessful outcome,
+// identified by a procedure code, carrying a container of protocol IEs. Only
+// that structure is decoded, and the IEs needed to follow UEs and the NAS
+// messages they exchange.
+
+// APMessageType is the kind of an S1AP or NGAP PDU.
+type APMessageType uint8
+
+// S1AP and NGAP PDU kinds.
+const (
+	APMessageTypeInitiatingMessage   APMessageType = 0
+	APMessageTypeSuccessfulOutcome   APMessageType = 1
+	APMessageTypeUnsuccessfulOutcome APMessageType = 2
+)
+
+func (t APMessageType) String() string {
+	switch t {
+	case APMessageTypeInitiatingMessage:
+		return "InitiatingMessage"
+	case APMessageTypeSuccessfulOutcome:
+		return "SuccessfulOutcome"
+	case APMessageTypeUnsuccessfulOutcome:
+		return "UnsuccessfulOutcome"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// APCriticality tells the receiver of an S1AP or NGAP procedure or IE how
+// to react if it does not understand it.
+type APCriticality uint8
+
+// S1AP and NGAP criticalities.
+const (
+	APCriticalityReject APCriticality = 0
+	APCriticalityIgnore APCriticality = 1
+	APCriticalityNotify APCriticality = 2
+)
+
+func (c APCriticality) String() string {
+	switch c {
+	case APCriticalityReject:
+		return "Reject"
+	case APCriticalityIgnore:
+		return "Ignore"
+	case APCriticalityNotify:
+		return "Notify"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// APProtocolIE is an information element of an S1AP or NGAP message. Value
+// is still encoded with the aligned packed encoding rules.
+type APProtocolIE struct {
+	ID          uint16
+	Criticality APCriticality
+	Value       []byte
+}
+
+// aperLength decodes an aligned PER length determinant, returning the
+// length and the number of bytes it used. Fragmented lengths, of 16K and
+// more, are not supported.
+func aperLength(data []byte) (int, int, error) {
+	if len(data) < 1 {
+		return 0, 0, errors.New("PER length determinant missing")
+	}
+	switch {
+	case data[0]&0x80 == 0:
+		return int(data[0]), 1, nil
+	case data[0]&0xc0 == 0x80:
+		if len(data) < 2 {
+			return 0, 0, errors.New("PER length determinant too short")
+		}
+		return int(binary.BigEndian.Uint16(data) & 0x3fff), 2, nil
+	}
+	return 0, 0, errors.New("fragmented PER length not supported")
+}
+
+// aperOpenType decodes an aligned PER open type, returning its contents and
+// the remaining data.
+func aperOpenType(data []byte) ([]byte, []byte, error) {
+	length, n, err := aperLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < n+length {
+		return nil, nil, errors.New("PER open type too short")
+	}
+	return data[n : n+length], data[n+length:], nil
+}
+
+// aperUint decodes an aligned PER constrained whole number with a range
+// larger than 64K, whose octet length takes lengthBits bits.
+func aperUint(data []byte, lengthBits uint) (uint64, bool) {
+	if len(data) < 1 {
+		return 0, false
+	}
+	n := int(data[0]>>(8-lengthBits)) + 1
+	if len(data) != n+1 {
+		return 0, false
+	}
+	var v uint64
+	for _, b := range data[1:] {
+		v = v<<8 | uint64(b)
+	}
+	return v, true
+}
+
+// aperOctetString decodes an aligned PER OCTET STRING without size
+// constraint.
+func aperOctetString(data []byte) ([]byte, bool) {
+	s, rest, err := aperOpenType(data)
+	if err != nil || len(rest) != 0 {
+		return nil, false
+	}
+	return s, true
+}
+
+// decodeAPPDU decodes the top level of an S1AP or NGAP PDU.
+func decodeAPPDU(data []byte, ies []APProtocolIE) (APMessageType, uint8, APCriticality, []APProtocolIE, error) {
+	if len(data) < 3 {
+		return 0, 0, 0, nil, errors.New("PDU too short")
+	}
+	if data[0]&0x80 != 0 {
+		return 0, 0, 0, nil, errors.New("PDU uses an unknown extension")
+	}
+	t := APMessageType(data[0] >> 5 & 0x3)
+	if t > APMessageTypeUnsuccessfulOutcome {
+		return 0, 0, 0, nil, fmt.Errorf("invalid PDU type %d", t)
+	}
+	code := data[1]
+	crit := APCriticality(data[2] >> 6)
+	value, _, err := aperOpenType(data[3:])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	// The message is an extensible sequence of a protocol IE container,
+	// with at most 65535 IEs.
+	if len(value) < 3 {
+		return 0, 0, 0, nil, errors.New("message too short")
+	}
+	count := int(binary.BigEndian.Uint16(value[1:3]))
+	value = value[3:]
+	ies = ies[:0]
+	for i := 0; i < count; i++ {
+		if len(value) < 3 {
+			return 0, 0, 0, nil, errors.New("protocol IE too short")
+		}
+		ie := APProtocolIE{
+			ID:          binary.BigEndian.Uint16(value),
+			Criticality: APCriticality(value[2] >> 6),
+		}
+		if ie.Value, value, err = aperOpenType(value[3:]); err != nil {
+			return 0, 0, 0, nil, fmt.Errorf("protocol IE %d: %v", ie.ID, err)
+		}
+		ies = append(ies, ie)
+	}
+	return t, code, crit, ies, nil
+}
+
+func findAPProtocolIE(ies []APProtocolIE, id uint16) []byte {
+	for _, ie := range ies {
+		if ie.ID == id {
+			return ie.Value
+		}
+	}
+	return nil
+}
+
+// S1APProcedureCode identifies an S1AP elementary procedure.
+type S1APProcedureCode uint8
+
+// S1AP procedure codes, from 3GPP TS 36.413.
+const (
+	S1APProcedureCodeHandoverPreparation        S1APProcedureCode = 0
+	S1APProcedureCodeHandoverResourceAllocation S1APProcedureCode = 1
+	S1APProcedureCodeHandoverNotification       S1APProcedureCode = 2
+	S1APProcedureCodePathSwitchRequest          S1APProcedureCode = 3
+	S1APProcedureCodeHandoverCancel             S1APProcedureCode = 4
+	S1APProcedureCodeERABSetup                  S1APProcedureCode = 5
+	S1APProcedureCodeERABModify                 S1APProcedureCode = 6
+	S1APProcedureCodeERABRelease                S1APProcedureCode = 7
+	S1APProcedureCodeERABReleaseIndication      S1APProcedureCode = 8
+	S1APProcedureCodeInitialContextSetup        S1APProcedureCode = 9
+	S1APProcedureCodePaging                     S1APProcedureCode = 10
+	S1APProcedureCodeDownlinkNASTransport       S1APProcedureCode = 11
+	S1APProcedureCodeInitialUEMessage           S1APProcedureCode = 12
+	S1APProcedureCodeUplinkNASTransport         S1APProcedureCode = 13
+	S1APProcedureCodeReset                      S1APProcedureCode = 14
+	S1APProcedureCodeErrorIndication            S1APProcedureCode = 15
+	S1APProcedureCodeNASNonDeliveryIndication   S1APProcedureCode = 16
+	S1APProcedureCodeS1Setup                    S1APProcedureCode = 17
+	S1APProcedureCodeUEContextReleaseRequest    S1APProcedureCode = 18
+	S1APProcedureCodeUEContextModification      S1APProcedureCode = 21
+	S1APProcedureCodeUECapabilityInfoIndication S1APProcedureCode = 22
+	S1APProcedureCodeUEContextRelease           S1APProcedureCode = 23
+	S1APProcedureCodeENBConfigurationUpdate     S1APProcedureCode = 29
+	S1APProcedureCodeMMEConfigurationUpdate     S1APProcedureCode = 30
+	S1APProcedureCodeOverloadStart              S1APProcedureCode = 34
+	S1APProcedureCodeOverloadStop               S1APProcedureCode = 35
+)
+
+func (c S1APProcedureCode) String() string {
+	switch c {
+	case S1APProcedureCodeHandoverPreparation:
+		return "HandoverPreparation"
+	case S1APProcedureCodeHandoverResourceAllocation:
+		return "HandoverResourceAllocation"
+	case S1APProcedureCodeHandoverNotification:
+		return "HandoverNotification"
+	case S1APProcedureCodePathSwitchRequest:
+		return "PathSwitchRequest"
+	case S1APProcedureCodeHandoverCancel:
+		return "HandoverCancel"
+	case S1APProcedureCodeERABSetup:
+		return "E-RABSetup"
+	case S1APProcedureCodeERABModify:
+		return "E-RABModify"
+	case S1APProcedureCodeERABRelease:
+		return "E-RABRelease"
+	case S1APProcedureCodeERABReleaseIndication:
+		return "E-RABReleaseIndication"
+	case S1APProcedureCodeInitialContextSetup:
+		return "InitialContextSetup"
+	case S1APProcedureCodePaging:
+		return "Paging"
+	case S1APProcedureCodeDownlinkNASTransport:
+		return "DownlinkNASTransport"
+	case S1APProcedureCodeInitialUEMessage:
+		return "InitialUEMessage"
+	case S1APProcedureCodeUplinkNASTransport:
+		return "UplinkNASTransport"
+	case S1APProcedureCodeReset:
+		return "Reset"
+	case S1APProcedureCodeErrorIndication:
+		return "ErrorIndication"
+	case S1APProcedureCodeNASNonDeliveryIndication:
+		return "NASNonDeliveryIndication"
+	case S1APProcedureCodeS1Setup:
+		return "S1Setup"
+	case S1APProcedureCodeUEContextReleaseRequest:
+		return "UEContextReleaseRequest"
+	case S1APProcedureCodeUEContextModification:
+		return "UEContextModification"
+	case S1APProcedureCodeUECapabilityInfoIndication:
+		return "UECapabilityInfoIndication"
+	case S1APProcedureCodeUEContextRelease:
+		return "UEContextRelease"
+	case S1APProcedureCodeENBConfigurationUpdate:
+		return "ENBConfigurationUpdate"
+	case S1APProcedureCodeMMEConfigurationUpdate:
+		return "MMEConfigurationUpdate"
+	case S1APProcedureCodeOverloadStart:
+		return "OverloadStart"
+	case S1APProcedureCodeOverloadStop:
+		return "OverloadStop"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// S1AP protocol IE identifiers, from 3GPP TS 36.413.
+const (
+	S1APProtocolIEMMEUES1APID uint16 = 0
+	S1APProtocolIECause       uint16 = 2
+	S1APProtocolIEENBUES1APID uint16 = 8
+	S1APProtocolIENASPDU      uint16 = 26
+	S1APProtocolIETAI         uint16 = 67
+	S1APProtocolIEEUTRANCGI   uint16 = 100
+)
+
+// S1AP is an S1 Application Protocol PDU, exchanged between eNodeBs and
+// MMEs over SCTP. Its payload is the NAS-PDU IE, if it has one.
+type S1AP struct {
+	BaseLayer
+	MessageType   APMessageType
+	ProcedureCode S1APProcedureCode
+	Criticality   APCriticality
+	IEs           []APProtocolIE
+}
+
+// LayerType returns LayerTypeS1AP.
+func (s *S1AP) LayerType() gopacket.LayerType { return LayerTypeS1AP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *S1AP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	t, code, crit, ies, err := decodeAPPDU(data, s.IEs)
+	if err != nil {
+		return fmt.Errorf("S1AP %v", err)
+	}
+	s.MessageType, s.ProcedureCode, s.Criticality, s.IEs = t, S1APProcedureCode(code), crit, ies
+	s.BaseLayer = BaseLayer{Contents: data}
+	s.Payload, _ = s.NASPDU()
+	return nil
+}
+
+// MMEUES1APID returns the MME-UE-S1AP-ID IE, identifying the UE in the MME.
+func (s *S1AP) MMEUES1APID() (uint32, bool) {
+	v, ok := aperUint(findAPProtocolIE(s.IEs, S1APProtocolIEMMEUES1APID), 2)
+	return uint32(v), ok
+}
+
+// ENBUES1APID returns the eNB-UE-S1AP-ID IE, identifying the UE in the
+// eNodeB.
+func (s *S1AP) ENBUES1APID() (uint32, bool) {
+	v, ok := aperUint(findAPProtocolIE(s.IEs, S1APProtocolIEENBUES1APID), 2)
+	return uint32(v), ok
+}
+
+// NASPDU returns the NAS message carried by the NAS-PDU IE.
+func (s *S1AP) NASPDU() ([]byte, bool) {
+	return aperOctetString(findAPProtocolIE(s.IEs, S1APProtocolIENASPDU))
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *S1AP) CanDecode() gopacket.LayerClass {
+	return LayerTypeS1AP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *S1AP) NextLayerType() gopacket.LayerType {
+	if len(s.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return LayerTypeNASEPS
+}
+
+func decodeS1AP(data []byte, p gopacket.PacketBuilder) error {
+	s := &S1AP{}
+	return decodingLayerDecoder(s, data, p)
+}
+
+// NGAPProcedureCode identifies an NGAP elementary procedure.
+type NGAPProcedureCode uint8
+
+// NGAP procedure codes, from 3GPP TS 38.413.
+const (
+	NGAPProcedureCodeAMFConfigurationUpdate          NGAPProcedureCode = 0
+	NGAPProcedureCodeDownlinkNASTransport            NGAPProcedureCode = 4
+	NGAPProcedureCodeErrorIndication                 NGAPProcedureCode = 9
+	NGAPProcedureCodeHandoverCancel                  NGAPProcedureCode = 10
+	NGAPProcedureCodeHandoverNotification            NGAPProcedureCode = 11
+	NGAPProcedureCodeHandoverPreparation             NGAPProcedureCode = 12
+	NGAPProcedureCodeHandoverResourceAllocation      NGAPProcedureCode = 13
+	NGAPProcedureCodeInitialContextSetup             NGAPProcedureCode = 14
+	NGAPProcedureCodeInitialUEMessage                NGAPProcedureCode = 15
+	NGAPProcedureCodeNASNonDeliveryIndication        NGAPProcedureCode = 19
+	NGAPProcedureCodeNGReset                         NGAPProcedureCode = 20
+	NGAPProcedureCodeNGSetup                         NGAPProcedureCode = 21
+	NGAPProcedureCodePaging                          NGAPProcedureCode = 24
+	NGAPProcedureCodePathSwitchRequest               NGAPProcedureCode = 25
+	NGAPProcedureCodePDUSessionResourceModify        NGAPProcedureCode = 26
+	NGAPProcedureCodePDUSessionResourceRelease       NGAPProcedureCode = 28
+	NGAPProcedureCodePDUSessionResourceSetup         NGAPProcedureCode = 29
+	NGAPProcedureCodeRANConfigurationUpdate          NGAPProcedureCode = 35
+	NGAPProcedureCodeUEContextModification           NGAPProcedureCode = 40
+	NGAPProcedureCodeUEContextRelease                NGAPProcedureCode = 41
+	NGAPProcedureCodeUEContextReleaseRequest         NGAPProcedureCode = 42
+	NGAPProcedureCodeUERadioCapabilityInfoIndication NGAPProcedureCode = 44
+	NGAPProcedureCodeUplinkNASTransport              NGAPProcedureCode = 46
+)
+
+func (c NGAPProcedureCode) String() string {
+	switch c {
+	case NGAPProcedureCodeAMFConfigurationUpdate:
+		return "AMFConfigurationUpdate"
+	case NGAPProcedureCodeDownlinkNASTransport:
+		return "DownlinkNASTransport"
+	case NGAPProcedureCodeErrorIndication:
+		return "ErrorIndication"
+	case NGAPProcedureCodeHandoverCancel:
+		return "HandoverCancel"
+	case NGAPProcedureCodeHandoverNotification:
+		return "HandoverNotification"
+	case NGAPProcedureCodeHandoverPreparation:
+		return "HandoverPreparation"
+	case NGAPProcedureCodeHandoverResourceAllocation:
+		return "HandoverResourceAllocation"
+	case NGAPProcedureCodeInitialContextSetup:
+		return "InitialContextSetup"
+	case NGAPProcedureCodeInitialUEMessage:
+		return "InitialUEMessage"
+	case NGAPProcedureCodeNASNonDeliveryIndication:
+		return "NASNonDeliveryIndication"
+	case NGAPProcedureCodeNGReset:
+		return "NGReset"
+	case NGAPProcedureCodeNGSetup:
+		return "NGSetup"
+	case NGAPProcedureCodePaging:
+		return "Paging"
+	case NGAPProcedureCodePathSwitchRequest:
+		return "PathSwitchRequest"
+	case NGAPProcedureCodePDUSessionResourceModify:
+		return "PDUSessionResourceModify"
+	case NGAPProcedureCodePDUSessionResourceRelease:
+		return "PDUSessionResourceRelease"
+	case NGAPProcedureCodePDUSessionResourceSetup:
+		return "PDUSessionResourceSetup"
+	case NGAPProcedureCodeRANConfigurationUpdate:
+		return "RANConfigurationUpdate"
+	case NGAPProcedureCodeUEContextModification:
+		return "UEContextModification"
+	case NGAPProcedureCodeUEContextRelease:
+		return "UEContextRelease"
+	case NGAPProcedureCodeUEContextReleaseRequest:
+		return "UEContextReleaseRequest"
+	case NGAPProcedureCodeUERadioCapabilityInfoIndication:
+		return "UERadioCapabilityInfoIndication"
+	case NGAPProcedureCodeUplinkNASTransport:
+		return "UplinkNASTransport"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// NGAP protocol IE identifiers, from 3GPP TS 38.413.
+const (
+	NGAPProtocolIEAMFUENGAPID      uint16 = 10
+	NGAPProtocolIECause            uint16 = 15
+	NGAPProtocolIENASPDU           uint16 = 38
+	NGAPProtocolIERANUENGAPID      uint16 = 85
+	NGAPProtocolIEUserLocationInfo uint16 = 121
+)
+
+// NGAP is an NG Application Protocol PDU, exchanged between gNodeBs and
+// AMFs over SCTP. Its payload is the NAS-PDU IE, if it has one.
+type NGAP struct {
+	BaseLayer
+	MessageType   APMessageType
+	ProcedureCode NGAPProcedureCode
+	Criticality   APCriticality
+	IEs           []APProtocolIE
+}
+
+// LayerType returns LayerTypeNGAP.
+func (n *NGAP) LayerType() gopacket.LayerType { return LayerTypeNGAP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NGAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	t, code, crit, ies, err := decodeAPPDU(data, n.IEs)
+	if err != nil {
+		return fmt.Errorf("NGAP %v", err)
+	}
+	n.MessageType, n.ProcedureCode, n.Criticality, n.IEs = t, NGAPProcedureCode(code), crit, ies
+	n.BaseLayer = BaseLayer{Contents: data}
+	n.Payload, _ = n.NASPDU()
+	return nil
+}
+
+// AMFUENGAPID returns the AMF-UE-NGAP-ID IE, identifying the UE in the AMF.
+func (n *NGAP) AMFUENGAPID() (uint64, bool) {
+	return aperUint(findAPProtocolIE(n.IEs, NGAPProtocolIEAMFUENGAPID), 3)
+}
+
+// RANUENGAPID returns the RAN-UE-NGAP-ID IE, identifying the UE in the
+// gNodeB.
+func (n *NGAP) RANUENGAPID() (uint32, bool) {
+	v, ok := aperUint(findAPProtocolIE(n.IEs, NGAPProtocolIERANUENGAPID), 2)
+	return uint32(v), ok
+}
+
+// NASPDU returns the NAS message carried by the NAS-PDU IE.
+func (n *NGAP) NASPDU() ([]byte, bool) {
+	return aperOctetString(findAPProtocolIE(n.IEs, NGAPProtocolIENASPDU))
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *NGAP) CanDecode() gopacket.LayerClass {
+	return LayerTypeNGAP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (n *NGAP) NextLayerType() gopacket.LayerType {
+	if len(n.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return LayerTypeNAS5GS
+}
+
+func decodeNGAP(data []byte, p gopacket.PacketBuilder) error {
+	n := &NGAP{}
+	return decodingLayerDecoder(n, data, p)
+}
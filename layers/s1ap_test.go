@@ -0,0 +1,134 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// sctpDataPacket returns an SCTP packet with a single DATA chunk carrying
+// data with the payload protocol ppid, followed by a SACK chunk.
+func sctpDataPacket(ppid SCTPPayloadProtocol, data []byte) []byte {
+	b := []byte{
+		0x8c, 0xbc, 0x8c, 0xbc, // ports 36412
+		0x00, 0x00, 0x00, 0x01, // verification tag
+		0x00, 0x00, 0x00, 0x00, // checksum
+		0x00, 0x03, 0x00, 0x00, // DATA, length
+		0x00, 0x00, 0x00, 0x01, // TSN
+		0x00, 0x01, 0x00, 0x00, // stream
+		0x00, 0x00, 0x00, 0x00, // payload protocol
+	}
+	binary.BigEndian.PutUint16(b[14:], uint16(16+len(data)))
+	binary.BigEndian.PutUint32(b[24:], uint32(ppid))
+	b = append(b, data...)
+	b = append(b, lotsOfZeros[:roundUpToNearest4(len(b))-len(b)]...)
+	return append(b, 0x03, 0x00, 0x00, 0x10, 0, 0, 0, 1, 0, 0, 0x10, 0, 0, 0, 0, 0)
+}
+
+// An InitialUEMessage with an eNB-UE-S1AP-ID of 1 and an attach request.
+var testS1APInitialUEMessage = []byte{
+	0x00, 0x0c, 0x40, 0x19, // initiating message, procedure 12, ignore
+	0x00, 0x00, 0x02, // 2 IEs
+	0x00, 0x08, 0x00, 0x02, 0x00, 0x01, // eNB-UE-S1AP-ID
+	0x00, 0x1a, 0x00, 0x0c, 0x0b, // NAS-PDU
+	0x07, 0x41, 0x71, 0x08, 0x09, 0x10, 0x10, 0x32, 0x54, 0x76, 0x98,
+}
+
+func TestS1APInitialUEMessage(t *testing.T) {
+	p := gopacket.NewPacket(sctpDataPacket(SCTPPayloadS1AP, testS1APInitialUEMessage), LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSCTP, LayerTypeSCTPData, LayerTypeS1AP, LayerTypeNASEPS}, t)
+
+	s := p.Layer(LayerTypeS1AP).(*S1AP)
+	if s.MessageType != APMessageTypeInitiatingMessage || s.ProcedureCode != S1APProcedureCodeInitialUEMessage || s.Criticality != APCriticalityIgnore {
+		t.Errorf("got %v %v %v, want InitiatingMessage InitialUEMessage Ignore", s.MessageType, s.ProcedureCode, s.Criticality)
+	}
+	if len(s.IEs) != 2 || s.IEs[0].ID != S1APProtocolIEENBUES1APID || s.IEs[1].ID != S1APProtocolIENASPDU {
+		t.Fatalf("got IEs %+v", s.IEs)
+	}
+	if id, ok := s.ENBUES1APID(); !ok || id != 1 {
+		t.Errorf("got eNB-UE-S1AP-ID %d %v, want 1", id, ok)
+	}
+	if _, ok := s.MMEUES1APID(); ok {
+		t.Error("got an MME-UE-S1AP-ID")
+	}
+	nas := p.Layer(LayerTypeNASEPS).(*NASEPS)
+	if nas.ProtocolDiscriminator != NASProtocolDiscriminatorEMM || nas.SecurityHeaderType != NASSecurityHeaderTypePlain || nas.MessageType != NASEPSMessageTypeAttachRequest {
+		t.Errorf("got NAS %v %v %v, want EMM Plain AttachRequest", nas.ProtocolDiscriminator, nas.SecurityHeaderType, nas.MessageType)
+	}
+	if want := testS1APInitialUEMessage[20:]; !bytes.Equal(nas.Payload, want) {
+		t.Errorf("got NAS payload %x, want %x", nas.Payload, want)
+	}
+}
+
+func TestS1APFragment(t *testing.T) {
+	data := sctpDataPacket(SCTPPayloadS1AP, testS1APInitialUEMessage[:16])
+	data[13] = 0x02 // first fragment only
+	p := gopacket.NewPacket(data, LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSCTP, LayerTypeSCTPData, gopacket.LayerTypePayload}, t)
+}
+
+func TestS1APTruncated(t *testing.T) {
+	data := testS1APInitialUEMessage[:len(testS1APInitialUEMessage)-1]
+	var s S1AP
+	if err := s.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded a truncated S1AP PDU")
+	}
+}
+
+// A DownlinkNASTransport with an AMF-UE-NGAP-ID of 0x100000000 and a
+// RAN-UE-NGAP-ID of 0x2a, carrying an integrity protected authentication
+// request.
+var testNGAPDownlinkNASTransport = []byte{
+	0x00, 0x04, 0x40, 0x22, // initiating message, procedure 4, ignore
+	0x00, 0x00, 0x03, // 3 IEs
+	0x00, 0x0a, 0x00, 0x06, 0x80, 0x01, 0x00, 0x00, 0x00, 0x00, // AMF-UE-NGAP-ID
+	0x00, 0x55, 0x00, 0x02, 0x00, 0x2a, // RAN-UE-NGAP-ID
+	0x00, 0x26, 0x00, 0x0b, 0x0a, // NAS-PDU
+	0x7e, 0x01, 0xde, 0xad, 0xbe, 0xef, 0x05, 0x7e, 0x00, 0x56,
+}
+
+func TestNGAPDownlinkNASTransport(t *testing.T) {
+	p := gopacket.NewPacket(sctpDataPacket(SCTPPayloadNGAP, testNGAPDownlinkNASTransport), LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSCTP, LayerTypeSCTPData, LayerTypeNGAP, LayerTypeNAS5GS, LayerTypeNAS5GS}, t)
+
+	n := p.Layer(LayerTypeNGAP).(*NGAP)
+	if n.MessageType != APMessageTypeInitiatingMessage || n.ProcedureCode != NGAPProcedureCodeDownlinkNASTransport {
+		t.Errorf("got %v %v, want InitiatingMessage DownlinkNASTransport", n.MessageType, n.ProcedureCode)
+	}
+	if id, ok := n.AMFUENGAPID(); !ok || id != 0x100000000 {
+		t.Errorf("got AMF-UE-NGAP-ID %#x %v, want 0x100000000", id, ok)
+	}
+	if id, ok := n.RANUENGAPID(); !ok || id != 0x2a {
+		t.Errorf("got RAN-UE-NGAP-ID %#x %v, want 0x2a", id, ok)
+	}
+
+	var nas []*NAS5GS
+	for _, l := range p.Layers() {
+		if l, ok := l.(*NAS5GS); ok {
+			nas = append(nas, l)
+		}
+	}
+	if nas[0].SecurityHeaderType != NASSecurityHeaderTypeIntegrityProtected || nas[0].MAC != 0xdeadbeef || nas[0].SequenceNumber != 5 {
+		t.Errorf("got security header %v MAC %#x sequence number %d", nas[0].SecurityHeaderType, nas[0].MAC, nas[0].SequenceNumber)
+	}
+	if nas[1].ProtocolDiscriminator != NAS5GSProtocolDiscriminator5GMM || nas[1].MessageType != NAS5GSMessageTypeAuthenticationRequest {
+		t.Errorf("got inner NAS %v %v, want 5GMM AuthenticationRequest", nas[1].ProtocolDiscriminator, nas[1].MessageType)
+	}
+}
@@ -50,8 +50,10 @@ func decodeWithSCTPChunkTypePrefix(data []byte, p gopacket.PacketBuilder) error
 	return chunkType.Decode(data, p)
 }
 
-// SerializeTo is for gopacket.SerializableLayer.
-func (s SCTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+// SerializeTo is for gopacket.SerializableLayer. With ComputeChecksums, the
+// CRC32c checksum of the packet, which covers the chunks serialized after
+// this layer, is computed and stored in Checksum.
+func (s *SCTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	bytes, err := b.PrependBytes(12)
 	if err != nil {
 		return err
@@ -60,14 +62,26 @@ func (s SCTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	binary.BigEndian.PutUint16(bytes[2:4], uint16(s.DstPort))
 	binary.BigEndian.PutUint32(bytes[4:8], s.VerificationTag)
 	if opts.ComputeChecksums {
+		binary.BigEndian.PutUint32(bytes[8:12], 0)
 		// Note:  MakeTable(Castagnoli) actually only creates the table once, then
 		// passes back a singleton on every other call, so this shouldn't cause
 		// excessive memory allocation.
 		binary.LittleEndian.PutUint32(bytes[8:12], crc32.Checksum(b.Bytes(), crc32.MakeTable(crc32.Castagnoli)))
+		s.Checksum = binary.BigEndian.Uint32(bytes[8:12])
+	} else {
+		binary.BigEndian.PutUint32(bytes[8:12], s.Checksum)
 	}
 	return nil
 }
 
+// VerifyChecksum verifies the CRC32c checksum of the decoded packet.
+func (s *SCTP) VerifyChecksum() gopacket.ChecksumResult {
+	data := append(append([]byte(nil), s.Contents...), s.Payload...)
+	binary.BigEndian.PutUint32(data[8:12], 0)
+	binary.LittleEndian.PutUint32(data[8:12], crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	return checksumResult(LayerTypeSCTP, s.Checksum, binary.BigEndian.Uint32(data[8:12]))
+}
+
 func (sctp *SCTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	if len(data) < 12 {
 		return errors.New("Invalid SCTP common header length")
@@ -120,11 +134,15 @@ func decodeSCTPChunk(data []byte) (SCTPChunk, error) {
 	actual := roundUpToNearest4(int(length))
 	ct := SCTPChunkType(data[0])
 
-	// For SCTP Data, use a separate layer for the payload
+	// For SCTP Data and I-Data, use a separate layer for the payload
 	delta := 0
-	if ct == SCTPChunkTypeData {
+	switch ct {
+	case SCTPChunkTypeData:
 		delta = int(actual) - int(length)
 		actual = 16
+	case SCTPChunkTypeIData:
+		delta = int(actual) - int(length)
+		actual = 20
 	}
 
 	return SCTPChunk{
@@ -136,6 +154,22 @@ func decodeSCTPChunk(data []byte) (SCTPChunk, error) {
 	}, nil
 }
 
+// serializeSCTPChunk prepends a chunk of the given type and flags whose value
+// is the given bytes, padded to a multiple of 4 bytes.
+func serializeSCTPChunk(b gopacket.SerializeBuffer, typ SCTPChunkType, flags uint8, value []byte) error {
+	length := 4 + len(value)
+	bytes, err := b.PrependBytes(roundUpToNearest4(length))
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(typ)
+	bytes[1] = flags
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(length))
+	copy(bytes[4:], value)
+	copy(bytes[length:], lotsOfZeros[:])
+	return nil
+}
+
 // SCTPParameter is a TLV parameter inside a SCTPChunk.
 type SCTPParameter struct {
 	Type         uint16
@@ -243,6 +277,7 @@ const (
 	SCTPPayloadDDPSegment                     = 16
 	SCTPPayloadDDPStream                      = 17
 	SCTPPayloadS1AP                           = 18
+	SCTPPayloadNGAP                           = 60
 )
 
 func (p SCTPPayloadProtocol) String() string {
@@ -285,6 +320,8 @@ func (p SCTPPayloadProtocol) String() string {
 		return "DDPStream"
 	case SCTPPayloadS1AP:
 		return "S1AP"
+	case SCTPPayloadNGAP:
+		return "NGAP"
 	}
 	return fmt.Sprintf("Unknown(%d)", p)
 }
@@ -305,7 +342,18 @@ func decodeSCTPData(data []byte, p gopacket.PacketBuilder) error {
 		PayloadProtocol: SCTPPayloadProtocol(binary.BigEndian.Uint32(data[12:16])),
 	}
 	// Length is the length in bytes of the data, INCLUDING the 16-byte header.
+	if int(sc.Length) >= 16 && int(sc.Length) <= len(data) {
+		sc.Payload = data[16:sc.Length]
+	}
 	p.AddLayer(sc)
+	if sc.BeginFragment && sc.EndFragment {
+		switch sc.PayloadProtocol {
+		case SCTPPayloadS1AP:
+			return p.NextDecoder(LayerTypeS1AP)
+		case SCTPPayloadNGAP:
+			return p.NextDecoder(LayerTypeNGAP)
+		}
+	}
 	return p.NextDecoder(gopacket.LayerTypePayload)
 }
 
@@ -314,7 +362,11 @@ func (sc SCTPData) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Seriali
 	payload := b.Bytes()
 	// Pad the payload to a 32 bit boundary
 	if rem := len(payload) % 4; rem != 0 {
-		b.AppendBytes(4 - rem)
+		padding, err := b.AppendBytes(4 - rem)
+		if err != nil {
+			return err
+		}
+		copy(padding, lotsOfZeros[:])
 	}
 	length := 16
 	bytes, err := b.PrependBytes(length)
@@ -389,25 +441,16 @@ func decodeSCTPInit(data []byte, p gopacket.PacketBuilder) error {
 
 // SerializeTo is for gopacket.SerializableLayer.
 func (sc SCTPInit) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	var payload []byte
+	value := make([]byte, 16)
+	binary.BigEndian.PutUint32(value[0:4], sc.InitiateTag)
+	binary.BigEndian.PutUint32(value[4:8], sc.AdvertisedReceiverWindowCredit)
+	binary.BigEndian.PutUint16(value[8:10], sc.OutboundStreams)
+	binary.BigEndian.PutUint16(value[10:12], sc.InboundStreams)
+	binary.BigEndian.PutUint32(value[12:16], sc.InitialTSN)
 	for _, param := range sc.Parameters {
-		payload = append(payload, SCTPParameter(param).Bytes()...)
-	}
-	length := 20 + len(payload)
-	bytes, err := b.PrependBytes(roundUpToNearest4(length))
-	if err != nil {
-		return err
+		value = append(value, SCTPParameter(param).Bytes()...)
 	}
-	bytes[0] = uint8(sc.Type)
-	bytes[1] = sc.Flags
-	binary.BigEndian.PutUint16(bytes[2:4], uint16(length))
-	binary.BigEndian.PutUint32(bytes[4:8], sc.InitiateTag)
-	binary.BigEndian.PutUint32(bytes[8:12], sc.AdvertisedReceiverWindowCredit)
-	binary.BigEndian.PutUint16(bytes[12:14], sc.OutboundStreams)
-	binary.BigEndian.PutUint16(bytes[14:16], sc.InboundStreams)
-	binary.BigEndian.PutUint32(bytes[16:20], sc.InitialTSN)
-	copy(bytes[20:], payload)
-	return nil
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, value)
 }
 
 // SCTPSack is the SCTP Selective ACK chunk layer.
@@ -468,26 +511,19 @@ func decodeSCTPSack(data []byte, p gopacket.PacketBuilder) error {
 
 // SerializeTo is for gopacket.SerializableLayer.
 func (sc SCTPSack) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	length := 16 + 2*len(sc.GapACKs) + 4*len(sc.DuplicateTSNs)
-	bytes, err := b.PrependBytes(roundUpToNearest4(length))
-	if err != nil {
-		return err
-	}
-	bytes[0] = uint8(sc.Type)
-	bytes[1] = sc.Flags
-	binary.BigEndian.PutUint16(bytes[2:4], uint16(length))
-	binary.BigEndian.PutUint32(bytes[4:8], sc.CumulativeTSNAck)
-	binary.BigEndian.PutUint32(bytes[8:12], sc.AdvertisedReceiverWindowCredit)
-	binary.BigEndian.PutUint16(bytes[12:14], uint16(len(sc.GapACKs)))
-	binary.BigEndian.PutUint16(bytes[14:16], uint16(len(sc.DuplicateTSNs)))
+	value := make([]byte, 12+2*len(sc.GapACKs)+4*len(sc.DuplicateTSNs))
+	binary.BigEndian.PutUint32(value[0:4], sc.CumulativeTSNAck)
+	binary.BigEndian.PutUint32(value[4:8], sc.AdvertisedReceiverWindowCredit)
+	binary.BigEndian.PutUint16(value[8:10], uint16(len(sc.GapACKs)))
+	binary.BigEndian.PutUint16(value[10:12], uint16(len(sc.DuplicateTSNs)))
 	for i, v := range sc.GapACKs {
-		binary.BigEndian.PutUint16(bytes[16+i*2:], v)
+		binary.BigEndian.PutUint16(value[12+i*2:], v)
 	}
-	offset := 16 + 2*len(sc.GapACKs)
+	offset := 12 + 2*len(sc.GapACKs)
 	for i, v := range sc.DuplicateTSNs {
-		binary.BigEndian.PutUint32(bytes[offset+i*4:], v)
+		binary.BigEndian.PutUint32(value[offset+i*4:], v)
 	}
-	return nil
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, value)
 }
 
 // SCTPHeartbeatParameter is the parameter type used by SCTP heartbeat and
@@ -533,17 +569,7 @@ func (sc SCTPHeartbeat) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Se
 	for _, param := range sc.Parameters {
 		payload = append(payload, SCTPParameter(param).Bytes()...)
 	}
-	length := 4 + len(payload)
-
-	bytes, err := b.PrependBytes(roundUpToNearest4(length))
-	if err != nil {
-		return err
-	}
-	bytes[0] = uint8(sc.Type)
-	bytes[1] = sc.Flags
-	binary.BigEndian.PutUint16(bytes[2:4], uint16(length))
-	copy(bytes[4:], payload)
-	return nil
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, payload)
 }
 
 // SCTPErrorParameter is the parameter type used by SCTP Abort and Error layers.
@@ -589,17 +615,7 @@ func (sc SCTPError) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serial
 	for _, param := range sc.Parameters {
 		payload = append(payload, SCTPParameter(param).Bytes()...)
 	}
-	length := 4 + len(payload)
-
-	bytes, err := b.PrependBytes(roundUpToNearest4(length))
-	if err != nil {
-		return err
-	}
-	bytes[0] = uint8(sc.Type)
-	bytes[1] = sc.Flags
-	binary.BigEndian.PutUint16(bytes[2:4], uint16(length))
-	copy(bytes[4:], payload)
-	return nil
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, payload)
 }
 
 // SCTPShutdown is the SCTP shutdown layer.
@@ -693,16 +709,7 @@ func decodeSCTPCookieEcho(data []byte, p gopacket.PacketBuilder) error {
 
 // SerializeTo is for gopacket.SerializableLayer.
 func (sc SCTPCookieEcho) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	length := 4 + len(sc.Cookie)
-	bytes, err := b.PrependBytes(roundUpToNearest4(length))
-	if err != nil {
-		return err
-	}
-	bytes[0] = uint8(sc.Type)
-	bytes[1] = sc.Flags
-	binary.BigEndian.PutUint16(bytes[2:4], uint16(length))
-	copy(bytes[4:], sc.Cookie)
-	return nil
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, sc.Cookie)
 }
 
 // This struct is used by all empty SCTP chunks (currently CookieAck and
@@ -0,0 +1,101 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestSCTPSerialize(t *testing.T) {
+	sctp := &SCTP{SrcPort: 36412, DstPort: 36412, VerificationTag: 0xdeadbeef}
+	init := &SCTPInit{
+		SCTPChunk:                      SCTPChunk{Type: SCTPChunkTypeInit},
+		InitiateTag:                    0x01020304,
+		AdvertisedReceiverWindowCredit: 65535,
+		OutboundStreams:                10,
+		InboundStreams:                 10,
+		InitialTSN:                     1,
+		Parameters:                     []SCTPInitParameter{{Type: 0xc000}, {Type: 0x8008, Value: []byte{0xc0, 0x82, 0x0f}}},
+	}
+	sack := &SCTPSack{
+		SCTPChunk:        SCTPChunk{Type: SCTPChunkTypeSack},
+		CumulativeTSNAck: 5,
+		GapACKs:          []uint16{2, 3},
+		DuplicateTSNs:    []uint32{4},
+	}
+	data := &SCTPData{
+		SCTPChunk:       SCTPChunk{Type: SCTPChunkTypeData},
+		BeginFragment:   true,
+		EndFragment:     true,
+		TSN:             1,
+		PayloadProtocol: 46, // Diameter
+	}
+
+	// Dirty the buffer, to check that serialization does not leave stale
+	// bytes in checksums or padding.
+	buf := gopacket.NewSerializeBuffer()
+	dirty, _ := buf.PrependBytes(128)
+	copy(dirty, bytes.Repeat([]byte{0xff}, len(dirty)))
+	buf.Clear()
+
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, sctp, init, sack, data, gopacket.Payload{1, 2, 3, 4, 5}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if len(b) != 12+32+24+24 {
+		t.Fatalf("serialized %d bytes: %x", len(b), b)
+	}
+	for _, pad := range [][]byte{b[12+31 : 12+32], b[len(b)-3:]} {
+		if !bytes.Equal(pad, make([]byte, len(pad))) {
+			t.Errorf("padding %x is not zero", pad)
+		}
+	}
+
+	check := append([]byte(nil), b...)
+	binary.BigEndian.PutUint32(check[8:12], 0)
+	if want := crc32.Checksum(check, crc32.MakeTable(crc32.Castagnoli)); binary.LittleEndian.Uint32(b[8:12]) != want {
+		t.Errorf("checksum %x, want %x", b[8:12], want)
+	}
+	if sctp.Checksum != binary.BigEndian.Uint32(b[8:12]) {
+		t.Errorf("Checksum field %#x does not match serialized checksum %x", sctp.Checksum, b[8:12])
+	}
+
+	p := gopacket.NewPacket(b, LayerTypeSCTP, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeSCTP, LayerTypeSCTPInit, LayerTypeSCTPSack, LayerTypeSCTPData, gopacket.LayerTypePayload}, t)
+	if got, ok := p.Layer(LayerTypeSCTPInit).(*SCTPInit); !ok {
+		t.Error("No SCTP Init layer")
+	} else if got.Length != 32 || !reflect.DeepEqual(got.Parameters[1].Value, init.Parameters[1].Value) {
+		t.Errorf("Init chunk decoded as %+v", got)
+	}
+	if got, ok := p.Layer(LayerTypeSCTPSack).(*SCTPSack); !ok {
+		t.Error("No SCTP Sack layer")
+	} else if !reflect.DeepEqual(got.GapACKs, sack.GapACKs) || !reflect.DeepEqual(got.DuplicateTSNs, sack.DuplicateTSNs) {
+		t.Errorf("Sack chunk decoded as %+v", got)
+	}
+	if got, ok := p.Layer(LayerTypeSCTPData).(*SCTPData); !ok {
+		t.Error("No SCTP Data layer")
+	} else if got.Length != 21 || !bytes.Equal(got.Payload, []byte{1, 2, 3, 4, 5}) {
+		t.Errorf("Data chunk decoded as %+v", got)
+	}
+
+	// Without ComputeChecksums, the Checksum field is serialized as is.
+	buf.Clear()
+	sctp.Checksum = 0x12345678
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, sctp); err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.BigEndian.Uint32(buf.Bytes()[8:12]); got != 0x12345678 {
+		t.Errorf("checksum %#x, want 0x12345678", got)
+	}
+}
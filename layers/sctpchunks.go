@@ -0,0 +1,611 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// SCTP parameter types used by the chunks of the SCTP extensions.
+const (
+	SCTPParamIPv4Address               = 5
+	SCTPParamIPv6Address               = 6
+	SCTPParamOutgoingSSNResetReq       = 13
+	SCTPParamIncomingSSNResetReq       = 14
+	SCTPParamSSNTSNResetReq            = 15
+	SCTPParamReconfigResponse          = 16
+	SCTPParamAddOutgoingStreamsReq     = 17
+	SCTPParamAddIncomingStreamsReq     = 18
+	SCTPParamAddIPAddress              = 0xc001
+	SCTPParamDeleteIPAddress           = 0xc002
+	SCTPParamErrorCauseIndication      = 0xc003
+	SCTPParamSetPrimaryAddress         = 0xc004
+	SCTPParamSuccessIndication         = 0xc005
+	SCTPParamAdaptationLayerIndication = 0xc006
+)
+
+// decodeSCTPChunkAtLeast decodes the common fields of a chunk whose length
+// must be at least min bytes, checking that it fits in data.
+func decodeSCTPChunkAtLeast(data []byte, min int) (SCTPChunk, error) {
+	if len(data) < 4 {
+		return SCTPChunk{}, errors.New("SCTP chunk too short")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < min || length > len(data) {
+		return SCTPChunk{}, fmt.Errorf("invalid SCTP %v chunk length %d", SCTPChunkType(data[0]), length)
+	}
+	if roundUpToNearest4(length) > len(data) {
+		// The padding of the last chunk of a packet may be missing.
+		data = append(data[:len(data):len(data)], lotsOfZeros[:roundUpToNearest4(length)-len(data)]...)
+	}
+	return decodeSCTPChunk(data)
+}
+
+// decodeSCTPParameters decodes a list of parameters, checking their
+// lengths.
+func decodeSCTPParameters(data []byte) ([]SCTPParameter, error) {
+	var params []SCTPParameter
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("SCTP parameter too short")
+		}
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 4 || length > len(data) {
+			return nil, fmt.Errorf("invalid SCTP parameter length %d", length)
+		}
+		p := decodeSCTPParameter(data)
+		params = append(params, p)
+		if p.ActualLength >= len(data) {
+			break
+		}
+		data = data[p.ActualLength:]
+	}
+	return params, nil
+}
+
+// SCTPForwardTSNStream is a stream of an SCTP Forward TSN chunk, and the
+// largest sequence number of the messages it skips.
+type SCTPForwardTSNStream struct {
+	StreamId       uint16
+	StreamSequence uint16
+}
+
+// SCTPForwardTSN is the SCTP Forward Cumulative TSN chunk layer (RFC 3758),
+// with which the sender of partially reliable streams tells the receiver to
+// move its cumulative TSN forward past abandoned messages.
+type SCTPForwardTSN struct {
+	SCTPChunk
+	NewCumulativeTSN uint32
+	Streams          []SCTPForwardTSNStream
+}
+
+// LayerType returns gopacket.LayerTypeSCTPForwardTSN.
+func (sc *SCTPForwardTSN) LayerType() gopacket.LayerType { return LayerTypeSCTPForwardTSN }
+
+func decodeSCTPForwardTSN(data []byte, p gopacket.PacketBuilder) error {
+	chunk, err := decodeSCTPChunkAtLeast(data, 8)
+	if err != nil {
+		return err
+	}
+	sc := &SCTPForwardTSN{
+		SCTPChunk:        chunk,
+		NewCumulativeTSN: binary.BigEndian.Uint32(data[4:8]),
+	}
+	for streams := data[8:sc.Length]; len(streams) >= 4; streams = streams[4:] {
+		sc.Streams = append(sc.Streams, SCTPForwardTSNStream{
+			StreamId:       binary.BigEndian.Uint16(streams[0:2]),
+			StreamSequence: binary.BigEndian.Uint16(streams[2:4]),
+		})
+	}
+	p.AddLayer(sc)
+	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
+}
+
+// SerializeTo is for gopacket.SerializableLayer.
+func (sc SCTPForwardTSN) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	value := make([]byte, 4+4*len(sc.Streams))
+	binary.BigEndian.PutUint32(value[0:4], sc.NewCumulativeTSN)
+	for i, s := range sc.Streams {
+		binary.BigEndian.PutUint16(value[4+4*i:], s.StreamId)
+		binary.BigEndian.PutUint16(value[6+4*i:], s.StreamSequence)
+	}
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, value)
+}
+
+// SCTPHMACIdentifier identifies the HMAC algorithm of an SCTP Auth chunk.
+type SCTPHMACIdentifier uint16
+
+// SCTP HMAC identifiers (RFC 4895).
+const (
+	SCTPHMACSHA1   SCTPHMACIdentifier = 1
+	SCTPHMACSHA256 SCTPHMACIdentifier = 3
+)
+
+func (h SCTPHMACIdentifier) String() string {
+	switch h {
+	case SCTPHMACSHA1:
+		return "SHA-1"
+	case SCTPHMACSHA256:
+		return "SHA-256"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(h))
+}
+
+// SCTPAuth is the SCTP Authentication chunk layer (RFC 4895), which
+// authenticates the chunks following it in the packet.
+type SCTPAuth struct {
+	SCTPChunk
+	SharedKeyIdentifier uint16
+	HMACIdentifier      SCTPHMACIdentifier
+	HMAC                []byte
+}
+
+// LayerType returns gopacket.LayerTypeSCTPAuth.
+func (sc *SCTPAuth) LayerType() gopacket.LayerType { return LayerTypeSCTPAuth }
+
+func decodeSCTPAuth(data []byte, p gopacket.PacketBuilder) error {
+	chunk, err := decodeSCTPChunkAtLeast(data, 8)
+	if err != nil {
+		return err
+	}
+	sc := &SCTPAuth{
+		SCTPChunk:           chunk,
+		SharedKeyIdentifier: binary.BigEndian.Uint16(data[4:6]),
+		HMACIdentifier:      SCTPHMACIdentifier(binary.BigEndian.Uint16(data[6:8])),
+		HMAC:                data[8:chunk.Length],
+	}
+	p.AddLayer(sc)
+	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
+}
+
+// SerializeTo is for gopacket.SerializableLayer.
+func (sc SCTPAuth) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	value := make([]byte, 4+len(sc.HMAC))
+	binary.BigEndian.PutUint16(value[0:2], sc.SharedKeyIdentifier)
+	binary.BigEndian.PutUint16(value[2:4], uint16(sc.HMACIdentifier))
+	copy(value[4:], sc.HMAC)
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, value)
+}
+
+// SCTPASCONFParameter is a parameter of an SCTP ASCONF or ASCONF-Ack chunk.
+type SCTPASCONFParameter SCTPParameter
+
+// SCTPASCONF is the SCTP Address Configuration Change chunk layer (RFC
+// 5061), also used for ASCONF-Ack chunks. The parameters of an ASCONF chunk
+// start with the address the sender uses to look up the association.
+type SCTPASCONF struct {
+	SCTPChunk
+	SequenceNumber uint32
+	Parameters     []SCTPASCONFParameter
+}
+
+// LayerType returns either gopacket.LayerTypeSCTPASCONF or
+// gopacket.LayerTypeSCTPASCONFAck.
+func (sc *SCTPASCONF) LayerType() gopacket.LayerType {
+	if sc.Type == SCTPChunkTypeASCONFAck {
+		return LayerTypeSCTPASCONFAck
+	}
+	// sc.Type == SCTPChunkTypeASCONF
+	return LayerTypeSCTPASCONF
+}
+
+func decodeSCTPASCONF(data []byte, p gopacket.PacketBuilder) error {
+	chunk, err := decodeSCTPChunkAtLeast(data, 8)
+	if err != nil {
+		return err
+	}
+	sc := &SCTPASCONF{
+		SCTPChunk:      chunk,
+		SequenceNumber: binary.BigEndian.Uint32(data[4:8]),
+	}
+	params, err := decodeSCTPParameters(data[8:chunk.Length])
+	if err != nil {
+		return err
+	}
+	for _, param := range params {
+		sc.Parameters = append(sc.Parameters, SCTPASCONFParameter(param))
+	}
+	p.AddLayer(sc)
+	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
+}
+
+// SerializeTo is for gopacket.SerializableLayer.
+func (sc SCTPASCONF) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, sc.SequenceNumber)
+	for _, param := range sc.Parameters {
+		value = append(value, SCTPParameter(param).Bytes()...)
+	}
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, value)
+}
+
+// NewSCTPAddressParameter returns an IPv4 or IPv6 address parameter.
+func NewSCTPAddressParameter(ip net.IP) SCTPASCONFParameter {
+	if ip4 := ip.To4(); ip4 != nil {
+		return SCTPASCONFParameter{Type: SCTPParamIPv4Address, Value: ip4}
+	}
+	return SCTPASCONFParameter{Type: SCTPParamIPv6Address, Value: ip.To16()}
+}
+
+// NewSCTPASCONFRequest returns a parameter of type typ, such as
+// SCTPParamAddIPAddress or SCTPParamSuccessIndication, with the given
+// correlation ID and embedded parameters.
+func NewSCTPASCONFRequest(typ uint16, correlationID uint32, embedded ...SCTPASCONFParameter) SCTPASCONFParameter {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, correlationID)
+	for _, e := range embedded {
+		value = append(value, SCTPParameter(e).Bytes()...)
+	}
+	return SCTPASCONFParameter{Type: typ, Value: value}
+}
+
+// Address returns the address of an IPv4 or IPv6 address parameter.
+func (p SCTPASCONFParameter) Address() (net.IP, error) {
+	switch {
+	case p.Type == SCTPParamIPv4Address && len(p.Value) == net.IPv4len:
+	case p.Type == SCTPParamIPv6Address && len(p.Value) == net.IPv6len:
+	default:
+		return nil, fmt.Errorf("not an SCTP address parameter: type %d, length %d", p.Type, len(p.Value))
+	}
+	return net.IP(p.Value), nil
+}
+
+func (p SCTPASCONFParameter) isRequest() bool {
+	switch p.Type {
+	case SCTPParamAddIPAddress, SCTPParamDeleteIPAddress, SCTPParamErrorCauseIndication,
+		SCTPParamSetPrimaryAddress, SCTPParamSuccessIndication:
+		return len(p.Value) >= 4
+	}
+	return false
+}
+
+// CorrelationID returns the ID which matches the request parameters of an
+// ASCONF chunk with the success and error cause indications of the
+// ASCONF-Ack chunk answering it.
+func (p SCTPASCONFParameter) CorrelationID() (uint32, error) {
+	if !p.isRequest() {
+		return 0, fmt.Errorf("SCTP parameter type %d has no correlation ID", p.Type)
+	}
+	return binary.BigEndian.Uint32(p.Value), nil
+}
+
+// Embedded returns the parameters following the correlation ID: the address
+// of an add, delete or set primary address parameter, or the error causes
+// of an error cause indication.
+func (p SCTPASCONFParameter) Embedded() ([]SCTPASCONFParameter, error) {
+	if !p.isRequest() {
+		return nil, fmt.Errorf("SCTP parameter type %d has no embedded parameters", p.Type)
+	}
+	params, err := decodeSCTPParameters(p.Value[4:])
+	if err != nil {
+		return nil, err
+	}
+	embedded := make([]SCTPASCONFParameter, len(params))
+	for i, param := range params {
+		embedded[i] = SCTPASCONFParameter(param)
+	}
+	return embedded, nil
+}
+
+// SCTPPad is the SCTP Padding chunk layer (RFC 4820), used to grow packets
+// for path MTU discovery.
+type SCTPPad struct {
+	SCTPChunk
+	Padding []byte
+}
+
+// LayerType returns gopacket.LayerTypeSCTPPad.
+func (sc *SCTPPad) LayerType() gopacket.LayerType { return LayerTypeSCTPPad }
+
+func decodeSCTPPad(data []byte, p gopacket.PacketBuilder) error {
+	chunk, err := decodeSCTPChunkAtLeast(data, 4)
+	if err != nil {
+		return err
+	}
+	sc := &SCTPPad{
+		SCTPChunk: chunk,
+		Padding:   data[4:chunk.Length],
+	}
+	p.AddLayer(sc)
+	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
+}
+
+// SerializeTo is for gopacket.SerializableLayer.
+func (sc SCTPPad) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, sc.Padding)
+}
+
+// SCTPIData is the SCTP I-Data chunk layer (RFC 8260), which carries user
+// messages identified by a message ID, so that the fragments of large
+// messages can be interleaved with other messages.
+type SCTPIData struct {
+	SCTPChunk
+	Immediate, Unordered, BeginFragment, EndFragment bool
+	TSN                                              uint32
+	StreamId                                         uint16
+	MessageId                                        uint32
+	// PayloadProtocol is only carried by the first fragment of a message,
+	// and FragmentSequence only by the others.
+	PayloadProtocol  SCTPPayloadProtocol
+	FragmentSequence uint32
+}
+
+// LayerType returns gopacket.LayerTypeSCTPIData.
+func (sc *SCTPIData) LayerType() gopacket.LayerType { return LayerTypeSCTPIData }
+
+func decodeSCTPIData(data []byte, p gopacket.PacketBuilder) error {
+	chunk, err := decodeSCTPChunkAtLeast(data, 20)
+	if err != nil {
+		return err
+	}
+	sc := &SCTPIData{
+		SCTPChunk:     chunk,
+		Immediate:     data[1]&0x8 != 0,
+		Unordered:     data[1]&0x4 != 0,
+		BeginFragment: data[1]&0x2 != 0,
+		EndFragment:   data[1]&0x1 != 0,
+		TSN:           binary.BigEndian.Uint32(data[4:8]),
+		StreamId:      binary.BigEndian.Uint16(data[8:10]),
+		MessageId:     binary.BigEndian.Uint32(data[12:16]),
+	}
+	if sc.BeginFragment {
+		sc.PayloadProtocol = SCTPPayloadProtocol(binary.BigEndian.Uint32(data[16:20]))
+	} else {
+		sc.FragmentSequence = binary.BigEndian.Uint32(data[16:20])
+	}
+	// Length is the length in bytes of the data, INCLUDING the 20-byte header.
+	sc.Payload = data[20:sc.Length]
+	p.AddLayer(sc)
+	if sc.BeginFragment && sc.EndFragment {
+		switch sc.PayloadProtocol {
+		case SCTPPayloadS1AP:
+			return p.NextDecoder(LayerTypeS1AP)
+		case SCTPPayloadNGAP:
+			return p.NextDecoder(LayerTypeNGAP)
+		}
+	}
+	return p.NextDecoder(gopacket.LayerTypePayload)
+}
+
+// SerializeTo is for gopacket.SerializableLayer.
+func (sc SCTPIData) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	payload := b.Bytes()
+	// Pad the payload to a 32 bit boundary
+	if rem := len(payload) % 4; rem != 0 {
+		padding, err := b.AppendBytes(4 - rem)
+		if err != nil {
+			return err
+		}
+		copy(padding, lotsOfZeros[:])
+	}
+	length := 20
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(sc.Type)
+	flags := uint8(0)
+	if sc.Immediate {
+		flags |= 0x8
+	}
+	if sc.Unordered {
+		flags |= 0x4
+	}
+	if sc.BeginFragment {
+		flags |= 0x2
+	}
+	if sc.EndFragment {
+		flags |= 0x1
+	}
+	bytes[1] = flags
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(length+len(payload)))
+	binary.BigEndian.PutUint32(bytes[4:8], sc.TSN)
+	binary.BigEndian.PutUint16(bytes[8:10], sc.StreamId)
+	bytes[10], bytes[11] = 0, 0
+	binary.BigEndian.PutUint32(bytes[12:16], sc.MessageId)
+	if sc.BeginFragment {
+		binary.BigEndian.PutUint32(bytes[16:20], uint32(sc.PayloadProtocol))
+	} else {
+		binary.BigEndian.PutUint32(bytes[16:20], sc.FragmentSequence)
+	}
+	return nil
+}
+
+// SCTPReconfigParameter is a parameter of an SCTP Re-configuration chunk.
+type SCTPReconfigParameter SCTPParameter
+
+// SCTPReconfig is the SCTP Re-configuration chunk layer (RFC 6525), which
+// resets or adds streams. It carries one or two requests, or responses to
+// them.
+type SCTPReconfig struct {
+	SCTPChunk
+	Parameters []SCTPReconfigParameter
+}
+
+// LayerType returns gopacket.LayerTypeSCTPReconfig.
+func (sc *SCTPReconfig) LayerType() gopacket.LayerType { return LayerTypeSCTPReconfig }
+
+func decodeSCTPReconfig(data []byte, p gopacket.PacketBuilder) error {
+	chunk, err := decodeSCTPChunkAtLeast(data, 4)
+	if err != nil {
+		return err
+	}
+	sc := &SCTPReconfig{SCTPChunk: chunk}
+	params, err := decodeSCTPParameters(data[4:chunk.Length])
+	if err != nil {
+		return err
+	}
+	for _, param := range params {
+		sc.Parameters = append(sc.Parameters, SCTPReconfigParameter(param))
+	}
+	p.AddLayer(sc)
+	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
+}
+
+// SerializeTo is for gopacket.SerializableLayer.
+func (sc SCTPReconfig) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var value []byte
+	for _, param := range sc.Parameters {
+		value = append(value, SCTPParameter(param).Bytes()...)
+	}
+	return serializeSCTPChunk(b, sc.Type, sc.Flags, value)
+}
+
+// SCTPReconfigResult is the result of a re-configuration request.
+type SCTPReconfigResult uint32
+
+// SCTP re-configuration results (RFC 6525).
+const (
+	SCTPReconfigSuccessNothingToDo     SCTPReconfigResult = 0
+	SCTPReconfigSuccessPerformed       SCTPReconfigResult = 1
+	SCTPReconfigDenied                 SCTPReconfigResult = 2
+	SCTPReconfigErrorWrongSSN          SCTPReconfigResult = 3
+	SCTPReconfigErrorRequestInProgress SCTPReconfigResult = 4
+	SCTPReconfigErrorBadSequence       SCTPReconfigResult = 5
+	SCTPReconfigInProgress             SCTPReconfigResult = 6
+)
+
+func (r SCTPReconfigResult) String() string {
+	switch r {
+	case SCTPReconfigSuccessNothingToDo:
+		return "SuccessNothingToDo"
+	case SCTPReconfigSuccessPerformed:
+		return "SuccessPerformed"
+	case SCTPReconfigDenied:
+		return "Denied"
+	case SCTPReconfigErrorWrongSSN:
+		return "ErrorWrongSSN"
+	case SCTPReconfigErrorRequestInProgress:
+		return "ErrorRequestInProgress"
+	case SCTPReconfigErrorBadSequence:
+		return "ErrorBadSequence"
+	case SCTPReconfigInProgress:
+		return "InProgress"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint32(r))
+}
+
+// SCTPReconfigRequest is a decoded re-configuration parameter. Which of its
+// fields are used depends on Type:
+//
+//	SCTPParamOutgoingSSNResetReq: RequestSequence, ResponseSequence,
+//	  LastTSN and Streams
+//	SCTPParamIncomingSSNResetReq: RequestSequence and Streams
+//	SCTPParamSSNTSNResetReq: RequestSequence
+//	SCTPParamReconfigResponse: ResponseSequence, Result and, if HasTSNs
+//	  is set, SenderNextTSN and ReceiverNextTSN
+//	SCTPParamAddOutgoingStreamsReq, SCTPParamAddIncomingStreamsReq:
+//	  RequestSequence and NewStreams
+//
+// Streams lists the streams to reset, all of them if it is empty.
+type SCTPReconfigRequest struct {
+	Type                              uint16
+	RequestSequence, ResponseSequence uint32
+	LastTSN                           uint32
+	Streams                           []uint16
+	Result                            SCTPReconfigResult
+	HasTSNs                           bool
+	SenderNextTSN, ReceiverNextTSN    uint32
+	NewStreams                        uint16
+}
+
+// Request decodes the parameter.
+func (p SCTPReconfigParameter) Request() (*SCTPReconfigRequest, error) {
+	r := &SCTPReconfigRequest{Type: p.Type}
+	v := p.Value
+	tooShort := fmt.Errorf("SCTP re-configuration parameter type %d too short", p.Type)
+	switch p.Type {
+	case SCTPParamOutgoingSSNResetReq:
+		if len(v) < 12 {
+			return nil, tooShort
+		}
+		r.RequestSequence = binary.BigEndian.Uint32(v[0:4])
+		r.ResponseSequence = binary.BigEndian.Uint32(v[4:8])
+		r.LastTSN = binary.BigEndian.Uint32(v[8:12])
+		r.Streams = decodeSCTPStreams(v[12:])
+	case SCTPParamIncomingSSNResetReq:
+		if len(v) < 4 {
+			return nil, tooShort
+		}
+		r.RequestSequence = binary.BigEndian.Uint32(v[0:4])
+		r.Streams = decodeSCTPStreams(v[4:])
+	case SCTPParamSSNTSNResetReq:
+		if len(v) < 4 {
+			return nil, tooShort
+		}
+		r.RequestSequence = binary.BigEndian.Uint32(v[0:4])
+	case SCTPParamReconfigResponse:
+		if len(v) < 8 {
+			return nil, tooShort
+		}
+		r.ResponseSequence = binary.BigEndian.Uint32(v[0:4])
+		r.Result = SCTPReconfigResult(binary.BigEndian.Uint32(v[4:8]))
+		if len(v) >= 16 {
+			r.HasTSNs = true
+			r.SenderNextTSN = binary.BigEndian.Uint32(v[8:12])
+			r.ReceiverNextTSN = binary.BigEndian.Uint32(v[12:16])
+		}
+	case SCTPParamAddOutgoingStreamsReq, SCTPParamAddIncomingStreamsReq:
+		if len(v) < 8 {
+			return nil, tooShort
+		}
+		r.RequestSequence = binary.BigEndian.Uint32(v[0:4])
+		r.NewStreams = binary.BigEndian.Uint16(v[4:6])
+	default:
+		return nil, fmt.Errorf("unsupported SCTP re-configuration parameter type %d", p.Type)
+	}
+	return r, nil
+}
+
+func decodeSCTPStreams(data []byte) []uint16 {
+	var streams []uint16
+	for ; len(data) >= 2; data = data[2:] {
+		streams = append(streams, binary.BigEndian.Uint16(data))
+	}
+	return streams
+}
+
+// Parameter encodes the request.
+func (r *SCTPReconfigRequest) Parameter() SCTPReconfigParameter {
+	var v []byte
+	put32 := func(u uint32) {
+		v = append(v, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+	}
+	putStreams := func() {
+		for _, s := range r.Streams {
+			v = append(v, byte(s>>8), byte(s))
+		}
+	}
+	switch r.Type {
+	case SCTPParamOutgoingSSNResetReq:
+		put32(r.RequestSequence)
+		put32(r.ResponseSequence)
+		put32(r.LastTSN)
+		putStreams()
+	case SCTPParamIncomingSSNResetReq:
+		put32(r.RequestSequence)
+		putStreams()
+	case SCTPParamSSNTSNResetReq:
+		put32(r.RequestSequence)
+	case SCTPParamReconfigResponse:
+		put32(r.ResponseSequence)
+		put32(uint32(r.Result))
+		if r.HasTSNs {
+			put32(r.SenderNextTSN)
+			put32(r.ReceiverNextTSN)
+		}
+	case SCTPParamAddOutgoingStreamsReq, SCTPParamAddIncomingStreamsReq:
+		put32(r.RequestSequence)
+		v = append(v, byte(r.NewStreams>>8), byte(r.NewStreams), 0, 0)
+	}
+	return SCTPReconfigParameter{Type: r.Type, Value: v}
+}
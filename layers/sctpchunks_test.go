@@ -0,0 +1,112 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestSCTPExtensionChunks(t *testing.T) {
+	reconfig := []*SCTPReconfigRequest{
+		{Type: SCTPParamOutgoingSSNResetReq, RequestSequence: 7, ResponseSequence: 3, LastTSN: 100, Streams: []uint16{1, 2, 5}},
+		{Type: SCTPParamReconfigResponse, ResponseSequence: 6, Result: SCTPReconfigSuccessPerformed, HasTSNs: true, SenderNextTSN: 200, ReceiverNextTSN: 300},
+	}
+	reconfigChunk := &SCTPReconfig{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeReconfig}}
+	for _, r := range reconfig {
+		reconfigChunk.Parameters = append(reconfigChunk.Parameters, r.Parameter())
+	}
+	layers := []gopacket.SerializableLayer{
+		&SCTP{SrcPort: 5000, DstPort: 5001, VerificationTag: 0x01020304},
+		&SCTPAuth{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeAuth}, SharedKeyIdentifier: 1, HMACIdentifier: SCTPHMACSHA1, HMAC: bytes.Repeat([]byte{0xab}, 20)},
+		&SCTPForwardTSN{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeForwardTSN}, NewCumulativeTSN: 42, Streams: []SCTPForwardTSNStream{{1, 10}, {3, 30}}},
+		&SCTPASCONF{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeASCONF}, SequenceNumber: 9, Parameters: []SCTPASCONFParameter{
+			NewSCTPAddressParameter(net.IP{192, 0, 2, 1}),
+			NewSCTPASCONFRequest(SCTPParamAddIPAddress, 77, NewSCTPAddressParameter(net.ParseIP("2001:db8::1"))),
+		}},
+		&SCTPPad{SCTPChunk: SCTPChunk{Type: SCTPChunkTypePad}, Padding: make([]byte, 6)},
+		reconfigChunk,
+		&SCTPIData{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeIData}, BeginFragment: true, EndFragment: true, TSN: 43, StreamId: 2, MessageId: 8, PayloadProtocol: 1234},
+		gopacket.Payload("hello"),
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, layers...); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{
+		LayerTypeSCTP,
+		LayerTypeSCTPAuth,
+		LayerTypeSCTPForwardTSN,
+		LayerTypeSCTPASCONF,
+		LayerTypeSCTPPad,
+		LayerTypeSCTPReconfig,
+		LayerTypeSCTPIData,
+		gopacket.LayerTypePayload,
+	}, t)
+
+	if auth, ok := p.Layer(LayerTypeSCTPAuth).(*SCTPAuth); !ok {
+		t.Error("No SCTP Auth layer")
+	} else if auth.SharedKeyIdentifier != 1 || auth.HMACIdentifier != SCTPHMACSHA1 || len(auth.HMAC) != 20 {
+		t.Errorf("Auth chunk decoded as %+v", auth)
+	}
+	if fwd, ok := p.Layer(LayerTypeSCTPForwardTSN).(*SCTPForwardTSN); !ok {
+		t.Error("No SCTP Forward TSN layer")
+	} else if want := []SCTPForwardTSNStream{{1, 10}, {3, 30}}; fwd.NewCumulativeTSN != 42 || !reflect.DeepEqual(fwd.Streams, want) {
+		t.Errorf("Forward TSN chunk decoded as %+v", fwd)
+	}
+
+	asconf, ok := p.Layer(LayerTypeSCTPASCONF).(*SCTPASCONF)
+	if !ok || len(asconf.Parameters) != 2 {
+		t.Fatalf("ASCONF chunk decoded as %+v", asconf)
+	}
+	if ip, err := asconf.Parameters[0].Address(); err != nil || !ip.Equal(net.IP{192, 0, 2, 1}) {
+		t.Errorf("ASCONF address %v, %v", ip, err)
+	}
+	if id, err := asconf.Parameters[1].CorrelationID(); err != nil || id != 77 {
+		t.Errorf("ASCONF correlation ID %d, %v", id, err)
+	}
+	if embedded, err := asconf.Parameters[1].Embedded(); err != nil || len(embedded) != 1 {
+		t.Errorf("ASCONF embedded parameters %+v, %v", embedded, err)
+	} else if ip, err := embedded[0].Address(); err != nil || !ip.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("ASCONF added address %v, %v", ip, err)
+	}
+
+	if pad, ok := p.Layer(LayerTypeSCTPPad).(*SCTPPad); !ok || pad.Length != 10 || pad.ActualLength != 12 {
+		t.Errorf("Pad chunk decoded as %+v", pad)
+	}
+
+	rc, ok := p.Layer(LayerTypeSCTPReconfig).(*SCTPReconfig)
+	if !ok || len(rc.Parameters) != len(reconfig) {
+		t.Fatalf("Re-configuration chunk decoded as %+v", rc)
+	}
+	for i, param := range rc.Parameters {
+		if got, err := param.Request(); err != nil {
+			t.Error(err)
+		} else if !reflect.DeepEqual(got, reconfig[i]) {
+			t.Errorf("Re-configuration parameter %d decoded as %+v, want %+v", i, got, reconfig[i])
+		}
+	}
+
+	idata, ok := p.Layer(LayerTypeSCTPIData).(*SCTPIData)
+	if !ok {
+		t.Fatal("No SCTP I-Data layer")
+	}
+	if idata.TSN != 43 || idata.StreamId != 2 || idata.MessageId != 8 || idata.PayloadProtocol != 1234 || !idata.BeginFragment || !idata.EndFragment {
+		t.Errorf("I-Data chunk decoded as %+v", idata)
+	}
+	if got := p.ApplicationLayer().Payload(); string(got) != "hello" {
+		t.Errorf("I-Data payload %q", got)
+	}
+}
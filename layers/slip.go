@@ -0,0 +1,118 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"errors"
+
+	"github.com/google/gopacket"
+)
+
+// SLIPPacketType is the type of a packet on a SLIP link, told by the high
+// bits of its first byte as transmitted.
+type SLIPPacketType uint8
+
+// SLIP packet types, from RFC 1144.
+const (
+	SLIPPacketTypeIP              SLIPPacketType = 0x40
+	SLIPPacketTypeUncompressedTCP SLIPPacketType = 0x70
+	SLIPPacketTypeCompressedTCP   SLIPPacketType = 0x80
+)
+
+func (t SLIPPacketType) String() string {
+	switch t {
+	case SLIPPacketTypeIP:
+		return "IP"
+	case SLIPPacketTypeUncompressedTCP:
+		return "Uncompressed TCP"
+	case SLIPPacketTypeCompressedTCP:
+		return "Compressed TCP"
+	}
+	return "Unknown"
+}
+
+func slipPacketType(b byte) SLIPPacketType {
+	switch {
+	case b&0x80 != 0:
+		return SLIPPacketTypeCompressedTCP
+	case b >= 0x70:
+		return SLIPPacketTypeUncompressedTCP
+	}
+	return SLIPPacketTypeIP
+}
+
+// SLIP is the 16 byte pseudo-header of pcap's LINKTYPE_SLIP. It is followed
+// by the IP packet, after Van Jacobson TCP/IP header decompression.
+type SLIP struct {
+	BaseLayer
+	// Outbound is set if the packet was sent by the capturing host.
+	Outbound   bool
+	PacketType SLIPPacketType
+	// LinkHeader holds the first 15 bytes of the packet as transmitted, so
+	// the compressed header of compressed TCP packets.
+	LinkHeader []byte
+}
+
+// LayerType returns LayerTypeSLIP.
+func (s *SLIP) LayerType() gopacket.LayerType { return LayerTypeSLIP }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *SLIP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 16 {
+		df.SetTruncated()
+		return errors.New("SLIP header too short")
+	}
+	s.Outbound = data[0] != 0
+	s.PacketType = slipPacketType(data[1])
+	s.LinkHeader = data[1:16]
+	s.BaseLayer = BaseLayer{Contents: data[:16], Payload: data[16:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info. If LinkHeader
+// is empty, only the PacketType is written.
+func (s *SLIP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(16)
+	if err != nil {
+		return err
+	}
+	copy(bytes, lotsOfZeros[:16])
+	if s.Outbound {
+		bytes[0] = 1
+	}
+	if len(s.LinkHeader) == 0 {
+		bytes[1] = uint8(s.PacketType)
+	} else {
+		copy(bytes[1:], s.LinkHeader)
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *SLIP) CanDecode() gopacket.LayerClass {
+	return LayerTypeSLIP
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *SLIP) NextLayerType() gopacket.LayerType {
+	if len(s.Payload) > 0 {
+		switch s.Payload[0] >> 4 {
+		case 4:
+			return LayerTypeIPv4
+		case 6:
+			return LayerTypeIPv6
+		}
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeSLIP(data []byte, p gopacket.PacketBuilder) error {
+	s := &SLIP{}
+	return decodingLayerDecoder(s, data, p)
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestSLIP(t *testing.T) {
+	data := serializeOverLink(t, &SLIP{Outbound: true, PacketType: SLIPPacketTypeIP})
+	if !bytes.Equal(data[:2], []byte{0x01, 0x40}) || !bytes.Equal(data[2:16], lotsOfZeros[:14]) {
+		t.Fatalf("header %x", data[:16])
+	}
+	p := gopacket.NewPacket(data, LinkTypeSLIP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSLIP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	s := p.Layer(LayerTypeSLIP).(*SLIP)
+	if !s.Outbound || s.PacketType != SLIPPacketTypeIP || len(s.LinkHeader) != 15 {
+		t.Errorf("got %+v", s)
+	}
+
+	// A received compressed TCP packet, whose link header holds the
+	// compressed header; the payload is the decompressed packet.
+	copy(data, []byte{0x00, 0xc5, 0x12, 0x34})
+	p = gopacket.NewPacket(data, LinkTypeSLIP, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeSLIP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	s = p.Layer(LayerTypeSLIP).(*SLIP)
+	if s.Outbound || s.PacketType != SLIPPacketTypeCompressedTCP || !bytes.Equal(s.LinkHeader[:3], []byte{0xc5, 0x12, 0x34}) {
+		t.Errorf("got %+v", s)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := s.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data[:16]) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), data[:16])
+	}
+
+	for b, want := range map[byte]SLIPPacketType{0x45: SLIPPacketTypeIP, 0x60: SLIPPacketTypeIP, 0x70: SLIPPacketTypeUncompressedTCP, 0x80: SLIPPacketTypeCompressedTCP} {
+		if got := slipPacketType(b); got != want {
+			t.Errorf("slipPacketType(%#x) = %v, want %v", b, got, want)
+		}
+	}
+
+	p = gopacket.NewPacket(data[:15], LinkTypeSLIP, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated header")
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+)
+
+// SRTControlType is the type of an SRT control packet.
+type SRTControlType uint16
+
+// SRT control packet types.
+const (
+	SRTControlHandshake         SRTControlType = 0x0000
+	SRTControlKeepAlive         SRTControlType = 0x0001
+	SRTControlACK               SRTControlType = 0x0002
+	SRTControlNAK               SRTControlType = 0x0003
+	SRTControlCongestionWarning SRTControlType = 0x0004
+	SRTControlShutdown          SRTControlType = 0x0005
+	SRTControlACKACK            SRTControlType = 0x0006
+	SRTControlDropRequest       SRTControlType = 0x0007
+	SRTControlPeerError         SRTControlType = 0x0008
+	SRTControlUserDefined       SRTControlType = 0x7fff
+)
+
+func (t SRTControlType) String() string {
+	switch t {
+	case SRTControlHandshake:
+		return "Handshake"
+	case SRTControlKeepAlive:
+		return "KeepAlive"
+	case SRTControlACK:
+		return "ACK"
+	case SRTControlNAK:
+		return "NAK"
+	case SRTControlCongestionWarning:
+		return "CongestionWarning"
+	case SRTControlShutdown:
+		return "Shutdown"
+	case SRTControlACKACK:
+		return "ACKACK"
+	case SRTControlDropRequest:
+		return "DropRequest"
+	case SRTControlPeerError:
+		return "PeerError"
+	case SRTControlUserDefined:
+		return "UserDefined"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
+// SRTPacketPosition is the position of a data packet within a message.
+type SRTPacketPosition uint8
+
+// SRT packet positions.
+const (
+	SRTPacketPositionMiddle SRTPacketPosition = 0
+	SRTPacketPositionLast   SRTPacketPosition = 1
+	SRTPacketPositionFirst  SRTPacketPosition = 2
+	SRTPacketPositionSingle SRTPacketPosition = 3
+)
+
+// SRTHandshakeType is the handshake type of an SRT handshake packet.
+type SRTHandshakeType uint32
+
+// SRT handshake types. Values above 1000 are rejection reasons.
+const (
+	SRTHandshakeDone       SRTHandshakeType = 0xfffffffd
+	SRTHandshakeAgreement  SRTHandshakeType = 0xfffffffe
+	SRTHandshakeConclusion SRTHandshakeType = 0xffffffff
+	SRTHandshakeWaveahand  SRTHandshakeType = 0x00000000
+	SRTHandshakeInduction  SRTHandshakeType = 0x00000001
+)
+
+func (t SRTHandshakeType) String() string {
+	switch t {
+	case SRTHandshakeDone:
+		return "Done"
+	case SRTHandshakeAgreement:
+		return "Agreement"
+	case SRTHandshakeConclusion:
+		return "Conclusion"
+	case SRTHandshakeWaveahand:
+		return "Waveahand"
+	case SRTHandshakeInduction:
+		return "Induction"
+	}
+	return fmt.Sprintf("Reject(%d)", uint32(t))
+}
+
+// SRTHandshakeExtensionType is the type of an SRT handshake extension.
+type SRTHandshakeExtensionType uint16
+
+// SRT handshake extension types.
+const (
+	SRTHandshakeExtensionHSReq      SRTHandshakeExtensionType = 1
+	SRTHandshakeExtensionHSRsp      SRTHandshakeExtensionType = 2
+	SRTHandshakeExtensionKMReq      SRTHandshakeExtensionType = 3
+	SRTHandshakeExtensionKMRsp      SRTHandshakeExtensionType = 4
+	SRTHandshakeExtensionStreamID   SRTHandshakeExtensionType = 5
+	SRTHandshakeExtensionCongestion SRTHandshakeExtensionType = 6
+	SRTHandshakeExtensionFilter     SRTHandshakeExtensionType = 7
+	SRTHandshakeExtensionGroup      SRTHandshakeExtensionType = 8
+)
+
+// SRTHandshakeExtension is a type-length-value extension following the
+// handshake body in conclusion handshakes.
+type SRTHandshakeExtension struct {
+	Type SRTHandshakeExtensionType
+	Data []byte
+}
+
+// SRTHandshake is the body of an SRT handshake control packet.
+type SRTHandshake struct {
+	Version               uint32
+	EncryptionField       uint16
+	ExtensionField        uint16
+	InitialSequenceNumber uint32
+	MTU                   uint32
+	MaxFlowWindow         uint32
+	Type                  SRTHandshakeType
+	SocketID              uint32
+	SYNCookie             uint32
+	PeerIP                net.IP
+	Extensions            []SRTHandshakeExtension
+}
+
+// StreamID returns the content of the Stream ID extension, if present.
+// The stream ID is sent as little-endian 32 bit words, so each word is
+// byte-swapped back into order here.
+func (h *SRTHandshake) StreamID() (string, bool) {
+	for _, ext := range h.Extensions {
+		if ext.Type != SRTHandshakeExtensionStreamID {
+			continue
+		}
+		b := make([]byte, 0, len(ext.Data))
+		for i := 0; i+4 <= len(ext.Data); i += 4 {
+			b = append(b, ext.Data[i+3], ext.Data[i+2], ext.Data[i+1], ext.Data[i])
+		}
+		return strings.TrimRight(string(b), "\x00"), true
+	}
+	return "", false
+}
+
+// SRTACK is the body of an SRT ACK control packet. Light ACKs only carry
+// LastAcknowledged and small ACKs stop after AvailableBuffer; fields not
+// present in the packet are left zero.
+type SRTACK struct {
+	LastAcknowledged      uint32
+	RTT                   uint32
+	RTTVariance           uint32
+	AvailableBuffer       uint32
+	PacketsReceivingRate  uint32
+	EstimatedLinkCapacity uint32
+	ReceivingRate         uint32
+}
+
+// SRTLossRange is a range of lost sequence numbers reported by a NAK.
+// Single losses have First == Last.
+type SRTLossRange struct {
+	First, Last uint32
+}
+
+// SRT is a Secure Reliable Transport packet, as specified in
+// draft-sharabayko-srt.
+//
+// SRT runs on whatever UDP port the session was set up on, so it is not
+// decoded from UDP by default; use RegisterUDPPortLayerType to map a port to
+// LayerTypeSRT.
+//
+// Data packets carry their data as payload. For control packets the
+// fields matching ControlType are filled in: Handshake, ACK (with the ACK
+// number in TypeSpecific) or Losses for NAKs. The control information of
+// other types is available in the layer contents.
+type SRT struct {
+	BaseLayer
+	IsControl bool
+
+	// Data packet fields.
+	SequenceNumber uint32
+	PacketPosition SRTPacketPosition
+	InOrder        bool
+	KeyFlags       uint8
+	Retransmitted  bool
+	MessageNumber  uint32
+
+	// Control packet fields.
+	ControlType  SRTControlType
+	Subtype      uint16
+	TypeSpecific uint32
+
+	Timestamp           uint32
+	DestinationSocketID uint32
+
+	Handshake SRTHandshake
+	ACK       SRTACK
+	Losses    []SRTLossRange
+}
+
+// LayerType returns LayerTypeSRT.
+func (s *SRT) LayerType() gopacket.LayerType { return LayerTypeSRT }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *SRT) CanDecode() gopacket.LayerClass { return LayerTypeSRT }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *SRT) NextLayerType() gopacket.LayerType {
+	if s.IsControl {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *SRT) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 16 {
+		df.SetTruncated()
+		return errors.New("SRT packet too short")
+	}
+	*s = SRT{Losses: s.Losses[:0], Handshake: SRTHandshake{Extensions: s.Handshake.Extensions[:0]}}
+	s.IsControl = data[0]&0x80 != 0
+	s.Timestamp = binary.BigEndian.Uint32(data[8:12])
+	s.DestinationSocketID = binary.BigEndian.Uint32(data[12:16])
+
+	if !s.IsControl {
+		s.SequenceNumber = binary.BigEndian.Uint32(data[0:4])
+		msg := binary.BigEndian.Uint32(data[4:8])
+		s.PacketPosition = SRTPacketPosition(msg >> 30)
+		s.InOrder = msg&0x20000000 != 0
+		s.KeyFlags = uint8(msg>>27) & 0x03
+		s.Retransmitted = msg&0x04000000 != 0
+		s.MessageNumber = msg & 0x03ffffff
+		s.BaseLayer = BaseLayer{Contents: data[:16], Payload: data[16:]}
+		return nil
+	}
+
+	s.ControlType = SRTControlType(binary.BigEndian.Uint16(data[0:2]) & 0x7fff)
+	s.Subtype = binary.BigEndian.Uint16(data[2:4])
+	s.TypeSpecific = binary.BigEndian.Uint32(data[4:8])
+	s.BaseLayer = BaseLayer{Contents: data}
+	cif := data[16:]
+
+	switch s.ControlType {
+	case SRTControlHandshake:
+		return s.decodeHandshake(cif, df)
+	case SRTControlACK:
+		fields := []*uint32{&s.ACK.LastAcknowledged, &s.ACK.RTT, &s.ACK.RTTVariance,
+			&s.ACK.AvailableBuffer, &s.ACK.PacketsReceivingRate,
+			&s.ACK.EstimatedLinkCapacity, &s.ACK.ReceivingRate}
+		if len(cif) < 4 {
+			df.SetTruncated()
+			return errors.New("SRT ACK too short")
+		}
+		for i := 0; i < len(fields) && 4*i+4 <= len(cif); i++ {
+			*fields[i] = binary.BigEndian.Uint32(cif[4*i:])
+		}
+	case SRTControlNAK:
+		for len(cif) >= 4 {
+			first := binary.BigEndian.Uint32(cif)
+			cif = cif[4:]
+			if first&0x80000000 == 0 {
+				s.Losses = append(s.Losses, SRTLossRange{First: first, Last: first})
+				continue
+			}
+			if len(cif) < 4 {
+				df.SetTruncated()
+				return errors.New("SRT NAK loss range truncated")
+			}
+			s.Losses = append(s.Losses, SRTLossRange{First: first & 0x7fffffff, Last: binary.BigEndian.Uint32(cif) & 0x7fffffff})
+			cif = cif[4:]
+		}
+	}
+	return nil
+}
+
+func (s *SRT) decodeHandshake(cif []byte, df gopacket.DecodeFeedback) error {
+	if len(cif) < 48 {
+		df.SetTruncated()
+		return errors.New("SRT handshake too short")
+	}
+	h := &s.Handshake
+	h.Version = binary.BigEndian.Uint32(cif[0:4])
+	h.EncryptionField = binary.BigEndian.Uint16(cif[4:6])
+	h.ExtensionField = binary.BigEndian.Uint16(cif[6:8])
+	h.InitialSequenceNumber = binary.BigEndian.Uint32(cif[8:12])
+	h.MTU = binary.BigEndian.Uint32(cif[12:16])
+	h.MaxFlowWindow = binary.BigEndian.Uint32(cif[16:20])
+	h.Type = SRTHandshakeType(binary.BigEndian.Uint32(cif[20:24]))
+	h.SocketID = binary.BigEndian.Uint32(cif[24:28])
+	h.SYNCookie = binary.BigEndian.Uint32(cif[28:32])
+	// IPv4 peers only use the first four bytes of the address field.
+	peer := cif[32:48]
+	ipv4 := true
+	for _, b := range peer[4:] {
+		if b != 0 {
+			ipv4 = false
+			break
+		}
+	}
+	if ipv4 {
+		h.PeerIP = net.IP(peer[:4])
+	} else {
+		h.PeerIP = net.IP(peer)
+	}
+
+	ext := cif[48:]
+	for len(ext) >= 4 {
+		typ := SRTHandshakeExtensionType(binary.BigEndian.Uint16(ext[0:2]))
+		length := 4 * int(binary.BigEndian.Uint16(ext[2:4]))
+		if len(ext) < 4+length {
+			df.SetTruncated()
+			return fmt.Errorf("SRT handshake extension %d truncated", typ)
+		}
+		h.Extensions = append(h.Extensions, SRTHandshakeExtension{Type: typ, Data: ext[4 : 4+length]})
+		ext = ext[4+length:]
+	}
+	return nil
+}
+
+func decodeSRT(data []byte, p gopacket.PacketBuilder) error {
+	s := &SRT{}
+	return decodingLayerDecoder(s, data, p)
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testSRTConclusion is a caller's conclusion handshake, version 5, with an
+// HSREQ extension and the stream ID "live/cam1".
+var testSRTConclusion = []byte{
+	0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x05, 0x12, 0x34, 0x56, 0x78, 0x00, 0x00, 0x05, 0xdc,
+	0x00, 0x00, 0x20, 0x00, 0xff, 0xff, 0xff, 0xff, 0x0a, 0x0b, 0x0c, 0x0d, 0x5e, 0x5e, 0x5e, 0x5e,
+	0x0a, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x03, 0x00, 0x01, 0x04, 0x03, 0x00, 0x00, 0x00, 0xbf, 0x00, 0x78, 0x00, 0x00,
+	0x00, 0x05, 0x00, 0x03, 0x65, 0x76, 0x69, 0x6c, 0x6d, 0x61, 0x63, 0x2f, 0x00, 0x00, 0x00, 0x31,
+}
+
+func TestSRTHandshake(t *testing.T) {
+	p := gopacket.NewPacket(testSRTConclusion, LayerTypeSRT, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSRT}, t)
+	s := p.Layer(LayerTypeSRT).(*SRT)
+	if !s.IsControl || s.ControlType != SRTControlHandshake || s.Timestamp != 0x100 {
+		t.Errorf("bad control header: %+v", s)
+	}
+	h := s.Handshake
+	if h.Version != 5 || h.Type != SRTHandshakeConclusion || h.InitialSequenceNumber != 0x12345678 || h.MTU != 1500 {
+		t.Errorf("bad handshake: %+v", h)
+	}
+	if h.SocketID != 0x0a0b0c0d || !h.PeerIP.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("got socket ID %#x, peer %v", h.SocketID, h.PeerIP)
+	}
+	if len(h.Extensions) != 2 || h.Extensions[0].Type != SRTHandshakeExtensionHSReq {
+		t.Fatalf("bad extensions %+v", h.Extensions)
+	}
+	if sid, ok := h.StreamID(); !ok || sid != "live/cam1" {
+		t.Errorf("got stream ID %q", sid)
+	}
+}
+
+func TestSRTACKAndNAK(t *testing.T) {
+	ack := []byte{
+		0x80, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x10, 0x00, 0x0a, 0x0b, 0x0c, 0x0d,
+		0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x27, 0x10, 0x00, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x20, 0x00,
+	}
+	var s SRT
+	if err := s.DecodeFromBytes(ack, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if s.ControlType != SRTControlACK || s.TypeSpecific != 7 {
+		t.Errorf("got %v number %d", s.ControlType, s.TypeSpecific)
+	}
+	want := SRTACK{LastAcknowledged: 0x100, RTT: 10000, RTTVariance: 1000, AvailableBuffer: 0x2000}
+	if s.ACK != want {
+		t.Errorf("got ACK %+v, want %+v", s.ACK, want)
+	}
+
+	nak := []byte{
+		0x80, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x0a, 0x0b, 0x0c, 0x0d,
+		0x00, 0x00, 0x00, 0x10, 0x80, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x25,
+	}
+	if err := s.DecodeFromBytes(nak, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if s.ControlType != SRTControlNAK || len(s.Losses) != 2 ||
+		s.Losses[0] != (SRTLossRange{0x10, 0x10}) || s.Losses[1] != (SRTLossRange{0x20, 0x25}) {
+		t.Errorf("got %v losses %+v", s.ControlType, s.Losses)
+	}
+}
+
+func TestSRTData(t *testing.T) {
+	data := []byte{
+		0x00, 0x00, 0x01, 0x02, 0xe4, 0x00, 0x00, 0x09, 0x00, 0x00, 0x10, 0x00, 0x0a, 0x0b, 0x0c, 0x0d,
+		0x47, 0x00, 0x11,
+	}
+	p := gopacket.NewPacket(data, LayerTypeSRT, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeSRT, gopacket.LayerTypePayload}, t)
+	s := p.Layer(LayerTypeSRT).(*SRT)
+	if s.IsControl || s.SequenceNumber != 0x102 || s.MessageNumber != 9 {
+		t.Errorf("bad data header: %+v", s)
+	}
+	if s.PacketPosition != SRTPacketPositionSingle || !s.InOrder || s.KeyFlags != 0 || !s.Retransmitted {
+		t.Errorf("bad message flags: %+v", s)
+	}
+	if len(s.Payload) != 3 {
+		t.Errorf("got payload %x", s.Payload)
+	}
+}
@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -21,7 +22,127 @@ type STPSwitchID struct {
 	HwAddr   net.HardwareAddr
 }
 
+func (id *STPSwitchID) decode(data []byte) {
+	id.Priority = binary.BigEndian.Uint16(data[0:2]) & 0xf000
+	id.SysID = binary.BigEndian.Uint16(data[0:2]) & 0x0fff
+	id.HwAddr = net.HardwareAddr(data[2:8])
+}
+
+func (id *STPSwitchID) serialize(data []byte) error {
+	prio, err := checkPriority(id.Priority)
+	if err != nil {
+		return err
+	}
+	if id.SysID >= 4096 {
+		return fmt.Errorf("Invalid VlanID value %d", id.SysID)
+	}
+	binary.BigEndian.PutUint16(data[0:2], prio|id.SysID)
+	copy(data[2:8], id.HwAddr)
+	return nil
+}
+
+// BPDU types.
+const (
+	STPTypeConfig uint8 = 0x00 // Configuration BPDU
+	STPTypeRST    uint8 = 0x02 // Rapid Spanning Tree BPDU, also used by MSTP
+	STPTypeTCN    uint8 = 0x80 // Topology Change Notification BPDU
+)
+
+// STPPortRole is the port role carried by RSTP and MSTP BPDUs.
+type STPPortRole uint8
+
+const (
+	STPPortRoleUnknown         STPPortRole = 0
+	STPPortRoleAlternateBackup STPPortRole = 1
+	STPPortRoleRoot            STPPortRole = 2
+	STPPortRoleDesignated      STPPortRole = 3
+)
+
+func (r STPPortRole) String() string {
+	switch r {
+	case STPPortRoleUnknown:
+		return "Unknown"
+	case STPPortRoleAlternateBackup:
+		return "Alternate/Backup"
+	case STPPortRoleRoot:
+		return "Root"
+	case STPPortRoleDesignated:
+		return "Designated"
+	}
+	return fmt.Sprintf("STPPortRole(%d)", uint8(r))
+}
+
+// stpFlags holds the flags of RSTP and MSTP BPDUs and MSTI messages.
+// Configuration BPDUs only use TC and TCA. MSTI messages use the TCA bit
+// as their master flag.
+type stpFlags struct {
+	TC, TCA    bool
+	Proposal   bool
+	PortRole   STPPortRole
+	Learning   bool
+	Forwarding bool
+	Agreement  bool
+}
+
+func decodeSTPFlags(b byte) stpFlags {
+	return stpFlags{
+		TC:         b&0x01 != 0,
+		Proposal:   b&0x02 != 0,
+		PortRole:   STPPortRole(b >> 2 & 0x03),
+		Learning:   b&0x10 != 0,
+		Forwarding: b&0x20 != 0,
+		Agreement:  b&0x40 != 0,
+		TCA:        b&0x80 != 0,
+	}
+}
+
+func (f stpFlags) byte() (b byte) {
+	if f.TC {
+		b |= 0x01
+	}
+	if f.Proposal {
+		b |= 0x02
+	}
+	b |= byte(f.PortRole&0x03) << 2
+	if f.Learning {
+		b |= 0x10
+	}
+	if f.Forwarding {
+		b |= 0x20
+	}
+	if f.Agreement {
+		b |= 0x40
+	}
+	if f.TCA {
+		b |= 0x80
+	}
+	return b
+}
+
+// STPMSTI is an MSTI configuration message of an MSTP BPDU, describing the
+// port in one multiple spanning tree instance.
+type STPMSTI struct {
+	TC, Master bool
+	Proposal   bool
+	PortRole   STPPortRole
+	Learning   bool
+	Forwarding bool
+	Agreement  bool
+	// RegionalRootID.SysID is the MSTI number.
+	RegionalRootID       STPSwitchID
+	InternalRootPathCost uint32
+	// BridgePriority and PortPriority are the high four bits of the
+	// bridge and port priorities.
+	BridgePriority uint8
+	PortPriority   uint8
+	RemainingHops  uint8
+}
+
 // STP decode spanning tree protocol packets to transport BPDU (bridge protocol data unit) message.
+//
+// Besides the configuration and topology change notification BPDUs of STP
+// (802.1D), it decodes the BPDUs of RSTP (802.1w, Version 2) and MSTP
+// (802.1s, Version 3), which use Type STPTypeRST.
 type STP struct {
 	BaseLayer
 	ProtocolID        uint16
@@ -35,6 +156,30 @@ type STP struct {
 	MaxAge            uint16
 	HelloTime         uint16
 	FDelay            uint16
+
+	// The following flags are only used by RSTP and MSTP BPDUs.
+	Proposal   bool
+	PortRole   STPPortRole
+	Learning   bool
+	Forwarding bool
+	Agreement  bool
+	// Version1Length is the length of the version 1 information of RSTP
+	// and MSTP BPDUs, which is always 0.
+	Version1Length uint8
+
+	// The following fields are only used by MSTP BPDUs.
+	Version3Length uint16
+	// MSTConfigFormat, MSTConfigName, MSTConfigRevision and
+	// MSTConfigDigest make up the MST configuration identifier, which
+	// is the same for all bridges of a region.
+	MSTConfigFormat          uint8
+	MSTConfigName            string
+	MSTConfigRevision        uint16
+	MSTConfigDigest          [16]byte
+	CISTInternalRootPathCost uint32
+	CISTBridgeID             STPSwitchID
+	CISTRemainingHops        uint8
+	MSTIs                    []STPMSTI
 }
 
 // LayerType returns gopacket.LayerTypeSTP.
@@ -45,31 +190,98 @@ func (s *STP) CanDecode() gopacket.LayerClass {
 	return LayerTypeSTP
 }
 
+const (
+	stpConfigLength = 35
+	stpRSTLength    = 36
+	// stpMSTLength is the length of an MSTP BPDU without MSTI messages.
+	stpMSTLength = 102
+	// stpMSTV3Length is the version 3 length without MSTI messages.
+	stpMSTV3Length   = 64
+	stpMSTIMsgLength = 16
+)
+
 // DecodeFromBytes decodes the given bytes into this layer.
 func (stp *STP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	stpLength := 35
-	if len(data) < stpLength {
+	if len(data) < 4 {
 		df.SetTruncated()
 		return fmt.Errorf("STP length %d too short", len(data))
 	}
-
+	*stp = STP{MSTIs: stp.MSTIs[:0]}
 	stp.ProtocolID = binary.BigEndian.Uint16(data[:2])
 	stp.Version = uint8(data[2])
 	stp.Type = uint8(data[3])
-	stp.TC = data[4]&0x01 != 0
-	stp.TCA = data[4]&0x80 != 0
-	stp.RouteID.Priority = binary.BigEndian.Uint16(data[5:7]) & 0xf000
-	stp.RouteID.SysID = binary.BigEndian.Uint16(data[5:7]) & 0x0fff
-	stp.RouteID.HwAddr = net.HardwareAddr(data[7:13])
+	if stp.Type == STPTypeTCN {
+		stp.Contents = data[:4]
+		stp.Payload = data[4:]
+		return nil
+	}
+
+	stpLength := stpConfigLength
+	if len(data) < stpLength {
+		df.SetTruncated()
+		return fmt.Errorf("STP length %d too short", len(data))
+	}
+	f := decodeSTPFlags(data[4])
+	stp.TC, stp.TCA = f.TC, f.TCA
+	stp.Proposal, stp.PortRole, stp.Learning, stp.Forwarding, stp.Agreement =
+		f.Proposal, f.PortRole, f.Learning, f.Forwarding, f.Agreement
+	stp.RouteID.decode(data[5:13])
 	stp.Cost = binary.BigEndian.Uint32(data[13:17])
-	stp.BridgeID.Priority = binary.BigEndian.Uint16(data[17:19]) & 0xf000
-	stp.BridgeID.SysID = binary.BigEndian.Uint16(data[17:19]) & 0x0fff
-	stp.BridgeID.HwAddr = net.HardwareAddr(data[19:25])
+	stp.BridgeID.decode(data[17:25])
 	stp.PortID = binary.BigEndian.Uint16(data[25:27])
 	stp.MessageAge = binary.BigEndian.Uint16(data[27:29])
 	stp.MaxAge = binary.BigEndian.Uint16(data[29:31])
 	stp.HelloTime = binary.BigEndian.Uint16(data[31:33])
 	stp.FDelay = binary.BigEndian.Uint16(data[33:35])
+
+	if stp.Type == STPTypeRST && stp.Version >= 2 {
+		if len(data) < stpRSTLength {
+			df.SetTruncated()
+			return fmt.Errorf("RSTP length %d too short", len(data))
+		}
+		stp.Version1Length = data[35]
+		stpLength = stpRSTLength
+	}
+	// MSTP BPDUs are accepted by RSTP bridges, which ignore the version 3
+	// information, so an MSTP BPDU without it is an RSTP BPDU.
+	if stp.Type == STPTypeRST && stp.Version >= 3 && len(data) >= stpRSTLength+2 {
+		stp.Version3Length = binary.BigEndian.Uint16(data[36:38])
+		end := stpRSTLength + 2 + int(stp.Version3Length)
+		if stp.Version3Length < stpMSTV3Length || (stp.Version3Length-stpMSTV3Length)%stpMSTIMsgLength != 0 {
+			return fmt.Errorf("invalid MSTP version 3 length %d", stp.Version3Length)
+		}
+		if len(data) < end {
+			df.SetTruncated()
+			return fmt.Errorf("MSTP length %d too short for version 3 length %d", len(data), stp.Version3Length)
+		}
+		stp.MSTConfigFormat = data[38]
+		stp.MSTConfigName = string(bytes.TrimRight(data[39:71], "\x00"))
+		stp.MSTConfigRevision = binary.BigEndian.Uint16(data[71:73])
+		copy(stp.MSTConfigDigest[:], data[73:89])
+		stp.CISTInternalRootPathCost = binary.BigEndian.Uint32(data[89:93])
+		stp.CISTBridgeID.decode(data[93:101])
+		stp.CISTRemainingHops = data[101]
+		for off := stpMSTLength; off < end; off += stpMSTIMsgLength {
+			msg := data[off : off+stpMSTIMsgLength]
+			f := decodeSTPFlags(msg[0])
+			m := STPMSTI{
+				TC:                   f.TC,
+				Master:               f.TCA,
+				Proposal:             f.Proposal,
+				PortRole:             f.PortRole,
+				Learning:             f.Learning,
+				Forwarding:           f.Forwarding,
+				Agreement:            f.Agreement,
+				InternalRootPathCost: binary.BigEndian.Uint32(msg[9:13]),
+				BridgePriority:       msg[13] >> 4,
+				PortPriority:         msg[14] >> 4,
+				RemainingHops:        msg[15],
+			}
+			m.RegionalRootID.decode(msg[1:9])
+			stp.MSTIs = append(stp.MSTIs, m)
+		}
+		stpLength = end
+	}
 	stp.Contents = data[:stpLength]
 	stp.Payload = data[stpLength:]
 
@@ -83,64 +295,97 @@ func (stp *STP) NextLayerType() gopacket.LayerType {
 
 // Check if the priority value is correct.
 func checkPriority(prio uint16) (uint16, error) {
-	if prio == 0 {
-		return prio, errors.New("Invalid Priority value must be in the rage <4096-61440> with an increment of 4096")
-	}
 	if prio%4096 == 0 {
 		return prio, nil
 	} else {
-		return prio, errors.New("Invalid Priority value must be in the rage <4096-61440> with an increment of 4096")
+		return prio, errors.New("Invalid Priority value must be in the rage <0-61440> with an increment of 4096")
 	}
 }
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
+//
+// The length of the BPDU follows from Type and Version: topology change
+// notifications are 4 bytes, RSTP BPDUs 36 bytes and MSTP BPDUs 102 bytes
+// plus 16 bytes per MSTI. With FixLengths, Version1Length and
+// Version3Length are set.
 func (s *STP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	var flags uint8 = 0x00
-	bytes, err := b.PrependBytes(35)
+	length := stpConfigLength
+	switch {
+	case s.Type == STPTypeTCN:
+		length = 4
+	case s.Type == STPTypeRST && s.Version >= 3:
+		length = stpMSTLength + stpMSTIMsgLength*len(s.MSTIs)
+	case s.Type == STPTypeRST && s.Version == 2:
+		length = stpRSTLength
+	}
+	bytes, err := b.PrependBytes(length)
 	if err != nil {
 		return err
 	}
 	binary.BigEndian.PutUint16(bytes, s.ProtocolID)
 	bytes[2] = s.Version
 	bytes[3] = s.Type
-	if s.TC {
-		flags |= 0x01
+	if length == 4 {
+		return nil
 	}
-	if s.TCA {
-		flags |= 0x80
-	}
-	bytes[4] = flags
+	bytes[4] = stpFlags{TC: s.TC, TCA: s.TCA, Proposal: s.Proposal, PortRole: s.PortRole,
+		Learning: s.Learning, Forwarding: s.Forwarding, Agreement: s.Agreement}.byte()
 
-	prioRoot, err := checkPriority(s.RouteID.Priority)
-	if err != nil {
-		panic(err)
-	}
-	if s.RouteID.SysID >= 4096 {
-		panic("Invalid VlanID value ..!")
+	if err := s.RouteID.serialize(bytes[5:13]); err != nil {
+		return err
 	}
-	binary.BigEndian.PutUint16(bytes[5:7], prioRoot|s.RouteID.SysID)
-	copy(bytes[7:13], s.RouteID.HwAddr)
-
 	binary.BigEndian.PutUint32(bytes[13:17], s.Cost)
-
-	prioBridge, err := checkPriority(s.BridgeID.Priority)
-	if err != nil {
-		panic(err)
-	}
-	if s.BridgeID.SysID >= 4096 {
-		panic("Invalid VlanID value ..!")
+	if err := s.BridgeID.serialize(bytes[17:25]); err != nil {
+		return err
 	}
-	binary.BigEndian.PutUint16(bytes[17:19], prioBridge|s.BridgeID.SysID)
-	copy(bytes[19:25], s.BridgeID.HwAddr)
-
 	binary.BigEndian.PutUint16(bytes[25:27], s.PortID)
 	binary.BigEndian.PutUint16(bytes[27:29], s.MessageAge)
 	binary.BigEndian.PutUint16(bytes[29:31], s.MaxAge)
 	binary.BigEndian.PutUint16(bytes[31:33], s.HelloTime)
 	binary.BigEndian.PutUint16(bytes[33:35], s.FDelay)
+	if length == stpConfigLength {
+		return nil
+	}
 
+	if opts.FixLengths {
+		s.Version1Length = 0
+	}
+	bytes[35] = s.Version1Length
+	if length == stpRSTLength {
+		return nil
+	}
+
+	if opts.FixLengths {
+		s.Version3Length = uint16(length - stpRSTLength - 2)
+	}
+	binary.BigEndian.PutUint16(bytes[36:38], s.Version3Length)
+	if len(s.MSTConfigName) > 32 {
+		return fmt.Errorf("MST configuration name %q longer than 32 bytes", s.MSTConfigName)
+	}
+	bytes[38] = s.MSTConfigFormat
+	copy(bytes[39:71], lotsOfZeros[:32])
+	copy(bytes[39:71], s.MSTConfigName)
+	binary.BigEndian.PutUint16(bytes[71:73], s.MSTConfigRevision)
+	copy(bytes[73:89], s.MSTConfigDigest[:])
+	binary.BigEndian.PutUint32(bytes[89:93], s.CISTInternalRootPathCost)
+	if err := s.CISTBridgeID.serialize(bytes[93:101]); err != nil {
+		return err
+	}
+	bytes[101] = s.CISTRemainingHops
+	for i, m := range s.MSTIs {
+		msg := bytes[stpMSTLength+i*stpMSTIMsgLength:]
+		msg[0] = stpFlags{TC: m.TC, TCA: m.Master, Proposal: m.Proposal, PortRole: m.PortRole,
+			Learning: m.Learning, Forwarding: m.Forwarding, Agreement: m.Agreement}.byte()
+		if err := m.RegionalRootID.serialize(msg[1:9]); err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(msg[9:13], m.InternalRootPathCost)
+		msg[13] = m.BridgePriority << 4
+		msg[14] = m.PortPriority << 4
+		msg[15] = m.RemainingHops
+	}
 	return nil
 }
 
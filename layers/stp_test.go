@@ -182,3 +182,89 @@ func TestEncodeDecodeSTP(t *testing.T) {
 		}
 	}
 }
+
+func TestSTPTopologyChangeNotification(t *testing.T) {
+	p := gopacket.NewPacket([]byte{0x00, 0x00, 0x00, 0x80}, LayerTypeSTP, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	stp := p.Layer(LayerTypeSTP).(*STP)
+	if stp.Type != STPTypeTCN || len(stp.Contents) != 4 {
+		t.Errorf("got type %#x with %d bytes", stp.Type, len(stp.Contents))
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := stp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), stp.Contents) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), stp.Contents)
+	}
+}
+
+func TestSTPMSTP(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00}
+	want := &STP{
+		Version:    3,
+		Type:       STPTypeRST,
+		RouteID:    STPSwitchID{Priority: 32768, HwAddr: mac},
+		BridgeID:   STPSwitchID{Priority: 32768, HwAddr: mac},
+		PortID:     0x8001,
+		MaxAge:     20 * 256,
+		HelloTime:  2 * 256,
+		FDelay:     15 * 256,
+		Proposal:   true,
+		PortRole:   STPPortRoleDesignated,
+		Learning:   true,
+		Forwarding: true,
+		Agreement:  true,
+
+		MSTConfigName:     "region1",
+		MSTConfigRevision: 7,
+		MSTConfigDigest:   [16]byte{0xac, 0x36, 0x17, 0x7f, 0x50, 0x28, 0x3c, 0xd4, 0xb8, 0x38, 0x21, 0xd8, 0xab, 0x26, 0xde, 0x62},
+		CISTBridgeID:      STPSwitchID{Priority: 32768, HwAddr: mac},
+		CISTRemainingHops: 20,
+		MSTIs: []STPMSTI{
+			{Master: true, PortRole: STPPortRoleRoot, Forwarding: true,
+				RegionalRootID: STPSwitchID{Priority: 4096, SysID: 1, HwAddr: mac},
+				BridgePriority: 8, PortPriority: 8, RemainingHops: 20},
+			{TC: true, PortRole: STPPortRoleAlternateBackup,
+				RegionalRootID:       STPSwitchID{Priority: 0, SysID: 2, HwAddr: mac},
+				InternalRootPathCost: 20000, BridgePriority: 15, PortPriority: 8, RemainingHops: 19},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := want.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != 134 || want.Version3Length != 96 {
+		t.Fatalf("got %d bytes, version 3 length %d", len(data), want.Version3Length)
+	}
+	if data[4] != 0x7e {
+		t.Errorf("got CIST flags %#x, want 0x7e", data[4])
+	}
+	if data[102] != 0xa8 || data[118] != 0x05 {
+		t.Errorf("got MSTI flags %#x and %#x, want 0xa8 and 0x05", data[102], data[118])
+	}
+
+	p := gopacket.NewPacket(data, LayerTypeSTP, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeSTP).(*STP)
+	want.BaseLayer = BaseLayer{Contents: data, Payload: data[134:]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	// RSTP bridges see only the first 36 bytes.
+	want.Version = 2
+	buf.Clear()
+	if err := want.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	rstp := buf.Bytes()
+	if len(rstp) != 36 || rstp[2] != 2 || !reflect.DeepEqual(rstp[3:], data[3:36]) {
+		t.Errorf("got RSTP BPDU %x", rstp)
+	}
+}
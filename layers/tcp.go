@@ -54,6 +54,7 @@ const (
 	TCPOptionKindCCEcho                          = 13 // obsolete
 	TCPOptionKindAltChecksum                     = 14 // len = 3, obsolete
 	TCPOptionKindAltChecksumData                 = 15 // len = n, obsolete
+	TCPOptionKindMPTCP                           = 30 // len = n
 )
 
 func (k TCPOptionKind) String() string {
@@ -90,6 +91,8 @@ func (k TCPOptionKind) String() string {
 		return "AltChecksum"
 	case TCPOptionKindAltChecksumData:
 		return "AltChecksumData"
+	case TCPOptionKindMPTCP:
+		return "MPTCP"
 	default:
 		return fmt.Sprintf("Unknown(%d)", k)
 	}
@@ -127,12 +130,169 @@ func (t TCPOption) String() string {
 	return fmt.Sprintf("TCPOption(%s:%s)", t.OptionType, hd)
 }
 
+// TCPSACKBlock is a block of data received out of order, from sequence
+// number Left up to but not including Right, as reported by a SACK option
+// (RFC 2018).
+type TCPSACKBlock struct {
+	Left, Right uint32
+}
+
+// NewTCPOptionMSS returns a maximum segment size option.
+func NewTCPOptionMSS(mss uint16) TCPOption {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, mss)
+	return TCPOption{OptionType: TCPOptionKindMSS, OptionLength: 4, OptionData: data}
+}
+
+// NewTCPOptionWindowScale returns a window scale option with the given
+// shift count.
+func NewTCPOptionWindowScale(shift uint8) TCPOption {
+	return TCPOption{OptionType: TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{shift}}
+}
+
+// NewTCPOptionSACKPermitted returns a SACK permitted option.
+func NewTCPOptionSACKPermitted() TCPOption {
+	return TCPOption{OptionType: TCPOptionKindSACKPermitted, OptionLength: 2}
+}
+
+// NewTCPOptionSACK returns a SACK option reporting blocks, of which at most
+// four fit in the TCP header.
+func NewTCPOptionSACK(blocks ...TCPSACKBlock) TCPOption {
+	data := make([]byte, 8*len(blocks))
+	for i, b := range blocks {
+		binary.BigEndian.PutUint32(data[8*i:], b.Left)
+		binary.BigEndian.PutUint32(data[8*i+4:], b.Right)
+	}
+	return TCPOption{OptionType: TCPOptionKindSACK, OptionLength: uint8(2 + len(data)), OptionData: data}
+}
+
+// NewTCPOptionTimestamps returns a timestamps option (RFC 7323).
+func NewTCPOptionTimestamps(tsval, tsecr uint32) TCPOption {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, tsval)
+	binary.BigEndian.PutUint32(data[4:], tsecr)
+	return TCPOption{OptionType: TCPOptionKindTimestamps, OptionLength: 10, OptionData: data}
+}
+
+// MaxTCPOptionLength is the number of bytes of options which fit in a TCP
+// header.
+const MaxTCPOptionLength = 40
+
+// BuildTCPOptions lays out opts for a TCP header the way most stacks do:
+// timestamps and SACK options are preceded by NOPs so that their 32-bit
+// fields are aligned, and NOPs are appended up to a multiple of 4 bytes,
+// so that the header needs no further padding. OptionLength is set from
+// the data of each option. It returns an error if the options do not fit
+// in the header.
+//
+//	tcp.Options, err = layers.BuildTCPOptions(
+//		layers.NewTCPOptionMSS(1460),
+//		layers.NewTCPOptionSACKPermitted(),
+//		layers.NewTCPOptionTimestamps(tsval, 0),
+//		layers.NewTCPOptionWindowScale(7),
+//	)
+func BuildTCPOptions(opts ...TCPOption) ([]TCPOption, error) {
+	nop := TCPOption{OptionType: TCPOptionKindNop, OptionLength: 1}
+	var built []TCPOption
+	var length int
+	for _, o := range opts {
+		switch o.OptionType {
+		case TCPOptionKindEndList, TCPOptionKindNop:
+			built = append(built, o)
+			length++
+			continue
+		case TCPOptionKindTimestamps, TCPOptionKindSACK:
+			for ; length%4 != 2; length++ {
+				built = append(built, nop)
+			}
+		}
+		o.OptionLength = uint8(2 + len(o.OptionData))
+		built = append(built, o)
+		length += 2 + len(o.OptionData)
+	}
+	for ; length%4 != 0; length++ {
+		built = append(built, nop)
+	}
+	if length > MaxTCPOptionLength {
+		return nil, fmt.Errorf("TCP options too long: %d bytes", length)
+	}
+	return built, nil
+}
+
+// option returns the data of the first option of kind k whose data is n
+// bytes long, or whose length is a multiple of n if multiple is set.
+func (t *TCP) option(k TCPOptionKind, n int, multiple bool) ([]byte, bool) {
+	for _, o := range t.Options {
+		if o.OptionType != k {
+			continue
+		}
+		if len(o.OptionData) == n || multiple && len(o.OptionData)%n == 0 {
+			return o.OptionData, true
+		}
+	}
+	return nil, false
+}
+
+// MSS returns the value of the maximum segment size option, if the segment
+// has a valid one.
+func (t *TCP) MSS() (uint16, bool) {
+	data, ok := t.option(TCPOptionKindMSS, 2, false)
+	if !ok {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(data), true
+}
+
+// WindowScale returns the shift count of the window scale option, if the
+// segment has a valid one.
+func (t *TCP) WindowScale() (uint8, bool) {
+	data, ok := t.option(TCPOptionKindWindowScale, 1, false)
+	if !ok {
+		return 0, false
+	}
+	return data[0], true
+}
+
+// SACKPermitted returns whether the segment has a SACK permitted option.
+func (t *TCP) SACKPermitted() bool {
+	_, ok := t.option(TCPOptionKindSACKPermitted, 0, false)
+	return ok
+}
+
+// SACKBlocks returns the blocks of the SACK option of the segment, or nil
+// if it has no valid one.
+func (t *TCP) SACKBlocks() []TCPSACKBlock {
+	data, ok := t.option(TCPOptionKindSACK, 8, true)
+	if !ok || len(data) == 0 {
+		return nil
+	}
+	blocks := make([]TCPSACKBlock, len(data)/8)
+	for i := range blocks {
+		blocks[i].Left = binary.BigEndian.Uint32(data[8*i:])
+		blocks[i].Right = binary.BigEndian.Uint32(data[8*i+4:])
+	}
+	return blocks
+}
+
+// Timestamps returns the timestamp value and echo reply of the timestamps
+// option, if the segment has a valid one.
+func (t *TCP) Timestamps() (tsval, tsecr uint32, ok bool) {
+	data, ok := t.option(TCPOptionKindTimestamps, 8, false)
+	if !ok {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(data), binary.BigEndian.Uint32(data[4:]), true
+}
+
 // LayerType returns gopacket.LayerTypeTCP
 func (t *TCP) LayerType() gopacket.LayerType { return LayerTypeTCP }
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
+//
+// DataOffset and Padding are computed from the options if opts.FixLengths is
+// set or DataOffset is zero, which is never valid.
 func (t *TCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	var optionLength int
 	for _, o := range t.Options {
@@ -143,10 +303,13 @@ func (t *TCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 			optionLength += 2 + len(o.OptionData)
 		}
 	}
-	if opts.FixLengths {
+	if opts.FixLengths || t.DataOffset == 0 {
 		if rem := optionLength % 4; rem != 0 {
 			t.Padding = lotsOfZeros[:4-rem]
 		}
+		if optionLength+len(t.Padding) > MaxTCPOptionLength {
+			return fmt.Errorf("TCP options too long: %d bytes", optionLength+len(t.Padding))
+		}
 		t.DataOffset = uint8((len(t.Padding) + optionLength + 20) / 4)
 	}
 	bytes, err := b.PrependBytes(20 + optionLength + len(t.Padding))
@@ -194,6 +357,11 @@ func (t *TCP) ComputeChecksum() (uint16, error) {
 	return t.computeChecksum(append(t.Contents, t.Payload...), IPProtocolTCP)
 }
 
+// VerifyChecksum verifies the checksum of the decoded packet.
+func (t *TCP) VerifyChecksum() gopacket.ChecksumResult {
+	return t.verifyChecksum(LayerTypeTCP, t.Contents, t.Payload, 16, IPProtocolTCP, t.Checksum)
+}
+
 func (t *TCP) flagsAndOffset() uint16 {
 	f := uint16(t.DataOffset) << 12
 	if t.FIN {
@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -101,3 +102,105 @@ func TestPacketTCPOptionDecode(t *testing.T) {
 		t.Errorf("expected options to be %#v, but got %#v", expected, tcp.Options)
 	}
 }
+
+func TestTCPOptionAccessors(t *testing.T) {
+	blocks := []TCPSACKBlock{{Left: 1000, Right: 2000}, {Left: 3000, Right: 4000}}
+	tcp := &TCP{
+		SrcPort: 1,
+		DstPort: 2,
+		SYN:     true,
+		Options: []TCPOption{
+			NewTCPOptionMSS(1460),
+			NewTCPOptionSACKPermitted(),
+			NewTCPOptionTimestamps(12345, 67890),
+			{OptionType: TCPOptionKindNop},
+			NewTCPOptionWindowScale(7),
+			NewTCPOptionSACK(blocks...),
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, tcp); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeTCP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeTCP).(*TCP)
+	if mss, ok := got.MSS(); !ok || mss != 1460 {
+		t.Errorf("got MSS %d, %v", mss, ok)
+	}
+	if shift, ok := got.WindowScale(); !ok || shift != 7 {
+		t.Errorf("got window scale %d, %v", shift, ok)
+	}
+	if !got.SACKPermitted() {
+		t.Error("SACK not permitted")
+	}
+	if tsval, tsecr, ok := got.Timestamps(); !ok || tsval != 12345 || tsecr != 67890 {
+		t.Errorf("got timestamps %d %d, %v", tsval, tsecr, ok)
+	}
+	if b := got.SACKBlocks(); !reflect.DeepEqual(b, blocks) {
+		t.Errorf("got SACK blocks %v, want %v", b, blocks)
+	}
+
+	bad := &TCP{Options: []TCPOption{
+		{OptionType: TCPOptionKindMSS, OptionLength: 3, OptionData: []byte{1}},
+		{OptionType: TCPOptionKindSACK, OptionLength: 6, OptionData: []byte{1, 2, 3, 4}},
+		{OptionType: TCPOptionKindTimestamps, OptionLength: 6, OptionData: []byte{1, 2, 3, 4}},
+	}}
+	if _, ok := bad.MSS(); ok {
+		t.Error("got MSS from a short option")
+	}
+	if b := bad.SACKBlocks(); b != nil {
+		t.Errorf("got SACK blocks %v from a short option", b)
+	}
+	if _, _, ok := bad.Timestamps(); ok {
+		t.Error("got timestamps from a short option")
+	}
+	if _, ok := bad.WindowScale(); ok || bad.SACKPermitted() {
+		t.Error("got missing options")
+	}
+}
+
+func TestBuildTCPOptions(t *testing.T) {
+	opts, err := BuildTCPOptions(
+		NewTCPOptionSACKPermitted(),
+		NewTCPOptionMSS(1460),
+		NewTCPOptionTimestamps(1, 2),
+		TCPOption{OptionType: TCPOptionKindSACK, OptionData: make([]byte, 8)},
+		NewTCPOptionWindowScale(7),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without FixLengths, the zero DataOffset is computed.
+	tcp := &TCP{SrcPort: 1, DstPort: 2, ACK: true, Options: opts}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, tcp); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x04, 0x02, // SACK permitted
+		0x02, 0x04, 0x05, 0xb4, // MSS
+		0x08, 0x0a, 0, 0, 0, 1, 0, 0, 0, 2, // timestamps
+		0x01, 0x01, // NOPs aligning the SACK
+		0x05, 0x0a, 0, 0, 0, 0, 0, 0, 0, 0, // SACK
+		0x03, 0x03, 0x07, // window scale
+		0x01, // NOP padding the options
+	}
+	data := buf.Bytes()
+	if off := data[12] >> 4; int(off) != 5+len(want)/4 {
+		t.Errorf("got data offset %d", off)
+	}
+	if !bytes.Equal(data[20:], want) {
+		t.Errorf("got options %x, want %x", data[20:], want)
+	}
+
+	if _, err := BuildTCPOptions(NewTCPOptionSACK(make([]TCPSACKBlock, 4)...), NewTCPOptionTimestamps(1, 2)); err == nil {
+		t.Error("built options longer than the header")
+	}
+	tcp.Options = []TCPOption{NewTCPOptionSACK(make([]TCPSACKBlock, 5)...)}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, tcp); err == nil {
+		t.Error("serialized options longer than the header")
+	}
+}
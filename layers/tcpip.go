@@ -73,6 +73,13 @@ func tcpipChecksum(data []byte, csum uint32) uint16 {
 // serialized TCP or UDP header plus its payload, with the checksum zero'd
 // out. headerProtocol is the IP protocol number of the upper-layer header.
 func (c *tcpipchecksum) computeChecksum(headerAndPayload []byte, headerProtocol IPProtocol) (uint16, error) {
+	return c.computePartialChecksum(headerAndPayload, len(headerAndPayload), headerProtocol)
+}
+
+// computePartialChecksum computes the checksum of protocols, like DCCP,
+// whose checksum may only cover the first covered bytes of
+// headerAndPayload. The pseudo-header still gives the full length.
+func (c *tcpipchecksum) computePartialChecksum(headerAndPayload []byte, covered int, headerProtocol IPProtocol) (uint16, error) {
 	if c.pseudoheader == nil {
 		return 0, errors.New("TCP/IP layer 4 checksum cannot be computed without network layer... call SetNetworkLayerForChecksum to set which layer to use")
 	}
@@ -84,7 +91,32 @@ func (c *tcpipchecksum) computeChecksum(headerAndPayload []byte, headerProtocol
 	csum += uint32(headerProtocol)
 	csum += length & 0xffff
 	csum += length >> 16
-	return tcpipChecksum(headerAndPayload, csum), nil
+	return tcpipChecksum(headerAndPayload[:covered], csum), nil
+}
+
+// verifyChecksum verifies the checksum of a decoded TCP/IP layer of type
+// lt, which is stored at offset in its header.
+func (c *tcpipchecksum) verifyChecksum(lt gopacket.LayerType, header, payload []byte, offset int, headerProtocol IPProtocol, stored uint16) gopacket.ChecksumResult {
+	data := append(append([]byte(nil), header...), payload...)
+	data[offset], data[offset+1] = 0, 0
+	csum, err := c.computeChecksum(data, headerProtocol)
+	if err != nil {
+		return gopacket.ChecksumResult{LayerType: lt, Status: gopacket.ChecksumUnverifiable, Checksum: uint32(stored), Err: err}
+	}
+	if csum == 0 && stored == 0xffff {
+		// Both are zero in one's complement, and UDP sends the latter.
+		csum = 0xffff
+	}
+	return checksumResult(lt, uint32(stored), uint32(csum))
+}
+
+// checksumResult returns the result of verifying a checksum.
+func checksumResult(lt gopacket.LayerType, stored, computed uint32) gopacket.ChecksumResult {
+	r := gopacket.ChecksumResult{LayerType: lt, Checksum: stored, Computed: computed}
+	if stored != computed {
+		r.Status = gopacket.ChecksumIncorrect
+	}
+	return r
 }
 
 // SetNetworkLayerForChecksum tells this layer which network layer is wrapping it.
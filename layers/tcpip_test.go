@@ -7,9 +7,11 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
 	"net"
+	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 const (
@@ -183,3 +185,81 @@ func TestIPv6JumbogramUDPChecksum(t *testing.T) {
 		t.Errorf("Bad checksum:\ngot:\n%#v\n\nwant:\n%#v\n\n", got, want)
 	}
 }
+
+func TestVerifyChecksums(t *testing.T) {
+	newIPv4 := func(proto IPProtocol) *IPv4 {
+		ip := createIPv4ChecksumTestLayer()
+		ip.Protocol = proto
+		return ip
+	}
+	tcp := &TCP{SrcPort: 1234, DstPort: 80, Seq: 1, SYN: true, Window: 1024}
+	ip4 := newIPv4(IPProtocolTCP)
+	tcp.SetNetworkLayerForChecksum(ip4)
+	udp := createUDPChecksumTestLayer()
+	ip6 := createIPv6ChecksumTestLayer()
+	ip6.NextHeader = IPProtocolICMPv6
+	icmp6 := &ICMPv6{TypeCode: CreateICMPv6TypeCode(ICMPv6TypeEchoRequest, 0)}
+	icmp6.SetNetworkLayerForChecksum(ip6)
+
+	for _, test := range []struct {
+		name    string
+		layers  []gopacket.SerializableLayer
+		checked bool // whether ComputeChecksums is set
+		want    []gopacket.ChecksumStatus
+	}{
+		{"tcp", []gopacket.SerializableLayer{ip4, tcp}, true,
+			[]gopacket.ChecksumStatus{gopacket.ChecksumCorrect, gopacket.ChecksumCorrect}},
+		{"udp without checksum", []gopacket.SerializableLayer{newIPv4(IPProtocolUDP), udp}, false,
+			[]gopacket.ChecksumStatus{gopacket.ChecksumIncorrect, gopacket.ChecksumNotComputed}},
+		{"icmpv4", []gopacket.SerializableLayer{newIPv4(IPProtocolICMPv4), &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeEchoRequest, 0), Id: 1}}, true,
+			[]gopacket.ChecksumStatus{gopacket.ChecksumCorrect, gopacket.ChecksumCorrect}},
+		{"icmpv6", []gopacket.SerializableLayer{ip6, icmp6}, true,
+			[]gopacket.ChecksumStatus{gopacket.ChecksumCorrect}},
+		{"sctp", []gopacket.SerializableLayer{newIPv4(IPProtocolSCTP), &SCTP{SrcPort: 1, DstPort: 2, VerificationTag: 3}}, true,
+			[]gopacket.ChecksumStatus{gopacket.ChecksumCorrect, gopacket.ChecksumCorrect}},
+	} {
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: test.checked}
+		all := append(test.layers, gopacket.Payload("payload"))
+		if err := gopacket.SerializeLayers(buf, opts, all...); err != nil {
+			t.Fatal(test.name, err)
+		}
+		linkType := LayerTypeIPv4
+		if _, ok := test.layers[0].(*IPv6); ok {
+			linkType = LayerTypeIPv6
+		}
+		p := gopacket.NewPacket(buf.Bytes(), linkType, gopacket.Default)
+		var got []gopacket.ChecksumStatus
+		for _, r := range p.VerifyChecksums(gopacket.ChecksumOptions{}) {
+			got = append(got, r.Status)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got checksums %v, want %v", test.name, got, test.want)
+		}
+		if !test.checked {
+			continue
+		}
+
+		// Corrupt the payload, covered by the last checksum.
+		data := buf.Bytes()
+		data[len(data)-1] ^= 0xff
+		for _, o := range []struct {
+			offloaded, truncated bool
+			want                 gopacket.ChecksumStatus
+		}{
+			{false, false, gopacket.ChecksumIncorrect},
+			{true, false, gopacket.ChecksumNotComputed},
+			{true, true, gopacket.ChecksumUnverifiable},
+		} {
+			p := gopacket.NewPacket(data, linkType, gopacket.Lazy)
+			p.Metadata().Truncated = o.truncated
+			results := p.VerifyChecksums(gopacket.ChecksumOptions{Offloaded: o.offloaded})
+			if len(results) != len(test.want) {
+				t.Fatalf("%s: got %d checksums, want %d", test.name, len(results), len(test.want))
+			}
+			if r := results[len(results)-1]; r.Status != o.want || r.Checksum == r.Computed {
+				t.Errorf("%s: corrupted packet checksum %+v with %+v, want %v", test.name, r, o, o.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// Teredo is the header of IPv6 packets tunneled in UDP by Teredo (RFC
+// 4380): the optional authentication and origin indicators sent by Teredo
+// servers and clients before the encapsulated IPv6 packet, which is the
+// payload of the layer.
+type Teredo struct {
+	BaseLayer
+	// Authentication is true if the packet has an authentication
+	// indicator, made of the following fields up to Confirmation.
+	Authentication bool
+	ClientID       []byte
+	AuthValue      []byte
+	Nonce          [8]byte
+	Confirmation   uint8
+	// Origin is true if the packet has an origin indicator, giving the
+	// address and port a client was seen from by its server. OriginPort
+	// and OriginIP hold their values, not their obfuscated encoding.
+	Origin     bool
+	OriginPort uint16
+	OriginIP   net.IP
+}
+
+// LayerType returns LayerTypeTeredo.
+func (t *Teredo) LayerType() gopacket.LayerType { return LayerTypeTeredo }
+
+// CanDecode returns LayerTypeTeredo.
+func (t *Teredo) CanDecode() gopacket.LayerClass { return LayerTypeTeredo }
+
+// NextLayerType returns LayerTypeIPv6.
+func (t *Teredo) NextLayerType() gopacket.LayerType { return LayerTypeIPv6 }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (t *Teredo) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	*t = Teredo{}
+	off := 0
+	if len(data) >= 2 && data[0] == 0 && data[1] == 1 {
+		if len(data) < 4 {
+			df.SetTruncated()
+			return errors.New("Teredo authentication indicator too short")
+		}
+		idLen, auLen := int(data[2]), int(data[3])
+		off = 4 + idLen + auLen + 9
+		if len(data) < off {
+			df.SetTruncated()
+			return errors.New("Teredo authentication indicator too short")
+		}
+		t.Authentication = true
+		t.ClientID = data[4 : 4+idLen]
+		t.AuthValue = data[4+idLen : 4+idLen+auLen]
+		copy(t.Nonce[:], data[off-9:])
+		t.Confirmation = data[off-1]
+	}
+	if len(data) >= off+2 && data[off] == 0 && data[off+1] == 0 {
+		if len(data) < off+8 {
+			df.SetTruncated()
+			return errors.New("Teredo origin indicator too short")
+		}
+		t.Origin = true
+		t.OriginPort = ^binary.BigEndian.Uint16(data[off+2:])
+		t.OriginIP = make(net.IP, 4)
+		for i := range t.OriginIP {
+			t.OriginIP[i] = ^data[off+4+i]
+		}
+		off += 8
+	}
+	if len(data) <= off || data[off]>>4 != 6 {
+		return errors.New("Teredo payload is not an IPv6 packet")
+	}
+	t.BaseLayer = BaseLayer{Contents: data[:off], Payload: data[off:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (t *Teredo) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if t.Origin {
+		ip := t.OriginIP.To4()
+		if ip == nil {
+			return fmt.Errorf("invalid Teredo origin address %v", t.OriginIP)
+		}
+		bytes, err := b.PrependBytes(8)
+		if err != nil {
+			return err
+		}
+		bytes[0], bytes[1] = 0, 0
+		binary.BigEndian.PutUint16(bytes[2:], ^t.OriginPort)
+		for i := range ip {
+			bytes[4+i] = ^ip[i]
+		}
+	}
+	if t.Authentication {
+		if len(t.ClientID) > 255 || len(t.AuthValue) > 255 {
+			return errors.New("Teredo client identifier or authentication value too long")
+		}
+		n := 4 + len(t.ClientID) + len(t.AuthValue)
+		bytes, err := b.PrependBytes(n + 9)
+		if err != nil {
+			return err
+		}
+		bytes[0], bytes[1] = 0, 1
+		bytes[2], bytes[3] = uint8(len(t.ClientID)), uint8(len(t.AuthValue))
+		copy(bytes[4:], t.ClientID)
+		copy(bytes[4+len(t.ClientID):], t.AuthValue)
+		copy(bytes[n:], t.Nonce[:])
+		bytes[n+8] = t.Confirmation
+	}
+	return nil
+}
+
+func decodeTeredo(data []byte, p gopacket.PacketBuilder) error {
+	t := &Teredo{}
+	if err := t.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(t)
+	setTunnel(p, IPv6TunnelTeredo)
+	return p.NextDecoder(LayerTypeIPv6)
+}
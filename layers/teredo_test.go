@@ -0,0 +1,93 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A Teredo bubble sent by a server to a client, with an origin indicator
+// for 192.0.2.45 port 40000.
+var testTeredoBubble = []byte{
+	0x45, 0x00, 0x00, 0x4c, 0x00, 0x00, 0x00, 0x00, 0x40, 0x11, 0x00, 0x00, // IPv4
+	0xc6, 0x33, 0x64, 0x01, 0xc0, 0x00, 0x02, 0x2d,
+	0x0d, 0xd8, 0x9c, 0x40, 0x00, 0x38, 0x00, 0x00, // UDP 3544 -> 40000
+	0x00, 0x00, 0x63, 0xbf, 0x3f, 0xff, 0xfd, 0xd2, // origin indicator
+	0x60, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3b, 0x15, // IPv6, no next header
+	0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0x00, 0xf2, 0x27, 0x3f, 0xff, 0xfd, 0xd2,
+	0x20, 0x01, 0x00, 0x00, 0xc6, 0x33, 0x64, 0x01, 0x80, 0x00, 0x63, 0xbf, 0x3f, 0xff, 0xfd, 0xd2,
+}
+
+func TestTeredoBubble(t *testing.T) {
+	p := gopacket.NewPacket(testTeredoBubble, LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeUDP, LayerTypeTeredo, LayerTypeIPv6}, t)
+	teredo := p.Layer(LayerTypeTeredo).(*Teredo)
+	if !teredo.Origin || teredo.OriginPort != 40000 || !teredo.OriginIP.Equal(net.IPv4(192, 0, 2, 45)) || teredo.Authentication {
+		t.Errorf("got %+v", teredo)
+	}
+	if ip6 := p.Layer(LayerTypeIPv6).(*IPv6); ip6.NextHeader != IPProtocolNoNextHeader || ip6.Length != 0 {
+		t.Errorf("got IPv6 %+v", ip6)
+	}
+	if got := p.Metadata().Tunnel; got != "Teredo" {
+		t.Errorf("got tunnel %q, want Teredo", got)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := teredo.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.Bytes(); !bytes.Equal(got, teredo.Contents) {
+		t.Errorf("got %x, want %x", got, teredo.Contents)
+	}
+}
+
+func TestTeredoAuthentication(t *testing.T) {
+	want := &Teredo{
+		Authentication: true,
+		ClientID:       []byte("client"),
+		AuthValue:      []byte{1, 2, 3, 4},
+		Nonce:          [8]byte{8, 7, 6, 5, 4, 3, 2, 1},
+		Confirmation:   1,
+		Origin:         true,
+		OriginPort:     1234,
+		OriginIP:       net.IP{203, 0, 113, 7},
+	}
+	ip6 := &IPv6{Version: 6, NextHeader: IPProtocolNoNextHeader, SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP("ff02::2")}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, want, ip6); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != 23+8+40 {
+		t.Fatalf("got %d bytes", len(data))
+	}
+	var got Teredo
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	got.BaseLayer = BaseLayer{}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("got %+v, want %+v", &got, want)
+	}
+
+	for _, n := range []int{3, 22, 30} {
+		if err := got.DecodeFromBytes(data[:n], gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("decoded %d truncated bytes", n)
+		}
+	}
+	if err := got.DecodeFromBytes([]byte{0x45, 0, 0, 0}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("decoded an IPv4 payload")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+)
+
+// TFTPOpcode is the type of a TFTP packet.
+type TFTPOpcode uint16
+
+// TFTP opcodes from RFC 1350 and RFC 2347.
+const (
+	TFTPOpcodeRRQ   TFTPOpcode = 1
+	TFTPOpcodeWRQ   TFTPOpcode = 2
+	TFTPOpcodeDATA  TFTPOpcode = 3
+	TFTPOpcodeACK   TFTPOpcode = 4
+	TFTPOpcodeERROR TFTPOpcode = 5
+	TFTPOpcodeOACK  TFTPOpcode = 6
+)
+
+func (o TFTPOpcode) String() string {
+	switch o {
+	case TFTPOpcodeRRQ:
+		return "RRQ"
+	case TFTPOpcodeWRQ:
+		return "WRQ"
+	case TFTPOpcodeDATA:
+		return "DATA"
+	case TFTPOpcodeACK:
+		return "ACK"
+	case TFTPOpcodeERROR:
+		return "ERROR"
+	case TFTPOpcodeOACK:
+		return "OACK"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(o))
+}
+
+// TFTPDefaultBlockSize is the block size used unless a blksize option
+// (RFC 2348) is negotiated.
+const TFTPDefaultBlockSize = 512
+
+// TFTPOption is a TFTP option (RFC 2347), as carried by RRQ, WRQ and OACK
+// packets.
+type TFTPOption struct {
+	Name, Value string
+}
+
+// TFTP is a Trivial File Transfer Protocol (RFC 1350) packet.
+//
+// Only requests are sent to the well-known port 69; the rest of a transfer
+// uses ports chosen by both ends. To decode those packets, decode the UDP
+// payload with DecodeFromBytes, or use a tracker such as the one in the
+// carve package.
+type TFTP struct {
+	BaseLayer
+	Opcode TFTPOpcode
+	// Filename, Mode and Options are set for RRQ and WRQ packets. OACK
+	// packets only carry Options.
+	Filename string
+	Mode     string
+	Options  []TFTPOption
+	// Block is set for DATA and ACK packets. The data of DATA packets is
+	// the layer payload.
+	Block uint16
+	// ErrorCode and ErrorMessage are set for ERROR packets.
+	ErrorCode    uint16
+	ErrorMessage string
+}
+
+// LayerType returns LayerTypeTFTP.
+func (t *TFTP) LayerType() gopacket.LayerType { return LayerTypeTFTP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (t *TFTP) CanDecode() gopacket.LayerClass { return LayerTypeTFTP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+// TFTP is an application layer; the data of DATA packets is returned by
+// Payload.
+func (t *TFTP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns the data of DATA packets.
+func (t *TFTP) Payload() []byte { return t.BaseLayer.Payload }
+
+// Option returns the value of the named option, compared case-insensitively
+// as RFC 2347 requires.
+func (t *TFTP) Option(name string) (string, bool) {
+	for _, o := range t.Options {
+		if strings.EqualFold(o.Name, name) {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// BlockSize returns the block size requested or acknowledged with the
+// blksize option, or TFTPDefaultBlockSize.
+func (t *TFTP) BlockSize() int {
+	if v, ok := t.Option("blksize"); ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 8 && n <= 65464 {
+			return n
+		}
+	}
+	return TFTPDefaultBlockSize
+}
+
+// tftpStrings splits data into NUL terminated strings.
+func tftpStrings(data []byte) ([]string, error) {
+	var out []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, 0)
+		if i < 0 {
+			return nil, errors.New("TFTP string not terminated")
+		}
+		out = append(out, string(data[:i]))
+		data = data[i+1:]
+	}
+	return out, nil
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (t *TFTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("TFTP packet too short")
+	}
+	*t = TFTP{Opcode: TFTPOpcode(binary.BigEndian.Uint16(data))}
+	t.Contents = data
+	switch t.Opcode {
+	case TFTPOpcodeRRQ, TFTPOpcodeWRQ, TFTPOpcodeOACK:
+		strs, err := tftpStrings(data[2:])
+		if err != nil {
+			return err
+		}
+		if t.Opcode != TFTPOpcodeOACK {
+			if len(strs) < 2 {
+				return errors.New("TFTP request without filename or mode")
+			}
+			t.Filename, t.Mode = strs[0], strs[1]
+			strs = strs[2:]
+		}
+		if len(strs)%2 != 0 {
+			return errors.New("TFTP option without value")
+		}
+		for i := 0; i < len(strs); i += 2 {
+			t.Options = append(t.Options, TFTPOption{Name: strs[i], Value: strs[i+1]})
+		}
+	case TFTPOpcodeDATA, TFTPOpcodeACK:
+		if len(data) < 4 {
+			df.SetTruncated()
+			return fmt.Errorf("TFTP %v packet too short", t.Opcode)
+		}
+		t.Block = binary.BigEndian.Uint16(data[2:4])
+		t.Contents, t.BaseLayer.Payload = data[:4], data[4:]
+	case TFTPOpcodeERROR:
+		if len(data) < 5 {
+			df.SetTruncated()
+			return errors.New("TFTP ERROR packet too short")
+		}
+		t.ErrorCode = binary.BigEndian.Uint16(data[2:4])
+		msg := data[4:]
+		if i := bytes.IndexByte(msg, 0); i >= 0 {
+			msg = msg[:i]
+		}
+		t.ErrorMessage = string(msg)
+	default:
+		return fmt.Errorf("unknown TFTP opcode %d", uint16(t.Opcode))
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer. The data of
+// DATA packets is the payload serialized before this layer.
+func (t *TFTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var body []byte
+	switch t.Opcode {
+	case TFTPOpcodeRRQ, TFTPOpcodeWRQ, TFTPOpcodeOACK:
+		if t.Opcode != TFTPOpcodeOACK {
+			body = append(append(body, t.Filename...), 0)
+			body = append(append(body, t.Mode...), 0)
+		}
+		for _, o := range t.Options {
+			body = append(append(body, o.Name...), 0)
+			body = append(append(body, o.Value...), 0)
+		}
+	case TFTPOpcodeDATA, TFTPOpcodeACK:
+		body = []byte{byte(t.Block >> 8), byte(t.Block)}
+	case TFTPOpcodeERROR:
+		body = append([]byte{byte(t.ErrorCode >> 8), byte(t.ErrorCode)}, t.ErrorMessage...)
+		body = append(body, 0)
+	default:
+		return fmt.Errorf("unknown TFTP opcode %d", uint16(t.Opcode))
+	}
+	bytes, err := b.PrependBytes(2 + len(body))
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes, uint16(t.Opcode))
+	copy(bytes[2:], body)
+	return nil
+}
+
+func decodeTFTP(data []byte, p gopacket.PacketBuilder) error {
+	t := &TFTP{}
+	if err := t.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(t)
+	p.SetApplicationLayer(t)
+	return nil
+}
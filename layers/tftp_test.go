@@ -0,0 +1,103 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testTFTPRRQ is a read request for "pxelinux.0" in octet mode with the
+// blksize and tsize options.
+var testTFTPRRQ = []byte{
+	0x00, 0x01, 'p', 'x', 'e', 'l', 'i', 'n', 'u', 'x', '.', '0', 0x00,
+	'o', 'c', 't', 'e', 't', 0x00,
+	'b', 'l', 'k', 's', 'i', 'z', 'e', 0x00, '1', '4', '6', '8', 0x00,
+	't', 's', 'i', 'z', 'e', 0x00, '0', 0x00,
+}
+
+func TestTFTPRequest(t *testing.T) {
+	var tftp TFTP
+	if err := tftp.DecodeFromBytes(testTFTPRRQ, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	want := TFTP{
+		BaseLayer: BaseLayer{Contents: testTFTPRRQ},
+		Opcode:    TFTPOpcodeRRQ,
+		Filename:  "pxelinux.0",
+		Mode:      "octet",
+		Options:   []TFTPOption{{"blksize", "1468"}, {"tsize", "0"}},
+	}
+	if !reflect.DeepEqual(tftp, want) {
+		t.Errorf("got %#v, want %#v", tftp, want)
+	}
+	if got := tftp.BlockSize(); got != 1468 {
+		t.Errorf("BlockSize() = %d, want 1468", got)
+	}
+	if v, ok := tftp.Option("TSize"); !ok || v != "0" {
+		t.Errorf(`Option("TSize") = %q, %v`, v, ok)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := tftp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testTFTPRRQ) {
+		t.Errorf("serialized %x, want %x", buf.Bytes(), testTFTPRRQ)
+	}
+}
+
+func TestTFTPPacket(t *testing.T) {
+	data := bytes.Repeat([]byte{0xaa}, 100)
+	eth := &Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: EthernetTypeIPv4,
+	}
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP,
+		SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 1024, DstPort: 69}
+	udp.SetNetworkLayerForChecksum(ip)
+	tftp := &TFTP{Opcode: TFTPOpcodeDATA, Block: 7}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, udp, tftp, gopacket.Payload(data)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeTFTP}, t)
+	got, ok := p.ApplicationLayer().(*TFTP)
+	if !ok {
+		t.Fatal("no TFTP application layer")
+	}
+	if got.Opcode != TFTPOpcodeDATA || got.Block != 7 || !bytes.Equal(got.Payload(), data) {
+		t.Errorf("got %v block %d with %d bytes", got.Opcode, got.Block, len(got.Payload()))
+	}
+}
+
+func TestTFTPError(t *testing.T) {
+	var tftp TFTP
+	err := tftp.DecodeFromBytes([]byte{0, 5, 0, 1, 'n', 'o', 't', ' ', 'f', 'o', 'u', 'n', 'd', 0}, gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tftp.ErrorCode != 1 || tftp.ErrorMessage != "not found" {
+		t.Errorf("got error %d %q", tftp.ErrorCode, tftp.ErrorMessage)
+	}
+	for _, bad := range [][]byte{{0}, {0, 3, 0}, {0, 1, 'a', 0}, {0, 1, 'a', 0, 'b'}, {0, 9}} {
+		if err := tftp.DecodeFromBytes(bad, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("decoded invalid packet %x", bad)
+		}
+	}
+}
@@ -95,6 +95,15 @@ func (u *UDP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	return nil
 }
 
+// VerifyChecksum verifies the checksum of the decoded packet. Over IPv4, a
+// zero checksum was not computed by the sender.
+func (u *UDP) VerifyChecksum() gopacket.ChecksumResult {
+	if _, ok := u.pseudoheader.(*IPv6); !ok && u.Checksum == 0 {
+		return gopacket.ChecksumResult{LayerType: LayerTypeUDP, Status: gopacket.ChecksumNotComputed}
+	}
+	return u.verifyChecksum(LayerTypeUDP, u.Contents, u.Payload, 6, IPProtocolUDP, u.Checksum)
+}
+
 func (u *UDP) CanDecode() gopacket.LayerClass {
 	return LayerTypeUDP
 }
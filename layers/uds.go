@@ -0,0 +1,227 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// UDSServiceID is the service of a UDS (ISO 14229) request.
+type UDSServiceID uint8
+
+const (
+	UDSServiceDiagnosticSessionControl   UDSServiceID = 0x10
+	UDSServiceECUReset                   UDSServiceID = 0x11
+	UDSServiceClearDiagnosticInformation UDSServiceID = 0x14
+	UDSServiceReadDTCInformation         UDSServiceID = 0x19
+	UDSServiceReadDataByIdentifier       UDSServiceID = 0x22
+	UDSServiceReadMemoryByAddress        UDSServiceID = 0x23
+	UDSServiceSecurityAccess             UDSServiceID = 0x27
+	UDSServiceCommunicationControl       UDSServiceID = 0x28
+	UDSServiceWriteDataByIdentifier      UDSServiceID = 0x2e
+	UDSServiceInputOutputControl         UDSServiceID = 0x2f
+	UDSServiceRoutineControl             UDSServiceID = 0x31
+	UDSServiceRequestDownload            UDSServiceID = 0x34
+	UDSServiceRequestUpload              UDSServiceID = 0x35
+	UDSServiceTransferData               UDSServiceID = 0x36
+	UDSServiceRequestTransferExit        UDSServiceID = 0x37
+	UDSServiceWriteMemoryByAddress       UDSServiceID = 0x3d
+	UDSServiceTesterPresent              UDSServiceID = 0x3e
+	UDSServiceControlDTCSetting          UDSServiceID = 0x85
+)
+
+func (s UDSServiceID) String() string {
+	switch s {
+	case UDSServiceDiagnosticSessionControl:
+		return "DiagnosticSessionControl"
+	case UDSServiceECUReset:
+		return "ECUReset"
+	case UDSServiceClearDiagnosticInformation:
+		return "ClearDiagnosticInformation"
+	case UDSServiceReadDTCInformation:
+		return "ReadDTCInformation"
+	case UDSServiceReadDataByIdentifier:
+		return "ReadDataByIdentifier"
+	case UDSServiceReadMemoryByAddress:
+		return "ReadMemoryByAddress"
+	case UDSServiceSecurityAccess:
+		return "SecurityAccess"
+	case UDSServiceCommunicationControl:
+		return "CommunicationControl"
+	case UDSServiceWriteDataByIdentifier:
+		return "WriteDataByIdentifier"
+	case UDSServiceInputOutputControl:
+		return "InputOutputControlByIdentifier"
+	case UDSServiceRoutineControl:
+		return "RoutineControl"
+	case UDSServiceRequestDownload:
+		return "RequestDownload"
+	case UDSServiceRequestUpload:
+		return "RequestUpload"
+	case UDSServiceTransferData:
+		return "TransferData"
+	case UDSServiceRequestTransferExit:
+		return "RequestTransferExit"
+	case UDSServiceWriteMemoryByAddress:
+		return "WriteMemoryByAddress"
+	case UDSServiceTesterPresent:
+		return "TesterPresent"
+	case UDSServiceControlDTCSetting:
+		return "ControlDTCSetting"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(s))
+	}
+}
+
+// UDSResponseCode is the code of a negative UDS response.
+type UDSResponseCode uint8
+
+const (
+	UDSResponseGeneralReject             UDSResponseCode = 0x10
+	UDSResponseServiceNotSupported       UDSResponseCode = 0x11
+	UDSResponseSubFunctionNotSupported   UDSResponseCode = 0x12
+	UDSResponseIncorrectMessageLength    UDSResponseCode = 0x13
+	UDSResponseBusyRepeatRequest         UDSResponseCode = 0x21
+	UDSResponseConditionsNotCorrect      UDSResponseCode = 0x22
+	UDSResponseRequestSequenceError      UDSResponseCode = 0x24
+	UDSResponseRequestOutOfRange         UDSResponseCode = 0x31
+	UDSResponseSecurityAccessDenied      UDSResponseCode = 0x33
+	UDSResponseInvalidKey                UDSResponseCode = 0x35
+	UDSResponseExceededNumberOfAttempts  UDSResponseCode = 0x36
+	UDSResponseRequiredTimeDelayNotEnded UDSResponseCode = 0x37
+	UDSResponsePending                   UDSResponseCode = 0x78
+	UDSResponseServiceNotInSession       UDSResponseCode = 0x7f
+)
+
+func (c UDSResponseCode) String() string {
+	switch c {
+	case UDSResponseGeneralReject:
+		return "GeneralReject"
+	case UDSResponseServiceNotSupported:
+		return "ServiceNotSupported"
+	case UDSResponseSubFunctionNotSupported:
+		return "SubFunctionNotSupported"
+	case UDSResponseIncorrectMessageLength:
+		return "IncorrectMessageLengthOrInvalidFormat"
+	case UDSResponseBusyRepeatRequest:
+		return "BusyRepeatRequest"
+	case UDSResponseConditionsNotCorrect:
+		return "ConditionsNotCorrect"
+	case UDSResponseRequestSequenceError:
+		return "RequestSequenceError"
+	case UDSResponseRequestOutOfRange:
+		return "RequestOutOfRange"
+	case UDSResponseSecurityAccessDenied:
+		return "SecurityAccessDenied"
+	case UDSResponseInvalidKey:
+		return "InvalidKey"
+	case UDSResponseExceededNumberOfAttempts:
+		return "ExceededNumberOfAttempts"
+	case UDSResponseRequiredTimeDelayNotEnded:
+		return "RequiredTimeDelayNotExpired"
+	case UDSResponsePending:
+		return "RequestCorrectlyReceivedResponsePending"
+	case UDSResponseServiceNotInSession:
+		return "ServiceNotSupportedInActiveSession"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(c))
+	}
+}
+
+const (
+	udsResponseFlag     = 0x40
+	udsNegativeResponse = 0x7f
+)
+
+// UDS is a Unified Diagnostic Services (ISO 14229) request or response, as
+// carried by DoIP diagnostic messages. Its payload is the parameters of the
+// service, starting with its sub-function or data identifier, if any.
+type UDS struct {
+	BaseLayer
+	// ServiceID is the service of requests, and that of the requests
+	// responses answer, without the flag of positive responses.
+	ServiceID UDSServiceID
+	Response  bool
+	// NegativeResponse is set for responses telling the ResponseCode of a
+	// request which failed.
+	NegativeResponse bool
+	ResponseCode     UDSResponseCode
+}
+
+// LayerType returns LayerTypeUDS.
+func (u *UDS) LayerType() gopacket.LayerType { return LayerTypeUDS }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (u *UDS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return fmt.Errorf("UDS length %d too short, 1 required", len(data))
+	}
+	u.ResponseCode = 0
+	if data[0] == udsNegativeResponse {
+		if len(data) < 3 {
+			df.SetTruncated()
+			return fmt.Errorf("UDS negative response length %d too short, 3 required", len(data))
+		}
+		u.ServiceID = UDSServiceID(data[1])
+		u.Response = true
+		u.NegativeResponse = true
+		u.ResponseCode = UDSResponseCode(data[2])
+		u.BaseLayer = BaseLayer{Contents: data[:3], Payload: data[3:]}
+		return nil
+	}
+	u.ServiceID = UDSServiceID(data[0] &^ udsResponseFlag)
+	u.Response = data[0]&udsResponseFlag != 0
+	u.NegativeResponse = false
+	u.BaseLayer = BaseLayer{Contents: data[:1], Payload: data[1:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (u *UDS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if u.NegativeResponse {
+		bytes, err := b.PrependBytes(3)
+		if err != nil {
+			return err
+		}
+		bytes[0] = udsNegativeResponse
+		bytes[1] = uint8(u.ServiceID)
+		bytes[2] = uint8(u.ResponseCode)
+		return nil
+	}
+	bytes, err := b.PrependBytes(1)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(u.ServiceID)
+	if u.Response {
+		bytes[0] |= udsResponseFlag
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (u *UDS) CanDecode() gopacket.LayerClass {
+	return LayerTypeUDS
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (u *UDS) NextLayerType() gopacket.LayerType {
+	if len(u.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeUDS(data []byte, p gopacket.PacketBuilder) error {
+	u := &UDS{}
+	return decodingLayerDecoder(u, data, p)
+}
@@ -8,7 +8,8 @@ package layers
 
 import (
 	"encoding/binary"
-	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -45,6 +46,8 @@ const (
 	USBRequestBlockSetupRequestGetConfiguration USBRequestBlockSetupRequest = 0x08
 	USBRequestBlockSetupRequestSetConfiguration USBRequestBlockSetupRequest = 0x09
 	USBRequestBlockSetupRequestSetIdle          USBRequestBlockSetupRequest = 0x0a
+	USBRequestBlockSetupRequestSetInterface     USBRequestBlockSetupRequest = 0x0b
+	USBRequestBlockSetupRequestSynchFrame       USBRequestBlockSetupRequest = 0x0c
 )
 
 func (a USBRequestBlockSetupRequest) String() string {
@@ -67,6 +70,10 @@ func (a USBRequestBlockSetupRequest) String() string {
 		return "SET_CONFIGURATION"
 	case USBRequestBlockSetupRequestSetIdle:
 		return "SET_IDLE"
+	case USBRequestBlockSetupRequestSetInterface:
+		return "SET_INTERFACE"
+	case USBRequestBlockSetupRequestSynchFrame:
+		return "SYNCH_FRAME"
 	default:
 		return "UNKNOWN"
 	}
@@ -101,6 +108,11 @@ func (a USBDirectionType) String() string {
 	}
 }
 
+// USB is the header usbmon prepends to the USB request blocks it captures
+// on Linux, of 64 bytes for LINKTYPE_USB_LINUX_MMAPPED and of 48 bytes for
+// LINKTYPE_USB_LINUX. The setup packet of control transfers, which the
+// header holds, is the start of its payload.
+//
 // The reference at http://www.beyondlogic.org/usbnutshell/usb1.shtml contains more information about the protocol.
 type USB struct {
 	BaseLayer
@@ -123,6 +135,10 @@ type USB struct {
 	UrbStartFrame          uint32
 	UrbCopyOfTransferFlags uint32
 	IsoNumDesc             uint32
+
+	// ShortHeader is set to decode the 48 byte header of
+	// LINKTYPE_USB_LINUX, which lacks the fields from UrbInterval on.
+	ShortHeader bool
 }
 
 func (u *USB) LayerType() gopacket.LayerType { return LayerTypeUSB }
@@ -130,10 +146,14 @@ func (u *USB) LayerType() gopacket.LayerType { return LayerTypeUSB }
 func (m *USB) NextLayerType() gopacket.LayerType {
 	if m.Setup {
 		return LayerTypeUSBRequestBlockSetup
-	} else if m.Data {
 	}
-
-	return m.TransferType.LayerType()
+	if t := m.TransferType.LayerType(); t != gopacket.LayerTypeZero {
+		return t
+	}
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
 }
 
 func decodeUSB(data []byte, p gopacket.PacketBuilder) error {
@@ -142,10 +162,23 @@ func decodeUSB(data []byte, p gopacket.PacketBuilder) error {
 	return decodingLayerDecoder(d, data, p)
 }
 
+func decodeUSB48(data []byte, p gopacket.PacketBuilder) error {
+	d := &USB{ShortHeader: true}
+	return decodingLayerDecoder(d, data, p)
+}
+
+// usbIsoDescriptorLength is the length of the descriptors of the packets of
+// isochronous transfers, which follow the header.
+const usbIsoDescriptorLength = 16
+
 func (m *USB) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	if len(data) < 40 {
+	length := 64
+	if m.ShortHeader {
+		length = 48
+	}
+	if len(data) < length {
 		df.SetTruncated()
-		return errors.New("USB < 40 bytes")
+		return fmt.Errorf("USB length %d too short, %d required", len(data), length)
 	}
 	m.ID = binary.LittleEndian.Uint64(data[0:8])
 	m.EventType = USBEventType(data[8])
@@ -161,13 +194,9 @@ func (m *USB) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	m.DeviceAddress = data[11]
 	m.BusID = binary.LittleEndian.Uint16(data[12:14])
 
-	if uint(data[14]) == 0 {
-		m.Setup = true
-	}
-
-	if uint(data[15]) == 0 {
-		m.Data = true
-	}
+	// The flags are zero if the setup packet and the data are present.
+	m.Setup = data[14] == 0
+	m.Data = data[15] == 0
 
 	m.TimestampSec = int64(binary.LittleEndian.Uint64(data[16:24]))
 	m.TimestampUsec = int32(binary.LittleEndian.Uint32(data[24:28]))
@@ -175,23 +204,43 @@ func (m *USB) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	m.UrbLength = binary.LittleEndian.Uint32(data[32:36])
 	m.UrbDataLength = binary.LittleEndian.Uint32(data[36:40])
 
-	m.Contents = data[:40]
-	m.Payload = data[40:]
-
-	if m.Setup {
-		m.Payload = data[40:]
-	} else if m.Data {
-		m.Payload = data[uint32(len(data))-m.UrbDataLength:]
+	// Bytes 40 to 48 hold the setup packet, or the error count and the
+	// number of descriptors of isochronous transfers.
+	m.UrbInterval, m.UrbStartFrame, m.UrbCopyOfTransferFlags, m.IsoNumDesc = 0, 0, 0, 0
+	if !m.ShortHeader {
+		m.UrbInterval = binary.LittleEndian.Uint32(data[48:52])
+		m.UrbStartFrame = binary.LittleEndian.Uint32(data[52:56])
+		m.UrbCopyOfTransferFlags = binary.LittleEndian.Uint32(data[56:60])
+		m.IsoNumDesc = binary.LittleEndian.Uint32(data[60:64])
+	} else if m.TransferType == USBTransportTypeIsochronous {
+		m.IsoNumDesc = binary.LittleEndian.Uint32(data[44:48])
+	}
+	if m.TransferType == USBTransportTypeIsochronous {
+		if uint64(m.IsoNumDesc)*usbIsoDescriptorLength > uint64(len(data)-length) {
+			df.SetTruncated()
+			return fmt.Errorf("USB %d isochronous descriptors exceed %d remaining bytes", m.IsoNumDesc, len(data)-length)
+		}
+		length += int(m.IsoNumDesc) * usbIsoDescriptorLength
 	}
 
-	// if 64 bit, dissect_linux_usb_pseudo_header_ext
-	if false {
-		m.UrbInterval = binary.LittleEndian.Uint32(data[40:44])
-		m.UrbStartFrame = binary.LittleEndian.Uint32(data[44:48])
-		m.UrbDataLength = binary.LittleEndian.Uint32(data[48:52])
-		m.IsoNumDesc = binary.LittleEndian.Uint32(data[52:56])
-		m.Contents = data[:56]
-		m.Payload = data[56:]
+	payload := data[length:]
+	if uint32(len(payload)) > m.UrbDataLength {
+		payload = payload[:m.UrbDataLength]
+	} else if uint32(len(payload)) < m.UrbDataLength {
+		df.SetTruncated()
+	}
+	m.Contents = data[:length]
+	m.Payload = payload
+	if m.Setup {
+		if m.ShortHeader {
+			m.Payload = data[40 : length+len(payload)]
+		} else {
+			// The memory mapped header separates the setup packet
+			// from the data of the transfer.
+			m.Payload = make([]byte, 8+len(payload))
+			copy(m.Payload, data[40:48])
+			copy(m.Payload[8:], payload)
+		}
 	}
 
 	// crc5 or crc16
@@ -200,6 +249,57 @@ func (m *USB) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	return nil
 }
 
+// USBRequestBlockSetupType is the type of a request, told by bits 5 and 6
+// of its RequestType.
+type USBRequestBlockSetupType uint8
+
+const (
+	USBRequestBlockSetupTypeStandard USBRequestBlockSetupType = 0
+	USBRequestBlockSetupTypeClass    USBRequestBlockSetupType = 1
+	USBRequestBlockSetupTypeVendor   USBRequestBlockSetupType = 2
+)
+
+func (a USBRequestBlockSetupType) String() string {
+	switch a {
+	case USBRequestBlockSetupTypeStandard:
+		return "Standard"
+	case USBRequestBlockSetupTypeClass:
+		return "Class"
+	case USBRequestBlockSetupTypeVendor:
+		return "Vendor"
+	default:
+		return "Unknown request type"
+	}
+}
+
+// USBRequestBlockSetupRecipient is the recipient of a request, told by the
+// low bits of its RequestType.
+type USBRequestBlockSetupRecipient uint8
+
+const (
+	USBRequestBlockSetupRecipientDevice    USBRequestBlockSetupRecipient = 0
+	USBRequestBlockSetupRecipientInterface USBRequestBlockSetupRecipient = 1
+	USBRequestBlockSetupRecipientEndpoint  USBRequestBlockSetupRecipient = 2
+	USBRequestBlockSetupRecipientOther     USBRequestBlockSetupRecipient = 3
+)
+
+func (a USBRequestBlockSetupRecipient) String() string {
+	switch a {
+	case USBRequestBlockSetupRecipientDevice:
+		return "Device"
+	case USBRequestBlockSetupRecipientInterface:
+		return "Interface"
+	case USBRequestBlockSetupRecipientEndpoint:
+		return "Endpoint"
+	case USBRequestBlockSetupRecipientOther:
+		return "Other"
+	default:
+		return "Unknown recipient"
+	}
+}
+
+// USBRequestBlockSetup is the setup packet of a control transfer. Its
+// payload is the data the host sends with the request, if any.
 type USBRequestBlockSetup struct {
 	BaseLayer
 	RequestType uint8
@@ -212,10 +312,17 @@ type USBRequestBlockSetup struct {
 func (u *USBRequestBlockSetup) LayerType() gopacket.LayerType { return LayerTypeUSBRequestBlockSetup }
 
 func (m *USBRequestBlockSetup) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
 	return gopacket.LayerTypePayload
 }
 
 func (m *USBRequestBlockSetup) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return fmt.Errorf("USB setup packet length %d too short, 8 required", len(data))
+	}
 	m.RequestType = data[0]
 	m.Request = USBRequestBlockSetupRequest(data[1])
 	m.Value = binary.LittleEndian.Uint16(data[2:4])
@@ -226,11 +333,38 @@ func (m *USBRequestBlockSetup) DecodeFromBytes(data []byte, df gopacket.DecodeFe
 	return nil
 }
 
+// Direction returns the direction of the data stage of the request.
+func (m *USBRequestBlockSetup) Direction() USBDirectionType {
+	if m.RequestType&uint8(USBTransportTypeTransferIn) != 0 {
+		return USBDirectionTypeIn
+	}
+	return USBDirectionTypeOut
+}
+
+// Type returns whether the request is a standard, class or vendor one.
+func (m *USBRequestBlockSetup) Type() USBRequestBlockSetupType {
+	return USBRequestBlockSetupType(m.RequestType>>5) & 0x3
+}
+
+// Recipient returns the recipient of the request.
+func (m *USBRequestBlockSetup) Recipient() USBRequestBlockSetupRecipient {
+	return USBRequestBlockSetupRecipient(m.RequestType & 0x1f)
+}
+
+// DescriptorType returns the type and the index of the descriptor that a
+// standard GET_DESCRIPTOR or SET_DESCRIPTOR request asks for. The Index of
+// the request is the language ID of string descriptors.
+func (m *USBRequestBlockSetup) DescriptorType() (USBDescriptorType, uint8) {
+	return USBDescriptorType(m.Value >> 8), uint8(m.Value)
+}
+
 func decodeUSBRequestBlockSetup(data []byte, p gopacket.PacketBuilder) error {
 	d := &USBRequestBlockSetup{}
 	return decodingLayerDecoder(d, data, p)
 }
 
+// USBControl is a control transfer past its setup stage. Its payload is the
+// data of the transfer, such as the descriptors a device returns.
 type USBControl struct {
 	BaseLayer
 }
@@ -238,11 +372,14 @@ type USBControl struct {
 func (u *USBControl) LayerType() gopacket.LayerType { return LayerTypeUSBControl }
 
 func (m *USBControl) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
 	return gopacket.LayerTypePayload
 }
 
 func (m *USBControl) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	m.Contents = data
+	m.BaseLayer = BaseLayer{Contents: data[:0], Payload: data}
 	return nil
 }
 
@@ -251,6 +388,8 @@ func decodeUSBControl(data []byte, p gopacket.PacketBuilder) error {
 	return decodingLayerDecoder(d, data, p)
 }
 
+// USBInterrupt is an interrupt transfer. Its payload is the data of the
+// transfer, such as the input reports of HID devices.
 type USBInterrupt struct {
 	BaseLayer
 }
@@ -258,11 +397,14 @@ type USBInterrupt struct {
 func (u *USBInterrupt) LayerType() gopacket.LayerType { return LayerTypeUSBInterrupt }
 
 func (m *USBInterrupt) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
 	return gopacket.LayerTypePayload
 }
 
 func (m *USBInterrupt) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	m.Contents = data
+	m.BaseLayer = BaseLayer{Contents: data[:0], Payload: data}
 	return nil
 }
 
@@ -271,6 +413,7 @@ func decodeUSBInterrupt(data []byte, p gopacket.PacketBuilder) error {
 	return decodingLayerDecoder(d, data, p)
 }
 
+// USBBulk is a bulk transfer. Its payload is the data of the transfer.
 type USBBulk struct {
 	BaseLayer
 }
@@ -278,11 +421,14 @@ type USBBulk struct {
 func (u *USBBulk) LayerType() gopacket.LayerType { return LayerTypeUSBBulk }
 
 func (m *USBBulk) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
 	return gopacket.LayerTypePayload
 }
 
 func (m *USBBulk) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	m.Contents = data
+	m.BaseLayer = BaseLayer{Contents: data[:0], Payload: data}
 	return nil
 }
 
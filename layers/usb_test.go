@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	_ "fmt"
 	"github.com/google/gopacket"
 	"reflect"
@@ -41,7 +42,7 @@ func TestPacketUSB0(t *testing.T) {
 	if got, ok := p.Layer(LayerTypeUSB).(*USB); ok {
 		want := &USB{
 			BaseLayer: BaseLayer{
-				Contents: []uint8{0x0, 0x38, 0x4a, 0x3b, 0x0, 0x88, 0xff, 0xff, 0x43, 0x1, 0x81, 0x1, 0x2, 0x0, 0x2d, 0x0, 0xc0, 0xd3, 0x5b, 0x50, 0x0, 0x0, 0x0, 0x0, 0x8a, 0x85, 0xa, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0},
+				Contents: testPacketUSB0[:64],
 				Payload:  []uint8{0x4},
 			},
 			ID:             0xffff88003b4a3800,
@@ -58,6 +59,9 @@ func TestPacketUSB0(t *testing.T) {
 			Status:         0,
 			UrbLength:      0x1,
 			UrbDataLength:  0x1,
+
+			UrbInterval:            0x80,
+			UrbCopyOfTransferFlags: 0x200,
 		}
 
 		if !reflect.DeepEqual(got, want) {
@@ -71,3 +75,197 @@ func BenchmarkDecodePacketUSB0(b *testing.B) {
 		gopacket.NewPacket(testPacketUSB0, LinkTypeLinuxUSB, gopacket.NoCopy)
 	}
 }
+
+// A GET_DESCRIPTOR request for the device descriptor, and its completion
+// with the descriptor.
+var testPacketUSBGetDescriptor = []byte{
+	0x00, 0x9e, 0x2d, 0x3b, 0x00, 0x88, 0xff, 0xff, 0x53, 0x02, 0x80, 0x05, 0x01, 0x00, 0x00, 0x3c,
+	0xc0, 0xd3, 0x5b, 0x50, 0x00, 0x00, 0x00, 0x00, 0x8a, 0x85, 0x0a, 0x00, 0x8d, 0xff, 0xff, 0xff,
+	0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0x06, 0x00, 0x01, 0x00, 0x00, 0x12, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+var testPacketUSBDeviceDescriptor = []byte{
+	0x00, 0x9e, 0x2d, 0x3b, 0x00, 0x88, 0xff, 0xff, 0x43, 0x02, 0x80, 0x05, 0x01, 0x00, 0x2d, 0x00,
+	0xc0, 0xd3, 0x5b, 0x50, 0x00, 0x00, 0x00, 0x00, 0x9a, 0x85, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x12, 0x00, 0x00, 0x00, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x12, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x08, 0x6d, 0x04, 0x77, 0xc0, 0x00, 0x72, 0x01, 0x02,
+	0x00, 0x01,
+}
+
+func TestPacketUSBControl(t *testing.T) {
+	p := gopacket.NewPacket(testPacketUSBGetDescriptor, LinkTypeLinuxUSB, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB, LayerTypeUSBRequestBlockSetup}, t)
+	s := p.Layer(LayerTypeUSBRequestBlockSetup).(*USBRequestBlockSetup)
+	if dt, i := s.DescriptorType(); s.Request != USBRequestBlockSetupRequestGetDescriptor || s.Direction() != USBDirectionTypeIn ||
+		s.Type() != USBRequestBlockSetupTypeStandard || s.Recipient() != USBRequestBlockSetupRecipientDevice ||
+		dt != USBDescriptorTypeDevice || i != 0 || s.Length != 18 || len(s.Payload) != 0 {
+		t.Errorf("got %+v", s)
+	}
+
+	p = gopacket.NewPacket(testPacketUSBDeviceDescriptor, LinkTypeLinuxUSB, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB, LayerTypeUSBControl, gopacket.LayerTypePayload}, t)
+	ds, err := DecodeUSBDescriptors(p.ApplicationLayer().Payload())
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("got %+v, %v", ds, err)
+	}
+	d, err := ds[0].Device()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.USBVersion != 0x0200 || d.MaxPacketSize0 != 8 || d.VendorID != 0x046d || d.ProductID != 0xc077 ||
+		d.DeviceVersion != 0x7200 || d.ProductIndex != 2 || d.NumConfigurations != 1 {
+		t.Errorf("got %+v", d)
+	}
+
+	p = gopacket.NewPacket(testPacketUSBDeviceDescriptor[:60], LinkTypeLinuxUSB, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated header")
+	}
+}
+
+func TestPacketUSB48(t *testing.T) {
+	// A SET_REPORT request of a HID device with its report, with the 48
+	// byte header.
+	data := []byte{
+		0x00, 0x9e, 0x2d, 0x3b, 0x00, 0x88, 0xff, 0xff, 0x53, 0x02, 0x00, 0x05, 0x01, 0x00, 0x00, 0x00,
+		0xc0, 0xd3, 0x5b, 0x50, 0x00, 0x00, 0x00, 0x00, 0x8a, 0x85, 0x0a, 0x00, 0x8d, 0xff, 0xff, 0xff,
+		0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x21, 0x09, 0x00, 0x02, 0x00, 0x00, 0x01, 0x00,
+		0x02,
+	}
+	p := gopacket.NewPacket(data, LinkTypeLinuxUSB48, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB, LayerTypeUSBRequestBlockSetup, gopacket.LayerTypePayload}, t)
+	u := p.Layer(LayerTypeUSB).(*USB)
+	if !u.ShortHeader || !u.Setup || !u.Data || u.Direction != USBDirectionTypeOut || u.UrbDataLength != 1 || len(u.Contents) != 48 {
+		t.Errorf("got %+v", u)
+	}
+	s := p.Layer(LayerTypeUSBRequestBlockSetup).(*USBRequestBlockSetup)
+	if s.Request != 0x09 /* SET_REPORT */ || s.Type() != USBRequestBlockSetupTypeClass ||
+		s.Recipient() != USBRequestBlockSetupRecipientInterface || s.Direction() != USBDirectionTypeOut ||
+		!bytes.Equal(s.Payload, []byte{0x02}) {
+		t.Errorf("got %+v", s)
+	}
+}
+
+func TestUSBPcap(t *testing.T) {
+	// A bulk transfer of 4 bytes out to endpoint 2.
+	data := []byte{
+		0x1b, 0x00, 0x10, 0x20, 0x30, 0x40, 0x00, 0x80, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x09, 0x00,
+		0x00, 0x01, 0x00, 0x03, 0x00, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00,
+		0xde, 0xad, 0xbe, 0xef,
+	}
+	p := gopacket.NewPacket(data, LinkTypeUSBPcap, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSBPcap, LayerTypeUSBBulk, gopacket.LayerTypePayload}, t)
+	u := p.Layer(LayerTypeUSBPcap).(*USBPcap)
+	if u.HeaderLength != 27 || u.IRPID != 0xffff800040302010 || u.Function != 9 || u.EventType != USBEventTypeSubmit ||
+		u.BusID != 1 || u.DeviceAddress != 3 || u.EndpointNumber != 2 || u.Direction != USBDirectionTypeOut ||
+		u.TransferType != USBTransportTypeBulk || u.DataLength != 4 {
+		t.Errorf("got %+v", u)
+	}
+	if !bytes.Equal(p.ApplicationLayer().Payload(), []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("payload %x", p.ApplicationLayer().Payload())
+	}
+
+	// The setup stage of a GET_DESCRIPTOR request for the language IDs.
+	data = []byte{
+		0x1c, 0x00, 0x10, 0x20, 0x30, 0x40, 0x00, 0x80, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x0b, 0x00,
+		0x00, 0x01, 0x00, 0x03, 0x00, 0x80, 0x02, 0x08, 0x00, 0x00, 0x00, 0x00,
+		0x80, 0x06, 0x00, 0x03, 0x00, 0x00, 0xff, 0x00,
+	}
+	p = gopacket.NewPacket(data, LinkTypeUSBPcap, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal(p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSBPcap, LayerTypeUSBRequestBlockSetup}, t)
+	u = p.Layer(LayerTypeUSBPcap).(*USBPcap)
+	if u.Stage != USBPcapControlStageSetup || u.Direction != USBDirectionTypeIn {
+		t.Errorf("got %+v", u)
+	}
+	if dt, _ := p.Layer(LayerTypeUSBRequestBlockSetup).(*USBRequestBlockSetup).DescriptorType(); dt != USBDescriptorTypeString {
+		t.Errorf("descriptor type %v", dt)
+	}
+
+	p = gopacket.NewPacket(data[:20], LinkTypeUSBPcap, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Error("no error decoding truncated header")
+	}
+}
+
+func TestUSBDescriptors(t *testing.T) {
+	// The configuration of a HID mouse.
+	data := []byte{
+		0x09, 0x02, 0x22, 0x00, 0x01, 0x01, 0x00, 0xa0, 0x32,
+		0x09, 0x04, 0x00, 0x00, 0x01, 0x03, 0x01, 0x02, 0x00,
+		0x09, 0x21, 0x11, 0x01, 0x00, 0x01, 0x22, 0x34, 0x00,
+		0x07, 0x05, 0x81, 0x03, 0x04, 0x00, 0x0a,
+	}
+	ds, err := DecodeUSBDescriptors(data)
+	if err != nil || len(ds) != 4 {
+		t.Fatalf("got %+v, %v", ds, err)
+	}
+	c, err := ds[0].Configuration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TotalLength != 34 || c.NumInterfaces != 1 || c.ConfigurationValue != 1 || c.Attributes != 0xa0 || c.MaxPower != 50 {
+		t.Errorf("configuration %+v", c)
+	}
+	i, err := ds[1].Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.NumEndpoints != 1 || i.Class != 3 || i.SubClass != 1 || i.Protocol != 2 {
+		t.Errorf("interface %+v", i)
+	}
+	h, err := ds[2].HID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.HIDVersion != 0x0111 || len(h.Descriptors) != 1 || h.Descriptors[0] != (USBHIDClassDescriptor{USBDescriptorTypeHIDReport, 52}) {
+		t.Errorf("HID %+v", h)
+	}
+	e, err := ds[3].Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Number() != 1 || e.Direction() != USBDirectionTypeIn || e.TransferType() != USBTransportTypeInterrupt ||
+		e.MaxPacketSize != 4 || e.Interval != 10 {
+		t.Errorf("endpoint %+v", e)
+	}
+	if _, err := ds[3].Interface(); err == nil {
+		t.Error("decoded an endpoint descriptor as an interface descriptor")
+	}
+
+	ds, err = DecodeUSBDescriptors([]byte{0x0a, 0x03, 'L', 0x00, 'o', 0x00, 'g', 0x00, 'i', 0x00})
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("got %+v, %v", ds, err)
+	}
+	if s, err := ds[0].Text(); err != nil || s != "Logi" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	// The first 8 bytes of a device descriptor.
+	ds, err = DecodeUSBDescriptors(testPacketUSBDeviceDescriptor[64:72])
+	if err != nil || len(ds) != 1 || ds[0].Type != USBDescriptorTypeDevice {
+		t.Fatalf("got %+v, %v", ds, err)
+	}
+	if _, err := ds[0].Device(); err == nil {
+		t.Error("no error decoding truncated device descriptor")
+	}
+	if _, err := DecodeUSBDescriptors([]byte{0x01, 0x02}); err == nil {
+		t.Error("no error decoding invalid descriptor length")
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// USBDescriptorType is the type of a USB descriptor.
+type USBDescriptorType uint8
+
+const (
+	USBDescriptorTypeDevice                  USBDescriptorType = 0x01
+	USBDescriptorTypeConfiguration           USBDescriptorType = 0x02
+	USBDescriptorTypeString                  USBDescriptorType = 0x03
+	USBDescriptorTypeInterface               USBDescriptorType = 0x04
+	USBDescriptorTypeEndpoint                USBDescriptorType = 0x05
+	USBDescriptorTypeDeviceQualifier         USBDescriptorType = 0x06
+	USBDescriptorTypeOtherSpeedConfiguration USBDescriptorType = 0x07
+	USBDescriptorTypeInterfaceAssociation    USBDescriptorType = 0x0b
+	USBDescriptorTypeBOS                     USBDescriptorType = 0x0f
+	USBDescriptorTypeHID                     USBDescriptorType = 0x21
+	USBDescriptorTypeHIDReport               USBDescriptorType = 0x22
+	USBDescriptorTypeClassInterface          USBDescriptorType = 0x24
+	USBDescriptorTypeClassEndpoint           USBDescriptorType = 0x25
+)
+
+func (a USBDescriptorType) String() string {
+	switch a {
+	case USBDescriptorTypeDevice:
+		return "Device"
+	case USBDescriptorTypeConfiguration:
+		return "Configuration"
+	case USBDescriptorTypeString:
+		return "String"
+	case USBDescriptorTypeInterface:
+		return "Interface"
+	case USBDescriptorTypeEndpoint:
+		return "Endpoint"
+	case USBDescriptorTypeDeviceQualifier:
+		return "Device Qualifier"
+	case USBDescriptorTypeOtherSpeedConfiguration:
+		return "Other Speed Configuration"
+	case USBDescriptorTypeInterfaceAssociation:
+		return "Interface Association"
+	case USBDescriptorTypeBOS:
+		return "BOS"
+	case USBDescriptorTypeHID:
+		return "HID"
+	case USBDescriptorTypeHIDReport:
+		return "HID Report"
+	case USBDescriptorTypeClassInterface:
+		return "Class Specific Interface"
+	case USBDescriptorTypeClassEndpoint:
+		return "Class Specific Endpoint"
+	default:
+		return "Unknown descriptor type"
+	}
+}
+
+// USBDescriptor is one of the descriptors a device returns in the data
+// stage of a GET_DESCRIPTOR request, such as a configuration descriptor
+// and the interface and endpoint descriptors following it. Data is the
+// descriptor without its length and type.
+type USBDescriptor struct {
+	Type USBDescriptorType
+	Data []byte
+}
+
+// DecodeUSBDescriptors decodes the descriptors of the data of a control
+// transfer, but for HID report descriptors, which are not framed as the
+// others and are the whole data. A last descriptor cut short, as in the
+// replies to requests for the first bytes of a device descriptor, is
+// returned with the bytes there are.
+func DecodeUSBDescriptors(data []byte) ([]USBDescriptor, error) {
+	var descriptors []USBDescriptor
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return descriptors, fmt.Errorf("USB descriptor length %d too short, 2 required", len(data))
+		}
+		length := int(data[0])
+		if length < 2 {
+			return descriptors, fmt.Errorf("USB descriptor length %d invalid", length)
+		}
+		if length > len(data) {
+			length = len(data)
+		}
+		descriptors = append(descriptors, USBDescriptor{Type: USBDescriptorType(data[1]), Data: data[2:length]})
+		data = data[length:]
+	}
+	return descriptors, nil
+}
+
+func (d *USBDescriptor) check(t USBDescriptorType, length int) error {
+	if d.Type != t {
+		return fmt.Errorf("USB descriptor of type %v, not %v", d.Type, t)
+	}
+	if len(d.Data) < length {
+		return fmt.Errorf("USB %v descriptor length %d too short, %d required", t, len(d.Data)+2, length+2)
+	}
+	return nil
+}
+
+// USBDeviceDescriptor is the descriptor of a device. Its versions are
+// binary coded decimals, 0x0200 standing for 2.00.
+type USBDeviceDescriptor struct {
+	USBVersion        uint16
+	Class             uint8
+	SubClass          uint8
+	Protocol          uint8
+	MaxPacketSize0    uint8
+	VendorID          uint16
+	ProductID         uint16
+	DeviceVersion     uint16
+	ManufacturerIndex uint8
+	ProductIndex      uint8
+	SerialNumberIndex uint8
+	NumConfigurations uint8
+}
+
+// Device decodes a device descriptor.
+func (d *USBDescriptor) Device() (*USBDeviceDescriptor, error) {
+	if err := d.check(USBDescriptorTypeDevice, 16); err != nil {
+		return nil, err
+	}
+	return &USBDeviceDescriptor{
+		USBVersion:        binary.LittleEndian.Uint16(d.Data[0:2]),
+		Class:             d.Data[2],
+		SubClass:          d.Data[3],
+		Protocol:          d.Data[4],
+		MaxPacketSize0:    d.Data[5],
+		VendorID:          binary.LittleEndian.Uint16(d.Data[6:8]),
+		ProductID:         binary.LittleEndian.Uint16(d.Data[8:10]),
+		DeviceVersion:     binary.LittleEndian.Uint16(d.Data[10:12]),
+		ManufacturerIndex: d.Data[12],
+		ProductIndex:      d.Data[13],
+		SerialNumberIndex: d.Data[14],
+		NumConfigurations: d.Data[15],
+	}, nil
+}
+
+// USBConfigurationDescriptor is the descriptor of a configuration, which
+// the descriptors of its interfaces and endpoints follow, TotalLength
+// bytes in all. MaxPower is in units of 2 mA.
+type USBConfigurationDescriptor struct {
+	TotalLength        uint16
+	NumInterfaces      uint8
+	ConfigurationValue uint8
+	ConfigurationIndex uint8
+	Attributes         uint8
+	MaxPower           uint8
+}
+
+// Configuration decodes a configuration descriptor.
+func (d *USBDescriptor) Configuration() (*USBConfigurationDescriptor, error) {
+	if err := d.check(USBDescriptorTypeConfiguration, 7); err != nil {
+		return nil, err
+	}
+	return &USBConfigurationDescriptor{
+		TotalLength:        binary.LittleEndian.Uint16(d.Data[0:2]),
+		NumInterfaces:      d.Data[2],
+		ConfigurationValue: d.Data[3],
+		ConfigurationIndex: d.Data[4],
+		Attributes:         d.Data[5],
+		MaxPower:           d.Data[6],
+	}, nil
+}
+
+// USBInterfaceDescriptor is the descriptor of an interface of a
+// configuration. HID devices have the Class 3.
+type USBInterfaceDescriptor struct {
+	InterfaceNumber  uint8
+	AlternateSetting uint8
+	NumEndpoints     uint8
+	Class            uint8
+	SubClass         uint8
+	Protocol         uint8
+	InterfaceIndex   uint8
+}
+
+// Interface decodes an interface descriptor.
+func (d *USBDescriptor) Interface() (*USBInterfaceDescriptor, error) {
+	if err := d.check(USBDescriptorTypeInterface, 7); err != nil {
+		return nil, err
+	}
+	return &USBInterfaceDescriptor{
+		InterfaceNumber:  d.Data[0],
+		AlternateSetting: d.Data[1],
+		NumEndpoints:     d.Data[2],
+		Class:            d.Data[3],
+		SubClass:         d.Data[4],
+		Protocol:         d.Data[5],
+		InterfaceIndex:   d.Data[6],
+	}, nil
+}
+
+// USBEndpointDescriptor is the descriptor of an endpoint of an interface.
+type USBEndpointDescriptor struct {
+	Address       uint8
+	Attributes    uint8
+	MaxPacketSize uint16
+	Interval      uint8
+}
+
+// Endpoint decodes an endpoint descriptor.
+func (d *USBDescriptor) Endpoint() (*USBEndpointDescriptor, error) {
+	if err := d.check(USBDescriptorTypeEndpoint, 5); err != nil {
+		return nil, err
+	}
+	return &USBEndpointDescriptor{
+		Address:       d.Data[0],
+		Attributes:    d.Data[1],
+		MaxPacketSize: binary.LittleEndian.Uint16(d.Data[2:4]),
+		Interval:      d.Data[4],
+	}, nil
+}
+
+// Number returns the number of the endpoint.
+func (e *USBEndpointDescriptor) Number() uint8 {
+	return e.Address & 0x0f
+}
+
+// Direction returns the direction of the transfers of the endpoint.
+func (e *USBEndpointDescriptor) Direction() USBDirectionType {
+	if e.Address&uint8(USBTransportTypeTransferIn) != 0 {
+		return USBDirectionTypeIn
+	}
+	return USBDirectionTypeOut
+}
+
+// usbEndpointTransferTypes maps the transfer types of endpoint descriptors,
+// which differ from those of usbmon and USBPcap.
+var usbEndpointTransferTypes = [...]USBTransportType{
+	USBTransportTypeControl,
+	USBTransportTypeIsochronous,
+	USBTransportTypeBulk,
+	USBTransportTypeInterrupt,
+}
+
+// TransferType returns the type of the transfers of the endpoint.
+func (e *USBEndpointDescriptor) TransferType() USBTransportType {
+	return usbEndpointTransferTypes[e.Attributes&0x3]
+}
+
+// USBHIDClassDescriptor is one of the class descriptors, such as the
+// report descriptor, a HID descriptor lists.
+type USBHIDClassDescriptor struct {
+	Type   USBDescriptorType
+	Length uint16
+}
+
+// USBHIDDescriptor is the descriptor of the interface of a HID device.
+type USBHIDDescriptor struct {
+	HIDVersion  uint16
+	CountryCode uint8
+	Descriptors []USBHIDClassDescriptor
+}
+
+// HID decodes a HID descriptor.
+func (d *USBDescriptor) HID() (*USBHIDDescriptor, error) {
+	if err := d.check(USBDescriptorTypeHID, 4); err != nil {
+		return nil, err
+	}
+	h := &USBHIDDescriptor{
+		HIDVersion:  binary.LittleEndian.Uint16(d.Data[0:2]),
+		CountryCode: d.Data[2],
+	}
+	n := int(d.Data[3])
+	if len(d.Data) < 4+3*n {
+		return nil, fmt.Errorf("USB HID descriptor of %d class descriptors too short", n)
+	}
+	for i := 0; i < n; i++ {
+		b := d.Data[4+3*i:]
+		h.Descriptors = append(h.Descriptors, USBHIDClassDescriptor{
+			Type:   USBDescriptorType(b[0]),
+			Length: binary.LittleEndian.Uint16(b[1:3]),
+		})
+	}
+	return h, nil
+}
+
+// Text decodes the UTF-16 text of a string descriptor. The string
+// descriptor of index 0 holds the language IDs a device supports instead.
+func (d *USBDescriptor) Text() (string, error) {
+	if err := d.check(USBDescriptorTypeString, 0); err != nil {
+		return "", err
+	}
+	if len(d.Data)%2 != 0 {
+		return "", errors.New("USB string descriptor of odd length")
+	}
+	u := make([]uint16, len(d.Data)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(d.Data[2*i:])
+	}
+	return string(utf16.Decode(u)), nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// USBPcapControlStage is the stage of a control transfer USBPcap captured.
+type USBPcapControlStage uint8
+
+const (
+	USBPcapControlStageSetup    USBPcapControlStage = 0
+	USBPcapControlStageData     USBPcapControlStage = 1
+	USBPcapControlStageStatus   USBPcapControlStage = 2
+	USBPcapControlStageComplete USBPcapControlStage = 3
+)
+
+func (a USBPcapControlStage) String() string {
+	switch a {
+	case USBPcapControlStageSetup:
+		return "Setup"
+	case USBPcapControlStageData:
+		return "Data"
+	case USBPcapControlStageStatus:
+		return "Status"
+	case USBPcapControlStageComplete:
+		return "Complete"
+	default:
+		return "Unknown control stage"
+	}
+}
+
+// USBPcapIsoPacket describes one of the packets of an isochronous transfer,
+// at Offset of the payload.
+type USBPcapIsoPacket struct {
+	Offset uint32
+	Length uint32
+	Status uint32
+}
+
+// USBPcap is the header USBPcap prepends to the USB request blocks it
+// captures on Windows, the packets of LINKTYPE_USBPCAP. As with USB, the
+// setup packets of control transfers are decoded as USBRequestBlockSetup
+// and the data of the other transfers is the payload.
+type USBPcap struct {
+	BaseLayer
+	HeaderLength uint16
+	// IRPID identifies the I/O request packet, the same for its
+	// submission and its completion.
+	IRPID uint64
+	// Status is the USBD_STATUS of the request block, and Function its
+	// URB_FUNCTION.
+	Status   uint32
+	Function uint16
+	// EventType is USBEventTypeComplete for requests on their way back
+	// from the device, and USBEventTypeSubmit for the others.
+	EventType      USBEventType
+	BusID          uint16
+	DeviceAddress  uint16
+	EndpointNumber uint8
+	Direction      USBDirectionType
+	TransferType   USBTransportType
+	DataLength     uint32
+	// Stage is the stage of control transfers.
+	Stage USBPcapControlStage
+	// The fields of isochronous transfers.
+	IsoStartFrame uint32
+	IsoErrorCount uint32
+	IsoPackets    []USBPcapIsoPacket
+}
+
+// LayerType returns LayerTypeUSBPcap.
+func (m *USBPcap) LayerType() gopacket.LayerType { return LayerTypeUSBPcap }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *USBPcap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 27 {
+		df.SetTruncated()
+		return fmt.Errorf("USBPcap length %d too short, %d required", len(data), 27)
+	}
+	m.HeaderLength = binary.LittleEndian.Uint16(data[0:2])
+	m.IRPID = binary.LittleEndian.Uint64(data[2:10])
+	m.Status = binary.LittleEndian.Uint32(data[10:14])
+	m.Function = binary.LittleEndian.Uint16(data[14:16])
+	m.EventType = USBEventTypeSubmit
+	if data[16]&0x01 != 0 {
+		m.EventType = USBEventTypeComplete
+	}
+	m.BusID = binary.LittleEndian.Uint16(data[17:19])
+	m.DeviceAddress = binary.LittleEndian.Uint16(data[19:21])
+	m.EndpointNumber = data[21] & 0x7f
+	m.Direction = USBDirectionTypeOut
+	if data[21]&uint8(USBTransportTypeTransferIn) != 0 {
+		m.Direction = USBDirectionTypeIn
+	}
+	m.TransferType = USBTransportType(data[22])
+	m.DataLength = binary.LittleEndian.Uint32(data[23:27])
+
+	length := int(m.HeaderLength)
+	if length < 27 {
+		return fmt.Errorf("USBPcap header length %d too short, %d required", length, 27)
+	}
+	if len(data) < length {
+		df.SetTruncated()
+		return fmt.Errorf("USBPcap header length %d exceeds %d bytes", length, len(data))
+	}
+	m.Stage = 0
+	m.IsoStartFrame, m.IsoErrorCount, m.IsoPackets = 0, 0, nil
+	switch m.TransferType {
+	case USBTransportTypeControl:
+		if length < 28 {
+			return fmt.Errorf("USBPcap control header length %d too short, %d required", length, 28)
+		}
+		m.Stage = USBPcapControlStage(data[27])
+	case USBTransportTypeIsochronous:
+		if length < 39 {
+			return fmt.Errorf("USBPcap isochronous header length %d too short, %d required", length, 39)
+		}
+		m.IsoStartFrame = binary.LittleEndian.Uint32(data[27:31])
+		n := binary.LittleEndian.Uint32(data[31:35])
+		m.IsoErrorCount = binary.LittleEndian.Uint32(data[35:39])
+		if uint64(n)*12 > uint64(length-39) {
+			return fmt.Errorf("USBPcap %d isochronous packets exceed header length %d", n, length)
+		}
+		for i := 0; i < int(n); i++ {
+			b := data[39+12*i:]
+			m.IsoPackets = append(m.IsoPackets, USBPcapIsoPacket{
+				Offset: binary.LittleEndian.Uint32(b[0:4]),
+				Length: binary.LittleEndian.Uint32(b[4:8]),
+				Status: binary.LittleEndian.Uint32(b[8:12]),
+			})
+		}
+	}
+
+	payload := data[length:]
+	if uint32(len(payload)) > m.DataLength {
+		payload = payload[:m.DataLength]
+	} else if uint32(len(payload)) < m.DataLength {
+		df.SetTruncated()
+	}
+	m.BaseLayer = BaseLayer{Contents: data[:length], Payload: payload}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *USBPcap) CanDecode() gopacket.LayerClass {
+	return LayerTypeUSBPcap
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (m *USBPcap) NextLayerType() gopacket.LayerType {
+	if m.TransferType == USBTransportTypeControl && m.Stage == USBPcapControlStageSetup {
+		return LayerTypeUSBRequestBlockSetup
+	}
+	if t := m.TransferType.LayerType(); t != gopacket.LayerTypeZero {
+		return t
+	}
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeUSBPcap(data []byte, p gopacket.PacketBuilder) error {
+	m := &USBPcap{}
+	return decodingLayerDecoder(m, data, p)
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// VLANTag is one tag of a stack of 802.1Q customer and 802.1ad service VLAN
+// tags, as used for Q-in-Q.
+type VLANTag struct {
+	// TPID is the EtherType announcing the tag, EthernetTypeDot1Q or
+	// EthernetTypeQinQ.
+	TPID           EthernetType
+	Priority       uint8
+	DropEligible   bool
+	VLANIdentifier uint16
+}
+
+func (t VLANTag) tci() (uint16, error) {
+	if t.VLANIdentifier > 0xfff || t.Priority > 7 {
+		return 0, fmt.Errorf("invalid VLAN tag priority %d, identifier %d", t.Priority, t.VLANIdentifier)
+	}
+	tci := uint16(t.Priority)<<13 | t.VLANIdentifier
+	if t.DropEligible {
+		tci |= 0x1000
+	}
+	return tci, nil
+}
+
+func isVLANTPID(t EthernetType) bool {
+	return t == EthernetTypeDot1Q || t == EthernetTypeQinQ
+}
+
+// VLANStack returns the VLAN tags of packet, outermost first, and the
+// EtherType following the innermost tag. It returns no tags if the packet
+// does not start with an Ethernet layer followed by Dot1Q layers.
+func VLANStack(packet gopacket.Packet) (tags []VLANTag, inner EthernetType) {
+	ls := packet.Layers()
+	if len(ls) == 0 {
+		return nil, 0
+	}
+	eth, ok := ls[0].(*Ethernet)
+	if !ok {
+		return nil, 0
+	}
+	tpid := eth.EthernetType
+	for _, l := range ls[1:] {
+		d, ok := l.(*Dot1Q)
+		if !ok || !isVLANTPID(tpid) {
+			break
+		}
+		tags = append(tags, VLANTag{TPID: tpid, Priority: d.Priority, DropEligible: d.DropEligible, VLANIdentifier: d.VLANIdentifier})
+		tpid = d.Type
+	}
+	return tags, tpid
+}
+
+// ParseVLANStack returns the VLAN tags of an Ethernet frame, outermost
+// first, the EtherType following the innermost tag and the offset of the
+// data following it, without decoding the frame.
+func ParseVLANStack(frame []byte) (tags []VLANTag, inner EthernetType, offset int, err error) {
+	if len(frame) < 14 {
+		return nil, 0, 0, errors.New("Ethernet frame too short")
+	}
+	offset = 12
+	for {
+		tpid := EthernetType(binary.BigEndian.Uint16(frame[offset:]))
+		if !isVLANTPID(tpid) {
+			return tags, tpid, offset + 2, nil
+		}
+		if len(frame) < offset+6 {
+			return nil, 0, 0, errors.New("VLAN tag truncated")
+		}
+		tci := binary.BigEndian.Uint16(frame[offset+2:])
+		tags = append(tags, VLANTag{
+			TPID:           tpid,
+			Priority:       uint8(tci >> 13),
+			DropEligible:   tci&0x1000 != 0,
+			VLANIdentifier: tci & 0xfff,
+		})
+		offset += 4
+	}
+}
+
+// PushVLANTag returns a copy of the Ethernet frame with tag added as its
+// outermost VLAN tag.
+func PushVLANTag(frame []byte, tag VLANTag) ([]byte, error) {
+	if len(frame) < 14 {
+		return nil, errors.New("Ethernet frame too short")
+	}
+	if !isVLANTPID(tag.TPID) {
+		return nil, fmt.Errorf("invalid VLAN TPID %v", tag.TPID)
+	}
+	tci, err := tag.tci()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(frame)+4)
+	copy(out, frame[:12])
+	binary.BigEndian.PutUint16(out[12:], uint16(tag.TPID))
+	binary.BigEndian.PutUint16(out[14:], tci)
+	copy(out[16:], frame[12:])
+	return out, nil
+}
+
+// PopVLANTag returns a copy of the Ethernet frame without its outermost
+// VLAN tag, and that tag. The frame is not padded to the minimum Ethernet
+// frame size.
+func PopVLANTag(frame []byte) ([]byte, VLANTag, error) {
+	tags, _, _, err := ParseVLANStack(frame)
+	if err != nil {
+		return nil, VLANTag{}, err
+	}
+	if len(tags) == 0 {
+		return nil, VLANTag{}, errors.New("Ethernet frame has no VLAN tag")
+	}
+	out := make([]byte, len(frame)-4)
+	copy(out, frame[:12])
+	copy(out[12:], frame[16:])
+	return out, tags[0], nil
+}
+
+// SetVLANStack sets up eth to be followed by the VLAN tags, outermost
+// first, and then by a layer of EtherType inner. It returns the Dot1Q
+// layers to serialize between eth and that layer:
+//
+//	tags := layers.SetVLANStack(eth, []layers.VLANTag{
+//		{TPID: layers.EthernetTypeQinQ, VLANIdentifier: 100},
+//		{TPID: layers.EthernetTypeDot1Q, VLANIdentifier: 20},
+//	}, layers.EthernetTypeIPv4)
+//	ls := append([]gopacket.SerializableLayer{eth}, tags...)
+//	err := gopacket.SerializeLayers(buf, opts, append(ls, ip, udp, payload)...)
+func SetVLANStack(eth *Ethernet, tags []VLANTag, inner EthernetType) []gopacket.SerializableLayer {
+	if len(tags) == 0 {
+		eth.EthernetType = inner
+		return nil
+	}
+	eth.EthernetType = tags[0].TPID
+	ls := make([]gopacket.SerializableLayer, len(tags))
+	for i, t := range tags {
+		next := inner
+		if i+1 < len(tags) {
+			next = tags[i+1].TPID
+		}
+		ls[i] = &Dot1Q{Priority: t.Priority, DropEligible: t.DropEligible, VLANIdentifier: t.VLANIdentifier, Type: next}
+	}
+	return ls
+}
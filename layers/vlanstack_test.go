@@ -0,0 +1,75 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestVLANStack(t *testing.T) {
+	want := []VLANTag{
+		{TPID: EthernetTypeQinQ, Priority: 5, VLANIdentifier: 100},
+		{TPID: EthernetTypeQinQ, VLANIdentifier: 200, DropEligible: true},
+		{TPID: EthernetTypeDot1Q, Priority: 1, VLANIdentifier: 20},
+	}
+	eth := &Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}}
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 1000, DstPort: 2000}
+	ls := append([]gopacket.SerializableLayer{eth}, SetVLANStack(eth, want, EthernetTypeIPv4)...)
+	ls = append(ls, ip, udp, gopacket.Payload("hello"))
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	p := gopacket.NewPacket(frame, LinkTypeEthernet, gopacket.Default)
+	tags, inner := VLANStack(p)
+	if !reflect.DeepEqual(tags, want) || inner != EthernetTypeIPv4 {
+		t.Errorf("got tags %+v, inner %v", tags, inner)
+	}
+	tags, inner, offset, err := ParseVLANStack(frame)
+	if err != nil || !reflect.DeepEqual(tags, want) || inner != EthernetTypeIPv4 || offset != 26 {
+		t.Errorf("parsed tags %+v, inner %v, offset %d, %v", tags, inner, offset, err)
+	}
+
+	popped, tag, err := PopVLANTag(frame)
+	if err != nil || tag != want[0] {
+		t.Fatalf("popped %+v, %v", tag, err)
+	}
+	p = gopacket.NewPacket(popped, LinkTypeEthernet, gopacket.Default)
+	if tags, _ := VLANStack(p); !reflect.DeepEqual(tags, want[1:]) {
+		t.Errorf("after pop got tags %+v", tags)
+	}
+	if p.Layer(LayerTypeUDP) == nil {
+		t.Error("no UDP layer after pop")
+	}
+	pushed, err := PushVLANTag(popped, tag)
+	if err != nil || !bytes.Equal(pushed, frame) {
+		t.Errorf("push after pop changed frame: %v\n%x\n%x", err, pushed, frame)
+	}
+
+	// Untagged frames.
+	untagged := append(append([]byte(nil), frame[:12]...), frame[24:]...)
+	if tags, inner, offset, err := ParseVLANStack(untagged); len(tags) != 0 || inner != EthernetTypeIPv4 || offset != 14 || err != nil {
+		t.Errorf("untagged frame parsed as %+v, %v, %d, %v", tags, inner, offset, err)
+	}
+	if _, _, err := PopVLANTag(untagged); err == nil {
+		t.Error("popped tag of untagged frame")
+	}
+	if _, err := PushVLANTag(untagged, VLANTag{TPID: EthernetTypeIPv4}); err == nil {
+		t.Error("pushed tag with invalid TPID")
+	}
+	if _, _, _, err := ParseVLANStack(frame[:16]); err == nil {
+		t.Error("parsed truncated tag")
+	}
+}
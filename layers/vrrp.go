@@ -9,13 +9,16 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
+	"time"
 
 	"github.com/google/gopacket"
 )
 
 /*
-	This layer provides decoding for Virtual Router Redundancy Protocol (VRRP) v2.
+	This layer provides decoding for Virtual Router Redundancy Protocol (VRRP) v2
+	and v3.
 	https://tools.ietf.org/html/rfc3768#section-5
     0                   1                   2                   3
     0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
@@ -91,7 +94,10 @@ type VRRPv2 struct {
 func (v *VRRPv2) LayerType() gopacket.LayerType { return LayerTypeVRRP }
 
 func (v *VRRPv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("VRRPv2 packet too short")
+	}
 	v.BaseLayer = BaseLayer{Contents: data[:len(data)]}
 	v.Version = data[0] >> 4 // high nibble == VRRP version. We're expecting v2
 
@@ -112,6 +118,11 @@ func (v *VRRPv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 	v.AuthType = VRRPv2AuthType(data[4])
 	v.AdverInt = uint8(data[5])
 	v.Checksum = binary.BigEndian.Uint16(data[6:8])
+	if len(data) < 8+4*int(v.CountIPAddr) {
+		df.SetTruncated()
+		return errors.New("VRRPv2 packet too short for its IP addresses")
+	}
+	v.IPAddress = v.IPAddress[:0]
 
 	// populate the IPAddress field. The number of addresses is specified in the v.CountIPAddr field
 	// offset references the starting byte containing the list of ip addresses
@@ -146,11 +157,215 @@ func (v *VRRPv2) Payload() []byte {
 	return nil
 }
 
-// decodeVRRP will parse VRRP v2
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// The authentication data is written as zeros, as RFC 3768 requires.
+func (v *VRRPv2) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if opts.FixLengths {
+		v.CountIPAddr = uint8(len(v.IPAddress))
+	}
+	bytes, err := b.PrependBytes(8 + 4*len(v.IPAddress) + 8)
+	if err != nil {
+		return err
+	}
+	bytes[0] = v.Version<<4 | uint8(v.Type)&0x0f
+	bytes[1] = v.VirtualRtrID
+	bytes[2] = v.Priority
+	bytes[3] = v.CountIPAddr
+	bytes[4] = uint8(v.AuthType)
+	bytes[5] = v.AdverInt
+	offset := 8
+	for _, ip := range v.IPAddress {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return fmt.Errorf("VRRPv2 address %v is not an IPv4 address", ip)
+		}
+		copy(bytes[offset:], ip4)
+		offset += 4
+	}
+	for i := offset; i < len(bytes); i++ {
+		bytes[i] = 0
+	}
+	if opts.ComputeChecksums {
+		bytes[6], bytes[7] = 0, 0
+		v.Checksum = tcpipChecksum(bytes, 0)
+	}
+	binary.BigEndian.PutUint16(bytes[6:], v.Checksum)
+	return nil
+}
+
+// VRRPv3 represents a VRRP v3 message (RFC 5798), which advertises either
+// IPv4 or IPv6 addresses. Unlike VRRPv2, its checksum covers the IPv4 or
+// IPv6 pseudo-header; call SetNetworkLayerForChecksum before serializing
+// with ComputeChecksums.
+//
+// VRRPv3 and VRRPv2 share LayerTypeVRRP; packets are decoded as either
+// depending on their version field.
+type VRRPv3 struct {
+	BaseLayer
+	Version      uint8      // VRRP protocol version of this packet (3)
+	Type         VRRPv2Type // The only type defined in v3 is ADVERTISEMENT, as in v2
+	VirtualRtrID uint8      // identifies the virtual router this packet is reporting status for
+	Priority     uint8      // the sending VRRP router's priority for the virtual router (100 = default)
+	CountIPAddr  uint8      // The number of IPv4 or IPv6 addresses contained in this advertisement
+	MaxAdverInt  uint16     // The advertisement interval in centiseconds (12 bits). The default is 100
+	Checksum     uint16     // covers the VRRP message and the IP pseudo-header
+	IPAddress    []net.IP   // the IPv4 or IPv6 addresses associated with the virtual router
+	// IPv6 is set if IPAddress holds IPv6 addresses. When decoding a
+	// packet, it follows the IP layer carrying the message; DecodeFromBytes
+	// alone derives it from the length of the message.
+	IPv6 bool
+
+	tcpipchecksum
+}
+
+// LayerType returns LayerTypeVRRP for VRRP v3 message.
+func (v *VRRPv3) LayerType() gopacket.LayerType { return LayerTypeVRRP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (v *VRRPv3) CanDecode() gopacket.LayerClass { return LayerTypeVRRP }
+
+// NextLayerType returns gopacket.LayerTypeZero; VRRP carries no payload.
+func (v *VRRPv3) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil; VRRP carries no payload.
+func (v *VRRPv3) Payload() []byte { return nil }
+
+// AdvertisementInterval returns MaxAdverInt as a duration.
+func (v *VRRPv3) AdvertisementInterval() time.Duration {
+	return time.Duration(v.MaxAdverInt&0x0fff) * 10 * time.Millisecond
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (v *VRRPv3) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	return v.decode(data, df, false, false)
+}
+
+// decode decodes data with addresses of the family ipv6 tells if known is
+// set, or else of the family the length of data implies.
+func (v *VRRPv3) decode(data []byte, df gopacket.DecodeFeedback, ipv6, known bool) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("VRRPv3 packet too short")
+	}
+	v.BaseLayer = BaseLayer{Contents: data}
+	v.Version = data[0] >> 4
+	v.Type = VRRPv2Type(data[0] & 0x0F)
+	if v.Type != VRRPv2Advertisement {
+		// rfc5798: A packet with unknown type MUST be discarded.
+		return fmt.Errorf("unrecognized VRRPv3 type %d", v.Type)
+	}
+	v.VirtualRtrID = data[1]
+	v.Priority = data[2]
+	v.CountIPAddr = data[3]
+	v.MaxAdverInt = binary.BigEndian.Uint16(data[4:6]) & 0x0fff
+	v.Checksum = binary.BigEndian.Uint16(data[6:8])
+
+	// The address family is not part of the message. Without the IP layer
+	// carrying it, it follows from the length, as VRRPv3 messages carry no
+	// authentication data.
+	n := int(v.CountIPAddr)
+	if !known {
+		ipv6 = n > 0 && len(data) >= 8+16*n
+	}
+	addrLen := 4
+	if ipv6 {
+		addrLen = 16
+	}
+	if len(data) < 8+addrLen*n {
+		df.SetTruncated()
+		return errors.New("VRRPv3 packet too short for its IP addresses")
+	}
+	v.IPv6 = addrLen == 16
+	v.IPAddress = v.IPAddress[:0]
+	for i := 0; i < n; i++ {
+		offset := 8 + i*addrLen
+		v.IPAddress = append(v.IPAddress, net.IP(data[offset:offset+addrLen]))
+	}
+	v.Contents = data[:8+n*addrLen]
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+func (v *VRRPv3) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	ipv6 := v.IPv6
+	if _, ok := v.pseudoheader.(*IPv6); ok {
+		ipv6 = true
+	}
+	addrLen := 4
+	if ipv6 {
+		addrLen = 16
+	}
+	if opts.FixLengths {
+		v.CountIPAddr = uint8(len(v.IPAddress))
+	}
+	bytes, err := b.PrependBytes(8 + addrLen*len(v.IPAddress))
+	if err != nil {
+		return err
+	}
+	bytes[0] = v.Version<<4 | uint8(v.Type)&0x0f
+	bytes[1] = v.VirtualRtrID
+	bytes[2] = v.Priority
+	bytes[3] = v.CountIPAddr
+	binary.BigEndian.PutUint16(bytes[4:], v.MaxAdverInt&0x0fff)
+	for i, ip := range v.IPAddress {
+		addr := ip.To16()
+		if !ipv6 {
+			addr = ip.To4()
+		} else if ip.To4() != nil {
+			addr = nil
+		}
+		if addr == nil {
+			return fmt.Errorf("VRRPv3 address %v does not match the address family", ip)
+		}
+		copy(bytes[8+i*addrLen:], addr)
+	}
+	if opts.ComputeChecksums {
+		bytes[6], bytes[7] = 0, 0
+		csum, err := v.computeChecksum(bytes, IPProtocolVRRP)
+		if err != nil {
+			return err
+		}
+		v.Checksum = csum
+	}
+	binary.BigEndian.PutUint16(bytes[6:], v.Checksum)
+	return nil
+}
+
+// decodeVRRP will parse VRRP v2 and v3
 func decodeVRRP(data []byte, p gopacket.PacketBuilder) error {
 	if len(data) < 8 {
 		return errors.New("Not a valid VRRP packet. Packet length is too small.")
 	}
+	if data[0]>>4 == 3 {
+		v := &VRRPv3{}
+		ipv6, known := vrrpOverIPv6(p)
+		if err := v.decode(data, p, ipv6, known); err != nil {
+			return err
+		}
+		p.AddLayer(v)
+		return nil
+	}
 	v := &VRRPv2{}
 	return decodingLayerDecoder(v, data, p)
 }
+
+// vrrpOverIPv6 reports whether the innermost IP layer of the packet being
+// built is IPv6, and whether there is one.
+func vrrpOverIPv6(p gopacket.PacketBuilder) (ipv6, known bool) {
+	packet, ok := p.(gopacket.Packet)
+	if !ok {
+		return false, false
+	}
+	ls := packet.Layers()
+	for i := len(ls) - 1; i >= 0; i-- {
+		switch ls[i].(type) {
+		case *IPv4:
+			return false, true
+		case *IPv6:
+			return true, true
+		}
+	}
+	return false, false
+}
@@ -6,8 +6,12 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/google/gopacket"
 )
 
 // vrrpPacketPriority100 is the packet:
@@ -53,3 +57,83 @@ func BenchmarkDecodeVRRPPacket0(b *testing.B) {
 		gopacket.NewPacket(vrrpPacketPriority100, LayerTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestVRRPv2Serialize(t *testing.T) {
+	p := gopacket.NewPacket(vrrpPacketPriority100, LinkTypeEthernet, gopacket.Default)
+	vrrp := p.Layer(LayerTypeVRRP).(*VRRPv2)
+	want := p.Layer(LayerTypeIPv4).LayerPayload()
+	vrrp.Checksum = 0
+	buf := gopacket.NewSerializeBuffer()
+	if err := vrrp.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("serialized\n%x\nwant\n%x", buf.Bytes(), want)
+	}
+	if vrrp.Checksum != 47698 {
+		t.Errorf("got checksum %d, want 47698", vrrp.Checksum)
+	}
+}
+
+func TestVRRPv3(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		network gopacket.SerializableLayer
+		addrs   []net.IP
+	}{
+		{"IPv4", &IPv4{Version: 4, TTL: 255, Protocol: IPProtocolVRRP,
+			SrcIP: net.IP{192, 168, 0, 30}, DstIP: net.IP{224, 0, 0, 18}},
+			[]net.IP{{192, 168, 0, 1}, {192, 168, 0, 2}}},
+		{"IPv6", &IPv6{Version: 6, HopLimit: 255, NextHeader: IPProtocolVRRP,
+			SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP("ff02::12")},
+			[]net.IP{net.ParseIP("fe80::100"), net.ParseIP("2001:db8::100")}},
+	} {
+		vrrp := &VRRPv3{Version: 3, Type: VRRPv2Advertisement, VirtualRtrID: 7,
+			Priority: 200, MaxAdverInt: 100, IPAddress: test.addrs}
+		if err := vrrp.SetNetworkLayerForChecksum(test.network.(gopacket.NetworkLayer)); err != nil {
+			t.Fatal(err)
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, test.network, vrrp); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		first := LayerTypeIPv4
+		if test.name == "IPv6" {
+			first = LayerTypeIPv6
+		}
+		p := gopacket.NewPacket(buf.Bytes(), first, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatalf("%s: failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+		}
+		checkLayers(p, []gopacket.LayerType{first, LayerTypeVRRP}, t)
+		got, ok := p.Layer(LayerTypeVRRP).(*VRRPv3)
+		if !ok {
+			t.Fatalf("%s: got layer %T", test.name, p.Layer(LayerTypeVRRP))
+		}
+		if got.CountIPAddr != 2 || got.IPv6 != (test.name == "IPv6") || got.AdvertisementInterval() != time.Second ||
+			!got.IPAddress[1].Equal(test.addrs[1]) || got.Checksum != vrrp.Checksum {
+			t.Errorf("%s: got %+v", test.name, got)
+		}
+		// The checksum over the message and the pseudo-header is zero.
+		verify, _ := vrrp.computeChecksum(got.Contents, IPProtocolVRRP)
+		if verify != 0 {
+			t.Errorf("%s: checksum %#04x does not verify", test.name, got.Checksum)
+		}
+	}
+
+	// An IPv4 message with trailing data long enough for an IPv6 address
+	// still holds an IPv4 address.
+	ip := &IPv4{Version: 4, TTL: 255, Protocol: IPProtocolVRRP, SrcIP: net.IP{192, 168, 0, 30}, DstIP: net.IP{224, 0, 0, 18}}
+	vrrp := &VRRPv3{Version: 3, Type: VRRPv2Advertisement, VirtualRtrID: 7,
+		IPAddress: []net.IP{{192, 168, 0, 1}, {192, 168, 0, 2}, {192, 168, 0, 3}, {192, 168, 0, 4}}}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip, vrrp); err != nil {
+		t.Fatal(err)
+	}
+	buf.Bytes()[20+3] = 1 // count of addresses
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.Default)
+	if got, ok := p.Layer(LayerTypeVRRP).(*VRRPv3); !ok || got.IPv6 || len(got.IPAddress) != 1 || !got.IPAddress[0].Equal(net.IP{192, 168, 0, 1}) {
+		t.Errorf("got %v", p)
+	}
+}
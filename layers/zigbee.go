@@ -0,0 +1,664 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// ZigbeeKeyID tells the kind of key which secures a Zigbee frame.
+type ZigbeeKeyID uint8
+
+// Zigbee key identifiers.
+const (
+	ZigbeeKeyIDData         ZigbeeKeyID = 0
+	ZigbeeKeyIDNetwork      ZigbeeKeyID = 1
+	ZigbeeKeyIDKeyTransport ZigbeeKeyID = 2
+	ZigbeeKeyIDKeyLoad      ZigbeeKeyID = 3
+)
+
+func (k ZigbeeKeyID) String() string {
+	switch k {
+	case ZigbeeKeyIDData:
+		return "Data"
+	case ZigbeeKeyIDNetwork:
+		return "Network"
+	case ZigbeeKeyIDKeyTransport:
+		return "Key-Transport"
+	case ZigbeeKeyIDKeyLoad:
+		return "Key-Load"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(k))
+}
+
+// zigbeeMICLength is the length of the MIC of secured Zigbee frames, whose
+// security level, 5, is not sent over the air.
+const zigbeeMICLength = 4
+
+// ZigbeeSecurityHeader is the auxiliary header of Zigbee frames secured at
+// the network or the application support layer, whose payload is encrypted.
+type ZigbeeSecurityHeader struct {
+	// Level is the security level of the header, usually 0 over the air.
+	Level         uint8
+	KeyID         ZigbeeKeyID
+	ExtendedNonce bool
+	FrameCounter  uint32
+	// Source is the extended address of the sender, if ExtendedNonce is
+	// set.
+	Source uint64
+	// KeySequenceNumber is set for frames secured with the network key.
+	KeySequenceNumber uint8
+}
+
+func (s *ZigbeeSecurityHeader) length() int {
+	n := 5
+	if s.ExtendedNonce {
+		n += 8
+	}
+	if s.KeyID == ZigbeeKeyIDNetwork {
+		n++
+	}
+	return n
+}
+
+// decodeZigbeeSecurityHeader decodes the auxiliary security header at the
+// start of data, returning it and its length.
+func decodeZigbeeSecurityHeader(data []byte) (*ZigbeeSecurityHeader, int, error) {
+	if len(data) < 5 {
+		return nil, 0, errors.New("Zigbee auxiliary security header too short")
+	}
+	s := &ZigbeeSecurityHeader{
+		Level:         data[0] & 0x7,
+		KeyID:         ZigbeeKeyID(data[0] >> 3 & 0x3),
+		ExtendedNonce: data[0]&0x20 != 0,
+		FrameCounter:  binary.LittleEndian.Uint32(data[1:]),
+	}
+	n := s.length()
+	if len(data) < n+zigbeeMICLength {
+		return nil, 0, errors.New("Zigbee auxiliary security header too short")
+	}
+	offset := 5
+	if s.ExtendedNonce {
+		s.Source = binary.LittleEndian.Uint64(data[offset:])
+		offset += 8
+	}
+	if s.KeyID == ZigbeeKeyIDNetwork {
+		s.KeySequenceNumber = data[offset]
+	}
+	return s, n, nil
+}
+
+// encode encodes the header into data, returning its length.
+func (s *ZigbeeSecurityHeader) encode(data []byte) int {
+	data[0] = s.Level&0x7 | uint8(s.KeyID&0x3)<<3
+	if s.ExtendedNonce {
+		data[0] |= 0x20
+	}
+	binary.LittleEndian.PutUint32(data[1:], s.FrameCounter)
+	offset := 5
+	if s.ExtendedNonce {
+		binary.LittleEndian.PutUint64(data[offset:], s.Source)
+		offset += 8
+	}
+	if s.KeyID == ZigbeeKeyIDNetwork {
+		data[offset] = s.KeySequenceNumber
+		offset++
+	}
+	return offset
+}
+
+// ZigbeeNWKFrameType is the type of a Zigbee network layer frame.
+type ZigbeeNWKFrameType uint8
+
+// Zigbee network layer frame types.
+const (
+	ZigbeeNWKFrameTypeData     ZigbeeNWKFrameType = 0
+	ZigbeeNWKFrameTypeCommand  ZigbeeNWKFrameType = 1
+	ZigbeeNWKFrameTypeInterPAN ZigbeeNWKFrameType = 3
+)
+
+func (t ZigbeeNWKFrameType) String() string {
+	switch t {
+	case ZigbeeNWKFrameTypeData:
+		return "Data"
+	case ZigbeeNWKFrameTypeCommand:
+		return "Command"
+	case ZigbeeNWKFrameTypeInterPAN:
+		return "Inter-PAN"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// isZigbeeNWK tells whether the payload of an IEEE 802.15.4 data frame
+// looks like a Zigbee network frame, of a protocol version from 1 to 3,
+// rather than a 6LoWPAN packet.
+func isZigbeeNWK(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+	version := data[0] >> 2 & 0xf
+	return version >= 1 && version <= 3 && data[0]&0x3 != 2 && data[0]>>6 < 2
+}
+
+// ZigbeeNWK is a Zigbee network layer frame. The payload of secured frames
+// is encrypted, and their MIC is set apart.
+//
+// Inter-PAN frames only have their frame control.
+type ZigbeeNWK struct {
+	BaseLayer
+	FrameType          ZigbeeNWKFrameType
+	ProtocolVersion    uint8
+	DiscoverRoute      uint8
+	Multicast          bool
+	Security           bool
+	SourceRoute        bool
+	HasDestinationIEEE bool
+	HasSourceIEEE      bool
+	EndDeviceInitiator bool
+	Destination        uint16
+	Source             uint16
+	Radius             uint8
+	SequenceNumber     uint8
+	DestinationIEEE    uint64
+	SourceIEEE         uint64
+	MulticastControl   uint8
+	// RelayIndex and Relays are the source route of frames which have
+	// one.
+	RelayIndex     uint8
+	Relays         []uint16
+	SecurityHeader *ZigbeeSecurityHeader
+	MIC            []byte
+}
+
+// LayerType returns LayerTypeZigbeeNWK.
+func (z *ZigbeeNWK) LayerType() gopacket.LayerType { return LayerTypeZigbeeNWK }
+
+// headerLength returns the length of the header before the auxiliary
+// security header.
+func (z *ZigbeeNWK) headerLength() int {
+	if z.FrameType == ZigbeeNWKFrameTypeInterPAN {
+		return 2
+	}
+	n := 8
+	if z.HasDestinationIEEE {
+		n += 8
+	}
+	if z.HasSourceIEEE {
+		n += 8
+	}
+	if z.Multicast {
+		n++
+	}
+	if z.SourceRoute {
+		n += 2 + 2*len(z.Relays)
+	}
+	return n
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (z *ZigbeeNWK) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("ZigbeeNWK frame control missing")
+	}
+	fc := binary.LittleEndian.Uint16(data)
+	z.FrameType = ZigbeeNWKFrameType(fc & 0x3)
+	z.ProtocolVersion = uint8(fc >> 2 & 0xf)
+	z.DiscoverRoute = uint8(fc >> 6 & 0x3)
+	z.Multicast = fc&0x0100 != 0
+	z.Security = fc&0x0200 != 0
+	z.SourceRoute = fc&0x0400 != 0
+	z.HasDestinationIEEE = fc&0x0800 != 0
+	z.HasSourceIEEE = fc&0x1000 != 0
+	z.EndDeviceInitiator = fc&0x2000 != 0
+	z.Destination, z.Source, z.Radius, z.SequenceNumber = 0, 0, 0, 0
+	z.DestinationIEEE, z.SourceIEEE, z.MulticastControl = 0, 0, 0
+	z.RelayIndex, z.Relays = 0, nil
+	z.SecurityHeader, z.MIC = nil, nil
+
+	offset := 2
+	if z.FrameType != ZigbeeNWKFrameTypeInterPAN {
+		if len(data) < z.headerLength() {
+			df.SetTruncated()
+			return errors.New("ZigbeeNWK header too short")
+		}
+		z.Destination = binary.LittleEndian.Uint16(data[2:])
+		z.Source = binary.LittleEndian.Uint16(data[4:])
+		z.Radius = data[6]
+		z.SequenceNumber = data[7]
+		offset = 8
+		if z.HasDestinationIEEE {
+			z.DestinationIEEE = binary.LittleEndian.Uint64(data[offset:])
+			offset += 8
+		}
+		if z.HasSourceIEEE {
+			z.SourceIEEE = binary.LittleEndian.Uint64(data[offset:])
+			offset += 8
+		}
+		if z.Multicast {
+			z.MulticastControl = data[offset]
+			offset++
+		}
+		if z.SourceRoute {
+			count := int(data[offset])
+			z.RelayIndex = data[offset+1]
+			offset += 2
+			if len(data) < offset+2*count {
+				df.SetTruncated()
+				return errors.New("ZigbeeNWK source route too short")
+			}
+			z.Relays = make([]uint16, count)
+			for i := range z.Relays {
+				z.Relays[i] = binary.LittleEndian.Uint16(data[offset:])
+				offset += 2
+			}
+		}
+	}
+
+	payload := data[offset:]
+	if z.Security {
+		s, n, err := decodeZigbeeSecurityHeader(payload)
+		if err != nil {
+			df.SetTruncated()
+			return err
+		}
+		z.SecurityHeader = s
+		offset += n
+		z.MIC = data[len(data)-zigbeeMICLength:]
+		payload = data[offset : len(data)-zigbeeMICLength]
+	}
+	z.BaseLayer = BaseLayer{Contents: data[:offset], Payload: payload}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (z *ZigbeeNWK) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if z.Security && z.SecurityHeader == nil {
+		return errors.New("ZigbeeNWK security set without a security header")
+	}
+	if len(z.Relays) > 0xff {
+		return fmt.Errorf("ZigbeeNWK source route of %d relays too long", len(z.Relays))
+	}
+	length := z.headerLength()
+	if z.Security {
+		length += z.SecurityHeader.length()
+		mic, err := b.AppendBytes(len(z.MIC))
+		if err != nil {
+			return err
+		}
+		copy(mic, z.MIC)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	fc := uint16(z.FrameType&0x3) | uint16(z.ProtocolVersion&0xf)<<2 | uint16(z.DiscoverRoute&0x3)<<6
+	for _, f := range []struct {
+		set  bool
+		mask uint16
+	}{
+		{z.Multicast, 0x0100},
+		{z.Security, 0x0200},
+		{z.SourceRoute, 0x0400},
+		{z.HasDestinationIEEE, 0x0800},
+		{z.HasSourceIEEE, 0x1000},
+		{z.EndDeviceInitiator, 0x2000},
+	} {
+		if f.set {
+			fc |= f.mask
+		}
+	}
+	binary.LittleEndian.PutUint16(bytes, fc)
+	offset := 2
+	if z.FrameType != ZigbeeNWKFrameTypeInterPAN {
+		binary.LittleEndian.PutUint16(bytes[2:], z.Destination)
+		binary.LittleEndian.PutUint16(bytes[4:], z.Source)
+		bytes[6] = z.Radius
+		bytes[7] = z.SequenceNumber
+		offset = 8
+		if z.HasDestinationIEEE {
+			binary.LittleEndian.PutUint64(bytes[offset:], z.DestinationIEEE)
+			offset += 8
+		}
+		if z.HasSourceIEEE {
+			binary.LittleEndian.PutUint64(bytes[offset:], z.SourceIEEE)
+			offset += 8
+		}
+		if z.Multicast {
+			bytes[offset] = z.MulticastControl
+			offset++
+		}
+		if z.SourceRoute {
+			bytes[offset] = uint8(len(z.Relays))
+			bytes[offset+1] = z.RelayIndex
+			offset += 2
+			for _, relay := range z.Relays {
+				binary.LittleEndian.PutUint16(bytes[offset:], relay)
+				offset += 2
+			}
+		}
+	}
+	if z.Security {
+		z.SecurityHeader.encode(bytes[offset:])
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (z *ZigbeeNWK) CanDecode() gopacket.LayerClass {
+	return LayerTypeZigbeeNWK
+}
+
+// NextLayerType returns LayerTypeZigbeeAPS for data frames in the clear.
+func (z *ZigbeeNWK) NextLayerType() gopacket.LayerType {
+	if z.Security || z.FrameType == ZigbeeNWKFrameTypeCommand {
+		return gopacket.LayerTypePayload
+	}
+	return LayerTypeZigbeeAPS
+}
+
+func decodeZigbeeNWK(data []byte, p gopacket.PacketBuilder) error {
+	z := &ZigbeeNWK{}
+	return decodingLayerDecoder(z, data, p)
+}
+
+// ZigbeeAPSFrameType is the type of a Zigbee application support layer
+// frame.
+type ZigbeeAPSFrameType uint8
+
+// Zigbee application support layer frame types.
+const (
+	ZigbeeAPSFrameTypeData     ZigbeeAPSFrameType = 0
+	ZigbeeAPSFrameTypeCommand  ZigbeeAPSFrameType = 1
+	ZigbeeAPSFrameTypeAck      ZigbeeAPSFrameType = 2
+	ZigbeeAPSFrameTypeInterPAN ZigbeeAPSFrameType = 3
+)
+
+func (t ZigbeeAPSFrameType) String() string {
+	switch t {
+	case ZigbeeAPSFrameTypeData:
+		return "Data"
+	case ZigbeeAPSFrameTypeCommand:
+		return "Command"
+	case ZigbeeAPSFrameTypeAck:
+		return "Ack"
+	case ZigbeeAPSFrameTypeInterPAN:
+		return "Inter-PAN"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// ZigbeeAPSDeliveryMode tells the recipients of a Zigbee application
+// support layer frame.
+type ZigbeeAPSDeliveryMode uint8
+
+// Zigbee application support layer delivery modes.
+const (
+	ZigbeeAPSDeliveryModeUnicast   ZigbeeAPSDeliveryMode = 0
+	ZigbeeAPSDeliveryModeBroadcast ZigbeeAPSDeliveryMode = 2
+	ZigbeeAPSDeliveryModeGroup     ZigbeeAPSDeliveryMode = 3
+)
+
+func (m ZigbeeAPSDeliveryMode) String() string {
+	switch m {
+	case ZigbeeAPSDeliveryModeUnicast:
+		return "Unicast"
+	case ZigbeeAPSDeliveryModeBroadcast:
+		return "Broadcast"
+	case ZigbeeAPSDeliveryModeGroup:
+		return "Group"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(m))
+}
+
+// ZigbeeAPS is a Zigbee application support layer frame, whose payload is
+// a ZCL or ZDP frame for data frames, or an APS command. The payload of
+// secured frames is encrypted, and their MIC is set apart.
+type ZigbeeAPS struct {
+	BaseLayer
+	FrameType      ZigbeeAPSFrameType
+	DeliveryMode   ZigbeeAPSDeliveryMode
+	AckFormat      bool
+	Security       bool
+	AckRequest     bool
+	ExtendedHeader bool
+	// DestinationEndpoint is set for the data frames and acks which are
+	// not sent to a group, and GroupAddress for those which are.
+	DestinationEndpoint uint8
+	GroupAddress        uint16
+	ClusterID           uint16
+	ProfileID           uint16
+	SourceEndpoint      uint8
+	Counter             uint8
+	// Fragmentation, BlockNumber and AckBitfield are the extended header
+	// of the frames which have one.
+	Fragmentation  uint8
+	BlockNumber    uint8
+	AckBitfield    uint8
+	SecurityHeader *ZigbeeSecurityHeader
+	MIC            []byte
+}
+
+// LayerType returns LayerTypeZigbeeAPS.
+func (z *ZigbeeAPS) LayerType() gopacket.LayerType { return LayerTypeZigbeeAPS }
+
+// hasEndpoints tells whether the frame has endpoints, a cluster and a
+// profile.
+func (z *ZigbeeAPS) hasEndpoints() bool {
+	return z.FrameType == ZigbeeAPSFrameTypeData || z.FrameType == ZigbeeAPSFrameTypeAck && !z.AckFormat
+}
+
+// headerLength returns the length of the header before the auxiliary
+// security header.
+func (z *ZigbeeAPS) headerLength() int {
+	n := 1
+	group := z.DeliveryMode == ZigbeeAPSDeliveryModeGroup
+	if z.FrameType == ZigbeeAPSFrameTypeData && !group || z.FrameType == ZigbeeAPSFrameTypeAck && !z.AckFormat {
+		n++
+	}
+	if group && (z.FrameType == ZigbeeAPSFrameTypeData || z.FrameType == ZigbeeAPSFrameTypeInterPAN) {
+		n += 2
+	}
+	if z.hasEndpoints() || z.FrameType == ZigbeeAPSFrameTypeInterPAN {
+		n += 4
+	}
+	if z.hasEndpoints() {
+		n++
+	}
+	if z.FrameType != ZigbeeAPSFrameTypeInterPAN {
+		n++
+	}
+	if z.ExtendedHeader {
+		n++
+		if z.Fragmentation != 0 {
+			n++
+			if z.FrameType == ZigbeeAPSFrameTypeAck {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (z *ZigbeeAPS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("ZigbeeAPS frame control missing")
+	}
+	fc := data[0]
+	z.FrameType = ZigbeeAPSFrameType(fc & 0x3)
+	z.DeliveryMode = ZigbeeAPSDeliveryMode(fc >> 2 & 0x3)
+	z.AckFormat = fc&0x10 != 0
+	z.Security = fc&0x20 != 0
+	z.AckRequest = fc&0x40 != 0
+	z.ExtendedHeader = fc&0x80 != 0
+	z.DestinationEndpoint, z.GroupAddress, z.ClusterID, z.ProfileID = 0, 0, 0, 0
+	z.SourceEndpoint, z.Counter = 0, 0
+	z.Fragmentation, z.BlockNumber, z.AckBitfield = 0, 0, 0
+	z.SecurityHeader, z.MIC = nil, nil
+
+	// The fragmentation of the extended header is not known yet.
+	if len(data) < z.headerLength() {
+		df.SetTruncated()
+		return errors.New("ZigbeeAPS header too short")
+	}
+	offset := 1
+	group := z.DeliveryMode == ZigbeeAPSDeliveryModeGroup
+	if z.FrameType == ZigbeeAPSFrameTypeData && !group || z.FrameType == ZigbeeAPSFrameTypeAck && !z.AckFormat {
+		z.DestinationEndpoint = data[offset]
+		offset++
+	}
+	if group && (z.FrameType == ZigbeeAPSFrameTypeData || z.FrameType == ZigbeeAPSFrameTypeInterPAN) {
+		z.GroupAddress = binary.LittleEndian.Uint16(data[offset:])
+		offset += 2
+	}
+	if z.hasEndpoints() || z.FrameType == ZigbeeAPSFrameTypeInterPAN {
+		z.ClusterID = binary.LittleEndian.Uint16(data[offset:])
+		z.ProfileID = binary.LittleEndian.Uint16(data[offset+2:])
+		offset += 4
+	}
+	if z.hasEndpoints() {
+		z.SourceEndpoint = data[offset]
+		offset++
+	}
+	if z.FrameType != ZigbeeAPSFrameTypeInterPAN {
+		z.Counter = data[offset]
+		offset++
+	}
+	if z.ExtendedHeader {
+		z.Fragmentation = data[offset] & 0x3
+		if len(data) < z.headerLength() {
+			df.SetTruncated()
+			return errors.New("ZigbeeAPS extended header too short")
+		}
+		offset++
+		if z.Fragmentation != 0 {
+			z.BlockNumber = data[offset]
+			offset++
+			if z.FrameType == ZigbeeAPSFrameTypeAck {
+				z.AckBitfield = data[offset]
+				offset++
+			}
+		}
+	}
+
+	payload := data[offset:]
+	if z.Security {
+		s, n, err := decodeZigbeeSecurityHeader(payload)
+		if err != nil {
+			df.SetTruncated()
+			return err
+		}
+		z.SecurityHeader = s
+		offset += n
+		z.MIC = data[len(data)-zigbeeMICLength:]
+		payload = data[offset : len(data)-zigbeeMICLength]
+	}
+	z.BaseLayer = BaseLayer{Contents: data[:offset], Payload: payload}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (z *ZigbeeAPS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if z.Security && z.SecurityHeader == nil {
+		return errors.New("ZigbeeAPS security set without a security header")
+	}
+	length := z.headerLength()
+	if z.Security {
+		length += z.SecurityHeader.length()
+		mic, err := b.AppendBytes(len(z.MIC))
+		if err != nil {
+			return err
+		}
+		copy(mic, z.MIC)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	fc := uint8(z.FrameType&0x3) | uint8(z.DeliveryMode&0x3)<<2
+	for _, f := range []struct {
+		set  bool
+		mask uint8
+	}{
+		{z.AckFormat, 0x10},
+		{z.Security, 0x20},
+		{z.AckRequest, 0x40},
+		{z.ExtendedHeader, 0x80},
+	} {
+		if f.set {
+			fc |= f.mask
+		}
+	}
+	bytes[0] = fc
+	offset := 1
+	group := z.DeliveryMode == ZigbeeAPSDeliveryModeGroup
+	if z.FrameType == ZigbeeAPSFrameTypeData && !group || z.FrameType == ZigbeeAPSFrameTypeAck && !z.AckFormat {
+		bytes[offset] = z.DestinationEndpoint
+		offset++
+	}
+	if group && (z.FrameType == ZigbeeAPSFrameTypeData || z.FrameType == ZigbeeAPSFrameTypeInterPAN) {
+		binary.LittleEndian.PutUint16(bytes[offset:], z.GroupAddress)
+		offset += 2
+	}
+	if z.hasEndpoints() || z.FrameType == ZigbeeAPSFrameTypeInterPAN {
+		binary.LittleEndian.PutUint16(bytes[offset:], z.ClusterID)
+		binary.LittleEndian.PutUint16(bytes[offset+2:], z.ProfileID)
+		offset += 4
+	}
+	if z.hasEndpoints() {
+		bytes[offset] = z.SourceEndpoint
+		offset++
+	}
+	if z.FrameType != ZigbeeAPSFrameTypeInterPAN {
+		bytes[offset] = z.Counter
+		offset++
+	}
+	if z.ExtendedHeader {
+		bytes[offset] = z.Fragmentation & 0x3
+		offset++
+		if z.Fragmentation != 0 {
+			bytes[offset] = z.BlockNumber
+			offset++
+			if z.FrameType == ZigbeeAPSFrameTypeAck {
+				bytes[offset] = z.AckBitfield
+				offset++
+			}
+		}
+	}
+	if z.Security {
+		z.SecurityHeader.encode(bytes[offset:])
+	}
+	return nil
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (z *ZigbeeAPS) CanDecode() gopacket.LayerClass {
+	return LayerTypeZigbeeAPS
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (z *ZigbeeAPS) NextLayerType() gopacket.LayerType {
+	if z.FrameType == ZigbeeAPSFrameTypeAck && len(z.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return gopacket.LayerTypePayload
+}
+
+func decodeZigbeeAPS(data []byte, p gopacket.PacketBuilder) error {
+	z := &ZigbeeAPS{}
+	return decodingLayerDecoder(z, data, p)
+}
@@ -0,0 +1,403 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package middlebox infers NATs, load balancers and transparent proxies
+// from the packets seen at a single capture point.
+//
+// None of these devices announce themselves, but they leave traces in the
+// headers of the traffic they handle:
+//
+//   - Hosts which increment a global IPv4 ID counter produce one ID
+//     sequence each. Several interleaved sequences from one address mean
+//     several hosts behind it: a NAT, or a load balancer if the address
+//     accepts connections (Bellovin, "A Technique for Counting NATted
+//     Hosts").
+//   - Operating systems differ in their initial TTL and hosts in their
+//     distance, so one address sending with several initial TTLs or hop
+//     counts is also several hosts.
+//   - A transparent proxy or SYN proxy answering the handshake on behalf
+//     of a server shows up as a TTL change between the handshake and the
+//     rest of a connection, or as TCP timestamps appearing although they
+//     were not negotiated.
+//   - A connection whose TCP timestamps jump backwards, or faster than any
+//     TCP clock runs, changed hosts midway, as when a load balancer fails
+//     over.
+//
+// All of these are heuristics. Hosts using per-destination or random IP
+// IDs, as current Linux and BSD kernels do, produce no usable sequences,
+// and route changes alter TTLs too. Findings should be read as hints to
+// investigate.
+//
+//	a := middlebox.NewAnalyzer()
+//	a.OnFinding = func(f middlebox.Finding) { log.Print(f) }
+//	for packet := range source.Packets() {
+//		a.Add(packet)
+//	}
+package middlebox
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/seqnum"
+)
+
+// Kind is the kind of a finding.
+type Kind uint8
+
+const (
+	// MultipleIPIDSequences means an address sent several interleaved
+	// IPv4 ID sequences. Count is the number of sequences.
+	MultipleIPIDSequences Kind = iota
+	// MultipleTTLs means an address sent packets with several initial TTLs
+	// or from several distances. Count is the number of distinct
+	// combinations.
+	MultipleTTLs
+	// TTLShiftInFlow means the TTL of one direction of a TCP connection
+	// changed after the handshake.
+	TTLShiftInFlow
+	// TimestampsNotNegotiated means TCP timestamps were sent on a
+	// connection whose handshake did not negotiate them.
+	TimestampsNotNegotiated
+	// TimestampJump means the TCP timestamps of one direction of a
+	// connection went backwards or advanced faster than a TCP clock can.
+	TimestampJump
+)
+
+func (k Kind) String() string {
+	switch k {
+	case MultipleIPIDSequences:
+		return "MultipleIPIDSequences"
+	case MultipleTTLs:
+		return "MultipleTTLs"
+	case TTLShiftInFlow:
+		return "TTLShiftInFlow"
+	case TimestampsNotNegotiated:
+		return "TimestampsNotNegotiated"
+	case TimestampJump:
+		return "TimestampJump"
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// Finding reports a trace of a middlebox.
+type Finding struct {
+	Kind Kind
+	// Addr is the source address of the packets showing the trace.
+	Addr gopacket.Endpoint
+	// Network and Transport identify the direction of the connection for
+	// findings about connections.
+	Network, Transport gopacket.Flow
+	// Count is the number of sequences or TTL combinations for
+	// MultipleIPIDSequences and MultipleTTLs.
+	Count int
+	// Server is set if Addr accepted TCP connections, which suggests a
+	// load balancer rather than a NAT for MultipleIPIDSequences and
+	// MultipleTTLs.
+	Server    bool
+	Detail    string
+	Timestamp time.Time
+}
+
+func (f Finding) String() string {
+	s := fmt.Sprintf("%v %v from %v", f.Timestamp.Format(time.RFC3339Nano), f.Kind, f.Addr)
+	if f.Transport != (gopacket.Flow{}) {
+		s += fmt.Sprintf(" (%v %v)", f.Network, f.Transport)
+	}
+	return s + ": " + f.Detail
+}
+
+type ipidSequence struct {
+	last  uint16
+	count int
+	seen  time.Time
+}
+
+type ttlKey struct {
+	initial, hops uint8
+}
+
+type hostState struct {
+	seqs     []*ipidSequence
+	ttls     map[ttlKey]int
+	server   bool
+	lastSeen time.Time
+	// reported holds the largest counts reported so far.
+	reportedSeqs, reportedTTLs int
+}
+
+type flowKey struct {
+	network, transport gopacket.Flow
+}
+
+type flowState struct {
+	// handshakeTTL is the TTL of the SYN or SYN-ACK, or -1.
+	handshakeTTL int
+	// handshakeTS is set if the SYN or SYN-ACK carried timestamps.
+	handshakeTS bool
+	// negotiated is known once both directions of the handshake were
+	// seen; notNegotiated is set if timestamps were not negotiated.
+	notNegotiated bool
+	tsVal         uint32
+	tsSeen        time.Time
+	haveTS        bool
+	reported      map[Kind]bool
+	lastSeen      time.Time
+}
+
+// Analyzer looks for traces of middleboxes in packets. An Analyzer is not
+// safe for concurrent use.
+type Analyzer struct {
+	// OnFinding, if not nil, is called for each finding. Address findings
+	// are reported again when their count grows; connection findings are
+	// reported once per direction of a connection.
+	OnFinding func(Finding)
+	// MaxIDGap is the largest increment between consecutive IPv4 IDs of a
+	// sequence. The default is 256.
+	MaxIDGap uint16
+	// SequenceTimeout is the time after which an idle IPv4 ID sequence is
+	// forgotten. The default is 10 seconds.
+	SequenceTimeout time.Duration
+	// MinPackets is the number of packets an ID sequence or a TTL
+	// combination needs before it counts. The default is 3.
+	MinPackets int
+
+	hosts map[gopacket.Endpoint]*hostState
+	flows map[flowKey]*flowState
+}
+
+// NewAnalyzer returns an Analyzer with the default settings.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		MaxIDGap:        256,
+		SequenceTimeout: 10 * time.Second,
+		MinPackets:      3,
+		hosts:           make(map[gopacket.Endpoint]*hostState),
+		flows:           make(map[flowKey]*flowState),
+	}
+}
+
+// maxSequences bounds the number of IPv4 ID sequences tracked per address.
+const maxSequences = 64
+
+// timestampSlack is the number of timestamp ticks a connection's
+// timestamps may go backwards, because of reordering, or run ahead of the
+// fastest allowed TCP clock of one tick per millisecond (RFC 7323).
+const timestampSlack = 1000
+
+// initialTTL guesses the initial TTL of a packet received with ttl.
+func initialTTL(ttl uint8) uint8 {
+	switch {
+	case ttl <= 32:
+		return 32
+	case ttl <= 64:
+		return 64
+	case ttl <= 128:
+		return 128
+	}
+	return 255
+}
+
+// Add analyzes packet. Packets without an IPv4 or IPv6 layer are ignored.
+func (a *Analyzer) Add(packet gopacket.Packet) {
+	if a.hosts == nil {
+		a.hosts = make(map[gopacket.Endpoint]*hostState)
+		a.flows = make(map[flowKey]*flowState)
+	}
+	ts := packet.Metadata().Timestamp
+	var network gopacket.Flow
+	var ttl uint8
+	var id uint16
+	hasID := false
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		network, ttl, id = ip.NetworkFlow(), ip.TTL, ip.Id
+		// Zero IDs are sent by hosts not using IDs for packets which must
+		// not be fragmented.
+		hasID = id != 0
+	case *layers.IPv6:
+		network, ttl = ip.NetworkFlow(), ip.HopLimit
+	default:
+		return
+	}
+	src := network.Src()
+	h := a.hosts[src]
+	if h == nil {
+		h = &hostState{ttls: make(map[ttlKey]int)}
+		a.hosts[src] = h
+	}
+	h.lastSeen = ts
+	if hasID {
+		a.addID(h, src, id, ts)
+	}
+	a.addTTL(h, src, ttl, ts)
+	if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		if tcp.SYN && tcp.ACK {
+			h.server = true
+		}
+		a.addTCP(network, tcp, ttl, ts)
+	}
+}
+
+func (a *Analyzer) minPackets() int {
+	if a.MinPackets <= 0 {
+		return 3
+	}
+	return a.MinPackets
+}
+
+func (a *Analyzer) addID(h *hostState, src gopacket.Endpoint, id uint16, ts time.Time) {
+	maxGap, timeout := a.MaxIDGap, a.SequenceTimeout
+	if maxGap == 0 {
+		maxGap = 256
+	}
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	var best *ipidSequence
+	live := h.seqs[:0]
+	for _, s := range h.seqs {
+		if ts.Sub(s.seen) > timeout {
+			continue
+		}
+		live = append(live, s)
+		gap := id - s.last
+		if gap != 0 && gap <= maxGap && (best == nil || gap < id-best.last) {
+			best = s
+		}
+	}
+	h.seqs = live
+	if best == nil {
+		if len(h.seqs) >= maxSequences {
+			return
+		}
+		best = &ipidSequence{}
+		h.seqs = append(h.seqs, best)
+	}
+	best.last, best.seen = id, ts
+	best.count++
+
+	n := 0
+	for _, s := range h.seqs {
+		if s.count >= a.minPackets() {
+			n++
+		}
+	}
+	if n >= 2 && n > h.reportedSeqs {
+		h.reportedSeqs = n
+		a.report(Finding{Kind: MultipleIPIDSequences, Addr: src, Count: n, Server: h.server, Timestamp: ts,
+			Detail: fmt.Sprintf("%d concurrent IP ID sequences", n)})
+	}
+}
+
+func (a *Analyzer) addTTL(h *hostState, src gopacket.Endpoint, ttl uint8, ts time.Time) {
+	init := initialTTL(ttl)
+	h.ttls[ttlKey{init, init - ttl}]++
+	n := 0
+	for _, c := range h.ttls {
+		if c >= a.minPackets() {
+			n++
+		}
+	}
+	if n >= 2 && n > h.reportedTTLs {
+		h.reportedTTLs = n
+		detail := ""
+		for k, c := range h.ttls {
+			if c >= a.minPackets() {
+				detail += fmt.Sprintf(" %d-%d", k.initial, k.hops)
+			}
+		}
+		a.report(Finding{Kind: MultipleTTLs, Addr: src, Count: n, Server: h.server, Timestamp: ts,
+			Detail: fmt.Sprintf("%d initial TTL and hop count combinations:%s", n, detail)})
+	}
+}
+
+func (a *Analyzer) addTCP(network gopacket.Flow, tcp *layers.TCP, ttl uint8, ts time.Time) {
+	transport := tcp.TransportFlow()
+	k := flowKey{network, transport}
+	st := a.flows[k]
+	if st == nil || (tcp.SYN && !tcp.ACK) {
+		st = &flowState{handshakeTTL: -1, reported: make(map[Kind]bool)}
+		a.flows[k] = st
+	}
+	st.lastSeen = ts
+	tsVal, _, hasTS := tcp.Timestamps()
+	f := Finding{Addr: network.Src(), Network: network, Transport: transport, Timestamp: ts}
+
+	if tcp.SYN {
+		st.handshakeTTL = int(ttl)
+		st.handshakeTS = hasTS
+		if tcp.ACK {
+			// Timestamps are negotiated if both SYNs carry them.
+			if rev := a.flows[flowKey{network.Reverse(), transport.Reverse()}]; rev != nil && rev.handshakeTTL >= 0 {
+				not := !(rev.handshakeTS && hasTS)
+				st.notNegotiated, rev.notNegotiated = not, not
+			}
+		}
+	} else {
+		if st.handshakeTTL >= 0 && int(ttl) != st.handshakeTTL {
+			f.Kind = TTLShiftInFlow
+			f.Detail = fmt.Sprintf("TTL %d during the handshake, %d after", st.handshakeTTL, ttl)
+			a.reportFlow(st, f)
+		}
+		if hasTS && st.notNegotiated {
+			f.Kind = TimestampsNotNegotiated
+			f.Detail = "TCP timestamps sent although the handshake did not negotiate them"
+			a.reportFlow(st, f)
+		}
+	}
+
+	if !hasTS {
+		return
+	}
+	if st.haveTS {
+		diff := int64(seqnum.Diff32(st.tsVal, tsVal))
+		limit := int64(ts.Sub(st.tsSeen)/time.Millisecond) + timestampSlack
+		if diff < -timestampSlack || diff > limit {
+			f.Kind = TimestampJump
+			f.Detail = fmt.Sprintf("TCP timestamp jumped by %d from %d to %d", diff, st.tsVal, tsVal)
+			a.reportFlow(st, f)
+		}
+	}
+	if !st.haveTS || seqnum.Less32(st.tsVal, tsVal) || tcp.SYN {
+		st.tsVal, st.tsSeen, st.haveTS = tsVal, ts, true
+	}
+}
+
+func (a *Analyzer) reportFlow(st *flowState, f Finding) {
+	if st.reported[f.Kind] {
+		return
+	}
+	st.reported[f.Kind] = true
+	a.report(f)
+}
+
+func (a *Analyzer) report(f Finding) {
+	if a.OnFinding != nil {
+		a.OnFinding(f)
+	}
+}
+
+// Hosts returns the number of addresses tracked.
+func (a *Analyzer) Hosts() int {
+	return len(a.hosts)
+}
+
+// Expire forgets the addresses and connections not seen since before.
+func (a *Analyzer) Expire(before time.Time) {
+	for k, h := range a.hosts {
+		if h.lastSeen.Before(before) {
+			delete(a.hosts, k)
+		}
+	}
+	for k, st := range a.flows {
+		if st.lastSeen.Before(before) {
+			delete(a.flows, k)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package middlebox
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	client = net.IP{10, 0, 0, 1}
+	server = net.IP{192, 0, 2, 1}
+	t0     = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func timestamps(val uint32) layers.TCPOption {
+	return layers.TCPOption{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10,
+		OptionData: []byte{byte(val >> 24), byte(val >> 16), byte(val >> 8), byte(val), 0, 0, 0, 0}}
+}
+
+func packet(t *testing.T, ts time.Time, src, dst net.IP, id uint16, ttl uint8, tcp *layers.TCP) gopacket.Packet {
+	ip := &layers.IPv4{Version: 4, IHL: 5, Id: id, TTL: ttl, Protocol: layers.IPProtocolTCP, SrcIP: src, DstIP: dst}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	p.Metadata().Timestamp = ts
+	return p
+}
+
+func collect(a *Analyzer) *[]Finding {
+	var found []Finding
+	a.OnFinding = func(f Finding) { found = append(found, f) }
+	return &found
+}
+
+func TestIPIDSequences(t *testing.T) {
+	a := NewAnalyzer()
+	found := collect(a)
+	// Two hosts behind client, with IDs around 1000 and 40000, and a
+	// third whose sequence wraps.
+	ids := []uint16{1000, 40000, 65534, 1001, 40003, 65535, 1005, 40004, 1, 1006}
+	for i, id := range ids {
+		a.Add(packet(t, t0.Add(time.Duration(i)*time.Millisecond), client, server, id, 64, &layers.TCP{SrcPort: 40000, DstPort: 80, ACK: true}))
+	}
+	if len(*found) != 2 {
+		t.Fatalf("got findings %v, want 2", *found)
+	}
+	f := (*found)[1]
+	if f.Kind != MultipleIPIDSequences || f.Count != 3 || f.Addr != layers.NewIPEndpoint(client) || f.Server {
+		t.Errorf("got %+v", f)
+	}
+
+	// A single sequence is one host.
+	a = NewAnalyzer()
+	found = collect(a)
+	for i := 0; i < 10; i++ {
+		a.Add(packet(t, t0, server, client, uint16(100+3*i), 64, &layers.TCP{SrcPort: 80, DstPort: 40000, ACK: true}))
+	}
+	if len(*found) != 0 {
+		t.Errorf("got findings %v for one host", *found)
+	}
+}
+
+func TestMultipleTTLs(t *testing.T) {
+	a := NewAnalyzer()
+	found := collect(a)
+	for i := 0; i < 3; i++ {
+		a.Add(packet(t, t0, server, client, 0, 57, &layers.TCP{SrcPort: 80, DstPort: 40000, SYN: true, ACK: true}))
+		a.Add(packet(t, t0, server, client, 0, 121, &layers.TCP{SrcPort: 80, DstPort: 40001, ACK: true}))
+	}
+	if len(*found) != 1 {
+		t.Fatalf("got findings %v, want 1", *found)
+	}
+	if f := (*found)[0]; f.Kind != MultipleTTLs || f.Count != 2 || !f.Server {
+		t.Errorf("got %+v", f)
+	}
+}
+
+func TestTransparentProxy(t *testing.T) {
+	a := NewAnalyzer()
+	found := collect(a)
+	a.Add(packet(t, t0, client, server, 0, 64, &layers.TCP{SrcPort: 40000, DstPort: 80, SYN: true}))
+	// A proxy close to the client answers the handshake without
+	// timestamps, then the server's packets come through with timestamps
+	// and a lower TTL.
+	a.Add(packet(t, t0, server, client, 0, 63, &layers.TCP{SrcPort: 80, DstPort: 40000, SYN: true, ACK: true}))
+	a.Add(packet(t, t0, client, server, 0, 64, &layers.TCP{SrcPort: 40000, DstPort: 80, ACK: true}))
+	for i := 0; i < 2; i++ {
+		a.Add(packet(t, t0, server, client, 0, 52, &layers.TCP{SrcPort: 80, DstPort: 40000, ACK: true,
+			Options: []layers.TCPOption{timestamps(5000)}}))
+	}
+	kinds := map[Kind]bool{}
+	for _, f := range *found {
+		kinds[f.Kind] = true
+		if f.Addr != layers.NewIPEndpoint(server) {
+			t.Errorf("finding %v for wrong address", f)
+		}
+	}
+	if len(*found) != 2 || !kinds[TTLShiftInFlow] || !kinds[TimestampsNotNegotiated] {
+		t.Errorf("got findings %v", *found)
+	}
+}
+
+func TestTimestampJump(t *testing.T) {
+	a := NewAnalyzer()
+	found := collect(a)
+	for i, val := range []uint32{1000, 1100, 1050, 2000, 500000, 500100} {
+		a.Add(packet(t, t0.Add(time.Duration(i)*time.Second), server, client, 0, 60, &layers.TCP{SrcPort: 80, DstPort: 40000, ACK: true,
+			Options: []layers.TCPOption{timestamps(val)}}))
+	}
+	if len(*found) != 1 || (*found)[0].Kind != TimestampJump {
+		t.Fatalf("got findings %v", *found)
+	}
+
+	a.Expire(t0.Add(time.Hour))
+	if a.Hosts() != 0 {
+		t.Errorf("%d hosts left after expiry", a.Hosts())
+	}
+}
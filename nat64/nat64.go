@@ -0,0 +1,629 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package nat64 translates packets between IPv4 and IPv6 with the IP/ICMP
+// Translation Algorithm of RFC 7915, which stateless (SIIT) and stateful
+// NAT64 translators and CLATs are built on.
+//
+// IPv4 addresses are embedded in an IPv6 prefix as RFC 6052 describes. A
+// Translator turns the IPv4 or IPv6 layer of a decoded packet into the
+// layers of the translated packet, ready to be serialized:
+//
+//	t := &nat64.Translator{}
+//	ip4 := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+//	translated, err := t.ToIPv6(ip4)
+//	if err != nil {
+//		return err // the packet is dropped
+//	}
+//	buf := gopacket.NewSerializeBuffer()
+//	err = gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, translated...)
+//
+// The translated layers carry correct checksums: TCP and UDP checksums are
+// adjusted to the translated pseudo-header, ICMP messages are translated
+// between ICMPv4 and ICMPv6, with the packet quoted by error messages, and
+// their checksums computed again. Other transport protocols are copied
+// unchanged.
+//
+// The TTL and hop limit are copied, translators forwarding packets
+// decrement them themselves. IPv4 options and IPv6 extension headers other
+// than the Fragment header are dropped, and IPv4 packets are not
+// fragmented to fit the IPv6 minimum MTU.
+package nat64
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// WellKnownPrefix is the Well-Known Prefix of RFC 6052, 64:ff9b::/96.
+var WellKnownPrefix = &net.IPNet{
+	IP:   net.IP{0, 0x64, 0xff, 0x9b, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	Mask: net.CIDRMask(96, 128),
+}
+
+// Translator translates packets between IPv4 and IPv6.
+type Translator struct {
+	// Prefix is the IPv6 prefix IPv4 addresses are embedded in, of length
+	// 32, 40, 48, 56, 64 or 96. WellKnownPrefix is used if it is nil.
+	Prefix *net.IPNet
+}
+
+func (t *Translator) prefix() (net.IP, int, error) {
+	p := t.Prefix
+	if p == nil {
+		p = WellKnownPrefix
+	}
+	ones, bits := p.Mask.Size()
+	switch {
+	case bits != 128 || p.IP.To16() == nil:
+		return nil, 0, fmt.Errorf("invalid IPv6 prefix %v", p)
+	case ones == 32, ones == 40, ones == 48, ones == 56, ones == 64, ones == 96:
+		return p.IP.To16(), ones / 8, nil
+	}
+	return nil, 0, fmt.Errorf("invalid IPv6 prefix length %d", ones)
+}
+
+// EmbedIPv4 returns the IPv6 address embedding ip in the prefix of t.
+func (t *Translator) EmbedIPv4(ip net.IP) (net.IP, error) {
+	prefix, n, err := t.prefix()
+	if err != nil {
+		return nil, err
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("%v is not an IPv4 address", ip)
+	}
+	ip6 := make(net.IP, net.IPv6len)
+	copy(ip6[:n], prefix)
+	// Bits 64 to 71 are left zero.
+	for i, j := n, 0; j < len(ip4); i++ {
+		if i != 8 {
+			ip6[i] = ip4[j]
+			j++
+		}
+	}
+	return ip6, nil
+}
+
+// ExtractIPv4 returns the IPv4 address embedded in ip, which must be in
+// the prefix of t.
+func (t *Translator) ExtractIPv4(ip net.IP) (net.IP, error) {
+	prefix, n, err := t.prefix()
+	if err != nil {
+		return nil, err
+	}
+	ip6 := ip.To16()
+	if ip6 == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("%v is not an IPv6 address", ip)
+	}
+	if !net.IP(ip6[:n]).Equal(prefix[:n]) {
+		return nil, fmt.Errorf("%v is not in the translation prefix", ip)
+	}
+	ip4 := make(net.IP, net.IPv4len)
+	for i, j := n, 0; j < len(ip4); i++ {
+		if i != 8 {
+			ip4[j] = ip6[i]
+			j++
+		}
+	}
+	return ip4, nil
+}
+
+// sum adds the 16 bit words of data to the one's complement sum s.
+func sum(s uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		s += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		s += uint32(data[len(data)-1]) << 8
+	}
+	return s
+}
+
+func fold(s uint32) uint16 {
+	for s > 0xffff {
+		s = (s >> 16) + (s & 0xffff)
+	}
+	return uint16(s)
+}
+
+// adjust returns the checksum csum updated for data summing to old
+// replaced by data summing to new (RFC 1624).
+func adjust(csum uint16, old, new uint32) uint16 {
+	return ^fold(uint32(^csum) + uint32(^fold(old)) + uint32(fold(new)))
+}
+
+// pseudoHeader6 returns the sum of the IPv6 pseudo-header.
+func pseudoHeader6(src, dst net.IP, length int, proto layers.IPProtocol) uint32 {
+	return sum(sum(0, src), dst) + uint32(length>>16) + uint32(length&0xffff) + uint32(proto)
+}
+
+// header is an IPv4 or IPv6 header, and its fragmentation.
+type header struct {
+	src, dst net.IP
+	proto    layers.IPProtocol
+	tos, ttl uint8
+	length   int // the length of the transport header and its payload
+	fragment bool
+	id       uint32
+	offset   uint16
+	more     bool
+}
+
+// first reports whether the packet holds the transport header.
+func (h *header) first() bool {
+	return h.offset == 0
+}
+
+// whole reports whether the packet was not fragmented.
+func (h *header) whole() bool {
+	return !h.fragment || h.offset == 0 && !h.more
+}
+
+func parse4(data []byte) (h header, transport []byte, err error) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return h, nil, errors.New("invalid IPv4 header")
+	}
+	ihl := int(data[0]&0xf) * 4
+	if ihl < 20 || ihl > len(data) {
+		return h, nil, fmt.Errorf("invalid IPv4 header length %d", ihl)
+	}
+	h.src, h.dst = net.IP(data[12:16]), net.IP(data[16:20])
+	h.proto = layers.IPProtocol(data[9])
+	h.tos, h.ttl = data[1], data[8]
+	h.length = int(binary.BigEndian.Uint16(data[2:4])) - ihl
+	flags := binary.BigEndian.Uint16(data[6:8])
+	h.id = uint32(binary.BigEndian.Uint16(data[4:6]))
+	h.offset, h.more = flags&0x1fff, flags&0x2000 != 0
+	h.fragment = h.more || h.offset != 0
+	return h, data[ihl:], nil
+}
+
+func parse6(data []byte) (h header, transport []byte, err error) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return h, nil, errors.New("invalid IPv6 header")
+	}
+	h.src, h.dst = net.IP(data[8:24]), net.IP(data[24:40])
+	h.tos = data[0]<<4 | data[1]>>4
+	h.ttl = data[7]
+	h.length = int(binary.BigEndian.Uint16(data[4:6]))
+	h.proto = layers.IPProtocol(data[6])
+	offset := 40
+	for {
+		switch h.proto {
+		case layers.IPProtocolIPv6HopByHop, layers.IPProtocolIPv6Destination, layers.IPProtocolIPv6Routing, layers.IPProtocolIPv6Fragment:
+		default:
+			if offset > len(data) {
+				return h, nil, errors.New("IPv6 extension header truncated")
+			}
+			h.length -= offset - 40
+			return h, data[offset:], nil
+		}
+		if offset+8 > len(data) {
+			return h, nil, errors.New("IPv6 extension header truncated")
+		}
+		next := layers.IPProtocol(data[offset])
+		switch h.proto {
+		case layers.IPProtocolIPv6Routing:
+			if data[offset+3] != 0 {
+				return h, nil, errors.New("IPv6 routing header with segments left")
+			}
+			offset += (int(data[offset+1]) + 1) * 8
+		case layers.IPProtocolIPv6Fragment:
+			flags := binary.BigEndian.Uint16(data[offset+2:])
+			h.fragment, h.offset, h.more = true, flags>>3, flags&1 != 0
+			h.id = binary.BigEndian.Uint32(data[offset+4:])
+			offset += 8
+		default:
+			offset += (int(data[offset+1]) + 1) * 8
+		}
+		h.proto = next
+	}
+}
+
+// ToIPv6 translates an IPv4 packet to IPv6, returning an IPv6 layer,
+// followed by a Fragment header if the packet is a fragment, and the
+// translated payload.
+func (t *Translator) ToIPv6(ip *layers.IPv4) ([]gopacket.SerializableLayer, error) {
+	h, _, err := parse4(ip.Contents)
+	if err != nil {
+		return nil, err
+	}
+	h.length = len(ip.Payload)
+	return t.toIPv6(h, ip.Payload, false)
+}
+
+func (t *Translator) toIPv6(h header, transport []byte, inner bool) ([]gopacket.SerializableLayer, error) {
+	src, err := t.EmbedIPv4(h.src)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := t.EmbedIPv4(h.dst)
+	if err != nil {
+		return nil, err
+	}
+	ip6 := &layers.IPv6{
+		Version:      6,
+		TrafficClass: h.tos,
+		NextHeader:   h.proto,
+		HopLimit:     h.ttl,
+		SrcIP:        src,
+		DstIP:        dst,
+	}
+	if h.proto == layers.IPProtocolICMPv4 {
+		ip6.NextHeader = layers.IPProtocolICMPv6
+	}
+	payload, err := t.transport4to6(h, ip6, transport, inner)
+	if err != nil {
+		return nil, err
+	}
+	length := h.length + len(payload) - len(transport)
+	translated := []gopacket.SerializableLayer{ip6}
+	if h.fragment {
+		translated = append(translated, &layers.IPv6Fragment{
+			NextHeader:     ip6.NextHeader,
+			FragmentOffset: h.offset,
+			MoreFragments:  h.more,
+			Identification: h.id,
+		})
+		ip6.NextHeader = layers.IPProtocolIPv6Fragment
+		length += 8
+	}
+	ip6.Length = uint16(length)
+	return append(translated, gopacket.Payload(payload)), nil
+}
+
+// transport4to6 translates the transport header and payload of an IPv4
+// packet, or of the IPv4 packet quoted in an ICMP error if inner is set,
+// to be carried by ip6.
+func (t *Translator) transport4to6(h header, ip6 *layers.IPv6, transport []byte, inner bool) ([]byte, error) {
+	b := append([]byte(nil), transport...)
+	old := sum(sum(0, h.src), h.dst)
+	switch h.proto {
+	case layers.IPProtocolTCP:
+		if h.first() && len(b) >= 18 {
+			binary.BigEndian.PutUint16(b[16:], adjust(binary.BigEndian.Uint16(b[16:]), old, sum(sum(0, ip6.SrcIP), ip6.DstIP)))
+		}
+	case layers.IPProtocolUDP:
+		if !h.first() || len(b) < 8 {
+			break
+		}
+		csum := binary.BigEndian.Uint16(b[6:])
+		if csum != 0 {
+			csum = adjust(csum, old, sum(sum(0, ip6.SrcIP), ip6.DstIP))
+		} else if inner {
+			break
+		} else if !h.whole() {
+			return nil, errors.New("fragmented UDP packet without checksum")
+		} else {
+			// IPv6 requires UDP checksums.
+			csum = ^fold(sum(pseudoHeader6(ip6.SrcIP, ip6.DstIP, len(b), layers.IPProtocolUDP), b))
+		}
+		if csum == 0 {
+			csum = 0xffff
+		}
+		binary.BigEndian.PutUint16(b[6:], csum)
+	case layers.IPProtocolICMPv4:
+		if inner {
+			// Echo messages are quoted by errors about them, and
+			// their checksum gains a pseudo-header.
+			if len(b) < 4 {
+				return b, nil
+			}
+			if typ, ok := echo4to6[b[0]]; ok {
+				old := sum(0, b[:2])
+				b[0] = typ
+				pseudo := pseudoHeader6(ip6.SrcIP, ip6.DstIP, h.length, layers.IPProtocolICMPv6)
+				binary.BigEndian.PutUint16(b[2:], adjust(binary.BigEndian.Uint16(b[2:]), old, sum(pseudo, b[:2])))
+			}
+			return b, nil
+		}
+		if !h.whole() {
+			return nil, errors.New("fragmented ICMP packet")
+		}
+		m, err := t.icmp4to6(b)
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(m[2:], ^fold(sum(pseudoHeader6(ip6.SrcIP, ip6.DstIP, len(m), layers.IPProtocolICMPv6), m)))
+		return m, nil
+	}
+	return b, nil
+}
+
+var echo4to6 = map[uint8]uint8{
+	layers.ICMPv4TypeEchoRequest: layers.ICMPv6TypeEchoRequest,
+	layers.ICMPv4TypeEchoReply:   layers.ICMPv6TypeEchoReply,
+}
+
+var echo6to4 = map[uint8]uint8{
+	layers.ICMPv6TypeEchoRequest: layers.ICMPv4TypeEchoRequest,
+	layers.ICMPv6TypeEchoReply:   layers.ICMPv4TypeEchoReply,
+}
+
+// icmpError is the translation of an ICMP error type and code.
+type icmpError struct {
+	typ, code uint8
+}
+
+var icmp4to6Unreachable = map[uint8]icmpError{
+	layers.ICMPv4CodeNet:                 {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeHost:                {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeProtocol:            {layers.ICMPv6TypeParameterProblem, layers.ICMPv6CodeUnrecognizedNextHeader},
+	layers.ICMPv4CodePort:                {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodePortUnreachable},
+	layers.ICMPv4CodeFragmentationNeeded: {layers.ICMPv6TypePacketTooBig, 0},
+	layers.ICMPv4CodeSourceRoutingFailed: {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeNetUnknown:          {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeHostUnknown:         {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeSourceIsolated:      {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeNetAdminProhibited:  {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeAdminProhibited},
+	layers.ICMPv4CodeHostAdminProhibited: {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeAdminProhibited},
+	layers.ICMPv4CodeNetTOS:              {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeHostTOS:             {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeNoRouteToDst},
+	layers.ICMPv4CodeCommAdminProhibited: {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeAdminProhibited},
+	layers.ICMPv4CodePrecedenceCutoff:    {layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodeAdminProhibited},
+}
+
+var icmp6to4Unreachable = map[uint8]icmpError{
+	layers.ICMPv6CodeNoRouteToDst:       {layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeHost},
+	layers.ICMPv6CodeAdminProhibited:    {layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeHostAdminProhibited},
+	layers.ICMPv6CodeBeyondScopeOfSrc:   {layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeHost},
+	layers.ICMPv6CodeAddressUnreachable: {layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeHost},
+	layers.ICMPv6CodePortUnreachable:    {layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort},
+}
+
+// pointer4to6 and pointer6to4 translate the pointers of parameter problem
+// messages to the fields of the translated header. Fields without a
+// translation, such as the IPv4 header checksum, are not listed.
+var pointer4to6 = map[uint8]uint8{
+	0: 0, 1: 1, 2: 4, 3: 4, 8: 7, 9: 6,
+	12: 8, 13: 8, 14: 8, 15: 8,
+	16: 24, 17: 24, 18: 24, 19: 24,
+}
+
+var pointer6to4 = map[uint8]uint8{
+	0: 0, 1: 1, 4: 2, 5: 2, 6: 9, 7: 8,
+}
+
+func init() {
+	for i := uint8(8); i < 24; i++ {
+		pointer6to4[i] = 12
+	}
+	for i := uint8(24); i < 40; i++ {
+		pointer6to4[i] = 16
+	}
+}
+
+// icmp4to6 translates an ICMPv4 message to ICMPv6, leaving its checksum
+// to compute.
+func (t *Translator) icmp4to6(m []byte) ([]byte, error) {
+	if len(m) < 8 {
+		return nil, errors.New("ICMPv4 message too short")
+	}
+	typ, code := m[0], m[1]
+	out := make([]byte, 8, len(m)+20)
+	copy(out[4:], m[4:8])
+	switch typ {
+	case layers.ICMPv4TypeEchoRequest, layers.ICMPv4TypeEchoReply:
+		out[0] = echo4to6[typ]
+		return append(out, m[8:]...), nil
+	case layers.ICMPv4TypeDestinationUnreachable:
+		e, ok := icmp4to6Unreachable[code]
+		if !ok {
+			return nil, fmt.Errorf("untranslatable ICMPv4 destination unreachable code %d", code)
+		}
+		out[0], out[1] = e.typ, e.code
+		binary.BigEndian.PutUint32(out[4:], 0)
+		switch e.typ {
+		case layers.ICMPv6TypePacketTooBig:
+			binary.BigEndian.PutUint32(out[4:], uint32(binary.BigEndian.Uint16(m[6:8]))+20)
+		case layers.ICMPv6TypeParameterProblem:
+			// The Next Header field.
+			out[7] = 6
+		}
+	case layers.ICMPv4TypeTimeExceeded:
+		out[0], out[1] = layers.ICMPv6TypeTimeExceeded, code
+		binary.BigEndian.PutUint32(out[4:], 0)
+	case layers.ICMPv4TypeParameterProblem:
+		p, ok := pointer4to6[m[4]]
+		if code != layers.ICMPv4CodePointerIndicatesError && code != layers.ICMPv4CodeBadLength || !ok {
+			return nil, fmt.Errorf("untranslatable ICMPv4 parameter problem code %d pointer %d", code, m[4])
+		}
+		out[0], out[1] = layers.ICMPv6TypeParameterProblem, layers.ICMPv6CodeErroneousHeaderField
+		binary.BigEndian.PutUint32(out[4:], uint32(p))
+	default:
+		return nil, fmt.Errorf("untranslatable ICMPv4 type %d", typ)
+	}
+	// Translate the quoted packet.
+	h, transport, err := parse4(m[8:])
+	if err != nil {
+		return nil, err
+	}
+	quoted, err := t.toIPv6(h, transport, true)
+	if err != nil {
+		return nil, err
+	}
+	return appendLayers(out, quoted, gopacket.SerializeOptions{})
+}
+
+// appendLayers appends the serialization of ls to b.
+func appendLayers(b []byte, ls []gopacket.SerializableLayer, opts gopacket.SerializeOptions) ([]byte, error) {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		return nil, err
+	}
+	return append(b, buf.Bytes()...), nil
+}
+
+// ToIPv4 translates an IPv6 packet to IPv4, returning an IPv4 layer and the
+// translated payload. Both addresses of the packet must be in the prefix of
+// t.
+func (t *Translator) ToIPv4(ip *layers.IPv6) ([]gopacket.SerializableLayer, error) {
+	data := append(append([]byte(nil), ip.Contents...), ip.Payload...)
+	h, transport, err := parse6(data)
+	if err != nil {
+		return nil, err
+	}
+	h.length = len(transport)
+	return t.toIPv4(h, transport, false)
+}
+
+func (t *Translator) toIPv4(h header, transport []byte, inner bool) ([]gopacket.SerializableLayer, error) {
+	src, err := t.ExtractIPv4(h.src)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := t.ExtractIPv4(h.dst)
+	if err != nil {
+		return nil, err
+	}
+	ip4 := &layers.IPv4{
+		Version:  4,
+		IHL:      5,
+		TOS:      h.tos,
+		TTL:      h.ttl,
+		Protocol: h.proto,
+		SrcIP:    src,
+		DstIP:    dst,
+	}
+	if h.proto == layers.IPProtocolICMPv6 {
+		ip4.Protocol = layers.IPProtocolICMPv4
+	}
+	payload, err := t.transport6to4(h, ip4, transport, inner)
+	if err != nil {
+		return nil, err
+	}
+	ip4.Length = uint16(20 + h.length + len(payload) - len(transport))
+	if h.fragment {
+		ip4.Id = uint16(h.id)
+		ip4.FragOffset = h.offset
+		if h.more {
+			ip4.Flags = layers.IPv4MoreFragments
+		}
+	} else if ip4.Length > 1260 {
+		ip4.Flags = layers.IPv4DontFragment
+	}
+	// Compute the header checksum, to serialize the layers with any
+	// options.
+	if _, err := appendLayers(nil, []gopacket.SerializableLayer{ip4}, gopacket.SerializeOptions{ComputeChecksums: true}); err != nil {
+		return nil, err
+	}
+	return []gopacket.SerializableLayer{ip4, gopacket.Payload(payload)}, nil
+}
+
+// transport6to4 translates the transport header and payload of an IPv6
+// packet, or of the IPv6 packet quoted in an ICMP error if inner is set,
+// to be carried by ip4.
+func (t *Translator) transport6to4(h header, ip4 *layers.IPv4, transport []byte, inner bool) ([]byte, error) {
+	b := append([]byte(nil), transport...)
+	old := sum(sum(0, h.src), h.dst)
+	csumOffset := -1
+	switch h.proto {
+	case layers.IPProtocolTCP:
+		csumOffset = 16
+	case layers.IPProtocolUDP:
+		csumOffset = 6
+	case layers.IPProtocolICMPv6:
+		if inner {
+			if len(b) < 4 {
+				return b, nil
+			}
+			if typ, ok := echo6to4[b[0]]; ok {
+				pseudo := pseudoHeader6(h.src, h.dst, h.length, layers.IPProtocolICMPv6)
+				old := sum(pseudo, b[:2])
+				b[0] = typ
+				binary.BigEndian.PutUint16(b[2:], adjust(binary.BigEndian.Uint16(b[2:]), old, sum(0, b[:2])))
+			}
+			return b, nil
+		}
+		if !h.whole() {
+			return nil, errors.New("fragmented ICMPv6 packet")
+		}
+		m, err := t.icmp6to4(b)
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(m[2:], ^fold(sum(0, m)))
+		return m, nil
+	}
+	if csumOffset >= 0 && h.first() && len(b) >= csumOffset+2 {
+		csum := adjust(binary.BigEndian.Uint16(b[csumOffset:]), old, sum(sum(0, ip4.SrcIP), ip4.DstIP))
+		if csum == 0 && h.proto == layers.IPProtocolUDP {
+			csum = 0xffff
+		}
+		binary.BigEndian.PutUint16(b[csumOffset:], csum)
+	}
+	return b, nil
+}
+
+// icmp6to4 translates an ICMPv6 message to ICMPv4, leaving its checksum
+// to compute.
+func (t *Translator) icmp6to4(m []byte) ([]byte, error) {
+	if len(m) < 8 {
+		return nil, errors.New("ICMPv6 message too short")
+	}
+	typ, code := m[0], m[1]
+	out := make([]byte, 8, len(m))
+	copy(out[4:], m[4:8])
+	switch typ {
+	case layers.ICMPv6TypeEchoRequest, layers.ICMPv6TypeEchoReply:
+		out[0] = echo6to4[typ]
+		return append(out, m[8:]...), nil
+	case layers.ICMPv6TypeDestinationUnreachable:
+		e, ok := icmp6to4Unreachable[code]
+		if !ok {
+			return nil, fmt.Errorf("untranslatable ICMPv6 destination unreachable code %d", code)
+		}
+		out[0], out[1] = e.typ, e.code
+		binary.BigEndian.PutUint32(out[4:], 0)
+	case layers.ICMPv6TypePacketTooBig:
+		out[0], out[1] = layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeFragmentationNeeded
+		mtu := binary.BigEndian.Uint32(m[4:8])
+		if mtu < 20 {
+			mtu = 20
+		}
+		if mtu -= 20; mtu > 0xffff {
+			mtu = 0xffff
+		}
+		binary.BigEndian.PutUint32(out[4:], mtu)
+	case layers.ICMPv6TypeTimeExceeded:
+		out[0], out[1] = layers.ICMPv4TypeTimeExceeded, code
+		binary.BigEndian.PutUint32(out[4:], 0)
+	case layers.ICMPv6TypeParameterProblem:
+		switch code {
+		case layers.ICMPv6CodeErroneousHeaderField:
+			pointer := binary.BigEndian.Uint32(m[4:8])
+			p, ok := pointer6to4[uint8(pointer)]
+			if pointer > 0xff || !ok {
+				return nil, fmt.Errorf("untranslatable ICMPv6 parameter problem pointer %d", pointer)
+			}
+			out[0], out[1] = layers.ICMPv4TypeParameterProblem, layers.ICMPv4CodePointerIndicatesError
+			binary.BigEndian.PutUint32(out[4:], uint32(p)<<24)
+		case layers.ICMPv6CodeUnrecognizedNextHeader:
+			out[0], out[1] = layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeProtocol
+			binary.BigEndian.PutUint32(out[4:], 0)
+		default:
+			return nil, fmt.Errorf("untranslatable ICMPv6 parameter problem code %d", code)
+		}
+	default:
+		return nil, fmt.Errorf("untranslatable ICMPv6 type %d", typ)
+	}
+	// Translate the quoted packet.
+	h, transport, err := parse6(m[8:])
+	if err != nil {
+		return nil, err
+	}
+	quoted, err := t.toIPv4(h, transport, true)
+	if err != nil {
+		return nil, err
+	}
+	return appendLayers(out, quoted, gopacket.SerializeOptions{})
+}
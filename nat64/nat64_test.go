@@ -0,0 +1,227 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package nat64
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestEmbedIPv4(t *testing.T) {
+	// The examples of RFC 6052, section 2.4.
+	ip4 := net.IP{192, 0, 2, 33}
+	for _, test := range []struct {
+		prefix, want string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::c000:221"},
+		{"64:ff9b::/96", "64:ff9b::c000:221"},
+	} {
+		_, prefix, _ := net.ParseCIDR(test.prefix)
+		tr := &Translator{Prefix: prefix}
+		got, err := tr.EmbedIPv4(ip4)
+		if err != nil || got.String() != test.want {
+			t.Errorf("%s: embedded as %v, %v, want %s", test.prefix, got, err, test.want)
+			continue
+		}
+		if back, err := tr.ExtractIPv4(got); err != nil || !back.Equal(ip4) {
+			t.Errorf("%s: extracted %v, %v", test.prefix, back, err)
+		}
+	}
+	if _, err := (&Translator{}).ExtractIPv4(net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("extracted an address out of the prefix")
+	}
+	_, odd, _ := net.ParseCIDR("2001:db8::/33")
+	if _, err := (&Translator{Prefix: odd}).EmbedIPv4(ip4); err == nil {
+		t.Error("embedded in a /33 prefix")
+	}
+}
+
+var (
+	host4   = net.IP{198, 51, 100, 7}
+	server4 = net.IP{192, 0, 2, 1}
+)
+
+func serialize(t *testing.T, ls ...gopacket.SerializableLayer) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// roundTrip translates an IPv4 packet to IPv6 and back, checking both
+// translations, and returns the IPv6 packet.
+func roundTrip(t *testing.T, name string, data []byte) gopacket.Packet {
+	tr := &Translator{}
+	p4 := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+	translated, err := tr.ToIPv6(p4.Layer(layers.LayerTypeIPv4).(*layers.IPv4))
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, translated...); err != nil {
+		t.Fatal(name, err)
+	}
+	p6 := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv6, gopacket.Default)
+	if p6.ErrorLayer() != nil {
+		t.Fatal(name, p6.ErrorLayer().Error())
+	}
+	for _, r := range p6.VerifyChecksums(gopacket.ChecksumOptions{}) {
+		if r.Status != gopacket.ChecksumCorrect {
+			t.Errorf("%s: IPv6 %v checksum %+v", name, r.LayerType, r)
+		}
+	}
+
+	back, err := tr.ToIPv4(p6.Layer(layers.LayerTypeIPv6).(*layers.IPv6))
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, back...); err != nil {
+		t.Fatal(name, err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("%s: translated back as\n%x, want\n%x", name, buf.Bytes(), data)
+	}
+	return p6
+}
+
+func TestTranslateTransport(t *testing.T) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, TOS: 0x10, Protocol: layers.IPProtocolTCP, SrcIP: host4, DstIP: server4}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 443, Seq: 7, SYN: true, Window: 1000}
+	tcp.SetNetworkLayerForChecksum(ip)
+	p6 := roundTrip(t, "tcp", serialize(t, ip, tcp, gopacket.Payload("hello")))
+	ip6 := p6.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	if ip6.SrcIP.String() != "64:ff9b::c633:6407" || ip6.DstIP.String() != "64:ff9b::c000:201" ||
+		ip6.HopLimit != 64 || ip6.TrafficClass != 0x10 || ip6.NextHeader != layers.IPProtocolTCP {
+		t.Errorf("translated as %+v", ip6)
+	}
+
+	ip.Protocol = layers.IPProtocolUDP
+	udp := &layers.UDP{SrcPort: 5000, DstPort: 9999}
+	udp.SetNetworkLayerForChecksum(ip)
+	roundTrip(t, "udp", serialize(t, ip, udp, gopacket.Payload("query")))
+
+	ip.Protocol = layers.IPProtocolICMPv4
+	echo := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 2}
+	p6 = roundTrip(t, "echo", serialize(t, ip, echo, gopacket.Payload("ping")))
+	if e, ok := p6.Layer(layers.LayerTypeICMPv6Echo).(*layers.ICMPv6Echo); !ok || e.Identifier != 1 || e.SeqNumber != 2 {
+		t.Errorf("echo translated as %v", p6)
+	}
+}
+
+func TestTranslateUDPWithoutChecksum(t *testing.T) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: host4, DstIP: server4}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip, &layers.UDP{SrcPort: 1, DstPort: 2}, gopacket.Payload("data")); err != nil {
+		t.Fatal(err)
+	}
+	p4 := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	translated, err := (&Translator{}).ToIPv6(p4.Layer(layers.LayerTypeIPv4).(*layers.IPv4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Clear()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, translated...); err != nil {
+		t.Fatal(err)
+	}
+	p6 := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv6, gopacket.Default)
+	if r := p6.VerifyChecksums(gopacket.ChecksumOptions{}); len(r) != 1 || r[0].Status != gopacket.ChecksumCorrect {
+		t.Errorf("UDP checksum %+v", r)
+	}
+}
+
+func TestTranslateICMPErrors(t *testing.T) {
+	// A port unreachable error about a UDP packet, which it quotes whole.
+	quotedIP := &layers.IPv4{Version: 4, TTL: 3, Protocol: layers.IPProtocolUDP, SrcIP: host4, DstIP: server4}
+	quotedUDP := &layers.UDP{SrcPort: 33434, DstPort: 33435}
+	quotedUDP.SetNetworkLayerForChecksum(quotedIP)
+	quoted := serialize(t, quotedIP, quotedUDP, gopacket.Payload("probe"))
+
+	ip := &layers.IPv4{Version: 4, TTL: 60, Protocol: layers.IPProtocolICMPv4, SrcIP: server4, DstIP: host4}
+	unreachable := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort)}
+	p6 := roundTrip(t, "port unreachable", serialize(t, ip, unreachable, gopacket.Payload(quoted)))
+	icmp := p6.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6)
+	if icmp.TypeCode != layers.CreateICMPv6TypeCode(layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodePortUnreachable) {
+		t.Errorf("translated as %v", icmp.TypeCode)
+	}
+	inner := gopacket.NewPacket(icmp.Payload[4:], layers.LayerTypeIPv6, gopacket.Default)
+	if udp, ok := inner.Layer(layers.LayerTypeUDP).(*layers.UDP); !ok || udp.DstPort != 33435 {
+		t.Fatalf("quoted packet translated as %v", inner)
+	}
+	if r := inner.VerifyChecksums(gopacket.ChecksumOptions{}); len(r) != 1 || r[0].Status != gopacket.ChecksumCorrect {
+		t.Errorf("quoted UDP checksum %+v", r)
+	}
+
+	// A time exceeded error about an echo request, whose checksum gains
+	// the IPv6 pseudo-header.
+	quotedIP.Protocol = layers.IPProtocolICMPv4
+	echo := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 9, Seq: 1}
+	quoted = serialize(t, quotedIP, echo, gopacket.Payload("ping"))
+	exceeded := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeTimeExceeded, layers.ICMPv4CodeTTLExceeded)}
+	p6 = roundTrip(t, "time exceeded", serialize(t, ip, exceeded, gopacket.Payload(quoted)))
+	icmp = p6.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6)
+	inner = gopacket.NewPacket(icmp.Payload[4:], layers.LayerTypeIPv6, gopacket.Default)
+	if r := inner.VerifyChecksums(gopacket.ChecksumOptions{}); len(r) != 1 || r[0].Status != gopacket.ChecksumCorrect {
+		t.Errorf("quoted echo checksum %+v", r)
+	}
+
+	// An error quoting only the header of an ICMP packet.
+	quoted = serialize(t, quotedIP)
+	roundTrip(t, "header only", serialize(t, ip, exceeded, gopacket.Payload(quoted)))
+
+	// Fragmentation needed maps to packet too big, with the MTU of the
+	// larger IPv6 header.
+	ip.Protocol = layers.IPProtocolICMPv4
+	tooBig := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeFragmentationNeeded), Seq: 1400}
+	quotedIP.Protocol = layers.IPProtocolUDP
+	quoted = serialize(t, quotedIP, quotedUDP, gopacket.Payload("probe"))
+	p6 = roundTrip(t, "fragmentation needed", serialize(t, ip, tooBig, gopacket.Payload(quoted)))
+	icmp = p6.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6)
+	if icmp.TypeCode.Type() != layers.ICMPv6TypePacketTooBig || !bytes.Equal(icmp.Payload[:4], []byte{0, 0, 0x05, 0x8c}) {
+		t.Errorf("translated as %v with %x", icmp.TypeCode, icmp.Payload[:4])
+	}
+}
+
+func TestTranslateFragment(t *testing.T) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Id: 0x1234, Flags: layers.IPv4MoreFragments, FragOffset: 100, Protocol: layers.IPProtocolUDP, SrcIP: host4, DstIP: server4}
+	p6 := roundTrip(t, "fragment", serialize(t, ip, gopacket.Payload("middle of a datagram")))
+	frag, ok := p6.Layer(layers.LayerTypeIPv6Fragment).(*layers.IPv6Fragment)
+	if !ok || frag.Identification != 0x1234 || frag.FragmentOffset != 100 || !frag.MoreFragments || frag.NextHeader != layers.IPProtocolUDP {
+		t.Errorf("fragment translated as %v", p6)
+	}
+}
+
+func TestUntranslatable(t *testing.T) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolICMPv4, SrcIP: host4, DstIP: server4}
+	timestamp := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeTimestampRequest, 0)}
+	p4 := gopacket.NewPacket(serialize(t, ip, timestamp, gopacket.Payload(make([]byte, 12))), layers.LayerTypeIPv4, gopacket.Default)
+	if _, err := (&Translator{}).ToIPv4(&layers.IPv6{}); err == nil {
+		t.Error("translated an empty IPv6 layer")
+	}
+	if got, err := (&Translator{}).ToIPv6(p4.Layer(layers.LayerTypeIPv4).(*layers.IPv4)); err == nil {
+		t.Errorf("translated a timestamp request as %v", got)
+	}
+
+	ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("64:ff9b::c000:201")}
+	udp := &layers.UDP{SrcPort: 1, DstPort: 2}
+	udp.SetNetworkLayerForChecksum(ip6)
+	p6 := gopacket.NewPacket(serialize(t, ip6, udp), layers.LayerTypeIPv6, gopacket.Default)
+	if _, err := (&Translator{}).ToIPv4(p6.Layer(layers.LayerTypeIPv6).(*layers.IPv6)); err == nil {
+		t.Error("translated a source address out of the prefix")
+	}
+}
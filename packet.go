@@ -48,6 +48,23 @@ type PacketMetadata struct {
 	// This is also set automatically for packets captured off the wire if
 	// CaptureInfo.CaptureLength < CaptureInfo.Length.
 	Truncated bool
+	// Tunnel names the kind of tunnel the packet's inner network layer was
+	// decapsulated from, if its decoder recognized one, for example "6to4"
+	// for IPv6 carried in IPv4. It is empty otherwise.
+	Tunnel string
+	// Tags are labels attached to the packet by rules evaluated when it was
+	// decoded, such as the coloring rules of a packet browser, highest
+	// priority first.
+	Tags []PacketTag
+}
+
+// PacketTag is a label attached to a packet, with the color and priority
+// a user interface should display it with. Its field names are kept short
+// in JSON, where it is exported alongside each packet.
+type PacketTag struct {
+	Name     string `json:"name"`
+	Color    string `json:"color,omitempty"`
+	Priority int    `json:"priority,omitempty"`
 }
 
 // Packet is the primary object used by gopacket.  Packets are created by a
@@ -97,6 +114,12 @@ type Packet interface {
 	Data() []byte
 	// Metadata returns packet metadata associated with this packet.
 	Metadata() *PacketMetadata
+
+	//// Functions for verifying the packet:
+	//// ------------------------------------------------------------------
+	// VerifyChecksums verifies the checksum of each layer implementing
+	// ChecksumVerifier, and returns the results in layer order.
+	VerifyChecksums(opts ChecksumOptions) []ChecksumResult
 }
 
 // packet contains all the information we need to fulfill the Packet interface,
@@ -491,6 +514,9 @@ func (p *eagerPacket) LayerClass(lc LayerClass) Layer {
 }
 func (p *eagerPacket) String() string { return p.packetString() }
 func (p *eagerPacket) Dump() string   { return p.packetDump() }
+func (p *eagerPacket) VerifyChecksums(opts ChecksumOptions) []ChecksumResult {
+	return verifyChecksums(p, opts)
+}
 
 // lazyPacket does lazy decoding on its packet data.  On construction it does
 // no initial decoding.  For each function call, it decodes only as many layers
@@ -603,6 +629,9 @@ func (p *lazyPacket) LayerClass(lc LayerClass) Layer {
 }
 func (p *lazyPacket) String() string { p.Layers(); return p.packetString() }
 func (p *lazyPacket) Dump() string   { p.Layers(); return p.packetDump() }
+func (p *lazyPacket) VerifyChecksums(opts ChecksumOptions) []ChecksumResult {
+	return verifyChecksums(p, opts)
+}
 
 // DecodeOptions tells gopacket how to decode a packet.
 type DecodeOptions struct {
@@ -0,0 +1,89 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Decompressor returns a reader decompressing the data read from r.
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+type compression struct {
+	name  string
+	magic []byte
+	d     Decompressor
+}
+
+var (
+	compressionsMu sync.RWMutex
+	// compressions are the formats Reader, NgReader and MmapReader detect
+	// by the magic their data starts with. Only gzip can be read without
+	// registering a Decompressor, to keep pcapgo free of dependencies; the
+	// pcapgo/decompress package registers those of zstd and lz4.
+	compressions = []compression{
+		{name: "gzip", magic: []byte{magicGzip1, magicGzip2}, d: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		}},
+		{name: "zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		{name: "lz4", magic: []byte{0x04, 0x22, 0x4d, 0x18}},
+	}
+)
+
+// RegisterDecompressor makes the readers decompress data starting with
+// magic with d. The name is used in error messages. Registering the magic
+// of a known format, like zstd (28 b5 2f fd) or lz4 (04 22 4d 18), sets its
+// decompressor; importing the pcapgo/decompress package registers both.
+func RegisterDecompressor(name string, magic []byte, d Decompressor) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	for i := range compressions {
+		if bytes.Equal(compressions[i].magic, magic) {
+			compressions[i].name, compressions[i].d = name, d
+			return
+		}
+	}
+	compressions = append(compressions, compression{name: name, magic: append([]byte(nil), magic...), d: d})
+}
+
+// detectCompression returns the compression the data of br starts with, if
+// any.
+func detectCompression(br *bufio.Reader) (*compression, error) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	for i := range compressions {
+		c := &compressions[i]
+		magic, err := br.Peek(len(c.magic))
+		if err == io.EOF || err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(magic, c.magic) {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// decompress returns a reader decompressing the data of br if it is
+// compressed, or br.
+func decompress(br *bufio.Reader) (io.Reader, error) {
+	c, err := detectCompression(br)
+	if err != nil || c == nil {
+		return br, err
+	}
+	if c.d == nil {
+		return nil, fmt.Errorf("%s compressed files need a decompressor, which importing github.com/google/gopacket/pcapgo/decompress registers for zstd and lz4", c.name)
+	}
+	return c.d(br)
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNgReaderGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w, err := NewNgWriter(gz, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci := gopacket.CaptureInfo{
+		Timestamp:     time.Unix(1519128000, 195312500).UTC(),
+		Length:        len(ngPacketSource[0]),
+		CaptureLength: len(ngPacketSource[0]),
+	}
+	if err := w.WritePacket(ci, ngPacketSource[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewNgReader(&buf, DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, gotCI, err := r.ReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, ngPacketSource[0]) || !gotCI.Timestamp.Equal(ci.Timestamp) {
+		t.Errorf("read packet %v %x, want %v %x", gotCI, data, ci, ngPacketSource[0])
+	}
+	if _, _, err := r.ReadPacketData(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestUnregisteredDecompressor(t *testing.T) {
+	zstd := []byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0, 0, 0}
+	if _, err := NewReader(bytes.NewReader(zstd)); err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("expected zstd error from Reader, got %v", err)
+	}
+	if _, err := NewNgReader(bytes.NewReader(zstd), DefaultNgReaderOptions); err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("expected zstd error from NgReader, got %v", err)
+	}
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	// A format made up for the test, compressing by prefixing the data
+	// with its magic.
+	magic := []byte("TEST")
+	RegisterDecompressor("test", magic, func(r io.Reader) (io.Reader, error) {
+		if _, err := io.ReadFull(r, make([]byte, len(magic))); err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	w := NewWriter(&buf)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	ci := gopacket.CaptureInfo{
+		Timestamp:     time.Unix(1519128000, 0).UTC(),
+		Length:        4,
+		CaptureLength: 4,
+	}
+	if err := w.WritePacket(ci, []byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := r.ReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{1, 2, 3, 4}) {
+		t.Errorf("read %x, want 01020304", data)
+	}
+}
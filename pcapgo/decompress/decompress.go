@@ -0,0 +1,63 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package decompress makes the readers of pcapgo uncompress zstd and lz4
+// compressed files. It registers its decompressors with
+// pcapgo.RegisterDecompressor when imported, and has nothing else to use:
+//
+//  import _ "github.com/google/gopacket/pcapgo/decompress"
+//
+// It is kept apart from pcapgo so that programs which don't need it don't
+// depend on the zstd and lz4 implementations.
+package decompress
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Magic numbers of zstd and lz4 frames.
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic  = []byte{0x04, 0x22, 0x4d, 0x18}
+)
+
+func init() {
+	pcapgo.RegisterDecompressor("zstd", zstdMagic, newZstdReader)
+	pcapgo.RegisterDecompressor("lz4", lz4Magic, func(r io.Reader) (io.Reader, error) {
+		return lz4.NewReader(r), nil
+	})
+}
+
+// zstdReader closes its decoder, releasing its goroutines, once it is done,
+// as the readers of pcapgo can't close their source.
+type zstdReader struct {
+	d *zstd.Decoder
+}
+
+func newZstdReader(r io.Reader) (io.Reader, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{d: d}, nil
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.d == nil {
+		return 0, io.EOF
+	}
+	n, err := r.d.Read(p)
+	if err != nil {
+		r.d.Close()
+		r.d = nil
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package decompress
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+func TestDecompress(t *testing.T) {
+	packets := [][]byte{[]byte("first packet"), bytes.Repeat([]byte{0xab}, 3000)}
+	for _, test := range []struct {
+		name     string
+		compress func(io.Writer) (io.WriteCloser, error)
+	}{
+		{"zstd", func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }},
+		{"lz4", func(w io.Writer) (io.WriteCloser, error) { return lz4.NewWriter(w), nil }},
+	} {
+		var buf bytes.Buffer
+		c, err := test.compress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := pcapgo.NewWriter(c)
+		if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range packets {
+			ci := gopacket.CaptureInfo{Timestamp: time.Unix(int64(i), 0), CaptureLength: len(p), Length: len(p)}
+			if err := w.WritePacket(ci, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := pcapgo.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for i, want := range packets {
+			data, ci, err := r.ReadPacketData()
+			if err != nil {
+				t.Fatalf("%s: packet %d: %v", test.name, i, err)
+			}
+			if !bytes.Equal(data, want) || ci.Timestamp.Unix() != int64(i) {
+				t.Errorf("%s: packet %d read as %v %x", test.name, i, ci, data)
+			}
+		}
+		if _, _, err := r.ReadPacketData(); err != io.EOF {
+			t.Errorf("%s: expected EOF, got %v", test.name, err)
+		}
+	}
+}
@@ -13,6 +13,9 @@ This package contains implementations for native PCAP support. Currently support
 
  * pcap-files read/write: Reader, Writer
  * pcapng-files read/write: NgReader, NgWriter
+ * pcap- and pcapng-files mapped into memory: MmapReader
+ * ERF-files written by Endace DAG cards read: ERFReader
+ * snoop-files (RFC 1761) written by Solaris and illumos read: SnoopReader
  * raw socket capture (linux only): EthernetHandle
 
 Basic Usage pcapng
@@ -37,10 +40,32 @@ supported (which means PacketDataSource and ZeroCopyPacketDataSource is supporte
 		data, ci, err := r.ReadPacketData()
 		...
 
+Large pcap and pcapng files can also be mapped into memory with NewMmapReader, whose
+ZeroCopyReadPacketData returns data pointing into the mapping, valid until the reader is closed.
+
+		r, err := NewMmapReader("somefile.pcapng", DefaultNgReaderOptions)
+		if err != nil {
+			...
+		}
+		defer r.Close()
+
+		data, ci, err := r.ZeroCopyReadPacketData()
+		...
+
+Reader and NgReader transparently uncompress gzip compressed files. Importing the
+github.com/google/gopacket/pcapgo/decompress package makes them uncompress zstd and lz4
+compressed files too, and other formats are uncompressed with the decompressors registered with
+RegisterDecompressor. To write compressed files, wrap the file, e.g. with gzip.NewWriter, and close
+the wrapping writer after the last packet. Compressed files cannot be mapped into memory.
+
+Timestamps of packets captured by a host with a skewed clock can be corrected while reading, with
+a constant offset and a linear drift, using SetTimeCorrection or the TimeCorrection option.
+
 Write supports only little endian, enhanced packets blocks, interface blocks, and interface statistics
-blocks. The same options as with writing are supported. Interface timestamp resolution is fixed to
-10^-9s to match time.Time. Any other values are ignored. Upon creating a writer, a section, and an
-interface block is automatically written. Additional interfaces can be added at any time. Since
+blocks. The same options as with writing are supported. Timestamps are written with the resolution of
+their interface, which defaults to 10^-9s to match time.Time. Upon creating a writer, a section, and an
+interface block is automatically written. Additional interfaces can be added at any time, also while
+other goroutines write packets to the same writer. Since
 the writer uses a bufio.Writer internally, Flush must be called before closing the file! Have a look
 at NewNgWriterInterface for more advanced usage.
 
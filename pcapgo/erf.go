@@ -0,0 +1,265 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ERFType is the type of an ERF record, telling the format of its data.
+type ERFType uint8
+
+const (
+	ERFTypeHDLCPOS          ERFType = 1
+	ERFTypeEthernet         ERFType = 2
+	ERFTypeATM              ERFType = 3
+	ERFTypeAAL5             ERFType = 4
+	ERFTypeMCHDLC           ERFType = 5
+	ERFTypeMCRaw            ERFType = 6
+	ERFTypeMCATM            ERFType = 7
+	ERFTypeMCRawChannel     ERFType = 8
+	ERFTypeMCAAL5           ERFType = 9
+	ERFTypeColorHDLCPOS     ERFType = 10
+	ERFTypeColorEthernet    ERFType = 11
+	ERFTypeMCAAL2           ERFType = 12
+	ERFTypeIPCounter        ERFType = 13
+	ERFTypeTCPFlowCounter   ERFType = 14
+	ERFTypeDSMColorHDLCPOS  ERFType = 15
+	ERFTypeDSMColorEthernet ERFType = 16
+	ERFTypeColorMCHDLCPOS   ERFType = 17
+	ERFTypeAAL2             ERFType = 18
+	ERFTypeColorHashPOS     ERFType = 19
+	ERFTypeColorHashEth     ERFType = 20
+	ERFTypeInfiniband       ERFType = 21
+	ERFTypeIPv4             ERFType = 22
+	ERFTypeIPv6             ERFType = 23
+	ERFTypeRawLink          ERFType = 24
+	ERFTypeInfinibandLink   ERFType = 25
+	ERFTypeMeta             ERFType = 27
+	ERFTypePad              ERFType = 48
+)
+
+// erfLinkTypes maps the types of ERF records to the link type of their
+// data.
+var erfLinkTypes = map[ERFType]layers.LinkType{
+	ERFTypeHDLCPOS:          layers.LinkTypeC_HDLC,
+	ERFTypeMCHDLC:           layers.LinkTypeC_HDLC,
+	ERFTypeColorHDLCPOS:     layers.LinkTypeC_HDLC,
+	ERFTypeDSMColorHDLCPOS:  layers.LinkTypeC_HDLC,
+	ERFTypeColorMCHDLCPOS:   layers.LinkTypeC_HDLC,
+	ERFTypeColorHashPOS:     layers.LinkTypeC_HDLC,
+	ERFTypeEthernet:         layers.LinkTypeEthernet,
+	ERFTypeColorEthernet:    layers.LinkTypeEthernet,
+	ERFTypeDSMColorEthernet: layers.LinkTypeEthernet,
+	ERFTypeColorHashEth:     layers.LinkTypeEthernet,
+	ERFTypeIPv4:             layers.LinkTypeIPv4,
+	ERFTypeIPv6:             layers.LinkTypeIPv6,
+}
+
+// LinkType returns the link type of the data of records of type t, or false
+// if the format of their data has no link type supported by gopacket.
+func (t ERFType) LinkType() (layers.LinkType, bool) {
+	lt, ok := erfLinkTypes[t]
+	return lt, ok
+}
+
+// headerLength returns the length of the header preceding the data of
+// records of type t.
+func (t ERFType) headerLength() int {
+	switch t {
+	case ERFTypeEthernet, ERFTypeColorEthernet, ERFTypeDSMColorEthernet, ERFTypeColorHashEth:
+		// offset and padding
+		return 2
+	case ERFTypeMCHDLC, ERFTypeMCRaw, ERFTypeMCATM, ERFTypeMCRawChannel, ERFTypeMCAAL5, ERFTypeMCAAL2, ERFTypeColorMCHDLCPOS, ERFTypeAAL2:
+		// multichannel header
+		return 4
+	}
+	return 0
+}
+
+// Flags of ERF records.
+const (
+	ERFFlagVarLen    = 0x04 // Record is not padded to a multiple of 8 bytes
+	ERFFlagTruncated = 0x08 // Record was truncated for lack of buffer space
+	ERFFlagRxError   = 0x10 // Packet had a link layer error
+	ERFFlagDSError   = 0x20 // Packet had a data stream management error
+)
+
+const (
+	erfHeaderLength    = 16
+	erfTypeExtension   = 0x80
+	erfFlagsInterface  = 0x03
+	erfExtensionLength = 8
+)
+
+// ERFHeader is the header of an ERF record. The ReadPacketData methods of
+// ERFReader return it in ci.AncillaryData[0], and the capture interface in
+// ci.InterfaceIndex.
+type ERFHeader struct {
+	Type ERFType
+	// Flags holds the capture interface in its lowest two bits, and ERFFlag
+	// values.
+	Flags uint8
+	// RecordLength is the length of the whole record, including its
+	// headers and padding.
+	RecordLength uint16
+	// LossCounter is the number of records lost between this record and
+	// the previous one, or the color of the packet for color types.
+	LossCounter uint16
+	WireLength  uint16
+	// Extensions are the extension headers of the record.
+	Extensions []uint64
+}
+
+// ERFReader reads records of the Extensible Record Format written by
+// Endace DAG capture cards. ERF files have no file header, and each record
+// has its own type, so records with data of different link types can be
+// mixed in the same file. Records of type ERFTypePad are skipped.
+//
+// Compressed data is uncompressed as with Reader.
+type ERFReader struct {
+	r         *bufio.Reader
+	header    ERFHeader
+	ancil     [1]interface{}
+	buf       [erfHeaderLength]byte
+	packetBuf []byte
+}
+
+// NewERFReader returns a new ERFReader reading ERF records from r.
+func NewERFReader(r io.Reader) (*ERFReader, error) {
+	br := bufio.NewReader(r)
+	if d, err := decompress(br); err != nil {
+		return nil, err
+	} else if d != io.Reader(br) {
+		br = bufio.NewReader(d)
+	}
+	return &ERFReader{r: br}, nil
+}
+
+// LinkType returns the link type of the first record, as ERF files usually
+// hold records of a single type. It returns an error if the first record
+// can't be read, or its data has no link type supported by gopacket.
+func (r *ERFReader) LinkType() (layers.LinkType, error) {
+	buf, err := r.r.Peek(erfHeaderLength)
+	if err != nil {
+		return 0, err
+	}
+	t := ERFType(buf[8] &^ erfTypeExtension)
+	lt, ok := t.LinkType()
+	if !ok {
+		return 0, fmt.Errorf("ERF record type %d has no link type", t)
+	}
+	return lt, nil
+}
+
+// readRecordHeader reads the headers of the next record which isn't padding,
+// returning the length of its data left to read.
+func (r *ERFReader) readRecordHeader() (ci gopacket.CaptureInfo, left int, err error) {
+	for {
+		if _, err = io.ReadFull(r.r, r.buf[:]); err != nil {
+			return
+		}
+		h := &r.header
+		// The timestamp is a little endian 32.32 fixed point number of
+		// seconds, whose fraction is rounded to nanoseconds.
+		ts := binary.LittleEndian.Uint64(r.buf[0:8])
+		frac := ((ts&0xffffffff)*1000000000 + 1<<31) >> 32
+		ci.Timestamp = time.Unix(int64(ts>>32), int64(frac)).UTC()
+		h.Type = ERFType(r.buf[8])
+		h.Flags = r.buf[9]
+		h.RecordLength = binary.BigEndian.Uint16(r.buf[10:12])
+		h.LossCounter = binary.BigEndian.Uint16(r.buf[12:14])
+		h.WireLength = binary.BigEndian.Uint16(r.buf[14:16])
+		h.Extensions = h.Extensions[:0]
+		left = int(h.RecordLength) - erfHeaderLength
+		if left < 0 {
+			err = fmt.Errorf("ERF record length %d too short", h.RecordLength)
+			return
+		}
+		extension := h.Type&erfTypeExtension != 0
+		h.Type &^= erfTypeExtension
+		for extension {
+			if left < erfExtensionLength {
+				err = fmt.Errorf("ERF extension headers exceed record length %d", h.RecordLength)
+				return
+			}
+			if _, err = io.ReadFull(r.r, r.buf[:erfExtensionLength]); err != nil {
+				return
+			}
+			left -= erfExtensionLength
+			h.Extensions = append(h.Extensions, binary.BigEndian.Uint64(r.buf[:erfExtensionLength]))
+			extension = r.buf[0]&erfTypeExtension != 0
+		}
+		if h.Type == ERFTypePad {
+			if _, err = r.r.Discard(left); err != nil {
+				return
+			}
+			continue
+		}
+		skip := h.Type.headerLength()
+		if skip > left {
+			err = fmt.Errorf("ERF record length %d too short for type %d", h.RecordLength, h.Type)
+			return
+		}
+		if _, err = r.r.Discard(skip); err != nil {
+			return
+		}
+		left -= skip
+		ci.InterfaceIndex = int(h.Flags & erfFlagsInterface)
+		ci.Length = int(h.WireLength)
+		// Records are padded, and the data of truncated records is
+		// shorter than the packet.
+		ci.CaptureLength = left
+		if ci.CaptureLength > ci.Length {
+			ci.CaptureLength = ci.Length
+		}
+		return
+	}
+}
+
+// ReadPacketData returns the data of the next record, without its padding,
+// and a copy of its ERFHeader in ci.AncillaryData[0].
+func (r *ERFReader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	var left int
+	if ci, left, err = r.readRecordHeader(); err != nil {
+		return
+	}
+	data = make([]byte, left)
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		return
+	}
+	header := r.header
+	header.Extensions = append([]uint64(nil), r.header.Extensions...)
+	ci.AncillaryData = []interface{}{header}
+	return data[:ci.CaptureLength], ci, nil
+}
+
+// ZeroCopyReadPacketData returns the data of the next record. The data buffer
+// and ci.AncillaryData are owned by the ERFReader, and each call to
+// ZeroCopyReadPacketData invalidates those returned by the previous one.
+func (r *ERFReader) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	var left int
+	if ci, left, err = r.readRecordHeader(); err != nil {
+		return
+	}
+	if cap(r.packetBuf) < left {
+		r.packetBuf = make([]byte, left)
+	}
+	if _, err = io.ReadFull(r.r, r.packetBuf[:left]); err != nil {
+		return
+	}
+	r.ancil[0] = r.header
+	ci.AncillaryData = r.ancil[:]
+	return r.packetBuf[:ci.CaptureLength], ci, nil
+}
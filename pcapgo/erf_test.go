@@ -0,0 +1,103 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var erfTestData = []byte{
+	// Ethernet record on interface 1 with an extension header, padded to 48 bytes
+	0x00, 0x00, 0x00, 0x80, 0x5a, 0xcc, 0x1a, 0x54, // timestamp
+	0x82, 0x01, 0x00, 0x30, 0x00, 0x02, 0x00, 0x12, // type, flags, rlen, lctr, wlen
+	0x0b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // extension header
+	0x00, 0x00, // offset, padding
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0xb5, 0x01, 0x02,
+	0x03, 0x04, // wlen bytes
+	0x00, 0x00, 0x00, 0x00, // padding
+	// Pad record
+	0x00, 0x00, 0x00, 0x00, 0x5a, 0xcc, 0x1a, 0x54,
+	0x30, 0x00, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// Truncated IPv4 record
+	0x01, 0x00, 0x00, 0x00, 0x5b, 0xcc, 0x1a, 0x54,
+	0x16, 0x0c, 0x00, 0x14, 0x00, 0x00, 0x00, 0x40,
+	0x45, 0x00, 0x00, 0x40,
+}
+
+func TestERFReader(t *testing.T) {
+	r, err := NewERFReader(bytes.NewReader(erfTestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lt, err := r.LinkType(); err != nil || lt != layers.LinkTypeEthernet {
+		t.Errorf("link type %v, %v, want Ethernet", lt, err)
+	}
+
+	data, ci, err := r.ReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gopacket.CaptureInfo{
+		Timestamp:      time.Date(2014, 9, 18, 12, 13, 14, 500000000, time.UTC),
+		CaptureLength:  18,
+		Length:         18,
+		InterfaceIndex: 1,
+		AncillaryData: []interface{}{ERFHeader{
+			Type:         ERFTypeEthernet,
+			Flags:        0x01,
+			RecordLength: 48,
+			LossCounter:  2,
+			WireLength:   18,
+			Extensions:   []uint64{0x0b00000000000001},
+		}},
+	}
+	if !reflect.DeepEqual(ci, want) {
+		t.Errorf("capture info\n%+v\nwant\n%+v", ci, want)
+	}
+	if !bytes.Equal(data, erfTestData[26:44]) {
+		t.Errorf("data %x, want %x", data, erfTestData[26:44])
+	}
+
+	data, ci, err = r.ZeroCopyReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := ci.AncillaryData[0].(ERFHeader)
+	if header.Type != ERFTypeIPv4 || header.Flags&ERFFlagTruncated == 0 {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if ci.CaptureLength != 4 || ci.Length != 64 || !bytes.Equal(data, []byte{0x45, 0x00, 0x00, 0x40}) {
+		t.Errorf("unexpected packet %+v %x", ci, data)
+	}
+	if !ci.Timestamp.Equal(time.Unix(0x541acc5b, 0)) {
+		t.Errorf("timestamp %v rounded wrong", ci.Timestamp)
+	}
+
+	if _, _, err = r.ReadPacketData(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestERFReaderShortRecord(t *testing.T) {
+	data := append([]byte(nil), erfTestData[:16]...)
+	data[11] = 8
+	r, err := NewERFReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadPacketData(); err == nil {
+		t.Error("expected error for record length shorter than its header")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"errors"
+
+	"golang.org/x/net/bpf"
+)
+
+// newFilter returns a virtual machine running filter, or nil if filter is
+// empty. It fails if the virtual machine can't run all of its instructions.
+func newFilter(filter []bpf.RawInstruction) (*bpf.VM, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	insts, allDecoded := bpf.Disassemble(filter)
+	if !allDecoded {
+		return nil, errors.New("BPF filter has instructions which can't be decoded")
+	}
+	return bpf.NewVM(insts)
+}
+
+// matches reports whether the packet data is accepted by vm, that is,
+// whether the filter returns a value greater than zero. Packets the filter
+// fails on, such as by reading past their end, do not match.
+func matches(vm *bpf.VM, data []byte) bool {
+	n, err := vm.Run(data)
+	return err == nil && n > 0
+}
+
+// SetBPF sets a BPF filter for the reader, which then returns only the
+// packets for which the filter returns a value greater than zero. The
+// packets skipped are read into a reused buffer, so ReadPacketData only
+// allocates memory for the packets returned. The filter runs in a virtual
+// machine and is given the captured data of each packet; it can be
+// compiled with pcap.CompileBPFFilter for the link type of the file or
+// assembled with golang.org/x/net/bpf. To remove the filter, provide an
+// empty slice.
+func (r *Reader) SetBPF(filter []bpf.RawInstruction) error {
+	vm, err := newFilter(filter)
+	if err != nil {
+		return err
+	}
+	r.filter = vm
+	return nil
+}
+
+// SetBPF sets a BPF filter for the reader, as Reader.SetBPF does. The
+// filter is run on the packets of all interfaces, so with
+// WantMixedLinkType it has to cope with each of their link types.
+func (r *NgReader) SetBPF(filter []bpf.RawInstruction) error {
+	vm, err := newFilter(filter)
+	if err != nil {
+		return err
+	}
+	r.filter = vm
+	return nil
+}
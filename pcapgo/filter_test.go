@@ -0,0 +1,132 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+// firstByteIs2 accepts packets starting with 2.
+var firstByteIs2 = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 0, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 2, SkipFalse: 1},
+	bpf.RetConstant{Val: 0xffff},
+	bpf.RetConstant{Val: 0},
+}
+
+var filterTestPackets = [][]byte{{1, 0, 0}, {2, 1}, {}, {3}, {2, 2, 2}, {1}}
+
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	ZeroCopyReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	SetBPF([]bpf.RawInstruction) error
+}
+
+func testFilter(t *testing.T, newReader func() packetReader) {
+	raw, err := bpf.Assemble(firstByteIs2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, zeroCopy := range []bool{false, true} {
+		r := newReader()
+		if err := r.SetBPF(raw); err != nil {
+			t.Fatal(err)
+		}
+		var got [][]byte
+		for {
+			read := r.ReadPacketData
+			if zeroCopy {
+				read = r.ZeroCopyReadPacketData
+			}
+			data, ci, err := read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if ci.CaptureLength != len(data) || len(got) < 2 && ci.Timestamp.Unix() != []int64{2, 5}[len(got)] {
+				t.Errorf("packet %d has capture info %+v", len(got), ci)
+			}
+			if zeroCopy {
+				data = append([]byte(nil), data...)
+			}
+			got = append(got, data)
+		}
+		if len(got) != 2 || !bytes.Equal(got[0], []byte{2, 1}) || !bytes.Equal(got[1], []byte{2, 2, 2}) {
+			t.Errorf("zero copy %v: got packets %v", zeroCopy, got)
+		}
+		if !zeroCopy && len(got) == 2 && &got[0][0] == &got[1][0] {
+			t.Error("ReadPacketData reused its buffer")
+		}
+	}
+
+	// Without a filter, all packets are read.
+	r := newReader()
+	r.SetBPF(raw)
+	r.SetBPF(nil)
+	n := 0
+	for {
+		if _, _, err := r.ReadPacketData(); err != nil {
+			break
+		}
+		n++
+	}
+	if n != len(filterTestPackets) {
+		t.Errorf("read %d packets after removing the filter, want %d", n, len(filterTestPackets))
+	}
+
+	if err := r.SetBPF([]bpf.RawInstruction{{Op: 0xff}}); err == nil {
+		t.Error("set invalid filter")
+	}
+	// The virtual machine accepts instructions it can't decode, and fails
+	// on every packet.
+	if err := r.SetBPF([]bpf.RawInstruction{{Op: 0xff}, {Op: 0x06, K: 1}}); err == nil {
+		t.Error("set filter with an undecodable instruction")
+	}
+}
+
+func TestReaderBPF(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	for i, data := range filterTestPackets {
+		w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Unix(int64(i+1), 0), CaptureLength: len(data), Length: len(data)}, data)
+	}
+	testFilter(t, func() packetReader {
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func TestNgReaderBPF(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewNgWriter(&buf, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range filterTestPackets {
+		w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Unix(int64(i+1), 0), CaptureLength: len(data), Length: len(data)}, data)
+	}
+	w.Flush()
+	testFilter(t, func() packetReader {
+		r, err := NewNgReader(bytes.NewReader(buf.Bytes()), DefaultNgReaderOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
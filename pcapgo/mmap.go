@@ -0,0 +1,195 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+// mmapReader reads a capture file mapped into memory.
+type mmapReader struct {
+	data []byte
+	off  int
+}
+
+func (m *mmapReader) Read(p []byte) (int, error) {
+	if m.off >= len(m.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[m.off:])
+	m.off += n
+	return n, nil
+}
+
+// Discard skips the next n bytes, as bufio.Reader.Discard does.
+func (m *mmapReader) Discard(n int) (int, error) {
+	if left := len(m.data) - m.off; n > left {
+		m.off = len(m.data)
+		return left, io.EOF
+	}
+	m.off += n
+	return n, nil
+}
+
+// next returns the next n bytes, without copying them.
+func (m *mmapReader) next(n int) ([]byte, error) {
+	if n > len(m.data)-m.off {
+		m.off = len(m.data)
+		return nil, io.ErrUnexpectedEOF
+	}
+	// Limit the capacity, so appending to the slice does not write to the
+	// read-only mapping.
+	b := m.data[m.off : m.off+n : m.off+n]
+	m.off += n
+	return b, nil
+}
+
+// MmapReader reads a pcap or pcapng file mapped into memory, instead of
+// reading it with system calls and copying each packet into a buffer.
+//
+// The data returned by ZeroCopyReadPacketData points into the mapping. It
+// is not invalidated by the next call, as with other readers, but stays
+// valid until Close is called, and must not be modified. Accessing it after
+// Close crashes the program. Data returned by ReadPacketData is copied and
+// stays valid.
+//
+// On systems without mmap, the file is read into memory instead.
+type MmapReader struct {
+	mapping []byte
+	pcap    *Reader
+	ng      *NgReader
+}
+
+// NewMmapReader maps the pcap or pcapng file at path into memory and reads
+// its header. The options are used if the file is a pcapng file, except
+// for the time correction which applies to both formats. Compressed files
+// are not supported, since their data can't be used in place.
+func NewMmapReader(path string, options NgReaderOptions) (*MmapReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size < 4 {
+		return nil, errors.New("Not enough data for read")
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file of %d bytes too large to map", size)
+	}
+	mapping, err := mmapFile(f, int(size))
+	if err != nil {
+		return nil, err
+	}
+	r := &MmapReader{mapping: mapping}
+	if err := r.readHeader(options); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *MmapReader) readHeader(options NgReaderOptions) error {
+	m := &mmapReader{data: r.mapping}
+	if c, err := detectCompression(bufio.NewReader(bytes.NewReader(r.mapping))); err != nil {
+		return err
+	} else if c != nil {
+		return fmt.Errorf("%s compressed files cannot be mapped", c.name)
+	}
+	if binary.LittleEndian.Uint32(r.mapping) == uint32(NgBlockTypeSectionHeader) {
+		r.ng = &NgReader{
+			currentOption: ngOption{
+				value: make([]byte, 1024),
+			},
+			options: options,
+			mapped:  m,
+		}
+		return r.ng.readFirstSection()
+	}
+	header, err := m.next(24)
+	if err != nil {
+		return errors.New("Not enough data for read")
+	}
+	r.pcap = &Reader{r: m, mapped: m, timeCorrection: options.TimeCorrection}
+	return r.pcap.parseHeader(header)
+}
+
+// ReadPacketData reads the next packet, copying its data.
+func (r *MmapReader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	if data, ci, err = r.ZeroCopyReadPacketData(); err != nil {
+		return
+	}
+	if len(ci.AncillaryData) > 0 {
+		ci.AncillaryData = append([]interface{}(nil), ci.AncillaryData...)
+	}
+	return append([]byte(nil), data...), ci, nil
+}
+
+// ZeroCopyReadPacketData reads the next packet, whose data points into the
+// mapping and is valid until Close. With WantMixedLinkType,
+// ci.AncillaryData is reused by the next call, as with NgReader.
+func (r *MmapReader) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	if r.mapping == nil {
+		return nil, ci, errors.New("MmapReader closed")
+	}
+	if r.ng != nil {
+		return r.ng.ZeroCopyReadPacketData()
+	}
+	return r.pcap.ZeroCopyReadPacketData()
+}
+
+// LinkType returns the link type of the file, or, for pcapng files, of its
+// first interface.
+func (r *MmapReader) LinkType() layers.LinkType {
+	if r.ng != nil {
+		return r.ng.LinkType()
+	}
+	return r.pcap.LinkType()
+}
+
+// SetBPF sets a BPF filter for the reader, as Reader.SetBPF does.
+func (r *MmapReader) SetBPF(filter []bpf.RawInstruction) error {
+	if r.ng != nil {
+		return r.ng.SetBPF(filter)
+	}
+	return r.pcap.SetBPF(filter)
+}
+
+// Reader returns the reader of a pcap file, or nil for a pcapng file.
+func (r *MmapReader) Reader() *Reader {
+	return r.pcap
+}
+
+// NgReader returns the reader of a pcapng file, or nil for a pcap file.
+func (r *MmapReader) NgReader() *NgReader {
+	return r.ng
+}
+
+// Close unmaps the file. The data returned by ZeroCopyReadPacketData must
+// not be used afterwards.
+func (r *MmapReader) Close() error {
+	if r.mapping == nil {
+		return nil
+	}
+	err := munmapFile(r.mapping)
+	r.mapping = nil
+	return err
+}
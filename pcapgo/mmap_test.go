@@ -0,0 +1,190 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+type readPacket struct {
+	data []byte
+	ci   gopacket.CaptureInfo
+}
+
+// readAll reads all packets of r, keeping the data returned by read as is.
+func readAll(read func() ([]byte, gopacket.CaptureInfo, error)) ([]readPacket, error) {
+	var packets []readPacket
+	for {
+		data, ci, err := read()
+		if err == io.EOF {
+			return packets, nil
+		} else if err != nil {
+			return packets, err
+		}
+		packets = append(packets, readPacket{data, ci})
+	}
+}
+
+func writeTempFile(t *testing.T, data []byte) string {
+	f, err := ioutil.TempFile("", "mmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestMmapReaderPcap(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterNanos(&buf)
+	w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	for i := 0; i < 100; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, i)
+		w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Unix(int64(i), int64(i)), CaptureLength: i, Length: i + 1}, data)
+	}
+	path := writeTempFile(t, buf.Bytes())
+	defer os.Remove(path)
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := readAll(r.ReadPacketData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMmapReader(path, DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.Reader() == nil || m.NgReader() != nil || m.LinkType() != layers.LinkTypeEthernet {
+		t.Fatalf("file not read as pcap file of Ethernet")
+	}
+	// Zero copy data stays valid until Close, so it can all be compared
+	// after reading.
+	got, err := readAll(m.ZeroCopyReadPacketData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets %v, want %v", got, want)
+	}
+	if len(got) > 1 && cap(got[1].data) != len(got[1].data) {
+		t.Error("zero copy data can be appended to")
+	}
+}
+
+func TestMmapReaderPcapng(t *testing.T) {
+	for _, dir := range []string{"le", "be"} {
+		paths, err := filepath.Glob(filepath.Join("tests", dir, "*.pcapng"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			options := NgReaderOptions{WantMixedLinkType: true, SkipUnknownVersion: true}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []readPacket
+			r, wantErr := NewNgReader(f, options)
+			if wantErr == nil {
+				want, wantErr = readAll(r.ReadPacketData)
+			}
+			f.Close()
+
+			var got []readPacket
+			m, err := NewMmapReader(path, options)
+			if err == nil {
+				got, err = readAll(m.ReadPacketData)
+				m.Close()
+			}
+			if (err == nil) != (wantErr == nil) {
+				t.Errorf("%s: got error %v, want %v", path, err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: got packets %v, want %v", path, got, want)
+			}
+		}
+	}
+}
+
+func TestMmapReaderBPF(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewNgWriter(&buf, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range filterTestPackets {
+		w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Unix(int64(i+1), 0), CaptureLength: len(data), Length: len(data)}, data)
+	}
+	w.Flush()
+	path := writeTempFile(t, buf.Bytes())
+	defer os.Remove(path)
+	testFilter(t, func() packetReader {
+		r, err := NewMmapReader(path, DefaultNgReaderOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func TestMmapReaderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	NewWriter(gz).WriteFileHeader(65536, layers.LinkTypeEthernet)
+	gz.Close()
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"gzip":      buf.Bytes(),
+		"truncated": {0xd4, 0xc3, 0xb2, 0xa1, 0x02, 0x00},
+		"garbage":   bytes.Repeat([]byte{0x42}, 100),
+	} {
+		path := writeTempFile(t, data)
+		if m, err := NewMmapReader(path, DefaultNgReaderOptions); err == nil {
+			t.Errorf("%s: no error", name)
+			m.Close()
+		}
+		os.Remove(path)
+	}
+
+	var pcap bytes.Buffer
+	w := NewWriter(&pcap)
+	w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	w.WritePacket(gopacket.CaptureInfo{CaptureLength: 10, Length: 10}, make([]byte, 10))
+	// Cut the last packet.
+	path := writeTempFile(t, pcap.Bytes()[:pcap.Len()-1])
+	defer os.Remove(path)
+	m, err := NewMmapReader(path, DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.ZeroCopyReadPacketData(); err != io.ErrUnexpectedEOF {
+		t.Errorf("reading truncated packet: got error %v", err)
+	}
+	m.Close()
+	if _, _, err := m.ZeroCopyReadPacketData(); err == nil {
+		t.Error("no error reading after Close")
+	}
+}
@@ -8,14 +8,18 @@ package pcapgo
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math/bits"
+	"net"
 	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
 )
 
 // NgReaderOptions holds options for reading a pcapng file
@@ -33,6 +37,14 @@ type NgReaderOptions struct {
 	SectionEndCallback func([]NgInterface, NgSectionInfo)
 	// StatisticsCallback is called when a interface statistics block is read. The interface id and the read statistics are provided.
 	StatisticsCallback func(int, NgInterfaceStatistics)
+	// TimeCorrection is applied to the timestamps of all packets, to correct the skewed clock of the capturing host.
+	TimeCorrection TimeCorrection
+	// WantPacketInfo enables reading the options of packet blocks, which are otherwise skipped. A *NgPacketInfo holding them, along with the section and interface of the packet, is then the last element of ci.AncillaryData.
+	WantPacketInfo bool
+	// NameResolutionCallback is called when a name resolution block is read.
+	NameResolutionCallback func(NgNameResolution)
+	// BlockCallback is called with the type and the body of the blocks the reader does not decode itself, such as decryption secrets and custom blocks, which are otherwise skipped.
+	BlockCallback func(NgBlockType, []byte)
 }
 
 // DefaultNgReaderOptions provides sane defaults for a pcapng reader.
@@ -50,36 +62,52 @@ type NgReader struct {
 	buf               [24]byte
 	packetBuf         []byte
 	ci                gopacket.CaptureInfo
-	ancil             [1]interface{}
+	ancil             [2]interface{}
+	nancil            int
+	packetInfo        NgPacketInfo
 	blen              int
 	firstSectionFound bool
 	activeSection     bool
 	bigEndian         bool
+	filter            *bpf.VM
+	// mapped is the file read by an MmapReader, which is read instead of
+	// r if set.
+	mapped *mmapReader
 }
 
 // NewNgReader initializes a new writer, reads the first section header, and if necessary according to the options the first interface.
+// Compressed data is uncompressed as with Reader.
 func NewNgReader(r io.Reader, options NgReaderOptions) (*NgReader, error) {
+	br := bufio.NewReader(r)
+	if d, err := decompress(br); err != nil {
+		return nil, err
+	} else if d != io.Reader(br) {
+		br = bufio.NewReader(d)
+	}
 	ret := &NgReader{
-		r: bufio.NewReader(r),
+		r: br,
 		currentOption: ngOption{
 			value: make([]byte, 1024),
 		},
 		options: options,
 	}
-
-	//pcapng _must_ start with a section header
-	if err := ret.readBlock(); err != nil {
+	if err := ret.readFirstSection(); err != nil {
 		return nil, err
 	}
-	if ret.currentBlock.typ != ngBlockTypeSectionHeader {
-		return nil, fmt.Errorf("Unknown magic %x", ret.currentBlock.typ)
-	}
+	return ret, nil
+}
 
-	if err := ret.readSectionHeader(); err != nil {
-		return nil, err
+// readFirstSection reads the first section header, and if necessary
+// according to the options the first interface.
+func (r *NgReader) readFirstSection() error {
+	//pcapng _must_ start with a section header
+	if err := r.readBlock(); err != nil {
+		return err
 	}
-
-	return ret, nil
+	if r.currentBlock.typ != NgBlockTypeSectionHeader {
+		return fmt.Errorf("Unknown magic %x", r.currentBlock.typ)
+	}
+	return r.readSectionHeader()
 }
 
 // First a couple of helper functions to speed things up
@@ -87,6 +115,10 @@ func NewNgReader(r io.Reader, options NgReaderOptions) (*NgReader, error) {
 // This is way faster than calling io.ReadFull since io.ReadFull needs an itab lookup, does an additional function call into ReadAtLeast, and ReadAtLeast does additional stuff we don't need
 // Additionally this removes the bounds check compared to io.ReadFull due to the use of uint
 func (r *NgReader) readBytes(buffer []byte) error {
+	if r.mapped != nil {
+		_, err := io.ReadFull(r.mapped, buffer)
+		return err
+	}
 	n := uint(0)
 	for n < uint(len(buffer)) {
 		nn, err := r.r.Read(buffer[n:])
@@ -98,6 +130,17 @@ func (r *NgReader) readBytes(buffer []byte) error {
 	return nil
 }
 
+// discard skips the next n bytes.
+func (r *NgReader) discard(n int) error {
+	var err error
+	if r.mapped != nil {
+		_, err = r.mapped.Discard(n)
+	} else {
+		_, err = r.r.Discard(n)
+	}
+	return err
+}
+
 // The following functions make the binary.* functions inlineable (except for getUint64, which is too big, but not in any hot path anyway)
 // Compared to storing binary.*Endian in a binary.ByteOrder this shaves off about 20% for (ZeroCopy)ReadPacketData, which is caused by the needed itab lookup + indirect go call
 func (r *NgReader) getUint16(buffer []byte) uint16 {
@@ -128,10 +171,10 @@ func (r *NgReader) readBlock() error {
 	if err := r.readBytes(r.buf[0:8]); err != nil {
 		return err
 	}
-	r.currentBlock.typ = ngBlockType(r.getUint32(r.buf[0:4]))
+	r.currentBlock.typ = NgBlockType(r.getUint32(r.buf[0:4]))
 	// The next part is a bit fucked up since a section header could change the endianess...
 	// So first read then length just into a buffer, check if its a section header and then do the endianess part...
-	if r.currentBlock.typ == ngBlockTypeSectionHeader {
+	if r.currentBlock.typ == NgBlockTypeSectionHeader {
 		if err := r.readBytes(r.buf[8:12]); err != nil {
 			return err
 		}
@@ -170,6 +213,7 @@ func (r *NgReader) readOption() error {
 		}
 		return nil
 	}
+	r.currentOption.value = r.currentOption.value[:0]
 	if length != 0 {
 		if length < uint16(cap(r.currentOption.value)) {
 			r.currentOption.value = r.currentOption.value[:length]
@@ -183,7 +227,7 @@ func (r *NgReader) readOption() error {
 		padding := length % 4
 		if padding > 0 {
 			padding = 4 - padding
-			if _, err := r.r.Discard(int(padding)); err != nil {
+			if err := r.discard(int(padding)); err != nil {
 				return err
 			}
 		}
@@ -192,6 +236,23 @@ func (r *NgReader) readOption() error {
 	return nil
 }
 
+// option returns a copy of the current option, which the next call to readOption overwrites.
+func (r *NgReader) option() NgOption {
+	return NgOption{
+		Code:  uint16(r.currentOption.code),
+		Value: append([]byte(nil), r.currentOption.value...),
+	}
+}
+
+// optionValue returns the value of the current option if it is at least length bytes long. Otherwise, the option is added to others, and nil returned.
+func (r *NgReader) optionValue(length int, others *[]NgOption) []byte {
+	if len(r.currentOption.value) < length {
+		*others = append(*others, r.option())
+		return nil
+	}
+	return r.currentOption.value
+}
+
 // readSectionHeader parses the full section header and implements section skipping in case of version mismatch
 // if needed, the first interface is read
 func (r *NgReader) readSectionHeader() error {
@@ -221,7 +282,7 @@ RESTART:
 			// but this would mean user would be kept in the dark about whats going on...
 			return ErrNgVersionMismatch
 		}
-		if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+		if err := r.discard(int(r.currentBlock.length)); err != nil {
 			return err
 		}
 		if err := r.skipSection(); err != nil {
@@ -248,10 +309,12 @@ OPTIONS:
 			section.OS = string(r.currentOption.value)
 		case ngOptionCodeUserApplication:
 			section.Application = string(r.currentOption.value)
+		default:
+			section.Options = append(section.Options, r.option())
 		}
 	}
 
-	if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+	if err := r.discard(int(r.currentBlock.length)); err != nil {
 		return err
 	}
 	r.activeSection = true
@@ -274,10 +337,10 @@ func (r *NgReader) skipSection() error {
 		if err := r.readBlock(); err != nil {
 			return err
 		}
-		if r.currentBlock.typ == ngBlockTypeSectionHeader {
+		if r.currentBlock.typ == NgBlockTypeSectionHeader {
 			return nil
 		}
-		if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+		if err := r.discard(int(r.currentBlock.length)); err != nil {
 			return err
 		}
 	}
@@ -298,7 +361,7 @@ func (r *NgReader) firstInterface() error {
 			return err
 		}
 		switch r.currentBlock.typ {
-		case ngBlockTypeInterfaceDescriptor:
+		case NgBlockTypeInterfaceDescriptor:
 			if err := r.readInterfaceDescriptor(); err != nil {
 				return err
 			}
@@ -312,10 +375,10 @@ func (r *NgReader) firstInterface() error {
 				continue
 			}
 			return nil
-		case ngBlockTypePacket, ngBlockTypeEnhancedPacket, ngBlockTypeSimplePacket, ngBlockTypeInterfaceStatistics:
+		case NgBlockTypePacket, NgBlockTypeEnhancedPacket, NgBlockTypeSimplePacket, NgBlockTypeInterfaceStatistics:
 			return errors.New("A section must have an interface before a packet block")
 		}
-		if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+		if err := r.readOtherBlock(); err != nil {
 			return err
 		}
 	}
@@ -347,16 +410,48 @@ OPTIONS:
 			intf.Description = string(r.currentOption.value)
 		case ngOptionCodeInterfaceFilter:
 			// ignore filter type (first byte) since it is not specified
-			intf.Filter = string(r.currentOption.value[1:])
+			if len(r.currentOption.value) > 0 {
+				intf.Filter = string(r.currentOption.value[1:])
+			}
 		case ngOptionCodeInterfaceOS:
 			intf.OS = string(r.currentOption.value)
 		case ngOptionCodeInterfaceTimestampOffset:
 			intf.TimestampOffset = r.getUint64(r.currentOption.value[:8])
 		case ngOptionCodeInterfaceTimestampResolution:
 			intf.TimestampResolution = NgResolution(r.currentOption.value[0])
+		case ngOptionCodeInterfaceHardware:
+			intf.Hardware = string(r.currentOption.value)
+		case ngOptionCodeInterfaceIPV4Address:
+			if v := r.optionValue(8, &intf.Options); v != nil {
+				v = append([]byte(nil), v[:8]...)
+				intf.IPv4Addresses = append(intf.IPv4Addresses, net.IPNet{IP: net.IP(v[:4]), Mask: net.IPMask(v[4:])})
+			}
+		case ngOptionCodeInterfaceIPV6Address:
+			if v := r.optionValue(17, &intf.Options); v != nil {
+				ip := append(net.IP(nil), v[:16]...)
+				intf.IPv6Addresses = append(intf.IPv6Addresses, net.IPNet{IP: ip, Mask: net.CIDRMask(int(v[16]), 128)})
+			}
+		case ngOptionCodeInterfaceMACAddress:
+			if v := r.optionValue(6, &intf.Options); v != nil {
+				intf.MACAddress = append(net.HardwareAddr(nil), v[:6]...)
+			}
+		case ngOptionCodeInterfaceEUIAddress:
+			if v := r.optionValue(8, &intf.Options); v != nil {
+				intf.EUIAddress = append(net.HardwareAddr(nil), v[:8]...)
+			}
+		case ngOptionCodeInterfaceSpeed:
+			if v := r.optionValue(8, &intf.Options); v != nil {
+				intf.Speed = r.getUint64(v)
+			}
+		case ngOptionCodeInterfaceFCSLength:
+			if v := r.optionValue(1, &intf.Options); v != nil {
+				intf.FCSLength = v[0]
+			}
+		default:
+			intf.Options = append(intf.Options, r.option())
 		}
 	}
-	if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+	if err := r.discard(int(r.currentBlock.length)); err != nil {
 		return err
 	}
 	if intf.TimestampResolution == 0 {
@@ -374,13 +469,6 @@ OPTIONS:
 			intf.secondMask *= 10
 		}
 	}
-	intf.scaleDown = 1
-	intf.scaleUp = 1
-	if intf.secondMask < 1e9 {
-		intf.scaleUp = 1e9 / intf.secondMask
-	} else {
-		intf.scaleDown = intf.secondMask / 1e9
-	}
 	r.ifaces = append(r.ifaces, intf)
 	return nil
 }
@@ -388,7 +476,10 @@ OPTIONS:
 // convertTime adds offset + shifts the given time value according to the given interface
 func (r *NgReader) convertTime(ifaceID int, ts uint64) (int64, int64) {
 	iface := r.ifaces[ifaceID]
-	return int64(ts/iface.secondMask + iface.TimestampOffset), int64(ts % iface.secondMask * iface.scaleUp / iface.scaleDown)
+	// scale in 128 bits, as the fraction of binary resolutions isn't a whole number of nanoseconds
+	hi, lo := bits.Mul64(ts%iface.secondMask, 1e9)
+	nsec, _ := bits.Div64(hi, lo, iface.secondMask)
+	return int64(ts/iface.secondMask + iface.TimestampOffset), int64(nsec)
 }
 
 // readInterfaceStatistics updates the statistics of the given interface
@@ -426,9 +517,23 @@ OPTIONS:
 			stats.PacketsReceived = r.getUint64(r.currentOption.value[:8])
 		case ngOptionCodeInterfaceStatisticsInterfaceDropped:
 			stats.PacketsDropped = r.getUint64(r.currentOption.value[:8])
+		case ngOptionCodeInterfaceStatisticsFilterAccept:
+			if v := r.optionValue(8, &stats.Options); v != nil {
+				stats.FilterAccepted = r.getUint64(v)
+			}
+		case ngOptionCodeInterfaceStatisticsOSDrop:
+			if v := r.optionValue(8, &stats.Options); v != nil {
+				stats.OSDropped = r.getUint64(v)
+			}
+		case ngOptionCodeInterfaceStatisticsDelivered:
+			if v := r.optionValue(8, &stats.Options); v != nil {
+				stats.Delivered = r.getUint64(v)
+			}
+		default:
+			stats.Options = append(stats.Options, r.option())
 		}
 	}
-	if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+	if err := r.discard(int(r.currentBlock.length)); err != nil {
 		return err
 	}
 	if r.options.StatisticsCallback != nil {
@@ -437,6 +542,93 @@ OPTIONS:
 	return nil
 }
 
+// readOtherBlock handles a block which is neither a section header, an interface block, nor a packet block, which is skipped unless a callback wants it.
+func (r *NgReader) readOtherBlock() error {
+	if r.currentBlock.typ == NgBlockTypeNameResolution && r.options.NameResolutionCallback != nil {
+		return r.readNameResolution()
+	}
+	if r.options.BlockCallback == nil || r.currentBlock.length < 4 {
+		return r.discard(int(r.currentBlock.length))
+	}
+	body := make([]byte, r.currentBlock.length-4)
+	if err := r.readBytes(body); err != nil {
+		return err
+	}
+	if err := r.discard(4); err != nil {
+		return err
+	}
+	r.options.BlockCallback(r.currentBlock.typ, body)
+	return nil
+}
+
+// readNameResolution parses a name resolution block and hands it to NameResolutionCallback.
+func (r *NgReader) readNameResolution() error {
+	var nr NgNameResolution
+RECORDS:
+	for {
+		if err := r.readBytes(r.buf[:4]); err != nil {
+			return err
+		}
+		r.currentBlock.length -= 4
+		typ := r.getUint16(r.buf[:2])
+		length := int(r.getUint16(r.buf[2:4]))
+		padding := (4 - length&3) & 3
+		if uint32(length+padding) > r.currentBlock.length {
+			return fmt.Errorf("Name resolution record of length %d exceeds block", length)
+		}
+		value := make([]byte, length)
+		if err := r.readBytes(value); err != nil {
+			return err
+		}
+		if err := r.discard(padding); err != nil {
+			return err
+		}
+		r.currentBlock.length -= uint32(length + padding)
+
+		var addrLen int
+		switch typ {
+		case ngNameResolutionEnd:
+			break RECORDS
+		case ngNameResolutionIPv4:
+			addrLen = net.IPv4len
+		case ngNameResolutionIPv6:
+			addrLen = net.IPv6len
+		default:
+			continue
+		}
+		if length < addrLen {
+			return fmt.Errorf("Name resolution record of length %d too short", length)
+		}
+		record := NgNameRecord{Address: net.IP(value[:addrLen])}
+		for _, name := range bytes.Split(value[addrLen:], []byte{0}) {
+			if len(name) > 0 {
+				record.Names = append(record.Names, string(name))
+			}
+		}
+		nr.Records = append(nr.Records, record)
+	}
+
+OPTIONS:
+	for {
+		if err := r.readOption(); err != nil {
+			return err
+		}
+		switch r.currentOption.code {
+		case ngOptionCodeEndOfOptions:
+			break OPTIONS
+		case ngOptionCodeComment:
+			nr.Comment = string(r.currentOption.value)
+		default:
+			nr.Options = append(nr.Options, r.option())
+		}
+	}
+	if err := r.discard(int(r.currentBlock.length)); err != nil {
+		return err
+	}
+	r.options.NameResolutionCallback(nr)
+	return nil
+}
+
 // readPacketHeader looks for a packet (enhanced, simple, or packet) and parses the header.
 // If an interface descriptor, an interface statistics block, or a section header is encountered, those are handled accordingly.
 // All other block types are skipped. New block types must be added here.
@@ -448,7 +640,7 @@ FIND_PACKET:
 			return err
 		}
 		switch r.currentBlock.typ {
-		case ngBlockTypeEnhancedPacket:
+		case NgBlockTypeEnhancedPacket:
 			if err := r.readBytes(r.buf[:20]); err != nil {
 				return err
 			}
@@ -460,8 +652,9 @@ FIND_PACKET:
 			r.ci.Timestamp = time.Unix(r.convertTime(r.ci.InterfaceIndex, uint64(r.getUint32(r.buf[4:8]))<<32|uint64(r.getUint32(r.buf[8:12])))).UTC()
 			r.ci.CaptureLength = int(r.getUint32(r.buf[12:16]))
 			r.ci.Length = int(r.getUint32(r.buf[16:20]))
+			r.packetInfo = NgPacketInfo{DropCount: NgNoValue64}
 			break FIND_PACKET
-		case ngBlockTypeSimplePacket:
+		case NgBlockTypeSimplePacket:
 			if err := r.readBytes(r.buf[:4]); err != nil {
 				return err
 			}
@@ -476,20 +669,21 @@ FIND_PACKET:
 			if r.ifaces[0].SnapLength != 0 && uint32(r.ci.CaptureLength) > r.ifaces[0].SnapLength {
 				r.ci.CaptureLength = int(r.ifaces[0].SnapLength)
 			}
+			r.packetInfo = NgPacketInfo{DropCount: NgNoValue64}
 			break FIND_PACKET
-		case ngBlockTypeInterfaceDescriptor:
+		case NgBlockTypeInterfaceDescriptor:
 			if err := r.readInterfaceDescriptor(); err != nil {
 				return err
 			}
-		case ngBlockTypeInterfaceStatistics:
+		case NgBlockTypeInterfaceStatistics:
 			if err := r.readInterfaceStatistics(); err != nil {
 				return err
 			}
-		case ngBlockTypeSectionHeader:
+		case NgBlockTypeSectionHeader:
 			if err := r.readSectionHeader(); err != nil {
 				return err
 			}
-		case ngBlockTypePacket:
+		case NgBlockTypePacket:
 			if err := r.readBytes(r.buf[:20]); err != nil {
 				return err
 			}
@@ -501,16 +695,21 @@ FIND_PACKET:
 			r.ci.Timestamp = time.Unix(r.convertTime(r.ci.InterfaceIndex, uint64(r.getUint32(r.buf[4:8]))<<32|uint64(r.getUint32(r.buf[8:12])))).UTC()
 			r.ci.CaptureLength = int(r.getUint32(r.buf[12:16]))
 			r.ci.Length = int(r.getUint32(r.buf[16:20]))
+			r.packetInfo = NgPacketInfo{DropCount: NgNoValue64}
+			// The drops count of packet blocks is 0xffff if unknown.
+			if drops := r.getUint16(r.buf[2:4]); drops != 0xffff {
+				r.packetInfo.DropCount = uint64(drops)
+			}
 			break FIND_PACKET
 		default:
-			if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+			if err := r.readOtherBlock(); err != nil {
 				return err
 			}
 		}
 	}
 	if !r.options.WantMixedLinkType {
 		if r.ifaces[r.ci.InterfaceIndex].LinkType != r.linkType {
-			if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+			if err := r.discard(int(r.currentBlock.length)); err != nil {
 				return err
 			}
 			if r.options.ErrorOnMismatchingLinkType {
@@ -518,32 +717,110 @@ FIND_PACKET:
 			}
 			goto RESTART
 		}
-		return nil
+	} else {
+		r.ancil[0] = r.ifaces[r.ci.InterfaceIndex].LinkType
+	}
+	r.nancil = 0
+	if r.options.WantMixedLinkType {
+		r.nancil++
+	}
+	if r.options.WantPacketInfo {
+		r.packetInfo.Section = r.sectionInfo
+		r.packetInfo.Interface = r.ifaces[r.ci.InterfaceIndex]
+		r.ancil[r.nancil] = &r.packetInfo
+		r.nancil++
 	}
-	r.ancil[0] = r.ifaces[r.ci.InterfaceIndex].LinkType
+	r.ci.Timestamp = r.options.TimeCorrection.correct(r.ci.Timestamp)
 	return nil
 }
 
+// finishPacket skips the rest of the current packet block after its data, reading its options into packetInfo if WantPacketInfo is set.
+func (r *NgReader) finishPacket() error {
+	if !r.options.WantPacketInfo {
+		return r.discard(int(r.currentBlock.length) - r.ci.CaptureLength)
+	}
+	length := uint32(r.ci.CaptureLength + (4-r.ci.CaptureLength&3)&3)
+	if length > r.currentBlock.length {
+		return fmt.Errorf("Packet data of length %d exceeds block", r.ci.CaptureLength)
+	}
+	if err := r.discard(int(length) - r.ci.CaptureLength); err != nil {
+		return err
+	}
+	r.currentBlock.length -= length
+	info := &r.packetInfo
+
+OPTIONS:
+	for {
+		if err := r.readOption(); err != nil {
+			return err
+		}
+		switch r.currentOption.code {
+		case ngOptionCodeEndOfOptions:
+			break OPTIONS
+		case ngOptionCodeComment:
+			info.Comments = append(info.Comments, string(r.currentOption.value))
+		case ngOptionCodePacketFlags:
+			if v := r.optionValue(4, &info.Options); v != nil {
+				info.Flags = r.getUint32(v)
+			}
+		case ngOptionCodePacketHash:
+			info.Hash = append([]byte(nil), r.currentOption.value...)
+		case ngOptionCodePacketDropCount:
+			if v := r.optionValue(8, &info.Options); v != nil {
+				info.DropCount = r.getUint64(v)
+			}
+		case ngOptionCodePacketID:
+			if v := r.optionValue(8, &info.Options); v != nil {
+				info.PacketID = r.getUint64(v)
+			}
+		case ngOptionCodePacketQueue:
+			if v := r.optionValue(4, &info.Options); v != nil {
+				info.Queue = r.getUint32(v)
+			}
+		default:
+			info.Options = append(info.Options, r.option())
+		}
+	}
+	return r.discard(int(r.currentBlock.length))
+}
+
 // ReadPacketData returns the next packet available from this data source.
 // If WantMixedLinkType is true, ci.AncillaryData[0] contains the link type.
+// If WantPacketInfo is true, the last element of ci.AncillaryData is the *NgPacketInfo of the packet.
 func (r *NgReader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	if r.filter != nil {
+		if data, ci, err = r.ZeroCopyReadPacketData(); err != nil {
+			return
+		}
+		ci.AncillaryData = r.copyAncillaryData()
+		return append([]byte(nil), data...), ci, nil
+	}
 	if err = r.readPacketHeader(); err != nil {
 		return
 	}
 	ci = r.ci
-	if r.options.WantMixedLinkType {
-		ci.AncillaryData = make([]interface{}, 1)
-		ci.AncillaryData[0] = r.ancil[0]
-	}
 	data = make([]byte, r.ci.CaptureLength)
 	if err = r.readBytes(data); err != nil {
 		return
 	}
-	// handle options somehow - this would be expensive
-	_, err = r.r.Discard(int(r.currentBlock.length) - r.ci.CaptureLength)
+	err = r.finishPacket()
+	ci.AncillaryData = r.copyAncillaryData()
 	return
 }
 
+// copyAncillaryData returns a copy of the ancillary data of the current packet, which the next packet does not overwrite.
+func (r *NgReader) copyAncillaryData() []interface{} {
+	if r.nancil == 0 {
+		return nil
+	}
+	ancil := append([]interface{}(nil), r.ancil[:r.nancil]...)
+	if r.options.WantPacketInfo {
+		info := r.packetInfo
+		ancil[r.nancil-1] = &info
+	}
+	return ancil
+}
+
 // ZeroCopyReadPacketData returns the next packet available from this data source.
 // If WantMixedLinkType is true, ci.AncillaryData[0] contains the link type.
 // Warning: Like data, ci.AncillaryData is also reused and overwritten on the next call to ZeroCopyReadPacketData.
@@ -551,12 +828,29 @@ func (r *NgReader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err e
 // It is not true zero copy, as data is still copied from the underlying reader. However,
 // this method avoids allocating heap memory for every packet.
 func (r *NgReader) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	for {
+		data, ci, err = r.readPacket()
+		if err != nil || r.filter == nil || matches(r.filter, data) {
+			return
+		}
+	}
+}
+
+// readPacket reads the next packet into packetBuf.
+func (r *NgReader) readPacket() (data []byte, ci gopacket.CaptureInfo, err error) {
 	if err = r.readPacketHeader(); err != nil {
 		return
 	}
 	ci = r.ci
-	if r.options.WantMixedLinkType {
-		ci.AncillaryData = r.ancil[:]
+	if r.nancil > 0 {
+		ci.AncillaryData = r.ancil[:r.nancil]
+	}
+	if r.mapped != nil {
+		if data, err = r.mapped.next(ci.CaptureLength); err != nil {
+			return
+		}
+		err = r.finishPacket()
+		return
 	}
 	if cap(r.packetBuf) < ci.CaptureLength {
 		snaplen := int(r.ifaces[ci.InterfaceIndex].SnapLength)
@@ -569,8 +863,7 @@ func (r *NgReader) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInf
 	if err = r.readBytes(data); err != nil {
 		return
 	}
-	// handle options somehow - this would be expensive
-	_, err = r.r.Discard(int(r.currentBlock.length) - ci.CaptureLength)
+	err = r.finishPacket()
 	return
 }
 
@@ -12,6 +12,7 @@ import (
 	"encoding/hex"
 	"io"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -38,6 +39,26 @@ var ngPacketSource = [...][]byte{
 	ngMustDecode("02000000450000a4c6ce00004011f147c0a8018bffffffff445c445c0090ba037b22686f73745f696e74223a20343039343531343438332c202276657273696f6e223a205b312c20385d2c2022646973706c61796e616d65223a2022222c2022706f7274223a2031373530302c20226e616d65737061636573223a205b32303532343235372c203633393533393037322c203633393533393333372c203633393533393535355d7d"),
 }
 
+// ngTestOptions are the custom and unknown options the test generator adds
+// to blocks.
+var ngTestOptions = []NgOption{
+	{Code: 0x0bac, Value: []byte("a fake string")},
+	{Code: 0x0bad, Value: []byte("some fake bytes")},
+	{Code: 0x4bac, Value: []byte("my fake string")},
+	{Code: 0x4bad, Value: []byte("my fake bytes")},
+	{Code: 0x0123, Value: []byte("try this one")},
+	{Code: 0x8123, Value: []byte("and this one")},
+}
+
+// ngReversedTestOptions are ngTestOptions in reverse order.
+var ngReversedTestOptions = func() []NgOption {
+	var options []NgOption
+	for i := len(ngTestOptions) - 1; i >= 0; i-- {
+		options = append(options, ngTestOptions[i])
+	}
+	return options
+}()
+
 type ngFileReadTestPacket struct {
 	data []byte
 	ci   gopacket.CaptureInfo
@@ -87,8 +108,6 @@ func ngRunFileReadTest(test ngFileReadTest, be string, zerocopy bool, t *testing
 			currentInterface.TimestampResolution = 6
 		}
 		// clear private values
-		intf.scaleDown = 0
-		intf.scaleUp = 0
 		intf.secondMask = 0
 
 		if !reflect.DeepEqual(intf, currentInterface) {
@@ -613,6 +632,7 @@ var tests = []ngFileReadTest{
 					OS:          "OS-X 10.10.5",
 					Application: "pcap_writer.lua",
 					Comment:     "test007",
+					Options:     ngTestOptions,
 				},
 				ifaces: []NgInterface{
 					{
@@ -656,6 +676,11 @@ var tests = []ngFileReadTest{
 						Filter:              "tcp port 23 and host 192.0.2.5",
 						OS:                  "Microsoft Windows for Workgroups 3.11b\npatch 42",
 						TimestampResolution: 9,
+						IPv4Addresses:       []net.IPNet{{IP: net.IP{10, 1, 2, 3}, Mask: net.IPMask{255, 255, 255, 0}}},
+						IPv6Addresses:       []net.IPNet{{IP: net.ParseIP("2100:db8::1a2b"), Mask: net.CIDRMask(64, 128)}},
+						Speed:               1000000000,
+						// The MAC and EUI addresses are one byte long.
+						Options: append([]NgOption{{Code: 6, Value: []byte{0}}, {Code: 7, Value: []byte{2}}}, ngTestOptions...),
 					},
 					{
 						LinkType:            layers.LinkTypeEthernet,
@@ -666,6 +691,10 @@ var tests = []ngFileReadTest{
 						Filter:              "tcp port 23 and host 192.0.2.5",
 						OS:                  "Novell NetWare 4.11\nbut not using IPX",
 						TimestampResolution: 9,
+						IPv4Addresses:       []net.IPNet{{IP: net.IP{10, 1, 2, 4}, Mask: net.IPMask{255, 255, 255, 0}}},
+						IPv6Addresses:       []net.IPNet{{IP: net.ParseIP("0:db8:85a3:8d3:1319:8a2e:370:7344"), Mask: net.CIDRMask(64, 128)}},
+						Speed:               100000000,
+						Options:             append(ngReversedTestOptions, NgOption{Code: 7, Value: []byte{2}}, NgOption{Code: 6, Value: []byte{0}}),
 					},
 				},
 			},
@@ -949,6 +978,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 				},
@@ -977,6 +1009,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -989,6 +1024,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -1001,6 +1039,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 							Comment:         "test014 ISB",
 						},
 					},
@@ -1251,6 +1292,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -1261,6 +1305,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0x4c39764ca47aa*1000-1000*1000).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -1273,6 +1320,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  42,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 							Comment:         "test101 ISB-2",
 						},
 					},
@@ -1329,6 +1379,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -1339,6 +1392,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0x4c39764ca47aa*1000-1000*1000).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -1351,6 +1407,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  42,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 							Comment:         "test102 ISB-2",
 						},
 					},
@@ -1472,6 +1531,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0x4c39764ca47aa*1000).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 				},
@@ -1482,6 +1544,7 @@ var tests = []ngFileReadTest{
 					OS:          "OS-X 10.10.5",
 					Application: "pcap_writer.lua",
 					Comment:     "test201 SHB-1",
+					Options:     []NgOption{{Code: 0x0123}, {Code: 0x8123, Value: []byte("test201 NRB")}},
 				},
 				ifaces: []NgInterface{
 					{
@@ -1494,7 +1557,11 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  42,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 							Comment:         "test201 ISB-2",
+							Options:         []NgOption{{Code: 0x0123}, {Code: 0x8123, Value: []byte("test201 NRB")}},
 						},
 					},
 				},
@@ -1515,6 +1582,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -1580,6 +1650,9 @@ var tests = []ngFileReadTest{
 							LastUpdate:      time.Unix(0, 0x4c39764ca47aa*1000).UTC(),
 							PacketsDropped:  NgNoValue64,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 				},
@@ -1590,6 +1663,7 @@ var tests = []ngFileReadTest{
 					OS:          "OS-X 10.10.5",
 					Application: "pcap_writer.lua",
 					Comment:     "test202 SHB-1",
+					Options:     []NgOption{{Code: 0x0123}, {Code: 0x8123, Value: []byte("test202 NRB")}},
 				},
 				ifaces: []NgInterface{
 					{
@@ -1602,7 +1676,11 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsDropped:  10,
 							PacketsReceived: NgNoValue64,
+							FilterAccepted:  42,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 							Comment:         "test202 ISB-2",
+							Options:         []NgOption{{Code: 0x0123}, {Code: 0x8123, Value: []byte("test202 NRB")}},
 						},
 					},
 				},
@@ -1625,6 +1703,9 @@ var tests = []ngFileReadTest{
 							EndTime:         time.Unix(0, 0x4c39764ca47aa*1000+1000*1000).UTC(),
 							PacketsReceived: 100,
 							PacketsDropped:  1,
+							FilterAccepted:  9,
+							OSDropped:       42,
+							Delivered:       6,
 							Comment:         "test202 ISB-0",
 						},
 					},
@@ -1842,6 +1923,109 @@ func TestNgFileReadLEZero(t *testing.T) {
 	}
 }
 
+func TestNgPacketInfo(t *testing.T) {
+	for _, be := range []string{"be", "le"} {
+		testf, err := os.Open(filepath.Join("tests", be, "test009.pcapng"))
+		if err != nil {
+			t.Fatal("Couldn't open file:", err)
+		}
+		options := DefaultNgReaderOptions
+		options.WantPacketInfo = true
+		r, err := NewNgReader(testf, options)
+		if err != nil {
+			t.Fatal("Couldn't read start of file:", err)
+		}
+		want := []NgPacketInfo{
+			{Comments: []string{"test009-1"}},
+			{Comments: []string{"test009-2"}, Flags: 0x48000000, DropCount: 0x3039},
+		}
+		for i, w := range want {
+			_, ci, err := r.ReadPacketData()
+			if err != nil {
+				t.Fatalf("%s: couldn't read packet %d: %s", be, i, err)
+			}
+			if len(ci.AncillaryData) != 1 {
+				t.Fatalf("%s: expected packet info in ancillary data, got %v", be, ci.AncillaryData)
+			}
+			info := ci.AncillaryData[0].(*NgPacketInfo)
+			if info.Section.Comment != "test009" || info.Interface.Name != "eth0" {
+				t.Errorf("%s: packet %d has wrong section or interface: %+v", be, i, info)
+			}
+			if len(info.Options) != len(ngTestOptions) {
+				t.Errorf("%s: packet %d should have %d other options but has %v", be, i, len(ngTestOptions), info.Options)
+			}
+			info.Section, info.Interface, info.Options = NgSectionInfo{}, NgInterface{}, nil
+			if !reflect.DeepEqual(*info, w) {
+				t.Errorf("%s: packet %d info should be\n%+v\nbut is\n%+v", be, i, w, *info)
+			}
+		}
+		testf.Close()
+	}
+}
+
+func TestNgOtherBlocks(t *testing.T) {
+	type block struct {
+		typ  NgBlockType
+		body string
+	}
+	for _, be := range []string{"be", "le"} {
+		var names []NgNameResolution
+		var blocks []block
+		options := DefaultNgReaderOptions
+		options.NameResolutionCallback = func(nr NgNameResolution) {
+			names = append(names, nr)
+		}
+		options.BlockCallback = func(typ NgBlockType, body []byte) {
+			blocks = append(blocks, block{typ, string(body)})
+		}
+		for _, name := range []string{"test015", "test017"} {
+			testf, err := os.Open(filepath.Join("tests", be, name+".pcapng"))
+			if err != nil {
+				t.Fatal("Couldn't open file:", err)
+			}
+			// test017 has no interface, so its blocks are all read by NewNgReader.
+			r, err := NewNgReader(testf, options)
+			if err == io.EOF {
+				testf.Close()
+				continue
+			} else if err != nil {
+				t.Fatal("Couldn't read start of file:", err)
+			}
+			for {
+				if _, _, err := r.ReadPacketData(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("%s: couldn't read %s: %s", be, name, err)
+				}
+			}
+			testf.Close()
+		}
+		wantNames := []NgNameResolution{{
+			Records: []NgNameRecord{
+				{Address: net.IPv4(192, 168, 1, 2).To4(), Names: []string{"example.com"}},
+				{Address: net.IPv4(192, 168, 3, 4).To4(), Names: []string{"example.net"}},
+				{Address: net.IPv4(10, 1, 2, 3).To4(), Names: []string{"example.org"}},
+			},
+			Comment: "test015 NRB",
+		}}
+		if !reflect.DeepEqual(names, wantNames) {
+			t.Errorf("%s: name resolution should be\n%+v\nbut is\n%+v", be, wantNames, names)
+		}
+		wantTypes := []NgBlockType{NgBlockTypeCustom, NgBlockTypeCustomNoCopy, NgBlockTypeCustom, NgBlockTypeCustomNoCopy}
+		if len(blocks) != len(wantTypes) {
+			t.Fatalf("%s: expected %d custom blocks, got %d", be, len(wantTypes), len(blocks))
+		}
+		for i, b := range blocks {
+			if b.typ != wantTypes[i] {
+				t.Errorf("%s: block %d should have type %x but has %x", be, i, wantTypes[i], b.typ)
+			}
+		}
+		if !bytes.Contains([]byte(blocks[0].body), []byte("an example Custom Block")) {
+			t.Errorf("%s: unexpected custom block body %q", be, blocks[0].body)
+		}
+	}
+}
+
 type endlessNgPacketReader struct {
 	packet []byte
 }
@@ -11,7 +11,9 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math/bits"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/google/gopacket"
@@ -42,17 +44,24 @@ var DefaultNgInterface = NgInterface{
 }
 
 // NgWriter holds the internal state of a pcapng file writer. Internally a bufio.NgWriter is used, therefore Flush must be called before closing the underlying file.
+//
+// An NgWriter is safe for concurrent use, so packets of several sources can be interleaved in the same file, and interfaces added while other sources write packets.
 type NgWriter struct {
+	mu      sync.Mutex
 	w       *bufio.Writer
 	options NgWriterOptions
-	intf    uint32
-	buf     [28]byte
+	// units holds the number of timestamp units per second of each interface written.
+	units []uint64
+	buf   [28]byte
 }
 
+// ngTimestamp is a timestamp in the units of an interface, written as an option.
+type ngTimestamp uint64
+
 // NewNgWriter initializes and returns a new writer. Additionally, one section and one interface (without statistics) is written to the file. Interface and section options are used from DefaultNgInterface and DefaultNgWriterOptions.
 // Flush must be called before the file is closed, or if eventual unwritten information should be written out to the storage device.
 //
-// Written files are in little endian format. Interface timestamp resolution is fixed to 9 (to match time.Time).
+// Written files are in little endian format. The interface timestamp resolution is 9 (to match time.Time).
 func NewNgWriter(w io.Writer, linkType layers.LinkType) (*NgWriter, error) {
 	intf := DefaultNgInterface
 	intf.LinkType = linkType
@@ -62,7 +71,7 @@ func NewNgWriter(w io.Writer, linkType layers.LinkType) (*NgWriter, error) {
 // NewNgWriterInterface initializes and returns a new writer. Additionally, one section and one interface (without statistics) is written to the file.
 // Flush must be called before the file is closed, or if eventual unwritten information should be written out to the storage device.
 //
-// Written files are in little endian format. Interface timestamp resolution is fixed to 9 (to match time.Time).
+// Written files are in little endian format. Timestamps of intf are written with its TimestampResolution, as with AddInterface.
 func NewNgWriterInterface(w io.Writer, intf NgInterface, options NgWriterOptions) (*NgWriter, error) {
 	ret := &NgWriter{
 		w:       bufio.NewWriter(w),
@@ -85,7 +94,7 @@ func ngOptionLength(option ngOption) int {
 		return len(val)
 	case string:
 		return len(val)
-	case time.Time:
+	case ngTimestamp:
 		return 8
 	case uint64:
 		return 8
@@ -148,10 +157,9 @@ func (w *NgWriter) writeOptions(options []ngOption) error {
 					return err
 				}
 			}
-		case time.Time:
-			ts := val.UnixNano()
-			binary.LittleEndian.PutUint32(w.buf[:4], uint32(ts>>32))
-			binary.LittleEndian.PutUint32(w.buf[4:8], uint32(ts))
+		case ngTimestamp:
+			binary.LittleEndian.PutUint32(w.buf[:4], uint32(val>>32))
+			binary.LittleEndian.PutUint32(w.buf[4:8], uint32(val))
 			if _, err := w.w.Write(w.buf[:8]); err != nil {
 				return err
 			}
@@ -214,7 +222,7 @@ func (w *NgWriter) writeSectionHeader() error {
 		24 + // header
 		4 // trailer
 
-	binary.LittleEndian.PutUint32(w.buf[:4], uint32(ngBlockTypeSectionHeader))
+	binary.LittleEndian.PutUint32(w.buf[:4], uint32(NgBlockTypeSectionHeader))
 	binary.LittleEndian.PutUint32(w.buf[4:8], length)
 	binary.LittleEndian.PutUint32(w.buf[8:12], ngByteOrderMagic)
 	binary.LittleEndian.PutUint16(w.buf[12:14], ngVersionMajor)
@@ -233,10 +241,20 @@ func (w *NgWriter) writeSectionHeader() error {
 	return err
 }
 
-// AddInterface adds the specified interface to the file, excluding statistics. Interface timestamp resolution is fixed to 9 (to match time.Time). Empty values are not written.
+// AddInterface adds the specified interface to the file, excluding statistics, and returns its id. Interfaces can be added at any time, e.g. when they are plugged in during a capture.
+// Timestamps of packets and statistics of the interface are written with its TimestampResolution, which defaults to 9 (to match time.Time) if zero. Empty values are not written.
 func (w *NgWriter) AddInterface(intf NgInterface) (id int, err error) {
-	id = int(w.intf)
-	w.intf++
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	resolution := intf.TimestampResolution
+	if resolution == 0 {
+		resolution = 9
+	}
+	unit, ok := ngResolutionUnits(resolution)
+	if !ok {
+		return 0, fmt.Errorf("Unsupported timestamp resolution %#x", uint8(resolution))
+	}
 
 	var scratch [7]ngOption
 	i := 0
@@ -271,7 +289,7 @@ func (w *NgWriter) AddInterface(intf NgInterface) (id int, err error) {
 		i++
 	}
 	scratch[i].code = ngOptionCodeInterfaceTimestampResolution
-	scratch[i].raw = uint8(9) // fix resolution to nanoseconds (time.Time) in decimal
+	scratch[i].raw = uint8(resolution)
 	i++
 	options := scratch[:i]
 
@@ -279,7 +297,7 @@ func (w *NgWriter) AddInterface(intf NgInterface) (id int, err error) {
 		16 + // header
 		4 // trailer
 
-	binary.LittleEndian.PutUint32(w.buf[:4], uint32(ngBlockTypeInterfaceDescriptor))
+	binary.LittleEndian.PutUint32(w.buf[:4], uint32(NgBlockTypeInterfaceDescriptor))
 	binary.LittleEndian.PutUint32(w.buf[4:8], length)
 	binary.LittleEndian.PutUint16(w.buf[8:10], uint16(intf.LinkType))
 	binary.LittleEndian.PutUint16(w.buf[10:12], 0) // reserved value
@@ -293,26 +311,60 @@ func (w *NgWriter) AddInterface(intf NgInterface) (id int, err error) {
 	}
 
 	binary.LittleEndian.PutUint32(w.buf[0:4], length)
-	_, err = w.w.Write(w.buf[:4])
-	return id, err
+	if _, err = w.w.Write(w.buf[:4]); err != nil {
+		return 0, err
+	}
+	// only count the interface once its block is written, so ids of later interfaces match their blocks
+	id = len(w.units)
+	w.units = append(w.units, unit)
+	return id, nil
+}
+
+// ngResolutionUnits returns the number of timestamp units per second of the given resolution, or false if they don't fit in 64 bits.
+func ngResolutionUnits(resolution NgResolution) (uint64, bool) {
+	if resolution.Binary() {
+		if resolution.Exponent() > 63 {
+			return 0, false
+		}
+		return 1 << resolution.Exponent(), true
+	}
+	if resolution.Exponent() > 19 {
+		return 0, false
+	}
+	unit := uint64(1)
+	for i := uint8(0); i < resolution.Exponent(); i++ {
+		unit *= 10
+	}
+	return unit, true
 }
 
-// WriteInterfaceStats writes the given interface statistics for the given interface id to the file. Empty values are not written.
+// timestamp converts t to the timestamp units of the given interface.
+func (w *NgWriter) timestamp(intf int, t time.Time) ngTimestamp {
+	unit := w.units[intf]
+	hi, lo := bits.Mul64(uint64(t.Nanosecond()), unit)
+	frac, _ := bits.Div64(hi, lo, 1e9)
+	return ngTimestamp(uint64(t.Unix())*unit + frac)
+}
+
+// WriteInterfaceStats writes the given interface statistics for the given interface id to the file. Empty values are not written; FilterAccepted, OSDropped and Delivered are also taken as empty if they are zero.
 func (w *NgWriter) WriteInterfaceStats(intf int, stats NgInterfaceStatistics) error {
-	if intf >= int(w.intf) || intf < 0 {
-		return fmt.Errorf("Can't send statistics for non existent interface %d; have only %d interfaces", intf, w.intf)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if intf >= len(w.units) || intf < 0 {
+		return fmt.Errorf("Can't send statistics for non existent interface %d; have only %d interfaces", intf, len(w.units))
 	}
 
-	var scratch [4]ngOption
+	var scratch [7]ngOption
 	i := 0
 	if !stats.StartTime.IsZero() {
 		scratch[i].code = ngOptionCodeInterfaceStatisticsStartTime
-		scratch[i].raw = stats.StartTime
+		scratch[i].raw = w.timestamp(intf, stats.StartTime)
 		i++
 	}
 	if !stats.EndTime.IsZero() {
 		scratch[i].code = ngOptionCodeInterfaceStatisticsEndTime
-		scratch[i].raw = stats.EndTime
+		scratch[i].raw = w.timestamp(intf, stats.EndTime)
 		i++
 	}
 	if stats.PacketsDropped != NgNoValue64 {
@@ -325,16 +377,31 @@ func (w *NgWriter) WriteInterfaceStats(intf int, stats NgInterfaceStatistics) er
 		scratch[i].raw = stats.PacketsReceived
 		i++
 	}
+	if stats.FilterAccepted != NgNoValue64 && stats.FilterAccepted != 0 {
+		scratch[i].code = ngOptionCodeInterfaceStatisticsFilterAccept
+		scratch[i].raw = stats.FilterAccepted
+		i++
+	}
+	if stats.OSDropped != NgNoValue64 && stats.OSDropped != 0 {
+		scratch[i].code = ngOptionCodeInterfaceStatisticsOSDrop
+		scratch[i].raw = stats.OSDropped
+		i++
+	}
+	if stats.Delivered != NgNoValue64 && stats.Delivered != 0 {
+		scratch[i].code = ngOptionCodeInterfaceStatisticsDelivered
+		scratch[i].raw = stats.Delivered
+		i++
+	}
 	options := scratch[:i]
 
 	length := prepareNgOptions(options) + 24
 
-	ts := stats.LastUpdate.UnixNano()
-	if stats.LastUpdate.IsZero() {
-		ts = 0
+	var ts ngTimestamp
+	if !stats.LastUpdate.IsZero() {
+		ts = w.timestamp(intf, stats.LastUpdate)
 	}
 
-	binary.LittleEndian.PutUint32(w.buf[:4], uint32(ngBlockTypeInterfaceStatistics))
+	binary.LittleEndian.PutUint32(w.buf[:4], uint32(NgBlockTypeInterfaceStatistics))
 	binary.LittleEndian.PutUint32(w.buf[4:8], length)
 	binary.LittleEndian.PutUint32(w.buf[8:12], uint32(intf))
 	binary.LittleEndian.PutUint32(w.buf[12:16], uint32(ts>>32))
@@ -354,8 +421,11 @@ func (w *NgWriter) WriteInterfaceStats(intf int, stats NgInterfaceStatistics) er
 
 // WritePacket writes out packet with the given data and capture info. The given InterfaceIndex must already be added to the file. InterfaceIndex 0 is automatically added by the NewWriter* methods.
 func (w *NgWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
-	if ci.InterfaceIndex >= int(w.intf) || ci.InterfaceIndex < 0 {
-		return fmt.Errorf("Can't send statistics for non existent interface %d; have only %d interfaces", ci.InterfaceIndex, w.intf)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ci.InterfaceIndex >= len(w.units) || ci.InterfaceIndex < 0 {
+		return fmt.Errorf("Can't write packet for non existent interface %d; have only %d interfaces", ci.InterfaceIndex, len(w.units))
 	}
 	if ci.CaptureLength != len(data) {
 		return fmt.Errorf("capture length %d does not match data length %d", ci.CaptureLength, len(data))
@@ -368,9 +438,9 @@ func (w *NgWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
 	padding := (4 - length&3) & 3
 	length += padding
 
-	ts := ci.Timestamp.UnixNano()
+	ts := w.timestamp(ci.InterfaceIndex, ci.Timestamp)
 
-	binary.LittleEndian.PutUint32(w.buf[:4], uint32(ngBlockTypeEnhancedPacket))
+	binary.LittleEndian.PutUint32(w.buf[:4], uint32(NgBlockTypeEnhancedPacket))
 	binary.LittleEndian.PutUint32(w.buf[4:8], length)
 	binary.LittleEndian.PutUint32(w.buf[8:12], uint32(ci.InterfaceIndex))
 	binary.LittleEndian.PutUint32(w.buf[12:16], uint32(ts>>32))
@@ -393,5 +463,7 @@ func (w *NgWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
 
 // Flush writes out buffered data to the storage media. Must be called before closing the underlying file.
 func (w *NgWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.w.Flush()
 }
@@ -8,6 +8,7 @@ package pcapgo
 
 import (
 	"bytes"
+	"io"
 	"testing"
 	"time"
 
@@ -62,6 +63,29 @@ func TestNgWriteSimple(t *testing.T) {
 	ngRunFileReadTest(test, "", false, t)
 }
 
+func TestNgWriteInterfaceStatsUnset(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	w, err := NewNgWriter(buffer, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal("Opening file failed with: ", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("Couldn't flush buffer", err)
+	}
+	n := buffer.Len()
+	// Statistics which only set the received and dropped counters get an
+	// option for each of these only.
+	if err := w.WriteInterfaceStats(0, NgInterfaceStatistics{PacketsReceived: 10, PacketsDropped: 1}); err != nil {
+		t.Fatal("Couldn't write statistics", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("Couldn't flush buffer", err)
+	}
+	if got, want := buffer.Len()-n, 24+2*12+4; got != want {
+		t.Errorf("wrote %d bytes of statistics, want %d", got, want)
+	}
+}
+
 func TestNgWriteComplex(t *testing.T) {
 	test := ngFileReadTest{
 		linkType: layers.LinkTypeEthernet,
@@ -83,6 +107,9 @@ func TestNgWriteComplex(t *testing.T) {
 							EndTime:         time.Unix(1519128000, 195312500).UTC(),
 							PacketsReceived: 100,
 							PacketsDropped:  1,
+							FilterAccepted:  NgNoValue64,
+							OSDropped:       NgNoValue64,
+							Delivered:       NgNoValue64,
 						},
 					},
 					{
@@ -93,7 +120,10 @@ func TestNgWriteComplex(t *testing.T) {
 						LinkType:        layers.LinkTypeEthernet,
 						TimestampOffset: 100,
 						Statistics: NgInterfaceStatistics{
-							LastUpdate: time.Unix(1519128000, 195312500).UTC(),
+							LastUpdate:     time.Unix(1519128000, 195312500).UTC(),
+							FilterAccepted: NgNoValue64,
+							OSDropped:      NgNoValue64,
+							Delivered:      NgNoValue64,
 						},
 					},
 				},
@@ -201,9 +231,17 @@ func TestNgWriteComplex(t *testing.T) {
 		t.Fatal("Couldn't flush buffer", err)
 	}
 
-	// writer fixes resolution to 9
-	test.sections[0].ifaces[0].TimestampResolution = 9
+	// interface 0 is written in milliseconds, and interface 1 defaults to nanoseconds
 	test.sections[0].ifaces[1].TimestampResolution = 9
+	stats := &test.sections[0].ifaces[0].Statistics
+	stats.LastUpdate = stats.LastUpdate.Truncate(time.Millisecond)
+	stats.StartTime = stats.StartTime.Truncate(time.Millisecond)
+	stats.EndTime = stats.EndTime.Truncate(time.Millisecond)
+	for i := range test.packets {
+		if test.packets[i].ci.InterfaceIndex == 0 {
+			test.packets[i].ci.Timestamp = test.packets[i].ci.Timestamp.Truncate(time.Millisecond)
+		}
+	}
 
 	// compensate for offset on interface 1
 	test.sections[0].ifaces[1].Statistics.LastUpdate = test.sections[0].ifaces[1].Statistics.LastUpdate.Add(100 * time.Second)
@@ -214,6 +252,137 @@ func TestNgWriteComplex(t *testing.T) {
 	ngRunFileReadTest(test, "", false, t)
 }
 
+func TestNgWriteResolutions(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	intf := DefaultNgInterface
+	intf.TimestampResolution = 6
+	w, err := NewNgWriterInterface(buffer, intf, DefaultNgWriterOptions)
+	if err != nil {
+		t.Fatal("Opening file failed with: ", err)
+	}
+
+	ts := time.Unix(1519128000, 123456789).UTC()
+	ci := func(intf int) gopacket.CaptureInfo {
+		return gopacket.CaptureInfo{
+			Timestamp:      ts,
+			Length:         1,
+			CaptureLength:  1,
+			InterfaceIndex: intf,
+		}
+	}
+	if err := w.WritePacket(ci(0), []byte{0}); err != nil {
+		t.Fatal("Couldn't write packet", err)
+	}
+	// interfaces added mid-capture, the first one with an invalid resolution
+	intf.TimestampResolution = 20
+	if _, err := w.AddInterface(intf); err == nil {
+		t.Fatal("Expected error for resolution 10^-20")
+	}
+	intf.TimestampResolution = 0x80 | 20
+	if id, err := w.AddInterface(intf); err != nil || id != 1 {
+		t.Fatalf("Adding interface returned %d, %v; want 1", id, err)
+	}
+	if err := w.WritePacket(ci(1), []byte{1}); err != nil {
+		t.Fatal("Couldn't write packet", err)
+	}
+	intf.TimestampResolution = 0
+	if id, err := w.AddInterface(intf); err != nil || id != 2 {
+		t.Fatalf("Adding interface returned %d, %v; want 2", id, err)
+	}
+	for _, i := range []int{2, 0, 1} {
+		if err := w.WritePacket(ci(i), []byte{byte(i)}); err != nil {
+			t.Fatal("Couldn't write packet", err)
+		}
+	}
+	if err := w.WritePacket(ci(3), []byte{3}); err == nil {
+		t.Fatal("Expected error for packet of unknown interface")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("Couldn't flush buffer", err)
+	}
+
+	want := []time.Time{
+		ts.Truncate(time.Microsecond),
+		// 2^-20 seconds, read back rounded down to nanoseconds
+		time.Unix(1519128000, (123456789<<20/1000000000)*1000000000>>20).UTC(),
+		ts,
+	}
+	r, err := NewNgReader(buffer, DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal("Couldn't read file", err)
+	}
+	for _, i := range []int{0, 1, 2, 0, 1} {
+		data, gotCI, err := r.ReadPacketData()
+		if err != nil {
+			t.Fatal("Couldn't read packet", err)
+		}
+		if gotCI.InterfaceIndex != i || data[0] != byte(i) {
+			t.Errorf("Packet of interface %d read from interface %d", data[0], gotCI.InterfaceIndex)
+		}
+		if !gotCI.Timestamp.Equal(want[i]) {
+			t.Errorf("Interface %d timestamp should be %v but is %v", i, want[i], gotCI.Timestamp)
+		}
+	}
+}
+
+func TestNgWriteConcurrent(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	w, err := NewNgWriter(buffer, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal("Opening file failed with: ", err)
+	}
+	const sources, packets = 4, 100
+	intf := DefaultNgInterface
+	intf.LinkType = layers.LinkTypeEthernet
+	errs := make(chan error, sources)
+	for s := 0; s < sources; s++ {
+		go func() {
+			id, err := w.AddInterface(intf)
+			for i := 0; i < packets && err == nil; i++ {
+				ci := gopacket.CaptureInfo{
+					Timestamp:      time.Now(),
+					Length:         1,
+					CaptureLength:  1,
+					InterfaceIndex: id,
+				}
+				err = w.WritePacket(ci, []byte{byte(id)})
+			}
+			errs <- err
+		}()
+	}
+	for s := 0; s < sources; s++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("Couldn't flush buffer", err)
+	}
+
+	r, err := NewNgReader(buffer, DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal("Couldn't read file", err)
+	}
+	counts := make(map[int]int)
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal("Couldn't read packet", err)
+		}
+		if int(data[0]) != ci.InterfaceIndex {
+			t.Fatalf("Packet of interface %d read from interface %d", data[0], ci.InterfaceIndex)
+		}
+		counts[ci.InterfaceIndex]++
+	}
+	for s := 1; s <= sources; s++ {
+		if counts[s] != packets {
+			t.Errorf("Read %d packets of interface %d, want %d", counts[s], s, packets)
+		}
+	}
+}
+
 type ngDevNull struct{}
 
 func (w *ngDevNull) Write(p []byte) (n int, err error) {
@@ -9,6 +9,7 @@ package pcapgo
 import (
 	"errors"
 	"math"
+	"net"
 	"time"
 
 	"github.com/google/gopacket"
@@ -29,15 +30,21 @@ const (
 	ngVersionMinor = 0
 )
 
-type ngBlockType uint32
+// NgBlockType is the type of a pcapng block.
+type NgBlockType uint32
 
 const (
-	ngBlockTypeInterfaceDescriptor ngBlockType = 1          // Interface description block
-	ngBlockTypePacket              ngBlockType = 2          // Packet block (deprecated)
-	ngBlockTypeSimplePacket        ngBlockType = 3          // Simple packet block
-	ngBlockTypeInterfaceStatistics ngBlockType = 5          // Interface statistics block
-	ngBlockTypeEnhancedPacket      ngBlockType = 6          // Enhanced packet block
-	ngBlockTypeSectionHeader       ngBlockType = 0x0A0D0D0A // Section header block (same in both endians)
+	NgBlockTypeInterfaceDescriptor NgBlockType = 1          // Interface description block
+	NgBlockTypePacket              NgBlockType = 2          // Packet block (deprecated)
+	NgBlockTypeSimplePacket        NgBlockType = 3          // Simple packet block
+	NgBlockTypeNameResolution      NgBlockType = 4          // Name resolution block
+	NgBlockTypeInterfaceStatistics NgBlockType = 5          // Interface statistics block
+	NgBlockTypeEnhancedPacket      NgBlockType = 6          // Enhanced packet block
+	NgBlockTypeSystemdJournal      NgBlockType = 9          // systemd journal export block
+	NgBlockTypeDecryptionSecrets   NgBlockType = 0x0A       // Decryption secrets block
+	NgBlockTypeCustom              NgBlockType = 0x0BAD     // Custom block which may be copied
+	NgBlockTypeCustomNoCopy        NgBlockType = 0x40000BAD // Custom block which must not be copied
+	NgBlockTypeSectionHeader       NgBlockType = 0x0A0D0D0A // Section header block (same in both endians)
 )
 
 type ngOptionCode uint16
@@ -64,6 +71,7 @@ const (
 	ngOptionCodeInterfaceOS                                          // operating system
 	ngOptionCodeInterfaceFCSLength                                   // length of the Frame Check Sequence in bits
 	ngOptionCodeInterfaceTimestampOffset                             // offset (in seconds) that must be added to packet timestamp
+	ngOptionCodeInterfaceHardware                                    // description of the interface hardware
 )
 
 const (
@@ -76,6 +84,27 @@ const (
 	ngOptionCodeInterfaceStatisticsDelivered                                 // Packets delivered to user
 )
 
+const (
+	ngOptionCodePacketFlags     ngOptionCode = iota + 2 // link layer flags
+	ngOptionCodePacketHash                              // hash of the packet
+	ngOptionCodePacketDropCount                         // packets lost between this packet and the preceding one
+	ngOptionCodePacketID                                // unique identifier of the packet
+	ngOptionCodePacketQueue                             // queue of the interface the packet was received on
+)
+
+const (
+	ngNameResolutionEnd  = 0 // end of records
+	ngNameResolutionIPv4 = 1 // IPv4 address and names
+	ngNameResolutionIPv6 = 2 // IPv6 address and names
+)
+
+// NgOption is a pcapng option, as read from a file. Code identifies the
+// option among those of its block.
+type NgOption struct {
+	Code  uint16
+	Value []byte
+}
+
 // ngOption is a pcapng option
 type ngOption struct {
 	code   ngOptionCode
@@ -86,7 +115,7 @@ type ngOption struct {
 
 // ngBlock is a pcapng block header
 type ngBlock struct {
-	typ    ngBlockType
+	typ    NgBlockType
 	length uint32 // remaining length of block
 }
 
@@ -134,11 +163,20 @@ type NgInterfaceStatistics struct {
 	PacketsReceived uint64
 	// PacketsReceived are the number of received packets. This value might be NoValue64 if this option is missing.
 	PacketsDropped uint64
+	// FilterAccepted, OSDropped and Delivered are the numbers of packets accepted by the capture filter, dropped by the operating system and delivered to the capturing application. These values might be NgNoValue64 if their options are missing. NgWriter.WriteInterfaceStats writes them only if they are neither zero nor NgNoValue64, so that statistics which do not set them write none.
+	FilterAccepted uint64
+	OSDropped      uint64
+	Delivered      uint64
+	// Options holds the options the reader does not decode into the fields above.
+	Options []NgOption
 }
 
 var ngEmptyStatistics = NgInterfaceStatistics{
 	PacketsReceived: NgNoValue64,
 	PacketsDropped:  NgNoValue64,
+	FilterAccepted:  NgNoValue64,
+	OSDropped:       NgNoValue64,
+	Delivered:       NgNoValue64,
 }
 
 // NgInterface holds all the information of a pcapng interface.
@@ -163,10 +201,22 @@ type NgInterface struct {
 	SnapLength uint32
 	// Statistics holds the interface statistics
 	Statistics NgInterfaceStatistics
+	// Hardware describes the hardware of the interface. This value might be empty if this option is missing.
+	Hardware string
+	// IPv4Addresses and IPv6Addresses are the network addresses of the interface. These values might be empty if these options are missing.
+	IPv4Addresses []net.IPNet
+	IPv6Addresses []net.IPNet
+	// MACAddress and EUIAddress are the hardware addresses of the interface. These values might be empty if these options are missing.
+	MACAddress net.HardwareAddr
+	EUIAddress net.HardwareAddr
+	// Speed is the speed of the interface in bits per second. This value might be 0 if this option is missing.
+	Speed uint64
+	// FCSLength is the length of the frame check sequence of the packets of the interface, in bits. This value might be 0 if this option is missing.
+	FCSLength uint8
+	// Options holds the options the reader does not decode into the fields above. NgWriter does not write them.
+	Options []NgOption
 
 	secondMask uint64
-	scaleUp    uint64
-	scaleDown  uint64
 }
 
 // Resolution returns the timestamp resolution of acquired timestamps before scaling to NanosecondTimestampResolution.
@@ -184,4 +234,42 @@ type NgSectionInfo struct {
 	Application string
 	// Comment can be an arbitrary comment. This value might be empty if this option is missing.
 	Comment string
+	// Options holds the options the reader does not decode into the fields above.
+	Options []NgOption
+}
+
+// NgPacketInfo holds the options of a packet block, along with the section and interface of the packet.
+type NgPacketInfo struct {
+	// Section and Interface are the information of the section of the packet and of the interface it was captured on, at the time it was read.
+	Section   NgSectionInfo
+	Interface NgInterface
+	// Comments are the comments of the packet. This value might be empty if these options are missing.
+	Comments []string
+	// Flags are the link layer flags of the packet, of which the lowest two bits tell its direction. This value might be 0 if this option is missing.
+	Flags uint32
+	// Hash is the hash of the packet, starting with the algorithm it was computed with. This value might be empty if this option is missing.
+	Hash []byte
+	// DropCount is the number of packets lost between this packet and the preceding one. This value might be NgNoValue64 if this option is missing.
+	DropCount uint64
+	// PacketID is the unique identifier of the packet. This value might be 0 if this option is missing.
+	PacketID uint64
+	// Queue is the queue of the interface the packet was received on. This value might be 0 if this option is missing.
+	Queue uint32
+	// Options holds the options the reader does not decode into the fields above.
+	Options []NgOption
+}
+
+// NgNameRecord is a record of a name resolution block: an address and its names.
+type NgNameRecord struct {
+	Address net.IP
+	Names   []string
+}
+
+// NgNameResolution holds the content of a name resolution block.
+type NgNameResolution struct {
+	Records []NgNameRecord
+	// Comment can be an arbitrary comment. This value might be empty if this option is missing.
+	Comment string
+	// Options holds the other options of the block, such as the name and addresses of the DNS server the names come from.
+	Options []NgOption
 }
@@ -14,10 +14,10 @@ import (
 	"time"
 
 	"bufio"
-	"compress/gzip"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
 )
 
 // Reader wraps an underlying io.Reader to read packet data in PCAP
@@ -28,7 +28,9 @@ import (
 // timestamp resolution in little-endian and big-endian encoding.
 //
 // If the PCAP data is gzip compressed it is transparently uncompressed
-// by wrapping the given io.Reader with a gzip.Reader.
+// by wrapping the given io.Reader with a gzip.Reader. Other compression
+// formats are uncompressed with the decompressors registered with
+// RegisterDecompressor.
 type Reader struct {
 	r              io.Reader
 	byteOrder      binary.ByteOrder
@@ -43,6 +45,12 @@ type Reader struct {
 	buf [16]byte
 	// buffer for ZeroCopyReadPacketData
 	packetBuf []byte
+	// filter set by SetBPF
+	filter *bpf.VM
+	// timestamp correction set by SetTimeCorrection
+	timeCorrection TimeCorrection
+	// mapped is the file read by an MmapReader, which r also reads from.
+	mapped *mmapReader
 }
 
 const magicNanoseconds = 0xA1B23C4D
@@ -71,26 +79,22 @@ func NewReader(r io.Reader) (*Reader, error) {
 }
 
 func (r *Reader) readHeader() error {
-	br := bufio.NewReader(r.r)
-	gzipMagic, err := br.Peek(2)
-	if err != nil {
+	var err error
+	if r.r, err = decompress(bufio.NewReader(r.r)); err != nil {
 		return err
 	}
 
-	if gzipMagic[0] == magicGzip1 && gzipMagic[1] == magicGzip2 {
-		if r.r, err = gzip.NewReader(br); err != nil {
-			return err
-		}
-	} else {
-		r.r = br
-	}
-
 	buf := make([]byte, 24)
 	if n, err := io.ReadFull(r.r, buf); err != nil {
 		return err
 	} else if n < 24 {
 		return errors.New("Not enough data for read")
 	}
+	return r.parseHeader(buf)
+}
+
+// parseHeader parses the 24 byte file header in buf.
+func (r *Reader) parseHeader(buf []byte) error {
 	if magic := binary.LittleEndian.Uint32(buf[0:4]); magic == magicNanoseconds {
 		r.byteOrder = binary.LittleEndian
 		r.nanoSecsFactor = 1
@@ -120,6 +124,12 @@ func (r *Reader) readHeader() error {
 
 // ReadPacketData reads next packet from file.
 func (r *Reader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	if r.filter != nil {
+		if data, ci, err = r.ZeroCopyReadPacketData(); err != nil {
+			return
+		}
+		return append([]byte(nil), data...), ci, nil
+	}
 	if ci, err = r.readPacketHeader(); err != nil {
 		return
 	}
@@ -142,6 +152,16 @@ func (r *Reader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err err
 // It is not true zero copy, as data is still copied from the underlying reader. However,
 // this method avoids allocating heap memory for every packet.
 func (r *Reader) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	for {
+		data, ci, err = r.readPacket()
+		if err != nil || r.filter == nil || matches(r.filter, data) {
+			return
+		}
+	}
+}
+
+// readPacket reads the next packet into packetBuf.
+func (r *Reader) readPacket() (data []byte, ci gopacket.CaptureInfo, err error) {
 	if ci, err = r.readPacketHeader(); err != nil {
 		return
 	}
@@ -154,6 +174,10 @@ func (r *Reader) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo,
 		return
 	}
 
+	if r.mapped != nil {
+		data, err = r.mapped.next(ci.CaptureLength)
+		return data, ci, err
+	}
 	if cap(r.packetBuf) < ci.CaptureLength {
 		snaplen := int(r.snaplen)
 		if snaplen < ci.CaptureLength {
@@ -170,7 +194,9 @@ func (r *Reader) readPacketHeader() (ci gopacket.CaptureInfo, err error) {
 	if _, err = io.ReadFull(r.r, r.buf[:]); err != nil {
 		return
 	}
-	ci.Timestamp = time.Unix(int64(r.byteOrder.Uint32(r.buf[0:4])), int64(r.byteOrder.Uint32(r.buf[4:8])*r.nanoSecsFactor)).UTC()
+	// scale in 64 bits, so that out of range fractions of microsecond files don't wrap around
+	ci.Timestamp = time.Unix(int64(r.byteOrder.Uint32(r.buf[0:4])), int64(r.byteOrder.Uint32(r.buf[4:8]))*int64(r.nanoSecsFactor)).UTC()
+	ci.Timestamp = r.timeCorrection.correct(ci.Timestamp)
 	ci.CaptureLength = int(r.byteOrder.Uint32(r.buf[8:12]))
 	ci.Length = int(r.byteOrder.Uint32(r.buf[12:16]))
 	return
@@ -225,7 +251,7 @@ func (r *Reader) String() string {
 // Resolution returns the timestamp resolution of acquired timestamps before scaling to NanosecondTimestampResolution.
 func (r *Reader) Resolution() gopacket.TimestampResolution {
 	if r.nanoSecsFactor == 1 {
-		return gopacket.TimestampResolutionMicrosecond
+		return gopacket.TimestampResolutionNanosecond
 	}
-	return gopacket.TimestampResolutionNanosecond
+	return gopacket.TimestampResolutionMicrosecond
 }
@@ -9,6 +9,8 @@ import (
 	"bytes"
 	"testing"
 	"time"
+
+	"github.com/google/gopacket"
 )
 
 // test header read
@@ -81,6 +83,9 @@ func TestPacket(t *testing.T) {
 		t.Error("Invalid time read")
 		t.FailNow()
 	}
+	if r.Resolution() != gopacket.TimestampResolutionMicrosecond {
+		t.Errorf("Invalid resolution %v", r.Resolution())
+	}
 	if ci.CaptureLength != 4 || ci.Length != 8 {
 		t.Error("Invalid CapLen or Len")
 	}
@@ -115,6 +120,9 @@ func TestPacketNano(t *testing.T) {
 		t.Error("Invalid time read")
 		t.FailNow()
 	}
+	if r.Resolution() != gopacket.TimestampResolutionNanosecond {
+		t.Errorf("Invalid resolution %v", r.Resolution())
+	}
 	if ci.CaptureLength != 4 || ci.Length != 8 {
 		t.Error("Invalid CapLen or Len")
 	}
@@ -124,6 +132,32 @@ func TestPacketNano(t *testing.T) {
 	}
 }
 
+func TestPacketNanoBigEndian(t *testing.T) {
+	test := []byte{
+		0xa1, 0xb2, 0x3c, 0x4d, 0x00, 0x02, 0x00, 0x04, // magic, maj, min
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // tz, sigfigs
+		0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x01, // snaplen, linkType
+		0x54, 0x1A, 0xCC, 0x5A, 0x3B, 0x9A, 0xC9, 0xFF, // sec, nsec
+		0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x08, // cap len, full len
+		0x01, 0x02, 0x03, 0x04, // data
+	}
+
+	r, err := NewReader(bytes.NewBuffer(test))
+	if err != nil {
+		t.Fatalf("Failed to get new reader object: %v", err)
+	}
+	_, ci, err := r.ReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ci.Timestamp.Equal(time.Date(2014, 9, 18, 12, 13, 14, 999999999, time.UTC)) {
+		t.Errorf("Invalid time read: %v", ci.Timestamp)
+	}
+	if r.Resolution() != gopacket.TimestampResolutionNanosecond {
+		t.Errorf("Invalid resolution %v", r.Resolution())
+	}
+}
+
 func TestGzipPacket(t *testing.T) {
 	test := []byte{
 		0x1f, 0x8b, 0x08, 0x08, 0x92, 0x4d, 0x81, 0x57,
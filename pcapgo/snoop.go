@@ -27,6 +27,7 @@ const unknownVersion = "Unknown Snoop Format Version"
 const unkownLinkType = "Unknown Link Type"
 const originalLenExceeded = "Capture length exceeds original packet length"
 const captureLenExceeded = "Capture length exceeds max capture length"
+const recordLenTooShort = "Record length too short for packet data"
 
 type snoopHeader struct {
 	Version  uint32
@@ -54,8 +55,11 @@ var (
 		4: layers.LinkTypeEthernet,  // Ethernet
 		5: layers.LinkTypeC_HDLC,    // HDLC
 		8: layers.LinkTypeFDDI,      // FDDI
+		// Solaris extensions
+		0x10: layers.LinkTypeIPOverFC, // Fibre Channel
+		0x12: layers.LinkTypeSunATM,   // ATM, with the same header as SunATM
 		/*
-			10 - 4294967295 Unassigned
+			10 - 4294967295 Unassigned, except for the Solaris extensions above
 			not supported:
 			1 - IEEE 802.4 Token Bus
 			3 - IEEE 802.6 Metro Net
@@ -106,7 +110,8 @@ func (r *SnoopReader) readHeader() error {
 		return fmt.Errorf("%s: %d", unknownVersion, r.header.Version)
 	}
 
-	if r.header.linkType = binary.BigEndian.Uint32(buf[12:16]); r.header.linkType > 10 {
+	r.header.linkType = binary.BigEndian.Uint32(buf[12:16])
+	if _, ok := layerTypes[r.header.linkType]; !ok && r.header.linkType > 10 {
 		return fmt.Errorf("%s, Code:%d", unkownLinkType, r.header.linkType)
 	}
 	return nil
@@ -127,7 +132,8 @@ func (r *SnoopReader) readPacketHeader() (ci gopacket.CaptureInfo, err error) {
 	ci.Timestamp = time.Unix(int64(binary.BigEndian.Uint32(r.buf[16:20])), int64(binary.BigEndian.Uint32(r.buf[20:24])*1000)).UTC()
 	ci.Length = int(binary.BigEndian.Uint32(r.buf[0:4]))
 	ci.CaptureLength = int(binary.BigEndian.Uint32(r.buf[4:8]))
-	r.pad = int(binary.BigEndian.Uint32(r.buf[8:12])) - (24 + ci.Length)
+	// records are padded after the included data, which is shorter than the packet if truncated
+	r.pad = int(binary.BigEndian.Uint32(r.buf[8:12])) - (24 + ci.CaptureLength)
 
 	if ci.CaptureLength > ci.Length {
 		err = errors.New(originalLenExceeded)
@@ -136,6 +142,11 @@ func (r *SnoopReader) readPacketHeader() (ci gopacket.CaptureInfo, err error) {
 
 	if ci.CaptureLength > maxCaptureLen {
 		err = errors.New(captureLenExceeded)
+		return
+	}
+
+	if r.pad < 0 {
+		err = errors.New(recordLenTooShort)
 	}
 
 	return
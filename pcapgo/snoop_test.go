@@ -11,6 +11,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/google/gopacket/layers"
 )
 
 var (
@@ -140,6 +142,44 @@ func TestBigPacketData(t *testing.T) {
 	buf[18] = 0x00
 }
 
+func TestTruncatedPacket(t *testing.T) {
+	buf := append([]byte(nil), spHeader...)
+	// 42 byte packet truncated to 10 bytes, padded to 36 bytes
+	buf = append(buf, 0x00, 0x00, 0x00, 0x2A, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x00, 0x00, 0x24, 0x00, 0x00, 0x00, 0x00, 0x5C, 0xBE, 0xB8, 0x4C, 0x00, 0x0C, 0xB1, 0x47)
+	buf = append(buf, pack[24:34]...)
+	buf = append(buf, 0x00, 0x00)
+	buf = append(buf, pack...)
+	handle, err := NewSnoopReader(bytes.NewReader(buf))
+	equalNil(t, err)
+	data, ci, err := handle.ReadPacketData()
+	equalNil(t, err)
+	equal(t, ci.CaptureLength, 10)
+	equal(t, ci.Length, 42)
+	equal(t, data, pack[24:34])
+	data, _, err = handle.ReadPacketData()
+	equalNil(t, err)
+	equal(t, data, pack[24:66])
+}
+
+func TestShortRecord(t *testing.T) {
+	buf, handle, err := OpenHandlePack()
+	equalNil(t, err)
+	buf[27] = 0x40 // record length 64 for 42 bytes of data
+	_, _, err = handle.ReadPacketData()
+	equalError(t, err, fmt.Errorf(recordLenTooShort))
+	buf[27] = 0x44
+}
+
+func TestSolarisLinkType(t *testing.T) {
+	buf := append([]byte(nil), spHeader...)
+	buf[15] = 0x10
+	handle, err := NewSnoopReader(bytes.NewReader(buf))
+	equalNil(t, err)
+	lt, err := handle.LinkType()
+	equalNil(t, err)
+	equal(t, *lt, layers.LinkTypeIPOverFC)
+}
+
 func TestLinkType(t *testing.T) {
 	_, handle, err := OpenHandlePack()
 	equalNil(t, err)
@@ -0,0 +1,73 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"time"
+)
+
+// TimeCorrection corrects the timestamps of packets captured by a host
+// whose clock is skewed, so that captures from several hosts can be merged
+// and correlated. A timestamp t is corrected to
+//
+//	t + Offset + Drift * (t - Reference)
+//
+// The zero TimeCorrection leaves timestamps unchanged.
+type TimeCorrection struct {
+	// Offset is added to all timestamps.
+	Offset time.Duration
+	// Drift is the time added to timestamps for every second elapsed
+	// since Reference, so a capturing clock running 20 ppm fast is
+	// corrected by a Drift of -20e-6.
+	Drift float64
+	// Reference is the time at which the drift is zero, in the time of the
+	// capturing clock. If it is zero, the timestamp of the first packet
+	// read is used.
+	Reference time.Time
+}
+
+// IsZero reports whether c leaves timestamps unchanged.
+func (c *TimeCorrection) IsZero() bool {
+	return c.Offset == 0 && c.Drift == 0
+}
+
+// correct returns the corrected timestamp t. Zero timestamps, of packets
+// without one, are left unchanged.
+func (c *TimeCorrection) correct(t time.Time) time.Time {
+	if c.IsZero() || t.IsZero() {
+		return t
+	}
+	if c.Drift != 0 {
+		if c.Reference.IsZero() {
+			c.Reference = t
+		}
+		t = t.Add(time.Duration(c.Drift * float64(t.Sub(c.Reference))))
+	}
+	return t.Add(c.Offset).UTC()
+}
+
+// SetTimeCorrection sets the correction applied to the timestamps of the
+// packets read next.
+func (r *Reader) SetTimeCorrection(c TimeCorrection) {
+	r.timeCorrection = c
+}
+
+// SetTimeCorrection sets the correction applied to the timestamps of the
+// packets read next, replacing the one given in the options.
+func (r *NgReader) SetTimeCorrection(c TimeCorrection) {
+	r.options.TimeCorrection = c
+}
+
+// SetTimeCorrection sets the correction applied to the timestamps of the
+// packets read next, replacing the one given in the options.
+func (r *MmapReader) SetTimeCorrection(c TimeCorrection) {
+	if r.ng != nil {
+		r.ng.SetTimeCorrection(c)
+		return
+	}
+	r.pcap.SetTimeCorrection(c)
+}
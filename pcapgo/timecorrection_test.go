@@ -0,0 +1,105 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var timeCorrectionStart = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var timeCorrectionPackets = []gopacket.CaptureInfo{
+	{Timestamp: timeCorrectionStart, CaptureLength: 4, Length: 4},
+	{Timestamp: timeCorrectionStart.Add(100 * time.Second), CaptureLength: 4, Length: 4},
+}
+
+func checkTimeCorrection(t *testing.T, read func() ([]byte, gopacket.CaptureInfo, error), want []time.Time) {
+	packets, err := readAll(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != len(want) {
+		t.Fatalf("read %d packets, want %d", len(packets), len(want))
+	}
+	for i, p := range packets {
+		if !p.ci.Timestamp.Equal(want[i]) {
+			t.Errorf("packet %d: timestamp %v, want %v", i, p.ci.Timestamp, want[i])
+		}
+	}
+}
+
+func TestReaderTimeCorrection(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterNanos(&buf)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range timeCorrectionPackets {
+		if err := w.WritePacket(ci, []byte{1, 2, 3, 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The clock runs 1 ms per second fast and 1 s ahead.
+	r.SetTimeCorrection(TimeCorrection{Offset: -time.Second, Drift: -1e-3})
+	checkTimeCorrection(t, r.ReadPacketData, []time.Time{
+		timeCorrectionStart.Add(-time.Second),
+		timeCorrectionStart.Add(100*time.Second - 100*time.Millisecond - time.Second),
+	})
+}
+
+func TestNgReaderTimeCorrection(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewNgWriter(&buf, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range timeCorrectionPackets {
+		if err := w.WritePacket(ci, []byte{1, 2, 3, 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultNgReaderOptions
+	options.TimeCorrection = TimeCorrection{
+		Offset:    time.Minute,
+		Drift:     2e-3,
+		Reference: timeCorrectionStart.Add(50 * time.Second),
+	}
+	r, err := NewNgReader(&buf, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTimeCorrection(t, r.ReadPacketData, []time.Time{
+		timeCorrectionStart.Add(time.Minute - 100*time.Millisecond),
+		timeCorrectionStart.Add(100*time.Second + time.Minute + 100*time.Millisecond),
+	})
+}
+
+func TestTimeCorrectionZero(t *testing.T) {
+	var c TimeCorrection
+	if !c.IsZero() {
+		t.Error("zero TimeCorrection is not zero")
+	}
+	if got := c.correct(timeCorrectionStart); !got.Equal(timeCorrectionStart) {
+		t.Errorf("zero TimeCorrection changed %v to %v", timeCorrectionStart, got)
+	}
+	c.Offset = time.Second
+	if got := c.correct(time.Time{}); !got.IsZero() {
+		t.Errorf("zero timestamp corrected to %v", got)
+	}
+}
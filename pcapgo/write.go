@@ -22,6 +22,9 @@ import (
 //
 // For those that care, we currently write v2.4 files with nanosecond
 // or microsecond timestamp resolution and little-endian encoding.
+//
+// To write a compressed file, wrap the underlying io.Writer, e.g. with
+// gzip.NewWriter, and close the wrapper after the last packet.
 type Writer struct {
 	w        io.Writer
 	tsScaler int
@@ -46,7 +49,7 @@ const versionMinor = 4
 //  f.Close()
 //  // Append to existing file (must have same snaplen and linktype)
 //  f2, _ := os.OpenFile("/tmp/fileNano.pcap", os.O_APPEND, 0700)
-//  w2 := pcapgo.NewWriter(f2)
+//  w2 := pcapgo.NewWriterNanos(f2)
 //  // no need for file header, it's already written.
 //  w2.WritePacket(gopacket.CaptureInfo{...}, data2)
 //  f2.Close()
@@ -98,6 +101,14 @@ func (w *Writer) WriteFileHeader(snaplen uint32, linktype layers.LinkType) error
 const nanosPerMicro = 1000
 const nanosPerNano = 1
 
+// Resolution returns the timestamp resolution packets are written with.
+func (w *Writer) Resolution() gopacket.TimestampResolution {
+	if w.tsScaler == nanosPerNano {
+		return gopacket.TimestampResolutionNanosecond
+	}
+	return gopacket.TimestampResolutionMicrosecond
+}
+
 func (w *Writer) writePacketHeader(ci gopacket.CaptureInfo) error {
 	t := ci.Timestamp
 	if t.IsZero() {
@@ -62,6 +62,46 @@ func TestWritePacket(t *testing.T) {
 	}
 }
 
+func TestWritePacketNanos(t *testing.T) {
+	ci := gopacket.CaptureInfo{
+		Timestamp:     time.Unix(0x01020304, 0x3B9AC9FF).UTC(),
+		Length:        0xABCD,
+		CaptureLength: 10,
+	}
+	data := []byte{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+	var buf bytes.Buffer
+	w := NewWriterNanos(&buf)
+	if err := w.WriteFileHeader(0xffff, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(ci, data); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x04, 0x03, 0x02, 0x01, 0xFF, 0xC9, 0x9A, 0x3B,
+		0x0A, 0x00, 0x00, 0x00, 0xCD, 0xAB, 0x00, 0x00,
+		0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00,
+	}
+	if got := buf.Bytes()[24:]; !bytes.Equal(got, want) {
+		t.Errorf("buf mismatch:\nwant: %+v\ngot:  %+v", want, got)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, gotCI, err := r.ReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotCI.Timestamp.Equal(ci.Timestamp) {
+		t.Errorf("timestamp mismatch: want %v, got %v", ci.Timestamp, gotCI.Timestamp)
+	}
+	if r.Resolution() != w.Resolution() {
+		t.Errorf("resolution mismatch: want %v, got %v", w.Resolution(), r.Resolution())
+	}
+}
+
 func BenchmarkWritePacket(b *testing.B) {
 	b.StopTimer()
 	ci := gopacket.CaptureInfo{
@@ -0,0 +1,16 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux,!go1.16
+
+package privdrop
+
+import "errors"
+
+// clearCapabilities needs syscall.AllThreadsSyscall, added in Go 1.16.
+func clearCapabilities() error {
+	return errors.New("privdrop: clearing capabilities requires Go 1.16, switch to an unprivileged user instead")
+}
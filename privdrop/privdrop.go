@@ -0,0 +1,63 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package privdrop gives up the privileges of a packet capture process once
+// its capture handles are open, so that bugs in the code decoding untrusted
+// packets cannot be used to take over a privileged process.
+//
+// Opening a capture handle requires CAP_NET_RAW (or root); reading from it
+// does not. Open all handles first, then call Drop:
+//
+//  handle, err := pcap.OpenLive("eth0", 65536, true, pcap.BlockForever)
+//  if err != nil {
+//  	log.Fatal(err)
+//  }
+//  err = privdrop.Drop(privdrop.Config{
+//  	User:    "nobody",
+//  	Chroot:  "/var/empty",
+//  	Seccomp: privdrop.CapturePolicy(),
+//  })
+//  if err != nil {
+//  	log.Fatal(err)
+//  }
+//  // Decode packets from handle.
+//
+// Drop only supports Linux. It changes the root directory, switches group
+// and user, clears all capabilities, sets no_new_privs, and finally
+// installs a seccomp filter, in that order. Each step applies to all
+// threads of the process. Operations needing privileges, such as changing
+// the BPF filter of a pcap handle, fail afterwards; changing the filter of
+// a socket the process already owns keeps working.
+package privdrop
+
+import "syscall"
+
+// Config selects the privileges Drop gives up.
+type Config struct {
+	// User is the name or numeric ID of the user to switch to. If empty,
+	// the user is not changed.
+	User string
+	// Group is the name or numeric ID of the group to switch to. If empty,
+	// the primary group of User is used, or the group is not changed if
+	// User is empty too. Supplementary groups are set to those of User,
+	// or cleared if only Group is given.
+	Group string
+	// Chroot is the directory to change the root directory to. If empty,
+	// the root directory is not changed.
+	Chroot string
+	// Seccomp, if not nil, restricts the system calls of the process.
+	Seccomp *SeccompPolicy
+}
+
+// SeccompPolicy is a seccomp system call allowlist.
+type SeccompPolicy struct {
+	// Allow holds the numbers of the allowed system calls for the running
+	// architecture, such as unix.SYS_READ.
+	Allow []uintptr
+	// Errno is the error returned by denied system calls. If zero, a
+	// denied system call kills the process instead.
+	Errno syscall.Errno
+}
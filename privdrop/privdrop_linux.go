@@ -0,0 +1,145 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux
+
+package privdrop
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Drop gives up the privileges selected by cfg. If it fails the process
+// may be left with only some privileges dropped, and should exit.
+func Drop(cfg Config) error {
+	uid, gid, groups, err := lookupIDs(cfg.User, cfg.Group)
+	if err != nil {
+		return err
+	}
+	if cfg.Chroot != "" {
+		if err := syscall.Chroot(cfg.Chroot); err != nil {
+			return fmt.Errorf("privdrop: chroot to %s: %v", cfg.Chroot, err)
+		}
+		if err := syscall.Chdir("/"); err != nil {
+			return fmt.Errorf("privdrop: chdir after chroot: %v", err)
+		}
+	}
+	// The syscall package applies these to all threads.
+	if gid >= 0 {
+		if err := syscall.Setgroups(groups); err != nil {
+			return fmt.Errorf("privdrop: setgroups: %v", err)
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return fmt.Errorf("privdrop: setgid %d: %v", gid, err)
+		}
+	}
+	if uid >= 0 {
+		if err := syscall.Setuid(uid); err != nil {
+			return fmt.Errorf("privdrop: setuid %d: %v", uid, err)
+		}
+	}
+	if err := DropCapabilities(); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("privdrop: setting no_new_privs: %v", err)
+	}
+	if cfg.Seccomp != nil {
+		return cfg.Seccomp.Install()
+	}
+	return nil
+}
+
+// lookupIDs resolves the user and group of a Config. Negative IDs mean no
+// change.
+func lookupIDs(userName, groupName string) (uid, gid int, groups []int, err error) {
+	uid, gid = -1, -1
+	if userName != "" {
+		u, err := lookupUser(userName)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, nil, fmt.Errorf("privdrop: user %s has non-numeric ID %q", userName, u.Uid)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return 0, 0, nil, fmt.Errorf("privdrop: user %s has non-numeric group ID %q", userName, u.Gid)
+		}
+		ids, err := u.GroupIds()
+		if err != nil {
+			ids = []string{u.Gid}
+		}
+		for _, id := range ids {
+			if g, err := strconv.Atoi(id); err == nil {
+				groups = append(groups, g)
+			}
+		}
+	}
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, nil, fmt.Errorf("privdrop: group %s has non-numeric ID %q", groupName, g.Gid)
+		}
+		if userName == "" {
+			groups = nil
+		}
+	}
+	if groups == nil && gid >= 0 {
+		groups = []int{gid}
+	}
+	return uid, gid, groups, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		// Numeric IDs without a passwd entry are fine.
+		return &user.User{Uid: name, Gid: name, Username: name}, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("privdrop: %v", err)
+	}
+	return u, nil
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return &user.Group{Gid: name, Name: name}, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return nil, fmt.Errorf("privdrop: %v", err)
+	}
+	return g, nil
+}
+
+// DropCapabilities clears the capability sets of all threads of the
+// process. Switching from root to another user already does this.
+//
+// Binaries using cgo can only drop capabilities by switching user; for
+// them DropCapabilities fails if any capability is left.
+func DropCapabilities() error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("privdrop: capget: %v", err)
+	}
+	if data == [2]unix.CapUserData{} {
+		return nil
+	}
+	return clearCapabilities()
+}
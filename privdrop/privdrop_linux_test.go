@@ -0,0 +1,106 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package privdrop
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestLookupIDs(t *testing.T) {
+	uid, gid, groups, err := lookupIDs("", "")
+	if err != nil || uid != -1 || gid != -1 || groups != nil {
+		t.Errorf("got %d, %d, %v, %v for no change", uid, gid, groups, err)
+	}
+	uid, gid, groups, err = lookupIDs("65534", "")
+	if err != nil || uid != 65534 || gid < 0 || len(groups) == 0 {
+		t.Errorf("got %d, %d, %v, %v for a numeric user", uid, gid, groups, err)
+	}
+	uid, gid, groups, err = lookupIDs("", "1234")
+	if err != nil || uid != -1 || gid != 1234 || len(groups) != 1 || groups[0] != 1234 {
+		t.Errorf("got %d, %d, %v, %v for a numeric group", uid, gid, groups, err)
+	}
+	if _, _, _, err := lookupIDs("no-such-user-privdrop", ""); err == nil {
+		t.Error("unknown user resolved")
+	}
+}
+
+func TestSeccompAssemble(t *testing.T) {
+	if auditArch == 0 {
+		t.Skip("seccomp is not supported on", runtime.GOARCH)
+	}
+	p := &SeccompPolicy{Allow: []uintptr{unix.SYS_READ, unix.SYS_WRITE}, Errno: unix.EPERM}
+	raw, err := p.Assemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insts, ok := bpf.Disassemble(raw)
+	if !ok {
+		t.Fatal("program does not disassemble")
+	}
+	vm, err := bpf.NewVM(insts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		nr, arch uint32
+		want     uint32
+	}{
+		{unix.SYS_READ, auditArch, seccompRetAllow},
+		{unix.SYS_WRITE, auditArch, seccompRetAllow},
+		{unix.SYS_OPENAT, auditArch, seccompRetErrno | uint32(unix.EPERM)},
+		{unix.SYS_READ, 0x40000003, seccompRetKillProcess},
+	} {
+		// struct seccomp_data is in host byte order; the VM loads big
+		// endian words.
+		data := make([]byte, 64)
+		binary.BigEndian.PutUint32(data[seccompDataNr:], test.nr)
+		binary.BigEndian.PutUint32(data[seccompDataArch:], test.arch)
+		got, err := vm.Run(data)
+		if err != nil || uint32(got) != test.want {
+			t.Errorf("syscall %d, arch %#x: got %#x, %v, want %#x", test.nr, test.arch, got, err, test.want)
+		}
+	}
+}
+
+// TestSeccompInstall installs CapturePolicy in a child process, which
+// then checks that opening files fails.
+func TestSeccompInstall(t *testing.T) {
+	if auditArch == 0 {
+		t.Skip("seccomp is not supported on", runtime.GOARCH)
+	}
+	if os.Getenv("PRIVDROP_TEST_CHILD") == "1" {
+		if err := CapturePolicy().Install(); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		go func() {
+			runtime.GC()
+			close(done)
+		}()
+		<-done
+		if _, err := os.Open("/"); !os.IsPermission(err) {
+			t.Fatalf("got error %v opening a file", err)
+		}
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeccompInstall$")
+	cmd.Env = append(os.Environ(), "PRIVDROP_TEST_CHILD=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && ee.Sys().(syscall.WaitStatus).Signaled() {
+			t.Fatalf("child was killed: %v\n%s", err, out)
+		}
+		t.Fatalf("child failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build !linux
+
+package privdrop
+
+import "errors"
+
+// Drop is only supported on Linux.
+func Drop(cfg Config) error {
+	return errors.New("privdrop: dropping privileges is only supported on Linux")
+}
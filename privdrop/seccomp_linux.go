@@ -0,0 +1,102 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux
+
+package privdrop
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Seccomp constants from linux/seccomp.h.
+const (
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// Offsets into struct seccomp_data.
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+// CapturePolicy returns a policy allowing the system calls made by the Go
+// runtime and by reading packets from open pcap, afpacket or pfring
+// handles. Opening files is not allowed; append unix.SYS_OPENAT to Allow to
+// write captures to disk.
+func CapturePolicy() *SeccompPolicy {
+	return &SeccompPolicy{Allow: append([]uintptr(nil), captureSyscalls...), Errno: unix.EPERM}
+}
+
+// Assemble returns the BPF program implementing p. It fails on
+// architectures other than amd64 and arm64.
+func (p *SeccompPolicy) Assemble() ([]bpf.RawInstruction, error) {
+	if auditArch == 0 {
+		return nil, errors.New("privdrop: seccomp is not supported on this architecture")
+	}
+	deny := uint32(seccompRetKillProcess)
+	if p.Errno != 0 {
+		deny = seccompRetErrno | uint32(p.Errno)&0xffff
+	}
+	prog := []bpf.Instruction{
+		// System call numbers differ between architectures, so refuse
+		// calls made through another ABI.
+		bpf.LoadAbsolute{Off: seccompDataArch, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: auditArch, SkipTrue: 1},
+		bpf.RetConstant{Val: seccompRetKillProcess},
+		bpf.LoadAbsolute{Off: seccompDataNr, Size: 4},
+	}
+	if syscallABIBits != 0 {
+		prog = append(prog,
+			bpf.JumpIf{Cond: bpf.JumpBitsNotSet, Val: syscallABIBits, SkipTrue: 1},
+			bpf.RetConstant{Val: seccompRetKillProcess})
+	}
+	for _, nr := range p.Allow {
+		prog = append(prog,
+			bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(nr), SkipTrue: 1},
+			bpf.RetConstant{Val: seccompRetAllow})
+	}
+	prog = append(prog, bpf.RetConstant{Val: deny})
+	return bpf.Assemble(prog)
+}
+
+// Install sets no_new_privs and installs p for all threads of the process.
+// Policies can only be added: a system call is allowed only if all
+// installed policies allow it.
+func (p *SeccompPolicy) Install() error {
+	raw, err := p.Assemble()
+	if err != nil {
+		return err
+	}
+	if len(raw) > 0xffff {
+		return fmt.Errorf("privdrop: seccomp program too long (%d instructions)", len(raw))
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("privdrop: setting no_new_privs: %v", err)
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(raw)),
+		Filter: (*unix.SockFilter)(unsafe.Pointer(&raw[0])),
+	}
+	r, _, errno := unix.RawSyscall(unix.SYS_SECCOMP, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("privdrop: installing seccomp filter: %v", errno)
+	}
+	if r != 0 {
+		return fmt.Errorf("privdrop: seccomp filter not installed, thread %d could not be synchronized", r)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux,!amd64,!arm64
+
+package privdrop
+
+// Seccomp policies are not supported on this architecture.
+const (
+	auditArch      = 0
+	syscallABIBits = 0
+)
+
+var captureSyscalls []uintptr
@@ -0,0 +1,205 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package rss computes the Toeplitz hash used by network cards for Receive
+// Side Scaling, to predict the receive queue of packets and shard their
+// processing the same way the hardware does.
+//
+// A Hash is created from an RSS key and the packet types whose ports are
+// hashed, and hashes the addresses and ports of packets. An
+// IndirectionTable maps hashes to queues:
+//
+//	h := rss.New(rss.DefaultKey, rss.TypeTCPIPv4|rss.TypeTCPIPv6)
+//	table := rss.NewIndirectionTable(128, workers)
+//	for packet := range source.Packets() {
+//		if hash, ok := h.Packet(packet); ok {
+//			queues[table.Queue(hash)] <- packet
+//		}
+//	}
+//
+// With SymmetricKey, both directions of a connection hash to the same value.
+package rss
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// DefaultKey is the RSS key of the Microsoft RSS specification, which many
+// drivers use by default.
+var DefaultKey = []byte{
+	0x6d, 0x5a, 0x56, 0xda, 0x25, 0x5b, 0x0e, 0xc2,
+	0x41, 0x67, 0x25, 0x3d, 0x43, 0xa3, 0x8f, 0xb0,
+	0xd0, 0xca, 0x2b, 0xcb, 0xae, 0x7b, 0x30, 0xb4,
+	0x77, 0xcb, 0x2d, 0xa3, 0x80, 0x30, 0xf2, 0x0c,
+	0x6a, 0x42, 0xb7, 0x3b, 0xbe, 0xac, 0x01, 0xfa,
+}
+
+// SymmetricKey is an RSS key repeating 0x6d5a, which gives the same hash to
+// both directions of a connection, so both are received by the same queue.
+var SymmetricKey = []byte{
+	0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a,
+	0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a,
+	0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a,
+	0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a,
+	0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a, 0x6d, 0x5a,
+}
+
+// Type is a set of packet types whose ports are hashed along with their
+// addresses. The addresses of all IPv4 and IPv6 packets are hashed.
+type Type uint8
+
+// Packet types.
+const (
+	TypeTCPIPv4 Type = 1 << iota
+	TypeUDPIPv4
+	TypeTCPIPv6
+	TypeUDPIPv6
+
+	// TypeAll hashes the ports of all TCP and UDP packets.
+	TypeAll = TypeTCPIPv4 | TypeUDPIPv4 | TypeTCPIPv6 | TypeUDPIPv6
+)
+
+// maxInput is the length of the longest hash input, the addresses and ports
+// of an IPv6 packet.
+const maxInput = 2*16 + 2*2
+
+// Hash computes the Toeplitz hash with a given key. A Hash is safe for
+// concurrent use.
+type Hash struct {
+	types Type
+	// table holds for each byte of the input the hash of each of its
+	// values at that position.
+	table [][256]uint32
+}
+
+// New returns a Hash using key, hashing the ports of packets of types. The
+// key must be at least 4 bytes longer than the longest input hashed, that is
+// 40 bytes to hash IPv6 packets; shorter keys can only hash inputs of up to
+// len(key)-4 bytes.
+func New(key []byte, types Type) *Hash {
+	h := &Hash{types: types}
+	n := len(key) - 4
+	if n < 0 {
+		n = 0
+	}
+	if n > maxInput {
+		n = maxInput
+	}
+	h.table = make([][256]uint32, n)
+	for i := range h.table {
+		for bit := uint(0); bit < 8; bit++ {
+			// The 32 bits of the key starting at the bit of the input.
+			v := window(key, uint(i)*8+bit)
+			for b := range h.table[i] {
+				if b&(0x80>>bit) != 0 {
+					h.table[i][b] ^= v
+				}
+			}
+		}
+	}
+	return h
+}
+
+// window returns the 32 bits of key starting at bit offset.
+func window(key []byte, offset uint) uint32 {
+	var v uint64
+	for i := uint(0); i < 5; i++ {
+		v <<= 8
+		if j := offset/8 + i; j < uint(len(key)) {
+			v |= uint64(key[j])
+		}
+	}
+	return uint32(v >> (8 - offset%8))
+}
+
+// Sum returns the hash of input. It panics if input is longer than the key
+// of h allows.
+func (h *Hash) Sum(input []byte) uint32 {
+	if len(input) > len(h.table) {
+		panic(fmt.Sprintf("rss: input of %d bytes too long for key", len(input)))
+	}
+	var v uint32
+	for i, b := range input {
+		v ^= h.table[i][b]
+	}
+	return v
+}
+
+// Flows returns the hash of a packet with network and transport flows, as
+// returned by NetworkFlow and TransportFlow of its layers. The transport
+// flow is hashed if its type is enabled for h, and ignored otherwise; it
+// may be the zero Flow. Flows returns false if the network flow is not an
+// IPv4 or IPv6 flow.
+func (h *Hash) Flows(network, transport gopacket.Flow) (uint32, bool) {
+	var tcp, udp Type
+	switch network.EndpointType() {
+	case layers.EndpointIPv4:
+		tcp, udp = TypeTCPIPv4, TypeUDPIPv4
+	case layers.EndpointIPv6:
+		tcp, udp = TypeTCPIPv6, TypeUDPIPv6
+	default:
+		return 0, false
+	}
+	var buf [maxInput]byte
+	src, dst := network.Endpoints()
+	n := copy(buf[:], src.Raw())
+	n += copy(buf[n:], dst.Raw())
+	switch t := transport.EndpointType(); {
+	case t == layers.EndpointTCPPort && h.types&tcp != 0,
+		t == layers.EndpointUDPPort && h.types&udp != 0:
+		src, dst = transport.Endpoints()
+		n += copy(buf[n:], src.Raw())
+		n += copy(buf[n:], dst.Raw())
+	}
+	return h.Sum(buf[:n]), true
+}
+
+// Packet returns the hash of packet, which is false if it has no IPv4 or
+// IPv6 layer. The ports of fragments are not hashed, even for the first
+// fragment which has them, so that all fragments of a datagram hash to the
+// same value, as NICs do.
+func (h *Hash) Packet(packet gopacket.Packet) (uint32, bool) {
+	net := packet.NetworkLayer()
+	if net == nil {
+		return 0, false
+	}
+	var transport gopacket.Flow
+	if t := packet.TransportLayer(); t != nil && !fragment(packet) {
+		transport = t.TransportFlow()
+	}
+	return h.Flows(net.NetworkFlow(), transport)
+}
+
+// fragment reports whether packet is an IPv4 or IPv6 fragment.
+func fragment(packet gopacket.Packet) bool {
+	if ip, ok := packet.NetworkLayer().(*layers.IPv4); ok {
+		return ip.Flags&layers.IPv4MoreFragments != 0 || ip.FragOffset != 0
+	}
+	return packet.Layer(layers.LayerTypeIPv6Fragment) != nil
+}
+
+// IndirectionTable maps hashes to queues, indexing the table with the low
+// bits of the hash.
+type IndirectionTable []int
+
+// NewIndirectionTable returns a table of size entries, which should be a
+// power of two, spreading hashes over queues in a round robin, as drivers
+// do by default.
+func NewIndirectionTable(size, queues int) IndirectionTable {
+	t := make(IndirectionTable, size)
+	for i := range t {
+		t[i] = i % queues
+	}
+	return t
+}
+
+// Queue returns the queue of hash.
+func (t IndirectionTable) Queue(hash uint32) int {
+	return t[hash%uint32(len(t))]
+}
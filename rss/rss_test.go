@@ -0,0 +1,140 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package rss
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Verification suite of the Microsoft RSS specification.
+var rssTests = []struct {
+	src, dst         string
+	srcPort, dstPort uint16
+	ip, tcp          uint32
+}{
+	{"66.9.149.187", "161.142.100.80", 2794, 1766, 0x323e8fc2, 0x51ccc178},
+	{"199.92.111.2", "65.69.140.83", 14230, 4739, 0xd718262a, 0xc626b0ea},
+	{"24.19.198.95", "12.22.207.184", 12898, 38024, 0xd2d0a5de, 0x5c2b394a},
+	{"38.27.205.30", "209.142.163.6", 48228, 2217, 0x82989176, 0xafc7327f},
+	{"153.39.163.191", "202.188.127.2", 44251, 1303, 0x5d1809c5, 0x10e828a2},
+	{"3ffe:2501:200:1fff::7", "3ffe:2501:200:3::1", 2794, 1766, 0x2cc18cd5, 0x40207d3d},
+	{"3ffe:501:8::260:97ff:fe40:efab", "ff02::1", 14230, 4739, 0x0f0c461c, 0xdde51bbf},
+	{"3ffe:1900:4545:3:200:f8ff:fe21:67cf", "fe80::200:f8ff:fe21:67cf", 44251, 38024, 0x4b61e985, 0x02d1feef},
+}
+
+func testPacket(t *testing.T, src, dst string, srcPort, dstPort uint16) gopacket.Packet {
+	srcIP, dstIP := net.ParseIP(src), net.ParseIP(dst)
+	tcp := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort)}
+	var ip gopacket.SerializableLayer
+	eth := &layers.Ethernet{SrcMAC: make(net.HardwareAddr, 6), DstMAC: make(net.HardwareAddr, 6)}
+	if v4 := srcIP.To4(); v4 != nil {
+		eth.EthernetType = layers.EthernetTypeIPv4
+		ip4 := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: v4, DstIP: dstIP.To4()}
+		tcp.SetNetworkLayerForChecksum(ip4)
+		ip = ip4
+	} else {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolTCP, SrcIP: srcIP, DstIP: dstIP}
+		tcp.SetNetworkLayerForChecksum(ip6)
+		ip = ip6
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LinkTypeEthernet, gopacket.Default)
+}
+
+func TestVerificationSuite(t *testing.T) {
+	ipOnly := New(DefaultKey, 0)
+	withPorts := New(DefaultKey, TypeAll)
+	for _, test := range rssTests {
+		p := testPacket(t, test.src, test.dst, test.srcPort, test.dstPort)
+		if got, ok := ipOnly.Packet(p); !ok || got != test.ip {
+			t.Errorf("%s -> %s: IP hash %#08x, %v, want %#08x", test.src, test.dst, got, ok, test.ip)
+		}
+		if got, ok := withPorts.Packet(p); !ok || got != test.tcp {
+			t.Errorf("%s -> %s: TCP hash %#08x, %v, want %#08x", test.src, test.dst, got, ok, test.tcp)
+		}
+	}
+}
+
+func TestSymmetricKey(t *testing.T) {
+	h := New(SymmetricKey, TypeAll)
+	for _, test := range rssTests {
+		forward, _ := h.Packet(testPacket(t, test.src, test.dst, test.srcPort, test.dstPort))
+		reverse, _ := h.Packet(testPacket(t, test.dst, test.src, test.dstPort, test.srcPort))
+		if forward != reverse {
+			t.Errorf("%s -> %s: forward hash %#08x, reverse hash %#08x", test.src, test.dst, forward, reverse)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	// Ports of TCP over IPv6 are not hashed if only TCP over IPv4 is
+	// enabled.
+	h := New(DefaultKey, TypeTCPIPv4)
+	test := rssTests[5]
+	if got, _ := h.Packet(testPacket(t, test.src, test.dst, test.srcPort, test.dstPort)); got != test.ip {
+		t.Errorf("got hash %#08x, want %#08x", got, test.ip)
+	}
+	if _, ok := h.Flows(gopacket.Flow{}, gopacket.Flow{}); ok {
+		t.Error("hashed a flow without addresses")
+	}
+}
+
+func TestFragments(t *testing.T) {
+	h := New(DefaultKey, TypeAll)
+	test := rssTests[0]
+	tcp := &layers.TCP{SrcPort: layers.TCPPort(test.srcPort), DstPort: layers.TCPPort(test.dstPort)}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, Flags: layers.IPv4MoreFragments,
+		SrcIP: net.ParseIP(test.src).To4(), DstIP: net.ParseIP(test.dst).To4()}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip, tcp); err != nil {
+		t.Fatal(err)
+	}
+	// The first fragment, which holds the ports, hashes as the others.
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	if got, ok := h.Packet(p); !ok || got != test.ip {
+		t.Errorf("got hash %#08x, %v, want %#08x", got, ok, test.ip)
+	}
+}
+
+func TestShortKey(t *testing.T) {
+	h := New(DefaultKey[:16], TypeAll)
+	if got, want := h.Sum([]byte{66, 9, 149, 187, 161, 142, 100, 80}), uint32(0x323e8fc2); got != want {
+		t.Errorf("got hash %#08x, want %#08x", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("hashing an input too long for the key did not panic")
+		}
+	}()
+	h.Sum(make([]byte, 13))
+}
+
+func TestIndirectionTable(t *testing.T) {
+	table := NewIndirectionTable(128, 3)
+	for hash, want := range map[uint32]int{0: 0, 1: 1, 2: 2, 3: 0, 127: 1, 128: 0, 0x51ccc178: 0x78 % 3} {
+		if got := table.Queue(hash); got != want {
+			t.Errorf("queue of %#x: got %d, want %d", hash, got, want)
+		}
+	}
+}
+
+func BenchmarkSum(b *testing.B) {
+	h := New(DefaultKey, TypeAll)
+	input := make([]byte, maxInput)
+	for i := 0; i < b.N; i++ {
+		h.Sum(input)
+	}
+}
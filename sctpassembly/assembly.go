@@ -0,0 +1,792 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package sctpassembly tracks SCTP associations and reassembles the user
+// messages they carry, as tcpassembly does for TCP streams.
+//
+// An Assembler follows the four way handshake (INIT, INIT ACK, COOKIE ECHO,
+// COOKIE ACK), the verification tags each end expects, and the shutdown or
+// abort of each association. Packets whose verification tag does not match
+// the association are ignored, as the peer would. Fragmented user messages
+// are reassembled, duplicate DATA chunks of retransmissions dropped, ordered
+// messages put back in the sequence of their stream, and complete messages
+// handed to a per-association AssociationHandler, created
+// by an AssociationFactory, which can hold the state of upper layer decoders
+// such as Diameter or S1AP:
+//
+//	assembler := sctpassembly.NewAssembler(factory)
+//	for packet := range source.Packets() {
+//		assembler.AssemblePacket(packet)
+//	}
+//	assembler.FlushAll()
+//
+// Colliding INITs sent by both ends at once, and restarts of an
+// association by a new handshake, are handled as RFC 4960 specifies.
+// Associations whose handshake was not captured are picked up from their
+// first packet. Messages abandoned by partially reliable senders, with a
+// FORWARD TSN chunk (RFC 3758), are skipped. Each address pair of a multi-homed association is tracked
+// as a separate association.
+//
+// An Assembler is not safe for concurrent use.
+package sctpassembly
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// State is the state of an association as seen by an observer.
+type State uint8
+
+// Association states.
+const (
+	// StateInit is the state after an INIT.
+	StateInit State = iota
+	// StateInitAck is the state after an INIT ACK.
+	StateInitAck
+	// StateCookieEchoed is the state after a COOKIE ECHO, in which the
+	// association may already carry data.
+	StateCookieEchoed
+	StateEstablished
+	// StateShutdown is the state after a SHUTDOWN.
+	StateShutdown
+	// StateShutdownAck is the state after a SHUTDOWN ACK.
+	StateShutdownAck
+	// StateClosed is the state of associations which were shut down or
+	// aborted.
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateInit:
+		return "Init"
+	case StateInitAck:
+		return "InitAck"
+	case StateCookieEchoed:
+		return "CookieEchoed"
+	case StateEstablished:
+		return "Established"
+	case StateShutdown:
+		return "Shutdown"
+	case StateShutdownAck:
+		return "ShutdownAck"
+	case StateClosed:
+		return "Closed"
+	}
+	return fmt.Sprintf("State(%d)", uint8(s))
+}
+
+// CloseReason tells why an association ended.
+type CloseReason uint8
+
+// Reasons for closing associations.
+const (
+	// CloseShutdown is the graceful end of an association, by a SHUTDOWN
+	// COMPLETE.
+	CloseShutdown CloseReason = iota
+	// CloseAbort is the end of an association by an ABORT.
+	CloseAbort
+	// CloseRestart is the end of an association restarted by a new
+	// handshake, which continues as a new association.
+	CloseRestart
+	// CloseFlush is the end of an association flushed by the Assembler.
+	CloseFlush
+)
+
+func (r CloseReason) String() string {
+	switch r {
+	case CloseShutdown:
+		return "Shutdown"
+	case CloseAbort:
+		return "Abort"
+	case CloseRestart:
+		return "Restart"
+	case CloseFlush:
+		return "Flush"
+	}
+	return fmt.Sprintf("CloseReason(%d)", uint8(r))
+}
+
+// Association describes an SCTP association. Its fields are updated by the
+// Assembler and must not be modified.
+type Association struct {
+	// Net and Transport are the flows from the initiator of the
+	// association to the responder. The initiator is the sender of the
+	// INIT, or, if it was not captured, the sender of the first packet.
+	Net, Transport gopacket.Flow
+	State          State
+	// InitiatorTag and ResponderTag are the verification tags of the
+	// initiator and responder, carried by the packets sent to them.
+	InitiatorTag, ResponderTag uint32
+	// Collision is set if both ends sent an INIT.
+	Collision bool
+	// Start is the time of the first packet, LastSeen the time of the
+	// last one.
+	Start, LastSeen time.Time
+}
+
+// Message is a reassembled SCTP user message.
+type Message struct {
+	// FromInitiator is set for messages sent by the initiator of the
+	// association.
+	FromInitiator   bool
+	StreamID        uint16
+	StreamSequence  uint16
+	PayloadProtocol layers.SCTPPayloadProtocol
+	Unordered       bool
+	// TSN is the transmission sequence number of the first fragment of
+	// the message.
+	TSN uint32
+	// Data is the message, which is only valid during the call to
+	// AssociationHandler.Message.
+	Data []byte
+	// Seen is the time of the packet completing the message.
+	Seen time.Time
+	// Skip is the number of ordered messages of the stream which were
+	// lost or abandoned before this one.
+	Skip int
+}
+
+// AssociationHandler receives the messages of an association.
+type AssociationHandler interface {
+	// Message is called for each complete message, as soon as all its
+	// fragments, and for ordered messages all earlier messages of its
+	// stream, have been seen.
+	Message(m *Message)
+	// Closed is called once when the association ends. No more messages
+	// are received after it.
+	Closed(reason CloseReason)
+}
+
+// AssociationFactory creates the handler of each new association.
+type AssociationFactory interface {
+	New(a *Association) AssociationHandler
+}
+
+// Stats holds the counters of an Assembler.
+type Stats struct {
+	Packets      uint64
+	Associations uint64
+	// BadTags is the number of packets ignored for their verification
+	// tag, Duplicates the number of DATA chunks already seen.
+	BadTags, Duplicates uint64
+	// Collisions and Restarts count INIT collisions and association
+	// restarts.
+	Collisions, Restarts uint64
+	// Lost is the number of fragments of incomplete messages given up on.
+	Lost uint64
+}
+
+// maxPending bounds the number of out of order TSNs and unassembled
+// fragments remembered per direction of an association, beyond which
+// missing chunks are assumed lost.
+const maxPending = 4096
+
+type fragment struct {
+	begin, end bool
+	data       []byte
+	message    Message
+}
+
+// side is the state of one end of an association, and of the data it
+// sends.
+type side struct {
+	tag, pendingTag        uint32
+	tagKnown, pendingKnown bool
+	// The initial TSN of the data sent, from the INIT or INIT ACK.
+	pendingTSN   uint32
+	pendingTSNOk bool
+
+	// cumTSN is the TSN up to which all data was received, received
+	// holds the TSNs received after it.
+	cumTSN    uint32
+	tsnKnown  bool
+	received  map[uint32]struct{}
+	fragments map[uint32]*fragment
+
+	// streams holds the ordering state of each stream, which starts at
+	// sequence number 0 if the handshake was seen.
+	streams     map[uint16]*stream
+	ssnFromZero bool
+}
+
+// stream holds the ordered messages of a stream which were completed
+// before earlier ones.
+type stream struct {
+	next    uint16
+	pending map[uint16]*Message
+}
+
+func (s *side) resetData() {
+	s.tsnKnown = false
+	s.received = nil
+	s.fragments = nil
+	s.streams = nil
+	s.ssnFromZero = s.pendingTSNOk
+	if s.pendingTSNOk {
+		s.cumTSN = s.pendingTSN - 1
+		s.tsnKnown = true
+	}
+}
+
+// advance moves the cumulative TSN past the TSNs received in sequence.
+func (s *side) advance() {
+	for {
+		if _, ok := s.received[s.cumTSN+1]; !ok {
+			return
+		}
+		delete(s.received, s.cumTSN+1)
+		s.cumTSN++
+	}
+}
+
+// stream returns the state of stream id, creating it with the sequence
+// number first if the stream was picked up midway.
+func (s *side) stream(id, first uint16) *stream {
+	st := s.streams[id]
+	if st == nil {
+		st = &stream{}
+		if !s.ssnFromZero {
+			st.next = first
+		}
+		if s.streams == nil {
+			s.streams = make(map[uint16]*stream)
+		}
+		s.streams[id] = st
+	}
+	return st
+}
+
+type key struct {
+	net, transport gopacket.Flow
+}
+
+type association struct {
+	Association
+	handler AssociationHandler
+	// sides holds the initiator and the responder.
+	sides [2]side
+}
+
+// Assembler tracks SCTP associations.
+type Assembler struct {
+	factory      AssociationFactory
+	associations map[key]*association
+	stats        Stats
+	buf          []byte
+}
+
+// NewAssembler returns an Assembler creating the handlers of associations
+// with factory.
+func NewAssembler(factory AssociationFactory) *Assembler {
+	return &Assembler{
+		factory:      factory,
+		associations: make(map[key]*association),
+	}
+}
+
+// Stats returns the counters of a.
+func (a *Assembler) Stats() Stats {
+	return a.stats
+}
+
+// AssemblePacket assembles the SCTP layer of packet, if it has one, with
+// the packet's timestamp.
+func (a *Assembler) AssemblePacket(packet gopacket.Packet) {
+	net := packet.NetworkLayer()
+	sctp, ok := packet.Layer(layers.LayerTypeSCTP).(*layers.SCTP)
+	if net == nil || !ok {
+		return
+	}
+	a.Assemble(net.NetworkFlow(), sctp, packet.Metadata().Timestamp)
+}
+
+type chunk struct {
+	typ   layers.SCTPChunkType
+	flags uint8
+	value []byte
+}
+
+// chunks returns the chunks of an SCTP packet, ignoring a truncated last
+// chunk.
+func chunks(data []byte) []chunk {
+	var cs []chunk
+	for len(data) >= 4 {
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 4 || length > len(data) {
+			break
+		}
+		cs = append(cs, chunk{layers.SCTPChunkType(data[0]), data[1], data[4:length]})
+		if length = (length + 3) &^ 3; length > len(data) {
+			break
+		}
+		data = data[length:]
+	}
+	return cs
+}
+
+// Assemble processes an SCTP packet with the network flow it was sent on,
+// seen at timestamp.
+func (a *Assembler) Assemble(netFlow gopacket.Flow, s *layers.SCTP, timestamp time.Time) {
+	a.stats.Packets++
+	cs := chunks(s.Payload)
+	if len(cs) == 0 {
+		return
+	}
+	k := key{netFlow, s.TransportFlow()}
+	from := 0
+	assoc := a.associations[k]
+	if assoc == nil {
+		if assoc = a.associations[key{netFlow.Reverse(), k.transport.Reverse()}]; assoc != nil {
+			from = 1
+		}
+	}
+	if assoc == nil {
+		switch {
+		case cs[0].typ == layers.SCTPChunkTypeInitAck:
+			// The INIT was missed, the receiver of the INIT ACK
+			// initiated the association.
+			k = key{netFlow.Reverse(), k.transport.Reverse()}
+			from = 1
+		case cs[0].typ != layers.SCTPChunkTypeInit && s.VerificationTag == 0:
+			// An out of the blue packet, which no association would
+			// accept.
+			a.stats.BadTags++
+			return
+		}
+		assoc = a.newAssociation(k, timestamp)
+		if cs[0].typ != layers.SCTPChunkTypeInit && cs[0].typ != layers.SCTPChunkTypeInitAck {
+			assoc.State = StateEstablished
+		}
+	}
+	assoc.LastSeen = timestamp
+	if !a.checkTag(assoc, from, s.VerificationTag, cs[0]) {
+		a.stats.BadTags++
+		return
+	}
+	for _, c := range cs {
+		next := a.chunk(assoc, from, c, timestamp)
+		if next == nil {
+			return
+		}
+		if next != assoc {
+			// A restart by the sender, which initiated the new
+			// association.
+			assoc, from = next, 0
+		}
+	}
+}
+
+func (a *Assembler) newAssociation(k key, timestamp time.Time) *association {
+	assoc := &association{Association: Association{
+		Net:       k.net,
+		Transport: k.transport,
+		Start:     timestamp,
+		LastSeen:  timestamp,
+	}}
+	assoc.handler = a.factory.New(&assoc.Association)
+	a.associations[k] = assoc
+	a.stats.Associations++
+	return assoc
+}
+
+// checkTag reports whether a packet sent by side from, starting with chunk
+// c, has the verification tag expected by its receiver.
+func (a *Assembler) checkTag(assoc *association, from int, tag uint32, c chunk) bool {
+	sender, receiver := &assoc.sides[from], &assoc.sides[1-from]
+	switch c.typ {
+	case layers.SCTPChunkTypeInit:
+		return tag == 0
+	case layers.SCTPChunkTypeAbort, layers.SCTPChunkTypeShutdownComplete:
+		// With the T bit, the sender reflects its own tag.
+		if c.flags&0x1 != 0 {
+			return !sender.tagKnown || tag == sender.tag || sender.pendingKnown && tag == sender.pendingTag
+		}
+	case layers.SCTPChunkTypeInitAck, layers.SCTPChunkTypeCookieEcho:
+		// These are sent to the tag of a new INIT or INIT ACK.
+		if receiver.pendingKnown && tag == receiver.pendingTag {
+			return true
+		}
+	}
+	if !receiver.tagKnown {
+		if receiver.pendingKnown {
+			return false
+		}
+		// Learn the tag of an association picked up midway.
+		receiver.tag, receiver.tagKnown = tag, true
+		a.updateTags(assoc)
+		return true
+	}
+	return tag == receiver.tag
+}
+
+func (a *Assembler) updateTags(assoc *association) {
+	assoc.InitiatorTag = assoc.sides[0].tag
+	assoc.ResponderTag = assoc.sides[1].tag
+}
+
+// chunk processes a chunk sent by side from, returning the association
+// further chunks of the packet belong to, or nil if it was closed.
+func (a *Assembler) chunk(assoc *association, from int, c chunk, timestamp time.Time) *association {
+	sender := &assoc.sides[from]
+	switch c.typ {
+	case layers.SCTPChunkTypeInit, layers.SCTPChunkTypeInitAck:
+		if len(c.value) < 16 {
+			return assoc
+		}
+		sender.pendingTag = binary.BigEndian.Uint32(c.value[0:4])
+		sender.pendingTSN = binary.BigEndian.Uint32(c.value[12:16])
+		sender.pendingKnown, sender.pendingTSNOk = true, true
+		if c.typ == layers.SCTPChunkTypeInitAck {
+			if assoc.State < StateInitAck {
+				assoc.State = StateInitAck
+			}
+			return assoc
+		}
+		switch {
+		case assoc.State == StateInit && from == 1:
+			// Both ends sent an INIT (RFC 4960, section 5.2.1).
+			assoc.Collision = true
+			a.stats.Collisions++
+		case assoc.State > StateCookieEchoed:
+			// A restart, or a new handshake after the INIT ACK of an
+			// earlier one was lost; tags change on the COOKIE ECHO.
+		default:
+			assoc.State = StateInit
+		}
+	case layers.SCTPChunkTypeCookieEcho:
+		return a.cookieEcho(assoc, from, timestamp)
+	case layers.SCTPChunkTypeCookieAck:
+		if assoc.State < StateEstablished {
+			assoc.State = StateEstablished
+		}
+	case layers.SCTPChunkTypeData:
+		a.data(assoc, from, c, timestamp)
+	case layers.SCTPChunkTypeForwardTSN:
+		a.forwardTSN(assoc, from, c)
+	case layers.SCTPChunkTypeShutdown:
+		if assoc.State < StateShutdown {
+			assoc.State = StateShutdown
+		}
+	case layers.SCTPChunkTypeShutdownAck:
+		if assoc.State < StateShutdownAck {
+			assoc.State = StateShutdownAck
+		}
+	case layers.SCTPChunkTypeShutdownComplete:
+		a.close(assoc, CloseShutdown)
+		return nil
+	case layers.SCTPChunkTypeAbort:
+		a.close(assoc, CloseAbort)
+		return nil
+	}
+	return assoc
+}
+
+// cookieEcho completes a handshake, adopting the tags of its INIT and INIT
+// ACK. If the association was already established with other tags, it was
+// restarted, and continues as a new association.
+func (a *Assembler) cookieEcho(assoc *association, from int, timestamp time.Time) *association {
+	sides := &assoc.sides
+	if !sides[0].pendingKnown || !sides[1].pendingKnown {
+		// The handshake was not captured, keep the current tags.
+		if assoc.State < StateCookieEchoed {
+			assoc.State = StateCookieEchoed
+		}
+		return assoc
+	}
+	restart := sides[0].tagKnown && sides[1].tagKnown &&
+		(sides[0].tag != sides[0].pendingTag || sides[1].tag != sides[1].pendingTag)
+	if restart {
+		// The sender of the COOKIE ECHO initiated the new handshake.
+		k := key{assoc.Net, assoc.Transport}
+		if from == 1 {
+			k = key{k.net.Reverse(), k.transport.Reverse()}
+		}
+		old := assoc
+		a.close(old, CloseRestart)
+		a.stats.Restarts++
+		assoc = a.newAssociation(k, timestamp)
+		assoc.sides[0], assoc.sides[1] = old.sides[from], old.sides[1-from]
+	}
+	for i := range assoc.sides {
+		s := &assoc.sides[i]
+		s.tag, s.tagKnown = s.pendingTag, true
+		s.pendingKnown = false
+		s.resetData()
+		s.pendingTSNOk = false
+	}
+	a.updateTags(assoc)
+	assoc.State = StateCookieEchoed
+	return assoc
+}
+
+// after reports whether TSN x comes after y, in serial number arithmetic.
+func after(x, y uint32) bool {
+	return int32(x-y) > 0
+}
+
+func (a *Assembler) data(assoc *association, from int, c chunk, timestamp time.Time) {
+	if len(c.value) < 12 {
+		return
+	}
+	s := &assoc.sides[from]
+	tsn := binary.BigEndian.Uint32(c.value[0:4])
+	if !s.tsnKnown {
+		s.cumTSN, s.tsnKnown = tsn-1, true
+	}
+	if _, ok := s.received[tsn]; ok || !after(tsn, s.cumTSN) {
+		a.stats.Duplicates++
+		return
+	}
+	if s.received == nil {
+		s.received = make(map[uint32]struct{})
+	}
+	s.received[tsn] = struct{}{}
+	if len(s.received) > maxPending {
+		// Give up on the TSNs missing before the oldest one received.
+		oldest := tsn
+		for t := range s.received {
+			if after(oldest, t) {
+				oldest = t
+			}
+		}
+		s.cumTSN = oldest - 1
+	}
+	s.advance()
+
+	f := &fragment{
+		begin: c.flags&0x2 != 0,
+		end:   c.flags&0x1 != 0,
+		data:  c.value[12:],
+		message: Message{
+			FromInitiator:   from == 0,
+			StreamID:        binary.BigEndian.Uint16(c.value[4:6]),
+			StreamSequence:  binary.BigEndian.Uint16(c.value[6:8]),
+			PayloadProtocol: layers.SCTPPayloadProtocol(binary.BigEndian.Uint32(c.value[8:12])),
+			Unordered:       c.flags&0x4 != 0,
+			TSN:             tsn,
+			Seen:            timestamp,
+		},
+	}
+	if f.begin && f.end {
+		f.message.Data = f.data
+		a.deliver(assoc, s, &f.message)
+		return
+	}
+	a.fragment(assoc, s, tsn, f)
+}
+
+// fragment stores a fragment of a message, and delivers the message if it
+// is complete.
+func (a *Assembler) fragment(assoc *association, s *side, tsn uint32, f *fragment) {
+	if s.fragments == nil {
+		s.fragments = make(map[uint32]*fragment)
+	}
+	if len(s.fragments) >= maxPending {
+		a.stats.Lost += uint64(len(s.fragments))
+		s.fragments = make(map[uint32]*fragment)
+	}
+	// Fragments are stored with a copy of their data, which is only valid
+	// during the call to Assemble.
+	f.data = append([]byte(nil), f.data...)
+	s.fragments[tsn] = f
+
+	first := tsn
+	for !s.fragments[first].begin {
+		if s.fragments[first-1] == nil {
+			return
+		}
+		first--
+	}
+	last := tsn
+	for !s.fragments[last].end {
+		if s.fragments[last+1] == nil {
+			return
+		}
+		last++
+	}
+	a.buf = a.buf[:0]
+	for t := first; ; t++ {
+		a.buf = append(a.buf, s.fragments[t].data...)
+		if t == last {
+			break
+		}
+	}
+	m := s.fragments[first].message
+	m.Data = a.buf
+	m.Seen = f.message.Seen
+	for t := first; ; t++ {
+		delete(s.fragments, t)
+		if t == last {
+			break
+		}
+	}
+	a.deliver(assoc, s, &m)
+}
+
+// after16 reports whether stream sequence number x comes after y.
+func after16(x, y uint16) bool {
+	return int16(x-y) > 0
+}
+
+// deliver hands a complete message sent by side s to the handler, or holds
+// it until the ordered messages sent before it on its stream are complete.
+func (a *Assembler) deliver(assoc *association, s *side, m *Message) {
+	if m.Unordered {
+		assoc.handler.Message(m)
+		return
+	}
+	st := s.stream(m.StreamID, m.StreamSequence)
+	switch {
+	case m.StreamSequence == st.next:
+		assoc.handler.Message(m)
+		st.next++
+		a.skipTo(assoc, st, st.next-1)
+	case after16(m.StreamSequence, st.next):
+		if _, ok := st.pending[m.StreamSequence]; ok {
+			a.stats.Duplicates++
+			return
+		}
+		if st.pending == nil {
+			st.pending = make(map[uint16]*Message)
+		}
+		// The data of messages is only valid during the call to
+		// Assemble.
+		held := *m
+		held.Data = append([]byte(nil), m.Data...)
+		st.pending[m.StreamSequence] = &held
+		if len(st.pending) > maxPending {
+			// Give up on the messages missing before the oldest one
+			// held.
+			a.skipTo(assoc, st, st.oldest())
+		}
+	default:
+		// A message of a reset stream, or one given up on.
+		assoc.handler.Message(m)
+	}
+}
+
+// oldest returns the first sequence number of the messages held by st.
+func (st *stream) oldest() uint16 {
+	oldest := st.next + 0x7fff
+	for ssn := range st.pending {
+		if after16(oldest, ssn) {
+			oldest = ssn
+		}
+	}
+	return oldest
+}
+
+// skipTo delivers the messages held by st up to sequence number ssn,
+// skipping the missing ones, and the messages following them in sequence.
+func (a *Assembler) skipTo(assoc *association, st *stream, ssn uint16) {
+	skip := 0
+	for {
+		if m, ok := st.pending[st.next]; ok {
+			delete(st.pending, st.next)
+			m.Skip, skip = skip, 0
+			assoc.handler.Message(m)
+			st.next++
+			continue
+		}
+		if !after16(ssn+1, st.next) {
+			return
+		}
+		// Skip to the next message held, or past ssn.
+		to := ssn + 1
+		for held := range st.pending {
+			if after16(to, held) {
+				to = held
+			}
+		}
+		skip += int(to - st.next)
+		st.next = to
+	}
+}
+
+// forwardTSN abandons the data sent by side from up to a new cumulative
+// TSN, and the ordered messages up to the sequence numbers given for each
+// stream.
+func (a *Assembler) forwardTSN(assoc *association, from int, c chunk) {
+	if len(c.value) < 4 {
+		return
+	}
+	s := &assoc.sides[from]
+	cumTSN := binary.BigEndian.Uint32(c.value[0:4])
+	if !s.tsnKnown || after(cumTSN, s.cumTSN) {
+		s.cumTSN, s.tsnKnown = cumTSN, true
+	}
+	for tsn := range s.received {
+		if !after(tsn, s.cumTSN) {
+			delete(s.received, tsn)
+		}
+	}
+	s.advance()
+	for tsn := range s.fragments {
+		if !after(tsn, cumTSN) {
+			delete(s.fragments, tsn)
+			a.stats.Lost++
+		}
+	}
+	for v := c.value[4:]; len(v) >= 4; v = v[4:] {
+		ssn := binary.BigEndian.Uint16(v[2:4])
+		a.skipTo(assoc, s.stream(binary.BigEndian.Uint16(v[0:2]), ssn+1), ssn)
+	}
+}
+
+func (a *Assembler) close(assoc *association, reason CloseReason) {
+	k := key{assoc.Net, assoc.Transport}
+	if a.associations[k] != assoc {
+		return
+	}
+	delete(a.associations, k)
+	for i := range assoc.sides {
+		s := &assoc.sides[i]
+		a.stats.Lost += uint64(len(s.fragments))
+		// Deliver the messages held behind missing ones.
+		for _, st := range s.streams {
+			for len(st.pending) > 0 {
+				a.skipTo(assoc, st, st.oldest())
+			}
+		}
+	}
+	if reason != CloseRestart && reason != CloseFlush {
+		assoc.State = StateClosed
+	}
+	assoc.handler.Closed(reason)
+}
+
+// FlushOlderThan closes the associations which saw no packet since t, and
+// returns their number.
+func (a *Assembler) FlushOlderThan(t time.Time) int {
+	var old []*association
+	for _, assoc := range a.associations {
+		if assoc.LastSeen.Before(t) {
+			old = append(old, assoc)
+		}
+	}
+	for _, assoc := range old {
+		a.close(assoc, CloseFlush)
+	}
+	return len(old)
+}
+
+// FlushAll closes all associations, and returns their number.
+func (a *Assembler) FlushAll() int {
+	var all []*association
+	for _, assoc := range a.associations {
+		all = append(all, assoc)
+	}
+	for _, assoc := range all {
+		a.close(assoc, CloseFlush)
+	}
+	return len(all)
+}
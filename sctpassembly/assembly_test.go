@@ -0,0 +1,302 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package sctpassembly
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	clientIP = net.IP{10, 0, 0, 1}
+	serverIP = net.IP{10, 0, 0, 2}
+	start    = time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type message struct {
+	fromInitiator bool
+	stream        uint16
+	data          string
+}
+
+type recorder struct {
+	assoc    *Association
+	messages []message
+	skips    []int
+	closed   []CloseReason
+}
+
+func (r *recorder) Message(m *Message) {
+	r.messages = append(r.messages, message{m.FromInitiator, m.StreamID, string(m.Data)})
+	r.skips = append(r.skips, m.Skip)
+}
+
+func (r *recorder) Closed(reason CloseReason) {
+	r.closed = append(r.closed, reason)
+}
+
+type factory struct {
+	recorders []*recorder
+}
+
+func (f *factory) New(a *Association) AssociationHandler {
+	r := &recorder{assoc: a}
+	f.recorders = append(f.recorders, r)
+	return r
+}
+
+type tester struct {
+	t         *testing.T
+	assembler *Assembler
+	factory   *factory
+	n         int
+}
+
+func newTester(t *testing.T) *tester {
+	f := &factory{}
+	return &tester{t: t, assembler: NewAssembler(f), factory: f}
+}
+
+// send assembles a packet from the client, or from the server, with the
+// verification tag and chunks given.
+func (x *tester) send(fromClient bool, tag uint32, chunks ...gopacket.SerializableLayer) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolSCTP, SrcIP: clientIP, DstIP: serverIP}
+	sctp := &layers.SCTP{SrcPort: 1000, DstPort: 3868, VerificationTag: tag}
+	if !fromClient {
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+		sctp.SrcPort, sctp.DstPort = sctp.DstPort, sctp.SrcPort
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, append([]gopacket.SerializableLayer{ip, sctp}, chunks...)...); err != nil {
+		x.t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	p.Metadata().Timestamp = start.Add(time.Duration(x.n) * time.Second)
+	x.n++
+	x.assembler.AssemblePacket(p)
+}
+
+func initChunk(typ layers.SCTPChunkType, tag, tsn uint32) gopacket.SerializableLayer {
+	return &layers.SCTPInit{
+		SCTPChunk:                      layers.SCTPChunk{Type: typ},
+		InitiateTag:                    tag,
+		AdvertisedReceiverWindowCredit: 65536,
+		OutboundStreams:                10,
+		InboundStreams:                 10,
+		InitialTSN:                     tsn,
+	}
+}
+
+func dataChunk(tsn uint32, stream uint16, begin, end bool) gopacket.SerializableLayer {
+	return &layers.SCTPData{
+		SCTPChunk:       layers.SCTPChunk{Type: layers.SCTPChunkTypeData},
+		BeginFragment:   begin,
+		EndFragment:     end,
+		TSN:             tsn,
+		StreamId:        stream,
+		PayloadProtocol: 46,
+	}
+}
+
+// seqChunk returns a complete message with stream sequence number ssn.
+func seqChunk(tsn uint32, stream, ssn uint16, unordered bool) gopacket.SerializableLayer {
+	d := dataChunk(tsn, stream, true, true).(*layers.SCTPData)
+	d.StreamSequence, d.Unordered = ssn, unordered
+	return d
+}
+
+var (
+	cookieEcho = &layers.SCTPCookieEcho{SCTPChunk: layers.SCTPChunk{Type: layers.SCTPChunkTypeCookieEcho}, Cookie: []byte("cookie")}
+	cookieAck  = &layers.SCTPEmptyLayer{SCTPChunk: layers.SCTPChunk{Type: layers.SCTPChunkTypeCookieAck}}
+)
+
+// handshake establishes an association between tags 0x11 of the client
+// and 0x22 of the server, whose TSNs start at 100 and 500.
+func (x *tester) handshake() {
+	x.send(true, 0, initChunk(layers.SCTPChunkTypeInit, 0x11, 100))
+	x.send(false, 0x11, initChunk(layers.SCTPChunkTypeInitAck, 0x22, 500))
+	x.send(true, 0x22, cookieEcho)
+	x.send(false, 0x11, cookieAck)
+}
+
+func TestAssociation(t *testing.T) {
+	x := newTester(t)
+	x.send(true, 0, initChunk(layers.SCTPChunkTypeInit, 0x11, 100))
+	x.send(false, 0x11, initChunk(layers.SCTPChunkTypeInitAck, 0x22, 500))
+	if len(x.factory.recorders) != 1 {
+		t.Fatalf("%d associations, want 1", len(x.factory.recorders))
+	}
+	r := x.factory.recorders[0]
+	if r.assoc.State != StateInitAck {
+		t.Errorf("state %v, want InitAck", r.assoc.State)
+	}
+	// Data may be bundled with the COOKIE ECHO.
+	x.send(true, 0x22, cookieEcho, dataChunk(100, 1, true, false), gopacket.Payload("hel"))
+	x.send(false, 0x11, cookieAck)
+	if r.assoc.State != StateEstablished || r.assoc.InitiatorTag != 0x11 || r.assoc.ResponderTag != 0x22 {
+		t.Errorf("unexpected association %+v", r.assoc)
+	}
+	x.send(true, 0x22, dataChunk(101, 1, false, true), gopacket.Payload("lo"))
+	// A retransmission.
+	x.send(true, 0x22, dataChunk(101, 1, false, true), gopacket.Payload("lo"))
+	// A packet with the wrong tag.
+	x.send(true, 0x11, dataChunk(102, 1, true, true), gopacket.Payload("bad"))
+	x.send(false, 0x11, dataChunk(500, 2, true, true), gopacket.Payload("world"))
+
+	x.send(true, 0x22, &layers.SCTPShutdown{SCTPChunk: layers.SCTPChunk{Type: layers.SCTPChunkTypeShutdown}, CumulativeTSNAck: 500})
+	if r.assoc.State != StateShutdown {
+		t.Errorf("state %v, want Shutdown", r.assoc.State)
+	}
+	x.send(false, 0x11, &layers.SCTPShutdownAck{SCTPChunk: layers.SCTPChunk{Type: layers.SCTPChunkTypeShutdownAck}})
+	x.send(true, 0x22, &layers.SCTPEmptyLayer{SCTPChunk: layers.SCTPChunk{Type: layers.SCTPChunkTypeShutdownComplete}})
+
+	want := []message{{true, 1, "hello"}, {false, 2, "world"}}
+	if !reflect.DeepEqual(r.messages, want) {
+		t.Errorf("messages %v, want %v", r.messages, want)
+	}
+	if !reflect.DeepEqual(r.closed, []CloseReason{CloseShutdown}) || r.assoc.State != StateClosed {
+		t.Errorf("closed %v in state %v", r.closed, r.assoc.State)
+	}
+	stats := x.assembler.Stats()
+	if stats.Associations != 1 || stats.Duplicates != 1 || stats.BadTags != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if n := x.assembler.FlushAll(); n != 0 {
+		t.Errorf("flushed %d associations, want 0", n)
+	}
+}
+
+func TestInitCollision(t *testing.T) {
+	x := newTester(t)
+	x.send(true, 0, initChunk(layers.SCTPChunkTypeInit, 0x11, 100))
+	x.send(false, 0, initChunk(layers.SCTPChunkTypeInit, 0x22, 500))
+	// Both ends answer with the tag of their own INIT.
+	x.send(false, 0x11, initChunk(layers.SCTPChunkTypeInitAck, 0x22, 500))
+	x.send(true, 0x22, initChunk(layers.SCTPChunkTypeInitAck, 0x11, 100))
+	x.send(true, 0x22, cookieEcho)
+	x.send(false, 0x11, cookieAck)
+	x.send(false, 0x11, dataChunk(500, 0, true, true), gopacket.Payload("hi"))
+	if len(x.factory.recorders) != 1 {
+		t.Fatalf("%d associations, want 1", len(x.factory.recorders))
+	}
+	r := x.factory.recorders[0]
+	if !r.assoc.Collision || r.assoc.State != StateEstablished || r.assoc.InitiatorTag != 0x11 || r.assoc.ResponderTag != 0x22 {
+		t.Errorf("unexpected association %+v", r.assoc)
+	}
+	if want := []message{{false, 0, "hi"}}; !reflect.DeepEqual(r.messages, want) {
+		t.Errorf("messages %v, want %v", r.messages, want)
+	}
+	if stats := x.assembler.Stats(); stats.Collisions != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	x := newTester(t)
+	x.handshake()
+	x.send(true, 0x22, dataChunk(100, 0, true, false), gopacket.Payload("lost"))
+	// The server restarts with new tags.
+	x.send(false, 0, initChunk(layers.SCTPChunkTypeInit, 0x33, 900))
+	x.send(true, 0x33, initChunk(layers.SCTPChunkTypeInitAck, 0x44, 200))
+	x.send(false, 0x44, cookieEcho, dataChunk(900, 0, true, true), gopacket.Payload("again"))
+	x.send(true, 0x33, cookieAck)
+	// Packets with the old tags are ignored.
+	x.send(true, 0x22, dataChunk(101, 0, false, true), gopacket.Payload("old"))
+
+	if len(x.factory.recorders) != 2 {
+		t.Fatalf("%d associations, want 2", len(x.factory.recorders))
+	}
+	old, restarted := x.factory.recorders[0], x.factory.recorders[1]
+	if !reflect.DeepEqual(old.closed, []CloseReason{CloseRestart}) || len(old.messages) != 0 {
+		t.Errorf("old association closed %v with messages %v", old.closed, old.messages)
+	}
+	a := restarted.assoc
+	if a.State != StateEstablished || a.InitiatorTag != 0x33 || a.ResponderTag != 0x44 || a.Net.Src().String() != serverIP.String() {
+		t.Errorf("unexpected restarted association %+v", a)
+	}
+	if want := []message{{true, 0, "again"}}; !reflect.DeepEqual(restarted.messages, want) {
+		t.Errorf("messages %v, want %v", restarted.messages, want)
+	}
+	if stats := x.assembler.Stats(); stats.Restarts != 1 || stats.BadTags != 1 || stats.Lost != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPickupAndAbort(t *testing.T) {
+	x := newTester(t)
+	// The handshake was not captured.
+	x.send(false, 0x11, dataChunk(502, 3, true, true), gopacket.Payload("late"))
+	x.send(true, 0x22, dataChunk(102, 3, true, true), gopacket.Payload("reply"))
+	if len(x.factory.recorders) != 1 {
+		t.Fatalf("%d associations, want 1", len(x.factory.recorders))
+	}
+	r := x.factory.recorders[0]
+	if r.assoc.State != StateEstablished || r.assoc.InitiatorTag != 0x22 || r.assoc.ResponderTag != 0x11 {
+		t.Errorf("unexpected association %+v", r.assoc)
+	}
+	// An ABORT with the T bit reflects the sender's own tag.
+	x.send(true, 0x11, &layers.SCTPError{SCTPChunk: layers.SCTPChunk{Type: layers.SCTPChunkTypeAbort, Flags: 1}})
+	want := []message{{true, 3, "late"}, {false, 3, "reply"}}
+	if !reflect.DeepEqual(r.messages, want) {
+		t.Errorf("messages %v, want %v", r.messages, want)
+	}
+	if !reflect.DeepEqual(r.closed, []CloseReason{CloseAbort}) {
+		t.Errorf("closed %v", r.closed)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	x := newTester(t)
+	x.handshake()
+	if n := x.assembler.FlushOlderThan(start); n != 0 {
+		t.Errorf("flushed %d associations, want 0", n)
+	}
+	if n := x.assembler.FlushOlderThan(start.Add(time.Hour)); n != 1 {
+		t.Errorf("flushed %d associations, want 1", n)
+	}
+	r := x.factory.recorders[0]
+	if !reflect.DeepEqual(r.closed, []CloseReason{CloseFlush}) {
+		t.Errorf("closed %v", r.closed)
+	}
+}
+
+func TestStreamOrder(t *testing.T) {
+	x := newTester(t)
+	x.handshake()
+	x.send(true, 0x22, seqChunk(102, 1, 1, false), gopacket.Payload("c"))
+	x.send(true, 0x22, seqChunk(103, 2, 0, false), gopacket.Payload("other"))
+	x.send(true, 0x22, seqChunk(104, 1, 0, true), gopacket.Payload("unordered"))
+	x.send(true, 0x22, dataChunk(101, 1, false, true), gopacket.Payload("b"))
+	// The first fragment of the first message of stream 1 completes it,
+	// and releases the second message.
+	x.send(true, 0x22, dataChunk(100, 1, true, false), gopacket.Payload("a"))
+	x.send(true, 0x22, seqChunk(106, 1, 3, false), gopacket.Payload("e"))
+	x.send(true, 0x22, seqChunk(108, 1, 5, false), gopacket.Payload("g"))
+	// TSN 105 was abandoned, with message 2 of stream 1.
+	x.send(true, 0x22, &layers.SCTPForwardTSN{
+		SCTPChunk:        layers.SCTPChunk{Type: layers.SCTPChunkTypeForwardTSN},
+		NewCumulativeTSN: 105,
+		Streams:          []layers.SCTPForwardTSNStream{{StreamId: 1, StreamSequence: 2}},
+	})
+	// Message 5 is held behind the missing message 4 until the end.
+	x.assembler.FlushAll()
+
+	r := x.factory.recorders[0]
+	want := []message{{true, 2, "other"}, {true, 1, "unordered"}, {true, 1, "ab"}, {true, 1, "c"}, {true, 1, "e"}, {true, 1, "g"}}
+	if !reflect.DeepEqual(r.messages, want) {
+		t.Errorf("messages %v, want %v", r.messages, want)
+	}
+	if want := []int{0, 0, 0, 0, 1, 1}; !reflect.DeepEqual(r.skips, want) {
+		t.Errorf("skips %v, want %v", r.skips, want)
+	}
+}
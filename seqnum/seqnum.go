@@ -0,0 +1,128 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package seqnum implements serial number arithmetic (RFC 1982) for the
+// wrapping sequence numbers and timestamps found in packet headers: TCP
+// sequence, acknowledgement and timestamp values, RTP and GTP sequence
+// numbers, RTP timestamps and the like.
+//
+// A serial number of n bits is before another if it is less than 2^(n-1)
+// steps behind it, counting modulo 2^n. Two numbers exactly 2^(n-1) apart
+// are not ordered either way; the Less functions report false for both
+// orders, as RFC 1982 requires, and the Diff functions return the negative
+// distance.
+//
+// Comparing serial numbers with < or subtracting them as unsigned values is
+// wrong as soon as the counter wraps, which on a busy TCP connection takes
+// a few seconds. Use these helpers instead:
+//
+//  if seqnum.Less32(tcp.Seq, expected) {
+//  	// retransmission
+//  }
+//
+// To track a counter across wraps, for example to compute RTP packet loss
+// or jitter over a long call, extend it to 64 bits with an Unwrapper.
+package seqnum
+
+// Less32 reports whether a comes before b.
+func Less32(a, b uint32) bool {
+	return a != b && b-a < 1<<31
+}
+
+// LessOrEqual32 reports whether a is b or comes before it.
+func LessOrEqual32(a, b uint32) bool {
+	return b-a < 1<<31
+}
+
+// Diff32 returns the number of steps from a to b, which is negative if b
+// comes before a.
+func Diff32(a, b uint32) int32 {
+	return int32(b - a)
+}
+
+// Max32 returns whichever of a and b comes later.
+func Max32(a, b uint32) uint32 {
+	if Less32(a, b) {
+		return b
+	}
+	return a
+}
+
+// InWindow32 reports whether v lies in the window of size numbers starting
+// at start, as in checking a TCP segment against the receive window. size
+// should be at most 2^31.
+func InWindow32(v, start, size uint32) bool {
+	return v-start < size
+}
+
+// Less16 reports whether a comes before b.
+func Less16(a, b uint16) bool {
+	return a != b && b-a < 1<<15
+}
+
+// LessOrEqual16 reports whether a is b or comes before it.
+func LessOrEqual16(a, b uint16) bool {
+	return b-a < 1<<15
+}
+
+// Diff16 returns the number of steps from a to b, which is negative if b
+// comes before a.
+func Diff16(a, b uint16) int16 {
+	return int16(b - a)
+}
+
+// Max16 returns whichever of a and b comes later.
+func Max16(a, b uint16) uint16 {
+	if Less16(a, b) {
+		return b
+	}
+	return a
+}
+
+// Unwrapper32 extends a wrapping 32 bit counter to 64 bits. Each value is
+// placed in the cycle that puts it closest to the previous value, so values
+// may arrive out of order as long as they are less than 2^31 apart.
+//
+// The zero value is ready to use and maps the first value to itself.
+type Unwrapper32 struct {
+	last    int64
+	started bool
+}
+
+// Unwrap returns the 64 bit value of v.
+func (u *Unwrapper32) Unwrap(v uint32) int64 {
+	if !u.started {
+		u.started = true
+		u.last = int64(v)
+		return u.last
+	}
+	ext := u.last + int64(Diff32(uint32(u.last), v))
+	if ext > u.last {
+		u.last = ext
+	}
+	return ext
+}
+
+// Unwrapper16 extends a wrapping 16 bit counter, such as an RTP sequence
+// number, to 64 bits. It works like Unwrapper32.
+type Unwrapper16 struct {
+	last    int64
+	started bool
+}
+
+// Unwrap returns the 64 bit value of v.
+func (u *Unwrapper16) Unwrap(v uint16) int64 {
+	if !u.started {
+		u.started = true
+		u.last = int64(v)
+		return u.last
+	}
+	ext := u.last + int64(Diff16(uint16(u.last), v))
+	if ext > u.last {
+		u.last = ext
+	}
+	return ext
+}
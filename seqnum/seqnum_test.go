@@ -0,0 +1,114 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package seqnum
+
+import "testing"
+
+func TestLess32(t *testing.T) {
+	for _, test := range []struct {
+		a, b uint32
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{5, 5, false},
+		{0xffffffff, 0, true},
+		{0, 0xffffffff, false},
+		{0xfffffff0, 0x10, true},
+		{0, 0x7fffffff, true},
+		// Exactly half the space apart: undefined, so false both ways.
+		{0, 0x80000000, false},
+		{0x80000000, 0, false},
+	} {
+		if got := Less32(test.a, test.b); got != test.want {
+			t.Errorf("Less32(%#x, %#x) = %v, want %v", test.a, test.b, got, test.want)
+		}
+		if got := LessOrEqual32(test.a, test.b); got != (test.want || test.a == test.b) {
+			t.Errorf("LessOrEqual32(%#x, %#x) = %v", test.a, test.b, got)
+		}
+	}
+}
+
+func TestDiffAndMax(t *testing.T) {
+	if d := Diff32(0xfffffffe, 3); d != 5 {
+		t.Errorf("Diff32 across the wrap = %d, want 5", d)
+	}
+	if d := Diff32(3, 0xfffffffe); d != -5 {
+		t.Errorf("Diff32 backwards across the wrap = %d, want -5", d)
+	}
+	if m := Max32(0xfffffffe, 3); m != 3 {
+		t.Errorf("Max32 = %#x, want 3", m)
+	}
+	if d := Diff16(65530, 4); d != 10 {
+		t.Errorf("Diff16 across the wrap = %d, want 10", d)
+	}
+	if !Less16(65535, 0) || Less16(0, 32768) || Max16(65535, 1) != 1 {
+		t.Error("16 bit comparisons failed")
+	}
+	if !LessOrEqual16(7, 7) || LessOrEqual16(8, 7) {
+		t.Error("LessOrEqual16 failed")
+	}
+}
+
+func TestInWindow32(t *testing.T) {
+	for _, test := range []struct {
+		v, start, size uint32
+		want           bool
+	}{
+		{10, 10, 5, true},
+		{14, 10, 5, true},
+		{15, 10, 5, false},
+		{9, 10, 5, false},
+		{2, 0xfffffffe, 8, true},
+		{0xfffffffd, 0xfffffffe, 8, false},
+		{10, 10, 0, false},
+	} {
+		if got := InWindow32(test.v, test.start, test.size); got != test.want {
+			t.Errorf("InWindow32(%#x, %#x, %d) = %v, want %v", test.v, test.start, test.size, got, test.want)
+		}
+	}
+}
+
+func TestUnwrapper16(t *testing.T) {
+	var u Unwrapper16
+	for i, test := range []struct {
+		in   uint16
+		want int64
+	}{
+		{65533, 65533},
+		{65535, 65535},
+		{1, 65537},
+		{65534, 65534}, // late packet from before the wrap
+		{2, 65538},
+		{30000, 30000 + 65536},
+		{60000, 60000 + 65536},
+		{10000, 10000 + 2*65536},
+	} {
+		if got := u.Unwrap(test.in); got != test.want {
+			t.Errorf("%d: Unwrap(%d) = %d, want %d", i, test.in, got, test.want)
+		}
+	}
+}
+
+func TestUnwrapper32(t *testing.T) {
+	var u Unwrapper32
+	for i, test := range []struct {
+		in   uint32
+		want int64
+	}{
+		{100, 100},
+		{50, 50}, // reordered before the first value
+		{0xf0000000, -0x10000000},
+		{0x70000000, 0x70000000},
+		{0xe0000000, 0xe0000000},
+		{0x10, 1<<32 + 0x10},
+	} {
+		if got := u.Unwrap(test.in); got != test.want {
+			t.Errorf("%d: Unwrap(%#x) = %#x, want %#x", i, test.in, got, test.want)
+		}
+	}
+}
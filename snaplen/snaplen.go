@@ -0,0 +1,191 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package snaplen finds out how much of each packet a sensor has to capture.
+//
+// An Analyzer is fed the packets of a capture and records, for each layer
+// type, the offset at which its header ends and how often the capture
+// ended within its header or its payload. From that it reports the layers
+// which are systematically cut off and recommends the smallest snaplen
+// which captures the headers of the protocols of interest:
+//
+//	a := snaplen.New()
+//	for packet := range source.Packets() {
+//		a.Add(packet)
+//	}
+//	for _, s := range a.Truncated(0.01) {
+//		fmt.Printf("%v: %d of %d headers cut\n", s.LayerType, s.CutHeader, s.Packets)
+//	}
+//	n, ok := a.Snaplen(layers.LayerTypeTCP, layers.LayerTypeDNS)
+package snaplen
+
+import (
+	"sort"
+
+	"github.com/google/gopacket"
+)
+
+// LayerStats holds what an Analyzer saw of one layer type.
+type LayerStats struct {
+	LayerType gopacket.LayerType
+	// Packets is the number of packets containing the layer, including
+	// those in which its header was cut.
+	Packets uint64
+	// HeaderEnd is the largest offset at which the header of the layer
+	// ended, which is the snaplen needed to capture the header in all
+	// packets in which it was complete.
+	HeaderEnd int
+	// CutHeader is the number of packets whose capture ended within the
+	// header of the layer, so it could not be decoded.
+	CutHeader uint64
+	// CutPayload is the number of packets whose capture ended within the
+	// payload of the layer, with the layer being the last one decoded.
+	CutPayload uint64
+}
+
+// Analyzer collects LayerStats. An Analyzer is not safe for concurrent use.
+type Analyzer struct {
+	layers             map[gopacket.LayerType]*LayerStats
+	packets, truncated uint64
+}
+
+// New returns an empty Analyzer.
+func New() *Analyzer {
+	return &Analyzer{layers: make(map[gopacket.LayerType]*LayerStats)}
+}
+
+func (a *Analyzer) stats(t gopacket.LayerType) *LayerStats {
+	s := a.layers[t]
+	if s == nil {
+		s = &LayerStats{LayerType: t}
+		a.layers[t] = s
+	}
+	return s
+}
+
+// ignored reports whether t is a layer type which is not a protocol header.
+func ignored(t gopacket.LayerType) bool {
+	switch t {
+	case gopacket.LayerTypePayload, gopacket.LayerTypeFragment, gopacket.LayerTypeDecodeFailure, gopacket.LayerTypeZero:
+		return true
+	}
+	return false
+}
+
+// Add records packet. A packet counts as truncated if it was captured
+// shorter than it was on the wire, or if a decoder found it truncated.
+func (a *Analyzer) Add(packet gopacket.Packet) {
+	a.packets++
+	md := packet.Metadata()
+	truncated := md.Truncated || (md.Length > 0 && md.CaptureLength < md.Length)
+	if truncated {
+		a.truncated++
+	}
+	var ls []gopacket.Layer
+	for _, l := range packet.Layers() {
+		if !ignored(l.LayerType()) {
+			ls = append(ls, l)
+		}
+	}
+	if len(ls) == 0 {
+		return
+	}
+	last := ls[len(ls)-1]
+	// cut is the layer whose header was cut, if decoding failed on a
+	// truncated packet. Some decoders add their layer before failing, in
+	// which case it has no payload; otherwise the last layer decoded
+	// knows the type of the one that failed, if it is a DecodingLayer.
+	cut := gopacket.LayerTypeZero
+	if truncated && packet.ErrorLayer() != nil {
+		if len(last.LayerPayload()) == 0 {
+			cut = last.LayerType()
+			ls = ls[:len(ls)-1]
+		} else if dl, ok := last.(interface{ NextLayerType() gopacket.LayerType }); ok && !ignored(dl.NextLayerType()) {
+			cut = dl.NextLayerType()
+		}
+	}
+	data := packet.Data()
+	for _, l := range ls {
+		s := a.stats(l.LayerType())
+		s.Packets++
+		if end, ok := headerEnd(data, l.LayerContents()); ok && end > s.HeaderEnd {
+			s.HeaderEnd = end
+		}
+	}
+	switch {
+	case cut != gopacket.LayerTypeZero:
+		s := a.stats(cut)
+		s.Packets++
+		s.CutHeader++
+	case truncated && len(ls) > 0:
+		a.stats(ls[len(ls)-1].LayerType()).CutPayload++
+	}
+}
+
+// headerEnd returns the offset in data at which contents end, if contents
+// is a part of data.
+func headerEnd(data, contents []byte) (int, bool) {
+	off := cap(data) - cap(contents)
+	if len(contents) == 0 || off < 0 || off+len(contents) > len(data) || &data[off] != &contents[0] {
+		return 0, false
+	}
+	return off + len(contents), true
+}
+
+// Packets returns the number of packets added and how many of them were
+// truncated.
+func (a *Analyzer) Packets() (packets, truncated uint64) {
+	return a.packets, a.truncated
+}
+
+// Stats returns the statistics of all layer types seen, ordered by layer
+// type.
+func (a *Analyzer) Stats() []LayerStats {
+	stats := make([]LayerStats, 0, len(a.layers))
+	for _, s := range a.layers {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].LayerType < stats[j].LayerType })
+	return stats
+}
+
+// Truncated returns the statistics of the layer types whose header was cut
+// in at least the fraction min of the packets containing them, most often
+// cut first.
+func (a *Analyzer) Truncated(min float64) []LayerStats {
+	var cut []LayerStats
+	for _, s := range a.Stats() {
+		if s.CutHeader > 0 && float64(s.CutHeader) >= min*float64(s.Packets) {
+			cut = append(cut, s)
+		}
+	}
+	sort.SliceStable(cut, func(i, j int) bool {
+		return float64(cut[i].CutHeader)/float64(cut[i].Packets) > float64(cut[j].CutHeader)/float64(cut[j].Packets)
+	})
+	return cut
+}
+
+// Snaplen returns the smallest snaplen which captures the headers of all
+// the given layer types in the packets seen so far. It returns false if
+// the result is only a lower bound, because the header of one of the types
+// was cut in some packets, never seen complete, or never seen at all.
+func (a *Analyzer) Snaplen(types ...gopacket.LayerType) (int, bool) {
+	n, exact := 0, true
+	for _, t := range types {
+		s := a.layers[t]
+		if s == nil {
+			exact = false
+			continue
+		}
+		if s.HeaderEnd > n {
+			n = s.HeaderEnd
+		}
+		if s.CutHeader > 0 || s.HeaderEnd == 0 {
+			exact = false
+		}
+	}
+	return n, exact
+}
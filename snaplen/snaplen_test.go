@@ -0,0 +1,79 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package snaplen
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func tcpPacket(t *testing.T, snaplen int, options bool) gopacket.Packet {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	tcp := &layers.TCP{SrcPort: 1, DstPort: 2, ACK: true}
+	if options {
+		tcp.Options = []layers.TCPOption{{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: make([]byte, 8)}}
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(make([]byte, 100))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	wire := len(data)
+	if snaplen < wire {
+		data = data[:snaplen]
+	}
+	p := gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+	md := p.Metadata()
+	md.CaptureLength, md.Length = len(data), wire
+	return p
+}
+
+func TestAnalyzer(t *testing.T) {
+	a := New()
+	a.Add(tcpPacket(t, 1500, false))
+	a.Add(tcpPacket(t, 96, true))  // payload cut
+	a.Add(tcpPacket(t, 60, false)) // payload cut
+	a.Add(tcpPacket(t, 40, false)) // TCP header cut
+	a.Add(tcpPacket(t, 64, true))  // TCP options cut
+
+	if packets, truncated := a.Packets(); packets != 5 || truncated != 4 {
+		t.Errorf("got %d packets, %d truncated", packets, truncated)
+	}
+	stats := map[gopacket.LayerType]LayerStats{}
+	for _, s := range a.Stats() {
+		stats[s.LayerType] = s
+	}
+	if s := stats[layers.LayerTypeIPv4]; s.Packets != 5 || s.HeaderEnd != 34 || s.CutHeader != 0 {
+		t.Errorf("IPv4: %+v", s)
+	}
+	if s := stats[layers.LayerTypeTCP]; s.Packets != 5 || s.HeaderEnd != 66 || s.CutHeader != 2 || s.CutPayload != 2 {
+		t.Errorf("TCP: %+v", s)
+	}
+
+	cut := a.Truncated(0.1)
+	if len(cut) != 1 || cut[0].LayerType != layers.LayerTypeTCP {
+		t.Errorf("got truncated layers %+v", cut)
+	}
+	if len(a.Truncated(0.5)) != 0 {
+		t.Error("TCP reported at 50%")
+	}
+	if n, exact := a.Snaplen(layers.LayerTypeEthernet, layers.LayerTypeIPv4); n != 34 || !exact {
+		t.Errorf("IPv4 snaplen %d, %v", n, exact)
+	}
+	if n, exact := a.Snaplen(layers.LayerTypeIPv4, layers.LayerTypeTCP); n != 66 || exact {
+		t.Errorf("TCP snaplen %d, %v", n, exact)
+	}
+	if n, exact := a.Snaplen(layers.LayerTypeIPv4, layers.LayerTypeUDP); n != 34 || exact {
+		t.Errorf("UDP snaplen %d, %v", n, exact)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package snapshot persists the state of long-lived analyzers, such as flow
+// tables, DNS query/response correlators or NetFlow template caches, so a
+// sensor can be restarted or upgraded without losing the state of the
+// flows in progress.
+//
+// Analyzers implement Snapshotter and are registered with a Coordinator
+// under a name which stays the same across versions. The Coordinator
+// writes the state of all of them to a single file, replacing the previous
+// one atomically, and restores each analyzer from its section on startup:
+//
+//	c := &snapshot.Coordinator{}
+//	c.Register("flows", snapshot.Func{
+//		Save: func(w io.Writer) error { return table.Snapshot(w, codec) },
+//		Load: func(r io.Reader) error { return table.Restore(r, codec) },
+//	})
+//	c.Register("dns", correlator)
+//	if err := c.Load(path); err != nil && !os.IsNotExist(err) {
+//		log.Print(err)
+//	}
+//	...
+//	// Before exiting, with packet processing stopped:
+//	if err := c.Save(path); err != nil {
+//		log.Print(err)
+//	}
+//
+// The Coordinator calls the analyzers one after the other. For the saved
+// states to be consistent with each other, packet processing should be
+// paused while Save runs, for example by calling it from the goroutine
+// feeding the analyzers.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Snapshotter is implemented by analyzers whose state can be saved and
+// restored. The format of the state is up to the analyzer; it should be
+// versioned if the analyzer may change between saving and restoring.
+type Snapshotter interface {
+	// Snapshot writes the state of the analyzer to w.
+	Snapshot(w io.Writer) error
+	// Restore reads a state written by Snapshot from r, replacing or
+	// adding to the current state. It must read all of the state, as r
+	// ends where it does.
+	Restore(r io.Reader) error
+}
+
+// Func adapts a pair of functions to the Snapshotter interface.
+type Func struct {
+	Save func(w io.Writer) error
+	Load func(r io.Reader) error
+}
+
+// Snapshot calls f.Save.
+func (f Func) Snapshot(w io.Writer) error { return f.Save(w) }
+
+// Restore calls f.Load.
+func (f Func) Restore(r io.Reader) error { return f.Load(r) }
+
+// magic starts every snapshot file, followed by the format version.
+const magic = "gopacket snapshot\x00\x01"
+
+// Coordinator saves and restores the state of registered analyzers. The
+// zero value is ready to use. A Coordinator is safe for concurrent use, but
+// Save and Load do not stop the analyzers.
+type Coordinator struct {
+	mu        sync.Mutex
+	analyzers map[string]Snapshotter
+}
+
+// Register adds s to the analyzers saved and restored under name. It
+// returns an error if name is empty or already registered.
+func (c *Coordinator) Register(name string, s Snapshotter) error {
+	if name == "" {
+		return errors.New("snapshot: empty name")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.analyzers == nil {
+		c.analyzers = make(map[string]Snapshotter)
+	}
+	if _, ok := c.analyzers[name]; ok {
+		return fmt.Errorf("snapshot: %q already registered", name)
+	}
+	c.analyzers[name] = s
+	return nil
+}
+
+// Unregister removes the analyzer registered under name.
+func (c *Coordinator) Unregister(name string) {
+	c.mu.Lock()
+	delete(c.analyzers, name)
+	c.mu.Unlock()
+}
+
+// SaveTo writes the state of all registered analyzers to w, in the order
+// of their names. Each state is checksummed so corruption is detected by
+// LoadFrom.
+func (c *Coordinator) SaveTo(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.analyzers))
+	for name := range c.analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(magic)
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.Reset()
+		if err := c.analyzers[name].Snapshot(&buf); err != nil {
+			return fmt.Errorf("snapshot: saving %q: %v", name, err)
+		}
+		writeBytes(bw, []byte(name))
+		writeBytes(bw, buf.Bytes())
+		var sum [4]byte
+		binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(buf.Bytes()))
+		bw.Write(sum[:])
+	}
+	// An empty name ends the snapshot, so truncated files are detected.
+	writeBytes(bw, nil)
+	return bw.Flush()
+}
+
+func writeBytes(w *bufio.Writer, b []byte) {
+	var n [binary.MaxVarintLen64]byte
+	w.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+	w.Write(b)
+}
+
+// maxSection bounds the size of the state of a single analyzer read by
+// LoadFrom, so a corrupted length does not exhaust memory.
+const maxSection = 1 << 30
+
+// LoadFrom reads a snapshot written by SaveTo from r and restores the
+// registered analyzers from it. The whole snapshot is read and checked
+// before any analyzer is restored. Analyzers without a state in the
+// snapshot are left alone, and states of analyzers which are not
+// registered are skipped, so analyzers can be added and removed between
+// versions.
+func (c *Coordinator) LoadFrom(r io.Reader) error {
+	cr := bufio.NewReader(r)
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(cr, head); err != nil {
+		return fmt.Errorf("snapshot: reading header: %v", err)
+	}
+	if string(head) != magic {
+		return errors.New("snapshot: not a snapshot or unsupported version")
+	}
+	states := make(map[string][]byte)
+	for {
+		name, err := readBytes(cr)
+		if err != nil {
+			return fmt.Errorf("snapshot: reading name: %v", err)
+		}
+		if len(name) == 0 {
+			break
+		}
+		state, err := readBytes(cr)
+		if err != nil {
+			return fmt.Errorf("snapshot: reading %q: %v", name, err)
+		}
+		var sum [4]byte
+		if _, err := io.ReadFull(cr, sum[:]); err != nil {
+			return fmt.Errorf("snapshot: reading %q: %v", name, err)
+		}
+		if binary.BigEndian.Uint32(sum[:]) != crc32.ChecksumIEEE(state) {
+			return fmt.Errorf("snapshot: checksum mismatch in %q", name)
+		}
+		states[string(name)] = state
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, s := range c.analyzers {
+		state, ok := states[name]
+		if !ok {
+			continue
+		}
+		if err := s.Restore(bytes.NewReader(state)); err != nil {
+			return fmt.Errorf("snapshot: restoring %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n > maxSection {
+		return nil, fmt.Errorf("length %d too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Save writes the state of all registered analyzers to the file at path.
+// The snapshot is written to a temporary file in the same directory, synced
+// and renamed over path, so path always holds a complete snapshot, either
+// the previous or the new one.
+func (c *Coordinator) Save(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = c.SaveTo(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Load restores the registered analyzers from the snapshot at path, as
+// LoadFrom does. If there is no snapshot, the error satisfies
+// os.IsNotExist.
+func (c *Coordinator) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.LoadFrom(f)
+}
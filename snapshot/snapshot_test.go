@@ -0,0 +1,124 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// counter is a trivial analyzer.
+type counter struct {
+	n byte
+}
+
+func (c *counter) Snapshot(w io.Writer) error {
+	_, err := w.Write([]byte{c.n})
+	return err
+}
+
+func (c *counter) Restore(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(b) != 1 {
+		return errors.New("bad state")
+	}
+	c.n = b[0]
+	return nil
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state")
+
+	a, b := &counter{n: 1}, &counter{n: 2}
+	var c Coordinator
+	c.Register("a", a)
+	c.Register("b", b)
+	if err := c.Register("a", b); err == nil {
+		t.Error("registered a twice")
+	}
+	if err := c.Register("", b); err == nil {
+		t.Error("registered an empty name")
+	}
+	if err := c.Load(path); !os.IsNotExist(err) {
+		t.Errorf("got error %v loading missing snapshot", err)
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new version drops "b" and adds "c".
+	a2, c2 := &counter{}, &counter{n: 9}
+	var next Coordinator
+	next.Register("a", a2)
+	next.Register("c", c2)
+	if err := next.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if a2.n != 1 || c2.n != 9 {
+		t.Errorf("restored a=%d c=%d, want 1 and 9", a2.n, c2.n)
+	}
+
+	// Saving again replaces the file and leaves no temporary files.
+	a2.n = 5
+	if err := next.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("got %d files, want 1", len(files))
+	}
+	if err := c.Load(path); err != nil || a.n != 5 || b.n != 2 {
+		t.Errorf("reloaded a=%d b=%d, %v", a.n, b.n, err)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	a := &counter{n: 1}
+	var c Coordinator
+	c.Register("a", a)
+	c.Register("b", Func{
+		Save: func(w io.Writer) error { _, err := w.Write([]byte("state of b")); return err },
+		Load: func(r io.Reader) error { return nil },
+	})
+	var buf bytes.Buffer
+	if err := c.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	flipped := append([]byte(nil), good...)
+	flipped[len(flipped)-8] ^= 1
+	for name, data := range map[string][]byte{
+		"truncated": good[:len(good)-1],
+		"flipped":   flipped,
+		"garbage":   []byte("not a snapshot at all"),
+	} {
+		a.n = 7
+		if err := c.LoadFrom(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if a.n != 7 {
+			t.Errorf("%s: analyzer restored from corrupt snapshot", name)
+		}
+	}
+
+	if err := c.LoadFrom(bytes.NewReader(good)); err != nil || a.n != 1 {
+		t.Errorf("restored a=%d, %v", a.n, err)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package statsassembly reassembles TCP streams and reports statistics on
+// each of them once it completes. It is the library behind the
+// statsassembly example, for programs which embed it:
+//
+//	c := &statsassembly.Collector{
+//		OnComplete: func(s *statsassembly.StreamStats) {
+//			log.Printf("%v:%v %d bytes", s.Net, s.Transport, s.Bytes)
+//		},
+//	}
+//	totals := c.Run(handle, done)
+//
+// Packets are decoded with a gopacket.DecodingLayerParser, which only
+// knows Ethernet, 802.1Q, IPv4, IPv6 and TCP, and read with
+// ZeroCopyReadPacketData, which is faster than ReadPacketData but reuses
+// its buffer.
+package statsassembly
+
+import (
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+)
+
+// DefaultFlushAfter is the default value of Collector.FlushAfter.
+const DefaultFlushAfter = 2 * time.Minute
+
+// StreamStats holds the statistics of a stream.
+type StreamStats struct {
+	Net, Transport gopacket.Flow
+	// Bytes and Packets count the reassembled data, OutOfOrder the packets
+	// seen before an earlier one, and Skipped the bytes which were never
+	// seen.
+	Bytes, Packets, OutOfOrder, Skipped int64
+	// Start and End are the timestamps of the first and last packets.
+	Start, End time.Time
+	// SawStart and SawEnd are true if the SYN and the FIN or RST of the
+	// stream were seen.
+	SawStart, SawEnd bool
+}
+
+// BytesPerSecond returns the byte rate of the stream, or 0 if all its
+// packets were seen at once.
+func (s *StreamStats) BytesPerSecond() float64 {
+	return rate(s.Bytes, s.End.Sub(s.Start))
+}
+
+// PacketsPerSecond returns the packet rate of the stream, or 0 if all its
+// packets were seen at once.
+func (s *StreamStats) PacketsPerSecond() float64 {
+	return rate(s.Packets, s.End.Sub(s.Start))
+}
+
+func rate(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+func (s *StreamStats) add(reassemblies []tcpassembly.Reassembly) {
+	for _, r := range reassemblies {
+		if s.Packets == 0 {
+			s.Start, s.End = r.Seen, r.Seen
+		}
+		if r.Seen.Before(s.End) {
+			s.OutOfOrder++
+		} else {
+			s.End = r.Seen
+		}
+		s.Bytes += int64(len(r.Bytes))
+		s.Packets++
+		if r.Skip > 0 {
+			s.Skipped += int64(r.Skip)
+		}
+		s.SawStart = s.SawStart || r.Start
+		s.SawEnd = s.SawEnd || r.End
+	}
+}
+
+// Totals counts the packets read by a Collector.
+type Totals struct {
+	Packets, Bytes int64
+	// DecodeErrors counts the packets which could not be decoded, and
+	// NotTCP the packets without an IPv4 or IPv6 and a TCP layer.
+	DecodeErrors, NotTCP int64
+	// ReadErrors counts the errors returned by the source.
+	ReadErrors int64
+}
+
+// Collector collects the statistics of TCP streams.
+type Collector struct {
+	// OnComplete is called with the statistics of each stream when it
+	// completes, or when it is flushed at the end of Run.
+	OnComplete func(*StreamStats)
+	// OnPacket, if not nil, is called with the layers decoded from every
+	// packet which could be decoded.
+	OnPacket func(decoded []gopacket.LayerType)
+	// MaxBufferedPagesPerConnection and MaxBufferedPagesTotal limit the
+	// packets buffered while waiting for missing data, as in
+	// tcpassembly.Assembler. Zero means no limit.
+	MaxBufferedPagesPerConnection int
+	MaxBufferedPagesTotal         int
+	// FlushAfter is how long, in local time, streams may wait for missing
+	// data before the gap is skipped. It defaults to DefaultFlushAfter.
+	FlushAfter time.Duration
+	// MaxPackets, if positive, stops Run after that many packets.
+	MaxPackets int64
+}
+
+type stream struct {
+	StreamStats
+	c *Collector
+}
+
+func (s *stream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+	s.add(reassemblies)
+}
+
+func (s *stream) ReassemblyComplete() {
+	if s.c.OnComplete != nil {
+		s.c.OnComplete(&s.StreamStats)
+	}
+}
+
+// Run collects the statistics of the streams of src until it returns
+// io.EOF, MaxPackets have been read or done is closed, and flushes all
+// streams before returning. Other errors of src, such as the timeouts of
+// live pcap handles, are counted and the read retried; they let Run flush
+// idle streams and notice that done is closed.
+func (c *Collector) Run(src gopacket.ZeroCopyPacketDataSource, done <-chan struct{}) Totals {
+	flushAfter := c.FlushAfter
+	if flushAfter <= 0 {
+		flushAfter = DefaultFlushAfter
+	}
+	assembler := tcpassembly.NewAssembler(tcpassembly.NewStreamPool(factory{c}))
+	assembler.MaxBufferedPagesPerConnection = c.MaxBufferedPagesPerConnection
+	assembler.MaxBufferedPagesTotal = c.MaxBufferedPagesTotal
+	defer assembler.FlushAll()
+
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var ip6 layers.IPv6
+	var ip6extensions layers.IPv6ExtensionSkipper
+	var tcp layers.TCP
+	var payload gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet,
+		&eth, &dot1q, &ip4, &ip6, &ip6extensions, &tcp, &payload)
+	parser.IgnoreUnsupported = true
+	decoded := make([]gopacket.LayerType, 0, 4)
+
+	var totals Totals
+	nextFlush := time.Now().Add(flushAfter / 2)
+	for c.MaxPackets <= 0 || totals.Packets < c.MaxPackets {
+		select {
+		case <-done:
+			return totals
+		default:
+		}
+		if now := time.Now(); now.After(nextFlush) {
+			assembler.FlushOlderThan(now.Add(-flushAfter))
+			nextFlush = now.Add(flushAfter / 2)
+		}
+
+		data, ci, err := src.ZeroCopyReadPacketData()
+		if err == io.EOF {
+			return totals
+		} else if err != nil {
+			totals.ReadErrors++
+			continue
+		}
+		totals.Packets++
+		totals.Bytes += int64(len(data))
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			totals.DecodeErrors++
+			continue
+		}
+		if c.OnPacket != nil {
+			c.OnPacket(decoded)
+		}
+		var netFlow gopacket.Flow
+		foundNetLayer, foundTCP := false, false
+		for _, typ := range decoded {
+			switch typ {
+			case layers.LayerTypeIPv4:
+				netFlow = ip4.NetworkFlow()
+				foundNetLayer = true
+			case layers.LayerTypeIPv6:
+				netFlow = ip6.NetworkFlow()
+				foundNetLayer = true
+			case layers.LayerTypeTCP:
+				foundTCP = true
+			}
+		}
+		if !foundNetLayer || !foundTCP {
+			totals.NotTCP++
+			continue
+		}
+		assembler.AssembleWithTimestamp(netFlow, &tcp, ci.Timestamp)
+	}
+	return totals
+}
+
+// factory creates a stream per connection for a Collector.
+type factory struct {
+	c *Collector
+}
+
+func (f factory) New(net, transport gopacket.Flow) tcpassembly.Stream {
+	return &stream{StreamStats: StreamStats{Net: net, Transport: transport}, c: f.c}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package statsassembly
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// source is a ZeroCopyPacketDataSource returning its packets in order, or
+// its errors in place of nil packets.
+type source struct {
+	packets [][]byte
+	err     error
+	now     time.Time
+}
+
+func (s *source) ZeroCopyReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(s.packets) == 0 {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	data := s.packets[0]
+	s.packets = s.packets[1:]
+	if data == nil {
+		return nil, gopacket.CaptureInfo{}, s.err
+	}
+	s.now = s.now.Add(time.Second)
+	return data, gopacket.CaptureInfo{Timestamp: s.now, CaptureLength: len(data), Length: len(data)}, nil
+}
+
+func packet(t *testing.T, transport gopacket.SerializableLayer, payload int) []byte {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	switch transport := transport.(type) {
+	case *layers.TCP:
+		ip.Protocol = layers.IPProtocolTCP
+		transport.SetNetworkLayerForChecksum(ip)
+	case *layers.UDP:
+		ip.Protocol = layers.IPProtocolUDP
+		transport.SetNetworkLayerForChecksum(ip)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, transport, gopacket.Payload(make([]byte, payload))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCollector(t *testing.T) {
+	src := &source{
+		packets: [][]byte{
+			packet(t, &layers.TCP{SrcPort: 1000, DstPort: 80, Seq: 100, SYN: true}, 0),
+			packet(t, &layers.TCP{SrcPort: 1000, DstPort: 80, Seq: 101, ACK: true}, 10),
+			nil,
+			packet(t, &layers.UDP{SrcPort: 53, DstPort: 53}, 10),
+			packet(t, &layers.TCP{SrcPort: 1000, DstPort: 80, Seq: 111, ACK: true}, 20),
+			packet(t, &layers.TCP{SrcPort: 1000, DstPort: 80, Seq: 131, FIN: true, ACK: true}, 0),
+			{0, 1, 2},
+		},
+		err: errors.New("timeout"),
+		now: time.Unix(1000, 0),
+	}
+	var streams []StreamStats
+	var decoded int
+	c := &Collector{
+		OnComplete: func(s *StreamStats) { streams = append(streams, *s) },
+		OnPacket:   func([]gopacket.LayerType) { decoded++ },
+	}
+	totals := c.Run(src, nil)
+
+	if want := (Totals{Packets: 6, Bytes: totals.Bytes, DecodeErrors: 1, NotTCP: 1, ReadErrors: 1}); totals != want {
+		t.Errorf("got totals %+v, want %+v", totals, want)
+	}
+	if decoded != 5 {
+		t.Errorf("got %d decoded packets, want 5", decoded)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("got %d streams, want 1", len(streams))
+	}
+	s := streams[0]
+	if s.Bytes != 30 || !s.SawStart || !s.SawEnd || s.OutOfOrder != 0 || s.Skipped != 0 {
+		t.Errorf("got stream %+v", s)
+	}
+	if d := s.End.Sub(s.Start); d != 4*time.Second {
+		t.Errorf("got duration %v, want 4s", d)
+	}
+}
+
+func TestCollectorMaxPackets(t *testing.T) {
+	src := &source{packets: [][]byte{
+		packet(t, &layers.TCP{SrcPort: 1000, DstPort: 80, Seq: 100, SYN: true}, 0),
+		packet(t, &layers.TCP{SrcPort: 1000, DstPort: 80, Seq: 101, ACK: true}, 10),
+	}}
+	var streams int
+	c := &Collector{MaxPackets: 1, OnComplete: func(*StreamStats) { streams++ }}
+	if totals := c.Run(src, nil); totals.Packets != 1 {
+		t.Errorf("read %d packets, want 1", totals.Packets)
+	}
+	if streams != 1 {
+		t.Errorf("flushed %d streams, want 1", streams)
+	}
+}
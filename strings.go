@@ -0,0 +1,57 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package gopacket
+
+import "unsafe"
+
+// UnsafeString returns a string sharing its memory with b, without copying.
+//
+// Go strings are assumed to be immutable, so the result is only valid for
+// as long as b is not modified. For byte slices taken from decoded layers
+// this means: for as long as the packet data is not reused (watch out for
+// NoCopy decoding and ZeroCopyReadPacketData sources) and, for layers which
+// decode into their own buffers such as DNS names, until the layer is used
+// to decode another packet. Copy the string with string([]byte(s)) if it
+// has to outlive that.
+func UnsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// StringInterner converts byte slices to strings, returning the same string
+// for equal inputs. Converting a value seen before does not allocate, which
+// makes it a safe alternative to UnsafeString for fields that repeat a lot,
+// like DNS names or LLDP system names.
+//
+// The zero value is ready to use. A StringInterner is not safe for
+// concurrent use.
+type StringInterner struct {
+	// MaxEntries bounds the number of strings kept. Once it is reached the
+	// table is emptied and starts over. Zero means no limit.
+	MaxEntries int
+
+	m map[string]string
+}
+
+// String returns b as a string.
+func (s *StringInterner) String(b []byte) string {
+	// The compiler does not allocate for string(b) used as a map key.
+	if str, ok := s.m[string(b)]; ok {
+		return str
+	}
+	if s.m == nil || (s.MaxEntries > 0 && len(s.m) >= s.MaxEntries) {
+		s.m = make(map[string]string)
+	}
+	str := string(b)
+	s.m[str] = str
+	return str
+}
+
+// Len returns the number of strings currently interned.
+func (s *StringInterner) Len() int { return len(s.m) }
@@ -0,0 +1,51 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package gopacket
+
+import "testing"
+
+func TestUnsafeString(t *testing.T) {
+	b := []byte("example.com")
+	var s string
+	if allocs := testing.AllocsPerRun(100, func() { s = UnsafeString(b) }); allocs != 0 {
+		t.Errorf("UnsafeString allocated %v times", allocs)
+	}
+	if s != "example.com" {
+		t.Errorf("got %q", s)
+	}
+	// The string shares memory with b.
+	b[0] = 'E'
+	if s != "Example.com" {
+		t.Errorf("got %q after changing the slice", s)
+	}
+	if UnsafeString(nil) != "" {
+		t.Error("UnsafeString(nil) is not empty")
+	}
+}
+
+func TestStringInterner(t *testing.T) {
+	var in StringInterner
+	in.MaxEntries = 2
+	b := []byte("a.example")
+	first := in.String(b)
+	if allocs := testing.AllocsPerRun(100, func() { in.String(b) }); allocs != 0 {
+		t.Errorf("interned lookup allocated %v times", allocs)
+	}
+	// Interned strings do not share memory with the input.
+	b[0] = 'b'
+	if first != "a.example" {
+		t.Errorf("got %q after changing the slice", first)
+	}
+	in.String([]byte("c.example"))
+	if in.Len() != 2 {
+		t.Errorf("got %d entries, want 2", in.Len())
+	}
+	in.String([]byte("d.example"))
+	if in.Len() != 1 {
+		t.Errorf("got %d entries after reaching MaxEntries, want 1", in.Len())
+	}
+}
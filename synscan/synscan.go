@@ -0,0 +1,241 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package synscan implements a TCP SYN scanner over a packet handle. It is
+// the library behind the synscan example, for programs which embed it:
+//
+//	router, _ := routing.New()
+//	iface, gw, src, _ := router.Route(dst)
+//	handle, _ := pcap.OpenLive(iface.Name, 65536, true, time.Second/10)
+//	s := &synscan.Scanner{Handle: handle, HardwareAddr: iface.HardwareAddr, Src: src, Gateway: gw}
+//	result, err := s.Scan(dst, done)
+//
+// The scanner resolves the hardware address of the gateway, or of the
+// target if there is none, with ARP, then sends a SYN to each port and
+// reads the answers until none has come for Timeout. It only scans IPv4
+// targets on Ethernet interfaces. Handles should have a read timeout, so
+// that Scan notices when its timeouts expire or done is closed.
+package synscan
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Default values of the Scanner fields.
+const (
+	DefaultSrcPort    layers.TCPPort = 54321
+	DefaultARPTimeout                = 3 * time.Second
+	DefaultTimeout                   = 5 * time.Second
+)
+
+// Handle reads and writes packets, as a *pcap.Handle does. ReadPacketData
+// may return any error other than io.EOF when no packet is available.
+type Handle interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	WritePacketData([]byte) error
+}
+
+// ErrDone is returned by Scan when done is closed.
+var ErrDone = errors.New("synscan: scan canceled")
+
+// Scanner scans the TCP ports of hosts. It is not safe for concurrent use.
+type Scanner struct {
+	Handle Handle
+	// HardwareAddr and Src are the hardware and IPv4 addresses of the
+	// interface of Handle, and Gateway the router to send packets through,
+	// or nil for targets on the local network.
+	HardwareAddr net.HardwareAddr
+	Src, Gateway net.IP
+	// Ports lists the ports to scan, 1 to 65535 if empty.
+	Ports []layers.TCPPort
+	// SrcPort is the port SYNs are sent from, DefaultSrcPort if zero.
+	SrcPort layers.TCPPort
+	// ARPTimeout is how long to wait for an ARP reply, and Timeout how long
+	// to wait for answers after the last SYN. They default to
+	// DefaultARPTimeout and DefaultTimeout.
+	ARPTimeout, Timeout time.Duration
+	// OnError, if not nil, is called with errors sending SYNs, after
+	// which the scan goes on with the next port.
+	OnError func(port layers.TCPPort, err error)
+
+	buf gopacket.SerializeBuffer
+}
+
+// Result holds the ports of a host which answered a SYN, sorted.
+type Result struct {
+	// Open ports answered with a SYN-ACK, and Closed ones with a RST.
+	Open, Closed []layers.TCPPort
+}
+
+func (s *Scanner) send(l ...gopacket.SerializableLayer) error {
+	if s.buf == nil {
+		s.buf = gopacket.NewSerializeBuffer()
+	}
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(s.buf, opts, l...); err != nil {
+		return err
+	}
+	return s.Handle.WritePacketData(s.buf.Bytes())
+}
+
+// read returns the next packet read from the handle, or nil if none was
+// available.
+func (s *Scanner) read(done <-chan struct{}) (gopacket.Packet, error) {
+	select {
+	case <-done:
+		return nil, ErrDone
+	default:
+	}
+	data, _, err := s.Handle.ReadPacketData()
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, nil
+	}
+	return gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.NoCopy), nil
+}
+
+// hardwareAddr returns the hardware address of ip, sending an ARP request
+// and waiting for the reply.
+func (s *Scanner) hardwareAddr(ip net.IP, done <-chan struct{}) (net.HardwareAddr, error) {
+	timeout := s.ARPTimeout
+	if timeout <= 0 {
+		timeout = DefaultARPTimeout
+	}
+	eth := layers.Ethernet{
+		SrcMAC:       s.HardwareAddr,
+		DstMAC:       layers.EthernetBroadcast,
+		EthernetType: layers.EthernetTypeARP,
+	}
+	arp := layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPRequest,
+		SourceHwAddress:   []byte(s.HardwareAddr),
+		SourceProtAddress: []byte(s.Src.To4()),
+		DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+		DstProtAddress:    []byte(ip),
+	}
+	if err := s.send(&eth, &arp); err != nil {
+		return nil, err
+	}
+	for start := time.Now(); time.Since(start) < timeout; {
+		packet, err := s.read(done)
+		if err != nil {
+			return nil, err
+		} else if packet == nil {
+			continue
+		}
+		if arp, ok := packet.Layer(layers.LayerTypeARP).(*layers.ARP); ok && arp.Operation == layers.ARPReply && net.IP(arp.SourceProtAddress).Equal(ip) {
+			return net.HardwareAddr(arp.SourceHwAddress), nil
+		}
+	}
+	return nil, errors.New("synscan: timeout getting ARP reply")
+}
+
+// Scan scans the ports of the IPv4 address dst. It returns ErrDone if done
+// is closed before the scan completes.
+func (s *Scanner) Scan(dst net.IP, done <-chan struct{}) (*Result, error) {
+	dst = dst.To4()
+	if dst == nil {
+		return nil, errors.New("synscan: target is not an IPv4 address")
+	}
+	src := s.Src.To4()
+	if src == nil {
+		return nil, errors.New("synscan: source is not an IPv4 address")
+	}
+	hop := dst
+	if s.Gateway != nil {
+		if hop = s.Gateway.To4(); hop == nil {
+			return nil, errors.New("synscan: gateway is not an IPv4 address")
+		}
+	}
+	hwaddr, err := s.hardwareAddr(hop, done)
+	if err != nil {
+		return nil, err
+	}
+	srcPort, timeout := s.SrcPort, s.Timeout
+	if srcPort == 0 {
+		srcPort = DefaultSrcPort
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ports := s.Ports
+	if len(ports) == 0 {
+		ports = make([]layers.TCPPort, 65535)
+		for i := range ports {
+			ports[i] = layers.TCPPort(i + 1)
+		}
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.HardwareAddr,
+		DstMAC:       hwaddr,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    src,
+		DstIP:    dst,
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+	}
+	tcp := layers.TCP{SrcPort: srcPort, SYN: true}
+	tcp.SetNetworkLayerForChecksum(&ip4)
+
+	// Answers are the packets of the reverse IPv4 flow, to the source
+	// port.
+	ipFlow := gopacket.NewFlow(layers.EndpointIPv4, dst, src)
+	seen := map[layers.TCPPort]bool{}
+	result := &Result{}
+	start := time.Now()
+	for next := 0; next < len(ports) || time.Since(start) < timeout; {
+		// Send one SYN per iteration until all ports have been sent
+		// one, then keep reading answers until the timeout.
+		if next < len(ports) {
+			start = time.Now()
+			tcp.DstPort = ports[next]
+			next++
+			if err := s.send(&eth, &ip4, &tcp); err != nil && s.OnError != nil {
+				s.OnError(tcp.DstPort, err)
+			}
+		}
+		packet, err := s.read(done)
+		if err != nil {
+			return nil, err
+		} else if packet == nil {
+			continue
+		}
+		if net := packet.NetworkLayer(); net == nil || net.NetworkFlow() != ipFlow {
+			continue
+		}
+		answer, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok || answer.DstPort != srcPort || seen[answer.SrcPort] {
+			continue
+		}
+		if answer.RST {
+			result.Closed = append(result.Closed, answer.SrcPort)
+		} else if answer.SYN && answer.ACK {
+			result.Open = append(result.Open, answer.SrcPort)
+		} else {
+			continue
+		}
+		seen[answer.SrcPort] = true
+	}
+	sort.Slice(result.Open, func(i, j int) bool { return result.Open[i] < result.Open[j] })
+	sort.Slice(result.Closed, func(i, j int) bool { return result.Closed[i] < result.Closed[j] })
+	return result, nil
+}
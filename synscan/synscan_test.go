@@ -0,0 +1,130 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package synscan
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	scannerMAC = net.HardwareAddr{0, 1, 2, 3, 4, 5}
+	targetMAC  = net.HardwareAddr{0, 1, 2, 3, 4, 6}
+	scannerIP  = net.IP{10, 0, 0, 1}
+	targetIP   = net.IP{10, 0, 0, 2}
+)
+
+var errTimeout = errors.New("timeout")
+
+// host is a Handle simulating a host which answers ARP requests, and SYNs
+// to its open ports with a SYN-ACK and to others with a RST.
+type host struct {
+	t       *testing.T
+	open    map[layers.TCPPort]bool
+	pending [][]byte
+}
+
+func (h *host) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(h.pending) == 0 {
+		return nil, gopacket.CaptureInfo{}, errTimeout
+	}
+	data := h.pending[0]
+	h.pending = h.pending[1:]
+	return data, gopacket.CaptureInfo{CaptureLength: len(data), Length: len(data)}, nil
+}
+
+func (h *host) WritePacketData(data []byte) error {
+	p := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	eth := &layers.Ethernet{SrcMAC: targetMAC, DstMAC: scannerMAC}
+	var answer []gopacket.SerializableLayer
+	if arp, ok := p.Layer(layers.LayerTypeARP).(*layers.ARP); ok {
+		if !net.IP(arp.DstProtAddress).Equal(targetIP) {
+			return nil
+		}
+		eth.EthernetType = layers.EthernetTypeARP
+		answer = []gopacket.SerializableLayer{eth, &layers.ARP{
+			AddrType:          layers.LinkTypeEthernet,
+			Protocol:          layers.EthernetTypeIPv4,
+			HwAddressSize:     6,
+			ProtAddressSize:   4,
+			Operation:         layers.ARPReply,
+			SourceHwAddress:   targetMAC,
+			SourceProtAddress: targetIP,
+			DstHwAddress:      arp.SourceHwAddress,
+			DstProtAddress:    arp.SourceProtAddress,
+		}}
+	} else if syn, ok := p.Layer(layers.LayerTypeTCP).(*layers.TCP); ok && syn.SYN {
+		eth.EthernetType = layers.EthernetTypeIPv4
+		ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: targetIP, DstIP: scannerIP}
+		tcp := &layers.TCP{SrcPort: syn.DstPort, DstPort: syn.SrcPort, ACK: true, Ack: syn.Seq + 1}
+		if h.open[syn.DstPort] {
+			tcp.SYN = true
+		} else {
+			tcp.RST = true
+		}
+		tcp.SetNetworkLayerForChecksum(ip)
+		answer = []gopacket.SerializableLayer{eth, ip, tcp}
+	} else {
+		h.t.Errorf("unexpected packet %v", p)
+		return nil
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, answer...); err != nil {
+		return err
+	}
+	h.pending = append(h.pending, buf.Bytes())
+	return nil
+}
+
+func TestScan(t *testing.T) {
+	h := &host{t: t, open: map[layers.TCPPort]bool{22: true, 443: true}}
+	s := &Scanner{
+		Handle:       h,
+		HardwareAddr: scannerMAC,
+		Src:          scannerIP,
+		Ports:        []layers.TCPPort{21, 22, 80, 443},
+		Timeout:      10 * time.Millisecond,
+	}
+	result, err := s.Scan(targetIP, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Result{Open: []layers.TCPPort{22, 443}, Closed: []layers.TCPPort{21, 80}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	h := &host{t: t}
+	s := &Scanner{
+		Handle:       h,
+		HardwareAddr: scannerMAC,
+		Src:          scannerIP,
+		Gateway:      net.IP{10, 0, 0, 254},
+		ARPTimeout:   10 * time.Millisecond,
+	}
+	if _, err := s.Scan(targetIP, nil); err == nil {
+		t.Error("scanned through a gateway which does not answer")
+	}
+	s.Gateway = nil
+	done := make(chan struct{})
+	close(done)
+	if _, err := s.Scan(targetIP, done); err != ErrDone {
+		t.Errorf("got %v, want ErrDone", err)
+	}
+	if _, err := s.Scan(net.ParseIP("2001:db8::1"), nil); err == nil {
+		t.Error("scanned an IPv6 target")
+	}
+}
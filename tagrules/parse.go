@@ -0,0 +1,210 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tagrules
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/fieldnames"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	s    string
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"}
+
+// keywords maps the names of operators to their symbols.
+var keywords = map[string]string{
+	"eq": "==", "ne": "!=", "lt": "<", "le": "<=", "gt": ">", "ge": ">=",
+	"and": "&&", "or": "||", "not": "!", "contains": "contains",
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return tokens, nil
+		}
+		if s[0] == '"' {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string %s", s)
+			}
+			unquoted, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[:end+1])
+			}
+			tokens = append(tokens, token{tokenString, unquoted})
+			s = s[end+1:]
+			continue
+		}
+		op := ""
+		for _, o := range operators {
+			if strings.HasPrefix(s, o) {
+				op = o
+				break
+			}
+		}
+		if op != "" {
+			tokens = append(tokens, token{tokenOp, op})
+			s = s[len(op):]
+			continue
+		}
+		n := strings.IndexAny(s, " \t\r\n\"=!<>&|()")
+		if n < 0 {
+			n = len(s)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("unexpected %q", s[:1])
+		}
+		if op, ok := keywords[s[:n]]; ok {
+			tokens = append(tokens, token{tokenOp, op})
+		} else {
+			tokens = append(tokens, token{tokenWord, s[:n]})
+		}
+		s = s[n:]
+	}
+}
+
+type parser struct {
+	tokens []token
+}
+
+func (p *parser) peek() token {
+	if len(p.tokens) == 0 {
+		return token{}
+	}
+	return p.tokens[0]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOp && t.s == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// parse parses a filter expression.
+func parse(s string) (node, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty filter")
+	}
+	p := &parser{tokens}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q", t.s)
+	}
+	return n, nil
+}
+
+func (p *parser) or() (node, error) {
+	x, err := p.and()
+	for err == nil && p.accept("||") {
+		var y node
+		if y, err = p.and(); err == nil {
+			x = orNode{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) and() (node, error) {
+	x, err := p.not()
+	for err == nil && p.accept("&&") {
+		var y node
+		if y, err = p.not(); err == nil {
+			x = andNode{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) not() (node, error) {
+	if p.accept("!") {
+		x, err := p.not()
+		return notNode{x}, err
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.accept("(") {
+		x, err := p.or()
+		if err == nil && !p.accept(")") {
+			err = errors.New("missing )")
+		}
+		return x, err
+	}
+	t := p.next()
+	if t.kind != tokenWord {
+		if t.kind == tokenEOF {
+			return nil, errors.New("unexpected end of filter")
+		}
+		return nil, fmt.Errorf("unexpected %q", t.s)
+	}
+	f, ok := fieldnames.Lookup(t.s)
+	if !ok {
+		if lt, ok := fieldnames.Protocol(t.s); ok {
+			return protocolNode{lt}, nil
+		}
+		return nil, fmt.Errorf("unknown field %q", t.s)
+	}
+	op := p.peek()
+	if op.kind != tokenOp {
+		return fieldNode{f: f}, nil
+	}
+	switch op.s {
+	case "==", "!=", "<", "<=", ">", ">=", "contains":
+	default:
+		return fieldNode{f: f}, nil
+	}
+	p.next()
+	switch v := p.next(); v.kind {
+	case tokenWord:
+		return fieldNode{f, op.s, newValue(v.s, false)}, nil
+	case tokenString:
+		return fieldNode{f, op.s, newValue(v.s, true)}, nil
+	}
+	return nil, fmt.Errorf("missing value after %s %s", t.s, op.s)
+}
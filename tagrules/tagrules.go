@@ -0,0 +1,273 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package tagrules tags packets as they are decoded, with rules matching
+// display filter expressions, so that packet browsers built on gopacket
+// can color packets and sort them by importance without dissecting them
+// again. Tags are stored in the packets' metadata, highest priority first.
+//
+//	rules, err := tagrules.Compile([]tagrules.Rule{
+//		{Filter: "tcp.flags.reset == 1", Tag: "reset", Color: "#a40000", Priority: 10},
+//		{Filter: "dns || ip.dst == 224.0.0.0/4", Tag: "noise", Color: "#c0c0c0"},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	packet := rules.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+//	if tags := packet.Metadata().Tags; len(tags) > 0 {
+//		fmt.Println(tags[0].Color)
+//	}
+//
+// Filters use Wireshark's field names, as mapped by package fieldnames, and
+// a subset of its display filter syntax:
+//
+//	tcp                     a layer of the protocol is present
+//	tcp.flags.syn           the field is present
+//	tcp.dstport == 443      comparison with ==, !=, <, <=, > or >=, or
+//	                        their names eq, ne, lt, le, gt and ge
+//	ip.src == 10.0.0.0/8    IP addresses match CIDR blocks
+//	dns.qry.name contains "example"
+//	!x, x && y, x || y      negation, conjunction and disjunction, also
+//	                        spelled not, and and or, with parentheses
+//
+// A comparison is true if any occurrence of the field satisfies it, except
+// for != which is true if none is equal. Numbers may be written in decimal
+// or in hexadecimal with a 0x prefix, and booleans as true, false, 1 or 0.
+// Values which are neither numbers nor addresses are compared with the
+// string form of fields, such as "SYN" for enumerations.
+package tagrules
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/fieldnames"
+)
+
+// Rule tags the packets matching Filter.
+type Rule struct {
+	Filter   string
+	Tag      string
+	Color    string
+	Priority int
+}
+
+// Rules is a compiled set of rules. It is safe for concurrent use.
+type Rules struct {
+	rules   []Rule
+	filters []node
+}
+
+// Compile compiles rules, returning an error naming the first rule whose
+// filter is invalid.
+func Compile(rules []Rule) (*Rules, error) {
+	r := &Rules{rules: append([]Rule(nil), rules...)}
+	for _, rule := range rules {
+		n, err := parse(rule.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("tagrules: rule %q: %v", rule.Tag, err)
+		}
+		r.filters = append(r.filters, n)
+	}
+	return r, nil
+}
+
+// Match returns the tags of the rules matching packet, highest priority
+// first and in rule order among equal priorities.
+func (r *Rules) Match(packet gopacket.Packet) []gopacket.PacketTag {
+	var tags []gopacket.PacketTag
+	for i, n := range r.filters {
+		if n.eval(packet) {
+			rule := &r.rules[i]
+			tags = append(tags, gopacket.PacketTag{Name: rule.Tag, Color: rule.Color, Priority: rule.Priority})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].Priority > tags[j].Priority })
+	return tags
+}
+
+// Apply stores the tags of the rules matching packet in its metadata,
+// replacing earlier ones. Packets decoded with the Lazy option are fully
+// decoded by Apply.
+func (r *Rules) Apply(packet gopacket.Packet) {
+	packet.Metadata().Tags = r.Match(packet)
+}
+
+// NewPacket decodes a packet like gopacket.NewPacket, and applies the
+// rules to it.
+func (r *Rules) NewPacket(data []byte, firstLayerDecoder gopacket.Decoder, options gopacket.DecodeOptions) gopacket.Packet {
+	packet := gopacket.NewPacket(data, firstLayerDecoder, options)
+	r.Apply(packet)
+	return packet
+}
+
+type node interface {
+	eval(gopacket.Packet) bool
+}
+
+type andNode struct{ x, y node }
+
+func (n andNode) eval(p gopacket.Packet) bool { return n.x.eval(p) && n.y.eval(p) }
+
+type orNode struct{ x, y node }
+
+func (n orNode) eval(p gopacket.Packet) bool { return n.x.eval(p) || n.y.eval(p) }
+
+type notNode struct{ x node }
+
+func (n notNode) eval(p gopacket.Packet) bool { return !n.x.eval(p) }
+
+// protocolNode matches packets with a layer of type t.
+type protocolNode struct{ t gopacket.LayerType }
+
+func (n protocolNode) eval(p gopacket.Packet) bool { return p.Layer(n.t) != nil }
+
+// fieldNode matches packets with an occurrence of f, satisfying the
+// comparison with v if op is not empty.
+type fieldNode struct {
+	f  fieldnames.Field
+	op string
+	v  *value
+}
+
+func (n fieldNode) eval(p gopacket.Packet) bool {
+	op := n.op
+	if op == "!=" {
+		op = "=="
+	}
+	found := false
+	for _, l := range p.Layers() {
+		for _, x := range n.f.Values(l) {
+			if op == "" || compare(x, op, n.v) {
+				found = true
+				break
+			}
+		}
+	}
+	if n.op == "!=" {
+		return !found
+	}
+	return found
+}
+
+// value is a literal of a comparison, with its interpretations.
+type value struct {
+	s       string
+	num     *int64
+	boolean *bool
+	ip      net.IP
+	ipnet   *net.IPNet
+	mac     net.HardwareAddr
+}
+
+func newValue(s string, quoted bool) *value {
+	v := &value{s: s}
+	if quoted {
+		return v
+	}
+	if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+		v.num = &n
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false" || v.num != nil) {
+		v.boolean = &b
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		v.ip = ip
+	} else if _, ipnet, err := net.ParseCIDR(s); err == nil {
+		v.ipnet = ipnet
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		v.mac = mac
+	}
+	return v
+}
+
+// compare returns whether x op v holds.
+func compare(x interface{}, op string, v *value) bool {
+	switch x := x.(type) {
+	case net.IP:
+		if v.ipnet != nil && op == "==" {
+			return v.ipnet.Contains(x)
+		}
+		if v.ip != nil && op == "==" {
+			return v.ip.Equal(x)
+		}
+		return false
+	case net.HardwareAddr:
+		return v.mac != nil && op == "==" && bytes.Equal(v.mac, x)
+	case []byte:
+		return compareStrings(string(x), op, v.s)
+	case string:
+		return compareStrings(x, op, v.s)
+	}
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Bool:
+		if v.boolean != nil {
+			return op == "==" && rv.Bool() == *v.boolean
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.num != nil {
+			return compareInts(rv.Int(), op, *v.num)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.num != nil {
+			if *v.num < 0 {
+				return op == ">" || op == ">="
+			}
+			return compareUints(rv.Uint(), op, uint64(*v.num))
+		}
+	}
+	return compareStrings(fmt.Sprint(x), op, v.s)
+}
+
+func compareInts(a int64, op string, b int64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func compareUints(a uint64, op string, b uint64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func compareStrings(a, op, b string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "contains":
+		return strings.Contains(a, b)
+	}
+	return false
+}
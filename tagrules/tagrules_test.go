@@ -0,0 +1,134 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tagrules
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func tcpPacket(t *testing.T, dst string, dstPort layers.TCPPort, syn bool) []byte {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{6, 7, 8, 9, 10, 11},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.IPv4(192, 0, 2, 1),
+		DstIP:    net.ParseIP(dst),
+	}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: dstPort, SYN: syn, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFilters(t *testing.T) {
+	p := gopacket.NewPacket(tcpPacket(t, "10.1.2.3", 443, true), layers.LayerTypeEthernet, gopacket.Default)
+	for _, test := range []struct {
+		filter string
+		want   bool
+	}{
+		{"tcp", true},
+		{"udp", false},
+		{"tcp.dstport == 443", true},
+		{"tcp.dstport eq 0x1bb", true},
+		{"tcp.dstport != 443", false},
+		{"tcp.dstport < 1024 && tcp.dstport >= 443", true},
+		{"tcp.dstport > 443", false},
+		{"tcp.flags.syn == 1 and not tcp.flags.ack == true", true},
+		{"tcp.flags.syn == false", false},
+		{"ip.dst == 10.0.0.0/8", true},
+		{"ip.dst == 10.1.2.4", false},
+		{"ip.src == 10.0.0.0/8 || ip.dst == 10.1.2.3", true},
+		{"!(udp || ip.ttl < 10)", true},
+		{"eth.src == 00:01:02:03:04:05", true},
+		{"ip.proto == TCP", true},
+		{"ip.proto contains \"CP\"", true},
+		{"udp.dstport", false},
+		{"udp.dstport != 53", true},
+	} {
+		n, err := parse(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if got := n.eval(p); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestInvalidFilters(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"tcp.nonexistent",
+		"tcp.dstport ==",
+		"(tcp",
+		"tcp)",
+		"tcp &&",
+		"dns.qry.name contains \"unterminated",
+		"tcp.dstport = 1",
+	} {
+		if _, err := parse(filter); err == nil {
+			t.Errorf("%q: parsed", filter)
+		}
+	}
+	if _, err := Compile([]Rule{{Filter: "tcp"}, {Filter: "tcp ||", Tag: "bad"}}); err == nil {
+		t.Error("compiled an invalid rule")
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := Compile([]Rule{
+		{Filter: "tcp", Tag: "tcp", Color: "#e7e6ff"},
+		{Filter: "tcp.flags.syn == 1", Tag: "syn", Color: "#a0a0a0", Priority: 5},
+		{Filter: "tcp.dstport == 443", Tag: "https", Priority: 5},
+		{Filter: "udp", Tag: "udp", Priority: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := rules.NewPacket(tcpPacket(t, "10.1.2.3", 443, true), layers.LayerTypeEthernet, gopacket.Default)
+	want := []gopacket.PacketTag{
+		{Name: "syn", Color: "#a0a0a0", Priority: 5},
+		{Name: "https", Priority: 5},
+		{Name: "tcp", Color: "#e7e6ff"},
+	}
+	if got := p.Metadata().Tags; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tags %+v, want %+v", got, want)
+	}
+
+	data, err := json.Marshal(p.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md gopacket.PacketMetadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(md.Tags, want) {
+		t.Errorf("got tags %+v after JSON round trip, want %+v", md.Tags, want)
+	}
+
+	p = rules.NewPacket(tcpPacket(t, "10.1.2.3", 80, false), layers.LayerTypeEthernet, gopacket.Default)
+	if got := p.Metadata().Tags; len(got) != 1 || got[0].Name != "tcp" {
+		t.Errorf("got tags %+v, want tcp", got)
+	}
+}
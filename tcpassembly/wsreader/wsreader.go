@@ -0,0 +1,440 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package wsreader provides an implementation for tcpassembly.Stream which
+// follows an HTTP connection through its WebSocket upgrade and decodes the
+// WebSocket (RFC 6455) frames exchanged afterwards into application
+// messages.
+//
+// Each direction of a connection is handled by its own Stream. The server
+// direction switches to WebSocket framing after a 101 Switching Protocols
+// response. The client direction switches once the server accepted its
+// upgrade request, so the two Streams of a connection must be linked
+// through their Peer fields; a rejected upgrade leaves both in HTTP:
+//
+//  type wsFactory struct {
+//  	pending map[[2]gopacket.Flow]*wsreader.Stream
+//  }
+//  func (f *wsFactory) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+//  	s := &wsreader.Stream{Handler: func(m wsreader.Message) {
+//  		fmt.Println(netFlow, tcpFlow, m.Opcode, len(m.Data))
+//  	}}
+//  	reverse := [2]gopacket.Flow{netFlow.Reverse(), tcpFlow.Reverse()}
+//  	if peer := f.pending[reverse]; peer != nil {
+//  		s.Peer, peer.Peer = peer, s
+//  		delete(f.pending, reverse)
+//  	} else {
+//  		f.pending[[2]gopacket.Flow{netFlow, tcpFlow}] = s
+//  	}
+//  	return s
+//  }
+//
+// Fragmented messages are reassembled before being handed to the Handler;
+// control frames are delivered as they arrive, even between fragments.
+// Messages compressed with the permessage-deflate extension are flagged
+// but not decompressed, since decompression needs the sliding window state
+// negotiated for the connection.
+package wsreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/tcpassembly"
+)
+
+// Opcode is the opcode of a WebSocket frame.
+type Opcode uint8
+
+// WebSocket opcodes.
+const (
+	OpContinuation Opcode = 0x0
+	OpText         Opcode = 0x1
+	OpBinary       Opcode = 0x2
+	OpClose        Opcode = 0x8
+	OpPing         Opcode = 0x9
+	OpPong         Opcode = 0xa
+)
+
+// IsControl reports whether o is a control opcode.
+func (o Opcode) IsControl() bool { return o&0x8 != 0 }
+
+func (o Opcode) String() string {
+	switch o {
+	case OpContinuation:
+		return "Continuation"
+	case OpText:
+		return "Text"
+	case OpBinary:
+		return "Binary"
+	case OpClose:
+		return "Close"
+	case OpPing:
+		return "Ping"
+	case OpPong:
+		return "Pong"
+	}
+	return fmt.Sprintf("Opcode(%d)", uint8(o))
+}
+
+// Frame is a single WebSocket frame.
+type Frame struct {
+	Fin              bool
+	RSV1, RSV2, RSV3 bool
+	Opcode           Opcode
+	Masked           bool
+	MaskKey          [4]byte
+	// Payload is the unmasked payload data.
+	Payload []byte
+}
+
+// ErrShortFrame is returned by DecodeFrame if data does not hold a whole
+// frame yet.
+var ErrShortFrame = errors.New("wsreader: incomplete frame")
+
+// ErrLostSync is stored in Stream.Err when bytes of an upgraded stream were
+// missed, after which frame boundaries can no longer be found.
+var ErrLostSync = errors.New("wsreader: stream data skipped, frame sync lost")
+
+// ErrTooLarge is stored in Stream.Err when an HTTP head, a frame or a
+// reassembled message exceeds the Stream's size limits.
+var ErrTooLarge = errors.New("wsreader: HTTP head or frame too large")
+
+// Default limits used by a Stream whose MaxHeadSize or MaxFrameSize is 0.
+const (
+	DefaultMaxHeadSize  = 64 << 10
+	DefaultMaxFrameSize = 16 << 20
+)
+
+// maxFrameHeader is the size of the largest frame header: 2 bytes, an 8 byte
+// extended length and a 4 byte masking key.
+const maxFrameHeader = 14
+
+// DecodeFrame decodes the frame at the start of data and returns it along
+// with the number of bytes it occupied. Masked payloads are unmasked into a
+// new slice; unmasked payloads point into data.
+func DecodeFrame(data []byte) (f Frame, n int, err error) {
+	if len(data) < 2 {
+		return f, 0, ErrShortFrame
+	}
+	f.Fin = data[0]&0x80 != 0
+	f.RSV1 = data[0]&0x40 != 0
+	f.RSV2 = data[0]&0x20 != 0
+	f.RSV3 = data[0]&0x10 != 0
+	f.Opcode = Opcode(data[0] & 0x0f)
+	f.Masked = data[1]&0x80 != 0
+
+	length := uint64(data[1] & 0x7f)
+	n = 2
+	switch length {
+	case 126:
+		if len(data) < n+2 {
+			return f, 0, ErrShortFrame
+		}
+		length = uint64(binary.BigEndian.Uint16(data[n:]))
+		n += 2
+	case 127:
+		if len(data) < n+8 {
+			return f, 0, ErrShortFrame
+		}
+		length = binary.BigEndian.Uint64(data[n:])
+		if length>>63 != 0 {
+			return f, 0, errors.New("wsreader: frame length has most significant bit set")
+		}
+		n += 8
+	}
+	if f.Opcode.IsControl() && (length > 125 || !f.Fin) {
+		return f, 0, fmt.Errorf("wsreader: invalid %v control frame", f.Opcode)
+	}
+	if f.Masked {
+		if len(data) < n+4 {
+			return f, 0, ErrShortFrame
+		}
+		copy(f.MaskKey[:], data[n:])
+		n += 4
+	}
+	if uint64(len(data)-n) < length {
+		return f, 0, ErrShortFrame
+	}
+	f.Payload = data[n : n+int(length)]
+	n += int(length)
+	if f.Masked {
+		p := make([]byte, len(f.Payload))
+		for i, b := range f.Payload {
+			p[i] = b ^ f.MaskKey[i%4]
+		}
+		f.Payload = p
+	}
+	return f, n, nil
+}
+
+// Message is a complete WebSocket message or control frame.
+type Message struct {
+	Opcode Opcode
+	Data   []byte
+	// Compressed is set if the message was sent with the permessage-deflate
+	// extension, in which case Data is still compressed.
+	Compressed bool
+	// Seen is the capture time of the data completing the message.
+	Seen time.Time
+}
+
+// Stream implements tcpassembly.Stream for one direction of a connection
+// which may be upgraded to WebSocket.
+type Stream struct {
+	// Handler is called with every decoded message. Data passed to it is
+	// owned by the handler.
+	Handler func(Message)
+	// Upgraded is set once the HTTP upgrade has been seen.
+	Upgraded bool
+	// Deflate is set if the upgrade negotiated permessage-deflate.
+	Deflate bool
+	// Peer is the Stream for the other direction of the connection. The
+	// client direction only switches to WebSocket framing once Peer has seen
+	// the server accept the upgrade; until then its data is held back, and
+	// without a Peer it never switches.
+	// Both Streams must be fed from the same goroutine.
+	Peer *Stream
+	// MaxHeadSize limits the size of an HTTP request or response head, and
+	// MaxFrameSize the payload of a frame or of a message reassembled from
+	// fragments. Exceeding either fails the stream with ErrTooLarge. Zero
+	// values mean DefaultMaxHeadSize and DefaultMaxFrameSize.
+	MaxHeadSize, MaxFrameSize int
+	// Err holds the first error found in the stream. Once set, the rest of
+	// the stream is ignored.
+	Err error
+
+	buf      []byte
+	skipBody int
+	msg      *Message
+	// requests and responses count the HTTP requests and final responses
+	// seen, so that a client Stream can match its upgrade request with the
+	// server's answer. awaiting holds the number of the pending upgrade
+	// request, or 0.
+	requests, responses int
+	awaiting            int
+}
+
+func (s *Stream) maxHeadSize() int {
+	if s.MaxHeadSize > 0 {
+		return s.MaxHeadSize
+	}
+	return DefaultMaxHeadSize
+}
+
+func (s *Stream) maxFrameSize() int {
+	if s.MaxFrameSize > 0 {
+		return s.MaxFrameSize
+	}
+	return DefaultMaxFrameSize
+}
+
+// Reassembled implements tcpassembly.Stream's Reassembled function.
+func (s *Stream) Reassembled(reassembly []tcpassembly.Reassembly) {
+	for _, r := range reassembly {
+		if r.Skip != 0 && (s.Upgraded || s.awaiting != 0 || len(s.buf) > 0 || s.skipBody > 0) {
+			s.fail(ErrLostSync)
+		}
+		s.Feed(r.Bytes, r.Seen)
+	}
+}
+
+// ReassemblyComplete implements tcpassembly.Stream's ReassemblyComplete
+// function.
+func (s *Stream) ReassemblyComplete() {
+	s.buf = nil
+	s.msg = nil
+}
+
+// Feed processes the next in-order bytes of the stream, seen at ts. It can
+// be used directly by callers doing their own reassembly.
+func (s *Stream) Feed(data []byte, ts time.Time) {
+	if s.Err != nil {
+		return
+	}
+	if !s.Upgraded && s.skipBody > 0 {
+		n := s.skipBody
+		if n > len(data) {
+			n = len(data)
+		}
+		s.skipBody -= n
+		data = data[n:]
+	}
+	s.buf = append(s.buf, data...)
+	for s.Err == nil && s.awaiting == 0 && len(s.buf) > 0 {
+		var n int
+		if s.Upgraded {
+			n = s.decodeFrame(ts)
+		} else {
+			n = s.decodeHTTPHead(ts)
+		}
+		if n == 0 {
+			break
+		}
+		s.buf = s.buf[n:]
+	}
+	// Whatever is left is a single incomplete head or frame, unless the
+	// stream waits for the answer to its upgrade request.
+	limit := s.maxHeadSize()
+	if s.Upgraded {
+		limit = maxFrameHeader + s.maxFrameSize()
+	} else if s.awaiting != 0 {
+		limit += maxFrameHeader + s.maxFrameSize()
+	}
+	if s.Err == nil && len(s.buf) > limit {
+		s.fail(ErrTooLarge)
+	}
+	if len(s.buf) == 0 {
+		s.buf = nil
+	}
+}
+
+func (s *Stream) fail(err error) {
+	if s.Err == nil {
+		s.Err = err
+	}
+	s.buf = nil
+	s.msg = nil
+}
+
+// upgradeAnswered is called by the server Stream once it answered the
+// client's pending upgrade request.
+func (s *Stream) upgradeAnswered(accepted, deflate bool, ts time.Time) {
+	s.awaiting = 0
+	s.Upgraded = accepted
+	s.Deflate = accepted && deflate
+	s.Feed(nil, ts)
+}
+
+// decodeHTTPHead consumes an HTTP request or response head from s.buf,
+// switching to WebSocket framing if it upgrades the connection. It returns
+// the number of bytes consumed, or 0 if the head is incomplete.
+func (s *Stream) decodeHTTPHead(ts time.Time) int {
+	end := bytes.Index(s.buf, []byte("\r\n\r\n"))
+	if end < 0 {
+		return 0
+	}
+	lines := strings.Split(string(s.buf[:end]), "\r\n")
+	headers := make(map[string]string)
+	for _, line := range lines[1:] {
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			headers[strings.ToLower(strings.TrimSpace(line[:i]))] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	n := end + 4
+
+	upgrade := strings.EqualFold(headers["upgrade"], "websocket")
+	deflate := strings.Contains(headers["sec-websocket-extensions"], "permessage-deflate")
+	first := strings.Fields(lines[0])
+	if len(first) >= 2 && strings.HasPrefix(first[0], "HTTP/") {
+		status, _ := strconv.Atoi(first[1])
+		if status >= 100 && status < 200 && status != 101 {
+			// Interim responses precede the final one.
+			return n
+		}
+		s.responses++
+		if upgrade && status == 101 {
+			s.Upgraded = true
+			s.Deflate = deflate
+		}
+		if p := s.Peer; p != nil && p.awaiting == s.responses {
+			p.upgradeAnswered(s.Upgraded, s.Deflate, ts)
+		}
+		if s.Upgraded {
+			return n
+		}
+	} else if len(first) >= 1 {
+		s.requests++
+		if upgrade && first[0] == "GET" {
+			// The server may have answered already if its direction was
+			// reassembled first.
+			if p := s.Peer; p != nil && p.responses >= s.requests {
+				s.Upgraded = p.Upgraded
+				s.Deflate = p.Upgraded && p.Deflate
+			} else {
+				s.awaiting = s.requests
+			}
+			return n
+		}
+	}
+
+	if strings.Contains(strings.ToLower(headers["transfer-encoding"]), "chunked") {
+		s.fail(errors.New("wsreader: chunked HTTP bodies are not supported"))
+		return 0
+	}
+	if cl, ok := headers["content-length"]; ok {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 {
+			s.fail(fmt.Errorf("wsreader: invalid Content-Length %q", cl))
+			return 0
+		}
+		if body := len(s.buf) - n; body < length {
+			s.skipBody = length - body
+			return len(s.buf)
+		}
+		n += length
+	}
+	return n
+}
+
+// decodeFrame consumes one frame from s.buf and returns its length, or 0 if
+// the frame is incomplete.
+func (s *Stream) decodeFrame(ts time.Time) int {
+	f, n, err := DecodeFrame(s.buf)
+	if err == ErrShortFrame {
+		return 0
+	} else if err != nil {
+		s.fail(err)
+		return 0
+	}
+	if len(f.Payload) > s.maxFrameSize() {
+		s.fail(ErrTooLarge)
+		return 0
+	}
+
+	switch {
+	case f.Opcode.IsControl():
+		s.deliver(Message{Opcode: f.Opcode, Data: append([]byte(nil), f.Payload...), Seen: ts})
+	case f.Opcode == OpContinuation:
+		if s.msg == nil {
+			s.fail(errors.New("wsreader: continuation frame without a message to continue"))
+			return 0
+		}
+		if len(s.msg.Data)+len(f.Payload) > s.maxFrameSize() {
+			s.fail(ErrTooLarge)
+			return 0
+		}
+		s.msg.Data = append(s.msg.Data, f.Payload...)
+	default:
+		if s.msg != nil {
+			s.fail(fmt.Errorf("wsreader: %v frame inside a fragmented message", f.Opcode))
+			return 0
+		}
+		s.msg = &Message{
+			Opcode:     f.Opcode,
+			Data:       append([]byte(nil), f.Payload...),
+			Compressed: s.Deflate && f.RSV1,
+		}
+	}
+	if !f.Opcode.IsControl() && f.Fin {
+		m := *s.msg
+		m.Seen = ts
+		s.msg = nil
+		s.deliver(m)
+	}
+	return n
+}
+
+func (s *Stream) deliver(m Message) {
+	if s.Handler != nil {
+		s.Handler(m)
+	}
+}
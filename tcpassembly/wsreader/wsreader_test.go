@@ -0,0 +1,181 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package wsreader
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/tcpassembly"
+)
+
+const testUpgradeResponse = "HTTP/1.1 101 Switching Protocols\r\n" +
+	"Upgrade: websocket\r\n" +
+	"Connection: Upgrade\r\n" +
+	"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n" +
+	"Sec-WebSocket-Extensions: permessage-deflate\r\n" +
+	"\r\n"
+
+func TestDecodeFrameMasked(t *testing.T) {
+	// "Hello" masked with 37 fa 21 3d, from RFC 6455 section 5.7.
+	data := []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58, 0xff}
+	f, n, err := DecodeFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 11 || !f.Fin || f.Opcode != OpText || !f.Masked || string(f.Payload) != "Hello" {
+		t.Errorf("got frame %+v, length %d", f, n)
+	}
+	if _, _, err := DecodeFrame(data[:10]); err != ErrShortFrame {
+		t.Errorf("got error %v for a short frame", err)
+	}
+}
+
+func TestDecodeFrameExtendedLength(t *testing.T) {
+	data := append([]byte{0x82, 0x7e, 0x01, 0x00}, make([]byte, 256)...)
+	f, n, err := DecodeFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) || f.Opcode != OpBinary || len(f.Payload) != 256 {
+		t.Errorf("got frame opcode %v, length %d, payload %d", f.Opcode, n, len(f.Payload))
+	}
+	if _, _, err := DecodeFrame([]byte{0x89, 0x7e, 0x00, 0x80}); err == nil || err == ErrShortFrame {
+		t.Errorf("got error %v for an oversized ping", err)
+	}
+}
+
+func TestStreamUpgradeAndFragments(t *testing.T) {
+	var got []Message
+	s := &Stream{Handler: func(m Message) { got = append(got, m) }}
+
+	stream := []byte("HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+	stream = append(stream, testUpgradeResponse...)
+	stream = append(stream,
+		0x01, 0x03, 'f', 'o', 'o', // first fragment
+		0x89, 0x01, 'p', // ping in between
+		0x80, 0x03, 'b', 'a', 'r', // last fragment
+		0xc1, 0x02, 0xaa, 0xbb, // compressed text
+		0x88, 0x02, 0x03, 0xe8, // close, 1000
+	)
+	// Feed the stream in small pieces to exercise buffering.
+	var rs []tcpassembly.Reassembly
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		rs = append(rs, tcpassembly.Reassembly{Bytes: stream[i:end]})
+	}
+	s.Reassembled(rs)
+	s.ReassemblyComplete()
+
+	if s.Err != nil {
+		t.Fatal(s.Err)
+	}
+	if !s.Upgraded || !s.Deflate {
+		t.Errorf("got upgraded %v, deflate %v", s.Upgraded, s.Deflate)
+	}
+	want := []Message{
+		{Opcode: OpPing, Data: []byte("p")},
+		{Opcode: OpText, Data: []byte("foobar")},
+		{Opcode: OpText, Data: []byte{0xaa, 0xbb}, Compressed: true},
+		{Opcode: OpClose, Data: []byte{0x03, 0xe8}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Opcode != want[i].Opcode || !bytes.Equal(got[i].Data, want[i].Data) || got[i].Compressed != want[i].Compressed {
+			t.Errorf("message %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStreamLostSync(t *testing.T) {
+	s := &Stream{}
+	s.Reassembled([]tcpassembly.Reassembly{
+		{Bytes: []byte(testUpgradeResponse)},
+		{Bytes: []byte{0x81, 0x01, 'a'}, Skip: 10},
+	})
+	if s.Err != ErrLostSync {
+		t.Errorf("got error %v, want %v", s.Err, ErrLostSync)
+	}
+}
+
+const testUpgradeRequest = "GET /chat HTTP/1.1\r\n" +
+	"Host: server.example.com\r\n" +
+	"Upgrade: websocket\r\n" +
+	"Connection: Upgrade\r\n" +
+	"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+	"Sec-WebSocket-Extensions: permessage-deflate\r\n" +
+	"\r\n"
+
+func newTestPair() (client, server *Stream, got *[]Message) {
+	got = new([]Message)
+	handler := func(m Message) { *got = append(*got, m) }
+	client = &Stream{Handler: handler}
+	server = &Stream{Handler: handler, Peer: client}
+	client.Peer = server
+	return client, server, got
+}
+
+func TestStreamClientWaitsForUpgrade(t *testing.T) {
+	client, server, got := newTestPair()
+	client.Feed([]byte(testUpgradeRequest), time.Time{})
+	client.Feed([]byte{0x81, 0x81, 0, 0, 0, 0, 'a'}, time.Time{})
+	if client.Upgraded || len(*got) != 0 {
+		t.Fatalf("client upgraded before the response: %v, %+v", client.Upgraded, *got)
+	}
+	server.Feed([]byte(testUpgradeResponse), time.Time{})
+	if client.Err != nil || server.Err != nil {
+		t.Fatal(client.Err, server.Err)
+	}
+	if !client.Upgraded || !client.Deflate || !server.Upgraded {
+		t.Errorf("got client upgraded %v, deflate %v, server upgraded %v", client.Upgraded, client.Deflate, server.Upgraded)
+	}
+	if len(*got) != 1 || (*got)[0].Opcode != OpText || string((*got)[0].Data) != "a" {
+		t.Errorf("got messages %+v", *got)
+	}
+}
+
+func TestStreamUpgradeRejected(t *testing.T) {
+	client, server, _ := newTestPair()
+	client.Feed([]byte(testUpgradeRequest), time.Time{})
+	client.Feed([]byte("GET / HTTP/1.1\r\n\r\n"), time.Time{})
+	server.Feed([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n"), time.Time{})
+	if client.Err != nil || server.Err != nil {
+		t.Fatal(client.Err, server.Err)
+	}
+	if client.Upgraded || server.Upgraded || client.requests != 2 {
+		t.Errorf("got client upgraded %v, server upgraded %v, %d requests", client.Upgraded, server.Upgraded, client.requests)
+	}
+}
+
+func TestStreamLimits(t *testing.T) {
+	s := &Stream{MaxHeadSize: 16}
+	s.Feed([]byte("HTTP/1.1 200 OK\r\nServer: something long"), time.Time{})
+	if s.Err != ErrTooLarge {
+		t.Errorf("got error %v for a long head, want %v", s.Err, ErrTooLarge)
+	}
+
+	s = &Stream{MaxFrameSize: 4}
+	s.Feed([]byte(testUpgradeResponse), time.Time{})
+	s.Feed([]byte{0x82, 0x7f, 0, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff}, time.Time{})
+	s.Feed(make([]byte, 16), time.Time{})
+	if s.Err != ErrTooLarge {
+		t.Errorf("got error %v for a long frame, want %v", s.Err, ErrTooLarge)
+	}
+
+	s = &Stream{MaxFrameSize: 4}
+	s.Feed([]byte(testUpgradeResponse), time.Time{})
+	s.Feed([]byte{0x01, 0x03, 'a', 'b', 'c', 0x80, 0x03, 'd', 'e', 'f'}, time.Time{})
+	if s.Err != ErrTooLarge {
+		t.Errorf("got error %v for a long message, want %v", s.Err, ErrTooLarge)
+	}
+}
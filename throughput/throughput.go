@@ -0,0 +1,254 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package throughput rolls packets up into bandwidth time series: the
+// bytes and packets seen in consecutive buckets of a fixed duration, in
+// total and broken down by protocol, by VLAN and for the busiest flows.
+//
+// Buckets are aligned to multiples of their duration and handed out as
+// soon as they are complete, ready to be written to a time series
+// database or dashboard:
+//
+//  r := throughput.New(time.Second, 10)
+//  r.OnBucket = func(b *throughput.Bucket) {
+//  	fmt.Printf("%v %.0f bit/s\n", b.Start, 8*b.Total.BytesPerSecond(b.Duration))
+//  }
+//  r.Run(source, done)
+//
+// Buckets are only complete once a later packet arrives, or, for live
+// captures, once Tick is called after the end of the bucket; Run does so
+// periodically.
+package throughput
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/flowtable"
+	"github.com/google/gopacket/layers"
+)
+
+// Counter counts packets and bytes.
+type Counter struct {
+	Packets, Bytes uint64
+}
+
+func (c *Counter) add(bytes int) {
+	c.Packets++
+	c.Bytes += uint64(bytes)
+}
+
+// PacketsPerSecond returns the packet rate of c over d.
+func (c Counter) PacketsPerSecond(d time.Duration) float64 {
+	return float64(c.Packets) / d.Seconds()
+}
+
+// BytesPerSecond returns the byte rate of c over d.
+func (c Counter) BytesPerSecond(d time.Duration) float64 {
+	return float64(c.Bytes) / d.Seconds()
+}
+
+// FlowCounter counts the traffic of one flow. The key is the same for both
+// directions of a connection.
+type FlowCounter struct {
+	Key flowtable.Key
+	Counter
+}
+
+// Bucket holds the traffic seen during one bucket of the time series.
+type Bucket struct {
+	Start    time.Time
+	Duration time.Duration
+	Total    Counter
+	// Protocols breaks the traffic down by the LayerType returned by the
+	// Rollup's Protocol function.
+	Protocols map[gopacket.LayerType]Counter
+	// VLANs breaks the traffic down by the outermost 802.1Q VLAN ID; 0
+	// counts untagged traffic.
+	VLANs map[uint16]Counter
+	// TopFlows holds the busiest flows by bytes, busiest first.
+	TopFlows []FlowCounter
+}
+
+// End returns the end of the bucket.
+func (b *Bucket) End() time.Time {
+	return b.Start.Add(b.Duration)
+}
+
+// Protocol returns the highest layer of packet that was decoded, ignoring
+// payload, fragment and decode failure layers. It is the default protocol
+// classification of a Rollup, counting for example TCP segments without a
+// decoded application layer as TCP and DNS messages as DNS.
+func Protocol(packet gopacket.Packet) gopacket.LayerType {
+	ls := packet.Layers()
+	for i := len(ls) - 1; i >= 0; i-- {
+		switch t := ls[i].LayerType(); t {
+		case gopacket.LayerTypePayload, gopacket.LayerTypeFragment, gopacket.LayerTypeDecodeFailure:
+		default:
+			return t
+		}
+	}
+	return gopacket.LayerTypeZero
+}
+
+// Rollup accumulates packets into buckets. A Rollup is not safe for
+// concurrent use.
+type Rollup struct {
+	// OnBucket is called with each bucket when it is complete. Buckets
+	// without any packets are handed out too, so the series has no gaps,
+	// unless a gap spans more than 1024 buckets, as after a jump of the
+	// clock.
+	OnBucket func(*Bucket)
+	// Protocol classifies packets for Bucket.Protocols. If nil, the
+	// Protocol function of this package is used.
+	Protocol func(gopacket.Packet) gopacket.LayerType
+
+	bucket   time.Duration
+	topFlows int
+
+	cur   *Bucket
+	flows map[flowtable.Key]*Counter
+}
+
+// New returns a Rollup with buckets of the given duration which keeps the
+// topFlows busiest flows of each bucket.
+func New(bucket time.Duration, topFlows int) *Rollup {
+	if bucket <= 0 {
+		bucket = time.Second
+	}
+	return &Rollup{bucket: bucket, topFlows: topFlows}
+}
+
+// Add counts packet in the bucket of its timestamp. The length of the
+// packet on the wire is counted if known, else the length captured.
+// Packets older than the current bucket are counted in it.
+func (r *Rollup) Add(packet gopacket.Packet) {
+	md := packet.Metadata()
+	r.Tick(md.Timestamp)
+	if r.cur == nil {
+		r.start(md.Timestamp.Truncate(r.bucket))
+	}
+	length := md.Length
+	if length == 0 {
+		length = len(packet.Data())
+	}
+	b := r.cur
+	b.Total.add(length)
+
+	protocol := r.Protocol
+	if protocol == nil {
+		protocol = Protocol
+	}
+	pt := protocol(packet)
+	pc := b.Protocols[pt]
+	pc.add(length)
+	b.Protocols[pt] = pc
+
+	var vlan uint16
+	if d, ok := packet.Layer(layers.LayerTypeDot1Q).(*layers.Dot1Q); ok {
+		vlan = d.VLANIdentifier
+	}
+	vc := b.VLANs[vlan]
+	vc.add(length)
+	b.VLANs[vlan] = vc
+
+	if r.topFlows > 0 {
+		if key, ok := flowtable.PacketKey(packet, false); ok {
+			fc := r.flows[key]
+			if fc == nil {
+				fc = &Counter{}
+				r.flows[key] = fc
+			}
+			fc.add(length)
+		}
+	}
+}
+
+func (r *Rollup) start(t time.Time) {
+	r.cur = &Bucket{
+		Start:     t,
+		Duration:  r.bucket,
+		Protocols: make(map[gopacket.LayerType]Counter),
+		VLANs:     make(map[uint16]Counter),
+	}
+	r.flows = make(map[flowtable.Key]*Counter)
+}
+
+// maxEmptyBuckets bounds the number of empty buckets handed out for a gap
+// between packets. Longer gaps are skipped.
+const maxEmptyBuckets = 1024
+
+// Tick hands out the buckets which ended at or before now. Call it
+// periodically with the current time when counting live traffic, so
+// buckets are complete even when no packets arrive.
+func (r *Rollup) Tick(now time.Time) {
+	for r.cur != nil && !now.Before(r.cur.End()) {
+		next := r.cur.End()
+		r.finish()
+		if n := now.Sub(next) / r.bucket; n > maxEmptyBuckets {
+			next = next.Add(n * r.bucket)
+		}
+		r.start(next)
+	}
+}
+
+// Flush hands out the current bucket, which may be incomplete, and starts
+// over.
+func (r *Rollup) Flush() {
+	if r.cur != nil {
+		r.finish()
+		r.cur = nil
+	}
+}
+
+func (r *Rollup) finish() {
+	b := r.cur
+	if r.topFlows > 0 && len(r.flows) > 0 {
+		flows := make([]FlowCounter, 0, len(r.flows))
+		for k, c := range r.flows {
+			flows = append(flows, FlowCounter{Key: k, Counter: *c})
+		}
+		sort.Slice(flows, func(i, j int) bool {
+			if flows[i].Bytes != flows[j].Bytes {
+				return flows[i].Bytes > flows[j].Bytes
+			}
+			return flows[i].Packets > flows[j].Packets
+		})
+		if len(flows) > r.topFlows {
+			flows = flows[:r.topFlows]
+		}
+		b.TopFlows = flows
+	}
+	if r.OnBucket != nil {
+		r.OnBucket(b)
+	}
+}
+
+// Run counts the packets of source until it is exhausted or done is
+// closed, calling Tick with the current time once per bucket duration. It
+// flushes the last bucket before returning. Run is meant for live
+// captures, whose packet timestamps follow the local clock.
+func (r *Rollup) Run(source *gopacket.PacketSource, done <-chan struct{}) {
+	ticker := time.NewTicker(r.bucket)
+	defer ticker.Stop()
+	packets := source.Packets()
+	for {
+		select {
+		case packet, ok := <-packets:
+			if !ok {
+				r.Flush()
+				return
+			}
+			r.Add(packet)
+		case now := <-ticker.C:
+			r.Tick(now)
+		case <-done:
+			r.Flush()
+			return
+		}
+	}
+}
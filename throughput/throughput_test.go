@@ -0,0 +1,115 @@
+// Copyright 2021 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package throughput
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var testStart = time.Unix(1600000000, 0)
+
+func testPacket(t *testing.T, at time.Duration, vlan uint16, sport layers.UDPPort, size int) gopacket.Packet {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4}
+	ls := []gopacket.SerializableLayer{eth}
+	if vlan != 0 {
+		eth.EthernetType = layers.EthernetTypeDot1Q
+		ls = append(ls, &layers.Dot1Q{VLANIdentifier: vlan, Type: layers.EthernetTypeIPv4})
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP,
+		SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &layers.UDP{SrcPort: sport, DstPort: 5000}
+	ls = append(ls, ip, udp, gopacket.Payload(make([]byte, 100)))
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	p.Metadata().Timestamp = testStart.Add(at)
+	p.Metadata().Length = size
+	return p
+}
+
+func TestRollup(t *testing.T) {
+	var buckets []*Bucket
+	r := New(time.Second, 1)
+	r.OnBucket = func(b *Bucket) { buckets = append(buckets, b) }
+
+	r.Add(testPacket(t, 100*time.Millisecond, 0, 1000, 1000))
+	r.Add(testPacket(t, 200*time.Millisecond, 10, 1001, 500))
+	r.Add(testPacket(t, 900*time.Millisecond, 10, 1001, 700))
+	if len(buckets) != 0 {
+		t.Fatal("bucket handed out early")
+	}
+	// Skips an empty bucket.
+	r.Add(testPacket(t, 2500*time.Millisecond, 0, 1000, 100))
+	if len(buckets) != 2 {
+		t.Fatalf("got %d buckets, want 2", len(buckets))
+	}
+	b := buckets[0]
+	if !b.Start.Equal(testStart) || b.Duration != time.Second {
+		t.Errorf("got bucket at %v of %v", b.Start, b.Duration)
+	}
+	if b.Total != (Counter{3, 2200}) || b.Total.BytesPerSecond(b.Duration) != 2200 {
+		t.Errorf("got total %+v", b.Total)
+	}
+	if got := b.Protocols[layers.LayerTypeUDP]; got != b.Total || len(b.Protocols) != 1 {
+		t.Errorf("got protocols %v", b.Protocols)
+	}
+	if b.VLANs[0] != (Counter{1, 1000}) || b.VLANs[10] != (Counter{2, 1200}) {
+		t.Errorf("got VLANs %v", b.VLANs)
+	}
+	if len(b.TopFlows) != 1 || b.TopFlows[0].Counter != (Counter{2, 1200}) {
+		t.Errorf("got top flows %+v", b.TopFlows)
+	}
+	if empty := buckets[1]; empty.Total.Packets != 0 || !empty.Start.Equal(testStart.Add(time.Second)) {
+		t.Errorf("got bucket %+v, want empty bucket", empty)
+	}
+
+	// Tick completes the current bucket without new packets.
+	r.Tick(testStart.Add(2999 * time.Millisecond))
+	if len(buckets) != 2 {
+		t.Fatal("bucket handed out early")
+	}
+	r.Tick(testStart.Add(3 * time.Second))
+	if len(buckets) != 3 || buckets[2].Total != (Counter{1, 100}) {
+		t.Fatalf("got %d buckets", len(buckets))
+	}
+	r.Flush()
+	if len(buckets) != 4 || buckets[3].Total.Packets != 0 {
+		t.Errorf("got %d buckets after flush", len(buckets))
+	}
+}
+
+func TestRollupClockJump(t *testing.T) {
+	var buckets []*Bucket
+	r := New(time.Second, 0)
+	r.OnBucket = func(b *Bucket) { buckets = append(buckets, b) }
+
+	r.Add(testPacket(t, 0, 0, 1000, 100))
+	jump := 24 * 365 * time.Hour
+	r.Add(testPacket(t, jump+500*time.Millisecond, 0, 1000, 100))
+	if len(buckets) != 1 || buckets[0].Total.Packets != 1 {
+		t.Fatalf("got %d buckets", len(buckets))
+	}
+	r.Flush()
+	if len(buckets) != 2 || !buckets[1].Start.Equal(testStart.Add(jump)) || buckets[1].Total.Packets != 1 {
+		t.Errorf("got %d buckets, last %+v", len(buckets), buckets[len(buckets)-1])
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	p := testPacket(t, 0, 0, 1000, 0)
+	if got := Protocol(p); got != layers.LayerTypeUDP {
+		t.Errorf("got protocol %v, want UDP", got)
+	}
+}
@@ -49,8 +49,33 @@ type SerializeOptions struct {
 	// ComputeChecksums determines whether, during serialization, layers
 	// should recompute checksums based on their payloads.
 	ComputeChecksums bool
+	// Truncation determines how SerializePacket handles packets which were
+	// truncated when captured, for example by a snaplen. It is ignored by
+	// SerializeLayers.
+	Truncation Truncation
 }
 
+// Truncation is a way of serializing packets which were truncated when
+// captured.
+type Truncation uint8
+
+const (
+	// TruncationIgnore serializes truncated packets like others: their
+	// length fields are left alone unless FixLengths is set, and may no
+	// longer match their data.
+	TruncationIgnore Truncation = iota
+	// TruncationPad appends zeros to truncated packets up to their original
+	// length, so that the length fields of their layers hold. It fails if
+	// the original length of a packet is unknown.
+	TruncationPad
+	// TruncationFixLengths keeps packets truncated, and sets FixLengths for
+	// truncated packets so that their length fields match the captured
+	// data.
+	TruncationFixLengths
+	// TruncationError fails to serialize truncated packets.
+	TruncationError
+)
+
 // SerializeBuffer is a helper used by gopacket for writing out packet layers.
 // SerializeBuffer starts off as an empty []byte.  Subsequent calls to PrependBytes
 // return byte slices before the current Bytes(), AppendBytes returns byte
@@ -205,6 +230,11 @@ func (w *serializeBuffer) PushLayer(l LayerType) {
 //   secondPayload := buf.Bytes()  // contains byte representation of d(e(f)). firstPayload is now invalidated, since the SerializeLayers call Clears buf.
 func SerializeLayers(w SerializeBuffer, opts SerializeOptions, layers ...SerializableLayer) error {
 	w.Clear()
+	return serializeLayers(w, opts, layers)
+}
+
+// serializeLayers writes layers into w, wrapping its current bytes.
+func serializeLayers(w SerializeBuffer, opts SerializeOptions, layers []SerializableLayer) error {
 	for i := len(layers) - 1; i >= 0; i-- {
 		layer := layers[i]
 		err := layer.SerializeTo(w, opts)
@@ -219,6 +249,8 @@ func SerializeLayers(w SerializeBuffer, opts SerializeOptions, layers ...Seriali
 // SerializePacket is a convenience function that calls SerializeLayers
 // on packet's Layers().
 // It returns an error if one of the packet layers is not a SerializableLayer.
+// Packets which were truncated when captured, as told by their metadata,
+// are handled as set by opts.Truncation.
 func SerializePacket(buf SerializeBuffer, opts SerializeOptions, packet Packet) error {
 	sls := []SerializableLayer{}
 	for _, layer := range packet.Layers() {
@@ -228,5 +260,26 @@ func SerializePacket(buf SerializeBuffer, opts SerializeOptions, packet Packet)
 		}
 		sls = append(sls, sl)
 	}
-	return SerializeLayers(buf, opts, sls...)
+	buf.Clear()
+	md := packet.Metadata()
+	if md.Truncated || md.CaptureLength < md.Length {
+		switch opts.Truncation {
+		case TruncationPad:
+			if md.CaptureLength >= md.Length {
+				return fmt.Errorf("cannot pad truncated packet of unknown length")
+			}
+			pad, err := buf.AppendBytes(md.Length - md.CaptureLength)
+			if err != nil {
+				return err
+			}
+			for i := range pad {
+				pad[i] = 0
+			}
+		case TruncationFixLengths:
+			opts.FixLengths = true
+		case TruncationError:
+			return fmt.Errorf("packet truncated to %d of %d bytes", md.CaptureLength, md.Length)
+		}
+	}
+	return serializeLayers(buf, opts, sls)
 }